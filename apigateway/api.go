@@ -0,0 +1,264 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apigateway
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	apigatewayv1 "google.golang.org/api/apigateway/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/apigateway/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/apigateway"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	apiControllerName = "apis.apigateway.gcp.crossplane.io"
+	apiFinalizer      = "finalizer." + apiControllerName
+)
+
+var apiLog = logging.Logger.WithName("controller." + apiControllerName)
+
+// An apiCreateSyncDeleter can create, sync, and delete APIs in an external
+// store - e.g. the GCP API. Each method returns true if the API requires
+// further reconciliation.
+type apiCreateSyncDeleter interface {
+	Create(ctx context.Context, a *v1alpha1.Api) (requeue bool)
+	Sync(ctx context.Context, a *v1alpha1.Api) (requeue bool)
+	Delete(ctx context.Context, a *v1alpha1.Api) (requeue bool)
+}
+
+// apis is an apiCreateSyncDeleter using the GCP API Gateway API.
+type apis struct {
+	client  apigateway.Client
+	project string
+}
+
+// Create creates the API. An API is little more than a name; its behaviour is
+// described by its API configs.
+func (c *apis) Create(ctx context.Context, a *v1alpha1.Api) bool {
+	a.Status.SetConditions(corev1alpha1.Creating())
+
+	parent := parentName(c.project, locationGlobal)
+	id := resourceID(a)
+	desired := &apigatewayv1.ApigatewayApi{
+		DisplayName:    a.Spec.DisplayName,
+		Labels:         a.Spec.Labels,
+		ManagedService: a.Spec.ManagedService,
+	}
+
+	if err := c.client.CreateApi(ctx, parent, id, desired); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		a.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create api")))
+		return true
+	}
+
+	a.Status.ApiName = parent + "/apis/" + id
+	meta.AddFinalizer(a, apiFinalizer)
+	a.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync updates the display name and labels of the API if they differ from its
+// spec, and reports whether it is active.
+func (c *apis) Sync(ctx context.Context, a *v1alpha1.Api) bool {
+	actual, err := c.client.GetApi(ctx, a.Status.ApiName)
+	if err != nil {
+		a.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	a.Status.State = actual.State
+	a.Status.ManagedService = actual.ManagedService
+
+	if actual.DisplayName != a.Spec.DisplayName || !labelsEqual(actual.Labels, a.Spec.Labels) {
+		desired := &apigatewayv1.ApigatewayApi{DisplayName: a.Spec.DisplayName, Labels: a.Spec.Labels}
+		if err := c.client.UpdateApi(ctx, a.Status.ApiName, desired, "displayName,labels"); err != nil {
+			a.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot update api")))
+			return true
+		}
+	}
+
+	return setState(&a.Status.ConditionedStatus, "api", actual.State)
+}
+
+// Delete deletes the API. GCP refuses to delete an API that has API configs,
+// so those must be deleted first.
+func (c *apis) Delete(ctx context.Context, a *v1alpha1.Api) bool {
+	a.Status.SetConditions(corev1alpha1.Deleting())
+
+	if a.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.client.DeleteApi(ctx, a.Status.ApiName); err != nil && !googleapi.IsErrorNotFound(err) {
+			a.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete api")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(a, apiFinalizer)
+	a.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// setState sets the conditions of a resource of the supplied kind in the
+// supplied API Gateway state, and returns true if the resource should be
+// requeued until its state changes.
+func setState(s *corev1alpha1.ConditionedStatus, kind, state string) bool {
+	switch state {
+	case stateActive:
+		s.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+		return false
+	case stateFailed:
+		s.SetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(errors.Errorf("%s is in state %s", kind, state)))
+		return true
+	default:
+		s.SetConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess())
+		return true
+	}
+}
+
+// labelsEqual returns true if the supplied labels are equal. A nil map is
+// equal to an empty one.
+func labelsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// An apiConnecter returns an apiCreateSyncDeleter that can create, sync, and
+// delete APIs with an external store - for example the GCP API.
+type apiConnecter interface {
+	Connect(context.Context, *v1alpha1.Api) (apiCreateSyncDeleter, error)
+}
+
+// apiProviderConnecter is an apiConnecter that returns an
+// apiCreateSyncDeleter authenticated using credentials read from a Crossplane
+// Provider resource.
+type apiProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns an apiCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// Api.
+func (c *apiProviderConnecter) Connect(ctx context.Context, a *v1alpha1.Api) (apiCreateSyncDeleter, error) {
+	client, p, err := c.connect(ctx, a, a.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &apis{client: client, project: p.Spec.ProjectID}, nil
+}
+
+// APIReconciler reconciles APIs read from the Kubernetes API with an external
+// store, typically the GCP API.
+type APIReconciler struct {
+	apiConnecter
+	kube client.Client
+}
+
+// APIController is responsible for adding the API controller and its
+// corresponding reconciler to the manager with any runtime configuration.
+type APIController struct {
+	// DefaultProvider is used by APIs that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new API Controller and adds it to the Manager
+// with default RBAC. The Manager will set fields on the Controller and start
+// it when the Manager is Started.
+func (c *APIController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &APIReconciler{
+		apiConnecter: &apiProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: apigateway.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(apiControllerName).
+		For(&v1alpha1.Api{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listAPIs)).
+		Complete(r)
+}
+
+// Reconcile API Gateway APIs with the GCP API.
+func (r *APIReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	apiLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.ApiKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	a := &v1alpha1.Api{}
+	if err := r.kube.Get(ctx, req.NamespacedName, a); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get api %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, a)
+	if err != nil {
+		a.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, a), "cannot update api %s", req.NamespacedName)
+	}
+
+	// The API has been deleted from the API server. Delete it from GCP.
+	if a.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, a)}, errors.Wrapf(r.kube.Update(ctx, a), "cannot update api %s", req.NamespacedName)
+	}
+
+	// The API is unnamed. Assume it has not been created in GCP.
+	if a.Status.ApiName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, a)}, errors.Wrapf(r.kube.Update(ctx, a), "cannot update api %s", req.NamespacedName)
+	}
+
+	// The API exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, a)}, errors.Wrapf(r.kube.Update(ctx, a), "cannot update api %s", req.NamespacedName)
+}
+
+// listAPIs is a provider.Lister of APIs.
+func listAPIs(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.ApiList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
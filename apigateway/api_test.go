@@ -0,0 +1,330 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apigateway
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	apigatewayv1 "google.golang.org/api/apigateway/v1"
+	"google.golang.org/api/googleapi"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/apigateway/v1alpha1"
+	fakeapigateway "github.com/crossplaneio/crossplane/pkg/clients/gcp/apigateway/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace    = "cool-namespace"
+	name         = "cool-api"
+	uid          = types.UID("definitely-a-uuid")
+	project      = "cool-project"
+	providerName = "cool-gcp"
+)
+
+var (
+	ctx           = context.Background()
+	errorBoom     = errors.New("boom")
+	errorNotFound = &googleapi.Error{Code: http.StatusNotFound}
+	parent        = parentName(project, locationGlobal)
+	testAPIName   = parent + "/apis/" + resourceIDPrefix + string(uid)
+)
+
+// Test that our Reconciler implementations satisfy the Reconciler interface.
+var (
+	_ reconcile.Reconciler = &APIReconciler{}
+	_ reconcile.Reconciler = &APIConfigReconciler{}
+	_ reconcile.Reconciler = &GatewayReconciler{}
+)
+
+type apiModifier func(*v1alpha1.Api)
+
+func withAPIConditions(c ...corev1alpha1.Condition) apiModifier {
+	return func(a *v1alpha1.Api) { a.Status.SetConditions(c...) }
+}
+
+func withAPIFinalizers(f ...string) apiModifier {
+	return func(a *v1alpha1.Api) { a.ObjectMeta.Finalizers = f }
+}
+
+func withAPIReclaimPolicy(r corev1alpha1.ReclaimPolicy) apiModifier {
+	return func(a *v1alpha1.Api) { a.Spec.ReclaimPolicy = r }
+}
+
+func withAPIName(n string) apiModifier {
+	return func(a *v1alpha1.Api) { a.Status.ApiName = n }
+}
+
+func withAPIState(s string) apiModifier {
+	return func(a *v1alpha1.Api) { a.Status.State = s }
+}
+
+func withAPIDisplayName(n string) apiModifier {
+	return func(a *v1alpha1.Api) { a.Spec.DisplayName = n }
+}
+
+func api(am ...apiModifier) *v1alpha1.Api {
+	a := &v1alpha1.Api{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.ApiSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+		},
+	}
+
+	for _, m := range am {
+		m(a)
+	}
+
+	return a
+}
+
+func TestAPICreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         apiCreateSyncDeleter
+		api         *v1alpha1.Api
+		want        *v1alpha1.Api
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &apis{project: project, client: &fakeapigateway.MockClient{
+				MockCreateApi: func(_ context.Context, p, _ string, a *apigatewayv1.ApigatewayApi) error {
+					if p != parent {
+						t.Errorf("CreateApi(...): want parent %s, got %s", parent, p)
+					}
+					if a.DisplayName != "Cool API" {
+						t.Errorf("CreateApi(...): want display name %q, got %q", "Cool API", a.DisplayName)
+					}
+					return nil
+				},
+			}},
+			api: api(withAPIDisplayName("Cool API")),
+			want: api(
+				withAPIDisplayName("Cool API"),
+				withAPIFinalizers(apiFinalizer),
+				withAPIName(testAPIName),
+				withAPIConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &apis{project: project, client: &fakeapigateway.MockClient{
+				MockCreateApi: func(_ context.Context, _, _ string, _ *apigatewayv1.ApigatewayApi) error { return errorBoom },
+			}},
+			api: api(),
+			want: api(
+				withAPIConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create api"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.api)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.api, test.EquateConditions()); diff != "" {
+				t.Errorf("api: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAPISync(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         apiCreateSyncDeleter
+		api         *v1alpha1.Api
+		want        *v1alpha1.Api
+		wantRequeue bool
+	}{
+		{
+			name: "Active",
+			csd: &apis{client: &fakeapigateway.MockClient{
+				MockGetApi: func(_ context.Context, _ string) (*apigatewayv1.ApigatewayApi, error) {
+					return &apigatewayv1.ApigatewayApi{State: stateActive}, nil
+				},
+			}},
+			api: api(withAPIName(testAPIName)),
+			want: api(
+				withAPIName(testAPIName),
+				withAPIState(stateActive),
+				withAPIConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "DisplayNameChanged",
+			csd: &apis{client: &fakeapigateway.MockClient{
+				MockGetApi: func(_ context.Context, _ string) (*apigatewayv1.ApigatewayApi, error) {
+					return &apigatewayv1.ApigatewayApi{State: stateActive, DisplayName: "Old API"}, nil
+				},
+				MockUpdateApi: func(_ context.Context, _ string, a *apigatewayv1.ApigatewayApi, mask string) error {
+					if a.DisplayName != "Cool API" || mask != "displayName,labels" {
+						t.Errorf("UpdateApi(...): want display name %q and mask %q, got %q and %q", "Cool API", "displayName,labels", a.DisplayName, mask)
+					}
+					return nil
+				},
+			}},
+			api: api(withAPIName(testAPIName), withAPIDisplayName("Cool API")),
+			want: api(
+				withAPIName(testAPIName),
+				withAPIDisplayName("Cool API"),
+				withAPIState(stateActive),
+				withAPIConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "Failed",
+			csd: &apis{client: &fakeapigateway.MockClient{
+				MockGetApi: func(_ context.Context, _ string) (*apigatewayv1.ApigatewayApi, error) {
+					return &apigatewayv1.ApigatewayApi{State: stateFailed}, nil
+				},
+			}},
+			api: api(withAPIName(testAPIName)),
+			want: api(
+				withAPIName(testAPIName),
+				withAPIState(stateFailed),
+				withAPIConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(errors.New("api is in state FAILED"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGet",
+			csd: &apis{client: &fakeapigateway.MockClient{
+				MockGetApi: func(_ context.Context, _ string) (*apigatewayv1.ApigatewayApi, error) { return nil, errorBoom },
+			}},
+			api: api(withAPIName(testAPIName)),
+			want: api(
+				withAPIName(testAPIName),
+				withAPIConditions(corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.api)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.api, test.EquateConditions()); diff != "" {
+				t.Errorf("api: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAPIDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         apiCreateSyncDeleter
+		api         *v1alpha1.Api
+		want        *v1alpha1.Api
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimDeleteNotFound",
+			csd: &apis{client: &fakeapigateway.MockClient{
+				MockDeleteApi: func(_ context.Context, _ string) error { return errorNotFound },
+			}},
+			api: api(
+				withAPIName(testAPIName),
+				withAPIFinalizers(apiFinalizer),
+				withAPIReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: api(
+				withAPIName(testAPIName),
+				withAPIReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withAPIConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailed",
+			csd: &apis{client: &fakeapigateway.MockClient{
+				MockDeleteApi: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			api: api(
+				withAPIName(testAPIName),
+				withAPIFinalizers(apiFinalizer),
+				withAPIReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: api(
+				withAPIName(testAPIName),
+				withAPIFinalizers(apiFinalizer),
+				withAPIReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withAPIConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot delete api"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "ReclaimRetain",
+			csd:  &apis{client: &fakeapigateway.MockClient{}},
+			api: api(
+				withAPIName(testAPIName),
+				withAPIFinalizers(apiFinalizer),
+				withAPIReclaimPolicy(corev1alpha1.ReclaimRetain),
+			),
+			want: api(
+				withAPIName(testAPIName),
+				withAPIReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withAPIConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.api)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.api, test.EquateConditions()); diff != "" {
+				t.Errorf("api: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,402 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apigateway
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	apigatewayv1 "google.golang.org/api/apigateway/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/apigateway/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/apigateway"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/shard"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	apiConfigControllerName = "apiconfigs.apigateway.gcp.crossplane.io"
+	apiConfigFinalizer      = "finalizer." + apiConfigControllerName
+
+	// configHashLength is the number of hex characters of the hash of an API
+	// config's documents that are appended to its ID.
+	configHashLength = 8
+
+	mapTimeout = 30 * time.Second
+)
+
+var apiConfigLog = logging.Logger.WithName("controller." + apiConfigControllerName)
+
+// An apiConfigCreateSyncDeleter can create, sync, and delete API configs in
+// an external store - e.g. the GCP API. Each method returns true if the API
+// config requires further reconciliation.
+type apiConfigCreateSyncDeleter interface {
+	Create(ctx context.Context, c *v1alpha1.ApiConfig) (requeue bool)
+	Sync(ctx context.Context, c *v1alpha1.ApiConfig) (requeue bool)
+	Delete(ctx context.Context, c *v1alpha1.ApiConfig) (requeue bool)
+}
+
+// apiConfigs is an apiConfigCreateSyncDeleter using the GCP API Gateway API.
+type apiConfigs struct {
+	client  apigateway.Client
+	kube    client.Client
+	project string
+}
+
+// Create creates an API config from the OpenAPI documents read from the
+// referenced ConfigMaps. Any referenced Api must be available before the API
+// config is created.
+func (c *apiConfigs) Create(ctx context.Context, cfg *v1alpha1.ApiConfig) bool {
+	cfg.Status.SetConditions(corev1alpha1.Creating())
+
+	if err := c.create(ctx, cfg); err != nil {
+		cfg.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	meta.AddFinalizer(cfg, apiConfigFinalizer)
+	cfg.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// create creates a new API config, and records its name and the hash of its
+// documents.
+func (c *apiConfigs) create(ctx context.Context, cfg *v1alpha1.ApiConfig) error {
+	api, err := resolveAPI(ctx, c.kube, c.project, cfg)
+	if err != nil {
+		return err
+	}
+
+	docs, err := openAPIDocuments(ctx, c.kube, cfg)
+	if err != nil {
+		return err
+	}
+
+	hash := configHash(cfg.Spec.GatewayServiceAccount, docs)
+	id := resourceID(cfg) + "-" + hash
+	desired := &apigatewayv1.ApigatewayApiConfig{
+		DisplayName:           cfg.Spec.DisplayName,
+		Labels:                cfg.Spec.Labels,
+		GatewayServiceAccount: cfg.Spec.GatewayServiceAccount,
+		OpenapiDocuments:      docs,
+	}
+
+	if err := c.client.CreateApiConfig(ctx, api, id, desired); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		return errors.Wrap(err, "cannot create api config")
+	}
+
+	cfg.Status.ApiConfigName = api + "/configs/" + id
+	cfg.Status.ConfigHash = hash
+	return nil
+}
+
+// Sync creates a new API config if the OpenAPI documents have changed since
+// the current API config was created, because API configs are immutable.
+// Previous API configs are deleted once they are no longer used by a gateway.
+func (c *apiConfigs) Sync(ctx context.Context, cfg *v1alpha1.ApiConfig) bool {
+	docs, err := openAPIDocuments(ctx, c.kube, cfg)
+	if err != nil {
+		cfg.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	if configHash(cfg.Spec.GatewayServiceAccount, docs) != cfg.Status.ConfigHash {
+		previous := cfg.Status.ApiConfigName
+		if err := c.create(ctx, cfg); err != nil {
+			cfg.Status.SetConditions(corev1alpha1.ReconcileError(err))
+			return true
+		}
+		cfg.Status.PreviousApiConfigNames = append(cfg.Status.PreviousApiConfigNames, previous)
+		cfg.Status.SetConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	actual, err := c.client.GetApiConfig(ctx, cfg.Status.ApiConfigName)
+	if err != nil {
+		cfg.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+	cfg.Status.State = actual.State
+
+	requeue := setState(&cfg.Status.ConditionedStatus, "api config", actual.State)
+	if actual.State != stateActive {
+		return requeue
+	}
+
+	// GCP refuses to delete an API config that is used by a gateway. Keep
+	// trying until gateways have moved to the current API config.
+	cfg.Status.PreviousApiConfigNames = c.deleteConfigs(ctx, cfg.Status.PreviousApiConfigNames)
+	return len(cfg.Status.PreviousApiConfigNames) > 0
+}
+
+// Delete deletes the current and any previous API configs.
+func (c *apiConfigs) Delete(ctx context.Context, cfg *v1alpha1.ApiConfig) bool {
+	cfg.Status.SetConditions(corev1alpha1.Deleting())
+
+	if cfg.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		remaining := c.deleteConfigs(ctx, append([]string{cfg.Status.ApiConfigName}, cfg.Status.PreviousApiConfigNames...))
+		if len(remaining) > 0 {
+			cfg.Status.SetConditions(corev1alpha1.ReconcileError(errors.Errorf("cannot delete api configs %v", remaining)))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(cfg, apiConfigFinalizer)
+	cfg.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// deleteConfigs deletes the named API configs, and returns those that could
+// not be deleted.
+func (c *apiConfigs) deleteConfigs(ctx context.Context, names []string) []string {
+	var remaining []string
+	for _, n := range names {
+		if n == "" {
+			continue
+		}
+		if err := c.client.DeleteApiConfig(ctx, n); err != nil && !googleapi.IsErrorNotFound(err) {
+			remaining = append(remaining, n)
+		}
+	}
+	return remaining
+}
+
+// resolveAPI returns the name of the API of the supplied API config, which may
+// either be specified directly or by reference to an Api in the same
+// namespace. It returns an error if a referenced Api is not yet available.
+func resolveAPI(ctx context.Context, kube client.Client, project string, cfg *v1alpha1.ApiConfig) (string, error) {
+	if cfg.Spec.ApiRef == nil {
+		if cfg.Spec.Api == "" {
+			return "", errors.New("one of api or apiRef must be set")
+		}
+		return apiName(project, cfg.Spec.Api), nil
+	}
+
+	a := &v1alpha1.Api{}
+	n := types.NamespacedName{Namespace: cfg.GetNamespace(), Name: cfg.Spec.ApiRef.Name}
+	if err := kube.Get(ctx, n, a); err != nil {
+		return "", errors.Wrapf(err, "cannot get api %s", n)
+	}
+	if a.Status.ApiName == "" || a.Status.GetCondition(corev1alpha1.TypeReady).Status != corev1.ConditionTrue {
+		return "", errors.Errorf("api %s is not yet available", n)
+	}
+	return a.Status.ApiName, nil
+}
+
+// openAPIDocuments returns the OpenAPI documents of the supplied API config,
+// read from the referenced ConfigMap keys. Each document's path is its key.
+func openAPIDocuments(ctx context.Context, kube client.Client, cfg *v1alpha1.ApiConfig) ([]*apigatewayv1.ApigatewayApiConfigOpenApiDocument, error) {
+	if len(cfg.Spec.OpenAPIDocuments) == 0 {
+		return nil, errors.New("at least one openAPIDocument must be set")
+	}
+
+	docs := make([]*apigatewayv1.ApigatewayApiConfigOpenApiDocument, 0, len(cfg.Spec.OpenAPIDocuments))
+	for _, sel := range cfg.Spec.OpenAPIDocuments {
+		cm := &corev1.ConfigMap{}
+		n := types.NamespacedName{Namespace: cfg.GetNamespace(), Name: sel.Name}
+		if err := kube.Get(ctx, n, cm); err != nil {
+			return nil, errors.Wrapf(err, "cannot get configmap %s", n)
+		}
+		d, ok := cm.Data[sel.Key]
+		if !ok {
+			return nil, errors.Errorf("configmap %s has no key %s", n, sel.Key)
+		}
+		docs = append(docs, &apigatewayv1.ApigatewayApiConfigOpenApiDocument{
+			Document: &apigatewayv1.ApigatewayApiConfigFile{
+				Path:     sel.Key,
+				Contents: base64.StdEncoding.EncodeToString([]byte(d)),
+			},
+		})
+	}
+	return docs, nil
+}
+
+// configHash returns a short hash of the supplied service account and
+// documents, which together determine the behaviour of an API config.
+func configHash(serviceAccount string, docs []*apigatewayv1.ApigatewayApiConfigOpenApiDocument) string {
+	parts := []string{serviceAccount}
+	for _, d := range docs {
+		parts = append(parts, d.Document.Path, d.Document.Contents)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])[:configHashLength]
+}
+
+// An apiConfigConnecter returns an apiConfigCreateSyncDeleter that can
+// create, sync, and delete API configs with an external store - for example
+// the GCP API.
+type apiConfigConnecter interface {
+	Connect(context.Context, *v1alpha1.ApiConfig) (apiConfigCreateSyncDeleter, error)
+}
+
+// apiConfigProviderConnecter is an apiConfigConnecter that returns an
+// apiConfigCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type apiConfigProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns an apiConfigCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// ApiConfig.
+func (c *apiConfigProviderConnecter) Connect(ctx context.Context, cfg *v1alpha1.ApiConfig) (apiConfigCreateSyncDeleter, error) {
+	client, p, err := c.connect(ctx, cfg, cfg.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &apiConfigs{client: client, kube: c.kube, project: p.Spec.ProjectID}, nil
+}
+
+// APIConfigReconciler reconciles ApiConfigs read from the Kubernetes API with
+// an external store, typically the GCP API.
+type APIConfigReconciler struct {
+	apiConfigConnecter
+	kube client.Client
+}
+
+// APIConfigController is responsible for adding the ApiConfig controller and
+// its corresponding reconciler to the manager with any runtime configuration.
+type APIConfigController struct {
+	// DefaultProvider is used by API configs that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new ApiConfig Controller and adds it to the
+// Manager with default RBAC. The Manager will set fields on the Controller and
+// start it when the Manager is Started.
+func (c *APIConfigController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &APIConfigReconciler{
+		apiConfigConnecter: &apiConfigProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: apigateway.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(apiConfigControllerName).
+		For(&v1alpha1.ApiConfig{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listAPIConfigs)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, enqueueAPIConfigsForConfigMap(mgr.GetClient())).
+		Complete(r)
+}
+
+// enqueueAPIConfigsForConfigMap returns an event handler that, when a
+// ConfigMap changes, enqueues a reconcile request for each API config in its
+// namespace that reads an OpenAPI document from it.
+func enqueueAPIConfigsForConfigMap(kube client.Client) handler.EventHandler {
+	return &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+			ctx, cancel := context.WithTimeout(context.Background(), mapTimeout)
+			defer cancel()
+			return apiConfigsForConfigMap(ctx, kube, types.NamespacedName{Namespace: o.Meta.GetNamespace(), Name: o.Meta.GetName()})
+		}),
+	}
+}
+
+// apiConfigsForConfigMap returns a reconcile request for each API config of the
+// client's shard that reads an OpenAPI document from the supplied ConfigMap.
+func apiConfigsForConfigMap(ctx context.Context, kube client.Client, cm types.NamespacedName) []reconcile.Request {
+	l := &v1alpha1.ApiConfigList{}
+	if err := kube.List(ctx, l, client.InNamespace(cm.Namespace)); err != nil {
+		return nil
+	}
+
+	reqs := []reconcile.Request{}
+	for i := range l.Items {
+		cfg := &l.Items[i]
+		if !shard.Owns(kube, cfg) {
+			continue
+		}
+		for _, sel := range cfg.Spec.OpenAPIDocuments {
+			if sel.Name == cm.Name {
+				reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cfg.GetNamespace(), Name: cfg.GetName()}})
+				break
+			}
+		}
+	}
+	return reqs
+}
+
+// Reconcile API Gateway API configs with the GCP API.
+func (r *APIConfigReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	apiConfigLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.ApiConfigKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	cfg := &v1alpha1.ApiConfig{}
+	if err := r.kube.Get(ctx, req.NamespacedName, cfg); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get api config %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, cfg)
+	if err != nil {
+		cfg.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, cfg), "cannot update api config %s", req.NamespacedName)
+	}
+
+	// The API config has been deleted from the API server. Delete it from
+	// GCP.
+	if cfg.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, cfg)}, errors.Wrapf(r.kube.Update(ctx, cfg), "cannot update api config %s", req.NamespacedName)
+	}
+
+	// The API config is unnamed. Assume it has not been created in GCP.
+	if cfg.Status.ApiConfigName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, cfg)}, errors.Wrapf(r.kube.Update(ctx, cfg), "cannot update api config %s", req.NamespacedName)
+	}
+
+	// The API config exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, cfg)}, errors.Wrapf(r.kube.Update(ctx, cfg), "cannot update api config %s", req.NamespacedName)
+}
+
+// listAPIConfigs is a provider.Lister of API configs.
+func listAPIConfigs(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.ApiConfigList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,413 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apigateway
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	apigatewayv1 "google.golang.org/api/apigateway/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpapis "github.com/crossplaneio/crossplane/gcp/apis"
+	"github.com/crossplaneio/crossplane/gcp/apis/apigateway/v1alpha1"
+	fakeapigateway "github.com/crossplaneio/crossplane/pkg/clients/gcp/apigateway/fake"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/shard"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	configMapName = "cool-openapi"
+	openAPIKey    = "openapi.yaml"
+	openAPISpec   = "swagger: '2.0'"
+)
+
+var (
+	testDocs = []*apigatewayv1.ApigatewayApiConfigOpenApiDocument{{
+		Document: &apigatewayv1.ApigatewayApiConfigFile{
+			Path:     openAPIKey,
+			Contents: base64.StdEncoding.EncodeToString([]byte(openAPISpec)),
+		},
+	}}
+	testConfigHash    = configHash("", testDocs)
+	testAPIConfigName = testAPIName + "/configs/" + resourceIDPrefix + string(uid) + "-" + testConfigHash
+	oldAPIConfigName  = testAPIName + "/configs/" + resourceIDPrefix + string(uid) + "-0ld0ld00"
+)
+
+func init() {
+	_ = gcpapis.AddToScheme(scheme.Scheme)
+}
+
+type apiConfigModifier func(*v1alpha1.ApiConfig)
+
+func withAPIConfigConditions(c ...corev1alpha1.Condition) apiConfigModifier {
+	return func(cfg *v1alpha1.ApiConfig) { cfg.Status.SetConditions(c...) }
+}
+
+func withAPIConfigFinalizers(f ...string) apiConfigModifier {
+	return func(cfg *v1alpha1.ApiConfig) { cfg.ObjectMeta.Finalizers = f }
+}
+
+func withAPIConfigReclaimPolicy(r corev1alpha1.ReclaimPolicy) apiConfigModifier {
+	return func(cfg *v1alpha1.ApiConfig) { cfg.Spec.ReclaimPolicy = r }
+}
+
+func withAPIConfigName(n, hash string) apiConfigModifier {
+	return func(cfg *v1alpha1.ApiConfig) {
+		cfg.Status.ApiConfigName = n
+		cfg.Status.ConfigHash = hash
+	}
+}
+
+func withAPIConfigState(s string) apiConfigModifier {
+	return func(cfg *v1alpha1.ApiConfig) { cfg.Status.State = s }
+}
+
+func withPreviousAPIConfigNames(n ...string) apiConfigModifier {
+	return func(cfg *v1alpha1.ApiConfig) { cfg.Status.PreviousApiConfigNames = n }
+}
+
+func apiConfig(cm ...apiConfigModifier) *v1alpha1.ApiConfig {
+	cfg := &v1alpha1.ApiConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.ApiConfigSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			ApiConfigParameters: v1alpha1.ApiConfigParameters{
+				ApiRef: &corev1.LocalObjectReference{Name: name},
+				OpenAPIDocuments: []corev1.ConfigMapKeySelector{{
+					LocalObjectReference: corev1.LocalObjectReference{Name: configMapName},
+					Key:                  openAPIKey,
+				}},
+			},
+		},
+	}
+
+	for _, m := range cm {
+		m(cfg)
+	}
+
+	return cfg
+}
+
+// mockGet returns a test.MockClient Get function that returns the supplied Api
+// and a ConfigMap containing the test OpenAPI document.
+func mockGet(a *v1alpha1.Api) func(context.Context, client.ObjectKey, runtime.Object) error {
+	return func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+		switch o := obj.(type) {
+		case *v1alpha1.Api:
+			a.DeepCopyInto(o)
+		case *corev1.ConfigMap:
+			o.Data = map[string]string{openAPIKey: openAPISpec}
+		}
+		return nil
+	}
+}
+
+func TestAPIConfigCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         apiConfigCreateSyncDeleter
+		cfg         *v1alpha1.ApiConfig
+		want        *v1alpha1.ApiConfig
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &apiConfigs{
+				project: project,
+				kube:    &test.MockClient{MockGet: mockGet(api(withAPIName(testAPIName), withAPIConditions(corev1alpha1.Available())))},
+				client: &fakeapigateway.MockClient{
+					MockCreateApiConfig: func(_ context.Context, p, _ string, c *apigatewayv1.ApigatewayApiConfig) error {
+						if p != testAPIName {
+							t.Errorf("CreateApiConfig(...): want parent %s, got %s", testAPIName, p)
+						}
+						if diff := cmp.Diff(testDocs, c.OpenapiDocuments); diff != "" {
+							t.Errorf("CreateApiConfig(...): -want documents, +got:\n%s", diff)
+						}
+						return nil
+					},
+				},
+			},
+			cfg: apiConfig(),
+			want: apiConfig(
+				withAPIConfigFinalizers(apiConfigFinalizer),
+				withAPIConfigName(testAPIConfigName, testConfigHash),
+				withAPIConfigConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "ApiNotAvailable",
+			csd: &apiConfigs{
+				project: project,
+				kube:    &test.MockClient{MockGet: mockGet(api(withAPIName(testAPIName), withAPIConditions(corev1alpha1.Creating())))},
+				client:  &fakeapigateway.MockClient{},
+			},
+			cfg: apiConfig(),
+			want: apiConfig(
+				withAPIConfigConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Errorf("api %s/%s is not yet available", namespace, name))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &apiConfigs{
+				project: project,
+				kube:    &test.MockClient{MockGet: mockGet(api(withAPIName(testAPIName), withAPIConditions(corev1alpha1.Available())))},
+				client: &fakeapigateway.MockClient{
+					MockCreateApiConfig: func(_ context.Context, _, _ string, _ *apigatewayv1.ApigatewayApiConfig) error { return errorBoom },
+				},
+			},
+			cfg: apiConfig(),
+			want: apiConfig(
+				withAPIConfigConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create api config"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.cfg)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.cfg, test.EquateConditions()); diff != "" {
+				t.Errorf("cfg: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAPIConfigSync(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         apiConfigCreateSyncDeleter
+		cfg         *v1alpha1.ApiConfig
+		want        *v1alpha1.ApiConfig
+		wantRequeue bool
+	}{
+		{
+			name: "Active",
+			csd: &apiConfigs{
+				kube: &test.MockClient{MockGet: mockGet(api())},
+				client: &fakeapigateway.MockClient{
+					MockGetApiConfig: func(_ context.Context, _ string) (*apigatewayv1.ApigatewayApiConfig, error) {
+						return &apigatewayv1.ApigatewayApiConfig{State: stateActive}, nil
+					},
+				},
+			},
+			cfg: apiConfig(withAPIConfigName(testAPIConfigName, testConfigHash)),
+			want: apiConfig(
+				withAPIConfigName(testAPIConfigName, testConfigHash),
+				withAPIConfigState(stateActive),
+				withPreviousAPIConfigNames(),
+				withAPIConfigConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "DocumentsChanged",
+			csd: &apiConfigs{
+				project: project,
+				kube:    &test.MockClient{MockGet: mockGet(api(withAPIName(testAPIName), withAPIConditions(corev1alpha1.Available())))},
+				client: &fakeapigateway.MockClient{
+					MockCreateApiConfig: func(_ context.Context, _, _ string, _ *apigatewayv1.ApigatewayApiConfig) error { return nil },
+				},
+			},
+			cfg: apiConfig(withAPIConfigName(oldAPIConfigName, "0ld0ld00")),
+			want: apiConfig(
+				withAPIConfigName(testAPIConfigName, testConfigHash),
+				withPreviousAPIConfigNames(oldAPIConfigName),
+				withAPIConfigConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "PreviousConfigDeleted",
+			csd: &apiConfigs{
+				kube: &test.MockClient{MockGet: mockGet(api())},
+				client: &fakeapigateway.MockClient{
+					MockGetApiConfig: func(_ context.Context, _ string) (*apigatewayv1.ApigatewayApiConfig, error) {
+						return &apigatewayv1.ApigatewayApiConfig{State: stateActive}, nil
+					},
+					MockDeleteApiConfig: func(_ context.Context, n string) error {
+						if n != oldAPIConfigName {
+							t.Errorf("DeleteApiConfig(...): want %s, got %s", oldAPIConfigName, n)
+						}
+						return nil
+					},
+				},
+			},
+			cfg: apiConfig(withAPIConfigName(testAPIConfigName, testConfigHash), withPreviousAPIConfigNames(oldAPIConfigName)),
+			want: apiConfig(
+				withAPIConfigName(testAPIConfigName, testConfigHash),
+				withAPIConfigState(stateActive),
+				withPreviousAPIConfigNames(),
+				withAPIConfigConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "PreviousConfigInUse",
+			csd: &apiConfigs{
+				kube: &test.MockClient{MockGet: mockGet(api())},
+				client: &fakeapigateway.MockClient{
+					MockGetApiConfig: func(_ context.Context, _ string) (*apigatewayv1.ApigatewayApiConfig, error) {
+						return &apigatewayv1.ApigatewayApiConfig{State: stateActive}, nil
+					},
+					MockDeleteApiConfig: func(_ context.Context, _ string) error { return errorBoom },
+				},
+			},
+			cfg: apiConfig(withAPIConfigName(testAPIConfigName, testConfigHash), withPreviousAPIConfigNames(oldAPIConfigName)),
+			want: apiConfig(
+				withAPIConfigName(testAPIConfigName, testConfigHash),
+				withAPIConfigState(stateActive),
+				withPreviousAPIConfigNames(oldAPIConfigName),
+				withAPIConfigConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.cfg)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.cfg, test.EquateConditions()); diff != "" {
+				t.Errorf("cfg: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAPIConfigDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         apiConfigCreateSyncDeleter
+		cfg         *v1alpha1.ApiConfig
+		want        *v1alpha1.ApiConfig
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimDeleteSuccessful",
+			csd: &apiConfigs{client: &fakeapigateway.MockClient{
+				MockDeleteApiConfig: func(_ context.Context, _ string) error { return errorNotFound },
+			}},
+			cfg: apiConfig(
+				withAPIConfigName(testAPIConfigName, testConfigHash),
+				withPreviousAPIConfigNames(oldAPIConfigName),
+				withAPIConfigFinalizers(apiConfigFinalizer),
+				withAPIConfigReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: apiConfig(
+				withAPIConfigName(testAPIConfigName, testConfigHash),
+				withPreviousAPIConfigNames(oldAPIConfigName),
+				withAPIConfigReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withAPIConfigConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailed",
+			csd: &apiConfigs{client: &fakeapigateway.MockClient{
+				MockDeleteApiConfig: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			cfg: apiConfig(
+				withAPIConfigName(testAPIConfigName, testConfigHash),
+				withAPIConfigFinalizers(apiConfigFinalizer),
+				withAPIConfigReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: apiConfig(
+				withAPIConfigName(testAPIConfigName, testConfigHash),
+				withAPIConfigFinalizers(apiConfigFinalizer),
+				withAPIConfigReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withAPIConfigConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Errorf("cannot delete api configs %v", []string{testAPIConfigName}))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.cfg)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.cfg, test.EquateConditions()); diff != "" {
+				t.Errorf("cfg: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAPIConfigsForConfigMap(t *testing.T) {
+	other := apiConfig()
+	other.SetName("other-config")
+	other.Spec.OpenAPIDocuments[0].Name = "other-openapi"
+
+	kube := fakeclient.NewFakeClient(apiConfig(), other)
+	got := apiConfigsForConfigMap(ctx, kube, types.NamespacedName{Namespace: namespace, Name: configMapName})
+	want := []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("apiConfigsForConfigMap(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestAPIConfigsForConfigMapSharded(t *testing.T) {
+	other := apiConfig()
+	other.SetName("other-shard-config")
+	other.SetLabels(map[string]string{"team": "other"})
+	cool := apiConfig()
+	cool.SetLabels(map[string]string{"team": "cool"})
+
+	kube, err := shard.NewClient(fakeclient.NewFakeClient(cool, other), shard.Options{Selector: "team=cool"})
+	if err != nil {
+		t.Fatalf("shard.NewClient(...): %s", err)
+	}
+	got := apiConfigsForConfigMap(ctx, kube, types.NamespacedName{Namespace: namespace, Name: configMapName})
+	want := []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("apiConfigsForConfigMap(...): -want, +got:\n%s", diff)
+	}
+}
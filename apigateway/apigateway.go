@@ -0,0 +1,101 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package apigateway contains controllers that manage API Gateway APIs, the
+// API configs that describe them using OpenAPI specs, and the gateways that
+// serve them.
+package apigateway
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/apigateway"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+)
+
+const (
+	reconcileTimeout = 1 * time.Minute
+
+	// APIs and API configs always live in the global location.
+	locationGlobal = "global"
+
+	// resourceIDPrefix is prepended to the UID of a managed resource to form
+	// the ID of its API Gateway resource. IDs must begin with a letter.
+	resourceIDPrefix = "crossplane-"
+
+	stateActive = "ACTIVE"
+	stateFailed = "FAILED"
+)
+
+// providerConnecter returns API Gateway clients authenticated using
+// credentials read from a Crossplane Provider resource.
+type providerConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (apigateway.Client, error)
+}
+
+// connect returns an API Gateway client authenticated using credentials read
+// from the Provider referenced by the supplied managed resource, and that
+// Provider.
+func (c *providerConnecter) connect(ctx context.Context, mg metav1.Object, ref *corev1.ObjectReference) (apigateway.Client, *gcpv1alpha1.Provider, error) {
+	p, err := c.providers.Get(ctx, c.kube, mg, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceAPIGateway)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return client, p, errors.Wrap(err, "cannot create new api gateway client")
+}
+
+// resourceID returns the ID of the API Gateway resource of the supplied
+// managed resource.
+func resourceID(mg metav1.Object) string {
+	return resourceIDPrefix + string(mg.GetUID())
+}
+
+// parentName returns the fully qualified name of the supplied location within
+// the supplied project, e.g. projects/p/locations/global.
+func parentName(project, location string) string {
+	if location == "" {
+		location = locationGlobal
+	}
+	return fmt.Sprintf("projects/%s/locations/%s", project, location)
+}
+
+// apiName returns the fully qualified name of the supplied API, which may be
+// an ID within the supplied project or already fully qualified.
+func apiName(project, api string) string {
+	if strings.HasPrefix(api, "projects/") {
+		return api
+	}
+	return parentName(project, locationGlobal) + "/apis/" + api
+}
@@ -0,0 +1,272 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apigateway
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	apigatewayv1 "google.golang.org/api/apigateway/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/apigateway/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/apigateway"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	gatewayControllerName = "gateways.apigateway.gcp.crossplane.io"
+	gatewayFinalizer      = "finalizer." + gatewayControllerName
+)
+
+var gatewayLog = logging.Logger.WithName("controller." + gatewayControllerName)
+
+// A gatewayCreateSyncDeleter can create, sync, and delete gateways in an
+// external store - e.g. the GCP API. Each method returns true if the gateway
+// requires further reconciliation.
+type gatewayCreateSyncDeleter interface {
+	Create(ctx context.Context, g *v1alpha1.Gateway) (requeue bool)
+	Sync(ctx context.Context, g *v1alpha1.Gateway) (requeue bool)
+	Delete(ctx context.Context, g *v1alpha1.Gateway) (requeue bool)
+}
+
+// gateways is a gatewayCreateSyncDeleter using the GCP API Gateway API.
+type gateways struct {
+	client  apigateway.Client
+	kube    client.Client
+	project string
+}
+
+// Create creates a gateway serving the referenced API config. Any referenced
+// ApiConfig must be available before the gateway is created.
+func (c *gateways) Create(ctx context.Context, g *v1alpha1.Gateway) bool {
+	g.Status.SetConditions(corev1alpha1.Creating())
+
+	cfg, err := resolveAPIConfig(ctx, c.kube, g)
+	if err != nil {
+		g.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	parent := parentName(c.project, g.Spec.Location)
+	id := resourceID(g)
+	desired := &apigatewayv1.ApigatewayGateway{
+		ApiConfig:   cfg,
+		DisplayName: g.Spec.DisplayName,
+		Labels:      g.Spec.Labels,
+	}
+
+	if err := c.client.CreateGateway(ctx, parent, id, desired); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		g.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create gateway")))
+		return true
+	}
+
+	g.Status.GatewayName = parent + "/gateways/" + id
+	meta.AddFinalizer(g, gatewayFinalizer)
+	g.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync moves the gateway to the API config it references if it is serving a
+// different one - for example because a referenced ApiConfig's OpenAPI
+// documents changed - and reports whether it is active.
+func (c *gateways) Sync(ctx context.Context, g *v1alpha1.Gateway) bool {
+	actual, err := c.client.GetGateway(ctx, g.Status.GatewayName)
+	if err != nil {
+		g.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	g.Status.State = actual.State
+	g.Status.DefaultHostname = actual.DefaultHostname
+	g.Status.ApiConfigName = actual.ApiConfig
+
+	cfg, err := resolveAPIConfig(ctx, c.kube, g)
+	if err != nil {
+		g.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	if actual.ApiConfig != cfg {
+		desired := &apigatewayv1.ApigatewayGateway{ApiConfig: cfg}
+		if err := c.client.UpdateGateway(ctx, g.Status.GatewayName, desired, "apiConfig"); err != nil {
+			g.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot update gateway")))
+			return true
+		}
+		g.Status.SetConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	return setState(&g.Status.ConditionedStatus, "gateway", actual.State)
+}
+
+// Delete deletes the gateway.
+func (c *gateways) Delete(ctx context.Context, g *v1alpha1.Gateway) bool {
+	g.Status.SetConditions(corev1alpha1.Deleting())
+
+	if g.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.client.DeleteGateway(ctx, g.Status.GatewayName); err != nil && !googleapi.IsErrorNotFound(err) {
+			g.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete gateway")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(g, gatewayFinalizer)
+	g.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// resolveAPIConfig returns the name of the API config served by the supplied
+// gateway, which may either be specified directly or by reference to an
+// ApiConfig in the same namespace. It returns an error if a referenced
+// ApiConfig is not yet available.
+func resolveAPIConfig(ctx context.Context, kube client.Client, g *v1alpha1.Gateway) (string, error) {
+	if g.Spec.ApiConfigRef == nil {
+		if g.Spec.ApiConfig == "" {
+			return "", errors.New("one of apiConfig or apiConfigRef must be set")
+		}
+		return g.Spec.ApiConfig, nil
+	}
+
+	cfg := &v1alpha1.ApiConfig{}
+	n := types.NamespacedName{Namespace: g.GetNamespace(), Name: g.Spec.ApiConfigRef.Name}
+	if err := kube.Get(ctx, n, cfg); err != nil {
+		return "", errors.Wrapf(err, "cannot get api config %s", n)
+	}
+	if cfg.Status.ApiConfigName == "" || cfg.Status.GetCondition(corev1alpha1.TypeReady).Status != corev1.ConditionTrue {
+		return "", errors.Errorf("api config %s is not yet available", n)
+	}
+	return cfg.Status.ApiConfigName, nil
+}
+
+// A gatewayConnecter returns a gatewayCreateSyncDeleter that can create, sync,
+// and delete gateways with an external store - for example the GCP API.
+type gatewayConnecter interface {
+	Connect(context.Context, *v1alpha1.Gateway) (gatewayCreateSyncDeleter, error)
+}
+
+// gatewayProviderConnecter is a gatewayConnecter that returns a
+// gatewayCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type gatewayProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns a gatewayCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// Gateway.
+func (c *gatewayProviderConnecter) Connect(ctx context.Context, g *v1alpha1.Gateway) (gatewayCreateSyncDeleter, error) {
+	client, p, err := c.connect(ctx, g, g.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &gateways{client: client, kube: c.kube, project: p.Spec.ProjectID}, nil
+}
+
+// GatewayReconciler reconciles Gateways read from the Kubernetes API with an
+// external store, typically the GCP API.
+type GatewayReconciler struct {
+	gatewayConnecter
+	kube client.Client
+}
+
+// GatewayController is responsible for adding the Gateway controller and its
+// corresponding reconciler to the manager with any runtime configuration.
+type GatewayController struct {
+	// DefaultProvider is used by gateways that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new Gateway Controller and adds it to the
+// Manager with default RBAC. The Manager will set fields on the Controller and
+// start it when the Manager is Started.
+func (c *GatewayController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &GatewayReconciler{
+		gatewayConnecter: &gatewayProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: apigateway.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(gatewayControllerName).
+		For(&v1alpha1.Gateway{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listGateways)).
+		Complete(r)
+}
+
+// Reconcile API Gateway gateways with the GCP API.
+func (r *GatewayReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	gatewayLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.GatewayKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	g := &v1alpha1.Gateway{}
+	if err := r.kube.Get(ctx, req.NamespacedName, g); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get gateway %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, g)
+	if err != nil {
+		g.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, g), "cannot update gateway %s", req.NamespacedName)
+	}
+
+	// The gateway has been deleted from the API server. Delete it from GCP.
+	if g.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, g)}, errors.Wrapf(r.kube.Update(ctx, g), "cannot update gateway %s", req.NamespacedName)
+	}
+
+	// The gateway is unnamed. Assume it has not been created in GCP.
+	if g.Status.GatewayName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, g)}, errors.Wrapf(r.kube.Update(ctx, g), "cannot update gateway %s", req.NamespacedName)
+	}
+
+	// The gateway exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, g)}, errors.Wrapf(r.kube.Update(ctx, g), "cannot update gateway %s", req.NamespacedName)
+}
+
+// listGateways is a provider.Lister of gateways.
+func listGateways(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.GatewayList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
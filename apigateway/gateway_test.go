@@ -0,0 +1,323 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apigateway
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	apigatewayv1 "google.golang.org/api/apigateway/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/apigateway/v1alpha1"
+	fakeapigateway "github.com/crossplaneio/crossplane/pkg/clients/gcp/apigateway/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	gatewayLocation = "us-central1"
+	defaultHostname = "cool-gateway-abc123.uc.gateway.dev"
+)
+
+var testGatewayName = parentName(project, gatewayLocation) + "/gateways/" + resourceIDPrefix + string(uid)
+
+type gatewayModifier func(*v1alpha1.Gateway)
+
+func withGatewayConditions(c ...corev1alpha1.Condition) gatewayModifier {
+	return func(g *v1alpha1.Gateway) { g.Status.SetConditions(c...) }
+}
+
+func withGatewayFinalizers(f ...string) gatewayModifier {
+	return func(g *v1alpha1.Gateway) { g.ObjectMeta.Finalizers = f }
+}
+
+func withGatewayReclaimPolicy(r corev1alpha1.ReclaimPolicy) gatewayModifier {
+	return func(g *v1alpha1.Gateway) { g.Spec.ReclaimPolicy = r }
+}
+
+func withGatewayName(n string) gatewayModifier {
+	return func(g *v1alpha1.Gateway) { g.Status.GatewayName = n }
+}
+
+func withGatewayStatus(state, cfg, hostname string) gatewayModifier {
+	return func(g *v1alpha1.Gateway) {
+		g.Status.State = state
+		g.Status.ApiConfigName = cfg
+		g.Status.DefaultHostname = hostname
+	}
+}
+
+func gateway(gm ...gatewayModifier) *v1alpha1.Gateway {
+	g := &v1alpha1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.GatewaySpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			GatewayParameters: v1alpha1.GatewayParameters{
+				Location:     gatewayLocation,
+				ApiConfigRef: &corev1.LocalObjectReference{Name: name},
+			},
+		},
+	}
+
+	for _, m := range gm {
+		m(g)
+	}
+
+	return g
+}
+
+// mockGetAPIConfig returns a test.MockClient Get function that returns the
+// supplied ApiConfig.
+func mockGetAPIConfig(cfg *v1alpha1.ApiConfig) func(context.Context, client.ObjectKey, runtime.Object) error {
+	return func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+		cfg.DeepCopyInto(obj.(*v1alpha1.ApiConfig))
+		return nil
+	}
+}
+
+func TestGatewayCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         gatewayCreateSyncDeleter
+		gw          *v1alpha1.Gateway
+		want        *v1alpha1.Gateway
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &gateways{
+				project: project,
+				kube: &test.MockClient{MockGet: mockGetAPIConfig(apiConfig(
+					withAPIConfigName(testAPIConfigName, testConfigHash),
+					withAPIConfigConditions(corev1alpha1.Available()),
+				))},
+				client: &fakeapigateway.MockClient{
+					MockCreateGateway: func(_ context.Context, p, _ string, g *apigatewayv1.ApigatewayGateway) error {
+						if want := parentName(project, gatewayLocation); p != want {
+							t.Errorf("CreateGateway(...): want parent %s, got %s", want, p)
+						}
+						if g.ApiConfig != testAPIConfigName {
+							t.Errorf("CreateGateway(...): want api config %s, got %s", testAPIConfigName, g.ApiConfig)
+						}
+						return nil
+					},
+				},
+			},
+			gw: gateway(),
+			want: gateway(
+				withGatewayFinalizers(gatewayFinalizer),
+				withGatewayName(testGatewayName),
+				withGatewayConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "ApiConfigNotAvailable",
+			csd: &gateways{
+				project: project,
+				kube: &test.MockClient{MockGet: mockGetAPIConfig(apiConfig(
+					withAPIConfigName(testAPIConfigName, testConfigHash),
+					withAPIConfigConditions(corev1alpha1.Creating()),
+				))},
+				client: &fakeapigateway.MockClient{},
+			},
+			gw: gateway(),
+			want: gateway(
+				withGatewayConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Errorf("api config %s/%s is not yet available", namespace, name))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.gw)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.gw, test.EquateConditions()); diff != "" {
+				t.Errorf("gw: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGatewaySync(t *testing.T) {
+	availableConfig := apiConfig(
+		withAPIConfigName(testAPIConfigName, testConfigHash),
+		withAPIConfigConditions(corev1alpha1.Available()),
+	)
+
+	cases := []struct {
+		name        string
+		csd         gatewayCreateSyncDeleter
+		gw          *v1alpha1.Gateway
+		want        *v1alpha1.Gateway
+		wantRequeue bool
+	}{
+		{
+			name: "Active",
+			csd: &gateways{
+				kube: &test.MockClient{MockGet: mockGetAPIConfig(availableConfig)},
+				client: &fakeapigateway.MockClient{
+					MockGetGateway: func(_ context.Context, _ string) (*apigatewayv1.ApigatewayGateway, error) {
+						return &apigatewayv1.ApigatewayGateway{State: stateActive, ApiConfig: testAPIConfigName, DefaultHostname: defaultHostname}, nil
+					},
+				},
+			},
+			gw: gateway(withGatewayName(testGatewayName)),
+			want: gateway(
+				withGatewayName(testGatewayName),
+				withGatewayStatus(stateActive, testAPIConfigName, defaultHostname),
+				withGatewayConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ApiConfigChanged",
+			csd: &gateways{
+				kube: &test.MockClient{MockGet: mockGetAPIConfig(availableConfig)},
+				client: &fakeapigateway.MockClient{
+					MockGetGateway: func(_ context.Context, _ string) (*apigatewayv1.ApigatewayGateway, error) {
+						return &apigatewayv1.ApigatewayGateway{State: stateActive, ApiConfig: oldAPIConfigName, DefaultHostname: defaultHostname}, nil
+					},
+					MockUpdateGateway: func(_ context.Context, _ string, g *apigatewayv1.ApigatewayGateway, mask string) error {
+						if g.ApiConfig != testAPIConfigName || mask != "apiConfig" {
+							t.Errorf("UpdateGateway(...): want api config %s and mask apiConfig, got %s and %s", testAPIConfigName, g.ApiConfig, mask)
+						}
+						return nil
+					},
+				},
+			},
+			gw: gateway(withGatewayName(testGatewayName)),
+			want: gateway(
+				withGatewayName(testGatewayName),
+				withGatewayStatus(stateActive, oldAPIConfigName, defaultHostname),
+				withGatewayConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedUpdate",
+			csd: &gateways{
+				kube: &test.MockClient{MockGet: mockGetAPIConfig(availableConfig)},
+				client: &fakeapigateway.MockClient{
+					MockGetGateway: func(_ context.Context, _ string) (*apigatewayv1.ApigatewayGateway, error) {
+						return &apigatewayv1.ApigatewayGateway{State: stateActive, ApiConfig: oldAPIConfigName}, nil
+					},
+					MockUpdateGateway: func(_ context.Context, _ string, _ *apigatewayv1.ApigatewayGateway, _ string) error { return errorBoom },
+				},
+			},
+			gw: gateway(withGatewayName(testGatewayName)),
+			want: gateway(
+				withGatewayName(testGatewayName),
+				withGatewayStatus(stateActive, oldAPIConfigName, ""),
+				withGatewayConditions(corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot update gateway"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.gw)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.gw, test.EquateConditions()); diff != "" {
+				t.Errorf("gw: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGatewayDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         gatewayCreateSyncDeleter
+		gw          *v1alpha1.Gateway
+		want        *v1alpha1.Gateway
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimDeleteSuccessful",
+			csd: &gateways{client: &fakeapigateway.MockClient{
+				MockDeleteGateway: func(_ context.Context, _ string) error { return nil },
+			}},
+			gw: gateway(
+				withGatewayName(testGatewayName),
+				withGatewayFinalizers(gatewayFinalizer),
+				withGatewayReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: gateway(
+				withGatewayName(testGatewayName),
+				withGatewayReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withGatewayConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailed",
+			csd: &gateways{client: &fakeapigateway.MockClient{
+				MockDeleteGateway: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			gw: gateway(
+				withGatewayName(testGatewayName),
+				withGatewayFinalizers(gatewayFinalizer),
+				withGatewayReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: gateway(
+				withGatewayName(testGatewayName),
+				withGatewayFinalizers(gatewayFinalizer),
+				withGatewayReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withGatewayConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot delete gateway"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.gw)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.gw, test.EquateConditions()); diff != "" {
+				t.Errorf("gw: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,367 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package apikeys contains a controller that manages GCP API keys, which
+// identify applications that call Google APIs such as Maps or reCAPTCHA
+// Enterprise from browsers and servers.
+package apikeys
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	apikeysv2 "google.golang.org/api/apikeys/v2"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/apikeys/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/apikeys"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/secretgc"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	controllerName   = "keys.apikeys.gcp.crossplane.io"
+	finalizerName    = "finalizer." + controllerName
+	reconcileTimeout = 1 * time.Minute
+
+	// keyIDPrefix is prepended to the UID of a Key to form the ID of its API
+	// key. Key IDs must start with a letter, while UIDs may not.
+	keyIDPrefix = "key-"
+
+	// keyLocation is the location of API keys, which are global.
+	keyLocation = "global"
+)
+
+var log = logging.Logger.WithName("controller." + controllerName)
+
+// A createsyncdeleter can create, sync, and delete API keys in an external
+// store - e.g. the GCP API. Each method returns true if the key requires
+// further reconciliation.
+type createsyncdeleter interface {
+	Create(ctx context.Context, k *v1alpha1.Key) (requeue bool)
+	Sync(ctx context.Context, k *v1alpha1.Key) (requeue bool)
+	Delete(ctx context.Context, k *v1alpha1.Key) (requeue bool)
+}
+
+// keys is a createsyncdeleter using the GCP API Keys API.
+type keys struct {
+	kube    client.Client
+	client  apikeys.Client
+	project string
+}
+
+// Create creates an API key with the desired restrictions.
+func (c *keys) Create(ctx context.Context, k *v1alpha1.Key) bool {
+	k.Status.SetConditions(corev1alpha1.Creating())
+
+	if err := validateKey(k.Spec.KeyParameters); err != nil {
+		// Don't requeue invalid specs; they'll be reconciled again when updated.
+		k.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return false
+	}
+
+	parent := parentName(c.project)
+	id := keyIDPrefix + string(k.GetUID())
+
+	// We may have created the key but failed to record its name.
+	if err := c.client.CreateKey(ctx, parent, id, newKey(k.Spec.KeyParameters)); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		k.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create API key")))
+		return true
+	}
+
+	k.Status.KeyName = parent + "/keys/" + id
+	meta.AddFinalizer(k, finalizerName)
+	k.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync updates the API key if its display name or restrictions differ from
+// its spec, and publishes the key string to the Key's connection secret.
+func (c *keys) Sync(ctx context.Context, k *v1alpha1.Key) bool {
+	actual, err := c.client.GetKey(ctx, k.Status.KeyName)
+	if err != nil {
+		k.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+	k.Status.UID = actual.Uid
+
+	if err := validateKey(k.Spec.KeyParameters); err != nil {
+		k.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return false
+	}
+
+	desired := newKey(k.Spec.KeyParameters)
+	if mask := updateMask(desired, actual); len(mask) > 0 {
+		if err := c.client.PatchKey(ctx, k.Status.KeyName, desired, strings.Join(mask, ",")); err != nil {
+			k.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot update API key")))
+			return true
+		}
+		k.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	ks, err := c.client.GetKeyString(ctx, k.Status.KeyName)
+	if err != nil {
+		k.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot get API key string")))
+		return true
+	}
+	if err := upsertSecret(ctx, c.kube, connectionSecret(k, ks)); err != nil {
+		k.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	k.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete deletes the API key. Deleted keys stop working immediately, but may
+// be restored in the console for 30 days.
+func (c *keys) Delete(ctx context.Context, k *v1alpha1.Key) bool {
+	k.Status.SetConditions(corev1alpha1.Deleting())
+
+	if k.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.client.DeleteKey(ctx, k.Status.KeyName); err != nil && !googleapi.IsErrorNotFound(err) {
+			k.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete API key")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(k, finalizerName)
+	k.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// validateKey returns an error if the supplied parameters restrict a key to
+// both browsers and servers, which the API Keys API does not allow, or allow
+// an API target without naming its service.
+func validateKey(p v1alpha1.KeyParameters) error {
+	r := p.Restrictions
+	if r == nil {
+		return nil
+	}
+	if len(r.AllowedReferrers) > 0 && len(r.AllowedIPs) > 0 {
+		return errors.New("at most one of allowedReferrers or allowedIPs may be specified")
+	}
+	for i, t := range r.AllowedAPIs {
+		if t.Service == "" {
+			return errors.Errorf("allowedAPIs[%d] must specify a service, e.g. maps-backend.googleapis.com", i)
+		}
+	}
+	return nil
+}
+
+// newKey returns the API key described by the supplied parameters.
+func newKey(p v1alpha1.KeyParameters) *apikeysv2.V2Key {
+	k := &apikeysv2.V2Key{DisplayName: p.DisplayName}
+
+	r := p.Restrictions
+	if r == nil {
+		return k
+	}
+
+	k.Restrictions = &apikeysv2.V2Restrictions{}
+	if len(r.AllowedReferrers) > 0 {
+		k.Restrictions.BrowserKeyRestrictions = &apikeysv2.V2BrowserKeyRestrictions{AllowedReferrers: r.AllowedReferrers}
+	}
+	if len(r.AllowedIPs) > 0 {
+		k.Restrictions.ServerKeyRestrictions = &apikeysv2.V2ServerKeyRestrictions{AllowedIps: r.AllowedIPs}
+	}
+	for _, t := range r.AllowedAPIs {
+		k.Restrictions.ApiTargets = append(k.Restrictions.ApiTargets, &apikeysv2.V2ApiTarget{Service: t.Service, Methods: t.Methods})
+	}
+	return k
+}
+
+// updateMask returns the paths of the fields of the actual key that differ
+// from the desired key. Restrictions are compared in full, so that removing
+// them from a Key's spec removes them from its API key.
+func updateMask(desired, actual *apikeysv2.V2Key) []string {
+	mask := []string{}
+	if desired.DisplayName != actual.DisplayName {
+		mask = append(mask, "displayName")
+	}
+	if !compare.Equal(normalizeRestrictions(desired.Restrictions), normalizeRestrictions(actual.Restrictions)) {
+		mask = append(mask, "restrictions")
+	}
+	return mask
+}
+
+// normalizeRestrictions returns nil if the supplied restrictions are empty,
+// which the API Keys API treats as unrestricted.
+func normalizeRestrictions(r *apikeysv2.V2Restrictions) *apikeysv2.V2Restrictions {
+	if r == nil || (r.BrowserKeyRestrictions == nil && r.ServerKeyRestrictions == nil && r.AndroidKeyRestrictions == nil &&
+		r.IosKeyRestrictions == nil && len(r.ApiTargets) == 0) {
+		return nil
+	}
+	return r
+}
+
+// parentName returns the fully qualified name of the location of API keys
+// within the supplied project, e.g. projects/p/locations/global.
+func parentName(project string) string {
+	return fmt.Sprintf("projects/%s/locations/%s", project, keyLocation)
+}
+
+// connectionSecret returns the connection secret of the supplied Key, which
+// contains its key string.
+func connectionSecret(k *v1alpha1.Key, keyString string) *corev1.Secret {
+	s := resource.ConnectionSecretFor(k, v1alpha1.KeyGroupVersionKind)
+	secretgc.Mark(s, k, v1alpha1.KeyGroupVersionKind)
+	s.Data = map[string][]byte{corev1alpha1.ResourceCredentialsTokenKey: []byte(keyString)}
+	return s
+}
+
+func upsertSecret(ctx context.Context, kube client.Client, s *corev1.Secret) error {
+	n := types.NamespacedName{Namespace: s.GetNamespace(), Name: s.GetName()}
+	if err := kube.Get(ctx, n, &corev1.Secret{}); err != nil {
+		if kerrors.IsNotFound(err) {
+			return errors.Wrapf(kube.Create(ctx, s), "cannot create secret %s", n)
+		}
+		return errors.Wrapf(err, "cannot get secret %s", n)
+	}
+	return errors.Wrapf(kube.Update(ctx, s), "cannot update secret %s", n)
+}
+
+// A connecter returns a createsyncdeleter that can create, sync, and delete
+// API keys with an external store - for example the GCP API.
+type connecter interface {
+	Connect(context.Context, *v1alpha1.Key) (createsyncdeleter, error)
+}
+
+// providerConnecter is a connecter that returns a createsyncdeleter
+// authenticated using credentials read from a Crossplane Provider resource.
+type providerConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (apikeys.Client, error)
+}
+
+// Connect returns a createsyncdeleter backed by the GCP API. GCP credentials
+// are read from the Crossplane Provider referenced by the supplied Key.
+func (c *providerConnecter) Connect(ctx context.Context, k *v1alpha1.Key) (createsyncdeleter, error) {
+	p, err := c.providers.Get(ctx, c.kube, k, k.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceAPIKeys, apikeysv2.CloudPlatformScope)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return &keys{kube: c.kube, client: client, project: p.Spec.ProjectID}, errors.Wrap(err, "cannot create new API Keys client")
+}
+
+// Reconciler reconciles Keys read from the Kubernetes API with an external
+// store, typically the GCP API.
+type Reconciler struct {
+	connecter
+	kube client.Client
+}
+
+// KeyController is responsible for adding the API Keys Key controller and its
+// corresponding reconciler to the manager with any runtime configuration.
+type KeyController struct {
+	// DefaultProvider is used by keys that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new Key Controller and adds it to the Manager
+// with default RBAC. The Manager will set fields on the Controller and start
+// it when the Manager is Started.
+func (c *KeyController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &Reconciler{
+		connecter: &providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: apikeys.NewClient,
+		},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerName).
+		For(&v1alpha1.Key{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listKeys)).
+		Complete(r)
+}
+
+// Reconcile API keys with the GCP API.
+func (r *Reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	log.V(logging.Debug).Info("reconciling", "kind", v1alpha1.KeyKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	k := &v1alpha1.Key{}
+	if err := r.kube.Get(ctx, req.NamespacedName, k); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get key %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, k)
+	if err != nil {
+		k.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, k), "cannot update key %s", req.NamespacedName)
+	}
+
+	// The key has been deleted from the API server. Delete from GCP.
+	if k.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, k)}, errors.Wrapf(r.kube.Update(ctx, k), "cannot update key %s", req.NamespacedName)
+	}
+
+	// The key is unnamed. Assume it has not been created in GCP.
+	if k.Status.KeyName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, k)}, errors.Wrapf(r.kube.Update(ctx, k), "cannot update key %s", req.NamespacedName)
+	}
+
+	// The key exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, k)}, errors.Wrapf(r.kube.Update(ctx, k), "cannot update key %s", req.NamespacedName)
+}
+
+// listKeys is a provider.Lister of API keys.
+func listKeys(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.KeyList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,474 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apikeys
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	apikeysv2 "google.golang.org/api/apikeys/v2"
+	"google.golang.org/api/googleapi"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/apikeys/v1alpha1"
+	fakeapikeys "github.com/crossplaneio/crossplane/pkg/clients/gcp/apikeys/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace    = "cool-namespace"
+	name         = "cool-key"
+	uid          = types.UID("definitely-a-uuid")
+	project      = "coolProject"
+	providerName = "cool-gcp"
+	secretName   = "cool-secret"
+	displayName  = "Cool frontend"
+	referrer     = "https://cool.example.com/*"
+	service      = "maps-backend.googleapis.com"
+	apiKeyUID    = "cool-api-key-uid"
+	keyString    = "AIzaSyCoolKeyString"
+)
+
+var (
+	ctx           = context.Background()
+	errorBoom     = errors.New("boom")
+	errorNotFound = &googleapi.Error{Code: http.StatusNotFound}
+	keyName       = parentName(project) + "/keys/" + keyIDPrefix + string(uid)
+)
+
+// Test that our Reconciler implementation satisfies the Reconciler interface.
+var _ reconcile.Reconciler = &Reconciler{}
+
+type keyModifier func(*v1alpha1.Key)
+
+func withConditions(c ...corev1alpha1.Condition) keyModifier {
+	return func(k *v1alpha1.Key) { k.Status.SetConditions(c...) }
+}
+
+func withFinalizers(f ...string) keyModifier {
+	return func(k *v1alpha1.Key) { k.ObjectMeta.Finalizers = f }
+}
+
+func withReclaimPolicy(p corev1alpha1.ReclaimPolicy) keyModifier {
+	return func(k *v1alpha1.Key) { k.Spec.ReclaimPolicy = p }
+}
+
+func withKeyName(n string) keyModifier {
+	return func(k *v1alpha1.Key) { k.Status.KeyName = n }
+}
+
+func withUID(u string) keyModifier {
+	return func(k *v1alpha1.Key) { k.Status.UID = u }
+}
+
+func withRestrictions(r *v1alpha1.KeyRestrictions) keyModifier {
+	return func(k *v1alpha1.Key) { k.Spec.Restrictions = r }
+}
+
+func key(km ...keyModifier) *v1alpha1.Key {
+	k := &v1alpha1.Key{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.KeySpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference:                &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+				WriteConnectionSecretToReference: corev1.LocalObjectReference{Name: secretName},
+			},
+			KeyParameters: v1alpha1.KeyParameters{
+				DisplayName: displayName,
+				Restrictions: &v1alpha1.KeyRestrictions{
+					AllowedReferrers: []string{referrer},
+					AllowedAPIs:      []v1alpha1.APITarget{{Service: service}},
+				},
+			},
+		},
+	}
+
+	for _, m := range km {
+		m(k)
+	}
+
+	return k
+}
+
+// actualKey returns the API key GCP would return for the default spec.
+func actualKey() *apikeysv2.V2Key {
+	return &apikeysv2.V2Key{
+		Name:        keyName,
+		Uid:         apiKeyUID,
+		DisplayName: displayName,
+		Restrictions: &apikeysv2.V2Restrictions{
+			BrowserKeyRestrictions: &apikeysv2.V2BrowserKeyRestrictions{AllowedReferrers: []string{referrer}},
+			ApiTargets:             []*apikeysv2.V2ApiTarget{{Service: service}},
+		},
+	}
+}
+
+func TestCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		k           *v1alpha1.Key
+		want        *v1alpha1.Key
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &keys{project: project, client: &fakeapikeys.MockClient{
+				MockCreateKey: func(_ context.Context, parent, id string, k *apikeysv2.V2Key) error {
+					if want := parentName(project); parent != want {
+						t.Errorf("CreateKey(...): want parent %s, got %s", want, parent)
+					}
+					if want := keyIDPrefix + string(uid); id != want {
+						t.Errorf("CreateKey(...): want ID %s, got %s", want, id)
+					}
+					if k.Restrictions.BrowserKeyRestrictions == nil {
+						t.Errorf("CreateKey(...): want browser key restrictions, got none")
+					}
+					return nil
+				},
+			}},
+			k: key(),
+			want: key(
+				withFinalizers(finalizerName),
+				withKeyName(keyName),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "AlreadyExists",
+			csd: &keys{project: project, client: &fakeapikeys.MockClient{
+				MockCreateKey: func(_ context.Context, _, _ string, _ *apikeysv2.V2Key) error {
+					return &googleapi.Error{Code: http.StatusConflict}
+				},
+			}},
+			k: key(),
+			want: key(
+				withFinalizers(finalizerName),
+				withKeyName(keyName),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "BrowserAndServer",
+			csd:  &keys{project: project, client: &fakeapikeys.MockClient{}},
+			k:    key(withRestrictions(&v1alpha1.KeyRestrictions{AllowedReferrers: []string{referrer}, AllowedIPs: []string{"10.0.0.0/8"}})),
+			want: key(
+				withRestrictions(&v1alpha1.KeyRestrictions{AllowedReferrers: []string{referrer}, AllowedIPs: []string{"10.0.0.0/8"}}),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.New("at most one of allowedReferrers or allowedIPs may be specified"))),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "Failed",
+			csd: &keys{project: project, client: &fakeapikeys.MockClient{
+				MockCreateKey: func(_ context.Context, _, _ string, _ *apikeysv2.V2Key) error { return errorBoom },
+			}},
+			k: key(),
+			want: key(
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create API key"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.k)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.k, test.EquateConditions()); diff != "" {
+				t.Errorf("k: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	secretNotFound := func(_ context.Context, _ client.ObjectKey, _ runtime.Object) error {
+		return kerrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, secretName)
+	}
+
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		k           *v1alpha1.Key
+		want        *v1alpha1.Key
+		wantRequeue bool
+	}{
+		{
+			name: "Available",
+			csd: &keys{
+				project: project,
+				client: &fakeapikeys.MockClient{
+					MockGetKey:       func(_ context.Context, _ string) (*apikeysv2.V2Key, error) { return actualKey(), nil },
+					MockGetKeyString: func(_ context.Context, _ string) (string, error) { return keyString, nil },
+				},
+				kube: &test.MockClient{
+					MockGet: secretNotFound,
+					MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+						s := obj.(*corev1.Secret)
+						if got := string(s.Data[corev1alpha1.ResourceCredentialsTokenKey]); got != keyString {
+							t.Errorf("Create(...): want key string %s, got %s", keyString, got)
+						}
+						return nil
+					},
+				},
+			},
+			k: key(withKeyName(keyName)),
+			want: key(
+				withKeyName(keyName),
+				withUID(apiKeyUID),
+				withConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "RestrictionsRemoved",
+			csd: &keys{project: project, client: &fakeapikeys.MockClient{
+				MockGetKey: func(_ context.Context, _ string) (*apikeysv2.V2Key, error) { return actualKey(), nil },
+				MockPatchKey: func(_ context.Context, _ string, k *apikeysv2.V2Key, mask string) error {
+					if mask != "restrictions" {
+						t.Errorf("PatchKey(...): want mask restrictions, got %s", mask)
+					}
+					if k.Restrictions != nil {
+						t.Errorf("PatchKey(...): want no restrictions, got %v", k.Restrictions)
+					}
+					return nil
+				},
+			}},
+			k: key(withKeyName(keyName), withRestrictions(nil)),
+			want: key(
+				withKeyName(keyName),
+				withRestrictions(nil),
+				withUID(apiKeyUID),
+				withConditions(corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedPatch",
+			csd: &keys{project: project, client: &fakeapikeys.MockClient{
+				MockGetKey:   func(_ context.Context, _ string) (*apikeysv2.V2Key, error) { return actualKey(), nil },
+				MockPatchKey: func(_ context.Context, _ string, _ *apikeysv2.V2Key, _ string) error { return errorBoom },
+			}},
+			k: key(withKeyName(keyName), withRestrictions(nil)),
+			want: key(
+				withKeyName(keyName),
+				withRestrictions(nil),
+				withUID(apiKeyUID),
+				withConditions(corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot update API key"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGetKeyString",
+			csd: &keys{project: project, client: &fakeapikeys.MockClient{
+				MockGetKey:       func(_ context.Context, _ string) (*apikeysv2.V2Key, error) { return actualKey(), nil },
+				MockGetKeyString: func(_ context.Context, _ string) (string, error) { return "", errorBoom },
+			}},
+			k: key(withKeyName(keyName)),
+			want: key(
+				withKeyName(keyName),
+				withUID(apiKeyUID),
+				withConditions(corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot get API key string"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGet",
+			csd: &keys{project: project, client: &fakeapikeys.MockClient{
+				MockGetKey: func(_ context.Context, _ string) (*apikeysv2.V2Key, error) { return nil, errorBoom },
+			}},
+			k: key(withKeyName(keyName)),
+			want: key(
+				withKeyName(keyName),
+				withConditions(corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.k)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.k, test.EquateConditions()); diff != "" {
+				t.Errorf("k: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		k           *v1alpha1.Key
+		want        *v1alpha1.Key
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimRetain",
+			csd:  &keys{project: project, client: &fakeapikeys.MockClient{}},
+			k:    key(withKeyName(keyName), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimRetain)),
+			want: key(
+				withKeyName(keyName),
+				withReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteAlreadyGone",
+			csd: &keys{project: project, client: &fakeapikeys.MockClient{
+				MockDeleteKey: func(_ context.Context, _ string) error { return errorNotFound },
+			}},
+			k: key(withKeyName(keyName), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: key(
+				withKeyName(keyName),
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailed",
+			csd: &keys{project: project, client: &fakeapikeys.MockClient{
+				MockDeleteKey: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			k: key(withKeyName(keyName), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: key(
+				withKeyName(keyName),
+				withFinalizers(finalizerName),
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot delete API key"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.k)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.k, test.EquateConditions()); diff != "" {
+				t.Errorf("k: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateKey(t *testing.T) {
+	cases := map[string]struct {
+		p       v1alpha1.KeyParameters
+		wantErr bool
+	}{
+		"Valid": {
+			p: key().Spec.KeyParameters,
+		},
+		"Unrestricted": {
+			p: v1alpha1.KeyParameters{},
+		},
+		"ServerKey": {
+			p: v1alpha1.KeyParameters{Restrictions: &v1alpha1.KeyRestrictions{AllowedIPs: []string{"10.0.0.0/8"}}},
+		},
+		"BrowserAndServer": {
+			p:       v1alpha1.KeyParameters{Restrictions: &v1alpha1.KeyRestrictions{AllowedReferrers: []string{referrer}, AllowedIPs: []string{"10.0.0.0/8"}}},
+			wantErr: true,
+		},
+		"APIWithoutService": {
+			p:       v1alpha1.KeyParameters{Restrictions: &v1alpha1.KeyRestrictions{AllowedAPIs: []v1alpha1.APITarget{{Methods: []string{"Get*"}}}}},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateKey(tc.p)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("validateKey(...): want error %t, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestUpdateMask(t *testing.T) {
+	cases := map[string]struct {
+		desired *apikeysv2.V2Key
+		actual  *apikeysv2.V2Key
+		want    []string
+	}{
+		"UpToDate": {
+			desired: newKey(key().Spec.KeyParameters),
+			actual:  actualKey(),
+			want:    []string{},
+		},
+		"EmptyRestrictions": {
+			desired: newKey(v1alpha1.KeyParameters{DisplayName: displayName, Restrictions: &v1alpha1.KeyRestrictions{}}),
+			actual:  &apikeysv2.V2Key{DisplayName: displayName},
+			want:    []string{},
+		},
+		"DisplayNameAndReferrersChanged": {
+			desired: newKey(key().Spec.KeyParameters),
+			actual: func() *apikeysv2.V2Key {
+				k := actualKey()
+				k.DisplayName = "Old frontend"
+				k.Restrictions.BrowserKeyRestrictions.AllowedReferrers = []string{"https://old.example.com/*"}
+				return k
+			}(),
+			want: []string{"displayName", "restrictions"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := updateMask(tc.desired, tc.actual)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("updateMask(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
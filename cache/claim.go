@@ -23,12 +23,14 @@ import (
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	cachev1alpha1 "github.com/crossplaneio/crossplane/apis/cache/v1alpha1"
 	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
 	"github.com/crossplaneio/crossplane/gcp/apis/cache/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/shard"
 	"github.com/crossplaneio/crossplane/pkg/resource"
 )
 
@@ -51,7 +53,7 @@ func (c *CloudMemorystoreInstanceClaimController) SetupWithManager(mgr ctrl.Mana
 
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(name).
-		Watches(&source.Kind{Type: &v1alpha1.CloudMemorystoreInstance{}}, &resource.EnqueueRequestForClaim{}).
+		Watches(&source.Kind{Type: &v1alpha1.CloudMemorystoreInstance{}}, shard.FilterRequests(mgr.GetClient(), func() runtime.Object { return &cachev1alpha1.RedisCluster{} }, &resource.EnqueueRequestForClaim{})).
 		For(&cachev1alpha1.RedisCluster{}).
 		WithEventFilter(resource.NewPredicates(resource.HasClassReferenceKind(resource.ClassKind(v1alpha1.CloudMemorystoreInstanceClassGroupVersionKind)))).
 		Complete(r)
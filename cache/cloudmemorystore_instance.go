@@ -21,17 +21,25 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
 	"github.com/crossplaneio/crossplane/gcp/apis/cache/v1alpha1"
+	servicenetworkingv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/servicenetworking/v1alpha1"
 	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
 	"github.com/crossplaneio/crossplane/pkg/clients/gcp/cloudmemorystore"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/refindex"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/secretgc"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/servicenetworking"
 	"github.com/crossplaneio/crossplane/pkg/logging"
 	"github.com/crossplaneio/crossplane/pkg/meta"
 	"github.com/crossplaneio/crossplane/pkg/resource"
@@ -166,26 +174,25 @@ type connecter interface {
 // authenticated using credentials read from a Crossplane Provider resource.
 type providerConnecter struct {
 	kube      client.Client
-	newClient func(ctx context.Context, creds []byte) (cloudmemorystore.Client, error)
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (cloudmemorystore.Client, error)
 }
 
 // Connect returns a createsyncdeleter backed by the GCP API. GCP credentials
 // are read from the Crossplane Provider referenced by the supplied
 // CloudMemorystoreInstance.
 func (c *providerConnecter) Connect(ctx context.Context, i *v1alpha1.CloudMemorystoreInstance) (createsyncdeleter, error) {
-	p := &gcpv1alpha1.Provider{}
-	n := meta.NamespacedNameOf(i.Spec.ProviderReference)
-	if err := c.kube.Get(ctx, n, p); err != nil {
-		return nil, errors.Wrapf(err, "cannot get provider %s", n)
+	p, err := c.providers.Get(ctx, c.kube, i, i.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
 	}
 
-	s := &corev1.Secret{}
-	n = types.NamespacedName{Namespace: p.Namespace, Name: p.Spec.Secret.Name}
-	if err := c.kube.Get(ctx, n, s); err != nil {
-		return nil, errors.Wrapf(err, "cannot get provider secret %s", n)
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceRedis)
+	if err != nil {
+		return nil, err
 	}
 
-	client, err := c.newClient(ctx, s.Data[p.Spec.Secret.Key])
+	client, err := c.newClient(ctx, creds)
 	return &cloudMemorystore{client: client, project: p.Spec.ProjectID}, errors.Wrap(err, "cannot create new CloudMemorystore client")
 }
 
@@ -198,20 +205,31 @@ type Reconciler struct {
 
 // CloudMemorystoreInstanceController is responsible for adding the Cloud Memorystore
 // controller and its corresponding reconciler to the manager with any runtime configuration.
-type CloudMemorystoreInstanceController struct{}
+type CloudMemorystoreInstanceController struct {
+	// DefaultProvider is used by instances that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
 
 // SetupWithManager creates a new CloudMemorystoreInstance Controller and adds it to the
 // Manager with default RBAC. The Manager will set fields on the Controller and
 // start it when the Manager is Started.
 func (c *CloudMemorystoreInstanceController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
 	r := &Reconciler{
-		connecter: &providerConnecter{kube: mgr.GetClient(), newClient: cloudmemorystore.NewClient},
-		kube:      mgr.GetClient(),
+		connecter: &providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: cloudmemorystore.NewClient,
+		},
+		kube: mgr.GetClient(),
 	}
 
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(controllerName).
 		For(&v1alpha1.CloudMemorystoreInstance{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listCloudMemorystoreInstances)).
+		Watches(&source.Kind{Type: &gcpv1alpha1.Provider{}}, refindex.EnqueueRequestsForProvider(mgr.GetClient(), c.DefaultProvider, newCloudMemorystoreInstanceList)).
+		Watches(&source.Kind{Type: &servicenetworkingv1alpha1.Connection{}}, refindex.EnqueueRequestsForDependents(mgr.GetClient(), servicenetworkingv1alpha1.ConnectionGroupVersionKind, newCloudMemorystoreInstanceList)).
 		Complete(r)
 }
 
@@ -243,10 +261,14 @@ func (r *Reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error)
 
 	// The instance is unnamed. Assume it has not been created in GCP.
 	if i.Status.InstanceName == "" {
+		if err := r.resolveConnection(ctx, i); err != nil {
+			i.Status.SetConditions(corev1alpha1.ReconcileError(err))
+			return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, i), "cannot update instance %s", req.NamespacedName)
+		}
 		return reconcile.Result{Requeue: client.Create(ctx, i)}, errors.Wrapf(r.kube.Update(ctx, i), "cannot update instance %s", req.NamespacedName)
 	}
 
-	if err := r.upsertSecret(ctx, connectionSecret(i)); err != nil {
+	if err := upsertSecret(ctx, r.kube, connectionSecret(i)); err != nil {
 		i.Status.SetConditions(corev1alpha1.ReconcileError(err))
 		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, i), "cannot update instance %s", req.NamespacedName)
 	}
@@ -255,20 +277,56 @@ func (r *Reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error)
 	return reconcile.Result{Requeue: client.Sync(ctx, i)}, errors.Wrapf(r.kube.Update(ctx, i), "cannot update instance %s", req.NamespacedName)
 }
 
-func (r *Reconciler) upsertSecret(ctx context.Context, s *corev1.Secret) error {
+// resolveConnection configures the supplied instance to be reached via private
+// services access using its referenced service networking connection, if any.
+func (r *Reconciler) resolveConnection(ctx context.Context, i *v1alpha1.CloudMemorystoreInstance) error {
+	if i.Spec.ConnectionReference == nil {
+		return nil
+	}
+	c, err := servicenetworking.ResolveConnection(ctx, r.kube, i.Spec.ConnectionReference)
+	if err != nil {
+		return err
+	}
+	i.Spec.AuthorizedNetwork = c.Status.Network
+	i.Spec.ConnectMode = v1alpha1.ConnectModePrivateServiceAccess
+	return nil
+}
+
+func upsertSecret(ctx context.Context, kube client.Client, s *corev1.Secret) error {
 	n := types.NamespacedName{Namespace: s.GetNamespace(), Name: s.GetName()}
-	if err := r.kube.Get(ctx, n, &corev1.Secret{}); err != nil {
+	if err := kube.Get(ctx, n, &corev1.Secret{}); err != nil {
 		if kerrors.IsNotFound(err) {
-			return errors.Wrapf(r.kube.Create(ctx, s), "cannot create secret %s", n)
+			return errors.Wrapf(kube.Create(ctx, s), "cannot create secret %s", n)
 		}
 		return errors.Wrapf(err, "cannot get secret %s", n)
 	}
-	return errors.Wrapf(r.kube.Update(ctx, s), "cannot update secret %s", n)
+	return errors.Wrapf(kube.Update(ctx, s), "cannot update secret %s", n)
 }
 
 func connectionSecret(i *v1alpha1.CloudMemorystoreInstance) *corev1.Secret {
 	// TODO(negz): Include the port here too?
 	s := resource.ConnectionSecretFor(i, v1alpha1.CloudMemorystoreInstanceGroupVersionKind)
+	secretgc.Mark(s, i, v1alpha1.CloudMemorystoreInstanceGroupVersionKind)
 	s.Data = map[string][]byte{corev1alpha1.ResourceCredentialsSecretEndpointKey: []byte(i.Status.Endpoint)}
 	return s
 }
+
+// listCloudMemorystoreInstances is a provider.Lister of CloudMemorystore instances.
+func listCloudMemorystoreInstances(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.CloudMemorystoreInstanceList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
+
+// newCloudMemorystoreInstanceList returns an empty list of
+// CloudMemorystoreInstances, into which the dependents of a resource are read
+// from the reference index.
+func newCloudMemorystoreInstanceList() runtime.Object {
+	return &v1alpha1.CloudMemorystoreInstanceList{}
+}
@@ -21,12 +21,14 @@ import (
 	"testing"
 	"time"
 
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/secretgc"
 	"github.com/crossplaneio/crossplane/pkg/meta"
 
 	redisv1 "cloud.google.com/go/redis/apiv1"
 	"github.com/google/go-cmp/cmp"
 	"github.com/googleapis/gax-go"
 	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
 	redisv1pb "google.golang.org/genproto/googleapis/cloud/redis/v1"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
@@ -60,7 +62,7 @@ const (
 	providerName       = "cool-gcp"
 	providerSecretName = "cool-gcp-secret"
 	providerSecretKey  = "credentials.json"
-	providerSecretData = "definitelyjson"
+	providerSecretData = `{"type": "service_account", "client_email": "cool@example.org", "private_key": "definitely-a-key"}`
 
 	connectionSecretName = "cool-connection-secret"
 )
@@ -499,7 +501,7 @@ func TestConnect(t *testing.T) {
 					}
 					return nil
 				}},
-				newClient: func(_ context.Context, _ []byte) (cloudmemorystore.Client, error) {
+				newClient: func(_ context.Context, _ *google.Credentials) (cloudmemorystore.Client, error) {
 					return &fakecloudmemorystore.MockClient{}, nil
 				},
 			},
@@ -512,7 +514,7 @@ func TestConnect(t *testing.T) {
 				kube: &test.MockClient{MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
 					return kerrors.NewNotFound(schema.GroupResource{}, providerName)
 				}},
-				newClient: func(_ context.Context, _ []byte) (cloudmemorystore.Client, error) {
+				newClient: func(_ context.Context, _ *google.Credentials) (cloudmemorystore.Client, error) {
 					return &fakecloudmemorystore.MockClient{}, nil
 				},
 			},
@@ -531,7 +533,7 @@ func TestConnect(t *testing.T) {
 					}
 					return nil
 				}},
-				newClient: func(_ context.Context, _ []byte) (cloudmemorystore.Client, error) {
+				newClient: func(_ context.Context, _ *google.Credentials) (cloudmemorystore.Client, error) {
 					return &fakecloudmemorystore.MockClient{}, nil
 				},
 			},
@@ -550,7 +552,7 @@ func TestConnect(t *testing.T) {
 					}
 					return nil
 				}},
-				newClient: func(_ context.Context, _ []byte) (cloudmemorystore.Client, error) { return nil, errorBoom },
+				newClient: func(_ context.Context, _ *google.Credentials) (cloudmemorystore.Client, error) { return nil, errorBoom },
 			},
 			i:       instance(),
 			want:    &cloudMemorystore{project: project},
@@ -865,6 +867,7 @@ func TestConnectionSecret(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
+			secretgc.Mark(tc.want, tc.i, v1alpha1.CloudMemorystoreInstanceGroupVersionKind)
 			got := connectionSecret(tc.i)
 			if diff := cmp.Diff(tc.want, got); diff != "" {
 				t.Errorf("connectionSecret(...): -want, +got:\n%s", diff)
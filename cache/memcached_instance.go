@@ -0,0 +1,300 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/cache/v1alpha1"
+	servicenetworkingv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/servicenetworking/v1alpha1"
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/memcache"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/refindex"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/secretgc"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/servicenetworking"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+)
+
+const (
+	memcachedControllerName = "memcachedinstances.cache.gcp.crossplane.io"
+	memcachedFinalizerName  = "finalizer." + memcachedControllerName
+)
+
+var memcachedLog = logging.Logger.WithName("controller." + memcachedControllerName)
+
+// A memcachedCreateSyncDeleter can create, sync, and delete Memcached
+// instances in an external store - e.g. the GCP API. Each method returns true
+// if the instance requires further reconciliation.
+type memcachedCreateSyncDeleter interface {
+	Create(ctx context.Context, i *v1alpha1.MemcachedInstance) (requeue bool)
+	Sync(ctx context.Context, i *v1alpha1.MemcachedInstance) (requeue bool)
+	Delete(ctx context.Context, i *v1alpha1.MemcachedInstance) (requeue bool)
+}
+
+// memorystoreMemcached is a memcachedCreateSyncDeleter using the GCP
+// Memorystore for Memcached API.
+type memorystoreMemcached struct {
+	client  memcache.Client
+	project string
+}
+
+func (c *memorystoreMemcached) Create(ctx context.Context, i *v1alpha1.MemcachedInstance) bool {
+	i.Status.SetConditions(corev1alpha1.Creating())
+
+	id := memcache.NewInstanceID(c.project, i)
+	if _, err := c.client.CreateInstance(ctx, memcache.NewCreateInstanceRequest(id, i)); err != nil {
+		i.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	i.Status.InstanceName = id.Instance
+	meta.AddFinalizer(i, memcachedFinalizerName)
+	i.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+func (c *memorystoreMemcached) Sync(ctx context.Context, i *v1alpha1.MemcachedInstance) bool {
+	id := memcache.NewInstanceID(c.project, i)
+	gcpInstance, err := c.client.GetInstance(ctx, memcache.NewGetInstanceRequest(id))
+	if err != nil {
+		i.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	i.Status.State = gcpInstance.GetState().String()
+
+	switch i.Status.State {
+	case v1alpha1.MemcachedStateReady:
+		i.Status.SetConditions(corev1alpha1.Available())
+		resource.SetBindable(i)
+	case v1alpha1.MemcachedStateCreating:
+		i.Status.SetConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess())
+		return true
+	case v1alpha1.MemcachedStateDeleting:
+		i.Status.SetConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess())
+		return false
+	default:
+		i.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	i.Status.DiscoveryEndpoint = gcpInstance.GetDiscoveryEndpoint()
+	i.Status.NodeCount = int(gcpInstance.GetNodeCount())
+	i.Status.ProviderID = gcpInstance.GetName()
+
+	if !memcache.NeedsUpdate(i, gcpInstance) {
+		i.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+		return false
+	}
+
+	if _, err := c.client.UpdateInstance(ctx, memcache.NewUpdateInstanceRequest(id, i)); err != nil {
+		i.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	i.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+func (c *memorystoreMemcached) Delete(ctx context.Context, i *v1alpha1.MemcachedInstance) bool {
+	i.Status.SetConditions(corev1alpha1.Deleting())
+
+	if i.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		id := memcache.NewInstanceID(c.project, i)
+		if _, err := c.client.DeleteInstance(ctx, memcache.NewDeleteInstanceRequest(id)); err != nil {
+			i.Status.SetConditions(corev1alpha1.ReconcileError(err))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(i, memcachedFinalizerName)
+	i.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// A memcachedConnecter returns a memcachedCreateSyncDeleter that can create,
+// sync, and delete Memcached instances with an external store - for example
+// the GCP API.
+type memcachedConnecter interface {
+	Connect(context.Context, *v1alpha1.MemcachedInstance) (memcachedCreateSyncDeleter, error)
+}
+
+// memcachedProviderConnecter is a memcachedConnecter that returns a
+// memcachedCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type memcachedProviderConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (memcache.Client, error)
+}
+
+// Connect returns a memcachedCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// MemcachedInstance.
+func (c *memcachedProviderConnecter) Connect(ctx context.Context, i *v1alpha1.MemcachedInstance) (memcachedCreateSyncDeleter, error) {
+	p, err := c.providers.Get(ctx, c.kube, i, i.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceMemcache)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return &memorystoreMemcached{client: client, project: p.Spec.ProjectID}, errors.Wrap(err, "cannot create new Memcache client")
+}
+
+// MemcachedReconciler reconciles MemcachedInstances read from the Kubernetes
+// API with an external store, typically the GCP API.
+type MemcachedReconciler struct {
+	memcachedConnecter
+	kube client.Client
+}
+
+// MemcachedInstanceController is responsible for adding the Memorystore for
+// Memcached controller and its corresponding reconciler to the manager with
+// any runtime configuration.
+type MemcachedInstanceController struct {
+	// DefaultProvider is used by instances that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new MemcachedInstance Controller and adds it to
+// the Manager with default RBAC. The Manager will set fields on the Controller
+// and start it when the Manager is Started.
+func (c *MemcachedInstanceController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &MemcachedReconciler{
+		memcachedConnecter: &memcachedProviderConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: memcache.NewClient,
+		},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(memcachedControllerName).
+		For(&v1alpha1.MemcachedInstance{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listMemcachedInstances)).
+		Watches(&source.Kind{Type: &gcpv1alpha1.Provider{}}, refindex.EnqueueRequestsForProvider(mgr.GetClient(), c.DefaultProvider, newMemcachedInstanceList)).
+		Watches(&source.Kind{Type: &servicenetworkingv1alpha1.Connection{}}, refindex.EnqueueRequestsForDependents(mgr.GetClient(), servicenetworkingv1alpha1.ConnectionGroupVersionKind, newMemcachedInstanceList)).
+		Complete(r)
+}
+
+// Reconcile Google Memorystore for Memcached resources with the GCP API.
+func (r *MemcachedReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	memcachedLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.MemcachedInstanceKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	i := &v1alpha1.MemcachedInstance{}
+	if err := r.kube.Get(ctx, req.NamespacedName, i); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get instance %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, i)
+	if err != nil {
+		i.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, i), "cannot update instance %s", req.NamespacedName)
+	}
+
+	// The instance has been deleted from the API server. Delete from GCP.
+	if i.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, i)}, errors.Wrapf(r.kube.Update(ctx, i), "cannot update instance %s", req.NamespacedName)
+	}
+
+	// The instance is unnamed. Assume it has not been created in GCP.
+	if i.Status.InstanceName == "" {
+		if err := r.resolveConnection(ctx, i); err != nil {
+			i.Status.SetConditions(corev1alpha1.ReconcileError(err))
+			return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, i), "cannot update instance %s", req.NamespacedName)
+		}
+		return reconcile.Result{Requeue: client.Create(ctx, i)}, errors.Wrapf(r.kube.Update(ctx, i), "cannot update instance %s", req.NamespacedName)
+	}
+
+	if err := upsertSecret(ctx, r.kube, memcachedConnectionSecret(i)); err != nil {
+		i.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, i), "cannot update instance %s", req.NamespacedName)
+	}
+
+	// The instance exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, i)}, errors.Wrapf(r.kube.Update(ctx, i), "cannot update instance %s", req.NamespacedName)
+}
+
+// resolveConnection configures the supplied instance to use the network of its
+// referenced service networking connection, if any.
+func (r *MemcachedReconciler) resolveConnection(ctx context.Context, i *v1alpha1.MemcachedInstance) error {
+	if i.Spec.ConnectionReference == nil {
+		return nil
+	}
+	c, err := servicenetworking.ResolveConnection(ctx, r.kube, i.Spec.ConnectionReference)
+	if err != nil {
+		return err
+	}
+	i.Spec.AuthorizedNetwork = c.Status.Network
+	return nil
+}
+
+// memcachedConnectionSecret publishes the instance's discovery endpoint, which
+// clients use to learn the addresses of individual Memcached nodes.
+func memcachedConnectionSecret(i *v1alpha1.MemcachedInstance) *corev1.Secret {
+	s := resource.ConnectionSecretFor(i, v1alpha1.MemcachedInstanceGroupVersionKind)
+	secretgc.Mark(s, i, v1alpha1.MemcachedInstanceGroupVersionKind)
+	s.Data = map[string][]byte{corev1alpha1.ResourceCredentialsSecretEndpointKey: []byte(i.Status.DiscoveryEndpoint)}
+	return s
+}
+
+// listMemcachedInstances is a provider.Lister of Memcached instances.
+func listMemcachedInstances(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.MemcachedInstanceList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
+
+// newMemcachedInstanceList returns an empty list of MemcachedInstances, into
+// which the dependents of a resource are read from the reference index.
+func newMemcachedInstanceList() runtime.Object {
+	return &v1alpha1.MemcachedInstanceList{}
+}
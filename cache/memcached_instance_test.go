@@ -0,0 +1,339 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"testing"
+
+	memcachev1 "cloud.google.com/go/memcache/apiv1beta2"
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleapis/gax-go"
+	memcachev1pb "google.golang.org/genproto/googleapis/cloud/memcache/v1beta2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/cache/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/memcache"
+	fakememcache "github.com/crossplaneio/crossplane/pkg/clients/gcp/memcache/fake"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/secretgc"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	memcachedInstanceName      = memcache.NamePrefix + "-" + string(uid)
+	memcachedQualifiedName     = "projects/" + project + "/locations/" + region + "/instances/" + memcachedInstanceName
+	memcachedDiscoveryEndpoint = "10.0.0.2:11211"
+	memcachedNodeCount         = 3
+)
+
+type memcachedModifier func(*v1alpha1.MemcachedInstance)
+
+func withMemcachedConditions(c ...corev1alpha1.Condition) memcachedModifier {
+	return func(i *v1alpha1.MemcachedInstance) { i.Status.SetConditions(c...) }
+}
+
+func withMemcachedBindingPhase(p corev1alpha1.BindingPhase) memcachedModifier {
+	return func(i *v1alpha1.MemcachedInstance) { i.Status.SetBindingPhase(p) }
+}
+
+func withMemcachedState(s string) memcachedModifier {
+	return func(i *v1alpha1.MemcachedInstance) { i.Status.State = s }
+}
+
+func withMemcachedFinalizers(f ...string) memcachedModifier {
+	return func(i *v1alpha1.MemcachedInstance) { i.ObjectMeta.Finalizers = f }
+}
+
+func withMemcachedReclaimPolicy(p corev1alpha1.ReclaimPolicy) memcachedModifier {
+	return func(i *v1alpha1.MemcachedInstance) { i.Spec.ReclaimPolicy = p }
+}
+
+func withMemcachedInstanceName(n string) memcachedModifier {
+	return func(i *v1alpha1.MemcachedInstance) { i.Status.InstanceName = n }
+}
+
+func withMemcachedStatus(id, endpoint string, nodes int) memcachedModifier {
+	return func(i *v1alpha1.MemcachedInstance) {
+		i.Status.ProviderID = id
+		i.Status.DiscoveryEndpoint = endpoint
+		i.Status.NodeCount = nodes
+	}
+}
+
+func memcachedInstance(im ...memcachedModifier) *v1alpha1.MemcachedInstance {
+	i := &v1alpha1.MemcachedInstance{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       memcachedInstanceName,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.MemcachedInstanceSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference:                &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+				WriteConnectionSecretToReference: corev1.LocalObjectReference{Name: connectionSecretName},
+			},
+			MemcachedInstanceParameters: v1alpha1.MemcachedInstanceParameters{
+				Region:    region,
+				NodeCount: memcachedNodeCount,
+				NodeConfig: v1alpha1.MemcachedNodeConfig{
+					CPUCount:     1,
+					MemorySizeMB: 1024,
+				},
+				AuthorizedNetwork: authorizedNetwork,
+			},
+		},
+	}
+
+	for _, m := range im {
+		m(i)
+	}
+
+	return i
+}
+
+// Test that our Reconciler implementation satisfies the Reconciler interface.
+var _ reconcile.Reconciler = &MemcachedReconciler{}
+
+func TestMemcachedCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         memcachedCreateSyncDeleter
+		i           *v1alpha1.MemcachedInstance
+		want        *v1alpha1.MemcachedInstance
+		wantRequeue bool
+	}{
+		{
+			name: "SuccessfulCreate",
+			csd: &memorystoreMemcached{client: &fakememcache.MockClient{
+				MockCreateInstance: func(_ context.Context, _ *memcachev1pb.CreateInstanceRequest, _ ...gax.CallOption) (*memcachev1.CreateInstanceOperation, error) {
+					return nil, nil
+				}},
+			},
+			i: memcachedInstance(),
+			want: memcachedInstance(
+				withMemcachedConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+				withMemcachedFinalizers(memcachedFinalizerName),
+				withMemcachedInstanceName(memcachedInstanceName),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &memorystoreMemcached{client: &fakememcache.MockClient{
+				MockCreateInstance: func(_ context.Context, _ *memcachev1pb.CreateInstanceRequest, _ ...gax.CallOption) (*memcachev1.CreateInstanceOperation, error) {
+					return nil, errorBoom
+				}},
+			},
+			i: memcachedInstance(),
+			want: memcachedInstance(
+				withMemcachedConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.i)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.i, test.EquateConditions()); diff != "" {
+				t.Errorf("i: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMemcachedSync(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         memcachedCreateSyncDeleter
+		i           *v1alpha1.MemcachedInstance
+		want        *v1alpha1.MemcachedInstance
+		wantRequeue bool
+	}{
+		{
+			name: "SuccessfulSyncWhileInstanceCreating",
+			csd: &memorystoreMemcached{client: &fakememcache.MockClient{
+				MockGetInstance: func(_ context.Context, _ *memcachev1pb.GetInstanceRequest, _ ...gax.CallOption) (*memcachev1pb.Instance, error) {
+					return &memcachev1pb.Instance{State: memcachev1pb.Instance_CREATING}, nil
+				},
+			}},
+			i: memcachedInstance(withMemcachedInstanceName(memcachedInstanceName)),
+			want: memcachedInstance(
+				withMemcachedInstanceName(memcachedInstanceName),
+				withMemcachedState(v1alpha1.MemcachedStateCreating),
+				withMemcachedConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "SuccessfulSyncWhileInstanceReadyAndDoesNotNeedUpdate",
+			csd: &memorystoreMemcached{client: &fakememcache.MockClient{
+				MockGetInstance: func(_ context.Context, _ *memcachev1pb.GetInstanceRequest, _ ...gax.CallOption) (*memcachev1pb.Instance, error) {
+					return &memcachev1pb.Instance{
+						Name:              memcachedQualifiedName,
+						State:             memcachev1pb.Instance_READY,
+						NodeCount:         memcachedNodeCount,
+						NodeConfig:        &memcachev1pb.Instance_NodeConfig{CpuCount: 1, MemorySizeMb: 1024},
+						DiscoveryEndpoint: memcachedDiscoveryEndpoint,
+					}, nil
+				},
+			}},
+			i: memcachedInstance(withMemcachedInstanceName(memcachedInstanceName)),
+			want: memcachedInstance(
+				withMemcachedInstanceName(memcachedInstanceName),
+				withMemcachedState(v1alpha1.MemcachedStateReady),
+				withMemcachedStatus(memcachedQualifiedName, memcachedDiscoveryEndpoint, memcachedNodeCount),
+				withMemcachedConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+				withMemcachedBindingPhase(corev1alpha1.BindingPhaseUnbound),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedUpdate",
+			csd: &memorystoreMemcached{client: &fakememcache.MockClient{
+				MockGetInstance: func(_ context.Context, _ *memcachev1pb.GetInstanceRequest, _ ...gax.CallOption) (*memcachev1pb.Instance, error) {
+					return &memcachev1pb.Instance{
+						Name:              memcachedQualifiedName,
+						State:             memcachev1pb.Instance_READY,
+						NodeCount:         memcachedNodeCount + 1,
+						NodeConfig:        &memcachev1pb.Instance_NodeConfig{CpuCount: 1, MemorySizeMb: 1024},
+						DiscoveryEndpoint: memcachedDiscoveryEndpoint,
+					}, nil
+				},
+				MockUpdateInstance: func(_ context.Context, _ *memcachev1pb.UpdateInstanceRequest, _ ...gax.CallOption) (*memcachev1.UpdateInstanceOperation, error) {
+					return nil, errorBoom
+				},
+			}},
+			i: memcachedInstance(withMemcachedInstanceName(memcachedInstanceName)),
+			want: memcachedInstance(
+				withMemcachedInstanceName(memcachedInstanceName),
+				withMemcachedState(v1alpha1.MemcachedStateReady),
+				withMemcachedStatus(memcachedQualifiedName, memcachedDiscoveryEndpoint, memcachedNodeCount+1),
+				withMemcachedConditions(corev1alpha1.Available(), corev1alpha1.ReconcileError(errorBoom)),
+				withMemcachedBindingPhase(corev1alpha1.BindingPhaseUnbound),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGet",
+			csd: &memorystoreMemcached{client: &fakememcache.MockClient{
+				MockGetInstance: func(_ context.Context, _ *memcachev1pb.GetInstanceRequest, _ ...gax.CallOption) (*memcachev1pb.Instance, error) {
+					return nil, errorBoom
+				},
+			}},
+			i: memcachedInstance(withMemcachedInstanceName(memcachedInstanceName)),
+			want: memcachedInstance(
+				withMemcachedInstanceName(memcachedInstanceName),
+				withMemcachedConditions(corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.i)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.i, test.EquateConditions()); diff != "" {
+				t.Errorf("i: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMemcachedDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         memcachedCreateSyncDeleter
+		i           *v1alpha1.MemcachedInstance
+		want        *v1alpha1.MemcachedInstance
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimRetainSuccessfulDelete",
+			csd:  &memorystoreMemcached{client: &fakememcache.MockClient{}},
+			i:    memcachedInstance(withMemcachedFinalizers(memcachedFinalizerName), withMemcachedReclaimPolicy(corev1alpha1.ReclaimRetain)),
+			want: memcachedInstance(
+				withMemcachedReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withMemcachedConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailedDelete",
+			csd: &memorystoreMemcached{client: &fakememcache.MockClient{
+				MockDeleteInstance: func(_ context.Context, _ *memcachev1pb.DeleteInstanceRequest, _ ...gax.CallOption) (*memcachev1.DeleteInstanceOperation, error) {
+					return nil, errorBoom
+				}},
+			},
+			i: memcachedInstance(withMemcachedFinalizers(memcachedFinalizerName), withMemcachedReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: memcachedInstance(
+				withMemcachedFinalizers(memcachedFinalizerName),
+				withMemcachedReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withMemcachedConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.i)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.i, test.EquateConditions()); diff != "" {
+				t.Errorf("i: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMemcachedConnectionSecret(t *testing.T) {
+	i := memcachedInstance(withMemcachedStatus(memcachedQualifiedName, memcachedDiscoveryEndpoint, memcachedNodeCount))
+	want := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            connectionSecretName,
+			Namespace:       namespace,
+			OwnerReferences: []metav1.OwnerReference{meta.AsController(meta.ReferenceTo(memcachedInstance(), v1alpha1.MemcachedInstanceGroupVersionKind))},
+		},
+		Data: map[string][]byte{corev1alpha1.ResourceCredentialsSecretEndpointKey: []byte(memcachedDiscoveryEndpoint)},
+	}
+
+	secretgc.Mark(want, i, v1alpha1.MemcachedInstanceGroupVersionKind)
+
+	got := memcachedConnectionSecret(i)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("memcachedConnectionSecret(...): -want, +got:\n%s", diff)
+	}
+}
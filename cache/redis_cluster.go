@@ -0,0 +1,361 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	clusterpb "cloud.google.com/go/redis/cluster/apiv1/clusterpb"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/cache/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/rediscluster"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/secretgc"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+)
+
+const (
+	redisClusterControllerName = "redisclusters.cache.gcp.crossplane.io"
+	redisClusterFinalizerName  = "finalizer." + redisClusterControllerName
+)
+
+// The Memorystore Redis Cluster API rejects updates that change more than one
+// of these fields at a time, so we converge them one by one in this order.
+const (
+	redisClusterFieldShardCount   = "shard_count"
+	redisClusterFieldReplicaCount = "replica_count"
+)
+
+var redisClusterLog = logging.Logger.WithName("controller." + redisClusterControllerName)
+
+// A redisClusterCreateSyncDeleter can create, sync, and delete Redis clusters
+// in an external store - e.g. the GCP API. Each method returns true if the
+// cluster requires further reconciliation.
+type redisClusterCreateSyncDeleter interface {
+	Create(ctx context.Context, c *v1alpha1.RedisCluster) (requeue bool)
+	Sync(ctx context.Context, c *v1alpha1.RedisCluster) (requeue bool)
+	Delete(ctx context.Context, c *v1alpha1.RedisCluster) (requeue bool)
+}
+
+// memorystoreRedisCluster is a redisClusterCreateSyncDeleter using the GCP
+// Memorystore for Redis Cluster API.
+type memorystoreRedisCluster struct {
+	client  rediscluster.Client
+	project string
+}
+
+func (c *memorystoreRedisCluster) Create(ctx context.Context, rc *v1alpha1.RedisCluster) bool {
+	rc.Status.SetConditions(corev1alpha1.Creating())
+
+	id := rediscluster.NewClusterID(c.project, rc)
+	if _, err := c.client.CreateCluster(ctx, rediscluster.NewCreateClusterRequest(id, rc)); err != nil {
+		rc.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	rc.Status.ClusterName = id.Cluster
+	meta.AddFinalizer(rc, redisClusterFinalizerName)
+	rc.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+func (c *memorystoreRedisCluster) Sync(ctx context.Context, rc *v1alpha1.RedisCluster) bool {
+	id := rediscluster.NewClusterID(c.project, rc)
+	gcpCluster, err := c.client.GetCluster(ctx, rediscluster.NewGetClusterRequest(id))
+	if err != nil {
+		rc.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	rc.Status.State = gcpCluster.GetState().String()
+
+	switch rc.Status.State {
+	case v1alpha1.RedisClusterStateActive:
+		rc.Status.SetConditions(corev1alpha1.Available())
+		resource.SetBindable(rc)
+	case v1alpha1.RedisClusterStateCreating:
+		rc.Status.SetConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess())
+		return true
+	case v1alpha1.RedisClusterStateDeleting:
+		rc.Status.SetConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess())
+		return false
+	default:
+		// The cluster is most likely UPDATING, i.e. adding or removing shards
+		// or replicas. Only one such update may be in flight at a time.
+		rc.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	rc.Status.DiscoveryEndpoint = redisClusterDiscoveryEndpoint(gcpCluster)
+	rc.Status.ShardCount = int(gcpCluster.GetShardCount())
+	rc.Status.ReplicaCount = int(gcpCluster.GetReplicaCount())
+	rc.Status.ProviderID = gcpCluster.GetName()
+
+	if err := redisClusterImmutableFieldsMatch(rc, gcpCluster); err != nil {
+		rc.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	if rc.Spec.TransitEncryption {
+		ca, err := c.client.GetClusterCertificateAuthority(ctx, rediscluster.NewGetCertificateAuthorityRequest(id))
+		if err != nil {
+			rc.Status.SetConditions(corev1alpha1.ReconcileError(err))
+			return true
+		}
+		rc.Status.ServerCACertificate = redisClusterServerCA(ca)
+	}
+
+	field := redisClusterUpdateField(rc, gcpCluster)
+	if field == "" {
+		rc.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+		return false
+	}
+
+	if _, err := c.client.UpdateCluster(ctx, rediscluster.NewUpdateClusterRequest(id, rc, field)); err != nil {
+		rc.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	// Requeue so that any remaining field is updated once this update is done.
+	rc.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+func (c *memorystoreRedisCluster) Delete(ctx context.Context, rc *v1alpha1.RedisCluster) bool {
+	rc.Status.SetConditions(corev1alpha1.Deleting())
+
+	if rc.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		id := rediscluster.NewClusterID(c.project, rc)
+		if _, err := c.client.DeleteCluster(ctx, rediscluster.NewDeleteClusterRequest(id)); err != nil {
+			rc.Status.SetConditions(corev1alpha1.ReconcileError(err))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(rc, redisClusterFinalizerName)
+	rc.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// redisClusterUpdateField returns the next field of the supplied cluster that
+// must be updated to match its spec, or an empty string if it is up to date.
+// Shards are converged before replicas.
+func redisClusterUpdateField(rc *v1alpha1.RedisCluster, c *clusterpb.Cluster) string {
+	switch {
+	case int32(rc.Spec.ShardCount) != c.GetShardCount():
+		return redisClusterFieldShardCount
+	case int32(rc.Spec.ReplicaCount) != c.GetReplicaCount():
+		return redisClusterFieldReplicaCount
+	default:
+		return ""
+	}
+}
+
+// redisClusterImmutableFieldsMatch returns an error if the transit encryption
+// or authorization mode of the supplied cluster differ from its spec. Neither
+// can be changed once a cluster has been created.
+func redisClusterImmutableFieldsMatch(rc *v1alpha1.RedisCluster, c *clusterpb.Cluster) error {
+	te := clusterpb.TransitEncryptionMode_TRANSIT_ENCRYPTION_MODE_DISABLED
+	if rc.Spec.TransitEncryption {
+		te = clusterpb.TransitEncryptionMode_TRANSIT_ENCRYPTION_MODE_SERVER_AUTHENTICATION
+	}
+	if c.GetTransitEncryptionMode() != te {
+		return errors.Errorf("cannot change transit encryption mode of Redis cluster %s from %s to %s", c.GetName(), c.GetTransitEncryptionMode(), te)
+	}
+
+	am := clusterpb.AuthorizationMode_AUTH_MODE_DISABLED
+	if rc.Spec.IAMAuthentication {
+		am = clusterpb.AuthorizationMode_AUTH_MODE_IAM_AUTH
+	}
+	if c.GetAuthorizationMode() != am {
+		return errors.Errorf("cannot change authorization mode of Redis cluster %s from %s to %s", c.GetName(), c.GetAuthorizationMode(), am)
+	}
+
+	return nil
+}
+
+// redisClusterDiscoveryEndpoint returns the host:port of the first discovery
+// endpoint of the supplied cluster, or an empty string if it has none yet.
+func redisClusterDiscoveryEndpoint(c *clusterpb.Cluster) string {
+	eps := c.GetDiscoveryEndpoints()
+	if len(eps) == 0 {
+		return ""
+	}
+	return net.JoinHostPort(eps[0].GetAddress(), strconv.Itoa(int(eps[0].GetPort())))
+}
+
+// redisClusterServerCA returns the PEM encoded certificates clients should
+// trust when connecting to a cluster with transit encryption enabled.
+func redisClusterServerCA(ca *clusterpb.CertificateAuthority) string {
+	certs := []string{}
+	for _, chain := range ca.GetManagedServerCa().GetCaCerts() {
+		certs = append(certs, chain.GetCertificates()...)
+	}
+	return strings.Join(certs, "\n")
+}
+
+// A redisClusterConnecter returns a redisClusterCreateSyncDeleter that can
+// create, sync, and delete Redis clusters with an external store - for example
+// the GCP API.
+type redisClusterConnecter interface {
+	Connect(context.Context, *v1alpha1.RedisCluster) (redisClusterCreateSyncDeleter, error)
+}
+
+// redisClusterProviderConnecter is a redisClusterConnecter that returns a
+// redisClusterCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type redisClusterProviderConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (rediscluster.Client, error)
+}
+
+// Connect returns a redisClusterCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// RedisCluster.
+func (c *redisClusterProviderConnecter) Connect(ctx context.Context, rc *v1alpha1.RedisCluster) (redisClusterCreateSyncDeleter, error) {
+	p, err := c.providers.Get(ctx, c.kube, rc, rc.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceRedis)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return &memorystoreRedisCluster{client: client, project: p.Spec.ProjectID}, errors.Wrap(err, "cannot create new Redis Cluster client")
+}
+
+// RedisClusterReconciler reconciles RedisClusters read from the Kubernetes
+// API with an external store, typically the GCP API.
+type RedisClusterReconciler struct {
+	redisClusterConnecter
+	kube client.Client
+}
+
+// RedisClusterController is responsible for adding the Memorystore for Redis
+// Cluster controller and its corresponding reconciler to the manager with any
+// runtime configuration.
+type RedisClusterController struct {
+	// DefaultProvider is used by clusters that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new RedisCluster Controller and adds it to the
+// Manager with default RBAC. The Manager will set fields on the Controller and
+// start it when the Manager is Started.
+func (c *RedisClusterController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &RedisClusterReconciler{
+		redisClusterConnecter: &redisClusterProviderConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: rediscluster.NewClient,
+		},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(redisClusterControllerName).
+		For(&v1alpha1.RedisCluster{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listRedisClusters)).
+		Complete(r)
+}
+
+// Reconcile Google Memorystore for Redis Cluster resources with the GCP API.
+func (r *RedisClusterReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	redisClusterLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.RedisClusterKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	rc := &v1alpha1.RedisCluster{}
+	if err := r.kube.Get(ctx, req.NamespacedName, rc); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get cluster %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, rc)
+	if err != nil {
+		rc.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, rc), "cannot update cluster %s", req.NamespacedName)
+	}
+
+	// The cluster has been deleted from the API server. Delete from GCP.
+	if rc.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, rc)}, errors.Wrapf(r.kube.Update(ctx, rc), "cannot update cluster %s", req.NamespacedName)
+	}
+
+	// The cluster is unnamed. Assume it has not been created in GCP.
+	if rc.Status.ClusterName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, rc)}, errors.Wrapf(r.kube.Update(ctx, rc), "cannot update cluster %s", req.NamespacedName)
+	}
+
+	if err := upsertSecret(ctx, r.kube, redisClusterConnectionSecret(rc)); err != nil {
+		rc.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, rc), "cannot update cluster %s", req.NamespacedName)
+	}
+
+	// The cluster exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, rc)}, errors.Wrapf(r.kube.Update(ctx, rc), "cannot update cluster %s", req.NamespacedName)
+}
+
+// redisClusterConnectionSecret publishes the cluster's discovery endpoint and,
+// when transit encryption is enabled, the CA certificates clients must trust.
+// Clusters using IAM authentication have no static password; clients present
+// an access token for an authorized service account instead.
+func redisClusterConnectionSecret(rc *v1alpha1.RedisCluster) *corev1.Secret {
+	s := resource.ConnectionSecretFor(rc, v1alpha1.RedisClusterGroupVersionKind)
+	secretgc.Mark(s, rc, v1alpha1.RedisClusterGroupVersionKind)
+	s.Data = map[string][]byte{corev1alpha1.ResourceCredentialsSecretEndpointKey: []byte(rc.Status.DiscoveryEndpoint)}
+	if rc.Status.ServerCACertificate != "" {
+		s.Data[corev1alpha1.ResourceCredentialsSecretCAKey] = []byte(rc.Status.ServerCACertificate)
+	}
+	return s
+}
+
+// listRedisClusters is a provider.Lister of Redis clusters.
+func listRedisClusters(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.RedisClusterList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,501 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"testing"
+
+	rediscluster "cloud.google.com/go/redis/cluster/apiv1"
+	clusterpb "cloud.google.com/go/redis/cluster/apiv1/clusterpb"
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleapis/gax-go"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/cache/v1alpha1"
+	redisclusterclient "github.com/crossplaneio/crossplane/pkg/clients/gcp/rediscluster"
+	fakerediscluster "github.com/crossplaneio/crossplane/pkg/clients/gcp/rediscluster/fake"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/secretgc"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	redisClusterName              = redisclusterclient.NamePrefix + "-" + string(uid)
+	redisClusterQualifiedName     = "projects/" + project + "/locations/" + region + "/clusters/" + redisClusterName
+	redisClusterDiscoveryAddress  = "10.0.0.3"
+	redisClusterDiscoveryEndpoint = "10.0.0.3:6379"
+	redisClusterShardCount        = 3
+	redisClusterReplicaCount      = 1
+	redisClusterCA                = "-----BEGIN CERTIFICATE-----\nCA\n-----END CERTIFICATE-----"
+)
+
+type redisClusterModifier func(*v1alpha1.RedisCluster)
+
+func withRedisClusterConditions(c ...corev1alpha1.Condition) redisClusterModifier {
+	return func(rc *v1alpha1.RedisCluster) { rc.Status.SetConditions(c...) }
+}
+
+func withRedisClusterBindingPhase(p corev1alpha1.BindingPhase) redisClusterModifier {
+	return func(rc *v1alpha1.RedisCluster) { rc.Status.SetBindingPhase(p) }
+}
+
+func withRedisClusterState(s string) redisClusterModifier {
+	return func(rc *v1alpha1.RedisCluster) { rc.Status.State = s }
+}
+
+func withRedisClusterFinalizers(f ...string) redisClusterModifier {
+	return func(rc *v1alpha1.RedisCluster) { rc.ObjectMeta.Finalizers = f }
+}
+
+func withRedisClusterReclaimPolicy(p corev1alpha1.ReclaimPolicy) redisClusterModifier {
+	return func(rc *v1alpha1.RedisCluster) { rc.Spec.ReclaimPolicy = p }
+}
+
+func withRedisClusterName(n string) redisClusterModifier {
+	return func(rc *v1alpha1.RedisCluster) { rc.Status.ClusterName = n }
+}
+
+func withRedisClusterTransitEncryption() redisClusterModifier {
+	return func(rc *v1alpha1.RedisCluster) { rc.Spec.TransitEncryption = true }
+}
+
+func withRedisClusterServerCA(ca string) redisClusterModifier {
+	return func(rc *v1alpha1.RedisCluster) { rc.Status.ServerCACertificate = ca }
+}
+
+func withRedisClusterStatus(id, endpoint string, shards, replicas int) redisClusterModifier {
+	return func(rc *v1alpha1.RedisCluster) {
+		rc.Status.ProviderID = id
+		rc.Status.DiscoveryEndpoint = endpoint
+		rc.Status.ShardCount = shards
+		rc.Status.ReplicaCount = replicas
+	}
+}
+
+func redisCluster(rm ...redisClusterModifier) *v1alpha1.RedisCluster {
+	rc := &v1alpha1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       redisClusterName,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.RedisClusterSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference:                &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+				WriteConnectionSecretToReference: corev1.LocalObjectReference{Name: connectionSecretName},
+			},
+			RedisClusterParameters: v1alpha1.RedisClusterParameters{
+				Region:            region,
+				ShardCount:        redisClusterShardCount,
+				ReplicaCount:      redisClusterReplicaCount,
+				IAMAuthentication: true,
+				Network:           authorizedNetwork,
+			},
+		},
+	}
+
+	for _, m := range rm {
+		m(rc)
+	}
+
+	return rc
+}
+
+func gcpRedisCluster(shards, replicas int32, te clusterpb.TransitEncryptionMode) *clusterpb.Cluster {
+	return &clusterpb.Cluster{
+		Name:                  redisClusterQualifiedName,
+		State:                 clusterpb.Cluster_ACTIVE,
+		ShardCount:            &shards,
+		ReplicaCount:          &replicas,
+		TransitEncryptionMode: te,
+		AuthorizationMode:     clusterpb.AuthorizationMode_AUTH_MODE_IAM_AUTH,
+		DiscoveryEndpoints:    []*clusterpb.DiscoveryEndpoint{{Address: redisClusterDiscoveryAddress, Port: 6379}},
+	}
+}
+
+// Test that our Reconciler implementation satisfies the Reconciler interface.
+var _ reconcile.Reconciler = &RedisClusterReconciler{}
+
+func TestRedisClusterCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         redisClusterCreateSyncDeleter
+		rc          *v1alpha1.RedisCluster
+		want        *v1alpha1.RedisCluster
+		wantRequeue bool
+	}{
+		{
+			name: "SuccessfulCreate",
+			csd: &memorystoreRedisCluster{client: &fakerediscluster.MockClient{
+				MockCreateCluster: func(_ context.Context, _ *clusterpb.CreateClusterRequest, _ ...gax.CallOption) (*rediscluster.CreateClusterOperation, error) {
+					return nil, nil
+				}},
+			},
+			rc: redisCluster(),
+			want: redisCluster(
+				withRedisClusterConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+				withRedisClusterFinalizers(redisClusterFinalizerName),
+				withRedisClusterName(redisClusterName),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &memorystoreRedisCluster{client: &fakerediscluster.MockClient{
+				MockCreateCluster: func(_ context.Context, _ *clusterpb.CreateClusterRequest, _ ...gax.CallOption) (*rediscluster.CreateClusterOperation, error) {
+					return nil, errorBoom
+				}},
+			},
+			rc: redisCluster(),
+			want: redisCluster(
+				withRedisClusterConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.rc)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.rc, test.EquateConditions()); diff != "" {
+				t.Errorf("rc: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRedisClusterSync(t *testing.T) {
+	disabled := clusterpb.TransitEncryptionMode_TRANSIT_ENCRYPTION_MODE_DISABLED
+	serverAuth := clusterpb.TransitEncryptionMode_TRANSIT_ENCRYPTION_MODE_SERVER_AUTHENTICATION
+	errImmutable := errors.Errorf("cannot change transit encryption mode of Redis cluster %s from %s to %s", redisClusterQualifiedName, disabled, serverAuth)
+
+	cases := []struct {
+		name        string
+		csd         redisClusterCreateSyncDeleter
+		rc          *v1alpha1.RedisCluster
+		want        *v1alpha1.RedisCluster
+		wantRequeue bool
+	}{
+		{
+			name: "SuccessfulSyncWhileClusterCreating",
+			csd: &memorystoreRedisCluster{client: &fakerediscluster.MockClient{
+				MockGetCluster: func(_ context.Context, _ *clusterpb.GetClusterRequest, _ ...gax.CallOption) (*clusterpb.Cluster, error) {
+					return &clusterpb.Cluster{State: clusterpb.Cluster_CREATING}, nil
+				},
+			}},
+			rc: redisCluster(withRedisClusterName(redisClusterName)),
+			want: redisCluster(
+				withRedisClusterName(redisClusterName),
+				withRedisClusterState(v1alpha1.RedisClusterStateCreating),
+				withRedisClusterConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "SuccessfulSyncWhileClusterUpdating",
+			csd: &memorystoreRedisCluster{client: &fakerediscluster.MockClient{
+				MockGetCluster: func(_ context.Context, _ *clusterpb.GetClusterRequest, _ ...gax.CallOption) (*clusterpb.Cluster, error) {
+					return &clusterpb.Cluster{State: clusterpb.Cluster_UPDATING}, nil
+				},
+			}},
+			rc: redisCluster(withRedisClusterName(redisClusterName)),
+			want: redisCluster(
+				withRedisClusterName(redisClusterName),
+				withRedisClusterState(v1alpha1.RedisClusterStateUpdating),
+				withRedisClusterConditions(corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "SuccessfulSyncWhileClusterActiveAndDoesNotNeedUpdate",
+			csd: &memorystoreRedisCluster{client: &fakerediscluster.MockClient{
+				MockGetCluster: func(_ context.Context, _ *clusterpb.GetClusterRequest, _ ...gax.CallOption) (*clusterpb.Cluster, error) {
+					return gcpRedisCluster(redisClusterShardCount, redisClusterReplicaCount, disabled), nil
+				},
+			}},
+			rc: redisCluster(withRedisClusterName(redisClusterName)),
+			want: redisCluster(
+				withRedisClusterName(redisClusterName),
+				withRedisClusterState(v1alpha1.RedisClusterStateActive),
+				withRedisClusterStatus(redisClusterQualifiedName, redisClusterDiscoveryEndpoint, redisClusterShardCount, redisClusterReplicaCount),
+				withRedisClusterConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+				withRedisClusterBindingPhase(corev1alpha1.BindingPhaseUnbound),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "SuccessfulSyncWithTransitEncryption",
+			csd: &memorystoreRedisCluster{client: &fakerediscluster.MockClient{
+				MockGetCluster: func(_ context.Context, _ *clusterpb.GetClusterRequest, _ ...gax.CallOption) (*clusterpb.Cluster, error) {
+					return gcpRedisCluster(redisClusterShardCount, redisClusterReplicaCount, serverAuth), nil
+				},
+				MockGetClusterCertificateAuthority: func(_ context.Context, _ *clusterpb.GetClusterCertificateAuthorityRequest, _ ...gax.CallOption) (*clusterpb.CertificateAuthority, error) {
+					return &clusterpb.CertificateAuthority{
+						ServerCa: &clusterpb.CertificateAuthority_ManagedServerCa{
+							ManagedServerCa: &clusterpb.CertificateAuthority_ManagedCertificateAuthority{
+								CaCerts: []*clusterpb.CertificateAuthority_ManagedCertificateAuthority_CertChain{{Certificates: []string{redisClusterCA}}},
+							},
+						},
+					}, nil
+				},
+			}},
+			rc: redisCluster(withRedisClusterName(redisClusterName), withRedisClusterTransitEncryption()),
+			want: redisCluster(
+				withRedisClusterName(redisClusterName),
+				withRedisClusterTransitEncryption(),
+				withRedisClusterState(v1alpha1.RedisClusterStateActive),
+				withRedisClusterStatus(redisClusterQualifiedName, redisClusterDiscoveryEndpoint, redisClusterShardCount, redisClusterReplicaCount),
+				withRedisClusterServerCA(redisClusterCA),
+				withRedisClusterConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+				withRedisClusterBindingPhase(corev1alpha1.BindingPhaseUnbound),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "SuccessfulShardCountUpdate",
+			csd: &memorystoreRedisCluster{client: &fakerediscluster.MockClient{
+				MockGetCluster: func(_ context.Context, _ *clusterpb.GetClusterRequest, _ ...gax.CallOption) (*clusterpb.Cluster, error) {
+					return gcpRedisCluster(redisClusterShardCount+1, redisClusterReplicaCount+1, disabled), nil
+				},
+				MockUpdateCluster: func(_ context.Context, r *clusterpb.UpdateClusterRequest, _ ...gax.CallOption) (*rediscluster.UpdateClusterOperation, error) {
+					if diff := cmp.Diff([]string{redisClusterFieldShardCount}, r.GetUpdateMask().GetPaths()); diff != "" {
+						return nil, errors.Errorf("update mask: -want, +got:\n%s", diff)
+					}
+					return nil, nil
+				},
+			}},
+			rc: redisCluster(withRedisClusterName(redisClusterName)),
+			want: redisCluster(
+				withRedisClusterName(redisClusterName),
+				withRedisClusterState(v1alpha1.RedisClusterStateActive),
+				withRedisClusterStatus(redisClusterQualifiedName, redisClusterDiscoveryEndpoint, redisClusterShardCount+1, redisClusterReplicaCount+1),
+				withRedisClusterConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+				withRedisClusterBindingPhase(corev1alpha1.BindingPhaseUnbound),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "SuccessfulReplicaCountUpdate",
+			csd: &memorystoreRedisCluster{client: &fakerediscluster.MockClient{
+				MockGetCluster: func(_ context.Context, _ *clusterpb.GetClusterRequest, _ ...gax.CallOption) (*clusterpb.Cluster, error) {
+					return gcpRedisCluster(redisClusterShardCount, redisClusterReplicaCount+1, disabled), nil
+				},
+				MockUpdateCluster: func(_ context.Context, r *clusterpb.UpdateClusterRequest, _ ...gax.CallOption) (*rediscluster.UpdateClusterOperation, error) {
+					if diff := cmp.Diff([]string{redisClusterFieldReplicaCount}, r.GetUpdateMask().GetPaths()); diff != "" {
+						return nil, errors.Errorf("update mask: -want, +got:\n%s", diff)
+					}
+					return nil, nil
+				},
+			}},
+			rc: redisCluster(withRedisClusterName(redisClusterName)),
+			want: redisCluster(
+				withRedisClusterName(redisClusterName),
+				withRedisClusterState(v1alpha1.RedisClusterStateActive),
+				withRedisClusterStatus(redisClusterQualifiedName, redisClusterDiscoveryEndpoint, redisClusterShardCount, redisClusterReplicaCount+1),
+				withRedisClusterConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+				withRedisClusterBindingPhase(corev1alpha1.BindingPhaseUnbound),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedUpdate",
+			csd: &memorystoreRedisCluster{client: &fakerediscluster.MockClient{
+				MockGetCluster: func(_ context.Context, _ *clusterpb.GetClusterRequest, _ ...gax.CallOption) (*clusterpb.Cluster, error) {
+					return gcpRedisCluster(redisClusterShardCount+1, redisClusterReplicaCount, disabled), nil
+				},
+				MockUpdateCluster: func(_ context.Context, _ *clusterpb.UpdateClusterRequest, _ ...gax.CallOption) (*rediscluster.UpdateClusterOperation, error) {
+					return nil, errorBoom
+				},
+			}},
+			rc: redisCluster(withRedisClusterName(redisClusterName)),
+			want: redisCluster(
+				withRedisClusterName(redisClusterName),
+				withRedisClusterState(v1alpha1.RedisClusterStateActive),
+				withRedisClusterStatus(redisClusterQualifiedName, redisClusterDiscoveryEndpoint, redisClusterShardCount+1, redisClusterReplicaCount),
+				withRedisClusterConditions(corev1alpha1.Available(), corev1alpha1.ReconcileError(errorBoom)),
+				withRedisClusterBindingPhase(corev1alpha1.BindingPhaseUnbound),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "ImmutableTransitEncryptionChanged",
+			csd: &memorystoreRedisCluster{client: &fakerediscluster.MockClient{
+				MockGetCluster: func(_ context.Context, _ *clusterpb.GetClusterRequest, _ ...gax.CallOption) (*clusterpb.Cluster, error) {
+					return gcpRedisCluster(redisClusterShardCount, redisClusterReplicaCount, disabled), nil
+				},
+			}},
+			rc: redisCluster(withRedisClusterName(redisClusterName), withRedisClusterTransitEncryption()),
+			want: redisCluster(
+				withRedisClusterName(redisClusterName),
+				withRedisClusterTransitEncryption(),
+				withRedisClusterState(v1alpha1.RedisClusterStateActive),
+				withRedisClusterStatus(redisClusterQualifiedName, redisClusterDiscoveryEndpoint, redisClusterShardCount, redisClusterReplicaCount),
+				withRedisClusterConditions(corev1alpha1.Available(), corev1alpha1.ReconcileError(errImmutable)),
+				withRedisClusterBindingPhase(corev1alpha1.BindingPhaseUnbound),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGet",
+			csd: &memorystoreRedisCluster{client: &fakerediscluster.MockClient{
+				MockGetCluster: func(_ context.Context, _ *clusterpb.GetClusterRequest, _ ...gax.CallOption) (*clusterpb.Cluster, error) {
+					return nil, errorBoom
+				},
+			}},
+			rc: redisCluster(withRedisClusterName(redisClusterName)),
+			want: redisCluster(
+				withRedisClusterName(redisClusterName),
+				withRedisClusterConditions(corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.rc)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.rc, test.EquateConditions()); diff != "" {
+				t.Errorf("rc: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRedisClusterDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         redisClusterCreateSyncDeleter
+		rc          *v1alpha1.RedisCluster
+		want        *v1alpha1.RedisCluster
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimRetainSuccessfulDelete",
+			csd:  &memorystoreRedisCluster{client: &fakerediscluster.MockClient{}},
+			rc:   redisCluster(withRedisClusterFinalizers(redisClusterFinalizerName), withRedisClusterReclaimPolicy(corev1alpha1.ReclaimRetain)),
+			want: redisCluster(
+				withRedisClusterReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withRedisClusterConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteSuccessfulDelete",
+			csd: &memorystoreRedisCluster{client: &fakerediscluster.MockClient{
+				MockDeleteCluster: func(_ context.Context, _ *clusterpb.DeleteClusterRequest, _ ...gax.CallOption) (*rediscluster.DeleteClusterOperation, error) {
+					return nil, nil
+				}},
+			},
+			rc: redisCluster(withRedisClusterFinalizers(redisClusterFinalizerName), withRedisClusterReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: redisCluster(
+				withRedisClusterReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withRedisClusterConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailedDelete",
+			csd: &memorystoreRedisCluster{client: &fakerediscluster.MockClient{
+				MockDeleteCluster: func(_ context.Context, _ *clusterpb.DeleteClusterRequest, _ ...gax.CallOption) (*rediscluster.DeleteClusterOperation, error) {
+					return nil, errorBoom
+				}},
+			},
+			rc: redisCluster(withRedisClusterFinalizers(redisClusterFinalizerName), withRedisClusterReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: redisCluster(
+				withRedisClusterFinalizers(redisClusterFinalizerName),
+				withRedisClusterReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withRedisClusterConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.rc)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.rc, test.EquateConditions()); diff != "" {
+				t.Errorf("rc: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRedisClusterConnectionSecret(t *testing.T) {
+	cases := []struct {
+		name string
+		rc   *v1alpha1.RedisCluster
+		want map[string][]byte
+	}{
+		{
+			name: "WithoutTransitEncryption",
+			rc:   redisCluster(withRedisClusterStatus(redisClusterQualifiedName, redisClusterDiscoveryEndpoint, redisClusterShardCount, redisClusterReplicaCount)),
+			want: map[string][]byte{corev1alpha1.ResourceCredentialsSecretEndpointKey: []byte(redisClusterDiscoveryEndpoint)},
+		},
+		{
+			name: "WithTransitEncryption",
+			rc: redisCluster(
+				withRedisClusterStatus(redisClusterQualifiedName, redisClusterDiscoveryEndpoint, redisClusterShardCount, redisClusterReplicaCount),
+				withRedisClusterServerCA(redisClusterCA),
+			),
+			want: map[string][]byte{
+				corev1alpha1.ResourceCredentialsSecretEndpointKey: []byte(redisClusterDiscoveryEndpoint),
+				corev1alpha1.ResourceCredentialsSecretCAKey:       []byte(redisClusterCA),
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			want := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            connectionSecretName,
+					Namespace:       namespace,
+					OwnerReferences: []metav1.OwnerReference{meta.AsController(meta.ReferenceTo(redisCluster(), v1alpha1.RedisClusterGroupVersionKind))},
+				},
+				Data: tc.want,
+			}
+
+			secretgc.Mark(want, tc.rc, v1alpha1.RedisClusterGroupVersionKind)
+
+			got := redisClusterConnectionSecret(tc.rc)
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("redisClusterConnectionSecret(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
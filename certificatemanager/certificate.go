@@ -0,0 +1,282 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificatemanager
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	cmv1 "google.golang.org/api/certificatemanager/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/certificatemanager/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/certificatemanager"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	certificateControllerName = "certificates.certificatemanager.gcp.crossplane.io"
+	certificateFinalizer      = "finalizer." + certificateControllerName
+
+	certificateStateActive = "ACTIVE"
+	certificateStateFailed = "FAILED"
+)
+
+var certificateLog = logging.Logger.WithName("controller." + certificateControllerName)
+
+// A certificateCreateSyncDeleter can create, sync, and delete certificates in
+// an external store - e.g. the GCP API. Each method returns true if the
+// certificate requires further reconciliation.
+type certificateCreateSyncDeleter interface {
+	Create(ctx context.Context, c *v1alpha1.Certificate) (requeue bool)
+	Sync(ctx context.Context, c *v1alpha1.Certificate) (requeue bool)
+	Delete(ctx context.Context, c *v1alpha1.Certificate) (requeue bool)
+}
+
+// certificates is a certificateCreateSyncDeleter using the GCP Certificate
+// Manager API.
+type certificates struct {
+	client  certificatemanager.Client
+	kube    client.Client
+	project string
+}
+
+// Create creates a Google managed certificate for the desired domains. Any
+// referenced DNS authorizations must be available before the certificate is
+// created.
+func (c *certificates) Create(ctx context.Context, cert *v1alpha1.Certificate) bool {
+	cert.Status.SetConditions(corev1alpha1.Creating())
+
+	auths, err := resolveDNSAuthorizations(ctx, c.kube, cert)
+	if err != nil {
+		cert.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	parent := parentName(c.project, cert.Spec.Location)
+	id := resourceID(cert)
+	desired := &cmv1.Certificate{
+		Description: cert.Spec.Description,
+		Labels:      cert.Spec.Labels,
+		Scope:       cert.Spec.Scope,
+		Managed: &cmv1.ManagedCertificate{
+			Domains:           cert.Spec.Domains,
+			DnsAuthorizations: auths,
+		},
+	}
+
+	if err := c.client.CreateCertificate(ctx, parent, id, desired); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		cert.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create certificate")))
+		return true
+	}
+
+	cert.Status.CertificateName = parent + "/certificates/" + id
+	meta.AddFinalizer(cert, certificateFinalizer)
+	cert.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync reports the provisioning state of the certificate. Provisioning can
+// take some time, so the certificate is requeued until it is either active or
+// has failed.
+func (c *certificates) Sync(ctx context.Context, cert *v1alpha1.Certificate) bool {
+	actual, err := c.client.GetCertificate(ctx, cert.Status.CertificateName)
+	if err != nil {
+		cert.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	cert.Status.ExpireTime = actual.ExpireTime
+	cert.Status.State = ""
+	cert.Status.ProvisioningIssue = ""
+	if m := actual.Managed; m != nil {
+		cert.Status.State = m.State
+		if m.ProvisioningIssue != nil {
+			cert.Status.ProvisioningIssue = m.ProvisioningIssue.Details
+		}
+	}
+
+	switch cert.Status.State {
+	case certificateStateActive:
+		cert.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+		return false
+	case certificateStateFailed:
+		cert.Status.SetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(errors.Errorf("certificate provisioning failed: %s", cert.Status.ProvisioningIssue)))
+		return true
+	default:
+		cert.Status.SetConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess())
+		return true
+	}
+}
+
+// Delete deletes the certificate. Certificate map entries that serve the
+// certificate must be deleted first.
+func (c *certificates) Delete(ctx context.Context, cert *v1alpha1.Certificate) bool {
+	cert.Status.SetConditions(corev1alpha1.Deleting())
+
+	if cert.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.client.DeleteCertificate(ctx, cert.Status.CertificateName); err != nil && !googleapi.IsErrorNotFound(err) {
+			cert.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete certificate")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(cert, certificateFinalizer)
+	cert.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// resolveDNSAuthorizations returns the names of the DNS authorizations used
+// by the supplied certificate, which may either be specified directly or by
+// reference to DnsAuthorizations in the same namespace. It returns an error if
+// a referenced DnsAuthorization is not yet available.
+func resolveDNSAuthorizations(ctx context.Context, kube client.Client, cert *v1alpha1.Certificate) ([]string, error) {
+	names := append([]string{}, cert.Spec.DNSAuthorizations...)
+	for _, ref := range cert.Spec.DNSAuthorizationRefs {
+		a := &v1alpha1.DnsAuthorization{}
+		n := types.NamespacedName{Namespace: cert.GetNamespace(), Name: ref.Name}
+		if err := kube.Get(ctx, n, a); err != nil {
+			return nil, errors.Wrapf(err, "cannot get dns authorization %s", n)
+		}
+		if a.Status.DnsAuthorizationName == "" || a.Status.GetCondition(corev1alpha1.TypeReady).Status != corev1.ConditionTrue {
+			return nil, errors.Errorf("dns authorization %s is not yet available", n)
+		}
+		names = append(names, a.Status.DnsAuthorizationName)
+	}
+	return names, nil
+}
+
+// A certificateConnecter returns a certificateCreateSyncDeleter that can
+// create, sync, and delete certificates with an external store - for example
+// the GCP API.
+type certificateConnecter interface {
+	Connect(context.Context, *v1alpha1.Certificate) (certificateCreateSyncDeleter, error)
+}
+
+// certificateProviderConnecter is a certificateConnecter that returns a
+// certificateCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type certificateProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns a certificateCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// Certificate.
+func (c *certificateProviderConnecter) Connect(ctx context.Context, cert *v1alpha1.Certificate) (certificateCreateSyncDeleter, error) {
+	client, p, _, err := c.connect(ctx, cert, cert.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &certificates{client: client, kube: c.kube, project: p.Spec.ProjectID}, nil
+}
+
+// CertificateReconciler reconciles Certificates read from the Kubernetes API
+// with an external store, typically the GCP API.
+type CertificateReconciler struct {
+	certificateConnecter
+	kube client.Client
+}
+
+// CertificateController is responsible for adding the Certificate controller
+// and its corresponding reconciler to the manager with any runtime
+// configuration.
+type CertificateController struct {
+	// DefaultProvider is used by certificates that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new Certificate Controller and adds it to the
+// Manager with default RBAC. The Manager will set fields on the Controller and
+// start it when the Manager is Started.
+func (c *CertificateController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &CertificateReconciler{
+		certificateConnecter: &certificateProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: certificatemanager.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(certificateControllerName).
+		For(&v1alpha1.Certificate{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listCertificates)).
+		Complete(r)
+}
+
+// Reconcile Certificate Manager certificates with the GCP API.
+func (r *CertificateReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	certificateLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.CertificateKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	cert := &v1alpha1.Certificate{}
+	if err := r.kube.Get(ctx, req.NamespacedName, cert); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get certificate %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, cert)
+	if err != nil {
+		cert.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, cert), "cannot update certificate %s", req.NamespacedName)
+	}
+
+	// The certificate has been deleted from the API server. Delete it from
+	// GCP.
+	if cert.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, cert)}, errors.Wrapf(r.kube.Update(ctx, cert), "cannot update certificate %s", req.NamespacedName)
+	}
+
+	// The certificate is unnamed. Assume it has not been created in GCP.
+	if cert.Status.CertificateName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, cert)}, errors.Wrapf(r.kube.Update(ctx, cert), "cannot update certificate %s", req.NamespacedName)
+	}
+
+	// The certificate exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, cert)}, errors.Wrapf(r.kube.Update(ctx, cert), "cannot update certificate %s", req.NamespacedName)
+}
+
+// listCertificates is a provider.Lister of certificates.
+func listCertificates(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.CertificateList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
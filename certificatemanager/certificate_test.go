@@ -0,0 +1,391 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificatemanager
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	cmv1 "google.golang.org/api/certificatemanager/v1"
+	"google.golang.org/api/googleapi"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/certificatemanager/v1alpha1"
+	fakecertificatemanager "github.com/crossplaneio/crossplane/pkg/clients/gcp/certificatemanager/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace    = "cool-namespace"
+	name         = "cool-certificate"
+	uid          = types.UID("definitely-a-uuid")
+	project      = "cool-project"
+	providerName = "cool-gcp"
+	domain       = "example.org"
+)
+
+var (
+	ctx             = context.Background()
+	errorBoom       = errors.New("boom")
+	errorNotFound   = &googleapi.Error{Code: http.StatusNotFound}
+	parent          = parentName(project, locationGlobal)
+	certificateName = parent + "/certificates/" + resourceIDPrefix + string(uid)
+	dnsAuthName     = parent + "/dnsAuthorizations/cool-auth"
+)
+
+// Test that our Reconciler implementations satisfy the Reconciler interface.
+var (
+	_ reconcile.Reconciler = &CertificateReconciler{}
+	_ reconcile.Reconciler = &DNSAuthorizationReconciler{}
+	_ reconcile.Reconciler = &CertificateMapReconciler{}
+)
+
+type certificateModifier func(*v1alpha1.Certificate)
+
+func withCertificateConditions(c ...corev1alpha1.Condition) certificateModifier {
+	return func(cert *v1alpha1.Certificate) { cert.Status.SetConditions(c...) }
+}
+
+func withCertificateFinalizers(f ...string) certificateModifier {
+	return func(cert *v1alpha1.Certificate) { cert.ObjectMeta.Finalizers = f }
+}
+
+func withCertificateReclaimPolicy(r corev1alpha1.ReclaimPolicy) certificateModifier {
+	return func(cert *v1alpha1.Certificate) { cert.Spec.ReclaimPolicy = r }
+}
+
+func withCertificateName(n string) certificateModifier {
+	return func(cert *v1alpha1.Certificate) { cert.Status.CertificateName = n }
+}
+
+func withCertificateState(s, issue string) certificateModifier {
+	return func(cert *v1alpha1.Certificate) {
+		cert.Status.State = s
+		cert.Status.ProvisioningIssue = issue
+	}
+}
+
+func withDNSAuthorizationRefs(n ...string) certificateModifier {
+	return func(cert *v1alpha1.Certificate) {
+		for _, name := range n {
+			cert.Spec.DNSAuthorizationRefs = append(cert.Spec.DNSAuthorizationRefs, corev1.LocalObjectReference{Name: name})
+		}
+	}
+}
+
+func certificate(cm ...certificateModifier) *v1alpha1.Certificate {
+	cert := &v1alpha1.Certificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.CertificateSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			CertificateParameters: v1alpha1.CertificateParameters{
+				Domains: []string{domain},
+			},
+		},
+	}
+
+	for _, m := range cm {
+		m(cert)
+	}
+
+	return cert
+}
+
+func TestCertificateCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         certificateCreateSyncDeleter
+		cert        *v1alpha1.Certificate
+		want        *v1alpha1.Certificate
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &certificates{
+				project: project,
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						a := dnsAuthorization(withDNSAuthorizationName(dnsAuthName), withDNSAuthorizationConditions(corev1alpha1.Available()))
+						a.DeepCopyInto(obj.(*v1alpha1.DnsAuthorization))
+						return nil
+					},
+				},
+				client: &fakecertificatemanager.MockClient{
+					MockCreateCertificate: func(_ context.Context, p, _ string, c *cmv1.Certificate) error {
+						if p != parent {
+							t.Errorf("CreateCertificate(...): want parent %s, got %s", parent, p)
+						}
+						want := []string{dnsAuthName}
+						if diff := cmp.Diff(want, c.Managed.DnsAuthorizations); diff != "" {
+							t.Errorf("CreateCertificate(...): -want DNS authorizations, +got:\n%s", diff)
+						}
+						return nil
+					},
+				},
+			},
+			cert: certificate(withDNSAuthorizationRefs("cool-auth")),
+			want: certificate(
+				withDNSAuthorizationRefs("cool-auth"),
+				withCertificateFinalizers(certificateFinalizer),
+				withCertificateName(certificateName),
+				withCertificateConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "DNSAuthorizationNotAvailable",
+			csd: &certificates{
+				project: project,
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						a := dnsAuthorization(withDNSAuthorizationName(dnsAuthName), withDNSAuthorizationConditions(corev1alpha1.Creating()))
+						a.DeepCopyInto(obj.(*v1alpha1.DnsAuthorization))
+						return nil
+					},
+				},
+				client: &fakecertificatemanager.MockClient{},
+			},
+			cert: certificate(withDNSAuthorizationRefs("cool-auth")),
+			want: certificate(
+				withDNSAuthorizationRefs("cool-auth"),
+				withCertificateConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Errorf("dns authorization %s/cool-auth is not yet available", namespace))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &certificates{
+				project: project,
+				client: &fakecertificatemanager.MockClient{
+					MockCreateCertificate: func(_ context.Context, _, _ string, _ *cmv1.Certificate) error { return errorBoom },
+				},
+			},
+			cert: certificate(),
+			want: certificate(
+				withCertificateConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create certificate"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.cert)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.cert, test.EquateConditions()); diff != "" {
+				t.Errorf("cert: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCertificateSync(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         certificateCreateSyncDeleter
+		cert        *v1alpha1.Certificate
+		want        *v1alpha1.Certificate
+		wantRequeue bool
+	}{
+		{
+			name: "Active",
+			csd: &certificates{client: &fakecertificatemanager.MockClient{
+				MockGetCertificate: func(_ context.Context, _ string) (*cmv1.Certificate, error) {
+					return &cmv1.Certificate{Managed: &cmv1.ManagedCertificate{State: certificateStateActive}}, nil
+				},
+			}},
+			cert: certificate(withCertificateName(certificateName)),
+			want: certificate(
+				withCertificateName(certificateName),
+				withCertificateState(certificateStateActive, ""),
+				withCertificateConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "Provisioning",
+			csd: &certificates{client: &fakecertificatemanager.MockClient{
+				MockGetCertificate: func(_ context.Context, _ string) (*cmv1.Certificate, error) {
+					return &cmv1.Certificate{Managed: &cmv1.ManagedCertificate{State: "PROVISIONING"}}, nil
+				},
+			}},
+			cert: certificate(withCertificateName(certificateName)),
+			want: certificate(
+				withCertificateName(certificateName),
+				withCertificateState("PROVISIONING", ""),
+				withCertificateConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "Failed",
+			csd: &certificates{client: &fakecertificatemanager.MockClient{
+				MockGetCertificate: func(_ context.Context, _ string) (*cmv1.Certificate, error) {
+					return &cmv1.Certificate{Managed: &cmv1.ManagedCertificate{
+						State:             certificateStateFailed,
+						ProvisioningIssue: &cmv1.ProvisioningIssue{Details: "CAA record forbids issuance"},
+					}}, nil
+				},
+			}},
+			cert: certificate(withCertificateName(certificateName)),
+			want: certificate(
+				withCertificateName(certificateName),
+				withCertificateState(certificateStateFailed, "CAA record forbids issuance"),
+				withCertificateConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(errors.New("certificate provisioning failed: CAA record forbids issuance"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGet",
+			csd: &certificates{client: &fakecertificatemanager.MockClient{
+				MockGetCertificate: func(_ context.Context, _ string) (*cmv1.Certificate, error) { return nil, errorBoom },
+			}},
+			cert: certificate(withCertificateName(certificateName)),
+			want: certificate(
+				withCertificateName(certificateName),
+				withCertificateConditions(corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.cert)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.cert, test.EquateConditions()); diff != "" {
+				t.Errorf("cert: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCertificateDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         certificateCreateSyncDeleter
+		cert        *v1alpha1.Certificate
+		want        *v1alpha1.Certificate
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimDeleteSuccessful",
+			csd: &certificates{client: &fakecertificatemanager.MockClient{
+				MockDeleteCertificate: func(_ context.Context, _ string) error { return nil },
+			}},
+			cert: certificate(
+				withCertificateName(certificateName),
+				withCertificateFinalizers(certificateFinalizer),
+				withCertificateReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: certificate(
+				withCertificateName(certificateName),
+				withCertificateReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withCertificateConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteNotFound",
+			csd: &certificates{client: &fakecertificatemanager.MockClient{
+				MockDeleteCertificate: func(_ context.Context, _ string) error { return errorNotFound },
+			}},
+			cert: certificate(
+				withCertificateName(certificateName),
+				withCertificateFinalizers(certificateFinalizer),
+				withCertificateReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: certificate(
+				withCertificateName(certificateName),
+				withCertificateReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withCertificateConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailed",
+			csd: &certificates{client: &fakecertificatemanager.MockClient{
+				MockDeleteCertificate: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			cert: certificate(
+				withCertificateName(certificateName),
+				withCertificateFinalizers(certificateFinalizer),
+				withCertificateReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: certificate(
+				withCertificateName(certificateName),
+				withCertificateFinalizers(certificateFinalizer),
+				withCertificateReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withCertificateConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot delete certificate"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "ReclaimRetain",
+			csd:  &certificates{client: &fakecertificatemanager.MockClient{}},
+			cert: certificate(
+				withCertificateName(certificateName),
+				withCertificateFinalizers(certificateFinalizer),
+				withCertificateReclaimPolicy(corev1alpha1.ReclaimRetain),
+			),
+			want: certificate(
+				withCertificateName(certificateName),
+				withCertificateReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withCertificateConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.cert)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.cert, test.EquateConditions()); diff != "" {
+				t.Errorf("cert: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package certificatemanager contains controllers that provision Google
+// managed TLS certificates, the DNS authorizations that prove ownership of
+// their domains, and the certificate maps that serve them from load balancers.
+package certificatemanager
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/certificatemanager"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/dns"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+)
+
+const (
+	reconcileTimeout = 1 * time.Minute
+
+	// locationGlobal is used by resources that don't specify a location.
+	// Certificate maps always live in the global location.
+	locationGlobal = "global"
+
+	// resourceIDPrefix is prepended to the UID of a managed resource to form
+	// the ID of its Certificate Manager resource. IDs must begin with a letter.
+	resourceIDPrefix = "crossplane-"
+)
+
+// providerConnecter returns Certificate Manager and Cloud DNS clients
+// authenticated using credentials read from a Crossplane Provider resource.
+type providerConnecter struct {
+	kube         client.Client
+	providers    provider.Resolver
+	newClient    func(ctx context.Context, creds *google.Credentials) (certificatemanager.Client, error)
+	newDNSClient func(ctx context.Context, creds *google.Credentials) (dns.Client, error)
+}
+
+// connect returns a Certificate Manager client authenticated using
+// credentials read from the Provider referenced by the supplied managed
+// resource, and that Provider.
+func (c *providerConnecter) connect(ctx context.Context, mg metav1.Object, ref *corev1.ObjectReference) (certificatemanager.Client, *gcpv1alpha1.Provider, *google.Credentials, error) {
+	p, err := c.providers.Get(ctx, c.kube, mg, ref)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceCertificateManager)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return client, p, creds, errors.Wrap(err, "cannot create new certificate manager client")
+}
+
+// resourceID returns the ID of the Certificate Manager resource of the
+// supplied managed resource.
+func resourceID(mg metav1.Object) string {
+	return resourceIDPrefix + string(mg.GetUID())
+}
+
+// parentName returns the fully qualified name of the supplied location within
+// the supplied project, e.g. projects/p/locations/global.
+func parentName(project, location string) string {
+	if location == "" {
+		location = locationGlobal
+	}
+	return fmt.Sprintf("projects/%s/locations/%s", project, location)
+}
@@ -0,0 +1,318 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificatemanager
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	cmv1 "google.golang.org/api/certificatemanager/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/certificatemanager/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/certificatemanager"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	certificateMapControllerName = "certificatemaps.certificatemanager.gcp.crossplane.io"
+	certificateMapFinalizer      = "finalizer." + certificateMapControllerName
+)
+
+var certificateMapLog = logging.Logger.WithName("controller." + certificateMapControllerName)
+
+// A certificateMapCreateSyncDeleter can create, sync, and delete certificate
+// maps in an external store - e.g. the GCP API. Each method returns true if
+// the certificate map requires further reconciliation.
+type certificateMapCreateSyncDeleter interface {
+	Create(ctx context.Context, m *v1alpha1.CertificateMap) (requeue bool)
+	Sync(ctx context.Context, m *v1alpha1.CertificateMap) (requeue bool)
+	Delete(ctx context.Context, m *v1alpha1.CertificateMap) (requeue bool)
+}
+
+// certificateMaps is a certificateMapCreateSyncDeleter using the GCP
+// Certificate Manager API.
+type certificateMaps struct {
+	client  certificatemanager.Client
+	kube    client.Client
+	project string
+}
+
+// Create creates an empty certificate map. Its entries are created when the
+// certificate map is synced.
+func (c *certificateMaps) Create(ctx context.Context, m *v1alpha1.CertificateMap) bool {
+	m.Status.SetConditions(corev1alpha1.Creating())
+
+	parent := parentName(c.project, locationGlobal)
+	id := resourceID(m)
+	desired := &cmv1.CertificateMap{Description: m.Spec.Description, Labels: m.Spec.Labels}
+
+	if err := c.client.CreateCertificateMap(ctx, parent, id, desired); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		m.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create certificate map")))
+		return true
+	}
+
+	m.Status.CertificateMapName = parent + "/certificateMaps/" + id
+	meta.AddFinalizer(m, certificateMapFinalizer)
+	m.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync creates the desired certificate map entries that don't exist, updates
+// those that serve the wrong certificates, and deletes those that are no
+// longer desired.
+func (c *certificateMaps) Sync(ctx context.Context, m *v1alpha1.CertificateMap) bool {
+	actual, err := c.client.ListCertificateMapEntries(ctx, m.Status.CertificateMapName)
+	if err != nil {
+		m.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot list certificate map entries")))
+		return true
+	}
+
+	existing := make(map[string]*cmv1.CertificateMapEntry, len(actual))
+	for _, e := range actual {
+		existing[e.Name] = e
+	}
+
+	for _, e := range m.Spec.Entries {
+		certs, err := resolveCertificates(ctx, c.kube, m, e)
+		if err != nil {
+			m.Status.SetConditions(corev1alpha1.ReconcileError(err))
+			return true
+		}
+
+		name := entryName(m.Status.CertificateMapName, e.Name)
+		desired := &cmv1.CertificateMapEntry{Hostname: e.Hostname, Matcher: e.Matcher, Certificates: certs}
+
+		a, ok := existing[name]
+		delete(existing, name)
+		switch {
+		case !ok:
+			if err := c.client.CreateCertificateMapEntry(ctx, m.Status.CertificateMapName, e.Name, desired); err != nil {
+				m.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot create certificate map entry %s", e.Name)))
+				return true
+			}
+		case !stringsEqual(desired.Certificates, a.Certificates):
+			if err := c.client.UpdateCertificateMapEntry(ctx, name, desired, "certificates"); err != nil {
+				m.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot update certificate map entry %s", e.Name)))
+				return true
+			}
+		}
+	}
+
+	for name := range existing {
+		if err := c.client.DeleteCertificateMapEntry(ctx, name); err != nil && !googleapi.IsErrorNotFound(err) {
+			m.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot delete certificate map entry %s", name)))
+			return true
+		}
+	}
+
+	m.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete deletes the certificate map. A certificate map cannot be deleted
+// while it has entries, so they are deleted first.
+func (c *certificateMaps) Delete(ctx context.Context, m *v1alpha1.CertificateMap) bool {
+	m.Status.SetConditions(corev1alpha1.Deleting())
+
+	if m.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		entries, err := c.client.ListCertificateMapEntries(ctx, m.Status.CertificateMapName)
+		if err != nil && !googleapi.IsErrorNotFound(err) {
+			m.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot list certificate map entries")))
+			return true
+		}
+		for _, e := range entries {
+			if err := c.client.DeleteCertificateMapEntry(ctx, e.Name); err != nil && !googleapi.IsErrorNotFound(err) {
+				m.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot delete certificate map entry %s", e.Name)))
+				return true
+			}
+		}
+
+		if err := c.client.DeleteCertificateMap(ctx, m.Status.CertificateMapName); err != nil && !googleapi.IsErrorNotFound(err) {
+			m.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete certificate map")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(m, certificateMapFinalizer)
+	m.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// entryName returns the fully qualified name of the supplied entry within the
+// supplied certificate map.
+func entryName(certificateMap, entry string) string {
+	return fmt.Sprintf("%s/certificateMapEntries/%s", certificateMap, entry)
+}
+
+// resolveCertificates returns the names of the certificates served by the
+// supplied entry, which may either be specified directly or by reference to
+// Certificates in the same namespace as the supplied certificate map. A
+// referenced Certificate need not be active, but must have been created.
+func resolveCertificates(ctx context.Context, kube client.Client, mg metav1.Object, e v1alpha1.CertificateMapEntry) ([]string, error) {
+	names := append([]string{}, e.Certificates...)
+	for _, ref := range e.CertificateRefs {
+		cert := &v1alpha1.Certificate{}
+		n := types.NamespacedName{Namespace: mg.GetNamespace(), Name: ref.Name}
+		if err := kube.Get(ctx, n, cert); err != nil {
+			return nil, errors.Wrapf(err, "cannot get certificate %s", n)
+		}
+		if cert.Status.CertificateName == "" {
+			return nil, errors.Errorf("certificate %s has not yet been created", n)
+		}
+		names = append(names, cert.Status.CertificateName)
+	}
+	return names, nil
+}
+
+// stringsEqual returns true if the supplied slices contain the same strings
+// in the same order.
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// A certificateMapConnecter returns a certificateMapCreateSyncDeleter that can
+// create, sync, and delete certificate maps with an external store - for
+// example the GCP API.
+type certificateMapConnecter interface {
+	Connect(context.Context, *v1alpha1.CertificateMap) (certificateMapCreateSyncDeleter, error)
+}
+
+// certificateMapProviderConnecter is a certificateMapConnecter that returns a
+// certificateMapCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type certificateMapProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns a certificateMapCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// CertificateMap.
+func (c *certificateMapProviderConnecter) Connect(ctx context.Context, m *v1alpha1.CertificateMap) (certificateMapCreateSyncDeleter, error) {
+	client, p, _, err := c.connect(ctx, m, m.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &certificateMaps{client: client, kube: c.kube, project: p.Spec.ProjectID}, nil
+}
+
+// CertificateMapReconciler reconciles CertificateMaps read from the Kubernetes
+// API with an external store, typically the GCP API.
+type CertificateMapReconciler struct {
+	certificateMapConnecter
+	kube client.Client
+}
+
+// CertificateMapController is responsible for adding the CertificateMap
+// controller and its corresponding reconciler to the manager with any runtime
+// configuration.
+type CertificateMapController struct {
+	// DefaultProvider is used by certificate maps that don't reference a
+	// provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new CertificateMap Controller and adds it to the
+// Manager with default RBAC. The Manager will set fields on the Controller and
+// start it when the Manager is Started.
+func (c *CertificateMapController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &CertificateMapReconciler{
+		certificateMapConnecter: &certificateMapProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: certificatemanager.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(certificateMapControllerName).
+		For(&v1alpha1.CertificateMap{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listCertificateMaps)).
+		Complete(r)
+}
+
+// Reconcile Certificate Manager certificate maps with the GCP API.
+func (r *CertificateMapReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	certificateMapLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.CertificateMapKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	m := &v1alpha1.CertificateMap{}
+	if err := r.kube.Get(ctx, req.NamespacedName, m); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get certificate map %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, m)
+	if err != nil {
+		m.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, m), "cannot update certificate map %s", req.NamespacedName)
+	}
+
+	// The certificate map has been deleted from the API server. Delete it
+	// from GCP.
+	if m.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, m)}, errors.Wrapf(r.kube.Update(ctx, m), "cannot update certificate map %s", req.NamespacedName)
+	}
+
+	// The certificate map is unnamed. Assume it has not been created in GCP.
+	if m.Status.CertificateMapName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, m)}, errors.Wrapf(r.kube.Update(ctx, m), "cannot update certificate map %s", req.NamespacedName)
+	}
+
+	// The certificate map exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, m)}, errors.Wrapf(r.kube.Update(ctx, m), "cannot update certificate map %s", req.NamespacedName)
+}
+
+// listCertificateMaps is a provider.Lister of certificate maps.
+func listCertificateMaps(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.CertificateMapList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,321 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificatemanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	cmv1 "google.golang.org/api/certificatemanager/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/certificatemanager/v1alpha1"
+	fakecertificatemanager "github.com/crossplaneio/crossplane/pkg/clients/gcp/certificatemanager/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+var certificateMapName = parent + "/certificateMaps/" + resourceIDPrefix + string(uid)
+
+type certificateMapModifier func(*v1alpha1.CertificateMap)
+
+func withCertificateMapConditions(c ...corev1alpha1.Condition) certificateMapModifier {
+	return func(m *v1alpha1.CertificateMap) { m.Status.SetConditions(c...) }
+}
+
+func withCertificateMapFinalizers(f ...string) certificateMapModifier {
+	return func(m *v1alpha1.CertificateMap) { m.ObjectMeta.Finalizers = f }
+}
+
+func withCertificateMapReclaimPolicy(r corev1alpha1.ReclaimPolicy) certificateMapModifier {
+	return func(m *v1alpha1.CertificateMap) { m.Spec.ReclaimPolicy = r }
+}
+
+func withCertificateMapName(n string) certificateMapModifier {
+	return func(m *v1alpha1.CertificateMap) { m.Status.CertificateMapName = n }
+}
+
+func withEntries(e ...v1alpha1.CertificateMapEntry) certificateMapModifier {
+	return func(m *v1alpha1.CertificateMap) { m.Spec.Entries = e }
+}
+
+func certificateMap(mm ...certificateMapModifier) *v1alpha1.CertificateMap {
+	m := &v1alpha1.CertificateMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.CertificateMapSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+		},
+	}
+
+	for _, mod := range mm {
+		mod(m)
+	}
+
+	return m
+}
+
+func TestCertificateMapCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         certificateMapCreateSyncDeleter
+		m           *v1alpha1.CertificateMap
+		want        *v1alpha1.CertificateMap
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &certificateMaps{project: project, client: &fakecertificatemanager.MockClient{
+				MockCreateCertificateMap: func(_ context.Context, _, _ string, _ *cmv1.CertificateMap) error { return nil },
+			}},
+			m: certificateMap(),
+			want: certificateMap(
+				withCertificateMapFinalizers(certificateMapFinalizer),
+				withCertificateMapName(certificateMapName),
+				withCertificateMapConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &certificateMaps{project: project, client: &fakecertificatemanager.MockClient{
+				MockCreateCertificateMap: func(_ context.Context, _, _ string, _ *cmv1.CertificateMap) error { return errorBoom },
+			}},
+			m: certificateMap(),
+			want: certificateMap(
+				withCertificateMapConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create certificate map"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.m)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.m, test.EquateConditions()); diff != "" {
+				t.Errorf("m: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCertificateMapSync(t *testing.T) {
+	entry := v1alpha1.CertificateMapEntry{
+		Name:            "cool-entry",
+		Hostname:        domain,
+		CertificateRefs: []corev1.LocalObjectReference{{Name: name}},
+	}
+	kube := &test.MockClient{
+		MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+			certificate(withCertificateName(certificateName)).DeepCopyInto(obj.(*v1alpha1.Certificate))
+			return nil
+		},
+	}
+
+	cases := []struct {
+		name        string
+		csd         certificateMapCreateSyncDeleter
+		m           *v1alpha1.CertificateMap
+		want        *v1alpha1.CertificateMap
+		wantRequeue bool
+	}{
+		{
+			name: "EntriesConverged",
+			csd: &certificateMaps{kube: kube, client: &fakecertificatemanager.MockClient{
+				MockListCertificateMapEntries: func(_ context.Context, _ string) ([]*cmv1.CertificateMapEntry, error) {
+					return []*cmv1.CertificateMapEntry{
+						{Name: entryName(certificateMapName, "stale-entry")},
+						{Name: entryName(certificateMapName, "cool-entry"), Certificates: []string{"stale-certificate"}},
+					}, nil
+				},
+				MockUpdateCertificateMapEntry: func(_ context.Context, n string, e *cmv1.CertificateMapEntry, mask string) error {
+					if n != entryName(certificateMapName, "cool-entry") || mask != "certificates" {
+						t.Errorf("UpdateCertificateMapEntry(...): unexpected update of %s with mask %s", n, mask)
+					}
+					if diff := cmp.Diff([]string{certificateName}, e.Certificates); diff != "" {
+						t.Errorf("UpdateCertificateMapEntry(...): -want certificates, +got:\n%s", diff)
+					}
+					return nil
+				},
+				MockDeleteCertificateMapEntry: func(_ context.Context, n string) error {
+					if n != entryName(certificateMapName, "stale-entry") {
+						t.Errorf("DeleteCertificateMapEntry(...): unexpected deletion of %s", n)
+					}
+					return nil
+				},
+			}},
+			m: certificateMap(withCertificateMapName(certificateMapName), withEntries(entry)),
+			want: certificateMap(
+				withCertificateMapName(certificateMapName),
+				withEntries(entry),
+				withCertificateMapConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedCreateEntry",
+			csd: &certificateMaps{kube: kube, client: &fakecertificatemanager.MockClient{
+				MockListCertificateMapEntries: func(_ context.Context, _ string) ([]*cmv1.CertificateMapEntry, error) {
+					return nil, nil
+				},
+				MockCreateCertificateMapEntry: func(_ context.Context, _, _ string, _ *cmv1.CertificateMapEntry) error { return errorBoom },
+			}},
+			m: certificateMap(withCertificateMapName(certificateMapName), withEntries(entry)),
+			want: certificateMap(
+				withCertificateMapName(certificateMapName),
+				withEntries(entry),
+				withCertificateMapConditions(corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create certificate map entry cool-entry"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "CertificateNotCreated",
+			csd: &certificateMaps{
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						certificate().DeepCopyInto(obj.(*v1alpha1.Certificate))
+						return nil
+					},
+				},
+				client: &fakecertificatemanager.MockClient{
+					MockListCertificateMapEntries: func(_ context.Context, _ string) ([]*cmv1.CertificateMapEntry, error) {
+						return nil, nil
+					},
+				},
+			},
+			m: certificateMap(withCertificateMapName(certificateMapName), withEntries(entry)),
+			want: certificateMap(
+				withCertificateMapName(certificateMapName),
+				withEntries(entry),
+				withCertificateMapConditions(corev1alpha1.ReconcileError(errors.Errorf("certificate %s/%s has not yet been created", namespace, name))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.m)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.m, test.EquateConditions()); diff != "" {
+				t.Errorf("m: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCertificateMapDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         certificateMapCreateSyncDeleter
+		m           *v1alpha1.CertificateMap
+		want        *v1alpha1.CertificateMap
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimDeleteSuccessful",
+			csd: &certificateMaps{client: &fakecertificatemanager.MockClient{
+				MockListCertificateMapEntries: func(_ context.Context, _ string) ([]*cmv1.CertificateMapEntry, error) {
+					return []*cmv1.CertificateMapEntry{{Name: entryName(certificateMapName, "cool-entry")}}, nil
+				},
+				MockDeleteCertificateMapEntry: func(_ context.Context, _ string) error { return nil },
+				MockDeleteCertificateMap:      func(_ context.Context, _ string) error { return nil },
+			}},
+			m: certificateMap(
+				withCertificateMapName(certificateMapName),
+				withCertificateMapFinalizers(certificateMapFinalizer),
+				withCertificateMapReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: certificateMap(
+				withCertificateMapName(certificateMapName),
+				withCertificateMapReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withCertificateMapConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedDeleteEntry",
+			csd: &certificateMaps{client: &fakecertificatemanager.MockClient{
+				MockListCertificateMapEntries: func(_ context.Context, _ string) ([]*cmv1.CertificateMapEntry, error) {
+					return []*cmv1.CertificateMapEntry{{Name: entryName(certificateMapName, "cool-entry")}}, nil
+				},
+				MockDeleteCertificateMapEntry: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			m: certificateMap(
+				withCertificateMapName(certificateMapName),
+				withCertificateMapFinalizers(certificateMapFinalizer),
+				withCertificateMapReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: certificateMap(
+				withCertificateMapName(certificateMapName),
+				withCertificateMapFinalizers(certificateMapFinalizer),
+				withCertificateMapReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withCertificateMapConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrapf(errorBoom, "cannot delete certificate map entry %s", entryName(certificateMapName, "cool-entry")))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "ReclaimRetain",
+			csd:  &certificateMaps{client: &fakecertificatemanager.MockClient{}},
+			m: certificateMap(
+				withCertificateMapName(certificateMapName),
+				withCertificateMapFinalizers(certificateMapFinalizer),
+				withCertificateMapReclaimPolicy(corev1alpha1.ReclaimRetain),
+			),
+			want: certificateMap(
+				withCertificateMapName(certificateMapName),
+				withCertificateMapReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withCertificateMapConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.m)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.m, test.EquateConditions()); diff != "" {
+				t.Errorf("m: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificatemanager
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	cmv1 "google.golang.org/api/certificatemanager/v1"
+	dnsv1 "google.golang.org/api/dns/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/certificatemanager/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/certificatemanager"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/dns"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	dnsAuthorizationControllerName = "dnsauthorizations.certificatemanager.gcp.crossplane.io"
+	dnsAuthorizationFinalizer      = "finalizer." + dnsAuthorizationControllerName
+
+	// validationRecordTTL is the TTL, in seconds, of the validation records
+	// written to Cloud DNS.
+	validationRecordTTL = 300
+)
+
+var dnsAuthorizationLog = logging.Logger.WithName("controller." + dnsAuthorizationControllerName)
+
+// A dnsAuthorizationCreateSyncDeleter can create, sync, and delete DNS
+// authorizations in an external store - e.g. the GCP API. Each method returns
+// true if the DNS authorization requires further reconciliation.
+type dnsAuthorizationCreateSyncDeleter interface {
+	Create(ctx context.Context, a *v1alpha1.DnsAuthorization) (requeue bool)
+	Sync(ctx context.Context, a *v1alpha1.DnsAuthorization) (requeue bool)
+	Delete(ctx context.Context, a *v1alpha1.DnsAuthorization) (requeue bool)
+}
+
+// dnsAuthorizations is a dnsAuthorizationCreateSyncDeleter using the GCP
+// Certificate Manager and Cloud DNS APIs.
+type dnsAuthorizations struct {
+	client  certificatemanager.Client
+	dns     dns.Client
+	project string
+}
+
+// Create creates a DNS authorization for the desired domain.
+func (c *dnsAuthorizations) Create(ctx context.Context, a *v1alpha1.DnsAuthorization) bool {
+	a.Status.SetConditions(corev1alpha1.Creating())
+
+	parent := parentName(c.project, a.Spec.Location)
+	id := resourceID(a)
+	desired := &cmv1.DnsAuthorization{
+		Domain:      a.Spec.Domain,
+		Description: a.Spec.Description,
+		Labels:      a.Spec.Labels,
+	}
+
+	// Creation is asynchronous. We may have created the DNS authorization but
+	// failed to record its name.
+	if err := c.client.CreateDnsAuthorization(ctx, parent, id, desired); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		a.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create dns authorization")))
+		return true
+	}
+
+	a.Status.DnsAuthorizationName = parent + "/dnsAuthorizations/" + id
+	meta.AddFinalizer(a, dnsAuthorizationFinalizer)
+	a.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync records the validation record of the DNS authorization and, if a Cloud
+// DNS managed zone is specified, ensures the record exists in that zone. The
+// DNS authorization is available once its validation record is in place.
+func (c *dnsAuthorizations) Sync(ctx context.Context, a *v1alpha1.DnsAuthorization) bool {
+	actual, err := c.client.GetDnsAuthorization(ctx, a.Status.DnsAuthorizationName)
+	if err != nil {
+		a.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	// The validation record is not known until creation completes.
+	if actual.DnsResourceRecord == nil {
+		a.Status.SetConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	a.Status.ValidationRecord = &v1alpha1.DNSResourceRecord{
+		Name: actual.DnsResourceRecord.Name,
+		Type: actual.DnsResourceRecord.Type,
+		Data: actual.DnsResourceRecord.Data,
+	}
+
+	if a.Spec.DNSManagedZone != "" {
+		if err := c.applyRecord(ctx, a.Spec.DNSManagedZone, a.Status.ValidationRecord); err != nil {
+			a.Status.SetConditions(corev1alpha1.ReconcileError(err))
+			return true
+		}
+	}
+
+	a.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// applyRecord creates the supplied validation record in the supplied managed
+// zone, or updates it if it exists but differs.
+func (c *dnsAuthorizations) applyRecord(ctx context.Context, zone string, r *v1alpha1.DNSResourceRecord) error {
+	desired := validationRecordSet(r)
+
+	actual, err := c.dns.GetResourceRecordSet(ctx, c.project, zone, r.Name, r.Type)
+	if googleapi.IsErrorNotFound(err) {
+		return errors.Wrapf(c.dns.CreateResourceRecordSet(ctx, c.project, zone, desired), "cannot create validation record %s in managed zone %s", r.Name, zone)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "cannot get validation record %s in managed zone %s", r.Name, zone)
+	}
+
+	if recordSetUpToDate(desired, actual) {
+		return nil
+	}
+	return errors.Wrapf(c.dns.PatchResourceRecordSet(ctx, c.project, zone, desired), "cannot update validation record %s in managed zone %s", r.Name, zone)
+}
+
+// Delete deletes the DNS authorization and any validation record it wrote to
+// Cloud DNS. Certificates that use the DNS authorization must be deleted
+// first.
+func (c *dnsAuthorizations) Delete(ctx context.Context, a *v1alpha1.DnsAuthorization) bool {
+	a.Status.SetConditions(corev1alpha1.Deleting())
+
+	if a.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if r := a.Status.ValidationRecord; r != nil && a.Spec.DNSManagedZone != "" {
+			err := c.dns.DeleteResourceRecordSet(ctx, c.project, a.Spec.DNSManagedZone, r.Name, r.Type)
+			if err != nil && !googleapi.IsErrorNotFound(err) {
+				a.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot delete validation record %s in managed zone %s", r.Name, a.Spec.DNSManagedZone)))
+				return true
+			}
+		}
+
+		if err := c.client.DeleteDnsAuthorization(ctx, a.Status.DnsAuthorizationName); err != nil && !googleapi.IsErrorNotFound(err) {
+			a.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete dns authorization")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(a, dnsAuthorizationFinalizer)
+	a.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// validationRecordSet returns the Cloud DNS record set for the supplied
+// validation record.
+func validationRecordSet(r *v1alpha1.DNSResourceRecord) *dnsv1.ResourceRecordSet {
+	return &dnsv1.ResourceRecordSet{
+		Name:    r.Name,
+		Type:    r.Type,
+		Ttl:     validationRecordTTL,
+		Rrdatas: []string{r.Data},
+	}
+}
+
+// recordSetUpToDate returns true if the actual record set has the desired
+// TTL and data.
+func recordSetUpToDate(desired, actual *dnsv1.ResourceRecordSet) bool {
+	if desired.Ttl != actual.Ttl || len(desired.Rrdatas) != len(actual.Rrdatas) {
+		return false
+	}
+	for i := range desired.Rrdatas {
+		if desired.Rrdatas[i] != actual.Rrdatas[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// A dnsAuthorizationConnecter returns a dnsAuthorizationCreateSyncDeleter
+// that can create, sync, and delete DNS authorizations with an external store
+// - for example the GCP API.
+type dnsAuthorizationConnecter interface {
+	Connect(context.Context, *v1alpha1.DnsAuthorization) (dnsAuthorizationCreateSyncDeleter, error)
+}
+
+// dnsAuthorizationProviderConnecter is a dnsAuthorizationConnecter that
+// returns a dnsAuthorizationCreateSyncDeleter authenticated using credentials
+// read from a Crossplane Provider resource.
+type dnsAuthorizationProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns a dnsAuthorizationCreateSyncDeleter backed by the GCP API.
+// GCP credentials are read from the Crossplane Provider referenced by the
+// supplied DnsAuthorization.
+func (c *dnsAuthorizationProviderConnecter) Connect(ctx context.Context, a *v1alpha1.DnsAuthorization) (dnsAuthorizationCreateSyncDeleter, error) {
+	client, p, creds, err := c.connect(ctx, a, a.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := c.newDNSClient(ctx, creds)
+	return &dnsAuthorizations{client: client, dns: d, project: p.Spec.ProjectID}, errors.Wrap(err, "cannot create new cloud dns client")
+}
+
+// DNSAuthorizationReconciler reconciles DnsAuthorizations read from the
+// Kubernetes API with an external store, typically the GCP API.
+type DNSAuthorizationReconciler struct {
+	dnsAuthorizationConnecter
+	kube client.Client
+}
+
+// DNSAuthorizationController is responsible for adding the DnsAuthorization
+// controller and its corresponding reconciler to the manager with any runtime
+// configuration.
+type DNSAuthorizationController struct {
+	// DefaultProvider is used by DNS authorizations that don't reference a
+	// provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new DnsAuthorization Controller and adds it to
+// the Manager with default RBAC. The Manager will set fields on the Controller
+// and start it when the Manager is Started.
+func (c *DNSAuthorizationController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &DNSAuthorizationReconciler{
+		dnsAuthorizationConnecter: &dnsAuthorizationProviderConnecter{&providerConnecter{
+			kube:         mgr.GetClient(),
+			providers:    providers,
+			newClient:    certificatemanager.NewClient,
+			newDNSClient: dns.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(dnsAuthorizationControllerName).
+		For(&v1alpha1.DnsAuthorization{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listDNSAuthorizations)).
+		Complete(r)
+}
+
+// Reconcile Certificate Manager DNS authorizations with the GCP API.
+func (r *DNSAuthorizationReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	dnsAuthorizationLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.DnsAuthorizationKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	a := &v1alpha1.DnsAuthorization{}
+	if err := r.kube.Get(ctx, req.NamespacedName, a); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get dns authorization %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, a)
+	if err != nil {
+		a.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, a), "cannot update dns authorization %s", req.NamespacedName)
+	}
+
+	// The DNS authorization has been deleted from the API server. Delete it
+	// from GCP.
+	if a.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, a)}, errors.Wrapf(r.kube.Update(ctx, a), "cannot update dns authorization %s", req.NamespacedName)
+	}
+
+	// The DNS authorization is unnamed. Assume it has not been created in GCP.
+	if a.Status.DnsAuthorizationName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, a)}, errors.Wrapf(r.kube.Update(ctx, a), "cannot update dns authorization %s", req.NamespacedName)
+	}
+
+	// The DNS authorization exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, a)}, errors.Wrapf(r.kube.Update(ctx, a), "cannot update dns authorization %s", req.NamespacedName)
+}
+
+// listDNSAuthorizations is a provider.Lister of DNS authorizations.
+func listDNSAuthorizations(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.DnsAuthorizationList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,387 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificatemanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	cmv1 "google.golang.org/api/certificatemanager/v1"
+	dnsv1 "google.golang.org/api/dns/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/certificatemanager/v1alpha1"
+	fakecertificatemanager "github.com/crossplaneio/crossplane/pkg/clients/gcp/certificatemanager/fake"
+	fakedns "github.com/crossplaneio/crossplane/pkg/clients/gcp/dns/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const managedZone = "cool-zone"
+
+var validationRecord = &v1alpha1.DNSResourceRecord{
+	Name: "_acme-challenge.example.org.",
+	Type: "CNAME",
+	Data: "cool.authorize.certificatemanager.goog.",
+}
+
+type dnsAuthorizationModifier func(*v1alpha1.DnsAuthorization)
+
+func withDNSAuthorizationConditions(c ...corev1alpha1.Condition) dnsAuthorizationModifier {
+	return func(a *v1alpha1.DnsAuthorization) { a.Status.SetConditions(c...) }
+}
+
+func withDNSAuthorizationFinalizers(f ...string) dnsAuthorizationModifier {
+	return func(a *v1alpha1.DnsAuthorization) { a.ObjectMeta.Finalizers = f }
+}
+
+func withDNSAuthorizationReclaimPolicy(r corev1alpha1.ReclaimPolicy) dnsAuthorizationModifier {
+	return func(a *v1alpha1.DnsAuthorization) { a.Spec.ReclaimPolicy = r }
+}
+
+func withDNSAuthorizationName(n string) dnsAuthorizationModifier {
+	return func(a *v1alpha1.DnsAuthorization) { a.Status.DnsAuthorizationName = n }
+}
+
+func withDNSManagedZone(z string) dnsAuthorizationModifier {
+	return func(a *v1alpha1.DnsAuthorization) { a.Spec.DNSManagedZone = z }
+}
+
+func withValidationRecord(r *v1alpha1.DNSResourceRecord) dnsAuthorizationModifier {
+	return func(a *v1alpha1.DnsAuthorization) { a.Status.ValidationRecord = r }
+}
+
+func dnsAuthorization(am ...dnsAuthorizationModifier) *v1alpha1.DnsAuthorization {
+	a := &v1alpha1.DnsAuthorization{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.DnsAuthorizationSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			DnsAuthorizationParameters: v1alpha1.DnsAuthorizationParameters{
+				Domain: domain,
+			},
+		},
+	}
+
+	for _, m := range am {
+		m(a)
+	}
+
+	return a
+}
+
+func dnsAuthorizationWithRecord() *cmv1.DnsAuthorization {
+	return &cmv1.DnsAuthorization{DnsResourceRecord: &cmv1.DnsResourceRecord{
+		Name: validationRecord.Name,
+		Type: validationRecord.Type,
+		Data: validationRecord.Data,
+	}}
+}
+
+func TestDNSAuthorizationCreate(t *testing.T) {
+	generatedName := parent + "/dnsAuthorizations/" + resourceIDPrefix + string(uid)
+
+	cases := []struct {
+		name        string
+		csd         dnsAuthorizationCreateSyncDeleter
+		a           *v1alpha1.DnsAuthorization
+		want        *v1alpha1.DnsAuthorization
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &dnsAuthorizations{project: project, client: &fakecertificatemanager.MockClient{
+				MockCreateDnsAuthorization: func(_ context.Context, _, _ string, a *cmv1.DnsAuthorization) error {
+					if a.Domain != domain {
+						t.Errorf("CreateDnsAuthorization(...): want domain %s, got %s", domain, a.Domain)
+					}
+					return nil
+				},
+			}},
+			a: dnsAuthorization(),
+			want: dnsAuthorization(
+				withDNSAuthorizationFinalizers(dnsAuthorizationFinalizer),
+				withDNSAuthorizationName(generatedName),
+				withDNSAuthorizationConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &dnsAuthorizations{project: project, client: &fakecertificatemanager.MockClient{
+				MockCreateDnsAuthorization: func(_ context.Context, _, _ string, _ *cmv1.DnsAuthorization) error { return errorBoom },
+			}},
+			a: dnsAuthorization(),
+			want: dnsAuthorization(
+				withDNSAuthorizationConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create dns authorization"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.a)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.a, test.EquateConditions()); diff != "" {
+				t.Errorf("a: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDNSAuthorizationSync(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         dnsAuthorizationCreateSyncDeleter
+		a           *v1alpha1.DnsAuthorization
+		want        *v1alpha1.DnsAuthorization
+		wantRequeue bool
+	}{
+		{
+			name: "NotYetCreated",
+			csd: &dnsAuthorizations{client: &fakecertificatemanager.MockClient{
+				MockGetDnsAuthorization: func(_ context.Context, _ string) (*cmv1.DnsAuthorization, error) {
+					return &cmv1.DnsAuthorization{}, nil
+				},
+			}},
+			a: dnsAuthorization(withDNSAuthorizationName(dnsAuthName)),
+			want: dnsAuthorization(
+				withDNSAuthorizationName(dnsAuthName),
+				withDNSAuthorizationConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "NoManagedZone",
+			csd: &dnsAuthorizations{client: &fakecertificatemanager.MockClient{
+				MockGetDnsAuthorization: func(_ context.Context, _ string) (*cmv1.DnsAuthorization, error) {
+					return dnsAuthorizationWithRecord(), nil
+				},
+			}},
+			a: dnsAuthorization(withDNSAuthorizationName(dnsAuthName)),
+			want: dnsAuthorization(
+				withDNSAuthorizationName(dnsAuthName),
+				withValidationRecord(validationRecord),
+				withDNSAuthorizationConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "RecordCreated",
+			csd: &dnsAuthorizations{
+				project: project,
+				client: &fakecertificatemanager.MockClient{
+					MockGetDnsAuthorization: func(_ context.Context, _ string) (*cmv1.DnsAuthorization, error) {
+						return dnsAuthorizationWithRecord(), nil
+					},
+				},
+				dns: &fakedns.MockClient{
+					MockGetResourceRecordSet: func(_ context.Context, _, _, _, _ string) (*dnsv1.ResourceRecordSet, error) {
+						return nil, errorNotFound
+					},
+					MockCreateResourceRecordSet: func(_ context.Context, p, z string, rrs *dnsv1.ResourceRecordSet) error {
+						if p != project || z != managedZone {
+							t.Errorf("CreateResourceRecordSet(...): want %s/%s, got %s/%s", project, managedZone, p, z)
+						}
+						if diff := cmp.Diff(validationRecordSet(validationRecord), rrs); diff != "" {
+							t.Errorf("CreateResourceRecordSet(...): -want, +got:\n%s", diff)
+						}
+						return nil
+					},
+				},
+			},
+			a: dnsAuthorization(withDNSAuthorizationName(dnsAuthName), withDNSManagedZone(managedZone)),
+			want: dnsAuthorization(
+				withDNSAuthorizationName(dnsAuthName),
+				withDNSManagedZone(managedZone),
+				withValidationRecord(validationRecord),
+				withDNSAuthorizationConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "RecordUpdated",
+			csd: &dnsAuthorizations{
+				project: project,
+				client: &fakecertificatemanager.MockClient{
+					MockGetDnsAuthorization: func(_ context.Context, _ string) (*cmv1.DnsAuthorization, error) {
+						return dnsAuthorizationWithRecord(), nil
+					},
+				},
+				dns: &fakedns.MockClient{
+					MockGetResourceRecordSet: func(_ context.Context, _, _, _, _ string) (*dnsv1.ResourceRecordSet, error) {
+						return &dnsv1.ResourceRecordSet{Ttl: validationRecordTTL, Rrdatas: []string{"stale."}}, nil
+					},
+					MockPatchResourceRecordSet: func(_ context.Context, _, _ string, _ *dnsv1.ResourceRecordSet) error { return errorBoom },
+				},
+			},
+			a: dnsAuthorization(withDNSAuthorizationName(dnsAuthName), withDNSManagedZone(managedZone)),
+			want: dnsAuthorization(
+				withDNSAuthorizationName(dnsAuthName),
+				withDNSManagedZone(managedZone),
+				withValidationRecord(validationRecord),
+				withDNSAuthorizationConditions(corev1alpha1.ReconcileError(errors.Wrapf(errorBoom, "cannot update validation record %s in managed zone %s", validationRecord.Name, managedZone))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "RecordUpToDate",
+			csd: &dnsAuthorizations{
+				project: project,
+				client: &fakecertificatemanager.MockClient{
+					MockGetDnsAuthorization: func(_ context.Context, _ string) (*cmv1.DnsAuthorization, error) {
+						return dnsAuthorizationWithRecord(), nil
+					},
+				},
+				dns: &fakedns.MockClient{
+					MockGetResourceRecordSet: func(_ context.Context, _, _, _, _ string) (*dnsv1.ResourceRecordSet, error) {
+						return validationRecordSet(validationRecord), nil
+					},
+				},
+			},
+			a: dnsAuthorization(withDNSAuthorizationName(dnsAuthName), withDNSManagedZone(managedZone)),
+			want: dnsAuthorization(
+				withDNSAuthorizationName(dnsAuthName),
+				withDNSManagedZone(managedZone),
+				withValidationRecord(validationRecord),
+				withDNSAuthorizationConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.a)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.a, test.EquateConditions()); diff != "" {
+				t.Errorf("a: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDNSAuthorizationDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         dnsAuthorizationCreateSyncDeleter
+		a           *v1alpha1.DnsAuthorization
+		want        *v1alpha1.DnsAuthorization
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimDeleteSuccessful",
+			csd: &dnsAuthorizations{
+				project: project,
+				client: &fakecertificatemanager.MockClient{
+					MockDeleteDnsAuthorization: func(_ context.Context, _ string) error { return nil },
+				},
+				dns: &fakedns.MockClient{
+					MockDeleteResourceRecordSet: func(_ context.Context, _, _, _, _ string) error { return errorNotFound },
+				},
+			},
+			a: dnsAuthorization(
+				withDNSAuthorizationName(dnsAuthName),
+				withDNSManagedZone(managedZone),
+				withValidationRecord(validationRecord),
+				withDNSAuthorizationFinalizers(dnsAuthorizationFinalizer),
+				withDNSAuthorizationReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: dnsAuthorization(
+				withDNSAuthorizationName(dnsAuthName),
+				withDNSManagedZone(managedZone),
+				withValidationRecord(validationRecord),
+				withDNSAuthorizationReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withDNSAuthorizationConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedDeleteRecord",
+			csd: &dnsAuthorizations{
+				project: project,
+				client:  &fakecertificatemanager.MockClient{},
+				dns: &fakedns.MockClient{
+					MockDeleteResourceRecordSet: func(_ context.Context, _, _, _, _ string) error { return errorBoom },
+				},
+			},
+			a: dnsAuthorization(
+				withDNSAuthorizationName(dnsAuthName),
+				withDNSManagedZone(managedZone),
+				withValidationRecord(validationRecord),
+				withDNSAuthorizationFinalizers(dnsAuthorizationFinalizer),
+				withDNSAuthorizationReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: dnsAuthorization(
+				withDNSAuthorizationName(dnsAuthName),
+				withDNSManagedZone(managedZone),
+				withValidationRecord(validationRecord),
+				withDNSAuthorizationFinalizers(dnsAuthorizationFinalizer),
+				withDNSAuthorizationReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withDNSAuthorizationConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrapf(errorBoom, "cannot delete validation record %s in managed zone %s", validationRecord.Name, managedZone))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "ReclaimRetain",
+			csd:  &dnsAuthorizations{client: &fakecertificatemanager.MockClient{}, dns: &fakedns.MockClient{}},
+			a: dnsAuthorization(
+				withDNSAuthorizationName(dnsAuthName),
+				withDNSAuthorizationFinalizers(dnsAuthorizationFinalizer),
+				withDNSAuthorizationReclaimPolicy(corev1alpha1.ReclaimRetain),
+			),
+			want: dnsAuthorization(
+				withDNSAuthorizationName(dnsAuthName),
+				withDNSAuthorizationReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withDNSAuthorizationConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.a)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.a, test.EquateConditions()); diff != "" {
+				t.Errorf("a: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
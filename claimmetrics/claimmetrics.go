@@ -0,0 +1,249 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package claimmetrics exports Prometheus metrics describing how long resource
+// claims take to be bound, and how many are waiting to be bound, so that the
+// service level of self-service provisioning may be reported.
+package claimmetrics
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cachev1alpha1 "github.com/crossplaneio/crossplane/apis/cache/v1alpha1"
+	computev1alpha1 "github.com/crossplaneio/crossplane/apis/compute/v1alpha1"
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	databasev1alpha1 "github.com/crossplaneio/crossplane/apis/database/v1alpha1"
+	storagev1alpha1 "github.com/crossplaneio/crossplane/apis/storage/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+)
+
+const (
+	controllerName   = "claimmetrics.gcp.crossplane.io"
+	reconcileTimeout = 1 * time.Minute
+)
+
+// Metric labels.
+const (
+	labelKind  = "kind"
+	labelClass = "class"
+)
+
+var log = logging.Logger.WithName("controller." + controllerName)
+
+var (
+	bindingSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "crossplane",
+		Subsystem: "gcp",
+		Name:      "claim_binding_seconds",
+		Help:      "Time from the creation of a resource claim until it is bound to a managed resource.",
+		// Managed resources take from seconds (buckets) to tens of
+		// minutes (Cloud SQL instances and GKE clusters) to provision.
+		Buckets: []float64{5, 15, 30, 60, 120, 300, 600, 900, 1200, 1800, 2700, 3600},
+	}, []string{labelKind, labelClass})
+
+	pendingClaims = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "crossplane",
+		Subsystem: "gcp",
+		Name:      "claims_pending",
+		Help:      "Number of resource claims that are not yet bound to a managed resource.",
+	}, []string{labelKind, labelClass})
+)
+
+func init() {
+	metrics.Registry.MustRegister(bindingSeconds, pendingClaims)
+}
+
+// claimKinds are the kinds of resource claim that may be satisfied by GCP
+// managed resources, and functions returning a new claim of each kind.
+var claimKinds = map[string]func() resource.Claim{
+	cachev1alpha1.RedisClusterKind:          func() resource.Claim { return &cachev1alpha1.RedisCluster{} },
+	computev1alpha1.KubernetesClusterKind:   func() resource.Claim { return &computev1alpha1.KubernetesCluster{} },
+	databasev1alpha1.MySQLInstanceKind:      func() resource.Claim { return &databasev1alpha1.MySQLInstance{} },
+	databasev1alpha1.PostgreSQLInstanceKind: func() resource.Claim { return &databasev1alpha1.PostgreSQLInstance{} },
+	databasev1alpha1.SQLServerInstanceKind:  func() resource.Claim { return &databasev1alpha1.SQLServerInstance{} },
+	storagev1alpha1.BucketKind:              func() resource.Claim { return &storagev1alpha1.Bucket{} },
+}
+
+// A claimKey identifies a claim of a particular kind.
+type claimKey struct {
+	kind string
+	name types.NamespacedName
+}
+
+// A pendingClaim is a claim that was observed to be waiting to be bound.
+type pendingClaim struct {
+	uid   types.UID
+	class string
+}
+
+// A Recorder records the binding latency of claims, and the number of claims
+// that are waiting to be bound.
+//
+// Binding latency is only recorded for claims that the Recorder observed
+// waiting to be bound. A claim that was bound while the Recorder was not
+// running, for example while the controller was being upgraded, would
+// otherwise be reported with the latency of the outage.
+type Recorder struct {
+	mu      sync.Mutex
+	pending map[claimKey]pendingClaim
+
+	binding *prometheus.HistogramVec
+	gauge   *prometheus.GaugeVec
+}
+
+// NewRecorder returns a Recorder that records to the package's registered
+// metrics.
+func NewRecorder() *Recorder {
+	return &Recorder{pending: map[claimKey]pendingClaim{}, binding: bindingSeconds, gauge: pendingClaims}
+}
+
+// Observe records the supplied claim of the supplied kind as it is at the
+// supplied time.
+func (r *Recorder) Observe(kind string, c resource.Claim, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k := claimKey{kind: kind, name: types.NamespacedName{Namespace: c.GetNamespace(), Name: c.GetName()}}
+	p, wasPending := r.pending[k]
+
+	if c.GetBindingPhase() != corev1alpha1.BindingPhaseBound {
+		r.pending[k] = pendingClaim{uid: c.GetUID(), class: class(c)}
+		r.updateGauge()
+		return
+	}
+
+	if !wasPending {
+		return
+	}
+	delete(r.pending, k)
+	r.updateGauge()
+
+	// The claim was deleted and recreated with the same name while we
+	// weren't watching.
+	if p.uid != c.GetUID() {
+		return
+	}
+	r.binding.WithLabelValues(kind, class(c)).Observe(now.Sub(c.GetCreationTimestamp().Time).Seconds())
+}
+
+// Forget the named claim of the supplied kind, which no longer exists.
+func (r *Recorder) Forget(kind string, name types.NamespacedName) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k := claimKey{kind: kind, name: name}
+	if _, ok := r.pending[k]; !ok {
+		return
+	}
+	delete(r.pending, k)
+	r.updateGauge()
+}
+
+// updateGauge sets the pending claims gauge from the claims known to be
+// pending. It must be called with the Recorder's lock held.
+func (r *Recorder) updateGauge() {
+	counts := map[[2]string]int{}
+	for k, p := range r.pending {
+		counts[[2]string{k.kind, p.class}]++
+	}
+	// Reset drops the series of kinds and classes that no longer have
+	// pending claims, rather than reporting zero forever.
+	r.gauge.Reset()
+	for l, n := range counts {
+		r.gauge.WithLabelValues(l[0], l[1]).Set(float64(n))
+	}
+}
+
+// class returns the namespaced name of the class referenced by the supplied
+// claim, or the empty string if it references no class.
+func class(c resource.Claim) string {
+	ref := c.GetClassReference()
+	if ref == nil {
+		return ""
+	}
+	if ref.Namespace == "" {
+		return ref.Name
+	}
+	return ref.Namespace + "/" + ref.Name
+}
+
+// Reconciler observes claims of a particular kind.
+type Reconciler struct {
+	kube     client.Client
+	kind     string
+	newClaim func() resource.Claim
+	recorder *Recorder
+}
+
+// Reconcile observes the requested claim.
+func (r *Reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	log.V(logging.Debug).Info("reconciling", "kind", r.kind, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	c := r.newClaim()
+	if err := r.kube.Get(ctx, req.NamespacedName, c); err != nil {
+		if kerrors.IsNotFound(err) {
+			r.recorder.Forget(r.kind, req.NamespacedName)
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get %s %s", r.kind, req.NamespacedName)
+	}
+
+	if c.GetDeletionTimestamp() != nil {
+		r.recorder.Forget(r.kind, req.NamespacedName)
+		return reconcile.Result{Requeue: false}, nil
+	}
+
+	r.recorder.Observe(r.kind, c, time.Now())
+	return reconcile.Result{Requeue: false}, nil
+}
+
+// Controller is responsible for adding a controller that observes each kind of
+// resource claim to the manager.
+type Controller struct{}
+
+// SetupWithManager creates a controller for each kind of resource claim and
+// adds it to the Manager. The Manager will set fields on the controllers and
+// start them when the Manager is Started.
+func (c *Controller) SetupWithManager(mgr ctrl.Manager) error {
+	rec := NewRecorder()
+	for kind, newClaim := range claimKinds {
+		r := &Reconciler{kube: mgr.GetClient(), kind: kind, newClaim: newClaim, recorder: rec}
+		err := ctrl.NewControllerManagedBy(mgr).
+			Named(strings.ToLower(kind) + "." + controllerName).
+			For(newClaim()).
+			Complete(r)
+		if err != nil {
+			return errors.Wrapf(err, "cannot set up %s metrics controller", kind)
+		}
+	}
+	return nil
+}
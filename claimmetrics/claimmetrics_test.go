@@ -0,0 +1,253 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claimmetrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	databasev1alpha1 "github.com/crossplaneio/crossplane/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace = "cool-namespace"
+	claimName = "cool-claim"
+	className = "cool-class"
+	claimUID  = types.UID("definitely-a-uuid")
+	kind      = databasev1alpha1.PostgreSQLInstanceKind
+)
+
+var (
+	errBoom = errors.New("boom")
+	created = time.Date(2019, 9, 1, 12, 0, 0, 0, time.UTC)
+	nn      = types.NamespacedName{Namespace: namespace, Name: claimName}
+)
+
+// Test that our Reconciler implementation satisfies the Reconciler interface.
+var _ reconcile.Reconciler = &Reconciler{}
+
+type claimModifier func(*databasev1alpha1.PostgreSQLInstance)
+
+func withBindingPhase(p corev1alpha1.BindingPhase) claimModifier {
+	return func(c *databasev1alpha1.PostgreSQLInstance) { c.SetBindingPhase(p) }
+}
+
+func withUID(uid types.UID) claimModifier {
+	return func(c *databasev1alpha1.PostgreSQLInstance) { c.SetUID(uid) }
+}
+
+func claim(cm ...claimModifier) *databasev1alpha1.PostgreSQLInstance {
+	c := &databasev1alpha1.PostgreSQLInstance{ObjectMeta: metav1.ObjectMeta{
+		Namespace:         namespace,
+		Name:              claimName,
+		UID:               claimUID,
+		CreationTimestamp: metav1.NewTime(created),
+	}}
+	c.SetClassReference(&corev1.ObjectReference{Namespace: namespace, Name: className})
+	for _, m := range cm {
+		m(c)
+	}
+	return c
+}
+
+func newTestRecorder() *Recorder {
+	return &Recorder{
+		pending: map[claimKey]pendingClaim{},
+		binding: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "binding"}, []string{labelKind, labelClass}),
+		gauge:   prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "pending"}, []string{labelKind, labelClass}),
+	}
+}
+
+type observed struct {
+	pending  float64
+	bindings uint64
+	seconds  float64
+}
+
+func observe(t *testing.T, r *Recorder) observed {
+	t.Helper()
+
+	o := observed{}
+	g := &dto.Metric{}
+	if err := r.gauge.WithLabelValues(kind, namespace+"/"+className).Write(g); err != nil {
+		t.Fatalf("cannot write gauge: %s", err)
+	}
+	o.pending = g.GetGauge().GetValue()
+
+	h := &dto.Metric{}
+	if err := r.binding.WithLabelValues(kind, namespace+"/"+className).(prometheus.Metric).Write(h); err != nil {
+		t.Fatalf("cannot write histogram: %s", err)
+	}
+	o.bindings = h.GetHistogram().GetSampleCount()
+	o.seconds = h.GetHistogram().GetSampleSum()
+	return o
+}
+
+func TestRecorder(t *testing.T) {
+	cases := []struct {
+		name   string
+		record func(r *Recorder)
+		want   observed
+	}{
+		{
+			name: "Pending",
+			record: func(r *Recorder) {
+				r.Observe(kind, claim(withBindingPhase(corev1alpha1.BindingPhaseUnbound)), created)
+			},
+			want: observed{pending: 1},
+		},
+		{
+			name: "PendingThenBound",
+			record: func(r *Recorder) {
+				r.Observe(kind, claim(withBindingPhase(corev1alpha1.BindingPhaseUnbound)), created)
+				r.Observe(kind, claim(withBindingPhase(corev1alpha1.BindingPhaseBound)), created.Add(90*time.Second))
+			},
+			want: observed{bindings: 1, seconds: 90},
+		},
+		{
+			name: "BoundBeforeObserved",
+			record: func(r *Recorder) {
+				r.Observe(kind, claim(withBindingPhase(corev1alpha1.BindingPhaseBound)), created.Add(90*time.Second))
+			},
+			want: observed{},
+		},
+		{
+			name: "BoundTwice",
+			record: func(r *Recorder) {
+				r.Observe(kind, claim(withBindingPhase(corev1alpha1.BindingPhaseUnbound)), created)
+				r.Observe(kind, claim(withBindingPhase(corev1alpha1.BindingPhaseBound)), created.Add(90*time.Second))
+				r.Observe(kind, claim(withBindingPhase(corev1alpha1.BindingPhaseBound)), created.Add(180*time.Second))
+			},
+			want: observed{bindings: 1, seconds: 90},
+		},
+		{
+			name: "RecreatedWhileNotObserved",
+			record: func(r *Recorder) {
+				r.Observe(kind, claim(withBindingPhase(corev1alpha1.BindingPhaseUnbound)), created)
+				r.Observe(kind, claim(withUID("another-uuid"), withBindingPhase(corev1alpha1.BindingPhaseBound)), created.Add(90*time.Second))
+			},
+			want: observed{},
+		},
+		{
+			name: "Forgotten",
+			record: func(r *Recorder) {
+				r.Observe(kind, claim(withBindingPhase(corev1alpha1.BindingPhaseUnbound)), created)
+				r.Forget(kind, nn)
+			},
+			want: observed{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newTestRecorder()
+			tc.record(r)
+			if diff := cmp.Diff(tc.want, observe(t, r), cmp.AllowUnexported(observed{})); diff != "" {
+				t.Errorf("tc.record(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	cases := []struct {
+		name    string
+		kube    client.Client
+		want    reconcile.Result
+		wantErr error
+		wantObs observed
+	}{
+		{
+			name: "ClaimNotFound",
+			kube: &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, _ runtime.Object) error {
+				return kerrors.NewNotFound(schema.GroupResource{}, claimName)
+			}},
+			want: reconcile.Result{Requeue: false},
+		},
+		{
+			name: "GetClaimError",
+			kube: &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, _ runtime.Object) error {
+				return errBoom
+			}},
+			want:    reconcile.Result{Requeue: false},
+			wantErr: errors.Wrapf(errBoom, "cannot get %s %s", kind, nn),
+		},
+		{
+			name: "ClaimDeleted",
+			kube: &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				now := metav1.Now()
+				c := claim(withBindingPhase(corev1alpha1.BindingPhaseUnbound))
+				c.SetDeletionTimestamp(&now)
+				*obj.(*databasev1alpha1.PostgreSQLInstance) = *c
+				return nil
+			}},
+			want: reconcile.Result{Requeue: false},
+		},
+		{
+			name: "ClaimPending",
+			kube: &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				*obj.(*databasev1alpha1.PostgreSQLInstance) = *claim(withBindingPhase(corev1alpha1.BindingPhaseUnbound))
+				return nil
+			}},
+			want:    reconcile.Result{Requeue: false},
+			wantObs: observed{pending: 1},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := newTestRecorder()
+
+			// Every case starts with the claim known to be pending.
+			rec.Observe(kind, claim(withBindingPhase(corev1alpha1.BindingPhaseUnbound)), created)
+
+			r := &Reconciler{
+				kube:     tc.kube,
+				kind:     kind,
+				newClaim: func() resource.Claim { return &databasev1alpha1.PostgreSQLInstance{} },
+				recorder: rec,
+			}
+			got, err := r.Reconcile(reconcile.Request{NamespacedName: nn})
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("r.Reconcile(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("r.Reconcile(...): -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantObs, observe(t, rec), cmp.AllowUnexported(observed{})); diff != "" {
+				t.Errorf("r.Reconcile(...): -want metrics, +got metrics:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,418 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cloudtasks contains a controller that manages Cloud Tasks queues,
+// which dispatch asynchronous work to Cloud Run services, App Engine, and
+// other HTTP targets.
+package cloudtasks
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	cloudtasksv2 "google.golang.org/api/cloudtasks/v2"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/cloudtasks/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/cloudtasks"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	controllerName   = "queues.cloudtasks.gcp.crossplane.io"
+	finalizerName    = "finalizer." + controllerName
+	reconcileTimeout = 1 * time.Minute
+
+	// queueIDPrefix is prepended to the UID of a Queue to form the ID of its
+	// Cloud Tasks queue. Cloud Tasks won't reuse the ID of a deleted queue
+	// for seven days, so queues are never named for their Queue.
+	queueIDPrefix = "queue-"
+
+	// stateRunning is the state of a queue that is dispatching tasks.
+	stateRunning = "RUNNING"
+)
+
+// Limits imposed by Cloud Tasks on the rate limits of a queue.
+const (
+	maxDispatchesPerSecond  = 500
+	maxConcurrentDispatches = 5000
+)
+
+// backoff matches a duration in seconds, e.g. 0.1s, as accepted by the Cloud
+// Tasks API.
+var backoff = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?s$`)
+
+var log = logging.Logger.WithName("controller." + controllerName)
+
+// A createsyncdeleter can create, sync, and delete Cloud Tasks queues in an
+// external store - e.g. the GCP API. Each method returns true if the queue
+// requires further reconciliation.
+type createsyncdeleter interface {
+	Create(ctx context.Context, q *v1alpha1.Queue) (requeue bool)
+	Sync(ctx context.Context, q *v1alpha1.Queue) (requeue bool)
+	Delete(ctx context.Context, q *v1alpha1.Queue) (requeue bool)
+}
+
+// queues is a createsyncdeleter using the GCP Cloud Tasks API.
+type queues struct {
+	client  cloudtasks.Client
+	project string
+}
+
+// Create creates a queue with the desired rate limits, retry configuration,
+// and target overrides.
+func (c *queues) Create(ctx context.Context, q *v1alpha1.Queue) bool {
+	q.Status.SetConditions(corev1alpha1.Creating())
+
+	if err := validateQueue(q.Spec.QueueParameters); err != nil {
+		// Don't requeue invalid specs; they'll be reconciled again when updated.
+		q.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return false
+	}
+
+	parent := parentName(c.project, q.Spec.Location)
+	desired := newQueue(q.Spec.QueueParameters)
+	desired.Name = parent + "/queues/" + queueIDPrefix + string(q.GetUID())
+
+	// We may have created the queue but failed to record its name.
+	if err := c.client.CreateQueue(ctx, parent, desired); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		q.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create queue")))
+		return true
+	}
+
+	q.Status.QueueName = desired.Name
+	meta.AddFinalizer(q, finalizerName)
+	q.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync updates the queue if it differs from its spec. The queue is available
+// while it is dispatching tasks; queues may be paused or disabled outside of
+// Crossplane.
+func (c *queues) Sync(ctx context.Context, q *v1alpha1.Queue) bool {
+	actual, err := c.client.GetQueue(ctx, q.Status.QueueName)
+	if err != nil {
+		q.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+	q.Status.State = actual.State
+
+	if err := validateQueue(q.Spec.QueueParameters); err != nil {
+		q.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return false
+	}
+
+	desired := newQueue(q.Spec.QueueParameters)
+	if mask := updateMask(desired, actual); len(mask) > 0 {
+		if err := c.client.PatchQueue(ctx, q.Status.QueueName, desired, strings.Join(mask, ",")); err != nil {
+			q.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot update queue")))
+			return true
+		}
+		q.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	if actual.State != stateRunning {
+		q.Status.SetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess())
+		return false
+	}
+
+	q.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete deletes the queue, along with any tasks it contains.
+func (c *queues) Delete(ctx context.Context, q *v1alpha1.Queue) bool {
+	q.Status.SetConditions(corev1alpha1.Deleting())
+
+	if q.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.client.DeleteQueue(ctx, q.Status.QueueName); err != nil && !googleapi.IsErrorNotFound(err) {
+			q.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete queue")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(q, finalizerName)
+	q.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// validateQueue returns an error if the supplied parameters exceed the rate
+// limits of Cloud Tasks, specify malformed or inverted retry backoffs, or
+// override both App Engine and HTTP targets.
+func validateQueue(p v1alpha1.QueueParameters) error {
+	if rl := p.RateLimits; rl != nil {
+		if rl.MaxDispatchesPerSecond != "" {
+			d, err := strconv.ParseFloat(rl.MaxDispatchesPerSecond, 64)
+			if err != nil || d <= 0 || d > maxDispatchesPerSecond {
+				return errors.Errorf("maxDispatchesPerSecond %q must be a number greater than 0 and at most %d", rl.MaxDispatchesPerSecond, maxDispatchesPerSecond)
+			}
+		}
+		if rl.MaxConcurrentDispatches < 0 || rl.MaxConcurrentDispatches > maxConcurrentDispatches {
+			return errors.Errorf("maxConcurrentDispatches %d must be between 0 and %d", rl.MaxConcurrentDispatches, maxConcurrentDispatches)
+		}
+	}
+
+	if rc := p.RetryConfig; rc != nil {
+		if rc.MaxAttempts < -1 {
+			return errors.Errorf("maxAttempts %d must be -1, for unlimited attempts, or greater", rc.MaxAttempts)
+		}
+		for _, d := range []string{rc.MinBackoff, rc.MaxBackoff, rc.MaxRetryDuration} {
+			if d != "" && !backoff.MatchString(d) {
+				return errors.Errorf("duration %q must be in seconds, e.g. 0.1s", d)
+			}
+		}
+		if rc.MinBackoff != "" && rc.MaxBackoff != "" && seconds(rc.MinBackoff) > seconds(rc.MaxBackoff) {
+			return errors.Errorf("minBackoff %s must not exceed maxBackoff %s", rc.MinBackoff, rc.MaxBackoff)
+		}
+	}
+
+	if p.AppEngineRoutingOverride != nil && p.HTTPTarget != nil {
+		return errors.New("at most one of appEngineRoutingOverride or httpTarget may be specified")
+	}
+	return nil
+}
+
+// newQueue returns the Cloud Tasks queue described by the supplied
+// parameters. Backoffs are normalized so they compare equal to those returned
+// by the API, e.g. 0.100s.
+func newQueue(p v1alpha1.QueueParameters) *cloudtasksv2.Queue {
+	q := &cloudtasksv2.Queue{}
+
+	if rl := p.RateLimits; rl != nil {
+		q.RateLimits = &cloudtasksv2.RateLimits{MaxConcurrentDispatches: rl.MaxConcurrentDispatches}
+		if rl.MaxDispatchesPerSecond != "" {
+			// validateQueue guarantees the rate is a number.
+			q.RateLimits.MaxDispatchesPerSecond, _ = strconv.ParseFloat(rl.MaxDispatchesPerSecond, 64)
+		}
+	}
+
+	if rc := p.RetryConfig; rc != nil {
+		q.RetryConfig = &cloudtasksv2.RetryConfig{
+			MaxAttempts:      rc.MaxAttempts,
+			MaxDoublings:     rc.MaxDoublings,
+			MinBackoff:       normalizeSeconds(rc.MinBackoff),
+			MaxBackoff:       normalizeSeconds(rc.MaxBackoff),
+			MaxRetryDuration: normalizeSeconds(rc.MaxRetryDuration),
+		}
+	}
+
+	if o := p.AppEngineRoutingOverride; o != nil {
+		q.AppEngineRoutingOverride = &cloudtasksv2.AppEngineRouting{Service: o.Service, Version: o.Version, Instance: o.Instance}
+	}
+
+	if t := p.HTTPTarget; t != nil {
+		q.HttpTarget = &cloudtasksv2.HttpTarget{HttpMethod: t.HTTPMethod}
+		if u := t.URIOverride; u != nil {
+			q.HttpTarget.UriOverride = &cloudtasksv2.UriOverride{Scheme: u.Scheme, Host: u.Host, Port: u.Port}
+			if u.Path != "" {
+				q.HttpTarget.UriOverride.PathOverride = &cloudtasksv2.PathOverride{Path: u.Path}
+			}
+		}
+		if o := t.OIDCToken; o != nil {
+			q.HttpTarget.OidcToken = &cloudtasksv2.OidcToken{ServiceAccountEmail: o.ServiceAccountEmail, Audience: o.Audience}
+		}
+	}
+	return q
+}
+
+// updateMask returns the paths of the fields of the actual queue that differ
+// from the desired queue. Fields that are not specified are left as Cloud
+// Tasks defaulted them.
+func updateMask(desired, actual *cloudtasksv2.Queue) []string {
+	mask := []string{}
+	if desired.RateLimits != nil && !compare.Equal(desired.RateLimits, actual.RateLimits, compare.IgnoreUnset()) {
+		mask = append(mask, "rateLimits")
+	}
+	if desired.RetryConfig != nil && !compare.Equal(desired.RetryConfig, normalizeRetryConfig(actual.RetryConfig), compare.IgnoreUnset()) {
+		mask = append(mask, "retryConfig")
+	}
+	if desired.AppEngineRoutingOverride != nil && !compare.Equal(desired.AppEngineRoutingOverride, actual.AppEngineRoutingOverride, compare.IgnoreUnset()) {
+		mask = append(mask, "appEngineRoutingOverride")
+	}
+	if desired.HttpTarget != nil && !compare.Equal(desired.HttpTarget, actual.HttpTarget, compare.IgnoreUnset()) {
+		mask = append(mask, "httpTarget")
+	}
+	return mask
+}
+
+// normalizeRetryConfig returns a copy of the supplied retry configuration
+// whose backoffs are normalized.
+func normalizeRetryConfig(rc *cloudtasksv2.RetryConfig) *cloudtasksv2.RetryConfig {
+	if rc == nil {
+		return nil
+	}
+	n := *rc
+	n.MinBackoff = normalizeSeconds(rc.MinBackoff)
+	n.MaxBackoff = normalizeSeconds(rc.MaxBackoff)
+	n.MaxRetryDuration = normalizeSeconds(rc.MaxRetryDuration)
+	return &n
+}
+
+// normalizeSeconds returns the supplied duration in seconds without trailing
+// zeros, e.g. 0.1s for 0.100s. Malformed durations are returned unchanged.
+func normalizeSeconds(d string) string {
+	if !backoff.MatchString(d) {
+		return d
+	}
+	return strconv.FormatFloat(seconds(d), 'f', -1, 64) + "s"
+}
+
+// seconds returns the number of seconds in the supplied duration, which must
+// match backoff.
+func seconds(d string) float64 {
+	s, _ := strconv.ParseFloat(strings.TrimSuffix(d, "s"), 64)
+	return s
+}
+
+// parentName returns the fully qualified name of the supplied location within
+// the supplied project, e.g. projects/p/locations/us-central1.
+func parentName(project, location string) string {
+	return fmt.Sprintf("projects/%s/locations/%s", project, location)
+}
+
+// A connecter returns a createsyncdeleter that can create, sync, and delete
+// Cloud Tasks queues with an external store - for example the GCP API.
+type connecter interface {
+	Connect(context.Context, *v1alpha1.Queue) (createsyncdeleter, error)
+}
+
+// providerConnecter is a connecter that returns a createsyncdeleter
+// authenticated using credentials read from a Crossplane Provider resource.
+type providerConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (cloudtasks.Client, error)
+}
+
+// Connect returns a createsyncdeleter backed by the GCP API. GCP credentials
+// are read from the Crossplane Provider referenced by the supplied Queue.
+func (c *providerConnecter) Connect(ctx context.Context, q *v1alpha1.Queue) (createsyncdeleter, error) {
+	p, err := c.providers.Get(ctx, c.kube, q, q.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceCloudTasks, cloudtasksv2.CloudPlatformScope)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return &queues{client: client, project: p.Spec.ProjectID}, errors.Wrap(err, "cannot create new Cloud Tasks client")
+}
+
+// Reconciler reconciles Queues read from the Kubernetes API with an external
+// store, typically the GCP API.
+type Reconciler struct {
+	connecter
+	kube client.Client
+}
+
+// QueueController is responsible for adding the Cloud Tasks Queue controller
+// and its corresponding reconciler to the manager with any runtime
+// configuration.
+type QueueController struct {
+	// DefaultProvider is used by queues that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new Queue Controller and adds it to the Manager
+// with default RBAC. The Manager will set fields on the Controller and start
+// it when the Manager is Started.
+func (c *QueueController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &Reconciler{
+		connecter: &providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: cloudtasks.NewClient,
+		},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerName).
+		For(&v1alpha1.Queue{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listQueues)).
+		Complete(r)
+}
+
+// Reconcile Cloud Tasks queues with the GCP API.
+func (r *Reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	log.V(logging.Debug).Info("reconciling", "kind", v1alpha1.QueueKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	q := &v1alpha1.Queue{}
+	if err := r.kube.Get(ctx, req.NamespacedName, q); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get queue %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, q)
+	if err != nil {
+		q.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, q), "cannot update queue %s", req.NamespacedName)
+	}
+
+	// The queue has been deleted from the API server. Delete from GCP.
+	if q.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, q)}, errors.Wrapf(r.kube.Update(ctx, q), "cannot update queue %s", req.NamespacedName)
+	}
+
+	// The queue is unnamed. Assume it has not been created in GCP.
+	if q.Status.QueueName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, q)}, errors.Wrapf(r.kube.Update(ctx, q), "cannot update queue %s", req.NamespacedName)
+	}
+
+	// The queue exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, q)}, errors.Wrapf(r.kube.Update(ctx, q), "cannot update queue %s", req.NamespacedName)
+}
+
+// listQueues is a provider.Lister of Cloud Tasks queues.
+func listQueues(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.QueueList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,504 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudtasks
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	cloudtasksv2 "google.golang.org/api/cloudtasks/v2"
+	"google.golang.org/api/googleapi"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/cloudtasks/v1alpha1"
+	fakecloudtasks "github.com/crossplaneio/crossplane/pkg/clients/gcp/cloudtasks/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace      = "cool-namespace"
+	name           = "cool-queue"
+	uid            = types.UID("definitely-a-uuid")
+	project        = "coolProject"
+	location       = "us-central1"
+	providerName   = "cool-gcp"
+	serviceAccount = "cool-sa@coolProject.iam.gserviceaccount.com"
+	host           = "cool-service-abc123-uc.a.run.app"
+)
+
+var (
+	ctx           = context.Background()
+	errorBoom     = errors.New("boom")
+	errorNotFound = &googleapi.Error{Code: http.StatusNotFound}
+	queueName     = parentName(project, location) + "/queues/" + queueIDPrefix + string(uid)
+)
+
+// Test that our Reconciler implementation satisfies the Reconciler interface.
+var _ reconcile.Reconciler = &Reconciler{}
+
+type queueModifier func(*v1alpha1.Queue)
+
+func withConditions(c ...corev1alpha1.Condition) queueModifier {
+	return func(q *v1alpha1.Queue) { q.Status.SetConditions(c...) }
+}
+
+func withFinalizers(f ...string) queueModifier {
+	return func(q *v1alpha1.Queue) { q.ObjectMeta.Finalizers = f }
+}
+
+func withReclaimPolicy(p corev1alpha1.ReclaimPolicy) queueModifier {
+	return func(q *v1alpha1.Queue) { q.Spec.ReclaimPolicy = p }
+}
+
+func withQueueName(n string) queueModifier {
+	return func(q *v1alpha1.Queue) { q.Status.QueueName = n }
+}
+
+func withState(s string) queueModifier {
+	return func(q *v1alpha1.Queue) { q.Status.State = s }
+}
+
+func withRetryConfig(rc *v1alpha1.RetryConfig) queueModifier {
+	return func(q *v1alpha1.Queue) { q.Spec.RetryConfig = rc }
+}
+
+func withAppEngineRoutingOverride(o *v1alpha1.AppEngineRouting) queueModifier {
+	return func(q *v1alpha1.Queue) { q.Spec.AppEngineRoutingOverride = o }
+}
+
+func queue(qm ...queueModifier) *v1alpha1.Queue {
+	q := &v1alpha1.Queue{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.QueueSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			QueueParameters: v1alpha1.QueueParameters{
+				Location: location,
+				RateLimits: &v1alpha1.RateLimits{
+					MaxDispatchesPerSecond:  "10",
+					MaxConcurrentDispatches: 100,
+				},
+				RetryConfig: &v1alpha1.RetryConfig{
+					MaxAttempts: 5,
+					MinBackoff:  "0.1s",
+					MaxBackoff:  "60s",
+				},
+				HTTPTarget: &v1alpha1.HTTPTarget{
+					HTTPMethod:  "POST",
+					URIOverride: &v1alpha1.URIOverride{Scheme: "HTTPS", Host: host, Path: "/tasks"},
+					OIDCToken:   &v1alpha1.OIDCToken{ServiceAccountEmail: serviceAccount},
+				},
+			},
+		},
+	}
+
+	for _, m := range qm {
+		m(q)
+	}
+
+	return q
+}
+
+// actualQueue returns the queue GCP would return for the default spec,
+// including the fields it defaults.
+func actualQueue(state string) *cloudtasksv2.Queue {
+	return &cloudtasksv2.Queue{
+		Name:  queueName,
+		State: state,
+		RateLimits: &cloudtasksv2.RateLimits{
+			MaxDispatchesPerSecond:  10,
+			MaxConcurrentDispatches: 100,
+			MaxBurstSize:            100,
+		},
+		RetryConfig: &cloudtasksv2.RetryConfig{
+			MaxAttempts:  5,
+			MinBackoff:   "0.100s",
+			MaxBackoff:   "60s",
+			MaxDoublings: 16,
+		},
+		HttpTarget: &cloudtasksv2.HttpTarget{
+			HttpMethod: "POST",
+			UriOverride: &cloudtasksv2.UriOverride{
+				Scheme:       "HTTPS",
+				Host:         host,
+				PathOverride: &cloudtasksv2.PathOverride{Path: "/tasks"},
+			},
+			OidcToken: &cloudtasksv2.OidcToken{ServiceAccountEmail: serviceAccount},
+		},
+	}
+}
+
+func TestCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		q           *v1alpha1.Queue
+		want        *v1alpha1.Queue
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &queues{project: project, client: &fakecloudtasks.MockClient{
+				MockCreateQueue: func(_ context.Context, parent string, q *cloudtasksv2.Queue) error {
+					if q.Name != queueName {
+						t.Errorf("CreateQueue(...): want name %s, got %s", queueName, q.Name)
+					}
+					if q.RateLimits.MaxDispatchesPerSecond != 10 {
+						t.Errorf("CreateQueue(...): want 10 dispatches per second, got %v", q.RateLimits.MaxDispatchesPerSecond)
+					}
+					return nil
+				},
+			}},
+			q: queue(),
+			want: queue(
+				withFinalizers(finalizerName),
+				withQueueName(queueName),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "AlreadyExists",
+			csd: &queues{project: project, client: &fakecloudtasks.MockClient{
+				MockCreateQueue: func(_ context.Context, _ string, _ *cloudtasksv2.Queue) error {
+					return &googleapi.Error{Code: http.StatusConflict}
+				},
+			}},
+			q: queue(),
+			want: queue(
+				withFinalizers(finalizerName),
+				withQueueName(queueName),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "TwoTargets",
+			csd:  &queues{project: project, client: &fakecloudtasks.MockClient{}},
+			q:    queue(withAppEngineRoutingOverride(&v1alpha1.AppEngineRouting{Service: "worker"})),
+			want: queue(
+				withAppEngineRoutingOverride(&v1alpha1.AppEngineRouting{Service: "worker"}),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.New("at most one of appEngineRoutingOverride or httpTarget may be specified"))),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "Failed",
+			csd: &queues{project: project, client: &fakecloudtasks.MockClient{
+				MockCreateQueue: func(_ context.Context, _ string, _ *cloudtasksv2.Queue) error { return errorBoom },
+			}},
+			q: queue(),
+			want: queue(
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create queue"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.q)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.q, test.EquateConditions()); diff != "" {
+				t.Errorf("q: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		q           *v1alpha1.Queue
+		want        *v1alpha1.Queue
+		wantRequeue bool
+	}{
+		{
+			name: "Available",
+			csd: &queues{project: project, client: &fakecloudtasks.MockClient{
+				MockGetQueue: func(_ context.Context, _ string) (*cloudtasksv2.Queue, error) { return actualQueue(stateRunning), nil },
+			}},
+			q: queue(withQueueName(queueName)),
+			want: queue(
+				withQueueName(queueName),
+				withState(stateRunning),
+				withConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "Paused",
+			csd: &queues{project: project, client: &fakecloudtasks.MockClient{
+				MockGetQueue: func(_ context.Context, _ string) (*cloudtasksv2.Queue, error) { return actualQueue("PAUSED"), nil },
+			}},
+			q: queue(withQueueName(queueName)),
+			want: queue(
+				withQueueName(queueName),
+				withState("PAUSED"),
+				withConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "RetryConfigChanged",
+			csd: &queues{project: project, client: &fakecloudtasks.MockClient{
+				MockGetQueue: func(_ context.Context, _ string) (*cloudtasksv2.Queue, error) { return actualQueue(stateRunning), nil },
+				MockPatchQueue: func(_ context.Context, _ string, q *cloudtasksv2.Queue, mask string) error {
+					if mask != "retryConfig" {
+						t.Errorf("PatchQueue(...): want mask retryConfig, got %s", mask)
+					}
+					if q.RetryConfig.MaxAttempts != 10 {
+						t.Errorf("PatchQueue(...): want 10 max attempts, got %d", q.RetryConfig.MaxAttempts)
+					}
+					return nil
+				},
+			}},
+			q: queue(withQueueName(queueName), withRetryConfig(&v1alpha1.RetryConfig{MaxAttempts: 10})),
+			want: queue(
+				withQueueName(queueName),
+				withRetryConfig(&v1alpha1.RetryConfig{MaxAttempts: 10}),
+				withState(stateRunning),
+				withConditions(corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedPatch",
+			csd: &queues{project: project, client: &fakecloudtasks.MockClient{
+				MockGetQueue:   func(_ context.Context, _ string) (*cloudtasksv2.Queue, error) { return actualQueue(stateRunning), nil },
+				MockPatchQueue: func(_ context.Context, _ string, _ *cloudtasksv2.Queue, _ string) error { return errorBoom },
+			}},
+			q: queue(withQueueName(queueName), withRetryConfig(&v1alpha1.RetryConfig{MaxAttempts: 10})),
+			want: queue(
+				withQueueName(queueName),
+				withRetryConfig(&v1alpha1.RetryConfig{MaxAttempts: 10}),
+				withState(stateRunning),
+				withConditions(corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot update queue"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGet",
+			csd: &queues{project: project, client: &fakecloudtasks.MockClient{
+				MockGetQueue: func(_ context.Context, _ string) (*cloudtasksv2.Queue, error) { return nil, errorBoom },
+			}},
+			q: queue(withQueueName(queueName)),
+			want: queue(
+				withQueueName(queueName),
+				withConditions(corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.q)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.q, test.EquateConditions()); diff != "" {
+				t.Errorf("q: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		q           *v1alpha1.Queue
+		want        *v1alpha1.Queue
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimRetain",
+			csd:  &queues{project: project, client: &fakecloudtasks.MockClient{}},
+			q:    queue(withQueueName(queueName), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimRetain)),
+			want: queue(
+				withQueueName(queueName),
+				withReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteAlreadyGone",
+			csd: &queues{project: project, client: &fakecloudtasks.MockClient{
+				MockDeleteQueue: func(_ context.Context, _ string) error { return errorNotFound },
+			}},
+			q: queue(withQueueName(queueName), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: queue(
+				withQueueName(queueName),
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailed",
+			csd: &queues{project: project, client: &fakecloudtasks.MockClient{
+				MockDeleteQueue: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			q: queue(withQueueName(queueName), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: queue(
+				withQueueName(queueName),
+				withFinalizers(finalizerName),
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot delete queue"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.q)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.q, test.EquateConditions()); diff != "" {
+				t.Errorf("q: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateQueue(t *testing.T) {
+	cases := map[string]struct {
+		p       v1alpha1.QueueParameters
+		wantErr bool
+	}{
+		"Valid": {
+			p: queue().Spec.QueueParameters,
+		},
+		"FractionalRate": {
+			p: v1alpha1.QueueParameters{RateLimits: &v1alpha1.RateLimits{MaxDispatchesPerSecond: "0.5"}},
+		},
+		"RateTooHigh": {
+			p:       v1alpha1.QueueParameters{RateLimits: &v1alpha1.RateLimits{MaxDispatchesPerSecond: "501"}},
+			wantErr: true,
+		},
+		"RateNotANumber": {
+			p:       v1alpha1.QueueParameters{RateLimits: &v1alpha1.RateLimits{MaxDispatchesPerSecond: "fast"}},
+			wantErr: true,
+		},
+		"TooManyConcurrentDispatches": {
+			p:       v1alpha1.QueueParameters{RateLimits: &v1alpha1.RateLimits{MaxConcurrentDispatches: 5001}},
+			wantErr: true,
+		},
+		"UnlimitedAttempts": {
+			p: v1alpha1.QueueParameters{RetryConfig: &v1alpha1.RetryConfig{MaxAttempts: -1}},
+		},
+		"MalformedBackoff": {
+			p:       v1alpha1.QueueParameters{RetryConfig: &v1alpha1.RetryConfig{MinBackoff: "100ms"}},
+			wantErr: true,
+		},
+		"InvertedBackoff": {
+			p:       v1alpha1.QueueParameters{RetryConfig: &v1alpha1.RetryConfig{MinBackoff: "10s", MaxBackoff: "1s"}},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateQueue(tc.p)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("validateQueue(...): want error %t, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestUpdateMask(t *testing.T) {
+	cases := map[string]struct {
+		desired *cloudtasksv2.Queue
+		actual  *cloudtasksv2.Queue
+		want    []string
+	}{
+		"UpToDate": {
+			desired: newQueue(queue().Spec.QueueParameters),
+			actual:  actualQueue(stateRunning),
+			want:    []string{},
+		},
+		"NotSpecified": {
+			desired: newQueue(v1alpha1.QueueParameters{}),
+			actual:  actualQueue(stateRunning),
+			want:    []string{},
+		},
+		"RateAndTargetChanged": {
+			desired: newQueue(queue().Spec.QueueParameters),
+			actual: func() *cloudtasksv2.Queue {
+				q := actualQueue(stateRunning)
+				q.RateLimits.MaxDispatchesPerSecond = 500
+				q.HttpTarget.UriOverride.Host = "old-service-abc123-uc.a.run.app"
+				return q
+			}(),
+			want: []string{"rateLimits", "httpTarget"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := updateMask(tc.desired, tc.actual)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("updateMask(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNormalizeSeconds(t *testing.T) {
+	cases := map[string]string{
+		"0.100s": "0.1s",
+		"60s":    "60s",
+		"1.5s":   "1.5s",
+		"":       "",
+		"100ms":  "100ms",
+	}
+
+	for d, want := range cases {
+		t.Run(d, func(t *testing.T) {
+			if got := normalizeSeconds(d); got != want {
+				t.Errorf("normalizeSeconds(%q): want %q, got %q", d, want, got)
+			}
+		})
+	}
+}
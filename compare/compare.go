@@ -0,0 +1,251 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package compare semantically compares the desired and actual state of GCP
+// resources in order to decide whether they need to be updated. GCP often
+// returns values that differ from those it was sent without differing in
+// meaning, for example by returning a full resource URL in place of a resource
+// name, or an empty list in place of an omitted one. Comparing such values
+// naively causes resources to be updated on every sync.
+package compare
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// resourceURL matches the scheme, host, service and version of a GCP resource
+// URL, e.g. https://www.googleapis.com/compute/v1/.
+var resourceURL = regexp.MustCompile(`^https://[a-z]+\.googleapis\.com/[a-z]+/[a-z0-9]+/`)
+
+// An Option configures how values are compared.
+type Option func(*comparer)
+
+// IgnoreFields ignores the fields at the supplied paths, typically because
+// they are output only. A path is a series of JSON field names separated by
+// dots, e.g. settings.settingsVersion. Paths do not include slice indices;
+// ignoring items.kind ignores the kind field of every element of items.
+func IgnoreFields(paths ...string) Option {
+	return func(c *comparer) {
+		for _, p := range paths {
+			c.ignore[p] = true
+		}
+	}
+}
+
+// IgnoreUnset ignores any field that is unset in the desired value, on the
+// assumption that GCP either defaulted it or that it is output only. Note that
+// this means unsetting a field in the desired value is not detected.
+func IgnoreUnset() Option {
+	return func(c *comparer) { c.ignoreUnset = true }
+}
+
+// Unordered compares the lists at the supplied paths as unordered sets, for
+// lists such as IP ranges or tags that GCP may return in any order. Paths are
+// as for IgnoreFields; the root path, for comparing two lists, is the empty
+// string.
+func Unordered(paths ...string) Option {
+	return func(c *comparer) {
+		for _, p := range paths {
+			c.unordered[p] = true
+		}
+	}
+}
+
+type comparer struct {
+	ignore      map[string]bool
+	unordered   map[string]bool
+	ignoreUnset bool
+}
+
+// Equal returns true if the supplied desired and actual values are
+// semantically equal. Both values must be serialisable as JSON.
+func Equal(want, actual interface{}, o ...Option) bool {
+	return len(Diff(want, actual, o...)) == 0
+}
+
+// Diff returns the sorted paths of the fields at which the supplied desired
+// and actual values semantically differ. Both values must be serialisable as
+// JSON. Values that cannot be serialised are considered to differ at the root
+// path, which is the empty string.
+func Diff(want, actual interface{}, o ...Option) []string {
+	c := &comparer{ignore: map[string]bool{}, unordered: map[string]bool{}}
+	for _, fn := range o {
+		fn(c)
+	}
+
+	w, werr := normalize(want)
+	a, aerr := normalize(actual)
+	if werr != nil || aerr != nil {
+		return []string{""}
+	}
+
+	diffs := c.diff("", w, a, nil)
+	sort.Strings(diffs)
+	return diffs
+}
+
+// normalize returns the generic JSON representation of the supplied value, in
+// which all structs are maps, all numbers are float64, and so on.
+func normalize(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	return out, json.Unmarshal(b, &out)
+}
+
+func (c *comparer) diff(path string, want, actual interface{}, diffs []string) []string {
+	if c.ignore[path] {
+		return diffs
+	}
+	if c.ignoreUnset && isZero(want) {
+		return diffs
+	}
+	if isEmpty(want) && isEmpty(actual) {
+		return diffs
+	}
+
+	// Compare an unset object to a set one field by field, such that fields
+	// that are ignored remain ignored.
+	if _, ok := actual.(map[string]interface{}); ok && want == nil {
+		want = map[string]interface{}{}
+	}
+
+	switch w := want.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return append(diffs, path)
+		}
+		for _, k := range keys(w, a) {
+			diffs = c.diff(join(path, k), w[k], a[k], diffs)
+		}
+		return diffs
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(w) != len(a) {
+			return append(diffs, path)
+		}
+		if c.unordered[path] {
+			if !c.sameElements(path, w, a) {
+				return append(diffs, path)
+			}
+			return diffs
+		}
+		for i := range w {
+			// Paths do not include slice indices, so we may find multiple
+			// differences at the same path.
+			if d := c.diff(path, w[i], a[i], nil); len(d) > 0 {
+				return append(diffs, d...)
+			}
+		}
+		return diffs
+	case string:
+		a, ok := actual.(string)
+		if !ok || trimResourceURL(w) != trimResourceURL(a) {
+			return append(diffs, path)
+		}
+		return diffs
+	default:
+		switch actual.(type) {
+		case map[string]interface{}, []interface{}:
+			return append(diffs, path)
+		}
+		if want != actual {
+			return append(diffs, path)
+		}
+		return diffs
+	}
+}
+
+// sameElements returns true if each of the supplied desired elements equals a
+// distinct actual element, in any order. Both lists must be of equal length.
+func (c *comparer) sameElements(path string, want, actual []interface{}) bool {
+	matched := make([]bool, len(actual))
+	for _, w := range want {
+		found := false
+		for i, a := range actual {
+			if !matched[i] && len(c.diff(path, w, a, nil)) == 0 {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// keys returns the union of the keys of the supplied maps.
+func keys(a, b map[string]interface{}) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	k := make([]string, 0, len(a)+len(b))
+	for _, m := range []map[string]interface{}{a, b} {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				k = append(k, key)
+			}
+		}
+	}
+	return k
+}
+
+func join(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+// trimResourceURL trims the scheme, host, service, and version from a GCP
+// resource URL, such that it may be compared to a relative resource name.
+func trimResourceURL(s string) string {
+	return strings.TrimPrefix(resourceURL.ReplaceAllString(s, ""), "/")
+}
+
+// isEmpty returns true if the supplied value is null, or an empty list or
+// object. GCP frequently returns one of these where another was sent.
+func isEmpty(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(t) == 0
+	case []interface{}:
+		return len(t) == 0
+	}
+	return false
+}
+
+// isZero returns true if the supplied value is empty, or the zero value of a
+// string, number, or boolean.
+func isZero(v interface{}) bool {
+	switch t := v.(type) {
+	case string:
+		return t == ""
+	case float64:
+		return t == 0
+	case bool:
+		return !t
+	}
+	return isEmpty(v)
+}
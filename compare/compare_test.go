@@ -0,0 +1,135 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type settings struct {
+	Tier    string            `json:"tier,omitempty"`
+	Version int64             `json:"version,omitempty,string"`
+	Labels  map[string]string `json:"labels"`
+}
+
+type instance struct {
+	Name     string    `json:"name,omitempty"`
+	Network  string    `json:"network,omitempty"`
+	Ranges   []string  `json:"ranges"`
+	Settings *settings `json:"settings,omitempty"`
+	Items    []item    `json:"items,omitempty"`
+	Enabled  bool      `json:"enabled"`
+}
+
+type item struct {
+	Name string `json:"name"`
+	Kind string `json:"kind,omitempty"`
+}
+
+func TestDiff(t *testing.T) {
+	cases := map[string]struct {
+		want   interface{}
+		actual interface{}
+		o      []Option
+		diffs  []string
+	}{
+		"Equal": {
+			want:   instance{Name: "cool", Settings: &settings{Tier: "db-n1-standard-1"}},
+			actual: instance{Name: "cool", Settings: &settings{Tier: "db-n1-standard-1"}},
+		},
+		"NilEqualsEmpty": {
+			want:   instance{Ranges: nil, Settings: &settings{Labels: nil}},
+			actual: instance{Ranges: []string{}, Settings: &settings{Labels: map[string]string{}}},
+		},
+		"ResourceURLEqualsName": {
+			want:   instance{Network: "projects/cool/global/networks/default"},
+			actual: instance{Network: "https://www.googleapis.com/compute/v1/projects/cool/global/networks/default"},
+		},
+		"Different": {
+			want:   instance{Name: "cool", Enabled: true, Ranges: []string{"10.0.0.0/8"}},
+			actual: instance{Name: "uncool", Ranges: []string{"10.0.0.0/16"}},
+			diffs:  []string{"enabled", "name", "ranges"},
+		},
+		"OutputOnlyFieldDiffers": {
+			want:   instance{Name: "cool", Settings: &settings{Tier: "db-n1-standard-1"}},
+			actual: instance{Name: "cool", Settings: &settings{Tier: "db-n1-standard-1", Version: 42}},
+			diffs:  []string{"settings.version"},
+		},
+		"IgnoreFields": {
+			want:   instance{Name: "cool", Items: []item{{Name: "a"}}},
+			actual: instance{Name: "cool", Items: []item{{Name: "a", Kind: "cool#item"}}, Settings: &settings{Version: 42}},
+			o:      []Option{IgnoreFields("items.kind", "settings.version")},
+		},
+		"IgnoreUnset": {
+			want:   instance{Name: "cool", Settings: &settings{Tier: "db-n1-standard-1"}},
+			actual: instance{Name: "cool", Enabled: true, Settings: &settings{Tier: "db-n1-standard-1", Version: 42, Labels: map[string]string{"goog": "yes"}}},
+			o:      []Option{IgnoreUnset()},
+		},
+		"IgnoreUnsetSetFieldDiffers": {
+			want:   instance{Name: "cool", Settings: &settings{Tier: "db-n1-standard-2"}},
+			actual: instance{Name: "cool", Settings: &settings{Tier: "db-n1-standard-1", Version: 42}},
+			o:      []Option{IgnoreUnset()},
+			diffs:  []string{"settings.tier"},
+		},
+		"ReorderedListDiffers": {
+			want:   instance{Ranges: []string{"10.0.0.0/8", "192.168.0.0/16"}},
+			actual: instance{Ranges: []string{"192.168.0.0/16", "10.0.0.0/8"}},
+			diffs:  []string{"ranges"},
+		},
+		"Unordered": {
+			want:   instance{Ranges: []string{"10.0.0.0/8", "192.168.0.0/16"}, Items: []item{{Name: "a"}, {Name: "b"}}},
+			actual: instance{Ranges: []string{"192.168.0.0/16", "10.0.0.0/8"}, Items: []item{{Name: "b", Kind: "k"}, {Name: "a", Kind: "k"}}},
+			o:      []Option{Unordered("ranges", "items"), IgnoreFields("items.kind")},
+		},
+		"UnorderedElementDiffers": {
+			want:   instance{Ranges: []string{"10.0.0.0/8", "192.168.0.0/16"}},
+			actual: instance{Ranges: []string{"192.168.0.0/16", "172.16.0.0/12"}},
+			o:      []Option{Unordered("ranges")},
+			diffs:  []string{"ranges"},
+		},
+		"UnorderedDuplicates": {
+			want:   instance{Ranges: []string{"10.0.0.0/8", "10.0.0.0/8"}},
+			actual: instance{Ranges: []string{"10.0.0.0/8", "192.168.0.0/16"}},
+			o:      []Option{Unordered("ranges")},
+			diffs:  []string{"ranges"},
+		},
+		"UnorderedRoot": {
+			want:   []string{"a", "b"},
+			actual: []string{"b", "a"},
+			o:      []Option{Unordered("")},
+		},
+		"Unserialisable": {
+			want:   instance{},
+			actual: func() {},
+			diffs:  []string{""},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := Diff(tc.want, tc.actual, tc.o...)
+			if diff := cmp.Diff(tc.diffs, got); diff != "" {
+				t.Errorf("Diff(...): -want, +got:\n%s", diff)
+			}
+			if Equal(tc.want, tc.actual, tc.o...) != (len(tc.diffs) == 0) {
+				t.Errorf("Equal(...): want %t", len(tc.diffs) == 0)
+			}
+		})
+	}
+}
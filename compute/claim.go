@@ -23,12 +23,14 @@ import (
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	computev1alpha1 "github.com/crossplaneio/crossplane/apis/compute/v1alpha1"
 	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
 	"github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/shard"
 	"github.com/crossplaneio/crossplane/pkg/resource"
 )
 
@@ -53,7 +55,7 @@ func (c *GKEClusterClaimController) SetupWithManager(mgr ctrl.Manager) error {
 
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(name).
-		Watches(&source.Kind{Type: &v1alpha1.GKECluster{}}, &resource.EnqueueRequestForClaim{}).
+		Watches(&source.Kind{Type: &v1alpha1.GKECluster{}}, shard.FilterRequests(mgr.GetClient(), func() runtime.Object { return &computev1alpha1.KubernetesCluster{} }, &resource.EnqueueRequestForClaim{})).
 		For(&computev1alpha1.KubernetesCluster{}).
 		WithEventFilter(resource.NewPredicates(resource.ObjectHasProvisioner(mgr.GetClient(), p))).
 		Complete(r)
@@ -82,6 +84,19 @@ func ConfigureGKECluster(_ context.Context, cm resource.Claim, cs resource.Class
 	spec.ProviderReference = rs.ProviderReference
 	spec.ReclaimPolicy = rs.ReclaimPolicy
 
+	// GKE manages node pools for Autopilot clusters, so we drop any node pool
+	// defaults that were applied while parsing the class parameters. Node pool
+	// fields the class sets explicitly are kept, so that the class is reported
+	// as invalid rather than silently provisioning a different cluster.
+	if spec.Autopilot {
+		if _, ok := rs.Parameters["machineType"]; !ok {
+			spec.MachineType = ""
+		}
+		if _, ok := rs.Parameters["numNodes"]; !ok {
+			spec.NumNodes = 0
+		}
+	}
+
 	i.Spec = *spec
 
 	return nil
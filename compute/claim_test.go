@@ -79,6 +79,59 @@ func TestConfigureGKECluster(t *testing.T) {
 				err: nil,
 			},
 		},
+		"Autopilot": {
+			args: args{
+				cm: &computev1alpha1.KubernetesCluster{ObjectMeta: metav1.ObjectMeta{UID: claimUID}},
+				cs: &corev1alpha1.ResourceClass{
+					ProviderReference: &corev1.ObjectReference{Name: providerName},
+					ReclaimPolicy:     corev1alpha1.ReclaimDelete,
+					Parameters:        map[string]string{"autopilot": "true"},
+				},
+				mg: &v1alpha1.GKECluster{},
+			},
+			want: want{
+				mg: &v1alpha1.GKECluster{
+					Spec: v1alpha1.GKEClusterSpec{
+						ResourceSpec: corev1alpha1.ResourceSpec{
+							ReclaimPolicy:                    corev1alpha1.ReclaimDelete,
+							WriteConnectionSecretToReference: corev1.LocalObjectReference{Name: string(claimUID)},
+							ProviderReference:                &corev1.ObjectReference{Name: providerName},
+						},
+						Autopilot: true,
+						Scopes:    []string{},
+						Labels:    map[string]string{},
+					},
+				},
+				err: nil,
+			},
+		},
+		"AutopilotNodePoolFields": {
+			args: args{
+				cm: &computev1alpha1.KubernetesCluster{ObjectMeta: metav1.ObjectMeta{UID: claimUID}},
+				cs: &corev1alpha1.ResourceClass{
+					ProviderReference: &corev1.ObjectReference{Name: providerName},
+					ReclaimPolicy:     corev1alpha1.ReclaimDelete,
+					Parameters:        map[string]string{"autopilot": "true", "machineType": "n1-standard-1"},
+				},
+				mg: &v1alpha1.GKECluster{},
+			},
+			want: want{
+				mg: &v1alpha1.GKECluster{
+					Spec: v1alpha1.GKEClusterSpec{
+						ResourceSpec: corev1alpha1.ResourceSpec{
+							ReclaimPolicy:                    corev1alpha1.ReclaimDelete,
+							WriteConnectionSecretToReference: corev1.LocalObjectReference{Name: string(claimUID)},
+							ProviderReference:                &corev1.ObjectReference{Name: providerName},
+						},
+						Autopilot:   true,
+						MachineType: "n1-standard-1",
+						Scopes:      []string{},
+						Labels:      map[string]string{},
+					},
+				},
+				err: nil,
+			},
+		},
 	}
 
 	for name, tc := range cases {
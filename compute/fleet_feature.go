@@ -0,0 +1,547 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	gkehubv1 "google.golang.org/api/gkehub/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/gkehub"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	fleetFeatureControllerName = "fleetfeatures.compute.gcp.crossplane.io"
+	fleetFeatureFinalizer      = "finalizer." + fleetFeatureControllerName
+
+	fleetFeatureReconcileTimeout = 1 * time.Minute
+
+	// The fleet features that may be enabled.
+	fleetFeatureConfigManagement = "configmanagement"
+	fleetFeaturePolicyController = "policycontroller"
+
+	// fleetFeatureActive is the state of a fleet feature that is enabled.
+	fleetFeatureActive = "ACTIVE"
+
+	// Config Sync reads hierarchical repositories unless told otherwise.
+	configSyncDefaultSourceFormat = "hierarchy"
+
+	policyControllerInstallEnabled = "INSTALL_SPEC_ENABLED"
+)
+
+var fleetFeatureLog = logging.Logger.WithName("controller." + fleetFeatureControllerName)
+
+// A fleetFeatureCreateSyncDeleter can create, sync, and delete fleet features
+// in an external store - e.g. the GCP API. Each method returns true if the
+// feature requires further reconciliation.
+type fleetFeatureCreateSyncDeleter interface {
+	Create(ctx context.Context, f *gcpcomputev1alpha1.FleetFeature) (requeue bool)
+	Sync(ctx context.Context, f *gcpcomputev1alpha1.FleetFeature) (requeue bool)
+	Delete(ctx context.Context, f *gcpcomputev1alpha1.FleetFeature) (requeue bool)
+}
+
+// fleetFeatures is a fleetFeatureCreateSyncDeleter using the GKE Hub API. A
+// feature is enabled once per project, so each project's feature should be
+// managed by at most one FleetFeature. The feature is configured for exactly
+// the memberships of the FleetFeature's spec.
+type fleetFeatures struct {
+	client  gkehub.Client
+	project string
+}
+
+// Create enables the feature for the memberships of the supplied FleetFeature.
+// Their memberships must have been resolved.
+func (c *fleetFeatures) Create(ctx context.Context, f *gcpcomputev1alpha1.FleetFeature) bool {
+	f.Status.SetConditions(corev1alpha1.Creating())
+
+	if err := validateFleetFeature(f.Spec); err != nil {
+		f.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	meta.AddFinalizer(f, fleetFeatureFinalizer)
+
+	parent := fmt.Sprintf("projects/%s/locations/%s", c.project, fleetLocation)
+	feature := &gkehubv1.Feature{MembershipSpecs: desiredFleetMembershipSpecs(f)}
+	if err := c.client.CreateFeature(ctx, parent, f.Spec.Feature, feature); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		f.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot enable fleet feature %s", f.Spec.Feature)))
+		return true
+	}
+
+	f.Status.FeatureName = fmt.Sprintf("%s/features/%s", parent, f.Spec.Feature)
+	f.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync updates the configuration of any membership that differs from the
+// supplied FleetFeature's spec, and removes the configuration of memberships
+// that are no longer in its spec.
+func (c *fleetFeatures) Sync(ctx context.Context, f *gcpcomputev1alpha1.FleetFeature) bool {
+	actual, err := c.client.GetFeature(ctx, f.Status.FeatureName)
+	if googleapi.IsErrorNotFound(err) {
+		// The feature was disabled outside of Crossplane. Enable it again.
+		f.Status.SetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(errors.Errorf("fleet feature %s not found", f.Status.FeatureName)))
+		f.Status.FeatureName = ""
+		return true
+	}
+	if err != nil {
+		f.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot get fleet feature %s", f.Status.FeatureName)))
+		return true
+	}
+
+	if actual.ResourceState != nil {
+		f.Status.State = actual.ResourceState.State
+	}
+	observeFleetMembershipStates(f, actual)
+
+	if err := validateFleetFeature(f.Spec); err != nil {
+		f.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	if update := fleetMembershipSpecUpdate(f, actual); len(update) > 0 {
+		if err := c.client.UpdateFeature(ctx, f.Status.FeatureName, &gkehubv1.Feature{MembershipSpecs: update}, "membershipSpecs"); err != nil {
+			f.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot update fleet feature %s", f.Status.FeatureName)))
+			return true
+		}
+		f.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	if f.Status.State != fleetFeatureActive {
+		f.Status.SetConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	f.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete disables the feature for the whole project.
+func (c *fleetFeatures) Delete(ctx context.Context, f *gcpcomputev1alpha1.FleetFeature) bool {
+	f.Status.SetConditions(corev1alpha1.Deleting())
+
+	if f.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete && f.Status.FeatureName != "" {
+		if err := c.client.DeleteFeature(ctx, f.Status.FeatureName); err != nil && !googleapi.IsErrorNotFound(err) {
+			f.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot disable fleet feature %s", f.Status.FeatureName)))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(f, fleetFeatureFinalizer)
+	f.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// validateFleetFeature returns an error if the supplied spec configures a
+// membership with settings its feature does not support.
+func validateFleetFeature(spec gcpcomputev1alpha1.FleetFeatureSpec) error {
+	for _, m := range spec.Memberships {
+		switch spec.Feature {
+		case fleetFeatureConfigManagement:
+			if m.ConfigSync == nil && m.PolicyController == nil {
+				return errors.Errorf("membership of cluster %s must configure configSync, policyController, or both", m.ClusterRef.Name)
+			}
+			if m.PolicyController != nil && len(m.PolicyController.Bundles) > 0 {
+				return errors.Errorf("membership of cluster %s configures policy bundles, which are only supported by the %s feature", m.ClusterRef.Name, fleetFeaturePolicyController)
+			}
+		case fleetFeaturePolicyController:
+			if m.ConfigSync != nil {
+				return errors.Errorf("membership of cluster %s configures configSync, which is only supported by the %s feature", m.ClusterRef.Name, fleetFeatureConfigManagement)
+			}
+			if m.PolicyController == nil {
+				return errors.Errorf("membership of cluster %s must configure policyController", m.ClusterRef.Name)
+			}
+		default:
+			return errors.Errorf("fleet feature must be %s or %s", fleetFeatureConfigManagement, fleetFeaturePolicyController)
+		}
+		if cs := m.ConfigSync; cs != nil && cs.SyncRepo == "" {
+			return errors.Errorf("configSync of cluster %s must specify a syncRepo", m.ClusterRef.Name)
+		}
+	}
+	return nil
+}
+
+// desiredFleetMembershipSpecs returns the GKE Hub membership specs of the
+// supplied FleetFeature, keyed by membership name.
+func desiredFleetMembershipSpecs(f *gcpcomputev1alpha1.FleetFeature) map[string]gkehubv1.MembershipFeatureSpec {
+	memberships := map[string]string{}
+	for _, s := range f.Status.Memberships {
+		memberships[s.Cluster] = s.Membership
+	}
+
+	specs := make(map[string]gkehubv1.MembershipFeatureSpec, len(f.Spec.Memberships))
+	for _, m := range f.Spec.Memberships {
+		name, ok := memberships[m.ClusterRef.Name]
+		if !ok {
+			continue
+		}
+		specs[name] = newFleetMembershipSpec(f.Spec.Feature, m)
+	}
+	return specs
+}
+
+// newFleetMembershipSpec returns the GKE Hub spec of the supplied membership
+// for the supplied feature.
+func newFleetMembershipSpec(feature string, m gcpcomputev1alpha1.FleetFeatureMembership) gkehubv1.MembershipFeatureSpec {
+	if feature == fleetFeaturePolicyController {
+		pc := m.PolicyController
+		bundles := make(map[string]gkehubv1.PolicyControllerBundleInstallSpec, len(pc.Bundles))
+		for _, b := range pc.Bundles {
+			bundles[b] = gkehubv1.PolicyControllerBundleInstallSpec{}
+		}
+		return gkehubv1.MembershipFeatureSpec{Policycontroller: &gkehubv1.PolicyControllerMembershipSpec{
+			PolicyControllerHubConfig: &gkehubv1.PolicyControllerHubConfig{
+				InstallSpec:             policyControllerInstallEnabled,
+				AuditIntervalSeconds:    pc.AuditIntervalSeconds,
+				ExemptableNamespaces:    pc.ExemptableNamespaces,
+				ReferentialRulesEnabled: pc.ReferentialRulesEnabled,
+				PolicyContent: &gkehubv1.PolicyControllerPolicyContentSpec{
+					TemplateLibrary: policyControllerTemplateLibrary(pc.TemplateLibraryInstalled),
+					Bundles:         bundles,
+				},
+			},
+		}}
+	}
+
+	cm := &gkehubv1.ConfigManagementMembershipSpec{}
+	if cs := m.ConfigSync; cs != nil {
+		cm.ConfigSync = &gkehubv1.ConfigManagementConfigSync{
+			SourceFormat: sourceFormat(cs.SourceFormat),
+			Git: &gkehubv1.ConfigManagementGitConfig{
+				SyncRepo:   cs.SyncRepo,
+				SyncBranch: cs.SyncBranch,
+				PolicyDir:  cs.PolicyDir,
+				SecretType: cs.SecretType,
+			},
+		}
+	}
+	if pc := m.PolicyController; pc != nil {
+		cm.PolicyController = &gkehubv1.ConfigManagementPolicyController{
+			Enabled:                  true,
+			AuditIntervalSeconds:     pc.AuditIntervalSeconds,
+			ExemptableNamespaces:     pc.ExemptableNamespaces,
+			ReferentialRulesEnabled:  pc.ReferentialRulesEnabled,
+			TemplateLibraryInstalled: pc.TemplateLibraryInstalled,
+			// Send false explicitly so that these settings may be disabled.
+			ForceSendFields: []string{"ReferentialRulesEnabled", "TemplateLibraryInstalled"},
+		}
+	}
+	return gkehubv1.MembershipFeatureSpec{Configmanagement: cm}
+}
+
+// observedFleetMembership returns the configuration of the supplied GKE Hub
+// membership spec, in the form of a FleetFeatureMembership. Only the settings
+// a FleetFeatureMembership configures are returned, so that the settings GKE
+// Hub defaults do not cause it to be updated on every sync.
+func observedFleetMembership(feature string, s gkehubv1.MembershipFeatureSpec) gcpcomputev1alpha1.FleetFeatureMembership {
+	m := gcpcomputev1alpha1.FleetFeatureMembership{}
+
+	if feature == fleetFeaturePolicyController {
+		if s.Policycontroller == nil || s.Policycontroller.PolicyControllerHubConfig == nil {
+			return m
+		}
+		hc := s.Policycontroller.PolicyControllerHubConfig
+		if hc.InstallSpec != policyControllerInstallEnabled {
+			return m
+		}
+		m.PolicyController = &gcpcomputev1alpha1.FleetPolicyController{
+			AuditIntervalSeconds:    hc.AuditIntervalSeconds,
+			ExemptableNamespaces:    hc.ExemptableNamespaces,
+			ReferentialRulesEnabled: hc.ReferentialRulesEnabled,
+		}
+		if pc := hc.PolicyContent; pc != nil {
+			m.PolicyController.TemplateLibraryInstalled = pc.TemplateLibrary == nil || pc.TemplateLibrary.Installation != "NOT_INSTALLED"
+			for b := range pc.Bundles {
+				m.PolicyController.Bundles = append(m.PolicyController.Bundles, b)
+			}
+			sort.Strings(m.PolicyController.Bundles)
+		}
+		return m
+	}
+
+	cm := s.Configmanagement
+	if cm == nil {
+		return m
+	}
+	if cs := cm.ConfigSync; cs != nil && cs.Git != nil {
+		m.ConfigSync = &gcpcomputev1alpha1.FleetConfigSync{
+			SourceFormat: cs.SourceFormat,
+			SyncRepo:     cs.Git.SyncRepo,
+			SyncBranch:   cs.Git.SyncBranch,
+			PolicyDir:    cs.Git.PolicyDir,
+			SecretType:   cs.Git.SecretType,
+		}
+	}
+	if pc := cm.PolicyController; pc != nil && pc.Enabled {
+		m.PolicyController = &gcpcomputev1alpha1.FleetPolicyController{
+			AuditIntervalSeconds:     pc.AuditIntervalSeconds,
+			ExemptableNamespaces:     pc.ExemptableNamespaces,
+			ReferentialRulesEnabled:  pc.ReferentialRulesEnabled,
+			TemplateLibraryInstalled: pc.TemplateLibraryInstalled,
+		}
+	}
+	return m
+}
+
+// fleetMembershipSpecUpdate returns the membership specs that must be updated
+// for the supplied actual feature to match the supplied FleetFeature, keyed by
+// membership name. Memberships that are configured but are no longer in the
+// FleetFeature's spec map to an empty spec, which removes their configuration.
+func fleetMembershipSpecUpdate(f *gcpcomputev1alpha1.FleetFeature, actual *gkehubv1.Feature) map[string]gkehubv1.MembershipFeatureSpec {
+	// GKE Hub may return memberships named by project number rather than
+	// project ID, so memberships are matched by ID.
+	existing := make(map[string]gkehubv1.MembershipFeatureSpec, len(actual.MembershipSpecs))
+	names := make(map[string]string, len(actual.MembershipSpecs))
+	for name, s := range actual.MembershipSpecs {
+		existing[membershipID(name)] = s
+		names[membershipID(name)] = name
+	}
+
+	update := map[string]gkehubv1.MembershipFeatureSpec{}
+	desired := desiredFleetMembershipSpecs(f)
+	for name, s := range desired {
+		a, ok := existing[membershipID(name)]
+		delete(names, membershipID(name))
+		if ok && fleetMembershipUpToDate(f.Spec.Feature, s, a) {
+			continue
+		}
+		update[name] = s
+	}
+	for _, name := range names {
+		update[name] = gkehubv1.MembershipFeatureSpec{}
+	}
+	return update
+}
+
+// fleetMembershipUpToDate returns true if the supplied actual membership spec
+// configures the supplied feature as the supplied desired spec does.
+func fleetMembershipUpToDate(feature string, desired, actual gkehubv1.MembershipFeatureSpec) bool {
+	d := observedFleetMembership(feature, desired)
+	a := observedFleetMembership(feature, actual)
+	if a.ConfigSync != nil {
+		a.ConfigSync.SourceFormat = sourceFormat(a.ConfigSync.SourceFormat)
+	}
+	return compare.Equal(d, a)
+}
+
+// observeFleetMembershipStates records the state of each membership of the
+// supplied FleetFeature reported by the supplied actual feature.
+func observeFleetMembershipStates(f *gcpcomputev1alpha1.FleetFeature, actual *gkehubv1.Feature) {
+	states := make(map[string]*gkehubv1.FeatureState, len(actual.MembershipStates))
+	for name, s := range actual.MembershipStates {
+		states[membershipID(name)] = s.State
+	}
+	for i := range f.Status.Memberships {
+		m := &f.Status.Memberships[i]
+		m.State, m.Description = "", ""
+		if s := states[membershipID(m.Membership)]; s != nil {
+			m.State, m.Description = s.Code, s.Description
+		}
+	}
+}
+
+// resolveFleetMemberships records the fleet membership of each GKE cluster
+// referenced by the supplied FleetFeature. It returns an error if a cluster is
+// not registered with a fleet.
+func resolveFleetMemberships(ctx context.Context, kube client.Client, f *gcpcomputev1alpha1.FleetFeature) error {
+	previous := make(map[string]gcpcomputev1alpha1.FleetFeatureMembershipStatus, len(f.Status.Memberships))
+	for _, s := range f.Status.Memberships {
+		previous[s.Cluster] = s
+	}
+
+	statuses := make([]gcpcomputev1alpha1.FleetFeatureMembershipStatus, 0, len(f.Spec.Memberships))
+	for _, m := range f.Spec.Memberships {
+		c := &gcpcomputev1alpha1.GKECluster{}
+		n := types.NamespacedName{Namespace: f.GetNamespace(), Name: m.ClusterRef.Name}
+		if err := kube.Get(ctx, n, c); err != nil {
+			return errors.Wrapf(err, "cannot get GKE cluster %s", n)
+		}
+		if c.Status.FleetMembership == "" {
+			return errors.Errorf("GKE cluster %s is not registered with a fleet", n)
+		}
+		s := previous[m.ClusterRef.Name]
+		s.Cluster, s.Membership = m.ClusterRef.Name, c.Status.FleetMembership
+		statuses = append(statuses, s)
+	}
+	f.Status.Memberships = statuses
+	return nil
+}
+
+func membershipID(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+func sourceFormat(f string) string {
+	if f == "" {
+		return configSyncDefaultSourceFormat
+	}
+	return f
+}
+
+func policyControllerTemplateLibrary(installed bool) *gkehubv1.PolicyControllerTemplateLibraryConfig {
+	if installed {
+		return &gkehubv1.PolicyControllerTemplateLibraryConfig{Installation: "ALL"}
+	}
+	return &gkehubv1.PolicyControllerTemplateLibraryConfig{Installation: "NOT_INSTALLED"}
+}
+
+// A fleetFeatureConnecter returns a fleetFeatureCreateSyncDeleter that can
+// create, sync, and delete fleet features with an external store - for
+// example the GCP API.
+type fleetFeatureConnecter interface {
+	Connect(context.Context, *gcpcomputev1alpha1.FleetFeature) (fleetFeatureCreateSyncDeleter, error)
+}
+
+// fleetFeatureProviderConnecter is a fleetFeatureConnecter that returns a
+// fleetFeatureCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type fleetFeatureProviderConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (gkehub.Client, error)
+}
+
+// Connect returns a fleetFeatureCreateSyncDeleter backed by the GKE Hub API.
+// GCP credentials are read from the Crossplane Provider referenced by the
+// supplied FleetFeature.
+func (c *fleetFeatureProviderConnecter) Connect(ctx context.Context, f *gcpcomputev1alpha1.FleetFeature) (fleetFeatureCreateSyncDeleter, error) {
+	p, err := c.providers.Get(ctx, c.kube, f, f.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceGKEHub, gkehub.DefaultScope)
+	if err != nil {
+		return nil, err
+	}
+
+	hc, err := c.newClient(ctx, creds)
+	return &fleetFeatures{client: hc, project: p.Spec.ProjectID}, errors.Wrap(err, "cannot create new GKE Hub client")
+}
+
+// FleetFeatureReconciler reconciles FleetFeatures read from the Kubernetes API
+// with an external store, typically the GCP API.
+type FleetFeatureReconciler struct {
+	fleetFeatureConnecter
+	kube client.Client
+}
+
+// FleetFeatureController is responsible for adding the FleetFeature
+// controller and its corresponding reconciler to the manager with any runtime
+// configuration.
+type FleetFeatureController struct {
+	// DefaultProvider is used by features that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new FleetFeature Controller and adds it to the
+// Manager with default RBAC. The Manager will set fields on the Controller and
+// start it when the Manager is Started.
+func (c *FleetFeatureController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &FleetFeatureReconciler{
+		fleetFeatureConnecter: &fleetFeatureProviderConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: gkehub.NewClient,
+		},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(fleetFeatureControllerName).
+		For(&gcpcomputev1alpha1.FleetFeature{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listFleetFeatures)).
+		Complete(r)
+}
+
+// Reconcile fleet features with the GCP API.
+func (r *FleetFeatureReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	fleetFeatureLog.V(logging.Debug).Info("reconciling", "kind", gcpcomputev1alpha1.FleetFeatureKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), fleetFeatureReconcileTimeout)
+	defer cancel()
+
+	f := &gcpcomputev1alpha1.FleetFeature{}
+	if err := r.kube.Get(ctx, req.NamespacedName, f); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get fleet feature %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, f)
+	if err != nil {
+		f.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, f), "cannot update fleet feature %s", req.NamespacedName)
+	}
+
+	// The feature has been deleted from the API server. Disable it in GCP.
+	if f.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, f)}, errors.Wrapf(r.kube.Update(ctx, f), "cannot update fleet feature %s", req.NamespacedName)
+	}
+
+	// Clusters are registered with their fleet asynchronously, so we wait
+	// for each cluster's membership before configuring it.
+	if err := resolveFleetMemberships(ctx, r.kube, f); err != nil {
+		f.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, f), "cannot update fleet feature %s", req.NamespacedName)
+	}
+
+	// The feature is unnamed. Assume it has not been enabled.
+	if f.Status.FeatureName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, f)}, errors.Wrapf(r.kube.Update(ctx, f), "cannot update fleet feature %s", req.NamespacedName)
+	}
+
+	// The feature exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, f)}, errors.Wrapf(r.kube.Update(ctx, f), "cannot update fleet feature %s", req.NamespacedName)
+}
+
+// listFleetFeatures is a provider.Lister of fleet features.
+func listFleetFeatures(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &gcpcomputev1alpha1.FleetFeatureList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
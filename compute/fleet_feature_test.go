@@ -0,0 +1,359 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	gkehubv1 "google.golang.org/api/gkehub/v1"
+	"google.golang.org/api/googleapi"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	fakegkehub "github.com/crossplaneio/crossplane/pkg/clients/gcp/gkehub/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	featureProject    = "cool-project"
+	featureName       = "projects/cool-project/locations/global/features/" + fleetFeatureConfigManagement
+	featureCluster    = "cool-cluster"
+	featureMembership = "projects/cool-project/locations/global/memberships/cool-cluster"
+	featureRepo       = "https://example.org/config.git"
+)
+
+var (
+	errFeatureBoom     = errors.New("boom")
+	errFeatureNotFound = &googleapi.Error{Code: http.StatusNotFound}
+)
+
+// Test that our Reconciler implementation satisfies the Reconciler interface.
+var _ reconcile.Reconciler = &FleetFeatureReconciler{}
+
+type fleetFeatureModifier func(*gcpcomputev1alpha1.FleetFeature)
+
+func withFeatureConditions(c ...corev1alpha1.Condition) fleetFeatureModifier {
+	return func(f *gcpcomputev1alpha1.FleetFeature) { f.Status.SetConditions(c...) }
+}
+
+func withFeatureFinalizers(fi ...string) fleetFeatureModifier {
+	return func(f *gcpcomputev1alpha1.FleetFeature) { f.ObjectMeta.Finalizers = fi }
+}
+
+func withFeatureReclaimPolicy(r corev1alpha1.ReclaimPolicy) fleetFeatureModifier {
+	return func(f *gcpcomputev1alpha1.FleetFeature) { f.Spec.ReclaimPolicy = r }
+}
+
+func withFeatureName(n string) fleetFeatureModifier {
+	return func(f *gcpcomputev1alpha1.FleetFeature) { f.Status.FeatureName = n }
+}
+
+func withFeatureState(s string) fleetFeatureModifier {
+	return func(f *gcpcomputev1alpha1.FleetFeature) { f.Status.State = s }
+}
+
+func withFeatureMembershipState(code string) fleetFeatureModifier {
+	return func(f *gcpcomputev1alpha1.FleetFeature) { f.Status.Memberships[0].State = code }
+}
+
+func withFeatureSyncRepo(r string) fleetFeatureModifier {
+	return func(f *gcpcomputev1alpha1.FleetFeature) { f.Spec.Memberships[0].ConfigSync.SyncRepo = r }
+}
+
+func fleetFeature(fm ...fleetFeatureModifier) *gcpcomputev1alpha1.FleetFeature {
+	f := &gcpcomputev1alpha1.FleetFeature{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "cool-namespace",
+			Name:       "cool-feature",
+			Finalizers: []string{},
+		},
+		Spec: gcpcomputev1alpha1.FleetFeatureSpec{
+			Feature: fleetFeatureConfigManagement,
+			Memberships: []gcpcomputev1alpha1.FleetFeatureMembership{{
+				ClusterRef: corev1.LocalObjectReference{Name: featureCluster},
+				ConfigSync: &gcpcomputev1alpha1.FleetConfigSync{SyncRepo: featureRepo, SecretType: "none"},
+			}},
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: "cool-namespace", Name: "cool-provider"},
+			},
+		},
+		Status: gcpcomputev1alpha1.FleetFeatureStatus{
+			Memberships: []gcpcomputev1alpha1.FleetFeatureMembershipStatus{{Cluster: featureCluster, Membership: featureMembership}},
+		},
+	}
+
+	for _, m := range fm {
+		m(f)
+	}
+
+	return f
+}
+
+// actualFeature returns the feature GKE Hub reports for the supplied spec,
+// naming memberships by project number and defaulting the source format.
+func actualFeature(spec gcpcomputev1alpha1.FleetFeatureSpec, state, code string) *gkehubv1.Feature {
+	s := newFleetMembershipSpec(spec.Feature, spec.Memberships[0])
+	s.Configmanagement.ConfigSync.SourceFormat = ""
+	s.Configmanagement.Version = "1.17.0"
+	name := "projects/123456/locations/global/memberships/" + featureCluster
+	return &gkehubv1.Feature{
+		ResourceState:    &gkehubv1.FeatureResourceState{State: state},
+		MembershipSpecs:  map[string]gkehubv1.MembershipFeatureSpec{name: s},
+		MembershipStates: map[string]gkehubv1.MembershipFeatureState{name: {State: &gkehubv1.FeatureState{Code: code}}},
+	}
+}
+
+func TestFleetFeatureCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         fleetFeatureCreateSyncDeleter
+		f           *gcpcomputev1alpha1.FleetFeature
+		want        *gcpcomputev1alpha1.FleetFeature
+		wantRequeue bool
+	}{
+		{
+			name: "SuccessfulCreate",
+			csd: &fleetFeatures{project: featureProject, client: &fakegkehub.MockClient{
+				MockCreateFeature: func(_ context.Context, _, id string, f *gkehubv1.Feature) error {
+					if id != fleetFeatureConfigManagement {
+						t.Errorf("feature ID: want %s, got %s", fleetFeatureConfigManagement, id)
+					}
+					if got := f.MembershipSpecs[featureMembership].Configmanagement.ConfigSync.Git.SyncRepo; got != featureRepo {
+						t.Errorf("sync repo: want %s, got %s", featureRepo, got)
+					}
+					return nil
+				},
+			}},
+			f: fleetFeature(),
+			want: fleetFeature(
+				withFeatureConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+				withFeatureFinalizers(fleetFeatureFinalizer),
+				withFeatureName(featureName),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "MissingSyncRepo",
+			csd:  &fleetFeatures{project: featureProject, client: &fakegkehub.MockClient{}},
+			f:    fleetFeature(withFeatureSyncRepo("")),
+			want: fleetFeature(
+				withFeatureSyncRepo(""),
+				withFeatureConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Errorf("configSync of cluster %s must specify a syncRepo", featureCluster))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &fleetFeatures{project: featureProject, client: &fakegkehub.MockClient{
+				MockCreateFeature: func(_ context.Context, _, _ string, _ *gkehubv1.Feature) error { return errFeatureBoom },
+			}},
+			f: fleetFeature(),
+			want: fleetFeature(
+				withFeatureConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrapf(errFeatureBoom, "cannot enable fleet feature %s", fleetFeatureConfigManagement))),
+				withFeatureFinalizers(fleetFeatureFinalizer),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(context.Background(), tc.f)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.f, test.EquateConditions()); diff != "" {
+				t.Errorf("f: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFleetFeatureSync(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         fleetFeatureCreateSyncDeleter
+		f           *gcpcomputev1alpha1.FleetFeature
+		want        *gcpcomputev1alpha1.FleetFeature
+		wantRequeue bool
+	}{
+		{
+			name: "UpToDate",
+			csd: &fleetFeatures{project: featureProject, client: &fakegkehub.MockClient{
+				MockGetFeature: func(_ context.Context, _ string) (*gkehubv1.Feature, error) {
+					return actualFeature(fleetFeature().Spec, fleetFeatureActive, "OK"), nil
+				},
+			}},
+			f: fleetFeature(withFeatureName(featureName)),
+			want: fleetFeature(
+				withFeatureName(featureName),
+				withFeatureState(fleetFeatureActive),
+				withFeatureMembershipState("OK"),
+				withFeatureConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "Enabling",
+			csd: &fleetFeatures{project: featureProject, client: &fakegkehub.MockClient{
+				MockGetFeature: func(_ context.Context, _ string) (*gkehubv1.Feature, error) {
+					return actualFeature(fleetFeature().Spec, "ENABLING", ""), nil
+				},
+			}},
+			f: fleetFeature(withFeatureName(featureName)),
+			want: fleetFeature(
+				withFeatureName(featureName),
+				withFeatureState("ENABLING"),
+				withFeatureConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "NeedsUpdate",
+			csd: &fleetFeatures{project: featureProject, client: &fakegkehub.MockClient{
+				MockGetFeature: func(_ context.Context, _ string) (*gkehubv1.Feature, error) {
+					return actualFeature(fleetFeature(withFeatureSyncRepo("https://example.org/old.git")).Spec, fleetFeatureActive, "OK"), nil
+				},
+				MockUpdateFeature: func(_ context.Context, _ string, f *gkehubv1.Feature, mask string) error {
+					if mask != "membershipSpecs" {
+						t.Errorf("update mask: want membershipSpecs, got %s", mask)
+					}
+					if got := f.MembershipSpecs[featureMembership].Configmanagement.ConfigSync.Git.SyncRepo; got != featureRepo {
+						t.Errorf("sync repo: want %s, got %s", featureRepo, got)
+					}
+					return nil
+				},
+			}},
+			f: fleetFeature(withFeatureName(featureName)),
+			want: fleetFeature(
+				withFeatureName(featureName),
+				withFeatureState(fleetFeatureActive),
+				withFeatureMembershipState("OK"),
+				withFeatureConditions(corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "NotFound",
+			csd: &fleetFeatures{project: featureProject, client: &fakegkehub.MockClient{
+				MockGetFeature: func(_ context.Context, _ string) (*gkehubv1.Feature, error) { return nil, errFeatureNotFound },
+			}},
+			f: fleetFeature(withFeatureName(featureName)),
+			want: fleetFeature(
+				withFeatureConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(errors.Errorf("fleet feature %s not found", featureName))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(context.Background(), tc.f)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.f, test.EquateConditions()); diff != "" {
+				t.Errorf("f: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFleetFeatureDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         fleetFeatureCreateSyncDeleter
+		f           *gcpcomputev1alpha1.FleetFeature
+		want        *gcpcomputev1alpha1.FleetFeature
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimDelete",
+			csd: &fleetFeatures{project: featureProject, client: &fakegkehub.MockClient{
+				MockDeleteFeature: func(_ context.Context, _ string) error { return nil },
+			}},
+			f: fleetFeature(withFeatureName(featureName), withFeatureReclaimPolicy(corev1alpha1.ReclaimDelete), withFeatureFinalizers(fleetFeatureFinalizer)),
+			want: fleetFeature(
+				withFeatureName(featureName),
+				withFeatureReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withFeatureConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimRetain",
+			csd:  &fleetFeatures{project: featureProject, client: &fakegkehub.MockClient{}},
+			f:    fleetFeature(withFeatureName(featureName), withFeatureReclaimPolicy(corev1alpha1.ReclaimRetain), withFeatureFinalizers(fleetFeatureFinalizer)),
+			want: fleetFeature(
+				withFeatureName(featureName),
+				withFeatureReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withFeatureConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedDelete",
+			csd: &fleetFeatures{project: featureProject, client: &fakegkehub.MockClient{
+				MockDeleteFeature: func(_ context.Context, _ string) error { return errFeatureBoom },
+			}},
+			f: fleetFeature(withFeatureName(featureName), withFeatureReclaimPolicy(corev1alpha1.ReclaimDelete), withFeatureFinalizers(fleetFeatureFinalizer)),
+			want: fleetFeature(
+				withFeatureName(featureName),
+				withFeatureReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withFeatureFinalizers(fleetFeatureFinalizer),
+				withFeatureConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrapf(errFeatureBoom, "cannot disable fleet feature %s", featureName))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(context.Background(), tc.f)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.f, test.EquateConditions()); diff != "" {
+				t.Errorf("f: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFleetMembershipSpecUpdate(t *testing.T) {
+	stale := "projects/123456/locations/global/memberships/former-cluster"
+	actual := actualFeature(fleetFeature().Spec, fleetFeatureActive, "OK")
+	actual.MembershipSpecs[stale] = newFleetMembershipSpec(fleetFeatureConfigManagement, fleetFeature().Spec.Memberships[0])
+
+	want := map[string]gkehubv1.MembershipFeatureSpec{stale: {}}
+	if diff := cmp.Diff(want, fleetMembershipSpecUpdate(fleetFeature(), actual)); diff != "" {
+		t.Errorf("fleetMembershipSpecUpdate(...): -want, +got:\n%s", diff)
+	}
+}
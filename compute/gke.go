@@ -23,21 +23,30 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
 	"google.golang.org/api/container/v1"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
 	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
-	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
 	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
 	"github.com/crossplaneio/crossplane/pkg/clients/gcp/gke"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/gkehub"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/deadline"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/externalname"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/plan"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/secretgc"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/tracing"
 	"github.com/crossplaneio/crossplane/pkg/logging"
 	"github.com/crossplaneio/crossplane/pkg/meta"
 	"github.com/crossplaneio/crossplane/pkg/resource"
@@ -68,27 +77,47 @@ type Reconciler struct {
 	scheme     *runtime.Scheme
 	kubeclient kubernetes.Interface
 	recorder   record.EventRecorder
-
-	connect func(*gcpcomputev1alpha1.GKECluster) (gke.Client, error)
-	create  func(*gcpcomputev1alpha1.GKECluster, gke.Client) (reconcile.Result, error)
-	sync    func(*gcpcomputev1alpha1.GKECluster, gke.Client) (reconcile.Result, error)
-	delete  func(*gcpcomputev1alpha1.GKECluster, gke.Client) (reconcile.Result, error)
+	providers  provider.Resolver
+	clusters   *clusterCache
+	services   *provider.ServiceEnabler
+
+	connect        func(*gcpcomputev1alpha1.GKECluster) (gke.Client, error)
+	connectFleet   func(*gcpcomputev1alpha1.GKECluster) (gkehub.Client, error)
+	connectCluster func(*corev1.Secret) (client.Client, error)
+	machineTypes   func(*gcpcomputev1alpha1.GKECluster) (machineTypeLookup, error)
+	nodePoolSizes  func(*gcpcomputev1alpha1.GKECluster) (nodePoolSizer, error)
+	subnetworks    func(*gcpcomputev1alpha1.GKECluster) (subnetworkClient, error)
+	create         func(*gcpcomputev1alpha1.GKECluster, gke.Client) (reconcile.Result, error)
+	sync           func(*gcpcomputev1alpha1.GKECluster, gke.Client) (reconcile.Result, error)
+	delete         func(*gcpcomputev1alpha1.GKECluster, gke.Client) (reconcile.Result, error)
 }
 
 // GKEClusterController is responsible for adding the GKECluster
 // controller and its corresponding reconciler to the manager with any runtime configuration.
-type GKEClusterController struct{}
+type GKEClusterController struct {
+	// DefaultProvider is used by clusters that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
 
 // SetupWithManager creates a new Controller and adds it to the Manager with default RBAC. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func (c *GKEClusterController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
 	r := &Reconciler{
 		Client:     mgr.GetClient(),
 		scheme:     mgr.GetScheme(),
 		kubeclient: kubernetes.NewForConfigOrDie(mgr.GetConfig()),
 		recorder:   mgr.GetEventRecorderFor(controllerName),
+		providers:  providers,
+		clusters:   newClusterCache(clusterCacheTTL),
+		services:   provider.NewServiceEnabler(mgr.GetClient()),
 	}
 	r.connect = r._connect
+	r.connectFleet = r._connectFleet
+	r.connectCluster = ConnectCluster
+	r.machineTypes = r._machineTypes
+	r.nodePoolSizes = r._nodePoolSizes
+	r.subnetworks = r._subnetworks
 	r.create = r._create
 	r.sync = r._sync
 	r.delete = r._delete
@@ -96,6 +125,7 @@ func (c *GKEClusterController) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(controllerName).
 		For(&gcpcomputev1alpha1.GKECluster{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listGKEClusters)).
 		Complete(r)
 }
 
@@ -105,9 +135,25 @@ func (r *Reconciler) fail(instance *gcpcomputev1alpha1.GKECluster, err error) (r
 	return resultRequeue, r.Update(context.TODO(), instance)
 }
 
+// failCreate is fail for errors creating the named cluster. A cluster that
+// cannot be created is waiting to be provisioned, and is marked as stalled if
+// it has been waiting for longer than its creation deadline.
+func (r *Reconciler) failCreate(instance *gcpcomputev1alpha1.GKECluster, name string, err error) (reconcile.Result, error) {
+	now := time.Now()
+	since, _ := deadline.Observe(instance, gcpcomputev1alpha1.ClusterStateProvisioning, now)
+	if deadline.ExceededSince(instance, since, now) && !deadline.IsStalled(instance.Status.ConditionedStatus) {
+		d := deadline.CreationDeadline(instance)
+		instance.Status.SetConditions(deadline.Stalled(d, err.Error()))
+		r.recorder.Eventf(instance, corev1.EventTypeWarning, deadline.EventReasonStalled,
+			"cluster %s could not be created after %s: %s", name, d, err)
+	}
+	return r.fail(instance, err)
+}
+
 // connectionSecret return secret object for cluster instance
 func (r *Reconciler) connectionSecret(instance *gcpcomputev1alpha1.GKECluster, cluster *container.Cluster) (*corev1.Secret, error) {
 	secret := resource.ConnectionSecretFor(instance, gcpcomputev1alpha1.GKEClusterGroupVersionKind)
+	secretgc.Mark(secret, instance, gcpcomputev1alpha1.GKEClusterGroupVersionKind)
 
 	secret.Data = map[string][]byte{
 		corev1alpha1.ResourceCredentialsSecretEndpointKey: []byte(cluster.Endpoint),
@@ -137,35 +183,108 @@ func (r *Reconciler) connectionSecret(instance *gcpcomputev1alpha1.GKECluster, c
 }
 
 func (r *Reconciler) _connect(instance *gcpcomputev1alpha1.GKECluster) (gke.Client, error) {
-	// Fetch Provider
-	p := &gcpv1alpha1.Provider{}
-	err := r.Get(ctx, meta.NamespacedNameOf(instance.Spec.ProviderReference), p)
+	p, err := r.providers.Get(ctx, r, instance, instance.Spec.ProviderReference)
 	if err != nil {
 		return nil, err
 	}
+	creds, err := provider.ServiceCredentials(ctx, r, p, provider.ServiceContainer, gke.DefaultScope)
+	if err != nil {
+		return nil, err
+	}
+
+	// Clusters are read from a cache shared by all reconciles, keyed by the
+	// project and the Provider whose credentials read them.
+	cl, err := gke.NewClusterClient(ctx, creds)
+	if err != nil || r.clusters == nil || creds.ProjectID == "" {
+		return cl, err
+	}
+	return &cachingClient{Client: cl, cache: r.clusters, key: cacheKey(p, creds.ProjectID)}, nil
+}
 
-	creds, err := gcp.ProviderCredentials(r.kubeclient, p, gke.DefaultScope)
+// enableDisabledService enables the GCP service that the supplied error
+// reports is disabled, if the cluster's Provider enables disabled services. It
+// returns true if it requested that the service be enabled.
+func (r *Reconciler) enableDisabledService(instance *gcpcomputev1alpha1.GKECluster, err error) (bool, error) {
+	if _, _, ok := provider.DisabledService(err); !ok || r.services == nil {
+		return false, nil
+	}
+	p, perr := r.providers.Get(ctx, r, instance, instance.Spec.ProviderReference)
+	if perr != nil {
+		return false, perr
+	}
+	enabling, eerr := r.services.EnableDisabledService(ctx, p, err)
+	if enabling {
+		instance.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "enabling disabled service; will retry")))
+	}
+	return enabling, eerr
+}
+
+// credentials returns credentials for the supplied service with the supplied
+// scope, read from the Provider referenced by the supplied cluster.
+func (r *Reconciler) credentials(instance *gcpcomputev1alpha1.GKECluster, service, scope string) (*google.Credentials, error) {
+	p, err := r.providers.Get(ctx, r, instance, instance.Spec.ProviderReference)
 	if err != nil {
 		return nil, err
 	}
 
-	return gke.NewClusterClient(ctx, creds)
+	return provider.ServiceCredentials(ctx, r, p, service, scope)
 }
 
 func (r *Reconciler) _create(instance *gcpcomputev1alpha1.GKECluster, client gke.Client) (reconcile.Result, error) {
-	instance.Status.SetConditions(corev1alpha1.Creating())
-	clusterName := fmt.Sprintf("%s%s", clusterNamePrefix, instance.UID)
+	if err := validateCluster(instance.Spec); err != nil {
+		instance.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		// do not requeue invalid specs; they will be reconciled again when updated
+		return result, r.Update(ctx, instance)
+	}
 
+	// GKE reports unavailable machine types and CPU platforms only once its
+	// node pools fail to provision.
+	if err := r.checkZoneAvailability(instance); err != nil {
+		if !isZoneAvailabilityError(err) {
+			return r.fail(instance, err)
+		}
+		instance.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return result, r.Update(ctx, instance)
+	}
+
+	defaultNetworkObservability(&instance.Spec)
+
+	// A cluster restored from a backup has a new UID, but reattaches to the
+	// GKE cluster named by its persisted external name.
+	clusterName := externalname.Resolve(instance, fmt.Sprintf("%s%s", clusterNamePrefix, instance.UID))
+	if plan.IsDryRun(instance) {
+		return r.plan(instance, plan.Describe("CreateCluster", map[string]interface{}{"name": clusterName, "spec": instance.Spec}))
+	}
+
+	instance.Status.SetConditions(corev1alpha1.Creating())
 	meta.AddFinalizer(instance, finalizer)
 
+	// Persist the cluster's name before creating it, so that it is never
+	// created under a name that may be forgotten.
+	if externalname.Get(instance) == "" {
+		externalname.Set(instance, clusterName)
+		if err := r.Update(ctx, instance); err != nil {
+			return resultRequeue, errors.Wrapf(err, updateErrorMessageFormat, instance.GetName())
+		}
+	}
+
+	span := startPhase(client, tracing.PhaseCreate)
 	_, err := client.CreateCluster(clusterName, instance.Spec)
+	tracing.End(span, err)
 	if err != nil && !gcp.IsErrorAlreadyExists(err) {
+		// new projects may not have the Kubernetes Engine API enabled yet
+		if enabling, eerr := r.enableDisabledService(instance, err); enabling || eerr != nil {
+			if eerr != nil {
+				return r.failCreate(instance, clusterName, eerr)
+			}
+			return reconcile.Result{RequeueAfter: requeueOnWait}, r.Update(ctx, instance)
+		}
 		if gcp.IsErrorBadRequest(err) {
 			instance.Status.SetConditions(corev1alpha1.ReconcileError(err))
 			// do not requeue on bad requests
 			return result, r.Update(ctx, instance)
 		}
-		return r.fail(instance, err)
+		return r.failCreate(instance, clusterName, err)
 	}
 
 	instance.Status.State = gcpcomputev1alpha1.ClusterStateProvisioning
@@ -176,13 +295,23 @@ func (r *Reconciler) _create(instance *gcpcomputev1alpha1.GKECluster, client gke
 }
 
 func (r *Reconciler) _sync(instance *gcpcomputev1alpha1.GKECluster, client gke.Client) (reconcile.Result, error) {
+	span := startPhase(client, tracing.PhaseObserve)
 	cluster, err := client.GetCluster(instance.Spec.Zone, instance.Status.ClusterName)
-	if err != nil {
+	if err := tracing.End(span, err); err != nil {
 		return r.fail(instance, err)
 	}
 
+	// report conditions and operations observed by GKE as events
+	reported := r.reportObservations(instance, cluster)
+
 	if cluster.Status != gcpcomputev1alpha1.ClusterStateRunning {
-		return reconcile.Result{RequeueAfter: requeueOnWait}, nil
+		return r.wait(instance, cluster, reported)
+	}
+
+	// A running cluster is no longer waiting, and so no longer stalled.
+	deadline.Forget(instance)
+	if deadline.IsStalled(instance.Status.ConditionedStatus) {
+		instance.Status.SetConditions(deadline.Recovered())
 	}
 
 	// create connection secret
@@ -192,26 +321,252 @@ func (r *Reconciler) _sync(instance *gcpcomputev1alpha1.GKECluster, client gke.C
 	}
 
 	// save secret
-	if _, err := util.ApplySecret(r.kubeclient, secret); err != nil {
+	span = startPhase(client, tracing.PhasePublishSecret)
+	_, err = util.ApplySecret(r.kubeclient, secret)
+	if err := tracing.End(span, err); err != nil {
+		return r.fail(instance, err)
+	}
+
+	// apply bootstrap manifests to the new cluster
+	if instance.Spec.Bootstrap != nil && !instance.Status.BootstrapApplied {
+		if plan.IsDryRun(instance) {
+			return r.planBootstrap(instance)
+		}
+		if err := r.bootstrap(instance, secret); err != nil {
+			return r.fail(instance, err)
+		}
+	}
+
+	// register with fleet
+	if instance.Spec.EnableFleetRegistration && instance.Status.FleetMembership == "" {
+		if plan.IsDryRun(instance) {
+			return r.planRegistration(instance, cluster)
+		}
+		if err := r.register(instance, cluster); err != nil {
+			return r.fail(instance, err)
+		}
+	}
+
+	// unregister from fleet if registration is no longer enabled
+	if !instance.Spec.EnableFleetRegistration && instance.Status.FleetMembership != "" {
+		if plan.IsDryRun(instance) {
+			return r.planUnregistration(instance)
+		}
+		if err := r.unregister(instance); err != nil {
+			return r.fail(instance, err)
+		}
+		instance.Status.FleetMembership = ""
+	}
+
+	// revert or adopt resource labels changed outside of the GKECluster
+	if labelsDrifted(instance.Spec, cluster) {
+		return r.reconcileLabels(instance, client, cluster)
+	}
+
+	// converge the master version, network policy, and maintenance policy
+	if v, ok := masterVersionUpdate(instance.Spec, cluster); ok {
+		return r.updateMasterVersion(instance, client, v)
+	}
+	if p, ok := networkPolicyUpdate(instance.Spec, cluster); ok {
+		return r.setNetworkPolicy(instance, client, p)
+	}
+	if u := networkPolicyAddonUpdate(instance.Spec, cluster); u != nil {
+		return r.updateCluster(instance, client, u)
+	}
+	if p, ok := maintenancePolicyUpdate(instance.Spec, cluster); ok {
+		return r.setMaintenancePolicy(instance, client, p)
+	}
+
+	// converge cost allocation and usage metering
+	if u := meteringUpdate(instance.Spec, cluster); u != nil {
+		return r.updateCluster(instance, client, u)
+	}
+
+	// converge upgrade and security bulletin notifications
+	u, err := notificationUpdate(instance.Spec, cluster)
+	if err != nil {
+		return r.fail(instance, err)
+	}
+	if u != nil {
+		return r.updateCluster(instance, client, u)
+	}
+
+	// converge intranode visibility
+	if u := networkUpdate(instance.Spec, cluster); u != nil {
+		return r.updateCluster(instance, client, u)
+	}
+
+	// converge VPC flow logs of the cluster's subnetwork
+	p, err := r.flowLogsPatch(instance, cluster)
+	if err != nil {
+		return r.fail(instance, err)
+	}
+	if p != nil {
+		return r.patchSubnetwork(instance, client, p)
+	}
+
+	// converge cluster DNS provider, scope, and domain
+	if u := dnsConfigUpdate(instance.Spec, cluster); u != nil {
+		return r.updateCluster(instance, client, u)
+	}
+
+	// converge vertical pod autoscaling and the autoscaling profile
+	if u := autoscalingUpdate(instance.Spec, cluster); u != nil {
+		return r.updateCluster(instance, client, u)
+	}
+
+	// converge application-layer secrets encryption
+	if u := databaseEncryptionUpdate(instance.Spec, cluster); u != nil {
+		return r.updateCluster(instance, client, u)
+	}
+
+	// remove the default node pool in favour of the spec's node pools
+	if instance.Spec.RemoveDefaultNodePool && hasNodePool(cluster, defaultNodePoolName) {
+		return r.deleteNodePool(instance, client, defaultNodePoolName)
+	}
+
+	// converge node pool surge and blue/green upgrade settings
+	if name, u, ok := nodePoolUpgradeSettingsUpdate(instance.Spec, cluster); ok {
+		return r.updateNodePool(instance, client, name, u)
+	}
+
+	// converge node pool image types and Linux and kubelet node configuration
+	if name, u, ok := nodePoolConfigUpdate(instance.Spec, cluster); ok {
+		return r.updateNodePool(instance, client, name, u)
+	}
+
+	// converge node pool total egress bandwidth tiers
+	if name, u, ok := nodePoolNetworkPerformanceUpdate(instance.Spec, cluster); ok {
+		return r.updateNodePool(instance, client, name, u)
+	}
+
+	// converge node pool boot disk types and sizes
+	if name, u, ok := nodePoolBootDiskUpdate(instance.Spec, cluster); ok {
+		return r.updateNodePool(instance, client, name, u)
+	}
+
+	// hibernate or wake node pools on schedule
+	hibernate, err := hibernating(instance.Spec.HibernationSchedule, time.Now())
+	if err != nil {
+		return r.fail(instance, err)
+	}
+	if step, ok := nodePoolHibernation(instance.Status.HibernatedNodePools, sleepingNodePools(instance), cluster, hibernate); ok {
+		return r.hibernateNodePool(instance, client, step)
+	}
+
+	counts, err := r.nodePoolCounts(instance, cluster)
+	if err != nil {
 		return r.fail(instance, err)
 	}
 
 	// update resource status
+	instance.Status.Hibernated = hibernate
+	if !hibernate {
+		instance.Status.HibernatedNodePools = nil
+		setSleepingNodePools(instance, nil)
+	}
 	instance.Status.Endpoint = cluster.Endpoint
 	instance.Status.State = gcpcomputev1alpha1.ClusterStateRunning
-	instance.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	instance.Status.CurrentNodeCount = cluster.CurrentNodeCount
+	instance.Status.NodePools = nodePoolStatuses(cluster, counts)
+	instance.Status.DatabaseEncryptionState = databaseEncryptionState(cluster)
+	synced := corev1alpha1.ReconcileSuccess()
+	if name, ok := nodePoolBootDiskShrink(instance.Spec, cluster); ok {
+		synced = bootDiskShrinkRefused(name)
+	}
+	instance.Status.SetConditions(corev1alpha1.Available(), synced)
+	if plan.IsDryRun(instance) || plan.IsPlanned(instance.Status.ConditionedStatus) {
+		instance.Status.SetConditions(plan.NothingPlanned())
+	}
 	resource.SetBindable(instance)
 
 	return reconcile.Result{RequeueAfter: requeueOnSucces},
 		errors.Wrapf(r.Update(ctx, instance), updateErrorMessageFormat, instance.GetName())
 }
 
+// updateCluster requests the supplied update to the supplied cluster. The
+// cluster is not running while the update is in progress, so we wait for it
+// before syncing the cluster again.
+func (r *Reconciler) updateCluster(instance *gcpcomputev1alpha1.GKECluster, client gke.Client, u *container.ClusterUpdate) (reconcile.Result, error) {
+	if plan.IsDryRun(instance) {
+		return r.plan(instance, plan.Describe("UpdateCluster", u))
+	}
+
+	span := startPhase(client, tracing.PhaseUpdate)
+	if err := tracing.End(span, client.UpdateCluster(instance.Spec.Zone, instance.Status.ClusterName, u)); err != nil {
+		return r.fail(instance, err)
+	}
+
+	instance.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return reconcile.Result{RequeueAfter: requeueOnWait},
+		errors.Wrapf(r.Update(ctx, instance), updateErrorMessageFormat, instance.GetName())
+}
+
+// deleteNodePool deletes the named node pool of the supplied cluster. The
+// cluster is not running while the node pool is deleted, so we wait for it
+// before syncing the cluster again.
+func (r *Reconciler) deleteNodePool(instance *gcpcomputev1alpha1.GKECluster, client gke.Client, name string) (reconcile.Result, error) {
+	if plan.IsDryRun(instance) {
+		return r.plan(instance, plan.Describe("DeleteNodePool", map[string]string{"cluster": instance.Status.ClusterName, "nodePool": name}))
+	}
+
+	span := startPhase(client, tracing.PhaseUpdate)
+	if err := tracing.End(span, client.DeleteNodePool(instance.Spec.Zone, instance.Status.ClusterName, name)); err != nil {
+		return r.fail(instance, err)
+	}
+
+	instance.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return reconcile.Result{RequeueAfter: requeueOnWait},
+		errors.Wrapf(r.Update(ctx, instance), updateErrorMessageFormat, instance.GetName())
+}
+
+// plan records a GKE API call that would have been made if the supplied
+// cluster were not in dry-run mode. The cluster is requeued so that drift
+// continues to be planned, as it is for clusters that are in sync.
+func (r *Reconciler) plan(instance *gcpcomputev1alpha1.GKECluster, description string) (reconcile.Result, error) {
+	instance.Status.SetConditions(plan.Planned(description), corev1alpha1.ReconcileSuccess())
+	r.recorder.Event(instance, corev1.EventTypeNormal, plan.EventReasonPlanned, description)
+	return reconcile.Result{RequeueAfter: requeueOnSucces}, errors.Wrapf(r.Update(ctx, instance), updateErrorMessageFormat, instance.GetName())
+}
+
+// wait requeues a cluster that is not yet running, marking it as stalled if it
+// has been in its current status for longer than its creation deadline. The
+// cluster is updated if it is newly stalled, if it newly entered its current
+// status, or if observations were reported.
+func (r *Reconciler) wait(instance *gcpcomputev1alpha1.GKECluster, cluster *container.Cluster, reported bool) (reconcile.Result, error) {
+	now := time.Now()
+	since, observed := deadline.Observe(instance, cluster.Status, now)
+	stalled := deadline.ExceededSince(instance, since, now) && !deadline.IsStalled(instance.Status.ConditionedStatus)
+	if !stalled && !observed && !reported {
+		return reconcile.Result{RequeueAfter: requeueOnWait}, nil
+	}
+
+	if stalled {
+		d := deadline.CreationDeadline(instance)
+		instance.Status.SetConditions(deadline.Stalled(d, cluster.StatusMessage))
+		r.recorder.Eventf(instance, corev1.EventTypeWarning, deadline.EventReasonStalled,
+			"cluster %s is %s after %s: %s", cluster.Name, cluster.Status, d, cluster.StatusMessage)
+	}
+
+	return reconcile.Result{RequeueAfter: requeueOnWait},
+		errors.Wrapf(r.Update(ctx, instance), updateErrorMessageFormat, instance.GetName())
+}
+
 // _delete check reclaim policy and if needed delete the gke cluster resource
 func (r *Reconciler) _delete(instance *gcpcomputev1alpha1.GKECluster, client gke.Client) (reconcile.Result, error) {
 	instance.Status.SetConditions(corev1alpha1.Deleting())
 	if instance.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
-		if err := client.DeleteCluster(instance.Spec.Zone, instance.Status.ClusterName); err != nil {
-			return r.fail(instance, err)
+		if plan.IsDryRun(instance) {
+			r.recorder.Event(instance, corev1.EventTypeNormal, plan.EventReasonPlanned,
+				plan.Describe("DeleteCluster", map[string]string{"zone": instance.Spec.Zone, "name": instance.Status.ClusterName}))
+		} else {
+			if err := r.unregister(instance); err != nil {
+				return r.fail(instance, err)
+			}
+			span := startPhase(client, tracing.PhaseDelete)
+			if err := tracing.End(span, client.DeleteCluster(instance.Spec.Zone, instance.Status.ClusterName)); err != nil {
+				return r.fail(instance, err)
+			}
 		}
 	}
 	meta.RemoveFinalizer(instance, finalizer)
@@ -223,6 +578,12 @@ func (r *Reconciler) _delete(instance *gcpcomputev1alpha1.GKECluster, client gke
 // and what is in the Provider.Spec
 func (r *Reconciler) Reconcile(request reconcile.Request) (reconcile.Result, error) {
 	log.V(logging.Debug).Info("reconciling", "kind", gcpcomputev1alpha1.GKEClusterKindAPIVersion, "request", request)
+	rctx, span := tracing.StartReconcile(ctx, gcpcomputev1alpha1.GKEClusterGroupVersionKind.Kind, request.NamespacedName)
+	rs, err := r.reconcile(rctx, request)
+	return rs, tracing.End(span, err)
+}
+
+func (r *Reconciler) reconcile(rctx context.Context, request reconcile.Request) (reconcile.Result, error) {
 	// Fetch the Provider instance
 	instance := &gcpcomputev1alpha1.GKECluster{}
 	err := r.Get(ctx, request.NamespacedName, instance)
@@ -234,10 +595,12 @@ func (r *Reconciler) Reconcile(request reconcile.Request) (reconcile.Result, err
 	}
 
 	// Create GKE Client
+	_, span := tracing.StartPhase(rctx, tracing.PhaseConnect)
 	gkeClient, err := r.connect(instance)
-	if err != nil {
+	if err := tracing.End(span, err); err != nil {
 		return r.fail(instance, err)
 	}
+	gkeClient = &tracedClient{Client: gkeClient, ctx: rctx}
 
 	// Check for deletion
 	if instance.DeletionTimestamp != nil {
@@ -252,3 +615,16 @@ func (r *Reconciler) Reconcile(request reconcile.Request) (reconcile.Result, err
 	// Sync cluster instance status with cluster status
 	return r.sync(instance, gkeClient)
 }
+
+// listGKEClusters is a provider.Lister of GKE clusters.
+func listGKEClusters(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &gcpcomputev1alpha1.GKEClusterList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
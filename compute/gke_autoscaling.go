@@ -0,0 +1,104 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+)
+
+// Cluster autoscaling profiles. See
+// https://cloud.google.com/kubernetes-engine/docs/concepts/cluster-autoscaler#autoscaling_profiles
+const (
+	autoscalingProfileBalanced            = "BALANCED"
+	autoscalingProfileOptimizeUtilization = "OPTIMIZE_UTILIZATION"
+)
+
+// validateAutoscaling returns an error if the supplied spec requests an
+// unknown autoscaling profile, or autoscaling behaviour that Autopilot
+// clusters don't allow. Autopilot always runs vertical pod autoscaling and
+// optimizes utilization.
+func validateAutoscaling(spec gcpcomputev1alpha1.GKEClusterSpec) error {
+	switch spec.AutoscalingProfile {
+	case "", autoscalingProfileBalanced, autoscalingProfileOptimizeUtilization:
+	default:
+		return errors.Errorf("autoscaling profile %q must be %s or %s", spec.AutoscalingProfile, autoscalingProfileBalanced, autoscalingProfileOptimizeUtilization)
+	}
+
+	if !spec.Autopilot {
+		return nil
+	}
+	if spec.EnableVerticalPodAutoscaling != nil && !*spec.EnableVerticalPodAutoscaling {
+		return errors.New("vertical pod autoscaling cannot be disabled for Autopilot clusters")
+	}
+	if spec.AutoscalingProfile == autoscalingProfileBalanced {
+		return errors.Errorf("Autopilot clusters must use the %s autoscaling profile", autoscalingProfileOptimizeUtilization)
+	}
+	return nil
+}
+
+// autoscalingUpdate returns the cluster update required for the supplied
+// cluster to match the vertical pod autoscaling and autoscaling profile of the
+// supplied spec, or nil if no update is required. Neither is managed if the
+// spec doesn't configure it. GKE accepts only one desired change per update,
+// so vertical pod autoscaling is converged before the autoscaling profile.
+func autoscalingUpdate(spec gcpcomputev1alpha1.GKEClusterSpec, cluster *container.Cluster) *container.ClusterUpdate {
+	if spec.Autopilot {
+		return nil
+	}
+
+	if e := spec.EnableVerticalPodAutoscaling; e != nil && *e != verticalPodAutoscalingEnabled(cluster) {
+		return &container.ClusterUpdate{
+			DesiredVerticalPodAutoscaling: &container.VerticalPodAutoscaling{
+				Enabled: *e,
+				// Send false explicitly so that vertical pod autoscaling may
+				// be disabled.
+				ForceSendFields: []string{"Enabled"},
+			},
+		}
+	}
+
+	if p := spec.AutoscalingProfile; p != "" && p != autoscalingProfile(cluster) {
+		// The desired cluster autoscaling replaces the cluster's, so we
+		// preserve its node auto-provisioning settings.
+		desired := &container.ClusterAutoscaling{}
+		if cluster.Autoscaling != nil {
+			a := *cluster.Autoscaling
+			desired = &a
+		}
+		desired.AutoscalingProfile = p
+		return &container.ClusterUpdate{DesiredClusterAutoscaling: desired}
+	}
+
+	return nil
+}
+
+func verticalPodAutoscalingEnabled(cluster *container.Cluster) bool {
+	return cluster.VerticalPodAutoscaling != nil && cluster.VerticalPodAutoscaling.Enabled
+}
+
+// autoscalingProfile returns the autoscaling profile of the supplied cluster.
+// GKE reports an unspecified profile for clusters that use the default,
+// balanced, profile.
+func autoscalingProfile(cluster *container.Cluster) string {
+	if cluster.Autoscaling == nil || cluster.Autoscaling.AutoscalingProfile == "" || cluster.Autoscaling.AutoscalingProfile == "PROFILE_UNSPECIFIED" {
+		return autoscalingProfileBalanced
+	}
+	return cluster.Autoscaling.AutoscalingProfile
+}
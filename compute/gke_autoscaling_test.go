@@ -0,0 +1,139 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestValidateAutoscaling(t *testing.T) {
+	disabled := false
+
+	cases := map[string]struct {
+		spec gcpcomputev1alpha1.GKEClusterSpec
+		want error
+	}{
+		"Unmanaged": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{},
+		},
+		"OptimizeUtilization": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{AutoscalingProfile: autoscalingProfileOptimizeUtilization},
+		},
+		"UnknownProfile": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{AutoscalingProfile: "CHEAP"},
+			want: errors.New(`autoscaling profile "CHEAP" must be BALANCED or OPTIMIZE_UTILIZATION`),
+		},
+		"AutopilotWithoutVPA": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{Autopilot: true, EnableVerticalPodAutoscaling: &disabled},
+			want: errors.New("vertical pod autoscaling cannot be disabled for Autopilot clusters"),
+		},
+		"AutopilotBalanced": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{Autopilot: true, AutoscalingProfile: autoscalingProfileBalanced},
+			want: errors.New("Autopilot clusters must use the OPTIMIZE_UTILIZATION autoscaling profile"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := validateAutoscaling(tc.spec)
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("validateAutoscaling(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAutoscalingUpdate(t *testing.T) {
+	enabled, disabled := true, false
+	limits := []*container.ResourceLimit{{ResourceType: "cpu", Maximum: 64}}
+
+	cases := map[string]struct {
+		spec    gcpcomputev1alpha1.GKEClusterSpec
+		cluster *container.Cluster
+		want    *container.ClusterUpdate
+	}{
+		"Unmanaged": {
+			spec:    gcpcomputev1alpha1.GKEClusterSpec{},
+			cluster: &container.Cluster{VerticalPodAutoscaling: &container.VerticalPodAutoscaling{Enabled: true}},
+			want:    nil,
+		},
+		"EnableVPA": {
+			spec:    gcpcomputev1alpha1.GKEClusterSpec{EnableVerticalPodAutoscaling: &enabled},
+			cluster: &container.Cluster{},
+			want: &container.ClusterUpdate{
+				DesiredVerticalPodAutoscaling: &container.VerticalPodAutoscaling{Enabled: true, ForceSendFields: []string{"Enabled"}},
+			},
+		},
+		"DisableVPA": {
+			spec:    gcpcomputev1alpha1.GKEClusterSpec{EnableVerticalPodAutoscaling: &disabled},
+			cluster: &container.Cluster{VerticalPodAutoscaling: &container.VerticalPodAutoscaling{Enabled: true}},
+			want: &container.ClusterUpdate{
+				DesiredVerticalPodAutoscaling: &container.VerticalPodAutoscaling{Enabled: false, ForceSendFields: []string{"Enabled"}},
+			},
+		},
+		"OptimizeUtilization": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{AutoscalingProfile: autoscalingProfileOptimizeUtilization},
+			cluster: &container.Cluster{Autoscaling: &container.ClusterAutoscaling{
+				EnableNodeAutoprovisioning: true,
+				ResourceLimits:             limits,
+			}},
+			want: &container.ClusterUpdate{
+				DesiredClusterAutoscaling: &container.ClusterAutoscaling{
+					EnableNodeAutoprovisioning: true,
+					ResourceLimits:             limits,
+					AutoscalingProfile:         autoscalingProfileOptimizeUtilization,
+				},
+			},
+		},
+		"DefaultProfileIsBalanced": {
+			spec:    gcpcomputev1alpha1.GKEClusterSpec{AutoscalingProfile: autoscalingProfileBalanced},
+			cluster: &container.Cluster{Autoscaling: &container.ClusterAutoscaling{AutoscalingProfile: "PROFILE_UNSPECIFIED"}},
+			want:    nil,
+		},
+		"VPABeforeProfile": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{
+				EnableVerticalPodAutoscaling: &enabled,
+				AutoscalingProfile:           autoscalingProfileOptimizeUtilization,
+			},
+			cluster: &container.Cluster{},
+			want: &container.ClusterUpdate{
+				DesiredVerticalPodAutoscaling: &container.VerticalPodAutoscaling{Enabled: true, ForceSendFields: []string{"Enabled"}},
+			},
+		},
+		"Autopilot": {
+			spec:    gcpcomputev1alpha1.GKEClusterSpec{Autopilot: true, EnableVerticalPodAutoscaling: &enabled},
+			cluster: &container.Cluster{},
+			want:    nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := autoscalingUpdate(tc.spec, tc.cluster)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("autoscalingUpdate(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"bytes"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/kubeconfig"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/plan"
+)
+
+// ConnectCluster returns a client for the GKE cluster described by the
+// supplied connection secret.
+func ConnectCluster(secret *corev1.Secret) (client.Client, error) {
+	return kubeconfig.NewClient(secret)
+}
+
+// bootstrap applies the supplied cluster's bootstrap manifests to the GKE
+// cluster described by the supplied connection secret. Manifests are applied
+// in the order they are specified, so e.g. namespaces should precede the
+// objects they contain.
+func (r *Reconciler) bootstrap(instance *gcpcomputev1alpha1.GKECluster, secret *corev1.Secret) error {
+	objs, err := r.bootstrapManifests(instance)
+	if err != nil {
+		return err
+	}
+
+	kube, err := r.connectCluster(secret)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range objs {
+		if err := apply(kube, o); err != nil {
+			return errors.Wrapf(err, "cannot apply bootstrap manifest %s %s", o.GetKind(), nameOf(o))
+		}
+	}
+
+	instance.Status.BootstrapApplied = true
+	return nil
+}
+
+// planBootstrap records the bootstrap manifests that would have been applied
+// to the supplied cluster were it not in dry-run mode.
+func (r *Reconciler) planBootstrap(instance *gcpcomputev1alpha1.GKECluster) (reconcile.Result, error) {
+	objs, err := r.bootstrapManifests(instance)
+	if err != nil {
+		return r.fail(instance, err)
+	}
+	manifests := make([]string, 0, len(objs))
+	for _, o := range objs {
+		manifests = append(manifests, o.GetKind()+" "+nameOf(o))
+	}
+	return r.plan(instance, plan.Describe("ApplyBootstrapManifests", manifests))
+}
+
+// bootstrapManifests returns the objects described by the supplied cluster's
+// bootstrap ConfigMaps, followed by those described by its object templates.
+// Each ConfigMap key may contain one or more YAML documents; keys are read in
+// lexical order.
+func (r *Reconciler) bootstrapManifests(instance *gcpcomputev1alpha1.GKECluster) ([]*unstructured.Unstructured, error) {
+	objs := []*unstructured.Unstructured{}
+
+	for _, ref := range instance.Spec.Bootstrap.ConfigMapRefs {
+		cm := &corev1.ConfigMap{}
+		n := types.NamespacedName{Namespace: instance.GetNamespace(), Name: ref.Name}
+		if err := r.Get(ctx, n, cm); err != nil {
+			return nil, errors.Wrapf(err, "cannot get bootstrap configmap %s", n)
+		}
+
+		keys := make([]string, 0, len(cm.Data))
+		for k := range cm.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			o, err := decodeManifests([]byte(cm.Data[k]))
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot decode key %s of bootstrap configmap %s", k, n)
+			}
+			objs = append(objs, o...)
+		}
+	}
+
+	for i, t := range instance.Spec.Bootstrap.ObjectTemplates {
+		o, err := decodeManifests(t.Raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot decode bootstrap object template %d", i)
+		}
+		objs = append(objs, o...)
+	}
+
+	return objs, nil
+}
+
+// decodeManifests decodes the objects in the supplied YAML or JSON stream.
+// Empty documents are skipped.
+func decodeManifests(data []byte) ([]*unstructured.Unstructured, error) {
+	objs := []*unstructured.Unstructured{}
+	d := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		o := &unstructured.Unstructured{}
+		err := d.Decode(&o.Object)
+		if err == io.EOF {
+			return objs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(o.Object) == 0 {
+			continue
+		}
+		if o.GetKind() == "" || o.GetAPIVersion() == "" {
+			return nil, errors.Errorf("object %s must specify an apiVersion and kind", nameOf(o))
+		}
+		objs = append(objs, o)
+	}
+}
+
+// apply creates the supplied object, or updates it if it already exists.
+func apply(kube client.Client, o *unstructured.Unstructured) error {
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(o.GroupVersionKind())
+	err := kube.Get(ctx, types.NamespacedName{Namespace: o.GetNamespace(), Name: o.GetName()}, current)
+	if kerrors.IsNotFound(err) {
+		return kube.Create(ctx, o)
+	}
+	if err != nil {
+		return err
+	}
+	o.SetResourceVersion(current.GetResourceVersion())
+	return kube.Update(ctx, o)
+}
+
+func nameOf(o *unstructured.Unstructured) string {
+	if o.GetNamespace() == "" {
+		return o.GetName()
+	}
+	return o.GetNamespace() + "/" + o.GetName()
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/fake"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/plan"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const bootstrapConfigMap = "cool-bootstrap"
+
+func TestDecodeManifests(t *testing.T) {
+	ns := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   map[string]interface{}{"name": "cool"},
+	}}
+	sa := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ServiceAccount",
+		"metadata":   map[string]interface{}{"namespace": "cool", "name": "agent"},
+	}}
+
+	cases := map[string]struct {
+		data    string
+		want    []*unstructured.Unstructured
+		wantErr error
+	}{
+		"MultipleDocuments": {
+			data: `---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: cool
+---
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  namespace: cool
+  name: agent
+`,
+			want: []*unstructured.Unstructured{ns, sa},
+		},
+		"JSON": {
+			data: `{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "cool"}}`,
+			want: []*unstructured.Unstructured{ns},
+		},
+		"MissingKind": {
+			data: `
+apiVersion: v1
+metadata:
+  name: cool
+`,
+			wantErr: errors.New("object cool must specify an apiVersion and kind"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := decodeManifests([]byte(tc.data))
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("decodeManifests(...): -want error, +got error:\n%s", diff)
+			}
+			if tc.wantErr != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("decodeManifests(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSyncBootstrap(t *testing.T) {
+	instance := testCluster()
+	instance.Spec.Bootstrap = &gcpcomputev1alpha1.GKEClusterBootstrap{
+		ConfigMapRefs: []corev1.LocalObjectReference{{Name: bootstrapConfigMap}},
+		ObjectTemplates: []runtime.RawExtension{{
+			Raw: []byte(`{"apiVersion": "v1", "kind": "ServiceAccount", "metadata": {"namespace": "cool", "name": "agent"}}`),
+		}},
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: bootstrapConfigMap},
+		Data: map[string]string{"namespace.yaml": `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: cool
+`},
+	}
+
+	target := fakeclient.NewFakeClient()
+	r := &Reconciler{
+		Client:         fakeclient.NewFakeClient(instance, cm),
+		kubeclient:     fakekube.NewSimpleClientset(),
+		connectCluster: func(*corev1.Secret) (client.Client, error) { return target, nil },
+	}
+
+	cl := fake.NewGKEClient()
+	cl.MockGetCluster = func(string, string) (*container.Cluster, error) {
+		return &container.Cluster{
+			Status:     gcpcomputev1alpha1.ClusterStateRunning,
+			MasterAuth: masterAuth,
+		}, nil
+	}
+
+	if _, err := r._sync(instance, cl); err != nil {
+		t.Fatalf("r._sync(...): %s", err)
+	}
+
+	if !instance.Status.BootstrapApplied {
+		t.Errorf("instance.Status.BootstrapApplied: want true, got false")
+	}
+
+	if err := target.Get(ctx, types.NamespacedName{Name: "cool"}, &corev1.Namespace{}); err != nil {
+		t.Errorf("cannot get bootstrapped namespace: %s", err)
+	}
+	if err := target.Get(ctx, types.NamespacedName{Namespace: "cool", Name: "agent"}, &corev1.ServiceAccount{}); err != nil {
+		t.Errorf("cannot get bootstrapped service account: %s", err)
+	}
+}
+
+func TestSyncBootstrapDryRun(t *testing.T) {
+	instance := testCluster()
+	instance.SetAnnotations(map[string]string{plan.AnnotationManagementMode: plan.ModeDryRun})
+	instance.Spec.Bootstrap = &gcpcomputev1alpha1.GKEClusterBootstrap{
+		ObjectTemplates: []runtime.RawExtension{{
+			Raw: []byte(`{"apiVersion": "v1", "kind": "ServiceAccount", "metadata": {"namespace": "cool", "name": "agent"}}`),
+		}},
+	}
+
+	r := &Reconciler{
+		Client:     fakeclient.NewFakeClient(instance),
+		kubeclient: fakekube.NewSimpleClientset(),
+		recorder:   record.NewFakeRecorder(1),
+		connectCluster: func(*corev1.Secret) (client.Client, error) {
+			t.Errorf("connectCluster(...): unexpected call in dry-run mode")
+			return fakeclient.NewFakeClient(), nil
+		},
+	}
+
+	cl := fake.NewGKEClient()
+	cl.MockGetCluster = func(string, string) (*container.Cluster, error) {
+		return &container.Cluster{
+			Status:     gcpcomputev1alpha1.ClusterStateRunning,
+			MasterAuth: masterAuth,
+		}, nil
+	}
+
+	rs, err := r._sync(instance, cl)
+	if err != nil {
+		t.Fatalf("r._sync(...): %s", err)
+	}
+	if diff := cmp.Diff(reconcile.Result{RequeueAfter: requeueOnSucces}, rs); diff != "" {
+		t.Errorf("r._sync(...): -want, +got:\n%s", diff)
+	}
+	if instance.Status.BootstrapApplied {
+		t.Errorf("instance.Status.BootstrapApplied: want false in dry-run mode")
+	}
+	if !plan.IsPlanned(instance.Status.ConditionedStatus) {
+		t.Errorf("instance.Status: want Planned condition, got %+v", instance.Status.ConditionedStatus)
+	}
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/api/container/v1"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/gke"
+)
+
+// clusterCacheTTL is how long the clusters listed in a project are used to
+// answer GetCluster calls before they are listed again. It is short enough
+// that a cluster's status is rarely more than one requeue stale.
+const clusterCacheTTL = 10 * time.Second
+
+// allLocations lists the clusters in every location of a project.
+const allLocations = "-"
+
+// A clusterCache caches the GKE clusters in each project. It is shared by
+// every reconcile of the GKE cluster controller so that one clusters.list call
+// per project and TTL answers the GetCluster calls of all clusters in that
+// project, in any location, rather than each cluster making its own. Clusters
+// are cached separately for each set of credentials, so that a cluster is
+// never read using credentials that may not read it.
+type clusterCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu       sync.Mutex
+	projects map[string]*cachedProject
+}
+
+// A cachedProject is the clusters last listed in a project, keyed by location
+// and name. Its mutex is held while the clusters are listed, so that
+// concurrent reconciles of clusters in the same project wait for a single
+// list call.
+type cachedProject struct {
+	mu       sync.Mutex
+	listed   time.Time
+	clusters map[string]*container.Cluster
+}
+
+func newClusterCache(ttl time.Duration) *clusterCache {
+	return &clusterCache{ttl: ttl, now: time.Now, projects: map[string]*cachedProject{}}
+}
+
+func (c *clusterCache) project(project string) *cachedProject {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.projects[project]
+	if !ok {
+		p = &cachedProject{}
+		c.projects[project] = p
+	}
+	return p
+}
+
+// cacheKey returns the key under which the clusters read using credentials
+// from the supplied Provider are cached: their project and the Provider.
+// Credentials such as access tokens have no JSON to identify them by, but a
+// Provider's resource version changes whenever its credentials config does.
+func cacheKey(p *gcpv1alpha1.Provider, project string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", project, p.GetNamespace(), p.GetName(), p.GetResourceVersion())
+}
+
+func clusterKey(location, name string) string {
+	return location + "/" + name
+}
+
+// GetCluster returns the named cluster from the cache, listing the clusters in
+// every location of its project using the supplied client if they were not
+// listed within the cache's TTL. Clusters that were not listed - for example
+// because they were created since - are read directly. The returned cluster is
+// shared and must not be modified.
+func (c *clusterCache) GetCluster(client gke.Client, project, zone, name string) (*container.Cluster, error) {
+	p := c.project(project)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.clusters == nil || c.now().Sub(p.listed) > c.ttl {
+		clusters, err := client.ListClusters(allLocations)
+		if err != nil {
+			return nil, err
+		}
+		p.clusters = make(map[string]*container.Cluster, len(clusters))
+		for _, cl := range clusters {
+			p.clusters[clusterKey(cl.Location, cl.Name)] = cl
+		}
+		p.listed = c.now()
+	}
+
+	if cl, ok := p.clusters[clusterKey(zone, name)]; ok {
+		return cl, nil
+	}
+	return client.GetCluster(zone, name)
+}
+
+// Invalidate discards the clusters cached under the supplied key, so that
+// changes made to them are observed by the next call to GetCluster.
+func (c *clusterCache) Invalidate(project string) {
+	p := c.project(project)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clusters = nil
+}
+
+// A cachingClient is a gke.Client that reads clusters from a clusterCache, and
+// invalidates the cache when it changes a cluster or its node pools.
+type cachingClient struct {
+	gke.Client
+	cache *clusterCache
+	key   string
+}
+
+func (c *cachingClient) GetCluster(zone, name string) (*container.Cluster, error) {
+	return c.cache.GetCluster(c.Client, c.key, zone, name)
+}
+
+func (c *cachingClient) CreateCluster(name string, spec gcpcomputev1alpha1.GKEClusterSpec) (*container.Cluster, error) {
+	defer c.cache.Invalidate(c.key)
+	return c.Client.CreateCluster(name, spec)
+}
+
+func (c *cachingClient) UpdateCluster(zone, name string, u *container.ClusterUpdate) error {
+	defer c.cache.Invalidate(c.key)
+	return c.Client.UpdateCluster(zone, name, u)
+}
+
+func (c *cachingClient) DeleteCluster(zone, name string) error {
+	defer c.cache.Invalidate(c.key)
+	return c.Client.DeleteCluster(zone, name)
+}
+
+func (c *cachingClient) UpdateMasterVersion(zone, name, version string) error {
+	defer c.cache.Invalidate(c.key)
+	return c.Client.UpdateMasterVersion(zone, name, version)
+}
+
+func (c *cachingClient) SetLabels(zone, name string, labels map[string]string, fingerprint string) error {
+	defer c.cache.Invalidate(c.key)
+	return c.Client.SetLabels(zone, name, labels, fingerprint)
+}
+
+func (c *cachingClient) SetNetworkPolicy(zone, name string, p *container.NetworkPolicy) error {
+	defer c.cache.Invalidate(c.key)
+	return c.Client.SetNetworkPolicy(zone, name, p)
+}
+
+func (c *cachingClient) SetMaintenancePolicy(zone, name string, p *container.MaintenancePolicy) error {
+	defer c.cache.Invalidate(c.key)
+	return c.Client.SetMaintenancePolicy(zone, name, p)
+}
+
+func (c *cachingClient) CreateNodePool(zone, cluster string, np gcpcomputev1alpha1.NodePoolSpec) error {
+	defer c.cache.Invalidate(c.key)
+	return c.Client.CreateNodePool(zone, cluster, np)
+}
+
+func (c *cachingClient) DeleteNodePool(zone, cluster, name string) error {
+	defer c.cache.Invalidate(c.key)
+	return c.Client.DeleteNodePool(zone, cluster, name)
+}
+
+func (c *cachingClient) UpdateNodePool(zone, cluster, name string, u *container.UpdateNodePoolRequest) error {
+	defer c.cache.Invalidate(c.key)
+	return c.Client.UpdateNodePool(zone, cluster, name, u)
+}
+
+func (c *cachingClient) SetNodePoolSize(zone, cluster, name string, size int64) error {
+	defer c.cache.Invalidate(c.key)
+	return c.Client.SetNodePoolSize(zone, cluster, name, size)
+}
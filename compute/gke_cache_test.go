@@ -0,0 +1,254 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestClusterCacheGetCluster(t *testing.T) {
+	errBoom := errors.New("boom")
+	listed := []*container.Cluster{
+		{Name: "gke-a", Location: "us-central1", Status: "PROVISIONING"},
+		{Name: "gke-b", Location: "us-central1", Status: "RUNNING"},
+		{Name: "gke-b", Location: "europe-west1", Status: "STOPPING"},
+	}
+
+	type want struct {
+		cluster *container.Cluster
+		err     error
+		lists   int
+		gets    int
+	}
+
+	cases := map[string]struct {
+		list    func(string) ([]*container.Cluster, error)
+		prepare func(c *clusterCache, now *time.Time)
+		name    string
+		zone    string
+		want    want
+	}{
+		"Listed": {
+			list: func(string) ([]*container.Cluster, error) { return listed, nil },
+			name: "gke-b",
+			want: want{cluster: listed[1], lists: 1},
+		},
+		"OtherLocation": {
+			list: func(string) ([]*container.Cluster, error) { return listed, nil },
+			name: "gke-a",
+			zone: "europe-west1",
+			want: want{cluster: &container.Cluster{Name: "gke-a"}, lists: 1, gets: 1},
+		},
+		"NotListed": {
+			list: func(string) ([]*container.Cluster, error) { return listed, nil },
+			name: "gke-new",
+			want: want{cluster: &container.Cluster{Name: "gke-new"}, lists: 1, gets: 1},
+		},
+		"Cached": {
+			list: func(string) ([]*container.Cluster, error) { return listed, nil },
+			prepare: func(c *clusterCache, now *time.Time) {
+				c.project("cool-project").clusters = map[string]*container.Cluster{"us-central1/gke-b": {Name: "gke-b", Status: "RECONCILING"}}
+				c.project("cool-project").listed = *now
+			},
+			name: "gke-b",
+			want: want{cluster: &container.Cluster{Name: "gke-b", Status: "RECONCILING"}},
+		},
+		"Expired": {
+			list: func(string) ([]*container.Cluster, error) { return listed, nil },
+			prepare: func(c *clusterCache, now *time.Time) {
+				c.project("cool-project").clusters = map[string]*container.Cluster{"us-central1/gke-b": {Name: "gke-b", Status: "RECONCILING"}}
+				c.project("cool-project").listed = now.Add(-2 * clusterCacheTTL)
+			},
+			name: "gke-b",
+			want: want{cluster: listed[1], lists: 1},
+		},
+		"Invalidated": {
+			list: func(string) ([]*container.Cluster, error) { return listed, nil },
+			prepare: func(c *clusterCache, now *time.Time) {
+				c.project("cool-project").clusters = map[string]*container.Cluster{"us-central1/gke-b": {Name: "gke-b", Status: "RECONCILING"}}
+				c.project("cool-project").listed = *now
+				c.Invalidate("cool-project")
+			},
+			name: "gke-b",
+			want: want{cluster: listed[1], lists: 1},
+		},
+		"ListFailed": {
+			list: func(string) ([]*container.Cluster, error) { return nil, errBoom },
+			name: "gke-b",
+			want: want{err: errBoom, lists: 1},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			c := newClusterCache(clusterCacheTTL)
+			c.now = func() time.Time { return now }
+			if tc.prepare != nil {
+				tc.prepare(c, &now)
+			}
+
+			lists, gets := 0, 0
+			cl := fake.NewGKEClient()
+			cl.MockListClusters = func(zone string) ([]*container.Cluster, error) {
+				lists++
+				if zone != allLocations {
+					t.Errorf("ListClusters(...): want zone %q, got %q", allLocations, zone)
+				}
+				return tc.list(zone)
+			}
+			cl.MockGetCluster = func(_, name string) (*container.Cluster, error) {
+				gets++
+				return &container.Cluster{Name: name}, nil
+			}
+
+			zone := tc.zone
+			if zone == "" {
+				zone = "us-central1"
+			}
+			got, err := c.GetCluster(cl, "cool-project", zone, tc.name)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("c.GetCluster(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.cluster, got); diff != "" {
+				t.Errorf("c.GetCluster(...): -want, +got:\n%s", diff)
+			}
+			if lists != tc.want.lists || gets != tc.want.gets {
+				t.Errorf("c.GetCluster(...): want %d list and %d get calls, got %d and %d", tc.want.lists, tc.want.gets, lists, gets)
+			}
+		})
+	}
+}
+
+func TestClusterCacheConcurrentGetCluster(t *testing.T) {
+	c := newClusterCache(clusterCacheTTL)
+
+	var mu sync.Mutex
+	lists := 0
+	cl := fake.NewGKEClient()
+	cl.MockListClusters = func(string) ([]*container.Cluster, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		lists++
+		return []*container.Cluster{{Name: "gke-a", Location: "us-central1"}, {Name: "gke-b", Location: "us-east1"}}, nil
+	}
+
+	var wg sync.WaitGroup
+	for _, k := range [][2]string{{"us-central1", "gke-a"}, {"us-east1", "gke-b"}, {"us-central1", "gke-a"}, {"us-east1", "gke-b"}} {
+		wg.Add(1)
+		go func(zone, name string) {
+			defer wg.Done()
+			if _, err := c.GetCluster(cl, "cool-project", zone, name); err != nil {
+				t.Errorf("c.GetCluster(...): %s", err)
+			}
+		}(k[0], k[1])
+	}
+	wg.Wait()
+
+	if lists != 1 {
+		t.Errorf("c.GetCluster(...): want 1 list call, got %d", lists)
+	}
+}
+
+func TestCachingClient(t *testing.T) {
+	cl := fake.NewGKEClient()
+	cl.MockCreateCluster = func(string, gcpcomputev1alpha1.GKEClusterSpec) (*container.Cluster, error) {
+		return &container.Cluster{}, nil
+	}
+	cl.MockUpdateCluster = func(string, string, *container.ClusterUpdate) error { return nil }
+	cl.MockDeleteCluster = func(string, string) error { return nil }
+	cl.MockUpdateMasterVersion = func(_, _, _ string) error { return nil }
+	cl.MockSetLabels = func(_, _ string, _ map[string]string, _ string) error { return nil }
+	cl.MockDeleteNodePool = func(_, _, _ string) error { return nil }
+	cl.MockUpdateNodePool = func(_, _, _ string, _ *container.UpdateNodePoolRequest) error { return nil }
+	cl.MockSetNodePoolSize = func(_, _, _ string, _ int64) error { return nil }
+
+	cases := map[string]func(cc *cachingClient) error{
+		"CreateCluster": func(cc *cachingClient) error {
+			_, err := cc.CreateCluster("gke-a", gcpcomputev1alpha1.GKEClusterSpec{})
+			return err
+		},
+		"UpdateCluster": func(cc *cachingClient) error {
+			return cc.UpdateCluster("us-central1", "gke-a", &container.ClusterUpdate{})
+		},
+		"DeleteCluster": func(cc *cachingClient) error {
+			return cc.DeleteCluster("us-central1", "gke-a")
+		},
+		"UpdateMasterVersion": func(cc *cachingClient) error {
+			return cc.UpdateMasterVersion("us-central1", "gke-a", "1.14")
+		},
+		"SetLabels": func(cc *cachingClient) error {
+			return cc.SetLabels("us-central1", "gke-a", map[string]string{"cool": "true"}, "fingerprint")
+		},
+		"DeleteNodePool": func(cc *cachingClient) error {
+			return cc.DeleteNodePool("us-central1", "gke-a", "pool-a")
+		},
+		"UpdateNodePool": func(cc *cachingClient) error {
+			return cc.UpdateNodePool("us-central1", "gke-a", "pool-a", &container.UpdateNodePoolRequest{})
+		},
+		"SetNodePoolSize": func(cc *cachingClient) error {
+			return cc.SetNodePoolSize("us-central1", "gke-a", "pool-a", 0)
+		},
+	}
+
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := newClusterCache(clusterCacheTTL)
+			c.project("cool-project/a").clusters = map[string]*container.Cluster{}
+			c.project("cool-project/b").clusters = map[string]*container.Cluster{}
+			cc := &cachingClient{Client: cl, cache: c, key: "cool-project/a"}
+
+			if err := mutate(cc); err != nil {
+				t.Fatalf("cc.%s(...): %s", name, err)
+			}
+			if c.project("cool-project/a").clusters != nil {
+				t.Errorf("cc.%s(...): want cache invalidated", name)
+			}
+			if c.project("cool-project/b").clusters == nil {
+				t.Errorf("cc.%s(...): want cache of other credentials retained", name)
+			}
+		})
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	pa := &gcpv1alpha1.Provider{ObjectMeta: metav1.ObjectMeta{Namespace: "cool-namespace", Name: "a", ResourceVersion: "1"}}
+	pb := &gcpv1alpha1.Provider{ObjectMeta: metav1.ObjectMeta{Namespace: "cool-namespace", Name: "b", ResourceVersion: "1"}}
+	a := cacheKey(pa, "cool-project")
+	b := cacheKey(pb, "cool-project")
+	if a == b {
+		t.Errorf("cacheKey(...): want distinct keys for distinct providers in the same project, got %q", a)
+	}
+
+	updated := pa.DeepCopy()
+	updated.SetResourceVersion("2")
+	if cacheKey(updated, "cool-project") == a {
+		t.Errorf("cacheKey(...): want distinct keys for distinct resource versions of a provider, got %q", a)
+	}
+}
@@ -0,0 +1,215 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	gcpcomputev1beta1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1beta1"
+)
+
+// GKEClusterConversionPath is the path at which the GKECluster conversion
+// webhook is served.
+const GKEClusterConversionPath = "/convert/gkeclusters.compute.gcp.crossplane.io"
+
+// networkingFields are the v1alpha1 GKECluster spec fields that v1beta1 groups
+// under spec.networkingConfig.
+var networkingFields = []string{
+	"enableIPAlias",
+	"createSubnetwork",
+	"clusterIPV4CIDR",
+	"clusterSecondaryRangeName",
+	"nodeIPV4CIDR",
+	"serviceIPV4CIDR",
+	"servicesSecondaryRangeName",
+}
+
+// defaultNodePoolFields maps the v1alpha1 GKECluster spec fields that describe
+// the default node pool to their v1beta1 node pool equivalents.
+var defaultNodePoolFields = map[string]string{
+	"machineType": "machineType",
+	"numNodes":    "initialNodeCount",
+	"scopes":      "scopes",
+}
+
+// GKEClusterConversionWebhook converts GKEClusters between the v1alpha1 and
+// v1beta1 API versions. v1beta1 renames spec.zone to spec.location, groups the
+// IP alias fields under spec.networkingConfig, and describes the default node
+// pool as an entry in spec.nodePools. All other fields are identical, so
+// objects are converted as unstructured JSON in order that no field unknown to
+// the conversion is lost.
+type GKEClusterConversionWebhook struct{}
+
+// ServeHTTP handles a ConversionReview sent by the API server.
+func (w *GKEClusterConversionWebhook) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	review := &apiextensionsv1beta1.ConversionReview{}
+	if err := json.NewDecoder(req.Body).Decode(review); err != nil || review.Request == nil {
+		http.Error(rw, "cannot decode conversion review", http.StatusBadRequest)
+		return
+	}
+
+	review.Response = convertGKEClusters(review.Request)
+	review.Request = nil
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(review); err != nil {
+		log.Error(err, "cannot encode conversion review")
+	}
+}
+
+// convertGKEClusters converts the objects of the supplied ConversionRequest to
+// its desired API version.
+func convertGKEClusters(req *apiextensionsv1beta1.ConversionRequest) *apiextensionsv1beta1.ConversionResponse {
+	rsp := &apiextensionsv1beta1.ConversionResponse{UID: req.UID}
+
+	converted := make([]runtime.RawExtension, 0, len(req.Objects))
+	for _, o := range req.Objects {
+		c, err := convertGKECluster(o.Raw, req.DesiredAPIVersion)
+		if err != nil {
+			rsp.Result = metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}
+			return rsp
+		}
+		converted = append(converted, runtime.RawExtension{Raw: c})
+	}
+
+	rsp.ConvertedObjects = converted
+	rsp.Result = metav1.Status{Status: metav1.StatusSuccess}
+	return rsp
+}
+
+// convertGKECluster converts the supplied JSON encoded GKECluster to the
+// supplied API version.
+func convertGKECluster(raw []byte, desired string) ([]byte, error) {
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, errors.Wrap(err, "cannot decode GKECluster")
+	}
+
+	from, _ := obj["apiVersion"].(string)
+	if from == desired {
+		return raw, nil
+	}
+
+	spec, _ := obj["spec"].(map[string]interface{})
+	if spec == nil {
+		spec = map[string]interface{}{}
+	}
+
+	switch {
+	case from == gcpcomputev1alpha1.SchemeGroupVersion.String() && desired == gcpcomputev1beta1.SchemeGroupVersion.String():
+		toV1beta1(spec)
+	case from == gcpcomputev1beta1.SchemeGroupVersion.String() && desired == gcpcomputev1alpha1.SchemeGroupVersion.String():
+		toV1alpha1(spec)
+	default:
+		return nil, errors.Errorf("cannot convert GKECluster from %s to %s", from, desired)
+	}
+
+	obj["apiVersion"] = desired
+	obj["spec"] = spec
+	return json.Marshal(obj)
+}
+
+// toV1beta1 restructures the supplied v1alpha1 GKECluster spec in place.
+func toV1beta1(spec map[string]interface{}) {
+	moveField(spec, "zone", spec, "location")
+
+	nc := map[string]interface{}{}
+	for _, f := range networkingFields {
+		moveField(spec, f, nc, f)
+	}
+	if len(nc) > 0 {
+		spec["networkingConfig"] = nc
+	}
+
+	dp := map[string]interface{}{}
+	for from, to := range defaultNodePoolFields {
+		moveField(spec, from, dp, to)
+	}
+	if len(dp) == 0 {
+		return
+	}
+	dp["name"] = defaultNodePoolName
+	pools, _ := spec["nodePools"].([]interface{})
+	spec["nodePools"] = append([]interface{}{dp}, pools...)
+}
+
+// toV1alpha1 restructures the supplied v1beta1 GKECluster spec in place.
+func toV1alpha1(spec map[string]interface{}) {
+	moveField(spec, "location", spec, "zone")
+
+	if nc, ok := spec["networkingConfig"].(map[string]interface{}); ok {
+		for _, f := range networkingFields {
+			moveField(nc, f, spec, f)
+		}
+		delete(spec, "networkingConfig")
+	}
+
+	pools, _ := spec["nodePools"].([]interface{})
+	if len(pools) == 0 {
+		return
+	}
+	dp, ok := pools[0].(map[string]interface{})
+	if !ok || dp["name"] != defaultNodePoolName || !isDefaultNodePool(dp) {
+		return
+	}
+	for to, from := range defaultNodePoolFields {
+		moveField(dp, from, spec, to)
+	}
+	if len(pools) == 1 {
+		delete(spec, "nodePools")
+		return
+	}
+	spec["nodePools"] = pools[1:]
+}
+
+// isDefaultNodePool returns true if the supplied v1beta1 node pool sets only
+// fields that v1alpha1 can represent as its default node pool. Any other node
+// pool is converted as an ordinary node pool so that no fields are lost.
+func isDefaultNodePool(np map[string]interface{}) bool {
+	for k := range np {
+		if k == "name" {
+			continue
+		}
+		known := false
+		for _, f := range defaultNodePoolFields {
+			if k == f {
+				known = true
+			}
+		}
+		if !known {
+			return false
+		}
+	}
+	return true
+}
+
+// moveField moves the supplied key from one map to another, if it is set.
+func moveField(from map[string]interface{}, fromKey string, to map[string]interface{}, toKey string) {
+	v, ok := from[fromKey]
+	if !ok {
+		return
+	}
+	delete(from, fromKey)
+	to[toKey] = v
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	gcpcomputev1beta1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1beta1"
+)
+
+var (
+	alphaVersion = gcpcomputev1alpha1.SchemeGroupVersion.String()
+	betaVersion  = gcpcomputev1beta1.SchemeGroupVersion.String()
+)
+
+func alphaCluster() map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": alphaVersion,
+		"kind":       "GKECluster",
+		"metadata":   map[string]interface{}{"name": "cool-cluster"},
+		"spec": map[string]interface{}{
+			"zone":                 "us-central1-a",
+			"enableIPAlias":        true,
+			"clusterIPV4CIDR":      "10.0.0.0/14",
+			"machineType":          "n1-standard-1",
+			"numNodes":             float64(3),
+			"enableCostAllocation": true,
+			"nodePools":            []interface{}{map[string]interface{}{"name": "untrusted", "imageType": "COS_CONTAINERD"}},
+		},
+	}
+}
+
+func betaCluster() map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": betaVersion,
+		"kind":       "GKECluster",
+		"metadata":   map[string]interface{}{"name": "cool-cluster"},
+		"spec": map[string]interface{}{
+			"location": "us-central1-a",
+			"networkingConfig": map[string]interface{}{
+				"enableIPAlias":   true,
+				"clusterIPV4CIDR": "10.0.0.0/14",
+			},
+			"enableCostAllocation": true,
+			"nodePools": []interface{}{
+				map[string]interface{}{"name": defaultNodePoolName, "machineType": "n1-standard-1", "initialNodeCount": float64(3)},
+				map[string]interface{}{"name": "untrusted", "imageType": "COS_CONTAINERD"},
+			},
+		},
+	}
+}
+
+func mustMarshal(t *testing.T, o interface{}) []byte {
+	t.Helper()
+	b, err := json.Marshal(o)
+	if err != nil {
+		t.Fatalf("json.Marshal(...): %s", err)
+	}
+	return b
+}
+
+func TestConvertGKECluster(t *testing.T) {
+	customPool := betaCluster()
+	customPool["spec"].(map[string]interface{})["nodePools"] = []interface{}{
+		map[string]interface{}{"name": defaultNodePoolName, "machineType": "n1-standard-1", "imageType": "UBUNTU"},
+	}
+	customPoolAlpha := betaCluster()
+	customPoolAlpha["apiVersion"] = alphaVersion
+	customPoolAlpha["spec"] = map[string]interface{}{
+		"zone":                 "us-central1-a",
+		"enableIPAlias":        true,
+		"clusterIPV4CIDR":      "10.0.0.0/14",
+		"enableCostAllocation": true,
+		"nodePools": []interface{}{
+			map[string]interface{}{"name": defaultNodePoolName, "machineType": "n1-standard-1", "imageType": "UBUNTU"},
+		},
+	}
+
+	cases := map[string]struct {
+		obj     map[string]interface{}
+		desired string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		"AlphaToBeta": {
+			obj:     alphaCluster(),
+			desired: betaVersion,
+			want:    betaCluster(),
+		},
+		"BetaToAlpha": {
+			obj:     betaCluster(),
+			desired: alphaVersion,
+			want:    alphaCluster(),
+		},
+		"BetaToAlphaNonDefaultFirstPool": {
+			obj:     customPool,
+			desired: alphaVersion,
+			want:    customPoolAlpha,
+		},
+		"SameVersion": {
+			obj:     alphaCluster(),
+			desired: alphaVersion,
+			want:    alphaCluster(),
+		},
+		"UnknownVersion": {
+			obj:     alphaCluster(),
+			desired: "compute.gcp.crossplane.io/v2",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			raw, err := convertGKECluster(mustMarshal(t, tc.obj), tc.desired)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("convertGKECluster(...): want error %t, got %v", tc.wantErr, err)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			got := map[string]interface{}{}
+			if err := json.Unmarshal(raw, &got); err != nil {
+				t.Fatalf("json.Unmarshal(...): %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("convertGKECluster(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGKEClusterConversionWebhook(t *testing.T) {
+	review := &apiextensionsv1beta1.ConversionReview{
+		Request: &apiextensionsv1beta1.ConversionRequest{
+			UID:               types.UID("cool-uid"),
+			DesiredAPIVersion: betaVersion,
+			Objects:           []runtime.RawExtension{{Raw: mustMarshal(t, alphaCluster())}},
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	(&GKEClusterConversionWebhook{}).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, GKEClusterConversionPath, bytes.NewReader(mustMarshal(t, review))))
+
+	got := &apiextensionsv1beta1.ConversionReview{}
+	if err := json.Unmarshal(rec.Body.Bytes(), got); err != nil {
+		t.Fatalf("json.Unmarshal(...): %s", err)
+	}
+	if got.Response == nil {
+		t.Fatal("ConversionReview.Response: want response, got nil")
+	}
+	if got.Response.UID != review.Request.UID || got.Response.Result.Status != metav1.StatusSuccess {
+		t.Errorf("ConversionReview.Response: want successful response for %s, got %+v", review.Request.UID, got.Response)
+	}
+	if len(got.Response.ConvertedObjects) != 1 {
+		t.Fatalf("ConversionReview.Response.ConvertedObjects: want 1 object, got %d", len(got.Response.ConvertedObjects))
+	}
+
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(got.Response.ConvertedObjects[0].Raw, &obj); err != nil {
+		t.Fatalf("json.Unmarshal(...): %s", err)
+	}
+	if diff := cmp.Diff(betaCluster(), obj); diff != "" {
+		t.Errorf("converted object: -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+// cpuPlatformAutomatic lets GCP choose the CPU platform of a node pool's
+// nodes. It is available in every zone.
+const cpuPlatformAutomatic = "Automatic"
+
+// fixedPlatformMachineFamilies are the machine families whose CPU platform is
+// fixed or chosen by GCP, and thus do not support a minimum CPU platform.
+var fixedPlatformMachineFamilies = []string{"e2", "t2d", "t2a", "a2", "a3", "c3", "c3d", "g2", "m3", "h3", "f1", "g1"}
+
+// validateMinCPUPlatform returns an error if the supplied node pool requests a
+// minimum CPU platform that its machine family does not support.
+func validateMinCPUPlatform(np gcpcomputev1alpha1.NodePoolSpec) error {
+	if np.MinCPUPlatform == "" || np.MinCPUPlatform == cpuPlatformAutomatic {
+		return nil
+	}
+
+	// GKE defaults node pools to the e2-medium machine type, which does not
+	// support a minimum CPU platform.
+	if np.MachineType == "" {
+		return errors.New("minCpuPlatform requires a machineType")
+	}
+	if containsString(fixedPlatformMachineFamilies, machineFamily(np.MachineType)) {
+		return errors.Errorf("machine type %q does not support a minimum CPU platform", np.MachineType)
+	}
+	return nil
+}
+
+// A machineTypeLookup looks up the machine types and CPU platforms offered by
+// GCP zones.
+type machineTypeLookup interface {
+	MachineType(ctx context.Context, zone, name string) (*compute.MachineType, error)
+	CPUPlatforms(ctx context.Context, zone string) ([]string, error)
+}
+
+// computeMachineTypes is a machineTypeLookup using the GCP Compute API.
+type computeMachineTypes struct {
+	service *compute.Service
+	project string
+}
+
+// MachineType returns the named machine type of the supplied zone.
+func (m *computeMachineTypes) MachineType(ctx context.Context, zone, name string) (*compute.MachineType, error) {
+	return m.service.MachineTypes.Get(m.project, zone, name).Context(ctx).Do()
+}
+
+// CPUPlatforms returns the CPU platforms available in the supplied zone.
+func (m *computeMachineTypes) CPUPlatforms(ctx context.Context, zone string) ([]string, error) {
+	z, err := m.service.Zones.Get(m.project, zone).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	return z.AvailableCpuPlatforms, nil
+}
+
+// _machineTypes returns a machineTypeLookup authenticated using the
+// credentials of the Provider referenced by the supplied cluster.
+func (r *Reconciler) _machineTypes(instance *gcpcomputev1alpha1.GKECluster) (machineTypeLookup, error) {
+	s, project, err := r.computeService(instance, compute.ComputeReadonlyScope)
+	if err != nil {
+		return nil, err
+	}
+	return &computeMachineTypes{service: s, project: project}, nil
+}
+
+// computeService returns a GCP Compute API client with the supplied scope and
+// the project ID, using the credentials of the Provider referenced by the
+// supplied cluster.
+func (r *Reconciler) computeService(instance *gcpcomputev1alpha1.GKECluster, scope string) (*compute.Service, string, error) {
+	p, err := r.providers.Get(ctx, r, instance, instance.Spec.ProviderReference)
+	if err != nil {
+		return nil, "", err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, r, p, provider.ServiceCompute, scope)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s, err := compute.NewService(ctx, option.WithCredentials(creds))
+	if err != nil {
+		return nil, "", errors.Wrap(err, "cannot create new compute client")
+	}
+	return s, p.Spec.ProjectID, nil
+}
+
+// A zoneAvailabilityError indicates that a node pool requests a machine type
+// or CPU platform that its zone does not offer.
+type zoneAvailabilityError struct{ error }
+
+// isZoneAvailabilityError returns true if the supplied error indicates that a
+// node pool cannot be created in its zone.
+func isZoneAvailabilityError(err error) bool {
+	_, ok := errors.Cause(err).(zoneAvailabilityError)
+	return ok
+}
+
+// validateZoneAvailability returns an error if a node pool of the supplied
+// cluster spec requests a machine type or minimum CPU platform that the
+// cluster's zone does not offer. The error satisfies isZoneAvailabilityError
+// unless the machine types could not be looked up. The nodes of regional
+// clusters may be placed in any of their region's zones, so they are not
+// checked.
+func validateZoneAvailability(lookup machineTypeLookup, spec gcpcomputev1alpha1.GKEClusterSpec) error {
+	if isRegion(spec.Zone) {
+		return nil
+	}
+
+	var platforms []string
+	for _, np := range spec.NodePools {
+		if np.MinCPUPlatform == "" || np.MinCPUPlatform == cpuPlatformAutomatic {
+			continue
+		}
+
+		_, err := lookup.MachineType(ctx, spec.Zone, np.MachineType)
+		if googleapi.IsErrorNotFound(err) {
+			return zoneAvailabilityError{errors.Errorf("node pool %q: machine type %q is not available in zone %q", np.Name, np.MachineType, spec.Zone)}
+		}
+		if err != nil {
+			return errors.Wrapf(err, "cannot get machine type %q in zone %q", np.MachineType, spec.Zone)
+		}
+
+		if platforms == nil {
+			if platforms, err = lookup.CPUPlatforms(ctx, spec.Zone); err != nil {
+				return errors.Wrapf(err, "cannot get CPU platforms of zone %q", spec.Zone)
+			}
+		}
+		if !containsString(platforms, np.MinCPUPlatform) {
+			return zoneAvailabilityError{errors.Errorf("node pool %q: CPU platform %q is not available in zone %q; use one of %s",
+				np.Name, np.MinCPUPlatform, spec.Zone, strings.Join(platforms, ", "))}
+		}
+	}
+	return nil
+}
+
+// checkZoneAvailability returns an error if the supplied cluster's node pools
+// cannot be created in its zone. It checks nothing if the reconciler cannot
+// look up machine types.
+func (r *Reconciler) checkZoneAvailability(instance *gcpcomputev1alpha1.GKECluster) error {
+	if r.machineTypes == nil || !requestsMinCPUPlatform(instance.Spec) {
+		return nil
+	}
+	lookup, err := r.machineTypes(instance)
+	if err != nil {
+		return err
+	}
+	return validateZoneAvailability(lookup, instance.Spec)
+}
+
+// requestsMinCPUPlatform returns true if any node pool of the supplied spec
+// requests a minimum CPU platform.
+func requestsMinCPUPlatform(spec gcpcomputev1alpha1.GKEClusterSpec) bool {
+	for _, np := range spec.NodePools {
+		if np.MinCPUPlatform != "" && np.MinCPUPlatform != cpuPlatformAutomatic {
+			return true
+		}
+	}
+	return false
+}
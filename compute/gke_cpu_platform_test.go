@@ -0,0 +1,147 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+type mockMachineTypeLookup struct {
+	MockMachineType  func(ctx context.Context, zone, name string) (*compute.MachineType, error)
+	MockCPUPlatforms func(ctx context.Context, zone string) ([]string, error)
+}
+
+func (m *mockMachineTypeLookup) MachineType(ctx context.Context, zone, name string) (*compute.MachineType, error) {
+	return m.MockMachineType(ctx, zone, name)
+}
+
+func (m *mockMachineTypeLookup) CPUPlatforms(ctx context.Context, zone string) ([]string, error) {
+	return m.MockCPUPlatforms(ctx, zone)
+}
+
+func TestValidateMinCPUPlatform(t *testing.T) {
+	cases := map[string]struct {
+		np   gcpcomputev1alpha1.NodePoolSpec
+		want error
+	}{
+		"Unset": {},
+		"Automatic": {
+			np: gcpcomputev1alpha1.NodePoolSpec{MinCPUPlatform: cpuPlatformAutomatic},
+		},
+		"Supported": {
+			np: gcpcomputev1alpha1.NodePoolSpec{MachineType: "n2-standard-8", MinCPUPlatform: "Intel Ice Lake"},
+		},
+		"NoMachineType": {
+			np:   gcpcomputev1alpha1.NodePoolSpec{MinCPUPlatform: "Intel Ice Lake"},
+			want: errors.New("minCpuPlatform requires a machineType"),
+		},
+		"FixedPlatformFamily": {
+			np:   gcpcomputev1alpha1.NodePoolSpec{MachineType: "e2-standard-8", MinCPUPlatform: "Intel Ice Lake"},
+			want: errors.New(`machine type "e2-standard-8" does not support a minimum CPU platform`),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := validateMinCPUPlatform(tc.np)
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("validateMinCPUPlatform(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateZoneAvailability(t *testing.T) {
+	errBoom := errors.New("boom")
+	zone := "us-central1-a"
+	pool := gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool", MachineType: "n2-standard-8", MinCPUPlatform: "Intel Ice Lake"}
+
+	available := func(_ context.Context, _, _ string) (*compute.MachineType, error) { return &compute.MachineType{}, nil }
+	platforms := func(_ context.Context, _ string) ([]string, error) {
+		return []string{"Intel Cascade Lake", "Intel Ice Lake"}, nil
+	}
+
+	cases := map[string]struct {
+		lookup          *mockMachineTypeLookup
+		spec            gcpcomputev1alpha1.GKEClusterSpec
+		want            error
+		wantUnavailable bool
+	}{
+		"NoMinCPUPlatform": {
+			lookup: &mockMachineTypeLookup{},
+			spec:   gcpcomputev1alpha1.GKEClusterSpec{Zone: zone, NodePools: []gcpcomputev1alpha1.NodePoolSpec{{Name: "cool-pool"}}},
+		},
+		"Regional": {
+			lookup: &mockMachineTypeLookup{},
+			spec:   gcpcomputev1alpha1.GKEClusterSpec{Zone: "us-central1", NodePools: []gcpcomputev1alpha1.NodePoolSpec{pool}},
+		},
+		"Available": {
+			lookup: &mockMachineTypeLookup{MockMachineType: available, MockCPUPlatforms: platforms},
+			spec:   gcpcomputev1alpha1.GKEClusterSpec{Zone: zone, NodePools: []gcpcomputev1alpha1.NodePoolSpec{pool}},
+		},
+		"MachineTypeUnavailable": {
+			lookup: &mockMachineTypeLookup{
+				MockMachineType: func(_ context.Context, _, _ string) (*compute.MachineType, error) {
+					return nil, &googleapi.Error{Code: http.StatusNotFound}
+				},
+			},
+			spec:            gcpcomputev1alpha1.GKEClusterSpec{Zone: zone, NodePools: []gcpcomputev1alpha1.NodePoolSpec{pool}},
+			want:            errors.New(`node pool "cool-pool": machine type "n2-standard-8" is not available in zone "us-central1-a"`),
+			wantUnavailable: true,
+		},
+		"CPUPlatformUnavailable": {
+			lookup: &mockMachineTypeLookup{
+				MockMachineType: available,
+				MockCPUPlatforms: func(_ context.Context, _ string) ([]string, error) {
+					return []string{"Intel Cascade Lake"}, nil
+				},
+			},
+			spec:            gcpcomputev1alpha1.GKEClusterSpec{Zone: zone, NodePools: []gcpcomputev1alpha1.NodePoolSpec{pool}},
+			want:            errors.New(`node pool "cool-pool": CPU platform "Intel Ice Lake" is not available in zone "us-central1-a"; use one of Intel Cascade Lake`),
+			wantUnavailable: true,
+		},
+		"LookupFailed": {
+			lookup: &mockMachineTypeLookup{
+				MockMachineType: func(_ context.Context, _, _ string) (*compute.MachineType, error) { return nil, errBoom },
+			},
+			spec: gcpcomputev1alpha1.GKEClusterSpec{Zone: zone, NodePools: []gcpcomputev1alpha1.NodePoolSpec{pool}},
+			want: errors.Wrapf(errBoom, "cannot get machine type %q in zone %q", "n2-standard-8", zone),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := validateZoneAvailability(tc.lookup, tc.spec)
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("validateZoneAvailability(...): -want, +got:\n%s", diff)
+			}
+			if gotUnavailable := isZoneAvailabilityError(got); gotUnavailable != tc.wantUnavailable {
+				t.Errorf("isZoneAvailabilityError(...): want %t, got %t", tc.wantUnavailable, gotUnavailable)
+			}
+		})
+	}
+}
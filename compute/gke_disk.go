@@ -0,0 +1,116 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+)
+
+// minBootDiskSizeGB is the smallest boot disk GKE will create for a node.
+const minBootDiskSizeGB = 10
+
+// bootDiskTypes are the disk types GKE nodes may boot from.
+var bootDiskTypes = []string{"pd-standard", "pd-balanced", "pd-ssd", "hyperdisk-balanced"}
+
+// validateBootDisk returns an error if the supplied node pool's boot disk is
+// of an unknown type or is too small to boot from.
+func validateBootDisk(np gcpcomputev1alpha1.NodePoolSpec) error {
+	if np.DiskType != "" && !containsString(bootDiskTypes, np.DiskType) {
+		return errors.Errorf("boot disk type %q is not supported; use one of %s", np.DiskType, strings.Join(bootDiskTypes, ", "))
+	}
+	if np.DiskSizeGB != 0 && np.DiskSizeGB < minBootDiskSizeGB {
+		return errors.Errorf("boot disk size %dGB must be at least %dGB", np.DiskSizeGB, minBootDiskSizeGB)
+	}
+	return nil
+}
+
+// nodePoolBootDiskUpdate returns the name of the next node pool of the
+// supplied cluster whose boot disk type differs from the supplied spec, or
+// whose boot disk is smaller than the spec requests, and the request that
+// updates it. It returns false if every node pool is up to date. GKE applies
+// the update by recreating the node pool's nodes.
+func nodePoolBootDiskUpdate(spec gcpcomputev1alpha1.GKEClusterSpec, cluster *container.Cluster) (string, *container.UpdateNodePoolRequest, bool) {
+	for _, np := range spec.NodePools {
+		actual := nodePool(cluster, np.Name)
+		if actual == nil || actual.Config == nil {
+			continue
+		}
+		c := actual.Config
+
+		typeChanged := np.DiskType != "" && np.DiskType != c.DiskType
+		grown := np.DiskSizeGB > c.DiskSizeGb
+		if !typeChanged && !grown {
+			continue
+		}
+
+		// The node version and image type are required, so we send the
+		// node pool's current version and image type.
+		u := &container.UpdateNodePoolRequest{NodeVersion: actual.Version, ImageType: c.ImageType}
+		if typeChanged {
+			u.DiskType = np.DiskType
+		}
+		if grown {
+			u.DiskSizeGb = np.DiskSizeGB
+		}
+		return np.Name, u, true
+	}
+	return "", nil, false
+}
+
+// nodePoolBootDiskShrink returns the name of the next node pool of the
+// supplied cluster whose boot disk is larger than the supplied spec requests,
+// and false if there is no such node pool. Persistent disks cannot shrink, and
+// recreating the node pool would evict its workloads, so such node pools are
+// left as they are.
+func nodePoolBootDiskShrink(spec gcpcomputev1alpha1.GKEClusterSpec, cluster *container.Cluster) (string, bool) {
+	for _, np := range spec.NodePools {
+		actual := nodePool(cluster, np.Name)
+		if actual == nil || actual.Config == nil {
+			continue
+		}
+		if np.DiskSizeGB != 0 && np.DiskSizeGB < actual.Config.DiskSizeGb {
+			return np.Name, true
+		}
+	}
+	return "", false
+}
+
+// ReasonBootDiskShrinkRefused indicates that a GKECluster's spec requests a
+// smaller boot disk for one of its node pools than the node pool has.
+const ReasonBootDiskShrinkRefused corev1alpha1.ConditionReason = "BootDiskShrinkRefused"
+
+// bootDiskShrinkRefused returns a condition indicating that the boot disk of
+// the named node pool cannot shrink, and how to replace the node pool.
+func bootDiskShrinkRefused(name string) corev1alpha1.Condition {
+	return corev1alpha1.Condition{
+		Type:               corev1alpha1.TypeSynced,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonBootDiskShrinkRefused,
+		Message: fmt.Sprintf("cannot shrink the boot disk of node pool %s: persistent disks cannot shrink; "+
+			"add a node pool with a smaller boot disk and remove this one once its workloads have moved", name),
+	}
+}
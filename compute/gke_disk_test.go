@@ -0,0 +1,189 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	fakegcp "github.com/crossplaneio/crossplane/pkg/clients/gcp/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestValidateBootDisk(t *testing.T) {
+	cases := map[string]struct {
+		np   gcpcomputev1alpha1.NodePoolSpec
+		want error
+	}{
+		"Unset": {},
+		"SSD": {
+			np: gcpcomputev1alpha1.NodePoolSpec{DiskType: "pd-ssd", DiskSizeGB: 50},
+		},
+		"UnknownType": {
+			np:   gcpcomputev1alpha1.NodePoolSpec{DiskType: "local-ssd"},
+			want: errors.New(`boot disk type "local-ssd" is not supported; use one of pd-standard, pd-balanced, pd-ssd, hyperdisk-balanced`),
+		},
+		"TooSmall": {
+			np:   gcpcomputev1alpha1.NodePoolSpec{DiskSizeGB: 5},
+			want: errors.New("boot disk size 5GB must be at least 10GB"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := validateBootDisk(tc.np)
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("validateBootDisk(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func diskPool(diskType string, sizeGB int64) *container.NodePool {
+	return &container.NodePool{
+		Name:    "cool-pool",
+		Version: "1.14.7-gke.14",
+		Config:  &container.NodeConfig{ImageType: "COS_CONTAINERD", DiskType: diskType, DiskSizeGb: sizeGB},
+	}
+}
+
+func TestNodePoolBootDiskUpdate(t *testing.T) {
+	type want struct {
+		name   string
+		update *container.UpdateNodePoolRequest
+		ok     bool
+	}
+
+	cases := map[string]struct {
+		np      gcpcomputev1alpha1.NodePoolSpec
+		cluster *container.Cluster
+		want    want
+	}{
+		"NotSpecified": {
+			np:      gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool"},
+			cluster: &container.Cluster{NodePools: []*container.NodePool{diskPool("pd-standard", 100)}},
+		},
+		"NodePoolNotCreated": {
+			np:      gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool", DiskType: "pd-ssd"},
+			cluster: &container.Cluster{},
+		},
+		"UpToDate": {
+			np:      gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool", DiskType: "pd-ssd", DiskSizeGB: 50},
+			cluster: &container.Cluster{NodePools: []*container.NodePool{diskPool("pd-ssd", 50)}},
+		},
+		"Shrunk": {
+			np:      gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool", DiskSizeGB: 50},
+			cluster: &container.Cluster{NodePools: []*container.NodePool{diskPool("pd-standard", 100)}},
+		},
+		"TypeChanged": {
+			np:      gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool", DiskType: "pd-balanced"},
+			cluster: &container.Cluster{NodePools: []*container.NodePool{diskPool("pd-standard", 100)}},
+			want: want{
+				name:   "cool-pool",
+				update: &container.UpdateNodePoolRequest{NodeVersion: "1.14.7-gke.14", ImageType: "COS_CONTAINERD", DiskType: "pd-balanced"},
+				ok:     true,
+			},
+		},
+		"Grown": {
+			np:      gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool", DiskType: "pd-ssd", DiskSizeGB: 200},
+			cluster: &container.Cluster{NodePools: []*container.NodePool{diskPool("pd-ssd", 100)}},
+			want: want{
+				name:   "cool-pool",
+				update: &container.UpdateNodePoolRequest{NodeVersion: "1.14.7-gke.14", ImageType: "COS_CONTAINERD", DiskSizeGb: 200},
+				ok:     true,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			spec := gcpcomputev1alpha1.GKEClusterSpec{NodePools: []gcpcomputev1alpha1.NodePoolSpec{tc.np}}
+			np, u, ok := nodePoolBootDiskUpdate(spec, tc.cluster)
+			got := want{name: np, update: u, ok: ok}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("nodePoolBootDiskUpdate(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSyncBootDiskShrink(t *testing.T) {
+	np := gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool", DiskSizeGB: 50}
+
+	cases := map[string]struct {
+		pools      []*container.NodePool
+		wantReason corev1alpha1.ConditionReason
+	}{
+		"RefuseShrink": {
+			pools:      []*container.NodePool{diskPool("pd-standard", 100)},
+			wantReason: ReasonBootDiskShrinkRefused,
+		},
+		"UpToDate": {
+			pools:      []*container.NodePool{diskPool("pd-standard", 50)},
+			wantReason: corev1alpha1.ReconcileSuccess().Reason,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			instance := testCluster()
+			instance.Spec.NodePools = []gcpcomputev1alpha1.NodePoolSpec{np}
+			instance.Status.ClusterName = "gke-cool"
+
+			r := &Reconciler{
+				Client:     fakeclient.NewFakeClient(instance),
+				kubeclient: fake.NewSimpleClientset(),
+			}
+
+			cl := fakegcp.NewGKEClient()
+			cl.MockGetCluster = func(string, string) (*container.Cluster, error) {
+				return &container.Cluster{
+					Status:     gcpcomputev1alpha1.ClusterStateRunning,
+					MasterAuth: masterAuth,
+					NodePools:  tc.pools,
+				}, nil
+			}
+			cl.MockDeleteNodePool = func(_, _, nodePool string) error {
+				t.Errorf("DeleteNodePool(...): want no node pool deleted, got %s", nodePool)
+				return nil
+			}
+
+			rs, err := r._sync(instance, cl)
+			if err != nil {
+				t.Fatalf("r._sync(...): %s", err)
+			}
+			if diff := cmp.Diff(reconcile.Result{RequeueAfter: requeueOnSucces}, rs); diff != "" {
+				t.Errorf("r._sync(...): -want, +got:\n%s", diff)
+			}
+			if got := instance.Status.GetCondition(corev1alpha1.TypeSynced).Reason; got != tc.wantReason {
+				t.Errorf("r._sync(...): want synced reason %s, got %s", tc.wantReason, got)
+			}
+			if got := instance.Status.GetCondition(corev1alpha1.TypeReady).Status; got != corev1.ConditionTrue {
+				t.Errorf("r._sync(...): want ready status %s, got %s", corev1.ConditionTrue, got)
+			}
+		})
+	}
+}
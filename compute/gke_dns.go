@@ -0,0 +1,112 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+)
+
+// Cluster DNS providers and scopes supported by GKE.
+const (
+	clusterDNSPlatformDefault = "PLATFORM_DEFAULT"
+	clusterDNSCloudDNS        = "CLOUD_DNS"
+	clusterDNSKubeDNS         = "KUBE_DNS"
+
+	clusterDNSScopeCluster = "CLUSTER_SCOPE"
+	clusterDNSScopeVPC     = "VPC_SCOPE"
+)
+
+// dnsDomain matches a DNS domain, e.g. cluster.example.org, without a
+// trailing dot.
+var dnsDomain = regexp.MustCompile(`^([a-z0-9]([-a-z0-9]*[a-z0-9])?\.)*[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// validateDNSConfig returns an error if the supplied GKECluster spec
+// configures cluster DNS in a way GKE does not support. A DNS scope and domain
+// may only be used with Cloud DNS, and VPC scope requires a domain.
+func validateDNSConfig(spec gcpcomputev1alpha1.GKEClusterSpec) error {
+	dc := spec.DNSConfig
+	if dc == nil {
+		return nil
+	}
+
+	switch dc.ClusterDNS {
+	case clusterDNSPlatformDefault, clusterDNSCloudDNS, clusterDNSKubeDNS:
+	default:
+		return errors.Errorf("cluster DNS provider %q must be one of %s, %s, or %s", dc.ClusterDNS, clusterDNSPlatformDefault, clusterDNSCloudDNS, clusterDNSKubeDNS)
+	}
+
+	if dc.ClusterDNS != clusterDNSCloudDNS && (dc.ClusterDNSScope != "" || dc.ClusterDNSDomain != "") {
+		return errors.Errorf("cluster DNS scope and domain require cluster DNS provider %s", clusterDNSCloudDNS)
+	}
+
+	switch dc.ClusterDNSScope {
+	case "", clusterDNSScopeCluster:
+	case clusterDNSScopeVPC:
+		if dc.ClusterDNSDomain == "" {
+			return errors.Errorf("cluster DNS scope %s requires a cluster DNS domain", clusterDNSScopeVPC)
+		}
+	default:
+		return errors.Errorf("cluster DNS scope %q must be one of %s or %s", dc.ClusterDNSScope, clusterDNSScopeCluster, clusterDNSScopeVPC)
+	}
+
+	if dc.ClusterDNSDomain != "" && !dnsDomain.MatchString(dc.ClusterDNSDomain) {
+		return errors.Errorf("cluster DNS domain %q is not a valid DNS domain", dc.ClusterDNSDomain)
+	}
+
+	return nil
+}
+
+// desiredDNSConfig returns the GKE DNS config of the supplied spec. Cloud DNS
+// defaults to cluster scope.
+func desiredDNSConfig(dc *gcpcomputev1alpha1.DNSConfig) *container.DNSConfig {
+	d := &container.DNSConfig{
+		ClusterDns:       dc.ClusterDNS,
+		ClusterDnsScope:  dc.ClusterDNSScope,
+		ClusterDnsDomain: dc.ClusterDNSDomain,
+	}
+	if d.ClusterDns == clusterDNSCloudDNS && d.ClusterDnsScope == "" {
+		d.ClusterDnsScope = clusterDNSScopeCluster
+	}
+	return d
+}
+
+// dnsConfigUpdate returns the cluster update required for the supplied
+// cluster to use the DNS provider, scope, and domain of the supplied spec, or
+// nil if no update is required. Existing nodes keep using their current DNS
+// provider until they are recreated, e.g. by a node pool upgrade. Cluster DNS
+// is not managed if the spec doesn't configure it.
+func dnsConfigUpdate(spec gcpcomputev1alpha1.GKEClusterSpec, cluster *container.Cluster) *container.ClusterUpdate {
+	if spec.DNSConfig == nil {
+		return nil
+	}
+
+	desired := desiredDNSConfig(spec.DNSConfig)
+	actual := &container.DNSConfig{}
+	if cluster.NetworkConfig != nil && cluster.NetworkConfig.DnsConfig != nil {
+		actual = cluster.NetworkConfig.DnsConfig
+	}
+	if desired.ClusterDns == actual.ClusterDns && desired.ClusterDnsScope == actual.ClusterDnsScope && desired.ClusterDnsDomain == actual.ClusterDnsDomain {
+		return nil
+	}
+
+	return &container.ClusterUpdate{DesiredDnsConfig: desired}
+}
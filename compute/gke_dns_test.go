@@ -0,0 +1,124 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestValidateDNSConfig(t *testing.T) {
+	cases := map[string]struct {
+		dc   *gcpcomputev1alpha1.DNSConfig
+		want error
+	}{
+		"Unset": {},
+		"CloudDNSVPCScope": {
+			dc: &gcpcomputev1alpha1.DNSConfig{ClusterDNS: clusterDNSCloudDNS, ClusterDNSScope: clusterDNSScopeVPC, ClusterDNSDomain: "cluster.example.org"},
+		},
+		"KubeDNS": {
+			dc: &gcpcomputev1alpha1.DNSConfig{ClusterDNS: clusterDNSKubeDNS},
+		},
+		"UnknownProvider": {
+			dc:   &gcpcomputev1alpha1.DNSConfig{ClusterDNS: "COREDNS"},
+			want: errors.Errorf(`cluster DNS provider "COREDNS" must be one of %s, %s, or %s`, clusterDNSPlatformDefault, clusterDNSCloudDNS, clusterDNSKubeDNS),
+		},
+		"ScopeWithoutCloudDNS": {
+			dc:   &gcpcomputev1alpha1.DNSConfig{ClusterDNS: clusterDNSKubeDNS, ClusterDNSScope: clusterDNSScopeCluster},
+			want: errors.Errorf("cluster DNS scope and domain require cluster DNS provider %s", clusterDNSCloudDNS),
+		},
+		"VPCScopeWithoutDomain": {
+			dc:   &gcpcomputev1alpha1.DNSConfig{ClusterDNS: clusterDNSCloudDNS, ClusterDNSScope: clusterDNSScopeVPC},
+			want: errors.Errorf("cluster DNS scope %s requires a cluster DNS domain", clusterDNSScopeVPC),
+		},
+		"InvalidDomain": {
+			dc:   &gcpcomputev1alpha1.DNSConfig{ClusterDNS: clusterDNSCloudDNS, ClusterDNSScope: clusterDNSScopeVPC, ClusterDNSDomain: "-cluster.example.org."},
+			want: errors.New(`cluster DNS domain "-cluster.example.org." is not a valid DNS domain`),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := validateDNSConfig(gcpcomputev1alpha1.GKEClusterSpec{DNSConfig: tc.dc})
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("validateDNSConfig(...): -want error, +got error:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDNSConfigUpdate(t *testing.T) {
+	spec := gcpcomputev1alpha1.GKEClusterSpec{DNSConfig: &gcpcomputev1alpha1.DNSConfig{ClusterDNS: clusterDNSCloudDNS}}
+	cloudDNS := &container.ClusterUpdate{
+		DesiredDnsConfig: &container.DNSConfig{ClusterDns: clusterDNSCloudDNS, ClusterDnsScope: clusterDNSScopeCluster},
+	}
+
+	cases := map[string]struct {
+		spec    gcpcomputev1alpha1.GKEClusterSpec
+		cluster *container.Cluster
+		want    *container.ClusterUpdate
+	}{
+		"Unmanaged": {
+			spec:    gcpcomputev1alpha1.GKEClusterSpec{},
+			cluster: &container.Cluster{},
+			want:    nil,
+		},
+		"MigrateFromKubeDNS": {
+			spec:    spec,
+			cluster: &container.Cluster{NetworkConfig: &container.NetworkConfig{DnsConfig: &container.DNSConfig{ClusterDns: clusterDNSKubeDNS}}},
+			want:    cloudDNS,
+		},
+		"NoDNSConfig": {
+			spec:    spec,
+			cluster: &container.Cluster{NetworkConfig: &container.NetworkConfig{}},
+			want:    cloudDNS,
+		},
+		"ChangeScope": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{DNSConfig: &gcpcomputev1alpha1.DNSConfig{
+				ClusterDNS:       clusterDNSCloudDNS,
+				ClusterDNSScope:  clusterDNSScopeVPC,
+				ClusterDNSDomain: "cluster.example.org",
+			}},
+			cluster: &container.Cluster{NetworkConfig: &container.NetworkConfig{DnsConfig: &container.DNSConfig{ClusterDns: clusterDNSCloudDNS, ClusterDnsScope: clusterDNSScopeCluster}}},
+			want: &container.ClusterUpdate{DesiredDnsConfig: &container.DNSConfig{
+				ClusterDns:       clusterDNSCloudDNS,
+				ClusterDnsScope:  clusterDNSScopeVPC,
+				ClusterDnsDomain: "cluster.example.org",
+			}},
+		},
+		"UpToDate": {
+			spec:    spec,
+			cluster: &container.Cluster{NetworkConfig: &container.NetworkConfig{DnsConfig: &container.DNSConfig{ClusterDns: clusterDNSCloudDNS, ClusterDnsScope: clusterDNSScopeCluster}}},
+			want:    nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := dnsConfigUpdate(tc.spec, tc.cluster)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("dnsConfigUpdate(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+)
+
+const (
+	// databaseEncryptionEncrypted is the desired state of a cluster whose
+	// Kubernetes secrets are encrypted at the application layer.
+	databaseEncryptionEncrypted = "ENCRYPTED"
+
+	// GKE reports these current states while secrets are re-encrypted, or
+	// when re-encrypting them failed.
+	databaseEncryptionPending = "CURRENT_STATE_ENCRYPTION_PENDING"
+	databaseEncryptionError   = "CURRENT_STATE_ENCRYPTION_ERROR"
+)
+
+// validateDatabaseEncryption returns an error if the supplied GKECluster spec
+// asks for application-layer secrets encryption with a Cloud KMS key that GKE
+// cannot use. GKE requires the key to be in the cluster's region.
+func validateDatabaseEncryption(spec gcpcomputev1alpha1.GKEClusterSpec) error {
+	if spec.DatabaseEncryption == nil {
+		return nil
+	}
+
+	key := spec.DatabaseEncryption.KeyName
+	if !kmsKeyName.MatchString(key) {
+		return errors.Errorf("database encryption key %q must be of the form projects/*/locations/*/keyRings/*/cryptoKeys/*", key)
+	}
+
+	r, err := clusterRegion(spec.Zone)
+	if err != nil {
+		return err
+	}
+	if l := kmsKeyLocation(key); l != r {
+		return errors.Errorf("database encryption key %q is in location %q; it must be in the cluster's region %q", key, l, r)
+	}
+
+	return nil
+}
+
+// kmsKeyLocation returns the location of the supplied fully qualified Cloud
+// KMS crypto key name, e.g. us-central1.
+func kmsKeyLocation(key string) string {
+	return strings.Split(key, "/")[3]
+}
+
+// clusterRegion returns the region of the supplied cluster location, which
+// may be either a region or a zone within a region. It returns an error if
+// the location is neither.
+func clusterRegion(location string) (string, error) {
+	if isRegion(location) {
+		return location, nil
+	}
+	i := strings.LastIndex(location, "-")
+	if i < 0 {
+		return "", errors.Errorf("cluster location %q is not a region or zone", location)
+	}
+	return location[:i], nil
+}
+
+// databaseEncryptionUpdate returns the cluster update required for the
+// supplied cluster's Kubernetes secrets to be encrypted with the Cloud KMS key
+// of the supplied spec, or nil if no update is required. Rotating the key in
+// the spec re-encrypts all secrets with the new key, as does retrying a
+// failed re-encryption. Database encryption is not managed if the spec
+// doesn't configure it.
+func databaseEncryptionUpdate(spec gcpcomputev1alpha1.GKEClusterSpec, cluster *container.Cluster) *container.ClusterUpdate {
+	if spec.DatabaseEncryption == nil {
+		return nil
+	}
+
+	actual := cluster.DatabaseEncryption
+	if actual != nil && actual.CurrentState == databaseEncryptionPending {
+		// Wait for the current re-encryption to finish.
+		return nil
+	}
+	if actual != nil && actual.State == databaseEncryptionEncrypted && actual.KeyName == spec.DatabaseEncryption.KeyName && actual.CurrentState != databaseEncryptionError {
+		return nil
+	}
+
+	return &container.ClusterUpdate{
+		DesiredDatabaseEncryption: &container.DatabaseEncryption{
+			State:   databaseEncryptionEncrypted,
+			KeyName: spec.DatabaseEncryption.KeyName,
+		},
+	}
+}
+
+// databaseEncryptionState returns the current state of application-layer
+// secrets encryption of the supplied cluster, if any.
+func databaseEncryptionState(cluster *container.Cluster) string {
+	if cluster.DatabaseEncryption == nil {
+		return ""
+	}
+	return cluster.DatabaseEncryption.CurrentState
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestValidateDatabaseEncryption(t *testing.T) {
+	key := "projects/p/locations/us-central1/keyRings/r/cryptoKeys/k"
+
+	cases := map[string]struct {
+		spec gcpcomputev1alpha1.GKEClusterSpec
+		want error
+	}{
+		"Unset": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{Zone: "us-central1-a"},
+		},
+		"ZonalCluster": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{
+				Zone:               "us-central1-a",
+				DatabaseEncryption: &gcpcomputev1alpha1.DatabaseEncryption{KeyName: key},
+			},
+		},
+		"RegionalCluster": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{
+				Zone:               "us-central1",
+				DatabaseEncryption: &gcpcomputev1alpha1.DatabaseEncryption{KeyName: key},
+			},
+		},
+		"InvalidKey": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{
+				Zone:               "us-central1-a",
+				DatabaseEncryption: &gcpcomputev1alpha1.DatabaseEncryption{KeyName: "my-key"},
+			},
+			want: errors.New(`database encryption key "my-key" must be of the form projects/*/locations/*/keyRings/*/cryptoKeys/*`),
+		},
+		"InvalidLocation": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{
+				Zone:               "global",
+				DatabaseEncryption: &gcpcomputev1alpha1.DatabaseEncryption{KeyName: key},
+			},
+			want: errors.New(`cluster location "global" is not a region or zone`),
+		},
+		"KeyInOtherRegion": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{
+				Zone:               "europe-west1-b",
+				DatabaseEncryption: &gcpcomputev1alpha1.DatabaseEncryption{KeyName: key},
+			},
+			want: errors.Errorf(`database encryption key %q is in location "us-central1"; it must be in the cluster's region "europe-west1"`, key),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := validateDatabaseEncryption(tc.spec)
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("validateDatabaseEncryption(...): -want error, +got error:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDatabaseEncryptionUpdate(t *testing.T) {
+	key := "projects/p/locations/us-central1/keyRings/r/cryptoKeys/k"
+	rotated := "projects/p/locations/us-central1/keyRings/r/cryptoKeys/k2"
+	spec := gcpcomputev1alpha1.GKEClusterSpec{DatabaseEncryption: &gcpcomputev1alpha1.DatabaseEncryption{KeyName: key}}
+	encrypt := &container.ClusterUpdate{
+		DesiredDatabaseEncryption: &container.DatabaseEncryption{State: databaseEncryptionEncrypted, KeyName: key},
+	}
+
+	cases := map[string]struct {
+		spec    gcpcomputev1alpha1.GKEClusterSpec
+		cluster *container.Cluster
+		want    *container.ClusterUpdate
+	}{
+		"Unmanaged": {
+			spec:    gcpcomputev1alpha1.GKEClusterSpec{},
+			cluster: &container.Cluster{},
+			want:    nil,
+		},
+		"Encrypt": {
+			spec:    spec,
+			cluster: &container.Cluster{DatabaseEncryption: &container.DatabaseEncryption{State: "DECRYPTED"}},
+			want:    encrypt,
+		},
+		"RotateKey": {
+			spec:    spec,
+			cluster: &container.Cluster{DatabaseEncryption: &container.DatabaseEncryption{State: databaseEncryptionEncrypted, KeyName: rotated}},
+			want:    encrypt,
+		},
+		"RetryFailedEncryption": {
+			spec: spec,
+			cluster: &container.Cluster{DatabaseEncryption: &container.DatabaseEncryption{
+				State:        databaseEncryptionEncrypted,
+				KeyName:      key,
+				CurrentState: databaseEncryptionError,
+			}},
+			want: encrypt,
+		},
+		"EncryptionPending": {
+			spec: spec,
+			cluster: &container.Cluster{DatabaseEncryption: &container.DatabaseEncryption{
+				State:        databaseEncryptionEncrypted,
+				KeyName:      rotated,
+				CurrentState: databaseEncryptionPending,
+			}},
+			want: nil,
+		},
+		"UpToDate": {
+			spec:    spec,
+			cluster: &container.Cluster{DatabaseEncryption: &container.DatabaseEncryption{State: databaseEncryptionEncrypted, KeyName: key}},
+			want:    nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := databaseEncryptionUpdate(tc.spec, tc.cluster)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("databaseEncryptionUpdate(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"sort"
+
+	"google.golang.org/api/container/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+)
+
+// Reasons of the events that report what GKE observes about a cluster.
+const (
+	EventReasonClusterCondition  = "ClusterCondition"
+	EventReasonNodePoolCondition = "NodePoolCondition"
+	EventReasonClusterStatus     = "ClusterStatus"
+	EventReasonNodePoolStatus    = "NodePoolStatus"
+	EventReasonConditionCleared  = "ConditionCleared"
+)
+
+// The statuses of a cluster or node pool that GKE reports while it is being
+// changed, e.g. upgraded, or while it is unhealthy.
+const (
+	gkeStatusReconciling      = "RECONCILING"
+	gkeStatusDegraded         = "DEGRADED"
+	gkeStatusError            = "ERROR"
+	gkeStatusRunningWithError = "RUNNING_WITH_ERROR"
+)
+
+// An observation is something GKE reports about a cluster that cluster
+// operators should see as an event, for example that the cluster's IP space
+// is exhausted, or that an upgrade has started.
+type observation struct {
+	key       string
+	eventType string
+	reason    string
+	message   string
+}
+
+// observe returns what GKE reports about the supplied cluster and its node
+// pools, sorted by key. Conditions are keyed by their code, so that a
+// condition is reported once while it persists even if its message changes.
+func observe(cluster *container.Cluster) []observation {
+	obs := []observation{}
+	for _, c := range cluster.Conditions {
+		code := conditionCode(c)
+		obs = append(obs, observation{
+			key:       "cluster/" + code,
+			eventType: corev1.EventTypeWarning,
+			reason:    EventReasonClusterCondition,
+			message:   fmt.Sprintf("%s: %s", code, c.Message),
+		})
+	}
+	if o, ok := observeStatus("cluster", "cluster", cluster.Status, cluster.StatusMessage, EventReasonClusterStatus); ok {
+		obs = append(obs, o)
+	}
+
+	for _, np := range cluster.NodePools {
+		scope := "nodePool/" + np.Name
+		for _, c := range np.Conditions {
+			code := conditionCode(c)
+			obs = append(obs, observation{
+				key:       scope + "/" + code,
+				eventType: corev1.EventTypeWarning,
+				reason:    EventReasonNodePoolCondition,
+				message:   fmt.Sprintf("node pool %s: %s: %s", np.Name, code, c.Message),
+			})
+		}
+		if o, ok := observeStatus(scope, "node pool "+np.Name, np.Status, np.StatusMessage, EventReasonNodePoolStatus); ok {
+			obs = append(obs, o)
+		}
+	}
+
+	sort.Slice(obs, func(i, j int) bool { return obs[i].key < obs[j].key })
+	return obs
+}
+
+// observeStatus returns an observation of the supplied status of a cluster or
+// node pool, if it is one that operators should see. GKE reconciles a cluster
+// or node pool while it upgrades or otherwise changes it.
+func observeStatus(scope, subject, status, message, reason string) (observation, bool) {
+	o := observation{key: scope + "/" + status, reason: reason}
+	switch status {
+	case gkeStatusReconciling:
+		o.eventType = corev1.EventTypeNormal
+	case gkeStatusDegraded, gkeStatusError, gkeStatusRunningWithError:
+		o.eventType = corev1.EventTypeWarning
+	default:
+		return observation{}, false
+	}
+	o.message = fmt.Sprintf("%s is %s", subject, status)
+	if message != "" {
+		o.message = fmt.Sprintf("%s is %s: %s", subject, status, message)
+	}
+	return o, true
+}
+
+// conditionCode returns the most specific code of the supplied condition.
+// GKE deprecated the detailed code, e.g. GCE_STOCKOUT, in favour of a
+// canonical code, e.g. RESOURCE_EXHAUSTED, but still reports it.
+func conditionCode(c *container.StatusCondition) string {
+	if c.Code != "" && c.Code != "UNKNOWN" {
+		return c.Code
+	}
+	if c.CanonicalCode != "" {
+		return c.CanonicalCode
+	}
+	return "UNKNOWN"
+}
+
+// reportObservations records an event on the supplied GKECluster for each
+// observation that was not reported by a previous sync, and for each
+// previously reported observation that GKE no longer reports. It returns true
+// if the cluster's reported observations changed.
+func (r *Reconciler) reportObservations(instance *gcpcomputev1alpha1.GKECluster, cluster *container.Cluster) bool {
+	obs := observe(cluster)
+
+	reported := map[string]bool{}
+	for _, k := range instance.Status.ReportedConditions {
+		reported[k] = true
+	}
+
+	keys := make([]string, 0, len(obs))
+	current := map[string]bool{}
+	for _, o := range obs {
+		if current[o.key] {
+			continue
+		}
+		current[o.key] = true
+		keys = append(keys, o.key)
+		if !reported[o.key] {
+			r.recorder.Event(instance, o.eventType, o.reason, o.message)
+		}
+	}
+
+	changed := len(keys) != len(instance.Status.ReportedConditions)
+	for _, k := range instance.Status.ReportedConditions {
+		if !current[k] {
+			r.recorder.Eventf(instance, corev1.EventTypeNormal, EventReasonConditionCleared, "GKE no longer reports %s", k)
+			changed = true
+		}
+	}
+	if !changed {
+		return false
+	}
+
+	if len(keys) == 0 {
+		keys = nil
+	}
+	instance.Status.ReportedConditions = keys
+	return true
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/container/v1"
+	"k8s.io/client-go/tools/record"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+)
+
+func TestObserve(t *testing.T) {
+	cases := map[string]struct {
+		cluster *container.Cluster
+		want    []string
+	}{
+		"Healthy": {
+			cluster: &container.Cluster{Status: gcpcomputev1alpha1.ClusterStateRunning, NodePools: []*container.NodePool{{Name: "pool", Status: gcpcomputev1alpha1.ClusterStateRunning}}},
+			want:    []string{},
+		},
+		"IPSpaceExhausted": {
+			cluster: &container.Cluster{
+				Status: gcpcomputev1alpha1.ClusterStateRunning,
+				NodePools: []*container.NodePool{{
+					Name:       "pool",
+					Status:     gkeStatusRunningWithError,
+					Conditions: []*container.StatusCondition{{CanonicalCode: "RESOURCE_EXHAUSTED", Message: "IP space exhausted"}},
+				}},
+			},
+			want: []string{
+				"Warning NodePoolCondition node pool pool: RESOURCE_EXHAUSTED: IP space exhausted",
+				"Warning NodePoolStatus node pool pool is RUNNING_WITH_ERROR",
+			},
+		},
+		"UpgradeStarted": {
+			cluster: &container.Cluster{Status: gkeStatusReconciling, StatusMessage: "Upgrading master to 1.15.4-gke.22"},
+			want:    []string{"Normal ClusterStatus cluster is RECONCILING: Upgrading master to 1.15.4-gke.22"},
+		},
+		"DeprecatedCode": {
+			cluster: &container.Cluster{
+				Status:     gcpcomputev1alpha1.ClusterStateRunning,
+				Conditions: []*container.StatusCondition{{Code: "GCE_STOCKOUT", CanonicalCode: "RESOURCE_EXHAUSTED", Message: "zone is out of resources"}},
+			},
+			want: []string{"Warning ClusterCondition GCE_STOCKOUT: zone is out of resources"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := []string{}
+			for _, o := range observe(tc.cluster) {
+				got = append(got, o.eventType+" "+o.reason+" "+o.message)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("observe(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReportObservations(t *testing.T) {
+	upgrading := &container.Cluster{Status: gkeStatusReconciling}
+
+	cases := map[string]struct {
+		reported     []string
+		cluster      *container.Cluster
+		wantReported []string
+		wantChanged  bool
+		wantEvents   []string
+	}{
+		"NothingToReport": {
+			cluster:     &container.Cluster{Status: gcpcomputev1alpha1.ClusterStateRunning},
+			wantChanged: false,
+			wantEvents:  []string{},
+		},
+		"NewObservation": {
+			cluster:      upgrading,
+			wantReported: []string{"cluster/RECONCILING"},
+			wantChanged:  true,
+			wantEvents:   []string{"Normal ClusterStatus cluster is RECONCILING"},
+		},
+		"AlreadyReported": {
+			reported:     []string{"cluster/RECONCILING"},
+			cluster:      upgrading,
+			wantReported: []string{"cluster/RECONCILING"},
+			wantChanged:  false,
+			wantEvents:   []string{},
+		},
+		"Cleared": {
+			reported:    []string{"cluster/RECONCILING"},
+			cluster:     &container.Cluster{Status: gcpcomputev1alpha1.ClusterStateRunning},
+			wantChanged: true,
+			wantEvents:  []string{"Normal ConditionCleared GKE no longer reports cluster/RECONCILING"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := record.NewFakeRecorder(10)
+			r := &Reconciler{recorder: rec}
+
+			instance := testCluster()
+			instance.Status.ReportedConditions = tc.reported
+
+			if got := r.reportObservations(instance, tc.cluster); got != tc.wantChanged {
+				t.Errorf("reportObservations(...): want changed %t, got %t", tc.wantChanged, got)
+			}
+			if diff := cmp.Diff(tc.wantReported, instance.Status.ReportedConditions); diff != "" {
+				t.Errorf("reportObservations(...): -want reported, +got reported:\n%s", diff)
+			}
+
+			close(rec.Events)
+			events := []string{}
+			for e := range rec.Events {
+				events = append(events, e)
+			}
+			if diff := cmp.Diff(tc.wantEvents, events); diff != "" {
+				t.Errorf("reportObservations(...): -want events, +got events:\n%s", diff)
+			}
+		})
+	}
+}
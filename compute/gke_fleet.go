@@ -0,0 +1,195 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+	gkehubv1 "google.golang.org/api/gkehub/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/gkehub"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/plan"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/secretgc"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+	"github.com/crossplaneio/crossplane/pkg/util"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	// Fleet memberships for GKE clusters always live in the global location.
+	fleetLocation = "global"
+
+	// fleetConnectSecretSuffix is appended to the name of a GKECluster to
+	// name the secret containing its Connect agent manifest.
+	fleetConnectSecretSuffix = "-fleet-connect"
+
+	// FleetConnectManifestKey is the key of the Connect agent manifest within
+	// a GKECluster's fleet connect secret.
+	FleetConnectManifestKey = "manifest"
+)
+
+// clusterSelfLink matches the project, location, and path of a GKE cluster's
+// self link, e.g. https://container.googleapis.com/v1/projects/p/zones/z/clusters/c.
+var clusterSelfLink = regexp.MustCompile(`^https://container\.googleapis\.com/v1/(projects/([^/]+)/(?:zones|locations)/([^/]+)/clusters/[^/]+)$`)
+
+func (r *Reconciler) _connectFleet(instance *gcpcomputev1alpha1.GKECluster) (gkehub.Client, error) {
+	creds, err := r.credentials(instance, provider.ServiceGKEHub, gkehub.DefaultScope)
+	if err != nil {
+		return nil, err
+	}
+	return gkehub.NewClient(ctx, creds)
+}
+
+// register registers the supplied cluster with its project's fleet, and
+// publishes the manifest of the Connect agent that links the cluster to the
+// fleet.
+func (r *Reconciler) register(instance *gcpcomputev1alpha1.GKECluster, cluster *container.Cluster) error {
+	parent, id, m, err := membershipFor(cluster)
+	if err != nil {
+		return err
+	}
+
+	hub, err := r.connectFleet(instance)
+	if err != nil {
+		return err
+	}
+
+	// Membership creation is asynchronous. If it is not complete we'll fail
+	// to generate a manifest below, and find it already exists when we retry.
+	name := fmt.Sprintf("%s/memberships/%s", parent, id)
+	err = hub.CreateMembership(ctx, parent, id, m)
+	if gcp.IsErrorAlreadyExists(err) {
+		err = adoptMembership(hub, name, m)
+	}
+	if err != nil {
+		return errors.Wrap(err, "cannot create fleet membership")
+	}
+
+	manifest, err := hub.GenerateConnectManifest(ctx, name)
+	if err != nil {
+		return errors.Wrapf(err, "cannot generate connect manifest for fleet membership %s", name)
+	}
+
+	if _, err := util.ApplySecret(r.kubeclient, fleetConnectSecret(instance, manifest)); err != nil {
+		return err
+	}
+
+	instance.Status.FleetMembership = name
+	return nil
+}
+
+// planRegistration records the fleet membership that would be created for
+// the supplied cluster were it not in dry-run mode.
+func (r *Reconciler) planRegistration(instance *gcpcomputev1alpha1.GKECluster, cluster *container.Cluster) (reconcile.Result, error) {
+	parent, id, m, err := membershipFor(cluster)
+	if err != nil {
+		return r.fail(instance, err)
+	}
+	return r.plan(instance, plan.Describe("CreateMembership", map[string]interface{}{"parent": parent, "membershipId": id, "membership": m}))
+}
+
+// unregister removes the supplied cluster from its fleet, if it was
+// registered.
+func (r *Reconciler) unregister(instance *gcpcomputev1alpha1.GKECluster) error {
+	if instance.Status.FleetMembership == "" {
+		return nil
+	}
+
+	hub, err := r.connectFleet(instance)
+	if err != nil {
+		return err
+	}
+
+	if err := hub.DeleteMembership(ctx, instance.Status.FleetMembership); err != nil && !googleapi.IsErrorNotFound(err) {
+		return errors.Wrapf(err, "cannot delete fleet membership %s", instance.Status.FleetMembership)
+	}
+
+	n := instance.GetName() + fleetConnectSecretSuffix
+	if err := r.kubeclient.CoreV1().Secrets(instance.GetNamespace()).Delete(n, &metav1.DeleteOptions{}); err != nil && !kerrors.IsNotFound(err) {
+		return errors.Wrapf(err, "cannot delete fleet connect secret %s", n)
+	}
+	return nil
+}
+
+// planUnregistration records the fleet membership that would be deleted for
+// the supplied cluster were it not in dry-run mode.
+func (r *Reconciler) planUnregistration(instance *gcpcomputev1alpha1.GKECluster) (reconcile.Result, error) {
+	return r.plan(instance, plan.Describe("DeleteMembership", map[string]string{"name": instance.Status.FleetMembership}))
+}
+
+// adoptMembership returns an error unless the existing fleet membership with
+// the supplied name is for the same cluster as the supplied membership. This
+// prevents a cluster from taking over the membership of another cluster.
+func adoptMembership(hub gkehub.Client, name string, m *gkehubv1.Membership) error {
+	existing, err := hub.GetMembership(ctx, name)
+	if err != nil {
+		return errors.Wrapf(err, "cannot get existing fleet membership %s", name)
+	}
+	if got, want := resourceLinkOf(existing), resourceLinkOf(m); got != want {
+		return errors.Errorf("fleet membership %s exists for cluster %s, not %s", name, got, want)
+	}
+	return nil
+}
+
+// resourceLinkOf returns the resource link of the GKE cluster of the supplied
+// membership. Zonal clusters may be linked by zone or by location, so zones
+// are normalised to locations.
+func resourceLinkOf(m *gkehubv1.Membership) string {
+	if m == nil || m.Endpoint == nil || m.Endpoint.GkeCluster == nil {
+		return ""
+	}
+	return strings.Replace(m.Endpoint.GkeCluster.ResourceLink, "/zones/", "/locations/", 1)
+}
+
+// membershipFor returns the parent, ID, and body of the fleet membership for
+// the supplied cluster. Memberships of all of a project's clusters share the
+// global location, so the ID includes the cluster's location as well as its
+// name to distinguish clusters of the same name in different locations.
+func membershipFor(cluster *container.Cluster) (parent, id string, m *gkehubv1.Membership, err error) {
+	match := clusterSelfLink.FindStringSubmatch(cluster.SelfLink)
+	if match == nil {
+		return "", "", nil, errors.Errorf("cannot determine project of cluster %s from self link %q", cluster.Name, cluster.SelfLink)
+	}
+
+	m = &gkehubv1.Membership{
+		Endpoint: &gkehubv1.MembershipEndpoint{
+			GkeCluster: &gkehubv1.GkeCluster{ResourceLink: "//container.googleapis.com/" + match[1]},
+		},
+	}
+	return fmt.Sprintf("projects/%s/locations/%s", match[2], fleetLocation), strings.ToLower(match[3] + "-" + cluster.Name), m, nil
+}
+
+// fleetConnectSecret returns the secret containing the supplied Connect agent
+// manifest for the supplied cluster.
+func fleetConnectSecret(instance *gcpcomputev1alpha1.GKECluster, manifest []byte) *corev1.Secret {
+	s := resource.ConnectionSecretFor(instance, gcpcomputev1alpha1.GKEClusterGroupVersionKind)
+	s.SetName(instance.GetName() + fleetConnectSecretSuffix)
+	secretgc.Mark(s, instance, gcpcomputev1alpha1.GKEClusterGroupVersionKind)
+	s.Data = map[string][]byte{FleetConnectManifestKey: manifest}
+	return s
+}
@@ -0,0 +1,315 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+	gkehubv1 "google.golang.org/api/gkehub/v1"
+	"google.golang.org/api/googleapi"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/fake"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/gkehub"
+	fakegkehub "github.com/crossplaneio/crossplane/pkg/clients/gcp/gkehub/fake"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/plan"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	fleetSelfLink   = "https://container.googleapis.com/v1/projects/cool-project/locations/us-central1/clusters/gke-cool"
+	fleetParent     = "projects/cool-project/locations/global"
+	fleetMembership = fleetParent + "/memberships/us-central1-gke-cool"
+	fleetLink       = "//container.googleapis.com/projects/cool-project/locations/us-central1/clusters/gke-cool"
+)
+
+func TestMembershipFor(t *testing.T) {
+	type want struct {
+		parent string
+		id     string
+		m      *gkehubv1.Membership
+		err    error
+	}
+
+	cases := map[string]struct {
+		cluster *container.Cluster
+		want    want
+	}{
+		"Successful": {
+			cluster: &container.Cluster{Name: "gke-cool", SelfLink: fleetSelfLink},
+			want: want{
+				parent: fleetParent,
+				id:     "us-central1-gke-cool",
+				m: &gkehubv1.Membership{
+					Endpoint: &gkehubv1.MembershipEndpoint{
+						GkeCluster: &gkehubv1.GkeCluster{ResourceLink: fleetLink},
+					},
+				},
+			},
+		},
+		"Zonal": {
+			cluster: &container.Cluster{
+				Name:     "gke-cool",
+				SelfLink: "https://container.googleapis.com/v1/projects/cool-project/zones/us-central1-a/clusters/gke-cool",
+			},
+			want: want{
+				parent: fleetParent,
+				id:     "us-central1-a-gke-cool",
+				m: &gkehubv1.Membership{
+					Endpoint: &gkehubv1.MembershipEndpoint{
+						GkeCluster: &gkehubv1.GkeCluster{
+							ResourceLink: "//container.googleapis.com/projects/cool-project/zones/us-central1-a/clusters/gke-cool",
+						},
+					},
+				},
+			},
+		},
+		"UnknownSelfLink": {
+			cluster: &container.Cluster{Name: "gke-cool", SelfLink: "gke-cool"},
+			want: want{
+				err: errors.New(`cannot determine project of cluster gke-cool from self link "gke-cool"`),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			parent, id, m, err := membershipFor(tc.cluster)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("membershipFor(...): -want error, +got error:\n%s", diff)
+			}
+			if parent != tc.want.parent || id != tc.want.id {
+				t.Errorf("membershipFor(...): want %s/%s, got %s/%s", tc.want.parent, tc.want.id, parent, id)
+			}
+			if diff := cmp.Diff(tc.want.m, m); diff != "" {
+				t.Errorf("membershipFor(...): -want membership, +got membership:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSyncFleetRegistration(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	available := corev1alpha1.ConditionedStatus{}
+	available.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+
+	notReady := corev1alpha1.ConditionedStatus{}
+	notReady.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(errBoom, "cannot generate connect manifest for fleet membership %s", fleetMembership)))
+
+	otherLink := "//container.googleapis.com/projects/cool-project/locations/us-east1/clusters/gke-cool"
+	taken := corev1alpha1.ConditionedStatus{}
+	taken.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(
+		errors.Errorf("fleet membership %s exists for cluster %s, not %s", fleetMembership, otherLink, fleetLink),
+		"cannot create fleet membership")))
+
+	alreadyExists := &googleapi.Error{Code: http.StatusConflict}
+	membership := func(link string) func(context.Context, string) (*gkehubv1.Membership, error) {
+		return func(_ context.Context, _ string) (*gkehubv1.Membership, error) {
+			return &gkehubv1.Membership{Endpoint: &gkehubv1.MembershipEndpoint{GkeCluster: &gkehubv1.GkeCluster{ResourceLink: link}}}, nil
+		}
+	}
+
+	cases := map[string]struct {
+		hub        *fakegkehub.MockClient
+		want       string
+		wantStatus corev1alpha1.ConditionedStatus
+	}{
+		"Registered": {
+			hub: &fakegkehub.MockClient{
+				MockCreateMembership: func(_ context.Context, parent, id string, _ *gkehubv1.Membership) error {
+					if parent != fleetParent {
+						t.Errorf("CreateMembership(...): want parent %s, got %s", fleetParent, parent)
+					}
+					return nil
+				},
+				MockGenerateConnectManifest: func(_ context.Context, _ string) ([]byte, error) { return []byte("manifest"), nil },
+			},
+			want:       fleetMembership,
+			wantStatus: available,
+		},
+		"AdoptedOwnMembership": {
+			hub: &fakegkehub.MockClient{
+				MockCreateMembership:        func(_ context.Context, _, _ string, _ *gkehubv1.Membership) error { return alreadyExists },
+				MockGetMembership:           membership(fleetLink),
+				MockGenerateConnectManifest: func(_ context.Context, _ string) ([]byte, error) { return []byte("manifest"), nil },
+			},
+			want:       fleetMembership,
+			wantStatus: available,
+		},
+		"MembershipOfAnotherCluster": {
+			hub: &fakegkehub.MockClient{
+				MockCreateMembership: func(_ context.Context, _, _ string, _ *gkehubv1.Membership) error { return alreadyExists },
+				MockGetMembership:    membership(otherLink),
+				MockGenerateConnectManifest: func(_ context.Context, _ string) ([]byte, error) {
+					t.Errorf("GenerateConnectManifest(...): unexpected call for another cluster's membership")
+					return nil, nil
+				},
+			},
+			wantStatus: taken,
+		},
+		"MembershipNotReady": {
+			hub: &fakegkehub.MockClient{
+				MockCreateMembership:        func(_ context.Context, _, _ string, _ *gkehubv1.Membership) error { return nil },
+				MockGenerateConnectManifest: func(_ context.Context, _ string) ([]byte, error) { return nil, errBoom },
+			},
+			wantStatus: notReady,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			instance := testCluster()
+			instance.Spec.EnableFleetRegistration = true
+
+			kube := fakekube.NewSimpleClientset()
+			r := &Reconciler{
+				Client:       fakeclient.NewFakeClient(instance),
+				kubeclient:   kube,
+				connectFleet: func(*gcpcomputev1alpha1.GKECluster) (gkehub.Client, error) { return tc.hub, nil },
+			}
+
+			cl := fake.NewGKEClient()
+			cl.MockGetCluster = func(string, string) (*container.Cluster, error) {
+				return &container.Cluster{
+					Name:       "gke-cool",
+					SelfLink:   fleetSelfLink,
+					Status:     gcpcomputev1alpha1.ClusterStateRunning,
+					MasterAuth: masterAuth,
+				}, nil
+			}
+
+			if _, err := r._sync(instance, cl); err != nil {
+				t.Fatalf("r._sync(...): %s", err)
+			}
+
+			if instance.Status.FleetMembership != tc.want {
+				t.Errorf("instance.Status.FleetMembership: want %q, got %q", tc.want, instance.Status.FleetMembership)
+			}
+			if diff := cmp.Diff(tc.wantStatus, instance.Status.ConditionedStatus, test.EquateConditions()); diff != "" {
+				t.Errorf("instance.Status: -want, +got:\n%s", diff)
+			}
+
+			if tc.want == "" {
+				return
+			}
+			s, err := kube.CoreV1().Secrets(instance.GetNamespace()).Get(instance.GetName()+fleetConnectSecretSuffix, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("cannot get fleet connect secret: %s", err)
+			}
+			if diff := cmp.Diff("manifest", string(s.Data[FleetConnectManifestKey])); diff != "" {
+				t.Errorf("fleet connect secret: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSyncFleetRegistrationDryRun(t *testing.T) {
+	instance := testCluster()
+	instance.Spec.EnableFleetRegistration = true
+	instance.SetAnnotations(map[string]string{plan.AnnotationManagementMode: plan.ModeDryRun})
+
+	kube := fakekube.NewSimpleClientset()
+	r := &Reconciler{
+		Client:     fakeclient.NewFakeClient(instance),
+		kubeclient: kube,
+		recorder:   record.NewFakeRecorder(1),
+		connectFleet: func(*gcpcomputev1alpha1.GKECluster) (gkehub.Client, error) {
+			t.Errorf("connectFleet(...): unexpected call in dry-run mode")
+			return &fakegkehub.MockClient{}, nil
+		},
+	}
+
+	cl := fake.NewGKEClient()
+	cl.MockGetCluster = func(string, string) (*container.Cluster, error) {
+		return &container.Cluster{
+			Name:       "gke-cool",
+			SelfLink:   fleetSelfLink,
+			Status:     gcpcomputev1alpha1.ClusterStateRunning,
+			MasterAuth: masterAuth,
+		}, nil
+	}
+
+	if _, err := r._sync(instance, cl); err != nil {
+		t.Fatalf("r._sync(...): %s", err)
+	}
+	if instance.Status.FleetMembership != "" {
+		t.Errorf("instance.Status.FleetMembership: want none, got %q", instance.Status.FleetMembership)
+	}
+	if !plan.IsPlanned(instance.Status.ConditionedStatus) {
+		t.Errorf("instance.Status: want Planned condition, got %+v", instance.Status.ConditionedStatus)
+	}
+	if _, err := kube.CoreV1().Secrets(instance.GetNamespace()).Get(instance.GetName()+fleetConnectSecretSuffix, metav1.GetOptions{}); err == nil {
+		t.Errorf("fleet connect secret: want none in dry-run mode")
+	}
+}
+
+func TestSyncFleetUnregistration(t *testing.T) {
+	instance := testCluster()
+	instance.Status.FleetMembership = fleetMembership
+
+	kube := fakekube.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: instance.GetNamespace(), Name: instance.GetName() + fleetConnectSecretSuffix},
+	})
+	deleted := ""
+	r := &Reconciler{
+		Client:     fakeclient.NewFakeClient(instance),
+		kubeclient: kube,
+		connectFleet: func(*gcpcomputev1alpha1.GKECluster) (gkehub.Client, error) {
+			return &fakegkehub.MockClient{
+				MockDeleteMembership: func(_ context.Context, name string) error {
+					deleted = name
+					return nil
+				},
+			}, nil
+		},
+	}
+
+	cl := fake.NewGKEClient()
+	cl.MockGetCluster = func(string, string) (*container.Cluster, error) {
+		return &container.Cluster{
+			Name:       "gke-cool",
+			SelfLink:   fleetSelfLink,
+			Status:     gcpcomputev1alpha1.ClusterStateRunning,
+			MasterAuth: masterAuth,
+		}, nil
+	}
+
+	if _, err := r._sync(instance, cl); err != nil {
+		t.Fatalf("r._sync(...): %s", err)
+	}
+	if deleted != fleetMembership {
+		t.Errorf("DeleteMembership(...): want %q, got %q", fleetMembership, deleted)
+	}
+	if instance.Status.FleetMembership != "" {
+		t.Errorf("instance.Status.FleetMembership: want none, got %q", instance.Status.FleetMembership)
+	}
+	if _, err := kube.CoreV1().Secrets(instance.GetNamespace()).Get(instance.GetName()+fleetConnectSecretSuffix, metav1.GetOptions{}); err == nil {
+		t.Errorf("fleet connect secret: want none once unregistered")
+	}
+}
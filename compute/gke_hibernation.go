@@ -0,0 +1,301 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/container/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/gke"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/plan"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/tracing"
+)
+
+// hibernating returns true if the supplied time falls within one of the
+// windows of the supplied hibernation schedule. A window is open if it will
+// end before it next starts.
+func hibernating(s *gcpcomputev1alpha1.HibernationSchedule, now time.Time) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+
+	// LoadLocation returns UTC if no time zone is specified.
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid hibernation time zone %q", s.TimeZone)
+	}
+	now = now.In(loc)
+
+	for _, w := range s.Windows {
+		start, err := cron.ParseStandard(w.Start)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid hibernation window start %q", w.Start)
+		}
+		end, err := cron.ParseStandard(w.End)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid hibernation window end %q", w.End)
+		}
+		if end.Next(now).Before(start.Next(now)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// validateHibernationSchedule returns an error if the supplied spec's
+// hibernation schedule can't be parsed, or if the cluster's node pools can't
+// be scaled to zero.
+func validateHibernationSchedule(spec gcpcomputev1alpha1.GKEClusterSpec) error {
+	if spec.HibernationSchedule == nil {
+		return nil
+	}
+	if spec.Autopilot {
+		return errors.New("clusters using Autopilot cannot be hibernated; GKE manages their nodes")
+	}
+	_, err := hibernating(spec.HibernationSchedule, time.Now())
+	return err
+}
+
+// AnnotationHibernatedNodePools records the size and autoscaling settings
+// each node pool of a GKECluster had before it was hibernated, so that they
+// may be restored when it wakes. Its value is a JSON object keyed by node pool
+// name.
+const AnnotationHibernatedNodePools = "compute.gcp.crossplane.io/hibernated-node-pools"
+
+// A sleepingNodePool is the size and autoscaling settings of a node pool
+// before it was hibernated. Like the node pool sizes GKE accepts, NodeCount is
+// the number of nodes in each of the node pool's zones.
+type sleepingNodePool struct {
+	NodeCount   int64                          `json:"nodeCount"`
+	Autoscaling *container.NodePoolAutoscaling `json:"autoscaling,omitempty"`
+}
+
+// sleepingNodePools returns the node pool settings recorded by the supplied
+// cluster's hibernated node pools annotation. Invalid annotations are ignored.
+func sleepingNodePools(o metav1.Object) map[string]sleepingNodePool {
+	p := map[string]sleepingNodePool{}
+	if v, ok := o.GetAnnotations()[AnnotationHibernatedNodePools]; ok {
+		_ = json.Unmarshal([]byte(v), &p)
+	}
+	return p
+}
+
+// setSleepingNodePools records the supplied node pool settings in the supplied
+// cluster's hibernated node pools annotation, removing it if there are none.
+func setSleepingNodePools(o metav1.Object, p map[string]sleepingNodePool) {
+	a := o.GetAnnotations()
+	if len(p) == 0 {
+		if _, ok := a[AnnotationHibernatedNodePools]; ok {
+			delete(a, AnnotationHibernatedNodePools)
+			o.SetAnnotations(a)
+		}
+		return
+	}
+	if a == nil {
+		a = map[string]string{}
+	}
+	// Marshalling a map of this struct can't fail.
+	v, _ := json.Marshal(p)
+	a[AnnotationHibernatedNodePools] = string(v)
+	o.SetAnnotations(a)
+}
+
+// A hibernationStep is the next change that must be made to a node pool to
+// hibernate or wake it. Autoscaling is disabled before a node pool is scaled
+// to zero, lest the autoscaler scale it back up to its minimum, and restored
+// once it has been scaled back up.
+type hibernationStep struct {
+	nodePool *container.NodePool
+
+	// sleep is true if the node pool is being hibernated.
+	sleep bool
+
+	// autoscaling is the autoscaling the node pool must be updated to use. If
+	// it is nil the node pool must instead be resized to size.
+	autoscaling *container.NodePoolAutoscaling
+	size        int64
+}
+
+// nodePoolHibernation returns the next change that must be made to a node
+// pool of the supplied cluster to hibernate or wake it, and false if none
+// must be made. Node pools are woken at their recorded size, or at their
+// initial node count if their size was not recorded.
+func nodePoolHibernation(hibernated []string, sleeping map[string]sleepingNodePool, cluster *container.Cluster, hibernate bool) (hibernationStep, bool) {
+	for _, np := range cluster.NodePools {
+		asleep := containsString(hibernated, np.Name)
+		rec, recorded := sleeping[np.Name]
+		switch {
+		case hibernate && !asleep && autoscalingEnabled(np.Autoscaling):
+			return hibernationStep{nodePool: np, sleep: true, autoscaling: &container.NodePoolAutoscaling{Enabled: false}}, true
+		case hibernate && !asleep:
+			return hibernationStep{nodePool: np, sleep: true, size: 0}, true
+		case !hibernate && asleep && recorded:
+			return hibernationStep{nodePool: np, size: rec.NodeCount}, true
+		case !hibernate && asleep:
+			return hibernationStep{nodePool: np, size: np.InitialNodeCount}, true
+		case !hibernate && recorded && autoscalingEnabled(rec.Autoscaling) && !autoscalingEnabled(np.Autoscaling):
+			return hibernationStep{nodePool: np, autoscaling: rec.Autoscaling}, true
+		}
+	}
+	return hibernationStep{}, false
+}
+
+func autoscalingEnabled(a *container.NodePoolAutoscaling) bool {
+	return a != nil && a.Enabled
+}
+
+// hibernateNodePool makes the supplied change to a node pool to hibernate or
+// wake it, and records the node pools that are hibernated. The size and
+// autoscaling settings of a node pool are recorded before it is first changed
+// to hibernate it.
+func (r *Reconciler) hibernateNodePool(instance *gcpcomputev1alpha1.GKECluster, client gke.Client, step hibernationStep) (reconcile.Result, error) {
+	name := step.nodePool.Name
+	if plan.IsDryRun(instance) {
+		if step.autoscaling != nil {
+			return r.plan(instance, plan.Describe("SetNodePoolAutoscaling", map[string]interface{}{"cluster": instance.Status.ClusterName, "nodePool": name, "autoscaling": step.autoscaling}))
+		}
+		return r.plan(instance, plan.Describe("SetNodePoolSize", map[string]interface{}{"cluster": instance.Status.ClusterName, "nodePool": name, "size": step.size}))
+	}
+
+	sleeping := sleepingNodePools(instance)
+	if _, ok := sleeping[name]; step.sleep && !ok {
+		sizes, err := r.nodePoolSizes(instance)
+		if err != nil {
+			return r.fail(instance, err)
+		}
+		count, err := sizes.NodeCount(ctx, step.nodePool)
+		if err != nil {
+			return r.fail(instance, errors.Wrapf(err, "cannot get size of node pool %s", name))
+		}
+		sleeping[name] = sleepingNodePool{NodeCount: perZoneNodeCount(step.nodePool, count), Autoscaling: step.nodePool.Autoscaling}
+		setSleepingNodePools(instance, sleeping)
+	}
+
+	span := startPhase(client, tracing.PhaseUpdate)
+	if step.autoscaling != nil {
+		u := &container.ClusterUpdate{DesiredNodePoolId: name, DesiredNodePoolAutoscaling: step.autoscaling}
+		if err := tracing.End(span, client.UpdateCluster(instance.Spec.Zone, instance.Status.ClusterName, u)); err != nil {
+			return r.fail(instance, err)
+		}
+	} else if err := tracing.End(span, client.SetNodePoolSize(instance.Spec.Zone, instance.Status.ClusterName, name, step.size)); err != nil {
+		return r.fail(instance, err)
+	}
+
+	switch {
+	case step.sleep && step.autoscaling == nil:
+		instance.Status.HibernatedNodePools = append(instance.Status.HibernatedNodePools, name)
+	case !step.sleep && step.autoscaling == nil:
+		instance.Status.HibernatedNodePools = removeString(instance.Status.HibernatedNodePools, name)
+		instance.Status.Hibernated = false
+	}
+
+	instance.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return reconcile.Result{RequeueAfter: requeueOnWait},
+		errors.Wrapf(r.Update(ctx, instance), updateErrorMessageFormat, instance.GetName())
+}
+
+// perZoneNodeCount returns the number of nodes in each zone of the supplied
+// node pool, given its total number of nodes. A node pool has one managed
+// instance group in each of its zones, and GKE keeps their sizes equal.
+func perZoneNodeCount(np *container.NodePool, total int64) int64 {
+	if zones := int64(len(np.InstanceGroupUrls)); zones > 1 {
+		return total / zones
+	}
+	return total
+}
+
+// A nodePoolSizer reports the current size of GKE node pools.
+type nodePoolSizer interface {
+	NodeCount(ctx context.Context, np *container.NodePool) (int64, error)
+}
+
+// computeNodePoolSizer is a nodePoolSizer using the GCP Compute API. The GKE
+// API does not report the current size of a node pool, so it sums the target
+// sizes of the node pool's managed instance groups.
+type computeNodePoolSizer struct {
+	service *compute.Service
+}
+
+// NodeCount returns the current number of nodes in the supplied node pool,
+// across all of its zones.
+func (s *computeNodePoolSizer) NodeCount(ctx context.Context, np *container.NodePool) (int64, error) {
+	var count int64
+	for _, u := range np.InstanceGroupUrls {
+		project, zone, name, err := parseInstanceGroupURL(u)
+		if err != nil {
+			return 0, err
+		}
+		m, err := s.service.InstanceGroupManagers.Get(project, zone, name).Context(ctx).Do()
+		if err != nil {
+			return 0, errors.Wrapf(err, "cannot get instance group manager %s", name)
+		}
+		count += m.TargetSize
+	}
+	return count, nil
+}
+
+// parseInstanceGroupURL returns the project, zone and name of the supplied
+// instance group URL, which GKE reports in the form
+// https://www.googleapis.com/compute/v1/projects/{project}/zones/{zone}/instanceGroupManagers/{name}.
+func parseInstanceGroupURL(u string) (project, zone, name string, err error) {
+	parts := strings.Split(u, "/")
+	for i := 0; i+1 < len(parts); i++ {
+		switch parts[i] {
+		case "projects":
+			project = parts[i+1]
+		case "zones":
+			zone = parts[i+1]
+		case "instanceGroupManagers":
+			name = parts[i+1]
+		}
+	}
+	if project == "" || zone == "" || name == "" {
+		return "", "", "", errors.Errorf("invalid instance group URL %q", u)
+	}
+	return project, zone, name, nil
+}
+
+// _nodePoolSizes returns a nodePoolSizer authenticated using the credentials
+// of the Provider referenced by the supplied cluster.
+func (r *Reconciler) _nodePoolSizes(instance *gcpcomputev1alpha1.GKECluster) (nodePoolSizer, error) {
+	s, _, err := r.computeService(instance, compute.ComputeReadonlyScope)
+	if err != nil {
+		return nil, err
+	}
+	return &computeNodePoolSizer{service: s}, nil
+}
+
+func removeString(s []string, v string) []string {
+	out := make([]string, 0, len(s))
+	for _, e := range s {
+		if e != v {
+			out = append(out, e)
+		}
+	}
+	return out
+}
@@ -0,0 +1,414 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"google.golang.org/api/container/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	fakegcp "github.com/crossplaneio/crossplane/pkg/clients/gcp/fake"
+)
+
+// overnight hibernates clusters from 19:00 to 07:00 on weekdays.
+var overnight = &gcpcomputev1alpha1.HibernationSchedule{
+	Windows:  []gcpcomputev1alpha1.HibernationWindow{{Start: "0 19 * * 1-5", End: "0 7 * * 2-6"}},
+	TimeZone: "Europe/Berlin",
+}
+
+func TestHibernating(t *testing.T) {
+	// A Wednesday.
+	at := func(hour int) time.Time { return time.Date(2019, time.October, 16, hour, 0, 0, 0, time.UTC) }
+
+	cases := map[string]struct {
+		s       *gcpcomputev1alpha1.HibernationSchedule
+		now     time.Time
+		want    bool
+		wantErr bool
+	}{
+		"NoSchedule": {
+			now: at(23),
+		},
+		"WithinWindow": {
+			s:    overnight,
+			now:  at(21),
+			want: true,
+		},
+		"WithinWindowAfterMidnight": {
+			s:    overnight,
+			now:  at(3),
+			want: true,
+		},
+		"OutsideWindow": {
+			s:   overnight,
+			now: at(12),
+		},
+		"TimeZone": {
+			// 17:30 UTC is 19:30 in Berlin.
+			s:    overnight,
+			now:  at(17).Add(30 * time.Minute),
+			want: true,
+		},
+		"InvalidCron": {
+			s:       &gcpcomputev1alpha1.HibernationSchedule{Windows: []gcpcomputev1alpha1.HibernationWindow{{Start: "at seven", End: "0 7 * * *"}}},
+			now:     at(12),
+			wantErr: true,
+		},
+		"InvalidTimeZone": {
+			s:       &gcpcomputev1alpha1.HibernationSchedule{TimeZone: "Mars/Olympus_Mons"},
+			now:     at(12),
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := hibernating(tc.s, tc.now)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("hibernating(...): want error %t, got %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("hibernating(...): want %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNodePoolHibernation(t *testing.T) {
+	autoscaled := &container.NodePoolAutoscaling{Enabled: true, MinNodeCount: 1, MaxNodeCount: 5}
+	cool := &container.NodePool{Name: "cool-pool", InitialNodeCount: 3}
+	other := &container.NodePool{Name: "other-pool", InitialNodeCount: 1}
+	scaling := &container.NodePool{Name: "cool-pool", InitialNodeCount: 3, Autoscaling: autoscaled}
+	stopped := &container.NodePool{Name: "cool-pool", InitialNodeCount: 3, Autoscaling: &container.NodePoolAutoscaling{}}
+
+	type want struct {
+		step hibernationStep
+		ok   bool
+	}
+
+	cases := map[string]struct {
+		pools      []*container.NodePool
+		hibernated []string
+		sleeping   map[string]sleepingNodePool
+		hibernate  bool
+		want       want
+	}{
+		"Hibernate": {
+			pools:     []*container.NodePool{cool, other},
+			hibernate: true,
+			want:      want{step: hibernationStep{nodePool: cool, sleep: true, size: 0}, ok: true},
+		},
+		"HibernateNextPool": {
+			pools:      []*container.NodePool{cool, other},
+			hibernated: []string{"cool-pool"},
+			hibernate:  true,
+			want:       want{step: hibernationStep{nodePool: other, sleep: true, size: 0}, ok: true},
+		},
+		"Hibernated": {
+			pools:      []*container.NodePool{cool, other},
+			hibernated: []string{"cool-pool", "other-pool"},
+			hibernate:  true,
+		},
+		"HibernateDisablesAutoscaling": {
+			pools:     []*container.NodePool{scaling},
+			hibernate: true,
+			want:      want{step: hibernationStep{nodePool: scaling, sleep: true, autoscaling: &container.NodePoolAutoscaling{}}, ok: true},
+		},
+		"HibernateAutoscalingDisabled": {
+			pools:     []*container.NodePool{stopped},
+			sleeping:  map[string]sleepingNodePool{"cool-pool": {NodeCount: 4, Autoscaling: autoscaled}},
+			hibernate: true,
+			want:      want{step: hibernationStep{nodePool: stopped, sleep: true, size: 0}, ok: true},
+		},
+		"WakeAtRecordedSize": {
+			pools:      []*container.NodePool{cool, other},
+			hibernated: []string{"cool-pool", "other-pool"},
+			sleeping:   map[string]sleepingNodePool{"cool-pool": {NodeCount: 5}},
+			want:       want{step: hibernationStep{nodePool: cool, size: 5}, ok: true},
+		},
+		"WakeAtInitialNodeCount": {
+			pools:      []*container.NodePool{cool, other},
+			hibernated: []string{"cool-pool", "other-pool"},
+			want:       want{step: hibernationStep{nodePool: cool, size: 3}, ok: true},
+		},
+		"WakeRestoresAutoscaling": {
+			pools:    []*container.NodePool{stopped},
+			sleeping: map[string]sleepingNodePool{"cool-pool": {NodeCount: 4, Autoscaling: autoscaled}},
+			want:     want{step: hibernationStep{nodePool: stopped, autoscaling: autoscaled}, ok: true},
+		},
+		"Awake": {
+			pools:    []*container.NodePool{scaling},
+			sleeping: map[string]sleepingNodePool{"cool-pool": {NodeCount: 4, Autoscaling: autoscaled}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			step, ok := nodePoolHibernation(tc.hibernated, tc.sleeping, &container.Cluster{NodePools: tc.pools}, tc.hibernate)
+			got := want{step: step, ok: ok}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{}, hibernationStep{})); diff != "" {
+				t.Errorf("nodePoolHibernation(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSleepingNodePools(t *testing.T) {
+	instance := testCluster()
+	want := map[string]sleepingNodePool{"cool-pool": {NodeCount: 4, Autoscaling: &container.NodePoolAutoscaling{Enabled: true, MaxNodeCount: 5}}}
+
+	setSleepingNodePools(instance, want)
+	if diff := cmp.Diff(want, sleepingNodePools(instance)); diff != "" {
+		t.Errorf("sleepingNodePools(...): -want, +got:\n%s", diff)
+	}
+
+	setSleepingNodePools(instance, nil)
+	if _, ok := instance.GetAnnotations()[AnnotationHibernatedNodePools]; ok {
+		t.Errorf("setSleepingNodePools(...): want annotation removed")
+	}
+}
+
+func TestParseInstanceGroupURL(t *testing.T) {
+	type want struct {
+		project, zone, name string
+		err                 bool
+	}
+
+	cases := map[string]struct {
+		url  string
+		want want
+	}{
+		"Valid": {
+			url:  "https://www.googleapis.com/compute/v1/projects/cool-project/zones/us-central1-a/instanceGroupManagers/gke-cool-pool-grp",
+			want: want{project: "cool-project", zone: "us-central1-a", name: "gke-cool-pool-grp"},
+		},
+		"Invalid": {
+			url:  "https://www.googleapis.com/compute/v1/projects/cool-project",
+			want: want{err: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			project, zone, n, err := parseInstanceGroupURL(tc.url)
+			got := want{project: project, zone: zone, name: n, err: err != nil}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("parseInstanceGroupURL(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+type fakeNodePoolSizer struct {
+	count int64
+	err   error
+}
+
+func (s fakeNodePoolSizer) NodeCount(context.Context, *container.NodePool) (int64, error) {
+	return s.count, s.err
+}
+
+func TestPerZoneNodeCount(t *testing.T) {
+	igm := "https://www.googleapis.com/compute/v1/projects/cool-project/zones/us-central1-a/instanceGroupManagers/gke-cool-pool-grp"
+
+	cases := map[string]struct {
+		np    *container.NodePool
+		total int64
+		want  int64
+	}{
+		"NoInstanceGroups": {
+			np:    &container.NodePool{},
+			total: 4,
+			want:  4,
+		},
+		"Zonal": {
+			np:    &container.NodePool{InstanceGroupUrls: []string{igm}},
+			total: 4,
+			want:  4,
+		},
+		"Regional": {
+			np:    &container.NodePool{InstanceGroupUrls: []string{igm, igm, igm}},
+			total: 9,
+			want:  3,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := perZoneNodeCount(tc.np, tc.total); got != tc.want {
+				t.Errorf("perZoneNodeCount(...): want %d, got %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSyncHibernation(t *testing.T) {
+	// A window that is open almost always; it ends every minute, but only
+	// starts once a year.
+	always := &gcpcomputev1alpha1.HibernationSchedule{
+		Windows: []gcpcomputev1alpha1.HibernationWindow{{Start: "0 0 1 1 *", End: "* * * * *"}},
+	}
+	autoscaled := &container.NodePoolAutoscaling{Enabled: true, MinNodeCount: 1, MaxNodeCount: 5}
+
+	cases := map[string]struct {
+		schedule        *gcpcomputev1alpha1.HibernationSchedule
+		hibernated      []string
+		sleeping        map[string]sleepingNodePool
+		autoscaling     *container.NodePoolAutoscaling
+		instanceGroups  []string
+		count           int64
+		wantSize        int64
+		wantResized     bool
+		wantAutoscaling *container.NodePoolAutoscaling
+		wantHibernated  bool
+		wantSleeping    map[string]sleepingNodePool
+		want            reconcile.Result
+	}{
+		"Hibernate": {
+			schedule:     always,
+			wantSize:     0,
+			wantResized:  true,
+			wantSleeping: map[string]sleepingNodePool{"cool-pool": {NodeCount: 4}},
+			want:         reconcile.Result{RequeueAfter: requeueOnWait},
+		},
+		"HibernateMultiZone": {
+			// A node pool of 3 nodes in each of 3 zones is recorded as
+			// having 3 nodes, which is the size GKE sets in each zone.
+			schedule: always,
+			instanceGroups: []string{
+				"https://www.googleapis.com/compute/v1/projects/cool-project/zones/us-central1-a/instanceGroupManagers/gke-cool-pool-a",
+				"https://www.googleapis.com/compute/v1/projects/cool-project/zones/us-central1-b/instanceGroupManagers/gke-cool-pool-b",
+				"https://www.googleapis.com/compute/v1/projects/cool-project/zones/us-central1-c/instanceGroupManagers/gke-cool-pool-c",
+			},
+			count:        9,
+			wantSize:     0,
+			wantResized:  true,
+			wantSleeping: map[string]sleepingNodePool{"cool-pool": {NodeCount: 3}},
+			want:         reconcile.Result{RequeueAfter: requeueOnWait},
+		},
+		"HibernateAutoscaled": {
+			schedule:        always,
+			autoscaling:     autoscaled,
+			wantAutoscaling: &container.NodePoolAutoscaling{},
+			wantSleeping:    map[string]sleepingNodePool{"cool-pool": {NodeCount: 4, Autoscaling: autoscaled}},
+			want:            reconcile.Result{RequeueAfter: requeueOnWait},
+		},
+		"Hibernated": {
+			schedule:       always,
+			hibernated:     []string{"cool-pool"},
+			sleeping:       map[string]sleepingNodePool{"cool-pool": {NodeCount: 4}},
+			wantHibernated: true,
+			wantSleeping:   map[string]sleepingNodePool{"cool-pool": {NodeCount: 4}},
+			want:           reconcile.Result{RequeueAfter: requeueOnSucces},
+		},
+		"Wake": {
+			hibernated:   []string{"cool-pool"},
+			sleeping:     map[string]sleepingNodePool{"cool-pool": {NodeCount: 4}},
+			wantSize:     4,
+			wantResized:  true,
+			wantSleeping: map[string]sleepingNodePool{"cool-pool": {NodeCount: 4}},
+			want:         reconcile.Result{RequeueAfter: requeueOnWait},
+		},
+		"WakeAutoscaled": {
+			sleeping:        map[string]sleepingNodePool{"cool-pool": {NodeCount: 4, Autoscaling: autoscaled}},
+			autoscaling:     &container.NodePoolAutoscaling{},
+			wantAutoscaling: autoscaled,
+			wantSleeping:    map[string]sleepingNodePool{"cool-pool": {NodeCount: 4, Autoscaling: autoscaled}},
+			want:            reconcile.Result{RequeueAfter: requeueOnWait},
+		},
+		"Awake": {
+			sleeping:    map[string]sleepingNodePool{"cool-pool": {NodeCount: 4, Autoscaling: autoscaled}},
+			autoscaling: autoscaled,
+			want:        reconcile.Result{RequeueAfter: requeueOnSucces},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			instance := testCluster()
+			instance.Spec.HibernationSchedule = tc.schedule
+			instance.Status.ClusterName = "gke-cool"
+			instance.Status.HibernatedNodePools = tc.hibernated
+			setSleepingNodePools(instance, tc.sleeping)
+
+			count := tc.count
+			if count == 0 {
+				count = 4
+			}
+			r := &Reconciler{
+				Client:     fakeclient.NewFakeClient(instance),
+				kubeclient: fake.NewSimpleClientset(),
+				nodePoolSizes: func(*gcpcomputev1alpha1.GKECluster) (nodePoolSizer, error) {
+					return fakeNodePoolSizer{count: count}, nil
+				},
+			}
+
+			resized := false
+			var autoscaling *container.NodePoolAutoscaling
+			cl := fakegcp.NewGKEClient()
+			cl.MockGetCluster = func(string, string) (*container.Cluster, error) {
+				return &container.Cluster{
+					Status:     gcpcomputev1alpha1.ClusterStateRunning,
+					MasterAuth: masterAuth,
+					NodePools:  []*container.NodePool{{Name: "cool-pool", InitialNodeCount: 3, Autoscaling: tc.autoscaling, InstanceGroupUrls: tc.instanceGroups}},
+				}, nil
+			}
+			cl.MockSetNodePoolSize = func(_, cluster, nodePool string, size int64) error {
+				if cluster != "gke-cool" || nodePool != "cool-pool" || size != tc.wantSize {
+					t.Errorf("SetNodePoolSize(...): want %s/%s size %d, got %s/%s size %d", "gke-cool", "cool-pool", tc.wantSize, cluster, nodePool, size)
+				}
+				resized = true
+				return nil
+			}
+			cl.MockUpdateCluster = func(_, cluster string, u *container.ClusterUpdate) error {
+				if cluster != "gke-cool" || u.DesiredNodePoolId != "cool-pool" {
+					t.Errorf("UpdateCluster(...): want %s/%s, got %s/%s", "gke-cool", "cool-pool", cluster, u.DesiredNodePoolId)
+				}
+				autoscaling = u.DesiredNodePoolAutoscaling
+				return nil
+			}
+
+			rs, err := r._sync(instance, cl)
+			if err != nil {
+				t.Fatalf("r._sync(...): %s", err)
+			}
+			if diff := cmp.Diff(tc.want, rs); diff != "" {
+				t.Errorf("r._sync(...): -want, +got:\n%s", diff)
+			}
+			if resized != tc.wantResized {
+				t.Errorf("SetNodePoolSize(...): want called %t, got %t", tc.wantResized, resized)
+			}
+			if diff := cmp.Diff(tc.wantAutoscaling, autoscaling); diff != "" {
+				t.Errorf("UpdateCluster(...): -want autoscaling, +got autoscaling:\n%s", diff)
+			}
+			if instance.Status.Hibernated != tc.wantHibernated {
+				t.Errorf("instance.Status.Hibernated: want %t, got %t", tc.wantHibernated, instance.Status.Hibernated)
+			}
+			if diff := cmp.Diff(tc.wantSleeping, sleepingNodePools(instance), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("sleepingNodePools(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/gke"
+)
+
+const (
+	// labelDriftEnforce reverts cluster resource labels that were changed
+	// outside of the GKECluster to those of its spec.
+	labelDriftEnforce = "Enforce"
+
+	// labelDriftAdopt absorbs cluster resource labels that were added
+	// outside of the GKECluster into its spec. Labels that are already in its
+	// spec are owned by the spec, and are reverted like labelDriftEnforce.
+	labelDriftAdopt = "Adopt"
+)
+
+// validateLabelDriftPolicy returns an error if the supplied spec's label drift
+// policy is unknown. Clusters without a policy do not reconcile their labels.
+func validateLabelDriftPolicy(spec gcpcomputev1alpha1.GKEClusterSpec) error {
+	switch spec.LabelDriftPolicy {
+	case "", labelDriftEnforce, labelDriftAdopt:
+		return nil
+	default:
+		return errors.Errorf("unknown label drift policy %q; use %s or %s", spec.LabelDriftPolicy, labelDriftEnforce, labelDriftAdopt)
+	}
+}
+
+// labelsDrifted returns true if the supplied cluster's resource labels differ
+// from those of the supplied spec, and the spec has a label drift policy.
+func labelsDrifted(spec gcpcomputev1alpha1.GKEClusterSpec, cluster *container.Cluster) bool {
+	if spec.LabelDriftPolicy == "" {
+		return false
+	}
+	if len(spec.ResourceLabels) != len(cluster.ResourceLabels) {
+		return true
+	}
+	for k, v := range spec.ResourceLabels {
+		if cv, ok := cluster.ResourceLabels[k]; !ok || cv != v {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileLabels reverts or adopts the resource labels of the supplied
+// cluster according to the supplied GKECluster's label drift policy.
+func (r *Reconciler) reconcileLabels(instance *gcpcomputev1alpha1.GKECluster, client gke.Client, cluster *container.Cluster) (reconcile.Result, error) {
+	if instance.Spec.LabelDriftPolicy == labelDriftAdopt && adoptLabels(&instance.Spec, cluster) {
+		return reconcile.Result{Requeue: true},
+			errors.Wrapf(r.Update(ctx, instance), updateErrorMessageFormat, instance.GetName())
+	}
+
+	// The label fingerprint guards against overwriting labels that changed
+	// since we read the cluster.
+	args := map[string]interface{}{"cluster": instance.Status.ClusterName, "labels": instance.Spec.ResourceLabels}
+	return r.mutateCluster(instance, client, "SetLabels", args, func() error {
+		return client.SetLabels(instance.Spec.Zone, instance.Status.ClusterName, instance.Spec.ResourceLabels, cluster.LabelFingerprint)
+	})
+}
+
+// adoptLabels copies the resource labels of the supplied cluster that are not
+// in the supplied spec into it. It returns true if any labels were copied.
+func adoptLabels(spec *gcpcomputev1alpha1.GKEClusterSpec, cluster *container.Cluster) bool {
+	adopted := false
+	for k, v := range cluster.ResourceLabels {
+		if _, ok := spec.ResourceLabels[k]; ok {
+			continue
+		}
+		if spec.ResourceLabels == nil {
+			spec.ResourceLabels = map[string]string{}
+		}
+		spec.ResourceLabels[k] = v
+		adopted = true
+	}
+	return adopted
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"google.golang.org/api/container/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	fakegcp "github.com/crossplaneio/crossplane/pkg/clients/gcp/fake"
+)
+
+func TestValidateLabelDriftPolicy(t *testing.T) {
+	cases := map[string]struct {
+		policy  string
+		wantErr bool
+	}{
+		"Unset":   {policy: ""},
+		"Enforce": {policy: labelDriftEnforce},
+		"Adopt":   {policy: labelDriftAdopt},
+		"Unknown": {policy: "Ignore", wantErr: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateLabelDriftPolicy(gcpcomputev1alpha1.GKEClusterSpec{LabelDriftPolicy: tc.policy})
+			if (err != nil) != tc.wantErr {
+				t.Errorf("validateLabelDriftPolicy(...): want error %t, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLabelsDrifted(t *testing.T) {
+	cases := map[string]struct {
+		policy  string
+		spec    map[string]string
+		cluster map[string]string
+		want    bool
+	}{
+		"NoPolicy": {
+			spec:    map[string]string{"team": "cool"},
+			cluster: map[string]string{"team": "uncool"},
+			want:    false,
+		},
+		"InSync": {
+			policy:  labelDriftEnforce,
+			spec:    map[string]string{"team": "cool"},
+			cluster: map[string]string{"team": "cool"},
+			want:    false,
+		},
+		"BothEmpty": {
+			policy:  labelDriftEnforce,
+			spec:    map[string]string{},
+			cluster: nil,
+			want:    false,
+		},
+		"Changed": {
+			policy:  labelDriftEnforce,
+			spec:    map[string]string{"team": "cool"},
+			cluster: map[string]string{"team": "uncool"},
+			want:    true,
+		},
+		"Added": {
+			policy:  labelDriftAdopt,
+			spec:    map[string]string{"team": "cool"},
+			cluster: map[string]string{"team": "cool", "env": "prod"},
+			want:    true,
+		},
+		"Removed": {
+			policy:  labelDriftEnforce,
+			spec:    map[string]string{"team": "cool"},
+			cluster: nil,
+			want:    true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			spec := gcpcomputev1alpha1.GKEClusterSpec{LabelDriftPolicy: tc.policy, ResourceLabels: tc.spec}
+			got := labelsDrifted(spec, &container.Cluster{ResourceLabels: tc.cluster})
+			if got != tc.want {
+				t.Errorf("labelsDrifted(...): want %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSyncLabels(t *testing.T) {
+	spec := map[string]string{"team": "cool"}
+	console := map[string]string{"team": "cool", "owner": "someone"}
+
+	cases := map[string]struct {
+		policy     string
+		spec       map[string]string
+		cluster    map[string]string
+		wantSet    map[string]string
+		wantLabels map[string]string
+		want       reconcile.Result
+	}{
+		"Enforce": {
+			policy:     labelDriftEnforce,
+			spec:       spec,
+			cluster:    console,
+			wantSet:    spec,
+			wantLabels: spec,
+			want:       reconcile.Result{RequeueAfter: requeueOnWait},
+		},
+		"Adopt": {
+			policy:     labelDriftAdopt,
+			spec:       spec,
+			cluster:    console,
+			wantLabels: console,
+			want:       reconcile.Result{Requeue: true},
+		},
+		"AdoptSpecChanged": {
+			// Labels in the spec are owned by it, so a label changed in the
+			// spec is pushed to the cluster rather than reverted.
+			policy:     labelDriftAdopt,
+			spec:       map[string]string{"team": "cooler", "owner": "someone"},
+			cluster:    console,
+			wantSet:    map[string]string{"team": "cooler", "owner": "someone"},
+			wantLabels: map[string]string{"team": "cooler", "owner": "someone"},
+			want:       reconcile.Result{RequeueAfter: requeueOnWait},
+		},
+		"AdoptSpecAdded": {
+			policy:     labelDriftAdopt,
+			spec:       map[string]string{"team": "cool", "owner": "someone", "env": "prod"},
+			cluster:    console,
+			wantSet:    map[string]string{"team": "cool", "owner": "someone", "env": "prod"},
+			wantLabels: map[string]string{"team": "cool", "owner": "someone", "env": "prod"},
+			want:       reconcile.Result{RequeueAfter: requeueOnWait},
+		},
+		"Ignore": {
+			spec:       spec,
+			cluster:    console,
+			wantLabels: spec,
+			want:       reconcile.Result{RequeueAfter: requeueOnSucces},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			instance := testCluster()
+			instance.Spec.LabelDriftPolicy = tc.policy
+			instance.Spec.ResourceLabels = make(map[string]string, len(tc.spec))
+			for k, v := range tc.spec {
+				instance.Spec.ResourceLabels[k] = v
+			}
+			instance.Status.ClusterName = "gke-cool"
+
+			r := &Reconciler{
+				Client:     fakeclient.NewFakeClient(instance),
+				kubeclient: fake.NewSimpleClientset(),
+			}
+
+			var set map[string]string
+			cl := fakegcp.NewGKEClient()
+			cl.MockGetCluster = func(string, string) (*container.Cluster, error) {
+				return &container.Cluster{
+					Status:           gcpcomputev1alpha1.ClusterStateRunning,
+					MasterAuth:       masterAuth,
+					ResourceLabels:   tc.cluster,
+					LabelFingerprint: "fingerprint",
+				}, nil
+			}
+			cl.MockSetLabels = func(_, cluster string, labels map[string]string, fingerprint string) error {
+				if cluster != "gke-cool" || fingerprint != "fingerprint" {
+					t.Errorf("SetLabels(...): want %s with fingerprint %s, got %s with fingerprint %s", "gke-cool", "fingerprint", cluster, fingerprint)
+				}
+				set = labels
+				return nil
+			}
+
+			rs, err := r._sync(instance, cl)
+			if err != nil {
+				t.Fatalf("r._sync(...): %s", err)
+			}
+			if diff := cmp.Diff(tc.want, rs); diff != "" {
+				t.Errorf("r._sync(...): -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantSet, set); diff != "" {
+				t.Errorf("SetLabels(...): -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantLabels, instance.Spec.ResourceLabels, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("instance.Spec.ResourceLabels: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
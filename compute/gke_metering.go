@@ -0,0 +1,84 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"google.golang.org/api/container/v1"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+)
+
+// meteringUpdate returns the cluster update required for the supplied cluster
+// to match the cost allocation and usage metering configuration of the
+// supplied spec, or nil if no update is required. GKE accepts only one desired
+// change per update, so cost allocation is converged before usage metering.
+func meteringUpdate(spec gcpcomputev1alpha1.GKEClusterSpec, cluster *container.Cluster) *container.ClusterUpdate {
+	if spec.EnableCostAllocation != costAllocationEnabled(cluster) {
+		return &container.ClusterUpdate{
+			DesiredCostManagementConfig: &container.CostManagementConfig{
+				Enabled: spec.EnableCostAllocation,
+				// Send false explicitly so that cost allocation may be disabled.
+				ForceSendFields: []string{"Enabled"},
+			},
+		}
+	}
+
+	desired := resourceUsageExportConfig(spec.ResourceUsageExport)
+	if !resourceUsageExportEqual(desired, cluster.ResourceUsageExportConfig) {
+		if desired == nil {
+			// An empty config disables usage metering.
+			desired = &container.ResourceUsageExportConfig{}
+		}
+		return &container.ClusterUpdate{DesiredResourceUsageExportConfig: desired}
+	}
+
+	return nil
+}
+
+func costAllocationEnabled(cluster *container.Cluster) bool {
+	return cluster.CostManagementConfig != nil && cluster.CostManagementConfig.Enabled
+}
+
+// resourceUsageExportConfig returns the GKE usage metering configuration
+// described by the supplied spec, or nil if usage metering is disabled.
+func resourceUsageExportConfig(spec *gcpcomputev1alpha1.ResourceUsageExportConfig) *container.ResourceUsageExportConfig {
+	if spec == nil || spec.BigQueryDatasetID == "" {
+		return nil
+	}
+	return &container.ResourceUsageExportConfig{
+		BigqueryDestination:         &container.BigQueryDestination{DatasetId: spec.BigQueryDatasetID},
+		EnableNetworkEgressMetering: spec.EnableNetworkEgressMetering,
+		ConsumptionMeteringConfig:   &container.ConsumptionMeteringConfig{Enabled: spec.EnableConsumptionMetering},
+	}
+}
+
+// resourceUsageExportEqual returns true if the desired and actual usage
+// metering configurations export the same data to the same dataset. An actual
+// configuration without a destination is equivalent to a nil one.
+func resourceUsageExportEqual(desired, actual *container.ResourceUsageExportConfig) bool {
+	if actual != nil && (actual.BigqueryDestination == nil || actual.BigqueryDestination.DatasetId == "") {
+		actual = nil
+	}
+	if desired == nil || actual == nil {
+		return desired == nil && actual == nil
+	}
+
+	consumption := actual.ConsumptionMeteringConfig != nil && actual.ConsumptionMeteringConfig.Enabled
+	return desired.BigqueryDestination.DatasetId == actual.BigqueryDestination.DatasetId &&
+		desired.EnableNetworkEgressMetering == actual.EnableNetworkEgressMetering &&
+		desired.ConsumptionMeteringConfig.Enabled == consumption
+}
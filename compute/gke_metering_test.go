@@ -0,0 +1,135 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/container/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	fakegcp "github.com/crossplaneio/crossplane/pkg/clients/gcp/fake"
+)
+
+const meteringDataset = "cool_usage"
+
+func TestMeteringUpdate(t *testing.T) {
+	metering := &gcpcomputev1alpha1.ResourceUsageExportConfig{
+		BigQueryDatasetID:         meteringDataset,
+		EnableConsumptionMetering: true,
+	}
+	exporting := &container.ResourceUsageExportConfig{
+		BigqueryDestination:       &container.BigQueryDestination{DatasetId: meteringDataset},
+		ConsumptionMeteringConfig: &container.ConsumptionMeteringConfig{Enabled: true},
+	}
+
+	cases := map[string]struct {
+		spec    gcpcomputev1alpha1.GKEClusterSpec
+		cluster *container.Cluster
+		want    *container.ClusterUpdate
+	}{
+		"Disabled": {
+			spec:    gcpcomputev1alpha1.GKEClusterSpec{},
+			cluster: &container.Cluster{},
+			want:    nil,
+		},
+		"EnableCostAllocation": {
+			spec:    gcpcomputev1alpha1.GKEClusterSpec{EnableCostAllocation: true},
+			cluster: &container.Cluster{},
+			want: &container.ClusterUpdate{
+				DesiredCostManagementConfig: &container.CostManagementConfig{Enabled: true, ForceSendFields: []string{"Enabled"}},
+			},
+		},
+		"DisableCostAllocation": {
+			spec:    gcpcomputev1alpha1.GKEClusterSpec{},
+			cluster: &container.Cluster{CostManagementConfig: &container.CostManagementConfig{Enabled: true}},
+			want: &container.ClusterUpdate{
+				DesiredCostManagementConfig: &container.CostManagementConfig{Enabled: false, ForceSendFields: []string{"Enabled"}},
+			},
+		},
+		"CostAllocationBeforeMetering": {
+			spec:    gcpcomputev1alpha1.GKEClusterSpec{EnableCostAllocation: true, ResourceUsageExport: metering},
+			cluster: &container.Cluster{},
+			want: &container.ClusterUpdate{
+				DesiredCostManagementConfig: &container.CostManagementConfig{Enabled: true, ForceSendFields: []string{"Enabled"}},
+			},
+		},
+		"EnableMetering": {
+			spec:    gcpcomputev1alpha1.GKEClusterSpec{ResourceUsageExport: metering},
+			cluster: &container.Cluster{},
+			want:    &container.ClusterUpdate{DesiredResourceUsageExportConfig: exporting},
+		},
+		"MeteringUpToDate": {
+			spec:    gcpcomputev1alpha1.GKEClusterSpec{EnableCostAllocation: true, ResourceUsageExport: metering},
+			cluster: &container.Cluster{CostManagementConfig: &container.CostManagementConfig{Enabled: true}, ResourceUsageExportConfig: exporting},
+			want:    nil,
+		},
+		"DisableMetering": {
+			spec:    gcpcomputev1alpha1.GKEClusterSpec{},
+			cluster: &container.Cluster{ResourceUsageExportConfig: exporting},
+			want:    &container.ClusterUpdate{DesiredResourceUsageExportConfig: &container.ResourceUsageExportConfig{}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := meteringUpdate(tc.spec, tc.cluster)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("meteringUpdate(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSyncMeteringUpdate(t *testing.T) {
+	instance := testCluster()
+	instance.Spec.EnableCostAllocation = true
+	instance.Status.ClusterName = "gke-cool"
+
+	r := &Reconciler{
+		Client:     fakeclient.NewFakeClient(instance),
+		kubeclient: fake.NewSimpleClientset(),
+	}
+
+	var got *container.ClusterUpdate
+	cl := fakegcp.NewGKEClient()
+	cl.MockGetCluster = func(string, string) (*container.Cluster, error) {
+		return &container.Cluster{Status: gcpcomputev1alpha1.ClusterStateRunning, MasterAuth: masterAuth}, nil
+	}
+	cl.MockUpdateCluster = func(_, name string, u *container.ClusterUpdate) error {
+		if name != "gke-cool" {
+			t.Errorf("UpdateCluster(...): want cluster gke-cool, got %s", name)
+		}
+		got = u
+		return nil
+	}
+
+	rs, err := r._sync(instance, cl)
+	if err != nil {
+		t.Fatalf("r._sync(...): %s", err)
+	}
+	if diff := cmp.Diff(reconcile.Result{RequeueAfter: requeueOnWait}, rs); diff != "" {
+		t.Errorf("r._sync(...): -want, +got:\n%s", diff)
+	}
+	if got == nil || got.DesiredCostManagementConfig == nil || !got.DesiredCostManagementConfig.Enabled {
+		t.Errorf("UpdateCluster(...): want cost allocation enabled, got %+v", got)
+	}
+}
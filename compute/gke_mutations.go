@@ -0,0 +1,239 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/gke"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/plan"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/tracing"
+)
+
+// The container API has no generic patch. Master versions, network policy,
+// maintenance policy, and resource labels may each only be changed by their
+// own mutation endpoint, so each is converged by a separate call to the
+// corresponding gke.Client method rather than by updateCluster.
+
+// floatingVersions are cluster versions that GKE resolves to a version of its
+// choosing when a cluster is created. They are never upgraded to.
+var floatingVersions = map[string]bool{"": true, "-": true, "latest": true}
+
+// networkPolicyProvider is the only network policy provider supported by GKE.
+const networkPolicyProvider = "CALICO"
+
+// datapathAdvanced is the datapath provider of GKE Dataplane V2, which
+// enforces network policy natively.
+const datapathAdvanced = "ADVANCED_DATAPATH"
+
+// maintenanceStartTime matches the start time of a daily maintenance window,
+// in the HH:MM format accepted by GKE.
+var maintenanceStartTime = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+// validateMaintenanceWindow returns an error if the supplied spec's daily
+// maintenance window would be rejected by GKE.
+func validateMaintenanceWindow(spec gcpcomputev1alpha1.GKEClusterSpec) error {
+	if spec.MaintenanceWindow == nil || maintenanceStartTime.MatchString(spec.MaintenanceWindow.DailyStartTime) {
+		return nil
+	}
+	return errors.Errorf("maintenance window start time %q must be of the form HH:MM", spec.MaintenanceWindow.DailyStartTime)
+}
+
+// masterVersionUpdate returns the master version the supplied cluster should
+// be upgraded to, if its current version is older than the supplied spec's
+// cluster version. Only the components of the spec version are compared, so
+// a spec version such as 1.27 matches any 1.27 patch version. Clusters that
+// GKE automatically upgraded past the spec version are never downgraded.
+func masterVersionUpdate(spec gcpcomputev1alpha1.GKEClusterSpec, cluster *container.Cluster) (string, bool) {
+	v := spec.ClusterVersion
+	if floatingVersions[v] || !versionOlder(cluster.CurrentMasterVersion, v) {
+		return "", false
+	}
+	return v, true
+}
+
+// parseVersion returns the numeric components of the supplied GKE version,
+// for example [1 27 3 100] for 1.27.3-gke.100.
+func parseVersion(v string) ([]int, bool) {
+	parts := strings.Split(strings.Replace(v, "-gke.", ".", 1), ".")
+	n := make([]int, len(parts))
+	for i, p := range parts {
+		c, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, false
+		}
+		n[i] = c
+	}
+	return n, true
+}
+
+// versionOlder returns true if the supplied current version is older than the
+// supplied version. Versions that cannot be parsed are older unless the
+// current version is, or is a more specific form of, the supplied version.
+func versionOlder(current, v string) bool {
+	c, cok := parseVersion(current)
+	w, wok := parseVersion(v)
+	if !cok || !wok {
+		return current != v && !strings.HasPrefix(current, v+".") && !strings.HasPrefix(current, v+"-")
+	}
+	for i := range w {
+		if i >= len(c) {
+			return true
+		}
+		if c[i] != w[i] {
+			return c[i] < w[i]
+		}
+	}
+	return false
+}
+
+func networkPolicyEnabled(cluster *container.Cluster) bool {
+	return cluster.NetworkPolicy != nil && cluster.NetworkPolicy.Enabled
+}
+
+func networkPolicyAddonEnabled(cluster *container.Cluster) bool {
+	return cluster.AddonsConfig != nil && cluster.AddonsConfig.NetworkPolicyConfig != nil && !cluster.AddonsConfig.NetworkPolicyConfig.Disabled
+}
+
+// managesNetworkPolicy returns true if the supplied spec enables or disables
+// network policy enforcement on the supplied cluster. Clusters using GKE
+// Dataplane V2 always enforce network policy, and cannot be configured.
+func managesNetworkPolicy(spec gcpcomputev1alpha1.GKEClusterSpec, cluster *container.Cluster) bool {
+	if spec.EnableNetworkPolicy == nil {
+		return false
+	}
+	return cluster.NetworkConfig == nil || cluster.NetworkConfig.DatapathProvider != datapathAdvanced
+}
+
+// networkPolicyUpdate returns the network policy the supplied cluster should
+// enforce, if it differs from that of the supplied spec. GKE requires the
+// network policy addon to be enabled before network policy is enforced, so
+// enforcement is not enabled until networkPolicyAddonUpdate has done so.
+func networkPolicyUpdate(spec gcpcomputev1alpha1.GKEClusterSpec, cluster *container.Cluster) (*container.NetworkPolicy, bool) {
+	if !managesNetworkPolicy(spec, cluster) {
+		return nil, false
+	}
+	want := *spec.EnableNetworkPolicy
+	if want == networkPolicyEnabled(cluster) || (want && !networkPolicyAddonEnabled(cluster)) {
+		return nil, false
+	}
+
+	p := &container.NetworkPolicy{Enabled: want, ForceSendFields: []string{"Enabled"}}
+	if want {
+		p.Provider = networkPolicyProvider
+	}
+	return p, true
+}
+
+// networkPolicyAddonUpdate returns the update that enables the network policy
+// addon of the supplied cluster before network policy is enforced, or that
+// disables it once network policy is no longer enforced.
+func networkPolicyAddonUpdate(spec gcpcomputev1alpha1.GKEClusterSpec, cluster *container.Cluster) *container.ClusterUpdate {
+	if !managesNetworkPolicy(spec, cluster) {
+		return nil
+	}
+	want, addon := *spec.EnableNetworkPolicy, networkPolicyAddonEnabled(cluster)
+	if want == addon || (!want && networkPolicyEnabled(cluster)) {
+		return nil
+	}
+
+	return &container.ClusterUpdate{DesiredAddonsConfig: &container.AddonsConfig{
+		NetworkPolicyConfig: &container.NetworkPolicyConfig{Disabled: !want, ForceSendFields: []string{"Disabled"}},
+	}}
+}
+
+// maintenancePolicyUpdate returns the maintenance policy of the supplied
+// cluster, if its daily maintenance window differs from that of the supplied
+// spec. The daily window replaces any recurring window set outside of the
+// GKECluster.
+func maintenancePolicyUpdate(spec gcpcomputev1alpha1.GKEClusterSpec, cluster *container.Cluster) (*container.MaintenancePolicy, bool) {
+	if spec.MaintenanceWindow == nil {
+		return nil, false
+	}
+
+	current := ""
+	mp := cluster.MaintenancePolicy
+	if mp != nil && mp.Window != nil && mp.Window.DailyMaintenanceWindow != nil {
+		current = mp.Window.DailyMaintenanceWindow.StartTime
+	}
+	if current == spec.MaintenanceWindow.DailyStartTime {
+		return nil, false
+	}
+
+	p := &container.MaintenancePolicy{Window: &container.MaintenanceWindow{
+		DailyMaintenanceWindow: &container.DailyMaintenanceWindow{StartTime: spec.MaintenanceWindow.DailyStartTime},
+	}}
+	// The resource version guards against overwriting a maintenance policy
+	// that changed since we read the cluster.
+	if mp != nil {
+		p.ResourceVersion = mp.ResourceVersion
+	}
+	return p, true
+}
+
+// mutateCluster calls the supplied mutation of the supplied cluster, which is
+// described by the supplied method and arguments when the cluster is in
+// dry-run mode. The cluster is not running while it is mutated, so we wait
+// for it before syncing the cluster again.
+func (r *Reconciler) mutateCluster(instance *gcpcomputev1alpha1.GKECluster, client gke.Client, method string, args interface{}, mutate func() error) (reconcile.Result, error) {
+	if plan.IsDryRun(instance) {
+		return r.plan(instance, plan.Describe(method, args))
+	}
+
+	span := startPhase(client, tracing.PhaseUpdate)
+	if err := tracing.End(span, mutate()); err != nil {
+		return r.fail(instance, err)
+	}
+
+	instance.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return reconcile.Result{RequeueAfter: requeueOnWait},
+		errors.Wrapf(r.Update(ctx, instance), updateErrorMessageFormat, instance.GetName())
+}
+
+// updateMasterVersion upgrades the master of the supplied cluster to the
+// supplied version. Node pools are upgraded separately by GKE's node
+// auto-upgrade.
+func (r *Reconciler) updateMasterVersion(instance *gcpcomputev1alpha1.GKECluster, client gke.Client, version string) (reconcile.Result, error) {
+	args := map[string]string{"cluster": instance.Status.ClusterName, "masterVersion": version}
+	return r.mutateCluster(instance, client, "UpdateMasterVersion", args, func() error {
+		return client.UpdateMasterVersion(instance.Spec.Zone, instance.Status.ClusterName, version)
+	})
+}
+
+// setNetworkPolicy sets the network policy enforced by the supplied cluster.
+func (r *Reconciler) setNetworkPolicy(instance *gcpcomputev1alpha1.GKECluster, client gke.Client, p *container.NetworkPolicy) (reconcile.Result, error) {
+	args := map[string]interface{}{"cluster": instance.Status.ClusterName, "networkPolicy": p}
+	return r.mutateCluster(instance, client, "SetNetworkPolicy", args, func() error {
+		return client.SetNetworkPolicy(instance.Spec.Zone, instance.Status.ClusterName, p)
+	})
+}
+
+// setMaintenancePolicy sets the maintenance policy of the supplied cluster.
+func (r *Reconciler) setMaintenancePolicy(instance *gcpcomputev1alpha1.GKECluster, client gke.Client, p *container.MaintenancePolicy) (reconcile.Result, error) {
+	args := map[string]interface{}{"cluster": instance.Status.ClusterName, "maintenancePolicy": p}
+	return r.mutateCluster(instance, client, "SetMaintenancePolicy", args, func() error {
+		return client.SetMaintenancePolicy(instance.Spec.Zone, instance.Status.ClusterName, p)
+	})
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/container/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	fakegcp "github.com/crossplaneio/crossplane/pkg/clients/gcp/fake"
+)
+
+func TestValidateMaintenanceWindow(t *testing.T) {
+	cases := map[string]struct {
+		window  *gcpcomputev1alpha1.MaintenanceWindowSpec
+		wantErr bool
+	}{
+		"Unset":        {},
+		"Valid":        {window: &gcpcomputev1alpha1.MaintenanceWindowSpec{DailyStartTime: "03:30"}},
+		"OutOfRange":   {window: &gcpcomputev1alpha1.MaintenanceWindowSpec{DailyStartTime: "24:00"}, wantErr: true},
+		"WrongFormat":  {window: &gcpcomputev1alpha1.MaintenanceWindowSpec{DailyStartTime: "3:30"}, wantErr: true},
+		"MissingStart": {window: &gcpcomputev1alpha1.MaintenanceWindowSpec{}, wantErr: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateMaintenanceWindow(gcpcomputev1alpha1.GKEClusterSpec{MaintenanceWindow: tc.window})
+			if (err != nil) != tc.wantErr {
+				t.Errorf("validateMaintenanceWindow(...): want error %t, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestMasterVersionUpdate(t *testing.T) {
+	cases := map[string]struct {
+		spec    string
+		current string
+		want    string
+		wantOK  bool
+	}{
+		"Unset":        {current: "1.27.3-gke.100"},
+		"Latest":       {spec: "latest", current: "1.27.3-gke.100"},
+		"MinorMatches": {spec: "1.27", current: "1.27.3-gke.100"},
+		"PatchMatches": {spec: "1.27.3", current: "1.27.3-gke.100"},
+		"ExactMatches": {spec: "1.27.3-gke.100", current: "1.27.3-gke.100"},
+		"MinorUpgrade": {spec: "1.28", current: "1.27.3-gke.100", want: "1.28", wantOK: true},
+		"NotAPrefixOf": {spec: "1.3", current: "1.27.3-gke.100"},
+		"NumericMinor": {spec: "1.10", current: "1.9.7-gke.1", want: "1.10", wantOK: true},
+		"PatchUpgrade": {spec: "1.27.4", current: "1.27.3-gke.100", want: "1.27.4", wantOK: true},
+		"BuildUpgrade": {spec: "1.27.3-gke.200", current: "1.27.3-gke.100", want: "1.27.3-gke.200", wantOK: true},
+		"BuildNewer":   {spec: "1.27.3-gke.50", current: "1.27.3-gke.100"},
+		"AutoUpgraded": {spec: "1.27", current: "1.28.2-gke.300"},
+		"Unparseable":  {spec: "1.27.x", current: "1.27.3-gke.100", want: "1.27.x", wantOK: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			spec := gcpcomputev1alpha1.GKEClusterSpec{ClusterVersion: tc.spec}
+			got, ok := masterVersionUpdate(spec, &container.Cluster{CurrentMasterVersion: tc.current})
+			if got != tc.want || ok != tc.wantOK {
+				t.Errorf("masterVersionUpdate(...): want %q, %t, got %q, %t", tc.want, tc.wantOK, got, ok)
+			}
+		})
+	}
+}
+
+func TestNetworkPolicyUpdates(t *testing.T) {
+	enable, disable := true, false
+	addon := func(enabled bool) *container.AddonsConfig {
+		return &container.AddonsConfig{NetworkPolicyConfig: &container.NetworkPolicyConfig{Disabled: !enabled}}
+	}
+
+	// Each case is one step of enabling or disabling network policy; the
+	// policy and addon updates must happen in the order GKE requires.
+	cases := map[string]struct {
+		spec       *bool
+		cluster    *container.Cluster
+		wantPolicy *container.NetworkPolicy
+		wantAddon  *container.ClusterUpdate
+	}{
+		"Unmanaged": {
+			cluster: &container.Cluster{AddonsConfig: addon(false)},
+		},
+		"EnableAddonFirst": {
+			spec:    &enable,
+			cluster: &container.Cluster{AddonsConfig: addon(false)},
+			wantAddon: &container.ClusterUpdate{DesiredAddonsConfig: &container.AddonsConfig{
+				NetworkPolicyConfig: &container.NetworkPolicyConfig{Disabled: false, ForceSendFields: []string{"Disabled"}},
+			}},
+		},
+		"ThenEnablePolicy": {
+			spec:       &enable,
+			cluster:    &container.Cluster{AddonsConfig: addon(true)},
+			wantPolicy: &container.NetworkPolicy{Enabled: true, Provider: networkPolicyProvider, ForceSendFields: []string{"Enabled"}},
+		},
+		"Enabled": {
+			spec:    &enable,
+			cluster: &container.Cluster{AddonsConfig: addon(true), NetworkPolicy: &container.NetworkPolicy{Enabled: true}},
+		},
+		"DisablePolicyFirst": {
+			spec:       &disable,
+			cluster:    &container.Cluster{AddonsConfig: addon(true), NetworkPolicy: &container.NetworkPolicy{Enabled: true}},
+			wantPolicy: &container.NetworkPolicy{Enabled: false, ForceSendFields: []string{"Enabled"}},
+		},
+		"ThenDisableAddon": {
+			spec:    &disable,
+			cluster: &container.Cluster{AddonsConfig: addon(true)},
+			wantAddon: &container.ClusterUpdate{DesiredAddonsConfig: &container.AddonsConfig{
+				NetworkPolicyConfig: &container.NetworkPolicyConfig{Disabled: true, ForceSendFields: []string{"Disabled"}},
+			}},
+		},
+		"DataplaneV2": {
+			spec:    &enable,
+			cluster: &container.Cluster{NetworkConfig: &container.NetworkConfig{DatapathProvider: datapathAdvanced}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			spec := gcpcomputev1alpha1.GKEClusterSpec{EnableNetworkPolicy: tc.spec}
+
+			gotPolicy, ok := networkPolicyUpdate(spec, tc.cluster)
+			if ok != (tc.wantPolicy != nil) {
+				t.Errorf("networkPolicyUpdate(...): want ok %t, got %t", tc.wantPolicy != nil, ok)
+			}
+			if diff := cmp.Diff(tc.wantPolicy, gotPolicy); diff != "" {
+				t.Errorf("networkPolicyUpdate(...): -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantAddon, networkPolicyAddonUpdate(spec, tc.cluster)); diff != "" {
+				t.Errorf("networkPolicyAddonUpdate(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMaintenancePolicyUpdate(t *testing.T) {
+	daily := func(start string) *container.MaintenancePolicy {
+		return &container.MaintenancePolicy{
+			ResourceVersion: "cool-version",
+			Window:          &container.MaintenanceWindow{DailyMaintenanceWindow: &container.DailyMaintenanceWindow{StartTime: start}},
+		}
+	}
+
+	cases := map[string]struct {
+		window  *gcpcomputev1alpha1.MaintenanceWindowSpec
+		current *container.MaintenancePolicy
+		want    *container.MaintenancePolicy
+	}{
+		"Unmanaged": {current: daily("03:00")},
+		"Unchanged": {
+			window:  &gcpcomputev1alpha1.MaintenanceWindowSpec{DailyStartTime: "03:00"},
+			current: daily("03:00"),
+		},
+		"Changed": {
+			window:  &gcpcomputev1alpha1.MaintenanceWindowSpec{DailyStartTime: "04:00"},
+			current: daily("03:00"),
+			want:    daily("04:00"),
+		},
+		"NoPolicy": {
+			window: &gcpcomputev1alpha1.MaintenanceWindowSpec{DailyStartTime: "04:00"},
+			want: &container.MaintenancePolicy{
+				Window: &container.MaintenanceWindow{DailyMaintenanceWindow: &container.DailyMaintenanceWindow{StartTime: "04:00"}},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			spec := gcpcomputev1alpha1.GKEClusterSpec{MaintenanceWindow: tc.window}
+			got, ok := maintenancePolicyUpdate(spec, &container.Cluster{MaintenancePolicy: tc.current})
+			if ok != (tc.want != nil) {
+				t.Errorf("maintenancePolicyUpdate(...): want ok %t, got %t", tc.want != nil, ok)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("maintenancePolicyUpdate(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSyncMasterVersion(t *testing.T) {
+	instance := testCluster()
+	instance.Spec.ClusterVersion = "1.28"
+	instance.Status.ClusterName = "gke-cool"
+
+	r := &Reconciler{
+		Client:     fakeclient.NewFakeClient(instance),
+		kubeclient: fake.NewSimpleClientset(),
+	}
+
+	upgraded := ""
+	cl := fakegcp.NewGKEClient()
+	cl.MockGetCluster = func(string, string) (*container.Cluster, error) {
+		return &container.Cluster{
+			Status:               gcpcomputev1alpha1.ClusterStateRunning,
+			MasterAuth:           masterAuth,
+			CurrentMasterVersion: "1.27.3-gke.100",
+		}, nil
+	}
+	cl.MockUpdateMasterVersion = func(_, cluster, version string) error {
+		if cluster != "gke-cool" {
+			t.Errorf("UpdateMasterVersion(...): want cluster %s, got %s", "gke-cool", cluster)
+		}
+		upgraded = version
+		return nil
+	}
+
+	rs, err := r._sync(instance, cl)
+	if err != nil {
+		t.Fatalf("r._sync(...): %s", err)
+	}
+	if diff := cmp.Diff(reconcile.Result{RequeueAfter: requeueOnWait}, rs); diff != "" {
+		t.Errorf("r._sync(...): -want, +got:\n%s", diff)
+	}
+	if upgraded != "1.28" {
+		t.Errorf("UpdateMasterVersion(...): want version %s, got %q", "1.28", upgraded)
+	}
+}
+
+func TestSyncMasterVersionAutoUpgraded(t *testing.T) {
+	instance := testCluster()
+	instance.Spec.ClusterVersion = "1.27"
+	instance.Status.ClusterName = "gke-cool"
+
+	r := &Reconciler{
+		Client:     fakeclient.NewFakeClient(instance),
+		kubeclient: fake.NewSimpleClientset(),
+	}
+
+	cl := fakegcp.NewGKEClient()
+	cl.MockGetCluster = func(string, string) (*container.Cluster, error) {
+		return &container.Cluster{
+			Status:               gcpcomputev1alpha1.ClusterStateRunning,
+			MasterAuth:           masterAuth,
+			CurrentMasterVersion: "1.28.2-gke.300",
+		}, nil
+	}
+	cl.MockUpdateMasterVersion = func(_, _, version string) error {
+		t.Errorf("UpdateMasterVersion(...): unexpected downgrade to %s", version)
+		return nil
+	}
+
+	if _, err := r._sync(instance, cl); err != nil {
+		t.Fatalf("r._sync(...): %s", err)
+	}
+	if instance.Status.State != gcpcomputev1alpha1.ClusterStateRunning {
+		t.Errorf("instance.Status.State: want %s, got %s", gcpcomputev1alpha1.ClusterStateRunning, instance.Status.State)
+	}
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/container/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/gke"
+)
+
+// defaultNetworkObservability enables intranode visibility on a new cluster
+// whose spec doesn't configure it, so that pod to pod traffic on the same
+// node appears in VPC flow logs. VPC flow logs are enabled too if the cluster
+// creates its own subnetwork; subnetworks that the cluster shares with other
+// workloads are left as they are unless the spec configures flow logs.
+// Existing clusters are left as they are.
+func defaultNetworkObservability(spec *gcpcomputev1alpha1.GKEClusterSpec) {
+	if spec.EnableIntraNodeVisibility == nil {
+		enabled := true
+		spec.EnableIntraNodeVisibility = &enabled
+	}
+	if spec.EnableFlowLogs == nil && spec.CreateSubnetwork {
+		enabled := true
+		spec.EnableFlowLogs = &enabled
+	}
+}
+
+// networkUpdate returns the cluster update required for the supplied cluster
+// to match the intranode visibility of the supplied spec, or nil if no update
+// is required. Intranode visibility is not managed if the spec doesn't
+// configure it.
+func networkUpdate(spec gcpcomputev1alpha1.GKEClusterSpec, cluster *container.Cluster) *container.ClusterUpdate {
+	if spec.EnableIntraNodeVisibility == nil || *spec.EnableIntraNodeVisibility == intraNodeVisibilityEnabled(cluster) {
+		return nil
+	}
+	return &container.ClusterUpdate{
+		DesiredIntraNodeVisibilityConfig: &container.IntraNodeVisibilityConfig{
+			Enabled: *spec.EnableIntraNodeVisibility,
+			// Send false explicitly so that intranode visibility may be disabled.
+			ForceSendFields: []string{"Enabled"},
+		},
+	}
+}
+
+func intraNodeVisibilityEnabled(cluster *container.Cluster) bool {
+	return cluster.NetworkConfig != nil && cluster.NetworkConfig.EnableIntraNodeVisibility
+}
+
+// A subnetworkClient reads and patches the VPC subnetworks of GKE clusters.
+type subnetworkClient interface {
+	Get(ctx context.Context, project, region, name string) (*compute.Subnetwork, error)
+	Patch(ctx context.Context, project, region, name string, s *compute.Subnetwork) error
+}
+
+// computeSubnetworks is a subnetworkClient using the GCP Compute API.
+type computeSubnetworks struct {
+	service *compute.Service
+}
+
+// Get the named subnetwork.
+func (c *computeSubnetworks) Get(ctx context.Context, project, region, name string) (*compute.Subnetwork, error) {
+	return c.service.Subnetworks.Get(project, region, name).Context(ctx).Do()
+}
+
+// Patch the named subnetwork.
+func (c *computeSubnetworks) Patch(ctx context.Context, project, region, name string, s *compute.Subnetwork) error {
+	_, err := c.service.Subnetworks.Patch(project, region, name, s).Context(ctx).Do()
+	return err
+}
+
+// _subnetworks returns a subnetworkClient authenticated using the credentials
+// of the Provider referenced by the supplied cluster.
+func (r *Reconciler) _subnetworks(instance *gcpcomputev1alpha1.GKECluster) (subnetworkClient, error) {
+	s, _, err := r.computeService(instance, compute.ComputeScope)
+	if err != nil {
+		return nil, err
+	}
+	return &computeSubnetworks{service: s}, nil
+}
+
+// A subnetworkPatch is a patch to the subnetwork of a GKE cluster.
+type subnetworkPatch struct {
+	subnetworks subnetworkClient
+	project     string
+	region      string
+	name        string
+	patch       *compute.Subnetwork
+}
+
+// flowLogsPatch returns the patch required for the subnetwork of the supplied
+// cluster to match the VPC flow logs of the supplied cluster's spec, or nil if
+// no patch is required. Flow logs are not managed if the spec doesn't
+// configure them.
+func (r *Reconciler) flowLogsPatch(instance *gcpcomputev1alpha1.GKECluster, cluster *container.Cluster) (*subnetworkPatch, error) {
+	if r.subnetworks == nil || instance.Spec.EnableFlowLogs == nil {
+		return nil, nil
+	}
+	if cluster.NetworkConfig == nil || cluster.NetworkConfig.Subnetwork == "" {
+		return nil, errors.New("cannot configure VPC flow logs: cluster has no subnetwork")
+	}
+	project, region, name, err := parseSubnetworkPath(cluster.NetworkConfig.Subnetwork)
+	if err != nil {
+		return nil, err
+	}
+
+	subnetworks, err := r.subnetworks(instance)
+	if err != nil {
+		return nil, err
+	}
+	s, err := subnetworks.Get(ctx, project, region, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get subnetwork %s", name)
+	}
+
+	enabled := *instance.Spec.EnableFlowLogs
+	if enabled == flowLogsEnabled(s) {
+		return nil, nil
+	}
+	return &subnetworkPatch{
+		subnetworks: subnetworks,
+		project:     project,
+		region:      region,
+		name:        name,
+		patch: &compute.Subnetwork{
+			// The fingerprint guards against overwriting changes made to the
+			// subnetwork since we read it.
+			Fingerprint: s.Fingerprint,
+			// Send false explicitly so that flow logs may be disabled.
+			LogConfig: &compute.SubnetworkLogConfig{Enable: enabled, ForceSendFields: []string{"Enable"}},
+		},
+	}, nil
+}
+
+// patchSubnetwork applies the supplied patch to the subnetwork of the supplied
+// cluster.
+func (r *Reconciler) patchSubnetwork(instance *gcpcomputev1alpha1.GKECluster, client gke.Client, p *subnetworkPatch) (reconcile.Result, error) {
+	args := map[string]interface{}{"project": p.project, "region": p.region, "subnetwork": p.name, "logConfig": p.patch.LogConfig}
+	return r.mutateCluster(instance, client, "PatchSubnetwork", args, func() error {
+		return p.subnetworks.Patch(ctx, p.project, p.region, p.name, p.patch)
+	})
+}
+
+// parseSubnetworkPath returns the project, region and name of the supplied
+// subnetwork path, which GKE reports in the form
+// projects/{project}/regions/{region}/subnetworks/{name}. The subnetwork may
+// belong to a Shared VPC host project rather than the cluster's project.
+func parseSubnetworkPath(path string) (project, region, name string, err error) {
+	parts := strings.Split(path, "/")
+	for i := 0; i+1 < len(parts); i++ {
+		switch parts[i] {
+		case "projects":
+			project = parts[i+1]
+		case "regions":
+			region = parts[i+1]
+		case "subnetworks":
+			name = parts[i+1]
+		}
+	}
+	if project == "" || region == "" || name == "" {
+		return "", "", "", errors.Errorf("invalid subnetwork %q", path)
+	}
+	return project, region, name, nil
+}
+
+func flowLogsEnabled(s *compute.Subnetwork) bool {
+	return s.LogConfig != nil && s.LogConfig.Enable
+}
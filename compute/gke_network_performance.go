@@ -0,0 +1,130 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+)
+
+// The total egress bandwidth tiers supported by GKE node pools. TIER_1
+// networking raises the egress bandwidth of each node to as much as 100 Gbps,
+// depending on its machine type.
+const (
+	bandwidthTier1           = "TIER_1"
+	bandwidthTierUnspecified = "TIER_UNSPECIFIED"
+)
+
+// tier1MachineFamilies are the machine families that support TIER_1
+// networking.
+var tier1MachineFamilies = []string{"c2", "c2d", "c3", "c3d", "m3", "n2", "n2d", "z3"}
+
+// validateNetworkPerformance returns an error if the supplied node pool asks
+// for a bandwidth tier that GKE does not support, or for TIER_1 networking on
+// a machine type that does not support it.
+func validateNetworkPerformance(np gcpcomputev1alpha1.NodePoolSpec) error {
+	if np.NetworkPerformanceConfig == nil {
+		return nil
+	}
+
+	switch t := strings.ToUpper(np.NetworkPerformanceConfig.TotalEgressBandwidthTier); t {
+	case bandwidthTierUnspecified:
+		return nil
+	case bandwidthTier1:
+	default:
+		return errors.Errorf("total egress bandwidth tier %q is not supported; use %s or %s",
+			np.NetworkPerformanceConfig.TotalEgressBandwidthTier, bandwidthTier1, bandwidthTierUnspecified)
+	}
+
+	// GKE defaults node pools to the e2-medium machine type, which does not
+	// support TIER_1 networking.
+	if np.MachineType == "" {
+		return errors.Errorf("%s networking requires a machineType", bandwidthTier1)
+	}
+	if !supportsTier1Networking(np.MachineType) {
+		return errors.Errorf("machine type %q does not support %s networking; use one of the %s machine families",
+			np.MachineType, bandwidthTier1, strings.Join(tier1MachineFamilies, ", "))
+	}
+	return nil
+}
+
+func supportsTier1Networking(machineType string) bool {
+	f := machineFamily(machineType)
+	for _, tf := range tier1MachineFamilies {
+		if f == tf {
+			return true
+		}
+	}
+	return false
+}
+
+// nodePoolNetworkPerformanceUpdate returns the name of the next node pool of
+// the supplied cluster whose total egress bandwidth tier differs from the
+// supplied spec, and the request that updates it. It returns false if every
+// node pool is up to date. Node pools that are yet to be created are ignored.
+func nodePoolNetworkPerformanceUpdate(spec gcpcomputev1alpha1.GKEClusterSpec, cluster *container.Cluster) (string, *container.UpdateNodePoolRequest, bool) {
+	for _, np := range spec.NodePools {
+		if np.NetworkPerformanceConfig == nil {
+			continue
+		}
+		actual := nodePool(cluster, np.Name)
+		if actual == nil {
+			continue
+		}
+
+		desired := normalizeBandwidthTier(np.NetworkPerformanceConfig.TotalEgressBandwidthTier)
+		if desired == totalEgressBandwidthTier(actual) {
+			continue
+		}
+
+		// The node version and image type are required, so we send the
+		// node pool's current version and image type.
+		u := &container.UpdateNodePoolRequest{
+			NodeVersion: actual.Version,
+			NodeNetworkConfig: &container.NodeNetworkConfig{
+				NetworkPerformanceConfig: &container.NetworkPerformanceConfig{TotalEgressBandwidthTier: desired},
+			},
+		}
+		if actual.Config != nil {
+			u.ImageType = actual.Config.ImageType
+		}
+		return np.Name, u, true
+	}
+	return "", nil, false
+}
+
+// totalEgressBandwidthTier returns the total egress bandwidth tier of the
+// supplied node pool.
+func totalEgressBandwidthTier(np *container.NodePool) string {
+	if np.NetworkConfig == nil || np.NetworkConfig.NetworkPerformanceConfig == nil {
+		return bandwidthTierUnspecified
+	}
+	return normalizeBandwidthTier(np.NetworkConfig.NetworkPerformanceConfig.TotalEgressBandwidthTier)
+}
+
+// normalizeBandwidthTier returns the supplied bandwidth tier in upper case,
+// treating an empty tier as unspecified.
+func normalizeBandwidthTier(t string) string {
+	if t == "" {
+		return bandwidthTierUnspecified
+	}
+	return strings.ToUpper(t)
+}
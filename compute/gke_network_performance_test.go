@@ -0,0 +1,162 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestValidateNetworkPerformance(t *testing.T) {
+	cases := map[string]struct {
+		np   gcpcomputev1alpha1.NodePoolSpec
+		want error
+	}{
+		"Unset": {},
+		"Tier1": {
+			np: gcpcomputev1alpha1.NodePoolSpec{
+				MachineType:              "n2-standard-32",
+				NetworkPerformanceConfig: &gcpcomputev1alpha1.NetworkPerformanceConfig{TotalEgressBandwidthTier: "tier_1"},
+			},
+		},
+		"Unspecified": {
+			np: gcpcomputev1alpha1.NodePoolSpec{
+				NetworkPerformanceConfig: &gcpcomputev1alpha1.NetworkPerformanceConfig{TotalEgressBandwidthTier: bandwidthTierUnspecified},
+			},
+		},
+		"UnknownTier": {
+			np: gcpcomputev1alpha1.NodePoolSpec{
+				MachineType:              "n2-standard-32",
+				NetworkPerformanceConfig: &gcpcomputev1alpha1.NetworkPerformanceConfig{TotalEgressBandwidthTier: "TIER_2"},
+			},
+			want: errors.New(`total egress bandwidth tier "TIER_2" is not supported; use TIER_1 or TIER_UNSPECIFIED`),
+		},
+		"DefaultMachineType": {
+			np: gcpcomputev1alpha1.NodePoolSpec{
+				NetworkPerformanceConfig: &gcpcomputev1alpha1.NetworkPerformanceConfig{TotalEgressBandwidthTier: bandwidthTier1},
+			},
+			want: errors.New("TIER_1 networking requires a machineType"),
+		},
+		"UnsupportedMachineFamily": {
+			np: gcpcomputev1alpha1.NodePoolSpec{
+				MachineType:              "e2-standard-32",
+				NetworkPerformanceConfig: &gcpcomputev1alpha1.NetworkPerformanceConfig{TotalEgressBandwidthTier: bandwidthTier1},
+			},
+			want: errors.New(`machine type "e2-standard-32" does not support TIER_1 networking; use one of the c2, c2d, c3, c3d, m3, n2, n2d, z3 machine families`),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := validateNetworkPerformance(tc.np)
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("validateNetworkPerformance(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNodePoolNetworkPerformanceUpdate(t *testing.T) {
+	pool := func(tier string) *container.NodePool {
+		np := &container.NodePool{Name: "cool-pool", Version: "1.14.7-gke.14", Config: &container.NodeConfig{ImageType: "COS_CONTAINERD"}}
+		if tier != "" {
+			np.NetworkConfig = &container.NodeNetworkConfig{
+				NetworkPerformanceConfig: &container.NetworkPerformanceConfig{TotalEgressBandwidthTier: tier},
+			}
+		}
+		return np
+	}
+	tier := func(t string) *gcpcomputev1alpha1.NetworkPerformanceConfig {
+		return &gcpcomputev1alpha1.NetworkPerformanceConfig{TotalEgressBandwidthTier: t}
+	}
+
+	type want struct {
+		name   string
+		update *container.UpdateNodePoolRequest
+		ok     bool
+	}
+
+	cases := map[string]struct {
+		np      gcpcomputev1alpha1.NodePoolSpec
+		cluster *container.Cluster
+		want    want
+	}{
+		"NotSpecified": {
+			np:      gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool"},
+			cluster: &container.Cluster{NodePools: []*container.NodePool{pool(bandwidthTier1)}},
+		},
+		"NodePoolNotCreated": {
+			np:      gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool", NetworkPerformanceConfig: tier(bandwidthTier1)},
+			cluster: &container.Cluster{},
+		},
+		"UpToDate": {
+			np:      gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool", NetworkPerformanceConfig: tier("tier_1")},
+			cluster: &container.Cluster{NodePools: []*container.NodePool{pool(bandwidthTier1)}},
+		},
+		"UnspecifiedUpToDate": {
+			np:      gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool", NetworkPerformanceConfig: tier(bandwidthTierUnspecified)},
+			cluster: &container.Cluster{NodePools: []*container.NodePool{pool("")}},
+		},
+		"EnableTier1": {
+			np:      gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool", NetworkPerformanceConfig: tier(bandwidthTier1)},
+			cluster: &container.Cluster{NodePools: []*container.NodePool{pool("")}},
+			want: want{
+				name: "cool-pool",
+				update: &container.UpdateNodePoolRequest{
+					NodeVersion: "1.14.7-gke.14",
+					ImageType:   "COS_CONTAINERD",
+					NodeNetworkConfig: &container.NodeNetworkConfig{
+						NetworkPerformanceConfig: &container.NetworkPerformanceConfig{TotalEgressBandwidthTier: bandwidthTier1},
+					},
+				},
+				ok: true,
+			},
+		},
+		"DisableTier1": {
+			np:      gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool", NetworkPerformanceConfig: tier(bandwidthTierUnspecified)},
+			cluster: &container.Cluster{NodePools: []*container.NodePool{pool(bandwidthTier1)}},
+			want: want{
+				name: "cool-pool",
+				update: &container.UpdateNodePoolRequest{
+					NodeVersion: "1.14.7-gke.14",
+					ImageType:   "COS_CONTAINERD",
+					NodeNetworkConfig: &container.NodeNetworkConfig{
+						NetworkPerformanceConfig: &container.NetworkPerformanceConfig{TotalEgressBandwidthTier: bandwidthTierUnspecified},
+					},
+				},
+				ok: true,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			spec := gcpcomputev1alpha1.GKEClusterSpec{NodePools: []gcpcomputev1alpha1.NodePoolSpec{tc.np}}
+			np, u, ok := nodePoolNetworkPerformanceUpdate(spec, tc.cluster)
+			got := want{name: np, update: u, ok: ok}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("nodePoolNetworkPerformanceUpdate(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
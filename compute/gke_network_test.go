@@ -0,0 +1,270 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/container/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	fakegcp "github.com/crossplaneio/crossplane/pkg/clients/gcp/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestNetworkUpdate(t *testing.T) {
+	enabled, disabled := true, false
+
+	cases := map[string]struct {
+		spec    gcpcomputev1alpha1.GKEClusterSpec
+		cluster *container.Cluster
+		want    *container.ClusterUpdate
+	}{
+		"Unmanaged": {
+			spec:    gcpcomputev1alpha1.GKEClusterSpec{},
+			cluster: &container.Cluster{NetworkConfig: &container.NetworkConfig{EnableIntraNodeVisibility: true}},
+			want:    nil,
+		},
+		"Enable": {
+			spec:    gcpcomputev1alpha1.GKEClusterSpec{EnableIntraNodeVisibility: &enabled},
+			cluster: &container.Cluster{},
+			want: &container.ClusterUpdate{
+				DesiredIntraNodeVisibilityConfig: &container.IntraNodeVisibilityConfig{Enabled: true, ForceSendFields: []string{"Enabled"}},
+			},
+		},
+		"Disable": {
+			spec:    gcpcomputev1alpha1.GKEClusterSpec{EnableIntraNodeVisibility: &disabled},
+			cluster: &container.Cluster{NetworkConfig: &container.NetworkConfig{EnableIntraNodeVisibility: true}},
+			want: &container.ClusterUpdate{
+				DesiredIntraNodeVisibilityConfig: &container.IntraNodeVisibilityConfig{Enabled: false, ForceSendFields: []string{"Enabled"}},
+			},
+		},
+		"UpToDate": {
+			spec:    gcpcomputev1alpha1.GKEClusterSpec{EnableIntraNodeVisibility: &enabled},
+			cluster: &container.Cluster{NetworkConfig: &container.NetworkConfig{EnableIntraNodeVisibility: true}},
+			want:    nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := networkUpdate(tc.spec, tc.cluster)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("networkUpdate(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCreateDefaultsIntraNodeVisibility(t *testing.T) {
+	disabled := false
+
+	cases := map[string]struct {
+		spec *bool
+		want bool
+	}{
+		"Default":  {spec: nil, want: true},
+		"Disabled": {spec: &disabled, want: false},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			instance := testCluster()
+			instance.Spec.EnableIntraNodeVisibility = tc.spec
+
+			r := &Reconciler{
+				Client:     fakeclient.NewFakeClient(instance),
+				kubeclient: fake.NewSimpleClientset(),
+			}
+
+			var got *bool
+			cl := fakegcp.NewGKEClient()
+			cl.MockCreateCluster = func(_ string, spec gcpcomputev1alpha1.GKEClusterSpec) (*container.Cluster, error) {
+				got = spec.EnableIntraNodeVisibility
+				return nil, nil
+			}
+
+			if _, err := r._create(instance, cl); err != nil {
+				t.Fatalf("r._create(...): %s", err)
+			}
+			if got == nil || *got != tc.want {
+				t.Errorf("CreateCluster(...): want intranode visibility %t, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDefaultNetworkObservability(t *testing.T) {
+	enabled, disabled := true, false
+
+	cases := map[string]struct {
+		spec gcpcomputev1alpha1.GKEClusterSpec
+		want gcpcomputev1alpha1.GKEClusterSpec
+	}{
+		"SharedSubnetwork": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{},
+			want: gcpcomputev1alpha1.GKEClusterSpec{EnableIntraNodeVisibility: &enabled},
+		},
+		"CreatedSubnetwork": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{CreateSubnetwork: true},
+			want: gcpcomputev1alpha1.GKEClusterSpec{CreateSubnetwork: true, EnableIntraNodeVisibility: &enabled, EnableFlowLogs: &enabled},
+		},
+		"Configured": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{CreateSubnetwork: true, EnableIntraNodeVisibility: &disabled, EnableFlowLogs: &disabled},
+			want: gcpcomputev1alpha1.GKEClusterSpec{CreateSubnetwork: true, EnableIntraNodeVisibility: &disabled, EnableFlowLogs: &disabled},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			defaultNetworkObservability(&tc.spec)
+			if diff := cmp.Diff(tc.want, tc.spec); diff != "" {
+				t.Errorf("defaultNetworkObservability(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+type fakeSubnetworks struct {
+	subnetwork *compute.Subnetwork
+	err        error
+}
+
+func (f *fakeSubnetworks) Get(_ context.Context, _, _, _ string) (*compute.Subnetwork, error) {
+	return f.subnetwork, f.err
+}
+
+func (f *fakeSubnetworks) Patch(_ context.Context, _, _, _ string, _ *compute.Subnetwork) error {
+	return f.err
+}
+
+func TestFlowLogsPatch(t *testing.T) {
+	enabled, disabled := true, false
+	errBoom := errors.New("boom")
+	path := "projects/cool-host/regions/us-central1/subnetworks/cool-subnet"
+	cluster := &container.Cluster{NetworkConfig: &container.NetworkConfig{Subnetwork: path}}
+
+	cases := map[string]struct {
+		spec        *bool
+		cluster     *container.Cluster
+		subnetworks *fakeSubnetworks
+		want        *compute.Subnetwork
+		wantErr     error
+	}{
+		"Unmanaged": {
+			cluster:     cluster,
+			subnetworks: &fakeSubnetworks{subnetwork: &compute.Subnetwork{}},
+		},
+		"Enable": {
+			spec:        &enabled,
+			cluster:     cluster,
+			subnetworks: &fakeSubnetworks{subnetwork: &compute.Subnetwork{Fingerprint: "abc"}},
+			want: &compute.Subnetwork{
+				Fingerprint: "abc",
+				LogConfig:   &compute.SubnetworkLogConfig{Enable: true, ForceSendFields: []string{"Enable"}},
+			},
+		},
+		"Disable": {
+			spec:        &disabled,
+			cluster:     cluster,
+			subnetworks: &fakeSubnetworks{subnetwork: &compute.Subnetwork{Fingerprint: "abc", LogConfig: &compute.SubnetworkLogConfig{Enable: true}}},
+			want: &compute.Subnetwork{
+				Fingerprint: "abc",
+				LogConfig:   &compute.SubnetworkLogConfig{Enable: false, ForceSendFields: []string{"Enable"}},
+			},
+		},
+		"UpToDate": {
+			spec:        &enabled,
+			cluster:     cluster,
+			subnetworks: &fakeSubnetworks{subnetwork: &compute.Subnetwork{LogConfig: &compute.SubnetworkLogConfig{Enable: true}}},
+		},
+		"NoSubnetwork": {
+			spec:        &enabled,
+			cluster:     &container.Cluster{},
+			subnetworks: &fakeSubnetworks{},
+			wantErr:     errors.New("cannot configure VPC flow logs: cluster has no subnetwork"),
+		},
+		"GetError": {
+			spec:        &enabled,
+			cluster:     cluster,
+			subnetworks: &fakeSubnetworks{err: errBoom},
+			wantErr:     errors.Wrap(errBoom, "cannot get subnetwork cool-subnet"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			instance := testCluster()
+			instance.Spec.EnableFlowLogs = tc.spec
+			r := &Reconciler{subnetworks: func(*gcpcomputev1alpha1.GKECluster) (subnetworkClient, error) { return tc.subnetworks, nil }}
+
+			got, err := r.flowLogsPatch(instance, tc.cluster)
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("r.flowLogsPatch(...): -want error, +got error:\n%s", diff)
+			}
+			var patch *compute.Subnetwork
+			if got != nil {
+				if got.project != "cool-host" || got.region != "us-central1" || got.name != "cool-subnet" {
+					t.Errorf("r.flowLogsPatch(...): want cool-host/us-central1/cool-subnet, got %s/%s/%s", got.project, got.region, got.name)
+				}
+				patch = got.patch
+			}
+			if diff := cmp.Diff(tc.want, patch); diff != "" {
+				t.Errorf("r.flowLogsPatch(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseSubnetworkPath(t *testing.T) {
+	cases := map[string]struct {
+		path    string
+		project string
+		region  string
+		name    string
+		wantErr bool
+	}{
+		"Valid": {
+			path:    "projects/cool-host/regions/us-central1/subnetworks/cool-subnet",
+			project: "cool-host",
+			region:  "us-central1",
+			name:    "cool-subnet",
+		},
+		"Invalid": {
+			path:    "cool-subnet",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			project, region, n, err := parseSubnetworkPath(tc.path)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseSubnetworkPath(...): want error %t, got %v", tc.wantErr, err)
+			}
+			if project != tc.project || region != tc.region || n != tc.name {
+				t.Errorf("parseSubnetworkPath(...): want %s/%s/%s, got %s/%s/%s", tc.project, tc.region, tc.name, project, region, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+)
+
+// The CPU manager policies supported by the GKE kubelet.
+const (
+	cpuManagerPolicyNone   = "none"
+	cpuManagerPolicyStatic = "static"
+)
+
+// The modes in which the metadata server is exposed to a node pool's
+// workloads. GKE_METADATA runs the GKE metadata server, which Workload
+// Identity requires; GCE_METADATA exposes the Compute Engine metadata server,
+// including the node's service account credentials.
+const (
+	workloadMetadataGKE = "GKE_METADATA"
+	workloadMetadataGCE = "GCE_METADATA"
+)
+
+// legacyEndpointsMetadataKey is the node metadata key that disables the
+// Compute Engine metadata server's legacy v0.1 and v1beta1 endpoints, which
+// do not require the Metadata-Flavor header and so are open to SSRF.
+const legacyEndpointsMetadataKey = "disable-legacy-endpoints"
+
+// dockerImageTypes are the node image types that use the Docker runtime,
+// which GKE no longer supports. Each maps to its containerd equivalent.
+var dockerImageTypes = map[string]string{
+	"COS":    "COS_CONTAINERD",
+	"UBUNTU": "UBUNTU_CONTAINERD",
+}
+
+// validateNodeConfig returns an error if the supplied node pool's image type,
+// Linux node configuration, or kubelet configuration would be rejected by GKE.
+func validateNodeConfig(np gcpcomputev1alpha1.NodePoolSpec) error {
+	it := strings.ToUpper(np.ImageType)
+	if c, ok := dockerImageTypes[it]; ok {
+		return errors.Errorf("image type %q uses the Docker runtime, which GKE no longer supports; use %s", np.ImageType, c)
+	}
+
+	if np.LinuxNodeConfig != nil && strings.HasPrefix(it, "WINDOWS") {
+		return errors.Errorf("linuxNodeConfig is not supported by image type %q", np.ImageType)
+	}
+
+	if kc := np.KubeletConfig; kc != nil {
+		switch kc.CPUManagerPolicy {
+		case "", cpuManagerPolicyNone, cpuManagerPolicyStatic:
+		default:
+			return errors.Errorf("CPU manager policy %q is not supported; use %s or %s", kc.CPUManagerPolicy, cpuManagerPolicyNone, cpuManagerPolicyStatic)
+		}
+		if kc.CPUCFSQuotaPeriod != "" {
+			if _, err := time.ParseDuration(kc.CPUCFSQuotaPeriod); err != nil {
+				return errors.Errorf("CPU CFS quota period %q must be a duration, e.g. 100ms", kc.CPUCFSQuotaPeriod)
+			}
+		}
+		if kc.PodPIDsLimit != 0 && (kc.PodPIDsLimit < 1024 || kc.PodPIDsLimit > 4194304) {
+			return errors.Errorf("pod PIDs limit %d must be between 1024 and 4194304", kc.PodPIDsLimit)
+		}
+	}
+
+	switch strings.ToUpper(np.WorkloadMetadataMode) {
+	case "", workloadMetadataGKE, workloadMetadataGCE:
+	default:
+		// The SECURE and EXPOSE modes of metadata concealment were removed
+		// from the v1 API in favour of Workload Identity.
+		return errors.Errorf("workload metadata mode %q is not supported; use %s or %s", np.WorkloadMetadataMode, workloadMetadataGKE, workloadMetadataGCE)
+	}
+
+	return nil
+}
+
+// validateWorkloadMetadata returns an error if the supplied cluster enables
+// Workload Identity but a node pool does not run the GKE metadata server.
+// Pods on such a node pool silently authenticate as the node's service account
+// rather than their Kubernetes service account.
+func validateWorkloadMetadata(spec gcpcomputev1alpha1.GKEClusterSpec) error {
+	if spec.WorkloadPool == "" {
+		return nil
+	}
+	for _, np := range spec.NodePools {
+		if !strings.EqualFold(np.WorkloadMetadataMode, workloadMetadataGKE) {
+			return errors.Errorf("node pool %q must set workloadMetadataMode to %s, because the cluster enables Workload Identity", np.Name, workloadMetadataGKE)
+		}
+	}
+	return nil
+}
+
+// legacyEndpointsEnabled returns true if the supplied node configuration
+// leaves the legacy metadata server endpoints enabled.
+func legacyEndpointsEnabled(c *container.NodeConfig) bool {
+	return c.Metadata[legacyEndpointsMetadataKey] != "true"
+}
+
+// desiredLinuxNodeConfig returns the GKE Linux node configuration described
+// by the supplied configuration.
+func desiredLinuxNodeConfig(c *gcpcomputev1alpha1.LinuxNodeConfig) *container.LinuxNodeConfig {
+	return &container.LinuxNodeConfig{Sysctls: c.Sysctls}
+}
+
+// desiredKubeletConfig returns the GKE kubelet configuration described by the
+// supplied configuration.
+func desiredKubeletConfig(c *gcpcomputev1alpha1.KubeletConfig) *container.NodeKubeletConfig {
+	d := &container.NodeKubeletConfig{
+		CpuManagerPolicy:  c.CPUManagerPolicy,
+		CpuCfsQuotaPeriod: c.CPUCFSQuotaPeriod,
+		PodPidsLimit:      c.PodPIDsLimit,
+	}
+	if c.CPUCFSQuota != nil {
+		d.CpuCfsQuota = *c.CPUCFSQuota
+		// Send false explicitly so that CPU CFS quota enforcement may be
+		// disabled.
+		d.ForceSendFields = []string{"CpuCfsQuota"}
+	}
+	return d
+}
+
+// nodePoolConfigUpdate returns the name of the next node pool of the supplied
+// cluster whose image type, Linux node configuration, kubelet configuration,
+// or workload metadata mode differs from the supplied spec, and the request that updates it. It returns
+// false if every node pool is up to date. Node pools that are yet to be created
+// are ignored.
+//
+// GKE applies each of these changes by recreating the node pool's nodes, so we
+// update only one of them at a time. Fields that are not specified are left
+// as GKE defaulted them.
+func nodePoolConfigUpdate(spec gcpcomputev1alpha1.GKEClusterSpec, cluster *container.Cluster) (string, *container.UpdateNodePoolRequest, bool) {
+	for _, np := range spec.NodePools {
+		actual := nodePool(cluster, np.Name)
+		if actual == nil {
+			continue
+		}
+		config := actual.Config
+		if config == nil {
+			config = &container.NodeConfig{}
+		}
+
+		// The node version and image type are required, so we send the
+		// node pool's current version and image type unless the image type
+		// is what we're updating.
+		u := &container.UpdateNodePoolRequest{NodeVersion: actual.Version, ImageType: config.ImageType}
+
+		if np.ImageType != "" && !strings.EqualFold(np.ImageType, config.ImageType) {
+			u.ImageType = strings.ToUpper(np.ImageType)
+			return np.Name, u, true
+		}
+
+		if np.LinuxNodeConfig != nil {
+			desired := desiredLinuxNodeConfig(np.LinuxNodeConfig)
+			if !compare.Equal(desired, config.LinuxNodeConfig, compare.IgnoreUnset()) {
+				u.LinuxNodeConfig = desired
+				return np.Name, u, true
+			}
+		}
+
+		if np.KubeletConfig != nil {
+			desired := desiredKubeletConfig(np.KubeletConfig)
+			if !compare.Equal(desired, config.KubeletConfig, compare.IgnoreUnset()) {
+				u.KubeletConfig = desired
+				return np.Name, u, true
+			}
+		}
+
+		if np.WorkloadMetadataMode != "" && !strings.EqualFold(np.WorkloadMetadataMode, workloadMetadataMode(config)) {
+			u.WorkloadMetadataConfig = &container.WorkloadMetadataConfig{Mode: strings.ToUpper(np.WorkloadMetadataMode)}
+			return np.Name, u, true
+		}
+	}
+	return "", nil, false
+}
+
+// workloadMetadataMode returns the workload metadata mode of the supplied node
+// configuration, or the empty string if it is unspecified.
+func workloadMetadataMode(c *container.NodeConfig) string {
+	if c.WorkloadMetadataConfig == nil {
+		return ""
+	}
+	return c.WorkloadMetadataConfig.Mode
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestValidateNodeConfig(t *testing.T) {
+	cases := map[string]struct {
+		np   gcpcomputev1alpha1.NodePoolSpec
+		want error
+	}{
+		"Unset": {},
+		"Containerd": {
+			np: gcpcomputev1alpha1.NodePoolSpec{
+				ImageType:       "ubuntu_containerd",
+				LinuxNodeConfig: &gcpcomputev1alpha1.LinuxNodeConfig{Sysctls: map[string]string{"net.core.somaxconn": "4096"}},
+				KubeletConfig:   &gcpcomputev1alpha1.KubeletConfig{CPUManagerPolicy: cpuManagerPolicyStatic, CPUCFSQuotaPeriod: "100ms", PodPIDsLimit: 4096},
+			},
+		},
+		"DockerImageType": {
+			np:   gcpcomputev1alpha1.NodePoolSpec{ImageType: "cos"},
+			want: errors.New(`image type "cos" uses the Docker runtime, which GKE no longer supports; use COS_CONTAINERD`),
+		},
+		"WindowsLinuxNodeConfig": {
+			np: gcpcomputev1alpha1.NodePoolSpec{
+				ImageType:       "WINDOWS_LTSC_CONTAINERD",
+				LinuxNodeConfig: &gcpcomputev1alpha1.LinuxNodeConfig{Sysctls: map[string]string{"net.core.somaxconn": "4096"}},
+			},
+			want: errors.New(`linuxNodeConfig is not supported by image type "WINDOWS_LTSC_CONTAINERD"`),
+		},
+		"UnknownCPUManagerPolicy": {
+			np:   gcpcomputev1alpha1.NodePoolSpec{KubeletConfig: &gcpcomputev1alpha1.KubeletConfig{CPUManagerPolicy: "dynamic"}},
+			want: errors.New(`CPU manager policy "dynamic" is not supported; use none or static`),
+		},
+		"InvalidCFSQuotaPeriod": {
+			np:   gcpcomputev1alpha1.NodePoolSpec{KubeletConfig: &gcpcomputev1alpha1.KubeletConfig{CPUCFSQuotaPeriod: "100"}},
+			want: errors.New(`CPU CFS quota period "100" must be a duration, e.g. 100ms`),
+		},
+		"PodPIDsLimitTooLow": {
+			np:   gcpcomputev1alpha1.NodePoolSpec{KubeletConfig: &gcpcomputev1alpha1.KubeletConfig{PodPIDsLimit: 100}},
+			want: errors.New("pod PIDs limit 100 must be between 1024 and 4194304"),
+		},
+		"GKEMetadata": {
+			np: gcpcomputev1alpha1.NodePoolSpec{WorkloadMetadataMode: "gke_metadata"},
+		},
+		"MetadataConcealment": {
+			np:   gcpcomputev1alpha1.NodePoolSpec{WorkloadMetadataMode: "SECURE"},
+			want: errors.New(`workload metadata mode "SECURE" is not supported; use GKE_METADATA or GCE_METADATA`),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := validateNodeConfig(tc.np)
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("validateNodeConfig(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNodePoolConfigUpdate(t *testing.T) {
+	enabled := true
+	sysctls := map[string]string{"net.core.somaxconn": "4096"}
+	pool := func(c *container.NodeConfig) *container.NodePool {
+		return &container.NodePool{Name: "cool-pool", Version: "1.14.7-gke.14", Config: c}
+	}
+
+	type want struct {
+		name   string
+		update *container.UpdateNodePoolRequest
+		ok     bool
+	}
+
+	cases := map[string]struct {
+		np      gcpcomputev1alpha1.NodePoolSpec
+		cluster *container.Cluster
+		want    want
+	}{
+		"NotSpecified": {
+			np:      gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool"},
+			cluster: &container.Cluster{NodePools: []*container.NodePool{pool(&container.NodeConfig{ImageType: "COS_CONTAINERD"})}},
+		},
+		"NodePoolNotCreated": {
+			np:      gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool", ImageType: "UBUNTU_CONTAINERD"},
+			cluster: &container.Cluster{},
+		},
+		"ImageTypeUpToDate": {
+			np:      gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool", ImageType: "cos_containerd"},
+			cluster: &container.Cluster{NodePools: []*container.NodePool{pool(&container.NodeConfig{ImageType: "COS_CONTAINERD"})}},
+		},
+		"ImageTypeChanged": {
+			np:      gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool", ImageType: "ubuntu_containerd"},
+			cluster: &container.Cluster{NodePools: []*container.NodePool{pool(&container.NodeConfig{ImageType: "COS_CONTAINERD"})}},
+			want: want{
+				name:   "cool-pool",
+				update: &container.UpdateNodePoolRequest{NodeVersion: "1.14.7-gke.14", ImageType: "UBUNTU_CONTAINERD"},
+				ok:     true,
+			},
+		},
+		"SysctlsChanged": {
+			np: gcpcomputev1alpha1.NodePoolSpec{
+				Name:            "cool-pool",
+				LinuxNodeConfig: &gcpcomputev1alpha1.LinuxNodeConfig{Sysctls: sysctls},
+			},
+			cluster: &container.Cluster{NodePools: []*container.NodePool{pool(&container.NodeConfig{ImageType: "COS_CONTAINERD"})}},
+			want: want{
+				name: "cool-pool",
+				update: &container.UpdateNodePoolRequest{
+					NodeVersion:     "1.14.7-gke.14",
+					ImageType:       "COS_CONTAINERD",
+					LinuxNodeConfig: &container.LinuxNodeConfig{Sysctls: sysctls},
+				},
+				ok: true,
+			},
+		},
+		"KubeletConfigChanged": {
+			np: gcpcomputev1alpha1.NodePoolSpec{
+				Name:          "cool-pool",
+				KubeletConfig: &gcpcomputev1alpha1.KubeletConfig{CPUManagerPolicy: cpuManagerPolicyStatic, CPUCFSQuota: &enabled},
+			},
+			cluster: &container.Cluster{NodePools: []*container.NodePool{pool(&container.NodeConfig{
+				ImageType:     "COS_CONTAINERD",
+				KubeletConfig: &container.NodeKubeletConfig{CpuManagerPolicy: cpuManagerPolicyNone, CpuCfsQuota: true},
+			})}},
+			want: want{
+				name: "cool-pool",
+				update: &container.UpdateNodePoolRequest{
+					NodeVersion: "1.14.7-gke.14",
+					ImageType:   "COS_CONTAINERD",
+					KubeletConfig: &container.NodeKubeletConfig{
+						CpuManagerPolicy: cpuManagerPolicyStatic,
+						CpuCfsQuota:      true,
+						ForceSendFields:  []string{"CpuCfsQuota"},
+					},
+				},
+				ok: true,
+			},
+		},
+		"ConfigUpToDate": {
+			np: gcpcomputev1alpha1.NodePoolSpec{
+				Name:            "cool-pool",
+				LinuxNodeConfig: &gcpcomputev1alpha1.LinuxNodeConfig{Sysctls: sysctls},
+				KubeletConfig:   &gcpcomputev1alpha1.KubeletConfig{CPUManagerPolicy: cpuManagerPolicyStatic},
+			},
+			cluster: &container.Cluster{NodePools: []*container.NodePool{pool(&container.NodeConfig{
+				ImageType:       "COS_CONTAINERD",
+				LinuxNodeConfig: &container.LinuxNodeConfig{Sysctls: sysctls},
+				KubeletConfig:   &container.NodeKubeletConfig{CpuManagerPolicy: cpuManagerPolicyStatic, CpuCfsQuota: true},
+			})}},
+		},
+		"WorkloadMetadataChanged": {
+			np: gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool", WorkloadMetadataMode: "gke_metadata"},
+			cluster: &container.Cluster{NodePools: []*container.NodePool{pool(&container.NodeConfig{
+				ImageType:              "COS_CONTAINERD",
+				WorkloadMetadataConfig: &container.WorkloadMetadataConfig{Mode: workloadMetadataGCE},
+			})}},
+			want: want{
+				name: "cool-pool",
+				update: &container.UpdateNodePoolRequest{
+					NodeVersion:            "1.14.7-gke.14",
+					ImageType:              "COS_CONTAINERD",
+					WorkloadMetadataConfig: &container.WorkloadMetadataConfig{Mode: workloadMetadataGKE},
+				},
+				ok: true,
+			},
+		},
+		"WorkloadMetadataUpToDate": {
+			np: gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool", WorkloadMetadataMode: workloadMetadataGKE},
+			cluster: &container.Cluster{NodePools: []*container.NodePool{pool(&container.NodeConfig{
+				ImageType:              "COS_CONTAINERD",
+				WorkloadMetadataConfig: &container.WorkloadMetadataConfig{Mode: workloadMetadataGKE},
+			})}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			spec := gcpcomputev1alpha1.GKEClusterSpec{NodePools: []gcpcomputev1alpha1.NodePoolSpec{tc.np}}
+			np, u, ok := nodePoolConfigUpdate(spec, tc.cluster)
+			got := want{name: np, update: u, ok: ok}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("nodePoolConfigUpdate(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateWorkloadMetadata(t *testing.T) {
+	cases := map[string]struct {
+		spec gcpcomputev1alpha1.GKEClusterSpec
+		want error
+	}{
+		"WorkloadIdentityDisabled": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{NodePools: []gcpcomputev1alpha1.NodePoolSpec{{Name: "cool-pool"}}},
+		},
+		"GKEMetadata": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{
+				WorkloadPool: "cool-project.svc.id.goog",
+				NodePools:    []gcpcomputev1alpha1.NodePoolSpec{{Name: "cool-pool", WorkloadMetadataMode: workloadMetadataGKE}},
+			},
+		},
+		"Unset": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{
+				WorkloadPool: "cool-project.svc.id.goog",
+				NodePools:    []gcpcomputev1alpha1.NodePoolSpec{{Name: "cool-pool"}},
+			},
+			want: errors.New(`node pool "cool-pool" must set workloadMetadataMode to GKE_METADATA, because the cluster enables Workload Identity`),
+		},
+		"GCEMetadata": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{
+				WorkloadPool: "cool-project.svc.id.goog",
+				NodePools:    []gcpcomputev1alpha1.NodePoolSpec{{Name: "cool-pool", WorkloadMetadataMode: workloadMetadataGCE}},
+			},
+			want: errors.New(`node pool "cool-pool" must set workloadMetadataMode to GKE_METADATA, because the cluster enables Workload Identity`),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := validateWorkloadMetadata(tc.spec)
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("validateWorkloadMetadata(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+)
+
+// defaultNodePoolName is the name GKE gives the node pool it creates from a
+// cluster's top level node configuration.
+const defaultNodePoolName = "default-pool"
+
+// hasNodePool returns true if the supplied cluster has a node pool with the
+// supplied name.
+func hasNodePool(cluster *container.Cluster, name string) bool {
+	return nodePool(cluster, name) != nil
+}
+
+// nodePool returns the node pool of the supplied cluster with the supplied
+// name, or nil if there is no such node pool.
+func nodePool(cluster *container.Cluster, name string) *container.NodePool {
+	for _, np := range cluster.NodePools {
+		if np.Name == name {
+			return np
+		}
+	}
+	return nil
+}
+
+// nodePoolCounts returns the current number of nodes in each of the supplied
+// cluster's node pools, keyed by node pool name. The GKE API does not report
+// the current size of a node pool, so it is read from the node pool's managed
+// instance groups.
+func (r *Reconciler) nodePoolCounts(instance *gcpcomputev1alpha1.GKECluster, cluster *container.Cluster) (map[string]int64, error) {
+	counts := map[string]int64{}
+	var sizes nodePoolSizer
+	for _, np := range cluster.NodePools {
+		if len(np.InstanceGroupUrls) == 0 {
+			continue
+		}
+		if sizes == nil {
+			s, err := r.nodePoolSizes(instance)
+			if err != nil {
+				return nil, err
+			}
+			sizes = s
+		}
+		count, err := sizes.NodeCount(ctx, np)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get size of node pool %s", np.Name)
+		}
+		counts[np.Name] = count
+	}
+	return counts, nil
+}
+
+// nodePoolStatuses returns the observed state of the supplied cluster's node
+// pools, including the supplied current number of nodes in each node pool.
+func nodePoolStatuses(cluster *container.Cluster, counts map[string]int64) []gcpcomputev1alpha1.NodePoolStatus {
+	if len(cluster.NodePools) == 0 {
+		return nil
+	}
+
+	s := make([]gcpcomputev1alpha1.NodePoolStatus, 0, len(cluster.NodePools))
+	for _, np := range cluster.NodePools {
+		ps := gcpcomputev1alpha1.NodePoolStatus{
+			Name:              np.Name,
+			Status:            np.Status,
+			StatusMessage:     np.StatusMessage,
+			Version:           np.Version,
+			NodeCount:         counts[np.Name],
+			InitialNodeCount:  np.InitialNodeCount,
+			InstanceGroupURLs: np.InstanceGroupUrls,
+		}
+		if c := np.Config; c != nil {
+			ps.ImageType = c.ImageType
+			ps.DiskSizeGB = c.DiskSizeGb
+			ps.DiskType = c.DiskType
+			ps.MinCPUPlatform = c.MinCpuPlatform
+			ps.WorkloadMetadataMode = workloadMetadataMode(c)
+			ps.LegacyEndpointsEnabled = legacyEndpointsEnabled(c)
+			if c.SandboxConfig != nil {
+				ps.SandboxType = c.SandboxConfig.Type
+			}
+		}
+		if us := np.UpgradeSettings; us != nil {
+			ps.UpgradeStrategy = us.Strategy
+		}
+		if a := np.Autoscaling; a != nil && a.Enabled {
+			ps.Autoscaling = &gcpcomputev1alpha1.NodePoolAutoscalingStatus{
+				MinNodeCount:    a.MinNodeCount,
+				MaxNodeCount:    a.MaxNodeCount,
+				Autoprovisioned: a.Autoprovisioned,
+			}
+		}
+		s = append(s, ps)
+	}
+	return s
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	fakegcp "github.com/crossplaneio/crossplane/pkg/clients/gcp/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestNodePoolStatuses(t *testing.T) {
+	igm := "https://www.googleapis.com/compute/v1/projects/cool-project/zones/us-central1-a/instanceGroupManagers/gke-cool-pool-grp"
+
+	cases := map[string]struct {
+		cluster *container.Cluster
+		counts  map[string]int64
+		want    []gcpcomputev1alpha1.NodePoolStatus
+	}{
+		"NoNodePools": {
+			cluster: &container.Cluster{},
+		},
+		"Autoscaling": {
+			cluster: &container.Cluster{NodePools: []*container.NodePool{{
+				Name:              "cool-pool",
+				Status:            "RUNNING",
+				Version:           "1.14.7-gke.14",
+				InitialNodeCount:  1,
+				InstanceGroupUrls: []string{igm},
+				Autoscaling:       &container.NodePoolAutoscaling{Enabled: true, MinNodeCount: 1, MaxNodeCount: 5},
+				UpgradeSettings:   &container.UpgradeSettings{Strategy: upgradeStrategySurge, MaxSurge: 1},
+			}}},
+			counts: map[string]int64{"cool-pool": 3},
+			want: []gcpcomputev1alpha1.NodePoolStatus{{
+				Name:              "cool-pool",
+				Status:            "RUNNING",
+				Version:           "1.14.7-gke.14",
+				NodeCount:         3,
+				InitialNodeCount:  1,
+				InstanceGroupURLs: []string{igm},
+				Autoscaling:       &gcpcomputev1alpha1.NodePoolAutoscalingStatus{MinNodeCount: 1, MaxNodeCount: 5},
+				UpgradeStrategy:   upgradeStrategySurge,
+			}},
+		},
+		"Sandboxed": {
+			cluster: &container.Cluster{NodePools: []*container.NodePool{{
+				Name:   "untrusted",
+				Status: "RUNNING",
+				Config: &container.NodeConfig{ImageType: sandboxImageType, SandboxConfig: &container.SandboxConfig{Type: sandboxTypeGVisor}},
+			}}},
+			want: []gcpcomputev1alpha1.NodePoolStatus{{
+				Name:                   "untrusted",
+				Status:                 "RUNNING",
+				ImageType:              sandboxImageType,
+				SandboxType:            sandboxTypeGVisor,
+				LegacyEndpointsEnabled: true,
+			}},
+		},
+		"WorkloadIdentity": {
+			cluster: &container.Cluster{NodePools: []*container.NodePool{{
+				Name:   "cool-pool",
+				Status: "RUNNING",
+				Config: &container.NodeConfig{
+					ImageType:              sandboxImageType,
+					Metadata:               map[string]string{legacyEndpointsMetadataKey: "true"},
+					WorkloadMetadataConfig: &container.WorkloadMetadataConfig{Mode: workloadMetadataGKE},
+				},
+			}}},
+			want: []gcpcomputev1alpha1.NodePoolStatus{{
+				Name:                 "cool-pool",
+				Status:               "RUNNING",
+				ImageType:            sandboxImageType,
+				WorkloadMetadataMode: workloadMetadataGKE,
+			}},
+		},
+		"AutoscalingDisabled": {
+			cluster: &container.Cluster{NodePools: []*container.NodePool{{
+				Name:          "cool-pool",
+				Status:        "ERROR",
+				StatusMessage: "quota exceeded",
+				Autoscaling:   &container.NodePoolAutoscaling{Enabled: false},
+			}}},
+			want: []gcpcomputev1alpha1.NodePoolStatus{{
+				Name:          "cool-pool",
+				Status:        "ERROR",
+				StatusMessage: "quota exceeded",
+			}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := nodePoolStatuses(tc.cluster, tc.counts)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("nodePoolStatuses(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNodePoolCounts(t *testing.T) {
+	igm := "https://www.googleapis.com/compute/v1/projects/cool-project/zones/us-central1-a/instanceGroupManagers/gke-cool-pool-grp"
+	errBoom := errors.New("boom")
+
+	cases := map[string]struct {
+		cluster *container.Cluster
+		sizes   func(*gcpcomputev1alpha1.GKECluster) (nodePoolSizer, error)
+		want    map[string]int64
+		wantErr error
+	}{
+		"NoInstanceGroups": {
+			// The sizer is not needed when no node pool has instance groups.
+			cluster: &container.Cluster{NodePools: []*container.NodePool{{Name: "cool-pool"}}},
+			want:    map[string]int64{},
+		},
+		"Counted": {
+			cluster: &container.Cluster{NodePools: []*container.NodePool{{Name: "cool-pool", InstanceGroupUrls: []string{igm}}}},
+			sizes:   func(*gcpcomputev1alpha1.GKECluster) (nodePoolSizer, error) { return fakeNodePoolSizer{count: 3}, nil },
+			want:    map[string]int64{"cool-pool": 3},
+		},
+		"SizerError": {
+			cluster: &container.Cluster{NodePools: []*container.NodePool{{Name: "cool-pool", InstanceGroupUrls: []string{igm}}}},
+			sizes:   func(*gcpcomputev1alpha1.GKECluster) (nodePoolSizer, error) { return nil, errBoom },
+			wantErr: errBoom,
+		},
+		"NodeCountError": {
+			cluster: &container.Cluster{NodePools: []*container.NodePool{{Name: "cool-pool", InstanceGroupUrls: []string{igm}}}},
+			sizes: func(*gcpcomputev1alpha1.GKECluster) (nodePoolSizer, error) {
+				return fakeNodePoolSizer{err: errBoom}, nil
+			},
+			wantErr: errors.Wrap(errBoom, "cannot get size of node pool cool-pool"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &Reconciler{nodePoolSizes: tc.sizes}
+			got, err := r.nodePoolCounts(testCluster(), tc.cluster)
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("r.nodePoolCounts(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("r.nodePoolCounts(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSyncRemoveDefaultNodePool(t *testing.T) {
+	cases := map[string]struct {
+		nodePools   []*container.NodePool
+		wantDeleted bool
+		want        reconcile.Result
+	}{
+		"DefaultNodePoolExists": {
+			nodePools:   []*container.NodePool{{Name: defaultNodePoolName}, {Name: "cool-pool"}},
+			wantDeleted: true,
+			want:        reconcile.Result{RequeueAfter: requeueOnWait},
+		},
+		"DefaultNodePoolRemoved": {
+			nodePools: []*container.NodePool{{Name: "cool-pool"}},
+			want:      reconcile.Result{RequeueAfter: requeueOnSucces},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			instance := testCluster()
+			instance.Spec.RemoveDefaultNodePool = true
+			instance.Spec.NodePools = []gcpcomputev1alpha1.NodePoolSpec{{Name: "cool-pool"}}
+			instance.Status.ClusterName = "gke-cool"
+
+			r := &Reconciler{
+				Client:     fakeclient.NewFakeClient(instance),
+				kubeclient: fake.NewSimpleClientset(),
+			}
+
+			deleted := false
+			cl := fakegcp.NewGKEClient()
+			cl.MockGetCluster = func(string, string) (*container.Cluster, error) {
+				return &container.Cluster{Status: gcpcomputev1alpha1.ClusterStateRunning, MasterAuth: masterAuth, NodePools: tc.nodePools}, nil
+			}
+			cl.MockDeleteNodePool = func(_, cluster, nodePool string) error {
+				if cluster != "gke-cool" || nodePool != defaultNodePoolName {
+					t.Errorf("DeleteNodePool(...): want %s/%s, got %s/%s", "gke-cool", defaultNodePoolName, cluster, nodePool)
+				}
+				deleted = true
+				return nil
+			}
+
+			rs, err := r._sync(instance, cl)
+			if err != nil {
+				t.Fatalf("r._sync(...): %s", err)
+			}
+			if diff := cmp.Diff(tc.want, rs); diff != "" {
+				t.Errorf("r._sync(...): -want, +got:\n%s", diff)
+			}
+			if deleted != tc.wantDeleted {
+				t.Errorf("DeleteNodePool(...): want called %t, got %t", tc.wantDeleted, deleted)
+			}
+		})
+	}
+}
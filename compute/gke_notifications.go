@@ -0,0 +1,136 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+)
+
+// notificationEventTypes are the GKE cluster events that may be published to
+// Pub/Sub.
+var notificationEventTypes = []string{"UPGRADE_AVAILABLE_EVENT", "UPGRADE_EVENT", "SECURITY_BULLETIN_EVENT"}
+
+// validateNotifications returns an error if the supplied notification config
+// filters on an event type GKE does not publish.
+func validateNotifications(spec *gcpcomputev1alpha1.NotificationConfig) error {
+	if spec == nil {
+		return nil
+	}
+	for _, e := range spec.EventTypes {
+		if !containsString(notificationEventTypes, e) {
+			return errors.Errorf("notification event type %q is not supported; use one of %s", e, strings.Join(notificationEventTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// notificationUpdate returns the cluster update required for the supplied
+// cluster to publish the notifications described by the supplied spec, or nil
+// if no update is required.
+func notificationUpdate(spec gcpcomputev1alpha1.GKEClusterSpec, cluster *container.Cluster) (*container.ClusterUpdate, error) {
+	desired, err := notificationConfig(spec.NotificationConfig, cluster)
+	if err != nil {
+		return nil, err
+	}
+	if notificationsEqual(desired, cluster.NotificationConfig) {
+		return nil, nil
+	}
+	return &container.ClusterUpdate{DesiredNotificationConfig: desired}, nil
+}
+
+// notificationConfig returns the GKE notification config described by the
+// supplied spec. Topics that are not fully qualified are assumed to be in the
+// supplied cluster's project.
+func notificationConfig(spec *gcpcomputev1alpha1.NotificationConfig, cluster *container.Cluster) (*container.NotificationConfig, error) {
+	if spec == nil || spec.Topic == "" {
+		// Send false explicitly so that notifications may be disabled.
+		return &container.NotificationConfig{Pubsub: &container.PubSub{ForceSendFields: []string{"Enabled"}}}, nil
+	}
+
+	topic := spec.Topic
+	if !strings.HasPrefix(topic, "projects/") {
+		match := clusterSelfLink.FindStringSubmatch(cluster.SelfLink)
+		if match == nil {
+			return nil, errors.Errorf("cannot determine project of cluster %s from self link %q", cluster.Name, cluster.SelfLink)
+		}
+		topic = fmt.Sprintf("projects/%s/topics/%s", match[2], topic)
+	}
+
+	ps := &container.PubSub{Enabled: true, Topic: topic}
+	if len(spec.EventTypes) > 0 {
+		ps.Filter = &container.Filter{EventType: sortedStrings(spec.EventTypes)}
+	}
+	return &container.NotificationConfig{Pubsub: ps}, nil
+}
+
+// notificationsEqual returns true if the desired and actual notification
+// configs publish the same events to the same topic.
+func notificationsEqual(desired, actual *container.NotificationConfig) bool {
+	d, a := pubsubOf(desired), pubsubOf(actual)
+	if !d.Enabled || !a.Enabled {
+		return d.Enabled == a.Enabled
+	}
+	if d.Topic != a.Topic {
+		return false
+	}
+
+	var de, ae []string
+	if d.Filter != nil {
+		de = d.Filter.EventType
+	}
+	if a.Filter != nil {
+		ae = sortedStrings(a.Filter.EventType)
+	}
+	if len(de) != len(ae) {
+		return false
+	}
+	for i := range de {
+		if de[i] != ae[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func pubsubOf(c *container.NotificationConfig) *container.PubSub {
+	if c == nil || c.Pubsub == nil {
+		return &container.PubSub{}
+	}
+	return c.Pubsub
+}
+
+func sortedStrings(s []string) []string {
+	sorted := append([]string{}, s...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const notificationTopic = "projects/cool-project/topics/upgrades"
+
+func TestNotificationUpdate(t *testing.T) {
+	publishing := &container.NotificationConfig{Pubsub: &container.PubSub{
+		Enabled: true,
+		Topic:   notificationTopic,
+		Filter:  &container.Filter{EventType: []string{"UPGRADE_AVAILABLE_EVENT", "UPGRADE_EVENT"}},
+	}}
+
+	cases := map[string]struct {
+		spec    gcpcomputev1alpha1.GKEClusterSpec
+		cluster *container.Cluster
+		want    *container.ClusterUpdate
+		wantErr error
+	}{
+		"Disabled": {
+			spec:    gcpcomputev1alpha1.GKEClusterSpec{},
+			cluster: &container.Cluster{},
+			want:    nil,
+		},
+		"Enable": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{NotificationConfig: &gcpcomputev1alpha1.NotificationConfig{
+				Topic:      "upgrades",
+				EventTypes: []string{"UPGRADE_EVENT", "UPGRADE_AVAILABLE_EVENT"},
+			}},
+			cluster: &container.Cluster{Name: "gke-cool", SelfLink: fleetSelfLink},
+			want:    &container.ClusterUpdate{DesiredNotificationConfig: publishing},
+		},
+		"UpToDate": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{NotificationConfig: &gcpcomputev1alpha1.NotificationConfig{
+				Topic:      notificationTopic,
+				EventTypes: []string{"UPGRADE_EVENT", "UPGRADE_AVAILABLE_EVENT"},
+			}},
+			cluster: &container.Cluster{NotificationConfig: &container.NotificationConfig{Pubsub: &container.PubSub{
+				Enabled: true,
+				Topic:   notificationTopic,
+				Filter:  &container.Filter{EventType: []string{"UPGRADE_EVENT", "UPGRADE_AVAILABLE_EVENT"}},
+			}}},
+			want: nil,
+		},
+		"ChangeFilter": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{NotificationConfig: &gcpcomputev1alpha1.NotificationConfig{
+				Topic: notificationTopic,
+			}},
+			cluster: &container.Cluster{NotificationConfig: publishing},
+			want: &container.ClusterUpdate{DesiredNotificationConfig: &container.NotificationConfig{
+				Pubsub: &container.PubSub{Enabled: true, Topic: notificationTopic},
+			}},
+		},
+		"Disable": {
+			spec:    gcpcomputev1alpha1.GKEClusterSpec{},
+			cluster: &container.Cluster{NotificationConfig: publishing},
+			want: &container.ClusterUpdate{DesiredNotificationConfig: &container.NotificationConfig{
+				Pubsub: &container.PubSub{ForceSendFields: []string{"Enabled"}},
+			}},
+		},
+		"UnknownProject": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{NotificationConfig: &gcpcomputev1alpha1.NotificationConfig{
+				Topic: "upgrades",
+			}},
+			cluster: &container.Cluster{Name: "gke-cool", SelfLink: "gke-cool"},
+			wantErr: errors.New(`cannot determine project of cluster gke-cool from self link "gke-cool"`),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := notificationUpdate(tc.spec, tc.cluster)
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("notificationUpdate(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("notificationUpdate(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
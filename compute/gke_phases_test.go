@@ -0,0 +1,126 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	runtimefake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/gke"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compute/gketest"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestReconcilePhases(t *testing.T) {
+	errBoom := errors.New("boom")
+	cl := gketest.NewClient(gketest.Running(clusterName))
+
+	cases := []struct {
+		name           string
+		cluster        *gcpcomputev1alpha1.GKECluster
+		connect        func(*gcpcomputev1alpha1.GKECluster) (gke.Client, error)
+		want           reconcile.Result
+		wantCreate     int
+		wantSync       int
+		wantDelete     int
+		wantConditions []corev1alpha1.Condition
+	}{
+		{
+			name:           "ConnectError",
+			cluster:        gketest.Cluster(namespace, clusterName),
+			connect:        gketest.Connect(nil, errBoom),
+			want:           resultRequeue,
+			wantConditions: []corev1alpha1.Condition{corev1alpha1.ReconcileError(errBoom)},
+		},
+		{
+			name:       "Create",
+			cluster:    gketest.Cluster(namespace, clusterName),
+			connect:    gketest.Connect(cl, nil),
+			want:       resultRequeue,
+			wantCreate: 1,
+		},
+		{
+			name:     "Sync",
+			cluster:  gketest.Cluster(namespace, clusterName, gketest.WithClusterName(clusterName)),
+			connect:  gketest.Connect(cl, nil),
+			want:     resultRequeue,
+			wantSync: 1,
+		},
+		{
+			name:       "Delete",
+			cluster:    gketest.Cluster(namespace, clusterName, gketest.WithClusterName(clusterName), gketest.WithDeletionTimestamp()),
+			connect:    gketest.Connect(cl, nil),
+			want:       resultRequeue,
+			wantDelete: 1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			create := &gketest.Phase{Result: resultRequeue}
+			sync := &gketest.Phase{Result: resultRequeue}
+			del := &gketest.Phase{Result: resultRequeue}
+
+			r := &Reconciler{
+				Client:     runtimefake.NewFakeClient(tc.cluster),
+				kubeclient: kubefake.NewSimpleClientset(),
+				connect:    tc.connect,
+				create:     create.Func(),
+				sync:       sync.Func(),
+				delete:     del.Func(),
+			}
+
+			got, err := r.Reconcile(request)
+			if diff := cmp.Diff(nil, err, test.EquateErrors()); diff != "" {
+				t.Errorf("r.Reconcile(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("r.Reconcile(...): -want, +got:\n%s", diff)
+			}
+
+			for phase, calls := range map[string][2]int{
+				"create": {tc.wantCreate, len(create.Called)},
+				"sync":   {tc.wantSync, len(sync.Called)},
+				"delete": {tc.wantDelete, len(del.Called)},
+			} {
+				if calls[0] != calls[1] {
+					t.Errorf("r.Reconcile(...): want %d %s calls, got %d", calls[0], phase, calls[1])
+				}
+			}
+
+			gketest.AssertConditions(t, r, key, tc.wantConditions...)
+		})
+	}
+}
+
+func TestConnectionSecretData(t *testing.T) {
+	r := &Reconciler{}
+	s, err := r.connectionSecret(gketest.Cluster(namespace, clusterName), gketest.Running(clusterName))
+	if err != nil {
+		t.Fatalf("r.connectionSecret(...): %v", err)
+	}
+	if diff := cmp.Diff(gketest.SecretData(), s.Data); diff != "" {
+		t.Errorf("r.connectionSecret(...): -want data, +got:\n%s", diff)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/crossplaneio/crossplane/gcp/apis"
 
@@ -33,6 +34,7 @@ import (
 	. "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/kubernetes/scheme"
 	. "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
 	. "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
@@ -40,6 +42,9 @@ import (
 	. "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
 	"github.com/crossplaneio/crossplane/pkg/clients/gcp/fake"
 	"github.com/crossplaneio/crossplane/pkg/clients/gcp/gke"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/deadline"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/externalname"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/plan"
 	"github.com/crossplaneio/crossplane/pkg/test"
 )
 
@@ -94,6 +99,155 @@ func assertResource(g *GomegaWithT, r *Reconciler, s corev1alpha1.ConditionedSta
 	return rc
 }
 
+func TestCreateInvalidSpec(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tc := testCluster()
+	tc.Spec.EnableConfidentialNodes = true
+	tc.Spec.MachineType = "n1-standard-1"
+
+	r := &Reconciler{
+		Client:     NewFakeClient(tc),
+		kubeclient: NewSimpleClientset(),
+	}
+
+	called := false
+	cl := fake.NewGKEClient()
+	cl.MockCreateCluster = func(string, GKEClusterSpec) (*container.Cluster, error) {
+		called = true
+		return nil, nil
+	}
+
+	expectedStatus := corev1alpha1.ConditionedStatus{}
+	expectedStatus.SetConditions(corev1alpha1.ReconcileError(validateCluster(tc.Spec)))
+
+	rs, err := r._create(tc, cl)
+	g.Expect(rs).To(Equal(result))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(called).To(BeFalse())
+	assertResource(g, r, expectedStatus)
+}
+
+func TestCreateDryRun(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tc := testCluster()
+	tc.Annotations = map[string]string{plan.AnnotationManagementMode: plan.ModeDryRun}
+
+	rec := record.NewFakeRecorder(1)
+	r := &Reconciler{
+		Client:     NewFakeClient(tc),
+		kubeclient: NewSimpleClientset(),
+		recorder:   rec,
+	}
+
+	called := false
+	cl := fake.NewGKEClient()
+	cl.MockCreateCluster = func(string, GKEClusterSpec) (*container.Cluster, error) {
+		called = true
+		return nil, nil
+	}
+
+	rs, err := r._create(tc, cl)
+	g.Expect(rs).To(Equal(reconcile.Result{RequeueAfter: requeueOnSucces}))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(called).To(BeFalse())
+	g.Expect(rec.Events).To(HaveLen(1))
+
+	rc := &GKECluster{}
+	g.Expect(r.Get(ctx, key, rc)).To(Succeed())
+	g.Expect(rc.Status.ClusterName).To(BeEmpty())
+	g.Expect(rc.Status.GetCondition(plan.TypePlanned).Status).To(Equal(corev1.ConditionTrue))
+}
+
+func TestCreatePersistsExternalName(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tc := testCluster()
+	tc.UID = "cool-uid"
+
+	r := &Reconciler{
+		Client:     NewFakeClient(tc),
+		kubeclient: NewSimpleClientset(),
+	}
+
+	testError := errors.New("test-error-create-cluster")
+	cl := fake.NewGKEClient()
+	cl.MockCreateCluster = func(string, GKEClusterSpec) (*container.Cluster, error) {
+		return nil, testError
+	}
+
+	_, err := r._create(tc, cl)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The name is persisted even though the cluster could not be created.
+	rc := &GKECluster{}
+	g.Expect(r.Get(ctx, key, rc)).To(Succeed())
+	g.Expect(externalname.Get(rc)).To(Equal(clusterNamePrefix + "cool-uid"))
+	g.Expect(rc.Status.ClusterName).To(BeEmpty())
+}
+
+func TestCreateRestored(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tc := testCluster()
+	tc.UID = "new-uid"
+	tc.Annotations = map[string]string{externalname.AnnotationExternalName: clusterNamePrefix + "old-uid"}
+
+	r := &Reconciler{
+		Client:     NewFakeClient(tc),
+		kubeclient: NewSimpleClientset(),
+	}
+
+	var created string
+	cl := fake.NewGKEClient()
+	cl.MockCreateCluster = func(name string, _ GKEClusterSpec) (*container.Cluster, error) {
+		created = name
+		return nil, nil
+	}
+
+	_, err := r._create(tc, cl)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(created).To(Equal(clusterNamePrefix + "old-uid"))
+
+	rc := &GKECluster{}
+	g.Expect(r.Get(ctx, key, rc)).To(Succeed())
+	g.Expect(rc.Status.ClusterName).To(Equal(clusterNamePrefix + "old-uid"))
+}
+
+func TestCreateClusterStalled(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tc := testCluster()
+	deadline.Observe(tc, ClusterStateProvisioning, time.Now().Add(-2*deadline.DefaultCreationDeadline))
+
+	rec := record.NewFakeRecorder(1)
+	r := &Reconciler{
+		Client:     NewFakeClient(tc),
+		kubeclient: NewSimpleClientset(),
+		recorder:   rec,
+	}
+
+	testError := errors.New("quota exceeded")
+	cl := fake.NewGKEClient()
+	cl.MockCreateCluster = func(string, GKEClusterSpec) (*container.Cluster, error) {
+		return nil, testError
+	}
+
+	expectedStatus := corev1alpha1.ConditionedStatus{}
+	expectedStatus.SetConditions(
+		corev1alpha1.Creating(),
+		deadline.Stalled(deadline.DefaultCreationDeadline, "quota exceeded"),
+		corev1alpha1.ReconcileError(testError),
+	)
+
+	rs, err := r._create(tc, cl)
+	g.Expect(rs).To(Equal(resultRequeue))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rec.Events).To(HaveLen(1))
+	assertResource(g, r, expectedStatus)
+}
+
 func TestSyncClusterGetError(t *testing.T) {
 	g := NewGomegaWithT(t)
 
@@ -152,6 +306,67 @@ func TestSyncClusterNotReady(t *testing.T) {
 	assertResource(g, r, expectedStatus)
 }
 
+func TestSyncClusterStalled(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tc := testCluster()
+	tc.CreationTimestamp = metav1.NewTime(time.Now().Add(-2 * deadline.DefaultCreationDeadline))
+	deadline.Observe(tc, ClusterStateProvisioning, time.Now().Add(-2*deadline.DefaultCreationDeadline))
+
+	rec := record.NewFakeRecorder(1)
+	r := &Reconciler{
+		Client:     NewFakeClient(tc),
+		kubeclient: NewSimpleClientset(),
+		recorder:   rec,
+	}
+
+	cl := fake.NewGKEClient()
+	cl.MockGetCluster = func(string, string) (*container.Cluster, error) {
+		return &container.Cluster{
+			Status:        ClusterStateProvisioning,
+			StatusMessage: "quota exceeded",
+		}, nil
+	}
+
+	expectedStatus := corev1alpha1.ConditionedStatus{}
+	expectedStatus.SetConditions(deadline.Stalled(deadline.DefaultCreationDeadline, "quota exceeded"))
+
+	rs, err := r._sync(tc, cl)
+	g.Expect(rs).To(Equal(reconcile.Result{RequeueAfter: requeueOnWait}))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rec.Events).To(HaveLen(1))
+	assertResource(g, r, expectedStatus)
+}
+
+func TestSyncClusterNewlyReconciling(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	// A cluster created long ago that has only just begun reconciling, for
+	// example to upgrade, is not stalled.
+	tc := testCluster()
+	tc.CreationTimestamp = metav1.NewTime(time.Now().Add(-2 * deadline.DefaultCreationDeadline))
+	deadline.Observe(tc, ClusterStateProvisioning, time.Now().Add(-2*deadline.DefaultCreationDeadline))
+
+	rec := record.NewFakeRecorder(1)
+	r := &Reconciler{
+		Client:     NewFakeClient(tc),
+		kubeclient: NewSimpleClientset(),
+		recorder:   rec,
+	}
+
+	cl := fake.NewGKEClient()
+	cl.MockGetCluster = func(string, string) (*container.Cluster, error) {
+		return &container.Cluster{Status: "RECONCILING"}, nil
+	}
+
+	rs, err := r._sync(tc, cl)
+	g.Expect(rs).To(Equal(reconcile.Result{RequeueAfter: requeueOnWait}))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rec.Events).To(HaveLen(0))
+	rc := assertResource(g, r, corev1alpha1.ConditionedStatus{})
+	g.Expect(rc.GetAnnotations()[deadline.AnnotationWaitingSince]).To(HavePrefix("RECONCILING "))
+}
+
 func TestSyncApplySecretError(t *testing.T) {
 	g := NewGomegaWithT(t)
 
@@ -196,6 +411,8 @@ func TestSync(t *testing.T) {
 	g := NewGomegaWithT(t)
 
 	tc := testCluster()
+	tc.Status.SetConditions(deadline.Stalled(deadline.DefaultCreationDeadline, "quota exceeded"))
+	deadline.Observe(tc, ClusterStateProvisioning, time.Now().Add(-2*deadline.DefaultCreationDeadline))
 
 	r := &Reconciler{
 		Client:     NewFakeClient(tc),
@@ -211,19 +428,55 @@ func TestSync(t *testing.T) {
 	cl.MockGetCluster = func(string, string) (*container.Cluster, error) {
 		called = true
 		return &container.Cluster{
-			Status:     ClusterStateRunning,
-			Endpoint:   endpoint,
-			MasterAuth: auth,
+			Status:           ClusterStateRunning,
+			Endpoint:         endpoint,
+			MasterAuth:       auth,
+			CurrentNodeCount: 3,
+			NodePools:        []*container.NodePool{{Name: "default-pool", Status: "RUNNING"}},
 		}, nil
 	}
 
 	expectedStatus := corev1alpha1.ConditionedStatus{}
-	expectedStatus.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	expectedStatus.SetConditions(deadline.Recovered(), corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
 
 	rs, err := r._sync(tc, cl)
 	g.Expect(rs).To(Equal(reconcile.Result{RequeueAfter: requeueOnSucces}))
 	g.Expect(err).NotTo(HaveOccurred())
 	g.Expect(called).To(BeTrue())
+	rc := assertResource(g, r, expectedStatus)
+	g.Expect(rc.GetAnnotations()).NotTo(HaveKey(deadline.AnnotationWaitingSince))
+	g.Expect(rc.Status.CurrentNodeCount).To(Equal(int64(3)))
+	g.Expect(rc.Status.NodePools).To(Equal([]NodePoolStatus{{Name: "default-pool", Status: "RUNNING"}}))
+}
+
+func TestSyncNothingPlanned(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	// A cluster that converged since a call was planned no longer plans it.
+	tc := testCluster()
+	tc.Annotations = map[string]string{plan.AnnotationManagementMode: plan.ModeDryRun}
+	tc.Status.SetConditions(plan.Planned("UpdateCluster"))
+
+	r := &Reconciler{
+		Client:     NewFakeClient(tc),
+		kubeclient: NewSimpleClientset(),
+	}
+
+	cl := fake.NewGKEClient()
+	cl.MockGetCluster = func(string, string) (*container.Cluster, error) {
+		return &container.Cluster{
+			Status:     ClusterStateRunning,
+			Endpoint:   "test-ep",
+			MasterAuth: masterAuth,
+		}, nil
+	}
+
+	expectedStatus := corev1alpha1.ConditionedStatus{}
+	expectedStatus.SetConditions(plan.NothingPlanned(), corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+
+	rs, err := r._sync(tc, cl)
+	g.Expect(rs).To(Equal(reconcile.Result{RequeueAfter: requeueOnSucces}))
+	g.Expect(err).NotTo(HaveOccurred())
 	assertResource(g, r, expectedStatus)
 }
 
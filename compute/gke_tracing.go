@@ -0,0 +1,46 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/gke"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/tracing"
+)
+
+// A tracedClient is a gke.Client that carries the context of the reconcile
+// it was connected for. The GKE client does not accept a context, so the
+// phases of a reconcile that call it find their parent span here.
+type tracedClient struct {
+	gke.Client
+	ctx context.Context
+}
+
+// startPhase starts a span for the supplied phase of the reconcile whose
+// context is carried by the supplied client. The span has no parent if the
+// client is not a tracedClient.
+func startPhase(client gke.Client, phase string) trace.Span {
+	pctx := ctx
+	if c, ok := client.(*tracedClient); ok {
+		pctx = c.ctx
+	}
+	_, span := tracing.StartPhase(pctx, phase)
+	return span
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/fake"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/tracing"
+)
+
+func TestStartPhase(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	defer otel.SetTracerProvider(prev)
+
+	rctx, root := tracing.StartReconcile(ctx, "GKECluster", types.NamespacedName{Namespace: "cool", Name: "cluster"})
+	startPhase(&tracedClient{Client: fake.NewGKEClient(), ctx: rctx}, tracing.PhaseObserve).End()
+	startPhase(fake.NewGKEClient(), tracing.PhaseObserve).End()
+	root.End()
+
+	ended := sr.Ended()
+	if len(ended) != 3 {
+		t.Fatalf("sr.Ended(): want 3 spans, got %d", len(ended))
+	}
+	if got, want := ended[0].Parent().SpanID(), root.SpanContext().SpanID(); got != want {
+		t.Errorf("traced client phase: want parent %s, got %s", want, got)
+	}
+	if ended[1].Parent().IsValid() {
+		t.Errorf("untraced client phase: want no parent, got %s", ended[1].Parent().SpanID())
+	}
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/gke"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/plan"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/tracing"
+)
+
+// The node pool upgrade strategies supported by GKE.
+const (
+	upgradeStrategySurge     = "SURGE"
+	upgradeStrategyBlueGreen = "BLUE_GREEN"
+)
+
+// maxSoakDuration is the longest GKE will soak a blue/green node pool
+// upgrade, or a batch of one.
+const maxSoakDuration = 7 * 24 * time.Hour
+
+// soakDuration matches a duration in seconds, e.g. 3600s, as accepted by the
+// GKE API.
+var soakDuration = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?s$`)
+
+// validateUpgradeSettings returns an error if the supplied node pool's
+// upgrade settings would be rejected by GKE.
+func validateUpgradeSettings(np gcpcomputev1alpha1.NodePoolSpec) error {
+	us := np.UpgradeSettings
+	if us == nil {
+		return nil
+	}
+
+	switch upgradeStrategy(us) {
+	case upgradeStrategySurge:
+		if us.BlueGreenSettings != nil {
+			return errors.Errorf("blueGreenSettings require the %s upgrade strategy", upgradeStrategyBlueGreen)
+		}
+		if us.MaxSurge < 0 || us.MaxUnavailable < 0 {
+			return errors.New("maxSurge and maxUnavailable cannot be negative")
+		}
+		if us.MaxSurge == 0 && us.MaxUnavailable == 0 {
+			return errors.New("surge upgrades require a maxSurge or maxUnavailable of at least 1")
+		}
+		return nil
+	case upgradeStrategyBlueGreen:
+		if us.MaxSurge != 0 || us.MaxUnavailable != 0 {
+			return errors.Errorf("maxSurge and maxUnavailable require the %s upgrade strategy", upgradeStrategySurge)
+		}
+		return validateBlueGreenSettings(us.BlueGreenSettings)
+	default:
+		return errors.Errorf("upgrade strategy %q is not supported; use %s or %s", us.Strategy, upgradeStrategySurge, upgradeStrategyBlueGreen)
+	}
+}
+
+// validateBlueGreenSettings returns an error if the supplied blue/green
+// upgrade settings would be rejected by GKE.
+func validateBlueGreenSettings(bg *gcpcomputev1alpha1.BlueGreenSettings) error {
+	if bg == nil {
+		return nil
+	}
+
+	if err := validateSoakDuration("nodePoolSoakDuration", bg.NodePoolSoakDuration); err != nil {
+		return err
+	}
+
+	p := bg.StandardRolloutPolicy
+	if p == nil {
+		return nil
+	}
+	if p.BatchPercentage != 0 && p.BatchNodeCount != 0 {
+		return errors.New("batchPercentage and batchNodeCount are mutually exclusive")
+	}
+	if p.BatchPercentage < 0 || p.BatchPercentage > 1 {
+		return errors.Errorf("batchPercentage %v must be between 0 and 1", p.BatchPercentage)
+	}
+	if p.BatchNodeCount < 0 {
+		return errors.New("batchNodeCount cannot be negative")
+	}
+	return validateSoakDuration("batchSoakDuration", p.BatchSoakDuration)
+}
+
+// validateSoakDuration returns an error if the supplied soak duration is not
+// a number of seconds, or is longer than GKE allows. An empty soak duration is
+// defaulted by GKE.
+func validateSoakDuration(field, d string) error {
+	if d == "" {
+		return nil
+	}
+	if !soakDuration.MatchString(d) {
+		return errors.Errorf("%s %q must be a number of seconds, e.g. 3600s", field, d)
+	}
+	s, _ := strconv.ParseFloat(strings.TrimSuffix(d, "s"), 64)
+	if time.Duration(s*float64(time.Second)) > maxSoakDuration {
+		return errors.Errorf("%s %q cannot be longer than %s", field, d, maxSoakDuration)
+	}
+	return nil
+}
+
+// upgradeStrategy returns the upgrade strategy of the supplied settings. GKE
+// uses surge upgrades unless told otherwise.
+func upgradeStrategy(us *gcpcomputev1alpha1.UpgradeSettings) string {
+	if us.Strategy == "" {
+		return upgradeStrategySurge
+	}
+	return strings.ToUpper(us.Strategy)
+}
+
+// desiredUpgradeSettings returns the GKE upgrade settings described by the
+// supplied settings.
+func desiredUpgradeSettings(us *gcpcomputev1alpha1.UpgradeSettings) *container.UpgradeSettings {
+	d := &container.UpgradeSettings{Strategy: upgradeStrategy(us)}
+	if d.Strategy == upgradeStrategySurge {
+		d.MaxSurge = us.MaxSurge
+		d.MaxUnavailable = us.MaxUnavailable
+		// Send zero explicitly so that a node pool may be upgraded without
+		// surge nodes, or without unavailable nodes.
+		d.ForceSendFields = []string{"MaxSurge", "MaxUnavailable"}
+		return d
+	}
+
+	bg := us.BlueGreenSettings
+	if bg == nil {
+		return d
+	}
+	d.BlueGreenSettings = &container.BlueGreenSettings{NodePoolSoakDuration: bg.NodePoolSoakDuration}
+	if p := bg.StandardRolloutPolicy; p != nil {
+		d.BlueGreenSettings.StandardRolloutPolicy = &container.StandardRolloutPolicy{
+			BatchPercentage:   p.BatchPercentage,
+			BatchNodeCount:    p.BatchNodeCount,
+			BatchSoakDuration: p.BatchSoakDuration,
+		}
+	}
+	return d
+}
+
+// upgradeSettingsUpToDate returns true if the actual upgrade settings of a
+// node pool match the desired settings. Blue/green settings that are not
+// desired are defaulted by GKE, and thus ignored.
+func upgradeSettingsUpToDate(desired, actual *container.UpgradeSettings) bool {
+	if actual == nil {
+		return false
+	}
+	strategy := actual.Strategy
+	if strategy == "" {
+		strategy = upgradeStrategySurge
+	}
+	if desired.Strategy != strategy {
+		return false
+	}
+	if desired.Strategy == upgradeStrategySurge {
+		return desired.MaxSurge == actual.MaxSurge && desired.MaxUnavailable == actual.MaxUnavailable
+	}
+	return compare.Equal(desired.BlueGreenSettings, actual.BlueGreenSettings, compare.IgnoreUnset())
+}
+
+// nodePoolUpgradeSettingsUpdate returns the name of the next node pool of
+// the supplied cluster whose upgrade settings differ from the supplied spec,
+// and the request that updates them. It returns false if every node pool's
+// upgrade settings are up to date. Node pools that are yet to be created are
+// ignored.
+func nodePoolUpgradeSettingsUpdate(spec gcpcomputev1alpha1.GKEClusterSpec, cluster *container.Cluster) (string, *container.UpdateNodePoolRequest, bool) {
+	for _, np := range spec.NodePools {
+		if np.UpgradeSettings == nil {
+			continue
+		}
+		actual := nodePool(cluster, np.Name)
+		if actual == nil {
+			continue
+		}
+		desired := desiredUpgradeSettings(np.UpgradeSettings)
+		if upgradeSettingsUpToDate(desired, actual.UpgradeSettings) {
+			continue
+		}
+
+		// The node version and image type are required, so we send the
+		// node pool's current version and image type.
+		u := &container.UpdateNodePoolRequest{NodeVersion: actual.Version, UpgradeSettings: desired}
+		if actual.Config != nil {
+			u.ImageType = actual.Config.ImageType
+		}
+		return np.Name, u, true
+	}
+	return "", nil, false
+}
+
+// updateNodePool requests the supplied update to the named node pool of the
+// supplied cluster. The cluster is not running while the update is in
+// progress, so we wait for it before syncing the cluster again.
+func (r *Reconciler) updateNodePool(instance *gcpcomputev1alpha1.GKECluster, client gke.Client, name string, u *container.UpdateNodePoolRequest) (reconcile.Result, error) {
+	if plan.IsDryRun(instance) {
+		return r.plan(instance, plan.Describe("UpdateNodePool", map[string]interface{}{"cluster": instance.Status.ClusterName, "nodePool": name, "update": u}))
+	}
+
+	span := startPhase(client, tracing.PhaseUpdate)
+	if err := tracing.End(span, client.UpdateNodePool(instance.Spec.Zone, instance.Status.ClusterName, name, u)); err != nil {
+		return r.fail(instance, err)
+	}
+
+	instance.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return reconcile.Result{RequeueAfter: requeueOnWait},
+		errors.Wrapf(r.Update(ctx, instance), updateErrorMessageFormat, instance.GetName())
+}
@@ -0,0 +1,264 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	fakegcp "github.com/crossplaneio/crossplane/pkg/clients/gcp/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestValidateUpgradeSettings(t *testing.T) {
+	cases := map[string]struct {
+		us   *gcpcomputev1alpha1.UpgradeSettings
+		want error
+	}{
+		"Unset": {},
+		"Surge": {
+			us: &gcpcomputev1alpha1.UpgradeSettings{MaxSurge: 1},
+		},
+		"SurgeWithoutNodes": {
+			us:   &gcpcomputev1alpha1.UpgradeSettings{Strategy: upgradeStrategySurge},
+			want: errors.New("surge upgrades require a maxSurge or maxUnavailable of at least 1"),
+		},
+		"SurgeWithBlueGreenSettings": {
+			us: &gcpcomputev1alpha1.UpgradeSettings{
+				MaxSurge:          1,
+				BlueGreenSettings: &gcpcomputev1alpha1.BlueGreenSettings{NodePoolSoakDuration: "3600s"},
+			},
+			want: errors.New("blueGreenSettings require the BLUE_GREEN upgrade strategy"),
+		},
+		"BlueGreen": {
+			us: &gcpcomputev1alpha1.UpgradeSettings{
+				Strategy: "blue_green",
+				BlueGreenSettings: &gcpcomputev1alpha1.BlueGreenSettings{
+					NodePoolSoakDuration:  "7200s",
+					StandardRolloutPolicy: &gcpcomputev1alpha1.StandardRolloutPolicy{BatchPercentage: 0.25, BatchSoakDuration: "600s"},
+				},
+			},
+		},
+		"BlueGreenWithMaxSurge": {
+			us:   &gcpcomputev1alpha1.UpgradeSettings{Strategy: upgradeStrategyBlueGreen, MaxSurge: 1},
+			want: errors.New("maxSurge and maxUnavailable require the SURGE upgrade strategy"),
+		},
+		"SoakDurationNotSeconds": {
+			us: &gcpcomputev1alpha1.UpgradeSettings{
+				Strategy:          upgradeStrategyBlueGreen,
+				BlueGreenSettings: &gcpcomputev1alpha1.BlueGreenSettings{NodePoolSoakDuration: "1h"},
+			},
+			want: errors.New(`nodePoolSoakDuration "1h" must be a number of seconds, e.g. 3600s`),
+		},
+		"SoakDurationTooLong": {
+			us: &gcpcomputev1alpha1.UpgradeSettings{
+				Strategy:          upgradeStrategyBlueGreen,
+				BlueGreenSettings: &gcpcomputev1alpha1.BlueGreenSettings{NodePoolSoakDuration: "604801s"},
+			},
+			want: errors.New(`nodePoolSoakDuration "604801s" cannot be longer than 168h0m0s`),
+		},
+		"BatchSizeAmbiguous": {
+			us: &gcpcomputev1alpha1.UpgradeSettings{
+				Strategy: upgradeStrategyBlueGreen,
+				BlueGreenSettings: &gcpcomputev1alpha1.BlueGreenSettings{
+					StandardRolloutPolicy: &gcpcomputev1alpha1.StandardRolloutPolicy{BatchPercentage: 0.5, BatchNodeCount: 2},
+				},
+			},
+			want: errors.New("batchPercentage and batchNodeCount are mutually exclusive"),
+		},
+		"UnknownStrategy": {
+			us:   &gcpcomputev1alpha1.UpgradeSettings{Strategy: "ROLLING"},
+			want: errors.New(`upgrade strategy "ROLLING" is not supported; use SURGE or BLUE_GREEN`),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := validateUpgradeSettings(gcpcomputev1alpha1.NodePoolSpec{Name: "cool-pool", UpgradeSettings: tc.us})
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("validateUpgradeSettings(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNodePoolUpgradeSettingsUpdate(t *testing.T) {
+	blueGreen := &gcpcomputev1alpha1.UpgradeSettings{
+		Strategy:          upgradeStrategyBlueGreen,
+		BlueGreenSettings: &gcpcomputev1alpha1.BlueGreenSettings{NodePoolSoakDuration: "7200s"},
+	}
+	pool := func(us *container.UpgradeSettings) *container.NodePool {
+		return &container.NodePool{
+			Name:            "cool-pool",
+			Version:         "1.14.7-gke.14",
+			Config:          &container.NodeConfig{ImageType: "COS_CONTAINERD"},
+			UpgradeSettings: us,
+		}
+	}
+
+	type want struct {
+		name     string
+		settings *container.UpgradeSettings
+		ok       bool
+	}
+
+	cases := map[string]struct {
+		us      *gcpcomputev1alpha1.UpgradeSettings
+		cluster *container.Cluster
+		want    want
+	}{
+		"NotSpecified": {
+			cluster: &container.Cluster{NodePools: []*container.NodePool{pool(nil)}},
+		},
+		"NodePoolNotCreated": {
+			us:      blueGreen,
+			cluster: &container.Cluster{},
+		},
+		"SurgeUpToDate": {
+			us:      &gcpcomputev1alpha1.UpgradeSettings{MaxSurge: 1},
+			cluster: &container.Cluster{NodePools: []*container.NodePool{pool(&container.UpgradeSettings{Strategy: upgradeStrategySurge, MaxSurge: 1})}},
+		},
+		"SurgeChanged": {
+			us:      &gcpcomputev1alpha1.UpgradeSettings{MaxSurge: 3, MaxUnavailable: 1},
+			cluster: &container.Cluster{NodePools: []*container.NodePool{pool(&container.UpgradeSettings{Strategy: upgradeStrategySurge, MaxSurge: 1})}},
+			want: want{
+				name: "cool-pool",
+				settings: &container.UpgradeSettings{
+					Strategy:        upgradeStrategySurge,
+					MaxSurge:        3,
+					MaxUnavailable:  1,
+					ForceSendFields: []string{"MaxSurge", "MaxUnavailable"},
+				},
+				ok: true,
+			},
+		},
+		"SwitchToBlueGreen": {
+			us:      blueGreen,
+			cluster: &container.Cluster{NodePools: []*container.NodePool{pool(&container.UpgradeSettings{Strategy: upgradeStrategySurge, MaxSurge: 1})}},
+			want: want{
+				name: "cool-pool",
+				settings: &container.UpgradeSettings{
+					Strategy:          upgradeStrategyBlueGreen,
+					BlueGreenSettings: &container.BlueGreenSettings{NodePoolSoakDuration: "7200s"},
+				},
+				ok: true,
+			},
+		},
+		"BlueGreenDefaulted": {
+			us: blueGreen,
+			cluster: &container.Cluster{NodePools: []*container.NodePool{pool(&container.UpgradeSettings{
+				Strategy: upgradeStrategyBlueGreen,
+				BlueGreenSettings: &container.BlueGreenSettings{
+					NodePoolSoakDuration:  "7200s",
+					StandardRolloutPolicy: &container.StandardRolloutPolicy{BatchPercentage: 1},
+				},
+			})}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			spec := gcpcomputev1alpha1.GKEClusterSpec{NodePools: []gcpcomputev1alpha1.NodePoolSpec{{Name: "cool-pool", UpgradeSettings: tc.us}}}
+			np, u, ok := nodePoolUpgradeSettingsUpdate(spec, tc.cluster)
+			got := want{name: np, ok: ok}
+			if u != nil {
+				got.settings = u.UpgradeSettings
+				if u.NodeVersion != "1.14.7-gke.14" || u.ImageType != "COS_CONTAINERD" {
+					t.Errorf("nodePoolUpgradeSettingsUpdate(...): want current node version and image type, got %q and %q", u.NodeVersion, u.ImageType)
+				}
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("nodePoolUpgradeSettingsUpdate(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSyncUpgradeSettings(t *testing.T) {
+	cases := map[string]struct {
+		actual      *container.UpgradeSettings
+		wantUpdated bool
+		want        reconcile.Result
+	}{
+		"OutOfDate": {
+			actual:      &container.UpgradeSettings{Strategy: upgradeStrategySurge, MaxSurge: 1},
+			wantUpdated: true,
+			want:        reconcile.Result{RequeueAfter: requeueOnWait},
+		},
+		"UpToDate": {
+			actual: &container.UpgradeSettings{
+				Strategy:          upgradeStrategyBlueGreen,
+				BlueGreenSettings: &container.BlueGreenSettings{NodePoolSoakDuration: "3600s"},
+			},
+			want: reconcile.Result{RequeueAfter: requeueOnSucces},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			instance := testCluster()
+			instance.Spec.NodePools = []gcpcomputev1alpha1.NodePoolSpec{{
+				Name: "cool-pool",
+				UpgradeSettings: &gcpcomputev1alpha1.UpgradeSettings{
+					Strategy:          upgradeStrategyBlueGreen,
+					BlueGreenSettings: &gcpcomputev1alpha1.BlueGreenSettings{NodePoolSoakDuration: "3600s"},
+				},
+			}}
+			instance.Status.ClusterName = "gke-cool"
+
+			r := &Reconciler{
+				Client:     fakeclient.NewFakeClient(instance),
+				kubeclient: fake.NewSimpleClientset(),
+			}
+
+			updated := false
+			cl := fakegcp.NewGKEClient()
+			cl.MockGetCluster = func(string, string) (*container.Cluster, error) {
+				return &container.Cluster{
+					Status:     gcpcomputev1alpha1.ClusterStateRunning,
+					MasterAuth: masterAuth,
+					NodePools:  []*container.NodePool{{Name: "cool-pool", UpgradeSettings: tc.actual}},
+				}, nil
+			}
+			cl.MockUpdateNodePool = func(_, cluster, nodePool string, u *container.UpdateNodePoolRequest) error {
+				if cluster != "gke-cool" || nodePool != "cool-pool" {
+					t.Errorf("UpdateNodePool(...): want %s/%s, got %s/%s", "gke-cool", "cool-pool", cluster, nodePool)
+				}
+				updated = true
+				return nil
+			}
+
+			rs, err := r._sync(instance, cl)
+			if err != nil {
+				t.Fatalf("r._sync(...): %s", err)
+			}
+			if diff := cmp.Diff(tc.want, rs); diff != "" {
+				t.Errorf("r._sync(...): -want, +got:\n%s", diff)
+			}
+			if updated != tc.wantUpdated {
+				t.Errorf("UpdateNodePool(...): want called %t, got %t", tc.wantUpdated, updated)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+)
+
+// confidentialMachineFamilies are the machine families that support
+// Confidential GKE Nodes, which require AMD SEV capable hosts.
+var confidentialMachineFamilies = []string{"n2d", "c2d"}
+
+// sharedCoreMachineTypes are the machine types that cannot run GKE Sandbox
+// node pools.
+var sharedCoreMachineTypes = []string{"e2-micro", "e2-small", "e2-medium", "f1-micro", "g1-small"}
+
+const (
+	// sandboxTypeGVisor is the only sandbox supported by GKE Sandbox.
+	sandboxTypeGVisor = "GVISOR"
+
+	// sandboxImageType is the only node image type that supports GKE Sandbox.
+	sandboxImageType = "COS_CONTAINERD"
+)
+
+// kmsKeyName matches a fully qualified Cloud KMS crypto key name.
+var kmsKeyName = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/keyRings/[^/]+/cryptoKeys/[^/]+$`)
+
+// validateCluster returns an error if the supplied GKECluster spec can never
+// be successfully created. GKE reports many of these errors only once node
+// provisioning fails, so we check them up front.
+func validateCluster(spec gcpcomputev1alpha1.GKEClusterSpec) error {
+	if spec.Autopilot {
+		if err := validateAutopilot(spec); err != nil {
+			return err
+		}
+	}
+
+	if spec.EnableConfidentialNodes {
+		if err := validateConfidentialNodes(spec.MachineType); err != nil {
+			return err
+		}
+	}
+
+	if spec.BootDiskKMSKey != "" && !kmsKeyName.MatchString(spec.BootDiskKMSKey) {
+		return errors.Errorf("boot disk KMS key %q must be of the form projects/*/locations/*/keyRings/*/cryptoKeys/*", spec.BootDiskKMSKey)
+	}
+
+	for _, np := range spec.NodePools {
+		if spec.EnableConfidentialNodes {
+			if err := validateConfidentialNodes(np.MachineType); err != nil {
+				return errors.Wrapf(err, "node pool %q", np.Name)
+			}
+		}
+		if err := validateSandbox(np); err != nil {
+			return errors.Wrapf(err, "node pool %q", np.Name)
+		}
+		if err := validateUpgradeSettings(np); err != nil {
+			return errors.Wrapf(err, "node pool %q", np.Name)
+		}
+		if err := validateNodeConfig(np); err != nil {
+			return errors.Wrapf(err, "node pool %q", np.Name)
+		}
+		if err := validateNetworkPerformance(np); err != nil {
+			return errors.Wrapf(err, "node pool %q", np.Name)
+		}
+		if err := validateBootDisk(np); err != nil {
+			return errors.Wrapf(err, "node pool %q", np.Name)
+		}
+		if err := validateMinCPUPlatform(np); err != nil {
+			return errors.Wrapf(err, "node pool %q", np.Name)
+		}
+	}
+
+	if err := validateWorkloadMetadata(spec); err != nil {
+		return err
+	}
+
+	if spec.RemoveDefaultNodePool {
+		if err := validateRemoveDefaultNodePool(spec); err != nil {
+			return err
+		}
+	}
+
+	if err := validateHibernationSchedule(spec); err != nil {
+		return err
+	}
+
+	if err := validateDatabaseEncryption(spec); err != nil {
+		return err
+	}
+
+	if err := validateDNSConfig(spec); err != nil {
+		return err
+	}
+
+	if err := validateAutoscaling(spec); err != nil {
+		return err
+	}
+
+	if err := validateLabelDriftPolicy(spec); err != nil {
+		return err
+	}
+
+	if err := validateMaintenanceWindow(spec); err != nil {
+		return err
+	}
+
+	return validateNotifications(spec.NotificationConfig)
+}
+
+// validateRemoveDefaultNodePool returns an error if the supplied GKECluster
+// spec would be left without a usable node pool once its default node pool is
+// removed.
+func validateRemoveDefaultNodePool(spec gcpcomputev1alpha1.GKEClusterSpec) error {
+	if len(spec.NodePools) == 0 {
+		return errors.New("removeDefaultNodePool requires at least one node pool")
+	}
+
+	unsandboxed := false
+	for _, np := range spec.NodePools {
+		if np.Name == defaultNodePoolName {
+			return errors.Errorf("node pool %q would be removed by removeDefaultNodePool; use a different name", np.Name)
+		}
+		if np.SandboxConfig == nil {
+			unsandboxed = true
+		}
+	}
+
+	// GKE Sandbox runs system workloads on an unsandboxed node pool, which is
+	// usually the default node pool.
+	if !unsandboxed {
+		return errors.New("removeDefaultNodePool requires at least one node pool that is not sandboxed")
+	}
+
+	return nil
+}
+
+// validateSandbox returns an error if the supplied node pool is sandboxed but
+// its image type or machine type cannot run GKE Sandbox. The cluster's default
+// node pool cannot be sandboxed, which ensures every cluster retains the
+// unsandboxed node pool GKE Sandbox requires for system workloads.
+func validateSandbox(np gcpcomputev1alpha1.NodePoolSpec) error {
+	if np.SandboxConfig == nil {
+		return nil
+	}
+
+	if t := np.SandboxConfig.Type; !strings.EqualFold(t, sandboxTypeGVisor) {
+		return errors.Errorf("sandbox type %q is not supported; use gvisor", t)
+	}
+
+	// GKE uses the COS_CONTAINERD image type when none is specified.
+	if np.ImageType != "" && !strings.EqualFold(np.ImageType, sandboxImageType) {
+		return errors.Errorf("sandboxed node pools must use the %s image type, not %q", sandboxImageType, np.ImageType)
+	}
+
+	for _, mt := range sharedCoreMachineTypes {
+		if strings.EqualFold(np.MachineType, mt) {
+			return errors.Errorf("sandboxed node pools cannot use shared-core machine type %q", np.MachineType)
+		}
+	}
+
+	return nil
+}
+
+// validateAutopilot returns an error if the supplied Autopilot GKECluster spec
+// sets fields that GKE manages on behalf of Autopilot clusters.
+func validateAutopilot(spec gcpcomputev1alpha1.GKEClusterSpec) error {
+	set := make([]string, 0)
+	if spec.MachineType != "" {
+		set = append(set, "machineType")
+	}
+	if spec.NumNodes != 0 {
+		set = append(set, "numNodes")
+	}
+	if len(spec.NodePools) != 0 {
+		set = append(set, "nodePools")
+	}
+	if len(set) > 0 {
+		return errors.Errorf("node pool fields %s cannot be set for Autopilot clusters", strings.Join(set, ", "))
+	}
+
+	if !isRegion(spec.Zone) {
+		return errors.Errorf("clusters using Autopilot must be regional; %q is not a region", spec.Zone)
+	}
+
+	return nil
+}
+
+// isRegion returns true if the supplied location is a region, e.g. us-central1,
+// rather than a zone within a region, e.g. us-central1-a.
+func isRegion(location string) bool {
+	return strings.Count(location, "-") == 1
+}
+
+// machineFamily returns the family of the supplied machine type, e.g. n2d for
+// n2d-standard-4.
+func machineFamily(machineType string) string {
+	return strings.ToLower(strings.SplitN(machineType, "-", 2)[0])
+}
+
+// validateConfidentialNodes returns an error if the supplied machine type
+// can't run Confidential GKE Nodes, which every node of a cluster with
+// confidential nodes enabled must be.
+func validateConfidentialNodes(machineType string) error {
+	if supportsConfidentialNodes(machineType) {
+		return nil
+	}
+	return errors.Errorf("machine type %q does not support confidential nodes; use one of the %s machine families",
+		machineType, strings.Join(confidentialMachineFamilies, ", "))
+}
+
+func supportsConfidentialNodes(machineType string) bool {
+	f := machineFamily(machineType)
+	for _, cf := range confidentialMachineFamilies {
+		if f == cf {
+			return true
+		}
+	}
+	return false
+}
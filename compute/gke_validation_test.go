@@ -0,0 +1,183 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestValidateCluster(t *testing.T) {
+	validKey := "projects/p/locations/us-central1/keyRings/r/cryptoKeys/k"
+
+	cases := map[string]struct {
+		spec gcpcomputev1alpha1.GKEClusterSpec
+		want error
+	}{
+		"Empty": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{},
+		},
+		"ConfidentialNodesSupported": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{
+				EnableConfidentialNodes: true,
+				MachineType:             "n2d-standard-4",
+				BootDiskKMSKey:          validKey,
+			},
+		},
+		"ConfidentialNodesUnsupported": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{
+				EnableConfidentialNodes: true,
+				MachineType:             "n1-standard-1",
+			},
+			want: errors.New(`machine type "n1-standard-1" does not support confidential nodes; use one of the n2d, c2d machine families`),
+		},
+		"ConfidentialNodesNodePoolSupported": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{
+				EnableConfidentialNodes: true,
+				MachineType:             "n2d-standard-4",
+				NodePools: []gcpcomputev1alpha1.NodePoolSpec{{
+					Name:        "batch",
+					MachineType: "c2d-standard-8",
+				}},
+			},
+		},
+		"ConfidentialNodesNodePoolUnsupported": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{
+				EnableConfidentialNodes: true,
+				MachineType:             "n2d-standard-4",
+				NodePools: []gcpcomputev1alpha1.NodePoolSpec{{
+					Name:        "batch",
+					MachineType: "e2-standard-8",
+				}},
+			},
+			want: errors.Wrapf(errors.New(`machine type "e2-standard-8" does not support confidential nodes; use one of the n2d, c2d machine families`), "node pool %q", "batch"),
+		},
+		"UnknownAutoscalingProfile": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{AutoscalingProfile: "CHEAP"},
+			want: errors.New(`autoscaling profile "CHEAP" must be BALANCED or OPTIMIZE_UTILIZATION`),
+		},
+		"InvalidBootDiskKMSKey": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{BootDiskKMSKey: "my-key"},
+			want: errors.New(`boot disk KMS key "my-key" must be of the form projects/*/locations/*/keyRings/*/cryptoKeys/*`),
+		},
+		"SandboxedNodePool": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{NodePools: []gcpcomputev1alpha1.NodePoolSpec{{
+				Name:          "untrusted",
+				MachineType:   "n1-standard-4",
+				ImageType:     "cos_containerd",
+				SandboxConfig: &gcpcomputev1alpha1.SandboxConfig{Type: "gvisor"},
+			}}},
+		},
+		"SandboxUnsupportedType": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{NodePools: []gcpcomputev1alpha1.NodePoolSpec{{
+				Name:          "untrusted",
+				SandboxConfig: &gcpcomputev1alpha1.SandboxConfig{Type: "kata"},
+			}}},
+			want: errors.New(`node pool "untrusted": sandbox type "kata" is not supported; use gvisor`),
+		},
+		"SandboxUnsupportedImageType": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{NodePools: []gcpcomputev1alpha1.NodePoolSpec{{
+				Name:          "untrusted",
+				ImageType:     "UBUNTU_CONTAINERD",
+				SandboxConfig: &gcpcomputev1alpha1.SandboxConfig{Type: "gvisor"},
+			}}},
+			want: errors.New(`node pool "untrusted": sandboxed node pools must use the COS_CONTAINERD image type, not "UBUNTU_CONTAINERD"`),
+		},
+		"SandboxSharedCoreMachineType": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{NodePools: []gcpcomputev1alpha1.NodePoolSpec{{
+				Name:          "untrusted",
+				MachineType:   "e2-small",
+				SandboxConfig: &gcpcomputev1alpha1.SandboxConfig{Type: "gvisor"},
+			}}},
+			want: errors.New(`node pool "untrusted": sandboxed node pools cannot use shared-core machine type "e2-small"`),
+		},
+		"RemoveDefaultNodePool": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{
+				RemoveDefaultNodePool: true,
+				NodePools:             []gcpcomputev1alpha1.NodePoolSpec{{Name: "cool-pool"}},
+			},
+		},
+		"RemoveDefaultNodePoolWithoutNodePools": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{RemoveDefaultNodePool: true},
+			want: errors.New("removeDefaultNodePool requires at least one node pool"),
+		},
+		"RemoveDefaultNodePoolNamedDefault": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{
+				RemoveDefaultNodePool: true,
+				NodePools:             []gcpcomputev1alpha1.NodePoolSpec{{Name: "default-pool"}},
+			},
+			want: errors.New(`node pool "default-pool" would be removed by removeDefaultNodePool; use a different name`),
+		},
+		"RemoveDefaultNodePoolOnlySandboxed": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{
+				RemoveDefaultNodePool: true,
+				NodePools: []gcpcomputev1alpha1.NodePoolSpec{{
+					Name:          "untrusted",
+					SandboxConfig: &gcpcomputev1alpha1.SandboxConfig{Type: "gvisor"},
+				}},
+			},
+			want: errors.New("removeDefaultNodePool requires at least one node pool that is not sandboxed"),
+		},
+		"Autopilot": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{Autopilot: true, Zone: "us-central1"},
+		},
+		"AutopilotNodePoolFields": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{
+				Autopilot:   true,
+				Zone:        "us-central1",
+				MachineType: "n1-standard-1",
+				NumNodes:    3,
+				NodePools:   []gcpcomputev1alpha1.NodePoolSpec{{Name: "untrusted"}},
+			},
+			want: errors.New("node pool fields machineType, numNodes, nodePools cannot be set for Autopilot clusters"),
+		},
+		"UnsupportedNotificationEventType": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{NotificationConfig: &gcpcomputev1alpha1.NotificationConfig{
+				Topic:      "upgrades",
+				EventTypes: []string{"UPGRADE_EVENT", "NODE_EVENT"},
+			}},
+			want: errors.New(`notification event type "NODE_EVENT" is not supported; use one of UPGRADE_AVAILABLE_EVENT, UPGRADE_EVENT, SECURITY_BULLETIN_EVENT`),
+		},
+		"HibernationSchedule": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{HibernationSchedule: &gcpcomputev1alpha1.HibernationSchedule{
+				Windows: []gcpcomputev1alpha1.HibernationWindow{{Start: "0 19 * * 1-5", End: "0 7 * * 1-5"}},
+			}},
+		},
+		"HibernationScheduleAutopilot": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{Autopilot: true, Zone: "us-central1", HibernationSchedule: &gcpcomputev1alpha1.HibernationSchedule{}},
+			want: errors.New("clusters using Autopilot cannot be hibernated; GKE manages their nodes"),
+		},
+		"AutopilotZonal": {
+			spec: gcpcomputev1alpha1.GKEClusterSpec{Autopilot: true, Zone: "us-central1-a"},
+			want: errors.New(`clusters using Autopilot must be regional; "us-central1-a" is not a region`),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := validateCluster(tc.spec)
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("validateCluster(...): -want error, +got error:\n%s", diff)
+			}
+		})
+	}
+}
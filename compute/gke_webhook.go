@@ -0,0 +1,96 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+
+	"github.com/pkg/errors"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+)
+
+// GKEClusterValidationPath is the path at which the GKECluster validating
+// webhook is served. It must be registered for the v1alpha1 API version.
+const GKEClusterValidationPath = "/validate/gkeclusters.compute.gcp.crossplane.io"
+
+// GKEClusterValidationWebhook rejects GKEClusters whose spec can never be
+// successfully created, for example Autopilot clusters that set node pool
+// fields. Without it the controller only reports such specs once it attempts
+// to create the cluster.
+type GKEClusterValidationWebhook struct{}
+
+// ServeHTTP handles an AdmissionReview sent by the API server.
+func (w *GKEClusterValidationWebhook) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	review := &admissionv1beta1.AdmissionReview{}
+	if err := json.NewDecoder(req.Body).Decode(review); err != nil || review.Request == nil {
+		http.Error(rw, "cannot decode admission review", http.StatusBadRequest)
+		return
+	}
+
+	review.Response = admitGKECluster(review.Request)
+	review.Request = nil
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(review); err != nil {
+		log.Error(err, "cannot encode admission review")
+	}
+}
+
+// admitGKECluster allows the supplied AdmissionRequest unless it creates a
+// GKECluster with an invalid spec, or is an update that makes the spec
+// invalid. Updates that don't change the spec are always allowed, so that
+// existing GKEClusters whose spec is invalid may still be deleted.
+func admitGKECluster(req *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	rsp := &admissionv1beta1.AdmissionResponse{UID: req.UID, Allowed: true}
+	switch req.Operation {
+	case admissionv1beta1.Create, admissionv1beta1.Update:
+	default:
+		return rsp
+	}
+
+	updated := &gcpcomputev1alpha1.GKECluster{}
+	if err := json.Unmarshal(req.Object.Raw, updated); err != nil {
+		return deny(rsp, errors.Wrap(err, "cannot decode GKECluster"))
+	}
+
+	if req.Operation == admissionv1beta1.Update {
+		old := &gcpcomputev1alpha1.GKECluster{}
+		if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+			return deny(rsp, errors.Wrap(err, "cannot decode existing GKECluster"))
+		}
+		if reflect.DeepEqual(old.Spec, updated.Spec) {
+			return rsp
+		}
+	}
+
+	return deny(rsp, validateCluster(updated.Spec))
+}
+
+// deny denies the supplied response with the supplied error, if any.
+func deny(rsp *admissionv1beta1.AdmissionResponse, err error) *admissionv1beta1.AdmissionResponse {
+	if err == nil {
+		return rsp
+	}
+	rsp.Allowed = false
+	rsp.Result = &metav1.Status{Status: metav1.StatusFailure, Reason: metav1.StatusReasonInvalid, Message: err.Error()}
+	return rsp
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+)
+
+func webhookCluster(spec gcpcomputev1alpha1.GKEClusterSpec) *gcpcomputev1alpha1.GKECluster {
+	return &gcpcomputev1alpha1.GKECluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "cool", Name: "cool-cluster"},
+		Spec:       spec,
+	}
+}
+
+func TestGKEClusterValidationWebhook(t *testing.T) {
+	autopilot := gcpcomputev1alpha1.GKEClusterSpec{Autopilot: true, Zone: "us-central1"}
+	autopilotNodes := gcpcomputev1alpha1.GKEClusterSpec{Autopilot: true, Zone: "us-central1", MachineType: "n1-standard-1"}
+
+	cases := map[string]struct {
+		op          admissionv1beta1.Operation
+		old         *gcpcomputev1alpha1.GKECluster
+		updated     *gcpcomputev1alpha1.GKECluster
+		wantAllowed bool
+	}{
+		"CreateAllowed": {
+			op:          admissionv1beta1.Create,
+			updated:     webhookCluster(autopilot),
+			wantAllowed: true,
+		},
+		"CreateAutopilotNodePoolFieldsDenied": {
+			op:      admissionv1beta1.Create,
+			updated: webhookCluster(autopilotNodes),
+		},
+		"UpdateAutopilotNodePoolFieldsDenied": {
+			op:      admissionv1beta1.Update,
+			old:     webhookCluster(autopilot),
+			updated: webhookCluster(autopilotNodes),
+		},
+		"UpdateUnchangedInvalidSpecAllowed": {
+			op:          admissionv1beta1.Update,
+			old:         webhookCluster(autopilotNodes),
+			updated:     webhookCluster(autopilotNodes),
+			wantAllowed: true,
+		},
+		"DeleteAllowed": {
+			op:          admissionv1beta1.Delete,
+			updated:     webhookCluster(autopilotNodes),
+			wantAllowed: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			review := &admissionv1beta1.AdmissionReview{
+				Request: &admissionv1beta1.AdmissionRequest{
+					UID:       types.UID("cool-uid"),
+					Operation: tc.op,
+					Object:    runtime.RawExtension{Raw: mustMarshal(t, tc.updated)},
+				},
+			}
+			if tc.old != nil {
+				review.Request.OldObject = runtime.RawExtension{Raw: mustMarshal(t, tc.old)}
+			}
+
+			rec := httptest.NewRecorder()
+			(&GKEClusterValidationWebhook{}).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, GKEClusterValidationPath, bytes.NewReader(mustMarshal(t, review))))
+
+			got := &admissionv1beta1.AdmissionReview{}
+			if err := json.Unmarshal(rec.Body.Bytes(), got); err != nil {
+				t.Fatalf("json.Unmarshal(...): %s", err)
+			}
+			if got.Response == nil {
+				t.Fatal("AdmissionReview.Response: want response, got nil")
+			}
+			if got.Response.UID != review.Request.UID {
+				t.Errorf("AdmissionReview.Response.UID: want %s, got %s", review.Request.UID, got.Response.UID)
+			}
+			if got.Response.Allowed != tc.wantAllowed {
+				t.Errorf("AdmissionReview.Response.Allowed: want %t, got %t", tc.wantAllowed, got.Response.Allowed)
+			}
+			if !tc.wantAllowed && (got.Response.Result == nil || got.Response.Result.Message == "") {
+				t.Errorf("AdmissionReview.Response.Result: want a message explaining the denial, got %+v", got.Response.Result)
+			}
+		})
+	}
+}
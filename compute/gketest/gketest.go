@@ -0,0 +1,256 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gketest contains helpers for table driven tests of the GKECluster
+// reconciler. It builds GKEClusters and the GKE clusters reported for them,
+// fakes the clients and phases injected into the reconciler, and asserts on
+// the conditions and connection secrets the reconciler writes.
+package gketest
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/container/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/fake"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/gke"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+// Credentials reported in the MasterAuth of clusters built by Running.
+const (
+	Username = "test-user"
+	Password = "test-pass"
+	CA       = "test-ca"
+	Cert     = "test-cert"
+	Key      = "test-key"
+	Endpoint = "10.0.0.1"
+)
+
+// A ClusterModifier modifies a GKECluster.
+type ClusterModifier func(*v1alpha1.GKECluster)
+
+// WithProvider sets the Provider referenced by a GKECluster.
+func WithProvider(namespace, name string) ClusterModifier {
+	return func(c *v1alpha1.GKECluster) {
+		c.Spec.ProviderReference = &corev1.ObjectReference{Namespace: namespace, Name: name}
+	}
+}
+
+// WithClusterName sets the name of a GKECluster's GKE cluster, marking it as
+// created.
+func WithClusterName(n string) ClusterModifier {
+	return func(c *v1alpha1.GKECluster) { c.Status.ClusterName = n }
+}
+
+// WithDeletionTimestamp marks a GKECluster as deleted.
+func WithDeletionTimestamp() ClusterModifier {
+	return func(c *v1alpha1.GKECluster) {
+		now := metav1.Now()
+		c.DeletionTimestamp = &now
+	}
+}
+
+// WithFinalizers sets the finalizers of a GKECluster.
+func WithFinalizers(f ...string) ClusterModifier {
+	return func(c *v1alpha1.GKECluster) { c.Finalizers = f }
+}
+
+// WithAnnotations sets the annotations of a GKECluster.
+func WithAnnotations(a map[string]string) ClusterModifier {
+	return func(c *v1alpha1.GKECluster) { c.Annotations = a }
+}
+
+// WithConditions sets the conditions of a GKECluster.
+func WithConditions(cs ...corev1alpha1.Condition) ClusterModifier {
+	return func(c *v1alpha1.GKECluster) { c.Status.SetConditions(cs...) }
+}
+
+// WithSpec applies the supplied function to the spec of a GKECluster. It is
+// intended for setting fields that lack a dedicated modifier.
+func WithSpec(fn func(*v1alpha1.GKEClusterSpec)) ClusterModifier {
+	return func(c *v1alpha1.GKECluster) { fn(&c.Spec) }
+}
+
+// Cluster returns a GKECluster with the supplied namespace and name, modified
+// by the supplied modifiers.
+func Cluster(namespace, name string, cm ...ClusterModifier) *v1alpha1.GKECluster {
+	c := &v1alpha1.GKECluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+	}
+	for _, m := range cm {
+		m(c)
+	}
+	return c
+}
+
+// A GKEClusterModifier modifies a GKE cluster, as reported by the GKE API.
+type GKEClusterModifier func(*container.Cluster)
+
+// WithStatus sets the status of a GKE cluster.
+func WithStatus(s string) GKEClusterModifier {
+	return func(c *container.Cluster) { c.Status = s }
+}
+
+// WithConditionMessages adds conditions with the supplied messages to a GKE
+// cluster.
+func WithConditionMessages(code string, messages ...string) GKEClusterModifier {
+	return func(c *container.Cluster) {
+		for _, m := range messages {
+			c.Conditions = append(c.Conditions, &container.StatusCondition{Code: code, Message: m})
+		}
+	}
+}
+
+// WithNodePools sets the node pools of a GKE cluster.
+func WithNodePools(np ...*container.NodePool) GKEClusterModifier {
+	return func(c *container.Cluster) { c.NodePools = np }
+}
+
+// GKECluster returns a GKE cluster with the supplied name that is still being
+// provisioned, modified by the supplied modifiers.
+func GKECluster(name string, gm ...GKEClusterModifier) *container.Cluster {
+	c := &container.Cluster{Name: name, Status: v1alpha1.ClusterStateProvisioning}
+	for _, m := range gm {
+		m(c)
+	}
+	return c
+}
+
+// Running returns a running GKE cluster with the supplied name, whose endpoint
+// and credentials are the constants of this package, modified by the supplied
+// modifiers.
+func Running(name string, gm ...GKEClusterModifier) *container.Cluster {
+	c := GKECluster(name, WithStatus(v1alpha1.ClusterStateRunning))
+	c.Endpoint = Endpoint
+	c.MasterAuth = MasterAuth()
+	for _, m := range gm {
+		m(c)
+	}
+	return c
+}
+
+// MasterAuth returns the MasterAuth of a GKE cluster whose credentials are
+// the constants of this package.
+func MasterAuth() *container.MasterAuth {
+	return &container.MasterAuth{
+		Username:             Username,
+		Password:             Password,
+		ClusterCaCertificate: base64.StdEncoding.EncodeToString([]byte(CA)),
+		ClientCertificate:    base64.StdEncoding.EncodeToString([]byte(Cert)),
+		ClientKey:            base64.StdEncoding.EncodeToString([]byte(Key)),
+	}
+}
+
+// SecretData returns the connection secret data published for a cluster built
+// by Running.
+func SecretData() map[string][]byte {
+	return map[string][]byte{
+		corev1alpha1.ResourceCredentialsSecretEndpointKey:   []byte(Endpoint),
+		corev1alpha1.ResourceCredentialsSecretUserKey:       []byte(Username),
+		corev1alpha1.ResourceCredentialsSecretPasswordKey:   []byte(Password),
+		corev1alpha1.ResourceCredentialsSecretCAKey:         []byte(CA),
+		corev1alpha1.ResourceCredentialsSecretClientCertKey: []byte(Cert),
+		corev1alpha1.ResourceCredentialsSecretClientKeyKey:  []byte(Key),
+	}
+}
+
+// NewClient returns a fake GKE client that reports the supplied cluster, and
+// creates and deletes clusters successfully. Its Mock functions may be
+// overridden to inject errors.
+func NewClient(c *container.Cluster) *fake.GKEClient {
+	cl := fake.NewGKEClient()
+	cl.MockGetCluster = func(string, string) (*container.Cluster, error) { return c, nil }
+	cl.MockCreateCluster = func(string, v1alpha1.GKEClusterSpec) (*container.Cluster, error) { return c, nil }
+	cl.MockDeleteCluster = func(string, string) error { return nil }
+	return cl
+}
+
+// Connect returns a connect function, as injected into the reconciler, that
+// returns the supplied client and error.
+func Connect(cl gke.Client, err error) func(*v1alpha1.GKECluster) (gke.Client, error) {
+	return func(*v1alpha1.GKECluster) (gke.Client, error) { return cl, err }
+}
+
+// A Phase is a fake create, sync, or delete phase of the reconciler. It
+// records the GKEClusters it is called with.
+type Phase struct {
+	Result reconcile.Result
+	Err    error
+
+	// Called records the names of the GKEClusters the phase was called with.
+	Called []string
+}
+
+// Func returns the phase function to inject into the reconciler.
+func (p *Phase) Func() func(*v1alpha1.GKECluster, gke.Client) (reconcile.Result, error) {
+	return func(c *v1alpha1.GKECluster, _ gke.Client) (reconcile.Result, error) {
+		p.Called = append(p.Called, c.GetName())
+		return p.Result, p.Err
+	}
+}
+
+// AssertConditions fails the test if the GKECluster with the supplied key
+// does not have exactly the supplied conditions.
+func AssertConditions(t *testing.T, kube client.Reader, key types.NamespacedName, want ...corev1alpha1.Condition) *v1alpha1.GKECluster {
+	t.Helper()
+	got := &v1alpha1.GKECluster{}
+	if err := kube.Get(context.Background(), key, got); err != nil {
+		t.Fatalf("cannot get GKECluster %s: %v", key, err)
+	}
+	ws := corev1alpha1.ConditionedStatus{}
+	ws.SetConditions(want...)
+	if diff := cmp.Diff(ws, got.Status.ConditionedStatus, test.EquateConditions()); diff != "" {
+		t.Errorf("GKECluster %s conditions: -want, +got:\n%s", key, diff)
+	}
+	return got
+}
+
+// AssertSecret fails the test if the secret with the supplied key does not
+// exist, or if its data differs from the supplied data.
+func AssertSecret(t *testing.T, kube kubernetes.Interface, key types.NamespacedName, want map[string][]byte) *corev1.Secret {
+	t.Helper()
+	got, err := kube.CoreV1().Secrets(key.Namespace).Get(key.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("cannot get secret %s: %v", key, err)
+	}
+	if diff := cmp.Diff(want, got.Data); diff != "" {
+		t.Errorf("secret %s data: -want, +got:\n%s", key, diff)
+	}
+	return got
+}
+
+// AssertNoSecret fails the test if the secret with the supplied key exists.
+func AssertNoSecret(t *testing.T, kube kubernetes.Interface, key types.NamespacedName) {
+	t.Helper()
+	if _, err := kube.CoreV1().Secrets(key.Namespace).Get(key.Name, metav1.GetOptions{}); err == nil {
+		t.Errorf("secret %s: want not found, got found", key)
+	}
+}
@@ -0,0 +1,415 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	compute "google.golang.org/api/compute/v1"
+	iapv1 "google.golang.org/api/iap/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/iap"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/network"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	iapTunnelControllerName = "iaptunnels.compute.gcp.crossplane.io"
+	iapTunnelFinalizer      = "finalizer." + iapTunnelControllerName
+	iapTunnelNamePrefix     = "iap-"
+
+	iapTunnelReconcileTimeout = 1 * time.Minute
+
+	// iapSourceRange is the range from which IAP TCP forwarding connects to
+	// the instances it tunnels to.
+	iapSourceRange = "35.235.240.0/20"
+
+	// iapTunnelAccessorRole allows members to tunnel to instances using IAP.
+	iapTunnelAccessorRole = "roles/iap.tunnelResourceAccessor"
+)
+
+var iapTunnelLog = logging.Logger.WithName("controller." + iapTunnelControllerName)
+
+// An iapTunnelCreateSyncDeleter can create, sync, and delete IAP TCP
+// forwarding configuration in an external store - e.g. the GCP API. Each
+// method returns true if the tunnel requires further reconciliation.
+type iapTunnelCreateSyncDeleter interface {
+	Create(ctx context.Context, t *gcpcomputev1alpha1.IAPTunnel) (requeue bool)
+	Sync(ctx context.Context, t *gcpcomputev1alpha1.IAPTunnel) (requeue bool)
+	Delete(ctx context.Context, t *gcpcomputev1alpha1.IAPTunnel) (requeue bool)
+}
+
+// iapTunnels is an iapTunnelCreateSyncDeleter using the GCP Compute and IAP
+// APIs. Each tunnel is a firewall rule allowing IAP to reach the tunnel's
+// target instances, and the members allowed to use IAP to connect to them.
+type iapTunnels struct {
+	network network.Client
+	iap     iap.Client
+	project string
+}
+
+// Create adds the firewall rule that allows IAP to reach the target
+// instances. Members are granted access to the tunnel when it is synced.
+func (c *iapTunnels) Create(ctx context.Context, t *gcpcomputev1alpha1.IAPTunnel) bool {
+	t.Status.SetConditions(corev1alpha1.Creating())
+
+	if len(t.Spec.Ports) == 0 {
+		t.Status.SetConditions(corev1alpha1.ReconcileError(errors.New("an IAP tunnel must allow at least one port")))
+		return true
+	}
+
+	meta.AddFinalizer(t, iapTunnelFinalizer)
+
+	name := fmt.Sprintf("%s%s", iapTunnelNamePrefix, t.GetUID())
+	if err := c.network.InsertFirewall(ctx, c.project, newIAPFirewall(c.project, name, t.Spec)); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		t.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot insert IAP firewall rule")))
+		return true
+	}
+
+	t.Status.FirewallName = name
+	t.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync updates the firewall rule if it differs from the tunnel's spec, and
+// grants tunnel access to exactly the members of the spec.
+func (c *iapTunnels) Sync(ctx context.Context, t *gcpcomputev1alpha1.IAPTunnel) bool {
+	actual, err := c.network.GetFirewall(ctx, c.project, t.Status.FirewallName)
+	if googleapi.IsErrorNotFound(err) {
+		// The firewall rule was deleted outside of Crossplane. Add it again.
+		t.Status.SetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(errors.Errorf("IAP firewall rule %s not found", t.Status.FirewallName)))
+		t.Status.FirewallName = ""
+		return true
+	}
+	if err != nil {
+		t.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot get IAP firewall rule %s", t.Status.FirewallName)))
+		return true
+	}
+
+	desired := newIAPFirewall(c.project, t.Status.FirewallName, t.Spec)
+	if !iapFirewallUpToDate(desired, actual) {
+		if err := c.network.PatchFirewall(ctx, c.project, t.Status.FirewallName, desired); err != nil {
+			t.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot update IAP firewall rule")))
+			return true
+		}
+	}
+
+	if err := c.grantAccess(ctx, t); err != nil {
+		t.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	t.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete removes the firewall rule, and revokes tunnel access from the members
+// it was granted to.
+func (c *iapTunnels) Delete(ctx context.Context, t *gcpcomputev1alpha1.IAPTunnel) bool {
+	t.Status.SetConditions(corev1alpha1.Deleting())
+
+	if t.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.network.DeleteFirewall(ctx, c.project, t.Status.FirewallName); err != nil && !googleapi.IsErrorNotFound(err) {
+			t.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete IAP firewall rule")))
+			return true
+		}
+		if err := c.revokeAccess(ctx, t); err != nil {
+			t.Status.SetConditions(corev1alpha1.ReconcileError(err))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(t, iapTunnelFinalizer)
+	t.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// grantAccess grants tunnel access to the members of the supplied tunnel's
+// spec, and revokes it from members that were previously granted access but
+// have since been removed from its spec.
+func (c *iapTunnels) grantAccess(ctx context.Context, t *gcpcomputev1alpha1.IAPTunnel) error {
+	p, err := c.iap.GetTunnelIamPolicy(ctx, c.project)
+	if err != nil {
+		return errors.Wrap(err, "cannot get IAP tunnel IAM policy")
+	}
+
+	desired := map[string]bool{}
+	changed := false
+	for _, m := range t.Spec.Members {
+		desired[m] = true
+		changed = addTunnelAccessor(p, m) || changed
+	}
+	for _, m := range t.Status.Members {
+		if !desired[m] {
+			changed = removeTunnelAccessor(p, m) || changed
+		}
+	}
+
+	if changed {
+		if err := c.iap.SetTunnelIamPolicy(ctx, c.project, p); err != nil {
+			return errors.Wrap(err, "cannot set IAP tunnel IAM policy")
+		}
+	}
+
+	t.Status.Members = t.Spec.Members
+	return nil
+}
+
+// revokeAccess revokes tunnel access from the members the supplied tunnel
+// granted it to.
+func (c *iapTunnels) revokeAccess(ctx context.Context, t *gcpcomputev1alpha1.IAPTunnel) error {
+	if len(t.Status.Members) == 0 {
+		return nil
+	}
+
+	p, err := c.iap.GetTunnelIamPolicy(ctx, c.project)
+	if err != nil {
+		return errors.Wrap(err, "cannot get IAP tunnel IAM policy")
+	}
+
+	changed := false
+	for _, m := range t.Status.Members {
+		changed = removeTunnelAccessor(p, m) || changed
+	}
+
+	if changed {
+		if err := c.iap.SetTunnelIamPolicy(ctx, c.project, p); err != nil {
+			return errors.Wrap(err, "cannot set IAP tunnel IAM policy")
+		}
+	}
+
+	t.Status.Members = nil
+	return nil
+}
+
+// newIAPFirewall returns a firewall rule with the supplied name that allows
+// IAP to reach the targets described by the supplied spec.
+func newIAPFirewall(project, name string, spec gcpcomputev1alpha1.IAPTunnelSpec) *compute.Firewall {
+	return &compute.Firewall{
+		Name:                  name,
+		Description:           "Allows IAP TCP forwarding. Managed by Crossplane.",
+		Network:               networkURL(project, spec.Network),
+		Direction:             "INGRESS",
+		SourceRanges:          []string{iapSourceRange},
+		Allowed:               []*compute.FirewallAllowed{{IPProtocol: "tcp", Ports: spec.Ports}},
+		TargetTags:            spec.TargetTags,
+		TargetServiceAccounts: spec.TargetServiceAccounts,
+		// Send empty targets explicitly so that they may be removed.
+		ForceSendFields: []string{"TargetTags", "TargetServiceAccounts"},
+	}
+}
+
+// iapFirewallUpToDate returns true if the supplied actual firewall rule allows
+// the same traffic to the same targets as the supplied desired rule. GCP may
+// return ranges, ports, and targets in any order.
+func iapFirewallUpToDate(desired, actual *compute.Firewall) bool {
+	return compare.Equal(desired.SourceRanges, actual.SourceRanges, compare.Unordered("")) &&
+		compare.Equal(desired.Allowed, actual.Allowed, compare.Unordered("", "ports")) &&
+		compare.Equal(desired.TargetTags, actual.TargetTags, compare.Unordered("")) &&
+		compare.Equal(desired.TargetServiceAccounts, actual.TargetServiceAccounts, compare.Unordered(""))
+}
+
+// addTunnelAccessor grants the supplied member tunnel access in the supplied
+// policy. It returns false if the member already had access.
+func addTunnelAccessor(p *iapv1.Policy, member string) bool {
+	for _, b := range p.Bindings {
+		if b.Role != iapTunnelAccessorRole || b.Condition != nil {
+			continue
+		}
+		for _, m := range b.Members {
+			if m == member {
+				return false
+			}
+		}
+		b.Members = append(b.Members, member)
+		return true
+	}
+	p.Bindings = append(p.Bindings, &iapv1.Binding{Role: iapTunnelAccessorRole, Members: []string{member}})
+	return true
+}
+
+// removeTunnelAccessor revokes the supplied member's tunnel access in the
+// supplied policy, removing the binding if it has no other members. It returns
+// false if the member did not have access.
+func removeTunnelAccessor(p *iapv1.Policy, member string) bool {
+	removed := false
+	bindings := make([]*iapv1.Binding, 0, len(p.Bindings))
+	for _, b := range p.Bindings {
+		if b.Role == iapTunnelAccessorRole && b.Condition == nil {
+			members := make([]string, 0, len(b.Members))
+			for _, m := range b.Members {
+				if m == member {
+					removed = true
+					continue
+				}
+				members = append(members, m)
+			}
+			if len(members) == 0 {
+				continue
+			}
+			b.Members = members
+		}
+		bindings = append(bindings, b)
+	}
+	p.Bindings = bindings
+	return removed
+}
+
+// An iapTunnelConnecter returns an iapTunnelCreateSyncDeleter that can create,
+// sync, and delete IAP tunnels with an external store - for example the GCP
+// API.
+type iapTunnelConnecter interface {
+	Connect(context.Context, *gcpcomputev1alpha1.IAPTunnel) (iapTunnelCreateSyncDeleter, error)
+}
+
+// iapTunnelProviderConnecter is an iapTunnelConnecter that returns an
+// iapTunnelCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type iapTunnelProviderConnecter struct {
+	kube             client.Client
+	providers        provider.Resolver
+	newNetworkClient func(ctx context.Context, creds *google.Credentials) (network.Client, error)
+	newIAPClient     func(ctx context.Context, creds *google.Credentials) (iap.Client, error)
+}
+
+// Connect returns an iapTunnelCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// IAPTunnel.
+func (c *iapTunnelProviderConnecter) Connect(ctx context.Context, t *gcpcomputev1alpha1.IAPTunnel) (iapTunnelCreateSyncDeleter, error) {
+	p, err := c.providers.Get(ctx, c.kube, t, t.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceCompute, compute.ComputeScope)
+	if err != nil {
+		return nil, err
+	}
+	nc, err := c.newNetworkClient(ctx, creds)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create new network client")
+	}
+
+	creds, err = provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceIAP, iapv1.CloudPlatformScope)
+	if err != nil {
+		return nil, err
+	}
+	ic, err := c.newIAPClient(ctx, creds)
+	return &iapTunnels{network: nc, iap: ic, project: p.Spec.ProjectID}, errors.Wrap(err, "cannot create new IAP client")
+}
+
+// IAPTunnelReconciler reconciles IAPTunnels read from the Kubernetes API with
+// an external store, typically the GCP API.
+type IAPTunnelReconciler struct {
+	iapTunnelConnecter
+	kube client.Client
+}
+
+// IAPTunnelController is responsible for adding the IAPTunnel controller and
+// its corresponding reconciler to the manager with any runtime configuration.
+type IAPTunnelController struct {
+	// DefaultProvider is used by tunnels that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new IAPTunnel Controller and adds it to the
+// Manager with default RBAC. The Manager will set fields on the Controller and
+// start it when the Manager is Started.
+func (c *IAPTunnelController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &IAPTunnelReconciler{
+		iapTunnelConnecter: &iapTunnelProviderConnecter{
+			kube:             mgr.GetClient(),
+			providers:        providers,
+			newNetworkClient: network.NewClient,
+			newIAPClient:     iap.NewClient,
+		},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(iapTunnelControllerName).
+		For(&gcpcomputev1alpha1.IAPTunnel{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listIAPTunnels)).
+		Complete(r)
+}
+
+// Reconcile IAP tunnels with the GCP API.
+func (r *IAPTunnelReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	iapTunnelLog.V(logging.Debug).Info("reconciling", "kind", gcpcomputev1alpha1.IAPTunnelKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), iapTunnelReconcileTimeout)
+	defer cancel()
+
+	t := &gcpcomputev1alpha1.IAPTunnel{}
+	if err := r.kube.Get(ctx, req.NamespacedName, t); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get IAP tunnel %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, t)
+	if err != nil {
+		t.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, t), "cannot update IAP tunnel %s", req.NamespacedName)
+	}
+
+	// The tunnel has been deleted from the API server. Remove it from GCP.
+	if t.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, t)}, errors.Wrapf(r.kube.Update(ctx, t), "cannot update IAP tunnel %s", req.NamespacedName)
+	}
+
+	// The tunnel's firewall rule is unnamed. Assume it has not been created.
+	if t.Status.FirewallName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, t)}, errors.Wrapf(r.kube.Update(ctx, t), "cannot update IAP tunnel %s", req.NamespacedName)
+	}
+
+	// The tunnel exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, t)}, errors.Wrapf(r.kube.Update(ctx, t), "cannot update IAP tunnel %s", req.NamespacedName)
+}
+
+// listIAPTunnels is a provider.Lister of IAP tunnels.
+func listIAPTunnels(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &gcpcomputev1alpha1.IAPTunnelList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
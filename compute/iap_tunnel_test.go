@@ -0,0 +1,383 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	iapv1 "google.golang.org/api/iap/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	fakeiap "github.com/crossplaneio/crossplane/pkg/clients/gcp/iap/fake"
+	fakenetwork "github.com/crossplaneio/crossplane/pkg/clients/gcp/network/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	tunnelUID       = types.UID("cool-uid")
+	tunnelName      = iapTunnelNamePrefix + "cool-uid"
+	tunnelProject   = "cool-project"
+	tunnelNetwork   = "cool-network"
+	tunnelMember    = "group:operators@example.org"
+	tunnelOldMember = "user:former@example.org"
+)
+
+var (
+	errTunnelBoom     = errors.New("boom")
+	errTunnelNotFound = &googleapi.Error{Code: http.StatusNotFound}
+)
+
+// Test that our Reconciler implementation satisfies the Reconciler interface.
+var _ reconcile.Reconciler = &IAPTunnelReconciler{}
+
+type iapTunnelModifier func(*gcpcomputev1alpha1.IAPTunnel)
+
+func withTunnelConditions(c ...corev1alpha1.Condition) iapTunnelModifier {
+	return func(t *gcpcomputev1alpha1.IAPTunnel) { t.Status.SetConditions(c...) }
+}
+
+func withTunnelFinalizers(f ...string) iapTunnelModifier {
+	return func(t *gcpcomputev1alpha1.IAPTunnel) { t.ObjectMeta.Finalizers = f }
+}
+
+func withTunnelReclaimPolicy(r corev1alpha1.ReclaimPolicy) iapTunnelModifier {
+	return func(t *gcpcomputev1alpha1.IAPTunnel) { t.Spec.ReclaimPolicy = r }
+}
+
+func withTunnelFirewallName(n string) iapTunnelModifier {
+	return func(t *gcpcomputev1alpha1.IAPTunnel) { t.Status.FirewallName = n }
+}
+
+func withTunnelPorts(p ...string) iapTunnelModifier {
+	return func(t *gcpcomputev1alpha1.IAPTunnel) { t.Spec.Ports = p }
+}
+
+func withTunnelStatusMembers(m ...string) iapTunnelModifier {
+	return func(t *gcpcomputev1alpha1.IAPTunnel) { t.Status.Members = m }
+}
+
+func iapTunnel(tm ...iapTunnelModifier) *gcpcomputev1alpha1.IAPTunnel {
+	t := &gcpcomputev1alpha1.IAPTunnel{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "cool-namespace",
+			Name:       "cool-tunnel",
+			UID:        tunnelUID,
+			Finalizers: []string{},
+		},
+		Spec: gcpcomputev1alpha1.IAPTunnelSpec{
+			Network:    tunnelNetwork,
+			Ports:      []string{"22"},
+			TargetTags: []string{"bastion"},
+			Members:    []string{tunnelMember},
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: "cool-namespace", Name: "cool-provider"},
+			},
+		},
+	}
+
+	for _, m := range tm {
+		m(t)
+	}
+
+	return t
+}
+
+func TestIAPTunnelCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         iapTunnelCreateSyncDeleter
+		t           *gcpcomputev1alpha1.IAPTunnel
+		want        *gcpcomputev1alpha1.IAPTunnel
+		wantRequeue bool
+	}{
+		{
+			name: "SuccessfulCreate",
+			csd: &iapTunnels{project: tunnelProject, network: &fakenetwork.MockClient{
+				MockInsertFirewall: func(_ context.Context, _ string, fw *compute.Firewall) error {
+					if diff := cmp.Diff([]string{iapSourceRange}, fw.SourceRanges); diff != "" {
+						t.Errorf("source ranges: -want, +got:\n%s", diff)
+					}
+					return nil
+				},
+			}},
+			t: iapTunnel(),
+			want: iapTunnel(
+				withTunnelConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+				withTunnelFinalizers(iapTunnelFinalizer),
+				withTunnelFirewallName(tunnelName),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "NoPorts",
+			csd:  &iapTunnels{project: tunnelProject, network: &fakenetwork.MockClient{}},
+			t:    iapTunnel(withTunnelPorts()),
+			want: iapTunnel(
+				withTunnelPorts(),
+				withTunnelConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.New("an IAP tunnel must allow at least one port"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedInsert",
+			csd: &iapTunnels{project: tunnelProject, network: &fakenetwork.MockClient{
+				MockInsertFirewall: func(_ context.Context, _ string, _ *compute.Firewall) error { return errTunnelBoom },
+			}},
+			t: iapTunnel(),
+			want: iapTunnel(
+				withTunnelConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errTunnelBoom, "cannot insert IAP firewall rule"))),
+				withTunnelFinalizers(iapTunnelFinalizer),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(context.Background(), tc.t)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.t, test.EquateConditions()); diff != "" {
+				t.Errorf("t: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestIAPTunnelSync(t *testing.T) {
+	upToDate := func(_ context.Context, project, name string) (*compute.Firewall, error) {
+		return newIAPFirewall(project, name, iapTunnel().Spec), nil
+	}
+	bound := func(_ context.Context, _ string) (*iapv1.Policy, error) {
+		return &iapv1.Policy{Bindings: []*iapv1.Binding{{Role: iapTunnelAccessorRole, Members: []string{tunnelMember}}}}, nil
+	}
+
+	cases := []struct {
+		name        string
+		csd         iapTunnelCreateSyncDeleter
+		t           *gcpcomputev1alpha1.IAPTunnel
+		want        *gcpcomputev1alpha1.IAPTunnel
+		wantRequeue bool
+	}{
+		{
+			name: "UpToDate",
+			csd: &iapTunnels{
+				project: tunnelProject,
+				network: &fakenetwork.MockClient{MockGetFirewall: upToDate},
+				iap:     &fakeiap.MockClient{MockGetTunnelIamPolicy: bound},
+			},
+			t: iapTunnel(withTunnelFirewallName(tunnelName), withTunnelStatusMembers(tunnelMember)),
+			want: iapTunnel(
+				withTunnelFirewallName(tunnelName),
+				withTunnelStatusMembers(tunnelMember),
+				withTunnelConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FirewallReordered",
+			csd: &iapTunnels{
+				project: tunnelProject,
+				network: &fakenetwork.MockClient{
+					MockGetFirewall: func(ctx context.Context, project, name string) (*compute.Firewall, error) {
+						return newIAPFirewall(project, name, iapTunnel(withTunnelPorts("3389", "22")).Spec), nil
+					},
+					MockPatchFirewall: func(_ context.Context, _, _ string, _ *compute.Firewall) error {
+						t.Errorf("PatchFirewall(...): unexpected call")
+						return nil
+					},
+				},
+				iap: &fakeiap.MockClient{MockGetTunnelIamPolicy: bound},
+			},
+			t: iapTunnel(withTunnelPorts("22", "3389"), withTunnelFirewallName(tunnelName), withTunnelStatusMembers(tunnelMember)),
+			want: iapTunnel(
+				withTunnelPorts("22", "3389"),
+				withTunnelFirewallName(tunnelName),
+				withTunnelStatusMembers(tunnelMember),
+				withTunnelConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FirewallAndMembersUpdated",
+			csd: &iapTunnels{
+				project: tunnelProject,
+				network: &fakenetwork.MockClient{
+					MockGetFirewall: func(ctx context.Context, project, name string) (*compute.Firewall, error) {
+						return newIAPFirewall(project, name, iapTunnel(withTunnelPorts("3389")).Spec), nil
+					},
+					MockPatchFirewall: func(_ context.Context, _, _ string, fw *compute.Firewall) error {
+						if diff := cmp.Diff([]string{"22"}, fw.Allowed[0].Ports); diff != "" {
+							t.Errorf("ports: -want, +got:\n%s", diff)
+						}
+						return nil
+					},
+				},
+				iap: &fakeiap.MockClient{
+					MockGetTunnelIamPolicy: func(_ context.Context, _ string) (*iapv1.Policy, error) {
+						return &iapv1.Policy{Bindings: []*iapv1.Binding{{Role: iapTunnelAccessorRole, Members: []string{tunnelOldMember}}}}, nil
+					},
+					MockSetTunnelIamPolicy: func(_ context.Context, _ string, p *iapv1.Policy) error {
+						want := []*iapv1.Binding{{Role: iapTunnelAccessorRole, Members: []string{tunnelMember}}}
+						if diff := cmp.Diff(want, p.Bindings); diff != "" {
+							t.Errorf("bindings: -want, +got:\n%s", diff)
+						}
+						return nil
+					},
+				},
+			},
+			t: iapTunnel(withTunnelFirewallName(tunnelName), withTunnelStatusMembers(tunnelOldMember)),
+			want: iapTunnel(
+				withTunnelFirewallName(tunnelName),
+				withTunnelStatusMembers(tunnelMember),
+				withTunnelConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FirewallNotFound",
+			csd: &iapTunnels{project: tunnelProject, network: &fakenetwork.MockClient{
+				MockGetFirewall: func(_ context.Context, _, _ string) (*compute.Firewall, error) { return nil, errTunnelNotFound },
+			}},
+			t: iapTunnel(withTunnelFirewallName(tunnelName)),
+			want: iapTunnel(
+				withTunnelConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(errors.Errorf("IAP firewall rule %s not found", tunnelName))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedSetPolicy",
+			csd: &iapTunnels{
+				project: tunnelProject,
+				network: &fakenetwork.MockClient{MockGetFirewall: upToDate},
+				iap: &fakeiap.MockClient{
+					MockGetTunnelIamPolicy: func(_ context.Context, _ string) (*iapv1.Policy, error) { return &iapv1.Policy{}, nil },
+					MockSetTunnelIamPolicy: func(_ context.Context, _ string, _ *iapv1.Policy) error { return errTunnelBoom },
+				},
+			},
+			t: iapTunnel(withTunnelFirewallName(tunnelName)),
+			want: iapTunnel(
+				withTunnelFirewallName(tunnelName),
+				withTunnelConditions(corev1alpha1.ReconcileError(errors.Wrap(errTunnelBoom, "cannot set IAP tunnel IAM policy"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(context.Background(), tc.t)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.t, test.EquateConditions()); diff != "" {
+				t.Errorf("t: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestIAPTunnelDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         iapTunnelCreateSyncDeleter
+		t           *gcpcomputev1alpha1.IAPTunnel
+		want        *gcpcomputev1alpha1.IAPTunnel
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimRetain",
+			csd:  &iapTunnels{project: tunnelProject, network: &fakenetwork.MockClient{}, iap: &fakeiap.MockClient{}},
+			t:    iapTunnel(withTunnelFinalizers(iapTunnelFinalizer), withTunnelReclaimPolicy(corev1alpha1.ReclaimRetain), withTunnelStatusMembers(tunnelMember)),
+			want: iapTunnel(
+				withTunnelReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withTunnelStatusMembers(tunnelMember),
+				withTunnelConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDelete",
+			csd: &iapTunnels{
+				project: tunnelProject,
+				network: &fakenetwork.MockClient{
+					MockDeleteFirewall: func(_ context.Context, _, _ string) error { return errTunnelNotFound },
+				},
+				iap: &fakeiap.MockClient{
+					MockGetTunnelIamPolicy: func(_ context.Context, _ string) (*iapv1.Policy, error) {
+						return &iapv1.Policy{Bindings: []*iapv1.Binding{{Role: iapTunnelAccessorRole, Members: []string{tunnelMember}}}}, nil
+					},
+					MockSetTunnelIamPolicy: func(_ context.Context, _ string, p *iapv1.Policy) error {
+						if len(p.Bindings) != 0 {
+							t.Errorf("bindings: want none, got %+v", p.Bindings)
+						}
+						return nil
+					},
+				},
+			},
+			t: iapTunnel(withTunnelFinalizers(iapTunnelFinalizer), withTunnelReclaimPolicy(corev1alpha1.ReclaimDelete), withTunnelStatusMembers(tunnelMember)),
+			want: iapTunnel(
+				withTunnelReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withTunnelConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedDelete",
+			csd: &iapTunnels{project: tunnelProject, network: &fakenetwork.MockClient{
+				MockDeleteFirewall: func(_ context.Context, _, _ string) error { return errTunnelBoom },
+			}},
+			t: iapTunnel(withTunnelFinalizers(iapTunnelFinalizer), withTunnelReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: iapTunnel(
+				withTunnelFinalizers(iapTunnelFinalizer),
+				withTunnelReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withTunnelConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errTunnelBoom, "cannot delete IAP firewall rule"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(context.Background(), tc.t)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.t, test.EquateConditions()); diff != "" {
+				t.Errorf("t: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,391 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	compute "google.golang.org/api/compute/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/instancegroup"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	instanceGroupManagerControllerName = "instancegroupmanagers.compute.gcp.crossplane.io"
+	instanceGroupManagerFinalizer      = "finalizer." + instanceGroupManagerControllerName
+	instanceGroupManagerNamePrefix     = "igm-"
+
+	instanceGroupManagerReconcileTimeout = 1 * time.Minute
+)
+
+var instanceGroupManagerLog = logging.Logger.WithName("controller." + instanceGroupManagerControllerName)
+
+// An instanceGroupManagerCreateSyncDeleter can create, sync, and delete
+// managed instance groups in an external store - e.g. the GCP API. Each
+// method returns true if the group requires further reconciliation.
+type instanceGroupManagerCreateSyncDeleter interface {
+	Create(ctx context.Context, m *gcpcomputev1alpha1.InstanceGroupManager) (requeue bool)
+	Sync(ctx context.Context, m *gcpcomputev1alpha1.InstanceGroupManager) (requeue bool)
+	Delete(ctx context.Context, m *gcpcomputev1alpha1.InstanceGroupManager) (requeue bool)
+}
+
+// instanceGroupManagers is an instanceGroupManagerCreateSyncDeleter using the
+// GCP Compute API.
+type instanceGroupManagers struct {
+	client  instancegroup.Client
+	kube    client.Client
+	project string
+}
+
+// Create creates the managed instance group described by the supplied
+// InstanceGroupManager. Its autoscaler, if any, is created once the group
+// exists.
+func (c *instanceGroupManagers) Create(ctx context.Context, m *gcpcomputev1alpha1.InstanceGroupManager) bool {
+	m.Status.SetConditions(corev1alpha1.Creating())
+
+	template, err := c.instanceTemplate(ctx, m)
+	if err != nil {
+		m.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	meta.AddFinalizer(m, instanceGroupManagerFinalizer)
+	name := fmt.Sprintf("%s%s", instanceGroupManagerNamePrefix, m.GetUID())
+	if err := c.client.InsertInstanceGroupManager(ctx, c.project, m.Spec.Zone, newInstanceGroupManager(name, template, m.Spec)); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		m.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot insert instance group manager")))
+		return true
+	}
+
+	m.Status.GroupName = name
+	m.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync brings the managed instance group and its autoscaler in line with its
+// spec, and reports whether the group is stable. Changing the instance
+// template rolls out new instances according to the group's update policy.
+func (c *instanceGroupManagers) Sync(ctx context.Context, m *gcpcomputev1alpha1.InstanceGroupManager) bool {
+	actual, err := c.client.GetInstanceGroupManager(ctx, c.project, m.Spec.Zone, m.Status.GroupName)
+	if err != nil {
+		m.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot get instance group manager %s", m.Status.GroupName)))
+		return true
+	}
+	m.Status.SelfLink = actual.SelfLink
+	m.Status.InstanceGroup = actual.InstanceGroup
+	m.Status.TargetSize = actual.TargetSize
+
+	template, err := c.instanceTemplate(ctx, m)
+	if err != nil {
+		m.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+	desired := newInstanceGroupManager(m.Status.GroupName, template, m.Spec)
+
+	// Only the instance template and update policy may be patched. Fields
+	// that GCP defaults, such as the update policy's max surge, are ignored
+	// unless they are set.
+	patch := &compute.InstanceGroupManager{InstanceTemplate: desired.InstanceTemplate, UpdatePolicy: desired.UpdatePolicy}
+	if !compare.Equal(patch, actual, compare.IgnoreUnset()) {
+		if err := c.client.PatchInstanceGroupManager(ctx, c.project, m.Spec.Zone, m.Status.GroupName, patch); err != nil {
+			m.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot patch instance group manager")))
+			return true
+		}
+	}
+
+	if !compare.Equal(desired.NamedPorts, actual.NamedPorts) {
+		if err := c.client.SetNamedPorts(ctx, c.project, m.Spec.Zone, m.Status.GroupName, desired.NamedPorts); err != nil {
+			m.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot set named ports")))
+			return true
+		}
+	}
+
+	// The autoscaler owns the target size of an autoscaled group.
+	if m.Spec.Autoscaling == nil && actual.TargetSize != m.Spec.TargetSize {
+		if err := c.client.ResizeInstanceGroupManager(ctx, c.project, m.Spec.Zone, m.Status.GroupName, m.Spec.TargetSize); err != nil {
+			m.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot resize instance group manager")))
+			return true
+		}
+	}
+
+	if err := c.syncAutoscaler(ctx, m); err != nil {
+		m.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	// A group is unstable while instances are being created, deleted, or
+	// recreated to match its template.
+	if actual.Status == nil || !actual.Status.IsStable {
+		m.Status.SetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	m.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// syncAutoscaler creates, updates, or deletes the autoscaler of the managed
+// instance group so that it matches the group's autoscaling spec. The
+// autoscaler shares the name of the group.
+func (c *instanceGroupManagers) syncAutoscaler(ctx context.Context, m *gcpcomputev1alpha1.InstanceGroupManager) error {
+	actual, err := c.client.GetAutoscaler(ctx, c.project, m.Spec.Zone, m.Status.GroupName)
+	if err != nil && !googleapi.IsErrorNotFound(err) {
+		return errors.Wrap(err, "cannot get autoscaler")
+	}
+	exists := err == nil
+
+	if m.Spec.Autoscaling == nil {
+		if !exists {
+			return nil
+		}
+		err := c.client.DeleteAutoscaler(ctx, c.project, m.Spec.Zone, m.Status.GroupName)
+		return errors.Wrap(resource.Ignore(googleapi.IsErrorNotFound, err), "cannot delete autoscaler")
+	}
+
+	desired := newAutoscaler(m.Status.GroupName, m.Status.SelfLink, m.Spec.Autoscaling)
+	if !exists {
+		return errors.Wrap(c.client.InsertAutoscaler(ctx, c.project, m.Spec.Zone, desired), "cannot insert autoscaler")
+	}
+	if compare.Equal(desired, actual, compare.IgnoreUnset()) {
+		return nil
+	}
+	return errors.Wrap(c.client.UpdateAutoscaler(ctx, c.project, m.Spec.Zone, desired), "cannot update autoscaler")
+}
+
+// Delete deletes the managed instance group and its instances. Its
+// autoscaler must be deleted first.
+func (c *instanceGroupManagers) Delete(ctx context.Context, m *gcpcomputev1alpha1.InstanceGroupManager) bool {
+	m.Status.SetConditions(corev1alpha1.Deleting())
+
+	if m.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.client.DeleteAutoscaler(ctx, c.project, m.Spec.Zone, m.Status.GroupName); err != nil && !googleapi.IsErrorNotFound(err) {
+			m.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete autoscaler")))
+			return true
+		}
+		if err := c.client.DeleteInstanceGroupManager(ctx, c.project, m.Spec.Zone, m.Status.GroupName); err != nil && !googleapi.IsErrorNotFound(err) {
+			m.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete instance group manager")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(m, instanceGroupManagerFinalizer)
+	m.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// instanceTemplate returns the self link of the InstanceTemplate referenced
+// by the supplied InstanceGroupManager.
+func (c *instanceGroupManagers) instanceTemplate(ctx context.Context, m *gcpcomputev1alpha1.InstanceGroupManager) (string, error) {
+	t := &gcpcomputev1alpha1.InstanceTemplate{}
+	n := types.NamespacedName{Namespace: m.GetNamespace(), Name: m.Spec.InstanceTemplateRef.Name}
+	if err := c.kube.Get(ctx, n, t); err != nil {
+		return "", errors.Wrapf(err, "cannot get instance template %s", n)
+	}
+	if t.Status.SelfLink == "" {
+		return "", errors.Errorf("instance template %s has not been created", n)
+	}
+	return t.Status.SelfLink, nil
+}
+
+// newInstanceGroupManager returns the GCP managed instance group with the
+// supplied name and instance template described by the supplied spec.
+func newInstanceGroupManager(name, template string, spec gcpcomputev1alpha1.InstanceGroupManagerSpec) *compute.InstanceGroupManager {
+	m := &compute.InstanceGroupManager{
+		Name:             name,
+		Description:      spec.Description,
+		BaseInstanceName: spec.BaseInstanceName,
+		InstanceTemplate: template,
+		TargetSize:       spec.TargetSize,
+		// Send zero explicitly so that a group may be created empty.
+		ForceSendFields: []string{"TargetSize"},
+	}
+
+	for _, p := range spec.NamedPorts {
+		m.NamedPorts = append(m.NamedPorts, &compute.NamedPort{Name: p.Name, Port: p.Port})
+	}
+
+	if u := spec.UpdatePolicy; u != nil {
+		m.UpdatePolicy = &compute.InstanceGroupManagerUpdatePolicy{
+			Type:          u.Type,
+			MinimalAction: u.MinimalAction,
+		}
+		if u.MaxSurge != nil {
+			m.UpdatePolicy.MaxSurge = &compute.FixedOrPercent{Fixed: *u.MaxSurge, ForceSendFields: []string{"Fixed"}}
+		}
+		if u.MaxUnavailable != nil {
+			m.UpdatePolicy.MaxUnavailable = &compute.FixedOrPercent{Fixed: *u.MaxUnavailable, ForceSendFields: []string{"Fixed"}}
+		}
+	}
+
+	return m
+}
+
+// newAutoscaler returns the GCP autoscaler with the supplied name that scales
+// the supplied managed instance group according to the supplied spec.
+func newAutoscaler(name, target string, spec *gcpcomputev1alpha1.InstanceGroupAutoscaling) *compute.Autoscaler {
+	a := &compute.Autoscaler{
+		Name:   name,
+		Target: target,
+		AutoscalingPolicy: &compute.AutoscalingPolicy{
+			MinNumReplicas:    spec.MinReplicas,
+			MaxNumReplicas:    spec.MaxReplicas,
+			CoolDownPeriodSec: spec.CooldownPeriodSec,
+			ForceSendFields:   []string{"MinNumReplicas"},
+		},
+	}
+	if spec.CPUUtilizationTarget != 0 {
+		a.AutoscalingPolicy.CpuUtilization = &compute.AutoscalingPolicyCpuUtilization{UtilizationTarget: spec.CPUUtilizationTarget}
+	}
+	return a
+}
+
+// An instanceGroupManagerConnecter returns an
+// instanceGroupManagerCreateSyncDeleter that can create, sync, and delete
+// managed instance groups with an external store - for example the GCP API.
+type instanceGroupManagerConnecter interface {
+	Connect(context.Context, *gcpcomputev1alpha1.InstanceGroupManager) (instanceGroupManagerCreateSyncDeleter, error)
+}
+
+// instanceGroupManagerProviderConnecter is an instanceGroupManagerConnecter
+// that returns an instanceGroupManagerCreateSyncDeleter authenticated using
+// credentials read from a Crossplane Provider resource.
+type instanceGroupManagerProviderConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (instancegroup.Client, error)
+}
+
+// Connect returns an instanceGroupManagerCreateSyncDeleter backed by the GCP
+// API. GCP credentials are read from the Crossplane Provider referenced by
+// the supplied InstanceGroupManager.
+func (c *instanceGroupManagerProviderConnecter) Connect(ctx context.Context, m *gcpcomputev1alpha1.InstanceGroupManager) (instanceGroupManagerCreateSyncDeleter, error) {
+	p, err := c.providers.Get(ctx, c.kube, m, m.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceCompute, compute.ComputeScope)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return &instanceGroupManagers{client: client, kube: c.kube, project: p.Spec.ProjectID}, errors.Wrap(err, "cannot create new instance group client")
+}
+
+// InstanceGroupManagerReconciler reconciles InstanceGroupManagers read from
+// the Kubernetes API with an external store, typically the GCP API.
+type InstanceGroupManagerReconciler struct {
+	instanceGroupManagerConnecter
+	kube client.Client
+}
+
+// InstanceGroupManagerController is responsible for adding the
+// InstanceGroupManager controller and its corresponding reconciler to the
+// manager with any runtime configuration.
+type InstanceGroupManagerController struct {
+	// DefaultProvider is used by groups that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new InstanceGroupManager Controller and adds it
+// to the Manager with default RBAC. The Manager will set fields on the
+// Controller and start it when the Manager is Started.
+func (c *InstanceGroupManagerController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &InstanceGroupManagerReconciler{
+		instanceGroupManagerConnecter: &instanceGroupManagerProviderConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: instancegroup.NewClient,
+		},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(instanceGroupManagerControllerName).
+		For(&gcpcomputev1alpha1.InstanceGroupManager{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listInstanceGroupManagers)).
+		Complete(r)
+}
+
+// Reconcile managed instance groups with the GCP API.
+func (r *InstanceGroupManagerReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	instanceGroupManagerLog.V(logging.Debug).Info("reconciling", "kind", gcpcomputev1alpha1.InstanceGroupManagerKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), instanceGroupManagerReconcileTimeout)
+	defer cancel()
+
+	m := &gcpcomputev1alpha1.InstanceGroupManager{}
+	if err := r.kube.Get(ctx, req.NamespacedName, m); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get instance group manager %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, m)
+	if err != nil {
+		m.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, m), "cannot update instance group manager %s", req.NamespacedName)
+	}
+
+	// The group has been deleted from the API server. Delete from GCP.
+	if m.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, m)}, errors.Wrapf(r.kube.Update(ctx, m), "cannot update instance group manager %s", req.NamespacedName)
+	}
+
+	// The group is unnamed. Assume it has not been created in GCP.
+	if m.Status.GroupName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, m)}, errors.Wrapf(r.kube.Update(ctx, m), "cannot update instance group manager %s", req.NamespacedName)
+	}
+
+	// The group exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, m)}, errors.Wrapf(r.kube.Update(ctx, m), "cannot update instance group manager %s", req.NamespacedName)
+}
+
+// listInstanceGroupManagers is a provider.Lister of managed instance groups.
+func listInstanceGroupManagers(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &gcpcomputev1alpha1.InstanceGroupManagerList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
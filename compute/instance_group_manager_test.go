@@ -0,0 +1,392 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	fakeinstancegroup "github.com/crossplaneio/crossplane/pkg/clients/gcp/instancegroup/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	groupUID           = types.UID("cool-uid")
+	groupName          = instanceGroupManagerNamePrefix + "cool-uid"
+	groupSelfLink      = "https://www.googleapis.com/compute/v1/projects/cool-project/zones/us-central1-a/instanceGroupManagers/" + groupName
+	groupInstanceGroup = "https://www.googleapis.com/compute/v1/projects/cool-project/zones/us-central1-a/instanceGroups/" + groupName
+	groupProject       = "cool-project"
+	groupZone          = "us-central1-a"
+	groupTemplate      = "cool-template"
+)
+
+var (
+	errGroupBoom     = errors.New("boom")
+	errGroupNotFound = &googleapi.Error{Code: http.StatusNotFound}
+)
+
+// Test that our Reconciler implementation satisfies the Reconciler interface.
+var _ reconcile.Reconciler = &InstanceGroupManagerReconciler{}
+
+type instanceGroupManagerModifier func(*gcpcomputev1alpha1.InstanceGroupManager)
+
+func withGroupConditions(c ...corev1alpha1.Condition) instanceGroupManagerModifier {
+	return func(m *gcpcomputev1alpha1.InstanceGroupManager) { m.Status.SetConditions(c...) }
+}
+
+func withGroupFinalizers(f ...string) instanceGroupManagerModifier {
+	return func(m *gcpcomputev1alpha1.InstanceGroupManager) { m.ObjectMeta.Finalizers = f }
+}
+
+func withGroupReclaimPolicy(r corev1alpha1.ReclaimPolicy) instanceGroupManagerModifier {
+	return func(m *gcpcomputev1alpha1.InstanceGroupManager) { m.Spec.ReclaimPolicy = r }
+}
+
+func withGroupName(n string) instanceGroupManagerModifier {
+	return func(m *gcpcomputev1alpha1.InstanceGroupManager) { m.Status.GroupName = n }
+}
+
+func withGroupTargetSize(s int64) instanceGroupManagerModifier {
+	return func(m *gcpcomputev1alpha1.InstanceGroupManager) { m.Spec.TargetSize = s }
+}
+
+func withGroupAutoscaling(a *gcpcomputev1alpha1.InstanceGroupAutoscaling) instanceGroupManagerModifier {
+	return func(m *gcpcomputev1alpha1.InstanceGroupManager) { m.Spec.Autoscaling = a }
+}
+
+func withGroupObservation(targetSize int64) instanceGroupManagerModifier {
+	return func(m *gcpcomputev1alpha1.InstanceGroupManager) {
+		m.Status.SelfLink = groupSelfLink
+		m.Status.InstanceGroup = groupInstanceGroup
+		m.Status.TargetSize = targetSize
+	}
+}
+
+func instanceGroupManager(gm ...instanceGroupManagerModifier) *gcpcomputev1alpha1.InstanceGroupManager {
+	m := &gcpcomputev1alpha1.InstanceGroupManager{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "cool-namespace",
+			Name:       "cool-group",
+			UID:        groupUID,
+			Finalizers: []string{},
+		},
+		Spec: gcpcomputev1alpha1.InstanceGroupManagerSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: "cool-namespace", Name: "cool-provider"},
+			},
+			Zone:                groupZone,
+			BaseInstanceName:    "cool",
+			InstanceTemplateRef: &corev1.LocalObjectReference{Name: groupTemplate},
+		},
+	}
+
+	for _, mod := range gm {
+		mod(m)
+	}
+
+	return m
+}
+
+// templateKube returns a client that gets an InstanceTemplate with the
+// supplied self link.
+func templateKube(selfLink string) client.Client {
+	return &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+		obj.(*gcpcomputev1alpha1.InstanceTemplate).Status.SelfLink = selfLink
+		return nil
+	}}
+}
+
+func TestInstanceGroupManagerCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         instanceGroupManagerCreateSyncDeleter
+		m           *gcpcomputev1alpha1.InstanceGroupManager
+		want        *gcpcomputev1alpha1.InstanceGroupManager
+		wantRequeue bool
+	}{
+		{
+			name: "SuccessfulCreate",
+			csd: &instanceGroupManagers{project: groupProject, kube: templateKube(templateSelfLink), client: &fakeinstancegroup.MockClient{
+				MockInsertInstanceGroupManager: func(_ context.Context, _, _ string, m *compute.InstanceGroupManager) error {
+					if m.Name != groupName {
+						t.Errorf("m.Name: want %s, got %s", groupName, m.Name)
+					}
+					if m.InstanceTemplate != templateSelfLink {
+						t.Errorf("m.InstanceTemplate: want %s, got %s", templateSelfLink, m.InstanceTemplate)
+					}
+					return nil
+				},
+			}},
+			m: instanceGroupManager(),
+			want: instanceGroupManager(
+				withGroupFinalizers(instanceGroupManagerFinalizer),
+				withGroupName(groupName),
+				withGroupConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "TemplateNotCreated",
+			csd:  &instanceGroupManagers{project: groupProject, kube: templateKube("")},
+			m:    instanceGroupManager(),
+			want: instanceGroupManager(
+				withGroupConditions(
+					corev1alpha1.Creating(),
+					corev1alpha1.ReconcileError(errors.Errorf("instance template %s has not been created", types.NamespacedName{Namespace: "cool-namespace", Name: groupTemplate})),
+				),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &instanceGroupManagers{project: groupProject, kube: templateKube(templateSelfLink), client: &fakeinstancegroup.MockClient{
+				MockInsertInstanceGroupManager: func(_ context.Context, _, _ string, _ *compute.InstanceGroupManager) error { return errGroupBoom },
+			}},
+			m: instanceGroupManager(),
+			want: instanceGroupManager(
+				withGroupFinalizers(instanceGroupManagerFinalizer),
+				withGroupConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errGroupBoom, "cannot insert instance group manager"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.m)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.m, test.EquateConditions()); diff != "" {
+				t.Errorf("group: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInstanceGroupManagerSync(t *testing.T) {
+	existing := func(targetSize int64, stable bool) *compute.InstanceGroupManager {
+		m := newInstanceGroupManager(groupName, templateSelfLink, instanceGroupManager().Spec)
+		m.SelfLink = groupSelfLink
+		m.InstanceGroup = groupInstanceGroup
+		m.TargetSize = targetSize
+		m.Status = &compute.InstanceGroupManagerStatus{IsStable: stable}
+		return m
+	}
+	autoscaling := &gcpcomputev1alpha1.InstanceGroupAutoscaling{MinReplicas: 1, MaxReplicas: 5, CPUUtilizationTarget: 0.6}
+
+	cases := []struct {
+		name        string
+		csd         instanceGroupManagerCreateSyncDeleter
+		m           *gcpcomputev1alpha1.InstanceGroupManager
+		want        *gcpcomputev1alpha1.InstanceGroupManager
+		wantRequeue bool
+	}{
+		{
+			name: "UpToDate",
+			csd: &instanceGroupManagers{project: groupProject, kube: templateKube(templateSelfLink), client: &fakeinstancegroup.MockClient{
+				MockGetInstanceGroupManager: func(_ context.Context, _, _, _ string) (*compute.InstanceGroupManager, error) {
+					return existing(0, true), nil
+				},
+				MockGetAutoscaler: func(_ context.Context, _, _, _ string) (*compute.Autoscaler, error) { return nil, errGroupNotFound },
+			}},
+			m: instanceGroupManager(withGroupName(groupName)),
+			want: instanceGroupManager(
+				withGroupName(groupName),
+				withGroupObservation(0),
+				withGroupConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "Resize",
+			csd: &instanceGroupManagers{project: groupProject, kube: templateKube(templateSelfLink), client: &fakeinstancegroup.MockClient{
+				MockGetInstanceGroupManager: func(_ context.Context, _, _, _ string) (*compute.InstanceGroupManager, error) {
+					return existing(1, false), nil
+				},
+				MockResizeInstanceGroupManager: func(_ context.Context, _, _, _ string, size int64) error {
+					if size != 3 {
+						t.Errorf("size: want 3, got %d", size)
+					}
+					return nil
+				},
+				MockGetAutoscaler: func(_ context.Context, _, _, _ string) (*compute.Autoscaler, error) { return nil, errGroupNotFound },
+			}},
+			m: instanceGroupManager(withGroupName(groupName), withGroupTargetSize(3)),
+			want: instanceGroupManager(
+				withGroupName(groupName),
+				withGroupTargetSize(3),
+				withGroupObservation(1),
+				withGroupConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "InsertAutoscaler",
+			csd: &instanceGroupManagers{project: groupProject, kube: templateKube(templateSelfLink), client: &fakeinstancegroup.MockClient{
+				MockGetInstanceGroupManager: func(_ context.Context, _, _, _ string) (*compute.InstanceGroupManager, error) {
+					return existing(1, true), nil
+				},
+				MockGetAutoscaler: func(_ context.Context, _, _, _ string) (*compute.Autoscaler, error) { return nil, errGroupNotFound },
+				MockInsertAutoscaler: func(_ context.Context, _, _ string, a *compute.Autoscaler) error {
+					if a.Target != groupSelfLink {
+						t.Errorf("a.Target: want %s, got %s", groupSelfLink, a.Target)
+					}
+					return nil
+				},
+			}},
+			m: instanceGroupManager(withGroupName(groupName), withGroupAutoscaling(autoscaling)),
+			want: instanceGroupManager(
+				withGroupName(groupName),
+				withGroupAutoscaling(autoscaling),
+				withGroupObservation(1),
+				withGroupConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedPatch",
+			csd: &instanceGroupManagers{project: groupProject, kube: templateKube(templateSelfLink + "-v2"), client: &fakeinstancegroup.MockClient{
+				MockGetInstanceGroupManager: func(_ context.Context, _, _, _ string) (*compute.InstanceGroupManager, error) {
+					return existing(0, true), nil
+				},
+				MockPatchInstanceGroupManager: func(_ context.Context, _, _, _ string, _ *compute.InstanceGroupManager) error { return errGroupBoom },
+			}},
+			m: instanceGroupManager(withGroupName(groupName)),
+			want: instanceGroupManager(
+				withGroupName(groupName),
+				withGroupObservation(0),
+				withGroupConditions(corev1alpha1.ReconcileError(errors.Wrap(errGroupBoom, "cannot patch instance group manager"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGetInstanceGroupManager",
+			csd: &instanceGroupManagers{project: groupProject, client: &fakeinstancegroup.MockClient{
+				MockGetInstanceGroupManager: func(_ context.Context, _, _, _ string) (*compute.InstanceGroupManager, error) {
+					return nil, errGroupBoom
+				},
+			}},
+			m: instanceGroupManager(withGroupName(groupName)),
+			want: instanceGroupManager(
+				withGroupName(groupName),
+				withGroupConditions(corev1alpha1.ReconcileError(errors.Wrapf(errGroupBoom, "cannot get instance group manager %s", groupName))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.m)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.m, test.EquateConditions()); diff != "" {
+				t.Errorf("group: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInstanceGroupManagerDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         instanceGroupManagerCreateSyncDeleter
+		m           *gcpcomputev1alpha1.InstanceGroupManager
+		want        *gcpcomputev1alpha1.InstanceGroupManager
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimRetainSuccessfulDelete",
+			csd:  &instanceGroupManagers{project: groupProject},
+			m: instanceGroupManager(
+				withGroupFinalizers(instanceGroupManagerFinalizer),
+				withGroupReclaimPolicy(corev1alpha1.ReclaimRetain),
+			),
+			want: instanceGroupManager(
+				withGroupReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withGroupConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteSuccessfulDelete",
+			csd: &instanceGroupManagers{project: groupProject, client: &fakeinstancegroup.MockClient{
+				MockDeleteAutoscaler:           func(_ context.Context, _, _, _ string) error { return errGroupNotFound },
+				MockDeleteInstanceGroupManager: func(_ context.Context, _, _, _ string) error { return nil },
+			}},
+			m: instanceGroupManager(
+				withGroupFinalizers(instanceGroupManagerFinalizer),
+				withGroupReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: instanceGroupManager(
+				withGroupReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withGroupConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailedDeleteAutoscaler",
+			csd: &instanceGroupManagers{project: groupProject, client: &fakeinstancegroup.MockClient{
+				MockDeleteAutoscaler: func(_ context.Context, _, _, _ string) error { return errGroupBoom },
+			}},
+			m: instanceGroupManager(
+				withGroupFinalizers(instanceGroupManagerFinalizer),
+				withGroupReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: instanceGroupManager(
+				withGroupFinalizers(instanceGroupManagerFinalizer),
+				withGroupReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withGroupConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errGroupBoom, "cannot delete autoscaler"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.m)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.m, test.EquateConditions()); diff != "" {
+				t.Errorf("group: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
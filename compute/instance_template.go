@@ -0,0 +1,297 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	compute "google.golang.org/api/compute/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/instancegroup"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	instanceTemplateControllerName = "instancetemplates.compute.gcp.crossplane.io"
+	instanceTemplateFinalizer      = "finalizer." + instanceTemplateControllerName
+	instanceTemplateNamePrefix     = "it-"
+
+	instanceTemplateReconcileTimeout = 1 * time.Minute
+)
+
+// instanceTemplateOutputFields are the output only fields of an instance
+// template.
+var instanceTemplateOutputFields = []string{"id", "kind", "selfLink", "creationTimestamp", "properties.metadata.kind", "properties.metadata.fingerprint", "properties.disks.kind", "properties.networkInterfaces.kind", "properties.networkInterfaces.name"}
+
+var instanceTemplateLog = logging.Logger.WithName("controller." + instanceTemplateControllerName)
+
+// An instanceTemplateCreateSyncDeleter can create, sync, and delete instance
+// templates in an external store - e.g. the GCP API. Each method returns true
+// if the template requires further reconciliation.
+type instanceTemplateCreateSyncDeleter interface {
+	Create(ctx context.Context, t *gcpcomputev1alpha1.InstanceTemplate) (requeue bool)
+	Sync(ctx context.Context, t *gcpcomputev1alpha1.InstanceTemplate) (requeue bool)
+	Delete(ctx context.Context, t *gcpcomputev1alpha1.InstanceTemplate) (requeue bool)
+}
+
+// instanceTemplates is an instanceTemplateCreateSyncDeleter using the GCP
+// Compute API.
+type instanceTemplates struct {
+	client  instancegroup.Client
+	project string
+}
+
+// Create creates the instance template described by the supplied
+// InstanceTemplate.
+func (c *instanceTemplates) Create(ctx context.Context, t *gcpcomputev1alpha1.InstanceTemplate) bool {
+	t.Status.SetConditions(corev1alpha1.Creating())
+	meta.AddFinalizer(t, instanceTemplateFinalizer)
+
+	name := fmt.Sprintf("%s%s", instanceTemplateNamePrefix, t.GetUID())
+	if err := c.client.InsertInstanceTemplate(ctx, c.project, newInstanceTemplate(name, t.Spec)); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		t.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot insert instance template")))
+		return true
+	}
+
+	t.Status.TemplateName = name
+	t.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync records the self link of the instance template. Instance templates are
+// immutable, so a template that differs from its spec is reported as an error
+// rather than updated; a new InstanceTemplate must be created instead.
+func (c *instanceTemplates) Sync(ctx context.Context, t *gcpcomputev1alpha1.InstanceTemplate) bool {
+	actual, err := c.client.GetInstanceTemplate(ctx, c.project, t.Status.TemplateName)
+	if err != nil {
+		t.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot get instance template %s", t.Status.TemplateName)))
+		return true
+	}
+	t.Status.SelfLink = actual.SelfLink
+
+	if diff := compare.Diff(newInstanceTemplate(t.Status.TemplateName, t.Spec), actual, compare.IgnoreFields(instanceTemplateOutputFields...)); len(diff) > 0 {
+		t.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileError(errors.Errorf("instance templates are immutable; cannot update fields %v", diff)))
+		return false
+	}
+
+	t.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete deletes the instance template. GCP refuses to delete a template
+// that is in use by a managed instance group.
+func (c *instanceTemplates) Delete(ctx context.Context, t *gcpcomputev1alpha1.InstanceTemplate) bool {
+	t.Status.SetConditions(corev1alpha1.Deleting())
+
+	if t.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.client.DeleteInstanceTemplate(ctx, c.project, t.Status.TemplateName); err != nil && !googleapi.IsErrorNotFound(err) {
+			t.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete instance template")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(t, instanceTemplateFinalizer)
+	t.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// newInstanceTemplate returns the GCP instance template with the supplied
+// name described by the supplied spec. Instances created from the template
+// have a single boot disk and a single network interface.
+func newInstanceTemplate(name string, spec gcpcomputev1alpha1.InstanceTemplateSpec) *compute.InstanceTemplate {
+	p := &compute.InstanceProperties{
+		MachineType: spec.MachineType,
+		Labels:      spec.Labels,
+		Disks: []*compute.AttachedDisk{{
+			Boot:       true,
+			AutoDelete: true,
+			Type:       "PERSISTENT",
+			InitializeParams: &compute.AttachedDiskInitializeParams{
+				SourceImage: spec.SourceImage,
+				DiskSizeGb:  spec.DiskSizeGB,
+				DiskType:    spec.DiskType,
+			},
+		}},
+		NetworkInterfaces: []*compute.NetworkInterface{{
+			Network:    spec.Network,
+			Subnetwork: spec.Subnetwork,
+		}},
+	}
+
+	if len(spec.Tags) > 0 {
+		p.Tags = &compute.Tags{Items: spec.Tags}
+	}
+
+	if len(spec.Metadata) > 0 {
+		// Sort metadata items by key so that they compare equal to those
+		// returned by GCP.
+		keys := make([]string, 0, len(spec.Metadata))
+		for k := range spec.Metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		p.Metadata = &compute.Metadata{}
+		for _, k := range keys {
+			v := spec.Metadata[k]
+			p.Metadata.Items = append(p.Metadata.Items, &compute.MetadataItems{Key: k, Value: &v})
+		}
+	}
+
+	if spec.ServiceAccount != "" {
+		p.ServiceAccounts = []*compute.ServiceAccount{{Email: spec.ServiceAccount, Scopes: spec.Scopes}}
+	}
+
+	return &compute.InstanceTemplate{Name: name, Description: spec.Description, Properties: p}
+}
+
+// An instanceTemplateConnecter returns an instanceTemplateCreateSyncDeleter
+// that can create, sync, and delete instance templates with an external store
+// - for example the GCP API.
+type instanceTemplateConnecter interface {
+	Connect(context.Context, *gcpcomputev1alpha1.InstanceTemplate) (instanceTemplateCreateSyncDeleter, error)
+}
+
+// instanceTemplateProviderConnecter is an instanceTemplateConnecter that
+// returns an instanceTemplateCreateSyncDeleter authenticated using
+// credentials read from a Crossplane Provider resource.
+type instanceTemplateProviderConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (instancegroup.Client, error)
+}
+
+// Connect returns an instanceTemplateCreateSyncDeleter backed by the GCP API.
+// GCP credentials are read from the Crossplane Provider referenced by the
+// supplied InstanceTemplate.
+func (c *instanceTemplateProviderConnecter) Connect(ctx context.Context, t *gcpcomputev1alpha1.InstanceTemplate) (instanceTemplateCreateSyncDeleter, error) {
+	p, err := c.providers.Get(ctx, c.kube, t, t.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceCompute, compute.ComputeScope)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return &instanceTemplates{client: client, project: p.Spec.ProjectID}, errors.Wrap(err, "cannot create new instance group client")
+}
+
+// InstanceTemplateReconciler reconciles InstanceTemplates read from the
+// Kubernetes API with an external store, typically the GCP API.
+type InstanceTemplateReconciler struct {
+	instanceTemplateConnecter
+	kube client.Client
+}
+
+// InstanceTemplateController is responsible for adding the InstanceTemplate
+// controller and its corresponding reconciler to the manager with any runtime
+// configuration.
+type InstanceTemplateController struct {
+	// DefaultProvider is used by templates that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new InstanceTemplate Controller and adds it to
+// the Manager with default RBAC. The Manager will set fields on the
+// Controller and start it when the Manager is Started.
+func (c *InstanceTemplateController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &InstanceTemplateReconciler{
+		instanceTemplateConnecter: &instanceTemplateProviderConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: instancegroup.NewClient,
+		},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(instanceTemplateControllerName).
+		For(&gcpcomputev1alpha1.InstanceTemplate{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listInstanceTemplates)).
+		Complete(r)
+}
+
+// Reconcile instance templates with the GCP API.
+func (r *InstanceTemplateReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	instanceTemplateLog.V(logging.Debug).Info("reconciling", "kind", gcpcomputev1alpha1.InstanceTemplateKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), instanceTemplateReconcileTimeout)
+	defer cancel()
+
+	t := &gcpcomputev1alpha1.InstanceTemplate{}
+	if err := r.kube.Get(ctx, req.NamespacedName, t); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get instance template %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, t)
+	if err != nil {
+		t.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, t), "cannot update instance template %s", req.NamespacedName)
+	}
+
+	// The template has been deleted from the API server. Delete from GCP.
+	if t.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, t)}, errors.Wrapf(r.kube.Update(ctx, t), "cannot update instance template %s", req.NamespacedName)
+	}
+
+	// The template is unnamed. Assume it has not been created in GCP.
+	if t.Status.TemplateName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, t)}, errors.Wrapf(r.kube.Update(ctx, t), "cannot update instance template %s", req.NamespacedName)
+	}
+
+	// The template exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, t)}, errors.Wrapf(r.kube.Update(ctx, t), "cannot update instance template %s", req.NamespacedName)
+}
+
+// listInstanceTemplates is a provider.Lister of instance templates.
+func listInstanceTemplates(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &gcpcomputev1alpha1.InstanceTemplateList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	compute "google.golang.org/api/compute/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	fakeinstancegroup "github.com/crossplaneio/crossplane/pkg/clients/gcp/instancegroup/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	templateUID         = types.UID("cool-uid")
+	templateName        = instanceTemplateNamePrefix + "cool-uid"
+	templateSelfLink    = "https://www.googleapis.com/compute/v1/projects/cool-project/global/instanceTemplates/" + templateName
+	templateProject     = "cool-project"
+	templateMachineType = "e2-medium"
+)
+
+var errTemplateBoom = errors.New("boom")
+
+// Test that our Reconciler implementation satisfies the Reconciler interface.
+var _ reconcile.Reconciler = &InstanceTemplateReconciler{}
+
+type instanceTemplateModifier func(*gcpcomputev1alpha1.InstanceTemplate)
+
+func withTemplateConditions(c ...corev1alpha1.Condition) instanceTemplateModifier {
+	return func(t *gcpcomputev1alpha1.InstanceTemplate) { t.Status.SetConditions(c...) }
+}
+
+func withTemplateFinalizers(f ...string) instanceTemplateModifier {
+	return func(t *gcpcomputev1alpha1.InstanceTemplate) { t.ObjectMeta.Finalizers = f }
+}
+
+func withTemplateReclaimPolicy(r corev1alpha1.ReclaimPolicy) instanceTemplateModifier {
+	return func(t *gcpcomputev1alpha1.InstanceTemplate) { t.Spec.ReclaimPolicy = r }
+}
+
+func withTemplateName(n string) instanceTemplateModifier {
+	return func(t *gcpcomputev1alpha1.InstanceTemplate) { t.Status.TemplateName = n }
+}
+
+func withTemplateSelfLink(l string) instanceTemplateModifier {
+	return func(t *gcpcomputev1alpha1.InstanceTemplate) { t.Status.SelfLink = l }
+}
+
+func withTemplateMetadata(m map[string]string) instanceTemplateModifier {
+	return func(t *gcpcomputev1alpha1.InstanceTemplate) { t.Spec.Metadata = m }
+}
+
+func instanceTemplate(tm ...instanceTemplateModifier) *gcpcomputev1alpha1.InstanceTemplate {
+	t := &gcpcomputev1alpha1.InstanceTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "cool-namespace",
+			Name:       "cool-template",
+			UID:        templateUID,
+			Finalizers: []string{},
+		},
+		Spec: gcpcomputev1alpha1.InstanceTemplateSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: "cool-namespace", Name: "cool-provider"},
+			},
+			MachineType: templateMachineType,
+			SourceImage: "projects/debian-cloud/global/images/family/debian-12",
+		},
+	}
+
+	for _, m := range tm {
+		m(t)
+	}
+
+	return t
+}
+
+func TestInstanceTemplateCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         instanceTemplateCreateSyncDeleter
+		t           *gcpcomputev1alpha1.InstanceTemplate
+		want        *gcpcomputev1alpha1.InstanceTemplate
+		wantRequeue bool
+	}{
+		{
+			name: "SuccessfulCreate",
+			csd: &instanceTemplates{project: templateProject, client: &fakeinstancegroup.MockClient{
+				MockInsertInstanceTemplate: func(_ context.Context, _ string, it *compute.InstanceTemplate) error {
+					if it.Name != templateName {
+						t.Errorf("it.Name: want %s, got %s", templateName, it.Name)
+					}
+					return nil
+				},
+			}},
+			t: instanceTemplate(),
+			want: instanceTemplate(
+				withTemplateFinalizers(instanceTemplateFinalizer),
+				withTemplateName(templateName),
+				withTemplateConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &instanceTemplates{project: templateProject, client: &fakeinstancegroup.MockClient{
+				MockInsertInstanceTemplate: func(_ context.Context, _ string, _ *compute.InstanceTemplate) error { return errTemplateBoom },
+			}},
+			t: instanceTemplate(),
+			want: instanceTemplate(
+				withTemplateFinalizers(instanceTemplateFinalizer),
+				withTemplateConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errTemplateBoom, "cannot insert instance template"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.t)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.t, test.EquateConditions()); diff != "" {
+				t.Errorf("template: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInstanceTemplateSync(t *testing.T) {
+	existing := func() *compute.InstanceTemplate {
+		it := newInstanceTemplate(templateName, instanceTemplate().Spec)
+		it.SelfLink = templateSelfLink
+		return it
+	}
+
+	cases := []struct {
+		name        string
+		csd         instanceTemplateCreateSyncDeleter
+		t           *gcpcomputev1alpha1.InstanceTemplate
+		want        *gcpcomputev1alpha1.InstanceTemplate
+		wantRequeue bool
+	}{
+		{
+			name: "UpToDate",
+			csd: &instanceTemplates{project: templateProject, client: &fakeinstancegroup.MockClient{
+				MockGetInstanceTemplate: func(_ context.Context, _, _ string) (*compute.InstanceTemplate, error) { return existing(), nil },
+			}},
+			t: instanceTemplate(withTemplateName(templateName)),
+			want: instanceTemplate(
+				withTemplateName(templateName),
+				withTemplateSelfLink(templateSelfLink),
+				withTemplateConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "Immutable",
+			csd: &instanceTemplates{project: templateProject, client: &fakeinstancegroup.MockClient{
+				MockGetInstanceTemplate: func(_ context.Context, _, _ string) (*compute.InstanceTemplate, error) { return existing(), nil },
+			}},
+			t: instanceTemplate(withTemplateName(templateName), withTemplateMetadata(map[string]string{"cool": "very"})),
+			want: instanceTemplate(
+				withTemplateName(templateName),
+				withTemplateMetadata(map[string]string{"cool": "very"}),
+				withTemplateSelfLink(templateSelfLink),
+				withTemplateConditions(
+					corev1alpha1.Available(),
+					corev1alpha1.ReconcileError(errors.Errorf("instance templates are immutable; cannot update fields %v", []string{"properties.metadata"})),
+				),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedGetInstanceTemplate",
+			csd: &instanceTemplates{project: templateProject, client: &fakeinstancegroup.MockClient{
+				MockGetInstanceTemplate: func(_ context.Context, _, _ string) (*compute.InstanceTemplate, error) { return nil, errTemplateBoom },
+			}},
+			t: instanceTemplate(withTemplateName(templateName)),
+			want: instanceTemplate(
+				withTemplateName(templateName),
+				withTemplateConditions(corev1alpha1.ReconcileError(errors.Wrapf(errTemplateBoom, "cannot get instance template %s", templateName))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.t)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.t, test.EquateConditions()); diff != "" {
+				t.Errorf("template: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInstanceTemplateDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         instanceTemplateCreateSyncDeleter
+		t           *gcpcomputev1alpha1.InstanceTemplate
+		want        *gcpcomputev1alpha1.InstanceTemplate
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimRetainSuccessfulDelete",
+			csd:  &instanceTemplates{project: templateProject},
+			t: instanceTemplate(
+				withTemplateFinalizers(instanceTemplateFinalizer),
+				withTemplateReclaimPolicy(corev1alpha1.ReclaimRetain),
+			),
+			want: instanceTemplate(
+				withTemplateReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withTemplateConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailedDelete",
+			csd: &instanceTemplates{project: templateProject, client: &fakeinstancegroup.MockClient{
+				MockDeleteInstanceTemplate: func(_ context.Context, _, _ string) error { return errTemplateBoom },
+			}},
+			t: instanceTemplate(
+				withTemplateFinalizers(instanceTemplateFinalizer),
+				withTemplateReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: instanceTemplate(
+				withTemplateFinalizers(instanceTemplateFinalizer),
+				withTemplateReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withTemplateConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errTemplateBoom, "cannot delete instance template"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.t)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.t, test.EquateConditions()); diff != "" {
+				t.Errorf("template: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
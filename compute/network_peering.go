@@ -0,0 +1,302 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	compute "google.golang.org/api/compute/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/network"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	networkPeeringControllerName = "networkpeerings.compute.gcp.crossplane.io"
+	networkPeeringFinalizer      = "finalizer." + networkPeeringControllerName
+	networkPeeringNamePrefix     = "np-"
+
+	networkPeeringReconcileTimeout = 1 * time.Minute
+
+	peeringStateActive = "ACTIVE"
+)
+
+var networkPeeringLog = logging.Logger.WithName("controller." + networkPeeringControllerName)
+
+// A networkPeeringCreateSyncDeleter can create, sync, and delete VPC network
+// peerings in an external store - e.g. the GCP API. Each method returns true
+// if the peering requires further reconciliation.
+type networkPeeringCreateSyncDeleter interface {
+	Create(ctx context.Context, p *gcpcomputev1alpha1.NetworkPeering) (requeue bool)
+	Sync(ctx context.Context, p *gcpcomputev1alpha1.NetworkPeering) (requeue bool)
+	Delete(ctx context.Context, p *gcpcomputev1alpha1.NetworkPeering) (requeue bool)
+}
+
+// networkPeerings is a networkPeeringCreateSyncDeleter using the GCP Compute
+// API.
+type networkPeerings struct {
+	client  network.Client
+	project string
+}
+
+// Create peers the network with the peer network. The peering is inactive
+// until the peer network is also peered with the network.
+func (c *networkPeerings) Create(ctx context.Context, p *gcpcomputev1alpha1.NetworkPeering) bool {
+	p.Status.SetConditions(corev1alpha1.Creating())
+	meta.AddFinalizer(p, networkPeeringFinalizer)
+
+	name := fmt.Sprintf("%s%s", networkPeeringNamePrefix, p.GetUID())
+	if err := c.client.AddPeering(ctx, c.project, p.Spec.Network, newNetworkPeering(c.project, name, p.Spec)); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		p.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot add network peering")))
+		return true
+	}
+
+	p.Status.PeeringName = name
+	p.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync updates the routes exchanged by the peering if they differ from its
+// spec, and reports whether the peering is active.
+func (c *networkPeerings) Sync(ctx context.Context, p *gcpcomputev1alpha1.NetworkPeering) bool {
+	n, err := c.client.GetNetwork(ctx, c.project, p.Spec.Network)
+	if err != nil {
+		p.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot get network %s", p.Spec.Network)))
+		return true
+	}
+
+	actual := findPeering(n, p.Status.PeeringName)
+	if actual == nil {
+		// The peering was removed outside of Crossplane. Add it again.
+		p.Status.SetConditions(corev1alpha1.ReconcileError(errors.Errorf("network peering %s not found", p.Status.PeeringName)))
+		p.Status.PeeringName = ""
+		return true
+	}
+	p.Status.State = actual.State
+	p.Status.StateDetails = actual.StateDetails
+
+	desired := newNetworkPeering(c.project, p.Status.PeeringName, p.Spec)
+	if desired.ExportCustomRoutes != actual.ExportCustomRoutes || desired.ImportCustomRoutes != actual.ImportCustomRoutes {
+		if err := c.client.UpdatePeering(ctx, c.project, p.Spec.Network, desired); err != nil {
+			p.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot update network peering")))
+			return true
+		}
+		p.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	// A peering is inactive until the peer network peers back.
+	if actual.State != peeringStateActive {
+		p.Status.SetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	p.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete removes the peering from the network. The peer network's side of
+// the peering becomes inactive.
+func (c *networkPeerings) Delete(ctx context.Context, p *gcpcomputev1alpha1.NetworkPeering) bool {
+	p.Status.SetConditions(corev1alpha1.Deleting())
+
+	if p.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.client.RemovePeering(ctx, c.project, p.Spec.Network, p.Status.PeeringName); err != nil && !googleapi.IsErrorNotFound(err) {
+			p.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot remove network peering")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(p, networkPeeringFinalizer)
+	p.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// newNetworkPeering returns a peering with the supplied name, described by
+// the supplied spec.
+func newNetworkPeering(project, name string, spec gcpcomputev1alpha1.NetworkPeeringSpec) *compute.NetworkPeering {
+	return &compute.NetworkPeering{
+		Name:                 name,
+		Network:              networkURL(project, spec.PeerNetwork),
+		ExchangeSubnetRoutes: true,
+		ExportCustomRoutes:   spec.ExportCustomRoutes,
+		ImportCustomRoutes:   spec.ImportCustomRoutes,
+		// Send false values explicitly so that custom routes may be disabled.
+		ForceSendFields: []string{"ExportCustomRoutes", "ImportCustomRoutes"},
+	}
+}
+
+// networkURL returns the URL of the supplied network, which may be a name
+// within the supplied project, a relative path such as
+// projects/p/global/networks/n, or a URL.
+func networkURL(project, n string) string {
+	switch {
+	case strings.HasPrefix(n, "https://"):
+		return n
+	case strings.HasPrefix(n, "projects/"):
+		return "https://www.googleapis.com/compute/v1/" + n
+	default:
+		return fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/global/networks/%s", project, n)
+	}
+}
+
+// findPeering returns the peering of the supplied network with the supplied
+// name, or nil if there is no such peering.
+func findPeering(n *compute.Network, name string) *compute.NetworkPeering {
+	for _, p := range n.Peerings {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// A networkPeeringConnecter returns a networkPeeringCreateSyncDeleter that can
+// create, sync, and delete network peerings with an external store - for
+// example the GCP API.
+type networkPeeringConnecter interface {
+	Connect(context.Context, *gcpcomputev1alpha1.NetworkPeering) (networkPeeringCreateSyncDeleter, error)
+}
+
+// networkPeeringProviderConnecter is a networkPeeringConnecter that returns a
+// networkPeeringCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type networkPeeringProviderConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (network.Client, error)
+}
+
+// Connect returns a networkPeeringCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// NetworkPeering.
+func (c *networkPeeringProviderConnecter) Connect(ctx context.Context, np *gcpcomputev1alpha1.NetworkPeering) (networkPeeringCreateSyncDeleter, error) {
+	p, err := c.providers.Get(ctx, c.kube, np, np.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceCompute, compute.ComputeScope)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return &networkPeerings{client: client, project: p.Spec.ProjectID}, errors.Wrap(err, "cannot create new network client")
+}
+
+// NetworkPeeringReconciler reconciles NetworkPeerings read from the
+// Kubernetes API with an external store, typically the GCP API.
+type NetworkPeeringReconciler struct {
+	networkPeeringConnecter
+	kube client.Client
+}
+
+// NetworkPeeringController is responsible for adding the NetworkPeering
+// controller and its corresponding reconciler to the manager with any runtime
+// configuration.
+type NetworkPeeringController struct {
+	// DefaultProvider is used by peerings that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new NetworkPeering Controller and adds it to the
+// Manager with default RBAC. The Manager will set fields on the Controller and
+// start it when the Manager is Started.
+func (c *NetworkPeeringController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &NetworkPeeringReconciler{
+		networkPeeringConnecter: &networkPeeringProviderConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: network.NewClient,
+		},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(networkPeeringControllerName).
+		For(&gcpcomputev1alpha1.NetworkPeering{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listNetworkPeerings)).
+		Complete(r)
+}
+
+// Reconcile VPC network peerings with the GCP API.
+func (r *NetworkPeeringReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	networkPeeringLog.V(logging.Debug).Info("reconciling", "kind", gcpcomputev1alpha1.NetworkPeeringKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), networkPeeringReconcileTimeout)
+	defer cancel()
+
+	p := &gcpcomputev1alpha1.NetworkPeering{}
+	if err := r.kube.Get(ctx, req.NamespacedName, p); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get network peering %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, p)
+	if err != nil {
+		p.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, p), "cannot update network peering %s", req.NamespacedName)
+	}
+
+	// The peering has been deleted from the API server. Remove it from GCP.
+	if p.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, p)}, errors.Wrapf(r.kube.Update(ctx, p), "cannot update network peering %s", req.NamespacedName)
+	}
+
+	// The peering is unnamed. Assume it has not been created in GCP.
+	if p.Status.PeeringName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, p)}, errors.Wrapf(r.kube.Update(ctx, p), "cannot update network peering %s", req.NamespacedName)
+	}
+
+	// The peering exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, p)}, errors.Wrapf(r.kube.Update(ctx, p), "cannot update network peering %s", req.NamespacedName)
+}
+
+// listNetworkPeerings is a provider.Lister of network peerings.
+func listNetworkPeerings(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &gcpcomputev1alpha1.NetworkPeeringList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
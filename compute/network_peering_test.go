@@ -0,0 +1,353 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	fakenetwork "github.com/crossplaneio/crossplane/pkg/clients/gcp/network/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	peeringUID         = types.UID("cool-uid")
+	peeringName        = networkPeeringNamePrefix + "cool-uid"
+	peeringProject     = "cool-project"
+	peeringNetwork     = "cool-network"
+	peeringPeerNetwork = "projects/peer-project/global/networks/peer-network"
+)
+
+var (
+	errPeeringBoom     = errors.New("boom")
+	errPeeringNotFound = &googleapi.Error{Code: http.StatusNotFound}
+)
+
+// Test that our Reconciler implementation satisfies the Reconciler interface.
+var _ reconcile.Reconciler = &NetworkPeeringReconciler{}
+
+type networkPeeringModifier func(*gcpcomputev1alpha1.NetworkPeering)
+
+func withPeeringConditions(c ...corev1alpha1.Condition) networkPeeringModifier {
+	return func(p *gcpcomputev1alpha1.NetworkPeering) { p.Status.SetConditions(c...) }
+}
+
+func withPeeringFinalizers(f ...string) networkPeeringModifier {
+	return func(p *gcpcomputev1alpha1.NetworkPeering) { p.ObjectMeta.Finalizers = f }
+}
+
+func withPeeringReclaimPolicy(r corev1alpha1.ReclaimPolicy) networkPeeringModifier {
+	return func(p *gcpcomputev1alpha1.NetworkPeering) { p.Spec.ReclaimPolicy = r }
+}
+
+func withPeeringName(n string) networkPeeringModifier {
+	return func(p *gcpcomputev1alpha1.NetworkPeering) { p.Status.PeeringName = n }
+}
+
+func withPeeringState(s string) networkPeeringModifier {
+	return func(p *gcpcomputev1alpha1.NetworkPeering) { p.Status.State = s }
+}
+
+func withPeeringExportCustomRoutes(e bool) networkPeeringModifier {
+	return func(p *gcpcomputev1alpha1.NetworkPeering) { p.Spec.ExportCustomRoutes = e }
+}
+
+func networkPeering(pm ...networkPeeringModifier) *gcpcomputev1alpha1.NetworkPeering {
+	p := &gcpcomputev1alpha1.NetworkPeering{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "cool-namespace",
+			Name:       "cool-peering",
+			UID:        peeringUID,
+			Finalizers: []string{},
+		},
+		Spec: gcpcomputev1alpha1.NetworkPeeringSpec{
+			Network:     peeringNetwork,
+			PeerNetwork: peeringPeerNetwork,
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: "cool-namespace", Name: "cool-provider"},
+			},
+		},
+	}
+
+	for _, m := range pm {
+		m(p)
+	}
+
+	return p
+}
+
+func TestNetworkPeeringCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         networkPeeringCreateSyncDeleter
+		p           *gcpcomputev1alpha1.NetworkPeering
+		want        *gcpcomputev1alpha1.NetworkPeering
+		wantRequeue bool
+	}{
+		{
+			name: "SuccessfulCreate",
+			csd: &networkPeerings{project: peeringProject, client: &fakenetwork.MockClient{
+				MockAddPeering: func(_ context.Context, _, n string, p *compute.NetworkPeering) error {
+					if n != peeringNetwork {
+						t.Errorf("network: want %s, got %s", peeringNetwork, n)
+					}
+					if want := "https://www.googleapis.com/compute/v1/" + peeringPeerNetwork; p.Network != want {
+						t.Errorf("p.Network: want %s, got %s", want, p.Network)
+					}
+					return nil
+				},
+			}},
+			p: networkPeering(),
+			want: networkPeering(
+				withPeeringFinalizers(networkPeeringFinalizer),
+				withPeeringName(peeringName),
+				withPeeringConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &networkPeerings{project: peeringProject, client: &fakenetwork.MockClient{
+				MockAddPeering: func(_ context.Context, _, _ string, _ *compute.NetworkPeering) error { return errPeeringBoom },
+			}},
+			p: networkPeering(),
+			want: networkPeering(
+				withPeeringFinalizers(networkPeeringFinalizer),
+				withPeeringConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errPeeringBoom, "cannot add network peering"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.p)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.p, test.EquateConditions()); diff != "" {
+				t.Errorf("peering: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNetworkPeeringSync(t *testing.T) {
+	withPeering := func(state string, export bool) func(context.Context, string, string) (*compute.Network, error) {
+		return func(_ context.Context, _, _ string) (*compute.Network, error) {
+			return &compute.Network{Peerings: []*compute.NetworkPeering{{Name: peeringName, State: state, ExportCustomRoutes: export}}}, nil
+		}
+	}
+
+	cases := []struct {
+		name        string
+		csd         networkPeeringCreateSyncDeleter
+		p           *gcpcomputev1alpha1.NetworkPeering
+		want        *gcpcomputev1alpha1.NetworkPeering
+		wantRequeue bool
+	}{
+		{
+			name: "PeeringActive",
+			csd: &networkPeerings{project: peeringProject, client: &fakenetwork.MockClient{
+				MockGetNetwork: withPeering(peeringStateActive, false),
+			}},
+			p: networkPeering(withPeeringName(peeringName)),
+			want: networkPeering(
+				withPeeringName(peeringName),
+				withPeeringState(peeringStateActive),
+				withPeeringConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "PeeringInactive",
+			csd: &networkPeerings{project: peeringProject, client: &fakenetwork.MockClient{
+				MockGetNetwork: withPeering("INACTIVE", false),
+			}},
+			p: networkPeering(withPeeringName(peeringName)),
+			want: networkPeering(
+				withPeeringName(peeringName),
+				withPeeringState("INACTIVE"),
+				withPeeringConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "UpdateCustomRoutes",
+			csd: &networkPeerings{project: peeringProject, client: &fakenetwork.MockClient{
+				MockGetNetwork: withPeering(peeringStateActive, false),
+				MockUpdatePeering: func(_ context.Context, _, _ string, p *compute.NetworkPeering) error {
+					if !p.ExportCustomRoutes {
+						t.Errorf("p.ExportCustomRoutes: want true, got false")
+					}
+					return nil
+				},
+			}},
+			p: networkPeering(withPeeringName(peeringName), withPeeringExportCustomRoutes(true)),
+			want: networkPeering(
+				withPeeringName(peeringName),
+				withPeeringExportCustomRoutes(true),
+				withPeeringState(peeringStateActive),
+				withPeeringConditions(corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "PeeringNotFound",
+			csd: &networkPeerings{project: peeringProject, client: &fakenetwork.MockClient{
+				MockGetNetwork: func(_ context.Context, _, _ string) (*compute.Network, error) { return &compute.Network{}, nil },
+			}},
+			p: networkPeering(withPeeringName(peeringName)),
+			want: networkPeering(
+				withPeeringConditions(corev1alpha1.ReconcileError(errors.Errorf("network peering %s not found", peeringName))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGetNetwork",
+			csd: &networkPeerings{project: peeringProject, client: &fakenetwork.MockClient{
+				MockGetNetwork: func(_ context.Context, _, _ string) (*compute.Network, error) { return nil, errPeeringBoom },
+			}},
+			p: networkPeering(withPeeringName(peeringName)),
+			want: networkPeering(
+				withPeeringName(peeringName),
+				withPeeringConditions(corev1alpha1.ReconcileError(errors.Wrapf(errPeeringBoom, "cannot get network %s", peeringNetwork))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.p)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.p, test.EquateConditions()); diff != "" {
+				t.Errorf("peering: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNetworkPeeringDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         networkPeeringCreateSyncDeleter
+		p           *gcpcomputev1alpha1.NetworkPeering
+		want        *gcpcomputev1alpha1.NetworkPeering
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimRetainSuccessfulDelete",
+			csd:  &networkPeerings{project: peeringProject},
+			p: networkPeering(
+				withPeeringFinalizers(networkPeeringFinalizer),
+				withPeeringReclaimPolicy(corev1alpha1.ReclaimRetain),
+			),
+			want: networkPeering(
+				withPeeringReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withPeeringConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteNotFound",
+			csd: &networkPeerings{project: peeringProject, client: &fakenetwork.MockClient{
+				MockRemovePeering: func(_ context.Context, _, _, _ string) error { return errPeeringNotFound },
+			}},
+			p: networkPeering(
+				withPeeringFinalizers(networkPeeringFinalizer),
+				withPeeringReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withPeeringName(peeringName),
+			),
+			want: networkPeering(
+				withPeeringReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withPeeringName(peeringName),
+				withPeeringConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailedDelete",
+			csd: &networkPeerings{project: peeringProject, client: &fakenetwork.MockClient{
+				MockRemovePeering: func(_ context.Context, _, _, _ string) error { return errPeeringBoom },
+			}},
+			p: networkPeering(
+				withPeeringFinalizers(networkPeeringFinalizer),
+				withPeeringReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withPeeringName(peeringName),
+			),
+			want: networkPeering(
+				withPeeringFinalizers(networkPeeringFinalizer),
+				withPeeringReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withPeeringName(peeringName),
+				withPeeringConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errPeeringBoom, "cannot remove network peering"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.p)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.p, test.EquateConditions()); diff != "" {
+				t.Errorf("peering: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNetworkURL(t *testing.T) {
+	cases := map[string]struct {
+		n    string
+		want string
+	}{
+		"Name":         {n: "n", want: "https://www.googleapis.com/compute/v1/projects/p/global/networks/n"},
+		"RelativePath": {n: "projects/o/global/networks/n", want: "https://www.googleapis.com/compute/v1/projects/o/global/networks/n"},
+		"URL":          {n: "https://www.googleapis.com/compute/v1/projects/o/global/networks/n", want: "https://www.googleapis.com/compute/v1/projects/o/global/networks/n"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := networkURL("p", tc.n); got != tc.want {
+				t.Errorf("networkURL(...): want %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
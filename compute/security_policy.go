@@ -0,0 +1,304 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	compute "google.golang.org/api/compute/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/securitypolicy"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	securityPolicyControllerName = "securitypolicies.compute.gcp.crossplane.io"
+	securityPolicyFinalizer      = "finalizer." + securityPolicyControllerName
+	securityPolicyNamePrefix     = "sp-"
+
+	securityPolicyReconcileTimeout = 1 * time.Minute
+)
+
+var securityPolicyLog = logging.Logger.WithName("controller." + securityPolicyControllerName)
+
+// A securityPolicyCreateSyncDeleter can create, sync, and delete Cloud Armor
+// security policies in an external store - e.g. the GCP API. Each method
+// returns true if the policy requires further reconciliation.
+type securityPolicyCreateSyncDeleter interface {
+	Create(ctx context.Context, p *gcpcomputev1alpha1.SecurityPolicy) (requeue bool)
+	Sync(ctx context.Context, p *gcpcomputev1alpha1.SecurityPolicy) (requeue bool)
+	Delete(ctx context.Context, p *gcpcomputev1alpha1.SecurityPolicy) (requeue bool)
+}
+
+// cloudArmor is a securityPolicyCreateSyncDeleter using the GCP Compute API.
+type cloudArmor struct {
+	client  securitypolicy.Client
+	project string
+}
+
+func (c *cloudArmor) Create(ctx context.Context, p *gcpcomputev1alpha1.SecurityPolicy) bool {
+	p.Status.SetConditions(corev1alpha1.Creating())
+	meta.AddFinalizer(p, securityPolicyFinalizer)
+
+	name := fmt.Sprintf("%s%s", securityPolicyNamePrefix, p.GetUID())
+	if err := c.client.InsertSecurityPolicy(ctx, c.project, newSecurityPolicy(name, p.Spec)); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		p.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	p.Status.PolicyName = name
+	p.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync brings the policy's rules and backend service attachments in line
+// with its spec.
+func (c *cloudArmor) Sync(ctx context.Context, p *gcpcomputev1alpha1.SecurityPolicy) bool {
+	actual, err := c.client.GetSecurityPolicy(ctx, c.project, p.Status.PolicyName)
+	if err != nil {
+		p.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+	p.Status.SelfLink = actual.SelfLink
+
+	add, patch, remove := diffRules(newRules(p.Spec), actual.Rules)
+	for _, r := range add {
+		if err := c.client.AddRule(ctx, c.project, p.Status.PolicyName, r); err != nil {
+			p.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot add rule with priority %d", r.Priority)))
+			return true
+		}
+	}
+	for _, r := range patch {
+		if err := c.client.PatchRule(ctx, c.project, p.Status.PolicyName, r); err != nil {
+			p.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot patch rule with priority %d", r.Priority)))
+			return true
+		}
+	}
+	for _, priority := range remove {
+		if err := c.client.RemoveRule(ctx, c.project, p.Status.PolicyName, priority); err != nil {
+			p.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot remove rule with priority %d", priority)))
+			return true
+		}
+	}
+
+	if err := c.attach(ctx, p); err != nil {
+		p.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	p.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// attach attaches the policy to the backend services in its spec, and
+// detaches it from any backend services that have since been removed.
+func (c *cloudArmor) attach(ctx context.Context, p *gcpcomputev1alpha1.SecurityPolicy) error {
+	wanted := map[string]bool{}
+	for _, bs := range p.Spec.BackendServices {
+		wanted[bs] = true
+		s, err := c.client.GetBackendService(ctx, c.project, bs)
+		if err != nil {
+			return errors.Wrapf(err, "cannot get backend service %s", bs)
+		}
+		if s.SecurityPolicy == p.Status.SelfLink {
+			continue
+		}
+		if err := c.client.SetBackendServiceSecurityPolicy(ctx, c.project, bs, p.Status.SelfLink); err != nil {
+			return errors.Wrapf(err, "cannot attach policy to backend service %s", bs)
+		}
+	}
+
+	for _, bs := range p.Status.AttachedBackendServices {
+		if wanted[bs] {
+			continue
+		}
+		if err := c.detach(ctx, bs); err != nil {
+			return err
+		}
+	}
+
+	p.Status.AttachedBackendServices = p.Spec.BackendServices
+	return nil
+}
+
+func (c *cloudArmor) detach(ctx context.Context, backendService string) error {
+	err := c.client.SetBackendServiceSecurityPolicy(ctx, c.project, backendService, "")
+	if err != nil && !googleapi.IsErrorNotFound(err) {
+		return errors.Wrapf(err, "cannot detach policy from backend service %s", backendService)
+	}
+	return nil
+}
+
+// Delete detaches the policy from all backend services, which GCP requires
+// before a policy may be deleted.
+func (c *cloudArmor) Delete(ctx context.Context, p *gcpcomputev1alpha1.SecurityPolicy) bool {
+	p.Status.SetConditions(corev1alpha1.Deleting())
+
+	if p.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		for _, bs := range p.Status.AttachedBackendServices {
+			if err := c.detach(ctx, bs); err != nil {
+				p.Status.SetConditions(corev1alpha1.ReconcileError(err))
+				return true
+			}
+		}
+		p.Status.AttachedBackendServices = nil
+
+		if err := c.client.DeleteSecurityPolicy(ctx, c.project, p.Status.PolicyName); err != nil && !googleapi.IsErrorNotFound(err) {
+			p.Status.SetConditions(corev1alpha1.ReconcileError(err))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(p, securityPolicyFinalizer)
+	p.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// A securityPolicyConnecter returns a securityPolicyCreateSyncDeleter that can
+// create, sync, and delete security policies with an external store - for
+// example the GCP API.
+type securityPolicyConnecter interface {
+	Connect(context.Context, *gcpcomputev1alpha1.SecurityPolicy) (securityPolicyCreateSyncDeleter, error)
+}
+
+// securityPolicyProviderConnecter is a securityPolicyConnecter that returns a
+// securityPolicyCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type securityPolicyProviderConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (securitypolicy.Client, error)
+}
+
+// Connect returns a securityPolicyCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// SecurityPolicy.
+func (c *securityPolicyProviderConnecter) Connect(ctx context.Context, sp *gcpcomputev1alpha1.SecurityPolicy) (securityPolicyCreateSyncDeleter, error) {
+	p, err := c.providers.Get(ctx, c.kube, sp, sp.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceCompute, compute.ComputeScope)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return &cloudArmor{client: client, project: p.Spec.ProjectID}, errors.Wrap(err, "cannot create new security policy client")
+}
+
+// SecurityPolicyReconciler reconciles SecurityPolicies read from the
+// Kubernetes API with an external store, typically the GCP API.
+type SecurityPolicyReconciler struct {
+	securityPolicyConnecter
+	kube client.Client
+}
+
+// SecurityPolicyController is responsible for adding the SecurityPolicy
+// controller and its corresponding reconciler to the manager with any runtime
+// configuration.
+type SecurityPolicyController struct {
+	// DefaultProvider is used by policies that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new SecurityPolicy Controller and adds it to the
+// Manager with default RBAC. The Manager will set fields on the Controller and
+// start it when the Manager is Started.
+func (c *SecurityPolicyController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &SecurityPolicyReconciler{
+		securityPolicyConnecter: &securityPolicyProviderConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: securitypolicy.NewClient,
+		},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(securityPolicyControllerName).
+		For(&gcpcomputev1alpha1.SecurityPolicy{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listSecurityPolicies)).
+		Complete(r)
+}
+
+// Reconcile Cloud Armor security policies with the GCP API.
+func (r *SecurityPolicyReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	securityPolicyLog.V(logging.Debug).Info("reconciling", "kind", gcpcomputev1alpha1.SecurityPolicyKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), securityPolicyReconcileTimeout)
+	defer cancel()
+
+	p := &gcpcomputev1alpha1.SecurityPolicy{}
+	if err := r.kube.Get(ctx, req.NamespacedName, p); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get security policy %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, p)
+	if err != nil {
+		p.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, p), "cannot update security policy %s", req.NamespacedName)
+	}
+
+	// The policy has been deleted from the API server. Delete from GCP.
+	if p.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, p)}, errors.Wrapf(r.kube.Update(ctx, p), "cannot update security policy %s", req.NamespacedName)
+	}
+
+	// The policy is unnamed. Assume it has not been created in GCP.
+	if p.Status.PolicyName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, p)}, errors.Wrapf(r.kube.Update(ctx, p), "cannot update security policy %s", req.NamespacedName)
+	}
+
+	// The policy exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, p)}, errors.Wrapf(r.kube.Update(ctx, p), "cannot update security policy %s", req.NamespacedName)
+}
+
+// listSecurityPolicies is a provider.Lister of security policies.
+func listSecurityPolicies(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &gcpcomputev1alpha1.SecurityPolicyList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
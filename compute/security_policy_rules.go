@@ -0,0 +1,150 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+
+	compute "google.golang.org/api/compute/v1"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+)
+
+const (
+	// defaultRulePriority is the priority of the rule GCP requires every
+	// security policy to have, which matches any request not matched by a
+	// higher priority rule.
+	defaultRulePriority = 2147483647
+	defaultRuleAction   = "allow"
+
+	versionedExprSrcIPs = "SRC_IPS_V1"
+)
+
+// newSecurityPolicy returns a Cloud Armor security policy with the supplied
+// name, described by the supplied spec.
+func newSecurityPolicy(name string, spec gcpcomputev1alpha1.SecurityPolicySpec) *compute.SecurityPolicy {
+	return &compute.SecurityPolicy{
+		Name:        name,
+		Description: spec.Description,
+		Rules:       newRules(spec),
+	}
+}
+
+// newRules returns the Cloud Armor rules described by the supplied spec,
+// including the default rule.
+func newRules(spec gcpcomputev1alpha1.SecurityPolicySpec) []*compute.SecurityPolicyRule {
+	rules := make([]*compute.SecurityPolicyRule, 0, len(spec.Rules)+1)
+	for _, r := range spec.Rules {
+		rules = append(rules, newRule(r))
+	}
+
+	action := spec.DefaultAction
+	if action == "" {
+		action = defaultRuleAction
+	}
+	return append(rules, &compute.SecurityPolicyRule{
+		Priority:    defaultRulePriority,
+		Description: "Default rule",
+		Action:      action,
+		Match:       srcIPsMatcher([]string{"*"}),
+	})
+}
+
+func newRule(r gcpcomputev1alpha1.SecurityPolicyRule) *compute.SecurityPolicyRule {
+	rule := &compute.SecurityPolicyRule{
+		Priority:    r.Priority,
+		Description: r.Description,
+		Action:      r.Action,
+		Preview:     r.Preview,
+	}
+
+	switch {
+	case len(r.SourceIPRanges) > 0:
+		rule.Match = srcIPsMatcher(r.SourceIPRanges)
+	case r.PreconfiguredWAF != "":
+		rule.Match = exprMatcher(fmt.Sprintf("evaluatePreconfiguredWaf('%s')", r.PreconfiguredWAF))
+	default:
+		rule.Match = exprMatcher(r.Expression)
+	}
+
+	if rl := r.RateLimit; rl != nil {
+		rule.RateLimitOptions = &compute.SecurityPolicyRuleRateLimitOptions{
+			RateLimitThreshold: &compute.SecurityPolicyRuleRateLimitOptionsThreshold{
+				Count:       rl.Count,
+				IntervalSec: rl.IntervalSec,
+			},
+			ConformAction: rl.ConformAction,
+			ExceedAction:  rl.ExceedAction,
+			EnforceOnKey:  rl.EnforceOnKey,
+		}
+	}
+
+	return rule
+}
+
+func srcIPsMatcher(ranges []string) *compute.SecurityPolicyRuleMatcher {
+	return &compute.SecurityPolicyRuleMatcher{
+		VersionedExpr: versionedExprSrcIPs,
+		Config:        &compute.SecurityPolicyRuleMatcherConfig{SrcIpRanges: ranges},
+	}
+}
+
+func exprMatcher(expression string) *compute.SecurityPolicyRuleMatcher {
+	return &compute.SecurityPolicyRuleMatcher{Expr: &compute.Expr{Expression: expression}}
+}
+
+// diffRules returns the rules that must be added to and patched in a security
+// policy with the actual rules in order for it to have the wanted rules, and
+// the priorities of the rules that must be removed. GCP identifies rules by
+// their priority.
+func diffRules(want, actual []*compute.SecurityPolicyRule) (add, patch []*compute.SecurityPolicyRule, remove []int64) {
+	existing := make(map[int64]*compute.SecurityPolicyRule, len(actual))
+	for _, r := range actual {
+		existing[r.Priority] = r
+	}
+
+	wanted := make(map[int64]bool, len(want))
+	for _, r := range want {
+		wanted[r.Priority] = true
+		e, ok := existing[r.Priority]
+		switch {
+		case !ok:
+			add = append(add, r)
+		case !ruleUpToDate(r, e):
+			patch = append(patch, r)
+		}
+	}
+
+	for _, r := range actual {
+		if !wanted[r.Priority] {
+			remove = append(remove, r.Priority)
+		}
+	}
+
+	return add, patch, remove
+}
+
+// ruleUpToDate returns true if the actual rule matches the wanted rule. Fields
+// that are unset in the wanted rule are ignored because GCP defaults some of
+// them, for example rateLimitOptions.enforceOnKey. Preview is compared
+// explicitly so that taking a rule out of preview is not ignored. The source
+// IP ranges a rule matches are a set, and may be returned in any order.
+func ruleUpToDate(want, actual *compute.SecurityPolicyRule) bool {
+	return want.Preview == actual.Preview &&
+		compare.Equal(want, actual, compare.IgnoreUnset(), compare.Unordered("match.config.srcIpRanges"))
+}
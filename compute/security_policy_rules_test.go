@@ -0,0 +1,179 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	compute "google.golang.org/api/compute/v1"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+)
+
+func TestNewRules(t *testing.T) {
+	cases := map[string]struct {
+		spec gcpcomputev1alpha1.SecurityPolicySpec
+		want []*compute.SecurityPolicyRule
+	}{
+		"DefaultOnly": {
+			spec: gcpcomputev1alpha1.SecurityPolicySpec{},
+			want: []*compute.SecurityPolicyRule{
+				{Priority: defaultRulePriority, Description: "Default rule", Action: "allow", Match: srcIPsMatcher([]string{"*"})},
+			},
+		},
+		"AllRuleKinds": {
+			spec: gcpcomputev1alpha1.SecurityPolicySpec{
+				DefaultAction: "deny(403)",
+				Rules: []gcpcomputev1alpha1.SecurityPolicyRule{
+					{Priority: 100, Action: "allow", SourceIPRanges: []string{"10.0.0.0/8"}},
+					{Priority: 200, Action: "deny(403)", PreconfiguredWAF: "sqli-v33-stable", Preview: true},
+					{
+						Priority:   300,
+						Action:     "throttle",
+						Expression: "request.path.startsWith('/api')",
+						RateLimit: &gcpcomputev1alpha1.RateLimit{
+							Count:         100,
+							IntervalSec:   60,
+							ConformAction: "allow",
+							ExceedAction:  "deny(429)",
+							EnforceOnKey:  "IP",
+						},
+					},
+				},
+			},
+			want: []*compute.SecurityPolicyRule{
+				{Priority: 100, Action: "allow", Match: srcIPsMatcher([]string{"10.0.0.0/8"})},
+				{Priority: 200, Action: "deny(403)", Preview: true, Match: exprMatcher("evaluatePreconfiguredWaf('sqli-v33-stable')")},
+				{
+					Priority: 300,
+					Action:   "throttle",
+					Match:    exprMatcher("request.path.startsWith('/api')"),
+					RateLimitOptions: &compute.SecurityPolicyRuleRateLimitOptions{
+						RateLimitThreshold: &compute.SecurityPolicyRuleRateLimitOptionsThreshold{Count: 100, IntervalSec: 60},
+						ConformAction:      "allow",
+						ExceedAction:       "deny(429)",
+						EnforceOnKey:       "IP",
+					},
+				},
+				{Priority: defaultRulePriority, Description: "Default rule", Action: "deny(403)", Match: srcIPsMatcher([]string{"*"})},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := newRules(tc.spec)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("newRules(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDiffRules(t *testing.T) {
+	allow := &compute.SecurityPolicyRule{Priority: 100, Action: "allow", Match: srcIPsMatcher([]string{"10.0.0.0/8"})}
+	deny := &compute.SecurityPolicyRule{Priority: 100, Action: "deny(403)", Match: srcIPsMatcher([]string{"10.0.0.0/8"})}
+	other := &compute.SecurityPolicyRule{Priority: 200, Action: "allow", Match: exprMatcher("true")}
+	observed := &compute.SecurityPolicyRule{Priority: 100, Action: "allow", Match: srcIPsMatcher([]string{"10.0.0.0/8"}), Kind: "compute#securityPolicyRule"}
+	throttle := &compute.SecurityPolicyRule{
+		Priority: 300,
+		Action:   "throttle",
+		Match:    exprMatcher("true"),
+		RateLimitOptions: &compute.SecurityPolicyRuleRateLimitOptions{
+			RateLimitThreshold: &compute.SecurityPolicyRuleRateLimitOptionsThreshold{Count: 100, IntervalSec: 60},
+			ConformAction:      "allow",
+			ExceedAction:       "deny(429)",
+		},
+	}
+	defaulted := &compute.SecurityPolicyRule{
+		Priority: 300,
+		Action:   "throttle",
+		Match:    exprMatcher("true"),
+		RateLimitOptions: &compute.SecurityPolicyRuleRateLimitOptions{
+			RateLimitThreshold: &compute.SecurityPolicyRuleRateLimitOptionsThreshold{Count: 100, IntervalSec: 60},
+			ConformAction:      "allow",
+			ExceedAction:       "deny(429)",
+			EnforceOnKey:       "ALL",
+		},
+		Kind:           "compute#securityPolicyRule",
+		RuleTupleCount: 2,
+	}
+	ranges := &compute.SecurityPolicyRule{Priority: 100, Action: "allow", Match: srcIPsMatcher([]string{"10.0.0.0/8", "192.168.0.0/16"})}
+	reordered := &compute.SecurityPolicyRule{Priority: 100, Action: "allow", Match: srcIPsMatcher([]string{"192.168.0.0/16", "10.0.0.0/8"})}
+	previewed := &compute.SecurityPolicyRule{Priority: 100, Action: "allow", Match: srcIPsMatcher([]string{"10.0.0.0/8"}), Preview: true}
+
+	type want struct {
+		add    []*compute.SecurityPolicyRule
+		patch  []*compute.SecurityPolicyRule
+		remove []int64
+	}
+
+	cases := map[string]struct {
+		want   []*compute.SecurityPolicyRule
+		actual []*compute.SecurityPolicyRule
+		diff   want
+	}{
+		"UpToDate": {
+			want:   []*compute.SecurityPolicyRule{allow},
+			actual: []*compute.SecurityPolicyRule{observed},
+		},
+		"GCPDefaulted": {
+			want:   []*compute.SecurityPolicyRule{throttle},
+			actual: []*compute.SecurityPolicyRule{defaulted},
+		},
+		"SourceRangesReordered": {
+			want:   []*compute.SecurityPolicyRule{ranges},
+			actual: []*compute.SecurityPolicyRule{reordered},
+		},
+		"PreviewDisabled": {
+			want:   []*compute.SecurityPolicyRule{allow},
+			actual: []*compute.SecurityPolicyRule{previewed},
+			diff:   want{patch: []*compute.SecurityPolicyRule{allow}},
+		},
+		"Add": {
+			want:   []*compute.SecurityPolicyRule{allow, other},
+			actual: []*compute.SecurityPolicyRule{allow},
+			diff:   want{add: []*compute.SecurityPolicyRule{other}},
+		},
+		"Patch": {
+			want:   []*compute.SecurityPolicyRule{deny},
+			actual: []*compute.SecurityPolicyRule{allow},
+			diff:   want{patch: []*compute.SecurityPolicyRule{deny}},
+		},
+		"Remove": {
+			want:   []*compute.SecurityPolicyRule{allow},
+			actual: []*compute.SecurityPolicyRule{allow, other},
+			diff:   want{remove: []int64{200}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			add, patch, remove := diffRules(tc.want, tc.actual)
+			if diff := cmp.Diff(tc.diff.add, add); diff != "" {
+				t.Errorf("diffRules(...) add: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.diff.patch, patch); diff != "" {
+				t.Errorf("diffRules(...) patch: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.diff.remove, remove); diff != "" {
+				t.Errorf("diffRules(...) remove: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	fakesecuritypolicy "github.com/crossplaneio/crossplane/pkg/clients/gcp/securitypolicy/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	policyUID      = types.UID("cool-uid")
+	policyName     = securityPolicyNamePrefix + "cool-uid"
+	policySelfLink = "https://www.googleapis.com/compute/v1/projects/cool-project/global/securityPolicies/" + policyName
+	policyProject  = "cool-project"
+	backendService = "cool-backend"
+	staleBackend   = "stale-backend"
+)
+
+var (
+	errPolicyBoom     = errors.New("boom")
+	errPolicyNotFound = &googleapi.Error{Code: http.StatusNotFound}
+)
+
+// Test that our Reconciler implementation satisfies the Reconciler interface.
+var _ reconcile.Reconciler = &SecurityPolicyReconciler{}
+
+type securityPolicyModifier func(*gcpcomputev1alpha1.SecurityPolicy)
+
+func withPolicyConditions(c ...corev1alpha1.Condition) securityPolicyModifier {
+	return func(p *gcpcomputev1alpha1.SecurityPolicy) { p.Status.SetConditions(c...) }
+}
+
+func withPolicyFinalizers(f ...string) securityPolicyModifier {
+	return func(p *gcpcomputev1alpha1.SecurityPolicy) { p.ObjectMeta.Finalizers = f }
+}
+
+func withPolicyReclaimPolicy(r corev1alpha1.ReclaimPolicy) securityPolicyModifier {
+	return func(p *gcpcomputev1alpha1.SecurityPolicy) { p.Spec.ReclaimPolicy = r }
+}
+
+func withPolicyName(n string) securityPolicyModifier {
+	return func(p *gcpcomputev1alpha1.SecurityPolicy) { p.Status.PolicyName = n }
+}
+
+func withPolicySelfLink(l string) securityPolicyModifier {
+	return func(p *gcpcomputev1alpha1.SecurityPolicy) { p.Status.SelfLink = l }
+}
+
+func withPolicyBackendServices(bs ...string) securityPolicyModifier {
+	return func(p *gcpcomputev1alpha1.SecurityPolicy) { p.Spec.BackendServices = bs }
+}
+
+func withPolicyAttachedBackendServices(bs ...string) securityPolicyModifier {
+	return func(p *gcpcomputev1alpha1.SecurityPolicy) { p.Status.AttachedBackendServices = bs }
+}
+
+func securityPolicy(pm ...securityPolicyModifier) *gcpcomputev1alpha1.SecurityPolicy {
+	p := &gcpcomputev1alpha1.SecurityPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "cool-namespace",
+			Name:       "cool-policy",
+			UID:        policyUID,
+			Finalizers: []string{},
+		},
+		Spec: gcpcomputev1alpha1.SecurityPolicySpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: "cool-namespace", Name: "cool-provider"},
+			},
+		},
+	}
+
+	for _, m := range pm {
+		m(p)
+	}
+
+	return p
+}
+
+func TestSecurityPolicyCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         securityPolicyCreateSyncDeleter
+		p           *gcpcomputev1alpha1.SecurityPolicy
+		want        *gcpcomputev1alpha1.SecurityPolicy
+		wantRequeue bool
+	}{
+		{
+			name: "SuccessfulCreate",
+			csd: &cloudArmor{project: policyProject, client: &fakesecuritypolicy.MockClient{
+				MockInsertSecurityPolicy: func(_ context.Context, _ string, p *compute.SecurityPolicy) error {
+					if p.Name != policyName {
+						t.Errorf("p.Name: want %s, got %s", policyName, p.Name)
+					}
+					return nil
+				},
+			}},
+			p: securityPolicy(),
+			want: securityPolicy(
+				withPolicyFinalizers(securityPolicyFinalizer),
+				withPolicyName(policyName),
+				withPolicyConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &cloudArmor{project: policyProject, client: &fakesecuritypolicy.MockClient{
+				MockInsertSecurityPolicy: func(_ context.Context, _ string, _ *compute.SecurityPolicy) error { return errPolicyBoom },
+			}},
+			p: securityPolicy(),
+			want: securityPolicy(
+				withPolicyFinalizers(securityPolicyFinalizer),
+				withPolicyConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errPolicyBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.p)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.p, test.EquateConditions()); diff != "" {
+				t.Errorf("policy: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSecurityPolicySync(t *testing.T) {
+	existing := &compute.SecurityPolicy{
+		Name:     policyName,
+		SelfLink: policySelfLink,
+		Rules:    newRules(gcpcomputev1alpha1.SecurityPolicySpec{}),
+	}
+
+	cases := []struct {
+		name        string
+		csd         securityPolicyCreateSyncDeleter
+		p           *gcpcomputev1alpha1.SecurityPolicy
+		want        *gcpcomputev1alpha1.SecurityPolicy
+		wantRequeue bool
+	}{
+		{
+			name: "SuccessfulSyncAttach",
+			csd: &cloudArmor{project: policyProject, client: &fakesecuritypolicy.MockClient{
+				MockGetSecurityPolicy: func(_ context.Context, _, _ string) (*compute.SecurityPolicy, error) { return existing, nil },
+				MockGetBackendService: func(_ context.Context, _, _ string) (*compute.BackendService, error) {
+					return &compute.BackendService{}, nil
+				},
+				MockSetBackendServiceSecurityPolicy: func(_ context.Context, _, bs, p string) error {
+					switch {
+					case bs == backendService && p != policySelfLink:
+						t.Errorf("attach %s: want policy %s, got %s", bs, policySelfLink, p)
+					case bs == staleBackend && p != "":
+						t.Errorf("detach %s: want no policy, got %s", bs, p)
+					}
+					return nil
+				},
+			}},
+			p: securityPolicy(
+				withPolicyName(policyName),
+				withPolicyBackendServices(backendService),
+				withPolicyAttachedBackendServices(staleBackend),
+			),
+			want: securityPolicy(
+				withPolicyName(policyName),
+				withPolicySelfLink(policySelfLink),
+				withPolicyBackendServices(backendService),
+				withPolicyAttachedBackendServices(backendService),
+				withPolicyConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedAddRule",
+			csd: &cloudArmor{project: policyProject, client: &fakesecuritypolicy.MockClient{
+				MockGetSecurityPolicy: func(_ context.Context, _, _ string) (*compute.SecurityPolicy, error) {
+					return &compute.SecurityPolicy{Name: policyName, SelfLink: policySelfLink}, nil
+				},
+				MockAddRule: func(_ context.Context, _, _ string, _ *compute.SecurityPolicyRule) error { return errPolicyBoom },
+			}},
+			p: securityPolicy(withPolicyName(policyName)),
+			want: securityPolicy(
+				withPolicyName(policyName),
+				withPolicySelfLink(policySelfLink),
+				withPolicyConditions(corev1alpha1.ReconcileError(errors.Wrapf(errPolicyBoom, "cannot add rule with priority %d", defaultRulePriority))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGetSecurityPolicy",
+			csd: &cloudArmor{project: policyProject, client: &fakesecuritypolicy.MockClient{
+				MockGetSecurityPolicy: func(_ context.Context, _, _ string) (*compute.SecurityPolicy, error) { return nil, errPolicyBoom },
+			}},
+			p: securityPolicy(withPolicyName(policyName)),
+			want: securityPolicy(
+				withPolicyName(policyName),
+				withPolicyConditions(corev1alpha1.ReconcileError(errPolicyBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.p)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.p, test.EquateConditions()); diff != "" {
+				t.Errorf("policy: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSecurityPolicyDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         securityPolicyCreateSyncDeleter
+		p           *gcpcomputev1alpha1.SecurityPolicy
+		want        *gcpcomputev1alpha1.SecurityPolicy
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimRetainSuccessfulDelete",
+			csd:  &cloudArmor{project: policyProject},
+			p: securityPolicy(
+				withPolicyFinalizers(securityPolicyFinalizer),
+				withPolicyReclaimPolicy(corev1alpha1.ReclaimRetain),
+			),
+			want: securityPolicy(
+				withPolicyReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withPolicyConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteSuccessfulDelete",
+			csd: &cloudArmor{project: policyProject, client: &fakesecuritypolicy.MockClient{
+				MockSetBackendServiceSecurityPolicy: func(_ context.Context, _, _, _ string) error { return errPolicyNotFound },
+				MockDeleteSecurityPolicy:            func(_ context.Context, _, _ string) error { return nil },
+			}},
+			p: securityPolicy(
+				withPolicyFinalizers(securityPolicyFinalizer),
+				withPolicyReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withPolicyAttachedBackendServices(backendService),
+			),
+			want: securityPolicy(
+				withPolicyReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withPolicyConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailedDetach",
+			csd: &cloudArmor{project: policyProject, client: &fakesecuritypolicy.MockClient{
+				MockSetBackendServiceSecurityPolicy: func(_ context.Context, _, _, _ string) error { return errPolicyBoom },
+			}},
+			p: securityPolicy(
+				withPolicyFinalizers(securityPolicyFinalizer),
+				withPolicyReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withPolicyAttachedBackendServices(backendService),
+			),
+			want: securityPolicy(
+				withPolicyFinalizers(securityPolicyFinalizer),
+				withPolicyReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withPolicyAttachedBackendServices(backendService),
+				withPolicyConditions(
+					corev1alpha1.Deleting(),
+					corev1alpha1.ReconcileError(errors.Wrapf(errPolicyBoom, "cannot detach policy from backend service %s", backendService)),
+				),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.p)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.p, test.EquateConditions()); diff != "" {
+				t.Errorf("policy: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,476 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	compute "google.golang.org/api/compute/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/network"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	sharedVPCHostControllerName    = "sharedvpchostprojects.compute.gcp.crossplane.io"
+	sharedVPCHostFinalizer         = "finalizer." + sharedVPCHostControllerName
+	sharedVPCServiceControllerName = "sharedvpcserviceprojects.compute.gcp.crossplane.io"
+	sharedVPCServiceFinalizer      = "finalizer." + sharedVPCServiceControllerName
+
+	sharedVPCReconcileTimeout = 1 * time.Minute
+
+	xpnStatusHost = "HOST"
+)
+
+var (
+	sharedVPCHostLog    = logging.Logger.WithName("controller." + sharedVPCHostControllerName)
+	sharedVPCServiceLog = logging.Logger.WithName("controller." + sharedVPCServiceControllerName)
+)
+
+// A sharedVPCHostCreateSyncDeleter can enable, sync, and disable Shared VPC
+// host projects in an external store - e.g. the GCP API. Each method returns
+// true if the host project requires further reconciliation.
+type sharedVPCHostCreateSyncDeleter interface {
+	Create(ctx context.Context, h *gcpcomputev1alpha1.SharedVPCHostProject) (requeue bool)
+	Sync(ctx context.Context, h *gcpcomputev1alpha1.SharedVPCHostProject) (requeue bool)
+	Delete(ctx context.Context, h *gcpcomputev1alpha1.SharedVPCHostProject) (requeue bool)
+}
+
+// sharedVPCHosts is a sharedVPCHostCreateSyncDeleter using the GCP Compute
+// API.
+type sharedVPCHosts struct {
+	client  network.Client
+	project string
+}
+
+// Create enables the host project. The provider's project is used if the
+// host project does not specify one.
+func (c *sharedVPCHosts) Create(ctx context.Context, h *gcpcomputev1alpha1.SharedVPCHostProject) bool {
+	h.Status.SetConditions(corev1alpha1.Creating())
+	meta.AddFinalizer(h, sharedVPCHostFinalizer)
+
+	project := h.Spec.ProjectID
+	if project == "" {
+		project = c.project
+	}
+	if err := c.client.EnableXpnHost(ctx, project); err != nil {
+		h.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot enable shared vpc host project %s", project)))
+		return true
+	}
+
+	h.Status.ProjectID = project
+	h.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync enables the host project again if it was disabled outside of
+// Crossplane.
+func (c *sharedVPCHosts) Sync(ctx context.Context, h *gcpcomputev1alpha1.SharedVPCHostProject) bool {
+	p, err := c.client.GetProject(ctx, h.Status.ProjectID)
+	if err != nil {
+		h.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot get project %s", h.Status.ProjectID)))
+		return true
+	}
+
+	if p.XpnProjectStatus != xpnStatusHost {
+		if err := c.client.EnableXpnHost(ctx, h.Status.ProjectID); err != nil {
+			h.Status.SetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot enable shared vpc host project %s", h.Status.ProjectID)))
+			return true
+		}
+		h.Status.SetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	h.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete disables the host project. GCP refuses to disable a host project
+// while service projects are attached to it.
+func (c *sharedVPCHosts) Delete(ctx context.Context, h *gcpcomputev1alpha1.SharedVPCHostProject) bool {
+	h.Status.SetConditions(corev1alpha1.Deleting())
+
+	if h.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.client.DisableXpnHost(ctx, h.Status.ProjectID); err != nil && !googleapi.IsErrorNotFound(err) {
+			h.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot disable shared vpc host project %s", h.Status.ProjectID)))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(h, sharedVPCHostFinalizer)
+	h.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// A sharedVPCServiceCreateSyncDeleter can attach, sync, and detach Shared VPC
+// service projects in an external store - e.g. the GCP API. Each method
+// returns true if the service project requires further reconciliation.
+type sharedVPCServiceCreateSyncDeleter interface {
+	Create(ctx context.Context, s *gcpcomputev1alpha1.SharedVPCServiceProject) (requeue bool)
+	Sync(ctx context.Context, s *gcpcomputev1alpha1.SharedVPCServiceProject) (requeue bool)
+	Delete(ctx context.Context, s *gcpcomputev1alpha1.SharedVPCServiceProject) (requeue bool)
+}
+
+// sharedVPCServices is a sharedVPCServiceCreateSyncDeleter using the GCP
+// Compute API.
+type sharedVPCServices struct {
+	client network.Client
+	kube   client.Client
+}
+
+// Create attaches the service project to its host project.
+func (c *sharedVPCServices) Create(ctx context.Context, s *gcpcomputev1alpha1.SharedVPCServiceProject) bool {
+	s.Status.SetConditions(corev1alpha1.Creating())
+
+	host, err := resolveHostProject(ctx, c.kube, s)
+	if err != nil {
+		s.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	meta.AddFinalizer(s, sharedVPCServiceFinalizer)
+	if err := c.client.EnableXpnResource(ctx, host, s.Spec.ServiceProjectID); err != nil {
+		s.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot attach service project %s to host project %s", s.Spec.ServiceProjectID, host)))
+		return true
+	}
+
+	s.Status.HostProjectID = host
+	s.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync attaches the service project to its host project again if it was
+// detached outside of Crossplane.
+func (c *sharedVPCServices) Sync(ctx context.Context, s *gcpcomputev1alpha1.SharedVPCServiceProject) bool {
+	host, err := c.client.GetXpnHost(ctx, s.Spec.ServiceProjectID)
+	if err != nil && !googleapi.IsErrorNotFound(err) {
+		s.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot get host project of service project %s", s.Spec.ServiceProjectID)))
+		return true
+	}
+
+	if host == nil || host.Name != s.Status.HostProjectID {
+		if err := c.client.EnableXpnResource(ctx, s.Status.HostProjectID, s.Spec.ServiceProjectID); err != nil {
+			s.Status.SetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot attach service project %s to host project %s", s.Spec.ServiceProjectID, s.Status.HostProjectID)))
+			return true
+		}
+		s.Status.SetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	s.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete detaches the service project from its host project.
+func (c *sharedVPCServices) Delete(ctx context.Context, s *gcpcomputev1alpha1.SharedVPCServiceProject) bool {
+	s.Status.SetConditions(corev1alpha1.Deleting())
+
+	if s.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete && s.Status.HostProjectID != "" {
+		if err := c.client.DisableXpnResource(ctx, s.Status.HostProjectID, s.Spec.ServiceProjectID); err != nil && !googleapi.IsErrorNotFound(err) {
+			s.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot detach service project %s from host project %s", s.Spec.ServiceProjectID, s.Status.HostProjectID)))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(s, sharedVPCServiceFinalizer)
+	s.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// resolveHostProject returns the ID of the host project of the supplied
+// service project, which may either be specified directly or by reference to
+// a SharedVPCHostProject in the same namespace. It returns an error if a
+// referenced SharedVPCHostProject is not yet available.
+func resolveHostProject(ctx context.Context, kube client.Client, s *gcpcomputev1alpha1.SharedVPCServiceProject) (string, error) {
+	if s.Spec.HostProjectRef == nil {
+		if s.Spec.HostProjectID == "" {
+			return "", errors.New("one of hostProjectId or hostProjectRef must be set")
+		}
+		return s.Spec.HostProjectID, nil
+	}
+
+	h := &gcpcomputev1alpha1.SharedVPCHostProject{}
+	n := types.NamespacedName{Namespace: s.GetNamespace(), Name: s.Spec.HostProjectRef.Name}
+	if err := kube.Get(ctx, n, h); err != nil {
+		return "", errors.Wrapf(err, "cannot get shared vpc host project %s", n)
+	}
+	if h.Status.ProjectID == "" || h.Status.GetCondition(corev1alpha1.TypeReady).Status != corev1.ConditionTrue {
+		return "", errors.Errorf("shared vpc host project %s is not yet available", n)
+	}
+	return h.Status.ProjectID, nil
+}
+
+// sharedVPCProviderConnecter returns network clients authenticated using
+// credentials read from a Crossplane Provider resource.
+type sharedVPCProviderConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (network.Client, error)
+}
+
+// connect returns a client authenticated using credentials read from the
+// Provider referenced by the supplied managed resource, and that Provider.
+func (c *sharedVPCProviderConnecter) connect(ctx context.Context, mg metav1.Object, ref *corev1.ObjectReference) (network.Client, *gcpv1alpha1.Provider, error) {
+	p, err := c.providers.Get(ctx, c.kube, mg, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceCompute, compute.ComputeScope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return client, p, errors.Wrap(err, "cannot create new network client")
+}
+
+// A sharedVPCHostConnecter returns a sharedVPCHostCreateSyncDeleter that can
+// enable, sync, and disable host projects with an external store - for
+// example the GCP API.
+type sharedVPCHostConnecter interface {
+	Connect(context.Context, *gcpcomputev1alpha1.SharedVPCHostProject) (sharedVPCHostCreateSyncDeleter, error)
+}
+
+// sharedVPCHostProviderConnecter is a sharedVPCHostConnecter that returns a
+// sharedVPCHostCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type sharedVPCHostProviderConnecter struct {
+	*sharedVPCProviderConnecter
+}
+
+// Connect returns a sharedVPCHostCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// SharedVPCHostProject.
+func (c *sharedVPCHostProviderConnecter) Connect(ctx context.Context, h *gcpcomputev1alpha1.SharedVPCHostProject) (sharedVPCHostCreateSyncDeleter, error) {
+	client, p, err := c.connect(ctx, h, h.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &sharedVPCHosts{client: client, project: p.Spec.ProjectID}, nil
+}
+
+// A sharedVPCServiceConnecter returns a sharedVPCServiceCreateSyncDeleter that
+// can attach, sync, and detach service projects with an external store - for
+// example the GCP API.
+type sharedVPCServiceConnecter interface {
+	Connect(context.Context, *gcpcomputev1alpha1.SharedVPCServiceProject) (sharedVPCServiceCreateSyncDeleter, error)
+}
+
+// sharedVPCServiceProviderConnecter is a sharedVPCServiceConnecter that
+// returns a sharedVPCServiceCreateSyncDeleter authenticated using credentials
+// read from a Crossplane Provider resource.
+type sharedVPCServiceProviderConnecter struct {
+	*sharedVPCProviderConnecter
+}
+
+// Connect returns a sharedVPCServiceCreateSyncDeleter backed by the GCP API.
+// GCP credentials are read from the Crossplane Provider referenced by the
+// supplied SharedVPCServiceProject.
+func (c *sharedVPCServiceProviderConnecter) Connect(ctx context.Context, s *gcpcomputev1alpha1.SharedVPCServiceProject) (sharedVPCServiceCreateSyncDeleter, error) {
+	client, _, err := c.connect(ctx, s, s.Spec.ProviderReference)
+	return &sharedVPCServices{client: client, kube: c.kube}, err
+}
+
+// SharedVPCHostReconciler reconciles SharedVPCHostProjects read from the
+// Kubernetes API with an external store, typically the GCP API.
+type SharedVPCHostReconciler struct {
+	sharedVPCHostConnecter
+	kube client.Client
+}
+
+// SharedVPCHostProjectController is responsible for adding the
+// SharedVPCHostProject controller and its corresponding reconciler to the
+// manager with any runtime configuration.
+type SharedVPCHostProjectController struct {
+	// DefaultProvider is used by host projects that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new SharedVPCHostProject Controller and adds it
+// to the Manager with default RBAC. The Manager will set fields on the
+// Controller and start it when the Manager is Started.
+func (c *SharedVPCHostProjectController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &SharedVPCHostReconciler{
+		sharedVPCHostConnecter: &sharedVPCHostProviderConnecter{&sharedVPCProviderConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: network.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(sharedVPCHostControllerName).
+		For(&gcpcomputev1alpha1.SharedVPCHostProject{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listSharedVPCHostProjects)).
+		Complete(r)
+}
+
+// Reconcile Shared VPC host projects with the GCP API.
+func (r *SharedVPCHostReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	sharedVPCHostLog.V(logging.Debug).Info("reconciling", "kind", gcpcomputev1alpha1.SharedVPCHostProjectKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sharedVPCReconcileTimeout)
+	defer cancel()
+
+	h := &gcpcomputev1alpha1.SharedVPCHostProject{}
+	if err := r.kube.Get(ctx, req.NamespacedName, h); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get shared vpc host project %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, h)
+	if err != nil {
+		h.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, h), "cannot update shared vpc host project %s", req.NamespacedName)
+	}
+
+	// The host project has been deleted from the API server. Disable it in
+	// GCP.
+	if h.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, h)}, errors.Wrapf(r.kube.Update(ctx, h), "cannot update shared vpc host project %s", req.NamespacedName)
+	}
+
+	// The host project has not been enabled. Enable it.
+	if h.Status.ProjectID == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, h)}, errors.Wrapf(r.kube.Update(ctx, h), "cannot update shared vpc host project %s", req.NamespacedName)
+	}
+
+	// The host project exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, h)}, errors.Wrapf(r.kube.Update(ctx, h), "cannot update shared vpc host project %s", req.NamespacedName)
+}
+
+// SharedVPCServiceReconciler reconciles SharedVPCServiceProjects read from
+// the Kubernetes API with an external store, typically the GCP API.
+type SharedVPCServiceReconciler struct {
+	sharedVPCServiceConnecter
+	kube client.Client
+}
+
+// SharedVPCServiceProjectController is responsible for adding the
+// SharedVPCServiceProject controller and its corresponding reconciler to the
+// manager with any runtime configuration.
+type SharedVPCServiceProjectController struct {
+	// DefaultProvider is used by service projects that don't reference a
+	// provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new SharedVPCServiceProject Controller and adds
+// it to the Manager with default RBAC. The Manager will set fields on the
+// Controller and start it when the Manager is Started.
+func (c *SharedVPCServiceProjectController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &SharedVPCServiceReconciler{
+		sharedVPCServiceConnecter: &sharedVPCServiceProviderConnecter{&sharedVPCProviderConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: network.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(sharedVPCServiceControllerName).
+		For(&gcpcomputev1alpha1.SharedVPCServiceProject{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listSharedVPCServiceProjects)).
+		Complete(r)
+}
+
+// Reconcile Shared VPC service projects with the GCP API.
+func (r *SharedVPCServiceReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	sharedVPCServiceLog.V(logging.Debug).Info("reconciling", "kind", gcpcomputev1alpha1.SharedVPCServiceProjectKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sharedVPCReconcileTimeout)
+	defer cancel()
+
+	s := &gcpcomputev1alpha1.SharedVPCServiceProject{}
+	if err := r.kube.Get(ctx, req.NamespacedName, s); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get shared vpc service project %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, s)
+	if err != nil {
+		s.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, s), "cannot update shared vpc service project %s", req.NamespacedName)
+	}
+
+	// The service project has been deleted from the API server. Detach it
+	// in GCP.
+	if s.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, s)}, errors.Wrapf(r.kube.Update(ctx, s), "cannot update shared vpc service project %s", req.NamespacedName)
+	}
+
+	// The service project has not been attached. Attach it.
+	if s.Status.HostProjectID == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, s)}, errors.Wrapf(r.kube.Update(ctx, s), "cannot update shared vpc service project %s", req.NamespacedName)
+	}
+
+	// The service project exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, s)}, errors.Wrapf(r.kube.Update(ctx, s), "cannot update shared vpc service project %s", req.NamespacedName)
+}
+
+// listSharedVPCHostProjects is a provider.Lister of Shared VPC host projects.
+func listSharedVPCHostProjects(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &gcpcomputev1alpha1.SharedVPCHostProjectList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
+
+// listSharedVPCServiceProjects is a provider.Lister of Shared VPC service
+// projects.
+func listSharedVPCServiceProjects(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &gcpcomputev1alpha1.SharedVPCServiceProjectList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,356 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	compute "google.golang.org/api/compute/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	fakenetwork "github.com/crossplaneio/crossplane/pkg/clients/gcp/network/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	xpnHostProject    = "host-project"
+	xpnServiceProject = "service-project"
+)
+
+// Test that our Reconciler implementations satisfy the Reconciler interface.
+var (
+	_ reconcile.Reconciler = &SharedVPCHostReconciler{}
+	_ reconcile.Reconciler = &SharedVPCServiceReconciler{}
+)
+
+type sharedVPCHostModifier func(*gcpcomputev1alpha1.SharedVPCHostProject)
+
+func withHostConditions(c ...corev1alpha1.Condition) sharedVPCHostModifier {
+	return func(h *gcpcomputev1alpha1.SharedVPCHostProject) { h.Status.SetConditions(c...) }
+}
+
+func withHostFinalizers(f ...string) sharedVPCHostModifier {
+	return func(h *gcpcomputev1alpha1.SharedVPCHostProject) { h.ObjectMeta.Finalizers = f }
+}
+
+func withHostStatusProjectID(p string) sharedVPCHostModifier {
+	return func(h *gcpcomputev1alpha1.SharedVPCHostProject) { h.Status.ProjectID = p }
+}
+
+func sharedVPCHost(hm ...sharedVPCHostModifier) *gcpcomputev1alpha1.SharedVPCHostProject {
+	h := &gcpcomputev1alpha1.SharedVPCHostProject{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "cool-namespace", Name: "cool-host", Finalizers: []string{}},
+		Spec: gcpcomputev1alpha1.SharedVPCHostProjectSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: "cool-namespace", Name: "cool-provider"},
+			},
+		},
+	}
+
+	for _, m := range hm {
+		m(h)
+	}
+
+	return h
+}
+
+type sharedVPCServiceModifier func(*gcpcomputev1alpha1.SharedVPCServiceProject)
+
+func withServiceConditions(c ...corev1alpha1.Condition) sharedVPCServiceModifier {
+	return func(s *gcpcomputev1alpha1.SharedVPCServiceProject) { s.Status.SetConditions(c...) }
+}
+
+func withServiceFinalizers(f ...string) sharedVPCServiceModifier {
+	return func(s *gcpcomputev1alpha1.SharedVPCServiceProject) { s.ObjectMeta.Finalizers = f }
+}
+
+func withServiceHostProjectRef(n string) sharedVPCServiceModifier {
+	return func(s *gcpcomputev1alpha1.SharedVPCServiceProject) {
+		s.Spec.HostProjectRef = &corev1.LocalObjectReference{Name: n}
+	}
+}
+
+func withServiceStatusHostProjectID(p string) sharedVPCServiceModifier {
+	return func(s *gcpcomputev1alpha1.SharedVPCServiceProject) { s.Status.HostProjectID = p }
+}
+
+func sharedVPCService(sm ...sharedVPCServiceModifier) *gcpcomputev1alpha1.SharedVPCServiceProject {
+	s := &gcpcomputev1alpha1.SharedVPCServiceProject{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "cool-namespace", Name: "cool-service", Finalizers: []string{}},
+		Spec: gcpcomputev1alpha1.SharedVPCServiceProjectSpec{
+			ServiceProjectID: xpnServiceProject,
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: "cool-namespace", Name: "cool-provider"},
+			},
+		},
+	}
+
+	for _, m := range sm {
+		m(s)
+	}
+
+	return s
+}
+
+func TestSharedVPCHostCreateSync(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	cases := []struct {
+		name        string
+		create      bool
+		csd         sharedVPCHostCreateSyncDeleter
+		h           *gcpcomputev1alpha1.SharedVPCHostProject
+		want        *gcpcomputev1alpha1.SharedVPCHostProject
+		wantRequeue bool
+	}{
+		{
+			name:   "CreateDefaultsToProviderProject",
+			create: true,
+			csd: &sharedVPCHosts{project: xpnHostProject, client: &fakenetwork.MockClient{
+				MockEnableXpnHost: func(_ context.Context, p string) error {
+					if p != xpnHostProject {
+						t.Errorf("EnableXpnHost(...): want %s, got %s", xpnHostProject, p)
+					}
+					return nil
+				},
+			}},
+			h: sharedVPCHost(),
+			want: sharedVPCHost(
+				withHostFinalizers(sharedVPCHostFinalizer),
+				withHostStatusProjectID(xpnHostProject),
+				withHostConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name:   "FailedCreate",
+			create: true,
+			csd: &sharedVPCHosts{project: xpnHostProject, client: &fakenetwork.MockClient{
+				MockEnableXpnHost: func(_ context.Context, _ string) error { return errBoom },
+			}},
+			h: sharedVPCHost(),
+			want: sharedVPCHost(
+				withHostFinalizers(sharedVPCHostFinalizer),
+				withHostConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrapf(errBoom, "cannot enable shared vpc host project %s", xpnHostProject))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "SyncHostEnabled",
+			csd: &sharedVPCHosts{project: xpnHostProject, client: &fakenetwork.MockClient{
+				MockGetProject: func(_ context.Context, _ string) (*compute.Project, error) {
+					return &compute.Project{XpnProjectStatus: xpnStatusHost}, nil
+				},
+			}},
+			h: sharedVPCHost(withHostStatusProjectID(xpnHostProject)),
+			want: sharedVPCHost(
+				withHostStatusProjectID(xpnHostProject),
+				withHostConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "SyncHostDisabled",
+			csd: &sharedVPCHosts{project: xpnHostProject, client: &fakenetwork.MockClient{
+				MockGetProject:    func(_ context.Context, _ string) (*compute.Project, error) { return &compute.Project{}, nil },
+				MockEnableXpnHost: func(_ context.Context, _ string) error { return nil },
+			}},
+			h: sharedVPCHost(withHostStatusProjectID(xpnHostProject)),
+			want: sharedVPCHost(
+				withHostStatusProjectID(xpnHostProject),
+				withHostConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotRequeue bool
+			if tc.create {
+				gotRequeue = tc.csd.Create(ctx, tc.h)
+			} else {
+				gotRequeue = tc.csd.Sync(ctx, tc.h)
+			}
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("requeue: want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.h, test.EquateConditions()); diff != "" {
+				t.Errorf("host project: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSharedVPCServiceCreate(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	hostReady := func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+		h := sharedVPCHost(withHostStatusProjectID(xpnHostProject), withHostConditions(corev1alpha1.Available()))
+		h.DeepCopyInto(obj.(*gcpcomputev1alpha1.SharedVPCHostProject))
+		return nil
+	}
+	hostNotReady := func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+		h := sharedVPCHost(withHostStatusProjectID(xpnHostProject), withHostConditions(corev1alpha1.Creating()))
+		h.DeepCopyInto(obj.(*gcpcomputev1alpha1.SharedVPCHostProject))
+		return nil
+	}
+
+	cases := []struct {
+		name        string
+		csd         sharedVPCServiceCreateSyncDeleter
+		s           *gcpcomputev1alpha1.SharedVPCServiceProject
+		want        *gcpcomputev1alpha1.SharedVPCServiceProject
+		wantRequeue bool
+	}{
+		{
+			name: "SuccessfulCreate",
+			csd: &sharedVPCServices{
+				kube: &test.MockClient{MockGet: hostReady},
+				client: &fakenetwork.MockClient{
+					MockEnableXpnResource: func(_ context.Context, host, service string) error {
+						if host != xpnHostProject || service != xpnServiceProject {
+							t.Errorf("EnableXpnResource(...): want %s/%s, got %s/%s", xpnHostProject, xpnServiceProject, host, service)
+						}
+						return nil
+					},
+				},
+			},
+			s: sharedVPCService(withServiceHostProjectRef("cool-host")),
+			want: sharedVPCService(
+				withServiceHostProjectRef("cool-host"),
+				withServiceFinalizers(sharedVPCServiceFinalizer),
+				withServiceStatusHostProjectID(xpnHostProject),
+				withServiceConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "HostProjectNotReady",
+			csd:  &sharedVPCServices{kube: &test.MockClient{MockGet: hostNotReady}},
+			s:    sharedVPCService(withServiceHostProjectRef("cool-host")),
+			want: sharedVPCService(
+				withServiceHostProjectRef("cool-host"),
+				withServiceConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.New("shared vpc host project cool-namespace/cool-host is not yet available"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "NoHostProject",
+			csd:  &sharedVPCServices{},
+			s:    sharedVPCService(),
+			want: sharedVPCService(
+				withServiceConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.New("one of hostProjectId or hostProjectRef must be set"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedAttach",
+			csd: &sharedVPCServices{
+				kube: &test.MockClient{MockGet: hostReady},
+				client: &fakenetwork.MockClient{
+					MockEnableXpnResource: func(_ context.Context, _, _ string) error { return errBoom },
+				},
+			},
+			s: sharedVPCService(withServiceHostProjectRef("cool-host")),
+			want: sharedVPCService(
+				withServiceHostProjectRef("cool-host"),
+				withServiceFinalizers(sharedVPCServiceFinalizer),
+				withServiceConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(
+					errors.Wrapf(errBoom, "cannot attach service project %s to host project %s", xpnServiceProject, xpnHostProject))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.s)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.s, test.EquateConditions()); diff != "" {
+				t.Errorf("service project: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSharedVPCServiceSync(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         sharedVPCServiceCreateSyncDeleter
+		s           *gcpcomputev1alpha1.SharedVPCServiceProject
+		want        *gcpcomputev1alpha1.SharedVPCServiceProject
+		wantRequeue bool
+	}{
+		{
+			name: "Attached",
+			csd: &sharedVPCServices{client: &fakenetwork.MockClient{
+				MockGetXpnHost: func(_ context.Context, _ string) (*compute.Project, error) {
+					return &compute.Project{Name: xpnHostProject}, nil
+				},
+			}},
+			s: sharedVPCService(withServiceStatusHostProjectID(xpnHostProject)),
+			want: sharedVPCService(
+				withServiceStatusHostProjectID(xpnHostProject),
+				withServiceConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "Detached",
+			csd: &sharedVPCServices{client: &fakenetwork.MockClient{
+				MockGetXpnHost:        func(_ context.Context, _ string) (*compute.Project, error) { return nil, nil },
+				MockEnableXpnResource: func(_ context.Context, _, _ string) error { return nil },
+			}},
+			s: sharedVPCService(withServiceStatusHostProjectID(xpnHostProject)),
+			want: sharedVPCService(
+				withServiceStatusHostProjectID(xpnHostProject),
+				withServiceConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.s)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.s, test.EquateConditions()); diff != "" {
+				t.Errorf("service project: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
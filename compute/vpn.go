@@ -0,0 +1,793 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	compute "google.golang.org/api/compute/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/vpn"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	vpnGatewayControllerName         = "vpngateways.compute.gcp.crossplane.io"
+	vpnGatewayFinalizer              = "finalizer." + vpnGatewayControllerName
+	vpnGatewayNamePrefix             = "vpngw-"
+	externalVpnGatewayControllerName = "externalvpngateways.compute.gcp.crossplane.io"
+	externalVpnGatewayFinalizer      = "finalizer." + externalVpnGatewayControllerName
+	externalVpnGatewayNamePrefix     = "extvpngw-"
+	vpnTunnelControllerName          = "vpntunnels.compute.gcp.crossplane.io"
+	vpnTunnelFinalizer               = "finalizer." + vpnTunnelControllerName
+	vpnTunnelNamePrefix              = "vpntunnel-"
+
+	vpnReconcileTimeout = 1 * time.Minute
+
+	tunnelStatusEstablished = "ESTABLISHED"
+)
+
+var (
+	vpnGatewayLog         = logging.Logger.WithName("controller." + vpnGatewayControllerName)
+	externalVpnGatewayLog = logging.Logger.WithName("controller." + externalVpnGatewayControllerName)
+	vpnTunnelLog          = logging.Logger.WithName("controller." + vpnTunnelControllerName)
+)
+
+// externalVpnGatewayInterfaces is the number of interfaces required by each
+// redundancy type of external VPN gateway.
+var externalVpnGatewayInterfaces = map[string]int{
+	"SINGLE_IP_INTERNALLY_REDUNDANT": 1,
+	"TWO_IPS_REDUNDANCY":             2,
+	"FOUR_IPS_REDUNDANCY":            4,
+}
+
+// A vpnGatewayCreateSyncDeleter can create, sync, and delete HA VPN gateways
+// in an external store - e.g. the GCP API. Each method returns true if the
+// gateway requires further reconciliation.
+type vpnGatewayCreateSyncDeleter interface {
+	Create(ctx context.Context, g *gcpcomputev1alpha1.VpnGateway) (requeue bool)
+	Sync(ctx context.Context, g *gcpcomputev1alpha1.VpnGateway) (requeue bool)
+	Delete(ctx context.Context, g *gcpcomputev1alpha1.VpnGateway) (requeue bool)
+}
+
+// vpnGateways is a vpnGatewayCreateSyncDeleter using the GCP Compute API.
+type vpnGateways struct {
+	client  vpn.Client
+	project string
+}
+
+// Create inserts the HA VPN gateway described by the supplied VpnGateway.
+func (c *vpnGateways) Create(ctx context.Context, g *gcpcomputev1alpha1.VpnGateway) bool {
+	g.Status.SetConditions(corev1alpha1.Creating())
+	meta.AddFinalizer(g, vpnGatewayFinalizer)
+
+	name := fmt.Sprintf("%s%s", vpnGatewayNamePrefix, g.GetUID())
+	if err := c.client.InsertVpnGateway(ctx, c.project, g.Spec.Region, newVpnGateway(c.project, name, g.Spec)); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		g.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot insert vpn gateway")))
+		return true
+	}
+
+	g.Status.GatewayName = name
+	g.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync records the self link of the HA VPN gateway and the external IP
+// addresses GCP allocated to its interfaces, which must be configured on the
+// peer gateway. HA VPN gateways cannot be updated once inserted.
+func (c *vpnGateways) Sync(ctx context.Context, g *gcpcomputev1alpha1.VpnGateway) bool {
+	actual, err := c.client.GetVpnGateway(ctx, c.project, g.Spec.Region, g.Status.GatewayName)
+	if err != nil {
+		g.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot get vpn gateway %s", g.Status.GatewayName)))
+		return true
+	}
+
+	g.Status.SelfLink = actual.SelfLink
+	g.Status.Interfaces = make([]gcpcomputev1alpha1.VpnGatewayInterface, 0, len(actual.VpnInterfaces))
+	for _, i := range actual.VpnInterfaces {
+		g.Status.Interfaces = append(g.Status.Interfaces, gcpcomputev1alpha1.VpnGatewayInterface{ID: i.Id, IPAddress: i.IpAddress})
+	}
+
+	g.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete deletes the HA VPN gateway. GCP refuses to delete a gateway that is
+// used by a VPN tunnel.
+func (c *vpnGateways) Delete(ctx context.Context, g *gcpcomputev1alpha1.VpnGateway) bool {
+	g.Status.SetConditions(corev1alpha1.Deleting())
+
+	if g.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.client.DeleteVpnGateway(ctx, c.project, g.Spec.Region, g.Status.GatewayName); err != nil && !googleapi.IsErrorNotFound(err) {
+			g.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete vpn gateway")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(g, vpnGatewayFinalizer)
+	g.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// newVpnGateway returns the HA VPN gateway with the supplied name described
+// by the supplied spec.
+func newVpnGateway(project, name string, spec gcpcomputev1alpha1.VpnGatewaySpec) *compute.VpnGateway {
+	return &compute.VpnGateway{
+		Name:        name,
+		Description: spec.Description,
+		Network:     networkURL(project, spec.Network),
+	}
+}
+
+// An externalVpnGatewayCreateSyncDeleter can create, sync, and delete
+// external VPN gateways in an external store - e.g. the GCP API. Each method
+// returns true if the gateway requires further reconciliation.
+type externalVpnGatewayCreateSyncDeleter interface {
+	Create(ctx context.Context, g *gcpcomputev1alpha1.ExternalVpnGateway) (requeue bool)
+	Sync(ctx context.Context, g *gcpcomputev1alpha1.ExternalVpnGateway) (requeue bool)
+	Delete(ctx context.Context, g *gcpcomputev1alpha1.ExternalVpnGateway) (requeue bool)
+}
+
+// externalVpnGateways is an externalVpnGatewayCreateSyncDeleter using the GCP
+// Compute API.
+type externalVpnGateways struct {
+	client  vpn.Client
+	project string
+}
+
+// Create inserts the external VPN gateway described by the supplied
+// ExternalVpnGateway. An external VPN gateway describes a peer gateway, such
+// as an on-premises VPN device, to GCP.
+func (c *externalVpnGateways) Create(ctx context.Context, g *gcpcomputev1alpha1.ExternalVpnGateway) bool {
+	g.Status.SetConditions(corev1alpha1.Creating())
+
+	if err := validateExternalVpnGateway(g.Spec); err != nil {
+		g.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return false
+	}
+
+	meta.AddFinalizer(g, externalVpnGatewayFinalizer)
+	name := fmt.Sprintf("%s%s", externalVpnGatewayNamePrefix, g.GetUID())
+	if err := c.client.InsertExternalVpnGateway(ctx, c.project, newExternalVpnGateway(name, g.Spec)); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		g.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot insert external vpn gateway")))
+		return true
+	}
+
+	g.Status.GatewayName = name
+	g.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync records the self link of the external VPN gateway. External VPN
+// gateways cannot be updated once inserted.
+func (c *externalVpnGateways) Sync(ctx context.Context, g *gcpcomputev1alpha1.ExternalVpnGateway) bool {
+	actual, err := c.client.GetExternalVpnGateway(ctx, c.project, g.Status.GatewayName)
+	if err != nil {
+		g.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot get external vpn gateway %s", g.Status.GatewayName)))
+		return true
+	}
+
+	g.Status.SelfLink = actual.SelfLink
+	g.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete deletes the external VPN gateway. GCP refuses to delete a gateway
+// that is the peer of a VPN tunnel.
+func (c *externalVpnGateways) Delete(ctx context.Context, g *gcpcomputev1alpha1.ExternalVpnGateway) bool {
+	g.Status.SetConditions(corev1alpha1.Deleting())
+
+	if g.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete && g.Status.GatewayName != "" {
+		if err := c.client.DeleteExternalVpnGateway(ctx, c.project, g.Status.GatewayName); err != nil && !googleapi.IsErrorNotFound(err) {
+			g.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete external vpn gateway")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(g, externalVpnGatewayFinalizer)
+	g.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// validateExternalVpnGateway returns an error if the supplied spec does not
+// describe as many interfaces as its redundancy type requires.
+func validateExternalVpnGateway(spec gcpcomputev1alpha1.ExternalVpnGatewaySpec) error {
+	want, ok := externalVpnGatewayInterfaces[spec.RedundancyType]
+	if !ok {
+		return errors.Errorf("unknown redundancy type %q", spec.RedundancyType)
+	}
+	if len(spec.Interfaces) != want {
+		return errors.Errorf("redundancy type %s requires %d interfaces, not %d", spec.RedundancyType, want, len(spec.Interfaces))
+	}
+	return nil
+}
+
+// newExternalVpnGateway returns the external VPN gateway with the supplied
+// name described by the supplied spec.
+func newExternalVpnGateway(name string, spec gcpcomputev1alpha1.ExternalVpnGatewaySpec) *compute.ExternalVpnGateway {
+	g := &compute.ExternalVpnGateway{
+		Name:           name,
+		Description:    spec.Description,
+		RedundancyType: spec.RedundancyType,
+	}
+	for _, i := range spec.Interfaces {
+		g.Interfaces = append(g.Interfaces, &compute.ExternalVpnGatewayInterface{
+			Id:        i.ID,
+			IpAddress: i.IPAddress,
+			// The first interface has ID 0.
+			ForceSendFields: []string{"Id"},
+		})
+	}
+	return g
+}
+
+// A vpnTunnelCreateSyncDeleter can create, sync, and delete VPN tunnels in an
+// external store - e.g. the GCP API. Each method returns true if the tunnel
+// requires further reconciliation.
+type vpnTunnelCreateSyncDeleter interface {
+	Create(ctx context.Context, t *gcpcomputev1alpha1.VpnTunnel) (requeue bool)
+	Sync(ctx context.Context, t *gcpcomputev1alpha1.VpnTunnel) (requeue bool)
+	Delete(ctx context.Context, t *gcpcomputev1alpha1.VpnTunnel) (requeue bool)
+}
+
+// vpnTunnels is a vpnTunnelCreateSyncDeleter using the GCP Compute API.
+type vpnTunnels struct {
+	client  vpn.Client
+	kube    client.Client
+	project string
+}
+
+// Create inserts the VPN tunnel described by the supplied VpnTunnel. The
+// gateways it connects may be referenced by name or by reference to a
+// VpnGateway or ExternalVpnGateway in the same namespace, in which case the
+// tunnel is not inserted until they are available.
+func (c *vpnTunnels) Create(ctx context.Context, t *gcpcomputev1alpha1.VpnTunnel) bool {
+	t.Status.SetConditions(corev1alpha1.Creating())
+
+	if err := validateVpnTunnel(t.Spec); err != nil {
+		t.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return false
+	}
+
+	tunnel, err := c.newVpnTunnel(ctx, t)
+	if err != nil {
+		t.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	meta.AddFinalizer(t, vpnTunnelFinalizer)
+	if err := c.client.InsertVpnTunnel(ctx, c.project, t.Spec.Region, tunnel); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		t.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot insert vpn tunnel")))
+		return true
+	}
+
+	t.Status.TunnelName = tunnel.Name
+	t.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync reports whether the VPN tunnel is established. VPN tunnels cannot be
+// updated once inserted.
+func (c *vpnTunnels) Sync(ctx context.Context, t *gcpcomputev1alpha1.VpnTunnel) bool {
+	actual, err := c.client.GetVpnTunnel(ctx, c.project, t.Spec.Region, t.Status.TunnelName)
+	if err != nil {
+		t.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot get vpn tunnel %s", t.Status.TunnelName)))
+		return true
+	}
+	t.Status.SelfLink = actual.SelfLink
+	t.Status.State = actual.Status
+	t.Status.DetailedStatus = actual.DetailedStatus
+
+	// A tunnel is not established until the peer gateway is configured with
+	// the same shared secret, which may happen long after it is inserted.
+	if actual.Status != tunnelStatusEstablished {
+		t.Status.SetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	t.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete deletes the VPN tunnel.
+func (c *vpnTunnels) Delete(ctx context.Context, t *gcpcomputev1alpha1.VpnTunnel) bool {
+	t.Status.SetConditions(corev1alpha1.Deleting())
+
+	if t.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete && t.Status.TunnelName != "" {
+		if err := c.client.DeleteVpnTunnel(ctx, c.project, t.Spec.Region, t.Status.TunnelName); err != nil && !googleapi.IsErrorNotFound(err) {
+			t.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete vpn tunnel")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(t, vpnTunnelFinalizer)
+	t.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// validateVpnTunnel returns an error if the supplied spec does not describe
+// exactly one gateway and one peer gateway, or does not reference a shared
+// secret.
+func validateVpnTunnel(spec gcpcomputev1alpha1.VpnTunnelSpec) error {
+	if (spec.VpnGateway == "") == (spec.VpnGatewayRef == nil) {
+		return errors.New("exactly one of vpnGateway or vpnGatewayRef must be set")
+	}
+	peers := 0
+	for _, set := range []bool{spec.PeerExternalGateway != "", spec.PeerExternalGatewayRef != nil, spec.PeerGCPGateway != ""} {
+		if set {
+			peers++
+		}
+	}
+	if peers != 1 {
+		return errors.New("exactly one of peerExternalGateway, peerExternalGatewayRef, or peerGcpGateway must be set")
+	}
+	if spec.Router == "" {
+		return errors.New("router must be set; HA VPN tunnels require a Cloud Router")
+	}
+	if spec.SharedSecretSecretRef.Name == "" || spec.SharedSecretSecretRef.Key == "" {
+		return errors.New("sharedSecretSecretRef must reference a secret key")
+	}
+	return nil
+}
+
+// newVpnTunnel returns the VPN tunnel described by the supplied VpnTunnel,
+// resolving its gateway references and reading its shared secret.
+func (c *vpnTunnels) newVpnTunnel(ctx context.Context, t *gcpcomputev1alpha1.VpnTunnel) (*compute.VpnTunnel, error) {
+	gw, err := resolveVpnGateway(ctx, c.kube, t)
+	if err != nil {
+		return nil, err
+	}
+	peer, err := resolveExternalVpnGateway(ctx, c.kube, t)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &corev1.Secret{}
+	n := types.NamespacedName{Namespace: t.GetNamespace(), Name: t.Spec.SharedSecretSecretRef.Name}
+	if err := c.kube.Get(ctx, n, s); err != nil {
+		return nil, errors.Wrapf(err, "cannot get shared secret %s", n)
+	}
+	secret, ok := s.Data[t.Spec.SharedSecretSecretRef.Key]
+	if !ok || len(secret) == 0 {
+		return nil, errors.Errorf("shared secret %s has no key %s", n, t.Spec.SharedSecretSecretRef.Key)
+	}
+
+	return &compute.VpnTunnel{
+		Name:                         fmt.Sprintf("%s%s", vpnTunnelNamePrefix, t.GetUID()),
+		Description:                  t.Spec.Description,
+		VpnGateway:                   gw,
+		VpnGatewayInterface:          t.Spec.VpnGatewayInterface,
+		PeerExternalGateway:          peer,
+		PeerExternalGatewayInterface: t.Spec.PeerExternalGatewayInterface,
+		PeerGcpGateway:               t.Spec.PeerGCPGateway,
+		Router:                       t.Spec.Router,
+		IkeVersion:                   t.Spec.IKEVersion,
+		SharedSecret:                 string(secret),
+		// Interface 0 is the first interface of each gateway.
+		ForceSendFields: []string{"VpnGatewayInterface", "PeerExternalGatewayInterface"},
+	}, nil
+}
+
+// resolveVpnGateway returns the HA VPN gateway of the supplied tunnel, which
+// may either be specified directly or by reference to a VpnGateway in the same
+// namespace. It returns an error if a referenced VpnGateway is not yet
+// available.
+func resolveVpnGateway(ctx context.Context, kube client.Client, t *gcpcomputev1alpha1.VpnTunnel) (string, error) {
+	if t.Spec.VpnGatewayRef == nil {
+		return t.Spec.VpnGateway, nil
+	}
+
+	g := &gcpcomputev1alpha1.VpnGateway{}
+	n := types.NamespacedName{Namespace: t.GetNamespace(), Name: t.Spec.VpnGatewayRef.Name}
+	if err := kube.Get(ctx, n, g); err != nil {
+		return "", errors.Wrapf(err, "cannot get vpn gateway %s", n)
+	}
+	if g.Status.SelfLink == "" || g.Status.GetCondition(corev1alpha1.TypeReady).Status != corev1.ConditionTrue {
+		return "", errors.Errorf("vpn gateway %s is not yet available", n)
+	}
+	return g.Status.SelfLink, nil
+}
+
+// resolveExternalVpnGateway returns the external peer gateway of the supplied
+// tunnel, which may either be specified directly or by reference to an
+// ExternalVpnGateway in the same namespace. It returns an error if a
+// referenced ExternalVpnGateway is not yet available.
+func resolveExternalVpnGateway(ctx context.Context, kube client.Client, t *gcpcomputev1alpha1.VpnTunnel) (string, error) {
+	if t.Spec.PeerExternalGatewayRef == nil {
+		return t.Spec.PeerExternalGateway, nil
+	}
+
+	g := &gcpcomputev1alpha1.ExternalVpnGateway{}
+	n := types.NamespacedName{Namespace: t.GetNamespace(), Name: t.Spec.PeerExternalGatewayRef.Name}
+	if err := kube.Get(ctx, n, g); err != nil {
+		return "", errors.Wrapf(err, "cannot get external vpn gateway %s", n)
+	}
+	if g.Status.SelfLink == "" || g.Status.GetCondition(corev1alpha1.TypeReady).Status != corev1.ConditionTrue {
+		return "", errors.Errorf("external vpn gateway %s is not yet available", n)
+	}
+	return g.Status.SelfLink, nil
+}
+
+// vpnProviderConnecter returns VPN clients authenticated using credentials
+// read from a Crossplane Provider resource.
+type vpnProviderConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (vpn.Client, error)
+}
+
+// connect returns a client authenticated using credentials read from the
+// Provider referenced by the supplied managed resource, and that Provider.
+func (c *vpnProviderConnecter) connect(ctx context.Context, mg metav1.Object, ref *corev1.ObjectReference) (vpn.Client, *gcpv1alpha1.Provider, error) {
+	p, err := c.providers.Get(ctx, c.kube, mg, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceCompute, compute.ComputeScope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return client, p, errors.Wrap(err, "cannot create new vpn client")
+}
+
+// A vpnGatewayConnecter returns a vpnGatewayCreateSyncDeleter that can
+// create, sync, and delete HA VPN gateways with an external store - for
+// example the GCP API.
+type vpnGatewayConnecter interface {
+	Connect(context.Context, *gcpcomputev1alpha1.VpnGateway) (vpnGatewayCreateSyncDeleter, error)
+}
+
+// vpnGatewayProviderConnecter is a vpnGatewayConnecter that returns a
+// vpnGatewayCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type vpnGatewayProviderConnecter struct {
+	*vpnProviderConnecter
+}
+
+// Connect returns a vpnGatewayCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// VpnGateway.
+func (c *vpnGatewayProviderConnecter) Connect(ctx context.Context, g *gcpcomputev1alpha1.VpnGateway) (vpnGatewayCreateSyncDeleter, error) {
+	client, p, err := c.connect(ctx, g, g.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &vpnGateways{client: client, project: p.Spec.ProjectID}, nil
+}
+
+// An externalVpnGatewayConnecter returns an
+// externalVpnGatewayCreateSyncDeleter that can create, sync, and delete
+// external VPN gateways with an external store - for example the GCP API.
+type externalVpnGatewayConnecter interface {
+	Connect(context.Context, *gcpcomputev1alpha1.ExternalVpnGateway) (externalVpnGatewayCreateSyncDeleter, error)
+}
+
+// externalVpnGatewayProviderConnecter is an externalVpnGatewayConnecter that
+// returns an externalVpnGatewayCreateSyncDeleter authenticated using
+// credentials read from a Crossplane Provider resource.
+type externalVpnGatewayProviderConnecter struct {
+	*vpnProviderConnecter
+}
+
+// Connect returns an externalVpnGatewayCreateSyncDeleter backed by the GCP
+// API. GCP credentials are read from the Crossplane Provider referenced by the
+// supplied ExternalVpnGateway.
+func (c *externalVpnGatewayProviderConnecter) Connect(ctx context.Context, g *gcpcomputev1alpha1.ExternalVpnGateway) (externalVpnGatewayCreateSyncDeleter, error) {
+	client, p, err := c.connect(ctx, g, g.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &externalVpnGateways{client: client, project: p.Spec.ProjectID}, nil
+}
+
+// A vpnTunnelConnecter returns a vpnTunnelCreateSyncDeleter that can create,
+// sync, and delete VPN tunnels with an external store - for example the GCP
+// API.
+type vpnTunnelConnecter interface {
+	Connect(context.Context, *gcpcomputev1alpha1.VpnTunnel) (vpnTunnelCreateSyncDeleter, error)
+}
+
+// vpnTunnelProviderConnecter is a vpnTunnelConnecter that returns a
+// vpnTunnelCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type vpnTunnelProviderConnecter struct {
+	*vpnProviderConnecter
+}
+
+// Connect returns a vpnTunnelCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// VpnTunnel.
+func (c *vpnTunnelProviderConnecter) Connect(ctx context.Context, t *gcpcomputev1alpha1.VpnTunnel) (vpnTunnelCreateSyncDeleter, error) {
+	client, p, err := c.connect(ctx, t, t.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &vpnTunnels{client: client, kube: c.kube, project: p.Spec.ProjectID}, nil
+}
+
+// VpnGatewayReconciler reconciles VpnGateways read from the Kubernetes API
+// with an external store, typically the GCP API.
+type VpnGatewayReconciler struct {
+	vpnGatewayConnecter
+	kube client.Client
+}
+
+// VpnGatewayController is responsible for adding the VpnGateway controller
+// and its corresponding reconciler to the manager with any runtime
+// configuration.
+type VpnGatewayController struct {
+	// DefaultProvider is used by gateways that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new VpnGateway Controller and adds it to the
+// Manager with default RBAC. The Manager will set fields on the Controller and
+// start it when the Manager is Started.
+func (c *VpnGatewayController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &VpnGatewayReconciler{
+		vpnGatewayConnecter: &vpnGatewayProviderConnecter{&vpnProviderConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: vpn.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(vpnGatewayControllerName).
+		For(&gcpcomputev1alpha1.VpnGateway{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listVpnGateways)).
+		Complete(r)
+}
+
+// Reconcile HA VPN gateways with the GCP API.
+func (r *VpnGatewayReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	vpnGatewayLog.V(logging.Debug).Info("reconciling", "kind", gcpcomputev1alpha1.VpnGatewayKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), vpnReconcileTimeout)
+	defer cancel()
+
+	g := &gcpcomputev1alpha1.VpnGateway{}
+	if err := r.kube.Get(ctx, req.NamespacedName, g); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get vpn gateway %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, g)
+	if err != nil {
+		g.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, g), "cannot update vpn gateway %s", req.NamespacedName)
+	}
+
+	// The gateway has been deleted from the API server. Delete from GCP.
+	if g.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, g)}, errors.Wrapf(r.kube.Update(ctx, g), "cannot update vpn gateway %s", req.NamespacedName)
+	}
+
+	// The gateway is unnamed. Assume it has not been created in GCP.
+	if g.Status.GatewayName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, g)}, errors.Wrapf(r.kube.Update(ctx, g), "cannot update vpn gateway %s", req.NamespacedName)
+	}
+
+	// The gateway exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, g)}, errors.Wrapf(r.kube.Update(ctx, g), "cannot update vpn gateway %s", req.NamespacedName)
+}
+
+// ExternalVpnGatewayReconciler reconciles ExternalVpnGateways read from the
+// Kubernetes API with an external store, typically the GCP API.
+type ExternalVpnGatewayReconciler struct {
+	externalVpnGatewayConnecter
+	kube client.Client
+}
+
+// ExternalVpnGatewayController is responsible for adding the
+// ExternalVpnGateway controller and its corresponding reconciler to the
+// manager with any runtime configuration.
+type ExternalVpnGatewayController struct {
+	// DefaultProvider is used by gateways that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new ExternalVpnGateway Controller and adds it to
+// the Manager with default RBAC. The Manager will set fields on the
+// Controller and start it when the Manager is Started.
+func (c *ExternalVpnGatewayController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &ExternalVpnGatewayReconciler{
+		externalVpnGatewayConnecter: &externalVpnGatewayProviderConnecter{&vpnProviderConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: vpn.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(externalVpnGatewayControllerName).
+		For(&gcpcomputev1alpha1.ExternalVpnGateway{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listExternalVpnGateways)).
+		Complete(r)
+}
+
+// Reconcile external VPN gateways with the GCP API.
+func (r *ExternalVpnGatewayReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	externalVpnGatewayLog.V(logging.Debug).Info("reconciling", "kind", gcpcomputev1alpha1.ExternalVpnGatewayKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), vpnReconcileTimeout)
+	defer cancel()
+
+	g := &gcpcomputev1alpha1.ExternalVpnGateway{}
+	if err := r.kube.Get(ctx, req.NamespacedName, g); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get external vpn gateway %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, g)
+	if err != nil {
+		g.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, g), "cannot update external vpn gateway %s", req.NamespacedName)
+	}
+
+	// The gateway has been deleted from the API server. Delete from GCP.
+	if g.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, g)}, errors.Wrapf(r.kube.Update(ctx, g), "cannot update external vpn gateway %s", req.NamespacedName)
+	}
+
+	// The gateway is unnamed. Assume it has not been created in GCP.
+	if g.Status.GatewayName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, g)}, errors.Wrapf(r.kube.Update(ctx, g), "cannot update external vpn gateway %s", req.NamespacedName)
+	}
+
+	// The gateway exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, g)}, errors.Wrapf(r.kube.Update(ctx, g), "cannot update external vpn gateway %s", req.NamespacedName)
+}
+
+// VpnTunnelReconciler reconciles VpnTunnels read from the Kubernetes API with
+// an external store, typically the GCP API.
+type VpnTunnelReconciler struct {
+	vpnTunnelConnecter
+	kube client.Client
+}
+
+// VpnTunnelController is responsible for adding the VpnTunnel controller and
+// its corresponding reconciler to the manager with any runtime configuration.
+type VpnTunnelController struct {
+	// DefaultProvider is used by tunnels that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new VpnTunnel Controller and adds it to the
+// Manager with default RBAC. The Manager will set fields on the Controller and
+// start it when the Manager is Started.
+func (c *VpnTunnelController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &VpnTunnelReconciler{
+		vpnTunnelConnecter: &vpnTunnelProviderConnecter{&vpnProviderConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: vpn.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(vpnTunnelControllerName).
+		For(&gcpcomputev1alpha1.VpnTunnel{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listVpnTunnels)).
+		Complete(r)
+}
+
+// Reconcile VPN tunnels with the GCP API.
+func (r *VpnTunnelReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	vpnTunnelLog.V(logging.Debug).Info("reconciling", "kind", gcpcomputev1alpha1.VpnTunnelKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), vpnReconcileTimeout)
+	defer cancel()
+
+	t := &gcpcomputev1alpha1.VpnTunnel{}
+	if err := r.kube.Get(ctx, req.NamespacedName, t); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get vpn tunnel %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, t)
+	if err != nil {
+		t.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, t), "cannot update vpn tunnel %s", req.NamespacedName)
+	}
+
+	// The tunnel has been deleted from the API server. Delete from GCP.
+	if t.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, t)}, errors.Wrapf(r.kube.Update(ctx, t), "cannot update vpn tunnel %s", req.NamespacedName)
+	}
+
+	// The tunnel is unnamed. Assume it has not been created in GCP.
+	if t.Status.TunnelName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, t)}, errors.Wrapf(r.kube.Update(ctx, t), "cannot update vpn tunnel %s", req.NamespacedName)
+	}
+
+	// The tunnel exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, t)}, errors.Wrapf(r.kube.Update(ctx, t), "cannot update vpn tunnel %s", req.NamespacedName)
+}
+
+// listVpnGateways is a provider.Lister of HA VPN gateways.
+func listVpnGateways(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &gcpcomputev1alpha1.VpnGatewayList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
+
+// listExternalVpnGateways is a provider.Lister of external VPN gateways.
+func listExternalVpnGateways(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &gcpcomputev1alpha1.ExternalVpnGatewayList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
+
+// listVpnTunnels is a provider.Lister of VPN tunnels.
+func listVpnTunnels(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &gcpcomputev1alpha1.VpnTunnelList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
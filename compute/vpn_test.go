@@ -0,0 +1,341 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	compute "google.golang.org/api/compute/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	fakevpn "github.com/crossplaneio/crossplane/pkg/clients/gcp/vpn/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	vpnUID             = types.UID("cool-uid")
+	vpnProject         = "cool-project"
+	vpnRegion          = "us-central1"
+	vpnGatewayName     = vpnGatewayNamePrefix + "cool-uid"
+	vpnGatewaySelfLink = "https://www.googleapis.com/compute/v1/projects/cool-project/regions/us-central1/vpnGateways/" + vpnGatewayName
+	vpnPeerSelfLink    = "https://www.googleapis.com/compute/v1/projects/cool-project/global/externalVpnGateways/cool-peer"
+	vpnTunnelName      = vpnTunnelNamePrefix + "cool-uid"
+	vpnRouter          = "cool-router"
+	vpnSharedSecret    = "hunter2"
+)
+
+// Test that our Reconciler implementations satisfy the Reconciler interface.
+var (
+	_ reconcile.Reconciler = &VpnGatewayReconciler{}
+	_ reconcile.Reconciler = &ExternalVpnGatewayReconciler{}
+	_ reconcile.Reconciler = &VpnTunnelReconciler{}
+)
+
+type vpnTunnelModifier func(*gcpcomputev1alpha1.VpnTunnel)
+
+func withTunnelConditions(c ...corev1alpha1.Condition) vpnTunnelModifier {
+	return func(t *gcpcomputev1alpha1.VpnTunnel) { t.Status.SetConditions(c...) }
+}
+
+func withTunnelFinalizers(f ...string) vpnTunnelModifier {
+	return func(t *gcpcomputev1alpha1.VpnTunnel) { t.ObjectMeta.Finalizers = f }
+}
+
+func withTunnelName(n string) vpnTunnelModifier {
+	return func(t *gcpcomputev1alpha1.VpnTunnel) { t.Status.TunnelName = n }
+}
+
+func withTunnelState(s string) vpnTunnelModifier {
+	return func(t *gcpcomputev1alpha1.VpnTunnel) { t.Status.State = s }
+}
+
+func withTunnelPeerGCPGateway(g string) vpnTunnelModifier {
+	return func(t *gcpcomputev1alpha1.VpnTunnel) { t.Spec.PeerGCPGateway = g }
+}
+
+func vpnTunnel(tm ...vpnTunnelModifier) *gcpcomputev1alpha1.VpnTunnel {
+	t := &gcpcomputev1alpha1.VpnTunnel{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "cool-namespace",
+			Name:       "cool-tunnel",
+			UID:        vpnUID,
+			Finalizers: []string{},
+		},
+		Spec: gcpcomputev1alpha1.VpnTunnelSpec{
+			Region:                 vpnRegion,
+			VpnGatewayRef:          &corev1.LocalObjectReference{Name: "cool-gateway"},
+			PeerExternalGatewayRef: &corev1.LocalObjectReference{Name: "cool-peer"},
+			Router:                 vpnRouter,
+			IKEVersion:             2,
+			SharedSecretSecretRef: corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "cool-secret"},
+				Key:                  "secret",
+			},
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: "cool-namespace", Name: "cool-provider"},
+			},
+		},
+	}
+
+	for _, m := range tm {
+		m(t)
+	}
+
+	return t
+}
+
+func TestVpnGatewaySync(t *testing.T) {
+	g := &gcpcomputev1alpha1.VpnGateway{Spec: gcpcomputev1alpha1.VpnGatewaySpec{Region: vpnRegion}}
+	g.Status.GatewayName = vpnGatewayName
+
+	csd := &vpnGateways{project: vpnProject, client: &fakevpn.MockClient{
+		MockGetVpnGateway: func(_ context.Context, _, _, _ string) (*compute.VpnGateway, error) {
+			return &compute.VpnGateway{
+				SelfLink:      vpnGatewaySelfLink,
+				VpnInterfaces: []*compute.VpnGatewayVpnGatewayInterface{{Id: 0, IpAddress: "192.0.2.1"}, {Id: 1, IpAddress: "192.0.2.2"}},
+			}, nil
+		},
+	}}
+
+	if csd.Sync(ctx, g) {
+		t.Errorf("csd.Sync(...): want false, got true")
+	}
+
+	want := &gcpcomputev1alpha1.VpnGateway{Spec: gcpcomputev1alpha1.VpnGatewaySpec{Region: vpnRegion}}
+	want.Status.GatewayName = vpnGatewayName
+	want.Status.SelfLink = vpnGatewaySelfLink
+	want.Status.Interfaces = []gcpcomputev1alpha1.VpnGatewayInterface{{ID: 0, IPAddress: "192.0.2.1"}, {ID: 1, IPAddress: "192.0.2.2"}}
+	want.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+
+	if diff := cmp.Diff(want, g, test.EquateConditions()); diff != "" {
+		t.Errorf("gateway: -want, +got:\n%s", diff)
+	}
+}
+
+func TestValidateExternalVpnGateway(t *testing.T) {
+	cases := map[string]struct {
+		spec gcpcomputev1alpha1.ExternalVpnGatewaySpec
+		want error
+	}{
+		"Valid": {
+			spec: gcpcomputev1alpha1.ExternalVpnGatewaySpec{
+				RedundancyType: "TWO_IPS_REDUNDANCY",
+				Interfaces:     []gcpcomputev1alpha1.ExternalVpnGatewayInterface{{ID: 0, IPAddress: "198.51.100.1"}, {ID: 1, IPAddress: "198.51.100.2"}},
+			},
+		},
+		"UnknownRedundancyType": {
+			spec: gcpcomputev1alpha1.ExternalVpnGatewaySpec{RedundancyType: "LOTS"},
+			want: errors.New(`unknown redundancy type "LOTS"`),
+		},
+		"TooFewInterfaces": {
+			spec: gcpcomputev1alpha1.ExternalVpnGatewaySpec{
+				RedundancyType: "FOUR_IPS_REDUNDANCY",
+				Interfaces:     []gcpcomputev1alpha1.ExternalVpnGatewayInterface{{ID: 0, IPAddress: "198.51.100.1"}},
+			},
+			want: errors.New("redundancy type FOUR_IPS_REDUNDANCY requires 4 interfaces, not 1"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := validateExternalVpnGateway(tc.spec)
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("validateExternalVpnGateway(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestVpnTunnelCreate(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	// get serves available gateways and the shared secret. The peer gateway
+	// is available only if peerReady is true.
+	get := func(peerReady bool) func(context.Context, client.ObjectKey, runtime.Object) error {
+		return func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+			switch o := obj.(type) {
+			case *gcpcomputev1alpha1.VpnGateway:
+				o.Status.SelfLink = vpnGatewaySelfLink
+				o.Status.SetConditions(corev1alpha1.Available())
+			case *gcpcomputev1alpha1.ExternalVpnGateway:
+				o.Status.SelfLink = vpnPeerSelfLink
+				o.Status.SetConditions(corev1alpha1.Creating())
+				if peerReady {
+					o.Status.SetConditions(corev1alpha1.Available())
+				}
+			case *corev1.Secret:
+				o.Data = map[string][]byte{"secret": []byte(vpnSharedSecret)}
+			}
+			return nil
+		}
+	}
+
+	cases := []struct {
+		name        string
+		csd         vpnTunnelCreateSyncDeleter
+		t           *gcpcomputev1alpha1.VpnTunnel
+		want        *gcpcomputev1alpha1.VpnTunnel
+		wantRequeue bool
+	}{
+		{
+			name: "SuccessfulCreate",
+			csd: &vpnTunnels{
+				project: vpnProject,
+				kube:    &test.MockClient{MockGet: get(true)},
+				client: &fakevpn.MockClient{
+					MockInsertVpnTunnel: func(_ context.Context, _, region string, tunnel *compute.VpnTunnel) error {
+						want := &compute.VpnTunnel{
+							Name:                vpnTunnelName,
+							VpnGateway:          vpnGatewaySelfLink,
+							PeerExternalGateway: vpnPeerSelfLink,
+							Router:              vpnRouter,
+							IkeVersion:          2,
+							SharedSecret:        vpnSharedSecret,
+							ForceSendFields:     []string{"VpnGatewayInterface", "PeerExternalGatewayInterface"},
+						}
+						if region != vpnRegion {
+							t.Errorf("region: want %s, got %s", vpnRegion, region)
+						}
+						if diff := cmp.Diff(want, tunnel); diff != "" {
+							t.Errorf("tunnel: -want, +got:\n%s", diff)
+						}
+						return nil
+					},
+				},
+			},
+			t: vpnTunnel(),
+			want: vpnTunnel(
+				withTunnelFinalizers(vpnTunnelFinalizer),
+				withTunnelName(vpnTunnelName),
+				withTunnelConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "PeerGatewayNotReady",
+			csd:  &vpnTunnels{project: vpnProject, kube: &test.MockClient{MockGet: get(false)}},
+			t:    vpnTunnel(),
+			want: vpnTunnel(
+				withTunnelConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.New("external vpn gateway cool-namespace/cool-peer is not yet available"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "AmbiguousPeer",
+			csd:  &vpnTunnels{project: vpnProject},
+			t:    vpnTunnel(withTunnelPeerGCPGateway("cool-gcp-gateway")),
+			want: vpnTunnel(
+				withTunnelPeerGCPGateway("cool-gcp-gateway"),
+				withTunnelConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.New("exactly one of peerExternalGateway, peerExternalGatewayRef, or peerGcpGateway must be set"))),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedInsert",
+			csd: &vpnTunnels{
+				project: vpnProject,
+				kube:    &test.MockClient{MockGet: get(true)},
+				client: &fakevpn.MockClient{
+					MockInsertVpnTunnel: func(_ context.Context, _, _ string, _ *compute.VpnTunnel) error { return errBoom },
+				},
+			},
+			t: vpnTunnel(),
+			want: vpnTunnel(
+				withTunnelFinalizers(vpnTunnelFinalizer),
+				withTunnelConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errBoom, "cannot insert vpn tunnel"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.t)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.t, test.EquateConditions()); diff != "" {
+				t.Errorf("tunnel: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestVpnTunnelSync(t *testing.T) {
+	withStatus := func(s string) func(context.Context, string, string, string) (*compute.VpnTunnel, error) {
+		return func(_ context.Context, _, _, _ string) (*compute.VpnTunnel, error) {
+			return &compute.VpnTunnel{Status: s}, nil
+		}
+	}
+
+	cases := []struct {
+		name        string
+		csd         vpnTunnelCreateSyncDeleter
+		t           *gcpcomputev1alpha1.VpnTunnel
+		want        *gcpcomputev1alpha1.VpnTunnel
+		wantRequeue bool
+	}{
+		{
+			name: "Established",
+			csd:  &vpnTunnels{project: vpnProject, client: &fakevpn.MockClient{MockGetVpnTunnel: withStatus(tunnelStatusEstablished)}},
+			t:    vpnTunnel(withTunnelName(vpnTunnelName)),
+			want: vpnTunnel(
+				withTunnelName(vpnTunnelName),
+				withTunnelState(tunnelStatusEstablished),
+				withTunnelConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "WaitingForPeer",
+			csd:  &vpnTunnels{project: vpnProject, client: &fakevpn.MockClient{MockGetVpnTunnel: withStatus("WAITING_FOR_FULL_CONFIG")}},
+			t:    vpnTunnel(withTunnelName(vpnTunnelName)),
+			want: vpnTunnel(
+				withTunnelName(vpnTunnelName),
+				withTunnelState("WAITING_FOR_FULL_CONFIG"),
+				withTunnelConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.t)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.t, test.EquateConditions()); diff != "" {
+				t.Errorf("tunnel: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
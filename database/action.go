@@ -0,0 +1,183 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/plan"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/tracing"
+)
+
+// AnnotationOperation may be set on a CloudsqlInstance to trigger a one-off
+// action, so that routine operations don't require gcloud access. The
+// controller removes the annotation when it triggers the action, and records
+// the running action in the instance's status until it completes.
+const AnnotationOperation = "cloudsql.gcp.crossplane.io/operation"
+
+// The actions that may be triggered by AnnotationOperation.
+const (
+	// ActionRestart restarts the instance.
+	ActionRestart = "restart"
+
+	// ActionFailover fails a highly available instance over to its standby.
+	ActionFailover = "failover"
+)
+
+// Reasons of the events recorded as actions run.
+const (
+	EventReasonActionStarted   = "ActionStarted"
+	EventReasonActionSucceeded = "ActionSucceeded"
+	EventReasonActionFailed    = "ActionFailed"
+)
+
+// availabilityRegional is the availability type of highly available
+// instances, which have a standby to fail over to.
+const availabilityRegional = "REGIONAL"
+
+// actionCalls are the Cloud SQL API calls made by each action.
+var actionCalls = map[string]string{
+	ActionRestart:  "RestartInstance",
+	ActionFailover: "FailoverInstance",
+}
+
+// validateAction returns an error if the supplied action is unknown, or cannot
+// be run on the supplied instance.
+func validateAction(action string, inst *sqladmin.DatabaseInstance) error {
+	switch action {
+	case ActionRestart:
+		return nil
+	case ActionFailover:
+		if inst.Settings == nil || inst.Settings.AvailabilityType != availabilityRegional {
+			return errors.Errorf("cannot fail over instance %s: only highly available (%s) instances have a standby", inst.Name, availabilityRegional)
+		}
+		return nil
+	default:
+		return errors.Errorf("unknown %s %q; use %s or %s", AnnotationOperation, action, ActionRestart, ActionFailover)
+	}
+}
+
+// runAction triggers the action requested by the instance's operation
+// annotation, if any, and observes it until it completes. It returns true
+// while an action is running, during which the instance should not be
+// updated.
+func (h *managedHandler) runAction(ctx context.Context, inst *sqladmin.DatabaseInstance) (running bool, err error) {
+	if h.Status.ActionOperation != "" {
+		return h.observeAction(ctx)
+	}
+
+	action, ok := h.GetAnnotations()[AnnotationOperation]
+	if !ok {
+		return false, nil
+	}
+
+	// Remove the annotation before triggering the action, so that it is
+	// triggered at most once even if we fail to record it. Restarting or
+	// failing over twice is worse than not doing so at all; the action may
+	// be requested again.
+	annotations := h.GetAnnotations()
+	delete(annotations, AnnotationOperation)
+	h.SetAnnotations(annotations)
+	if err := h.updateObject(ctx); err != nil {
+		return false, errors.Wrapf(err, "cannot remove annotation %s", AnnotationOperation)
+	}
+
+	if err := validateAction(action, inst); err != nil {
+		h.recordEvent(corev1.EventTypeWarning, EventReasonActionFailed, err.Error())
+		return false, err
+	}
+
+	if h.isDryRun() {
+		desc := plan.Describe(actionCalls[action], inst.Name)
+		h.Status.SetConditions(plan.Planned(desc))
+		h.recordEvent(corev1.EventTypeNormal, plan.EventReasonPlanned, desc)
+		return false, nil
+	}
+
+	op, err := h.startAction(ctx, action, inst)
+	if err != nil {
+		err = errors.Wrapf(err, "cannot %s instance %s", action, inst.Name)
+		h.recordEvent(corev1.EventTypeWarning, EventReasonActionFailed, err.Error())
+		return false, err
+	}
+
+	h.Status.Action = action
+	h.Status.ActionOperation = op.Name
+	h.recordEvent(corev1.EventTypeNormal, EventReasonActionStarted, fmt.Sprintf("started %s of instance %s", action, inst.Name))
+	return true, nil
+}
+
+// startAction calls the Cloud SQL API to start the supplied action, returning
+// the operation running it.
+func (h *managedHandler) startAction(ctx context.Context, action string, inst *sqladmin.DatabaseInstance) (op *sqladmin.Operation, err error) {
+	ctx, span := tracing.StartPhase(ctx, tracing.PhaseUpdate)
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := h.withCallTimeout(ctx)
+	defer cancel()
+
+	if action == ActionFailover {
+		// The settings version guards against failing over an instance whose
+		// settings changed since we read it.
+		return h.instance.Failover(ctx, inst.Name, &sqladmin.InstancesFailoverRequest{
+			FailoverContext: &sqladmin.FailoverContext{SettingsVersion: inst.Settings.SettingsVersion},
+		})
+	}
+	return h.instance.Restart(ctx, inst.Name)
+}
+
+// observeAction returns true while the instance's running action has not
+// completed. Once it completes the action is forgotten, and its result
+// recorded as an event.
+func (h *managedHandler) observeAction(ctx context.Context) (running bool, err error) {
+	cctx, cancel := h.withCallTimeout(ctx)
+	defer cancel()
+	op, err := h.instance.GetOperation(cctx, h.Status.ActionOperation)
+	if err != nil {
+		return true, errors.Wrapf(err, "cannot get %s operation %s", h.Status.Action, h.Status.ActionOperation)
+	}
+	if op, err = h.waitForOperation(cctx, op); err != nil {
+		return true, errors.Wrapf(err, "cannot wait for %s operation", h.Status.Action)
+	}
+	if op.Status != operationDone {
+		return true, nil
+	}
+
+	action := h.Status.Action
+	h.Status.Action, h.Status.ActionOperation = "", ""
+	if err := operationError(op); err != nil {
+		err = errors.Wrapf(err, "%s failed", action)
+		h.recordEvent(corev1.EventTypeWarning, EventReasonActionFailed, err.Error())
+		return false, err
+	}
+	h.recordEvent(corev1.EventTypeNormal, EventReasonActionSucceeded, fmt.Sprintf("completed %s of instance %s", action, instanceName(h.CloudsqlInstance)))
+	return false, nil
+}
+
+// recordEvent records an event about the instance, if the handler has a
+// recorder.
+func (h *managedHandler) recordEvent(eventtype, reason, message string) {
+	if h.recorder == nil {
+		return
+	}
+	h.recorder.Event(h.CloudsqlInstance, eventtype, reason, message)
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/cloudsql"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/cloudsql/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func Test_managedHandler_runAction(t *testing.T) {
+	zonal := &sqladmin.DatabaseInstance{Name: "test-instance", Settings: &sqladmin.Settings{AvailabilityType: "ZONAL", SettingsVersion: 7}}
+	regional := &sqladmin.DatabaseInstance{Name: "test-instance", Settings: &sqladmin.Settings{AvailabilityType: availabilityRegional, SettingsVersion: 7}}
+
+	type want struct {
+		running           bool
+		err               error
+		action            string
+		operation         string
+		annotationRemoved bool
+		events            int
+	}
+	tests := map[string]struct {
+		annotation string
+		action     string
+		operation  string
+		dryRun     bool
+		inst       *sqladmin.DatabaseInstance
+		instance   cloudsql.InstanceService
+		want       want
+	}{
+		"NotRequested": {
+			inst: zonal,
+		},
+		"Restart": {
+			annotation: ActionRestart,
+			inst:       zonal,
+			instance: &fake.MockInstanceClient{
+				MockRestart: func(ctx context.Context, name string) (*sqladmin.Operation, error) {
+					return &sqladmin.Operation{Name: "restart-operation"}, nil
+				},
+			},
+			want: want{running: true, action: ActionRestart, operation: "restart-operation", annotationRemoved: true, events: 1},
+		},
+		"Failover": {
+			annotation: ActionFailover,
+			inst:       regional,
+			instance: &fake.MockInstanceClient{
+				MockFailover: func(ctx context.Context, name string, req *sqladmin.InstancesFailoverRequest) (*sqladmin.Operation, error) {
+					if req.FailoverContext.SettingsVersion != 7 {
+						t.Errorf("runAction() settings version: want 7, got %d", req.FailoverContext.SettingsVersion)
+					}
+					return &sqladmin.Operation{Name: "failover-operation"}, nil
+				},
+			},
+			want: want{running: true, action: ActionFailover, operation: "failover-operation", annotationRemoved: true, events: 1},
+		},
+		"FailoverZonal": {
+			annotation: ActionFailover,
+			inst:       zonal,
+			want: want{
+				err:               errors.New("cannot fail over instance test-instance: only highly available (REGIONAL) instances have a standby"),
+				annotationRemoved: true,
+				events:            1,
+			},
+		},
+		"Unknown": {
+			annotation: "reboot",
+			inst:       zonal,
+			want: want{
+				err:               errors.Errorf(`unknown %s "reboot"; use restart or failover`, AnnotationOperation),
+				annotationRemoved: true,
+				events:            1,
+			},
+		},
+		"DryRun": {
+			annotation: ActionRestart,
+			dryRun:     true,
+			inst:       zonal,
+			want:       want{annotationRemoved: true, events: 1},
+		},
+		"RestartFailed": {
+			annotation: ActionRestart,
+			inst:       zonal,
+			instance: &fake.MockInstanceClient{
+				MockRestart: func(ctx context.Context, name string) (*sqladmin.Operation, error) { return nil, errTest },
+			},
+			want: want{
+				err:               errors.Wrap(errTest, "cannot restart instance test-instance"),
+				annotationRemoved: true,
+				events:            1,
+			},
+		},
+		"Running": {
+			action:    ActionRestart,
+			operation: "restart-operation",
+			inst:      zonal,
+			instance: &fake.MockInstanceClient{
+				MockGetOperation: func(ctx context.Context, name string) (*sqladmin.Operation, error) {
+					return &sqladmin.Operation{Name: name, Status: "RUNNING"}, nil
+				},
+			},
+			want: want{running: true, action: ActionRestart, operation: "restart-operation"},
+		},
+		"Completed": {
+			action:    ActionRestart,
+			operation: "restart-operation",
+			inst:      zonal,
+			instance: &fake.MockInstanceClient{
+				MockGetOperation: func(ctx context.Context, name string) (*sqladmin.Operation, error) {
+					return &sqladmin.Operation{Name: name, Status: operationDone}, nil
+				},
+			},
+			want: want{events: 1},
+		},
+		"ActionFailed": {
+			action:    ActionFailover,
+			operation: "failover-operation",
+			inst:      regional,
+			instance: &fake.MockInstanceClient{
+				MockGetOperation: func(ctx context.Context, name string) (*sqladmin.Operation, error) {
+					return &sqladmin.Operation{Name: name, Status: operationDone, Error: &sqladmin.OperationErrors{
+						Errors: []*sqladmin.OperationError{{Message: "boom"}},
+					}}, nil
+				},
+			},
+			want: want{
+				err:    errors.Wrap(errors.New("operation failover-operation failed: boom"), "failover failed"),
+				events: 1,
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			obj := &v1alpha1.CloudsqlInstance{ObjectMeta: *testMeta.DeepCopy()}
+			if tt.annotation != "" {
+				obj.SetAnnotations(map[string]string{AnnotationOperation: tt.annotation})
+			}
+			obj.Status.Action = tt.action
+			obj.Status.ActionOperation = tt.operation
+
+			updated := false
+			r := record.NewFakeRecorder(10)
+			ih := &managedHandler{
+				CloudsqlInstance: obj,
+				localOperations: &mockLocalOperations{
+					mockUpdateObject: func(context.Context) error {
+						if _, ok := obj.GetAnnotations()[AnnotationOperation]; ok {
+							t.Errorf("runAction() updated object without removing annotation %s", AnnotationOperation)
+						}
+						updated = true
+						return nil
+					},
+					mockIsDryRun: func() bool { return tt.dryRun },
+				},
+				instance: tt.instance,
+				recorder: r,
+			}
+
+			running, err := ih.runAction(context.Background(), tt.inst)
+			if diff := cmp.Diff(tt.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("runAction() error -want, +got: %s", diff)
+			}
+			if running != tt.want.running {
+				t.Errorf("runAction() running: want %t, got %t", tt.want.running, running)
+			}
+			if diff := cmp.Diff(tt.want.action, obj.Status.Action); diff != "" {
+				t.Errorf("runAction() action -want, +got: %s", diff)
+			}
+			if diff := cmp.Diff(tt.want.operation, obj.Status.ActionOperation); diff != "" {
+				t.Errorf("runAction() operation -want, +got: %s", diff)
+			}
+			if updated != tt.want.annotationRemoved {
+				t.Errorf("runAction() annotation removed: want %t, got %t", tt.want.annotationRemoved, updated)
+			}
+			if got := len(r.Events); got != tt.want.events {
+				t.Errorf("runAction() events: want %d, got %d", tt.want.events, got)
+			}
+		})
+	}
+}
@@ -17,33 +17,104 @@ limitations under the License.
 package database
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
 	databasev1alpha1 "github.com/crossplaneio/crossplane/apis/database/v1alpha1"
 	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	servicenetworkingv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/servicenetworking/v1alpha1"
+	storagev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/storage/v1alpha1"
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/faultinject"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/httpreplay"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/refindex"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/shard"
 	"github.com/crossplaneio/crossplane/pkg/resource"
 )
 
 // CloudsqlController is responsible for adding the Cloudsql
 // controller and its corresponding reconciler to the manager with any runtime configuration.
-type CloudsqlController struct{}
+type CloudsqlController struct {
+	// DefaultProvider is used by instances that don't reference a provider.
+	DefaultProvider types.NamespacedName
+
+	// ReconcileTimeout bounds each reconcile of a CloudsqlInstance.
+	// DefaultReconcileTimeout is used if it is zero.
+	ReconcileTimeout time.Duration
+
+	// APICallTimeout bounds each Cloud SQL API call. DefaultAPICallTimeout is
+	// used if it is zero.
+	APICallTimeout time.Duration
+
+	// TraceAPICalls records a span for each Cloud SQL API call. Spans are
+	// only exported if tracing is set up.
+	TraceAPICalls bool
+
+	// Faults are injected into Cloud SQL API calls, if not nil, in order to
+	// test how the controller handles failing calls. It must not be set in
+	// production.
+	Faults *faultinject.Injector
+
+	// Replay records Cloud SQL API calls to, or replays them from, a fixture
+	// file, if not nil. It must not be set in production.
+	Replay *httpreplay.Recorder
+
+	// Pooler configures the connection poolers instances may request.
+	Pooler PoolerConfig
+}
 
 // SetupWithManager creates a Controller that reconciles CloudsqlInstance resources.
 func (c *CloudsqlController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+
+	// Cancel any in-flight reconciles when the manager stops.
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		<-stop
+		cancel()
+		return nil
+	})); err != nil {
+		cancel()
+		return err
+	}
+
 	r := &Reconciler{
 		client:  mgr.GetClient(),
-		factory: &operationsFactory{mgr.GetClient()},
+		ctx:     ctx,
+		timeout: c.ReconcileTimeout,
+		factory: &operationsFactory{
+			Client:        mgr.GetClient(),
+			recorder:      mgr.GetEventRecorderFor(controllerName),
+			providers:     providers,
+			callTimeout:   c.APICallTimeout,
+			traceAPICalls: c.TraceAPICalls,
+			faults:        c.Faults,
+			replay:        c.Replay,
+			services:      provider.NewServiceEnabler(mgr.GetClient()),
+			instances:     newInstanceCache(instanceCacheTTL),
+			pooler:        c.Pooler,
+		},
 	}
 
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(controllerName).
 		For(&v1alpha1.CloudsqlInstance{}).
+		Watches(&source.Kind{Type: &core.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listCloudsqlInstances)).
+		Watches(&source.Kind{Type: &gcpv1alpha1.Provider{}}, refindex.EnqueueRequestsForProvider(mgr.GetClient(), c.DefaultProvider, newCloudsqlInstanceList)).
+		Watches(&source.Kind{Type: &servicenetworkingv1alpha1.Connection{}}, refindex.EnqueueRequestsForDependents(mgr.GetClient(), servicenetworkingv1alpha1.ConnectionGroupVersionKind, newCloudsqlInstanceList)).
+		Watches(&source.Kind{Type: &storagev1alpha1.Bucket{}}, refindex.EnqueueRequestsForDependents(mgr.GetClient(), storagev1alpha1.BucketGroupVersionKind, newCloudsqlInstanceList)).
 		Owns(&core.Secret{}).
 		Complete(r)
 }
@@ -67,11 +138,19 @@ func (c *PostgreSQLInstanceClaimController) SetupWithManager(mgr ctrl.Manager) e
 
 	name := strings.ToLower(fmt.Sprintf("%s.%s", databasev1alpha1.PostgreSQLInstanceKind, controllerName))
 
+	if err := setupClassScheduler(mgr, name, func() resource.Claim { return &databasev1alpha1.PostgreSQLInstance{} }); err != nil {
+		return err
+	}
+
+	if err := setupClaimPropagator(mgr, name, func() resource.Claim { return &databasev1alpha1.PostgreSQLInstance{} }); err != nil {
+		return err
+	}
+
 	p := v1alpha1.CloudsqlInstanceKindAPIVersion
 
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(name).
-		Watches(&source.Kind{Type: &v1alpha1.CloudsqlInstance{}}, &resource.EnqueueRequestForClaim{}).
+		Watches(&source.Kind{Type: &v1alpha1.CloudsqlInstance{}}, shard.FilterRequests(mgr.GetClient(), func() runtime.Object { return &databasev1alpha1.PostgreSQLInstance{} }, &resource.EnqueueRequestForClaim{})).
 		For(&databasev1alpha1.PostgreSQLInstance{}).
 		WithEventFilter(resource.NewPredicates(resource.ObjectHasProvisioner(mgr.GetClient(), p))).
 		Complete(r)
@@ -96,12 +175,77 @@ func (c *MySQLInstanceClaimController) SetupWithManager(mgr ctrl.Manager) error
 
 	name := strings.ToLower(fmt.Sprintf("%s.%s", databasev1alpha1.MySQLInstanceKind, controllerName))
 
+	if err := setupClassScheduler(mgr, name, func() resource.Claim { return &databasev1alpha1.MySQLInstance{} }); err != nil {
+		return err
+	}
+
+	if err := setupClaimPropagator(mgr, name, func() resource.Claim { return &databasev1alpha1.MySQLInstance{} }); err != nil {
+		return err
+	}
+
 	p := v1alpha1.CloudsqlInstanceKindAPIVersion
 
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(name).
-		Watches(&source.Kind{Type: &v1alpha1.CloudsqlInstance{}}, &resource.EnqueueRequestForClaim{}).
+		Watches(&source.Kind{Type: &v1alpha1.CloudsqlInstance{}}, shard.FilterRequests(mgr.GetClient(), func() runtime.Object { return &databasev1alpha1.MySQLInstance{} }, &resource.EnqueueRequestForClaim{})).
 		For(&databasev1alpha1.MySQLInstance{}).
 		WithEventFilter(resource.NewPredicates(resource.ObjectHasProvisioner(mgr.GetClient(), p))).
 		Complete(r)
 }
+
+// SQLServerInstanceClaimController is responsible for adding the
+// SQLServerInstance claim controller and its corresponding reconciler to the
+// manager with any runtime configuration.
+type SQLServerInstanceClaimController struct{}
+
+// SetupWithManager adds a controller that reconciles SQLServerInstance instance claims.
+func (c *SQLServerInstanceClaimController) SetupWithManager(mgr ctrl.Manager) error {
+	r := resource.NewClaimReconciler(mgr,
+		resource.ClaimKind(databasev1alpha1.SQLServerInstanceGroupVersionKind),
+		resource.ClassKind(corev1alpha1.ResourceClassGroupVersionKind),
+		resource.ManagedKind(v1alpha1.CloudsqlInstanceGroupVersionKind),
+		resource.WithManagedBinder(resource.NewAPIManagedStatusBinder(mgr.GetClient())),
+		resource.WithManagedFinalizer(resource.NewAPIManagedStatusUnbinder(mgr.GetClient())),
+		resource.WithManagedConfigurators(
+			resource.ManagedConfiguratorFn(ConfigureSQLServerCloudsqlInstance),
+			resource.NewObjectMetaConfigurator(mgr.GetScheme()),
+		))
+
+	name := strings.ToLower(fmt.Sprintf("%s.%s", databasev1alpha1.SQLServerInstanceKind, controllerName))
+
+	if err := setupClassScheduler(mgr, name, func() resource.Claim { return &databasev1alpha1.SQLServerInstance{} }); err != nil {
+		return err
+	}
+
+	if err := setupClaimPropagator(mgr, name, func() resource.Claim { return &databasev1alpha1.SQLServerInstance{} }); err != nil {
+		return err
+	}
+
+	p := v1alpha1.CloudsqlInstanceKindAPIVersion
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		Watches(&source.Kind{Type: &v1alpha1.CloudsqlInstance{}}, shard.FilterRequests(mgr.GetClient(), func() runtime.Object { return &databasev1alpha1.SQLServerInstance{} }, &resource.EnqueueRequestForClaim{})).
+		For(&databasev1alpha1.SQLServerInstance{}).
+		WithEventFilter(resource.NewPredicates(resource.ObjectHasProvisioner(mgr.GetClient(), p))).
+		Complete(r)
+}
+
+// listCloudsqlInstances is a provider.Lister of CloudSQL instances.
+func listCloudsqlInstances(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.CloudsqlInstanceList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].GetProviderReference()})
+	}
+	return refs, nil
+}
+
+// newCloudsqlInstanceList returns an empty list of CloudsqlInstances, into
+// which the dependents of a resource are read from the reference index.
+func newCloudsqlInstanceList() runtime.Object {
+	return &v1alpha1.CloudsqlInstanceList{}
+}
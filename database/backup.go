@@ -0,0 +1,235 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	storagev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/storage/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/tracing"
+	utilgoogleapi "github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	// exportFileTypeSQL and exportFileTypeBAK are the export file types of
+	// MySQL and PostgreSQL, and of SQL Server, respectively.
+	exportFileTypeSQL = "SQL"
+	exportFileTypeBAK = "BAK"
+)
+
+// systemDatabases are the databases of PostgreSQL and SQL Server instances
+// that hold no application data, and so are not exported.
+var systemDatabases = map[string]bool{
+	"cloudsqladmin": true,
+	"template0":     true,
+	"template1":     true,
+	"master":        true,
+	"model":         true,
+	"msdb":          true,
+	"tempdb":        true,
+}
+
+// A databaseLister lists the databases of a Cloud SQL instance.
+type databaseLister interface {
+	list(ctx context.Context, instance string) ([]string, error)
+}
+
+// A sqladminDatabases lists the databases of Cloud SQL instances in a
+// project using the Cloud SQL Admin API.
+type sqladminDatabases struct {
+	service *sqladmin.Service
+	project string
+}
+
+func (d *sqladminDatabases) list(ctx context.Context, instance string) ([]string, error) {
+	rsp, err := d.service.Databases.List(d.project, instance).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(rsp.Items))
+	for _, db := range rsp.Items {
+		names = append(names, db.Name)
+	}
+	return names, nil
+}
+
+// errNoFinalBackupBucket is returned when a final backup is asked for without
+// a bucket to export it to. On-demand backups are deleted along with their
+// instance, so they can't serve as a final backup.
+var errNoFinalBackupBucket = errors.New("finalBackupOnDelete requires a finalBackupBucketRef; on-demand backups are deleted with the instance")
+
+// finalBackupURI returns the Cloud Storage URI to which the supplied database
+// of the instance should be exported by its final backup. An empty database
+// names an export of the whole instance.
+func (h *localHandler) finalBackupURI(ctx context.Context, database string) (string, error) {
+	if h.Spec.FinalBackupBucketRef == nil {
+		return "", errNoFinalBackupBucket
+	}
+	b := &storagev1alpha1.Bucket{}
+	n := types.NamespacedName{Namespace: h.GetNamespace(), Name: h.Spec.FinalBackupBucketRef.Name}
+	if err := h.client.Get(ctx, n, b); err != nil {
+		return "", errors.Wrapf(err, "cannot get final backup bucket %s", n)
+	}
+	object := instanceName(h.CloudsqlInstance) + "-final"
+	if database != "" {
+		object += "-" + database
+	}
+	return fmt.Sprintf("gs://%s/%s.%s", b.GetBucketName(), object, exportContext("", h.Spec.DatabaseVersion, database).FileType), nil
+}
+
+// exportsPerDatabase returns true if instances of the supplied database
+// version must be exported one database at a time. MySQL instances export
+// all of their databases at once.
+func exportsPerDatabase(version string) bool {
+	return isEngine(version, sqlServerDBVersionPrefix) || isEngine(version, v1alpha1.PostgresqlDBVersionPrefix)
+}
+
+// finalBackup backs up the instance before it is deleted, if its spec asks
+// for a final backup. It returns true once the backup is complete. PostgreSQL
+// and SQL Server databases are exported one at a time, recording each database
+// as its export starts. A failed backup is reported as an error and retried,
+// blocking deletion until it succeeds or the final backup is no longer asked
+// for.
+func (h *managedHandler) finalBackup(ctx context.Context) (done bool, err error) {
+	if !h.Spec.FinalBackupOnDelete {
+		return true, nil
+	}
+
+	if h.Status.FinalBackupOperation == "" {
+		return h.startFinalBackup(ctx)
+	}
+
+	cctx, cancel := h.withCallTimeout(ctx)
+	defer cancel()
+	op, err := h.instance.GetOperation(cctx, h.Status.FinalBackupOperation)
+	if err != nil {
+		return false, errors.Wrapf(err, "cannot get final backup operation %s", h.Status.FinalBackupOperation)
+	}
+	if op, err = h.waitForOperation(cctx, op); err != nil {
+		return false, errors.Wrap(err, "cannot wait for final backup operation")
+	}
+	if op.Status != operationDone {
+		return false, nil
+	}
+	if err := operationError(op); err != nil {
+		// Forget the failed operation so that the backup is retried.
+		h.Status.FinalBackupOperation = ""
+		if n := len(h.Status.FinalBackupDatabases); n > 0 {
+			h.Status.FinalBackupDatabases = h.Status.FinalBackupDatabases[:n-1]
+		}
+		return false, errors.Wrap(err, "final backup failed")
+	}
+	if !exportsPerDatabase(h.Spec.DatabaseVersion) {
+		return true, nil
+	}
+	return h.startFinalBackup(ctx)
+}
+
+// startFinalBackup exports the instance, or its next database that has not
+// yet been exported, to its final backup bucket, and records the operation
+// doing so. It returns true if there is nothing left to back up.
+func (h *managedHandler) startFinalBackup(ctx context.Context) (done bool, err error) {
+	ctx, span := tracing.StartPhase(ctx, tracing.PhaseDelete)
+	defer func() { tracing.End(span, err) }()
+
+	// The instance may be deleted before it was synced with a final backup
+	// bucket, so make sure it may export to that bucket.
+	if err := h.grantServiceAccountAccess(ctx); err != nil {
+		return false, err
+	}
+
+	database := ""
+	if exportsPerDatabase(h.Spec.DatabaseVersion) {
+		database, err = h.nextFinalBackupDatabase(ctx)
+		if utilgoogleapi.IsErrorNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, errors.Wrap(err, "cannot list databases to back up")
+		}
+		if database == "" {
+			return true, nil
+		}
+	}
+
+	uri, err := h.finalBackupURI(ctx, database)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := h.withCallTimeout(ctx)
+	defer cancel()
+
+	op, err := h.instance.Export(ctx, instanceName(h.CloudsqlInstance), &sqladmin.InstancesExportRequest{
+		ExportContext: exportContext(uri, h.Spec.DatabaseVersion, database),
+	})
+	if utilgoogleapi.IsErrorNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "cannot start final backup")
+	}
+	h.Status.FinalBackupOperation = op.Name
+	if database != "" {
+		h.Status.FinalBackupDatabases = append(h.Status.FinalBackupDatabases, database)
+	}
+	return false, nil
+}
+
+// nextFinalBackupDatabase returns the first of the instance's databases that
+// holds application data and whose export has not yet started, or an empty
+// string if there is no such database.
+func (h *managedHandler) nextFinalBackupDatabase(ctx context.Context) (string, error) {
+	ctx, cancel := h.withCallTimeout(ctx)
+	defer cancel()
+	names, err := h.databases.list(ctx, instanceName(h.CloudsqlInstance))
+	if err != nil {
+		return "", err
+	}
+	started := map[string]bool{}
+	for _, db := range h.Status.FinalBackupDatabases {
+		started[db] = true
+	}
+	sort.Strings(names)
+	for _, db := range names {
+		if !systemDatabases[db] && !started[db] {
+			return db, nil
+		}
+	}
+	return "", nil
+}
+
+// exportContext returns the context of an export of the supplied database of
+// an instance of the supplied database version to the supplied Cloud Storage
+// URI. An empty database exports all of a MySQL instance's databases.
+func exportContext(uri, version, database string) *sqladmin.ExportContext {
+	ec := &sqladmin.ExportContext{Uri: uri, FileType: exportFileTypeSQL}
+	if isEngine(version, sqlServerDBVersionPrefix) {
+		ec.FileType = exportFileTypeBAK
+	}
+	if database != "" {
+		ec.Databases = []string{database}
+	}
+	return ec
+}
@@ -0,0 +1,285 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/cloudsql"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/cloudsql/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+type mockDatabaseLister struct {
+	mockList func(ctx context.Context, instance string) ([]string, error)
+}
+
+func (m *mockDatabaseLister) list(ctx context.Context, instance string) ([]string, error) {
+	return m.mockList(ctx, instance)
+}
+
+func listDatabases(names ...string) databaseLister {
+	return &mockDatabaseLister{mockList: func(context.Context, string) ([]string, error) { return names, nil }}
+}
+
+// exportDatabases returns an export that fails the test unless it exports
+// the supplied databases.
+func exportDatabases(t *testing.T, want ...string) *fake.MockInstanceClient {
+	return &fake.MockInstanceClient{
+		MockExport: func(ctx context.Context, name string, req *sqladmin.InstancesExportRequest) (*sqladmin.Operation, error) {
+			if diff := cmp.Diff(want, req.ExportContext.Databases); diff != "" {
+				t.Errorf("finalBackup() databases -want, +got: %s", diff)
+			}
+			return &sqladmin.Operation{Name: "export-operation"}, nil
+		},
+		MockGetOperation: func(ctx context.Context, name string) (*sqladmin.Operation, error) {
+			return &sqladmin.Operation{Name: name, Status: operationDone}, nil
+		},
+	}
+}
+
+func Test_managedHandler_finalBackup(t *testing.T) {
+	exportURI := "gs://test-bucket/" + getExpectedInstanceName(testUID) + "-final.SQL"
+
+	type want struct {
+		done      bool
+		err       error
+		operation string
+		databases []string
+	}
+	tests := map[string]struct {
+		finalBackup bool
+		version     string
+		operation   string
+		exported    []string
+		uri         string
+		uriErr      error
+		instance    cloudsql.InstanceService
+		databases   databaseLister
+		want        want
+	}{
+		"NotRequested": {
+			want: want{done: true},
+		},
+		"NoBucket": {
+			finalBackup: true,
+			uriErr:      errNoFinalBackupBucket,
+			want:        want{err: errNoFinalBackupBucket},
+		},
+		"StartExport": {
+			finalBackup: true,
+			uri:         exportURI,
+			instance: &fake.MockInstanceClient{
+				MockExport: func(ctx context.Context, name string, req *sqladmin.InstancesExportRequest) (*sqladmin.Operation, error) {
+					if diff := cmp.Diff(getExpectedInstanceName(testUID), name); diff != "" {
+						t.Errorf("finalBackup() instance name -want, +got: %s", diff)
+					}
+					if diff := cmp.Diff(exportURI, req.ExportContext.Uri); diff != "" {
+						t.Errorf("finalBackup() export URI -want, +got: %s", diff)
+					}
+					if req.ExportContext.Databases != nil {
+						t.Errorf("finalBackup() databases: want all, got %v", req.ExportContext.Databases)
+					}
+					return &sqladmin.Operation{Name: "export-operation"}, nil
+				},
+			},
+			want: want{operation: "export-operation"},
+		},
+		"StartPostgresExport": {
+			finalBackup: true,
+			version:     "POSTGRES_14",
+			uri:         exportURI,
+			instance:    exportDatabases(t, "orders"),
+			databases:   listDatabases("postgres", "orders", "cloudsqladmin", "template1"),
+			want:        want{operation: "export-operation", databases: []string{"orders"}},
+		},
+		"StartSQLServerExport": {
+			finalBackup: true,
+			version:     "SQLSERVER_2019_STANDARD",
+			uri:         exportURI,
+			instance:    exportDatabases(t, "inventory"),
+			databases:   listDatabases("master", "model", "msdb", "tempdb", "inventory"),
+			want:        want{operation: "export-operation", databases: []string{"inventory"}},
+		},
+		"ExportNextDatabase": {
+			finalBackup: true,
+			version:     "POSTGRES_14",
+			operation:   "backup-operation",
+			exported:    []string{"orders"},
+			uri:         exportURI,
+			instance:    exportDatabases(t, "postgres"),
+			databases:   listDatabases("postgres", "orders"),
+			want:        want{operation: "export-operation", databases: []string{"orders", "postgres"}},
+		},
+		"AllDatabasesExported": {
+			finalBackup: true,
+			version:     "POSTGRES_14",
+			operation:   "backup-operation",
+			exported:    []string{"orders", "postgres"},
+			instance:    exportDatabases(t),
+			databases:   listDatabases("postgres", "orders"),
+			want:        want{done: true, operation: "backup-operation", databases: []string{"orders", "postgres"}},
+		},
+		"ListDatabasesFailed": {
+			finalBackup: true,
+			version:     "POSTGRES_14",
+			databases: &mockDatabaseLister{mockList: func(context.Context, string) ([]string, error) {
+				return nil, errTest
+			}},
+			want: want{err: errors.Wrap(errTest, "cannot list databases to back up")},
+		},
+		"InstanceGone": {
+			finalBackup: true,
+			uri:         exportURI,
+			instance: &fake.MockInstanceClient{
+				MockExport: func(ctx context.Context, name string, req *sqladmin.InstancesExportRequest) (*sqladmin.Operation, error) {
+					return nil, &googleapi.Error{Code: http.StatusNotFound}
+				},
+			},
+			want: want{done: true},
+		},
+		"StartFailed": {
+			finalBackup: true,
+			uri:         exportURI,
+			instance: &fake.MockInstanceClient{
+				MockExport: func(ctx context.Context, name string, req *sqladmin.InstancesExportRequest) (*sqladmin.Operation, error) {
+					return nil, errTest
+				},
+			},
+			want: want{err: errors.Wrap(errTest, "cannot start final backup")},
+		},
+		"Pending": {
+			finalBackup: true,
+			operation:   "backup-operation",
+			instance: &fake.MockInstanceClient{
+				MockGetOperation: func(ctx context.Context, name string) (*sqladmin.Operation, error) {
+					return &sqladmin.Operation{Name: name, Status: "RUNNING"}, nil
+				},
+			},
+			want: want{operation: "backup-operation"},
+		},
+		"Failed": {
+			finalBackup: true,
+			operation:   "backup-operation",
+			instance: &fake.MockInstanceClient{
+				MockGetOperation: func(ctx context.Context, name string) (*sqladmin.Operation, error) {
+					return &sqladmin.Operation{Name: name, Status: operationDone, Error: &sqladmin.OperationErrors{
+						Errors: []*sqladmin.OperationError{{Message: "boom"}},
+					}}, nil
+				},
+			},
+			want: want{err: errors.Wrap(errors.New("operation backup-operation failed: boom"), "final backup failed")},
+		},
+		"DatabaseExportFailed": {
+			finalBackup: true,
+			version:     "POSTGRES_14",
+			operation:   "backup-operation",
+			exported:    []string{"orders", "postgres"},
+			instance: &fake.MockInstanceClient{
+				MockGetOperation: func(ctx context.Context, name string) (*sqladmin.Operation, error) {
+					return &sqladmin.Operation{Name: name, Status: operationDone, Error: &sqladmin.OperationErrors{
+						Errors: []*sqladmin.OperationError{{Message: "boom"}},
+					}}, nil
+				},
+			},
+			want: want{
+				err:       errors.Wrap(errors.New("operation backup-operation failed: boom"), "final backup failed"),
+				databases: []string{"orders"},
+			},
+		},
+		"Done": {
+			finalBackup: true,
+			operation:   "backup-operation",
+			instance: &fake.MockInstanceClient{
+				MockGetOperation: func(ctx context.Context, name string) (*sqladmin.Operation, error) {
+					return &sqladmin.Operation{Name: name, Status: operationDone}, nil
+				},
+			},
+			want: want{done: true, operation: "backup-operation"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			obj := &v1alpha1.CloudsqlInstance{ObjectMeta: testMeta}
+			obj.Spec.FinalBackupOnDelete = tt.finalBackup
+			obj.Spec.DatabaseVersion = tt.version
+			obj.Status.FinalBackupOperation = tt.operation
+			obj.Status.FinalBackupDatabases = tt.exported
+			ih := &managedHandler{
+				CloudsqlInstance: obj,
+				localOperations: &mockLocalOperations{
+					mockFinalBackupURI: func(context.Context, string) (string, error) { return tt.uri, tt.uriErr },
+				},
+				instance:  tt.instance,
+				databases: tt.databases,
+			}
+			done, err := ih.finalBackup(context.Background())
+			if diff := cmp.Diff(tt.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("finalBackup() error -want, +got: %s", diff)
+			}
+			if done != tt.want.done {
+				t.Errorf("finalBackup() done: want %t, got %t", tt.want.done, done)
+			}
+			if diff := cmp.Diff(tt.want.operation, obj.Status.FinalBackupOperation); diff != "" {
+				t.Errorf("finalBackup() operation -want, +got: %s", diff)
+			}
+			if diff := cmp.Diff(tt.want.databases, obj.Status.FinalBackupDatabases, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("finalBackup() databases -want, +got: %s", diff)
+			}
+		})
+	}
+}
+
+func TestExportContext(t *testing.T) {
+	cases := map[string]struct {
+		version  string
+		database string
+		want     *sqladmin.ExportContext
+	}{
+		"MySQL": {
+			version: "MYSQL_8_0",
+			want:    &sqladmin.ExportContext{Uri: "gs://b/o", FileType: exportFileTypeSQL},
+		},
+		"PostgreSQL": {
+			version:  "POSTGRES_14",
+			database: "orders",
+			want:     &sqladmin.ExportContext{Uri: "gs://b/o", FileType: exportFileTypeSQL, Databases: []string{"orders"}},
+		},
+		"SQLServer": {
+			version:  "SQLSERVER_2019_STANDARD",
+			database: "inventory",
+			want:     &sqladmin.ExportContext{Uri: "gs://b/o", FileType: exportFileTypeBAK, Databases: []string{"inventory"}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := exportContext("gs://b/o", tc.version, tc.database)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("exportContext() -want, +got: %s", diff)
+			}
+		})
+	}
+}
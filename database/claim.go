@@ -50,15 +50,29 @@ func ConfigurePostgreSQLCloudsqlInstance(_ context.Context, cm resource.Claim, c
 	}
 
 	spec := v1alpha1.NewCloudSQLInstanceSpec(rs.Parameters)
-	translated := translateVersion(pg.Spec.EngineVersion, v1alpha1.PostgresqlDBVersionPrefix)
+	translated, err := translateVersion(pg.Spec.EngineVersion, v1alpha1.PostgresqlDBVersionPrefix)
+	if err != nil {
+		return err
+	}
 	v, err := resource.ResolveClassClaimValues(spec.DatabaseVersion, translated)
 	if err != nil {
 		return err
 	}
 	spec.DatabaseVersion = v
 
+	if err := configureSize(cm, rs.Parameters, spec); err != nil {
+		return err
+	}
+	if err := configureClaimFields(cm, spec); err != nil {
+		return err
+	}
+	ref, err := configureProvider(cm, rs)
+	if err != nil {
+		return err
+	}
+
 	spec.WriteConnectionSecretToReference = corev1.LocalObjectReference{Name: string(cm.GetUID())}
-	spec.ProviderReference = rs.ProviderReference
+	spec.ProviderReference = ref
 	spec.ReclaimPolicy = rs.ReclaimPolicy
 
 	i.Spec = *spec
@@ -86,15 +100,29 @@ func ConfigureMyCloudsqlInstance(_ context.Context, cm resource.Claim, cs resour
 	}
 
 	spec := v1alpha1.NewCloudSQLInstanceSpec(rs.Parameters)
-	translated := translateVersion(my.Spec.EngineVersion, v1alpha1.MysqlDBVersionPrefix)
+	translated, err := translateVersion(my.Spec.EngineVersion, v1alpha1.MysqlDBVersionPrefix)
+	if err != nil {
+		return err
+	}
 	v, err := resource.ResolveClassClaimValues(spec.DatabaseVersion, translated)
 	if err != nil {
 		return err
 	}
 	spec.DatabaseVersion = v
 
+	if err := configureSize(cm, rs.Parameters, spec); err != nil {
+		return err
+	}
+	if err := configureClaimFields(cm, spec); err != nil {
+		return err
+	}
+	ref, err := configureProvider(cm, rs)
+	if err != nil {
+		return err
+	}
+
 	spec.WriteConnectionSecretToReference = corev1.LocalObjectReference{Name: string(cm.GetUID())}
-	spec.ProviderReference = rs.ProviderReference
+	spec.ProviderReference = ref
 	spec.ReclaimPolicy = rs.ReclaimPolicy
 
 	i.Spec = *spec
@@ -102,9 +130,81 @@ func ConfigureMyCloudsqlInstance(_ context.Context, cm resource.Claim, cs resour
 	return nil
 }
 
-func translateVersion(version, versionPrefix string) string {
+// ConfigureSQLServerCloudsqlInstance configures the supplied instance
+// (presumed to be a CloudsqlInstance) using the supplied instance claim
+// (presumed to be a SQLServerInstance) and instance class.
+func ConfigureSQLServerCloudsqlInstance(_ context.Context, cm resource.Claim, cs resource.Class, mg resource.Managed) error {
+	ss, cmok := cm.(*databasev1alpha1.SQLServerInstance)
+	if !cmok {
+		return errors.Errorf("expected instance claim %s to be %s", cm.GetName(), databasev1alpha1.SQLServerInstanceGroupVersionKind)
+	}
+
+	rs, csok := cs.(*corev1alpha1.ResourceClass)
+	if !csok {
+		return errors.Errorf("expected resource class %s to be %s", cs.GetName(), corev1alpha1.ResourceClassGroupVersionKind)
+	}
+
+	i, mgok := mg.(*v1alpha1.CloudsqlInstance)
+	if !mgok {
+		return errors.Errorf("expected managed resource %s to be %s", mg.GetName(), v1alpha1.CloudsqlInstanceGroupVersionKind)
+	}
+
+	spec := v1alpha1.NewCloudSQLInstanceSpec(rs.Parameters)
+	translated, err := translateVersion(ss.Spec.EngineVersion, sqlServerDBVersionPrefix)
+	if err != nil {
+		return err
+	}
+	if translated != "" {
+		// SQL Server database versions include an edition. Classes may ask
+		// for another edition by omitting the claim's engine version.
+		translated += "_" + sqlServerDefaultEdition
+	}
+	v, err := resource.ResolveClassClaimValues(spec.DatabaseVersion, translated)
+	if err != nil {
+		return err
+	}
+	spec.DatabaseVersion = v
+
+	if err := configureSize(cm, rs.Parameters, spec); err != nil {
+		return err
+	}
+	if err := configureClaimFields(cm, spec); err != nil {
+		return err
+	}
+	ref, err := configureProvider(cm, rs)
+	if err != nil {
+		return err
+	}
+
+	spec.WriteConnectionSecretToReference = corev1.LocalObjectReference{Name: string(cm.GetUID())}
+	spec.ProviderReference = ref
+	spec.ReclaimPolicy = rs.ReclaimPolicy
+
+	i.Spec = *spec
+
+	return nil
+}
+
+// supportedVersions are the engine versions a resource claim may request,
+// keyed by the Cloud SQL database version prefix of their engine.
+var supportedVersions = map[string][]string{
+	v1alpha1.MysqlDBVersionPrefix:      {"5.6", "5.7", "8.0"},
+	v1alpha1.PostgresqlDBVersionPrefix: {"9.6", "10", "11", "12", "13", "14", "15", "16"},
+	sqlServerDBVersionPrefix:           {"2017", "2019", "2022"},
+}
+
+// translateVersion translates the supplied claim engine version, e.g. 9.6, to
+// a Cloud SQL database version, e.g. POSTGRES_9_6. It returns an error if
+// Cloud SQL does not support the requested engine version.
+func translateVersion(version, versionPrefix string) (string, error) {
 	if version == "" {
-		return ""
+		return "", nil
+	}
+	supported := supportedVersions[versionPrefix]
+	for _, s := range supported {
+		if version == s {
+			return fmt.Sprintf("%s_%s", versionPrefix, strings.Replace(version, ".", "_", -1)), nil
+		}
 	}
-	return fmt.Sprintf("%s_%s", versionPrefix, strings.Replace(version, ".", "_", -1))
+	return "", errors.Errorf("engine version %q is not supported; supported versions are %s", version, strings.Join(supported, ", "))
 }
@@ -21,6 +21,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -35,6 +36,7 @@ import (
 var (
 	_ resource.ManagedConfigurator = resource.ManagedConfiguratorFn(ConfigurePostgreSQLCloudsqlInstance)
 	_ resource.ManagedConfigurator = resource.ManagedConfiguratorFn(ConfigureMyCloudsqlInstance)
+	_ resource.ManagedConfigurator = resource.ManagedConfiguratorFn(ConfigureSQLServerCloudsqlInstance)
 )
 
 func TestConfigurePostgreCloudsqlInstance(t *testing.T) {
@@ -86,6 +88,89 @@ func TestConfigurePostgreCloudsqlInstance(t *testing.T) {
 				err: nil,
 			},
 		},
+		"SizeClass": {
+			args: args{
+				cm: &databasev1alpha1.PostgreSQLInstance{
+					ObjectMeta: metav1.ObjectMeta{UID: claimUID, Annotations: map[string]string{AnnotationSize: "large"}},
+					Spec:       databasev1alpha1.PostgreSQLInstanceSpec{EngineVersion: "9.6"},
+				},
+				cs: &corev1alpha1.ResourceClass{
+					Parameters: map[string]string{
+						"sizeClass.large.tier":      "db-custom-8-30720",
+						"sizeClass.large.storageGB": "500",
+						"sizeClass.large.flags":     "max_connections=1000",
+					},
+					ProviderReference: &corev1.ObjectReference{Name: providerName},
+					ReclaimPolicy:     corev1alpha1.ReclaimDelete,
+				},
+				mg: &v1alpha1.CloudsqlInstance{},
+			},
+			want: want{
+				mg: &v1alpha1.CloudsqlInstance{
+					Spec: v1alpha1.CloudsqlInstanceSpec{
+						ResourceSpec: corev1alpha1.ResourceSpec{
+							ReclaimPolicy:                    corev1alpha1.ReclaimDelete,
+							WriteConnectionSecretToReference: corev1.LocalObjectReference{Name: string(claimUID)},
+							ProviderReference:                &corev1.ObjectReference{Name: providerName},
+						},
+						AuthorizedNetworks: []string{},
+						DatabaseVersion:    "POSTGRES_9_6",
+						DatabaseFlags:      map[string]string{"max_connections": "1000"},
+						Labels:             map[string]string{},
+						StorageGB:          500,
+						Tier:               "db-custom-8-30720",
+					},
+				},
+				err: nil,
+			},
+		},
+		"ProviderOverride": {
+			args: args{
+				cm: &databasev1alpha1.PostgreSQLInstance{
+					ObjectMeta: metav1.ObjectMeta{UID: claimUID, Annotations: map[string]string{AnnotationProvider: "data-prod"}},
+					Spec:       databasev1alpha1.PostgreSQLInstanceSpec{EngineVersion: "9.6"},
+				},
+				cs: &corev1alpha1.ResourceClass{
+					Parameters:        map[string]string{allowedProvidersParameter: "data-prod"},
+					ProviderReference: &corev1.ObjectReference{Name: providerName},
+					ReclaimPolicy:     corev1alpha1.ReclaimDelete,
+				},
+				mg: &v1alpha1.CloudsqlInstance{},
+			},
+			want: want{
+				mg: &v1alpha1.CloudsqlInstance{
+					Spec: v1alpha1.CloudsqlInstanceSpec{
+						ResourceSpec: corev1alpha1.ResourceSpec{
+							ReclaimPolicy:                    corev1alpha1.ReclaimDelete,
+							WriteConnectionSecretToReference: corev1.LocalObjectReference{Name: string(claimUID)},
+							ProviderReference:                &corev1.ObjectReference{Name: "data-prod"},
+						},
+						AuthorizedNetworks: []string{},
+						DatabaseVersion:    "POSTGRES_9_6",
+						Labels:             map[string]string{},
+						StorageGB:          v1alpha1.DefaultStorageGB,
+					},
+				},
+				err: nil,
+			},
+		},
+		"UnsupportedEngineVersion": {
+			args: args{
+				cm: &databasev1alpha1.PostgreSQLInstance{
+					ObjectMeta: metav1.ObjectMeta{UID: claimUID},
+					Spec:       databasev1alpha1.PostgreSQLInstanceSpec{EngineVersion: "9.5"},
+				},
+				cs: &corev1alpha1.ResourceClass{
+					ProviderReference: &corev1.ObjectReference{Name: providerName},
+					ReclaimPolicy:     corev1alpha1.ReclaimDelete,
+				},
+				mg: &v1alpha1.CloudsqlInstance{},
+			},
+			want: want{
+				mg:  &v1alpha1.CloudsqlInstance{},
+				err: errors.New(`engine version "9.5" is not supported; supported versions are 9.6, 10, 11, 12, 13, 14, 15, 16`),
+			},
+		},
 	}
 
 	for name, tc := range cases {
@@ -150,6 +235,23 @@ func TestConfigureMyCloudsqlInstance(t *testing.T) {
 				err: nil,
 			},
 		},
+		"UnsupportedEngineVersion": {
+			args: args{
+				cm: &databasev1alpha1.MySQLInstance{
+					ObjectMeta: metav1.ObjectMeta{UID: claimUID},
+					Spec:       databasev1alpha1.MySQLInstanceSpec{EngineVersion: "5.5"},
+				},
+				cs: &corev1alpha1.ResourceClass{
+					ProviderReference: &corev1.ObjectReference{Name: providerName},
+					ReclaimPolicy:     corev1alpha1.ReclaimDelete,
+				},
+				mg: &v1alpha1.CloudsqlInstance{},
+			},
+			want: want{
+				mg:  &v1alpha1.CloudsqlInstance{},
+				err: errors.New(`engine version "5.5" is not supported; supported versions are 5.6, 5.7, 8.0`),
+			},
+		},
 	}
 
 	for name, tc := range cases {
@@ -164,3 +266,84 @@ func TestConfigureMyCloudsqlInstance(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigureSQLServerCloudsqlInstance(t *testing.T) {
+	type args struct {
+		ctx context.Context
+		cm  resource.Claim
+		cs  *corev1alpha1.ResourceClass
+		mg  resource.Managed
+	}
+
+	type want struct {
+		mg  resource.Managed
+		err error
+	}
+
+	claimUID := types.UID("definitely-a-uuid")
+	providerName := "coolprovider"
+
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"Successful": {
+			args: args{
+				cm: &databasev1alpha1.SQLServerInstance{
+					ObjectMeta: metav1.ObjectMeta{UID: claimUID},
+					Spec:       databasev1alpha1.SQLServerInstanceSpec{EngineVersion: "2019"},
+				},
+				cs: &corev1alpha1.ResourceClass{
+					ProviderReference: &corev1.ObjectReference{Name: providerName},
+					ReclaimPolicy:     corev1alpha1.ReclaimDelete,
+				},
+				mg: &v1alpha1.CloudsqlInstance{},
+			},
+			want: want{
+				mg: &v1alpha1.CloudsqlInstance{
+					Spec: v1alpha1.CloudsqlInstanceSpec{
+						ResourceSpec: corev1alpha1.ResourceSpec{
+							ReclaimPolicy:                    corev1alpha1.ReclaimDelete,
+							WriteConnectionSecretToReference: corev1.LocalObjectReference{Name: string(claimUID)},
+							ProviderReference:                &corev1.ObjectReference{Name: providerName},
+						},
+						AuthorizedNetworks: []string{},
+						DatabaseVersion:    "SQLSERVER_2019_STANDARD",
+						Labels:             map[string]string{},
+						StorageGB:          v1alpha1.DefaultStorageGB,
+					},
+				},
+				err: nil,
+			},
+		},
+		"UnsupportedEngineVersion": {
+			args: args{
+				cm: &databasev1alpha1.SQLServerInstance{
+					ObjectMeta: metav1.ObjectMeta{UID: claimUID},
+					Spec:       databasev1alpha1.SQLServerInstanceSpec{EngineVersion: "2012"},
+				},
+				cs: &corev1alpha1.ResourceClass{
+					ProviderReference: &corev1.ObjectReference{Name: providerName},
+					ReclaimPolicy:     corev1alpha1.ReclaimDelete,
+				},
+				mg: &v1alpha1.CloudsqlInstance{},
+			},
+			want: want{
+				mg:  &v1alpha1.CloudsqlInstance{},
+				err: errors.New(`engine version "2012" is not supported; supported versions are 2017, 2019, 2022`),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ConfigureSQLServerCloudsqlInstance(tc.args.ctx, tc.args.cm, tc.args.cs, tc.args.mg)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("ConfigureSQLServerCloudsqlInstance(...) Error -want, +got: %s", diff)
+			}
+			if diff := cmp.Diff(tc.want.mg, tc.args.mg, test.EquateConditions()); diff != "" {
+				t.Errorf("ConfigureSQLServerCloudsqlInstance(...) Managed: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+)
+
+// AnnotationOverrideDeletionProtection may be set to "true" on a
+// CloudsqlInstance with a Delete reclaim policy to allow the controller to
+// disable GCP deletion protection before deleting the instance.
+const AnnotationOverrideDeletionProtection = "cloudsql.gcp.crossplane.io/override-deletion-protection"
+
+// ReasonDeletionProtected indicates that a CloudsqlInstance could not be
+// deleted because it has GCP deletion protection enabled.
+const ReasonDeletionProtected corev1alpha1.ConditionReason = "DeletionProtected"
+
+// deletionProtected returns a condition indicating that the named instance
+// could not be deleted, and how to override its deletion protection.
+func deletionProtected(name string) corev1alpha1.Condition {
+	return corev1alpha1.Condition{
+		Type:               corev1alpha1.TypeSynced,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonDeletionProtected,
+		Message: fmt.Sprintf("cannot delete instance %s: deletion protection is enabled; "+
+			"annotate this resource with %s=true to disable deletion protection and delete it",
+			name, AnnotationOverrideDeletionProtection),
+	}
+}
+
+// ReasonInstanceUnavailable indicates that a CloudsqlInstance that has been
+// ready is no longer ready, for example because it is undergoing maintenance.
+const ReasonInstanceUnavailable corev1alpha1.ConditionReason = "InstanceUnavailable"
+
+// instanceUnavailable returns a condition indicating that the named instance,
+// which has been ready, is in the supplied not ready state.
+func instanceUnavailable(name, state string) corev1alpha1.Condition {
+	return corev1alpha1.Condition{
+		Type:               corev1alpha1.TypeReady,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonInstanceUnavailable,
+		Message:            fmt.Sprintf("instance %s is %s; waiting for it to become %s", name, state, v1alpha1.StateRunnable),
+	}
+}
+
+// Reasons a CloudsqlInstance may fail to be created that a user can act upon.
+const (
+	// ReasonQuotaExceeded indicates that the instance could not be created
+	// because the GCP project has exhausted a quota.
+	ReasonQuotaExceeded corev1alpha1.ConditionReason = "QuotaExceeded"
+
+	// ReasonIPRangeExhausted indicates that the instance could not be
+	// created because the private IP range allocated to its network has no
+	// free blocks.
+	ReasonIPRangeExhausted corev1alpha1.ConditionReason = "IPRangeExhausted"
+
+	// ReasonInvalidSpec indicates that the instance's spec configures a
+	// setting, database flag, or feature that its database engine or version
+	// does not support.
+	ReasonInvalidSpec corev1alpha1.ConditionReason = "InvalidSpec"
+)
+
+const (
+	reasonQuotaExceeded = "quotaExceeded"
+	codeQuotaExceeded   = "QUOTA_EXCEEDED"
+)
+
+// quotaMetric matches the quota metric GCP names in quota errors, e.g.
+// "Quota exceeded for quota metric 'Instances' and limit 'Instances per project'".
+var quotaMetric = regexp.MustCompile(`(?i)quota metric '([^']+)'`)
+
+// ipRangeExhaustedMessages are the messages with which GCP reports that a
+// private IP range allocated for service networking has no free blocks.
+var ipRangeExhaustedMessages = []string{
+	"couldn't find free blocks in allocated ip ranges",
+	"allocated ip range is exhausted",
+}
+
+// failureCondition returns a condition describing the supplied error if it
+// indicates that a quota or private IP range was exhausted, or that the spec
+// is invalid. It returns false if the error is not one of these well known
+// failures.
+func failureCondition(err error) (corev1alpha1.Condition, bool) {
+	if err == nil {
+		return corev1alpha1.Condition{}, false
+	}
+
+	var reason corev1alpha1.ConditionReason
+	switch e := errors.Cause(err).(type) {
+	case *googleapi.Error:
+		reason = classifyAPIError(e)
+	case *operationFailure:
+		reason = classifyOperationFailure(e)
+	case *invalidSpecError:
+		reason = ReasonInvalidSpec
+	}
+
+	msg := err.Error()
+	switch reason {
+	case ReasonQuotaExceeded:
+		if m := quotaMetric.FindStringSubmatch(msg); m != nil {
+			msg = fmt.Sprintf("quota metric %s exceeded: %s", m[1], msg)
+		}
+	case ReasonIPRangeExhausted:
+		msg = fmt.Sprintf("private IP range exhausted; allocate a larger range to the instance's network: %s", msg)
+	case ReasonInvalidSpec:
+		msg = fmt.Sprintf("invalid spec: %s", msg)
+	default:
+		return corev1alpha1.Condition{}, false
+	}
+
+	return corev1alpha1.Condition{
+		Type:               corev1alpha1.TypeSynced,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            msg,
+	}, true
+}
+
+func classifyAPIError(e *googleapi.Error) corev1alpha1.ConditionReason {
+	for _, i := range e.Errors {
+		if i.Reason == reasonQuotaExceeded {
+			return ReasonQuotaExceeded
+		}
+		if ipRangeExhausted(i.Message) {
+			return ReasonIPRangeExhausted
+		}
+	}
+	if ipRangeExhausted(e.Message) {
+		return ReasonIPRangeExhausted
+	}
+	return ""
+}
+
+func classifyOperationFailure(f *operationFailure) corev1alpha1.ConditionReason {
+	for _, e := range f.errors {
+		if e.Code == codeQuotaExceeded || quotaMetric.MatchString(e.Message) {
+			return ReasonQuotaExceeded
+		}
+		if ipRangeExhausted(e.Message) {
+			return ReasonIPRangeExhausted
+		}
+	}
+	return ""
+}
+
+func ipRangeExhausted(msg string) bool {
+	msg = strings.ToLower(msg)
+	for _, m := range ipRangeExhaustedMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
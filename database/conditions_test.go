@@ -0,0 +1,121 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+	corev1 "k8s.io/api/core/v1"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func Test_failureCondition(t *testing.T) {
+	errQuota := &googleapi.Error{
+		Code:    http.StatusForbidden,
+		Message: "Quota exceeded for quota metric 'Instances' and limit 'Instances per project'",
+		Errors:  []googleapi.ErrorItem{{Reason: "quotaExceeded"}},
+	}
+	errRateLimited := &googleapi.Error{
+		Code:    http.StatusTooManyRequests,
+		Message: "Rate limit exceeded",
+		Errors:  []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}},
+	}
+	errIPRange := operationError(&sqladmin.Operation{
+		Name: "test-operation",
+		Error: &sqladmin.OperationErrors{Errors: []*sqladmin.OperationError{
+			{Code: "INTERNAL_ERROR", Message: "Failed to create subnetwork. Couldn't find free blocks in allocated IP ranges."},
+		}},
+	})
+	errQuotaOperation := operationError(&sqladmin.Operation{
+		Name: "test-operation",
+		Error: &sqladmin.OperationErrors{Errors: []*sqladmin.OperationError{
+			{Code: "QUOTA_EXCEEDED", Message: "Quota exceeded"},
+		}},
+	})
+
+	condition := func(r corev1alpha1.ConditionReason, msg string) corev1alpha1.Condition {
+		return corev1alpha1.Condition{Type: corev1alpha1.TypeSynced, Status: corev1.ConditionFalse, Reason: r, Message: msg}
+	}
+
+	type want struct {
+		c  corev1alpha1.Condition
+		ok bool
+	}
+	tests := map[string]struct {
+		err  error
+		want want
+	}{
+		"NoError": {
+			err:  nil,
+			want: want{ok: false},
+		},
+		"UnknownError": {
+			err:  errors.New("boom"),
+			want: want{ok: false},
+		},
+		"RateLimited": {
+			err:  errRateLimited,
+			want: want{ok: false},
+		},
+		"QuotaExceeded": {
+			err: errors.Wrap(errQuota, "cannot create"),
+			want: want{
+				c:  condition(ReasonQuotaExceeded, "quota metric Instances exceeded: cannot create: "+errQuota.Error()),
+				ok: true,
+			},
+		},
+		"QuotaExceededOperation": {
+			err: errQuotaOperation,
+			want: want{
+				c:  condition(ReasonQuotaExceeded, errQuotaOperation.Error()),
+				ok: true,
+			},
+		},
+		"IPRangeExhausted": {
+			err: errIPRange,
+			want: want{
+				c:  condition(ReasonIPRangeExhausted, "private IP range exhausted; allocate a larger range to the instance's network: "+errIPRange.Error()),
+				ok: true,
+			},
+		},
+		"InvalidSpec": {
+			err: invalidSpec(errors.New("binaryLogEnabled is not supported by POSTGRES_14")),
+			want: want{
+				c:  condition(ReasonInvalidSpec, "invalid spec: binaryLogEnabled is not supported by POSTGRES_14"),
+				ok: true,
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, ok := failureCondition(tt.err)
+			if diff := cmp.Diff(tt.want.c, c, test.EquateConditions()); diff != "" {
+				t.Errorf("failureCondition() -want, +got: %s", diff)
+			}
+			if ok != tt.want.ok {
+				t.Errorf("failureCondition() ok: want %t, got %t", tt.want.ok, ok)
+			}
+		})
+	}
+}
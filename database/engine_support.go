@@ -0,0 +1,166 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+)
+
+// An engineRange is a database engine, and the earliest version of it, that
+// supports a database flag or feature. All versions are supported if since is
+// empty.
+type engineRange struct {
+	engine string
+	since  []int
+}
+
+// supports returns true if the supplied database version, e.g. MYSQL_8_0, is
+// within the range.
+func (r engineRange) supports(version string) bool {
+	return isEngine(version, r.engine) && compareVersions(versionOf(version), r.since) >= 0
+}
+
+func (r engineRange) String() string {
+	name := engineNames[r.engine]
+	if len(r.since) == 0 {
+		return name
+	}
+	v := make([]string, 0, len(r.since))
+	for _, n := range r.since {
+		v = append(v, strconv.Itoa(n))
+	}
+	return fmt.Sprintf("%s %s and later", name, strings.Join(v, "."))
+}
+
+// engineNames are the names of the engines with the supplied version prefix.
+var engineNames = map[string]string{
+	v1alpha1.MysqlDBVersionPrefix:      "MySQL",
+	v1alpha1.PostgresqlDBVersionPrefix: "PostgreSQL",
+	sqlServerDBVersionPrefix:           "SQL Server",
+}
+
+var (
+	mysql     = engineRange{engine: v1alpha1.MysqlDBVersionPrefix}
+	postgres  = engineRange{engine: v1alpha1.PostgresqlDBVersionPrefix}
+	sqlServer = engineRange{engine: sqlServerDBVersionPrefix}
+)
+
+// flagSupport is the engines and versions that support well known database
+// flags. Cloud SQL rejects a flag its engine does not support only once an
+// instance is created or updated. Flags that are not listed are passed to
+// Cloud SQL unchecked.
+var flagSupport = map[string][]engineRange{
+	"cloudsql.iam_authentication":       {{engine: v1alpha1.MysqlDBVersionPrefix, since: []int{5, 7}}, {engine: v1alpha1.PostgresqlDBVersionPrefix, since: []int{9, 6}}},
+	"cloudsql.enable_pgaudit":           {postgres},
+	"cloudsql.logical_decoding":         {postgres},
+	"cloudsql.enable_pg_cron":           {{engine: v1alpha1.PostgresqlDBVersionPrefix, since: []int{10}}},
+	"log_min_duration_statement":        {postgres},
+	flagPostgresTimeZone:                {postgres},
+	"max_connections":                   {mysql, postgres},
+	"binlog_row_image":                  {mysql},
+	"binlog_expire_logs_seconds":        {{engine: v1alpha1.MysqlDBVersionPrefix, since: []int{8, 0}}},
+	"general_log":                       {mysql},
+	"slow_query_log":                    {mysql},
+	"long_query_time":                   {mysql},
+	"log_bin_trust_function_creators":   {mysql},
+	flagMySQLTimeZone:                   {mysql},
+	flagMySQLCharacterSet:               {mysql},
+	flagMySQLCollation:                  {mysql},
+	"user connections":                  {sqlServer},
+	"contained database authentication": {sqlServer},
+	"cross db ownership chaining":       {sqlServer},
+	"max degree of parallelism":         {sqlServer},
+	"cost threshold for parallelism":    {sqlServer},
+	"external scripts enabled":          {sqlServer},
+	"remote access":                     {sqlServer},
+}
+
+// A feature of a Cloud SQL instance that only some engines support.
+type feature struct {
+	name    string
+	engines []engineRange
+	enabled func(s *sqladmin.Settings) bool
+}
+
+// features are the settings that only some engines support.
+var features = []feature{
+	{
+		name:    "binaryLogEnabled",
+		engines: []engineRange{mysql},
+		enabled: func(s *sqladmin.Settings) bool {
+			return s.BackupConfiguration != nil && s.BackupConfiguration.BinaryLogEnabled
+		},
+	},
+	{
+		name:    "pointInTimeRecoveryEnabled",
+		engines: []engineRange{postgres, sqlServer},
+		enabled: func(s *sqladmin.Settings) bool {
+			return s.BackupConfiguration != nil && s.BackupConfiguration.PointInTimeRecoveryEnabled
+		},
+	},
+	{
+		name:    "sqlServerAuditConfig",
+		engines: []engineRange{sqlServer},
+		enabled: func(s *sqladmin.Settings) bool { return s.SqlServerAuditConfig != nil },
+	},
+}
+
+// validateEngineSupport returns an error if the supplied Cloud SQL instance
+// sets a database flag or enables a feature that the supplied database
+// version does not support. Unknown or defaulted versions are not checked.
+func validateEngineSupport(version string, inst *sqladmin.DatabaseInstance) error {
+	if _, ok := engineNames[engineOf(version)]; !ok || inst.Settings == nil {
+		return nil
+	}
+
+	for _, f := range inst.Settings.DatabaseFlags {
+		engines, ok := flagSupport[f.Name]
+		if ok && !supportedBy(engines, version) {
+			return errors.Errorf("database flag %q is not supported by %s; it is supported by %s", f.Name, version, describe(engines))
+		}
+	}
+	for _, f := range features {
+		if f.enabled(inst.Settings) && !supportedBy(f.engines, version) {
+			return errors.Errorf("%s is not supported by %s; it is supported by %s", f.name, version, describe(f.engines))
+		}
+	}
+	return nil
+}
+
+func supportedBy(engines []engineRange, version string) bool {
+	for _, e := range engines {
+		if e.supports(version) {
+			return true
+		}
+	}
+	return false
+}
+
+func describe(engines []engineRange) string {
+	s := make([]string, 0, len(engines))
+	for _, e := range engines {
+		s = append(s, e.String())
+	}
+	return strings.Join(s, ", ")
+}
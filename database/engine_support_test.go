@@ -0,0 +1,101 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestValidateEngineSupport(t *testing.T) {
+	flags := func(names ...string) *sqladmin.DatabaseInstance {
+		inst := &sqladmin.DatabaseInstance{Settings: &sqladmin.Settings{}}
+		for _, n := range names {
+			inst.Settings.DatabaseFlags = append(inst.Settings.DatabaseFlags, &sqladmin.DatabaseFlags{Name: n, Value: "on"})
+		}
+		return inst
+	}
+	backups := func(bc *sqladmin.BackupConfiguration) *sqladmin.DatabaseInstance {
+		return &sqladmin.DatabaseInstance{Settings: &sqladmin.Settings{BackupConfiguration: bc}}
+	}
+
+	cases := map[string]struct {
+		version string
+		inst    *sqladmin.DatabaseInstance
+		want    error
+	}{
+		"DefaultedVersion": {
+			inst: flags("user connections"),
+		},
+		"UnknownFlag": {
+			version: "POSTGRES_14",
+			inst:    flags("work_mem"),
+		},
+		"IAMAuthenticationOnPostgres": {
+			version: "POSTGRES_14",
+			inst:    flags("cloudsql.iam_authentication"),
+		},
+		"IAMAuthenticationOnSQLServer": {
+			version: "SQLSERVER_2019_STANDARD",
+			inst:    flags("cloudsql.iam_authentication"),
+			want:    errors.New(`database flag "cloudsql.iam_authentication" is not supported by SQLSERVER_2019_STANDARD; it is supported by MySQL 5.7 and later, PostgreSQL 9.6 and later`),
+		},
+		"IAMAuthenticationOnOldMySQL": {
+			version: "MYSQL_5_6",
+			inst:    flags("cloudsql.iam_authentication"),
+			want:    errors.New(`database flag "cloudsql.iam_authentication" is not supported by MYSQL_5_6; it is supported by MySQL 5.7 and later, PostgreSQL 9.6 and later`),
+		},
+		"SQLServerFlagOnMySQL": {
+			version: "MYSQL_8_0",
+			inst:    flags("max_connections", "user connections"),
+			want:    errors.New(`database flag "user connections" is not supported by MYSQL_8_0; it is supported by SQL Server`),
+		},
+		"BinaryLogOnMySQL": {
+			version: "MYSQL_8_0",
+			inst:    backups(&sqladmin.BackupConfiguration{Enabled: true, BinaryLogEnabled: true}),
+		},
+		"BinaryLogOnPostgres": {
+			version: "POSTGRES_14",
+			inst:    backups(&sqladmin.BackupConfiguration{Enabled: true, BinaryLogEnabled: true}),
+			want:    errors.New("binaryLogEnabled is not supported by POSTGRES_14; it is supported by MySQL"),
+		},
+		"PointInTimeRecoveryOnMySQL": {
+			version: "MYSQL_8_0",
+			inst:    backups(&sqladmin.BackupConfiguration{Enabled: true, PointInTimeRecoveryEnabled: true}),
+			want:    errors.New("pointInTimeRecoveryEnabled is not supported by MYSQL_8_0; it is supported by PostgreSQL, SQL Server"),
+		},
+		"SQLServerAuditOnPostgres": {
+			version: "POSTGRES_14",
+			inst:    &sqladmin.DatabaseInstance{Settings: &sqladmin.Settings{SqlServerAuditConfig: &sqladmin.SqlServerAuditConfig{}}},
+			want:    errors.New("sqlServerAuditConfig is not supported by POSTGRES_14; it is supported by SQL Server"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := validateEngineSupport(tc.version, tc.inst)
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("validateEngineSupport(...): -want error, +got error:\n%s", diff)
+			}
+		})
+	}
+}
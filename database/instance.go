@@ -18,20 +18,29 @@ package database
 
 import (
 	"context"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
-	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2"
+	gapi "google.golang.org/api/googleapi"
+	monitoring "google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
 	sqladmin "google.golang.org/api/sqladmin/v1beta4"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
-	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
 	"github.com/crossplaneio/crossplane/pkg/clients/gcp/cloudsql"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/faultinject"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/httpreplay"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/tracing"
 	"github.com/crossplaneio/crossplane/pkg/logging"
 	"github.com/crossplaneio/crossplane/pkg/meta"
 	"github.com/crossplaneio/crossplane/pkg/resource"
@@ -42,9 +51,16 @@ const (
 	controllerName = "cloudsqlinstance.database.gcp.crossplane.io"
 	finalizer      = "finalizer." + controllerName
 
-	reconcileTimeout    = 1 * time.Minute
 	requeueAfterWait    = 10 * time.Second
 	requeueAfterSuccess = 5 * time.Minute
+
+	// DefaultReconcileTimeout bounds the time spent reconciling a
+	// CloudsqlInstance, unless the controller is configured otherwise.
+	DefaultReconcileTimeout = 1 * time.Minute
+
+	// DefaultAPICallTimeout bounds the time spent on each Cloud SQL API call,
+	// unless the controller is configured otherwise.
+	DefaultAPICallTimeout = 30 * time.Second
 )
 
 var (
@@ -72,6 +88,11 @@ var (
 type Reconciler struct {
 	client  client.Client
 	factory factory
+
+	// ctx is the parent of each reconcile's context. It is cancelled when the
+	// manager stops, so that in-flight API calls are abandoned promptly.
+	ctx     context.Context
+	timeout time.Duration
 }
 
 // Reconcile reads that state of the cloudsql instance object and makes changes based on the state read
@@ -79,20 +100,33 @@ type Reconciler struct {
 func (r *Reconciler) Reconcile(request reconcile.Request) (reconcile.Result, error) {
 	log.V(logging.Debug).Info("reconciling", "kind", v1alpha1.CloudsqlInstanceGroupVersionKind, "request", request)
 
-	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	ctx, cancel := r.newContext()
 	defer cancel()
 
+	ctx, span := tracing.StartReconcile(ctx, v1alpha1.CloudsqlInstanceKind, request.NamespacedName)
+	rs, err := r.reconcile(ctx, request)
+	return rs, tracing.End(span, err)
+}
+
+func (r *Reconciler) reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
 	i := &v1alpha1.CloudsqlInstance{}
 	if err := r.client.Get(ctx, request.NamespacedName, i); err != nil {
 		return requeueNever, handleNotFound(err)
 	}
 
+	// Each sync updates the instance's status, which triggers another
+	// reconcile. Skip instances that are not yet due to be synced again.
+	if wait, ok := r.nextSync(ctx, i, time.Now()); ok {
+		return reconcile.Result{RequeueAfter: wait}, nil
+	}
+
 	// create local operations to handle Kubernetes (local) types operations
 	lops := r.factory.makeLocalOperations(i, r.client)
 
 	// create managed operations to handle CloudSQL (managed) instance operations
-	mops, err := r.factory.makeManagedOperations(ctx, i, lops)
-	if err != nil {
+	cctx, span := tracing.StartPhase(ctx, tracing.PhaseConnect)
+	mops, err := r.factory.makeManagedOperations(cctx, i, lops)
+	if err := tracing.End(span, err); err != nil {
 		return requeueNow, lops.updateReconcileStatus(ctx, err)
 	}
 
@@ -106,6 +140,49 @@ func (r *Reconciler) Reconcile(request reconcile.Request) (reconcile.Result, err
 	return sd.sync(ctx)
 }
 
+// nextSync returns the time until the supplied instance is next due to be
+// synced with GCP, and true if it is not yet due. An instance is due once the
+// poll interval has passed since it was last synced, or as soon as its spec
+// changes, it is deleted, or its connection secret is deleted. An instance
+// that is asked to run an action or is running one is always due; annotations
+// don't change its generation, and a running action must be observed until it
+// completes. Deletion protection is only overridden while an instance is
+// deleted, when it is always due.
+func (r *Reconciler) nextSync(ctx context.Context, i *v1alpha1.CloudsqlInstance, now time.Time) (time.Duration, bool) {
+	if meta.WasDeleted(i) || i.Status.LastSyncTime == nil || i.Status.ObservedGeneration != i.GetGeneration() {
+		return 0, false
+	}
+	if _, ok := i.GetAnnotations()[AnnotationOperation]; ok || i.Status.ActionOperation != "" {
+		return 0, false
+	}
+
+	wait := i.Status.LastSyncTime.Add(requeueAfterSuccess).Sub(now)
+	if wait <= 0 {
+		return 0, false
+	}
+
+	// The next sync regenerates a connection secret that was deleted.
+	key := types.NamespacedName{Namespace: i.GetNamespace(), Name: i.ConnectionSecret().Name}
+	if err := r.client.Get(ctx, key, &corev1.Secret{}); err != nil {
+		return 0, false
+	}
+	return wait, true
+}
+
+// newContext returns the context of a single reconcile, which is cancelled
+// when the reconcile times out or the manager stops.
+func (r *Reconciler) newContext() (context.Context, context.CancelFunc) {
+	ctx := r.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	t := r.timeout
+	if t == 0 {
+		t = DefaultReconcileTimeout
+	}
+	return context.WithTimeout(ctx, t)
+}
+
 type factory interface {
 	makeLocalOperations(*v1alpha1.CloudsqlInstance, client.Client) localOperations
 	makeManagedOperations(context.Context, *v1alpha1.CloudsqlInstance, localOperations) (managedOperations, error)
@@ -114,33 +191,100 @@ type factory interface {
 
 type operationsFactory struct {
 	client.Client
+	recorder    record.EventRecorder
+	providers   provider.Resolver
+	callTimeout time.Duration
+
+	// clientOptions are passed to each Cloud SQL client, typically to use a
+	// fake API endpoint in tests.
+	clientOptions []option.ClientOption
+
+	// traceAPICalls records a span for each Cloud SQL API call.
+	traceAPICalls bool
+
+	// faults are injected into Cloud SQL API calls, if not nil.
+	faults *faultinject.Injector
+
+	// replay records or replays Cloud SQL API calls, if not nil.
+	replay *httpreplay.Recorder
+
+	// services enables GCP services that Cloud SQL API calls report are
+	// disabled, if not nil.
+	services *provider.ServiceEnabler
+
+	// instances caches the instances of each project, if not nil.
+	instances *instanceCache
+
+	// pooler configures the connection poolers instances may request.
+	pooler PoolerConfig
 }
 
 var _ factory = &operationsFactory{}
 
 func (f *operationsFactory) makeLocalOperations(inst *v1alpha1.CloudsqlInstance, kube client.Client) localOperations {
-	return newLocalHandler(inst, kube)
+	h := newLocalHandler(inst, kube)
+	h.recorder = f.recorder
+	h.pooler = f.pooler
+	return h
 }
 
 func (f *operationsFactory) makeManagedOperations(ctx context.Context, inst *v1alpha1.CloudsqlInstance, ops localOperations) (managedOperations, error) {
-	p := &gcpv1alpha1.Provider{}
-	n := meta.NamespacedNameOf(inst.GetProviderReference())
-	if err := f.Get(ctx, n, p); err != nil {
+	p, err := f.providers.Get(ctx, f, inst, inst.GetProviderReference())
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, f, p, provider.ServiceSQLAdmin, cloudsql.DefaultScope, monitoring.MonitoringReadScope)
+	if err != nil {
 		return nil, err
 	}
 
-	s := &corev1.Secret{}
-	n = types.NamespacedName{Namespace: p.GetNamespace(), Name: p.Spec.Secret.Name}
-	if err := f.Get(ctx, n, s); err != nil {
-		return nil, errors.Wrapf(err, "cannot get provider's secret %s", n)
+	var hc *http.Client
+	if f.traceAPICalls {
+		hc = tracing.HTTPClient(ctx, creds)
+	}
+	if f.replay != nil {
+		if hc == nil {
+			hc = oauth2.NewClient(ctx, creds.TokenSource)
+		}
+		// Wrap the replay transport first so that injected faults are
+		// neither recorded nor replayed.
+		hc = f.replay.WrapClient(hc)
+	}
+	if f.faults != nil {
+		if hc == nil {
+			hc = oauth2.NewClient(ctx, creds.TokenSource)
+		}
+		hc = f.faults.WrapClient(hc)
+	}
+
+	opts := f.clientOptions
+	if hc != nil {
+		// Options that are passed later take precedence, so any client
+		// options of the factory override the traced HTTP client.
+		opts = append([]option.ClientOption{option.WithHTTPClient(hc)}, opts...)
 	}
 
-	creds, err := google.CredentialsFromJSON(ctx, s.Data[p.Spec.Secret.Key], cloudsql.DefaultScope)
+	h, err := newManagedHandler(ctx, inst, ops, creds, opts...)
 	if err != nil {
-		return nil, errors.Wrapf(err, "cannot retrieve creds from json")
+		return nil, err
+	}
+	if f.instances != nil && creds.ProjectID != "" {
+		h.instance = &cachingInstanceService{InstanceService: h.instance, cache: f.instances, key: instanceCacheKey(p, creds.ProjectID)}
+	}
+	h.callTimeout = f.callTimeout
+	h.recorder = f.recorder
+	h.enableService = func(ctx context.Context, err error) (bool, error) {
+		return f.services.EnableDisabledService(ctx, p, err)
 	}
 
-	return newManagedHandler(ctx, inst, ops, creds)
+	// Access previously granted must be revoked even if none is now wanted.
+	if needsServiceAccountAccess(inst) {
+		if h.kms, h.buckets, err = newGranters(ctx, f, p); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
 }
 
 func (f *operationsFactory) makeSyncDeleter(ops managedOperations) syncdeleter {
@@ -161,14 +305,49 @@ type instanceSyncDeleter struct {
 }
 
 func (sd *instanceSyncDeleter) delete(ctx context.Context) (reconcile.Result, error) {
-	if sd.isReclaimDelete() {
-		if err := handleNotFound(sd.deleteInstance(ctx)); err != nil {
+	// Instances in dry-run mode never change GCP, so the instance is left as is.
+	if sd.isReclaimDelete() && !sd.isDryRun() {
+		// Block deletion until the final backup, if any, is complete.
+		done, err := sd.finalBackup(ctx)
+		if err != nil {
+			return requeueNow, sd.updateReconcileStatus(ctx, err)
+		}
+		if !done {
+			return requeueWait, sd.updateReconcileStatus(ctx, nil)
+		}
+
+		err = handleNotFound(sd.deleteInstance(ctx))
+		if isErrorDeletionProtected(err) {
+			return sd.deleteProtected(ctx)
+		}
+		if err != nil {
 			return requeueNow, sd.updateReconcileStatus(ctx, err)
 		}
 	}
+	// The connection pooler runs in Kubernetes, so it is deleted along with
+	// the instance regardless of its reclaim policy.
+	if err := sd.deleteConnectionPooler(ctx); err != nil {
+		return requeueNow, sd.updateReconcileStatus(ctx, err)
+	}
 	return requeueNow, sd.removeFinalizer(ctx)
 }
 
+// deleteProtected handles an instance that GCP refused to delete because it
+// has deletion protection enabled. Deletion protection is only disabled when
+// explicitly overridden; otherwise we report why the instance can't be deleted
+// and check back infrequently.
+func (sd *instanceSyncDeleter) deleteProtected(ctx context.Context) (reconcile.Result, error) {
+	if !sd.isDeletionProtectionOverridden() {
+		return requeueSync, sd.updateDeletionProtectedStatus(ctx)
+	}
+	if err := sd.disableDeletionProtection(ctx); err != nil {
+		return requeueNow, sd.updateReconcileStatus(ctx, errors.Wrap(err, "cannot disable deletion protection"))
+	}
+	// Disabling deletion protection is an asynchronous operation. Wait for it
+	// to complete before we try to delete the instance again.
+	return requeueWait, sd.updateReconcileStatus(ctx, nil)
+}
+
 // sync - synchronizes the state of the cloudsql instance instance with the
 // state of the obj object
 func (sd *instanceSyncDeleter) sync(ctx context.Context) (reconcile.Result, error) {
@@ -204,11 +383,80 @@ func newInstanceCreateUpdater(ops managedOperations) *instanceCreateUpdater {
 
 // create new instance instance
 func (ih *instanceCreateUpdater) create(ctx context.Context) (reconcile.Result, error) {
+	// A previous reconcile may have started creating an instance that GCP does
+	// not report yet. Observe that operation rather than creating a duplicate.
+	op, err := ih.getCreateOperation(ctx)
+	if err != nil {
+		return requeueNow, ih.updateReconcileStatus(ctx, err)
+	}
+	if op != nil {
+		if op.Status != operationDone {
+			return ih.waitForCreate(ctx, operationError(op))
+		}
+		if err := operationError(op); err != nil && !ih.resetFailedCreate() {
+			return requeueSync, ih.updateFailedStatus(ctx, err)
+		}
+		// Either creation failed and the spec has since changed, or the
+		// instance was created but no longer exists, presumably because it
+		// was deleted outside of Crossplane. Create it again.
+	}
+
+	// Don't requeue invalid specs; they'll be reconciled again when updated.
+	if err := ih.validate(); err != nil {
+		return requeueNever, ih.updateReconcileStatus(ctx, err)
+	}
+
+	if err := ih.resolveConnection(ctx); err != nil {
+		return requeueWait, ih.updateReconcileStatus(ctx, err)
+	}
+
+	if ih.isDryRun() {
+		return requeueNever, ih.updatePlannedStatus(ctx, "CreateInstance")
+	}
+
 	if err := ih.addFinalizer(ctx); err != nil {
 		return requeueNow, errors.Wrap(err, "failed to update instance object")
 	}
 
-	return requeueNow, ih.updateReconcileStatus(ctx, ih.createInstance(ctx))
+	if err := ih.createInstance(ctx); err != nil {
+		stalled, serr := ih.isCreationStalled(ctx, v1alpha1.StatePendingCreate)
+		if serr != nil {
+			return requeueNow, errors.Wrap(serr, "failed to update instance object")
+		}
+		if stalled {
+			return requeueNow, ih.updateStalledStatus(ctx, err)
+		}
+		return requeueNow, ih.updateReconcileStatus(ctx, err)
+	}
+	return requeueNow, ih.updateReconcileStatus(ctx, nil)
+}
+
+// waitForCreate requeues an instance whose creation operation is still
+// running, marking it as stalled if it has been pending creation for longer
+// than its creation deadline. The supplied error, if any, is the error most
+// recently reported by the operation.
+func (ih *instanceCreateUpdater) waitForCreate(ctx context.Context, err error) (reconcile.Result, error) {
+	stalled, serr := ih.isCreationStalled(ctx, v1alpha1.StatePendingCreate)
+	if serr != nil {
+		return requeueNow, errors.Wrap(serr, "failed to update instance object")
+	}
+	if stalled {
+		return requeueWait, ih.updateStalledStatus(ctx, err)
+	}
+	return requeueWait, ih.updateReconcileStatus(ctx, nil)
+}
+
+// createOperationError returns the error reported by the operation creating
+// the instance, if any, so that it may be surfaced when creation stalls.
+func (ih *instanceCreateUpdater) createOperationError(ctx context.Context) error {
+	op, err := ih.getCreateOperation(ctx)
+	if err != nil {
+		return err
+	}
+	if op == nil {
+		return nil
+	}
+	return operationError(op)
 }
 
 // update cloudsql instance instance if needed
@@ -218,15 +466,71 @@ func (ih *instanceCreateUpdater) update(ctx context.Context, inst *sqladmin.Data
 	}
 
 	if !ih.isInstanceReady() {
+		if ih.hasBeenReady() {
+			// The instance was created, so it is unavailable rather than
+			// creating, for example during maintenance.
+			return requeueWait, ih.updateUnavailableStatus(ctx)
+		}
+		stalled, err := ih.isCreationStalled(ctx, inst.State)
+		if err != nil {
+			return requeueNow, errors.Wrap(err, "failed to update instance object")
+		}
+		if stalled {
+			return requeueWait, ih.updateStalledStatus(ctx, ih.createOperationError(ctx))
+		}
+		return requeueWait, ih.updateReconcileStatus(ctx, nil)
+	}
+
+	if err := ih.forgetCreation(ctx); err != nil {
+		return requeueNow, errors.Wrap(err, "failed to update instance object")
+	}
+
+	// Run any action requested by the operation annotation before updating
+	// the instance, which would otherwise contend with the action.
+	running, err := ih.runAction(ctx, inst)
+	if err != nil {
+		return requeueSync, ih.updateReconcileStatus(ctx, err)
+	}
+	if running {
 		return requeueWait, ih.updateReconcileStatus(ctx, nil)
 	}
 
-	// NOTE: needsUpdate(...) always returns false, for details see needsUpdate function call
 	if ih.needsUpdate(inst) {
+		if err := ih.validate(); err != nil {
+			return requeueNever, ih.updateReconcileStatus(ctx, err)
+		}
+		if ih.isDryRun() {
+			return requeueSync, ih.updatePlannedStatus(ctx, "UpdateInstance")
+		}
 		return requeueNow, ih.updateReconcileStatus(ctx, ih.updateInstance(ctx))
 	}
 
-	return requeueSync, ih.updateReconcileStatus(ctx, ih.updateUserCreds(ctx))
+	// Metrics are informational, so failing to read them doesn't fail the
+	// reconcile.
+	if err := ih.observeMetrics(ctx, inst); err != nil {
+		log.V(logging.Debug).Info("cannot observe metrics", "instance", inst.Name, "error", err.Error())
+	}
+
+	if err := ih.updateUserCreds(ctx); err != nil {
+		return requeueSync, ih.updateReconcileStatus(ctx, err)
+	}
+	if err := ih.grantServiceAccountAccess(ctx); err != nil {
+		return requeueSync, ih.updateReconcileStatus(ctx, err)
+	}
+	if err := ih.reconcileConnectionPooler(ctx); err != nil {
+		return requeueSync, ih.updateReconcileStatus(ctx, err)
+	}
+	return requeueSync, ih.updateSyncedStatus(ctx)
+}
+
+// isErrorDeletionProtected returns true if the supplied error indicates that
+// an instance could not be deleted because it has deletion protection enabled.
+func isErrorDeletionProtected(err error) bool {
+	e, ok := errors.Cause(err).(*gapi.Error)
+	if !ok {
+		return false
+	}
+	return e.Code == http.StatusBadRequest && strings.Contains(strings.ToLower(e.Message), "deletion protection")
 }
 
 func handleNotFound(err error) error {
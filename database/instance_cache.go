@@ -0,0 +1,171 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/cloudsql"
+)
+
+// instanceCacheTTL is how long the instances listed in a project are used to
+// answer Get calls before they are listed again. It is short enough that an
+// instance's state is rarely more than one requeue stale.
+const instanceCacheTTL = 10 * time.Second
+
+// An instanceCache caches the Cloud SQL instances in each project. It is
+// shared by every reconcile of the CloudsqlInstance controller so that one
+// instances.list call per project and TTL answers the Get calls of all
+// instances in that project, rather than each instance making its own.
+// Instances are cached separately for each set of credentials, so that an
+// instance is never read using credentials that may not read it.
+type instanceCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu       sync.Mutex
+	projects map[string]*cachedProject
+}
+
+// A cachedProject is the instances last listed in a project. Its mutex is held
+// while the instances are listed, so that concurrent reconciles of instances
+// in the same project wait for a single list call.
+type cachedProject struct {
+	mu        sync.Mutex
+	listed    time.Time
+	instances map[string]*sqladmin.DatabaseInstance
+}
+
+func newInstanceCache(ttl time.Duration) *instanceCache {
+	return &instanceCache{ttl: ttl, now: time.Now, projects: map[string]*cachedProject{}}
+}
+
+// instanceCacheKey returns the key under which the instances read using credentials
+// from the supplied Provider are cached: their project and the Provider.
+// Credentials such as access tokens have no JSON to identify them by, but a
+// Provider's resource version changes whenever its credentials config does.
+func instanceCacheKey(p *gcpv1alpha1.Provider, project string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", project, p.GetNamespace(), p.GetName(), p.GetResourceVersion())
+}
+
+func (c *instanceCache) project(project string) *cachedProject {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.projects[project]
+	if !ok {
+		p = &cachedProject{}
+		c.projects[project] = p
+	}
+	return p
+}
+
+// Get returns the named instance from the cache, listing the instances in its
+// project using the supplied service if they were not listed within the
+// cache's TTL. Instances that were not listed - for example because they were
+// created since - are read directly. The returned instance is shared and must
+// not be modified.
+func (c *instanceCache) Get(ctx context.Context, svc cloudsql.InstanceService, project, name string) (*sqladmin.DatabaseInstance, error) {
+	p := c.project(project)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.instances == nil || c.now().Sub(p.listed) > c.ttl {
+		instances, err := svc.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.instances = make(map[string]*sqladmin.DatabaseInstance, len(instances))
+		for _, inst := range instances {
+			p.instances[inst.Name] = inst
+		}
+		p.listed = c.now()
+	}
+
+	if inst, ok := p.instances[name]; ok {
+		return inst, nil
+	}
+	return svc.Get(ctx, name)
+}
+
+// Invalidate discards the instances cached under the supplied key, so that
+// changes made to them are observed by the next call to Get.
+func (c *instanceCache) Invalidate(project string) {
+	p := c.project(project)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.instances = nil
+}
+
+// A cachingInstanceService is a cloudsql.InstanceService that reads instances
+// from an instanceCache, and invalidates the cache when it changes an
+// instance.
+type cachingInstanceService struct {
+	cloudsql.InstanceService
+	cache *instanceCache
+	key   string
+}
+
+func (s *cachingInstanceService) Get(ctx context.Context, name string) (*sqladmin.DatabaseInstance, error) {
+	return s.cache.Get(ctx, s.InstanceService, s.key, name)
+}
+
+func (s *cachingInstanceService) Create(ctx context.Context, inst *sqladmin.DatabaseInstance) (*sqladmin.Operation, error) {
+	defer s.cache.Invalidate(s.key)
+	return s.InstanceService.Create(ctx, inst)
+}
+
+func (s *cachingInstanceService) Update(ctx context.Context, name string, inst *sqladmin.DatabaseInstance) error {
+	defer s.cache.Invalidate(s.key)
+	return s.InstanceService.Update(ctx, name, inst)
+}
+
+func (s *cachingInstanceService) Patch(ctx context.Context, name string, inst *sqladmin.DatabaseInstance) error {
+	defer s.cache.Invalidate(s.key)
+	return s.InstanceService.Patch(ctx, name, inst)
+}
+
+func (s *cachingInstanceService) Delete(ctx context.Context, name string) error {
+	defer s.cache.Invalidate(s.key)
+	return s.InstanceService.Delete(ctx, name)
+}
+
+func (s *cachingInstanceService) Restart(ctx context.Context, name string) (*sqladmin.Operation, error) {
+	defer s.cache.Invalidate(s.key)
+	return s.InstanceService.Restart(ctx, name)
+}
+
+func (s *cachingInstanceService) Failover(ctx context.Context, name string, req *sqladmin.InstancesFailoverRequest) (*sqladmin.Operation, error) {
+	defer s.cache.Invalidate(s.key)
+	return s.InstanceService.Failover(ctx, name, req)
+}
+
+func (s *cachingInstanceService) InsertBackupRun(ctx context.Context, name string, run *sqladmin.BackupRun) (*sqladmin.Operation, error) {
+	defer s.cache.Invalidate(s.key)
+	return s.InstanceService.InsertBackupRun(ctx, name, run)
+}
+
+func (s *cachingInstanceService) Export(ctx context.Context, name string, req *sqladmin.InstancesExportRequest) (*sqladmin.Operation, error) {
+	defer s.cache.Invalidate(s.key)
+	return s.InstanceService.Export(ctx, name, req)
+}
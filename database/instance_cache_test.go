@@ -0,0 +1,247 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/cloudsql/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestInstanceCacheGet(t *testing.T) {
+	errBoom := errors.New("boom")
+	listed := []*sqladmin.DatabaseInstance{{Name: "sql-a", State: "PENDING_CREATE"}, {Name: "sql-b", State: "RUNNABLE"}}
+
+	type want struct {
+		instance *sqladmin.DatabaseInstance
+		err      error
+		lists    int
+		gets     int
+	}
+
+	cases := map[string]struct {
+		list    func() ([]*sqladmin.DatabaseInstance, error)
+		prepare func(c *instanceCache, now *time.Time)
+		name    string
+		want    want
+	}{
+		"Listed": {
+			list: func() ([]*sqladmin.DatabaseInstance, error) { return listed, nil },
+			name: "sql-b",
+			want: want{instance: listed[1], lists: 1},
+		},
+		"NotListed": {
+			list: func() ([]*sqladmin.DatabaseInstance, error) { return listed, nil },
+			name: "sql-new",
+			want: want{instance: &sqladmin.DatabaseInstance{Name: "sql-new"}, lists: 1, gets: 1},
+		},
+		"Cached": {
+			list: func() ([]*sqladmin.DatabaseInstance, error) { return listed, nil },
+			prepare: func(c *instanceCache, now *time.Time) {
+				c.project("cool-project").instances = map[string]*sqladmin.DatabaseInstance{"sql-b": {Name: "sql-b", State: "MAINTENANCE"}}
+				c.project("cool-project").listed = *now
+			},
+			name: "sql-b",
+			want: want{instance: &sqladmin.DatabaseInstance{Name: "sql-b", State: "MAINTENANCE"}},
+		},
+		"Expired": {
+			list: func() ([]*sqladmin.DatabaseInstance, error) { return listed, nil },
+			prepare: func(c *instanceCache, now *time.Time) {
+				c.project("cool-project").instances = map[string]*sqladmin.DatabaseInstance{"sql-b": {Name: "sql-b", State: "MAINTENANCE"}}
+				c.project("cool-project").listed = now.Add(-2 * instanceCacheTTL)
+			},
+			name: "sql-b",
+			want: want{instance: listed[1], lists: 1},
+		},
+		"Invalidated": {
+			list: func() ([]*sqladmin.DatabaseInstance, error) { return listed, nil },
+			prepare: func(c *instanceCache, now *time.Time) {
+				c.project("cool-project").instances = map[string]*sqladmin.DatabaseInstance{"sql-b": {Name: "sql-b", State: "MAINTENANCE"}}
+				c.project("cool-project").listed = *now
+				c.Invalidate("cool-project")
+			},
+			name: "sql-b",
+			want: want{instance: listed[1], lists: 1},
+		},
+		"ListFailed": {
+			list: func() ([]*sqladmin.DatabaseInstance, error) { return nil, errBoom },
+			name: "sql-b",
+			want: want{err: errBoom, lists: 1},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			c := newInstanceCache(instanceCacheTTL)
+			c.now = func() time.Time { return now }
+			if tc.prepare != nil {
+				tc.prepare(c, &now)
+			}
+
+			lists, gets := 0, 0
+			svc := &fake.MockInstanceClient{
+				MockList: func(context.Context) ([]*sqladmin.DatabaseInstance, error) {
+					lists++
+					return tc.list()
+				},
+				MockGet: func(_ context.Context, name string) (*sqladmin.DatabaseInstance, error) {
+					gets++
+					return &sqladmin.DatabaseInstance{Name: name}, nil
+				},
+			}
+
+			got, err := c.Get(context.Background(), svc, "cool-project", tc.name)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("c.Get(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.instance, got); diff != "" {
+				t.Errorf("c.Get(...): -want, +got:\n%s", diff)
+			}
+			if lists != tc.want.lists || gets != tc.want.gets {
+				t.Errorf("c.Get(...): want %d list and %d get calls, got %d and %d", tc.want.lists, tc.want.gets, lists, gets)
+			}
+		})
+	}
+}
+
+func TestInstanceCacheConcurrentGet(t *testing.T) {
+	c := newInstanceCache(instanceCacheTTL)
+
+	var mu sync.Mutex
+	lists := 0
+	svc := &fake.MockInstanceClient{
+		MockList: func(context.Context) ([]*sqladmin.DatabaseInstance, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			lists++
+			return []*sqladmin.DatabaseInstance{{Name: "sql-a"}, {Name: "sql-b"}}, nil
+		},
+	}
+
+	var wg sync.WaitGroup
+	for _, name := range []string{"sql-a", "sql-b", "sql-a", "sql-b"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if _, err := c.Get(context.Background(), svc, "cool-project", name); err != nil {
+				t.Errorf("c.Get(...): %s", err)
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	if lists != 1 {
+		t.Errorf("c.Get(...): want 1 list call, got %d", lists)
+	}
+}
+
+func TestCachingInstanceService(t *testing.T) {
+	op := func() (*sqladmin.Operation, error) { return &sqladmin.Operation{}, nil }
+	svc := &fake.MockInstanceClient{
+		MockCreate:  func(context.Context, *sqladmin.DatabaseInstance) (*sqladmin.Operation, error) { return op() },
+		MockUpdate:  func(context.Context, string, *sqladmin.DatabaseInstance) error { return nil },
+		MockPatch:   func(context.Context, string, *sqladmin.DatabaseInstance) error { return nil },
+		MockDelete:  func(context.Context, string) error { return nil },
+		MockRestart: func(context.Context, string) (*sqladmin.Operation, error) { return op() },
+		MockFailover: func(context.Context, string, *sqladmin.InstancesFailoverRequest) (*sqladmin.Operation, error) {
+			return op()
+		},
+		MockInsertBackupRun: func(context.Context, string, *sqladmin.BackupRun) (*sqladmin.Operation, error) { return op() },
+		MockExport: func(context.Context, string, *sqladmin.InstancesExportRequest) (*sqladmin.Operation, error) {
+			return op()
+		},
+	}
+
+	ctx := context.Background()
+	cases := map[string]func(cs *cachingInstanceService) error{
+		"Create": func(cs *cachingInstanceService) error {
+			_, err := cs.Create(ctx, &sqladmin.DatabaseInstance{Name: "sql-a"})
+			return err
+		},
+		"Update": func(cs *cachingInstanceService) error {
+			return cs.Update(ctx, "sql-a", &sqladmin.DatabaseInstance{})
+		},
+		"Patch": func(cs *cachingInstanceService) error {
+			return cs.Patch(ctx, "sql-a", &sqladmin.DatabaseInstance{})
+		},
+		"Delete": func(cs *cachingInstanceService) error {
+			return cs.Delete(ctx, "sql-a")
+		},
+		"Restart": func(cs *cachingInstanceService) error {
+			_, err := cs.Restart(ctx, "sql-a")
+			return err
+		},
+		"Failover": func(cs *cachingInstanceService) error {
+			_, err := cs.Failover(ctx, "sql-a", &sqladmin.InstancesFailoverRequest{})
+			return err
+		},
+		"InsertBackupRun": func(cs *cachingInstanceService) error {
+			_, err := cs.InsertBackupRun(ctx, "sql-a", &sqladmin.BackupRun{})
+			return err
+		},
+		"Export": func(cs *cachingInstanceService) error {
+			_, err := cs.Export(ctx, "sql-a", &sqladmin.InstancesExportRequest{})
+			return err
+		},
+	}
+
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := newInstanceCache(instanceCacheTTL)
+			c.project("cool-project/a").instances = map[string]*sqladmin.DatabaseInstance{}
+			c.project("cool-project/b").instances = map[string]*sqladmin.DatabaseInstance{}
+			cs := &cachingInstanceService{InstanceService: svc, cache: c, key: "cool-project/a"}
+
+			if err := mutate(cs); err != nil {
+				t.Fatalf("cs.%s(...): %s", name, err)
+			}
+			if c.project("cool-project/a").instances != nil {
+				t.Errorf("cs.%s(...): want cache invalidated", name)
+			}
+			if c.project("cool-project/b").instances == nil {
+				t.Errorf("cs.%s(...): want cache of other credentials retained", name)
+			}
+		})
+	}
+}
+
+func TestInstanceCacheKey(t *testing.T) {
+	pa := &gcpv1alpha1.Provider{ObjectMeta: metav1.ObjectMeta{Namespace: "cool-namespace", Name: "a", ResourceVersion: "1"}}
+	pb := &gcpv1alpha1.Provider{ObjectMeta: metav1.ObjectMeta{Namespace: "cool-namespace", Name: "b", ResourceVersion: "1"}}
+	a := instanceCacheKey(pa, "cool-project")
+	b := instanceCacheKey(pb, "cool-project")
+	if a == b {
+		t.Errorf("instanceCacheKey(...): want distinct keys for distinct providers in the same project, got %q", a)
+	}
+
+	updated := pa.DeepCopy()
+	updated.SetResourceVersion("2")
+	if instanceCacheKey(updated, "cool-project") == a {
+		t.Errorf("instanceCacheKey(...): want distinct keys for distinct resource versions of a provider, got %q", a)
+	}
+}
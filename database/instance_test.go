@@ -2,8 +2,10 @@ package database
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
@@ -37,6 +39,10 @@ func (m *mockCreateUpdater) update(ctx context.Context, di *sqladmin.DatabaseIns
 	return m.mockUpdate(ctx, di)
 }
 
+func noCreateOperation(ctx context.Context) (*sqladmin.Operation, error) {
+	return nil, nil
+}
+
 func assertUpdateReconcileStatusSuccess(t *testing.T, e error) error {
 	if e != nil {
 		t.Errorf("update() unexpected error: %v", e)
@@ -44,6 +50,42 @@ func assertUpdateReconcileStatusSuccess(t *testing.T, e error) error {
 	return e
 }
 
+var errDeletionProtected = &googleapi.Error{
+	Code:    http.StatusBadRequest,
+	Message: "The instance is protected. Please disable deletion protection and try again.",
+}
+
+func Test_isErrorDeletionProtected(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"NoError": {
+			err:  nil,
+			want: false,
+		},
+		"OtherError": {
+			err:  errTest,
+			want: false,
+		},
+		"OtherBadRequest": {
+			err:  &googleapi.Error{Code: http.StatusBadRequest, Message: "invalid tier"},
+			want: false,
+		},
+		"DeletionProtected": {
+			err:  errDeletionProtected,
+			want: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := isErrorDeletionProtected(tt.err); got != tt.want {
+				t.Errorf("isErrorDeletionProtected() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_handleNotFound(t *testing.T) {
 	tests := map[string]struct {
 		args error
@@ -114,6 +156,7 @@ func Test_instanceCreateUpdater_update(t *testing.T) {
 					localOperations: &mockLocalOperations{
 						mockUpdateInstanceStatus: func(ctx context.Context, di *sqladmin.DatabaseInstance) error { return nil },
 						mockIsInstanceReady:      func() bool { return false },
+						mockIsCreationStalled:    func(context.Context, string) (bool, error) { return false, nil },
 						mockUpdateReconcileStatus: func(ctx context.Context, e error) error {
 							return assertUpdateReconcileStatusSuccess(t, e)
 						},
@@ -127,6 +170,59 @@ func Test_instanceCreateUpdater_update(t *testing.T) {
 				res: requeueWait,
 			},
 		},
+		"InstanceIsNotReadyAndStalled": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					localOperations: &mockLocalOperations{
+						mockUpdateInstanceStatus: func(ctx context.Context, di *sqladmin.DatabaseInstance) error { return nil },
+						mockIsInstanceReady:      func() bool { return false },
+						mockIsCreationStalled:    func(context.Context, string) (bool, error) { return true, nil },
+						mockUpdateStalledStatus: func(ctx context.Context, err error) error {
+							if diff := cmp.Diff("operation op-create failed: quota exceeded", fmt.Sprint(err)); diff != "" {
+								t.Errorf("updateStalledStatus(...): -want error, +got error:\n%s", diff)
+							}
+							return errTest
+						},
+					},
+					mockGetCreateOperation: func(ctx context.Context) (*sqladmin.Operation, error) {
+						return &sqladmin.Operation{
+							Name:   "op-create",
+							Status: "RUNNING",
+							Error:  &sqladmin.OperationErrors{Errors: []*sqladmin.OperationError{{Message: "quota exceeded"}}},
+						}, nil
+					},
+				},
+			},
+			args: args{
+				inst: &sqladmin.DatabaseInstance{},
+			},
+			want: want{
+				err: errTest,
+				res: requeueWait,
+			},
+		},
+		"PreviouslyReadyInstanceInMaintenance": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					localOperations: &mockLocalOperations{
+						mockUpdateInstanceStatus: func(ctx context.Context, di *sqladmin.DatabaseInstance) error { return nil },
+						mockIsInstanceReady:      func() bool { return false },
+						mockHasBeenReady:         func() bool { return true },
+						mockIsCreationStalled: func(context.Context, string) (bool, error) {
+							t.Errorf("isCreationStalled(...): unexpected call for an instance that has been ready")
+							return true, nil
+						},
+						mockUpdateUnavailableStatus: func(ctx context.Context) error { return nil },
+					},
+				},
+			},
+			args: args{
+				inst: &sqladmin.DatabaseInstance{State: v1alpha1.StateMaintenance},
+			},
+			want: want{
+				res: requeueWait,
+			},
+		},
 		"InstanceNeedsAnUpdate": {
 			fields: fields{
 				operations: &mockManagedOperations{
@@ -134,6 +230,7 @@ func Test_instanceCreateUpdater_update(t *testing.T) {
 						mockUpdateInstanceStatus: func(ctx context.Context, di *sqladmin.DatabaseInstance) error { return nil },
 						mockIsInstanceReady:      func() bool { return true },
 						mockNeedUpdate:           func(di *sqladmin.DatabaseInstance) bool { return true },
+						mockIsDryRun:             func() bool { return false },
 						mockUpdateReconcileStatus: func(ctx context.Context, e error) error {
 							return assertUpdateReconcileStatusSuccess(t, e)
 						},
@@ -148,7 +245,78 @@ func Test_instanceCreateUpdater_update(t *testing.T) {
 				res: requeueNow,
 			},
 		},
+		"InstanceNeedsAnUpdateInvalid": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					localOperations: &mockLocalOperations{
+						mockUpdateInstanceStatus: func(ctx context.Context, di *sqladmin.DatabaseInstance) error { return nil },
+						mockIsInstanceReady:      func() bool { return true },
+						mockNeedUpdate:           func(di *sqladmin.DatabaseInstance) bool { return true },
+						mockValidate:             func() error { return errTest },
+						mockUpdateReconcileStatus: func(ctx context.Context, e error) error {
+							if diff := cmp.Diff(errTest, e, test.EquateErrors()); diff != "" {
+								t.Errorf("update() error %s", diff)
+							}
+							return nil
+						},
+					},
+					mockUpdateInstance: func(ctx context.Context) error {
+						t.Errorf("update() unexpected call to updateInstance")
+						return nil
+					},
+				},
+			},
+			args: args{
+				inst: &sqladmin.DatabaseInstance{},
+			},
+			want: want{
+				res: requeueNever,
+			},
+		},
+		"InstanceNeedsAnUpdateDryRun": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					localOperations: &mockLocalOperations{
+						mockUpdateInstanceStatus: func(ctx context.Context, di *sqladmin.DatabaseInstance) error { return nil },
+						mockIsInstanceReady:      func() bool { return true },
+						mockNeedUpdate:           func(di *sqladmin.DatabaseInstance) bool { return true },
+						mockIsDryRun:             func() bool { return true },
+						mockUpdatePlannedStatus: func(ctx context.Context, call string) error {
+							if call != "UpdateInstance" {
+								t.Errorf("update() planned call: want UpdateInstance, got %s", call)
+							}
+							return nil
+						},
+					},
+				},
+			},
+			args: args{
+				inst: &sqladmin.DatabaseInstance{},
+			},
+			want: want{
+				res: requeueSync,
+			},
+		},
 		"UpdateUserCreds": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					localOperations: &mockLocalOperations{
+						mockUpdateInstanceStatus: func(ctx context.Context, di *sqladmin.DatabaseInstance) error { return nil },
+						mockIsInstanceReady:      func() bool { return true },
+						mockNeedUpdate:           func(di *sqladmin.DatabaseInstance) bool { return false },
+						mockUpdateSyncedStatus:   func(ctx context.Context) error { return nil },
+					},
+					mockUpdateUserCreds: func(ctx context.Context) error { return nil },
+				},
+			},
+			args: args{
+				inst: &sqladmin.DatabaseInstance{},
+			},
+			want: want{
+				res: requeueSync,
+			},
+		},
+		"UpdateUserCredsFailure": {
 			fields: fields{
 				operations: &mockManagedOperations{
 					localOperations: &mockLocalOperations{
@@ -156,12 +324,32 @@ func Test_instanceCreateUpdater_update(t *testing.T) {
 						mockIsInstanceReady:      func() bool { return true },
 						mockNeedUpdate:           func(di *sqladmin.DatabaseInstance) bool { return false },
 						mockUpdateReconcileStatus: func(ctx context.Context, e error) error {
-							if e != nil {
-								t.Errorf("update() unexpectd error: %v", e)
+							if diff := cmp.Diff(errTest, e, test.EquateErrors()); diff != "" {
+								t.Errorf("update() error -want, +got: %s", diff)
 							}
 							return nil
 						},
 					},
+					mockUpdateUserCreds: func(ctx context.Context) error { return errTest },
+				},
+			},
+			args: args{
+				inst: &sqladmin.DatabaseInstance{},
+			},
+			want: want{
+				res: requeueSync,
+			},
+		},
+		"ObserveMetricsFailure": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					localOperations: &mockLocalOperations{
+						mockUpdateInstanceStatus: func(ctx context.Context, di *sqladmin.DatabaseInstance) error { return nil },
+						mockIsInstanceReady:      func() bool { return true },
+						mockNeedUpdate:           func(di *sqladmin.DatabaseInstance) bool { return false },
+						mockUpdateSyncedStatus:   func(ctx context.Context) error { return nil },
+					},
+					mockObserveMetrics:  func(ctx context.Context, di *sqladmin.DatabaseInstance) error { return errTest },
 					mockUpdateUserCreds: func(ctx context.Context) error { return nil },
 				},
 			},
@@ -203,10 +391,197 @@ func Test_instanceCreateUpdater_create(t *testing.T) {
 		args   args
 		want   want
 	}{
+		"GetCreateOperationFailure": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					mockGetCreateOperation: func(ctx context.Context) (*sqladmin.Operation, error) { return nil, errTest },
+					localOperations: &mockLocalOperations{
+						mockUpdateReconcileStatus: func(ctx context.Context, e error) error {
+							if diff := cmp.Diff(errTest, e, test.EquateErrors()); diff != "" {
+								t.Errorf("create() error %s", diff)
+							}
+							return nil
+						},
+					},
+				},
+			},
+			want: want{
+				res: requeueNow,
+			},
+		},
+		"InvalidSpec": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					mockGetCreateOperation: func(ctx context.Context) (*sqladmin.Operation, error) { return nil, nil },
+					mockCreateInstance: func(ctx context.Context) error {
+						t.Errorf("create() unexpected call to createInstance")
+						return nil
+					},
+					localOperations: &mockLocalOperations{
+						mockValidate: func() error { return errTest },
+						mockUpdateReconcileStatus: func(ctx context.Context, e error) error {
+							if diff := cmp.Diff(errTest, e, test.EquateErrors()); diff != "" {
+								t.Errorf("create() error %s", diff)
+							}
+							return nil
+						},
+					},
+				},
+			},
+			want: want{
+				res: requeueNever,
+			},
+		},
+		"CreateInProgress": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					mockGetCreateOperation: func(ctx context.Context) (*sqladmin.Operation, error) {
+						return &sqladmin.Operation{Name: "test-operation", Status: "RUNNING"}, nil
+					},
+					mockCreateInstance: func(ctx context.Context) error {
+						t.Errorf("create() unexpected call to createInstance")
+						return nil
+					},
+					localOperations: &mockLocalOperations{
+						mockUpdateReconcileStatus: func(ctx context.Context, e error) error {
+							return assertUpdateReconcileStatusSuccess(t, e)
+						},
+					},
+				},
+			},
+			want: want{
+				res: requeueWait,
+			},
+		},
+		"CreateInProgressStalled": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					mockGetCreateOperation: func(ctx context.Context) (*sqladmin.Operation, error) {
+						return &sqladmin.Operation{
+							Name:   "op-create",
+							Status: "RUNNING",
+							Error:  &sqladmin.OperationErrors{Errors: []*sqladmin.OperationError{{Message: "quota exceeded"}}},
+						}, nil
+					},
+					localOperations: &mockLocalOperations{
+						mockIsCreationStalled: func(_ context.Context, state string) (bool, error) {
+							return state == v1alpha1.StatePendingCreate, nil
+						},
+						mockUpdateStalledStatus: func(ctx context.Context, err error) error {
+							if diff := cmp.Diff("operation op-create failed: quota exceeded", fmt.Sprint(err)); diff != "" {
+								t.Errorf("updateStalledStatus(...): -want error, +got error:\n%s", diff)
+							}
+							return nil
+						},
+					},
+				},
+			},
+			want: want{
+				res: requeueWait,
+			},
+		},
+		"CreateFailed": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					mockGetCreateOperation: func(ctx context.Context) (*sqladmin.Operation, error) {
+						return &sqladmin.Operation{
+							Name:   "test-operation",
+							Status: operationDone,
+							Error:  &sqladmin.OperationErrors{Errors: []*sqladmin.OperationError{{Message: "quota exceeded"}}},
+						}, nil
+					},
+					mockCreateInstance: func(ctx context.Context) error {
+						t.Errorf("create() unexpected call to createInstance")
+						return nil
+					},
+					localOperations: &mockLocalOperations{
+						mockResetFailedCreate: func() bool { return false },
+						mockUpdateFailedStatus: func(ctx context.Context, e error) error {
+							want := errors.New("operation test-operation failed: quota exceeded")
+							if diff := cmp.Diff(want, e, test.EquateErrors()); diff != "" {
+								t.Errorf("create() error %s", diff)
+							}
+							return nil
+						},
+					},
+				},
+			},
+			want: want{
+				res: requeueSync,
+			},
+		},
+		"CreateFailedSpecChanged": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					mockGetCreateOperation: func(ctx context.Context) (*sqladmin.Operation, error) {
+						return &sqladmin.Operation{
+							Name:   "test-operation",
+							Status: operationDone,
+							Error:  &sqladmin.OperationErrors{Errors: []*sqladmin.OperationError{{Message: "quota exceeded"}}},
+						}, nil
+					},
+					localOperations: &mockLocalOperations{
+						mockResetFailedCreate: func() bool { return true },
+						mockResolveConnection: func(ctx context.Context) error { return nil },
+						mockIsDryRun:          func() bool { return false },
+						mockAddFinalizer:      func(ctx context.Context) error { return nil },
+						mockUpdateReconcileStatus: func(ctx context.Context, e error) error {
+							return assertUpdateReconcileStatusSuccess(t, e)
+						},
+					},
+					mockCreateInstance: func(ctx context.Context) error { return nil },
+				},
+			},
+			want: want{
+				res: requeueNow,
+			},
+		},
+		"ConnectionNotReady": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					mockGetCreateOperation: noCreateOperation,
+					localOperations: &mockLocalOperations{
+						mockResolveConnection: func(ctx context.Context) error { return errTest },
+						mockUpdateReconcileStatus: func(ctx context.Context, e error) error {
+							if diff := cmp.Diff(errTest, e, test.EquateErrors()); diff != "" {
+								t.Errorf("create() error %s", diff)
+							}
+							return nil
+						},
+					},
+				},
+			},
+			want: want{
+				res: requeueWait,
+			},
+		},
+		"DryRun": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					mockGetCreateOperation: noCreateOperation,
+					localOperations: &mockLocalOperations{
+						mockResolveConnection: func(ctx context.Context) error { return nil },
+						mockIsDryRun:          func() bool { return true },
+						mockUpdatePlannedStatus: func(ctx context.Context, call string) error {
+							if call != "CreateInstance" {
+								t.Errorf("create() planned call: want CreateInstance, got %s", call)
+							}
+							return nil
+						},
+					},
+				},
+			},
+			want: want{
+				res: requeueNever,
+			},
+		},
 		"AddFinalizerFailure": {
 			fields: fields{
 				operations: &mockManagedOperations{
+					mockGetCreateOperation: noCreateOperation,
 					localOperations: &mockLocalOperations{
+						mockResolveConnection: func(ctx context.Context) error { return nil },
+						mockIsDryRun:          func() bool { return false },
 						mockAddFinalizer: func(ctx context.Context) error {
 							return errTest
 						},
@@ -221,8 +596,11 @@ func Test_instanceCreateUpdater_create(t *testing.T) {
 		"CreateInstance": {
 			fields: fields{
 				operations: &mockManagedOperations{
+					mockGetCreateOperation: noCreateOperation,
 					localOperations: &mockLocalOperations{
-						mockAddFinalizer: func(ctx context.Context) error { return nil },
+						mockResolveConnection: func(ctx context.Context) error { return nil },
+						mockIsDryRun:          func() bool { return false },
+						mockAddFinalizer:      func(ctx context.Context) error { return nil },
 						mockUpdateReconcileStatus: func(ctx context.Context, e error) error {
 							return assertUpdateReconcileStatusSuccess(t, e)
 						},
@@ -234,6 +612,30 @@ func Test_instanceCreateUpdater_create(t *testing.T) {
 				res: requeueNow,
 			},
 		},
+		"CreateInstanceFailureStalled": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					mockGetCreateOperation: noCreateOperation,
+					localOperations: &mockLocalOperations{
+						mockResolveConnection: func(ctx context.Context) error { return nil },
+						mockAddFinalizer:      func(ctx context.Context) error { return nil },
+						mockIsCreationStalled: func(_ context.Context, state string) (bool, error) {
+							return state == v1alpha1.StatePendingCreate, nil
+						},
+						mockUpdateStalledStatus: func(ctx context.Context, err error) error {
+							if diff := cmp.Diff(errTest, err, test.EquateErrors()); diff != "" {
+								t.Errorf("updateStalledStatus(...): -want error, +got error:\n%s", diff)
+							}
+							return nil
+						},
+					},
+					mockCreateInstance: func(ctx context.Context) error { return errTest },
+				},
+			},
+			want: want{
+				res: requeueNow,
+			},
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -365,6 +767,110 @@ func Test_instanceSyncDeleter_delete(t *testing.T) {
 					mockDeleteInstance: func(ctx context.Context) error { return errTest },
 					localOperations: &mockLocalOperations{
 						mockIsReclaimDelete: func() bool { return true },
+						mockIsDryRun:        func() bool { return false },
+						mockUpdateReconcileStatus: func(ctx context.Context, e error) error {
+							if diff := cmp.Diff(errTest, e, test.EquateErrors()); diff != "" {
+								t.Errorf("delete() error %s", diff)
+							}
+							return nil
+						},
+					},
+				},
+				createupdater: nil,
+			},
+			want: want{
+				res: requeueNow,
+			},
+		},
+		"DeleteProtected": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					mockDeleteInstance: func(ctx context.Context) error { return errDeletionProtected },
+					localOperations: &mockLocalOperations{
+						mockIsReclaimDelete:                func() bool { return true },
+						mockIsDryRun:                       func() bool { return false },
+						mockIsDeletionProtectionOverridden: func() bool { return false },
+						mockUpdateDeletionProtectedStatus:  func(ctx context.Context) error { return nil },
+					},
+				},
+				createupdater: nil,
+			},
+			want: want{
+				res: requeueSync,
+			},
+		},
+		"DeleteProtectedOverridden": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					mockDeleteInstance:            func(ctx context.Context) error { return errDeletionProtected },
+					mockDisableDeletionProtection: func(ctx context.Context) error { return nil },
+					localOperations: &mockLocalOperations{
+						mockIsReclaimDelete:                func() bool { return true },
+						mockIsDryRun:                       func() bool { return false },
+						mockIsDeletionProtectionOverridden: func() bool { return true },
+						mockUpdateReconcileStatus: func(ctx context.Context, e error) error {
+							return assertUpdateReconcileStatusSuccess(t, e)
+						},
+					},
+				},
+				createupdater: nil,
+			},
+			want: want{
+				res: requeueWait,
+			},
+		},
+		"DeleteProtectedOverrideFailed": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					mockDeleteInstance:            func(ctx context.Context) error { return errDeletionProtected },
+					mockDisableDeletionProtection: func(ctx context.Context) error { return errTest },
+					localOperations: &mockLocalOperations{
+						mockIsReclaimDelete:                func() bool { return true },
+						mockIsDryRun:                       func() bool { return false },
+						mockIsDeletionProtectionOverridden: func() bool { return true },
+						mockUpdateReconcileStatus: func(ctx context.Context, e error) error {
+							if diff := cmp.Diff(errors.Wrap(errTest, "cannot disable deletion protection"), e, test.EquateErrors()); diff != "" {
+								t.Errorf("delete() error %s", diff)
+							}
+							return nil
+						},
+					},
+				},
+				createupdater: nil,
+			},
+			want: want{
+				res: requeueNow,
+			},
+		},
+		"FinalBackupPending": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					mockFinalBackup: func(ctx context.Context) (bool, error) { return false, nil },
+					mockDeleteInstance: func(ctx context.Context) error {
+						t.Errorf("delete() deleted the instance before its final backup completed")
+						return nil
+					},
+					localOperations: &mockLocalOperations{
+						mockIsReclaimDelete: func() bool { return true },
+						mockIsDryRun:        func() bool { return false },
+						mockUpdateReconcileStatus: func(ctx context.Context, e error) error {
+							return assertUpdateReconcileStatusSuccess(t, e)
+						},
+					},
+				},
+				createupdater: nil,
+			},
+			want: want{
+				res: requeueWait,
+			},
+		},
+		"FinalBackupError": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					mockFinalBackup: func(ctx context.Context) (bool, error) { return false, errTest },
+					localOperations: &mockLocalOperations{
+						mockIsReclaimDelete: func() bool { return true },
+						mockIsDryRun:        func() bool { return false },
 						mockUpdateReconcileStatus: func(ctx context.Context, e error) error {
 							if diff := cmp.Diff(errTest, e, test.EquateErrors()); diff != "" {
 								t.Errorf("delete() error %s", diff)
@@ -389,6 +895,7 @@ func Test_instanceSyncDeleter_delete(t *testing.T) {
 					},
 					localOperations: &mockLocalOperations{
 						mockIsReclaimDelete: func() bool { return true },
+						mockIsDryRun:        func() bool { return false },
 						mockRemoveFinalizer: func(ctx context.Context) error { return nil },
 					},
 				},
@@ -404,6 +911,22 @@ func Test_instanceSyncDeleter_delete(t *testing.T) {
 					mockDeleteInstance: func(ctx context.Context) error { return nil },
 					localOperations: &mockLocalOperations{
 						mockIsReclaimDelete: func() bool { return true },
+						mockIsDryRun:        func() bool { return false },
+						mockRemoveFinalizer: func(ctx context.Context) error { return nil },
+					},
+				},
+				createupdater: nil,
+			},
+			want: want{
+				res: requeueNow,
+			},
+		},
+		"DeleteDryRun": {
+			fields: fields{
+				operations: &mockManagedOperations{
+					localOperations: &mockLocalOperations{
+						mockIsReclaimDelete: func() bool { return true },
+						mockIsDryRun:        func() bool { return true },
 						mockRemoveFinalizer: func(ctx context.Context) error { return nil },
 					},
 				},
@@ -513,7 +1036,7 @@ func Test_operationsFactory_makeManagedOperations(t *testing.T) {
 				inst: mockInstance,
 			},
 			want: want{
-				err: errTest,
+				err: errors.Wrapf(errTest, "cannot get provider %s", types.NamespacedName{}),
 				ops: nil,
 			},
 		},
@@ -540,7 +1063,7 @@ func Test_operationsFactory_makeManagedOperations(t *testing.T) {
 				inst: mockInstance,
 			},
 			want: want{
-				err: errors.Wrapf(errTest, "cannot get provider's secret default/test-provider-secret"),
+				err: errors.Wrapf(errTest, "cannot get provider secret default/test-provider-secret"),
 				ops: nil,
 			},
 		},
@@ -564,7 +1087,7 @@ func Test_operationsFactory_makeManagedOperations(t *testing.T) {
 			},
 			want: want{
 				err: errors.Wrapf(errors.New("unexpected end of JSON input"),
-					"cannot retrieve creds from json"),
+					"cannot parse credentials in provider secret default/test-provider-secret"),
 			},
 		},
 	}
@@ -736,3 +1259,149 @@ func TestReconciler_Reconcile(t *testing.T) {
 		})
 	}
 }
+
+func TestReconciler_nextSync(t *testing.T) {
+	now := time.Now()
+	synced := meta.NewTime(now.Add(-time.Minute))
+	stale := meta.NewTime(now.Add(-2 * requeueAfterSuccess))
+	deleted := meta.NewTime(now)
+
+	instance := func(generation, observed int64, lastSync *meta.Time) *v1alpha1.CloudsqlInstance {
+		i := &v1alpha1.CloudsqlInstance{ObjectMeta: meta.ObjectMeta{Namespace: testNs, Name: testName, Generation: generation}}
+		i.Status.ObservedGeneration = observed
+		i.Status.LastSyncTime = lastSync
+		return i
+	}
+	secretExists := &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+		if _, ok := obj.(*core.Secret); !ok {
+			t.Errorf("nextSync() unexpected object type: %T", obj)
+		}
+		return nil
+	}}
+
+	type want struct {
+		wait time.Duration
+		skip bool
+	}
+	tests := map[string]struct {
+		kube client.Client
+		inst *v1alpha1.CloudsqlInstance
+		want want
+	}{
+		"NeverSynced": {
+			kube: secretExists,
+			inst: instance(1, 0, nil),
+			want: want{},
+		},
+		"GenerationChanged": {
+			kube: secretExists,
+			inst: instance(2, 1, &synced),
+			want: want{},
+		},
+		"PollIntervalPassed": {
+			kube: secretExists,
+			inst: instance(1, 1, &stale),
+			want: want{},
+		},
+		"Deleted": {
+			kube: secretExists,
+			inst: func() *v1alpha1.CloudsqlInstance {
+				i := instance(1, 1, &synced)
+				i.SetDeletionTimestamp(&deleted)
+				return i
+			}(),
+			want: want{},
+		},
+		"SecretMissing": {
+			kube: &test.MockClient{MockGet: func(context.Context, client.ObjectKey, runtime.Object) error {
+				return kerrors.NewNotFound(schema.GroupResource{}, testName)
+			}},
+			inst: instance(1, 1, &synced),
+			want: want{},
+		},
+		"ActionRequested": {
+			kube: secretExists,
+			inst: func() *v1alpha1.CloudsqlInstance {
+				i := instance(1, 1, &synced)
+				i.SetAnnotations(map[string]string{AnnotationOperation: ActionRestart})
+				return i
+			}(),
+			want: want{},
+		},
+		"ActionRunning": {
+			kube: secretExists,
+			inst: func() *v1alpha1.CloudsqlInstance {
+				i := instance(1, 1, &synced)
+				i.Status.ActionOperation = "restart-operation"
+				return i
+			}(),
+			want: want{},
+		},
+		"DeletionProtectionOverridden": {
+			kube: secretExists,
+			inst: func() *v1alpha1.CloudsqlInstance {
+				i := instance(1, 1, &synced)
+				i.SetDeletionTimestamp(&deleted)
+				i.SetAnnotations(map[string]string{AnnotationOverrideDeletionProtection: "true"})
+				return i
+			}(),
+			want: want{},
+		},
+		"UpToDate": {
+			kube: secretExists,
+			inst: instance(1, 1, &synced),
+			want: want{wait: requeueAfterSuccess - time.Minute, skip: true},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := &Reconciler{client: tt.kube}
+			wait, skip := r.nextSync(context.Background(), tt.inst, now)
+			if diff := cmp.Diff(tt.want, want{wait: wait, skip: skip}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("nextSync() -want, +got: %s", diff)
+			}
+		})
+	}
+}
+
+func TestReconciler_newContext(t *testing.T) {
+	stopped, stop := context.WithCancel(context.Background())
+	stop()
+
+	tests := map[string]struct {
+		r        *Reconciler
+		want     time.Duration
+		wantDone bool
+	}{
+		"Default": {
+			r:    &Reconciler{},
+			want: DefaultReconcileTimeout,
+		},
+		"Configured": {
+			r:    &Reconciler{timeout: 10 * time.Second},
+			want: 10 * time.Second,
+		},
+		"ManagerStopped": {
+			r:        &Reconciler{ctx: stopped},
+			want:     DefaultReconcileTimeout,
+			wantDone: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := tt.r.newContext()
+			defer cancel()
+
+			d, ok := ctx.Deadline()
+			if !ok {
+				t.Fatalf("newContext() has no deadline")
+			}
+			if got := time.Until(d); got > tt.want {
+				t.Errorf("newContext() deadline: want at most %s, got %s", tt.want, got)
+			}
+			if got := ctx.Err() != nil; got != tt.wantDone {
+				t.Errorf("newContext() done: want %t, got %t", tt.wantDone, got)
+			}
+		})
+	}
+}
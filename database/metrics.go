@@ -0,0 +1,118 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	monitoring "google.golang.org/api/monitoring/v3"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+)
+
+const (
+	// metricsInterval is how often the metrics of an instance are read. An
+	// instance is reconciled whenever its status changes, so without this
+	// limit metrics would be read far more often than they change.
+	metricsInterval = 1 * time.Minute
+
+	// metricsWindow is how far back to look for the latest point of each
+	// metric. Cloud SQL samples its metrics every minute.
+	metricsWindow = 5 * time.Minute
+)
+
+// The Cloud Monitoring metrics that are recorded in instance status.
+const (
+	metricDiskBytesUsed = "cloudsql.googleapis.com/database/disk/bytes_used"
+	metricDiskQuota     = "cloudsql.googleapis.com/database/disk/quota"
+	metricConnections   = "cloudsql.googleapis.com/database/network/connections"
+)
+
+// A metricsReader reads the latest metrics of a Cloud SQL instance.
+type metricsReader interface {
+	read(ctx context.Context, project, instance string, now time.Time) (*v1alpha1.CloudsqlInstanceMetrics, error)
+}
+
+// A monitoringReader reads the metrics of Cloud SQL instances from the Cloud
+// Monitoring API.
+type monitoringReader struct {
+	service *monitoring.Service
+}
+
+func (r *monitoringReader) read(ctx context.Context, project, instance string, now time.Time) (*v1alpha1.CloudsqlInstanceMetrics, error) {
+	m := &v1alpha1.CloudsqlInstanceMetrics{}
+	for metric, v := range map[string]*int64{
+		metricDiskBytesUsed: &m.DiskBytesUsed,
+		metricDiskQuota:     &m.DiskQuotaBytes,
+		metricConnections:   &m.Connections,
+	} {
+		rsp, err := r.service.Projects.TimeSeries.List("projects/" + project).
+			Filter(fmt.Sprintf("metric.type=%q AND resource.labels.database_id=%q", metric, project+":"+instance)).
+			IntervalStartTime(now.Add(-metricsWindow).Format(time.RFC3339)).
+			IntervalEndTime(now.Format(time.RFC3339)).
+			Context(ctx).
+			Do()
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot list time series of metric %s", metric)
+		}
+		*v = latestValue(rsp.TimeSeries)
+	}
+	return m, nil
+}
+
+// latestValue returns the sum of the latest point of each of the supplied
+// time series. Cloud Monitoring returns the points of a series newest first.
+func latestValue(series []*monitoring.TimeSeries) int64 {
+	var sum int64
+	for _, s := range series {
+		if len(s.Points) == 0 || s.Points[0].Value == nil || s.Points[0].Value.Int64Value == nil {
+			continue
+		}
+		sum += *s.Points[0].Value.Int64Value
+	}
+	return sum
+}
+
+// observeMetrics records the disk usage, connection count, and machine tier of
+// the supplied instance in the status of its CloudsqlInstance, and warns if
+// its disk is nearly full or was auto-resized. Metrics that were recorded less
+// than metricsInterval ago are not read again.
+func (h *managedHandler) observeMetrics(ctx context.Context, inst *sqladmin.DatabaseInstance) error {
+	now := time.Now()
+	if h.metrics == nil || (h.Status.Metrics != nil && now.Sub(h.Status.Metrics.ObservedAt.Time) < metricsInterval) {
+		return nil
+	}
+
+	ctx, cancel := h.withCallTimeout(ctx)
+	defer cancel()
+	m, err := h.metrics.read(ctx, inst.Project, inst.Name, now)
+	if err != nil {
+		return errors.Wrap(err, "cannot read instance metrics")
+	}
+	if inst.Settings != nil {
+		m.Tier = inst.Settings.Tier
+	}
+	m.ObservedAt = metav1.NewTime(now)
+	h.observeStorage(inst, h.Status.Metrics, m)
+	h.Status.Metrics = m
+	return nil
+}
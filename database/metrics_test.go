@@ -0,0 +1,130 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	monitoring "google.golang.org/api/monitoring/v3"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+type mockMetricsReader struct {
+	mockRead func(ctx context.Context, project, instance string, now time.Time) (*v1alpha1.CloudsqlInstanceMetrics, error)
+}
+
+func (m *mockMetricsReader) read(ctx context.Context, project, instance string, now time.Time) (*v1alpha1.CloudsqlInstanceMetrics, error) {
+	return m.mockRead(ctx, project, instance, now)
+}
+
+func point(v int64) *monitoring.Point {
+	return &monitoring.Point{Value: &monitoring.TypedValue{Int64Value: &v}}
+}
+
+func TestLatestValue(t *testing.T) {
+	cases := map[string]struct {
+		series []*monitoring.TimeSeries
+		want   int64
+	}{
+		"NoSeries": {},
+		"LatestPoint": {
+			series: []*monitoring.TimeSeries{{Points: []*monitoring.Point{point(3), point(2)}}},
+			want:   3,
+		},
+		"SumOfSeries": {
+			series: []*monitoring.TimeSeries{
+				{Points: []*monitoring.Point{point(3), point(2)}},
+				{Points: []*monitoring.Point{point(4)}},
+				{Points: []*monitoring.Point{}},
+				{Points: []*monitoring.Point{{Value: &monitoring.TypedValue{}}}},
+			},
+			want: 7,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := latestValue(tc.series); got != tc.want {
+				t.Errorf("latestValue(...): want %d, got %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestObserveMetrics(t *testing.T) {
+	errBoom := errors.New("boom")
+	recent := metav1.NewTime(time.Now())
+	inst := &sqladmin.DatabaseInstance{Project: "cool-project", Name: "cool-instance", Settings: &sqladmin.Settings{Tier: "db-n1-standard-1"}}
+
+	cases := map[string]struct {
+		metrics metricsReader
+		current *v1alpha1.CloudsqlInstanceMetrics
+		want    *v1alpha1.CloudsqlInstanceMetrics
+		wantErr error
+	}{
+		"NoReader": {},
+		"RecentlyObserved": {
+			metrics: &mockMetricsReader{mockRead: func(context.Context, string, string, time.Time) (*v1alpha1.CloudsqlInstanceMetrics, error) {
+				t.Errorf("read(...): unexpected call")
+				return nil, nil
+			}},
+			current: &v1alpha1.CloudsqlInstanceMetrics{Connections: 1, ObservedAt: recent},
+			want:    &v1alpha1.CloudsqlInstanceMetrics{Connections: 1, ObservedAt: recent},
+		},
+		"Observed": {
+			metrics: &mockMetricsReader{mockRead: func(_ context.Context, project, instance string, _ time.Time) (*v1alpha1.CloudsqlInstanceMetrics, error) {
+				if project != "cool-project" || instance != "cool-instance" {
+					t.Errorf("read(...): want cool-project/cool-instance, got %s/%s", project, instance)
+				}
+				return &v1alpha1.CloudsqlInstanceMetrics{DiskBytesUsed: 10, DiskQuotaBytes: 100, Connections: 2}, nil
+			}},
+			current: &v1alpha1.CloudsqlInstanceMetrics{Connections: 1, ObservedAt: metav1.NewTime(time.Now().Add(-2 * metricsInterval))},
+			want:    &v1alpha1.CloudsqlInstanceMetrics{DiskBytesUsed: 10, DiskQuotaBytes: 100, Connections: 2, Tier: "db-n1-standard-1"},
+		},
+		"ReadFailure": {
+			metrics: &mockMetricsReader{mockRead: func(context.Context, string, string, time.Time) (*v1alpha1.CloudsqlInstanceMetrics, error) {
+				return nil, errBoom
+			}},
+			wantErr: errors.Wrap(errBoom, "cannot read instance metrics"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			i := &v1alpha1.CloudsqlInstance{}
+			i.Status.Metrics = tc.current
+			h := &managedHandler{CloudsqlInstance: i, metrics: tc.metrics}
+
+			err := h.observeMetrics(context.Background(), inst)
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("observeMetrics(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want, i.Status.Metrics, cmpopts.IgnoreFields(v1alpha1.CloudsqlInstanceMetrics{}, "ObservedAt")); diff != "" {
+				t.Errorf("observeMetrics(...): -want metrics, +got metrics:\n%s", diff)
+			}
+		})
+	}
+}
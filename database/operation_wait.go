@@ -0,0 +1,109 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+)
+
+const (
+	// operationPollInitial is how long to wait before first polling an
+	// operation. Each subsequent wait doubles, up to operationPollMax.
+	operationPollInitial = 1 * time.Second
+	operationPollMax     = 8 * time.Second
+
+	// operationWaitTimeout bounds the time spent waiting for an operation
+	// within a single reconcile. Operations that take longer, such as the
+	// creation of an instance, are polled again at the next reconcile.
+	operationWaitTimeout = 15 * time.Second
+)
+
+// An operationWaiter waits for Cloud SQL operations to complete.
+type operationWaiter interface {
+	// WaitForOperation returns the supplied operation once it is done, or as
+	// last observed if it is still running when its deadline passes. It does
+	// not report whether a done operation failed; see operationError.
+	WaitForOperation(ctx context.Context, op *sqladmin.Operation) (*sqladmin.Operation, error)
+}
+
+// An operationGetter gets Cloud SQL operations by name.
+type operationGetter interface {
+	GetOperation(ctx context.Context, name string) (*sqladmin.Operation, error)
+}
+
+// An operationPoller is an operationWaiter that polls operations with
+// exponential backoff.
+type operationPoller struct {
+	operations operationGetter
+
+	initial time.Duration
+	max     time.Duration
+	timeout time.Duration
+
+	// after is time.After, except in tests.
+	after func(time.Duration) <-chan time.Time
+}
+
+// newOperationPoller returns an operationPoller that polls operations using
+// the supplied getter.
+func newOperationPoller(g operationGetter) *operationPoller {
+	return &operationPoller{
+		operations: g,
+		initial:    operationPollInitial,
+		max:        operationPollMax,
+		timeout:    operationWaitTimeout,
+		after:      time.After,
+	}
+}
+
+// WaitForOperation polls the supplied operation until it is done or the
+// poller's deadline passes, whichever is first.
+func (p *operationPoller) WaitForOperation(ctx context.Context, op *sqladmin.Operation) (*sqladmin.Operation, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	for interval := p.initial; op.Status != operationDone; interval = backoff(interval, p.max) {
+		select {
+		case <-ctx.Done():
+			return op, nil
+		case <-p.after(interval):
+		}
+
+		got, err := p.operations.GetOperation(ctx, op.Name)
+		if ctx.Err() != nil {
+			// The deadline passed while we were polling.
+			return op, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get operation %s", op.Name)
+		}
+		op = got
+	}
+	return op, nil
+}
+
+// backoff returns double the supplied interval, capped at max.
+func backoff(interval, max time.Duration) time.Duration {
+	if interval *= 2; interval > max {
+		return max
+	}
+	return interval
+}
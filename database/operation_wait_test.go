@@ -0,0 +1,121 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/cloudsql/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func Test_operationPoller_WaitForOperation(t *testing.T) {
+	running := &sqladmin.Operation{Name: "test-operation", Status: "RUNNING"}
+	done := &sqladmin.Operation{Name: "test-operation", Status: operationDone}
+
+	// afterPolls returns a MockGetOperation that reports the operation done
+	// after the supplied number of polls.
+	afterPolls := func(n int) func(context.Context, string) (*sqladmin.Operation, error) {
+		polls := 0
+		return func(_ context.Context, _ string) (*sqladmin.Operation, error) {
+			if polls++; polls < n {
+				return running, nil
+			}
+			return done, nil
+		}
+	}
+
+	type want struct {
+		op        *sqladmin.Operation
+		err       error
+		intervals []time.Duration
+	}
+	tests := map[string]struct {
+		ctx        context.Context
+		op         *sqladmin.Operation
+		operations operationGetter
+		want       want
+	}{
+		"AlreadyDone": {
+			ctx:        context.Background(),
+			op:         done,
+			operations: &fake.MockInstanceClient{},
+			want:       want{op: done},
+		},
+		"DoneAfterBackoff": {
+			ctx:        context.Background(),
+			op:         running,
+			operations: &fake.MockInstanceClient{MockGetOperation: afterPolls(5)},
+			want: want{
+				op:        done,
+				intervals: []time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second},
+			},
+		},
+		"DeadlinePassed": {
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			}(),
+			op:         running,
+			operations: &fake.MockInstanceClient{},
+			want:       want{op: running, intervals: []time.Duration{1 * time.Second}},
+		},
+		"GetOperationFailed": {
+			ctx: context.Background(),
+			op:  running,
+			operations: &fake.MockInstanceClient{
+				MockGetOperation: func(_ context.Context, _ string) (*sqladmin.Operation, error) { return nil, errTest },
+			},
+			want: want{
+				err:       errors.Wrap(errTest, "cannot get operation test-operation"),
+				intervals: []time.Duration{1 * time.Second},
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var intervals []time.Duration
+			p := newOperationPoller(tt.operations)
+			p.after = func(d time.Duration) <-chan time.Time {
+				intervals = append(intervals, d)
+				ch := make(chan time.Time)
+				if tt.ctx.Err() == nil {
+					close(ch)
+				}
+				return ch
+			}
+
+			op, err := p.WaitForOperation(tt.ctx, tt.op)
+			if diff := cmp.Diff(tt.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("WaitForOperation() error -want, +got: %s", diff)
+			}
+			if diff := cmp.Diff(tt.want.op, op); diff != "" {
+				t.Errorf("WaitForOperation() -want, +got: %s", diff)
+			}
+			if diff := cmp.Diff(tt.want.intervals, intervals); diff != "" {
+				t.Errorf("WaitForOperation() intervals -want, +got: %s", diff)
+			}
+		})
+	}
+}
@@ -20,40 +20,75 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/pkg/errors"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/googleapi"
+	monitoring "google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
 	sqladmin "google.golang.org/api/sqladmin/v1beta4"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
 	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
 	"github.com/crossplaneio/crossplane/pkg/clients/gcp/cloudsql"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/deadline"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/externalname"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/plan"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/secretgc"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/servicenetworking"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/tracing"
 	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/resource"
 	"github.com/crossplaneio/crossplane/pkg/util"
+	utilgoogleapi "github.com/crossplaneio/crossplane/pkg/util/googleapi"
 )
 
 type localOperations interface {
 	// Bucket object managedOperations
 	addFinalizer(context.Context) error
 	isReclaimDelete() bool
+	isDeletionProtectionOverridden() bool
 	isInstanceReady() bool
+	hasBeenReady() bool
+	isCreationStalled(ctx context.Context, state string) (bool, error)
+	forgetCreation(context.Context) error
+	isDryRun() bool
+	resetFailedCreate() bool
 	needsUpdate(*sqladmin.DatabaseInstance) bool
+	validate() error
 	removeFinalizer(context.Context) error
+	resolveConnection(context.Context) error
+	finalBackupURI(ctx context.Context, database string) (string, error)
+	serviceAccountBuckets(context.Context) ([]string, error)
+	reconcileConnectionPooler(context.Context) error
+	deleteConnectionPooler(context.Context) error
 
 	// Controller-runtime managedOperations
 	updateObject(ctx context.Context) error
 	updateInstanceStatus(context.Context, *sqladmin.DatabaseInstance) error
 	updateReconcileStatus(context.Context, error) error
+	updateSyncedStatus(context.Context) error
+	updateStalledStatus(context.Context, error) error
+	updateUnavailableStatus(context.Context) error
+	updateDeletionProtectedStatus(context.Context) error
+	updateFailedStatus(context.Context, error) error
+	updatePlannedStatus(ctx context.Context, call string) error
 	updateConnectionSecret(ctx context.Context) (*corev1.Secret, error)
 }
 
 type localHandler struct {
 	*v1alpha1.CloudsqlInstance
-	client client.Client
+	client   client.Client
+	recorder record.EventRecorder
+	pooler   PoolerConfig
 }
 
 var _ localOperations = &localHandler{}
@@ -68,8 +103,13 @@ func newLocalHandler(instance *v1alpha1.CloudsqlInstance, kube client.Client) *l
 //
 // Crossplane GCP Bucket object managedOperations
 //
+
+// addFinalizer adds the finalizer and persists the name of the instance. It
+// is called before the instance is created, so that the instance is never
+// created under a name that may be forgotten.
 func (h *localHandler) addFinalizer(ctx context.Context) error {
 	meta.AddFinalizer(h, finalizer)
+	externalname.Set(h, instanceName(h.CloudsqlInstance))
 	return h.updateObject(ctx)
 }
 
@@ -78,21 +118,128 @@ func (h *localHandler) removeFinalizer(ctx context.Context) error {
 	return h.updateObject(ctx)
 }
 
+// resolveConnection configures the instance to use the private network of its
+// referenced service networking connection, if any. It returns an error if the
+// connection is not yet available.
+func (h *localHandler) resolveConnection(ctx context.Context) error {
+	if h.Spec.ConnectionReference == nil {
+		return nil
+	}
+	c, err := servicenetworking.ResolveConnection(ctx, h.client, h.Spec.ConnectionReference)
+	if err != nil {
+		return err
+	}
+	h.Spec.PrivateNetwork = c.Status.Network
+	return nil
+}
+
+// validate returns an error if the instance's spec is invalid for its
+// database engine and version.
+func (h *localHandler) validate() error {
+	return invalidSpec(validateSpec(h.CloudsqlInstance))
+}
+
 func (h *localHandler) isInstanceReady() bool {
 	return h.IsRunnable()
 }
 
+// hasBeenReady returns true if the instance has been ready since it was
+// created, in which case its creation deadline no longer applies.
+func (h *localHandler) hasBeenReady() bool {
+	return h.Status.Phase == v1alpha1.PhaseRunning || h.Status.LastSyncTime != nil
+}
+
+// isCreationStalled returns true if the instance has been in the supplied not
+// ready state for longer than its creation deadline, and has not already been
+// marked as stalled. The state is recorded when first observed.
+func (h *localHandler) isCreationStalled(ctx context.Context, state string) (bool, error) {
+	now := time.Now()
+	since, observed := deadline.Observe(h, state, now)
+	if observed {
+		if err := h.updateObject(ctx); err != nil {
+			return false, err
+		}
+	}
+	return deadline.ExceededSince(h, since, now) && !deadline.IsStalled(h.Status.ConditionedStatus), nil
+}
+
+// forgetCreation forgets the not ready state recorded by isCreationStalled,
+// once the instance is ready.
+func (h *localHandler) forgetCreation(ctx context.Context) error {
+	if !deadline.Forget(h) {
+		return nil
+	}
+	return h.updateObject(ctx)
+}
+
+func (h *localHandler) isDryRun() bool {
+	return plan.IsDryRun(h)
+}
+
+// resetFailedCreate forgets the failed operation that created the instance if
+// its spec has changed since the failure was recorded, so that creation may be
+// retried. It returns true if the operation was forgotten.
+func (h *localHandler) resetFailedCreate() bool {
+	if h.Status.Phase != v1alpha1.PhaseFailed || h.Status.ObservedGeneration == h.GetGeneration() {
+		return false
+	}
+	h.Status.Operation = ""
+	h.Status.Phase = v1alpha1.PhasePending
+	return true
+}
+
 func (h *localHandler) isReclaimDelete() bool {
 	return h.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete
 }
 
+func (h *localHandler) isDeletionProtectionOverridden() bool {
+	return h.GetAnnotations()[AnnotationOverrideDeletionProtection] == "true"
+}
+
+// needsUpdate returns true if the actual instance differs from our desired
+// instance. Fields we don't set are defaulted or output only, and are ignored.
 func (h *localHandler) needsUpdate(actual *sqladmin.DatabaseInstance) bool {
-	// TODO: update functionality is not supported for this instance.
-	//   In order to add this support we need to refactor DatabaseInstanceType
-	return false
+	desired := desiredInstance(h.CloudsqlInstance)
+
+	// Authorized networks may be removed entirely, and may be returned in any
+	// order, so we compare them separately.
+	if h.Spec.AuthorizedNetworks != nil {
+		var networks []*sqladmin.AclEntry
+		if actual.Settings != nil && actual.Settings.IpConfiguration != nil {
+			networks = actual.Settings.IpConfiguration.AuthorizedNetworks
+		}
+		if !authorizedNetworksEqual(desired.Settings.IpConfiguration.AuthorizedNetworks, networks) {
+			return true
+		}
+		desired.Settings.IpConfiguration.AuthorizedNetworks = nil
+	}
+
+	// Cloud SQL may return database flags in any order.
+	if len(desired.Settings.DatabaseFlags) > 0 {
+		var flags []*sqladmin.DatabaseFlags
+		if actual.Settings != nil {
+			flags = actual.Settings.DatabaseFlags
+		}
+		if !flagsEqual(desired.Settings.DatabaseFlags, flags) {
+			return true
+		}
+		desired.Settings.DatabaseFlags = nil
+	}
 
-	// TODO: when we ready to support update, delete 4 lines above and uncomment the lines below
-	//  consider using cmp.Equal to determine whether an update is required
+	if !compare.Equal(desired, actual, compare.IgnoreUnset()) {
+		return true
+	}
+
+	// Insights may be explicitly disabled, so we must compare unset fields.
+	// False values are sent explicitly but omitted by the API, so we compare
+	// them as though they were omitted.
+	var insights *sqladmin.InsightsConfig
+	if actual.Settings != nil {
+		insights = actual.Settings.InsightsConfig
+	}
+	want := *desired.Settings.InsightsConfig
+	want.ForceSendFields = nil
+	return !compare.Equal(&want, insights)
 }
 
 func (h *localHandler) updateObject(ctx context.Context) error {
@@ -100,19 +247,88 @@ func (h *localHandler) updateObject(ctx context.Context) error {
 }
 
 func (h *localHandler) updateInstanceStatus(ctx context.Context, inst *sqladmin.DatabaseInstance) error {
-	h.SetStatus(inst)
+	observe(h.CloudsqlInstance, inst)
 	return h.client.Status().Update(ctx, h.CloudsqlInstance)
 }
 
 func (h *localHandler) updateReconcileStatus(ctx context.Context, err error) error {
+	if h.Status.Phase == "" {
+		h.Status.Phase = v1alpha1.PhasePending
+	}
 	if err == nil {
 		h.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	} else if c, ok := failureCondition(err); ok {
+		h.Status.SetConditions(c)
 	} else {
 		h.Status.SetConditions(corev1alpha1.ReconcileError(err))
 	}
 	return h.client.Status().Update(ctx, h.CloudsqlInstance)
 }
 
+// updateSyncedStatus records that the instance was successfully synced with
+// GCP at its current generation, including the base tier it was synced with.
+func (h *localHandler) updateSyncedStatus(ctx context.Context) error {
+	now := metav1.Now()
+	h.Status.ObservedGeneration = h.GetGeneration()
+	h.Status.LastSyncTime = &now
+	h.Status.BaseTier = baseTier(h.CloudsqlInstance)
+	if plan.IsDryRun(h) || plan.IsPlanned(h.Status.ConditionedStatus) {
+		h.Status.SetConditions(plan.NothingPlanned())
+	}
+	return h.updateReconcileStatus(ctx, nil)
+}
+
+// updateStalledStatus records that the instance has been creating for longer
+// than its creation deadline. The supplied error, if any, is the error most
+// recently reported by the operation creating the instance.
+func (h *localHandler) updateStalledStatus(ctx context.Context, err error) error {
+	d := deadline.CreationDeadline(h)
+	msg := fmt.Sprintf("instance %s is %s", instanceName(h.CloudsqlInstance), h.Status.State)
+	if h.Status.State == "" {
+		msg = fmt.Sprintf("instance %s has not been created", instanceName(h.CloudsqlInstance))
+	}
+	if err != nil {
+		msg = fmt.Sprintf("%s: %s", msg, err)
+	}
+	h.Status.SetConditions(deadline.Stalled(d, msg))
+	h.recorder.Eventf(h.CloudsqlInstance, corev1.EventTypeWarning, deadline.EventReasonStalled, "%s after %s", msg, d)
+	return h.client.Status().Update(ctx, h.CloudsqlInstance)
+}
+
+// updateUnavailableStatus records that an instance that has been ready is no
+// longer ready, for example because it is undergoing maintenance.
+func (h *localHandler) updateUnavailableStatus(ctx context.Context) error {
+	h.Status.SetConditions(instanceUnavailable(instanceName(h.CloudsqlInstance), h.Status.State))
+	return h.client.Status().Update(ctx, h.CloudsqlInstance)
+}
+
+func (h *localHandler) updateDeletionProtectedStatus(ctx context.Context) error {
+	h.Status.SetConditions(deletionProtected(instanceName(h.CloudsqlInstance)))
+	return h.client.Status().Update(ctx, h.CloudsqlInstance)
+}
+
+// updateFailedStatus records that the instance failed to be created for the
+// supplied reason, at the current generation of its spec.
+func (h *localHandler) updateFailedStatus(ctx context.Context, err error) error {
+	h.Status.Phase = v1alpha1.PhaseFailed
+	h.Status.ObservedGeneration = h.GetGeneration()
+	if c, ok := failureCondition(err); ok {
+		h.Status.SetConditions(corev1alpha1.Unavailable(), c)
+		return h.client.Status().Update(ctx, h.CloudsqlInstance)
+	}
+	h.Status.SetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(err))
+	return h.client.Status().Update(ctx, h.CloudsqlInstance)
+}
+
+// updatePlannedStatus records the supplied instance call, which would have been
+// made were the instance not in dry-run mode.
+func (h *localHandler) updatePlannedStatus(ctx context.Context, call string) error {
+	desc := plan.Describe(call, desiredInstance(h.CloudsqlInstance))
+	h.Status.SetConditions(plan.Planned(desc), corev1alpha1.ReconcileSuccess())
+	h.recorder.Event(h.CloudsqlInstance, corev1.EventTypeNormal, plan.EventReasonPlanned, desc)
+	return h.client.Status().Update(ctx, h.CloudsqlInstance)
+}
+
 func (h *localHandler) getConnectionSecret(ctx context.Context) (*corev1.Secret, error) {
 	key := types.NamespacedName{
 		Name:      h.ConnectionSecret().Name,
@@ -122,7 +338,10 @@ func (h *localHandler) getConnectionSecret(ctx context.Context) (*corev1.Secret,
 	return s, h.client.Get(ctx, key, s)
 }
 
-func (h *localHandler) updateConnectionSecret(ctx context.Context) (*corev1.Secret, error) {
+func (h *localHandler) updateConnectionSecret(ctx context.Context) (s *corev1.Secret, err error) {
+	ctx, span := tracing.StartPhase(ctx, tracing.PhasePublishSecret)
+	defer func() { tracing.End(span, err) }()
+
 	secret := h.ConnectionSecret()
 
 	password, err := util.GeneratePassword(v1alpha1.PasswordLength)
@@ -130,18 +349,23 @@ func (h *localHandler) updateConnectionSecret(ctx context.Context) (*corev1.Secr
 		return nil, errors.Wrapf(err, "failed to generate password")
 	}
 
-	s := secret.DeepCopy()
+	s = secret.DeepCopy()
 	if err := util.CreateOrUpdate(ctx, h.client, s, func() error {
 		if !meta.HaveSameController(s, secret) {
 			return errors.Errorf("connection secret %s/%s exists and is not controlled by %s/%s",
 				s.GetNamespace(), s.GetName(), h.GetNamespace(), h.GetName())
 		}
+		secretgc.Mark(s, h.CloudsqlInstance, v1alpha1.CloudsqlInstanceGroupVersionKind)
 
 		if _, found := s.Data[corev1alpha1.ResourceCredentialsSecretPasswordKey]; !found {
 			s.Data[corev1alpha1.ResourceCredentialsSecretPasswordKey] = []byte(password)
 		}
 		s.Data[corev1alpha1.ResourceCredentialsSecretEndpointKey] = secret.Data[corev1alpha1.ResourceCredentialsSecretEndpointKey]
 		s.Data[corev1alpha1.ResourceCredentialsSecretUserKey] = secret.Data[corev1alpha1.ResourceCredentialsSecretUserKey]
+		if isEngine(h.Spec.DatabaseVersion, sqlServerDBVersionPrefix) {
+			s.Data[corev1alpha1.ResourceCredentialsSecretUserKey] = []byte(sqlServerUserName)
+			s.Data[ConnectionSecretPortKey] = []byte(sqlServerPort)
+		}
 		return nil
 	}); err != nil {
 		return nil, err
@@ -153,12 +377,20 @@ type managedOperations interface {
 	localOperations
 	// DatabaseInstance managedOperations
 	getInstance(ctx context.Context) (*sqladmin.DatabaseInstance, error)
+	getCreateOperation(ctx context.Context) (*sqladmin.Operation, error)
 	createInstance(ctx context.Context) error
 	updateInstance(ctx context.Context) error
 	deleteInstance(ctx context.Context) error
+	disableDeletionProtection(ctx context.Context) error
+	finalBackup(ctx context.Context) (bool, error)
+	observeMetrics(ctx context.Context, inst *sqladmin.DatabaseInstance) error
+	runAction(ctx context.Context, inst *sqladmin.DatabaseInstance) (bool, error)
 
 	// DatabaseUser managedOperations
 	updateUserCreds(ctx context.Context) error
+
+	// IAM managedOperations
+	grantServiceAccountAccess(ctx context.Context) error
 }
 
 type managedHandler struct {
@@ -166,16 +398,52 @@ type managedHandler struct {
 	localOperations
 	instance cloudsql.InstanceService
 	user     cloudsql.UserService
+	metrics  metricsReader
+
+	// databases lists the instance's databases, so that each can be exported
+	// by its final backup.
+	databases databaseLister
+
+	// kms and buckets grant the instance's service account access to KMS
+	// keys and buckets. They are nil unless the instance needs such access.
+	kms     granter
+	buckets granter
+
+	// callTimeout bounds each Cloud SQL API call. DefaultAPICallTimeout is
+	// used if it is zero.
+	callTimeout time.Duration
+
+	// enableService enables the GCP service that an error reports is
+	// disabled, returning true if it did so. It may be nil.
+	enableService func(context.Context, error) (bool, error)
+
+	// operations waits for running operations to complete. Operations are
+	// observed once per reconcile if it is nil.
+	operations operationWaiter
+
+	// recorder records storage warnings as events. It may be nil.
+	recorder record.EventRecorder
 }
 
 var _ managedOperations = &managedHandler{}
 
-func newManagedHandler(ctx context.Context, inst *v1alpha1.CloudsqlInstance, tops localOperations, creds *google.Credentials) (*managedHandler, error) {
-	instClient, err := cloudsql.NewInstanceClient(ctx, creds)
+// newManagedHandler returns a managedHandler whose Cloud SQL clients are
+// authenticated using the supplied credentials. Any supplied client options,
+// for example a fake API endpoint, are passed to the clients.
+func newManagedHandler(ctx context.Context, inst *v1alpha1.CloudsqlInstance, tops localOperations, creds *google.Credentials, opts ...option.ClientOption) (*managedHandler, error) {
+	instClient, err := cloudsql.NewInstanceClient(ctx, creds, opts...)
+	if err != nil {
+		return nil, err
+	}
+	userClient, err := cloudsql.NewUserClient(ctx, creds, opts...)
+	if err != nil {
+		return nil, err
+	}
+	sqladminService, err := sqladmin.NewService(ctx, append([]option.ClientOption{option.WithCredentials(creds)}, opts...)...)
 	if err != nil {
 		return nil, err
 	}
-	userClient, err := cloudsql.NewUserClient(ctx, creds)
+	monitoringService, err := monitoring.NewService(ctx, append([]option.ClientOption{option.WithCredentials(creds)}, opts...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -184,35 +452,156 @@ func newManagedHandler(ctx context.Context, inst *v1alpha1.CloudsqlInstance, top
 		localOperations:  tops,
 		instance:         instClient,
 		user:             userClient,
+		metrics:          &monitoringReader{service: monitoringService},
+		databases:        &sqladminDatabases{service: sqladminService, project: creds.ProjectID},
+		operations:       newOperationPoller(instClient),
 	}, nil
 }
 
+// withCallTimeout returns a context that bounds a single Cloud SQL API call.
+func (h *managedHandler) withCallTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if h.callTimeout == 0 {
+		return context.WithTimeout(ctx, DefaultAPICallTimeout)
+	}
+	return context.WithTimeout(ctx, h.callTimeout)
+}
+
 func (h *managedHandler) getInstance(ctx context.Context) (*sqladmin.DatabaseInstance, error) {
-	inst, err := h.instance.Get(ctx, h.GetResourceName())
+	ctx, span := tracing.StartPhase(ctx, tracing.PhaseObserve)
+	ctx, cancel := h.withCallTimeout(ctx)
+	defer cancel()
+	inst, err := h.instance.Get(ctx, instanceName(h.CloudsqlInstance))
 	if err == nil {
-		h.SetStatus(inst)
+		observe(h.CloudsqlInstance, inst)
 	}
+	// An instance that doesn't exist yet is not an error worth tracing.
+	tracing.End(span, resource.Ignore(utilgoogleapi.IsErrorNotFound, err))
 	return inst, err
 }
 
-func (h *managedHandler) createInstance(ctx context.Context) error {
+// getCreateOperation returns the operation that created the instance, or nil
+// if no such operation is known. GCP only retains operations for a limited
+// time, so an operation that can no longer be found is treated as unknown.
+func (h *managedHandler) getCreateOperation(ctx context.Context) (*sqladmin.Operation, error) {
+	if h.Status.Operation == "" {
+		return nil, nil
+	}
+	ctx, cancel := h.withCallTimeout(ctx)
+	defer cancel()
+	op, err := h.instance.GetOperation(ctx, h.Status.Operation)
+	if err != nil {
+		return nil, errors.Wrapf(handleNotFound(err), "cannot get operation %s", h.Status.Operation)
+	}
+	return h.waitForOperation(ctx, op)
+}
+
+// waitForOperation returns the supplied operation once it is done, or as last
+// observed if it is still running when the wait times out.
+func (h *managedHandler) waitForOperation(ctx context.Context, op *sqladmin.Operation) (*sqladmin.Operation, error) {
+	if h.operations == nil || op.Status == operationDone {
+		return op, nil
+	}
+	return h.operations.WaitForOperation(ctx, op)
+}
+
+// createInstance requests the creation of the instance, and records the
+// operation doing so. An instance that already exists is assumed to have been
+// created by a previous reconcile whose operation was never recorded.
+func (h *managedHandler) createInstance(ctx context.Context) (err error) {
+	ctx, span := tracing.StartPhase(ctx, tracing.PhaseCreate)
+	defer func() { tracing.End(span, err) }()
+
 	h.Status.SetConditions(corev1alpha1.Creating())
-	return h.instance.Create(ctx, h.DatabaseInstance(h.GetResourceName()))
+	inst := desiredInstance(h.CloudsqlInstance)
+	if isEngine(h.Spec.DatabaseVersion, sqlServerDBVersionPrefix) {
+		// Cloud SQL requires the password of the sqlserver user to create a
+		// SQL Server instance. It is kept in the connection secret, which is
+		// written before the instance is created so that it is never lost.
+		s, err := h.updateConnectionSecret(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to update connection secret")
+		}
+		inst.RootPassword = string(s.Data[corev1alpha1.ResourceCredentialsSecretPasswordKey])
+	}
+
+	ctx, cancel := h.withCallTimeout(ctx)
+	defer cancel()
+	op, err := h.instance.Create(ctx, inst)
+	if err != nil && !gcp.IsErrorAlreadyExists(err) {
+		return h.enableDisabledService(ctx, err)
+	}
+	h.Status.Phase = v1alpha1.PhaseCreating
+	if op != nil {
+		h.Status.Operation = op.Name
+	}
+	return nil
 }
 
-func (h *managedHandler) updateInstance(ctx context.Context) error {
-	name := h.GetResourceName()
-	return h.instance.Update(ctx, name, h.DatabaseInstance(name))
+// enableDisabledService returns the supplied error of a failed call. New
+// projects may not have the Cloud SQL Admin API enabled yet, so the service
+// the error reports is disabled is enabled if the Provider allows it.
+func (h *managedHandler) enableDisabledService(ctx context.Context, err error) error {
+	if h.enableService == nil {
+		return err
+	}
+	enabling, eerr := h.enableService(ctx, err)
+	if eerr != nil {
+		return eerr
+	}
+	if enabling {
+		return errors.Wrap(err, "enabling disabled service; will retry")
+	}
+	return err
 }
 
-func (h *managedHandler) deleteInstance(ctx context.Context) error {
-	return h.instance.Delete(ctx, h.GetResourceName())
+func (h *managedHandler) updateInstance(ctx context.Context) (err error) {
+	ctx, span := tracing.StartPhase(ctx, tracing.PhaseUpdate)
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := h.withCallTimeout(ctx)
+	defer cancel()
+	return h.instance.Update(ctx, instanceName(h.CloudsqlInstance), desiredInstance(h.CloudsqlInstance))
+}
+
+func (h *managedHandler) deleteInstance(ctx context.Context) (err error) {
+	ctx, span := tracing.StartPhase(ctx, tracing.PhaseDelete)
+	defer func() { tracing.End(span, err) }()
+
+	h.Status.Phase = v1alpha1.PhaseDeleting
+	ctx, cancel := h.withCallTimeout(ctx)
+	defer cancel()
+	return h.instance.Delete(ctx, instanceName(h.CloudsqlInstance))
+}
+
+// disableDeletionProtection patches only the deletion protection setting of
+// the instance, leaving the rest of its settings as they are in GCP. The
+// settings version guards against patching settings that changed since they
+// were read.
+func (h *managedHandler) disableDeletionProtection(ctx context.Context) error {
+	name := instanceName(h.CloudsqlInstance)
+	ctx, cancel := h.withCallTimeout(ctx)
+	defer cancel()
+	actual, err := h.instance.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	var version int64
+	if actual.Settings != nil {
+		version = actual.Settings.SettingsVersion
+	}
+	patch := &sqladmin.DatabaseInstance{Settings: &sqladmin.Settings{
+		DeletionProtectionEnabled: false,
+		SettingsVersion:           version,
+		ForceSendFields:           []string{"DeletionProtectionEnabled"},
+	}}
+	return h.instance.Patch(ctx, name, patch)
 }
 
 func (h *managedHandler) getUser(ctx context.Context) (*sqladmin.User, error) {
-	instanceName := h.GetResourceName()
-	userName := h.DatabaseUserName()
-	users, err := h.user.List(ctx, instanceName)
+	userName := databaseUserName(h.CloudsqlInstance)
+	ctx, cancel := h.withCallTimeout(ctx)
+	defer cancel()
+	users, err := h.user.List(ctx, instanceName(h.CloudsqlInstance))
 	if err != nil {
 		return nil, err
 	}
@@ -236,7 +625,20 @@ func (h *managedHandler) getUser(ctx context.Context) (*sqladmin.User, error) {
 //
 // TODO(illya): In the future, we need to come up with more sophisticated means
 //  to detect the password value drift
+//
+// Instances in dry-run mode keep their connection secret and user as they are.
+// Their password is reset on every sync, so the planned reset is recorded as
+// an event rather than as a Planned condition that would never clear.
 func (h *managedHandler) updateUserCreds(ctx context.Context) error {
+	if h.isDryRun() {
+		if h.recorder != nil {
+			h.recorder.Event(h.CloudsqlInstance, corev1.EventTypeNormal, plan.EventReasonPlanned, plan.Describe("UpdateUser", map[string]string{
+				"instance": instanceName(h.CloudsqlInstance),
+				"name":     databaseUserName(h.CloudsqlInstance),
+			}))
+		}
+		return nil
+	}
 
 	secret, err := h.updateConnectionSecret(ctx)
 	if err != nil {
@@ -249,5 +651,7 @@ func (h *managedHandler) updateUserCreds(ctx context.Context) error {
 	}
 	user.Password = string(secret.Data[corev1alpha1.ResourceCredentialsSecretPasswordKey])
 
+	ctx, cancel := h.withCallTimeout(ctx)
+	defer cancel()
 	return h.user.Update(ctx, user.Instance, user.Name, user)
 }
@@ -2,9 +2,11 @@ package database
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
@@ -24,6 +26,10 @@ import (
 	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
 	"github.com/crossplaneio/crossplane/pkg/clients/gcp/cloudsql"
 	"github.com/crossplaneio/crossplane/pkg/clients/gcp/cloudsql/fake"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/deadline"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/externalname"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/plan"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/secretgc"
 	"github.com/crossplaneio/crossplane/pkg/test"
 )
 
@@ -71,17 +77,35 @@ var (
 
 type mockLocalOperations struct {
 	// Bucket object managedOperations
-	mockAddFinalizer    func(context.Context) error
-	mockIsReclaimDelete func() bool
-	mockIsInstanceReady func() bool
-	mockNeedUpdate      func(*sqladmin.DatabaseInstance) bool
-	mockRemoveFinalizer func(context.Context) error
+	mockAddFinalizer                   func(context.Context) error
+	mockIsReclaimDelete                func() bool
+	mockIsDeletionProtectionOverridden func() bool
+	mockIsInstanceReady                func() bool
+	mockHasBeenReady                   func() bool
+	mockIsCreationStalled              func(context.Context, string) (bool, error)
+	mockForgetCreation                 func(context.Context) error
+	mockIsDryRun                       func() bool
+	mockResetFailedCreate              func() bool
+	mockNeedUpdate                     func(*sqladmin.DatabaseInstance) bool
+	mockRemoveFinalizer                func(context.Context) error
+	mockResolveConnection              func(context.Context) error
+	mockFinalBackupURI                 func(context.Context, string) (string, error)
+	mockServiceAccountBuckets          func(context.Context) ([]string, error)
+	mockReconcileConnectionPooler      func(context.Context) error
+	mockDeleteConnectionPooler         func(context.Context) error
+	mockValidate                       func() error
 
 	// Controller-runtime managedOperations
-	mockUpdateObject           func(context.Context) error
-	mockUpdateInstanceStatus   func(context.Context, *sqladmin.DatabaseInstance) error
-	mockUpdateReconcileStatus  func(context.Context, error) error
-	mockUpdateConnectionSecret func(context.Context) (*core.Secret, error)
+	mockUpdateObject                  func(context.Context) error
+	mockUpdateInstanceStatus          func(context.Context, *sqladmin.DatabaseInstance) error
+	mockUpdateReconcileStatus         func(context.Context, error) error
+	mockUpdateSyncedStatus            func(context.Context) error
+	mockUpdateStalledStatus           func(context.Context, error) error
+	mockUpdateUnavailableStatus       func(context.Context) error
+	mockUpdateDeletionProtectedStatus func(context.Context) error
+	mockUpdateFailedStatus            func(context.Context, error) error
+	mockUpdatePlannedStatus           func(context.Context, string) error
+	mockUpdateConnectionSecret        func(context.Context) (*core.Secret, error)
 }
 
 var _ localOperations = &mockLocalOperations{}
@@ -92,15 +116,78 @@ func (m *mockLocalOperations) addFinalizer(ctx context.Context) error {
 func (m *mockLocalOperations) isReclaimDelete() bool {
 	return m.mockIsReclaimDelete()
 }
+func (m *mockLocalOperations) isDeletionProtectionOverridden() bool {
+	return m.mockIsDeletionProtectionOverridden()
+}
 func (m *mockLocalOperations) isInstanceReady() bool {
 	return m.mockIsInstanceReady()
 }
+func (m *mockLocalOperations) hasBeenReady() bool {
+	if m.mockHasBeenReady == nil {
+		return false
+	}
+	return m.mockHasBeenReady()
+}
+func (m *mockLocalOperations) isCreationStalled(ctx context.Context, state string) (bool, error) {
+	if m.mockIsCreationStalled == nil {
+		return false, nil
+	}
+	return m.mockIsCreationStalled(ctx, state)
+}
+func (m *mockLocalOperations) forgetCreation(ctx context.Context) error {
+	if m.mockForgetCreation == nil {
+		return nil
+	}
+	return m.mockForgetCreation(ctx)
+}
+func (m *mockLocalOperations) isDryRun() bool {
+	if m.mockIsDryRun == nil {
+		return false
+	}
+	return m.mockIsDryRun()
+}
+func (m *mockLocalOperations) resetFailedCreate() bool {
+	return m.mockResetFailedCreate()
+}
 func (m *mockLocalOperations) needsUpdate(di *sqladmin.DatabaseInstance) bool {
 	return m.mockNeedUpdate(di)
 }
 func (m *mockLocalOperations) removeFinalizer(ctx context.Context) error {
 	return m.mockRemoveFinalizer(ctx)
 }
+func (m *mockLocalOperations) resolveConnection(ctx context.Context) error {
+	return m.mockResolveConnection(ctx)
+}
+func (m *mockLocalOperations) finalBackupURI(ctx context.Context, database string) (string, error) {
+	if m.mockFinalBackupURI == nil {
+		return "", nil
+	}
+	return m.mockFinalBackupURI(ctx, database)
+}
+func (m *mockLocalOperations) serviceAccountBuckets(ctx context.Context) ([]string, error) {
+	if m.mockServiceAccountBuckets == nil {
+		return nil, nil
+	}
+	return m.mockServiceAccountBuckets(ctx)
+}
+func (m *mockLocalOperations) reconcileConnectionPooler(ctx context.Context) error {
+	if m.mockReconcileConnectionPooler == nil {
+		return nil
+	}
+	return m.mockReconcileConnectionPooler(ctx)
+}
+func (m *mockLocalOperations) deleteConnectionPooler(ctx context.Context) error {
+	if m.mockDeleteConnectionPooler == nil {
+		return nil
+	}
+	return m.mockDeleteConnectionPooler(ctx)
+}
+func (m *mockLocalOperations) validate() error {
+	if m.mockValidate == nil {
+		return nil
+	}
+	return m.mockValidate()
+}
 func (m *mockLocalOperations) updateObject(ctx context.Context) error {
 	return m.mockUpdateObject(ctx)
 }
@@ -110,6 +197,24 @@ func (m *mockLocalOperations) updateInstanceStatus(ctx context.Context, di *sqla
 func (m *mockLocalOperations) updateReconcileStatus(ctx context.Context, err error) error {
 	return m.mockUpdateReconcileStatus(ctx, err)
 }
+func (m *mockLocalOperations) updateSyncedStatus(ctx context.Context) error {
+	return m.mockUpdateSyncedStatus(ctx)
+}
+func (m *mockLocalOperations) updateStalledStatus(ctx context.Context, err error) error {
+	return m.mockUpdateStalledStatus(ctx, err)
+}
+func (m *mockLocalOperations) updateUnavailableStatus(ctx context.Context) error {
+	return m.mockUpdateUnavailableStatus(ctx)
+}
+func (m *mockLocalOperations) updateDeletionProtectedStatus(ctx context.Context) error {
+	return m.mockUpdateDeletionProtectedStatus(ctx)
+}
+func (m *mockLocalOperations) updateFailedStatus(ctx context.Context, err error) error {
+	return m.mockUpdateFailedStatus(ctx, err)
+}
+func (m *mockLocalOperations) updatePlannedStatus(ctx context.Context, call string) error {
+	return m.mockUpdatePlannedStatus(ctx, call)
+}
 func (m *mockLocalOperations) updateConnectionSecret(ctx context.Context) (*core.Secret, error) {
 	return m.mockUpdateConnectionSecret(ctx)
 }
@@ -118,13 +223,21 @@ type mockManagedOperations struct {
 	localOperations
 
 	// DatabaseInstance managedOperations
-	mockGetInstance    func(context.Context) (*sqladmin.DatabaseInstance, error)
-	mockCreateInstance func(context.Context) error
-	mockUpdateInstance func(context.Context) error
-	mockDeleteInstance func(context.Context) error
+	mockGetInstance               func(context.Context) (*sqladmin.DatabaseInstance, error)
+	mockGetCreateOperation        func(context.Context) (*sqladmin.Operation, error)
+	mockCreateInstance            func(context.Context) error
+	mockUpdateInstance            func(context.Context) error
+	mockDeleteInstance            func(context.Context) error
+	mockDisableDeletionProtection func(context.Context) error
+	mockFinalBackup               func(context.Context) (bool, error)
+	mockObserveMetrics            func(context.Context, *sqladmin.DatabaseInstance) error
+	mockRunAction                 func(context.Context, *sqladmin.DatabaseInstance) (bool, error)
 
 	// DatabaseUser managedOperations
 	mockUpdateUserCreds func(context.Context) error
+
+	// IAM managedOperations
+	mockGrantServiceAccountAccess func(context.Context) error
 }
 
 var _ managedOperations = &mockManagedOperations{}
@@ -132,6 +245,9 @@ var _ managedOperations = &mockManagedOperations{}
 func (m *mockManagedOperations) getInstance(ctx context.Context) (*sqladmin.DatabaseInstance, error) {
 	return m.mockGetInstance(ctx)
 }
+func (m *mockManagedOperations) getCreateOperation(ctx context.Context) (*sqladmin.Operation, error) {
+	return m.mockGetCreateOperation(ctx)
+}
 func (m *mockManagedOperations) createInstance(ctx context.Context) error {
 	return m.mockCreateInstance(ctx)
 }
@@ -141,10 +257,38 @@ func (m *mockManagedOperations) updateInstance(ctx context.Context) error {
 func (m *mockManagedOperations) deleteInstance(ctx context.Context) error {
 	return m.mockDeleteInstance(ctx)
 }
+func (m *mockManagedOperations) disableDeletionProtection(ctx context.Context) error {
+	return m.mockDisableDeletionProtection(ctx)
+}
+func (m *mockManagedOperations) finalBackup(ctx context.Context) (bool, error) {
+	if m.mockFinalBackup == nil {
+		return true, nil
+	}
+	return m.mockFinalBackup(ctx)
+}
+func (m *mockManagedOperations) observeMetrics(ctx context.Context, inst *sqladmin.DatabaseInstance) error {
+	if m.mockObserveMetrics == nil {
+		return nil
+	}
+	return m.mockObserveMetrics(ctx, inst)
+}
+func (m *mockManagedOperations) runAction(ctx context.Context, inst *sqladmin.DatabaseInstance) (bool, error) {
+	if m.mockRunAction == nil {
+		return false, nil
+	}
+	return m.mockRunAction(ctx, inst)
+}
 func (m *mockManagedOperations) updateUserCreds(ctx context.Context) error {
 	return m.mockUpdateUserCreds(ctx)
 }
 
+func (m *mockManagedOperations) grantServiceAccountAccess(ctx context.Context) error {
+	if m.mockGrantServiceAccountAccess == nil {
+		return nil
+	}
+	return m.mockGrantServiceAccountAccess(ctx)
+}
+
 type mockFactory struct {
 	mockMakeLocalOperations   func(*v1alpha1.CloudsqlInstance, client.Client) localOperations
 	mockMakeManagedOperations func(context.Context, *v1alpha1.CloudsqlInstance, localOperations) (managedOperations, error)
@@ -268,8 +412,9 @@ func Test_localHandler_addFinalizer(t *testing.T) {
 		ctx context.Context
 	}
 	type want struct {
-		err        error
-		finalizers []string
+		err          error
+		finalizers   []string
+		externalName string
 	}
 	tests := map[string]struct {
 		fields fields
@@ -289,7 +434,8 @@ func Test_localHandler_addFinalizer(t *testing.T) {
 				},
 			},
 			want: want{
-				finalizers: []string{finalizer},
+				finalizers:   []string{finalizer},
+				externalName: getExpectedInstanceName(""),
 			},
 		},
 		"SomeOtherFinalizer": {
@@ -298,7 +444,8 @@ func Test_localHandler_addFinalizer(t *testing.T) {
 				kube:     test.NewMockClient(),
 			},
 			want: want{
-				finalizers: []string{"foo", finalizer},
+				finalizers:   []string{"foo", finalizer},
+				externalName: getExpectedInstanceName(""),
 			},
 		},
 		"ExistingFinalizer": {
@@ -307,7 +454,21 @@ func Test_localHandler_addFinalizer(t *testing.T) {
 				kube:     test.NewMockClient(),
 			},
 			want: want{
-				finalizers: []string{"foo", finalizer},
+				finalizers:   []string{"foo", finalizer},
+				externalName: getExpectedInstanceName(""),
+			},
+		},
+		"PersistedName": {
+			fields: fields{
+				instance: newInstance().withObjectMeta(meta1.ObjectMeta{
+					UID:         testUID,
+					Annotations: map[string]string{externalname.AnnotationExternalName: "restored"},
+				}).build(),
+				kube: test.NewMockClient(),
+			},
+			want: want{
+				finalizers:   []string{finalizer},
+				externalName: "restored",
 			},
 		},
 	}
@@ -320,6 +481,9 @@ func Test_localHandler_addFinalizer(t *testing.T) {
 			if diff := cmp.Diff(tt.want.finalizers, tt.fields.instance.Finalizers); diff != "" {
 				t.Errorf("addFinalizer() -want, +got: %s", diff)
 			}
+			if diff := cmp.Diff(tt.want.externalName, externalname.Get(tt.fields.instance)); diff != "" {
+				t.Errorf("addFinalizer() external name -want, +got: %s", diff)
+			}
 		})
 	}
 }
@@ -425,6 +589,112 @@ func Test_localHandler_isInstanceReady(t *testing.T) {
 	}
 }
 
+func Test_localHandler_hasBeenReady(t *testing.T) {
+	synced := meta1.Now()
+	tests := map[string]struct {
+		status v1alpha1.CloudsqlInstanceStatus
+		want   bool
+	}{
+		"Creating": {
+			status: v1alpha1.CloudsqlInstanceStatus{Phase: v1alpha1.PhaseCreating},
+			want:   false,
+		},
+		"FailedToCreate": {
+			status: v1alpha1.CloudsqlInstanceStatus{Phase: v1alpha1.PhaseFailed},
+			want:   false,
+		},
+		"Running": {
+			status: v1alpha1.CloudsqlInstanceStatus{Phase: v1alpha1.PhaseRunning},
+			want:   true,
+		},
+		"FailedAfterSync": {
+			status: v1alpha1.CloudsqlInstanceStatus{Phase: v1alpha1.PhaseFailed, LastSyncTime: &synced},
+			want:   true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ih := &localHandler{CloudsqlInstance: &v1alpha1.CloudsqlInstance{Status: tt.status}}
+			if got := ih.hasBeenReady(); got != tt.want {
+				t.Errorf("hasBeenReady() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_localHandler_isCreationStalled(t *testing.T) {
+	longAgo := time.Now().Add(-2 * deadline.DefaultCreationDeadline)
+	waiting := func(state string, since time.Time) map[string]string {
+		return map[string]string{deadline.AnnotationWaitingSince: state + " " + since.UTC().Format(time.RFC3339)}
+	}
+	stalled := corev1alpha1.ConditionedStatus{}
+	stalled.SetConditions(deadline.Stalled(deadline.DefaultCreationDeadline, ""))
+
+	tests := map[string]struct {
+		meta        meta1.ObjectMeta
+		status      v1alpha1.CloudsqlInstanceStatus
+		state       string
+		want        bool
+		wantUpdated bool
+	}{
+		"NewlyObserved": {
+			meta:        meta1.ObjectMeta{CreationTimestamp: meta1.NewTime(longAgo)},
+			state:       v1alpha1.StatePendingCreate,
+			want:        false,
+			wantUpdated: true,
+		},
+		"WithinDeadline": {
+			meta:  meta1.ObjectMeta{Annotations: waiting(v1alpha1.StatePendingCreate, time.Now())},
+			state: v1alpha1.StatePendingCreate,
+			want:  false,
+		},
+		"DeadlineExceeded": {
+			meta:  meta1.ObjectMeta{Annotations: waiting(v1alpha1.StatePendingCreate, longAgo)},
+			state: v1alpha1.StatePendingCreate,
+			want:  true,
+		},
+		"StateChanged": {
+			meta:        meta1.ObjectMeta{Annotations: waiting(v1alpha1.StatePendingCreate, longAgo)},
+			state:       "MAINTENANCE",
+			want:        false,
+			wantUpdated: true,
+		},
+		"AlreadyStalled": {
+			meta:   meta1.ObjectMeta{Annotations: waiting(v1alpha1.StatePendingCreate, longAgo)},
+			status: v1alpha1.CloudsqlInstanceStatus{ResourceStatus: corev1alpha1.ResourceStatus{ConditionedStatus: stalled}},
+			state:  v1alpha1.StatePendingCreate,
+			want:   false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			updated := false
+			ih := &localHandler{
+				CloudsqlInstance: &v1alpha1.CloudsqlInstance{
+					ObjectMeta: tt.meta,
+					Status:     tt.status,
+				},
+				client: &test.MockClient{
+					MockUpdate: func(_ context.Context, _ runtime.Object, _ ...client.UpdateOption) error {
+						updated = true
+						return nil
+					},
+				},
+			}
+			got, err := ih.isCreationStalled(context.Background(), tt.state)
+			if err != nil {
+				t.Fatalf("isCreationStalled(...): %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("isCreationStalled(...) = %v, want %v", got, tt.want)
+			}
+			if updated != tt.wantUpdated {
+				t.Errorf("isCreationStalled(...): updated = %v, want %v", updated, tt.wantUpdated)
+			}
+		})
+	}
+}
+
 func Test_localHandler_isReclaimDelete(t *testing.T) {
 	tests := map[string]struct {
 		inst *v1alpha1.CloudsqlInstance
@@ -457,10 +727,114 @@ func Test_localHandler_isReclaimDelete(t *testing.T) {
 	}
 }
 
+func Test_localHandler_resetFailedCreate(t *testing.T) {
+	tests := map[string]struct {
+		status    v1alpha1.CloudsqlInstanceStatus
+		want      bool
+		wantPhase v1alpha1.CloudsqlInstancePhase
+		wantOp    string
+	}{
+		"Creating": {
+			status:    v1alpha1.CloudsqlInstanceStatus{Phase: v1alpha1.PhaseCreating, Operation: "test-operation"},
+			wantPhase: v1alpha1.PhaseCreating,
+			wantOp:    "test-operation",
+		},
+		"FailedAtCurrentGeneration": {
+			status:    v1alpha1.CloudsqlInstanceStatus{Phase: v1alpha1.PhaseFailed, Operation: "test-operation", ResourceStatus: corev1alpha1.ResourceStatus{ObservedGeneration: 2}},
+			wantPhase: v1alpha1.PhaseFailed,
+			wantOp:    "test-operation",
+		},
+		"FailedAtPreviousGeneration": {
+			status:    v1alpha1.CloudsqlInstanceStatus{Phase: v1alpha1.PhaseFailed, Operation: "test-operation", ResourceStatus: corev1alpha1.ResourceStatus{ObservedGeneration: 1}},
+			want:      true,
+			wantPhase: v1alpha1.PhasePending,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			inst := &v1alpha1.CloudsqlInstance{ObjectMeta: meta1.ObjectMeta{Generation: 2}, Status: tt.status}
+			ih := &localHandler{CloudsqlInstance: inst}
+			if got := ih.resetFailedCreate(); got != tt.want {
+				t.Errorf("resetFailedCreate() = %v, want %v", got, tt.want)
+			}
+			if inst.Status.Phase != tt.wantPhase {
+				t.Errorf("resetFailedCreate() phase = %s, want %s", inst.Status.Phase, tt.wantPhase)
+			}
+			if inst.Status.Operation != tt.wantOp {
+				t.Errorf("resetFailedCreate() operation = %q, want %q", inst.Status.Operation, tt.wantOp)
+			}
+		})
+	}
+}
+
 func Test_localHandler_needUpdate(t *testing.T) {
-	handler := &localHandler{}
-	if got := handler.needsUpdate(&sqladmin.DatabaseInstance{}); got {
-		t.Errorf("needsUpdate() = %v, should always be false", got)
+	inst := newInstance().build()
+	inst.Spec.DatabaseVersion = "POSTGRES_9_6"
+	upToDate := desiredInstance(inst)
+	upToDate.SelfLink = "https://sqladmin.googleapis.com/sql/v1beta4/projects/cool/instances/cool"
+	upToDate.State = v1alpha1.StateRunnable
+
+	changed := desiredInstance(inst)
+	changed.DatabaseVersion = "POSTGRES_11"
+
+	insightsDisabled := desiredInstance(inst)
+	insightsDisabled.Settings.InsightsConfig = nil
+
+	// The API omits false values that were sent explicitly.
+	apiResponse := func(i *sqladmin.DatabaseInstance) *sqladmin.DatabaseInstance {
+		b, err := json.Marshal(i)
+		if err != nil {
+			t.Fatalf("json.Marshal(...): %s", err)
+		}
+		out := &sqladmin.DatabaseInstance{}
+		if err := json.Unmarshal(b, out); err != nil {
+			t.Fatalf("json.Unmarshal(...): %s", err)
+		}
+		return out
+	}
+
+	withInsightsOff := newInstance().build()
+	withInsightsOff.Spec.DatabaseVersion = "POSTGRES_9_6"
+	withInsightsOff.Spec.InsightsConfig = &v1alpha1.InsightsConfig{}
+
+	insightsOn := desiredInstance(withInsightsOff)
+	insightsOn.Settings.InsightsConfig.QueryInsightsEnabled = true
+
+	withNetworks := newInstance().build()
+	withNetworks.Spec.DatabaseVersion = "POSTGRES_9_6"
+	withNetworks.Spec.AuthorizedNetworks = []v1alpha1.AuthorizedNetwork{
+		{Name: "office", CIDR: "203.0.113.0/24"},
+		{Name: "vpn", CIDR: "10.0.0.0/8"},
+	}
+
+	networksReordered := desiredInstance(withNetworks)
+	n := networksReordered.Settings.IpConfiguration.AuthorizedNetworks
+	networksReordered.Settings.IpConfiguration.AuthorizedNetworks = []*sqladmin.AclEntry{n[1], n[0]}
+
+	networkRemoved := desiredInstance(withNetworks)
+	networkRemoved.Settings.IpConfiguration.AuthorizedNetworks = append(n, &sqladmin.AclEntry{Name: "conference", Value: "192.0.2.0/24"})
+
+	tests := map[string]struct {
+		inst   *v1alpha1.CloudsqlInstance
+		actual *sqladmin.DatabaseInstance
+		want   bool
+	}{
+		"UpToDate":                  {inst: inst, actual: upToDate, want: false},
+		"Changed":                   {inst: inst, actual: changed, want: true},
+		"InsightsDisabled":          {inst: inst, actual: insightsDisabled, want: true},
+		"APIResponse":               {inst: inst, actual: apiResponse(desiredInstance(inst))},
+		"APIResponseInsightsOff":    {inst: withInsightsOff, actual: apiResponse(desiredInstance(withInsightsOff))},
+		"InsightsNotYetDisabled":    {inst: withInsightsOff, actual: apiResponse(insightsOn), want: true},
+		"AuthorizedNetworksReorder": {inst: withNetworks, actual: networksReordered, want: false},
+		"AuthorizedNetworkRemoved":  {inst: withNetworks, actual: networkRemoved, want: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			handler := &localHandler{CloudsqlInstance: tt.inst}
+			if got := handler.needsUpdate(tt.actual); got != tt.want {
+				t.Errorf("needsUpdate() = %v, want %v", got, tt.want)
+			}
+		})
 	}
 }
 
@@ -604,6 +978,76 @@ func Test_localHandler_updateInstanceStatus(t *testing.T) {
 	}
 }
 
+func Test_localHandler_updateSyncedStatus(t *testing.T) {
+	planned := newInstanceStatus().withConditions(plan.Planned("UpdateInstance")).build()
+
+	tests := map[string]struct {
+		annotations map[string]string
+		status      corev1alpha1.ResourceStatus
+		want        corev1alpha1.ConditionedStatus
+	}{
+		"Active": {
+			want: newInstanceStatus().withConditions(corev1alpha1.ReconcileSuccess()).build().ConditionedStatus,
+		},
+		"DryRun": {
+			annotations: map[string]string{plan.AnnotationManagementMode: plan.ModeDryRun},
+			status:      *planned,
+			want:        newInstanceStatus().withConditions(plan.NothingPlanned(), corev1alpha1.ReconcileSuccess()).build().ConditionedStatus,
+		},
+		"NoLongerDryRun": {
+			status: *planned,
+			want:   newInstanceStatus().withConditions(plan.NothingPlanned(), corev1alpha1.ReconcileSuccess()).build().ConditionedStatus,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			inst := &v1alpha1.CloudsqlInstance{ObjectMeta: meta1.ObjectMeta{Annotations: tt.annotations, Generation: 2}}
+			inst.Spec.Tier = "db-custom-1-3840"
+			inst.Status.ResourceStatus = tt.status
+			h := &localHandler{
+				CloudsqlInstance: inst,
+				client: &test.MockClient{MockStatusUpdate: func(context.Context, runtime.Object, ...client.UpdateOption) error {
+					return nil
+				}},
+			}
+			if err := h.updateSyncedStatus(context.Background()); err != nil {
+				t.Fatalf("updateSyncedStatus(): %s", err)
+			}
+			if diff := cmp.Diff(tt.want, inst.Status.ConditionedStatus, test.EquateConditions()); diff != "" {
+				t.Errorf("updateSyncedStatus() -want, +got: %s", diff)
+			}
+			if inst.Status.ObservedGeneration != 2 {
+				t.Errorf("updateSyncedStatus() observed generation: want 2, got %d", inst.Status.ObservedGeneration)
+			}
+			if inst.Status.BaseTier != inst.Spec.Tier {
+				t.Errorf("updateSyncedStatus() base tier: want %s, got %s", inst.Spec.Tier, inst.Status.BaseTier)
+			}
+		})
+	}
+}
+
+func Test_localHandler_updateUnavailableStatus(t *testing.T) {
+	inst := &v1alpha1.CloudsqlInstance{ObjectMeta: meta1.ObjectMeta{Name: "cool-instance"}}
+	inst.Status.Phase = v1alpha1.PhaseRunning
+	inst.Status.State = v1alpha1.StateMaintenance
+	h := &localHandler{
+		CloudsqlInstance: inst,
+		client: &test.MockClient{MockStatusUpdate: func(context.Context, runtime.Object, ...client.UpdateOption) error {
+			return nil
+		}},
+	}
+	if err := h.updateUnavailableStatus(context.Background()); err != nil {
+		t.Fatalf("updateUnavailableStatus(): %s", err)
+	}
+	want := newInstanceStatus().withConditions(instanceUnavailable(instanceName(inst), v1alpha1.StateMaintenance)).build().ConditionedStatus
+	if diff := cmp.Diff(want, inst.Status.ConditionedStatus, test.EquateConditions()); diff != "" {
+		t.Errorf("updateUnavailableStatus() -want, +got: %s", diff)
+	}
+	if deadline.IsStalled(inst.Status.ConditionedStatus) {
+		t.Errorf("updateUnavailableStatus(): instance that has been ready marked as stalled")
+	}
+}
+
 func Test_localHandler_updateReconcileStatus(t *testing.T) {
 	testError := errors.New("test-error")
 	inst := &v1alpha1.CloudsqlInstance{}
@@ -647,6 +1091,7 @@ func Test_localHandler_updateReconcileStatus(t *testing.T) {
 			want: want{
 				err: testError,
 				status: v1alpha1.CloudsqlInstanceStatus{
+					Phase:          v1alpha1.PhasePending,
 					ResourceStatus: *newInstanceStatus().withConditions(corev1alpha1.ReconcileSuccess()).build(),
 				},
 			},
@@ -663,6 +1108,7 @@ func Test_localHandler_updateReconcileStatus(t *testing.T) {
 			},
 			want: want{
 				status: v1alpha1.CloudsqlInstanceStatus{
+					Phase:          v1alpha1.PhasePending,
 					ResourceStatus: *newInstanceStatus().withConditions(corev1alpha1.ReconcileSuccess()).build(),
 				},
 			},
@@ -682,6 +1128,7 @@ func Test_localHandler_updateReconcileStatus(t *testing.T) {
 			},
 			want: want{
 				status: v1alpha1.CloudsqlInstanceStatus{
+					Phase:          v1alpha1.PhasePending,
 					ResourceStatus: *newInstanceStatus().withConditions(corev1alpha1.ReconcileError(testError)).build(),
 				},
 			},
@@ -877,6 +1324,42 @@ func Test_localHandler_updateConnectionSecret(t *testing.T) {
 				sec: testSecret("new-ep", "test-pass"),
 			},
 		},
+		"ExistsSQLServer": {
+			fields: fields{
+				inst: &v1alpha1.CloudsqlInstance{
+					ObjectMeta: testMeta,
+					Spec: v1alpha1.CloudsqlInstanceSpec{
+						ResourceSpec: *newInstanceSpec().
+							withWriteConnectionSecretRef(core.LocalObjectReference{Name: testName}).build(),
+						DatabaseVersion: "SQLSERVER_2019_STANDARD",
+					},
+					Status: v1alpha1.CloudsqlInstanceStatus{
+						Endpoint: "test-ep",
+					},
+				},
+				kube: &test.MockClient{
+					MockGet: func(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+						assertKey(key)
+						s := assertObj(obj)
+						ts := testSecret("test-ep", "test-pass")
+						ts.DeepCopyInto(s)
+						return nil
+					},
+					MockUpdate: func(ctx context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+						assertObj(obj)
+						return nil
+					},
+				},
+			},
+			want: want{
+				sec: func() *core.Secret {
+					s := testSecret("test-ep", "test-pass")
+					s.Data[corev1alpha1.ResourceCredentialsSecretUserKey] = []byte(sqlServerUserName)
+					s.Data[ConnectionSecretPortKey] = []byte(sqlServerPort)
+					return s
+				}(),
+			},
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -902,6 +1385,7 @@ func Test_localHandler_updateConnectionSecret(t *testing.T) {
 			}
 			got.Data[corev1alpha1.ResourceCredentialsSecretPasswordKey] = nil
 			tt.want.sec.Data[corev1alpha1.ResourceCredentialsSecretPasswordKey] = nil
+			secretgc.Mark(tt.want.sec, tt.fields.inst, v1alpha1.CloudsqlInstanceGroupVersionKind)
 			if diff := cmp.Diff(tt.want.sec, got); diff != "" {
 				t.Errorf("updateConnectionSecret() -want, +got: %s", diff)
 			}
@@ -949,6 +1433,7 @@ func Test_managedHandler_getInstance(t *testing.T) {
 			want: want{
 				status: v1alpha1.CloudsqlInstanceStatus{
 					State:          "thinking-about",
+					Phase:          v1alpha1.PhaseCreating,
 					Endpoint:       "test.ip.address",
 					ResourceStatus: *newInstanceStatus().withConditions(corev1alpha1.Unavailable()).build(),
 				},
@@ -972,9 +1457,109 @@ func Test_managedHandler_getInstance(t *testing.T) {
 	}
 }
 
+func Test_managedHandler_getCreateOperation(t *testing.T) {
+	type want struct {
+		op  *sqladmin.Operation
+		err error
+	}
+	tests := map[string]struct {
+		operation string
+		instance  cloudsql.InstanceService
+		want      want
+	}{
+		"NoOperation": {
+			instance: &fake.MockInstanceClient{},
+			want:     want{},
+		},
+		"Found": {
+			operation: "test-operation",
+			instance: &fake.MockInstanceClient{
+				MockGetOperation: func(ctx context.Context, name string) (*sqladmin.Operation, error) {
+					return &sqladmin.Operation{Name: name, Status: operationDone}, nil
+				},
+			},
+			want: want{op: &sqladmin.Operation{Name: "test-operation", Status: operationDone}},
+		},
+		"Expired": {
+			operation: "test-operation",
+			instance: &fake.MockInstanceClient{
+				MockGetOperation: func(ctx context.Context, name string) (*sqladmin.Operation, error) {
+					return nil, &googleapi.Error{Code: http.StatusNotFound}
+				},
+			},
+			want: want{},
+		},
+		"Failure": {
+			operation: "test-operation",
+			instance: &fake.MockInstanceClient{
+				MockGetOperation: func(ctx context.Context, name string) (*sqladmin.Operation, error) {
+					return nil, errTest
+				},
+			},
+			want: want{err: errors.Wrap(errTest, "cannot get operation test-operation")},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			obj := &v1alpha1.CloudsqlInstance{ObjectMeta: testMeta}
+			obj.Status.Operation = tt.operation
+			ih := &managedHandler{
+				CloudsqlInstance: obj,
+				instance:         tt.instance,
+			}
+			op, err := ih.getCreateOperation(context.Background())
+			if diff := cmp.Diff(tt.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("getCreateOperation() error -want, +got: %s", diff)
+			}
+			if diff := cmp.Diff(tt.want.op, op); diff != "" {
+				t.Errorf("getCreateOperation() -want, +got: %s", diff)
+			}
+		})
+	}
+}
+
+func Test_managedHandler_withCallTimeout(t *testing.T) {
+	tests := map[string]struct {
+		timeout time.Duration
+		want    time.Duration
+	}{
+		"Default": {
+			want: DefaultAPICallTimeout,
+		},
+		"Configured": {
+			timeout: 5 * time.Second,
+			want:    5 * time.Second,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ih := &managedHandler{
+				CloudsqlInstance: &v1alpha1.CloudsqlInstance{ObjectMeta: testMeta},
+				callTimeout:      tt.timeout,
+				instance: &fake.MockInstanceClient{
+					MockGet: func(ctx context.Context, _ string) (*sqladmin.DatabaseInstance, error) {
+						d, ok := ctx.Deadline()
+						if !ok {
+							t.Errorf("getInstance() context has no deadline")
+						}
+						if got := time.Until(d); got > tt.want {
+							t.Errorf("getInstance() context deadline: want at most %s, got %s", tt.want, got)
+						}
+						return &sqladmin.DatabaseInstance{}, nil
+					},
+				},
+			}
+			if _, err := ih.getInstance(context.Background()); err != nil {
+				t.Errorf("getInstance() error: %s", err)
+			}
+		})
+	}
+}
+
 func Test_managedHandler_createInstance(t *testing.T) {
 	type fields struct {
 		obj      *v1alpha1.CloudsqlInstance
+		local    localOperations
 		instance cloudsql.InstanceService
 	}
 	type args struct {
@@ -995,16 +1580,100 @@ func Test_managedHandler_createInstance(t *testing.T) {
 					ObjectMeta: testMeta,
 				},
 				instance: &fake.MockInstanceClient{
-					MockCreate: func(ctx context.Context, instance *sqladmin.DatabaseInstance) error {
+					MockCreate: func(ctx context.Context, instance *sqladmin.DatabaseInstance) (*sqladmin.Operation, error) {
 						expectedInstanceName := getExpectedInstanceName(testUID)
 						if instance == nil {
 							t.Errorf("createInstance() create instance is nil")
-							return nil
+							return nil, nil
 						}
 						if diff := cmp.Diff(expectedInstanceName, instance.Name); diff != "" {
 							t.Errorf("createInstance() create -want, +got: %s", diff)
 						}
-						return nil
+						return &sqladmin.Operation{Name: "test-operation"}, nil
+					},
+				},
+			},
+			want: want{
+				status: v1alpha1.CloudsqlInstanceStatus{
+					Phase:          v1alpha1.PhaseCreating,
+					Operation:      "test-operation",
+					ResourceStatus: *newInstanceStatus().withConditions(corev1alpha1.Creating()).build(),
+				},
+			},
+		},
+		"SQLServer": {
+			fields: fields{
+				obj: &v1alpha1.CloudsqlInstance{
+					ObjectMeta: testMeta,
+					Spec:       v1alpha1.CloudsqlInstanceSpec{DatabaseVersion: "SQLSERVER_2019_STANDARD"},
+				},
+				local: &mockLocalOperations{
+					mockUpdateConnectionSecret: func(ctx context.Context) (*core.Secret, error) {
+						return &core.Secret{Data: map[string][]byte{
+							corev1alpha1.ResourceCredentialsSecretPasswordKey: []byte("cool-password"),
+						}}, nil
+					},
+				},
+				instance: &fake.MockInstanceClient{
+					MockCreate: func(ctx context.Context, instance *sqladmin.DatabaseInstance) (*sqladmin.Operation, error) {
+						if diff := cmp.Diff("cool-password", instance.RootPassword); diff != "" {
+							t.Errorf("createInstance() root password -want, +got: %s", diff)
+						}
+						return &sqladmin.Operation{Name: "test-operation"}, nil
+					},
+				},
+			},
+			want: want{
+				status: v1alpha1.CloudsqlInstanceStatus{
+					Phase:          v1alpha1.PhaseCreating,
+					Operation:      "test-operation",
+					ResourceStatus: *newInstanceStatus().withConditions(corev1alpha1.Creating()).build(),
+				},
+			},
+		},
+		"SQLServerSecretFailure": {
+			fields: fields{
+				obj: &v1alpha1.CloudsqlInstance{
+					ObjectMeta: testMeta,
+					Spec:       v1alpha1.CloudsqlInstanceSpec{DatabaseVersion: "SQLSERVER_2019_STANDARD"},
+				},
+				local: &mockLocalOperations{
+					mockUpdateConnectionSecret: func(ctx context.Context) (*core.Secret, error) { return nil, errTest },
+				},
+			},
+			want: want{
+				status: v1alpha1.CloudsqlInstanceStatus{
+					ResourceStatus: *newInstanceStatus().withConditions(corev1alpha1.Creating()).build(),
+				},
+				err: errors.Wrap(errTest, "failed to update connection secret"),
+			},
+		},
+		"AlreadyExists": {
+			fields: fields{
+				obj: &v1alpha1.CloudsqlInstance{
+					ObjectMeta: testMeta,
+				},
+				instance: &fake.MockInstanceClient{
+					MockCreate: func(ctx context.Context, instance *sqladmin.DatabaseInstance) (*sqladmin.Operation, error) {
+						return nil, &googleapi.Error{Code: http.StatusConflict}
+					},
+				},
+			},
+			want: want{
+				status: v1alpha1.CloudsqlInstanceStatus{
+					Phase:          v1alpha1.PhaseCreating,
+					ResourceStatus: *newInstanceStatus().withConditions(corev1alpha1.Creating()).build(),
+				},
+			},
+		},
+		"Failure": {
+			fields: fields{
+				obj: &v1alpha1.CloudsqlInstance{
+					ObjectMeta: testMeta,
+				},
+				instance: &fake.MockInstanceClient{
+					MockCreate: func(ctx context.Context, instance *sqladmin.DatabaseInstance) (*sqladmin.Operation, error) {
+						return nil, errTest
 					},
 				},
 			},
@@ -1012,6 +1681,7 @@ func Test_managedHandler_createInstance(t *testing.T) {
 				status: v1alpha1.CloudsqlInstanceStatus{
 					ResourceStatus: *newInstanceStatus().withConditions(corev1alpha1.Creating()).build(),
 				},
+				err: errTest,
 			},
 		},
 	}
@@ -1019,9 +1689,10 @@ func Test_managedHandler_createInstance(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			ih := &managedHandler{
 				CloudsqlInstance: tt.fields.obj,
+				localOperations:  tt.fields.local,
 				instance:         tt.fields.instance,
 			}
-			if diff := cmp.Diff(tt.want.err, ih.createInstance(tt.args.ctx)); diff != "" {
+			if diff := cmp.Diff(tt.want.err, ih.createInstance(tt.args.ctx), test.EquateErrors()); diff != "" {
 				t.Errorf("createInstance() error -want, +got: %s", diff)
 			}
 			if diff := cmp.Diff(tt.want.status, tt.fields.obj.Status); diff != "" {
@@ -1084,6 +1755,40 @@ func Test_managedHandler_updateInstance(t *testing.T) {
 	}
 }
 
+func Test_managedHandler_disableDeletionProtection(t *testing.T) {
+	obj := &v1alpha1.CloudsqlInstance{ObjectMeta: testMeta}
+	obj.Spec.DeletionProtectionEnabled = true
+
+	ih := &managedHandler{
+		CloudsqlInstance: obj,
+		instance: &fake.MockInstanceClient{
+			MockGet: func(ctx context.Context, name string) (*sqladmin.DatabaseInstance, error) {
+				return &sqladmin.DatabaseInstance{Settings: &sqladmin.Settings{Tier: "db-custom-4-16384", DeletionProtectionEnabled: true, SettingsVersion: 42}}, nil
+			},
+			MockUpdate: func(ctx context.Context, name string, instance *sqladmin.DatabaseInstance) error {
+				t.Errorf("disableDeletionProtection() unexpected call to Update")
+				return nil
+			},
+			MockPatch: func(ctx context.Context, name string, instance *sqladmin.DatabaseInstance) error {
+				if diff := cmp.Diff(getExpectedInstanceName(testUID), name); diff != "" {
+					t.Errorf("disableDeletionProtection() name -want, +got: %s", diff)
+				}
+				want := &sqladmin.DatabaseInstance{Settings: &sqladmin.Settings{
+					SettingsVersion: 42,
+					ForceSendFields: []string{"DeletionProtectionEnabled"},
+				}}
+				if diff := cmp.Diff(want, instance); diff != "" {
+					t.Errorf("disableDeletionProtection() patch -want, +got: %s", diff)
+				}
+				return nil
+			},
+		},
+	}
+	if err := ih.disableDeletionProtection(context.Background()); err != nil {
+		t.Errorf("disableDeletionProtection() unexpected error: %v", err)
+	}
+}
+
 func Test_managedHandler_deleteInstance(t *testing.T) {
 	type fields struct {
 		obj      *v1alpha1.CloudsqlInstance
@@ -1266,6 +1971,24 @@ func Test_managedHandler_updateUserCreds(t *testing.T) {
 			},
 			want: errors.Wrapf(errTest, "failed to get user"),
 		},
+		"DryRun": {
+			fields: fields{
+				obj: &v1alpha1.CloudsqlInstance{},
+				ops: &mockLocalOperations{
+					mockIsDryRun: func() bool { return true },
+					mockUpdateConnectionSecret: func(ctx context.Context) (*core.Secret, error) {
+						t.Errorf("updateUserCreds() should not update the connection secret in dry-run mode")
+						return nil, nil
+					},
+				},
+				user: &fake.MockUserClient{
+					MockUpdate: func(ctx context.Context, s string, s2 string, user *sqladmin.User) error {
+						t.Errorf("updateUserCreds() should not update the user in dry-run mode")
+						return nil
+					},
+				},
+			},
+		},
 		"SuccessfulUpdate": {
 			fields: fields{
 				obj: &v1alpha1.CloudsqlInstance{},
@@ -1333,3 +2056,40 @@ func Test_newManagedHandler(t *testing.T) {
 		})
 	}
 }
+
+func Test_managedHandler_enableDisabledService(t *testing.T) {
+	enable := func(enabling bool, err error) func(context.Context, error) (bool, error) {
+		return func(context.Context, error) (bool, error) { return enabling, err }
+	}
+	errEnable := errors.New("test-enable-error")
+
+	tests := map[string]struct {
+		enableService func(context.Context, error) (bool, error)
+		want          error
+	}{
+		"Unset": {
+			want: errTest,
+		},
+		"NotDisabled": {
+			enableService: enable(false, nil),
+			want:          errTest,
+		},
+		"Enabling": {
+			enableService: enable(true, nil),
+			want:          errors.Wrap(errTest, "enabling disabled service; will retry"),
+		},
+		"EnableFailed": {
+			enableService: enable(false, errEnable),
+			want:          errEnable,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &managedHandler{enableService: tt.enableService}
+			err := h.enableDisabledService(context.Background(), errTest)
+			if diff := cmp.Diff(tt.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("enableDisabledService() error -want, +got: %s", diff)
+			}
+		})
+	}
+}
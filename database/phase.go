@@ -0,0 +1,91 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/deadline"
+)
+
+// operationDone is the status of a Cloud SQL operation that has completed,
+// successfully or otherwise.
+const operationDone = "DONE"
+
+// phaseFor returns the lifecycle phase of an instance that GCP reports to be
+// in the supplied state. States that don't imply a phase, such as maintenance
+// or suspension, leave the current phase of a created instance unchanged.
+func phaseFor(state string, current v1alpha1.CloudsqlInstancePhase) v1alpha1.CloudsqlInstancePhase {
+	switch state {
+	case v1alpha1.StatePendingCreate:
+		return v1alpha1.PhaseCreating
+	case v1alpha1.StateRunnable:
+		return v1alpha1.PhaseRunning
+	case v1alpha1.StateFailed:
+		return v1alpha1.PhaseFailed
+	}
+	if current == "" || current == v1alpha1.PhasePending {
+		// GCP knows about the instance, so it has at least begun creating it.
+		return v1alpha1.PhaseCreating
+	}
+	return current
+}
+
+// operationError returns an error describing why the supplied operation
+// failed, or nil if it did not fail.
+func operationError(op *sqladmin.Operation) error {
+	if op.Error == nil || len(op.Error.Errors) == 0 {
+		return nil
+	}
+	return &operationFailure{name: op.Name, errors: op.Error.Errors}
+}
+
+// An operationFailure is the error of a failed operation. It retains the
+// operation's errors so that they may be classified.
+type operationFailure struct {
+	name   string
+	errors []*sqladmin.OperationError
+}
+
+func (f *operationFailure) Error() string {
+	msgs := make([]string, 0, len(f.errors))
+	for _, e := range f.errors {
+		msgs = append(msgs, e.Message)
+	}
+	return fmt.Sprintf("operation %s failed: %s", f.name, strings.Join(msgs, "; "))
+}
+
+// observe updates the status of the supplied CloudsqlInstance to reflect the
+// observed state of its GCP instance.
+func observe(i *v1alpha1.CloudsqlInstance, inst *sqladmin.DatabaseInstance) {
+	i.SetStatus(inst)
+	if inst == nil {
+		return
+	}
+	i.Status.Phase = phaseFor(inst.State, i.Status.Phase)
+	if i.Status.Phase == v1alpha1.PhaseRunning && deadline.IsStalled(i.Status.ConditionedStatus) {
+		i.Status.SetConditions(deadline.Recovered())
+	}
+	i.Status.ServiceAccountEmail = inst.ServiceAccountEmailAddress
+	if inst.Settings != nil {
+		i.Status.Tier = inst.Settings.Tier
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/deadline"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func Test_phaseFor(t *testing.T) {
+	tests := map[string]struct {
+		state   string
+		current v1alpha1.CloudsqlInstancePhase
+		want    v1alpha1.CloudsqlInstancePhase
+	}{
+		"PendingCreate": {
+			state:   v1alpha1.StatePendingCreate,
+			current: v1alpha1.PhasePending,
+			want:    v1alpha1.PhaseCreating,
+		},
+		"Runnable": {
+			state:   v1alpha1.StateRunnable,
+			current: v1alpha1.PhaseCreating,
+			want:    v1alpha1.PhaseRunning,
+		},
+		"Failed": {
+			state:   v1alpha1.StateFailed,
+			current: v1alpha1.PhaseCreating,
+			want:    v1alpha1.PhaseFailed,
+		},
+		"MaintenanceWhileRunning": {
+			state:   v1alpha1.StateMaintenance,
+			current: v1alpha1.PhaseRunning,
+			want:    v1alpha1.PhaseRunning,
+		},
+		"UnknownStateWithoutPhase": {
+			state: "thinking-about",
+			want:  v1alpha1.PhaseCreating,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := phaseFor(tt.state, tt.current); got != tt.want {
+				t.Errorf("phaseFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_operationError(t *testing.T) {
+	tests := map[string]struct {
+		op   *sqladmin.Operation
+		want error
+	}{
+		"Succeeded": {
+			op:   &sqladmin.Operation{Name: "test-operation", Status: operationDone},
+			want: nil,
+		},
+		"Failed": {
+			op: &sqladmin.Operation{
+				Name:   "test-operation",
+				Status: operationDone,
+				Error: &sqladmin.OperationErrors{Errors: []*sqladmin.OperationError{
+					{Message: "quota exceeded"},
+					{Message: "try again later"},
+				}},
+			},
+			want: errors.New("operation test-operation failed: quota exceeded; try again later"),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, operationError(tt.op), test.EquateErrors()); diff != "" {
+				t.Errorf("operationError() -want, +got: %s", diff)
+			}
+		})
+	}
+}
+
+func Test_observeRecovered(t *testing.T) {
+	i := &v1alpha1.CloudsqlInstance{}
+	i.Status.Phase = v1alpha1.PhaseCreating
+	i.Status.SetConditions(deadline.Stalled(deadline.DefaultCreationDeadline, ""))
+
+	observe(i, &sqladmin.DatabaseInstance{State: v1alpha1.StatePendingCreate})
+	if !deadline.IsStalled(i.Status.ConditionedStatus) {
+		t.Errorf("observe(...): want creating instance to remain stalled")
+	}
+
+	observe(i, &sqladmin.DatabaseInstance{State: v1alpha1.StateRunnable})
+	want := corev1alpha1.ConditionedStatus{}
+	want.SetConditions(deadline.Recovered())
+	if diff := cmp.Diff(want, i.Status.ConditionedStatus, test.EquateConditions()); diff != "" {
+		t.Errorf("observe(...): -want, +got:\n%s", diff)
+	}
+}
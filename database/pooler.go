@@ -0,0 +1,473 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/util"
+)
+
+// A connection pooler is a PgBouncer or ProxySQL Deployment and Service that
+// pool connections to an instance on behalf of applications. It may run in a
+// namespace other than the instance's if the operator allows it, so it is tied
+// to the instance by labels and by the instance's status rather than by owner
+// references.
+const (
+	poolerNameSuffix = "-pooler"
+
+	// poolerConfigKey is the key of the pooler Secret holding the pooler's
+	// configuration file.
+	poolerConfigKey = "pooler.conf"
+
+	// poolerUserListKey is the key of the pooler Secret holding PgBouncer's
+	// auth_file.
+	poolerUserListKey = "userlist.txt"
+
+	poolerConfigDir = "/etc/pooler"
+
+	labelPoolerName      = "app.kubernetes.io/name"
+	labelPoolerInstance  = "app.kubernetes.io/instance"
+	labelPoolerManagedBy = "app.kubernetes.io/managed-by"
+	poolerManagedBy      = "crossplane"
+
+	// annotationPoolerConfig records a digest of the pooler's configuration
+	// on its pods, so that they are restarted when it changes.
+	annotationPoolerConfig = "cloudsql.gcp.crossplane.io/pooler-config"
+)
+
+// Pool modes supported by PgBouncer.
+const (
+	poolModeSession     = "session"
+	poolModeTransaction = "transaction"
+	poolModeStatement   = "statement"
+)
+
+// A poolerKind describes how to run the connection pooler of an engine.
+type poolerKind struct {
+	name       string
+	image      string
+	port       int32
+	serverPort int
+	command    []string
+}
+
+var (
+	pgBouncer = poolerKind{
+		name:       "pgbouncer",
+		image:      "bitnami/pgbouncer:1.22.1",
+		port:       6432,
+		serverPort: 5432,
+		command:    []string{"pgbouncer", poolerConfigDir + "/" + poolerConfigKey},
+	}
+	proxySQL = poolerKind{
+		name:       "proxysql",
+		image:      "proxysql/proxysql:2.6.2",
+		port:       6033,
+		serverPort: 3306,
+		command:    []string{"proxysql", "-f", "-c", poolerConfigDir + "/" + poolerConfigKey},
+	}
+)
+
+// PoolerConfig configures the connection poolers that instances may request.
+// It is set by the operator rather than by each instance, because a pooler
+// runs with the instance's credentials.
+type PoolerConfig struct {
+	// PgBouncerImage and ProxySQLImage are the images run by PostgreSQL and
+	// MySQL connection poolers. Defaults are used if they are empty.
+	PgBouncerImage string
+	ProxySQLImage  string
+
+	// Namespaces in which instances of any namespace may run their connection
+	// pooler. An instance may always run its pooler in its own namespace.
+	Namespaces []string
+}
+
+// image returns the image that runs the supplied kind of connection pooler.
+func (c PoolerConfig) image(kind poolerKind) string {
+	switch {
+	case kind.name == pgBouncer.name && c.PgBouncerImage != "":
+		return c.PgBouncerImage
+	case kind.name == proxySQL.name && c.ProxySQLImage != "":
+		return c.ProxySQLImage
+	}
+	return kind.image
+}
+
+// allows returns an error unless an instance of namespace from may run its
+// connection pooler in namespace to.
+func (c PoolerConfig) allows(from, to string) error {
+	if to == from {
+		return nil
+	}
+	for _, ns := range c.Namespaces {
+		if ns == to {
+			return nil
+		}
+	}
+	return errors.Errorf("connectionPooler namespace %s is not allowed for instances in namespace %s", to, from)
+}
+
+// poolerKindFor returns the connection pooler for the supplied database
+// version, and false if its engine is not supported.
+func poolerKindFor(version string) (poolerKind, bool) {
+	switch {
+	case isEngine(version, v1alpha1.PostgresqlDBVersionPrefix):
+		return pgBouncer, true
+	case isEngine(version, v1alpha1.MysqlDBVersionPrefix):
+		return proxySQL, true
+	}
+	return poolerKind{}, false
+}
+
+// validateConnectionPooler returns an error if the supplied spec requests a
+// connection pooler that cannot be deployed.
+func validateConnectionPooler(spec v1alpha1.CloudsqlInstanceSpec) error {
+	p := spec.ConnectionPooler
+	if p == nil {
+		return nil
+	}
+	if _, ok := poolerKindFor(spec.DatabaseVersion); !ok {
+		return errors.New("connectionPooler is only supported by PostgreSQL and MySQL instances")
+	}
+	if p.Image != "" {
+		return errors.New("connectionPooler image is configured by the operator and must not be set")
+	}
+	if p.Namespace != "" {
+		if errs := validation.IsDNS1123Label(p.Namespace); len(errs) > 0 {
+			return errors.Errorf("connectionPooler namespace %q is invalid: %s", p.Namespace, strings.Join(errs, "; "))
+		}
+	}
+	switch p.PoolMode {
+	case "", poolModeSession, poolModeTransaction, poolModeStatement:
+	default:
+		return errors.Errorf("connectionPooler poolMode %q is not supported; use one of %s, %s, %s", p.PoolMode, poolModeSession, poolModeTransaction, poolModeStatement)
+	}
+	if p.PoolMode != "" && !isEngine(spec.DatabaseVersion, v1alpha1.PostgresqlDBVersionPrefix) {
+		return errors.New("connectionPooler poolMode is only supported by PostgreSQL instances")
+	}
+	if p.MaxClientConnections < 0 || p.DefaultPoolSize < 0 {
+		return errors.New("connectionPooler maxClientConnections and defaultPoolSize must not be negative")
+	}
+	if p.Replicas != nil && *p.Replicas < 0 {
+		return errors.New("connectionPooler replicas must not be negative")
+	}
+	return nil
+}
+
+// poolerNamespace returns the namespace in which the instance's connection
+// pooler should run. It may not be one the operator allows.
+func (h *localHandler) poolerNamespace() string {
+	if ns := h.Spec.ConnectionPooler.Namespace; ns != "" {
+		return ns
+	}
+	return h.GetNamespace()
+}
+
+// poolerName returns the name of the instance's connection pooler objects.
+// The name of the Cloud SQL instance is unique within its project, so it may
+// be used in any namespace.
+func (h *localHandler) poolerName() string {
+	return instanceName(h.CloudsqlInstance) + poolerNameSuffix
+}
+
+// reconcileConnectionPooler deploys the connection pooler requested by the
+// instance's spec, wired to its connection secret, and removes the pooler it
+// previously deployed if none is requested or it has moved namespace.
+func (h *localHandler) reconcileConnectionPooler(ctx context.Context) error {
+	if h.isDryRun() {
+		return nil
+	}
+	if h.Spec.ConnectionPooler == nil {
+		if err := h.deleteConnectionPooler(ctx); err != nil {
+			return err
+		}
+		h.Status.ConnectionPoolerEndpoint = ""
+		return nil
+	}
+	if ns := h.Status.ConnectionPoolerNamespace; ns != "" && ns != h.poolerNamespace() {
+		if err := h.deleteConnectionPooler(ctx); err != nil {
+			return err
+		}
+	}
+
+	kind, ok := poolerKindFor(h.Spec.DatabaseVersion)
+	if !ok {
+		return validateConnectionPooler(h.Spec)
+	}
+	if err := h.pooler.allows(h.GetNamespace(), h.poolerNamespace()); err != nil {
+		return err
+	}
+
+	cs, err := h.getConnectionSecret(ctx)
+	if err != nil {
+		return errors.Wrap(err, "cannot get connection secret")
+	}
+	config, err := poolerConfig(kind, h.Spec.ConnectionPooler, cs)
+	if err != nil {
+		return err
+	}
+
+	ns, name := h.poolerNamespace(), h.poolerName()
+	labels := map[string]string{
+		labelPoolerName:      kind.name,
+		labelPoolerInstance:  name,
+		labelPoolerManagedBy: poolerManagedBy,
+	}
+	endpoint := fmt.Sprintf("%s.%s.svc", name, ns)
+
+	// The pooler's Secret holds its configuration, including the credentials
+	// it uses to connect to the instance. It also holds the credentials
+	// applications use to connect to the pooler.
+	s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+	if err := h.applyPoolerObject(ctx, s, func() error {
+		s.Labels = labels
+		s.Data = config
+		s.Data[corev1alpha1.ResourceCredentialsSecretEndpointKey] = []byte(endpoint)
+		s.Data[ConnectionSecretPortKey] = []byte(strconv.Itoa(int(kind.port)))
+		s.Data[corev1alpha1.ResourceCredentialsSecretUserKey] = cs.Data[corev1alpha1.ResourceCredentialsSecretUserKey]
+		s.Data[corev1alpha1.ResourceCredentialsSecretPasswordKey] = cs.Data[corev1alpha1.ResourceCredentialsSecretPasswordKey]
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+	if err := h.applyPoolerObject(ctx, d, func() error {
+		d.Labels = labels
+		d.Spec = poolerDeploymentSpec(kind, h.pooler.image(kind), h.Spec.ConnectionPooler, labels, name, configDigest(config))
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+	if err := h.applyPoolerObject(ctx, svc, func() error {
+		svc.Labels = labels
+		// The cluster IP is allocated by the API server and is immutable, so
+		// we update only the fields we own.
+		svc.Spec.Type = corev1.ServiceTypeClusterIP
+		svc.Spec.Selector = labels
+		svc.Spec.Ports = []corev1.ServicePort{{
+			Name:       kind.name,
+			Port:       kind.port,
+			TargetPort: intstr.FromInt(int(kind.port)),
+			Protocol:   corev1.ProtocolTCP,
+		}}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	h.Status.ConnectionPoolerNamespace = ns
+	h.Status.ConnectionPoolerEndpoint = fmt.Sprintf("%s:%d", endpoint, kind.port)
+	return nil
+}
+
+// applyPoolerObject creates or updates the supplied connection pooler object
+// using the supplied function. It refuses to update an object that it did not
+// create.
+func (h *localHandler) applyPoolerObject(ctx context.Context, o interface {
+	runtime.Object
+	metav1.Object
+}, mutate func() error) error {
+	err := util.CreateOrUpdate(ctx, h.client, o, func() error {
+		if o.GetResourceVersion() != "" && o.GetLabels()[labelPoolerManagedBy] != poolerManagedBy {
+			return errors.Errorf("%s/%s exists and is not managed by crossplane", o.GetNamespace(), o.GetName())
+		}
+		return mutate()
+	})
+	return errors.Wrapf(err, "cannot apply connection pooler %T %s/%s", o, o.GetNamespace(), o.GetName())
+}
+
+// deleteConnectionPooler deletes the connection pooler the instance
+// previously deployed, if any.
+func (h *localHandler) deleteConnectionPooler(ctx context.Context) error {
+	ns := h.Status.ConnectionPoolerNamespace
+	if ns == "" {
+		return nil
+	}
+	name := h.poolerName()
+	for _, o := range []runtime.Object{
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}},
+	} {
+		if err := h.client.Delete(ctx, o); err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrapf(err, "cannot delete connection pooler %T %s/%s", o, ns, name)
+		}
+	}
+	h.Status.ConnectionPoolerNamespace = ""
+	return nil
+}
+
+// poolerDeploymentSpec returns the spec of a Deployment that runs the supplied
+// kind of connection pooler using the supplied image, configured by the named
+// Secret whose configuration has the supplied digest.
+func poolerDeploymentSpec(kind poolerKind, image string, p *v1alpha1.ConnectionPoolerSpec, labels map[string]string, secret, digest string) appsv1.DeploymentSpec {
+	replicas := int32(1)
+	if p.Replicas != nil {
+		replicas = *p.Replicas
+	}
+
+	return appsv1.DeploymentSpec{
+		Replicas: &replicas,
+		Selector: &metav1.LabelSelector{MatchLabels: labels},
+		Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels:      labels,
+				Annotations: map[string]string{annotationPoolerConfig: digest},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:    kind.name,
+					Image:   image,
+					Command: kind.command,
+					Ports:   []corev1.ContainerPort{{Name: kind.name, ContainerPort: kind.port, Protocol: corev1.ProtocolTCP}},
+					ReadinessProbe: &corev1.Probe{
+						Handler: corev1.Handler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(int(kind.port))}},
+					},
+					VolumeMounts: []corev1.VolumeMount{{Name: "config", MountPath: poolerConfigDir, ReadOnly: true}},
+				}},
+				Volumes: []corev1.Volume{{
+					Name: "config",
+					VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+						SecretName: secret,
+						Items: []corev1.KeyToPath{
+							{Key: poolerConfigKey, Path: poolerConfigKey},
+							{Key: poolerUserListKey, Path: poolerUserListKey},
+						},
+					}},
+				}},
+			},
+		},
+	}
+}
+
+// configDigest returns a digest of the supplied pooler configuration.
+func configDigest(config map[string][]byte) string {
+	d := sha256.New()
+	for _, k := range []string{poolerConfigKey, poolerUserListKey} {
+		d.Write([]byte(k))
+		d.Write(config[k])
+	}
+	return fmt.Sprintf("%x", d.Sum(nil))
+}
+
+// poolerConfig returns the configuration files of the supplied kind of
+// connection pooler, which connects to the instance using the credentials in
+// the supplied connection secret.
+func poolerConfig(kind poolerKind, p *v1alpha1.ConnectionPoolerSpec, cs *corev1.Secret) (map[string][]byte, error) {
+	host := string(cs.Data[corev1alpha1.ResourceCredentialsSecretEndpointKey])
+	user := string(cs.Data[corev1alpha1.ResourceCredentialsSecretUserKey])
+	password := string(cs.Data[corev1alpha1.ResourceCredentialsSecretPasswordKey])
+	if host == "" || user == "" {
+		return nil, errors.New("connection secret has no endpoint or user yet")
+	}
+
+	maxClients, poolSize := p.MaxClientConnections, p.DefaultPoolSize
+	if maxClients == 0 {
+		maxClients = 100
+	}
+	if poolSize == 0 {
+		poolSize = 20
+	}
+
+	if kind.name == proxySQL.name {
+		return map[string][]byte{
+			poolerConfigKey:   []byte(proxySQLConfig(host, kind, user, password, maxClients, poolSize)),
+			poolerUserListKey: {},
+		}, nil
+	}
+
+	mode := p.PoolMode
+	if mode == "" {
+		mode = poolModeTransaction
+	}
+	return map[string][]byte{
+		poolerConfigKey:   []byte(pgBouncerConfig(host, kind, mode, maxClients, poolSize)),
+		poolerUserListKey: []byte(pgBouncerQuote(user) + " " + pgBouncerQuote(password) + "\n"),
+	}, nil
+}
+
+// pgBouncerConfig returns a pgbouncer.ini that pools connections to every
+// database of the supplied host.
+func pgBouncerConfig(host string, kind poolerKind, mode string, maxClients, poolSize int32) string {
+	return fmt.Sprintf(`[databases]
+* = host=%s port=%d
+
+[pgbouncer]
+listen_addr = 0.0.0.0
+listen_port = %d
+auth_type = scram-sha-256
+auth_file = %s/%s
+pool_mode = %s
+max_client_conn = %d
+default_pool_size = %d
+ignore_startup_parameters = extra_float_digits
+`, host, kind.serverPort, kind.port, poolerConfigDir, poolerUserListKey, mode, maxClients, poolSize)
+}
+
+// pgBouncerQuote quotes the supplied string for a PgBouncer auth_file.
+func pgBouncerQuote(s string) string {
+	return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+}
+
+// proxySQLConfig returns a proxysql.cnf that pools connections to the
+// supplied host. The admin interface listens only on localhost.
+func proxySQLConfig(host string, kind poolerKind, user, password string, maxClients, poolSize int32) string {
+	return fmt.Sprintf(`datadir="/var/lib/proxysql"
+
+admin_variables=
+{
+	mysql_ifaces="127.0.0.1:6032"
+}
+
+mysql_variables=
+{
+	interfaces="0.0.0.0:%d"
+	max_connections=%d
+	monitor_username=%s
+	monitor_password=%s
+}
+
+mysql_servers=
+(
+	{ address=%s, port=%d, hostgroup=0, max_connections=%d }
+)
+
+mysql_users=
+(
+	{ username=%s, password=%s, default_hostgroup=0 }
+)
+`, kind.port, maxClients, strconv.Quote(user), strconv.Quote(password), strconv.Quote(host), kind.serverPort, poolSize, strconv.Quote(user), strconv.Quote(password))
+}
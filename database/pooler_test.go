@@ -0,0 +1,225 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestValidateConnectionPooler(t *testing.T) {
+	negative := int32(-1)
+
+	cases := map[string]struct {
+		spec v1alpha1.CloudsqlInstanceSpec
+		want error
+	}{
+		"NoPooler": {
+			spec: v1alpha1.CloudsqlInstanceSpec{DatabaseVersion: "SQLSERVER_2019_STANDARD"},
+		},
+		"PostgreSQL": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion:  "POSTGRES_15",
+				ConnectionPooler: &v1alpha1.ConnectionPoolerSpec{Namespace: "apps", PoolMode: poolModeSession},
+			},
+		},
+		"MySQL": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion:  "MYSQL_8_0",
+				ConnectionPooler: &v1alpha1.ConnectionPoolerSpec{},
+			},
+		},
+		"SQLServer": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion:  "SQLSERVER_2019_STANDARD",
+				ConnectionPooler: &v1alpha1.ConnectionPoolerSpec{},
+			},
+			want: errors.New("connectionPooler is only supported by PostgreSQL and MySQL instances"),
+		},
+		"InvalidNamespace": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion:  "POSTGRES_15",
+				ConnectionPooler: &v1alpha1.ConnectionPoolerSpec{Namespace: "Apps"},
+			},
+			want: errors.Errorf("connectionPooler namespace %q is invalid: %s", "Apps", strings.Join(validation.IsDNS1123Label("Apps"), "; ")),
+		},
+		"UnknownPoolMode": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion:  "POSTGRES_15",
+				ConnectionPooler: &v1alpha1.ConnectionPoolerSpec{PoolMode: "cool"},
+			},
+			want: errors.New(`connectionPooler poolMode "cool" is not supported; use one of session, transaction, statement`),
+		},
+		"MySQLPoolMode": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion:  "MYSQL_8_0",
+				ConnectionPooler: &v1alpha1.ConnectionPoolerSpec{PoolMode: poolModeTransaction},
+			},
+			want: errors.New("connectionPooler poolMode is only supported by PostgreSQL instances"),
+		},
+		"Image": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion:  "POSTGRES_15",
+				ConnectionPooler: &v1alpha1.ConnectionPoolerSpec{Image: "cool/pgbouncer"},
+			},
+			want: errors.New("connectionPooler image is configured by the operator and must not be set"),
+		},
+		"NegativeReplicas": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion:  "POSTGRES_15",
+				ConnectionPooler: &v1alpha1.ConnectionPoolerSpec{Replicas: &negative},
+			},
+			want: errors.New("connectionPooler replicas must not be negative"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := validateConnectionPooler(tc.spec)
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("validateConnectionPooler(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPoolerConfig(t *testing.T) {
+	cs := &corev1.Secret{Data: map[string][]byte{
+		corev1alpha1.ResourceCredentialsSecretEndpointKey: []byte("10.0.0.3"),
+		corev1alpha1.ResourceCredentialsSecretUserKey:     []byte("postgres"),
+		corev1alpha1.ResourceCredentialsSecretPasswordKey: []byte(`pa"ss`),
+	}}
+
+	got, err := poolerConfig(pgBouncer, &v1alpha1.ConnectionPoolerSpec{}, cs)
+	if err != nil {
+		t.Fatalf("poolerConfig(...): %s", err)
+	}
+	if diff := cmp.Diff("\"postgres\" \"pa\"\"ss\"\n", string(got[poolerUserListKey])); diff != "" {
+		t.Errorf("poolerConfig(...): -want userlist, +got userlist:\n%s", diff)
+	}
+	for _, want := range []string{"* = host=10.0.0.3 port=5432", "listen_port = 6432", "pool_mode = transaction", "max_client_conn = 100"} {
+		if !strings.Contains(string(got[poolerConfigKey]), want) {
+			t.Errorf("poolerConfig(...): want config containing %q, got:\n%s", want, got[poolerConfigKey])
+		}
+	}
+
+	got, err = poolerConfig(proxySQL, &v1alpha1.ConnectionPoolerSpec{DefaultPoolSize: 5}, cs)
+	if err != nil {
+		t.Fatalf("poolerConfig(...): %s", err)
+	}
+	for _, want := range []string{`address="10.0.0.3", port=3306, hostgroup=0, max_connections=5`, `password="pa\"ss"`} {
+		if !strings.Contains(string(got[poolerConfigKey]), want) {
+			t.Errorf("poolerConfig(...): want config containing %q, got:\n%s", want, got[poolerConfigKey])
+		}
+	}
+
+	_, err = poolerConfig(pgBouncer, &v1alpha1.ConnectionPoolerSpec{}, &corev1.Secret{})
+	if diff := cmp.Diff(errors.New("connection secret has no endpoint or user yet"), err, test.EquateErrors()); diff != "" {
+		t.Errorf("poolerConfig(...): -want error, +got error:\n%s", diff)
+	}
+}
+
+func TestReconcileConnectionPooler(t *testing.T) {
+	ctx := context.Background()
+	poolerNs := "apps"
+
+	inst := &v1alpha1.CloudsqlInstance{ObjectMeta: *testMeta.DeepCopy()}
+	inst.Spec.DatabaseVersion = "POSTGRES_15"
+	inst.Spec.WriteConnectionSecretToReference = corev1.LocalObjectReference{Name: testName}
+	inst.Spec.ConnectionPooler = &v1alpha1.ConnectionPoolerSpec{Namespace: poolerNs}
+
+	cs := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNs, Name: testName},
+		Data: map[string][]byte{
+			corev1alpha1.ResourceCredentialsSecretEndpointKey: []byte("10.0.0.3"),
+			corev1alpha1.ResourceCredentialsSecretUserKey:     []byte("postgres"),
+			corev1alpha1.ResourceCredentialsSecretPasswordKey: []byte("secret"),
+		},
+	}
+	kube := fakeclient.NewFakeClient(cs)
+	h := newLocalHandler(inst, kube)
+
+	// The pooler may not run in another namespace unless the operator allows it.
+	wantErr := errors.Errorf("connectionPooler namespace %s is not allowed for instances in namespace %s", poolerNs, testNs)
+	if diff := cmp.Diff(wantErr, h.reconcileConnectionPooler(ctx), test.EquateErrors()); diff != "" {
+		t.Errorf("h.reconcileConnectionPooler(...): -want error, +got error:\n%s", diff)
+	}
+
+	h.pooler = PoolerConfig{PgBouncerImage: "cool/pgbouncer:1.0", Namespaces: []string{poolerNs}}
+	if err := h.reconcileConnectionPooler(ctx); err != nil {
+		t.Fatalf("h.reconcileConnectionPooler(...): %s", err)
+	}
+
+	name := instanceName(inst) + poolerNameSuffix
+	key := types.NamespacedName{Namespace: poolerNs, Name: name}
+
+	d := &appsv1.Deployment{}
+	if err := kube.Get(ctx, key, d); err != nil {
+		t.Fatalf("kube.Get(...): cannot get pooler deployment: %s", err)
+	}
+	if diff := cmp.Diff("cool/pgbouncer:1.0", d.Spec.Template.Spec.Containers[0].Image); diff != "" {
+		t.Errorf("h.reconcileConnectionPooler(...): -want image, +got image:\n%s", diff)
+	}
+
+	s := &corev1.Secret{}
+	if err := kube.Get(ctx, key, s); err != nil {
+		t.Fatalf("kube.Get(...): cannot get pooler secret: %s", err)
+	}
+	wantEndpoint := name + "." + poolerNs + ".svc"
+	if diff := cmp.Diff(wantEndpoint, string(s.Data[corev1alpha1.ResourceCredentialsSecretEndpointKey])); diff != "" {
+		t.Errorf("h.reconcileConnectionPooler(...): -want secret endpoint, +got secret endpoint:\n%s", diff)
+	}
+
+	if err := kube.Get(ctx, key, &corev1.Service{}); err != nil {
+		t.Fatalf("kube.Get(...): cannot get pooler service: %s", err)
+	}
+
+	if diff := cmp.Diff(poolerNs, inst.Status.ConnectionPoolerNamespace); diff != "" {
+		t.Errorf("h.reconcileConnectionPooler(...): -want status namespace, +got status namespace:\n%s", diff)
+	}
+	if diff := cmp.Diff(wantEndpoint+":6432", inst.Status.ConnectionPoolerEndpoint); diff != "" {
+		t.Errorf("h.reconcileConnectionPooler(...): -want status endpoint, +got status endpoint:\n%s", diff)
+	}
+
+	// Removing the pooler from the spec deletes it.
+	inst.Spec.ConnectionPooler = nil
+	if err := h.reconcileConnectionPooler(ctx); err != nil {
+		t.Fatalf("h.reconcileConnectionPooler(...): %s", err)
+	}
+	if err := kube.Get(ctx, key, &appsv1.Deployment{}); !kerrors.IsNotFound(err) {
+		t.Errorf("kube.Get(...): want pooler deployment to be deleted, got error %v", err)
+	}
+	if inst.Status.ConnectionPoolerNamespace != "" || inst.Status.ConnectionPoolerEndpoint != "" {
+		t.Errorf("h.reconcileConnectionPooler(...): want pooler status to be cleared, got %q, %q",
+			inst.Status.ConnectionPoolerNamespace, inst.Status.ConnectionPoolerEndpoint)
+	}
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+)
+
+const (
+	// AnnotationStorageGB may be set on an instance claim to request the
+	// storage size, in GB, of the CloudsqlInstance it is bound to. It may be
+	// increased after the claim is bound, but never decreased; Cloud SQL
+	// cannot shrink the disk of an instance.
+	AnnotationStorageGB = "database.gcp.crossplane.io/storage-gb"
+
+	// EventReasonCannotPropagate is the reason of events recording why a
+	// claim's changes could not be propagated to its instance.
+	EventReasonCannotPropagate = "CannotPropagate"
+
+	propagatorTimeout = 1 * time.Minute
+)
+
+// configureClaimFields configures the supplied spec using the mutable fields
+// of the supplied instance claim.
+func configureClaimFields(cm resource.Claim, spec *v1alpha1.CloudsqlInstanceSpec) error {
+	gb, ok, err := claimStorageGB(cm)
+	if err != nil || !ok {
+		return err
+	}
+	spec.StorageGB = gb
+	return nil
+}
+
+// propagateClaimFields updates the supplied spec of a bound instance to match
+// the mutable fields of the supplied instance claim. It returns true if the
+// spec was changed, and an error if the claim requests a change that can't
+// be made to an existing instance.
+func propagateClaimFields(cm resource.Claim, spec *v1alpha1.CloudsqlInstanceSpec) (bool, error) {
+	gb, ok, err := claimStorageGB(cm)
+	if err != nil || !ok || gb == spec.StorageGB {
+		return false, err
+	}
+	if gb < spec.StorageGB {
+		return false, errors.Errorf("cannot decrease storage from %dGB to %dGB", spec.StorageGB, gb)
+	}
+	spec.StorageGB = gb
+	return true, nil
+}
+
+// claimStorageGB returns the storage size requested by the supplied claim,
+// and whether it requests one.
+func claimStorageGB(cm resource.Claim) (int64, bool, error) {
+	v, ok := cm.GetAnnotations()[AnnotationStorageGB]
+	if !ok {
+		return 0, false, nil
+	}
+	gb, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || gb <= 0 {
+		return 0, false, errors.Errorf("annotation %s must be a positive number of GB, not %q", AnnotationStorageGB, v)
+	}
+	return gb, true, nil
+}
+
+// A claimPropagator propagates changes to the mutable fields of a bound
+// instance claim to the CloudsqlInstance it is bound to. The claim reconciler
+// only configures an instance when it is created.
+type claimPropagator struct {
+	kube     client.Client
+	recorder record.EventRecorder
+	newClaim func() resource.Claim
+}
+
+// setupClaimPropagator adds a claimPropagator for the claim kind returned by
+// the supplied function to the supplied manager.
+func setupClaimPropagator(mgr ctrl.Manager, name string, newClaim func() resource.Claim) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("propagator." + name).
+		For(newClaim()).
+		Complete(&claimPropagator{
+			kube:     mgr.GetClient(),
+			recorder: mgr.GetEventRecorderFor("propagator." + name),
+			newClaim: newClaim,
+		})
+}
+
+// Reconcile propagates the mutable fields of an instance claim to its
+// CloudsqlInstance.
+func (p *claimPropagator) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), propagatorTimeout)
+	defer cancel()
+
+	cm := p.newClaim()
+	if err := p.kube.Get(ctx, req.NamespacedName, cm); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get claim %s", req.NamespacedName)
+	}
+
+	// Unbound claims are configured by the claim reconciler when their
+	// instance is created.
+	ref := cm.GetResourceReference()
+	if ref == nil || ref.Kind != v1alpha1.CloudsqlInstanceKind {
+		return reconcile.Result{Requeue: false}, nil
+	}
+
+	i := &v1alpha1.CloudsqlInstance{}
+	n := types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}
+	if err := p.kube.Get(ctx, n, i); err != nil {
+		return reconcile.Result{Requeue: false}, errors.Wrapf(resource.Ignore(kerrors.IsNotFound, err), "cannot get instance %s", n)
+	}
+
+	changed, err := propagateClaimFields(cm, &i.Spec)
+	if err != nil {
+		// Don't requeue invalid changes; they'll be reconciled again when the
+		// claim is updated.
+		p.recorder.Event(cm, corev1.EventTypeWarning, EventReasonCannotPropagate, err.Error())
+		return reconcile.Result{Requeue: false}, nil
+	}
+	if !changed {
+		return reconcile.Result{Requeue: false}, nil
+	}
+
+	return reconcile.Result{Requeue: false}, errors.Wrapf(p.kube.Update(ctx, i), "cannot update instance %s", n)
+}
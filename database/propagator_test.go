@@ -0,0 +1,198 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	databasev1alpha1 "github.com/crossplaneio/crossplane/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+var _ reconcile.Reconciler = &claimPropagator{}
+
+func storageClaim(gb string) *databasev1alpha1.PostgreSQLInstance {
+	cm := &databasev1alpha1.PostgreSQLInstance{ObjectMeta: metav1.ObjectMeta{Namespace: testNs, Name: "cool-claim"}}
+	if gb != "" {
+		cm.SetAnnotations(map[string]string{AnnotationStorageGB: gb})
+	}
+	return cm
+}
+
+func TestConfigureClaimFields(t *testing.T) {
+	cases := map[string]struct {
+		cm      resource.Claim
+		want    int64
+		wantErr error
+	}{
+		"NotAnnotated": {
+			cm:   storageClaim(""),
+			want: 10,
+		},
+		"StorageGB": {
+			cm:   storageClaim("20"),
+			want: 20,
+		},
+		"InvalidStorageGB": {
+			cm:      storageClaim("lots"),
+			want:    10,
+			wantErr: errors.Errorf("annotation %s must be a positive number of GB, not %q", AnnotationStorageGB, "lots"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			spec := &v1alpha1.CloudsqlInstanceSpec{StorageGB: 10}
+			err := configureClaimFields(tc.cm, spec)
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("configureClaimFields(...): -want error, +got error:\n%s", diff)
+			}
+			if spec.StorageGB != tc.want {
+				t.Errorf("configureClaimFields(...): want storage %dGB, got %dGB", tc.want, spec.StorageGB)
+			}
+		})
+	}
+}
+
+func TestPropagateClaimFields(t *testing.T) {
+	cases := map[string]struct {
+		cm          resource.Claim
+		want        int64
+		wantChanged bool
+		wantErr     error
+	}{
+		"NotAnnotated": {
+			cm:   storageClaim(""),
+			want: 10,
+		},
+		"Unchanged": {
+			cm:   storageClaim("10"),
+			want: 10,
+		},
+		"Increase": {
+			cm:          storageClaim("20"),
+			want:        20,
+			wantChanged: true,
+		},
+		"Decrease": {
+			cm:      storageClaim("5"),
+			want:    10,
+			wantErr: errors.New("cannot decrease storage from 10GB to 5GB"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			spec := &v1alpha1.CloudsqlInstanceSpec{StorageGB: 10}
+			changed, err := propagateClaimFields(tc.cm, spec)
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("propagateClaimFields(...): -want error, +got error:\n%s", diff)
+			}
+			if changed != tc.wantChanged {
+				t.Errorf("propagateClaimFields(...): want changed %t, got %t", tc.wantChanged, changed)
+			}
+			if spec.StorageGB != tc.want {
+				t.Errorf("propagateClaimFields(...): want storage %dGB, got %dGB", tc.want, spec.StorageGB)
+			}
+		})
+	}
+}
+
+func TestClaimPropagatorReconcile(t *testing.T) {
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: testNs, Name: "cool-claim"}}
+	instanceRef := &corev1.ObjectReference{Namespace: testNs, Name: "cool-instance", Kind: v1alpha1.CloudsqlInstanceKind}
+
+	bound := func(gb string) *databasev1alpha1.PostgreSQLInstance {
+		cm := storageClaim(gb)
+		cm.SetResourceReference(instanceRef)
+		return cm
+	}
+
+	cases := map[string]struct {
+		claim      *databasev1alpha1.PostgreSQLInstance
+		wantUpdate int64
+		wantEvents int
+	}{
+		"Unbound": {
+			claim: storageClaim("20"),
+		},
+		"Increase": {
+			claim:      bound("20"),
+			wantUpdate: 20,
+		},
+		"Unchanged": {
+			claim: bound("10"),
+		},
+		"Decrease": {
+			claim:      bound("5"),
+			wantEvents: 1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var updated int64
+			rec := record.NewFakeRecorder(1)
+			p := &claimPropagator{
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						switch o := obj.(type) {
+						case *databasev1alpha1.PostgreSQLInstance:
+							tc.claim.DeepCopyInto(o)
+						case *v1alpha1.CloudsqlInstance:
+							o.Spec.StorageGB = 10
+						}
+						return nil
+					},
+					MockUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+						updated = obj.(*v1alpha1.CloudsqlInstance).Spec.StorageGB
+						return nil
+					},
+				},
+				recorder: rec,
+				newClaim: func() resource.Claim { return &databasev1alpha1.PostgreSQLInstance{} },
+			}
+
+			got, err := p.Reconcile(req)
+			if err != nil {
+				t.Fatalf("p.Reconcile(...): %s", err)
+			}
+			if diff := cmp.Diff(reconcile.Result{Requeue: false}, got); diff != "" {
+				t.Errorf("p.Reconcile(...): -want result, +got result:\n%s", diff)
+			}
+			if updated != tc.wantUpdate {
+				t.Errorf("p.Reconcile(...): want instance updated to %dGB, got %dGB", tc.wantUpdate, updated)
+			}
+			if len(rec.Events) != tc.wantEvents {
+				t.Errorf("p.Reconcile(...): want %d events, got %d", tc.wantEvents, len(rec.Events))
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+)
+
+// AnnotationProvider may be set on an instance claim to create its instance
+// using a Provider other than that of its resource class, for example one
+// whose project is dedicated to a team's databases. The Provider must be in
+// the namespace of the resource class's Provider, and must be allowed by the
+// resource class. The Provider is chosen when the instance is configured;
+// changing the annotation of a bound claim has no effect.
+const AnnotationProvider = "database.gcp.crossplane.io/provider"
+
+// allowedProvidersParameter is the resource class parameter listing the
+// comma separated names of the Providers its claims may select, e.g.
+// data-prod,data-staging. Claims may not select a Provider unless the class
+// allows it, so that a claim can't use another team's credentials.
+const allowedProvidersParameter = "allowedProviders"
+
+// configureProvider returns the Provider the supplied claim's instance should
+// use: the Provider requested by the claim, if it is allowed by the supplied
+// resource class, or otherwise the class's Provider.
+func configureProvider(cm resource.Claim, rs *corev1alpha1.ResourceClass) (*corev1.ObjectReference, error) {
+	name, ok := cm.GetAnnotations()[AnnotationProvider]
+	if !ok || (rs.ProviderReference != nil && name == rs.ProviderReference.Name) {
+		return rs.ProviderReference, nil
+	}
+	if rs.ProviderReference == nil {
+		return nil, errors.Errorf("annotation %s cannot be used with a resource class that does not reference a provider", AnnotationProvider)
+	}
+
+	allowed := allowedProviders(rs.Parameters)
+	if !allowed[name] {
+		return nil, errors.Errorf("provider %q is not allowed by the resource class; allowed providers are %s", name, strings.Join(providerNames(allowed), ", "))
+	}
+	return &corev1.ObjectReference{Namespace: rs.ProviderReference.Namespace, Name: name}, nil
+}
+
+// allowedProviders returns the set of Provider names allowed by the supplied
+// resource class parameters.
+func allowedProviders(parameters map[string]string) map[string]bool {
+	allowed := map[string]bool{}
+	for _, n := range strings.Split(parameters[allowedProvidersParameter], ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			allowed[n] = true
+		}
+	}
+	return allowed
+}
+
+func providerNames(allowed map[string]bool) []string {
+	names := make([]string, 0, len(allowed))
+	for n := range allowed {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
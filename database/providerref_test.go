@@ -0,0 +1,89 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	databasev1alpha1 "github.com/crossplaneio/crossplane/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestConfigureProvider(t *testing.T) {
+	classProvider := &corev1.ObjectReference{Namespace: "gcp", Name: "shared"}
+
+	type want struct {
+		ref *corev1.ObjectReference
+		err error
+	}
+
+	cases := map[string]struct {
+		annotations map[string]string
+		rs          *corev1alpha1.ResourceClass
+		want        want
+	}{
+		"NoOverride": {
+			rs:   &corev1alpha1.ResourceClass{ProviderReference: classProvider},
+			want: want{ref: classProvider},
+		},
+		"ClassProvider": {
+			annotations: map[string]string{AnnotationProvider: "shared"},
+			rs:          &corev1alpha1.ResourceClass{ProviderReference: classProvider},
+			want:        want{ref: classProvider},
+		},
+		"Allowed": {
+			annotations: map[string]string{AnnotationProvider: "data-prod"},
+			rs: &corev1alpha1.ResourceClass{
+				Parameters:        map[string]string{allowedProvidersParameter: "data-staging, data-prod"},
+				ProviderReference: classProvider,
+			},
+			want: want{ref: &corev1.ObjectReference{Namespace: "gcp", Name: "data-prod"}},
+		},
+		"NotAllowed": {
+			annotations: map[string]string{AnnotationProvider: "other-team"},
+			rs: &corev1alpha1.ResourceClass{
+				Parameters:        map[string]string{allowedProvidersParameter: "data-staging,data-prod"},
+				ProviderReference: classProvider,
+			},
+			want: want{err: errors.New(`provider "other-team" is not allowed by the resource class; allowed providers are data-prod, data-staging`)},
+		},
+		"NoClassProvider": {
+			annotations: map[string]string{AnnotationProvider: "data-prod"},
+			rs:          &corev1alpha1.ResourceClass{Parameters: map[string]string{allowedProvidersParameter: "data-prod"}},
+			want:        want{err: errors.Errorf("annotation %s cannot be used with a resource class that does not reference a provider", AnnotationProvider)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cm := &databasev1alpha1.PostgreSQLInstance{ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations}}
+			got, err := configureProvider(cm, tc.rs)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("configureProvider(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.ref, got); diff != "" {
+				t.Errorf("configureProvider(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
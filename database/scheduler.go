@@ -0,0 +1,194 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	computev1alpha1 "github.com/crossplaneio/crossplane/apis/compute/v1alpha1"
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+)
+
+const (
+	// AnnotationClassSelector may be set on an instance claim that does not
+	// reference a resource class. Its value is a label selector, e.g.
+	// tier=production, that limits the resource classes the claim may be
+	// scheduled to.
+	AnnotationClassSelector = "database.gcp.crossplane.io/class-selector"
+
+	// AnnotationCluster may be set on an instance claim that does not
+	// reference a resource class. Its value is the name of a KubernetesCluster
+	// claim in the same namespace. Resource classes that provision instances
+	// in the same region as that cluster are preferred.
+	AnnotationCluster = "database.gcp.crossplane.io/cluster"
+
+	// classRegionParameter is the resource class parameter that specifies
+	// the region of a CloudSQL instance.
+	classRegionParameter = "region"
+
+	schedulerTimeout = 1 * time.Minute
+	schedulerWait    = 30 * time.Second
+)
+
+// A classScheduler selects a resource class for instance claims that ask to
+// be scheduled but do not yet reference a resource class. Once a class is
+// selected the claim is reconciled as usual by its claim reconciler.
+type classScheduler struct {
+	kube     client.Client
+	newClaim func() resource.Claim
+}
+
+// setupClassScheduler adds a classScheduler for the claim kind returned by
+// the supplied function to the supplied manager.
+func setupClassScheduler(mgr ctrl.Manager, name string, newClaim func() resource.Claim) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("scheduler." + name).
+		For(newClaim()).
+		Complete(&classScheduler{kube: mgr.GetClient(), newClaim: newClaim})
+}
+
+// Reconcile schedules an instance claim to a resource class.
+func (s *classScheduler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), schedulerTimeout)
+	defer cancel()
+
+	cm := s.newClaim()
+	if err := s.kube.Get(ctx, req.NamespacedName, cm); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get claim %s", req.NamespacedName)
+	}
+
+	// Claims that explicitly reference a class, or that don't ask to be
+	// scheduled, are left to the claim reconciler.
+	a := cm.GetAnnotations()
+	_, hasSelector := a[AnnotationClassSelector]
+	_, hasCluster := a[AnnotationCluster]
+	if cm.GetClassReference() != nil || (!hasSelector && !hasCluster) {
+		return reconcile.Result{Requeue: false}, nil
+	}
+
+	sel, err := labels.Parse(a[AnnotationClassSelector])
+	if err != nil {
+		// Don't requeue invalid selectors; they'll be reconciled again when
+		// the claim is updated.
+		log.Info("cannot schedule claim", "request", req, "error", errors.Wrap(err, "cannot parse class selector").Error())
+		return reconcile.Result{Requeue: false}, nil
+	}
+
+	region, err := s.clusterRegion(ctx, cm)
+	if err != nil {
+		log.Info("cannot schedule claim", "request", req, "error", err.Error())
+		return reconcile.Result{RequeueAfter: schedulerWait}, nil
+	}
+
+	l := &corev1alpha1.ResourceClassList{}
+	if err := s.kube.List(ctx, l, client.InNamespace(cm.GetNamespace())); err != nil {
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot list resource classes in namespace %s", cm.GetNamespace())
+	}
+
+	cs := selectClass(l.Items, sel, region)
+	if cs == nil {
+		// A suitable class may be created later.
+		log.Info("cannot schedule claim", "request", req, "error", "no resource class matches the claim")
+		return reconcile.Result{RequeueAfter: schedulerWait}, nil
+	}
+
+	cm.SetClassReference(meta.ReferenceTo(cs, corev1alpha1.ResourceClassGroupVersionKind))
+	return reconcile.Result{Requeue: false}, errors.Wrapf(s.kube.Update(ctx, cm), "cannot update claim %s", req.NamespacedName)
+}
+
+// clusterRegion returns the region of the GKE cluster bound to the
+// KubernetesCluster claim named by the supplied instance claim, or an empty
+// string if the instance claim names no cluster.
+func (s *classScheduler) clusterRegion(ctx context.Context, cm resource.Claim) (string, error) {
+	name := cm.GetAnnotations()[AnnotationCluster]
+	if name == "" {
+		return "", nil
+	}
+
+	kc := &computev1alpha1.KubernetesCluster{}
+	n := types.NamespacedName{Namespace: cm.GetNamespace(), Name: name}
+	if err := s.kube.Get(ctx, n, kc); err != nil {
+		return "", errors.Wrapf(err, "cannot get kubernetes cluster %s", n)
+	}
+
+	ref := kc.GetResourceReference()
+	if ref == nil {
+		return "", errors.Errorf("kubernetes cluster %s is not yet bound", n)
+	}
+
+	gke := &gcpcomputev1alpha1.GKECluster{}
+	if err := s.kube.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, gke); err != nil {
+		return "", errors.Wrapf(err, "cannot get GKE cluster bound to kubernetes cluster %s", n)
+	}
+	return regionOf(gke.Spec.Zone), nil
+}
+
+// selectClass returns the CloudSQL resource class that best satisfies the
+// supplied selector and region, or nil if no class matches the selector.
+// Classes in the supplied region are preferred. Ties are broken by name so
+// that repeated scheduling is deterministic.
+func selectClass(classes []corev1alpha1.ResourceClass, sel labels.Selector, region string) *corev1alpha1.ResourceClass {
+	candidates := make([]*corev1alpha1.ResourceClass, 0, len(classes))
+	for i := range classes {
+		cs := &classes[i]
+		if cs.Provisioner != v1alpha1.CloudsqlInstanceKindAPIVersion || !sel.Matches(labels.Set(cs.GetLabels())) {
+			continue
+		}
+		candidates = append(candidates, cs)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		ii := region != "" && regionOf(candidates[i].Parameters[classRegionParameter]) == region
+		jj := region != "" && regionOf(candidates[j].Parameters[classRegionParameter]) == region
+		if ii != jj {
+			return ii
+		}
+		return candidates[i].GetName() < candidates[j].GetName()
+	})
+	return candidates[0]
+}
+
+// regionOf returns the region of the supplied zone, e.g. us-central1 for
+// us-central1-a. Regions are returned unchanged.
+func regionOf(location string) string {
+	i := strings.LastIndex(location, "-")
+	if i < 0 || len(location)-i != 2 {
+		return location
+	}
+	return location[:i]
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	computev1alpha1 "github.com/crossplaneio/crossplane/apis/compute/v1alpha1"
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	databasev1alpha1 "github.com/crossplaneio/crossplane/apis/database/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+var _ reconcile.Reconciler = &classScheduler{}
+
+func resourceClass(name, provisioner, region string, l map[string]string) corev1alpha1.ResourceClass {
+	return corev1alpha1.ResourceClass{
+		ObjectMeta:  metav1.ObjectMeta{Namespace: testNs, Name: name, Labels: l},
+		Provisioner: provisioner,
+		Parameters:  map[string]string{classRegionParameter: region},
+	}
+}
+
+func TestSelectClass(t *testing.T) {
+	prod := map[string]string{"tier": "production"}
+	dev := map[string]string{"tier": "development"}
+
+	classes := []corev1alpha1.ResourceClass{
+		resourceClass("central", v1alpha1.CloudsqlInstanceKindAPIVersion, "us-central1", prod),
+		resourceClass("east", v1alpha1.CloudsqlInstanceKindAPIVersion, "us-east1", prod),
+		resourceClass("east-dev", v1alpha1.CloudsqlInstanceKindAPIVersion, "us-east1", dev),
+		resourceClass("aaa-bucket", "bucket.storage.gcp.crossplane.io/v1alpha1", "us-east1", prod),
+	}
+
+	cases := map[string]struct {
+		sel    labels.Selector
+		region string
+		want   string
+	}{
+		"PreferRegion": {
+			sel:    labels.SelectorFromSet(prod),
+			region: "us-east1",
+			want:   "east",
+		},
+		"NoRegionMatch": {
+			sel:    labels.SelectorFromSet(prod),
+			region: "europe-west1",
+			want:   "central",
+		},
+		"NoRegion": {
+			sel:  labels.Everything(),
+			want: "central",
+		},
+		"SelectorOnly": {
+			sel:    labels.SelectorFromSet(dev),
+			region: "us-central1",
+			want:   "east-dev",
+		},
+		"NoMatch": {
+			sel:  labels.SelectorFromSet(map[string]string{"tier": "staging"}),
+			want: "",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := ""
+			if cs := selectClass(classes, tc.sel, tc.region); cs != nil {
+				got = cs.GetName()
+			}
+			if got != tc.want {
+				t.Errorf("selectClass(...): want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRegionOf(t *testing.T) {
+	cases := map[string]string{
+		"us-central1-a": "us-central1",
+		"us-central1":   "us-central1",
+		"":              "",
+	}
+
+	for location, want := range cases {
+		if got := regionOf(location); got != want {
+			t.Errorf("regionOf(%q): want %q, got %q", location, want, got)
+		}
+	}
+}
+
+func TestClassSchedulerReconcile(t *testing.T) {
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: testNs, Name: "cool-claim"}}
+	clusterRef := &corev1.ObjectReference{Namespace: testNs, Name: "cool-gke"}
+
+	claim := func(annotations map[string]string, ref *corev1.ObjectReference) *databasev1alpha1.PostgreSQLInstance {
+		cm := &databasev1alpha1.PostgreSQLInstance{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   testNs,
+			Name:        "cool-claim",
+			Annotations: annotations,
+		}}
+		cm.SetClassReference(ref)
+		return cm
+	}
+
+	kube := func(cm *databasev1alpha1.PostgreSQLInstance, bound bool, updated **corev1.ObjectReference) client.Client {
+		return &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				switch o := obj.(type) {
+				case *databasev1alpha1.PostgreSQLInstance:
+					cm.DeepCopyInto(o)
+				case *computev1alpha1.KubernetesCluster:
+					if bound {
+						o.SetResourceReference(clusterRef)
+					}
+				case *gcpcomputev1alpha1.GKECluster:
+					o.Spec.Zone = "us-east1-b"
+				}
+				return nil
+			},
+			MockList: func(_ context.Context, obj runtime.Object, _ ...client.ListOption) error {
+				obj.(*corev1alpha1.ResourceClassList).Items = []corev1alpha1.ResourceClass{
+					resourceClass("central", v1alpha1.CloudsqlInstanceKindAPIVersion, "us-central1", nil),
+					resourceClass("east", v1alpha1.CloudsqlInstanceKindAPIVersion, "us-east1", nil),
+				}
+				return nil
+			},
+			MockUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+				*updated = obj.(resource.Claim).GetClassReference()
+				return nil
+			},
+		}
+	}
+
+	cases := map[string]struct {
+		claim      *databasev1alpha1.PostgreSQLInstance
+		bound      bool
+		want       reconcile.Result
+		wantUpdate *corev1.ObjectReference
+	}{
+		"ScheduledNearCluster": {
+			claim:      claim(map[string]string{AnnotationCluster: "cool-cluster"}, nil),
+			bound:      true,
+			want:       reconcile.Result{Requeue: false},
+			wantUpdate: &corev1.ObjectReference{Namespace: testNs, Name: "east", APIVersion: corev1alpha1.ResourceClassGroupVersionKind.GroupVersion().String(), Kind: corev1alpha1.ResourceClassGroupVersionKind.Kind},
+		},
+		"ClusterNotBound": {
+			claim: claim(map[string]string{AnnotationCluster: "cool-cluster"}, nil),
+			want:  reconcile.Result{RequeueAfter: schedulerWait},
+		},
+		"NoMatchingClass": {
+			claim: claim(map[string]string{AnnotationClassSelector: "tier=production"}, nil),
+			want:  reconcile.Result{RequeueAfter: schedulerWait},
+		},
+		"NotAnnotated": {
+			claim: claim(nil, nil),
+			want:  reconcile.Result{Requeue: false},
+		},
+		"AlreadyScheduled": {
+			claim: claim(map[string]string{AnnotationCluster: "cool-cluster"}, &corev1.ObjectReference{Name: "coolclass"}),
+			bound: true,
+			want:  reconcile.Result{Requeue: false},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var updated *corev1.ObjectReference
+			s := &classScheduler{
+				kube:     kube(tc.claim, tc.bound, &updated),
+				newClaim: func() resource.Claim { return &databasev1alpha1.PostgreSQLInstance{} },
+			}
+
+			got, err := s.Reconcile(req)
+			if err != nil {
+				t.Fatalf("s.Reconcile(...): %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("s.Reconcile(...): -want result, +got result:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantUpdate, updated); diff != "" {
+				t.Errorf("s.Reconcile(...): -want class reference, +got class reference:\n%s", diff)
+			}
+		})
+	}
+}
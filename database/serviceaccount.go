@@ -0,0 +1,346 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	cloudkms "google.golang.org/api/cloudkms/v1"
+	"google.golang.org/api/option"
+	gcs "google.golang.org/api/storage/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	storagev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/storage/v1alpha1"
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+)
+
+// The roles granted to the service account of an instance.
+const (
+	// roleKMSEncrypterDecrypter allows an instance to use a customer managed
+	// encryption key.
+	roleKMSEncrypterDecrypter = "roles/cloudkms.cryptoKeyEncrypterDecrypter"
+
+	// roleStorageObjectAdmin allows an instance to import from and export to
+	// a bucket.
+	roleStorageObjectAdmin = "roles/storage.objectAdmin"
+)
+
+// A granter grants the supplied member a role on the supplied GCP resource,
+// and revokes it. Grant returns false if the member already had the role.
+type granter interface {
+	grant(ctx context.Context, resource, member string) (added bool, err error)
+	revoke(ctx context.Context, resource, member string) error
+}
+
+// needsServiceAccountAccess returns true if the supplied instance's service
+// account should be granted access to KMS keys or buckets, or has previously
+// been granted access that may need to be revoked.
+func needsServiceAccountAccess(i *v1alpha1.CloudsqlInstance) bool {
+	return i.Spec.ServiceAccountAccess != nil || i.Spec.FinalBackupOnDelete ||
+		len(i.Status.GrantedKMSKeys) > 0 || len(i.Status.GrantedBuckets) > 0
+}
+
+// newGranters returns granters for Cloud KMS crypto keys and Cloud Storage
+// buckets, authenticated using credentials read from the supplied Provider.
+func newGranters(ctx context.Context, kube client.Client, p *gcpv1alpha1.Provider) (kms, buckets granter, err error) {
+	creds, err := provider.ServiceCredentials(ctx, kube, p, provider.ServiceKMS, cloudkms.CloudkmsScope)
+	if err != nil {
+		return nil, nil, err
+	}
+	ks, err := cloudkms.NewService(ctx, option.WithCredentials(creds))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot create new Cloud KMS client")
+	}
+
+	creds, err = provider.ServiceCredentials(ctx, kube, p, provider.ServiceStorage, gcs.DevstorageFullControlScope)
+	if err != nil {
+		return nil, nil, err
+	}
+	ss, err := gcs.NewService(ctx, option.WithCredentials(creds))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot create new Cloud Storage client")
+	}
+
+	return &kmsGranter{keys: ks.Projects.Locations.KeyRings.CryptoKeys}, &bucketGranter{buckets: ss.Buckets}, nil
+}
+
+// serviceAccountBuckets returns the names of the buckets the instance's
+// service account should be granted access to; those referenced by its spec,
+// and the bucket its final backup is exported to, if it takes one.
+func (h *localHandler) serviceAccountBuckets(ctx context.Context) ([]string, error) {
+	var refs []corev1.LocalObjectReference
+	if h.Spec.ServiceAccountAccess != nil {
+		refs = append(refs, h.Spec.ServiceAccountAccess.BucketRefs...)
+	}
+	if h.Spec.FinalBackupOnDelete && h.Spec.FinalBackupBucketRef != nil {
+		refs = append(refs, *h.Spec.FinalBackupBucketRef)
+	}
+
+	names := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		b := &storagev1alpha1.Bucket{}
+		n := types.NamespacedName{Namespace: h.GetNamespace(), Name: ref.Name}
+		if err := h.client.Get(ctx, n, b); err != nil {
+			return nil, errors.Wrapf(err, "cannot get bucket %s", n)
+		}
+		names = appendMissing(names, b.GetBucketName())
+	}
+	return names, nil
+}
+
+// grantServiceAccountAccess grants the instance's service account access to
+// the KMS keys and buckets its spec references, and revokes access it
+// previously granted to keys and buckets that are no longer referenced. Cloud
+// SQL generates a service account for each instance, so access can't be
+// granted until the instance exists. Access is not revoked when the instance
+// is deleted, because its service account is deleted along with it.
+func (h *managedHandler) grantServiceAccountAccess(ctx context.Context) error {
+	sa := h.Status.ServiceAccountEmail
+	if sa == "" || h.isDryRun() || !needsServiceAccountAccess(h.CloudsqlInstance) {
+		return nil
+	}
+	member := "serviceAccount:" + sa
+
+	var keys []string
+	if h.Spec.ServiceAccountAccess != nil {
+		keys = h.Spec.ServiceAccountAccess.KMSKeys
+	}
+	granted, err := reconcileGrants(ctx, h.kms, member, keys, h.Status.GrantedKMSKeys)
+	h.Status.GrantedKMSKeys = granted
+	if err != nil {
+		return errors.Wrap(err, "cannot grant service account access to KMS keys")
+	}
+
+	buckets, err := h.serviceAccountBuckets(ctx)
+	if err != nil {
+		return err
+	}
+	granted, err = reconcileGrants(ctx, h.buckets, member, buckets, h.Status.GrantedBuckets)
+	h.Status.GrantedBuckets = granted
+	return errors.Wrap(err, "cannot grant service account access to buckets")
+}
+
+// reconcileGrants grants the supplied member access to each desired resource
+// that it has not been granted access to, and revokes its access to each
+// granted resource that is no longer desired. It returns the resources the
+// member was granted access to once it is done, even if it returns an error.
+// Access the member already had is not returned, so that it is never revoked.
+func reconcileGrants(ctx context.Context, g granter, member string, desired, granted []string) ([]string, error) {
+	if len(desired) == 0 && len(granted) == 0 {
+		return nil, nil
+	}
+	if g == nil {
+		return granted, errors.New("no client is configured")
+	}
+
+	want := map[string]bool{}
+	for _, r := range desired {
+		want[r] = true
+	}
+
+	result := make([]string, 0, len(desired))
+	for _, r := range granted {
+		if want[r] {
+			result = appendMissing(result, r)
+			continue
+		}
+		if err := g.revoke(ctx, r, member); err != nil {
+			return append(result, r), errors.Wrapf(err, "cannot revoke access to %s", r)
+		}
+	}
+
+	has := map[string]bool{}
+	for _, r := range result {
+		has[r] = true
+	}
+	for _, r := range desired {
+		if has[r] {
+			continue
+		}
+		added, err := g.grant(ctx, r, member)
+		if err != nil {
+			return result, errors.Wrapf(err, "cannot grant access to %s", r)
+		}
+		if added {
+			result = appendMissing(result, r)
+		}
+	}
+	return result, nil
+}
+
+// kmsGranter grants the encrypter/decrypter role on Cloud KMS crypto keys.
+type kmsGranter struct {
+	keys *cloudkms.ProjectsLocationsKeyRingsCryptoKeysService
+}
+
+func (g *kmsGranter) grant(ctx context.Context, key, member string) (bool, error) {
+	p, err := g.keys.GetIamPolicy(key).Context(ctx).Do()
+	if err != nil {
+		return false, err
+	}
+	if !addKMSMember(p, roleKMSEncrypterDecrypter, member) {
+		return false, nil
+	}
+	_, err = g.keys.SetIamPolicy(key, &cloudkms.SetIamPolicyRequest{Policy: p}).Context(ctx).Do()
+	return err == nil, err
+}
+
+func (g *kmsGranter) revoke(ctx context.Context, key, member string) error {
+	p, err := g.keys.GetIamPolicy(key).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	if !removeKMSMember(p, roleKMSEncrypterDecrypter, member) {
+		return nil
+	}
+	_, err = g.keys.SetIamPolicy(key, &cloudkms.SetIamPolicyRequest{Policy: p}).Context(ctx).Do()
+	return err
+}
+
+// bucketGranter grants the object admin role on Cloud Storage buckets.
+type bucketGranter struct {
+	buckets *gcs.BucketsService
+}
+
+func (g *bucketGranter) grant(ctx context.Context, bucket, member string) (bool, error) {
+	p, err := g.buckets.GetIamPolicy(bucket).Context(ctx).Do()
+	if err != nil {
+		return false, err
+	}
+	if !addBucketMember(p, roleStorageObjectAdmin, member) {
+		return false, nil
+	}
+	_, err = g.buckets.SetIamPolicy(bucket, p).Context(ctx).Do()
+	return err == nil, err
+}
+
+func (g *bucketGranter) revoke(ctx context.Context, bucket, member string) error {
+	p, err := g.buckets.GetIamPolicy(bucket).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	if !removeBucketMember(p, roleStorageObjectAdmin, member) {
+		return nil
+	}
+	_, err = g.buckets.SetIamPolicy(bucket, p).Context(ctx).Do()
+	return err
+}
+
+// addKMSMember adds the supplied member to the supplied role's unconditional
+// binding in the supplied policy. It returns false if the policy already bound
+// the member to the role.
+func addKMSMember(p *cloudkms.Policy, role, member string) bool {
+	for _, b := range p.Bindings {
+		if b.Role == role && b.Condition == nil {
+			var added bool
+			b.Members, added = withMember(b.Members, member)
+			return added
+		}
+	}
+	p.Bindings = append(p.Bindings, &cloudkms.Binding{Role: role, Members: []string{member}})
+	return true
+}
+
+// removeKMSMember removes the supplied member from the supplied role's
+// unconditional binding in the supplied policy, removing the binding if it has
+// no other members. It returns false if the policy did not bind the member to
+// the role.
+func removeKMSMember(p *cloudkms.Policy, role, member string) bool {
+	removed := false
+	bindings := make([]*cloudkms.Binding, 0, len(p.Bindings))
+	for _, b := range p.Bindings {
+		if b.Role == role && b.Condition == nil {
+			var ok bool
+			if b.Members, ok = withoutMember(b.Members, member); ok {
+				removed = true
+			}
+			if len(b.Members) == 0 {
+				continue
+			}
+		}
+		bindings = append(bindings, b)
+	}
+	p.Bindings = bindings
+	return removed
+}
+
+// addBucketMember is addKMSMember for bucket policies.
+func addBucketMember(p *gcs.Policy, role, member string) bool {
+	for _, b := range p.Bindings {
+		if b.Role == role && b.Condition == nil {
+			var added bool
+			b.Members, added = withMember(b.Members, member)
+			return added
+		}
+	}
+	p.Bindings = append(p.Bindings, &gcs.PolicyBindings{Role: role, Members: []string{member}})
+	return true
+}
+
+// removeBucketMember is removeKMSMember for bucket policies.
+func removeBucketMember(p *gcs.Policy, role, member string) bool {
+	removed := false
+	bindings := make([]*gcs.PolicyBindings, 0, len(p.Bindings))
+	for _, b := range p.Bindings {
+		if b.Role == role && b.Condition == nil {
+			var ok bool
+			if b.Members, ok = withoutMember(b.Members, member); ok {
+				removed = true
+			}
+			if len(b.Members) == 0 {
+				continue
+			}
+		}
+		bindings = append(bindings, b)
+	}
+	p.Bindings = bindings
+	return removed
+}
+
+// withMember returns the supplied members with the supplied member appended,
+// and true, unless it was already a member.
+func withMember(members []string, member string) ([]string, bool) {
+	for _, m := range members {
+		if m == member {
+			return members, false
+		}
+	}
+	return append(members, member), true
+}
+
+// withoutMember returns the supplied members without the supplied member, and
+// true if it was a member.
+func withoutMember(members []string, member string) ([]string, bool) {
+	out := make([]string, 0, len(members))
+	for _, m := range members {
+		if m != member {
+			out = append(out, m)
+		}
+	}
+	return out, len(out) != len(members)
+}
+
+// appendMissing appends s to ss unless ss already contains it.
+func appendMissing(ss []string, s string) []string {
+	out, _ := withMember(ss, s)
+	return out
+}
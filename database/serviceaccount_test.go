@@ -0,0 +1,277 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	cloudkms "google.golang.org/api/cloudkms/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+type fakeGranter struct {
+	granted  []string
+	revoked  []string
+	existing []string
+	errGrant error
+}
+
+func (g *fakeGranter) grant(_ context.Context, resource, _ string) (bool, error) {
+	if g.errGrant != nil {
+		return false, g.errGrant
+	}
+	for _, r := range g.existing {
+		if r == resource {
+			return false, nil
+		}
+	}
+	g.granted = append(g.granted, resource)
+	return true, nil
+}
+
+func (g *fakeGranter) revoke(_ context.Context, resource, _ string) error {
+	g.revoked = append(g.revoked, resource)
+	return nil
+}
+
+func TestReconcileGrants(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	type want struct {
+		result  []string
+		granted []string
+		revoked []string
+		err     error
+	}
+
+	cases := map[string]struct {
+		g       *fakeGranter
+		desired []string
+		granted []string
+		want    want
+	}{
+		"NothingToDo": {
+			g:    &fakeGranter{},
+			want: want{},
+		},
+		"GrantNew": {
+			g:       &fakeGranter{},
+			desired: []string{"a", "b"},
+			granted: []string{"a"},
+			want: want{
+				result:  []string{"a", "b"},
+				granted: []string{"b"},
+			},
+		},
+		"RevokeStale": {
+			g:       &fakeGranter{},
+			desired: []string{"b"},
+			granted: []string{"a", "b"},
+			want: want{
+				result:  []string{"b"},
+				revoked: []string{"a"},
+			},
+		},
+		"AlreadyHadAccess": {
+			g:       &fakeGranter{existing: []string{"a"}},
+			desired: []string{"a", "b"},
+			want: want{
+				result:  []string{"b"},
+				granted: []string{"b"},
+			},
+		},
+		"GrantError": {
+			g:       &fakeGranter{errGrant: errBoom},
+			desired: []string{"a", "b"},
+			granted: []string{"a"},
+			want: want{
+				result: []string{"a"},
+				err:    errors.Wrap(errBoom, "cannot grant access to b"),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := reconcileGrants(context.Background(), tc.g, "serviceAccount:sa", tc.desired, tc.granted)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("reconcileGrants(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got, cmpEmpty); diff != "" {
+				t.Errorf("reconcileGrants(...): -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.granted, tc.g.granted); diff != "" {
+				t.Errorf("reconcileGrants(...): -want granted, +got granted:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.revoked, tc.g.revoked); diff != "" {
+				t.Errorf("reconcileGrants(...): -want revoked, +got revoked:\n%s", diff)
+			}
+		})
+	}
+}
+
+// cmpEmpty treats nil and empty slices as equal.
+var cmpEmpty = cmp.FilterValues(func(x, y []string) bool { return len(x) == 0 && len(y) == 0 }, cmp.Ignore())
+
+func TestGrantServiceAccountAccess(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	type want struct {
+		keys    []string
+		buckets []string
+		err     error
+	}
+
+	cases := map[string]struct {
+		access      *v1alpha1.ServiceAccountAccess
+		finalBackup bool
+		email       string
+		buckets     func(context.Context) ([]string, error)
+		want        want
+	}{
+		"NoServiceAccountYet": {
+			access: &v1alpha1.ServiceAccountAccess{KMSKeys: []string{"k"}},
+		},
+		"NoAccess": {
+			email: "sa@example.org",
+		},
+		"Granted": {
+			access:  &v1alpha1.ServiceAccountAccess{KMSKeys: []string{"k"}},
+			email:   "sa@example.org",
+			buckets: func(context.Context) ([]string, error) { return []string{"b"}, nil },
+			want: want{
+				keys:    []string{"k"},
+				buckets: []string{"b"},
+			},
+		},
+		"FinalBackupBucket": {
+			finalBackup: true,
+			email:       "sa@example.org",
+			buckets:     func(context.Context) ([]string, error) { return []string{"backups"}, nil },
+			want: want{
+				buckets: []string{"backups"},
+			},
+		},
+		"ResolveBucketsError": {
+			access:  &v1alpha1.ServiceAccountAccess{KMSKeys: []string{"k"}},
+			email:   "sa@example.org",
+			buckets: func(context.Context) ([]string, error) { return nil, errBoom },
+			want: want{
+				keys: []string{"k"},
+				err:  errBoom,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			inst := &v1alpha1.CloudsqlInstance{}
+			inst.Spec.ServiceAccountAccess = tc.access
+			inst.Spec.FinalBackupOnDelete = tc.finalBackup
+			inst.Status.ServiceAccountEmail = tc.email
+			h := &managedHandler{
+				CloudsqlInstance: inst,
+				localOperations:  &mockLocalOperations{mockServiceAccountBuckets: tc.buckets},
+				kms:              &fakeGranter{},
+				buckets:          &fakeGranter{},
+			}
+			err := h.grantServiceAccountAccess(context.Background())
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("grantServiceAccountAccess(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.keys, inst.Status.GrantedKMSKeys, cmpEmpty); diff != "" {
+				t.Errorf("grantServiceAccountAccess(...): -want KMS keys, +got KMS keys:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.buckets, inst.Status.GrantedBuckets, cmpEmpty); diff != "" {
+				t.Errorf("grantServiceAccountAccess(...): -want buckets, +got buckets:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServiceAccountBuckets(t *testing.T) {
+	cases := map[string]struct {
+		access      *v1alpha1.ServiceAccountAccess
+		finalBackup bool
+		want        []string
+	}{
+		"NoAccess": {},
+		"FinalBackupOnly": {
+			finalBackup: true,
+			want:        []string{"backups"},
+		},
+		"FinalBackupNotTaken": {
+			access: &v1alpha1.ServiceAccountAccess{BucketRefs: []corev1.LocalObjectReference{{Name: "imports"}}},
+			want:   []string{"imports"},
+		},
+		"Both": {
+			access:      &v1alpha1.ServiceAccountAccess{BucketRefs: []corev1.LocalObjectReference{{Name: "imports"}}},
+			finalBackup: true,
+			want:        []string{"imports", "backups"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			inst := &v1alpha1.CloudsqlInstance{}
+			inst.Spec.ServiceAccountAccess = tc.access
+			inst.Spec.FinalBackupOnDelete = tc.finalBackup
+			inst.Spec.FinalBackupBucketRef = &corev1.LocalObjectReference{Name: "backups"}
+
+			var got []string
+			kube := &test.MockClient{
+				MockGet: func(_ context.Context, key client.ObjectKey, _ runtime.Object) error {
+					got = append(got, key.Name)
+					return nil
+				},
+			}
+			if _, err := newLocalHandler(inst, kube).serviceAccountBuckets(context.Background()); err != nil {
+				t.Fatalf("serviceAccountBuckets(...): %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got, cmpEmpty); diff != "" {
+				t.Errorf("serviceAccountBuckets(...): -want buckets, +got buckets:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestKMSMembers(t *testing.T) {
+	p := &cloudkms.Policy{Bindings: []*cloudkms.Binding{{Role: "roles/viewer", Members: []string{"user:a"}}}}
+
+	if !addKMSMember(p, roleKMSEncrypterDecrypter, "serviceAccount:sa") {
+		t.Errorf("addKMSMember(...): want true, got false")
+	}
+	if addKMSMember(p, roleKMSEncrypterDecrypter, "serviceAccount:sa") {
+		t.Errorf("addKMSMember(...): want false for an existing member, got true")
+	}
+	if !removeKMSMember(p, roleKMSEncrypterDecrypter, "serviceAccount:sa") {
+		t.Errorf("removeKMSMember(...): want true, got false")
+	}
+
+	want := &cloudkms.Policy{Bindings: []*cloudkms.Binding{{Role: "roles/viewer", Members: []string{"user:a"}}}}
+	if diff := cmp.Diff(want, p); diff != "" {
+		t.Errorf("removeKMSMember(...): -want, +got:\n%s", diff)
+	}
+}
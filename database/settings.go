@@ -0,0 +1,244 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/externalname"
+)
+
+// DefaultQueryStringLength is the maximum length of the query strings
+// recorded by query insights for instances that don't specify one.
+const DefaultQueryStringLength = 1024
+
+// sqlServerDBVersionPrefix is the Cloud SQL database version prefix of SQL
+// Server instances, e.g. SQLSERVER_2019_STANDARD.
+const sqlServerDBVersionPrefix = "SQLSERVER"
+
+// sqlServerDefaultEdition is the edition of the SQL Server instances
+// requested by claims.
+const sqlServerDefaultEdition = "STANDARD"
+
+// Cloud SQL creates SQL Server instances with a sqlserver administrator. They
+// listen on the standard SQL Server port.
+const (
+	sqlServerUserName = "sqlserver"
+	sqlServerPort     = "1433"
+)
+
+// ConnectionSecretPortKey is the key of the port of an instance in its
+// connection secret. It is only written for SQL Server instances, which most
+// clients don't connect to on their default port.
+const ConnectionSecretPortKey = "port"
+
+// The database flags through which the locale of MySQL and PostgreSQL
+// instances is configured. SQL Server instances are configured via settings.
+const (
+	flagMySQLTimeZone     = "default_time_zone"
+	flagMySQLCharacterSet = "character_set_server"
+	flagMySQLCollation    = "collation_server"
+	flagPostgresTimeZone  = "timezone"
+)
+
+// instanceName returns the name of the Cloud SQL instance of the supplied
+// CloudsqlInstance. The name is derived from the CloudsqlInstance's UID unless
+// it has been persisted, in which case a CloudsqlInstance restored from a
+// backup reattaches to its Cloud SQL instance.
+func instanceName(i *v1alpha1.CloudsqlInstance) string {
+	return externalname.Resolve(i, i.GetResourceName())
+}
+
+// desiredInstance returns the Cloud SQL instance described by the supplied
+// CloudsqlInstance.
+func desiredInstance(i *v1alpha1.CloudsqlInstance) *sqladmin.DatabaseInstance {
+	inst := i.DatabaseInstance(instanceName(i))
+	if inst.Settings == nil {
+		inst.Settings = &sqladmin.Settings{}
+	}
+	inst.Settings.InsightsConfig = insightsConfig(i.Spec.InsightsConfig)
+	inst.Settings.Tier = desiredTier(i, inst.Settings.Tier, time.Now())
+
+	// Authorized networks are only managed if they are specified, in which
+	// case any network that is not specified is removed.
+	if n := i.Spec.AuthorizedNetworks; n != nil {
+		if inst.Settings.IpConfiguration == nil {
+			inst.Settings.IpConfiguration = &sqladmin.IpConfiguration{}
+		}
+		inst.Settings.IpConfiguration.AuthorizedNetworks = authorizedNetworks(n, time.Now())
+		// Send an empty list explicitly so that all networks may be removed.
+		inst.Settings.IpConfiguration.ForceSendFields = append(inst.Settings.IpConfiguration.ForceSendFields, "AuthorizedNetworks")
+	}
+
+	if isEngine(i.Spec.DatabaseVersion, sqlServerDBVersionPrefix) {
+		inst.Settings.TimeZone = i.Spec.TimeZone
+		inst.Settings.Collation = i.Spec.Collation
+		if i.Spec.ActiveDirectoryDomain != "" {
+			inst.Settings.ActiveDirectoryConfig = &sqladmin.SqlActiveDirectoryConfig{Domain: i.Spec.ActiveDirectoryDomain}
+		}
+	}
+	inst.Settings.DatabaseFlags = mergeFlags(inst.Settings.DatabaseFlags, specFlags(i.Spec.DatabaseFlags))
+	inst.Settings.DatabaseFlags = mergeFlags(inst.Settings.DatabaseFlags, localeFlags(i.Spec))
+
+	return inst
+}
+
+// isEngine returns true if the supplied database version, e.g. POSTGRES_9_6,
+// is a version of the engine with the supplied prefix.
+func isEngine(version, prefix string) bool {
+	return strings.HasPrefix(version, prefix+"_")
+}
+
+// databaseUserName returns the name of the database user whose credentials
+// are published to the connection secret of the supplied CloudsqlInstance.
+func databaseUserName(i *v1alpha1.CloudsqlInstance) string {
+	if isEngine(i.Spec.DatabaseVersion, sqlServerDBVersionPrefix) {
+		return sqlServerUserName
+	}
+	return i.DatabaseUserName()
+}
+
+// localeFlags returns the database flags that configure the time zone,
+// character set, and collation described by the supplied spec.
+func localeFlags(s v1alpha1.CloudsqlInstanceSpec) []*sqladmin.DatabaseFlags {
+	var flags []*sqladmin.DatabaseFlags
+	add := func(name, value string) {
+		if value != "" {
+			flags = append(flags, &sqladmin.DatabaseFlags{Name: name, Value: value})
+		}
+	}
+
+	switch {
+	case isEngine(s.DatabaseVersion, v1alpha1.MysqlDBVersionPrefix):
+		add(flagMySQLTimeZone, s.TimeZone)
+		add(flagMySQLCharacterSet, s.CharacterSet)
+		add(flagMySQLCollation, s.Collation)
+	case isEngine(s.DatabaseVersion, v1alpha1.PostgresqlDBVersionPrefix):
+		add(flagPostgresTimeZone, s.TimeZone)
+	}
+	return flags
+}
+
+// mergeFlags returns the supplied flags with the supplied overrides. An
+// override replaces any flag of the same name.
+func mergeFlags(flags, overrides []*sqladmin.DatabaseFlags) []*sqladmin.DatabaseFlags {
+	if len(overrides) == 0 {
+		return flags
+	}
+	merged := make([]*sqladmin.DatabaseFlags, 0, len(flags)+len(overrides))
+	for _, f := range flags {
+		if !containsFlag(overrides, f.Name) {
+			merged = append(merged, f)
+		}
+	}
+	return append(merged, overrides...)
+}
+
+func containsFlag(flags []*sqladmin.DatabaseFlags, name string) bool {
+	for _, f := range flags {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// flagsEqual returns true if the supplied database flags contain the same
+// flags, regardless of their order.
+func flagsEqual(desired, actual []*sqladmin.DatabaseFlags) bool {
+	if len(desired) != len(actual) {
+		return false
+	}
+	want := make(map[string]string, len(desired))
+	for _, f := range desired {
+		want[f.Name] = f.Value
+	}
+	for _, f := range actual {
+		if v, ok := want[f.Name]; !ok || v != f.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// insightsConfig returns the query insights configuration described by the
+// supplied spec. Query insights are enabled for instances that don't
+// configure them.
+func insightsConfig(c *v1alpha1.InsightsConfig) *sqladmin.InsightsConfig {
+	if c == nil {
+		return &sqladmin.InsightsConfig{QueryInsightsEnabled: true, QueryStringLength: DefaultQueryStringLength}
+	}
+
+	l := c.QueryStringLength
+	if l == 0 {
+		l = DefaultQueryStringLength
+	}
+	return &sqladmin.InsightsConfig{
+		QueryInsightsEnabled:  c.QueryInsightsEnabled,
+		RecordApplicationTags: c.RecordApplicationTags,
+		RecordClientAddress:   c.RecordClientAddress,
+		QueryStringLength:     l,
+		// Send false values explicitly so that insights may be disabled.
+		ForceSendFields: []string{"QueryInsightsEnabled", "RecordApplicationTags", "RecordClientAddress"},
+	}
+}
+
+// authorizedNetworks returns the authorized networks described by the supplied
+// spec, sorted by name. Networks that expired before the supplied time are
+// omitted, so that they are removed from the instance.
+func authorizedNetworks(n []v1alpha1.AuthorizedNetwork, now time.Time) []*sqladmin.AclEntry {
+	acl := make([]*sqladmin.AclEntry, 0, len(n))
+	for _, an := range n {
+		e := &sqladmin.AclEntry{Name: an.Name, Value: an.CIDR}
+		if an.ExpirationTime != nil {
+			if !an.ExpirationTime.After(now) {
+				continue
+			}
+			e.ExpirationTime = an.ExpirationTime.UTC().Format(time.RFC3339)
+		}
+		acl = append(acl, e)
+	}
+	sort.SliceStable(acl, func(i, j int) bool { return acl[i].Name < acl[j].Name })
+	return acl
+}
+
+// authorizedNetworksEqual returns true if the supplied authorized networks
+// contain the same entries, regardless of their order.
+func authorizedNetworksEqual(desired, actual []*sqladmin.AclEntry) bool {
+	return compare.Equal(comparableACL(desired), comparableACL(actual), compare.Unordered(""))
+}
+
+// comparableACL returns the names, values, and expiration times of the
+// supplied entries. Cloud SQL may return expiration times with a different
+// precision than they were sent with, so they are normalised.
+func comparableACL(acl []*sqladmin.AclEntry) []*sqladmin.AclEntry {
+	out := make([]*sqladmin.AclEntry, 0, len(acl))
+	for _, e := range acl {
+		c := &sqladmin.AclEntry{Name: e.Name, Value: e.Value, ExpirationTime: e.ExpirationTime}
+		if t, err := time.Parse(time.RFC3339, e.ExpirationTime); err == nil {
+			c.ExpirationTime = t.UTC().Format(time.RFC3339Nano)
+		}
+		out = append(out, c)
+	}
+	return out
+}
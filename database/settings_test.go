@@ -0,0 +1,284 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+)
+
+func TestInsightsConfig(t *testing.T) {
+	forceSend := []string{"QueryInsightsEnabled", "RecordApplicationTags", "RecordClientAddress"}
+
+	cases := map[string]struct {
+		c    *v1alpha1.InsightsConfig
+		want *sqladmin.InsightsConfig
+	}{
+		"Default": {
+			c:    nil,
+			want: &sqladmin.InsightsConfig{QueryInsightsEnabled: true, QueryStringLength: DefaultQueryStringLength},
+		},
+		"Disabled": {
+			c: &v1alpha1.InsightsConfig{QueryInsightsEnabled: false},
+			want: &sqladmin.InsightsConfig{
+				QueryStringLength: DefaultQueryStringLength,
+				ForceSendFields:   forceSend,
+			},
+		},
+		"Configured": {
+			c: &v1alpha1.InsightsConfig{
+				QueryInsightsEnabled:  true,
+				RecordApplicationTags: true,
+				RecordClientAddress:   true,
+				QueryStringLength:     4500,
+			},
+			want: &sqladmin.InsightsConfig{
+				QueryInsightsEnabled:  true,
+				RecordApplicationTags: true,
+				RecordClientAddress:   true,
+				QueryStringLength:     4500,
+				ForceSendFields:       forceSend,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := insightsConfig(tc.c)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("insightsConfig(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAuthorizedNetworks(t *testing.T) {
+	now := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	later := metav1.NewTime(now.Add(time.Hour))
+	earlier := metav1.NewTime(now.Add(-time.Hour))
+
+	cases := map[string]struct {
+		n    []v1alpha1.AuthorizedNetwork
+		want []*sqladmin.AclEntry
+	}{
+		"Empty": {
+			n:    []v1alpha1.AuthorizedNetwork{},
+			want: []*sqladmin.AclEntry{},
+		},
+		"SortedByName": {
+			n: []v1alpha1.AuthorizedNetwork{
+				{Name: "vpn", CIDR: "10.0.0.0/8"},
+				{Name: "office", CIDR: "203.0.113.0/24"},
+			},
+			want: []*sqladmin.AclEntry{
+				{Name: "office", Value: "203.0.113.0/24"},
+				{Name: "vpn", Value: "10.0.0.0/8"},
+			},
+		},
+		"ExpiredOmitted": {
+			n: []v1alpha1.AuthorizedNetwork{
+				{Name: "contractor", CIDR: "198.51.100.7/32", ExpirationTime: &later},
+				{Name: "conference", CIDR: "192.0.2.0/24", ExpirationTime: &earlier},
+			},
+			want: []*sqladmin.AclEntry{
+				{Name: "contractor", Value: "198.51.100.7/32", ExpirationTime: "2019-10-01T13:00:00Z"},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := authorizedNetworks(tc.n, now)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("authorizedNetworks(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAuthorizedNetworksEqual(t *testing.T) {
+	office := &sqladmin.AclEntry{Name: "office", Value: "203.0.113.0/24"}
+	vpn := &sqladmin.AclEntry{Name: "vpn", Value: "10.0.0.0/8", ExpirationTime: "2019-10-01T13:00:00Z"}
+
+	cases := map[string]struct {
+		desired []*sqladmin.AclEntry
+		actual  []*sqladmin.AclEntry
+		want    bool
+	}{
+		"Reordered": {
+			desired: []*sqladmin.AclEntry{office, vpn},
+			actual:  []*sqladmin.AclEntry{vpn, office},
+			want:    true,
+		},
+		"ExpirationPrecision": {
+			desired: []*sqladmin.AclEntry{vpn},
+			actual:  []*sqladmin.AclEntry{{Name: "vpn", Value: "10.0.0.0/8", ExpirationTime: "2019-10-01T13:00:00.000Z", Kind: "sql#aclEntry"}},
+			want:    true,
+		},
+		"Added": {
+			desired: []*sqladmin.AclEntry{office, vpn},
+			actual:  []*sqladmin.AclEntry{office},
+			want:    false,
+		},
+		"Removed": {
+			desired: []*sqladmin.AclEntry{},
+			actual:  []*sqladmin.AclEntry{office},
+			want:    false,
+		},
+		"CIDRChanged": {
+			desired: []*sqladmin.AclEntry{office},
+			actual:  []*sqladmin.AclEntry{{Name: "office", Value: "203.0.113.0/25"}},
+			want:    false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := authorizedNetworksEqual(tc.desired, tc.actual); got != tc.want {
+				t.Errorf("authorizedNetworksEqual(...): want %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLocaleFlags(t *testing.T) {
+	cases := map[string]struct {
+		s    v1alpha1.CloudsqlInstanceSpec
+		want []*sqladmin.DatabaseFlags
+	}{
+		"MySQL": {
+			s: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion: "MYSQL_8_0",
+				TimeZone:        "+09:00",
+				CharacterSet:    "utf8mb4",
+				Collation:       "utf8mb4_ja_0900_as_cs",
+			},
+			want: []*sqladmin.DatabaseFlags{
+				{Name: flagMySQLTimeZone, Value: "+09:00"},
+				{Name: flagMySQLCharacterSet, Value: "utf8mb4"},
+				{Name: flagMySQLCollation, Value: "utf8mb4_ja_0900_as_cs"},
+			},
+		},
+		"PostgreSQL": {
+			s: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion: "POSTGRES_14",
+				TimeZone:        "Asia/Tokyo",
+			},
+			want: []*sqladmin.DatabaseFlags{{Name: flagPostgresTimeZone, Value: "Asia/Tokyo"}},
+		},
+		"SQLServer": {
+			s: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion: "SQLSERVER_2019_STANDARD",
+				TimeZone:        "Tokyo Standard Time",
+			},
+			want: nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := localeFlags(tc.s)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("localeFlags(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMergeFlags(t *testing.T) {
+	flags := []*sqladmin.DatabaseFlags{{Name: "max_connections", Value: "100"}, {Name: flagPostgresTimeZone, Value: "UTC"}}
+	overrides := []*sqladmin.DatabaseFlags{{Name: flagPostgresTimeZone, Value: "Asia/Tokyo"}}
+
+	want := []*sqladmin.DatabaseFlags{{Name: "max_connections", Value: "100"}, {Name: flagPostgresTimeZone, Value: "Asia/Tokyo"}}
+	if diff := cmp.Diff(want, mergeFlags(flags, overrides)); diff != "" {
+		t.Errorf("mergeFlags(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestFlagsEqual(t *testing.T) {
+	tz := &sqladmin.DatabaseFlags{Name: flagMySQLTimeZone, Value: "+09:00"}
+	cs := &sqladmin.DatabaseFlags{Name: flagMySQLCharacterSet, Value: "utf8mb4"}
+
+	cases := map[string]struct {
+		desired []*sqladmin.DatabaseFlags
+		actual  []*sqladmin.DatabaseFlags
+		want    bool
+	}{
+		"Reordered": {
+			desired: []*sqladmin.DatabaseFlags{tz, cs},
+			actual:  []*sqladmin.DatabaseFlags{cs, tz},
+			want:    true,
+		},
+		"Changed": {
+			desired: []*sqladmin.DatabaseFlags{tz},
+			actual:  []*sqladmin.DatabaseFlags{{Name: flagMySQLTimeZone, Value: "+00:00"}},
+			want:    false,
+		},
+		"Added": {
+			desired: []*sqladmin.DatabaseFlags{tz, cs},
+			actual:  []*sqladmin.DatabaseFlags{tz},
+			want:    false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := flagsEqual(tc.desired, tc.actual); got != tc.want {
+				t.Errorf("flagsEqual(...): want %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDesiredInstanceActiveDirectory(t *testing.T) {
+	cases := map[string]struct {
+		s    v1alpha1.CloudsqlInstanceSpec
+		want *sqladmin.SqlActiveDirectoryConfig
+	}{
+		"SQLServer": {
+			s: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion:       "SQLSERVER_2019_STANDARD",
+				ActiveDirectoryDomain: "ad.example.org",
+			},
+			want: &sqladmin.SqlActiveDirectoryConfig{Domain: "ad.example.org"},
+		},
+		"SQLServerNoDomain": {
+			s: v1alpha1.CloudsqlInstanceSpec{DatabaseVersion: "SQLSERVER_2019_STANDARD"},
+		},
+		"MySQL": {
+			s: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion:       "MYSQL_8_0",
+				ActiveDirectoryDomain: "ad.example.org",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := desiredInstance(&v1alpha1.CloudsqlInstance{Spec: tc.s}).Settings.ActiveDirectoryConfig
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("desiredInstance(...).Settings.ActiveDirectoryConfig: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+)
+
+// AnnotationSize may be set on an instance claim to request one of the size
+// classes defined by its resource class, e.g. small, so that the claim need
+// not specify a machine tier. The size class is applied when the instance is
+// configured; an explicit storage-gb annotation overrides its storage size.
+const AnnotationSize = "database.gcp.crossplane.io/size"
+
+// A resource class defines each size class using parameters of the form
+// sizeClass.<name>.<field>, for example:
+//
+//	sizeClass.small.tier: db-custom-1-3840
+//	sizeClass.small.storageGB: "10"
+//	sizeClass.large.tier: db-custom-8-30720
+//	sizeClass.large.storageGB: "500"
+//	sizeClass.large.storageType: PD_SSD
+//	sizeClass.large.flags: max_connections=1000,log_min_duration_statement=500
+//
+// Flags are a comma separated list of database flags, each name=value.
+const (
+	sizeClassParameterPrefix = "sizeClass."
+
+	sizeClassFieldTier        = "tier"
+	sizeClassFieldStorageGB   = "storageGB"
+	sizeClassFieldStorageType = "storageType"
+	sizeClassFieldFlags       = "flags"
+)
+
+// A sizeClass is a named combination of machine tier, disk, and database
+// flags.
+type sizeClass struct {
+	tier        string
+	storageGB   int64
+	storageType string
+	flags       map[string]string
+}
+
+// sizeClasses returns the size classes defined by the supplied resource class
+// parameters, keyed by name.
+func sizeClasses(parameters map[string]string) (map[string]*sizeClass, error) {
+	classes := map[string]*sizeClass{}
+	for k, v := range parameters {
+		if !strings.HasPrefix(k, sizeClassParameterPrefix) {
+			continue
+		}
+		p := strings.Split(strings.TrimPrefix(k, sizeClassParameterPrefix), ".")
+		if len(p) != 2 || p[0] == "" {
+			return nil, errors.Errorf("size class parameter %s must be of the form %s<name>.<field>", k, sizeClassParameterPrefix)
+		}
+		c, ok := classes[p[0]]
+		if !ok {
+			c = &sizeClass{}
+			classes[p[0]] = c
+		}
+		switch p[1] {
+		case sizeClassFieldTier:
+			c.tier = v
+		case sizeClassFieldStorageGB:
+			gb, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || gb <= 0 {
+				return nil, errors.Errorf("size class parameter %s must be a positive number of GB, not %q", k, v)
+			}
+			c.storageGB = gb
+		case sizeClassFieldStorageType:
+			c.storageType = v
+		case sizeClassFieldFlags:
+			flags, err := parseFlags(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid size class parameter %s", k)
+			}
+			c.flags = flags
+		default:
+			return nil, errors.Errorf("size class parameter %s has unknown field %s", k, p[1])
+		}
+	}
+	return classes, nil
+}
+
+// parseFlags parses a comma separated list of name=value database flags.
+func parseFlags(s string) (map[string]string, error) {
+	flags := map[string]string{}
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		p := strings.SplitN(f, "=", 2)
+		if len(p) != 2 || p[0] == "" {
+			return nil, errors.Errorf("database flag %q must be of the form name=value", f)
+		}
+		flags[p[0]] = p[1]
+	}
+	return flags, nil
+}
+
+// configureSize configures the supplied spec using the size class requested
+// by the supplied claim, if any, as defined by the supplied resource class
+// parameters.
+func configureSize(cm resource.Claim, parameters map[string]string, spec *v1alpha1.CloudsqlInstanceSpec) error {
+	size, ok := cm.GetAnnotations()[AnnotationSize]
+	if !ok {
+		return nil
+	}
+	classes, err := sizeClasses(parameters)
+	if err != nil {
+		return err
+	}
+	c, ok := classes[size]
+	if !ok {
+		return errors.Errorf("size %q is not defined by the resource class; defined sizes are %s", size, strings.Join(sizeNames(classes), ", "))
+	}
+
+	if c.tier != "" {
+		spec.Tier = c.tier
+	}
+	if c.storageGB != 0 {
+		spec.StorageGB = c.storageGB
+	}
+	if c.storageType != "" {
+		spec.StorageType = c.storageType
+	}
+	if len(c.flags) > 0 {
+		if spec.DatabaseFlags == nil {
+			spec.DatabaseFlags = map[string]string{}
+		}
+		for k, v := range c.flags {
+			spec.DatabaseFlags[k] = v
+		}
+	}
+	return nil
+}
+
+func sizeNames(classes map[string]*sizeClass) []string {
+	names := make([]string, 0, len(classes))
+	for n := range classes {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// specFlags returns the supplied database flags, sorted by name.
+func specFlags(flags map[string]string) []*sqladmin.DatabaseFlags {
+	if len(flags) == 0 {
+		return nil
+	}
+	out := make([]*sqladmin.DatabaseFlags, 0, len(flags))
+	for _, n := range sortedKeys(flags) {
+		out = append(out, &sqladmin.DatabaseFlags{Name: n, Value: flags[n]})
+	}
+	return out
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
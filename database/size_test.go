@@ -0,0 +1,132 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	databasev1alpha1 "github.com/crossplaneio/crossplane/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestConfigureSize(t *testing.T) {
+	parameters := map[string]string{
+		"sizeClass.small.tier":        "db-custom-1-3840",
+		"sizeClass.small.storageGB":   "10",
+		"sizeClass.large.tier":        "db-custom-8-30720",
+		"sizeClass.large.storageType": "PD_SSD",
+		"sizeClass.large.flags":       "max_connections=1000, log_min_duration_statement=500",
+	}
+
+	type want struct {
+		spec v1alpha1.CloudsqlInstanceSpec
+		err  error
+	}
+
+	cases := map[string]struct {
+		size       string
+		parameters map[string]string
+		want       want
+	}{
+		"NoSize": {
+			parameters: parameters,
+			want:       want{spec: v1alpha1.CloudsqlInstanceSpec{StorageGB: 20}},
+		},
+		"Small": {
+			size:       "small",
+			parameters: parameters,
+			want:       want{spec: v1alpha1.CloudsqlInstanceSpec{Tier: "db-custom-1-3840", StorageGB: 10}},
+		},
+		"Large": {
+			size:       "large",
+			parameters: parameters,
+			want: want{spec: v1alpha1.CloudsqlInstanceSpec{
+				Tier:          "db-custom-8-30720",
+				StorageGB:     20,
+				StorageType:   "PD_SSD",
+				DatabaseFlags: map[string]string{"max_connections": "1000", "log_min_duration_statement": "500"},
+			}},
+		},
+		"UndefinedSize": {
+			size:       "medium",
+			parameters: parameters,
+			want: want{
+				spec: v1alpha1.CloudsqlInstanceSpec{StorageGB: 20},
+				err:  errors.New(`size "medium" is not defined by the resource class; defined sizes are large, small`),
+			},
+		},
+		"InvalidStorage": {
+			size:       "small",
+			parameters: map[string]string{"sizeClass.small.storageGB": "lots"},
+			want: want{
+				spec: v1alpha1.CloudsqlInstanceSpec{StorageGB: 20},
+				err:  errors.New(`size class parameter sizeClass.small.storageGB must be a positive number of GB, not "lots"`),
+			},
+		},
+		"InvalidFlags": {
+			size:       "small",
+			parameters: map[string]string{"sizeClass.small.flags": "max_connections"},
+			want: want{
+				spec: v1alpha1.CloudsqlInstanceSpec{StorageGB: 20},
+				err:  errors.Wrap(errors.New(`database flag "max_connections" must be of the form name=value`), "invalid size class parameter sizeClass.small.flags"),
+			},
+		},
+		"UnknownField": {
+			size:       "small",
+			parameters: map[string]string{"sizeClass.small.cpus": "2"},
+			want: want{
+				spec: v1alpha1.CloudsqlInstanceSpec{StorageGB: 20},
+				err:  errors.New("size class parameter sizeClass.small.cpus has unknown field cpus"),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cm := &databasev1alpha1.PostgreSQLInstance{}
+			if tc.size != "" {
+				cm.ObjectMeta = metav1.ObjectMeta{Annotations: map[string]string{AnnotationSize: tc.size}}
+			}
+			spec := &v1alpha1.CloudsqlInstanceSpec{StorageGB: 20}
+			err := configureSize(cm, tc.parameters, spec)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("configureSize(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.spec, *spec); diff != "" {
+				t.Errorf("configureSize(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSpecFlags(t *testing.T) {
+	if got := specFlags(nil); got != nil {
+		t.Errorf("specFlags(nil): want nil, got %v", got)
+	}
+
+	got := specFlags(map[string]string{"b": "2", "a": "1"})
+	want := []*sqladmin.DatabaseFlags{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("specFlags(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,295 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+	core "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/database/sqladmintest"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/faultinject"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+// secretStore is a fake Kubernetes API that stores only secrets, and
+// accepts any update to a CloudsqlInstance.
+type secretStore struct {
+	secrets map[string]*core.Secret
+}
+
+func (s *secretStore) client() client.Client {
+	return &test.MockClient{
+		MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+			sec, ok := s.secrets[key.String()]
+			if !ok {
+				return kerrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, key.Name)
+			}
+			sec.DeepCopyInto(obj.(*core.Secret))
+			return nil
+		},
+		MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+			sec := obj.(*core.Secret)
+			s.secrets[client.ObjectKey{Namespace: sec.GetNamespace(), Name: sec.GetName()}.String()] = sec.DeepCopy()
+			return nil
+		},
+		MockUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+			if sec, ok := obj.(*core.Secret); ok {
+				s.secrets[client.ObjectKey{Namespace: sec.GetNamespace(), Name: sec.GetName()}.String()] = sec.DeepCopy()
+			}
+			return nil
+		},
+		MockStatusUpdate: func(_ context.Context, _ runtime.Object, _ ...client.UpdateOption) error { return nil },
+	}
+}
+
+func TestSyncDeleterWithServer(t *testing.T) {
+	ctx := context.Background()
+	name := getExpectedInstanceName(testUID)
+	now := meta1.Now()
+
+	errQuota := &googleapi.Error{
+		Code:    http.StatusForbidden,
+		Message: "Quota exceeded for quota metric 'Instances' and limit 'Instances per project'",
+		Errors:  []googleapi.ErrorItem{{Reason: "quotaExceeded", Message: "Quota exceeded"}},
+	}
+	quotaExceeded, _ := failureCondition(errQuota)
+	errInProgress := &googleapi.Error{
+		Code:    http.StatusConflict,
+		Message: "Operation failed because another operation was already in progress.",
+		Errors:  []googleapi.ErrorItem{{Reason: "operationInProgress", Message: "Operation failed because another operation was already in progress."}},
+	}
+	failedCreate := &sqladmin.Operation{
+		Name:   "operation-create",
+		Status: operationDone,
+		Error:  &sqladmin.OperationErrors{Errors: []*sqladmin.OperationError{{Code: "INTERNAL_ERROR", Message: "boom"}}},
+	}
+
+	cloudsqlInstance := func(deleted bool) *v1alpha1.CloudsqlInstance {
+		om := testMeta
+		om.CreationTimestamp = now
+		i := newInstance().
+			withObjectMeta(om).
+			withResourceSpec(newInstanceSpec().
+				withReclaimPolicy(corev1alpha1.ReclaimDelete).
+				withWriteConnectionSecretRef(core.LocalObjectReference{Name: testName}).build()).build()
+		i.Spec.DatabaseVersion = "MYSQL_5_7"
+		if deleted {
+			i.SetDeletionTimestamp(&now)
+			i.SetFinalizers([]string{finalizer})
+		}
+		return i
+	}
+
+	type want struct {
+		result     reconcile.Result
+		phase      v1alpha1.CloudsqlInstancePhase
+		conditions []corev1alpha1.Condition
+		finalizers []string
+		exists     bool
+		calls      []sqladmintest.Call
+	}
+
+	cases := map[string]struct {
+		server     func(s *sqladmintest.Server, i *v1alpha1.CloudsqlInstance)
+		faults     []faultinject.Fault
+		deleted    bool
+		operation  string
+		reconciles int
+		want       want
+	}{
+		"CreateFailure": {
+			server: func(s *sqladmintest.Server, _ *v1alpha1.CloudsqlInstance) {
+				s.AddOperation(failedCreate)
+			},
+			operation:  failedCreate.Name,
+			reconciles: 1,
+			want: want{
+				result:     requeueSync,
+				phase:      v1alpha1.PhaseFailed,
+				conditions: []corev1alpha1.Condition{corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(operationError(failedCreate))},
+				calls:      []sqladmintest.Call{sqladmintest.CallInstanceGet, sqladmintest.CallOperationGet},
+			},
+		},
+		"QuotaExceeded": {
+			server: func(s *sqladmintest.Server, _ *v1alpha1.CloudsqlInstance) {
+				s.FailNext(sqladmintest.CallInstanceInsert, errQuota)
+			},
+			reconciles: 1,
+			want: want{
+				result:     requeueNow,
+				phase:      v1alpha1.PhasePending,
+				conditions: []corev1alpha1.Condition{corev1alpha1.Creating(), quotaExceeded},
+				finalizers: []string{finalizer},
+				calls:      []sqladmintest.Call{sqladmintest.CallInstanceGet, sqladmintest.CallInstanceInsert},
+			},
+		},
+		"CreateThenBecomeRunnable": {
+			reconciles: 3,
+			want: want{
+				result:     requeueSync,
+				phase:      v1alpha1.PhaseRunning,
+				conditions: []corev1alpha1.Condition{corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()},
+				finalizers: []string{finalizer},
+				exists:     true,
+				calls: []sqladmintest.Call{
+					sqladmintest.CallInstanceGet, sqladmintest.CallInstanceInsert,
+					sqladmintest.CallInstanceGet,
+					sqladmintest.CallInstanceGet, sqladmintest.CallUserList, sqladmintest.CallUserUpdate,
+				},
+			},
+		},
+		"DeleteRetry": {
+			server: func(s *sqladmintest.Server, i *v1alpha1.CloudsqlInstance) {
+				s.AddInstance(desiredInstance(i), sqladmintest.StateRunnable)
+				s.FailNext(sqladmintest.CallInstanceDelete, errInProgress)
+			},
+			deleted:    true,
+			reconciles: 2,
+			want: want{
+				result:     requeueNow,
+				phase:      v1alpha1.PhaseDeleting,
+				conditions: []corev1alpha1.Condition{corev1alpha1.ReconcileError(errInProgress)},
+				calls:      []sqladmintest.Call{sqladmintest.CallInstanceDelete, sqladmintest.CallInstanceDelete},
+			},
+		},
+		"SecretRegeneration": {
+			server: func(s *sqladmintest.Server, i *v1alpha1.CloudsqlInstance) {
+				s.AddInstance(desiredInstance(i), sqladmintest.StateRunnable)
+			},
+			reconciles: 1,
+			want: want{
+				result:     requeueSync,
+				phase:      v1alpha1.PhaseRunning,
+				conditions: []corev1alpha1.Condition{corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()},
+				exists:     true,
+				calls:      []sqladmintest.Call{sqladmintest.CallInstanceGet, sqladmintest.CallUserList, sqladmintest.CallUserUpdate},
+			},
+		},
+		"RateLimited": {
+			faults:     []faultinject.Fault{faultinject.FaultTooManyRequests},
+			reconciles: 1,
+			want: want{
+				result:     requeueNow,
+				phase:      v1alpha1.PhasePending,
+				conditions: []corev1alpha1.Condition{corev1alpha1.ReconcileError(faultinject.Error(http.StatusTooManyRequests))},
+			},
+		},
+		"RecoverFromServerErrors": {
+			server: func(s *sqladmintest.Server, i *v1alpha1.CloudsqlInstance) {
+				s.AddInstance(desiredInstance(i), sqladmintest.StateRunnable)
+			},
+			faults:     []faultinject.Fault{faultinject.FaultInternalServerError, faultinject.FaultServiceUnavailable},
+			reconciles: 3,
+			want: want{
+				result:     requeueSync,
+				phase:      v1alpha1.PhaseRunning,
+				conditions: []corev1alpha1.Condition{corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()},
+				exists:     true,
+				calls:      []sqladmintest.Call{sqladmintest.CallInstanceGet, sqladmintest.CallUserList, sqladmintest.CallUserUpdate},
+			},
+		},
+	}
+
+	for n, tc := range cases {
+		t.Run(n, func(t *testing.T) {
+			s := sqladmintest.NewServer()
+			defer s.Close()
+
+			i := cloudsqlInstance(tc.deleted)
+			i.Status.Operation = tc.operation
+			if tc.server != nil {
+				tc.server(s, i)
+			}
+
+			secrets := &secretStore{secrets: map[string]*core.Secret{}}
+			lh := newLocalHandler(i, secrets.client())
+			lh.recorder = &record.FakeRecorder{}
+			opts := s.ClientOptions()
+			if tc.faults != nil {
+				fi := faultinject.New(faultinject.Options{Faults: tc.faults})
+				opts = append(opts, option.WithHTTPClient(fi.WrapClient(s.Client())))
+			}
+			mh, err := newManagedHandler(ctx, i, lh, &google.Credentials{ProjectID: "cool-project"}, opts...)
+			if err != nil {
+				t.Fatalf("newManagedHandler(...): %s", err)
+			}
+			sd := (&operationsFactory{}).makeSyncDeleter(mh)
+
+			var got reconcile.Result
+			for r := 0; r < tc.reconciles; r++ {
+				if tc.deleted {
+					got, _ = sd.delete(ctx)
+					continue
+				}
+				got, _ = sd.sync(ctx)
+			}
+
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("result: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.phase, i.Status.Phase); diff != "" {
+				t.Errorf("phase: -want, +got:\n%s", diff)
+			}
+			wantStatus := corev1alpha1.ConditionedStatus{}
+			wantStatus.SetConditions(tc.want.conditions...)
+			if diff := cmp.Diff(wantStatus, i.Status.ConditionedStatus, test.EquateConditions()); diff != "" {
+				t.Errorf("conditions: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.finalizers, i.GetFinalizers(), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("finalizers: -want, +got:\n%s", diff)
+			}
+			if exists := s.Instance(name) != nil; exists != tc.want.exists {
+				t.Errorf("instance exists: want %t, got %t", tc.want.exists, exists)
+			}
+			if diff := cmp.Diff(tc.want.calls, s.Calls()); diff != "" {
+				t.Errorf("calls: -want, +got:\n%s", diff)
+			}
+
+			// Each user password update must be recorded in the connection
+			// secret, which is regenerated if it does not exist.
+			u := s.User(name, v1alpha1.MysqlDefaultUser)
+			if u == nil || u.Password == "" {
+				return
+			}
+			sec, ok := secrets.secrets[testKey.String()]
+			if !ok {
+				t.Fatalf("connection secret %s was not created", testKey)
+			}
+			if diff := cmp.Diff(u.Password, string(sec.Data[corev1alpha1.ResourceCredentialsSecretPasswordKey])); diff != "" {
+				t.Errorf("password: -user, +secret:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,403 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sqladmintest provides a fake Cloud SQL Admin API server for tests.
+// Instances created through the fake move through a programmable sequence of
+// states, and errors may be queued for any call, so that the CloudSQL
+// controllers can be tested against a real sqladmin client.
+package sqladmintest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+)
+
+// A Call to the Cloud SQL Admin API, named after its REST method.
+type Call string
+
+// Calls supported by the fake server.
+const (
+	CallInstanceGet    Call = "instances.get"
+	CallInstanceInsert Call = "instances.insert"
+	CallInstancePatch  Call = "instances.patch"
+	CallInstanceUpdate Call = "instances.update"
+	CallInstanceDelete Call = "instances.delete"
+	CallOperationGet   Call = "operations.get"
+	CallUserList       Call = "users.list"
+	CallUserUpdate     Call = "users.update"
+)
+
+// Instance states reported by the fake server.
+const (
+	StatePendingCreate = "PENDING_CREATE"
+	StateRunnable      = "RUNNABLE"
+	StateFailed        = "FAILED"
+)
+
+const operationDone = "DONE"
+
+// Default users created with each new instance, by database engine.
+const (
+	defaultMySQLUser      = "root"
+	defaultPostgreSQLUser = "postgres"
+)
+
+type instance struct {
+	*sqladmin.DatabaseInstance
+
+	// states the instance will move through, one per get.
+	states []string
+	users  map[string]*sqladmin.User
+}
+
+// A Server is a fake Cloud SQL Admin API server. It is safe for concurrent
+// use.
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	instances  map[string]*instance
+	operations map[string]*sqladmin.Operation
+	errors     map[Call][]*googleapi.Error
+	calls      []Call
+	opCount    int
+
+	// createStates are the states through which instances created via the
+	// API move after they are pending creation, one per get.
+	createStates []string
+}
+
+// NewServer starts and returns a new fake Cloud SQL Admin API server. The
+// caller should call Close when finished, to shut it down. Instances created
+// via the API are pending creation until they are first read, and runnable
+// thereafter, unless programmed otherwise with SetCreateStates.
+func NewServer() *Server {
+	s := &Server{
+		instances:    make(map[string]*instance),
+		operations:   make(map[string]*sqladmin.Operation),
+		errors:       make(map[Call][]*googleapi.Error),
+		createStates: []string{StateRunnable},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// ClientOptions returns options that configure a sqladmin client to use the
+// fake server. No credentials are required.
+func (s *Server) ClientOptions() []option.ClientOption {
+	return []option.ClientOption{
+		option.WithEndpoint(s.URL + "/sql/v1beta4/"),
+		option.WithHTTPClient(s.Client()),
+	}
+}
+
+// AddInstance adds the supplied instance, as if it had already been created.
+// The instance starts in the first of the supplied states and moves to the
+// next each time it is read, remaining in the final state. A default user is
+// created for the instance.
+func (s *Server) AddInstance(i *sqladmin.DatabaseInstance, states ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addInstance(i, states)
+}
+
+// SetCreateStates programs the states through which instances that are
+// subsequently created via the API will move after they are first read while
+// pending creation, one per get.
+func (s *Server) SetCreateStates(states ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.createStates = states
+}
+
+// AddOperation adds the supplied operation, as if it had been started by a
+// previous call.
+func (s *Server) AddOperation(op *sqladmin.Operation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.operations[op.Name] = op
+}
+
+// FailNext queues the supplied error to be returned by the next instance of
+// the supplied call. Errors are returned in the order they were queued.
+func (s *Server) FailNext(c Call, err *googleapi.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors[c] = append(s.errors[c], err)
+}
+
+// Instance returns a copy of the named instance, or nil if it does not
+// exist.
+func (s *Server) Instance(name string) *sqladmin.DatabaseInstance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.instances[name]
+	if !ok {
+		return nil
+	}
+	c := *i.DatabaseInstance
+	return &c
+}
+
+// User returns a copy of the named user of the named instance, or nil if it
+// does not exist.
+func (s *Server) User(instanceName, name string) *sqladmin.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.instances[instanceName]
+	if !ok || i.users[name] == nil {
+		return nil
+	}
+	c := *i.users[name]
+	return &c
+}
+
+// Calls returns the calls made to the server, in the order they were made.
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call{}, s.calls...)
+}
+
+func (s *Server) addInstance(i *sqladmin.DatabaseInstance, states []string) *instance {
+	user := defaultMySQLUser
+	if strings.HasPrefix(i.DatabaseVersion, "POSTGRES") {
+		user = defaultPostgreSQLUser
+	}
+	if len(states) > 0 {
+		i.State, states = states[0], states[1:]
+	}
+	in := &instance{
+		DatabaseInstance: i,
+		states:           append([]string{}, states...),
+		users:            map[string]*sqladmin.User{user: {Name: user, Instance: i.Name, Project: i.Project}},
+	}
+	s.instances[i.Name] = in
+	return in
+}
+
+func (s *Server) newOperation(opType, target string) *sqladmin.Operation {
+	s.opCount++
+	op := &sqladmin.Operation{
+		Kind:          "sql#operation",
+		Name:          fmt.Sprintf("operation-%d", s.opCount),
+		OperationType: opType,
+		Status:        operationDone,
+		TargetId:      target,
+	}
+	s.operations[op.Name] = op
+	return op
+}
+
+// serve routes requests to the Cloud SQL Admin API, e.g.
+// /sql/v1beta4/projects/p/instances/i/users, by the path following the final
+// "projects/" segment. This is robust to the different base paths used by
+// different versions of the sqladmin client.
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := strings.LastIndex(r.URL.Path, "/projects/")
+	if idx < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	// project, collection, [name, [subcollection]]
+	p := strings.Split(strings.Trim(r.URL.Path[idx+len("/projects/"):], "/"), "/")
+
+	switch {
+	case len(p) == 2 && p[1] == "instances" && r.Method == http.MethodPost:
+		s.handle(w, CallInstanceInsert, func() (interface{}, error) { return s.insertInstance(p[0], r) })
+	case len(p) == 3 && p[1] == "instances" && r.Method == http.MethodGet:
+		s.handle(w, CallInstanceGet, func() (interface{}, error) { return s.getInstance(p[2]) })
+	case len(p) == 3 && p[1] == "instances" && r.Method == http.MethodPatch:
+		s.handle(w, CallInstancePatch, func() (interface{}, error) { return s.updateInstance(p[2], r, true) })
+	case len(p) == 3 && p[1] == "instances" && r.Method == http.MethodPut:
+		s.handle(w, CallInstanceUpdate, func() (interface{}, error) { return s.updateInstance(p[2], r, false) })
+	case len(p) == 3 && p[1] == "instances" && r.Method == http.MethodDelete:
+		s.handle(w, CallInstanceDelete, func() (interface{}, error) { return s.deleteInstance(p[2]) })
+	case len(p) == 3 && p[1] == "operations" && r.Method == http.MethodGet:
+		s.handle(w, CallOperationGet, func() (interface{}, error) { return s.getOperation(p[2]) })
+	case len(p) == 4 && p[1] == "instances" && p[3] == "users" && r.Method == http.MethodGet:
+		s.handle(w, CallUserList, func() (interface{}, error) { return s.listUsers(p[2]) })
+	case len(p) == 4 && p[1] == "instances" && p[3] == "users" && r.Method == http.MethodPut:
+		s.handle(w, CallUserUpdate, func() (interface{}, error) { return s.updateUser(p[2], r) })
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handle records the supplied call and writes either its queued error, or the
+// result of the supplied function.
+func (s *Server) handle(w http.ResponseWriter, c Call, fn func() (interface{}, error)) {
+	s.calls = append(s.calls, c)
+
+	if q := s.errors[c]; len(q) > 0 {
+		s.errors[c] = q[1:]
+		writeError(w, q[0])
+		return
+	}
+
+	body, err := fn()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (s *Server) insertInstance(project string, r *http.Request) (interface{}, error) {
+	i := &sqladmin.DatabaseInstance{}
+	if err := json.NewDecoder(r.Body).Decode(i); err != nil {
+		return nil, &googleapi.Error{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if _, ok := s.instances[i.Name]; ok {
+		return nil, conflict(fmt.Sprintf("instance %s already exists", i.Name))
+	}
+
+	i.Kind = "sql#instance"
+	i.Project = project
+	if i.Settings != nil {
+		i.Settings.SettingsVersion = 1
+	}
+	s.addInstance(i, append([]string{StatePendingCreate}, s.createStates...))
+	return s.newOperation("CREATE", i.Name), nil
+}
+
+// getInstance returns the named instance, then moves it to its next state.
+func (s *Server) getInstance(name string) (interface{}, error) {
+	i, ok := s.instances[name]
+	if !ok {
+		return nil, notFound(fmt.Sprintf("instance %s does not exist", name))
+	}
+	body, err := json.Marshal(i.DatabaseInstance)
+	if err != nil {
+		return nil, err
+	}
+	if len(i.states) > 0 {
+		i.State, i.states = i.states[0], i.states[1:]
+	}
+	return json.RawMessage(body), nil
+}
+
+// updateInstance replaces the settings of the named instance. A patch only
+// replaces the settings that are sent.
+func (s *Server) updateInstance(name string, r *http.Request, patch bool) (interface{}, error) {
+	i, ok := s.instances[name]
+	if !ok {
+		return nil, notFound(fmt.Sprintf("instance %s does not exist", name))
+	}
+	u := &sqladmin.DatabaseInstance{}
+	if patch {
+		// Decode over a copy of the current settings so that only the fields
+		// that were sent are changed.
+		u.Settings = &sqladmin.Settings{}
+		if i.Settings != nil {
+			*u.Settings = *i.Settings
+		}
+	}
+	if err := json.NewDecoder(r.Body).Decode(u); err != nil {
+		return nil, &googleapi.Error{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if u.Settings != nil {
+		version := int64(0)
+		if i.Settings != nil {
+			version = i.Settings.SettingsVersion
+		}
+		u.Settings.SettingsVersion = version + 1
+		i.Settings = u.Settings
+	}
+	return s.newOperation("UPDATE", name), nil
+}
+
+func (s *Server) deleteInstance(name string) (interface{}, error) {
+	if _, ok := s.instances[name]; !ok {
+		return nil, notFound(fmt.Sprintf("instance %s does not exist", name))
+	}
+	delete(s.instances, name)
+	return s.newOperation("DELETE", name), nil
+}
+
+func (s *Server) getOperation(name string) (interface{}, error) {
+	op, ok := s.operations[name]
+	if !ok {
+		return nil, notFound(fmt.Sprintf("operation %s does not exist", name))
+	}
+	return op, nil
+}
+
+func (s *Server) listUsers(instanceName string) (interface{}, error) {
+	i, ok := s.instances[instanceName]
+	if !ok {
+		return nil, notFound(fmt.Sprintf("instance %s does not exist", instanceName))
+	}
+	rsp := &sqladmin.UsersListResponse{Kind: "sql#usersList", Items: make([]*sqladmin.User, 0, len(i.users))}
+	for _, u := range i.users {
+		rsp.Items = append(rsp.Items, u)
+	}
+	return rsp, nil
+}
+
+func (s *Server) updateUser(instanceName string, r *http.Request) (interface{}, error) {
+	i, ok := s.instances[instanceName]
+	if !ok {
+		return nil, notFound(fmt.Sprintf("instance %s does not exist", instanceName))
+	}
+	u := &sqladmin.User{}
+	if err := json.NewDecoder(r.Body).Decode(u); err != nil {
+		return nil, &googleapi.Error{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	name := r.URL.Query().Get("name")
+	if _, ok := i.users[name]; !ok {
+		return nil, notFound(fmt.Sprintf("user %s does not exist", name))
+	}
+	u.Name = name
+	u.Instance = instanceName
+	i.users[name] = u
+	return s.newOperation("UPDATE_USER", instanceName), nil
+}
+
+func notFound(msg string) *googleapi.Error {
+	return &googleapi.Error{Code: http.StatusNotFound, Message: msg, Errors: []googleapi.ErrorItem{{Reason: "notFound", Message: msg}}}
+}
+
+func conflict(msg string) *googleapi.Error {
+	return &googleapi.Error{Code: http.StatusConflict, Message: msg, Errors: []googleapi.ErrorItem{{Reason: "alreadyExists", Message: msg}}}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	e, ok := err.(*googleapi.Error)
+	if !ok {
+		e = &googleapi.Error{Code: http.StatusInternalServerError, Message: err.Error()}
+	}
+	writeJSON(w, e.Code, struct {
+		Error *googleapi.Error `json:"error"`
+	}{Error: e})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sqladmintest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/googleapi"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+)
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer()
+	defer s.Close()
+	s.SetCreateStates(StateFailed)
+	s.FailNext(CallInstanceInsert, &googleapi.Error{Code: http.StatusForbidden, Message: "Quota exceeded"})
+
+	svc, err := sqladmin.NewService(ctx, s.ClientOptions()...)
+	if err != nil {
+		t.Fatalf("sqladmin.NewService(...): %s", err)
+	}
+
+	i := &sqladmin.DatabaseInstance{Name: "cool-instance", DatabaseVersion: "POSTGRES_11"}
+	if _, err := svc.Instances.Insert("cool-project", i).Context(ctx).Do(); err == nil {
+		t.Fatal("Insert(...): want queued error, got nil")
+	}
+	op, err := svc.Instances.Insert("cool-project", i).Context(ctx).Do()
+	if err != nil {
+		t.Fatalf("Insert(...): %s", err)
+	}
+	if op.Status != operationDone {
+		t.Errorf("Insert(...): want operation %s, got %s", operationDone, op.Status)
+	}
+
+	states := make([]string, 0, 3)
+	for n := 0; n < 3; n++ {
+		got, err := svc.Instances.Get("cool-project", "cool-instance").Context(ctx).Do()
+		if err != nil {
+			t.Fatalf("Get(...): %s", err)
+		}
+		states = append(states, got.State)
+	}
+	if diff := cmp.Diff([]string{StatePendingCreate, StateFailed, StateFailed}, states); diff != "" {
+		t.Errorf("Get(...): -want states, +got states:\n%s", diff)
+	}
+
+	if s.User("cool-instance", defaultPostgreSQLUser) == nil {
+		t.Errorf("User(...): want default user %s, got nil", defaultPostgreSQLUser)
+	}
+
+	if _, err := svc.Instances.Delete("cool-project", "cool-instance").Context(ctx).Do(); err != nil {
+		t.Fatalf("Delete(...): %s", err)
+	}
+	_, err = svc.Instances.Get("cool-project", "cool-instance").Context(ctx).Do()
+	if e, ok := err.(*googleapi.Error); !ok || e.Code != http.StatusNotFound {
+		t.Errorf("Get(...): want not found error, got %v", err)
+	}
+
+	want := []Call{CallInstanceInsert, CallInstanceInsert, CallInstanceGet, CallInstanceGet, CallInstanceGet, CallInstanceDelete, CallInstanceGet}
+	if diff := cmp.Diff(want, s.Calls()); diff != "" {
+		t.Errorf("Calls(): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+)
+
+// DefaultStorageAlertThresholdPercent is the disk utilization above which a
+// CloudsqlInstance that does not specify a threshold is warned about.
+const DefaultStorageAlertThresholdPercent = 90
+
+// TypeStorageWarning conditions indicate whether an instance's disk is nearly
+// full, or was recently grown by storage auto-resize.
+const TypeStorageWarning corev1alpha1.ConditionType = "StorageWarning"
+
+// Reasons an instance's storage may or may not be warned about. They are also
+// the reasons of the events recorded when a warning is raised.
+const (
+	ReasonStorageThresholdExceeded corev1alpha1.ConditionReason = "StorageThresholdExceeded"
+	ReasonStorageAutoResized       corev1alpha1.ConditionReason = "StorageAutoResized"
+	ReasonStorageWithinThreshold   corev1alpha1.ConditionReason = "StorageWithinThreshold"
+)
+
+// validateStorageAlertThreshold returns an error if the supplied threshold is
+// not a percentage.
+func validateStorageAlertThreshold(percent int) error {
+	if percent < 0 || percent > 100 {
+		return errors.Errorf("storageAlertThresholdPercent %d must be between 0 and 100", percent)
+	}
+	return nil
+}
+
+// storageCondition returns a condition describing the storage of the supplied
+// instance given its current metrics and those previously observed, which may
+// be nil. It returns false if the metrics don't report the disk's size.
+// Storage auto-resize is assumed to have grown the disk if it grew beyond the
+// size the spec requests.
+func storageCondition(spec v1alpha1.CloudsqlInstanceSpec, inst *sqladmin.DatabaseInstance, prev, cur *v1alpha1.CloudsqlInstanceMetrics) (corev1alpha1.Condition, bool) {
+	if cur == nil || cur.DiskQuotaBytes == 0 {
+		return corev1alpha1.Condition{}, false
+	}
+
+	c := corev1alpha1.Condition{
+		Type:               TypeStorageWarning,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonStorageWithinThreshold,
+	}
+
+	if prev != nil && prev.DiskQuotaBytes > 0 && cur.DiskQuotaBytes > prev.DiskQuotaBytes &&
+		inst.Settings != nil && inst.Settings.DataDiskSizeGb > spec.StorageGB {
+		c.Status = corev1.ConditionTrue
+		c.Reason = ReasonStorageAutoResized
+		c.Message = fmt.Sprintf("storage auto-resize grew the disk from %s to %s", formatBytes(prev.DiskQuotaBytes), formatBytes(cur.DiskQuotaBytes))
+		return c, true
+	}
+
+	threshold := spec.StorageAlertThresholdPercent
+	if threshold == 0 {
+		threshold = DefaultStorageAlertThresholdPercent
+	}
+	used := cur.DiskBytesUsed * 100 / cur.DiskQuotaBytes
+	c.Message = fmt.Sprintf("disk is %d%% full; %s of %s used", used, formatBytes(cur.DiskBytesUsed), formatBytes(cur.DiskQuotaBytes))
+	if used >= int64(threshold) {
+		c.Status = corev1.ConditionTrue
+		c.Reason = ReasonStorageThresholdExceeded
+		c.Message = fmt.Sprintf("%s, which exceeds the alert threshold of %d%%", c.Message, threshold)
+	}
+	return c, true
+}
+
+// observeStorage sets the storage warning condition of the supplied instance,
+// and records a warning event whenever a new warning is raised.
+func (h *managedHandler) observeStorage(inst *sqladmin.DatabaseInstance, prev, cur *v1alpha1.CloudsqlInstanceMetrics) {
+	c, ok := storageCondition(h.Spec, inst, prev, cur)
+	if !ok {
+		return
+	}
+	was := h.Status.GetCondition(TypeStorageWarning)
+	h.Status.SetConditions(c)
+
+	if c.Status != corev1.ConditionTrue || h.recorder == nil {
+		return
+	}
+	if was.Status == corev1.ConditionTrue && was.Reason == c.Reason && c.Reason != ReasonStorageAutoResized {
+		return
+	}
+	h.recorder.Event(h.CloudsqlInstance, corev1.EventTypeWarning, string(c.Reason), c.Message)
+}
+
+// formatBytes returns the supplied number of bytes in GiB, e.g. 10.5GiB.
+func formatBytes(b int64) string {
+	return fmt.Sprintf("%.1fGiB", float64(b)/(1<<30))
+}
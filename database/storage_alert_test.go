@@ -0,0 +1,155 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const gib = 1 << 30
+
+func TestStorageCondition(t *testing.T) {
+	inst := func(gb int64) *sqladmin.DatabaseInstance {
+		return &sqladmin.DatabaseInstance{Settings: &sqladmin.Settings{DataDiskSizeGb: gb}}
+	}
+	condition := func(s corev1.ConditionStatus, r corev1alpha1.ConditionReason, msg string) corev1alpha1.Condition {
+		return corev1alpha1.Condition{Type: TypeStorageWarning, Status: s, Reason: r, Message: msg}
+	}
+
+	type want struct {
+		c  corev1alpha1.Condition
+		ok bool
+	}
+	cases := map[string]struct {
+		spec v1alpha1.CloudsqlInstanceSpec
+		inst *sqladmin.DatabaseInstance
+		prev *v1alpha1.CloudsqlInstanceMetrics
+		cur  *v1alpha1.CloudsqlInstanceMetrics
+		want want
+	}{
+		"NoMetrics": {
+			spec: v1alpha1.CloudsqlInstanceSpec{StorageGB: 10},
+			inst: inst(10),
+			cur:  &v1alpha1.CloudsqlInstanceMetrics{Connections: 1},
+			want: want{ok: false},
+		},
+		"WithinDefaultThreshold": {
+			spec: v1alpha1.CloudsqlInstanceSpec{StorageGB: 10},
+			inst: inst(10),
+			cur:  &v1alpha1.CloudsqlInstanceMetrics{DiskBytesUsed: 5 * gib, DiskQuotaBytes: 10 * gib},
+			want: want{
+				c:  condition(corev1.ConditionFalse, ReasonStorageWithinThreshold, "disk is 50% full; 5.0GiB of 10.0GiB used"),
+				ok: true,
+			},
+		},
+		"ExceedsDefaultThreshold": {
+			spec: v1alpha1.CloudsqlInstanceSpec{StorageGB: 10},
+			inst: inst(10),
+			cur:  &v1alpha1.CloudsqlInstanceMetrics{DiskBytesUsed: 9 * gib, DiskQuotaBytes: 10 * gib},
+			want: want{
+				c:  condition(corev1.ConditionTrue, ReasonStorageThresholdExceeded, "disk is 90% full; 9.0GiB of 10.0GiB used, which exceeds the alert threshold of 90%"),
+				ok: true,
+			},
+		},
+		"ExceedsThreshold": {
+			spec: v1alpha1.CloudsqlInstanceSpec{StorageGB: 10, StorageAlertThresholdPercent: 50},
+			inst: inst(10),
+			cur:  &v1alpha1.CloudsqlInstanceMetrics{DiskBytesUsed: 5 * gib, DiskQuotaBytes: 10 * gib},
+			want: want{
+				c:  condition(corev1.ConditionTrue, ReasonStorageThresholdExceeded, "disk is 50% full; 5.0GiB of 10.0GiB used, which exceeds the alert threshold of 50%"),
+				ok: true,
+			},
+		},
+		"AutoResized": {
+			spec: v1alpha1.CloudsqlInstanceSpec{StorageGB: 10},
+			inst: inst(12),
+			prev: &v1alpha1.CloudsqlInstanceMetrics{DiskBytesUsed: 9 * gib, DiskQuotaBytes: 10 * gib},
+			cur:  &v1alpha1.CloudsqlInstanceMetrics{DiskBytesUsed: 9 * gib, DiskQuotaBytes: 12 * gib},
+			want: want{
+				c:  condition(corev1.ConditionTrue, ReasonStorageAutoResized, "storage auto-resize grew the disk from 10.0GiB to 12.0GiB"),
+				ok: true,
+			},
+		},
+		"ResizedBySpec": {
+			spec: v1alpha1.CloudsqlInstanceSpec{StorageGB: 20},
+			inst: inst(20),
+			prev: &v1alpha1.CloudsqlInstanceMetrics{DiskBytesUsed: 9 * gib, DiskQuotaBytes: 10 * gib},
+			cur:  &v1alpha1.CloudsqlInstanceMetrics{DiskBytesUsed: 9 * gib, DiskQuotaBytes: 20 * gib},
+			want: want{
+				c:  condition(corev1.ConditionFalse, ReasonStorageWithinThreshold, "disk is 45% full; 9.0GiB of 20.0GiB used"),
+				ok: true,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c, ok := storageCondition(tc.spec, tc.inst, tc.prev, tc.cur)
+			if diff := cmp.Diff(tc.want.c, c, test.EquateConditions()); diff != "" {
+				t.Errorf("storageCondition(...): -want, +got:\n%s", diff)
+			}
+			if ok != tc.want.ok {
+				t.Errorf("storageCondition(...) ok: want %t, got %t", tc.want.ok, ok)
+			}
+		})
+	}
+}
+
+func TestObserveStorage(t *testing.T) {
+	full := &v1alpha1.CloudsqlInstanceMetrics{DiskBytesUsed: 95 * gib, DiskQuotaBytes: 100 * gib}
+	exceeded, _ := storageCondition(v1alpha1.CloudsqlInstanceSpec{}, &sqladmin.DatabaseInstance{}, nil, full)
+
+	cases := map[string]struct {
+		current    []corev1alpha1.Condition
+		wantEvents int
+	}{
+		"NewWarning": {
+			wantEvents: 1,
+		},
+		"ExistingWarning": {
+			current:    []corev1alpha1.Condition{exceeded},
+			wantEvents: 0,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			i := &v1alpha1.CloudsqlInstance{}
+			i.Status.SetConditions(tc.current...)
+			r := record.NewFakeRecorder(10)
+			h := &managedHandler{CloudsqlInstance: i, recorder: r}
+
+			h.observeStorage(&sqladmin.DatabaseInstance{}, nil, full)
+
+			if got := i.Status.GetCondition(TypeStorageWarning).Reason; got != ReasonStorageThresholdExceeded {
+				t.Errorf("observeStorage(...): want reason %s, got %s", ReasonStorageThresholdExceeded, got)
+			}
+			if got := len(r.Events); got != tc.wantEvents {
+				t.Errorf("observeStorage(...): want %d events, got %d", tc.wantEvents, got)
+			}
+		})
+	}
+}
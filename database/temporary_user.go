@@ -0,0 +1,483 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/cloudsql"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/secretgc"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+	"github.com/crossplaneio/crossplane/pkg/util"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	temporaryUserControllerName = "cloudsqltemporaryusers.database.gcp.crossplane.io"
+	temporaryUserFinalizer      = "finalizer." + temporaryUserControllerName
+
+	// temporaryUserPrefix is prepended to part of the UID of a
+	// CloudsqlTemporaryUser to form the name of its database user. MySQL
+	// user names may be at most 32 characters long.
+	temporaryUserPrefix     = "tmp-"
+	temporaryUserNameLength = 16
+
+	// maxTemporaryUserTTL bounds the lifetime of a temporary user. Longer
+	// lived access should be granted by other means.
+	maxTemporaryUserTTL = 24 * time.Hour
+)
+
+// Event reasons of CloudsqlTemporaryUsers. Each grant and revocation is
+// recorded as an event, alongside the Cloud SQL admin audit log.
+const (
+	EventReasonAccessGranted = "AccessGranted"
+	EventReasonAccessRevoked = "AccessRevoked"
+)
+
+var temporaryUserLog = logging.Logger.WithName("controller." + temporaryUserControllerName)
+
+// A temporaryUserCreateSyncDeleter can create, sync, and delete temporary
+// users of Cloud SQL instances. Each method returns the result of the
+// reconcile, which is requeued when the user expires.
+type temporaryUserCreateSyncDeleter interface {
+	Create(ctx context.Context, u *v1alpha1.CloudsqlTemporaryUser) reconcile.Result
+	Sync(ctx context.Context, u *v1alpha1.CloudsqlTemporaryUser) reconcile.Result
+	Delete(ctx context.Context, u *v1alpha1.CloudsqlTemporaryUser) reconcile.Result
+}
+
+// temporaryUsers is a temporaryUserCreateSyncDeleter using the Cloud SQL
+// admin API.
+type temporaryUsers struct {
+	kube     client.Client
+	users    cloudsql.UserService
+	recorder record.EventRecorder
+	now      func() time.Time
+
+	// provider is the Provider whose credentials users are created with. It
+	// is recorded so that users can be revoked after their instance is gone.
+	provider *corev1.ObjectReference
+}
+
+// Create creates a database user of the referenced CloudsqlInstance and
+// writes its credentials to the user's connection secret. The user expires
+// its TTL after it is created.
+func (c *temporaryUsers) Create(ctx context.Context, u *v1alpha1.CloudsqlTemporaryUser) reconcile.Result {
+	u.Status.SetConditions(corev1alpha1.Creating())
+
+	if err := validateTemporaryUser(u); err != nil {
+		// Only a change to the spec can fix an invalid spec.
+		u.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return requeueNever
+	}
+
+	inst, err := resolveTemporaryUserInstance(ctx, c.kube, u)
+	if err != nil {
+		u.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return requeueWait
+	}
+
+	// The finalizer is persisted before the user is created, so that a user
+	// is never created that would not be revoked when this resource is
+	// deleted. The instance and Provider are persisted with it, so that the
+	// user can be revoked even if its CloudsqlInstance is deleted first.
+	meta.AddFinalizer(u, temporaryUserFinalizer)
+	u.Status.InstanceName = instanceName(inst)
+	u.Status.ProviderReference = c.provider
+	if err := c.kube.Update(ctx, u); err != nil {
+		u.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot add finalizer")))
+		return requeueNow
+	}
+
+	// The password is kept in the connection secret, which is written before
+	// the user is created so that a password is never lost.
+	name := temporaryUserName(u)
+	s, err := c.applySecret(ctx, u, inst, name)
+	if err != nil {
+		u.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot write connection secret")))
+		return requeueNow
+	}
+
+	user := &sqladmin.User{Name: name, Password: string(s.Data[corev1alpha1.ResourceCredentialsSecretPasswordKey])}
+	if isEngine(inst.Spec.DatabaseVersion, v1alpha1.MysqlDBVersionPrefix) {
+		user.Host = "%"
+	}
+
+	// We may have created the user but failed to record its name.
+	instance := instanceName(inst)
+	err = c.users.Create(ctx, instance, user)
+	if gcp.IsErrorAlreadyExists(err) {
+		err = c.users.Update(ctx, instance, name, user)
+	}
+	if err != nil {
+		u.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot create user of instance %s", instance)))
+		return requeueNow
+	}
+
+	expires := metav1.NewTime(c.now().Add(u.Spec.TTL.Duration))
+	u.Status.UserName = name
+	u.Status.ExpiresAt = &expires
+	u.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	c.recorder.Event(u, corev1.EventTypeNormal, EventReasonAccessGranted,
+		fmt.Sprintf("granted user %s access to instance %s until %s: %s", name, instance, expires.UTC().Format(time.RFC3339), u.Spec.Reason))
+	return reconcile.Result{RequeueAfter: u.Spec.TTL.Duration}
+}
+
+// Sync revokes the user once it has expired.
+func (c *temporaryUsers) Sync(ctx context.Context, u *v1alpha1.CloudsqlTemporaryUser) reconcile.Result {
+	if u.Status.Expired {
+		return requeueNever
+	}
+
+	if remaining := u.Status.ExpiresAt.Sub(c.now()); remaining > 0 {
+		u.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+		return reconcile.Result{RequeueAfter: remaining}
+	}
+
+	if err := c.revoke(ctx, u, "expired"); err != nil {
+		u.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return requeueNow
+	}
+	u.Status.Expired = true
+	u.Status.SetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess())
+	return requeueNever
+}
+
+// Delete revokes the user if it has not yet expired. The user may have been
+// created without its name being recorded, so it is revoked whenever the
+// finalizer is present. Temporary users are always revoked, regardless of
+// their reclaim policy.
+func (c *temporaryUsers) Delete(ctx context.Context, u *v1alpha1.CloudsqlTemporaryUser) reconcile.Result {
+	u.Status.SetConditions(corev1alpha1.Deleting())
+
+	if hasTemporaryUserFinalizer(u) && !u.Status.Expired {
+		if err := c.revoke(ctx, u, "deleted"); err != nil {
+			u.Status.SetConditions(corev1alpha1.ReconcileError(err))
+			return requeueNow
+		}
+	}
+
+	meta.RemoveFinalizer(u, temporaryUserFinalizer)
+	u.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return requeueNever
+}
+
+// revoke deletes the user and its connection secret, recording why. The user
+// is identified by its deterministic name, and by the instance it was granted
+// access to or, if that was not recorded, the instance it references.
+func (c *temporaryUsers) revoke(ctx context.Context, u *v1alpha1.CloudsqlTemporaryUser, why string) error {
+	name, instance := temporaryUserName(u), u.Status.InstanceName
+	if instance == "" {
+		inst, err := getTemporaryUserInstance(ctx, c.kube, u)
+		if err != nil {
+			return err
+		}
+		instance = instanceName(inst)
+	}
+
+	if err := c.users.Delete(ctx, instance, name); err != nil && !googleapi.IsErrorNotFound(err) {
+		return errors.Wrapf(err, "cannot delete user %s of instance %s", name, instance)
+	}
+	s := resource.ConnectionSecretFor(u, v1alpha1.CloudsqlTemporaryUserGroupVersionKind)
+	if err := c.kube.Delete(ctx, s); err != nil && !kerrors.IsNotFound(err) {
+		return errors.Wrapf(err, "cannot delete connection secret %s/%s", s.GetNamespace(), s.GetName())
+	}
+	c.recorder.Event(u, corev1.EventTypeNormal, EventReasonAccessRevoked,
+		fmt.Sprintf("revoked access of user %s to instance %s: %s", name, instance, why))
+	return nil
+}
+
+// hasTemporaryUserFinalizer returns true if the supplied user has the
+// finalizer added before its database user is created.
+func hasTemporaryUserFinalizer(u *v1alpha1.CloudsqlTemporaryUser) bool {
+	for _, f := range u.GetFinalizers() {
+		if f == temporaryUserFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// applySecret writes the connection secret of the supplied user, which
+// connects to the supplied instance. A password that was already written is
+// kept.
+func (c *temporaryUsers) applySecret(ctx context.Context, u *v1alpha1.CloudsqlTemporaryUser, inst *v1alpha1.CloudsqlInstance, name string) (*corev1.Secret, error) {
+	password, err := util.GeneratePassword(v1alpha1.PasswordLength)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot generate password")
+	}
+
+	secret := resource.ConnectionSecretFor(u, v1alpha1.CloudsqlTemporaryUserGroupVersionKind)
+	s := secret.DeepCopy()
+	err = util.CreateOrUpdate(ctx, c.kube, s, func() error {
+		if !meta.HaveSameController(s, secret) {
+			return errors.Errorf("connection secret %s/%s exists and is not controlled by %s/%s",
+				s.GetNamespace(), s.GetName(), u.GetNamespace(), u.GetName())
+		}
+		secretgc.Mark(s, u, v1alpha1.CloudsqlTemporaryUserGroupVersionKind)
+
+		if s.Data == nil {
+			s.Data = map[string][]byte{}
+		}
+		if _, found := s.Data[corev1alpha1.ResourceCredentialsSecretPasswordKey]; !found {
+			s.Data[corev1alpha1.ResourceCredentialsSecretPasswordKey] = []byte(password)
+		}
+		s.Data[corev1alpha1.ResourceCredentialsSecretUserKey] = []byte(name)
+		s.Data[corev1alpha1.ResourceCredentialsSecretEndpointKey] = inst.ConnectionSecret().Data[corev1alpha1.ResourceCredentialsSecretEndpointKey]
+		if isEngine(inst.Spec.DatabaseVersion, sqlServerDBVersionPrefix) {
+			s.Data[ConnectionSecretPortKey] = []byte(sqlServerPort)
+		}
+		return nil
+	})
+	return s, err
+}
+
+// validateTemporaryUser returns an error if the supplied user's TTL or reason
+// is invalid.
+func validateTemporaryUser(u *v1alpha1.CloudsqlTemporaryUser) error {
+	if u.Spec.TTL.Duration <= 0 || u.Spec.TTL.Duration > maxTemporaryUserTTL {
+		return errors.Errorf("ttl must be greater than zero and at most %s", maxTemporaryUserTTL)
+	}
+	if strings.TrimSpace(u.Spec.Reason) == "" {
+		return errors.New("reason must be specified")
+	}
+	return nil
+}
+
+// getTemporaryUserInstance returns the CloudsqlInstance referenced by the
+// supplied user, which must be in the user's namespace.
+func getTemporaryUserInstance(ctx context.Context, kube client.Client, u *v1alpha1.CloudsqlTemporaryUser) (*v1alpha1.CloudsqlInstance, error) {
+	inst := &v1alpha1.CloudsqlInstance{}
+	n := types.NamespacedName{Namespace: u.GetNamespace(), Name: u.Spec.InstanceRef.Name}
+	return inst, errors.Wrapf(kube.Get(ctx, n, inst), "cannot get cloudsql instance %s", n)
+}
+
+// resolveTemporaryUserInstance returns the CloudsqlInstance referenced by the
+// supplied user. It returns an error if the instance is not yet runnable.
+func resolveTemporaryUserInstance(ctx context.Context, kube client.Client, u *v1alpha1.CloudsqlTemporaryUser) (*v1alpha1.CloudsqlInstance, error) {
+	inst, err := getTemporaryUserInstance(ctx, kube, u)
+	if err != nil {
+		return nil, err
+	}
+	if inst.Status.State != v1alpha1.StateRunnable {
+		return nil, errors.Errorf("cloudsql instance %s/%s is not yet runnable", inst.GetNamespace(), inst.GetName())
+	}
+	return inst, nil
+}
+
+// temporaryUserName returns the name of the database user of the supplied
+// CloudsqlTemporaryUser.
+func temporaryUserName(u *v1alpha1.CloudsqlTemporaryUser) string {
+	id := strings.Replace(string(u.GetUID()), "-", "", -1)
+	if len(id) > temporaryUserNameLength-len(temporaryUserPrefix) {
+		id = id[:temporaryUserNameLength-len(temporaryUserPrefix)]
+	}
+	return temporaryUserPrefix + id
+}
+
+// A temporaryUserConnecter returns a temporaryUserCreateSyncDeleter that can
+// create, sync, and delete temporary users with an external store - for
+// example the GCP API.
+type temporaryUserConnecter interface {
+	Connect(context.Context, *v1alpha1.CloudsqlTemporaryUser) (temporaryUserCreateSyncDeleter, error)
+}
+
+// temporaryUserProviderConnecter is a temporaryUserConnecter that returns a
+// temporaryUserCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type temporaryUserProviderConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	recorder  record.EventRecorder
+	newClient func(ctx context.Context, creds *google.Credentials) (cloudsql.UserService, error)
+}
+
+// Connect returns a temporaryUserCreateSyncDeleter backed by the Cloud SQL
+// admin API. GCP credentials are read from the Crossplane Provider referenced
+// by the CloudsqlInstance of the supplied CloudsqlTemporaryUser, which is the
+// Provider that may manage the instance's users. If the instance is gone the
+// Provider recorded when the user was created is used instead.
+func (c *temporaryUserProviderConnecter) Connect(ctx context.Context, u *v1alpha1.CloudsqlTemporaryUser) (temporaryUserCreateSyncDeleter, error) {
+	var mg metav1.Object = u
+	ref := u.Status.ProviderReference
+	inst, err := getTemporaryUserInstance(ctx, c.kube, u)
+	switch {
+	case err == nil:
+		mg, ref = inst, inst.GetProviderReference()
+	case !kerrors.IsNotFound(errors.Cause(err)) || ref == nil:
+		return nil, err
+	}
+
+	p, err := c.providers.Get(ctx, c.kube, mg, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceSQLAdmin, cloudsql.DefaultScope)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := c.newClient(ctx, creds)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create new cloudsql user client")
+	}
+	return &temporaryUsers{
+		kube:     c.kube,
+		users:    users,
+		recorder: c.recorder,
+		now:      time.Now,
+		provider: &corev1.ObjectReference{Namespace: p.GetNamespace(), Name: p.GetName()},
+	}, nil
+}
+
+// TemporaryUserReconciler reconciles CloudsqlTemporaryUsers read from the
+// Kubernetes API with the Cloud SQL admin API.
+type TemporaryUserReconciler struct {
+	temporaryUserConnecter
+	kube client.Client
+}
+
+// TemporaryUserController is responsible for adding the
+// CloudsqlTemporaryUser controller and its corresponding reconciler to the
+// manager with any runtime configuration.
+type TemporaryUserController struct {
+	// DefaultProvider is used by temporary users that don't reference a
+	// provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new CloudsqlTemporaryUser Controller and adds it
+// to the Manager with default RBAC. The Manager will set fields on the
+// Controller and start it when the Manager is Started.
+func (c *TemporaryUserController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &TemporaryUserReconciler{
+		temporaryUserConnecter: &temporaryUserProviderConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			recorder:  mgr.GetEventRecorderFor(temporaryUserControllerName),
+			newClient: func(ctx context.Context, creds *google.Credentials) (cloudsql.UserService, error) {
+				return cloudsql.NewUserClient(ctx, creds)
+			},
+		},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(temporaryUserControllerName).
+		For(&v1alpha1.CloudsqlTemporaryUser{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listTemporaryUsers)).
+		Complete(r)
+}
+
+// Reconcile CloudsqlTemporaryUsers with the Cloud SQL admin API.
+func (r *TemporaryUserReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	temporaryUserLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.CloudsqlTemporaryUserKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultReconcileTimeout)
+	defer cancel()
+
+	u := &v1alpha1.CloudsqlTemporaryUser{}
+	if err := r.kube.Get(ctx, req.NamespacedName, u); err != nil {
+		if kerrors.IsNotFound(err) {
+			return requeueNever, nil
+		}
+		return requeueNever, errors.Wrapf(err, "cannot get temporary user %s", req.NamespacedName)
+	}
+
+	// A user that recorded no Provider can't be revoked once its instance is
+	// gone, but its instance's users were deleted with the instance.
+	if u.DeletionTimestamp != nil && u.Status.ProviderReference == nil {
+		if _, err := getTemporaryUserInstance(ctx, r.kube, u); kerrors.IsNotFound(errors.Cause(err)) {
+			meta.RemoveFinalizer(u, temporaryUserFinalizer)
+			u.Status.SetConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess())
+			return requeueNever, errors.Wrapf(r.kube.Update(ctx, u), "cannot update temporary user %s", req.NamespacedName)
+		}
+	}
+
+	client, err := r.Connect(ctx, u)
+	if err != nil {
+		u.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return requeueNow, errors.Wrapf(r.kube.Update(ctx, u), "cannot update temporary user %s", req.NamespacedName)
+	}
+
+	// The temporary user has been deleted from the API server. Revoke it.
+	if u.DeletionTimestamp != nil {
+		return client.Delete(ctx, u), errors.Wrapf(r.kube.Update(ctx, u), "cannot update temporary user %s", req.NamespacedName)
+	}
+
+	// The temporary user is unnamed. Assume it has not been created.
+	if u.Status.UserName == "" {
+		return client.Create(ctx, u), errors.Wrapf(r.kube.Update(ctx, u), "cannot update temporary user %s", req.NamespacedName)
+	}
+
+	// The temporary user exists. Revoke it if it has expired.
+	return client.Sync(ctx, u), errors.Wrapf(r.kube.Update(ctx, u), "cannot update temporary user %s", req.NamespacedName)
+}
+
+// listTemporaryUsers is a provider.Lister of temporary users. Each user uses
+// the Provider of the instance it references or, if that instance is gone, the
+// Provider it recorded.
+func listTemporaryUsers(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.CloudsqlTemporaryUserList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	il := &v1alpha1.CloudsqlInstanceList{}
+	if err := kube.List(ctx, il); err != nil {
+		return nil, err
+	}
+	instances := make(map[types.NamespacedName]*v1alpha1.CloudsqlInstance, len(il.Items))
+	for i := range il.Items {
+		instances[types.NamespacedName{Namespace: il.Items[i].GetNamespace(), Name: il.Items[i].GetName()}] = &il.Items[i]
+	}
+
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		inst, ok := instances[types.NamespacedName{Namespace: l.Items[i].GetNamespace(), Name: l.Items[i].Spec.InstanceRef.Name}]
+		if !ok {
+			if ref := l.Items[i].Status.ProviderReference; ref != nil {
+				refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: ref})
+			}
+			continue
+		}
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: inst.GetProviderReference()})
+	}
+	return refs, nil
+}
@@ -0,0 +1,522 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/cloudsql/fake"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/externalname"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	temporaryUserInstanceRef = "cool-instance"
+	temporaryUserUID         = "0123abcd-4567-89ef-0123-456789abcdef"
+	temporaryUserDBName      = "tmp-0123abcd4567"
+	temporaryUserInstance    = "cool-instance-name"
+)
+
+var temporaryUserProvider = &corev1.ObjectReference{Namespace: testNs, Name: "cool-provider"}
+
+var _ reconcile.Reconciler = &TemporaryUserReconciler{}
+
+type temporaryUserModifier func(*v1alpha1.CloudsqlTemporaryUser)
+
+func withTemporaryUserConditions(c ...corev1alpha1.Condition) temporaryUserModifier {
+	return func(u *v1alpha1.CloudsqlTemporaryUser) { u.Status.SetConditions(c...) }
+}
+
+func withTemporaryUserFinalizers(f ...string) temporaryUserModifier {
+	return func(u *v1alpha1.CloudsqlTemporaryUser) { u.ObjectMeta.Finalizers = f }
+}
+
+func withTemporaryUserTTL(d time.Duration) temporaryUserModifier {
+	return func(u *v1alpha1.CloudsqlTemporaryUser) { u.Spec.TTL = metav1.Duration{Duration: d} }
+}
+
+func withTemporaryUserGranted(expires time.Time) temporaryUserModifier {
+	return func(u *v1alpha1.CloudsqlTemporaryUser) {
+		t := metav1.NewTime(expires)
+		u.Status.UserName = temporaryUserDBName
+		u.Status.InstanceName = temporaryUserInstance
+		u.Status.ExpiresAt = &t
+	}
+}
+
+func withTemporaryUserInstance() temporaryUserModifier {
+	return func(u *v1alpha1.CloudsqlTemporaryUser) {
+		u.Status.InstanceName = temporaryUserInstance
+		u.Status.ProviderReference = temporaryUserProvider
+	}
+}
+
+func withTemporaryUserExpired() temporaryUserModifier {
+	return func(u *v1alpha1.CloudsqlTemporaryUser) { u.Status.Expired = true }
+}
+
+func temporaryUser(m ...temporaryUserModifier) *v1alpha1.CloudsqlTemporaryUser {
+	u := &v1alpha1.CloudsqlTemporaryUser{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  testNs,
+			Name:       testName,
+			UID:        temporaryUserUID,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.CloudsqlTemporaryUserSpec{
+			InstanceRef: corev1.LocalObjectReference{Name: temporaryUserInstanceRef},
+			TTL:         metav1.Duration{Duration: time.Hour},
+			Reason:      "INC-1234: investigate stuck migration",
+		},
+	}
+	for _, fn := range m {
+		fn(u)
+	}
+	return u
+}
+
+// getTemporaryUserInstance returns a MockGet that gets a CloudsqlInstance in
+// the supplied state, and reports that connection secrets don't exist.
+func getTemporaryUserInstance(state string) func(context.Context, client.ObjectKey, runtime.Object) error {
+	return func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+		switch o := obj.(type) {
+		case *v1alpha1.CloudsqlInstance:
+			o.SetName(key.Name)
+			o.SetNamespace(key.Namespace)
+			externalname.Set(o, temporaryUserInstance)
+			o.Spec.DatabaseVersion = "MYSQL_5_7"
+			o.Status.State = state
+			return nil
+		default:
+			return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+		}
+	}
+}
+
+func TestTemporaryUserCreate(t *testing.T) {
+	now := time.Now()
+	errConflict := &googleapi.Error{Code: http.StatusConflict}
+
+	cases := []struct {
+		name       string
+		kube       client.Client
+		users      *fake.MockUserClient
+		u          *v1alpha1.CloudsqlTemporaryUser
+		want       *v1alpha1.CloudsqlTemporaryUser
+		wantResult reconcile.Result
+	}{
+		{
+			name: "Successful",
+			kube: &test.MockClient{
+				MockGet:    getTemporaryUserInstance(v1alpha1.StateRunnable),
+				MockCreate: func(_ context.Context, _ runtime.Object, _ ...client.CreateOption) error { return nil },
+				MockUpdate: func(_ context.Context, _ runtime.Object, _ ...client.UpdateOption) error { return nil },
+			},
+			users: &fake.MockUserClient{
+				MockCreate: func(_ context.Context, instance string, u *sqladmin.User) error {
+					if u.Name != temporaryUserDBName || u.Host != "%" || u.Password == "" {
+						t.Errorf("Create(...): unexpected user %+v", u)
+					}
+					return nil
+				},
+			},
+			u: temporaryUser(),
+			want: temporaryUser(
+				withTemporaryUserFinalizers(temporaryUserFinalizer),
+				withTemporaryUserInstance(),
+				withTemporaryUserGranted(now.Add(time.Hour)),
+				withTemporaryUserConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantResult: reconcile.Result{RequeueAfter: time.Hour},
+		},
+		{
+			name: "AlreadyExists",
+			kube: &test.MockClient{
+				MockGet:    getTemporaryUserInstance(v1alpha1.StateRunnable),
+				MockCreate: func(_ context.Context, _ runtime.Object, _ ...client.CreateOption) error { return nil },
+				MockUpdate: func(_ context.Context, _ runtime.Object, _ ...client.UpdateOption) error { return nil },
+			},
+			users: &fake.MockUserClient{
+				MockCreate: func(_ context.Context, _ string, _ *sqladmin.User) error { return errConflict },
+				MockUpdate: func(_ context.Context, _, name string, _ *sqladmin.User) error {
+					if name != temporaryUserDBName {
+						t.Errorf("Update(...): want user %s, got %s", temporaryUserDBName, name)
+					}
+					return nil
+				},
+			},
+			u: temporaryUser(),
+			want: temporaryUser(
+				withTemporaryUserFinalizers(temporaryUserFinalizer),
+				withTemporaryUserInstance(),
+				withTemporaryUserGranted(now.Add(time.Hour)),
+				withTemporaryUserConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantResult: reconcile.Result{RequeueAfter: time.Hour},
+		},
+		{
+			name:  "InvalidTTL",
+			kube:  &test.MockClient{},
+			users: &fake.MockUserClient{},
+			u:     temporaryUser(withTemporaryUserTTL(48 * time.Hour)),
+			want: temporaryUser(
+				withTemporaryUserTTL(48*time.Hour),
+				withTemporaryUserConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Errorf("ttl must be greater than zero and at most %s", maxTemporaryUserTTL))),
+			),
+			wantResult: requeueNever,
+		},
+		{
+			name:  "InstanceNotRunnable",
+			kube:  &test.MockClient{MockGet: getTemporaryUserInstance(v1alpha1.StatePendingCreate)},
+			users: &fake.MockUserClient{},
+			u:     temporaryUser(),
+			want: temporaryUser(
+				withTemporaryUserConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Errorf("cloudsql instance %s/%s is not yet runnable", testNs, temporaryUserInstanceRef))),
+			),
+			wantResult: requeueWait,
+		},
+		{
+			name: "FailedAddFinalizer",
+			kube: &test.MockClient{
+				MockGet:    getTemporaryUserInstance(v1alpha1.StateRunnable),
+				MockUpdate: func(_ context.Context, _ runtime.Object, _ ...client.UpdateOption) error { return errTest },
+			},
+			users: &fake.MockUserClient{
+				MockCreate: func(_ context.Context, _ string, _ *sqladmin.User) error {
+					t.Errorf("Create(...): want no user created before the finalizer is persisted")
+					return nil
+				},
+			},
+			u: temporaryUser(),
+			want: temporaryUser(
+				withTemporaryUserFinalizers(temporaryUserFinalizer),
+				withTemporaryUserInstance(),
+				withTemporaryUserConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errTest, "cannot add finalizer"))),
+			),
+			wantResult: requeueNow,
+		},
+		{
+			name: "FailedCreate",
+			kube: &test.MockClient{
+				MockGet:    getTemporaryUserInstance(v1alpha1.StateRunnable),
+				MockCreate: func(_ context.Context, _ runtime.Object, _ ...client.CreateOption) error { return nil },
+				MockUpdate: func(_ context.Context, _ runtime.Object, _ ...client.UpdateOption) error { return nil },
+			},
+			users: &fake.MockUserClient{
+				MockCreate: func(_ context.Context, _ string, _ *sqladmin.User) error { return errTest },
+			},
+			u: temporaryUser(),
+			want: temporaryUser(
+				withTemporaryUserFinalizers(temporaryUserFinalizer),
+				withTemporaryUserInstance(),
+				withTemporaryUserConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrapf(errTest, "cannot create user of instance %s", temporaryUserInstance))),
+			),
+			wantResult: requeueNow,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &temporaryUsers{kube: tc.kube, users: tc.users, recorder: record.NewFakeRecorder(10), now: func() time.Time { return now }, provider: temporaryUserProvider}
+			got := c.Create(context.Background(), tc.u)
+
+			if diff := cmp.Diff(tc.wantResult, got); diff != "" {
+				t.Errorf("c.Create(...): -want result, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want, tc.u, test.EquateConditions()); diff != "" {
+				t.Errorf("u: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTemporaryUserSync(t *testing.T) {
+	now := time.Now()
+
+	cases := []struct {
+		name       string
+		kube       client.Client
+		users      *fake.MockUserClient
+		u          *v1alpha1.CloudsqlTemporaryUser
+		want       *v1alpha1.CloudsqlTemporaryUser
+		wantResult reconcile.Result
+	}{
+		{
+			name:  "NotExpired",
+			kube:  &test.MockClient{},
+			users: &fake.MockUserClient{},
+			u:     temporaryUser(withTemporaryUserGranted(now.Add(time.Minute))),
+			want: temporaryUser(
+				withTemporaryUserGranted(now.Add(time.Minute)),
+				withTemporaryUserConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantResult: reconcile.Result{RequeueAfter: time.Minute},
+		},
+		{
+			name: "Expired",
+			kube: &test.MockClient{
+				MockDelete: func(_ context.Context, obj runtime.Object, _ ...client.DeleteOption) error {
+					if _, ok := obj.(*corev1.Secret); !ok {
+						t.Errorf("Delete(...): want secret, got %T", obj)
+					}
+					return nil
+				},
+			},
+			users: &fake.MockUserClient{
+				MockDelete: func(_ context.Context, instance, name string) error {
+					if instance != temporaryUserInstance || name != temporaryUserDBName {
+						t.Errorf("Delete(...): unexpected user %s of instance %s", name, instance)
+					}
+					return nil
+				},
+			},
+			u: temporaryUser(withTemporaryUserGranted(now.Add(-time.Minute))),
+			want: temporaryUser(
+				withTemporaryUserGranted(now.Add(-time.Minute)),
+				withTemporaryUserExpired(),
+				withTemporaryUserConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantResult: requeueNever,
+		},
+		{
+			name: "FailedRevoke",
+			kube: &test.MockClient{},
+			users: &fake.MockUserClient{
+				MockDelete: func(_ context.Context, _, _ string) error { return errTest },
+			},
+			u: temporaryUser(withTemporaryUserGranted(now.Add(-time.Minute))),
+			want: temporaryUser(
+				withTemporaryUserGranted(now.Add(-time.Minute)),
+				withTemporaryUserConditions(corev1alpha1.ReconcileError(errors.Wrapf(errTest, "cannot delete user %s of instance %s", temporaryUserDBName, temporaryUserInstance))),
+			),
+			wantResult: requeueNow,
+		},
+		{
+			name:       "AlreadyExpired",
+			kube:       &test.MockClient{},
+			users:      &fake.MockUserClient{},
+			u:          temporaryUser(withTemporaryUserGranted(now.Add(-time.Minute)), withTemporaryUserExpired()),
+			want:       temporaryUser(withTemporaryUserGranted(now.Add(-time.Minute)), withTemporaryUserExpired()),
+			wantResult: requeueNever,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &temporaryUsers{kube: tc.kube, users: tc.users, recorder: record.NewFakeRecorder(10), now: func() time.Time { return now }}
+			got := c.Sync(context.Background(), tc.u)
+
+			if diff := cmp.Diff(tc.wantResult, got); diff != "" {
+				t.Errorf("c.Sync(...): -want result, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want, tc.u, test.EquateConditions()); diff != "" {
+				t.Errorf("u: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTemporaryUserDelete(t *testing.T) {
+	now := time.Now()
+	errNotFound := &googleapi.Error{Code: http.StatusNotFound}
+
+	cases := []struct {
+		name        string
+		users       *fake.MockUserClient
+		u           *v1alpha1.CloudsqlTemporaryUser
+		wantRevoked bool
+		want        *v1alpha1.CloudsqlTemporaryUser
+	}{
+		{
+			name: "Granted",
+			users: &fake.MockUserClient{
+				MockDelete: func(_ context.Context, _, _ string) error { return errNotFound },
+			},
+			u:           temporaryUser(withTemporaryUserGranted(now.Add(time.Minute)), withTemporaryUserFinalizers(temporaryUserFinalizer)),
+			wantRevoked: true,
+			want: temporaryUser(
+				withTemporaryUserGranted(now.Add(time.Minute)),
+				withTemporaryUserConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+		},
+		{
+			// The user was created, but its name was not recorded.
+			name: "Unrecorded",
+			users: &fake.MockUserClient{
+				MockDelete: func(_ context.Context, instance, name string) error {
+					if instance != temporaryUserInstance || name != temporaryUserDBName {
+						t.Errorf("Delete(...): unexpected user %s of instance %s", name, instance)
+					}
+					return nil
+				},
+			},
+			u:           temporaryUser(withTemporaryUserFinalizers(temporaryUserFinalizer)),
+			wantRevoked: true,
+			want:        temporaryUser(withTemporaryUserConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess())),
+		},
+		{
+			name:  "NeverCreated",
+			users: &fake.MockUserClient{},
+			u:     temporaryUser(),
+			want:  temporaryUser(withTemporaryUserConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess())),
+		},
+		{
+			name:  "Expired",
+			users: &fake.MockUserClient{},
+			u:     temporaryUser(withTemporaryUserGranted(now.Add(-time.Minute)), withTemporaryUserExpired(), withTemporaryUserFinalizers(temporaryUserFinalizer)),
+			want: temporaryUser(
+				withTemporaryUserGranted(now.Add(-time.Minute)),
+				withTemporaryUserExpired(),
+				withTemporaryUserConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			revoked := false
+			kube := &test.MockClient{
+				MockGet: getTemporaryUserInstance(v1alpha1.StateRunnable),
+				MockDelete: func(_ context.Context, _ runtime.Object, _ ...client.DeleteOption) error {
+					revoked = true
+					return nil
+				},
+			}
+			c := &temporaryUsers{kube: kube, users: tc.users, recorder: record.NewFakeRecorder(10), now: func() time.Time { return now }}
+			got := c.Delete(context.Background(), tc.u)
+
+			if diff := cmp.Diff(requeueNever, got); diff != "" {
+				t.Errorf("c.Delete(...): -want result, +got:\n%s", diff)
+			}
+			if revoked != tc.wantRevoked {
+				t.Errorf("c.Delete(...): want revoked %t, got %t", tc.wantRevoked, revoked)
+			}
+			if diff := cmp.Diff(tc.want, tc.u, test.EquateConditions()); diff != "" {
+				t.Errorf("u: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTemporaryUserName(t *testing.T) {
+	got := temporaryUserName(temporaryUser())
+	if got != temporaryUserDBName {
+		t.Errorf("temporaryUserName(...): want %s, got %s", temporaryUserDBName, got)
+	}
+}
+
+func TestListTemporaryUsers(t *testing.T) {
+	providerRef := &corev1.ObjectReference{Namespace: testNs, Name: "cool-provider"}
+	recordedRef := &corev1.ObjectReference{Namespace: testNs, Name: "recorded-provider"}
+	kube := &test.MockClient{
+		MockList: func(_ context.Context, obj runtime.Object, _ ...client.ListOption) error {
+			switch l := obj.(type) {
+			case *v1alpha1.CloudsqlTemporaryUserList:
+				orphan := temporaryUser()
+				orphan.Spec.InstanceRef.Name = "missing-instance"
+				recorded := temporaryUser()
+				recorded.Spec.InstanceRef.Name = "missing-instance"
+				recorded.Status.ProviderReference = recordedRef
+				l.Items = []v1alpha1.CloudsqlTemporaryUser{*temporaryUser(), *orphan, *recorded}
+			case *v1alpha1.CloudsqlInstanceList:
+				inst := v1alpha1.CloudsqlInstance{ObjectMeta: metav1.ObjectMeta{Namespace: testNs, Name: temporaryUserInstanceRef}}
+				inst.Spec.ProviderReference = providerRef
+				l.Items = []v1alpha1.CloudsqlInstance{inst}
+			}
+			return nil
+		},
+	}
+
+	refs, err := listTemporaryUsers(context.Background(), kube)
+	if err != nil {
+		t.Fatalf("listTemporaryUsers(...): %s", err)
+	}
+	if len(refs) != 2 {
+		t.Fatalf("listTemporaryUsers(...): want 2 references, got %d", len(refs))
+	}
+	if diff := cmp.Diff(providerRef, refs[0].Provider); diff != "" {
+		t.Errorf("listTemporaryUsers(...): -want provider, +got provider:\n%s", diff)
+	}
+	if diff := cmp.Diff(recordedRef, refs[1].Provider); diff != "" {
+		t.Errorf("listTemporaryUsers(...): -want recorded provider, +got provider:\n%s", diff)
+	}
+}
+
+type mockTemporaryUserConnecter struct {
+	mockConnect func(context.Context, *v1alpha1.CloudsqlTemporaryUser) (temporaryUserCreateSyncDeleter, error)
+}
+
+func (m *mockTemporaryUserConnecter) Connect(ctx context.Context, u *v1alpha1.CloudsqlTemporaryUser) (temporaryUserCreateSyncDeleter, error) {
+	return m.mockConnect(ctx, u)
+}
+
+func TestTemporaryUserReconcileInstanceGone(t *testing.T) {
+	deleted := metav1.Now()
+	var updated *v1alpha1.CloudsqlTemporaryUser
+	kube := &test.MockClient{
+		MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+			if u, ok := obj.(*v1alpha1.CloudsqlTemporaryUser); ok {
+				temporaryUser(withTemporaryUserFinalizers(temporaryUserFinalizer)).DeepCopyInto(u)
+				u.DeletionTimestamp = &deleted
+				return nil
+			}
+			return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+		},
+		MockUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+			updated = obj.(*v1alpha1.CloudsqlTemporaryUser)
+			return nil
+		},
+	}
+	r := &TemporaryUserReconciler{
+		kube: kube,
+		temporaryUserConnecter: &mockTemporaryUserConnecter{
+			mockConnect: func(context.Context, *v1alpha1.CloudsqlTemporaryUser) (temporaryUserCreateSyncDeleter, error) {
+				t.Errorf("Connect(...): unexpected call once the instance is gone")
+				return nil, errTest
+			},
+		},
+	}
+
+	got, err := r.Reconcile(reconcile.Request{NamespacedName: client.ObjectKey{Namespace: testNs, Name: testName}})
+	if err != nil {
+		t.Fatalf("r.Reconcile(...): %s", err)
+	}
+	if diff := cmp.Diff(requeueNever, got); diff != "" {
+		t.Errorf("r.Reconcile(...): -want result, +got:\n%s", diff)
+	}
+	if updated == nil || hasTemporaryUserFinalizer(updated) {
+		t.Errorf("r.Reconcile(...): want finalizer removed")
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+)
+
+// defaultTierRestartWindow is how long after a tier schedule window starts or
+// ends its tier change may be applied, if the schedule doesn't specify.
+const defaultTierRestartWindow = 1 * time.Hour
+
+// desiredTier returns the machine tier the supplied instance should have at
+// the supplied time, given the supplied base tier of its spec. Changing an
+// instance's tier restarts it, so the tier of a schedule window is only
+// applied during its schedule's restart window; outside it the instance keeps
+// its observed tier until the next window starts or ends. A change to the base
+// tier is requested by the user rather than the schedule, so it is applied
+// immediately.
+func desiredTier(i *v1alpha1.CloudsqlInstance, base string, now time.Time) string {
+	s := i.Spec.TierSchedule
+	if s == nil {
+		return base
+	}
+
+	// Invalid schedules are rejected before instances are created.
+	want, restart, err := scheduledTier(s, base, now)
+	if err != nil {
+		return base
+	}
+
+	current := i.Status.Tier
+	baseChanged := i.Status.BaseTier != "" && i.Status.BaseTier != base
+	if current == "" || current == want || restart || baseChanged {
+		return want
+	}
+	return current
+}
+
+// baseTier returns the tier of the supplied instance's spec, which its tier
+// schedule overrides while one of its windows is open.
+func baseTier(i *v1alpha1.CloudsqlInstance) string {
+	if s := i.DatabaseInstance(instanceName(i)).Settings; s != nil {
+		return s.Tier
+	}
+	return ""
+}
+
+// scheduledTier returns the tier of the first window of the supplied schedule
+// that is open at the supplied time, or the supplied base tier if no window is
+// open. It also returns true if any window started or ended within the
+// schedule's restart window before the supplied time. A window is open if it
+// will end before it next starts.
+func scheduledTier(s *v1alpha1.TierSchedule, base string, now time.Time) (string, bool, error) {
+	// LoadLocation returns UTC if no time zone is specified.
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return "", false, errors.Wrapf(err, "invalid tier schedule time zone %q", s.TimeZone)
+	}
+	now = now.In(loc)
+
+	restartWindow := defaultTierRestartWindow
+	if s.RestartWindow != nil {
+		restartWindow = s.RestartWindow.Duration
+	}
+	since := now.Add(-restartWindow)
+
+	tier, restart := base, false
+	open := false
+	for _, w := range s.Windows {
+		start, err := cron.ParseStandard(w.Start)
+		if err != nil {
+			return "", false, errors.Wrapf(err, "invalid tier schedule window start %q", w.Start)
+		}
+		end, err := cron.ParseStandard(w.End)
+		if err != nil {
+			return "", false, errors.Wrapf(err, "invalid tier schedule window end %q", w.End)
+		}
+		if !open && end.Next(now).Before(start.Next(now)) {
+			tier, open = w.Tier, true
+		}
+		if !start.Next(since).After(now) || !end.Next(since).After(now) {
+			restart = true
+		}
+	}
+	return tier, restart, nil
+}
+
+// validateTierSchedule returns an error if the supplied tier schedule can't
+// be parsed, or names no tier for one of its windows.
+func validateTierSchedule(s *v1alpha1.TierSchedule) error {
+	if s == nil {
+		return nil
+	}
+	for _, w := range s.Windows {
+		if w.Tier == "" {
+			return errors.Errorf("tier schedule window %q to %q must specify a tier", w.Start, w.End)
+		}
+	}
+	if s.RestartWindow != nil && s.RestartWindow.Duration <= 0 {
+		return errors.Errorf("tier schedule restart window %s must be positive", s.RestartWindow.Duration)
+	}
+	_, _, err := scheduledTier(s, "", time.Now())
+	return err
+}
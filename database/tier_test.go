@@ -0,0 +1,156 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestDesiredTier(t *testing.T) {
+	day := "db-custom-2-8192"
+	night := "db-custom-8-32768"
+	schedule := &v1alpha1.TierSchedule{
+		Windows: []v1alpha1.TierWindow{{Start: "0 22 * * *", End: "0 6 * * *", Tier: night}},
+	}
+	at := func(hour, min int) time.Time { return time.Date(2026, 10, 15, hour, min, 0, 0, time.UTC) }
+
+	instance := func(s *v1alpha1.TierSchedule, observed string) *v1alpha1.CloudsqlInstance {
+		i := &v1alpha1.CloudsqlInstance{Spec: v1alpha1.CloudsqlInstanceSpec{TierSchedule: s}}
+		i.Status.Tier = observed
+		return i
+	}
+
+	cases := map[string]struct {
+		i    *v1alpha1.CloudsqlInstance
+		now  time.Time
+		want string
+	}{
+		"NoSchedule": {
+			i:    instance(nil, night),
+			now:  at(23, 0),
+			want: day,
+		},
+		"NotYetCreated": {
+			i:    instance(schedule, ""),
+			now:  at(2, 0),
+			want: night,
+		},
+		"WindowStarted": {
+			i:    instance(schedule, day),
+			now:  at(22, 30),
+			want: night,
+		},
+		"WindowStartedOutsideRestartWindow": {
+			i:    instance(schedule, day),
+			now:  at(2, 0),
+			want: day,
+		},
+		"WindowEnded": {
+			i:    instance(schedule, night),
+			now:  at(6, 10),
+			want: day,
+		},
+		"WindowEndedOutsideRestartWindow": {
+			i:    instance(schedule, night),
+			now:  at(12, 0),
+			want: night,
+		},
+		"LongerRestartWindow": {
+			i: instance(&v1alpha1.TierSchedule{
+				Windows:       schedule.Windows,
+				RestartWindow: &metav1.Duration{Duration: 7 * time.Hour},
+			}, night),
+			now:  at(12, 0),
+			want: day,
+		},
+		"BaseTierChangedOutsideRestartWindow": {
+			i: func() *v1alpha1.CloudsqlInstance {
+				i := instance(schedule, "db-custom-1-3840")
+				i.Status.BaseTier = "db-custom-1-3840"
+				return i
+			}(),
+			now:  at(12, 0),
+			want: day,
+		},
+		"BaseTierUnchangedOutsideRestartWindow": {
+			i: func() *v1alpha1.CloudsqlInstance {
+				i := instance(schedule, night)
+				i.Status.BaseTier = day
+				return i
+			}(),
+			now:  at(12, 0),
+			want: night,
+		},
+		"UpToDate": {
+			i:    instance(schedule, night),
+			now:  at(2, 0),
+			want: night,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := desiredTier(tc.i, day, tc.now); got != tc.want {
+				t.Errorf("desiredTier(...): want %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateTierSchedule(t *testing.T) {
+	cases := map[string]struct {
+		s    *v1alpha1.TierSchedule
+		want error
+	}{
+		"Unset": {},
+		"Valid": {
+			s: &v1alpha1.TierSchedule{
+				TimeZone: "Europe/London",
+				Windows:  []v1alpha1.TierWindow{{Start: "0 22 * * 1-5", End: "0 6 * * 2-6", Tier: "db-custom-8-32768"}},
+			},
+		},
+		"NoTier": {
+			s:    &v1alpha1.TierSchedule{Windows: []v1alpha1.TierWindow{{Start: "0 22 * * *", End: "0 6 * * *"}}},
+			want: errors.New(`tier schedule window "0 22 * * *" to "0 6 * * *" must specify a tier`),
+		},
+		"NegativeRestartWindow": {
+			s:    &v1alpha1.TierSchedule{RestartWindow: &metav1.Duration{Duration: -time.Hour}},
+			want: errors.New("tier schedule restart window -1h0m0s must be positive"),
+		},
+		"InvalidTimeZone": {
+			s:    &v1alpha1.TierSchedule{TimeZone: "Mars/Olympus_Mons"},
+			want: errors.Wrapf(errors.New("unknown time zone Mars/Olympus_Mons"), "invalid tier schedule time zone %q", "Mars/Olympus_Mons"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := validateTierSchedule(tc.s)
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("validateTierSchedule(...): -want error, +got error:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+)
+
+// mysqlTimeZone matches the UTC offsets accepted by the MySQL
+// default_time_zone flag, from -12:59 to +13:00.
+var mysqlTimeZone = regexp.MustCompile(`^([+-](0[0-9]|1[0-2]):[0-5][0-9]|\+13:00)$`)
+
+// kmsKeyName matches the resource name of a Cloud KMS crypto key.
+var kmsKeyName = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/keyRings/[^/]+/cryptoKeys/[^/]+$`)
+
+// An invalidSpecError indicates that a CloudsqlInstance's spec is invalid for
+// its database engine. It is reported via an InvalidSpec condition.
+type invalidSpecError struct {
+	error
+}
+
+// invalidSpec returns an invalidSpecError wrapping the supplied error, or nil
+// if it is nil.
+func invalidSpec(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &invalidSpecError{error: err}
+}
+
+// validateSpec returns an error if the supplied CloudsqlInstance's spec is
+// invalid, or sets a database flag or feature its engine version does not
+// support.
+func validateSpec(i *v1alpha1.CloudsqlInstance) error {
+	if err := validateInstance(i.Spec); err != nil {
+		return err
+	}
+	return validateEngineSupport(i.Spec.DatabaseVersion, desiredInstance(i))
+}
+
+// validateInstance returns an error if the supplied CloudsqlInstance spec
+// configures settings that its database engine does not support. Cloud SQL
+// rejects these only once an instance is created or updated, so we check
+// them up front.
+func validateInstance(spec v1alpha1.CloudsqlInstanceSpec) error {
+	switch {
+	case isEngine(spec.DatabaseVersion, sqlServerDBVersionPrefix):
+		if spec.CharacterSet != "" {
+			return errors.New("characterSet is not supported by SQL Server instances; use a collation instead")
+		}
+		if spec.ActiveDirectoryDomain != "" && spec.PrivateNetwork == "" {
+			return errors.New("activeDirectoryDomain requires a privateNetwork; Managed Microsoft AD is only reachable via private IP")
+		}
+	case isEngine(spec.DatabaseVersion, v1alpha1.MysqlDBVersionPrefix):
+		if spec.TimeZone != "" && !mysqlTimeZone.MatchString(spec.TimeZone) {
+			return errors.Errorf("timeZone %q must be a UTC offset from -12:59 to +13:00, e.g. +09:00, for MySQL instances", spec.TimeZone)
+		}
+		if spec.Collation != "" && spec.CharacterSet != "" && !strings.HasPrefix(spec.Collation, spec.CharacterSet+"_") {
+			return errors.Errorf("collation %q is not a collation of character set %q", spec.Collation, spec.CharacterSet)
+		}
+	case isEngine(spec.DatabaseVersion, v1alpha1.PostgresqlDBVersionPrefix):
+		if spec.CharacterSet != "" || spec.Collation != "" {
+			return errors.New("characterSet and collation are not supported by PostgreSQL instances; they are configured per database")
+		}
+	}
+	if spec.ActiveDirectoryDomain != "" && !isEngine(spec.DatabaseVersion, sqlServerDBVersionPrefix) {
+		return errors.New("activeDirectoryDomain is only supported by SQL Server instances")
+	}
+	if spec.FinalBackupOnDelete && spec.FinalBackupBucketRef == nil {
+		return errNoFinalBackupBucket
+	}
+	if err := validateServiceAccountAccess(spec.ServiceAccountAccess); err != nil {
+		return err
+	}
+	if err := validateStorageAlertThreshold(spec.StorageAlertThresholdPercent); err != nil {
+		return err
+	}
+	if err := validateConnectionPooler(spec); err != nil {
+		return err
+	}
+	return validateTierSchedule(spec.TierSchedule)
+}
+
+// validateServiceAccountAccess returns an error if the supplied access names a
+// KMS key by anything but its full resource name, which is the only form the
+// Cloud KMS IAM API accepts.
+func validateServiceAccountAccess(a *v1alpha1.ServiceAccountAccess) error {
+	if a == nil {
+		return nil
+	}
+	for _, k := range a.KMSKeys {
+		if !kmsKeyName.MatchString(k) {
+			return errors.Errorf("serviceAccountAccess KMS key %q must be of the form projects/*/locations/*/keyRings/*/cryptoKeys/*", k)
+		}
+	}
+	return nil
+}
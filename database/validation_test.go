@@ -0,0 +1,148 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestValidateInstance(t *testing.T) {
+	cases := map[string]struct {
+		spec v1alpha1.CloudsqlInstanceSpec
+		want error
+	}{
+		"Empty": {
+			spec: v1alpha1.CloudsqlInstanceSpec{},
+		},
+		"FinalBackupWithoutBucket": {
+			spec: v1alpha1.CloudsqlInstanceSpec{FinalBackupOnDelete: true},
+			want: errNoFinalBackupBucket,
+		},
+		"FinalBackupToBucket": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				FinalBackupOnDelete:  true,
+				FinalBackupBucketRef: &corev1.LocalObjectReference{Name: "cool-bucket"},
+			},
+		},
+		"SQLServerLocale": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion: "SQLSERVER_2019_STANDARD",
+				TimeZone:        "Tokyo Standard Time",
+				Collation:       "Japanese_CI_AS",
+			},
+		},
+		"SQLServerCharacterSet": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion: "SQLSERVER_2019_STANDARD",
+				CharacterSet:    "utf8",
+			},
+			want: errors.New("characterSet is not supported by SQL Server instances; use a collation instead"),
+		},
+		"SQLServerActiveDirectory": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion:       "SQLSERVER_2019_STANDARD",
+				ActiveDirectoryDomain: "ad.example.org",
+				PrivateNetwork:        "projects/cool-project/global/networks/default",
+			},
+		},
+		"SQLServerActiveDirectoryPublicIP": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion:       "SQLSERVER_2019_STANDARD",
+				ActiveDirectoryDomain: "ad.example.org",
+			},
+			want: errors.New("activeDirectoryDomain requires a privateNetwork; Managed Microsoft AD is only reachable via private IP"),
+		},
+		"MySQLActiveDirectory": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion:       "MYSQL_8_0",
+				ActiveDirectoryDomain: "ad.example.org",
+			},
+			want: errors.New("activeDirectoryDomain is only supported by SQL Server instances"),
+		},
+		"MySQLLocale": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion: "MYSQL_8_0",
+				TimeZone:        "-03:30",
+				CharacterSet:    "utf8mb4",
+				Collation:       "utf8mb4_unicode_ci",
+			},
+		},
+		"MySQLNamedTimeZone": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion: "MYSQL_8_0",
+				TimeZone:        "Asia/Tokyo",
+			},
+			want: errors.New(`timeZone "Asia/Tokyo" must be a UTC offset from -12:59 to +13:00, e.g. +09:00, for MySQL instances`),
+		},
+		"MySQLMismatchedCollation": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion: "MYSQL_8_0",
+				CharacterSet:    "utf8mb4",
+				Collation:       "latin1_swedish_ci",
+			},
+			want: errors.New(`collation "latin1_swedish_ci" is not a collation of character set "utf8mb4"`),
+		},
+		"PostgreSQLTimeZone": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion: "POSTGRES_14",
+				TimeZone:        "Europe/Berlin",
+			},
+		},
+		"PostgreSQLCollation": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				DatabaseVersion: "POSTGRES_14",
+				Collation:       "de_DE.UTF8",
+			},
+			want: errors.New("characterSet and collation are not supported by PostgreSQL instances; they are configured per database"),
+		},
+		"ServiceAccountAccessKMSKey": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				ServiceAccountAccess: &v1alpha1.ServiceAccountAccess{
+					KMSKeys: []string{"projects/p/locations/us-central1/keyRings/r/cryptoKeys/k"},
+				},
+			},
+		},
+		"ServiceAccountAccessShortKMSKey": {
+			spec: v1alpha1.CloudsqlInstanceSpec{
+				ServiceAccountAccess: &v1alpha1.ServiceAccountAccess{
+					KMSKeys: []string{"r/k"},
+				},
+			},
+			want: errors.New(`serviceAccountAccess KMS key "r/k" must be of the form projects/*/locations/*/keyRings/*/cryptoKeys/*`),
+		},
+		"StorageAlertThresholdTooHigh": {
+			spec: v1alpha1.CloudsqlInstanceSpec{StorageAlertThresholdPercent: 120},
+			want: errors.New("storageAlertThresholdPercent 120 must be between 0 and 100"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := validateInstance(tc.spec)
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("validateInstance(...): -want error, +got error:\n%s", diff)
+			}
+		})
+	}
+}
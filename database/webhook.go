@@ -0,0 +1,184 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/externalname"
+)
+
+// CloudsqlInstanceValidationPath is the path at which the CloudsqlInstance
+// validating webhook is served.
+const CloudsqlInstanceValidationPath = "/validate/cloudsqlinstances.database.gcp.crossplane.io"
+
+// CloudsqlInstanceValidationWebhook rejects updates to CloudsqlInstances that
+// change fields Cloud SQL cannot change once an instance exists. Without it the
+// controller cannot apply such changes, and the spec would never again match
+// the instance it describes. It also rejects CloudsqlInstances whose spec sets
+// a database flag or feature their engine version does not support, which
+// the controller would otherwise only report via an InvalidSpec condition.
+type CloudsqlInstanceValidationWebhook struct{}
+
+// ServeHTTP handles an AdmissionReview sent by the API server.
+func (w *CloudsqlInstanceValidationWebhook) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	review := &admissionv1beta1.AdmissionReview{}
+	if err := json.NewDecoder(req.Body).Decode(review); err != nil || review.Request == nil {
+		http.Error(rw, "cannot decode admission review", http.StatusBadRequest)
+		return
+	}
+
+	review.Response = admitCloudsqlInstance(review.Request)
+	review.Request = nil
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(review); err != nil {
+		log.Error(err, "cannot encode admission review")
+	}
+}
+
+// admitCloudsqlInstance allows the supplied AdmissionRequest unless it creates
+// a CloudsqlInstance with an invalid spec, or is an update that changes an
+// immutable field or makes the spec invalid. Updates that don't change the
+// spec are always allowed, so that existing CloudsqlInstances whose spec is
+// invalid may still be deleted.
+func admitCloudsqlInstance(req *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	rsp := &admissionv1beta1.AdmissionResponse{UID: req.UID, Allowed: true}
+	switch req.Operation {
+	case admissionv1beta1.Create:
+		i := &v1alpha1.CloudsqlInstance{}
+		if err := json.Unmarshal(req.Object.Raw, i); err != nil {
+			return deny(rsp, errors.Wrap(err, "cannot decode CloudsqlInstance"))
+		}
+		return deny(rsp, validateSpec(i))
+	case admissionv1beta1.Update:
+	default:
+		return rsp
+	}
+
+	old, updated := &v1alpha1.CloudsqlInstance{}, &v1alpha1.CloudsqlInstance{}
+	if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+		return deny(rsp, errors.Wrap(err, "cannot decode existing CloudsqlInstance"))
+	}
+	if err := json.Unmarshal(req.Object.Raw, updated); err != nil {
+		return deny(rsp, errors.Wrap(err, "cannot decode updated CloudsqlInstance"))
+	}
+
+	if err := validateInstanceUpdate(old, updated); err != nil {
+		return deny(rsp, err)
+	}
+	if reflect.DeepEqual(old.Spec, updated.Spec) {
+		return rsp
+	}
+	return deny(rsp, validateSpec(updated))
+}
+
+// deny denies the supplied response with the supplied error, if any.
+func deny(rsp *admissionv1beta1.AdmissionResponse, err error) *admissionv1beta1.AdmissionResponse {
+	if err == nil {
+		return rsp
+	}
+	rsp.Allowed = false
+	rsp.Result = &metav1.Status{Status: metav1.StatusFailure, Reason: metav1.StatusReasonInvalid, Message: err.Error()}
+	return rsp
+}
+
+// validateInstanceUpdate returns an error if the supplied updated
+// CloudsqlInstance changes a field that Cloud SQL cannot change in place.
+func validateInstanceUpdate(old, updated *v1alpha1.CloudsqlInstance) error {
+	if n := externalname.Get(old); n != "" && externalname.Get(updated) != n {
+		return errors.Errorf("annotation %s is immutable: it records the name of Cloud SQL instance %q, which cannot be renamed; "+
+			"create a new CloudsqlInstance to use a different name", externalname.AnnotationExternalName, n)
+	}
+
+	if old.Spec.Region != updated.Spec.Region {
+		return errors.Errorf("spec.region is immutable: cannot move Cloud SQL instance from %q to %q; "+
+			"create a new CloudsqlInstance in %q and migrate your data to it", old.Spec.Region, updated.Spec.Region, updated.Spec.Region)
+	}
+
+	return validateDatabaseVersionUpdate(old.Spec.DatabaseVersion, updated.Spec.DatabaseVersion)
+}
+
+// validateDatabaseVersionUpdate returns an error if updating from the supplied
+// old database version to the supplied new one would change database engine
+// or downgrade a major version. Cloud SQL supports in-place major version
+// upgrades, but not downgrades.
+func validateDatabaseVersionUpdate(old, updated string) error {
+	if old == updated || old == "" {
+		return nil
+	}
+
+	oe, ue := engineOf(old), engineOf(updated)
+	if oe != ue {
+		return errors.Errorf("spec.databaseVersion cannot change database engine from %s to %s; "+
+			"create a new CloudsqlInstance and migrate your data to it", old, updated)
+	}
+
+	if compareVersions(versionOf(old), versionOf(updated)) > 0 {
+		return errors.Errorf("spec.databaseVersion cannot be downgraded from %s to %s; "+
+			"restore a backup of the instance to a new CloudsqlInstance running %s instead", old, updated, updated)
+	}
+
+	return nil
+}
+
+// engineOf returns the engine of the supplied Cloud SQL database version, e.g.
+// POSTGRES for POSTGRES_14.
+func engineOf(version string) string {
+	return strings.SplitN(version, "_", 2)[0]
+}
+
+// versionOf returns the numeric components of the supplied Cloud SQL database
+// version, e.g. [8 0] for MYSQL_8_0, or [2019] for SQLSERVER_2019_STANDARD.
+// Non-numeric components such as the SQL Server edition are ignored.
+func versionOf(version string) []int {
+	v := []int{}
+	for _, s := range strings.Split(version, "_")[1:] {
+		if n, err := strconv.Atoi(s); err == nil {
+			v = append(v, n)
+		}
+	}
+	return v
+}
+
+// compareVersions returns a positive number if version a is greater than
+// version b, a negative number if it is less, and zero if they are equal.
+// Missing trailing components are treated as zero.
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
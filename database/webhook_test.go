@@ -0,0 +1,202 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/externalname"
+)
+
+func webhookInstance(region, version, externalName string) *v1alpha1.CloudsqlInstance {
+	i := &v1alpha1.CloudsqlInstance{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "cool", Name: "cool-instance"},
+		Spec:       v1alpha1.CloudsqlInstanceSpec{Region: region, DatabaseVersion: version},
+	}
+	if externalName != "" {
+		externalname.Set(i, externalName)
+	}
+	return i
+}
+
+func TestValidateInstanceUpdate(t *testing.T) {
+	cases := map[string]struct {
+		old     *v1alpha1.CloudsqlInstance
+		updated *v1alpha1.CloudsqlInstance
+		wantErr bool
+	}{
+		"Unchanged": {
+			old:     webhookInstance("us-west2", "POSTGRES_14", "cool-db"),
+			updated: webhookInstance("us-west2", "POSTGRES_14", "cool-db"),
+		},
+		"MajorVersionUpgrade": {
+			old:     webhookInstance("us-west2", "POSTGRES_14", ""),
+			updated: webhookInstance("us-west2", "POSTGRES_15", ""),
+		},
+		"MinorVersionUpgrade": {
+			old:     webhookInstance("us-west2", "MYSQL_8_0", ""),
+			updated: webhookInstance("us-west2", "MYSQL_8_0_31", ""),
+		},
+		"SQLServerEditionChange": {
+			old:     webhookInstance("us-west2", "SQLSERVER_2019_STANDARD", ""),
+			updated: webhookInstance("us-west2", "SQLSERVER_2019_ENTERPRISE", ""),
+		},
+		"ExternalNameSet": {
+			old:     webhookInstance("us-west2", "POSTGRES_14", ""),
+			updated: webhookInstance("us-west2", "POSTGRES_14", "cool-db"),
+		},
+		"RegionChanged": {
+			old:     webhookInstance("us-west2", "POSTGRES_14", ""),
+			updated: webhookInstance("us-east1", "POSTGRES_14", ""),
+			wantErr: true,
+		},
+		"VersionDowngraded": {
+			old:     webhookInstance("us-west2", "MYSQL_8_0", ""),
+			updated: webhookInstance("us-west2", "MYSQL_5_7", ""),
+			wantErr: true,
+		},
+		"EngineChanged": {
+			old:     webhookInstance("us-west2", "MYSQL_8_0", ""),
+			updated: webhookInstance("us-west2", "POSTGRES_14", ""),
+			wantErr: true,
+		},
+		"ExternalNameChanged": {
+			old:     webhookInstance("us-west2", "POSTGRES_14", "cool-db"),
+			updated: webhookInstance("us-west2", "POSTGRES_14", "other-db"),
+			wantErr: true,
+		},
+		"ExternalNameRemoved": {
+			old:     webhookInstance("us-west2", "POSTGRES_14", "cool-db"),
+			updated: webhookInstance("us-west2", "POSTGRES_14", ""),
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateInstanceUpdate(tc.old, tc.updated)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("validateInstanceUpdate(...): want error %t, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCloudsqlInstanceValidationWebhook(t *testing.T) {
+	cases := map[string]struct {
+		op          admissionv1beta1.Operation
+		old         *v1alpha1.CloudsqlInstance
+		updated     *v1alpha1.CloudsqlInstance
+		wantAllowed bool
+	}{
+		"CreateAllowed": {
+			op:          admissionv1beta1.Create,
+			updated:     webhookInstance("us-west2", "POSTGRES_14", ""),
+			wantAllowed: true,
+		},
+		"UpdateAllowed": {
+			op:          admissionv1beta1.Update,
+			old:         webhookInstance("us-west2", "POSTGRES_14", ""),
+			updated:     webhookInstance("us-west2", "POSTGRES_15", ""),
+			wantAllowed: true,
+		},
+		"UpdateDenied": {
+			op:      admissionv1beta1.Update,
+			old:     webhookInstance("us-west2", "POSTGRES_14", ""),
+			updated: webhookInstance("us-east1", "POSTGRES_14", ""),
+		},
+		"CreateUnsupportedFlagDenied": {
+			op:      admissionv1beta1.Create,
+			updated: withFlags(webhookInstance("us-west2", "SQLSERVER_2019_STANDARD", ""), map[string]string{"cloudsql.iam_authentication": "on"}),
+		},
+		"UpdateUnsupportedFlagDenied": {
+			op:      admissionv1beta1.Update,
+			old:     webhookInstance("us-west2", "MYSQL_5_6", ""),
+			updated: withFlags(webhookInstance("us-west2", "MYSQL_5_6", ""), map[string]string{"cloudsql.iam_authentication": "on"}),
+		},
+		"UpdateUnchangedInvalidSpecAllowed": {
+			op:          admissionv1beta1.Update,
+			old:         withFlags(webhookInstance("us-west2", "MYSQL_5_6", ""), map[string]string{"cloudsql.iam_authentication": "on"}),
+			updated:     withLabel(withFlags(webhookInstance("us-west2", "MYSQL_5_6", ""), map[string]string{"cloudsql.iam_authentication": "on"}), "team", "cool"),
+			wantAllowed: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			review := &admissionv1beta1.AdmissionReview{
+				Request: &admissionv1beta1.AdmissionRequest{
+					UID:       types.UID("cool-uid"),
+					Operation: tc.op,
+					Object:    runtime.RawExtension{Raw: mustMarshal(t, tc.updated)},
+				},
+			}
+			if tc.old != nil {
+				review.Request.OldObject = runtime.RawExtension{Raw: mustMarshal(t, tc.old)}
+			}
+
+			rec := httptest.NewRecorder()
+			(&CloudsqlInstanceValidationWebhook{}).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, CloudsqlInstanceValidationPath, bytes.NewReader(mustMarshal(t, review))))
+
+			got := &admissionv1beta1.AdmissionReview{}
+			if err := json.Unmarshal(rec.Body.Bytes(), got); err != nil {
+				t.Fatalf("json.Unmarshal(...): %s", err)
+			}
+			if got.Response == nil {
+				t.Fatal("AdmissionReview.Response: want response, got nil")
+			}
+			if got.Response.UID != review.Request.UID {
+				t.Errorf("AdmissionReview.Response.UID: want %s, got %s", review.Request.UID, got.Response.UID)
+			}
+			if got.Response.Allowed != tc.wantAllowed {
+				t.Errorf("AdmissionReview.Response.Allowed: want %t, got %t", tc.wantAllowed, got.Response.Allowed)
+			}
+			if !tc.wantAllowed && (got.Response.Result == nil || got.Response.Result.Message == "") {
+				t.Errorf("AdmissionReview.Response.Result: want a message explaining the denial, got %+v", got.Response.Result)
+			}
+		})
+	}
+}
+
+func withFlags(i *v1alpha1.CloudsqlInstance, flags map[string]string) *v1alpha1.CloudsqlInstance {
+	i.Spec.DatabaseFlags = flags
+	return i
+}
+
+func withLabel(i *v1alpha1.CloudsqlInstance, k, v string) *v1alpha1.CloudsqlInstance {
+	i.SetLabels(map[string]string{k: v})
+	return i
+}
+
+func mustMarshal(t *testing.T, o interface{}) []byte {
+	t.Helper()
+	b, err := json.Marshal(o)
+	if err != nil {
+		t.Fatalf("json.Marshal(...): %s", err)
+	}
+	return b
+}
@@ -0,0 +1,398 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataflow
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	dataflow "google.golang.org/api/dataflow/v1b3"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/dataflow/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	dataflowclient "github.com/crossplaneio/crossplane/pkg/clients/gcp/dataflow"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	controllerName   = "jobs.dataflow.gcp.crossplane.io"
+	finalizerName    = "finalizer." + controllerName
+	reconcileTimeout = 1 * time.Minute
+
+	// jobNamePrefix is prepended to the UID of a Job to name its Dataflow job.
+	jobNamePrefix = "job-"
+)
+
+// Dataflow job states and types. See
+// https://cloud.google.com/dataflow/docs/reference/rest/v1b3/projects.jobs#Job.JobState
+const (
+	jobStatePending    = "JOB_STATE_PENDING"
+	jobStateQueued     = "JOB_STATE_QUEUED"
+	jobStateStarting   = "JOB_STATE_STARTING"
+	jobStateRunning    = "JOB_STATE_RUNNING"
+	jobStateDone       = "JOB_STATE_DONE"
+	jobStateFailed     = "JOB_STATE_FAILED"
+	jobStateCancelled  = "JOB_STATE_CANCELLED"
+	jobStateUpdated    = "JOB_STATE_UPDATED"
+	jobStateDraining   = "JOB_STATE_DRAINING"
+	jobStateDrained    = "JOB_STATE_DRAINED"
+	jobStateCancelling = "JOB_STATE_CANCELLING"
+
+	jobTypeStreaming = "JOB_TYPE_STREAMING"
+)
+
+var log = logging.Logger.WithName("controller." + controllerName)
+
+// A createsyncdeleter can create, sync, and delete Dataflow jobs in an
+// external store - e.g. the GCP API. Each method returns true if the job
+// requires further reconciliation.
+type createsyncdeleter interface {
+	Create(ctx context.Context, j *v1alpha1.Job) (requeue bool)
+	Sync(ctx context.Context, j *v1alpha1.Job) (requeue bool)
+	Delete(ctx context.Context, j *v1alpha1.Job) (requeue bool)
+}
+
+// dataflowJobs is a createsyncdeleter using the GCP Dataflow API.
+type dataflowJobs struct {
+	client  dataflowclient.Client
+	kube    client.Client
+	project string
+}
+
+// Create launches the job from its classic or flex template. The finalizer is
+// persisted before the job is launched, so that a job whose ID is never
+// recorded is still stopped when the Job is deleted. A job that already
+// exists was launched by a previous reconcile, and is adopted.
+func (d *dataflowJobs) Create(ctx context.Context, j *v1alpha1.Job) bool {
+	j.Status.SetConditions(corev1alpha1.Creating())
+
+	meta.AddFinalizer(j, finalizerName)
+	if err := d.kube.Update(ctx, j); err != nil {
+		j.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot persist finalizer")))
+		return true
+	}
+
+	job, err := d.launch(ctx, j)
+	if gcp.IsErrorAlreadyExists(errors.Cause(err)) {
+		job, err = d.findJob(ctx, j)
+		if err == nil && job == nil {
+			err = errors.Errorf("job %s already exists but was not found", jobName(j))
+		}
+	}
+	if err != nil {
+		j.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	j.Status.JobID = job.Id
+	j.Status.State = job.CurrentState
+	j.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// jobName returns the name of the Dataflow job launched for the supplied Job.
+func jobName(j *v1alpha1.Job) string {
+	return jobNamePrefix + string(j.GetUID())
+}
+
+// findJob returns the Dataflow job launched for the supplied Job, or nil if
+// there is none. Jobs are found by name, preferring a job that has not yet
+// stopped.
+func (d *dataflowJobs) findJob(ctx context.Context, j *v1alpha1.Job) (*dataflow.Job, error) {
+	jobs, err := d.client.ListJobs(ctx, d.project, j.Spec.Location)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list jobs")
+	}
+	var found *dataflow.Job
+	for _, job := range jobs {
+		if job.Name != jobName(j) {
+			continue
+		}
+		if !isTerminal(job.CurrentState) {
+			return job, nil
+		}
+		found = job
+	}
+	return found, nil
+}
+
+func (d *dataflowJobs) launch(ctx context.Context, j *v1alpha1.Job) (*dataflow.Job, error) {
+	name := jobName(j)
+	env := &dataflow.RuntimeEnvironment{
+		ServiceAccountEmail: j.Spec.ServiceAccountEmail,
+		TempLocation:        j.Spec.TempLocation,
+		MaxWorkers:          j.Spec.MaxWorkers,
+		Network:             j.Spec.Network,
+		Subnetwork:          j.Spec.Subnetwork,
+	}
+
+	switch {
+	case j.Spec.FlexTemplateGCSPath != "":
+		p := &dataflow.LaunchFlexTemplateParameter{
+			JobName:              name,
+			ContainerSpecGcsPath: j.Spec.FlexTemplateGCSPath,
+			Parameters:           j.Spec.Parameters,
+			Environment: &dataflow.FlexTemplateRuntimeEnvironment{
+				ServiceAccountEmail: env.ServiceAccountEmail,
+				TempLocation:        env.TempLocation,
+				MaxWorkers:          env.MaxWorkers,
+				Network:             env.Network,
+				Subnetwork:          env.Subnetwork,
+			},
+		}
+		job, err := d.client.LaunchFlexTemplate(ctx, d.project, j.Spec.Location, p)
+		return job, errors.Wrapf(err, "cannot launch flex template %s", j.Spec.FlexTemplateGCSPath)
+	case j.Spec.TemplateGCSPath != "":
+		p := &dataflow.LaunchTemplateParameters{
+			JobName:     name,
+			Parameters:  j.Spec.Parameters,
+			Environment: env,
+		}
+		job, err := d.client.LaunchTemplate(ctx, d.project, j.Spec.Location, j.Spec.TemplateGCSPath, p)
+		return job, errors.Wrapf(err, "cannot launch template %s", j.Spec.TemplateGCSPath)
+	}
+	return nil, errors.New("one of templateGcsPath or flexTemplateGcsPath must be set")
+}
+
+// Sync observes the state of the job. Dataflow jobs cannot be updated in
+// place, so changes to a launched job's spec are ignored.
+func (d *dataflowJobs) Sync(ctx context.Context, j *v1alpha1.Job) bool {
+	job, err := d.client.GetJob(ctx, d.project, j.Spec.Location, j.Status.JobID)
+	if err != nil {
+		j.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	j.Status.State = job.CurrentState
+	j.Status.Type = job.Type
+
+	switch job.CurrentState {
+	case jobStateRunning, jobStateDone:
+		j.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+		return false
+	case jobStatePending, jobStateQueued, jobStateStarting:
+		j.Status.SetConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess())
+		return true
+	case jobStateFailed, jobStateCancelled, jobStateDrained, jobStateUpdated:
+		// The job has stopped and will never run again.
+		j.Status.SetConditions(corev1alpha1.ReconcileError(errors.Errorf("job %s is in terminal state %s", j.Status.JobID, job.CurrentState)))
+		return false
+	}
+
+	j.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Delete stops the job, if it is still running. Streaming jobs are drained
+// rather than cancelled if the job requests it, allowing them to finish
+// processing buffered data. The job's finalizer is removed only once it has
+// stopped.
+func (d *dataflowJobs) Delete(ctx context.Context, j *v1alpha1.Job) bool {
+	j.Status.SetConditions(corev1alpha1.Deleting())
+
+	if j.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		job, err := d.observe(ctx, j)
+		if err != nil {
+			j.Status.SetConditions(corev1alpha1.ReconcileError(err))
+			return true
+		}
+		if job != nil && !isTerminal(job.CurrentState) {
+			j.Status.JobID = job.Id
+			j.Status.State = job.CurrentState
+			if requeue := d.stop(ctx, j, job); requeue {
+				return true
+			}
+		}
+	}
+
+	meta.RemoveFinalizer(j, finalizerName)
+	j.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// observe returns the Dataflow job of the supplied Job, or nil if it does not
+// exist. A job whose ID was never recorded is found by name.
+func (d *dataflowJobs) observe(ctx context.Context, j *v1alpha1.Job) (*dataflow.Job, error) {
+	if j.Status.JobID == "" {
+		return d.findJob(ctx, j)
+	}
+	job, err := d.client.GetJob(ctx, d.project, j.Spec.Location, j.Status.JobID)
+	if googleapi.IsErrorNotFound(err) {
+		return nil, nil
+	}
+	return job, err
+}
+
+// stop requests that the supplied job be drained or cancelled. It returns
+// true until the job has stopped.
+func (d *dataflowJobs) stop(ctx context.Context, j *v1alpha1.Job, job *dataflow.Job) bool {
+	// Jobs that are already draining or cancelling are left to finish.
+	if job.CurrentState == jobStateDraining || job.CurrentState == jobStateCancelling {
+		j.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	// Only streaming jobs may be drained.
+	want := jobStateCancelled
+	if j.Spec.DrainOnDelete && job.Type == jobTypeStreaming {
+		want = jobStateDraining
+	}
+
+	if err := d.client.UpdateJob(ctx, d.project, j.Spec.Location, j.Status.JobID, &dataflow.Job{RequestedState: want}); err != nil {
+		j.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot request job state %s", want)))
+		return true
+	}
+
+	j.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// isTerminal returns true if a job in the supplied state has stopped.
+func isTerminal(state string) bool {
+	switch state {
+	case jobStateDone, jobStateFailed, jobStateCancelled, jobStateDrained, jobStateUpdated:
+		return true
+	}
+	return false
+}
+
+// A connecter returns a createsyncdeleter that can create, sync, and delete
+// Dataflow jobs with an external store - for example the GCP API.
+type connecter interface {
+	Connect(context.Context, *v1alpha1.Job) (createsyncdeleter, error)
+}
+
+// providerConnecter is a connecter that returns a createsyncdeleter
+// authenticated using credentials read from a Crossplane Provider resource.
+type providerConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (dataflowclient.Client, error)
+}
+
+// Connect returns a createsyncdeleter backed by the GCP API. GCP credentials
+// are read from the Crossplane Provider referenced by the supplied Job.
+func (c *providerConnecter) Connect(ctx context.Context, j *v1alpha1.Job) (createsyncdeleter, error) {
+	p, err := c.providers.Get(ctx, c.kube, j, j.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceDataflow)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return &dataflowJobs{client: client, kube: c.kube, project: p.Spec.ProjectID}, errors.Wrap(err, "cannot create new Dataflow client")
+}
+
+// Reconciler reconciles Jobs read from the Kubernetes API with an external
+// store, typically the GCP API.
+type Reconciler struct {
+	connecter
+	kube client.Client
+}
+
+// JobController is responsible for adding the Dataflow Job controller and its
+// corresponding reconciler to the manager with any runtime configuration.
+type JobController struct {
+	// DefaultProvider is used by jobs that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new Job Controller and adds it to the Manager
+// with default RBAC. The Manager will set fields on the Controller and start
+// it when the Manager is Started.
+func (c *JobController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &Reconciler{
+		connecter: &providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: dataflowclient.NewClient,
+		},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerName).
+		For(&v1alpha1.Job{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listJobs)).
+		Complete(r)
+}
+
+// Reconcile Google Dataflow jobs with the GCP API.
+func (r *Reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	log.V(logging.Debug).Info("reconciling", "kind", v1alpha1.JobKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	j := &v1alpha1.Job{}
+	if err := r.kube.Get(ctx, req.NamespacedName, j); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get job %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, j)
+	if err != nil {
+		j.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, j), "cannot update job %s", req.NamespacedName)
+	}
+
+	// The job has been deleted from the API server. Stop it in GCP.
+	if j.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, j)}, errors.Wrapf(r.kube.Update(ctx, j), "cannot update job %s", req.NamespacedName)
+	}
+
+	// The job has no ID. Assume it has not been launched in GCP.
+	if j.Status.JobID == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, j)}, errors.Wrapf(r.kube.Update(ctx, j), "cannot update job %s", req.NamespacedName)
+	}
+
+	// The job exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, j)}, errors.Wrapf(r.kube.Update(ctx, j), "cannot update job %s", req.NamespacedName)
+}
+
+// listJobs is a provider.Lister of Dataflow jobs.
+func listJobs(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.JobList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,514 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataflow
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	dataflow "google.golang.org/api/dataflow/v1b3"
+	"google.golang.org/api/googleapi"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/dataflow/v1alpha1"
+	fakedataflow "github.com/crossplaneio/crossplane/pkg/clients/gcp/dataflow/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace    = "cool-namespace"
+	jobName      = "cool-job"
+	uid          = types.UID("definitely-a-uuid")
+	project      = "coolProject"
+	location     = "us-central1"
+	providerName = "cool-gcp"
+	jobID        = "2019-10-01_00_00_00-1234"
+	template     = "gs://dataflow-templates/latest/Word_Count"
+	flexTemplate = "gs://cool-bucket/templates/cool.json"
+)
+
+var (
+	ctx           = context.Background()
+	errorBoom     = errors.New("boom")
+	errorNotFound = &googleapi.Error{Code: http.StatusNotFound}
+	errorConflict = &googleapi.Error{Code: http.StatusConflict}
+)
+
+// Test that our Reconciler implementation satisfies the Reconciler interface.
+var _ reconcile.Reconciler = &Reconciler{}
+
+type jobModifier func(*v1alpha1.Job)
+
+func withConditions(c ...corev1alpha1.Condition) jobModifier {
+	return func(j *v1alpha1.Job) { j.Status.SetConditions(c...) }
+}
+
+func withFinalizers(f ...string) jobModifier {
+	return func(j *v1alpha1.Job) { j.ObjectMeta.Finalizers = f }
+}
+
+func withReclaimPolicy(p corev1alpha1.ReclaimPolicy) jobModifier {
+	return func(j *v1alpha1.Job) { j.Spec.ReclaimPolicy = p }
+}
+
+func withFlexTemplate(path string) jobModifier {
+	return func(j *v1alpha1.Job) {
+		j.Spec.TemplateGCSPath = ""
+		j.Spec.FlexTemplateGCSPath = path
+	}
+}
+
+func withDrainOnDelete() jobModifier {
+	return func(j *v1alpha1.Job) { j.Spec.DrainOnDelete = true }
+}
+
+func withJobID(id string) jobModifier {
+	return func(j *v1alpha1.Job) { j.Status.JobID = id }
+}
+
+func withState(s string) jobModifier {
+	return func(j *v1alpha1.Job) { j.Status.State = s }
+}
+
+func withType(t string) jobModifier {
+	return func(j *v1alpha1.Job) { j.Status.Type = t }
+}
+
+func job(jm ...jobModifier) *v1alpha1.Job {
+	j := &v1alpha1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       jobName,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.JobSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			JobParameters: v1alpha1.JobParameters{
+				Location:        location,
+				TemplateGCSPath: template,
+				Parameters:      map[string]string{"inputFile": "gs://cool-bucket/input.txt"},
+			},
+		},
+	}
+
+	for _, m := range jm {
+		m(j)
+	}
+
+	return j
+}
+
+func TestCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		j           *v1alpha1.Job
+		want        *v1alpha1.Job
+		wantRequeue bool
+	}{
+		{
+			name: "SuccessfulLaunchTemplate",
+			csd: &dataflowJobs{project: project, kube: test.NewMockClient(), client: &fakedataflow.MockClient{
+				MockLaunchTemplate: func(_ context.Context, _, _, path string, p *dataflow.LaunchTemplateParameters) (*dataflow.Job, error) {
+					if path != template {
+						t.Errorf("path: want %s, got %s", template, path)
+					}
+					if p.JobName != jobNamePrefix+string(uid) {
+						t.Errorf("p.JobName: want %s, got %s", jobNamePrefix+string(uid), p.JobName)
+					}
+					return &dataflow.Job{Id: jobID, CurrentState: jobStatePending}, nil
+				},
+			}},
+			j: job(),
+			want: job(
+				withFinalizers(finalizerName),
+				withJobID(jobID),
+				withState(jobStatePending),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "SuccessfulLaunchFlexTemplate",
+			csd: &dataflowJobs{project: project, kube: test.NewMockClient(), client: &fakedataflow.MockClient{
+				MockLaunchFlexTemplate: func(_ context.Context, _, _ string, p *dataflow.LaunchFlexTemplateParameter) (*dataflow.Job, error) {
+					if p.ContainerSpecGcsPath != flexTemplate {
+						t.Errorf("p.ContainerSpecGcsPath: want %s, got %s", flexTemplate, p.ContainerSpecGcsPath)
+					}
+					return &dataflow.Job{Id: jobID, CurrentState: jobStateQueued}, nil
+				},
+			}},
+			j: job(withFlexTemplate(flexTemplate)),
+			want: job(
+				withFlexTemplate(flexTemplate),
+				withFinalizers(finalizerName),
+				withJobID(jobID),
+				withState(jobStateQueued),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "NoTemplate",
+			csd:  &dataflowJobs{project: project, kube: test.NewMockClient(), client: &fakedataflow.MockClient{}},
+			j:    job(withFlexTemplate("")),
+			want: job(
+				withFlexTemplate(""),
+				withFinalizers(finalizerName),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.New("one of templateGcsPath or flexTemplateGcsPath must be set"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedLaunch",
+			csd: &dataflowJobs{project: project, kube: test.NewMockClient(), client: &fakedataflow.MockClient{
+				MockLaunchTemplate: func(_ context.Context, _, _, _ string, _ *dataflow.LaunchTemplateParameters) (*dataflow.Job, error) {
+					return nil, errorBoom
+				},
+			}},
+			j: job(),
+			want: job(
+				withFinalizers(finalizerName),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrapf(errorBoom, "cannot launch template %s", template))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedPersistFinalizer",
+			csd: &dataflowJobs{
+				project: project,
+				kube: &test.MockClient{
+					MockUpdate: func(_ context.Context, _ runtime.Object, _ ...client.UpdateOption) error { return errorBoom },
+				},
+				client: &fakedataflow.MockClient{
+					MockLaunchTemplate: func(_ context.Context, _, _, _ string, _ *dataflow.LaunchTemplateParameters) (*dataflow.Job, error) {
+						t.Errorf("LaunchTemplate(...): unexpected call")
+						return nil, nil
+					},
+				},
+			},
+			j: job(),
+			want: job(
+				withFinalizers(finalizerName),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot persist finalizer"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "AdoptExistingJob",
+			csd: &dataflowJobs{project: project, kube: test.NewMockClient(), client: &fakedataflow.MockClient{
+				MockLaunchTemplate: func(_ context.Context, _, _, _ string, _ *dataflow.LaunchTemplateParameters) (*dataflow.Job, error) {
+					return nil, errorConflict
+				},
+				MockListJobs: func(_ context.Context, _, _ string) ([]*dataflow.Job, error) {
+					return []*dataflow.Job{
+						{Id: "other", Name: "job-other", CurrentState: jobStateRunning},
+						{Id: jobID, Name: jobNamePrefix + string(uid), CurrentState: jobStateRunning},
+					}, nil
+				},
+			}},
+			j: job(),
+			want: job(
+				withFinalizers(finalizerName),
+				withJobID(jobID),
+				withState(jobStateRunning),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.j)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.j, test.EquateConditions()); diff != "" {
+				t.Errorf("j: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	getJob := func(state string) *fakedataflow.MockClient {
+		return &fakedataflow.MockClient{
+			MockGetJob: func(_ context.Context, _, _, _ string) (*dataflow.Job, error) {
+				return &dataflow.Job{Id: jobID, CurrentState: state, Type: jobTypeStreaming}, nil
+			},
+		}
+	}
+
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		j           *v1alpha1.Job
+		want        *v1alpha1.Job
+		wantRequeue bool
+	}{
+		{
+			name: "JobRunning",
+			csd:  &dataflowJobs{project: project, client: getJob(jobStateRunning)},
+			j:    job(withJobID(jobID)),
+			want: job(
+				withJobID(jobID),
+				withState(jobStateRunning),
+				withType(jobTypeStreaming),
+				withConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "JobStarting",
+			csd:  &dataflowJobs{project: project, client: getJob(jobStateStarting)},
+			j:    job(withJobID(jobID)),
+			want: job(
+				withJobID(jobID),
+				withState(jobStateStarting),
+				withType(jobTypeStreaming),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "JobFailed",
+			csd:  &dataflowJobs{project: project, client: getJob(jobStateFailed)},
+			j:    job(withJobID(jobID)),
+			want: job(
+				withJobID(jobID),
+				withState(jobStateFailed),
+				withType(jobTypeStreaming),
+				withConditions(corev1alpha1.ReconcileError(errors.Errorf("job %s is in terminal state %s", jobID, jobStateFailed))),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedGet",
+			csd: &dataflowJobs{project: project, client: &fakedataflow.MockClient{
+				MockGetJob: func(_ context.Context, _, _, _ string) (*dataflow.Job, error) { return nil, errorBoom },
+			}},
+			j: job(withJobID(jobID)),
+			want: job(
+				withJobID(jobID),
+				withConditions(corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.j)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.j, test.EquateConditions()); diff != "" {
+				t.Errorf("j: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	// stopJob returns a client that expects the job to be stopped by
+	// requesting the supplied state.
+	stopJob := func(state, jobType, want string, err error) *fakedataflow.MockClient {
+		return &fakedataflow.MockClient{
+			MockGetJob: func(_ context.Context, _, _, _ string) (*dataflow.Job, error) {
+				return &dataflow.Job{Id: jobID, CurrentState: state, Type: jobType}, nil
+			},
+			MockUpdateJob: func(_ context.Context, _, _, _ string, j *dataflow.Job) error {
+				if j.RequestedState != want {
+					t.Errorf("j.RequestedState: want %s, got %s", want, j.RequestedState)
+				}
+				return err
+			},
+		}
+	}
+
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		j           *v1alpha1.Job
+		want        *v1alpha1.Job
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimRetain",
+			csd:  &dataflowJobs{project: project, client: &fakedataflow.MockClient{}},
+			j:    job(withJobID(jobID), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimRetain)),
+			want: job(
+				withJobID(jobID),
+				withReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "DrainStreamingJob",
+			csd:  &dataflowJobs{project: project, client: stopJob(jobStateRunning, jobTypeStreaming, jobStateDraining, nil)},
+			j:    job(withJobID(jobID), withDrainOnDelete(), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: job(
+				withJobID(jobID),
+				withDrainOnDelete(),
+				withState(jobStateRunning),
+				withFinalizers(finalizerName),
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "CancelUnrecordedJob",
+			csd: &dataflowJobs{project: project, client: &fakedataflow.MockClient{
+				MockListJobs: func(_ context.Context, _, _ string) ([]*dataflow.Job, error) {
+					return []*dataflow.Job{{Id: jobID, Name: jobNamePrefix + string(uid), CurrentState: jobStatePending}}, nil
+				},
+				MockUpdateJob: func(_ context.Context, _, _, id string, j *dataflow.Job) error {
+					if id != jobID || j.RequestedState != jobStateCancelled {
+						t.Errorf("UpdateJob(...): want job %s requested %s, got job %s requested %s", jobID, jobStateCancelled, id, j.RequestedState)
+					}
+					return nil
+				},
+			}},
+			j: job(withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: job(
+				withJobID(jobID),
+				withState(jobStatePending),
+				withFinalizers(finalizerName),
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "NoJobLaunched",
+			csd: &dataflowJobs{project: project, client: &fakedataflow.MockClient{
+				MockListJobs: func(_ context.Context, _, _ string) ([]*dataflow.Job, error) { return nil, nil },
+			}},
+			j: job(withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: job(
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "CancelBatchJob",
+			csd:  &dataflowJobs{project: project, client: stopJob(jobStateRunning, "JOB_TYPE_BATCH", jobStateCancelled, nil)},
+			j:    job(withJobID(jobID), withDrainOnDelete(), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: job(
+				withJobID(jobID),
+				withDrainOnDelete(),
+				withState(jobStateRunning),
+				withFinalizers(finalizerName),
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "JobDraining",
+			csd: &dataflowJobs{project: project, client: &fakedataflow.MockClient{
+				MockGetJob: func(_ context.Context, _, _, _ string) (*dataflow.Job, error) {
+					return &dataflow.Job{Id: jobID, CurrentState: jobStateDraining}, nil
+				},
+			}},
+			j: job(withJobID(jobID), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: job(
+				withJobID(jobID),
+				withState(jobStateDraining),
+				withFinalizers(finalizerName),
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "JobStopped",
+			csd: &dataflowJobs{project: project, client: &fakedataflow.MockClient{
+				MockGetJob: func(_ context.Context, _, _, _ string) (*dataflow.Job, error) {
+					return &dataflow.Job{Id: jobID, CurrentState: jobStateDrained}, nil
+				},
+			}},
+			j: job(withJobID(jobID), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: job(
+				withJobID(jobID),
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "JobAlreadyGone",
+			csd: &dataflowJobs{project: project, client: &fakedataflow.MockClient{
+				MockGetJob: func(_ context.Context, _, _, _ string) (*dataflow.Job, error) { return nil, errorNotFound },
+			}},
+			j: job(withJobID(jobID), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: job(
+				withJobID(jobID),
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedStop",
+			csd:  &dataflowJobs{project: project, client: stopJob(jobStateRunning, jobTypeStreaming, jobStateCancelled, errorBoom)},
+			j:    job(withJobID(jobID), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: job(
+				withJobID(jobID),
+				withState(jobStateRunning),
+				withFinalizers(finalizerName),
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrapf(errorBoom, "cannot request job state %s", jobStateCancelled))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.j)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.j, test.EquateConditions()); diff != "" {
+				t.Errorf("j: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataplex
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	dataplexv1 "google.golang.org/api/dataplex/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/dataplex/v1alpha1"
+	storagev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/storage/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/dataplex"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	assetControllerName = "assets.dataplex.gcp.crossplane.io"
+	assetFinalizer      = "finalizer." + assetControllerName
+)
+
+var assetLog = logging.Logger.WithName("controller." + assetControllerName)
+
+// An assetCreateSyncDeleter can create, sync, and delete assets in an external
+// store - e.g. the GCP API. Each method returns true if the asset requires
+// further reconciliation.
+type assetCreateSyncDeleter interface {
+	Create(ctx context.Context, a *v1alpha1.Asset) (requeue bool)
+	Sync(ctx context.Context, a *v1alpha1.Asset) (requeue bool)
+	Delete(ctx context.Context, a *v1alpha1.Asset) (requeue bool)
+}
+
+// assets is an assetCreateSyncDeleter using the GCP Dataplex API.
+type assets struct {
+	client  dataplex.Client
+	kube    client.Client
+	project string
+}
+
+// Create attaches a bucket or dataset to the referenced zone, which must have
+// been created first. Dataplex then discovers the metadata of the bucket or
+// dataset and registers it with Data Catalog.
+func (c *assets) Create(ctx context.Context, a *v1alpha1.Asset) bool {
+	a.Status.SetConditions(corev1alpha1.Creating())
+
+	zone, err := resolveZone(ctx, c.kube, a.GetNamespace(), a.Spec.ZoneRef)
+	if err != nil {
+		a.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	desired, err := c.desiredAsset(ctx, a)
+	if err != nil {
+		a.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	id := resourceID(a)
+
+	// Creation is asynchronous. We may have created the asset but failed to
+	// record its name.
+	if err := c.client.CreateAsset(ctx, zone, id, desired); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		a.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create asset")))
+		return true
+	}
+
+	a.Status.AssetName = zone + "/assets/" + id
+	meta.AddFinalizer(a, assetFinalizer)
+	a.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync updates the asset if it differs from its spec, and reports its state
+// and whether Dataplex is able to read the bucket or dataset it attaches.
+func (c *assets) Sync(ctx context.Context, a *v1alpha1.Asset) bool {
+	actual, err := c.client.GetAsset(ctx, a.Status.AssetName)
+	if err != nil {
+		a.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+	a.Status.State = actual.State
+	a.Status.UID = actual.Uid
+	a.Status.ResourceState = ""
+	if actual.ResourceStatus != nil {
+		a.Status.ResourceState = actual.ResourceStatus.State
+	}
+
+	desired := newAsset(a.Spec.AssetParameters, "")
+	if mask := assetUpdateMask(desired, actual); len(mask) > 0 {
+		if err := c.client.PatchAsset(ctx, a.Status.AssetName, desired, strings.Join(mask, ",")); err != nil {
+			a.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot update asset")))
+			return true
+		}
+		a.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	if actual.ResourceStatus != nil && actual.ResourceStatus.State == resourceStateError {
+		a.Status.SetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(errors.Errorf("dataplex cannot access the bucket or dataset of the asset: %s", actual.ResourceStatus.Message)))
+		return true
+	}
+
+	conditions, requeue := stateConditions("asset", actual.State)
+	a.Status.SetConditions(conditions...)
+	return requeue
+}
+
+// Delete detaches the bucket or dataset from its zone. The bucket or dataset
+// itself is not deleted.
+func (c *assets) Delete(ctx context.Context, a *v1alpha1.Asset) bool {
+	a.Status.SetConditions(corev1alpha1.Deleting())
+
+	if a.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.client.DeleteAsset(ctx, a.Status.AssetName); err != nil && !googleapi.IsErrorNotFound(err) {
+			a.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete asset")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(a, assetFinalizer)
+	a.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// desiredAsset returns the asset described by the supplied Asset, resolving
+// the bucket or dataset it attaches.
+func (c *assets) desiredAsset(ctx context.Context, a *v1alpha1.Asset) (*dataplexv1.GoogleCloudDataplexV1Asset, error) {
+	switch {
+	case a.Spec.BucketRef != nil || a.Spec.BucketName != "":
+		name, err := resolveBucket(ctx, c.kube, a.GetNamespace(), a.Spec.BucketName, a.Spec.BucketRef)
+		if err != nil {
+			return nil, err
+		}
+		return newAsset(a.Spec.AssetParameters, fmt.Sprintf("projects/%s/buckets/%s", c.project, name)), nil
+	case a.Spec.DatasetID != "":
+		return newAsset(a.Spec.AssetParameters, fmt.Sprintf("projects/%s/datasets/%s", c.project, a.Spec.DatasetID)), nil
+	default:
+		return nil, errors.New("asset must specify a bucket or a dataset")
+	}
+}
+
+// resolveZone returns the fully qualified name of the zone referenced by the
+// supplied reference, which must be in the supplied namespace. It returns an
+// error if the zone has not yet been created.
+func resolveZone(ctx context.Context, kube client.Client, namespace string, ref corev1.LocalObjectReference) (string, error) {
+	z := &v1alpha1.Zone{}
+	n := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+	if err := kube.Get(ctx, n, z); err != nil {
+		return "", errors.Wrapf(err, "cannot get zone %s", n)
+	}
+	if z.Status.ZoneName == "" {
+		return "", errors.Errorf("zone %s is not yet created", n)
+	}
+	return z.Status.ZoneName, nil
+}
+
+// resolveBucket returns the name of the supplied bucket, or of the bucket
+// referenced by the supplied reference if any. A referenced bucket must be in
+// the supplied namespace.
+func resolveBucket(ctx context.Context, kube client.Client, namespace, name string, ref *corev1.LocalObjectReference) (string, error) {
+	if ref == nil {
+		return name, nil
+	}
+	b := &storagev1alpha1.Bucket{}
+	n := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+	if err := kube.Get(ctx, n, b); err != nil {
+		return "", errors.Wrapf(err, "cannot get bucket %s", n)
+	}
+	return b.GetBucketName(), nil
+}
+
+// newAsset returns the asset described by the supplied parameters, attaching
+// the supplied fully qualified bucket or dataset name. The name may be omitted
+// when the asset is only used to compute an update, because the attached
+// resource of an asset can't be changed.
+func newAsset(p v1alpha1.AssetParameters, resource string) *dataplexv1.GoogleCloudDataplexV1Asset {
+	typ := v1alpha1.AssetTypeStorageBucket
+	if p.DatasetID != "" {
+		typ = v1alpha1.AssetTypeBigQueryDataset
+	}
+	return &dataplexv1.GoogleCloudDataplexV1Asset{
+		DisplayName:   p.DisplayName,
+		Description:   p.Description,
+		Labels:        p.Labels,
+		ResourceSpec:  &dataplexv1.GoogleCloudDataplexV1AssetResourceSpec{Name: resource, Type: typ},
+		DiscoverySpec: &dataplexv1.GoogleCloudDataplexV1AssetDiscoverySpec{Enabled: p.DiscoveryEnabled},
+	}
+}
+
+// assetUpdateMask returns the paths of the fields of the actual asset that
+// differ from the desired asset.
+func assetUpdateMask(desired, actual *dataplexv1.GoogleCloudDataplexV1Asset) []string {
+	mask := []string{}
+	if desired.DisplayName != actual.DisplayName {
+		mask = append(mask, "displayName")
+	}
+	if desired.Description != actual.Description {
+		mask = append(mask, "description")
+	}
+	if !compare.Equal(desired.Labels, actual.Labels) {
+		mask = append(mask, "labels")
+	}
+	if actual.DiscoverySpec == nil || desired.DiscoverySpec.Enabled != actual.DiscoverySpec.Enabled {
+		mask = append(mask, "discoverySpec.enabled")
+	}
+	return mask
+}
+
+// An assetConnecter returns an assetCreateSyncDeleter that can create, sync,
+// and delete assets with an external store - for example the GCP API.
+type assetConnecter interface {
+	Connect(context.Context, *v1alpha1.Asset) (assetCreateSyncDeleter, error)
+}
+
+// assetProviderConnecter is an assetConnecter that returns a
+// assetCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type assetProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns an assetCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// Asset.
+func (c *assetProviderConnecter) Connect(ctx context.Context, a *v1alpha1.Asset) (assetCreateSyncDeleter, error) {
+	client, p, err := c.connect(ctx, a, a.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &assets{client: client, kube: c.kube, project: p.Spec.ProjectID}, nil
+}
+
+// AssetReconciler reconciles Assets read from the Kubernetes API with an
+// external store, typically the GCP API.
+type AssetReconciler struct {
+	assetConnecter
+	kube client.Client
+}
+
+// AssetController is responsible for adding the Asset controller and its
+// corresponding reconciler to the manager with any runtime configuration.
+type AssetController struct {
+	// DefaultProvider is used by assets that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new Asset Controller and adds it to the Manager
+// with default RBAC. The Manager will set fields on the Controller and start
+// it when the Manager is Started.
+func (c *AssetController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &AssetReconciler{
+		assetConnecter: &assetProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: dataplex.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(assetControllerName).
+		For(&v1alpha1.Asset{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listAssets)).
+		Complete(r)
+}
+
+// Reconcile Dataplex assets with the GCP API.
+func (r *AssetReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	assetLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.AssetKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	a := &v1alpha1.Asset{}
+	if err := r.kube.Get(ctx, req.NamespacedName, a); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get asset %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, a)
+	if err != nil {
+		a.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, a), "cannot update asset %s", req.NamespacedName)
+	}
+
+	// The asset has been deleted from the API server. Delete it from GCP.
+	if a.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, a)}, errors.Wrapf(r.kube.Update(ctx, a), "cannot update asset %s", req.NamespacedName)
+	}
+
+	// The asset is unnamed. Assume it has not been created in GCP.
+	if a.Status.AssetName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, a)}, errors.Wrapf(r.kube.Update(ctx, a), "cannot update asset %s", req.NamespacedName)
+	}
+
+	// The asset exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, a)}, errors.Wrapf(r.kube.Update(ctx, a), "cannot update asset %s", req.NamespacedName)
+}
+
+// listAssets is a provider.Lister of assets.
+func listAssets(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.AssetList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,282 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataplex
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	dataplexv1 "google.golang.org/api/dataplex/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/dataplex/v1alpha1"
+	storagev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/storage/v1alpha1"
+	fakedataplex "github.com/crossplaneio/crossplane/pkg/clients/gcp/dataplex/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	zoneRef    = "cool-zone"
+	bucketRef  = "cool-bucket-claim"
+	bucketName = "cool-bucket"
+	datasetID  = "cool_dataset"
+)
+
+var assetName = zoneName + "/assets/" + resourceIDPrefix + string(uid)
+
+// getZoneAndBucket returns a MockGet that gets a zone with the supplied name
+// and a bucket named bucketName.
+func getZoneAndBucket(n string) func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+	return func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+		switch o := obj.(type) {
+		case *v1alpha1.Zone:
+			o.Status.ZoneName = n
+		case *storagev1alpha1.Bucket:
+			o.SetName(bucketRef)
+			o.Spec.NameFormat = bucketName
+		}
+		return nil
+	}
+}
+
+type assetModifier func(*v1alpha1.Asset)
+
+func withAssetConditions(c ...corev1alpha1.Condition) assetModifier {
+	return func(a *v1alpha1.Asset) { a.Status.SetConditions(c...) }
+}
+
+func withAssetFinalizers(f ...string) assetModifier {
+	return func(a *v1alpha1.Asset) { a.ObjectMeta.Finalizers = f }
+}
+
+func withAssetName(n string) assetModifier {
+	return func(a *v1alpha1.Asset) { a.Status.AssetName = n }
+}
+
+func withAssetState(s, resourceState string) assetModifier {
+	return func(a *v1alpha1.Asset) {
+		a.Status.State = s
+		a.Status.ResourceState = resourceState
+	}
+}
+
+func withBucketRef(n string) assetModifier {
+	return func(a *v1alpha1.Asset) { a.Spec.BucketRef = &corev1.LocalObjectReference{Name: n} }
+}
+
+func withDatasetID(id string) assetModifier {
+	return func(a *v1alpha1.Asset) { a.Spec.DatasetID = id }
+}
+
+func asset(am ...assetModifier) *v1alpha1.Asset {
+	a := &v1alpha1.Asset{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.AssetSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			AssetParameters: v1alpha1.AssetParameters{
+				ZoneRef:          corev1.LocalObjectReference{Name: zoneRef},
+				DiscoveryEnabled: true,
+			},
+		},
+	}
+
+	for _, m := range am {
+		m(a)
+	}
+
+	return a
+}
+
+func TestAssetCreate(t *testing.T) {
+	// wantResource returns a MockCreateAsset that expects an asset attaching
+	// the supplied resource.
+	wantResource := func(t *testing.T, typ, name string) func(context.Context, string, string, *dataplexv1.GoogleCloudDataplexV1Asset) error {
+		return func(_ context.Context, p, _ string, a *dataplexv1.GoogleCloudDataplexV1Asset) error {
+			if p != zoneName {
+				t.Errorf("CreateAsset(...): want parent %s, got %s", zoneName, p)
+			}
+			want := &dataplexv1.GoogleCloudDataplexV1AssetResourceSpec{Type: typ, Name: name}
+			if diff := cmp.Diff(want, a.ResourceSpec); diff != "" {
+				t.Errorf("CreateAsset(...): -want resource spec, +got:\n%s", diff)
+			}
+			return nil
+		}
+	}
+
+	cases := []struct {
+		name        string
+		csd         assetCreateSyncDeleter
+		a           *v1alpha1.Asset
+		want        *v1alpha1.Asset
+		wantRequeue bool
+	}{
+		{
+			name: "Bucket",
+			csd: &assets{
+				project: project,
+				kube:    &test.MockClient{MockGet: getZoneAndBucket(zoneName)},
+				client: &fakedataplex.MockClient{
+					MockCreateAsset: wantResource(t, v1alpha1.AssetTypeStorageBucket, "projects/"+project+"/buckets/"+bucketName),
+				},
+			},
+			a: asset(withBucketRef(bucketRef)),
+			want: asset(
+				withBucketRef(bucketRef),
+				withAssetFinalizers(assetFinalizer),
+				withAssetName(assetName),
+				withAssetConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "Dataset",
+			csd: &assets{
+				project: project,
+				kube:    &test.MockClient{MockGet: getZoneAndBucket(zoneName)},
+				client: &fakedataplex.MockClient{
+					MockCreateAsset: wantResource(t, v1alpha1.AssetTypeBigQueryDataset, "projects/"+project+"/datasets/"+datasetID),
+				},
+			},
+			a: asset(withDatasetID(datasetID)),
+			want: asset(
+				withDatasetID(datasetID),
+				withAssetFinalizers(assetFinalizer),
+				withAssetName(assetName),
+				withAssetConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "NoResource",
+			csd: &assets{
+				project: project,
+				kube:    &test.MockClient{MockGet: getZoneAndBucket(zoneName)},
+				client:  &fakedataplex.MockClient{},
+			},
+			a: asset(),
+			want: asset(
+				withAssetConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.New("asset must specify a bucket or a dataset"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "ZoneNotCreated",
+			csd: &assets{
+				project: project,
+				kube:    &test.MockClient{MockGet: getZoneAndBucket("")},
+				client:  &fakedataplex.MockClient{},
+			},
+			a: asset(withDatasetID(datasetID)),
+			want: asset(
+				withDatasetID(datasetID),
+				withAssetConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Errorf("zone %s/%s is not yet created", namespace, zoneRef))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.a)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.a, test.EquateConditions()); diff != "" {
+				t.Errorf("a: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAssetSync(t *testing.T) {
+	// actual returns the asset GCP reports for asset() with the supplied
+	// states.
+	actual := func(state, resourceState, message string) *dataplexv1.GoogleCloudDataplexV1Asset {
+		a := newAsset(asset().Spec.AssetParameters, "projects/"+project+"/buckets/"+bucketName)
+		a.State = state
+		a.ResourceStatus = &dataplexv1.GoogleCloudDataplexV1AssetResourceStatus{State: resourceState, Message: message}
+		return a
+	}
+
+	cases := []struct {
+		name        string
+		csd         assetCreateSyncDeleter
+		a           *v1alpha1.Asset
+		want        *v1alpha1.Asset
+		wantRequeue bool
+	}{
+		{
+			name: "Ready",
+			csd: &assets{client: &fakedataplex.MockClient{
+				MockGetAsset: func(_ context.Context, _ string) (*dataplexv1.GoogleCloudDataplexV1Asset, error) {
+					return actual(stateActive, "READY", ""), nil
+				},
+			}},
+			a: asset(withAssetName(assetName)),
+			want: asset(
+				withAssetName(assetName),
+				withAssetState(stateActive, "READY"),
+				withAssetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ResourceError",
+			csd: &assets{client: &fakedataplex.MockClient{
+				MockGetAsset: func(_ context.Context, _ string) (*dataplexv1.GoogleCloudDataplexV1Asset, error) {
+					return actual(stateActive, resourceStateError, "permission denied"), nil
+				},
+			}},
+			a: asset(withAssetName(assetName)),
+			want: asset(
+				withAssetName(assetName),
+				withAssetState(stateActive, resourceStateError),
+				withAssetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(errors.New("dataplex cannot access the bucket or dataset of the asset: permission denied"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.a)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.a, test.EquateConditions()); diff != "" {
+				t.Errorf("a: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dataplex contains controllers that register the data managed by
+// Crossplane with Dataplex. Lakes group zones, and zones group the assets -
+// Cloud Storage buckets and BigQuery datasets - whose metadata Dataplex
+// discovers and publishes to Data Catalog.
+package dataplex
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	dataplexv1 "google.golang.org/api/dataplex/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/dataplex"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+)
+
+const (
+	reconcileTimeout = 1 * time.Minute
+
+	// resourceIDPrefix is prepended to the UID of a managed resource to form
+	// the ID of its Dataplex resource. IDs must begin with a letter.
+	resourceIDPrefix = "crossplane-"
+)
+
+// Lake, zone, and asset states. See
+// https://cloud.google.com/dataplex/docs/reference/rest/v1/State
+const (
+	stateActive         = "ACTIVE"
+	stateActionRequired = "ACTION_REQUIRED"
+	stateDeleting       = "DELETING"
+)
+
+// resourceStateError is the state of an asset whose bucket or dataset can't
+// be read by Dataplex.
+const resourceStateError = "ERROR"
+
+// providerConnecter returns Dataplex clients authenticated using credentials
+// read from a Crossplane Provider resource.
+type providerConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (dataplex.Client, error)
+}
+
+// connect returns a Dataplex client authenticated using credentials read from
+// the Provider referenced by the supplied managed resource, and that Provider.
+func (c *providerConnecter) connect(ctx context.Context, mg metav1.Object, ref *corev1.ObjectReference) (dataplex.Client, *gcpv1alpha1.Provider, error) {
+	p, err := c.providers.Get(ctx, c.kube, mg, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceDataplex, dataplexv1.CloudPlatformScope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return client, p, errors.Wrap(err, "cannot create new dataplex client")
+}
+
+// resourceID returns the ID of the Dataplex resource of the supplied managed
+// resource.
+func resourceID(mg metav1.Object) string {
+	return resourceIDPrefix + string(mg.GetUID())
+}
+
+// parentName returns the fully qualified name of the supplied location within
+// the supplied project, e.g. projects/p/locations/us-central1.
+func parentName(project, location string) string {
+	return fmt.Sprintf("projects/%s/locations/%s", project, location)
+}
+
+// stateConditions returns the conditions of a lake, zone, or asset in the
+// supplied state, and whether it requires further reconciliation.
+func stateConditions(kind, state string) ([]corev1alpha1.Condition, bool) {
+	switch state {
+	case stateActive:
+		return []corev1alpha1.Condition{corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()}, false
+	case stateActionRequired:
+		// Typically Dataplex lacks permission to read the underlying
+		// resource. It recovers once permission is granted.
+		return []corev1alpha1.Condition{corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(errors.Errorf("%s requires action; check that the dataplex service agent can access it", kind))}, true
+	case stateDeleting:
+		return []corev1alpha1.Condition{corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()}, true
+	default:
+		return []corev1alpha1.Condition{corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()}, true
+	}
+}
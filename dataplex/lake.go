@@ -0,0 +1,259 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataplex
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	dataplexv1 "google.golang.org/api/dataplex/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/dataplex/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/dataplex"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	lakeControllerName = "lakes.dataplex.gcp.crossplane.io"
+	lakeFinalizer      = "finalizer." + lakeControllerName
+)
+
+var lakeLog = logging.Logger.WithName("controller." + lakeControllerName)
+
+// A lakeCreateSyncDeleter can create, sync, and delete lakes in an external
+// store - e.g. the GCP API. Each method returns true if the lake requires
+// further reconciliation.
+type lakeCreateSyncDeleter interface {
+	Create(ctx context.Context, l *v1alpha1.Lake) (requeue bool)
+	Sync(ctx context.Context, l *v1alpha1.Lake) (requeue bool)
+	Delete(ctx context.Context, l *v1alpha1.Lake) (requeue bool)
+}
+
+// lakes is a lakeCreateSyncDeleter using the GCP Dataplex API.
+type lakes struct {
+	client  dataplex.Client
+	project string
+}
+
+// Create creates a lake. Dataplex creates a Data Catalog entry group and a
+// Dataproc Metastore attachment point for each lake.
+func (c *lakes) Create(ctx context.Context, l *v1alpha1.Lake) bool {
+	l.Status.SetConditions(corev1alpha1.Creating())
+
+	parent := parentName(c.project, l.Spec.Location)
+	id := resourceID(l)
+
+	// Creation is asynchronous. We may have created the lake but failed to
+	// record its name.
+	if err := c.client.CreateLake(ctx, parent, id, newLake(l.Spec.LakeParameters)); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		l.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create lake")))
+		return true
+	}
+
+	l.Status.LakeName = parent + "/lakes/" + id
+	meta.AddFinalizer(l, lakeFinalizer)
+	l.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync updates the lake if it differs from its spec, and reports its state.
+func (c *lakes) Sync(ctx context.Context, l *v1alpha1.Lake) bool {
+	actual, err := c.client.GetLake(ctx, l.Status.LakeName)
+	if err != nil {
+		l.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+	l.Status.State = actual.State
+	l.Status.UID = actual.Uid
+
+	desired := newLake(l.Spec.LakeParameters)
+	if mask := lakeUpdateMask(desired, actual); len(mask) > 0 {
+		if err := c.client.PatchLake(ctx, l.Status.LakeName, desired, strings.Join(mask, ",")); err != nil {
+			l.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot update lake")))
+			return true
+		}
+		l.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	conditions, requeue := stateConditions("lake", actual.State)
+	l.Status.SetConditions(conditions...)
+	return requeue
+}
+
+// Delete deletes the lake. Dataplex refuses to delete a lake that contains
+// zones, so a lake is deleted only after its zones.
+func (c *lakes) Delete(ctx context.Context, l *v1alpha1.Lake) bool {
+	l.Status.SetConditions(corev1alpha1.Deleting())
+
+	if l.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.client.DeleteLake(ctx, l.Status.LakeName); err != nil && !googleapi.IsErrorNotFound(err) {
+			l.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete lake")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(l, lakeFinalizer)
+	l.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// newLake returns the lake described by the supplied parameters.
+func newLake(p v1alpha1.LakeParameters) *dataplexv1.GoogleCloudDataplexV1Lake {
+	return &dataplexv1.GoogleCloudDataplexV1Lake{
+		DisplayName: p.DisplayName,
+		Description: p.Description,
+		Labels:      p.Labels,
+	}
+}
+
+// lakeUpdateMask returns the paths of the fields of the actual lake that
+// differ from the desired lake.
+func lakeUpdateMask(desired, actual *dataplexv1.GoogleCloudDataplexV1Lake) []string {
+	mask := []string{}
+	if desired.DisplayName != actual.DisplayName {
+		mask = append(mask, "displayName")
+	}
+	if desired.Description != actual.Description {
+		mask = append(mask, "description")
+	}
+	if !compare.Equal(desired.Labels, actual.Labels) {
+		mask = append(mask, "labels")
+	}
+	return mask
+}
+
+// A lakeConnecter returns a lakeCreateSyncDeleter that can create, sync, and
+// delete lakes with an external store - for example the GCP API.
+type lakeConnecter interface {
+	Connect(context.Context, *v1alpha1.Lake) (lakeCreateSyncDeleter, error)
+}
+
+// lakeProviderConnecter is a lakeConnecter that returns a
+// lakeCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type lakeProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns a lakeCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// Lake.
+func (c *lakeProviderConnecter) Connect(ctx context.Context, l *v1alpha1.Lake) (lakeCreateSyncDeleter, error) {
+	client, p, err := c.connect(ctx, l, l.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &lakes{client: client, project: p.Spec.ProjectID}, nil
+}
+
+// LakeReconciler reconciles Lakes read from the Kubernetes API with an
+// external store, typically the GCP API.
+type LakeReconciler struct {
+	lakeConnecter
+	kube client.Client
+}
+
+// LakeController is responsible for adding the Lake controller and its
+// corresponding reconciler to the manager with any runtime configuration.
+type LakeController struct {
+	// DefaultProvider is used by lakes that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new Lake Controller and adds it to the Manager
+// with default RBAC. The Manager will set fields on the Controller and start
+// it when the Manager is Started.
+func (c *LakeController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &LakeReconciler{
+		lakeConnecter: &lakeProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: dataplex.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(lakeControllerName).
+		For(&v1alpha1.Lake{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listLakes)).
+		Complete(r)
+}
+
+// Reconcile Dataplex lakes with the GCP API.
+func (r *LakeReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	lakeLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.LakeKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	l := &v1alpha1.Lake{}
+	if err := r.kube.Get(ctx, req.NamespacedName, l); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get lake %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, l)
+	if err != nil {
+		l.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, l), "cannot update lake %s", req.NamespacedName)
+	}
+
+	// The lake has been deleted from the API server. Delete it from GCP.
+	if l.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, l)}, errors.Wrapf(r.kube.Update(ctx, l), "cannot update lake %s", req.NamespacedName)
+	}
+
+	// The lake is unnamed. Assume it has not been created in GCP.
+	if l.Status.LakeName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, l)}, errors.Wrapf(r.kube.Update(ctx, l), "cannot update lake %s", req.NamespacedName)
+	}
+
+	// The lake exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, l)}, errors.Wrapf(r.kube.Update(ctx, l), "cannot update lake %s", req.NamespacedName)
+}
+
+// listLakes is a provider.Lister of lakes.
+func listLakes(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.LakeList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
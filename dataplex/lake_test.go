@@ -0,0 +1,339 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataplex
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	dataplexv1 "google.golang.org/api/dataplex/v1"
+	"google.golang.org/api/googleapi"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/dataplex/v1alpha1"
+	fakedataplex "github.com/crossplaneio/crossplane/pkg/clients/gcp/dataplex/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace    = "cool-namespace"
+	name         = "cool-resource"
+	uid          = types.UID("definitely-a-uuid")
+	project      = "cool-project"
+	providerName = "cool-gcp"
+	location     = "us-central1"
+)
+
+var (
+	ctx           = context.Background()
+	errorBoom     = errors.New("boom")
+	errorNotFound = &googleapi.Error{Code: http.StatusNotFound}
+	parent        = parentName(project, location)
+	lakeName      = parent + "/lakes/" + resourceIDPrefix + string(uid)
+)
+
+// Test that our Reconciler implementations satisfy the Reconciler interface.
+var (
+	_ reconcile.Reconciler = &LakeReconciler{}
+	_ reconcile.Reconciler = &ZoneReconciler{}
+	_ reconcile.Reconciler = &AssetReconciler{}
+)
+
+type lakeModifier func(*v1alpha1.Lake)
+
+func withLakeConditions(c ...corev1alpha1.Condition) lakeModifier {
+	return func(l *v1alpha1.Lake) { l.Status.SetConditions(c...) }
+}
+
+func withLakeFinalizers(f ...string) lakeModifier {
+	return func(l *v1alpha1.Lake) { l.ObjectMeta.Finalizers = f }
+}
+
+func withLakeReclaimPolicy(r corev1alpha1.ReclaimPolicy) lakeModifier {
+	return func(l *v1alpha1.Lake) { l.Spec.ReclaimPolicy = r }
+}
+
+func withLakeName(n string) lakeModifier {
+	return func(l *v1alpha1.Lake) { l.Status.LakeName = n }
+}
+
+func withLakeState(s string) lakeModifier {
+	return func(l *v1alpha1.Lake) { l.Status.State = s }
+}
+
+func withLakeDescription(d string) lakeModifier {
+	return func(l *v1alpha1.Lake) { l.Spec.Description = d }
+}
+
+func lake(lm ...lakeModifier) *v1alpha1.Lake {
+	l := &v1alpha1.Lake{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.LakeSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			LakeParameters: v1alpha1.LakeParameters{
+				Location:    location,
+				DisplayName: "Cool Lake",
+				Labels:      map[string]string{"team": "cool"},
+			},
+		},
+	}
+
+	for _, m := range lm {
+		m(l)
+	}
+
+	return l
+}
+
+func TestLakeCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         lakeCreateSyncDeleter
+		l           *v1alpha1.Lake
+		want        *v1alpha1.Lake
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &lakes{
+				project: project,
+				client: &fakedataplex.MockClient{
+					MockCreateLake: func(_ context.Context, p, id string, _ *dataplexv1.GoogleCloudDataplexV1Lake) error {
+						if p != parent {
+							t.Errorf("CreateLake(...): want parent %s, got %s", parent, p)
+						}
+						if want := resourceIDPrefix + string(uid); id != want {
+							t.Errorf("CreateLake(...): want id %s, got %s", want, id)
+						}
+						return nil
+					},
+				},
+			},
+			l: lake(),
+			want: lake(
+				withLakeFinalizers(lakeFinalizer),
+				withLakeName(lakeName),
+				withLakeConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &lakes{
+				project: project,
+				client: &fakedataplex.MockClient{
+					MockCreateLake: func(_ context.Context, _, _ string, _ *dataplexv1.GoogleCloudDataplexV1Lake) error { return errorBoom },
+				},
+			},
+			l: lake(),
+			want: lake(
+				withLakeConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create lake"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.l)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.l, test.EquateConditions()); diff != "" {
+				t.Errorf("l: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLakeSync(t *testing.T) {
+	// upToDate returns the lake GCP reports for lake() in the supplied state.
+	upToDate := func(state string) *dataplexv1.GoogleCloudDataplexV1Lake {
+		l := newLake(lake().Spec.LakeParameters)
+		l.State = state
+		return l
+	}
+
+	cases := []struct {
+		name        string
+		csd         lakeCreateSyncDeleter
+		l           *v1alpha1.Lake
+		want        *v1alpha1.Lake
+		wantRequeue bool
+	}{
+		{
+			name: "Active",
+			csd: &lakes{client: &fakedataplex.MockClient{
+				MockGetLake: func(_ context.Context, _ string) (*dataplexv1.GoogleCloudDataplexV1Lake, error) {
+					return upToDate(stateActive), nil
+				},
+			}},
+			l: lake(withLakeName(lakeName)),
+			want: lake(
+				withLakeName(lakeName),
+				withLakeState(stateActive),
+				withLakeConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "Creating",
+			csd: &lakes{client: &fakedataplex.MockClient{
+				MockGetLake: func(_ context.Context, _ string) (*dataplexv1.GoogleCloudDataplexV1Lake, error) {
+					return upToDate("CREATING"), nil
+				},
+			}},
+			l: lake(withLakeName(lakeName)),
+			want: lake(
+				withLakeName(lakeName),
+				withLakeState("CREATING"),
+				withLakeConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "NeedsUpdate",
+			csd: &lakes{client: &fakedataplex.MockClient{
+				MockGetLake: func(_ context.Context, _ string) (*dataplexv1.GoogleCloudDataplexV1Lake, error) {
+					return upToDate(stateActive), nil
+				},
+				MockPatchLake: func(_ context.Context, _ string, _ *dataplexv1.GoogleCloudDataplexV1Lake, mask string) error {
+					if mask != "description" {
+						t.Errorf("PatchLake(...): want mask description, got %s", mask)
+					}
+					return nil
+				},
+			}},
+			l: lake(withLakeName(lakeName), withLakeDescription("new")),
+			want: lake(
+				withLakeName(lakeName),
+				withLakeDescription("new"),
+				withLakeState(stateActive),
+				withLakeConditions(corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGet",
+			csd: &lakes{client: &fakedataplex.MockClient{
+				MockGetLake: func(_ context.Context, _ string) (*dataplexv1.GoogleCloudDataplexV1Lake, error) {
+					return nil, errorBoom
+				},
+			}},
+			l: lake(withLakeName(lakeName)),
+			want: lake(
+				withLakeName(lakeName),
+				withLakeConditions(corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.l)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.l, test.EquateConditions()); diff != "" {
+				t.Errorf("l: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLakeDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         lakeCreateSyncDeleter
+		l           *v1alpha1.Lake
+		want        *v1alpha1.Lake
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimDelete",
+			csd: &lakes{client: &fakedataplex.MockClient{
+				MockDeleteLake: func(_ context.Context, n string) error {
+					if n != lakeName {
+						t.Errorf("DeleteLake(...): want %s, got %s", lakeName, n)
+					}
+					return nil
+				},
+			}},
+			l:           lake(withLakeName(lakeName), withLakeFinalizers(lakeFinalizer), withLakeReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want:        lake(withLakeName(lakeName), withLakeReclaimPolicy(corev1alpha1.ReclaimDelete), withLakeConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess())),
+			wantRequeue: false,
+		},
+		{
+			name:        "ReclaimRetain",
+			csd:         &lakes{client: &fakedataplex.MockClient{}},
+			l:           lake(withLakeName(lakeName), withLakeFinalizers(lakeFinalizer), withLakeReclaimPolicy(corev1alpha1.ReclaimRetain)),
+			want:        lake(withLakeName(lakeName), withLakeReclaimPolicy(corev1alpha1.ReclaimRetain), withLakeConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess())),
+			wantRequeue: false,
+		},
+		{
+			name: "NotFound",
+			csd: &lakes{client: &fakedataplex.MockClient{
+				MockDeleteLake: func(_ context.Context, _ string) error { return errorNotFound },
+			}},
+			l:           lake(withLakeName(lakeName), withLakeFinalizers(lakeFinalizer), withLakeReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want:        lake(withLakeName(lakeName), withLakeReclaimPolicy(corev1alpha1.ReclaimDelete), withLakeConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess())),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedDelete",
+			csd: &lakes{client: &fakedataplex.MockClient{
+				MockDeleteLake: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			l:           lake(withLakeName(lakeName), withLakeFinalizers(lakeFinalizer), withLakeReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want:        lake(withLakeName(lakeName), withLakeFinalizers(lakeFinalizer), withLakeReclaimPolicy(corev1alpha1.ReclaimDelete), withLakeConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot delete lake")))),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.l)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.l, test.EquateConditions()); diff != "" {
+				t.Errorf("l: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataplex
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	dataplexv1 "google.golang.org/api/dataplex/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/dataplex/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/dataplex"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	zoneControllerName = "zones.dataplex.gcp.crossplane.io"
+	zoneFinalizer      = "finalizer." + zoneControllerName
+)
+
+var zoneLog = logging.Logger.WithName("controller." + zoneControllerName)
+
+// A zoneCreateSyncDeleter can create, sync, and delete zones in an external
+// store - e.g. the GCP API. Each method returns true if the zone requires
+// further reconciliation.
+type zoneCreateSyncDeleter interface {
+	Create(ctx context.Context, z *v1alpha1.Zone) (requeue bool)
+	Sync(ctx context.Context, z *v1alpha1.Zone) (requeue bool)
+	Delete(ctx context.Context, z *v1alpha1.Zone) (requeue bool)
+}
+
+// zones is a zoneCreateSyncDeleter using the GCP Dataplex API.
+type zones struct {
+	client dataplex.Client
+	kube   client.Client
+}
+
+// Create creates a zone within the referenced lake, which must have been
+// created first.
+func (c *zones) Create(ctx context.Context, z *v1alpha1.Zone) bool {
+	z.Status.SetConditions(corev1alpha1.Creating())
+
+	lake, err := resolveLake(ctx, c.kube, z.GetNamespace(), z.Spec.LakeRef)
+	if err != nil {
+		z.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	id := resourceID(z)
+
+	// Creation is asynchronous. We may have created the zone but failed to
+	// record its name.
+	if err := c.client.CreateZone(ctx, lake, id, newZone(z.Spec.ZoneParameters)); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		z.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create zone")))
+		return true
+	}
+
+	z.Status.ZoneName = lake + "/zones/" + id
+	meta.AddFinalizer(z, zoneFinalizer)
+	z.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync updates the zone if it differs from its spec, and reports its state.
+func (c *zones) Sync(ctx context.Context, z *v1alpha1.Zone) bool {
+	actual, err := c.client.GetZone(ctx, z.Status.ZoneName)
+	if err != nil {
+		z.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+	z.Status.State = actual.State
+	z.Status.UID = actual.Uid
+
+	desired := newZone(z.Spec.ZoneParameters)
+	if mask := zoneUpdateMask(desired, actual); len(mask) > 0 {
+		if err := c.client.PatchZone(ctx, z.Status.ZoneName, desired, strings.Join(mask, ",")); err != nil {
+			z.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot update zone")))
+			return true
+		}
+		z.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	conditions, requeue := stateConditions("zone", actual.State)
+	z.Status.SetConditions(conditions...)
+	return requeue
+}
+
+// Delete deletes the zone. Dataplex refuses to delete a zone that contains
+// assets, so a zone is deleted only after its assets.
+func (c *zones) Delete(ctx context.Context, z *v1alpha1.Zone) bool {
+	z.Status.SetConditions(corev1alpha1.Deleting())
+
+	if z.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.client.DeleteZone(ctx, z.Status.ZoneName); err != nil && !googleapi.IsErrorNotFound(err) {
+			z.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete zone")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(z, zoneFinalizer)
+	z.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// resolveLake returns the fully qualified name of the lake referenced by the
+// supplied reference, which must be in the supplied namespace. It returns an
+// error if the lake has not yet been created.
+func resolveLake(ctx context.Context, kube client.Client, namespace string, ref corev1.LocalObjectReference) (string, error) {
+	l := &v1alpha1.Lake{}
+	n := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+	if err := kube.Get(ctx, n, l); err != nil {
+		return "", errors.Wrapf(err, "cannot get lake %s", n)
+	}
+	if l.Status.LakeName == "" {
+		return "", errors.Errorf("lake %s is not yet created", n)
+	}
+	return l.Status.LakeName, nil
+}
+
+// newZone returns the zone described by the supplied parameters. Zones are
+// single region unless otherwise specified.
+func newZone(p v1alpha1.ZoneParameters) *dataplexv1.GoogleCloudDataplexV1Zone {
+	locationType := p.LocationType
+	if locationType == "" {
+		locationType = v1alpha1.LocationTypeSingleRegion
+	}
+	return &dataplexv1.GoogleCloudDataplexV1Zone{
+		DisplayName:   p.DisplayName,
+		Description:   p.Description,
+		Labels:        p.Labels,
+		Type:          p.Type,
+		ResourceSpec:  &dataplexv1.GoogleCloudDataplexV1ZoneResourceSpec{LocationType: locationType},
+		DiscoverySpec: &dataplexv1.GoogleCloudDataplexV1ZoneDiscoverySpec{Enabled: p.DiscoveryEnabled},
+	}
+}
+
+// zoneUpdateMask returns the paths of the fields of the actual zone that
+// differ from the desired zone. The type and location type of a zone can't be
+// changed.
+func zoneUpdateMask(desired, actual *dataplexv1.GoogleCloudDataplexV1Zone) []string {
+	mask := []string{}
+	if desired.DisplayName != actual.DisplayName {
+		mask = append(mask, "displayName")
+	}
+	if desired.Description != actual.Description {
+		mask = append(mask, "description")
+	}
+	if !compare.Equal(desired.Labels, actual.Labels) {
+		mask = append(mask, "labels")
+	}
+	if actual.DiscoverySpec == nil || desired.DiscoverySpec.Enabled != actual.DiscoverySpec.Enabled {
+		mask = append(mask, "discoverySpec.enabled")
+	}
+	return mask
+}
+
+// A zoneConnecter returns a zoneCreateSyncDeleter that can create, sync, and
+// delete zones with an external store - for example the GCP API.
+type zoneConnecter interface {
+	Connect(context.Context, *v1alpha1.Zone) (zoneCreateSyncDeleter, error)
+}
+
+// zoneProviderConnecter is a zoneConnecter that returns a
+// zoneCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type zoneProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns a zoneCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// Zone.
+func (c *zoneProviderConnecter) Connect(ctx context.Context, z *v1alpha1.Zone) (zoneCreateSyncDeleter, error) {
+	client, _, err := c.connect(ctx, z, z.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &zones{client: client, kube: c.kube}, nil
+}
+
+// ZoneReconciler reconciles Zones read from the Kubernetes API with an
+// external store, typically the GCP API.
+type ZoneReconciler struct {
+	zoneConnecter
+	kube client.Client
+}
+
+// ZoneController is responsible for adding the Zone controller and its
+// corresponding reconciler to the manager with any runtime configuration.
+type ZoneController struct {
+	// DefaultProvider is used by zones that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new Zone Controller and adds it to the Manager
+// with default RBAC. The Manager will set fields on the Controller and start
+// it when the Manager is Started.
+func (c *ZoneController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &ZoneReconciler{
+		zoneConnecter: &zoneProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: dataplex.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(zoneControllerName).
+		For(&v1alpha1.Zone{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listZones)).
+		Complete(r)
+}
+
+// Reconcile Dataplex zones with the GCP API.
+func (r *ZoneReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	zoneLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.ZoneKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	z := &v1alpha1.Zone{}
+	if err := r.kube.Get(ctx, req.NamespacedName, z); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get zone %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, z)
+	if err != nil {
+		z.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, z), "cannot update zone %s", req.NamespacedName)
+	}
+
+	// The zone has been deleted from the API server. Delete it from GCP.
+	if z.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, z)}, errors.Wrapf(r.kube.Update(ctx, z), "cannot update zone %s", req.NamespacedName)
+	}
+
+	// The zone is unnamed. Assume it has not been created in GCP.
+	if z.Status.ZoneName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, z)}, errors.Wrapf(r.kube.Update(ctx, z), "cannot update zone %s", req.NamespacedName)
+	}
+
+	// The zone exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, z)}, errors.Wrapf(r.kube.Update(ctx, z), "cannot update zone %s", req.NamespacedName)
+}
+
+// listZones is a provider.Lister of zones.
+func listZones(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.ZoneList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
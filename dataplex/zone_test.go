@@ -0,0 +1,236 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataplex
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	dataplexv1 "google.golang.org/api/dataplex/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/dataplex/v1alpha1"
+	fakedataplex "github.com/crossplaneio/crossplane/pkg/clients/gcp/dataplex/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const lakeRef = "cool-lake"
+
+var zoneName = lakeName + "/zones/" + resourceIDPrefix + string(uid)
+
+// getLake returns a MockGet that gets a lake with the supplied name.
+func getLake(n string) func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+	return func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+		obj.(*v1alpha1.Lake).Status.LakeName = n
+		return nil
+	}
+}
+
+type zoneModifier func(*v1alpha1.Zone)
+
+func withZoneConditions(c ...corev1alpha1.Condition) zoneModifier {
+	return func(z *v1alpha1.Zone) { z.Status.SetConditions(c...) }
+}
+
+func withZoneFinalizers(f ...string) zoneModifier {
+	return func(z *v1alpha1.Zone) { z.ObjectMeta.Finalizers = f }
+}
+
+func withZoneName(n string) zoneModifier {
+	return func(z *v1alpha1.Zone) { z.Status.ZoneName = n }
+}
+
+func withZoneState(s string) zoneModifier {
+	return func(z *v1alpha1.Zone) { z.Status.State = s }
+}
+
+func withZoneDiscovery(enabled bool) zoneModifier {
+	return func(z *v1alpha1.Zone) { z.Spec.DiscoveryEnabled = enabled }
+}
+
+func zone(zm ...zoneModifier) *v1alpha1.Zone {
+	z := &v1alpha1.Zone{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.ZoneSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			ZoneParameters: v1alpha1.ZoneParameters{
+				LakeRef: corev1.LocalObjectReference{Name: lakeRef},
+				Type:    v1alpha1.ZoneTypeRaw,
+			},
+		},
+	}
+
+	for _, m := range zm {
+		m(z)
+	}
+
+	return z
+}
+
+func TestZoneCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         zoneCreateSyncDeleter
+		z           *v1alpha1.Zone
+		want        *v1alpha1.Zone
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &zones{
+				kube: &test.MockClient{MockGet: getLake(lakeName)},
+				client: &fakedataplex.MockClient{
+					MockCreateZone: func(_ context.Context, p, _ string, z *dataplexv1.GoogleCloudDataplexV1Zone) error {
+						if p != lakeName {
+							t.Errorf("CreateZone(...): want parent %s, got %s", lakeName, p)
+						}
+						if z.ResourceSpec.LocationType != v1alpha1.LocationTypeSingleRegion {
+							t.Errorf("CreateZone(...): want location type %s, got %s", v1alpha1.LocationTypeSingleRegion, z.ResourceSpec.LocationType)
+						}
+						return nil
+					},
+				},
+			},
+			z: zone(),
+			want: zone(
+				withZoneFinalizers(zoneFinalizer),
+				withZoneName(zoneName),
+				withZoneConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "LakeNotCreated",
+			csd: &zones{
+				kube:   &test.MockClient{MockGet: getLake("")},
+				client: &fakedataplex.MockClient{},
+			},
+			z: zone(),
+			want: zone(
+				withZoneConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Errorf("lake %s/%s is not yet created", namespace, lakeRef))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &zones{
+				kube: &test.MockClient{MockGet: getLake(lakeName)},
+				client: &fakedataplex.MockClient{
+					MockCreateZone: func(_ context.Context, _, _ string, _ *dataplexv1.GoogleCloudDataplexV1Zone) error { return errorBoom },
+				},
+			},
+			z: zone(),
+			want: zone(
+				withZoneConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create zone"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.z)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.z, test.EquateConditions()); diff != "" {
+				t.Errorf("z: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestZoneSync(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         zoneCreateSyncDeleter
+		z           *v1alpha1.Zone
+		want        *v1alpha1.Zone
+		wantRequeue bool
+	}{
+		{
+			name: "ActionRequired",
+			csd: &zones{client: &fakedataplex.MockClient{
+				MockGetZone: func(_ context.Context, _ string) (*dataplexv1.GoogleCloudDataplexV1Zone, error) {
+					z := newZone(zone().Spec.ZoneParameters)
+					z.State = stateActionRequired
+					return z, nil
+				},
+			}},
+			z: zone(withZoneName(zoneName)),
+			want: zone(
+				withZoneName(zoneName),
+				withZoneState(stateActionRequired),
+				withZoneConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(errors.New("zone requires action; check that the dataplex service agent can access it"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "EnableDiscovery",
+			csd: &zones{client: &fakedataplex.MockClient{
+				MockGetZone: func(_ context.Context, _ string) (*dataplexv1.GoogleCloudDataplexV1Zone, error) {
+					z := newZone(zone().Spec.ZoneParameters)
+					z.State = stateActive
+					return z, nil
+				},
+				MockPatchZone: func(_ context.Context, _ string, _ *dataplexv1.GoogleCloudDataplexV1Zone, mask string) error {
+					if mask != "discoverySpec.enabled" {
+						t.Errorf("PatchZone(...): want mask discoverySpec.enabled, got %s", mask)
+					}
+					return nil
+				},
+			}},
+			z: zone(withZoneName(zoneName), withZoneDiscovery(true)),
+			want: zone(
+				withZoneName(zoneName),
+				withZoneDiscovery(true),
+				withZoneState(stateActive),
+				withZoneConditions(corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.z)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.z, test.EquateConditions()); diff != "" {
+				t.Errorf("z: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package deadline detects managed resources that have been stuck creating
+// in GCP for longer than they should.
+package deadline
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+)
+
+const (
+	// AnnotationCreationDeadline may be set on a managed resource to override
+	// DefaultCreationDeadline. Its value must be parseable by
+	// time.ParseDuration, e.g. "45m".
+	AnnotationCreationDeadline = "gcp.crossplane.io/creation-deadline"
+
+	// AnnotationWaitingSince records the not ready state a managed resource
+	// was last observed in, and when it was first observed in that state. Its
+	// value is the state and an RFC 3339 time separated by a space.
+	AnnotationWaitingSince = "gcp.crossplane.io/waiting-since"
+
+	// DefaultCreationDeadline is how long a managed resource may be creating,
+	// or otherwise not ready, before it is considered stalled.
+	DefaultCreationDeadline = 30 * time.Minute
+
+	// TypeStalled resources have been creating for longer than their creation
+	// deadline.
+	TypeStalled corev1alpha1.ConditionType = "Stalled"
+
+	// ReasonCreationDeadlineExceeded is the reason for a Stalled condition.
+	ReasonCreationDeadlineExceeded corev1alpha1.ConditionReason = "CreationDeadlineExceeded"
+
+	// ReasonRecovered is the reason for a Stalled condition that is no longer
+	// true because the resource became ready.
+	ReasonRecovered corev1alpha1.ConditionReason = "Recovered"
+
+	// EventReasonStalled is the reason of the warning event emitted when a
+	// resource is first observed to be stalled.
+	EventReasonStalled = "CreationStalled"
+)
+
+// CreationDeadline returns the creation deadline of the supplied object. The
+// default deadline is returned if the object's annotation is unset or invalid.
+func CreationDeadline(o metav1.Object) time.Duration {
+	v, ok := o.GetAnnotations()[AnnotationCreationDeadline]
+	if !ok {
+		return DefaultCreationDeadline
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return DefaultCreationDeadline
+	}
+	return d
+}
+
+// Observe records that the supplied object was observed in the supplied not
+// ready state at the supplied time, and returns when it was first observed in
+// that state. It returns true if the object's annotations were changed, and
+// must then be persisted.
+func Observe(o metav1.Object, state string, now time.Time) (time.Time, bool) {
+	a := o.GetAnnotations()
+	if v, ok := a[AnnotationWaitingSince]; ok {
+		parts := strings.SplitN(v, " ", 2)
+		if len(parts) == 2 && parts[0] == state {
+			if since, err := time.Parse(time.RFC3339, parts[1]); err == nil {
+				return since, false
+			}
+		}
+	}
+	if a == nil {
+		a = map[string]string{}
+	}
+	a[AnnotationWaitingSince] = state + " " + now.UTC().Format(time.RFC3339)
+	o.SetAnnotations(a)
+	return now, true
+}
+
+// Forget removes the not ready state recorded by Observe from the supplied
+// object. It returns true if the object's annotations were changed.
+func Forget(o metav1.Object) bool {
+	a := o.GetAnnotations()
+	if _, ok := a[AnnotationWaitingSince]; !ok {
+		return false
+	}
+	delete(a, AnnotationWaitingSince)
+	o.SetAnnotations(a)
+	return true
+}
+
+// ExceededSince returns true if the supplied object has been waiting since
+// the supplied time for longer than its creation deadline allows.
+func ExceededSince(o metav1.Object, since, now time.Time) bool {
+	return now.Sub(since) > CreationDeadline(o)
+}
+
+// Stalled returns a condition indicating that a managed resource has been
+// creating for longer than its deadline. The supplied message should describe
+// the most recent error reported by GCP, if any.
+func Stalled(deadline time.Duration, message string) corev1alpha1.Condition {
+	m := fmt.Sprintf("resource has not become ready within its creation deadline of %s", deadline)
+	if message != "" {
+		m = fmt.Sprintf("%s: %s", m, message)
+	}
+	return corev1alpha1.Condition{
+		Type:               TypeStalled,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonCreationDeadlineExceeded,
+		Message:            m,
+	}
+}
+
+// Recovered returns a condition indicating that a managed resource that was
+// stalled has since become ready.
+func Recovered() corev1alpha1.Condition {
+	return corev1alpha1.Condition{
+		Type:               TypeStalled,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonRecovered,
+	}
+}
+
+// IsStalled returns true if the supplied conditioned status has a true
+// Stalled condition.
+func IsStalled(s corev1alpha1.ConditionedStatus) bool {
+	return s.GetCondition(TypeStalled).Status == corev1.ConditionTrue
+}
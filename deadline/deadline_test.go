@@ -0,0 +1,152 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deadline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+)
+
+func TestCreationDeadline(t *testing.T) {
+	cases := map[string]struct {
+		o    metav1.Object
+		want time.Duration
+	}{
+		"NoAnnotation": {
+			o:    &metav1.ObjectMeta{},
+			want: DefaultCreationDeadline,
+		},
+		"ValidAnnotation": {
+			o:    &metav1.ObjectMeta{Annotations: map[string]string{AnnotationCreationDeadline: "45m"}},
+			want: 45 * time.Minute,
+		},
+		"InvalidAnnotation": {
+			o:    &metav1.ObjectMeta{Annotations: map[string]string{AnnotationCreationDeadline: "soon"}},
+			want: DefaultCreationDeadline,
+		},
+		"NegativeAnnotation": {
+			o:    &metav1.ObjectMeta{Annotations: map[string]string{AnnotationCreationDeadline: "-5m"}},
+			want: DefaultCreationDeadline,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := CreationDeadline(tc.o)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("CreationDeadline(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestIsStalled(t *testing.T) {
+	stalled := corev1alpha1.ConditionedStatus{}
+	stalled.SetConditions(Stalled(DefaultCreationDeadline, "boom"))
+
+	if IsStalled(corev1alpha1.ConditionedStatus{}) {
+		t.Errorf("IsStalled(...): want false for empty status")
+	}
+	if !IsStalled(stalled) {
+		t.Errorf("IsStalled(...): want true for stalled status")
+	}
+
+	stalled.SetConditions(Recovered())
+	if IsStalled(stalled) {
+		t.Errorf("IsStalled(...): want false for recovered status")
+	}
+}
+
+func TestObserve(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	earlier := now.Add(-1 * time.Hour)
+	waiting := func(state string, since time.Time) map[string]string {
+		return map[string]string{AnnotationWaitingSince: state + " " + since.Format(time.RFC3339)}
+	}
+
+	type want struct {
+		since       time.Time
+		changed     bool
+		annotations map[string]string
+	}
+
+	cases := map[string]struct {
+		o     metav1.Object
+		state string
+		want  want
+	}{
+		"FirstObserved": {
+			o:     &metav1.ObjectMeta{},
+			state: "RECONCILING",
+			want:  want{since: now, changed: true, annotations: waiting("RECONCILING", now)},
+		},
+		"StillObserved": {
+			o:     &metav1.ObjectMeta{Annotations: waiting("RECONCILING", earlier)},
+			state: "RECONCILING",
+			want:  want{since: earlier, changed: false, annotations: waiting("RECONCILING", earlier)},
+		},
+		"StateChanged": {
+			o:     &metav1.ObjectMeta{Annotations: waiting("PROVISIONING", earlier)},
+			state: "RECONCILING",
+			want:  want{since: now, changed: true, annotations: waiting("RECONCILING", now)},
+		},
+		"InvalidAnnotation": {
+			o:     &metav1.ObjectMeta{Annotations: map[string]string{AnnotationWaitingSince: "RECONCILING"}},
+			state: "RECONCILING",
+			want:  want{since: now, changed: true, annotations: waiting("RECONCILING", now)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			since, changed := Observe(tc.o, tc.state, now)
+			got := want{since: since, changed: changed, annotations: tc.o.GetAnnotations()}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("Observe(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestForget(t *testing.T) {
+	o := &metav1.ObjectMeta{Annotations: map[string]string{AnnotationWaitingSince: "RECONCILING 2019-01-01T00:00:00Z"}}
+	if !Forget(o) {
+		t.Errorf("Forget(...): want true for waiting object")
+	}
+	if _, ok := o.GetAnnotations()[AnnotationWaitingSince]; ok {
+		t.Errorf("Forget(...): want annotation removed")
+	}
+	if Forget(o) {
+		t.Errorf("Forget(...): want false for object that is not waiting")
+	}
+}
+
+func TestExceededSince(t *testing.T) {
+	now := time.Now()
+	o := &metav1.ObjectMeta{}
+	if ExceededSince(o, now.Add(-1*time.Minute), now) {
+		t.Errorf("ExceededSince(...): want false within deadline")
+	}
+	if !ExceededSince(o, now.Add(-1*time.Hour), now) {
+		t.Errorf("ExceededSince(...): want true past deadline")
+	}
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package defaults contains a mutating webhook that fills the location, tier,
+// and network of managed resources from the default policy of their
+// namespace when they are omitted. Minimal manifests thus produce sensible
+// resources, and changing a policy does not require editing every resource.
+package defaults
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplaneio/crossplane/pkg/logging"
+)
+
+// Path is the path at which the defaulting webhook is served.
+const Path = "/mutate/defaults.gcp.crossplane.io"
+
+// DefaultPolicyName is the name of the ConfigMap from which default policies
+// are read if the Options don't specify a name.
+const DefaultPolicyName = "gcp-defaults"
+
+// requestTimeout bounds the reads of policies made while admitting a
+// resource. The API server waits on the webhook, so it must be brief.
+const requestTimeout = 5 * time.Second
+
+var log = logging.Logger.WithName("webhook.defaults")
+
+// A policy is read from a ConfigMap whose data maps each defaulted field to
+// its default, for example:
+//
+//	region: us-central1
+//	zone: us-central1-a
+//	network: projects/cool-project/global/networks/cool-network
+//	CloudsqlInstance.tier: db-custom-1-3840
+//	CloudMemorystoreInstance.tier: STANDARD_HA
+//
+// A key may be qualified by the kind of managed resource it applies to, in
+// which case it takes precedence over the unqualified key. Tiers mean
+// different things to different kinds, so they should always be qualified.
+type policy map[string]string
+
+// get returns the default of the supplied field for the supplied kind.
+func (p policy) get(kind, field string) (string, bool) {
+	if v, ok := p[kind+"."+field]; ok {
+		return v, true
+	}
+	v, ok := p[field]
+	return v, ok
+}
+
+// The fields that may be defaulted.
+const (
+	fieldRegion  = "region"
+	fieldZone    = "zone"
+	fieldTier    = "tier"
+	fieldNetwork = "network"
+)
+
+// specFields maps each kind of managed resource that may be defaulted to the
+// spec field that each defaulted field fills.
+var specFields = map[string]map[string]string{
+	"CloudsqlInstance": {
+		fieldRegion:  "region",
+		fieldTier:    "tier",
+		fieldNetwork: "privateNetwork",
+	},
+	"CloudMemorystoreInstance": {
+		fieldRegion:  "region",
+		fieldTier:    "tier",
+		fieldNetwork: "authorizedNetwork",
+	},
+	"GKECluster": {
+		fieldZone:    "zone",
+		fieldNetwork: "network",
+	},
+}
+
+// Options configure the defaulting webhook.
+type Options struct {
+	// Enabled enables the webhook. Like the other webhooks, it requires the
+	// manager's webhook server to be configured with a serving certificate
+	// trusted by the API server.
+	Enabled bool
+
+	// PolicyName is the name of the ConfigMap from which the default policy
+	// of each namespace is read. DefaultPolicyName is used if it is empty.
+	PolicyName string
+
+	// Namespace from which the default policy of namespaces that don't have
+	// one is read. Namespaces without a policy are not defaulted if it is
+	// empty.
+	Namespace string
+}
+
+// A Webhook fills omitted fields of managed resources from the default
+// policy of their namespace.
+type Webhook struct {
+	kube client.Reader
+	o    Options
+}
+
+// NewWebhook returns a defaulting webhook that reads policies using the
+// supplied client.
+func NewWebhook(kube client.Reader, o Options) *Webhook {
+	if o.PolicyName == "" {
+		o.PolicyName = DefaultPolicyName
+	}
+	return &Webhook{kube: kube, o: o}
+}
+
+// ServeHTTP handles an AdmissionReview sent by the API server.
+func (w *Webhook) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	review := &admissionv1beta1.AdmissionReview{}
+	if err := json.NewDecoder(req.Body).Decode(review); err != nil || review.Request == nil {
+		http.Error(rw, "cannot decode admission review", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), requestTimeout)
+	defer cancel()
+
+	review.Response = w.admit(ctx, review.Request)
+	review.Request = nil
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(review); err != nil {
+		log.Error(err, "cannot encode admission review")
+	}
+}
+
+// admit allows the supplied AdmissionRequest, patching the object it creates
+// to fill omitted fields from the default policy of its namespace. Policies
+// are only applied when a resource is created, so that changing a policy
+// never moves or resizes an existing resource.
+func (w *Webhook) admit(ctx context.Context, req *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	rsp := &admissionv1beta1.AdmissionResponse{UID: req.UID, Allowed: true}
+	fields, ok := specFields[req.Kind.Kind]
+	if req.Operation != admissionv1beta1.Create || !ok {
+		return rsp
+	}
+
+	p, err := w.policy(ctx, req.Namespace)
+	if err != nil {
+		// Failing to default a resource shouldn't prevent its creation;
+		// the controller reports the fields it requires.
+		log.Error(err, "cannot read default policy", "namespace", req.Namespace)
+		return rsp
+	}
+
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(req.Object.Raw, &obj); err != nil {
+		rsp.Allowed = false
+		rsp.Result = &metav1.Status{Status: metav1.StatusFailure, Reason: metav1.StatusReasonBadRequest, Message: errors.Wrapf(err, "cannot decode %s", req.Kind.Kind).Error()}
+		return rsp
+	}
+
+	patch := defaults(req.Kind.Kind, fields, p, obj)
+	if len(patch) == 0 {
+		return rsp
+	}
+
+	// A JSON patch can always be marshalled.
+	rsp.Patch, _ = json.Marshal(patch)
+	pt := admissionv1beta1.PatchTypeJSONPatch
+	rsp.PatchType = &pt
+	return rsp
+}
+
+// A patchOperation is a JSON patch operation.
+type patchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value string `json:"value"`
+}
+
+// defaults returns the JSON patch that fills the supplied fields of the
+// supplied object's spec that are omitted and that the supplied policy
+// defaults. Operations are sorted by path.
+func defaults(kind string, fields map[string]string, p policy, obj map[string]interface{}) []patchOperation {
+	spec, ok := obj["spec"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	patch := []patchOperation{}
+	for field, specField := range fields {
+		if v, ok := spec[specField].(string); ok && v != "" {
+			continue
+		}
+		v, ok := p.get(kind, field)
+		if !ok || v == "" {
+			continue
+		}
+		patch = append(patch, patchOperation{Op: "add", Path: "/spec/" + specField, Value: v})
+	}
+	sort.Slice(patch, func(i, j int) bool { return patch[i].Path < patch[j].Path })
+	return patch
+}
+
+// policy returns the default policy of the supplied namespace, falling back
+// to that of the configured namespace. It returns an empty policy if neither
+// namespace has a policy.
+func (w *Webhook) policy(ctx context.Context, namespace string) (policy, error) {
+	for _, ns := range []string{namespace, w.o.Namespace} {
+		if ns == "" {
+			continue
+		}
+		cm := &corev1.ConfigMap{}
+		err := w.kube.Get(ctx, types.NamespacedName{Namespace: ns, Name: w.o.PolicyName}, cm)
+		if kerrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get default policy %s/%s", ns, w.o.PolicyName)
+		}
+		return policy(cm.Data), nil
+	}
+	return policy{}, nil
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package defaults
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace   = "cool-namespace"
+	fallbackNS  = "crossplane-system"
+	region      = "us-central1"
+	otherRegion = "europe-west1"
+	tier        = "db-custom-1-3840"
+	network     = "projects/cool-project/global/networks/cool-network"
+)
+
+var errBoom = errors.New("boom")
+
+// policies returns a client that serves the supplied policies, keyed by
+// namespace.
+func policies(p map[string]map[string]string) client.Reader {
+	return &test.MockClient{
+		MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+			data, ok := p[key.Namespace]
+			if !ok || key.Name != DefaultPolicyName {
+				return kerrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, key.Name)
+			}
+			obj.(*corev1.ConfigMap).Data = data
+			return nil
+		},
+	}
+}
+
+func request(op admissionv1beta1.Operation, kind string, spec map[string]interface{}) *admissionv1beta1.AdmissionRequest {
+	raw, _ := json.Marshal(map[string]interface{}{"spec": spec})
+	return &admissionv1beta1.AdmissionRequest{
+		UID:       "cool-uid",
+		Kind:      metav1.GroupVersionKind{Kind: kind},
+		Namespace: namespace,
+		Operation: op,
+		Object:    runtime.RawExtension{Raw: raw},
+	}
+}
+
+func TestAdmit(t *testing.T) {
+	cases := map[string]struct {
+		kube  client.Reader
+		o     Options
+		req   *admissionv1beta1.AdmissionRequest
+		patch []patchOperation
+	}{
+		"UpdateIsNotDefaulted": {
+			kube: policies(map[string]map[string]string{namespace: {"region": region}}),
+			req:  request(admissionv1beta1.Update, "CloudsqlInstance", map[string]interface{}{}),
+		},
+		"UnknownKindIsNotDefaulted": {
+			kube: policies(map[string]map[string]string{namespace: {"region": region}}),
+			req:  request(admissionv1beta1.Create, "Bucket", map[string]interface{}{}),
+		},
+		"OmittedFieldsAreDefaulted": {
+			kube: policies(map[string]map[string]string{namespace: {
+				"region":                        region,
+				"network":                       network,
+				"CloudsqlInstance.tier":         tier,
+				"CloudMemorystoreInstance.tier": "STANDARD_HA",
+			}}),
+			req: request(admissionv1beta1.Create, "CloudsqlInstance", map[string]interface{}{}),
+			patch: []patchOperation{
+				{Op: "add", Path: "/spec/privateNetwork", Value: network},
+				{Op: "add", Path: "/spec/region", Value: region},
+				{Op: "add", Path: "/spec/tier", Value: tier},
+			},
+		},
+		"SpecifiedFieldsAreNotDefaulted": {
+			kube: policies(map[string]map[string]string{namespace: {"region": region, "tier": tier}}),
+			req:  request(admissionv1beta1.Create, "CloudsqlInstance", map[string]interface{}{"region": otherRegion}),
+			patch: []patchOperation{
+				{Op: "add", Path: "/spec/tier", Value: tier},
+			},
+		},
+		"QualifiedKeyTakesPrecedence": {
+			kube: policies(map[string]map[string]string{namespace: {"zone": "us-central1-a", "GKECluster.zone": "us-central1-b"}}),
+			req:  request(admissionv1beta1.Create, "GKECluster", map[string]interface{}{}),
+			patch: []patchOperation{
+				{Op: "add", Path: "/spec/zone", Value: "us-central1-b"},
+			},
+		},
+		"FallbackPolicyIsUsed": {
+			kube: policies(map[string]map[string]string{fallbackNS: {"region": otherRegion}}),
+			o:    Options{Namespace: fallbackNS},
+			req:  request(admissionv1beta1.Create, "CloudMemorystoreInstance", map[string]interface{}{}),
+			patch: []patchOperation{
+				{Op: "add", Path: "/spec/region", Value: otherRegion},
+			},
+		},
+		"NoPolicyIsNotDefaulted": {
+			kube: policies(map[string]map[string]string{fallbackNS: {"region": otherRegion}}),
+			req:  request(admissionv1beta1.Create, "CloudsqlInstance", map[string]interface{}{}),
+		},
+		"PolicyErrorIsNotDefaulted": {
+			kube: &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, _ runtime.Object) error { return errBoom },
+			},
+			req: request(admissionv1beta1.Create, "CloudsqlInstance", map[string]interface{}{}),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rsp := NewWebhook(tc.kube, tc.o).admit(context.Background(), tc.req)
+			if !rsp.Allowed {
+				t.Fatalf("admit(...): want allowed, got denied: %v", rsp.Result)
+			}
+
+			var patch []patchOperation
+			if rsp.Patch != nil {
+				if err := json.Unmarshal(rsp.Patch, &patch); err != nil {
+					t.Fatalf("admit(...): cannot decode patch: %v", err)
+				}
+			}
+			if diff := cmp.Diff(tc.patch, patch); diff != "" {
+				t.Errorf("admit(...): -want patch, +got patch:\n%s", diff)
+			}
+		})
+	}
+}
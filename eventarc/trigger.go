@@ -0,0 +1,396 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package eventarc contains a controller that routes events from GCP
+// services, such as Pub/Sub and Cloud Storage, to Cloud Run and GKE services
+// using Eventarc triggers.
+package eventarc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	eventarcv1 "google.golang.org/api/eventarc/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/eventarc/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/eventarc"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	controllerName   = "triggers.eventarc.gcp.crossplane.io"
+	finalizerName    = "finalizer." + controllerName
+	reconcileTimeout = 1 * time.Minute
+
+	// triggerIDPrefix is prepended to the UID of a Trigger to form the ID of
+	// its Eventarc trigger. IDs must begin with a letter.
+	triggerIDPrefix = "trigger-"
+
+	// attributeType is the event filter attribute that every trigger must
+	// filter on, e.g. google.cloud.pubsub.topic.v1.messagePublished.
+	attributeType = "type"
+
+	// conditionOK is the code of a trigger condition that is satisfied.
+	conditionOK = "OK"
+)
+
+var log = logging.Logger.WithName("controller." + controllerName)
+
+// A createsyncdeleter can create, sync, and delete Eventarc triggers in an
+// external store - e.g. the GCP API. Each method returns true if the trigger
+// requires further reconciliation.
+type createsyncdeleter interface {
+	Create(ctx context.Context, t *v1alpha1.Trigger) (requeue bool)
+	Sync(ctx context.Context, t *v1alpha1.Trigger) (requeue bool)
+	Delete(ctx context.Context, t *v1alpha1.Trigger) (requeue bool)
+}
+
+// triggers is a createsyncdeleter using the GCP Eventarc API.
+type triggers struct {
+	client  eventarc.Client
+	project string
+}
+
+// Create creates a trigger that delivers the filtered events to the desired
+// Cloud Run or GKE service.
+func (c *triggers) Create(ctx context.Context, t *v1alpha1.Trigger) bool {
+	t.Status.SetConditions(corev1alpha1.Creating())
+
+	if err := validateTrigger(t.Spec.TriggerParameters); err != nil {
+		// Don't requeue invalid specs; they'll be reconciled again when updated.
+		t.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return false
+	}
+
+	parent := parentName(c.project, t.Spec.Location)
+	id := triggerIDPrefix + string(t.GetUID())
+
+	// Creation is asynchronous. We may have created the trigger but failed to
+	// record its name.
+	if err := c.client.CreateTrigger(ctx, parent, id, newTrigger(t.Spec.TriggerParameters)); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		t.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create trigger")))
+		return true
+	}
+
+	t.Status.TriggerName = parent + "/triggers/" + id
+	meta.AddFinalizer(t, finalizerName)
+	t.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync updates the trigger if it differs from its spec. The trigger is
+// available once Eventarc reports that all of its conditions, for example
+// that its service account may invoke its destination, are satisfied.
+func (c *triggers) Sync(ctx context.Context, t *v1alpha1.Trigger) bool {
+	actual, err := c.client.GetTrigger(ctx, t.Status.TriggerName)
+	if err != nil {
+		t.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+	if actual.Transport != nil && actual.Transport.Pubsub != nil {
+		t.Status.TransportTopic = actual.Transport.Pubsub.Topic
+		t.Status.TransportSubscription = actual.Transport.Pubsub.Subscription
+	}
+
+	if err := validateTrigger(t.Spec.TriggerParameters); err != nil {
+		t.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return false
+	}
+
+	desired := newTrigger(t.Spec.TriggerParameters)
+	if mask := updateMask(desired, actual); len(mask) > 0 {
+		if err := c.client.UpdateTrigger(ctx, t.Status.TriggerName, desired, strings.Join(mask, ",")); err != nil {
+			t.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot update trigger")))
+			return true
+		}
+		t.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	if err := unsatisfiedConditions(actual.Conditions); err != nil {
+		t.Status.SetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	t.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete deletes the trigger. Eventarc deletes any Pub/Sub subscription it
+// created to transport the trigger's events.
+func (c *triggers) Delete(ctx context.Context, t *v1alpha1.Trigger) bool {
+	t.Status.SetConditions(corev1alpha1.Deleting())
+
+	if t.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.client.DeleteTrigger(ctx, t.Status.TriggerName); err != nil && !googleapi.IsErrorNotFound(err) {
+			t.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete trigger")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(t, finalizerName)
+	t.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// validateTrigger returns an error if the supplied parameters don't filter on
+// an event type, or don't specify exactly one destination.
+func validateTrigger(p v1alpha1.TriggerParameters) error {
+	typed := false
+	for _, f := range p.EventFilters {
+		if f.Attribute == attributeType {
+			typed = true
+		}
+	}
+	if !typed {
+		return errors.Errorf("eventFilters must include a filter on the %q attribute", attributeType)
+	}
+
+	d := p.Destination
+	if (d.CloudRun == nil) == (d.GKE == nil) {
+		return errors.New("destination must specify exactly one of cloudRun or gke")
+	}
+	return nil
+}
+
+// newTrigger returns the Eventarc trigger described by the supplied
+// parameters. Filters are sorted so they compare equal regardless of the order
+// in which they were specified or are returned by the API.
+func newTrigger(p v1alpha1.TriggerParameters) *eventarcv1.Trigger {
+	t := &eventarcv1.Trigger{
+		ServiceAccount: p.ServiceAccount,
+		Labels:         p.Labels,
+		Destination:    &eventarcv1.Destination{},
+	}
+
+	for _, f := range p.EventFilters {
+		t.EventFilters = append(t.EventFilters, &eventarcv1.EventFilter{
+			Attribute: f.Attribute,
+			Value:     f.Value,
+			Operator:  f.Operator,
+		})
+	}
+	sortFilters(t.EventFilters)
+
+	if r := p.Destination.CloudRun; r != nil {
+		t.Destination.CloudRun = &eventarcv1.CloudRun{Service: r.Service, Path: r.Path, Region: r.Region}
+	}
+	if g := p.Destination.GKE; g != nil {
+		t.Destination.Gke = &eventarcv1.GKE{
+			Cluster:   g.Cluster,
+			Location:  g.Location,
+			Namespace: g.Namespace,
+			Service:   g.Service,
+			Path:      g.Path,
+		}
+	}
+
+	// Events of some types, e.g. Pub/Sub messages, are transported by an
+	// existing topic rather than one Eventarc creates. The transport can't be
+	// changed once the trigger is created.
+	if p.TransportTopic != "" {
+		t.Transport = &eventarcv1.Transport{Pubsub: &eventarcv1.Pubsub{Topic: p.TransportTopic}}
+	}
+	return t
+}
+
+func sortFilters(f []*eventarcv1.EventFilter) {
+	sort.Slice(f, func(i, j int) bool {
+		if f[i].Attribute != f[j].Attribute {
+			return f[i].Attribute < f[j].Attribute
+		}
+		return f[i].Value < f[j].Value
+	})
+}
+
+// updateMask returns the paths of the fields of the actual trigger that differ
+// from the desired trigger and may be updated.
+func updateMask(desired, actual *eventarcv1.Trigger) []string {
+	filters := make([]*eventarcv1.EventFilter, len(actual.EventFilters))
+	copy(filters, actual.EventFilters)
+	sortFilters(filters)
+
+	mask := []string{}
+	if !compare.Equal(desired.EventFilters, filters) {
+		mask = append(mask, "eventFilters")
+	}
+	if desired.ServiceAccount != "" && desired.ServiceAccount != actual.ServiceAccount {
+		mask = append(mask, "serviceAccount")
+	}
+	if !compare.Equal(desired.Destination, actual.Destination, compare.IgnoreUnset()) {
+		mask = append(mask, "destination")
+	}
+	if !compare.Equal(desired.Labels, actual.Labels) {
+		mask = append(mask, "labels")
+	}
+	return mask
+}
+
+// unsatisfiedConditions returns an error describing any of the supplied
+// trigger conditions that are not OK, or nil if all are.
+func unsatisfiedConditions(conditions map[string]eventarcv1.StateCondition) error {
+	msgs := []string{}
+	for name, c := range conditions {
+		if c.Code == conditionOK {
+			continue
+		}
+		msgs = append(msgs, fmt.Sprintf("%s: %s: %s", name, c.Code, c.Message))
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	sort.Strings(msgs)
+	return errors.Errorf("trigger conditions are not satisfied: %s", strings.Join(msgs, "; "))
+}
+
+// parentName returns the fully qualified name of the supplied location within
+// the supplied project, e.g. projects/p/locations/us-central1.
+func parentName(project, location string) string {
+	return fmt.Sprintf("projects/%s/locations/%s", project, location)
+}
+
+// A connecter returns a createsyncdeleter that can create, sync, and delete
+// Eventarc triggers with an external store - for example the GCP API.
+type connecter interface {
+	Connect(context.Context, *v1alpha1.Trigger) (createsyncdeleter, error)
+}
+
+// providerConnecter is a connecter that returns a createsyncdeleter
+// authenticated using credentials read from a Crossplane Provider resource.
+type providerConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (eventarc.Client, error)
+}
+
+// Connect returns a createsyncdeleter backed by the GCP API. GCP credentials
+// are read from the Crossplane Provider referenced by the supplied Trigger.
+func (c *providerConnecter) Connect(ctx context.Context, t *v1alpha1.Trigger) (createsyncdeleter, error) {
+	p, err := c.providers.Get(ctx, c.kube, t, t.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceEventarc, eventarcv1.CloudPlatformScope)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return &triggers{client: client, project: p.Spec.ProjectID}, errors.Wrap(err, "cannot create new Eventarc client")
+}
+
+// Reconciler reconciles Triggers read from the Kubernetes API with an external
+// store, typically the GCP API.
+type Reconciler struct {
+	connecter
+	kube client.Client
+}
+
+// TriggerController is responsible for adding the Eventarc Trigger controller
+// and its corresponding reconciler to the manager with any runtime
+// configuration.
+type TriggerController struct {
+	// DefaultProvider is used by triggers that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new Trigger Controller and adds it to the
+// Manager with default RBAC. The Manager will set fields on the Controller and
+// start it when the Manager is Started.
+func (c *TriggerController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &Reconciler{
+		connecter: &providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: eventarc.NewClient,
+		},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerName).
+		For(&v1alpha1.Trigger{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listTriggers)).
+		Complete(r)
+}
+
+// Reconcile Eventarc triggers with the GCP API.
+func (r *Reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	log.V(logging.Debug).Info("reconciling", "kind", v1alpha1.TriggerKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	t := &v1alpha1.Trigger{}
+	if err := r.kube.Get(ctx, req.NamespacedName, t); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get trigger %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, t)
+	if err != nil {
+		t.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, t), "cannot update trigger %s", req.NamespacedName)
+	}
+
+	// The trigger has been deleted from the API server. Delete from GCP.
+	if t.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, t)}, errors.Wrapf(r.kube.Update(ctx, t), "cannot update trigger %s", req.NamespacedName)
+	}
+
+	// The trigger is unnamed. Assume it has not been created in GCP.
+	if t.Status.TriggerName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, t)}, errors.Wrapf(r.kube.Update(ctx, t), "cannot update trigger %s", req.NamespacedName)
+	}
+
+	// The trigger exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, t)}, errors.Wrapf(r.kube.Update(ctx, t), "cannot update trigger %s", req.NamespacedName)
+}
+
+// listTriggers is a provider.Lister of Eventarc triggers.
+func listTriggers(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.TriggerList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
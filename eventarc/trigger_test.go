@@ -0,0 +1,464 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventarc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	eventarcv1 "google.golang.org/api/eventarc/v1"
+	"google.golang.org/api/googleapi"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/eventarc/v1alpha1"
+	fakeeventarc "github.com/crossplaneio/crossplane/pkg/clients/gcp/eventarc/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace      = "cool-namespace"
+	name           = "cool-trigger"
+	uid            = types.UID("definitely-a-uuid")
+	project        = "coolProject"
+	location       = "us-central1"
+	providerName   = "cool-gcp"
+	serviceAccount = "cool-sa@coolProject.iam.gserviceaccount.com"
+	eventType      = "google.cloud.storage.object.v1.finalized"
+	bucket         = "cool-bucket"
+	service        = "cool-service"
+	topic          = "projects/coolProject/topics/eventarc-us-central1-cool"
+)
+
+var (
+	ctx           = context.Background()
+	errorBoom     = errors.New("boom")
+	errorNotFound = &googleapi.Error{Code: http.StatusNotFound}
+	triggerName   = parentName(project, location) + "/triggers/" + triggerIDPrefix + string(uid)
+)
+
+// Test that our Reconciler implementation satisfies the Reconciler interface.
+var _ reconcile.Reconciler = &Reconciler{}
+
+type triggerModifier func(*v1alpha1.Trigger)
+
+func withConditions(c ...corev1alpha1.Condition) triggerModifier {
+	return func(t *v1alpha1.Trigger) { t.Status.SetConditions(c...) }
+}
+
+func withFinalizers(f ...string) triggerModifier {
+	return func(t *v1alpha1.Trigger) { t.ObjectMeta.Finalizers = f }
+}
+
+func withReclaimPolicy(p corev1alpha1.ReclaimPolicy) triggerModifier {
+	return func(t *v1alpha1.Trigger) { t.Spec.ReclaimPolicy = p }
+}
+
+func withTriggerName(n string) triggerModifier {
+	return func(t *v1alpha1.Trigger) { t.Status.TriggerName = n }
+}
+
+func withTransport(topic, subscription string) triggerModifier {
+	return func(t *v1alpha1.Trigger) {
+		t.Status.TransportTopic = topic
+		t.Status.TransportSubscription = subscription
+	}
+}
+
+func withDestination(d v1alpha1.TriggerDestination) triggerModifier {
+	return func(t *v1alpha1.Trigger) { t.Spec.Destination = d }
+}
+
+func trigger(tm ...triggerModifier) *v1alpha1.Trigger {
+	t := &v1alpha1.Trigger{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.TriggerSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			TriggerParameters: v1alpha1.TriggerParameters{
+				Location: location,
+				EventFilters: []v1alpha1.EventFilter{
+					{Attribute: "bucket", Value: bucket},
+					{Attribute: attributeType, Value: eventType},
+				},
+				Destination: v1alpha1.TriggerDestination{
+					CloudRun: &v1alpha1.CloudRunDestination{Service: service, Region: location},
+				},
+				ServiceAccount: serviceAccount,
+			},
+		},
+	}
+
+	for _, m := range tm {
+		m(t)
+	}
+
+	return t
+}
+
+// actualTrigger returns the trigger GCP would return for the default spec.
+func actualTrigger(conditions map[string]eventarcv1.StateCondition) *eventarcv1.Trigger {
+	return &eventarcv1.Trigger{
+		Name: triggerName,
+		EventFilters: []*eventarcv1.EventFilter{
+			{Attribute: attributeType, Value: eventType},
+			{Attribute: "bucket", Value: bucket},
+		},
+		ServiceAccount: serviceAccount,
+		Destination:    &eventarcv1.Destination{CloudRun: &eventarcv1.CloudRun{Service: service, Region: location}},
+		Transport:      &eventarcv1.Transport{Pubsub: &eventarcv1.Pubsub{Topic: topic, Subscription: "projects/coolProject/subscriptions/eventarc-cool"}},
+		Conditions:     conditions,
+	}
+}
+
+func TestCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		t           *v1alpha1.Trigger
+		want        *v1alpha1.Trigger
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &triggers{project: project, client: &fakeeventarc.MockClient{
+				MockCreateTrigger: func(_ context.Context, parent, id string, tr *eventarcv1.Trigger) error {
+					if parent+"/triggers/"+id != triggerName {
+						t.Errorf("CreateTrigger(...): want name %s, got %s/triggers/%s", triggerName, parent, id)
+					}
+					return nil
+				},
+			}},
+			t: trigger(),
+			want: trigger(
+				withFinalizers(finalizerName),
+				withTriggerName(triggerName),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "AlreadyExists",
+			csd: &triggers{project: project, client: &fakeeventarc.MockClient{
+				MockCreateTrigger: func(_ context.Context, _, _ string, _ *eventarcv1.Trigger) error {
+					return &googleapi.Error{Code: http.StatusConflict}
+				},
+			}},
+			t: trigger(),
+			want: trigger(
+				withFinalizers(finalizerName),
+				withTriggerName(triggerName),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "InvalidDestination",
+			csd:  &triggers{project: project, client: &fakeeventarc.MockClient{}},
+			t:    trigger(withDestination(v1alpha1.TriggerDestination{})),
+			want: trigger(
+				withDestination(v1alpha1.TriggerDestination{}),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.New("destination must specify exactly one of cloudRun or gke"))),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "Failed",
+			csd: &triggers{project: project, client: &fakeeventarc.MockClient{
+				MockCreateTrigger: func(_ context.Context, _, _ string, _ *eventarcv1.Trigger) error { return errorBoom },
+			}},
+			t: trigger(),
+			want: trigger(
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create trigger"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.t)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.t, test.EquateConditions()); diff != "" {
+				t.Errorf("t: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	ok := map[string]eventarcv1.StateCondition{"service account": {Code: conditionOK}}
+	denied := map[string]eventarcv1.StateCondition{"service account": {Code: "PERMISSION_DENIED", Message: "cannot invoke service"}}
+	subscription := "projects/coolProject/subscriptions/eventarc-cool"
+
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		t           *v1alpha1.Trigger
+		want        *v1alpha1.Trigger
+		wantRequeue bool
+	}{
+		{
+			name: "Available",
+			csd: &triggers{project: project, client: &fakeeventarc.MockClient{
+				MockGetTrigger: func(_ context.Context, _ string) (*eventarcv1.Trigger, error) { return actualTrigger(ok), nil },
+			}},
+			t: trigger(withTriggerName(triggerName)),
+			want: trigger(
+				withTriggerName(triggerName),
+				withTransport(topic, subscription),
+				withConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ConditionUnsatisfied",
+			csd: &triggers{project: project, client: &fakeeventarc.MockClient{
+				MockGetTrigger: func(_ context.Context, _ string) (*eventarcv1.Trigger, error) { return actualTrigger(denied), nil },
+			}},
+			t: trigger(withTriggerName(triggerName)),
+			want: trigger(
+				withTriggerName(triggerName),
+				withTransport(topic, subscription),
+				withConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(errors.New("trigger conditions are not satisfied: service account: PERMISSION_DENIED: cannot invoke service"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "NeedsUpdate",
+			csd: &triggers{project: project, client: &fakeeventarc.MockClient{
+				MockGetTrigger: func(_ context.Context, _ string) (*eventarcv1.Trigger, error) {
+					tr := actualTrigger(ok)
+					tr.Destination.CloudRun.Service = "old-service"
+					return tr, nil
+				},
+				MockUpdateTrigger: func(_ context.Context, _ string, _ *eventarcv1.Trigger, mask string) error {
+					if mask != "destination" {
+						t.Errorf("UpdateTrigger(...): want mask destination, got %s", mask)
+					}
+					return nil
+				},
+			}},
+			t: trigger(withTriggerName(triggerName)),
+			want: trigger(
+				withTriggerName(triggerName),
+				withTransport(topic, subscription),
+				withConditions(corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedUpdate",
+			csd: &triggers{project: project, client: &fakeeventarc.MockClient{
+				MockGetTrigger: func(_ context.Context, _ string) (*eventarcv1.Trigger, error) {
+					tr := actualTrigger(ok)
+					tr.ServiceAccount = "old-sa@coolProject.iam.gserviceaccount.com"
+					return tr, nil
+				},
+				MockUpdateTrigger: func(_ context.Context, _ string, _ *eventarcv1.Trigger, _ string) error { return errorBoom },
+			}},
+			t: trigger(withTriggerName(triggerName)),
+			want: trigger(
+				withTriggerName(triggerName),
+				withTransport(topic, subscription),
+				withConditions(corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot update trigger"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGet",
+			csd: &triggers{project: project, client: &fakeeventarc.MockClient{
+				MockGetTrigger: func(_ context.Context, _ string) (*eventarcv1.Trigger, error) { return nil, errorBoom },
+			}},
+			t: trigger(withTriggerName(triggerName)),
+			want: trigger(
+				withTriggerName(triggerName),
+				withConditions(corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.t)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.t, test.EquateConditions()); diff != "" {
+				t.Errorf("t: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		t           *v1alpha1.Trigger
+		want        *v1alpha1.Trigger
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimRetain",
+			csd:  &triggers{project: project, client: &fakeeventarc.MockClient{}},
+			t:    trigger(withTriggerName(triggerName), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimRetain)),
+			want: trigger(
+				withTriggerName(triggerName),
+				withReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteAlreadyGone",
+			csd: &triggers{project: project, client: &fakeeventarc.MockClient{
+				MockDeleteTrigger: func(_ context.Context, _ string) error { return errorNotFound },
+			}},
+			t: trigger(withTriggerName(triggerName), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: trigger(
+				withTriggerName(triggerName),
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailed",
+			csd: &triggers{project: project, client: &fakeeventarc.MockClient{
+				MockDeleteTrigger: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			t: trigger(withTriggerName(triggerName), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: trigger(
+				withTriggerName(triggerName),
+				withFinalizers(finalizerName),
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot delete trigger"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.t)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.t, test.EquateConditions()); diff != "" {
+				t.Errorf("t: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateTrigger(t *testing.T) {
+	cases := map[string]struct {
+		p       v1alpha1.TriggerParameters
+		wantErr bool
+	}{
+		"Valid": {
+			p: trigger().Spec.TriggerParameters,
+		},
+		"NoEventType": {
+			p: v1alpha1.TriggerParameters{
+				EventFilters: []v1alpha1.EventFilter{{Attribute: "bucket", Value: bucket}},
+				Destination:  v1alpha1.TriggerDestination{CloudRun: &v1alpha1.CloudRunDestination{Service: service}},
+			},
+			wantErr: true,
+		},
+		"TwoDestinations": {
+			p: v1alpha1.TriggerParameters{
+				EventFilters: []v1alpha1.EventFilter{{Attribute: attributeType, Value: eventType}},
+				Destination: v1alpha1.TriggerDestination{
+					CloudRun: &v1alpha1.CloudRunDestination{Service: service},
+					GKE:      &v1alpha1.GKEDestination{Cluster: "cool-cluster", Location: location, Namespace: "default", Service: service},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateTrigger(tc.p)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("validateTrigger(...): want error %t, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestUpdateMask(t *testing.T) {
+	cases := map[string]struct {
+		desired *eventarcv1.Trigger
+		actual  *eventarcv1.Trigger
+		want    []string
+	}{
+		"UpToDate": {
+			desired: newTrigger(trigger().Spec.TriggerParameters),
+			actual:  actualTrigger(nil),
+			want:    []string{},
+		},
+		"FiltersChanged": {
+			desired: newTrigger(trigger().Spec.TriggerParameters),
+			actual: func() *eventarcv1.Trigger {
+				tr := actualTrigger(nil)
+				tr.EventFilters[1].Value = "old-bucket"
+				return tr
+			}(),
+			want: []string{"eventFilters"},
+		},
+		"GKEDestinationAndLabels": {
+			desired: newTrigger(trigger(withDestination(v1alpha1.TriggerDestination{
+				GKE: &v1alpha1.GKEDestination{Cluster: "cool-cluster", Location: location, Namespace: "default", Service: service},
+			}), func(t *v1alpha1.Trigger) { t.Spec.Labels = map[string]string{"cool": "true"} }).Spec.TriggerParameters),
+			actual: actualTrigger(nil),
+			want:   []string{"destination", "labels"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := updateMask(tc.desired, tc.actual)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("updateMask(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
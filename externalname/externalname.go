@@ -0,0 +1,57 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package externalname persists the names of the external resources that
+// managed resources correspond to. Many controllers derive an external name
+// from the UID of a managed resource, which changes if the managed resource is
+// restored from a backup. Persisting the derived name in an annotation before
+// the external resource is created allows a restored managed resource to
+// reattach to its external resource rather than create a duplicate.
+package externalname
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AnnotationExternalName records the name of the external resource
+// corresponding to the annotated managed resource.
+const AnnotationExternalName = "gcp.crossplane.io/external-name"
+
+// Get returns the external name persisted on the supplied object, or the
+// empty string if none has been persisted.
+func Get(o metav1.Object) string {
+	return o.GetAnnotations()[AnnotationExternalName]
+}
+
+// Set persists the supplied external name on the supplied object. The object
+// must be updated for the name to be persisted.
+func Set(o metav1.Object, name string) {
+	a := o.GetAnnotations()
+	if a == nil {
+		a = map[string]string{}
+	}
+	a[AnnotationExternalName] = name
+	o.SetAnnotations(a)
+}
+
+// Resolve returns the external name persisted on the supplied object, or the
+// supplied derived name if none has been persisted.
+func Resolve(o metav1.Object, derived string) string {
+	if n := Get(o); n != "" {
+		return n
+	}
+	return derived
+}
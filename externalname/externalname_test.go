@@ -0,0 +1,79 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package externalname
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResolve(t *testing.T) {
+	cases := map[string]struct {
+		o       metav1.Object
+		derived string
+		want    string
+	}{
+		"NoAnnotation": {
+			o:       &metav1.ObjectMeta{},
+			derived: "gke-new-uid",
+			want:    "gke-new-uid",
+		},
+		"Persisted": {
+			o:       &metav1.ObjectMeta{Annotations: map[string]string{AnnotationExternalName: "gke-old-uid"}},
+			derived: "gke-new-uid",
+			want:    "gke-old-uid",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, Resolve(tc.o, tc.derived)); diff != "" {
+				t.Errorf("Resolve(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	cases := map[string]struct {
+		o    metav1.Object
+		name string
+		want map[string]string
+	}{
+		"NoAnnotations": {
+			o:    &metav1.ObjectMeta{},
+			name: "gke-cool-uid",
+			want: map[string]string{AnnotationExternalName: "gke-cool-uid"},
+		},
+		"OtherAnnotations": {
+			o:    &metav1.ObjectMeta{Annotations: map[string]string{"cool": "very"}},
+			name: "gke-cool-uid",
+			want: map[string]string{"cool": "very", AnnotationExternalName: "gke-cool-uid"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			Set(tc.o, tc.name)
+			if diff := cmp.Diff(tc.want, tc.o.GetAnnotations()); diff != "" {
+				t.Errorf("Set(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,406 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package facade serves a REST API that provisions resource claims on behalf
+// of consumers that are not Kubernetes native. Claims created via the API are
+// reconciled by the same controllers as any other claim.
+package facade
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	databasev1alpha1 "github.com/crossplaneio/crossplane/apis/database/v1alpha1"
+	storagev1alpha1 "github.com/crossplaneio/crossplane/apis/storage/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/database"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+)
+
+const (
+	requestTimeout  = 30 * time.Second
+	shutdownTimeout = 10 * time.Second
+
+	// maxRequestSize bounds the size of a request body, in bytes.
+	maxRequestSize = 1 << 20
+)
+
+// DefaultTokenSecretName is the name of the Secret from which the bearer
+// token of each namespace is read if the Options don't specify a name.
+const DefaultTokenSecretName = "gcp-facade-token"
+
+// TokenKey is the key of the token secret's data that holds the token.
+const TokenKey = "token"
+
+var log = logging.Logger.WithName("facade")
+
+// DefaultAllowedAnnotations are the annotations that claims created via the
+// API may set if the Options don't specify any. They ask that a claim be
+// scheduled to one of the resource classes in its namespace.
+var DefaultAllowedAnnotations = []string{database.AnnotationClassSelector, database.AnnotationCluster}
+
+// claimKinds are the kinds of resource claim that may be provisioned via the
+// API, keyed by the path segment that identifies them.
+var claimKinds = map[string]func() resource.Claim{
+	"postgresqlinstances": func() resource.Claim { return &databasev1alpha1.PostgreSQLInstance{} },
+	"mysqlinstances":      func() resource.Claim { return &databasev1alpha1.MySQLInstance{} },
+	"buckets":             func() resource.Claim { return &storagev1alpha1.Bucket{} },
+}
+
+// Options configure the API server.
+type Options struct {
+	// Address at which the API is served, e.g. :8443.
+	Address string
+
+	// AllowedAnnotations are the annotations that claims created via the API
+	// may set. Requests that set any other annotation are refused, lest they
+	// alter how a claim is reconciled in ways its namespace's consumers may
+	// not. DefaultAllowedAnnotations are allowed if it is nil.
+	AllowedAnnotations []string
+
+	// TokenSecretName is the name of the Secret, in each namespace, whose
+	// token key holds the bearer token that requests for that namespace must
+	// present. Requests for a namespace without such a Secret are refused.
+	TokenSecretName string
+
+	// CertFile and KeyFile contain the TLS certificate and key with which the
+	// API is served. Both are required; connection details are never served
+	// in plain text.
+	CertFile string
+	KeyFile  string
+}
+
+// A CreateRequest requests that a resource claim be created.
+type CreateRequest struct {
+	// Name of the resource claim. Its connection secret has the same name.
+	Name string `json:"name"`
+
+	// ClassReference to the resource class used to satisfy the claim, which
+	// must be in the claim's namespace. The default class is used if it is
+	// omitted.
+	ClassReference *corev1.ObjectReference `json:"classReference,omitempty"`
+
+	// EngineVersion of a PostgreSQL or MySQL instance, e.g. 11.
+	EngineVersion string `json:"engineVersion,omitempty"`
+
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations of the claim, which must be among the annotations allowed
+	// by the Options.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// A Claim describes the state of a resource claim.
+type Claim struct {
+	Namespace    string `json:"namespace"`
+	Name         string `json:"name"`
+	BindingPhase string `json:"bindingPhase,omitempty"`
+	Ready        bool   `json:"ready"`
+	Reason       string `json:"reason,omitempty"`
+	Message      string `json:"message,omitempty"`
+}
+
+// A Server serves the API. It is a manager.Runnable.
+type Server struct {
+	kube client.Client
+	o    Options
+}
+
+// NewServer returns a Server that manages resource claims using the supplied
+// client.
+func NewServer(kube client.Client, o Options) *Server {
+	if o.TokenSecretName == "" {
+		o.TokenSecretName = DefaultTokenSecretName
+	}
+	if o.AllowedAnnotations == nil {
+		o.AllowedAnnotations = DefaultAllowedAnnotations
+	}
+	return &Server{kube: kube, o: o}
+}
+
+// Start serves the API until the supplied channel is closed. It refuses to
+// serve the API without TLS.
+func (s *Server) Start(stop <-chan struct{}) error {
+	if s.o.CertFile == "" || s.o.KeyFile == "" {
+		return errors.Errorf("cannot serve API at %s without a TLS certificate and key", s.o.Address)
+	}
+
+	srv := &http.Server{Addr: s.o.Address, Handler: s}
+
+	go func() {
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Info("cannot shut down API server", "error", err.Error())
+		}
+	}()
+
+	err := srv.ListenAndServeTLS(s.o.CertFile, s.o.KeyFile)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return errors.Wrapf(err, "cannot serve API at %s", s.o.Address)
+}
+
+// ServeHTTP serves the following routes:
+//
+//	POST   /v1/namespaces/{namespace}/{kind}
+//	GET    /v1/namespaces/{namespace}/{kind}/{name}
+//	DELETE /v1/namespaces/{namespace}/{kind}/{name}
+//	GET    /v1/namespaces/{namespace}/{kind}/{name}/connection
+//
+// Where kind is one of postgresqlinstances, mysqlinstances, or buckets. Each
+// request must present the bearer token of the namespace it addresses.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(p) < 4 || len(p) > 6 || p[0] != "v1" || p[1] != "namespaces" || (len(p) == 6 && p[5] != "connection") {
+		writeError(w, http.StatusNotFound, errors.Errorf("unknown path %s", r.URL.Path))
+		return
+	}
+	newClaim, ok := claimKinds[p[3]]
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.Errorf("unknown kind %s", p[3]))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	ok, err := s.authorized(ctx, r, p[2])
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusUnauthorized, errors.Errorf("a valid bearer token for namespace %s is required", p[2]))
+		return
+	}
+
+	switch {
+	case len(p) == 4 && r.Method == http.MethodPost:
+		s.create(ctx, w, r, p[2], newClaim())
+	case len(p) == 5 && r.Method == http.MethodGet:
+		s.get(ctx, w, types.NamespacedName{Namespace: p[2], Name: p[4]}, newClaim())
+	case len(p) == 5 && r.Method == http.MethodDelete:
+		s.delete(ctx, w, types.NamespacedName{Namespace: p[2], Name: p[4]}, newClaim())
+	case len(p) == 6 && r.Method == http.MethodGet:
+		s.connection(ctx, w, types.NamespacedName{Namespace: p[2], Name: p[4]}, newClaim())
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s is not allowed", r.Method))
+	}
+}
+
+// authorized returns true if the supplied request presents the bearer token
+// of the supplied namespace. No request is authorized for a namespace whose
+// token secret does not exist or holds an empty token.
+func (s *Server) authorized(ctx context.Context, r *http.Request, namespace string) (bool, error) {
+	h := r.Header.Get("Authorization")
+	if !strings.HasPrefix(h, "Bearer ") {
+		return false, nil
+	}
+
+	secret := &corev1.Secret{}
+	n := types.NamespacedName{Namespace: namespace, Name: s.o.TokenSecretName}
+	if err := s.kube.Get(ctx, n, secret); err != nil {
+		if kerrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "cannot get token secret %s", n)
+	}
+
+	want := secret.Data[TokenKey]
+	if len(want) == 0 {
+		return false, nil
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(h, "Bearer ")), want) == 1, nil
+}
+
+func (s *Server) create(ctx context.Context, w http.ResponseWriter, r *http.Request, namespace string, cm resource.Claim) {
+	req := &CreateRequest{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(req); err != nil {
+		writeError(w, http.StatusBadRequest, errors.Wrap(err, "cannot decode request"))
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, errors.New("name is required"))
+		return
+	}
+
+	if err := s.checkAnnotations(req.Annotations); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	class, code, err := s.classReference(ctx, namespace, req.ClassReference)
+	if err != nil {
+		writeError(w, code, err)
+		return
+	}
+
+	cm.SetNamespace(namespace)
+	cm.SetName(req.Name)
+	cm.SetLabels(req.Labels)
+	cm.SetAnnotations(req.Annotations)
+	cm.SetClassReference(class)
+	cm.SetWriteConnectionSecretToReference(corev1.LocalObjectReference{Name: req.Name})
+
+	switch c := cm.(type) {
+	case *databasev1alpha1.PostgreSQLInstance:
+		c.Spec.EngineVersion = req.EngineVersion
+	case *databasev1alpha1.MySQLInstance:
+		c.Spec.EngineVersion = req.EngineVersion
+	default:
+		if req.EngineVersion != "" {
+			writeError(w, http.StatusBadRequest, errors.New("engineVersion is only supported by database instances"))
+			return
+		}
+	}
+
+	if err := s.kube.Create(ctx, cm); err != nil {
+		writeError(w, statusFor(err), errors.Wrapf(err, "cannot create claim %s/%s", namespace, req.Name))
+		return
+	}
+	writeJSON(w, http.StatusCreated, claimFor(cm))
+}
+
+// checkAnnotations returns an error unless every supplied annotation is
+// allowed by the Options.
+func (s *Server) checkAnnotations(a map[string]string) error {
+	allowed := make(map[string]bool, len(s.o.AllowedAnnotations))
+	for _, k := range s.o.AllowedAnnotations {
+		allowed[k] = true
+	}
+	for k := range a {
+		if !allowed[k] {
+			return errors.Errorf("annotation %s is not allowed", k)
+		}
+	}
+	return nil
+}
+
+// classReference returns a reference to the resource class referenced by the
+// supplied reference, or nil if it is nil. Claims may only use the resource
+// classes of their own namespace, just as they may only be scheduled to them.
+// It returns the HTTP status code of the error, if any.
+func (s *Server) classReference(ctx context.Context, namespace string, ref *corev1.ObjectReference) (*corev1.ObjectReference, int, error) {
+	if ref == nil {
+		return nil, 0, nil
+	}
+	if ref.Namespace != "" && ref.Namespace != namespace {
+		return nil, http.StatusForbidden, errors.Errorf("claims in namespace %s may only use the resource classes of that namespace", namespace)
+	}
+
+	cs := &corev1alpha1.ResourceClass{}
+	n := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+	if err := s.kube.Get(ctx, n, cs); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, http.StatusBadRequest, errors.Errorf("resource class %s does not exist", n)
+		}
+		return nil, statusFor(err), errors.Wrapf(err, "cannot get resource class %s", n)
+	}
+	return meta.ReferenceTo(cs, corev1alpha1.ResourceClassGroupVersionKind), 0, nil
+}
+
+func (s *Server) get(ctx context.Context, w http.ResponseWriter, n types.NamespacedName, cm resource.Claim) {
+	if err := s.kube.Get(ctx, n, cm); err != nil {
+		writeError(w, statusFor(err), errors.Wrapf(err, "cannot get claim %s", n))
+		return
+	}
+	writeJSON(w, http.StatusOK, claimFor(cm))
+}
+
+func (s *Server) delete(ctx context.Context, w http.ResponseWriter, n types.NamespacedName, cm resource.Claim) {
+	cm.SetNamespace(n.Namespace)
+	cm.SetName(n.Name)
+	if err := s.kube.Delete(ctx, cm); err != nil {
+		writeError(w, statusFor(err), errors.Wrapf(err, "cannot delete claim %s", n))
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// connection returns the connection details of a ready claim.
+func (s *Server) connection(ctx context.Context, w http.ResponseWriter, n types.NamespacedName, cm resource.Claim) {
+	if err := s.kube.Get(ctx, n, cm); err != nil {
+		writeError(w, statusFor(err), errors.Wrapf(err, "cannot get claim %s", n))
+		return
+	}
+	if cm.GetCondition(corev1alpha1.TypeReady).Status != corev1.ConditionTrue {
+		writeError(w, http.StatusConflict, errors.Errorf("claim %s is not yet ready", n))
+		return
+	}
+
+	secret := &corev1.Secret{}
+	sn := types.NamespacedName{Namespace: n.Namespace, Name: cm.GetWriteConnectionSecretToReference().Name}
+	if err := s.kube.Get(ctx, sn, secret); err != nil {
+		writeError(w, statusFor(err), errors.Wrapf(err, "cannot get connection secret %s", sn))
+		return
+	}
+
+	details := make(map[string]string, len(secret.Data))
+	for k, v := range secret.Data {
+		details[k] = string(v)
+	}
+	writeJSON(w, http.StatusOK, details)
+}
+
+func claimFor(cm resource.Claim) *Claim {
+	ready := cm.GetCondition(corev1alpha1.TypeReady)
+	return &Claim{
+		Namespace:    cm.GetNamespace(),
+		Name:         cm.GetName(),
+		BindingPhase: string(cm.GetBindingPhase()),
+		Ready:        ready.Status == corev1.ConditionTrue,
+		Reason:       string(ready.Reason),
+		Message:      ready.Message,
+	}
+}
+
+// statusFor returns the HTTP status code corresponding to the supplied
+// Kubernetes API error.
+func statusFor(err error) int {
+	if s, ok := err.(kerrors.APIStatus); ok {
+		if code := s.Status().Code; code != 0 {
+			return int(code)
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Info("cannot write response", "error", err.Error())
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, &metav1.Status{Status: metav1.StatusFailure, Code: int32(code), Message: err.Error()})
+}
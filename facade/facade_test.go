@@ -0,0 +1,332 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package facade
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	databasev1alpha1 "github.com/crossplaneio/crossplane/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/database"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace = "cool-namespace"
+	name      = "cool-claim"
+	className = "cool-class"
+	token     = "cool-token"
+)
+
+var errBoom = errors.New("boom")
+
+func mustJSON(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+// withToken returns a copy of the supplied client that also returns the token
+// secret of the test namespace.
+func withToken(kube *test.MockClient) *test.MockClient {
+	mc := &test.MockClient{}
+	if kube != nil {
+		*mc = *kube
+	}
+	get := mc.MockGet
+	mc.MockGet = func(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+		if s, ok := obj.(*corev1.Secret); ok && key.Name == DefaultTokenSecretName {
+			if key.Namespace != namespace {
+				return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+			}
+			s.Data = map[string][]byte{TokenKey: []byte(token)}
+			return nil
+		}
+		if get == nil {
+			return nil
+		}
+		return get(ctx, key, obj)
+	}
+	return mc
+}
+
+func TestServeHTTP(t *testing.T) {
+	type want struct {
+		code int
+		body string
+	}
+
+	readyClaim := func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+		switch o := obj.(type) {
+		case *databasev1alpha1.PostgreSQLInstance:
+			o.SetNamespace(namespace)
+			o.SetName(name)
+			o.SetWriteConnectionSecretToReference(corev1.LocalObjectReference{Name: name})
+			o.SetBindingPhase(corev1alpha1.BindingPhaseBound)
+			o.SetConditions(corev1alpha1.Available())
+		case *corev1.Secret:
+			o.Data = map[string][]byte{"endpoint": []byte("10.0.0.1")}
+		}
+		return nil
+	}
+
+	cases := map[string]struct {
+		kube   *test.MockClient
+		method string
+		path   string
+		token  string
+		body   string
+		want   want
+	}{
+		"Unauthenticated": {
+			method: http.MethodGet,
+			path:   "/v1/namespaces/cool-namespace/postgresqlinstances/cool-claim",
+			token:  "wrong-token",
+			want:   want{code: http.StatusUnauthorized},
+		},
+		"UnauthenticatedOtherNamespace": {
+			method: http.MethodGet,
+			path:   "/v1/namespaces/other-namespace/postgresqlinstances/cool-claim",
+			token:  token,
+			want:   want{code: http.StatusUnauthorized},
+		},
+		"UnknownKind": {
+			method: http.MethodGet,
+			path:   "/v1/namespaces/cool-namespace/spannerinstances/cool-claim",
+			token:  token,
+			want:   want{code: http.StatusNotFound},
+		},
+		"Create": {
+			kube: &test.MockClient{
+				MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+					pg := obj.(*databasev1alpha1.PostgreSQLInstance)
+					if pg.Spec.EngineVersion != "11" || pg.GetWriteConnectionSecretToReference().Name != name {
+						t.Errorf("Create(...): unexpected claim %+v", pg)
+					}
+					return nil
+				},
+			},
+			method: http.MethodPost,
+			path:   "/v1/namespaces/cool-namespace/postgresqlinstances",
+			token:  token,
+			body:   `{"name": "cool-claim", "engineVersion": "11"}`,
+			want: want{
+				code: http.StatusCreated,
+				body: `{"namespace":"cool-namespace","name":"cool-claim","ready":false}`,
+			},
+		},
+		"CreateAllowedAnnotation": {
+			kube: &test.MockClient{
+				MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+					pg := obj.(*databasev1alpha1.PostgreSQLInstance)
+					if pg.GetAnnotations()[database.AnnotationClassSelector] != "tier=production" {
+						t.Errorf("Create(...): unexpected annotations %v", pg.GetAnnotations())
+					}
+					return nil
+				},
+			},
+			method: http.MethodPost,
+			path:   "/v1/namespaces/cool-namespace/postgresqlinstances",
+			token:  token,
+			body:   `{"name": "cool-claim", "annotations": {"database.gcp.crossplane.io/class-selector": "tier=production"}}`,
+			want:   want{code: http.StatusCreated},
+		},
+		"CreateAnnotationNotAllowed": {
+			method: http.MethodPost,
+			path:   "/v1/namespaces/cool-namespace/postgresqlinstances",
+			token:  token,
+			body:   `{"name": "cool-claim", "annotations": {"cloudsql.gcp.crossplane.io/override-deletion-protection": "true"}}`,
+			want:   want{code: http.StatusBadRequest},
+		},
+		"CreateWithClass": {
+			kube: &test.MockClient{
+				MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+					if key.Namespace != namespace || key.Name != className {
+						return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+					}
+					obj.(*corev1alpha1.ResourceClass).SetNamespace(key.Namespace)
+					obj.(*corev1alpha1.ResourceClass).SetName(key.Name)
+					return nil
+				},
+				MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+					ref := obj.(*databasev1alpha1.PostgreSQLInstance).GetClassReference()
+					if ref == nil || ref.Namespace != namespace || ref.Name != className {
+						t.Errorf("Create(...): unexpected class reference %+v", ref)
+					}
+					return nil
+				},
+			},
+			method: http.MethodPost,
+			path:   "/v1/namespaces/cool-namespace/postgresqlinstances",
+			token:  token,
+			body:   `{"name": "cool-claim", "classReference": {"name": "cool-class"}}`,
+			want:   want{code: http.StatusCreated},
+		},
+		"CreateWithClassOfOtherNamespace": {
+			method: http.MethodPost,
+			path:   "/v1/namespaces/cool-namespace/postgresqlinstances",
+			token:  token,
+			body:   `{"name": "cool-claim", "classReference": {"namespace": "other-namespace", "name": "cool-class"}}`,
+			want:   want{code: http.StatusForbidden},
+		},
+		"CreateWithClassNotFound": {
+			kube: &test.MockClient{
+				MockGet: func(_ context.Context, key client.ObjectKey, _ runtime.Object) error {
+					return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+				},
+			},
+			method: http.MethodPost,
+			path:   "/v1/namespaces/cool-namespace/postgresqlinstances",
+			token:  token,
+			body:   `{"name": "cool-claim", "classReference": {"name": "missing-class"}}`,
+			want:   want{code: http.StatusBadRequest},
+		},
+		"CreateEngineVersionUnsupported": {
+			method: http.MethodPost,
+			path:   "/v1/namespaces/cool-namespace/buckets",
+			token:  token,
+			body:   `{"name": "cool-claim", "engineVersion": "11"}`,
+			want:   want{code: http.StatusBadRequest},
+		},
+		"CreateAlreadyExists": {
+			kube: &test.MockClient{
+				MockCreate: func(_ context.Context, _ runtime.Object, _ ...client.CreateOption) error {
+					return kerrors.NewAlreadyExists(schema.GroupResource{}, name)
+				},
+			},
+			method: http.MethodPost,
+			path:   "/v1/namespaces/cool-namespace/mysqlinstances",
+			token:  token,
+			body:   `{"name": "cool-claim"}`,
+			want:   want{code: http.StatusConflict},
+		},
+		"Get": {
+			kube:   &test.MockClient{MockGet: readyClaim},
+			method: http.MethodGet,
+			path:   "/v1/namespaces/cool-namespace/postgresqlinstances/cool-claim",
+			token:  token,
+			want: want{
+				code: http.StatusOK,
+				body: mustJSON(&Claim{
+					Namespace:    namespace,
+					Name:         name,
+					BindingPhase: string(corev1alpha1.BindingPhaseBound),
+					Ready:        true,
+					Reason:       string(corev1alpha1.Available().Reason),
+				}),
+			},
+		},
+		"GetFailed": {
+			kube: &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, _ runtime.Object) error { return errBoom },
+			},
+			method: http.MethodGet,
+			path:   "/v1/namespaces/cool-namespace/postgresqlinstances/cool-claim",
+			token:  token,
+			want:   want{code: http.StatusInternalServerError},
+		},
+		"Connection": {
+			kube:   &test.MockClient{MockGet: readyClaim},
+			method: http.MethodGet,
+			path:   "/v1/namespaces/cool-namespace/postgresqlinstances/cool-claim/connection",
+			token:  token,
+			want: want{
+				code: http.StatusOK,
+				body: `{"endpoint":"10.0.0.1"}`,
+			},
+		},
+		"ConnectionNotReady": {
+			kube: &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, _ runtime.Object) error { return nil },
+			},
+			method: http.MethodGet,
+			path:   "/v1/namespaces/cool-namespace/postgresqlinstances/cool-claim/connection",
+			token:  token,
+			want:   want{code: http.StatusConflict},
+		},
+		"Delete": {
+			kube: &test.MockClient{
+				MockDelete: func(_ context.Context, _ runtime.Object, _ ...client.DeleteOption) error { return nil },
+			},
+			method: http.MethodDelete,
+			path:   "/v1/namespaces/cool-namespace/buckets/cool-claim",
+			token:  token,
+			want:   want{code: http.StatusAccepted},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := NewServer(withToken(tc.kube), Options{})
+
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+
+			if rec.Code != tc.want.code {
+				t.Errorf("s.ServeHTTP(...): want status %d, got %d: %s", tc.want.code, rec.Code, rec.Body.String())
+			}
+			if tc.want.body == "" {
+				return
+			}
+			if diff := cmp.Diff(tc.want.body, strings.TrimSpace(rec.Body.String())); diff != "" {
+				t.Errorf("s.ServeHTTP(...): -want body, +got body:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestStartWithoutTLS(t *testing.T) {
+	s := NewServer(&test.MockClient{}, Options{Address: ":0"})
+	if err := s.Start(make(chan struct{})); err == nil {
+		t.Errorf("s.Start(...): want error serving without TLS, got nil")
+	}
+}
+
+func TestStatusFor(t *testing.T) {
+	cases := map[string]struct {
+		err  error
+		want int
+	}{
+		"NotFound": {err: kerrors.NewNotFound(schema.GroupResource{}, name), want: http.StatusNotFound},
+		"Unknown":  {err: errBoom, want: http.StatusInternalServerError},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := statusFor(tc.err); got != tc.want {
+				t.Errorf("statusFor(...): want %d, got %d", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package faultinject wraps the HTTP transport of GCP API clients such that
+// their calls fail in a programmed sequence - for example with 429, 500, or
+// timeout errors - so that the backoff, requeue, and condition setting of
+// controllers can be tested deterministically. Faults may be programmed by
+// tests or, for end-to-end tests, by environment variable.
+package faultinject
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+)
+
+// EnvFaults is the environment variable from which FromEnvironment reads a
+// fault sequence, e.g. 429,429,ok,timeout,500.
+const EnvFaults = "CROSSPLANE_GCP_INJECT_FAULTS"
+
+// EnvRepeat is the environment variable that, if true, causes a fault
+// sequence read by FromEnvironment to repeat once it is exhausted.
+const EnvRepeat = "CROSSPLANE_GCP_INJECT_FAULTS_REPEAT"
+
+// A Fault is injected into a single GCP API call. It is either an HTTP status
+// code, e.g. 429, or one of the faults below.
+type Fault string
+
+// Faults that are not HTTP status codes.
+const (
+	// FaultNone passes the call through to the GCP API.
+	FaultNone Fault = "ok"
+
+	// FaultTimeout fails the call immediately with a timeout error, as if
+	// the GCP API had not responded in time.
+	FaultTimeout Fault = "timeout"
+)
+
+// Faults commonly returned by the GCP API.
+const (
+	FaultTooManyRequests     Fault = "429"
+	FaultInternalServerError Fault = "500"
+	FaultServiceUnavailable  Fault = "503"
+)
+
+// reasons are the reasons the GCP API gives for common errors.
+var reasons = map[int]string{
+	http.StatusTooManyRequests:     "rateLimitExceeded",
+	http.StatusInternalServerError: "backendError",
+	http.StatusServiceUnavailable:  "backendError",
+}
+
+// reasonInjected is the reason given for injected errors that the GCP API
+// does not commonly return.
+const reasonInjected = "injectedFault"
+
+// Options configure an Injector.
+type Options struct {
+	// Faults are injected into successive calls, one per call. Calls pass
+	// through to the GCP API once the faults are exhausted.
+	Faults []Fault
+
+	// Repeat the faults once they are exhausted, rather than passing calls
+	// through.
+	Repeat bool
+}
+
+// An Injector injects a sequence of faults into the calls made using the
+// HTTP transports it wraps. Transports wrapped by the same Injector share its
+// sequence. It is safe for concurrent use.
+type Injector struct {
+	mu       sync.Mutex
+	o        Options
+	next     int
+	injected int
+}
+
+// New returns an Injector that injects faults as configured by the supplied
+// options.
+func New(o Options) *Injector {
+	return &Injector{o: o}
+}
+
+// FromEnvironment returns an Injector configured by environment variable, or
+// nil if no faults are configured.
+func FromEnvironment() (*Injector, error) {
+	spec, ok := os.LookupEnv(EnvFaults)
+	if !ok || spec == "" {
+		return nil, nil
+	}
+	faults, err := Parse(spec)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse %s", EnvFaults)
+	}
+	repeat, _ := strconv.ParseBool(os.Getenv(EnvRepeat))
+	return New(Options{Faults: faults, Repeat: repeat}), nil
+}
+
+// Parse the supplied comma separated sequence of faults, e.g.
+// 429,ok,timeout. Each fault is an HTTP status code of at least 400, ok, or
+// timeout.
+func Parse(spec string) ([]Fault, error) {
+	fields := strings.Split(spec, ",")
+	faults := make([]Fault, 0, len(fields))
+	for _, s := range fields {
+		f := Fault(strings.ToLower(strings.TrimSpace(s)))
+		if f != FaultNone && f != FaultTimeout {
+			if code, err := strconv.Atoi(string(f)); err != nil || code < 400 || code > 599 {
+				return nil, errors.Errorf("invalid fault %q", s)
+			}
+		}
+		faults = append(faults, f)
+	}
+	return faults, nil
+}
+
+// Injected returns the number of faults that have been injected, not
+// counting calls that were passed through.
+func (i *Injector) Injected() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.injected
+}
+
+// Wrap the supplied transport such that faults are injected into its calls.
+// A nil Injector returns the supplied transport, as does a nil transport
+// after it defaults to http.DefaultTransport.
+func (i *Injector) Wrap(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if i == nil {
+		return base
+	}
+	return &transport{base: base, injector: i}
+}
+
+// WrapClient returns a copy of the supplied HTTP client whose transport
+// injects faults. The copy is authenticated if the supplied client is.
+func (i *Injector) WrapClient(c *http.Client) *http.Client {
+	if i == nil {
+		return c
+	}
+	wrapped := *c
+	wrapped.Transport = i.Wrap(c.Transport)
+	return &wrapped
+}
+
+// fault returns the fault to inject into the next call.
+func (i *Injector) fault() Fault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.next >= len(i.o.Faults) {
+		if !i.o.Repeat || len(i.o.Faults) == 0 {
+			return FaultNone
+		}
+		i.next = 0
+	}
+	f := i.o.Faults[i.next]
+	i.next++
+	if f != FaultNone {
+		i.injected++
+	}
+	return f
+}
+
+type transport struct {
+	base     http.RoundTripper
+	injector *Injector
+}
+
+// RoundTrip injects the next fault into the supplied request, or passes it
+// through to the underlying transport.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f := t.injector.fault()
+	if f == FaultNone {
+		return t.base.RoundTrip(req)
+	}
+
+	// A RoundTripper must always close the request body.
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	if f == FaultTimeout {
+		return nil, timeoutError{}
+	}
+
+	// Parse guarantees the status code is valid.
+	code, _ := strconv.Atoi(string(f))
+	return errorResponse(req, code), nil
+}
+
+// Error returns the error the GCP API client reports when the supplied HTTP
+// status code is injected.
+func Error(code int) *googleapi.Error {
+	reason, ok := reasons[code]
+	if !ok {
+		reason = reasonInjected
+	}
+	msg := "Injected fault: " + http.StatusText(code)
+	return &googleapi.Error{
+		Code:    code,
+		Message: msg,
+		Errors:  []googleapi.ErrorItem{{Reason: reason, Message: msg}},
+	}
+}
+
+// errorResponse returns a response to the supplied request with the supplied
+// status code, and a body in the form of a GCP API error.
+func errorResponse(req *http.Request, code int) *http.Response {
+	body, _ := json.Marshal(struct {
+		Error *googleapi.Error `json:"error"`
+	}{Error: Error(code)})
+	return &http.Response{
+		Status:        strconv.Itoa(code) + " " + http.StatusText(code),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json; charset=UTF-8"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// timeoutError is a net.Error that reports a timeout.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "injected fault: timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,162 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package faultinject
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]struct {
+		spec    string
+		want    []Fault
+		wantErr error
+	}{
+		"Sequence": {
+			spec: "429, OK,timeout,500",
+			want: []Fault{FaultTooManyRequests, FaultNone, FaultTimeout, FaultInternalServerError},
+		},
+		"NotAnError": {
+			spec:    "429,200",
+			wantErr: errors.New(`invalid fault "200"`),
+		},
+		"Unknown": {
+			spec:    "slow",
+			wantErr: errors.New(`invalid fault "slow"`),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := Parse(tc.spec)
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("Parse(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Parse(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+// outcome describes the result of a call made through an Injector.
+type outcome struct {
+	Code    int
+	Err     string
+	Timeout bool
+}
+
+func call(t *testing.T, c *http.Client, url string) outcome {
+	t.Helper()
+	res, err := c.Get(url)
+	if err != nil {
+		ne, ok := errors.Cause(err).(net.Error)
+		return outcome{Timeout: ok && ne.Timeout()}
+	}
+	defer res.Body.Close()
+	o := outcome{Code: res.StatusCode}
+	if err := googleapi.CheckResponse(res); err != nil {
+		o.Err = err.Error()
+	}
+	return o
+}
+
+func TestInjector(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	defer srv.Close()
+
+	cases := map[string]struct {
+		o            Options
+		calls        int
+		want         []outcome
+		wantInjected int
+	}{
+		"Sequence": {
+			o:     Options{Faults: []Fault{FaultTooManyRequests, FaultNone, FaultTimeout}},
+			calls: 4,
+			want: []outcome{
+				{Code: http.StatusTooManyRequests, Err: Error(http.StatusTooManyRequests).Error()},
+				{Code: http.StatusOK},
+				{Timeout: true},
+				{Code: http.StatusOK},
+			},
+			wantInjected: 2,
+		},
+		"Repeat": {
+			o:     Options{Faults: []Fault{FaultServiceUnavailable, FaultNone}, Repeat: true},
+			calls: 3,
+			want: []outcome{
+				{Code: http.StatusServiceUnavailable, Err: Error(http.StatusServiceUnavailable).Error()},
+				{Code: http.StatusOK},
+				{Code: http.StatusServiceUnavailable, Err: Error(http.StatusServiceUnavailable).Error()},
+			},
+			wantInjected: 2,
+		},
+		"UncommonStatus": {
+			o:     Options{Faults: []Fault{"409"}},
+			calls: 1,
+			want: []outcome{
+				{Code: http.StatusConflict, Err: "googleapi: Error 409: Injected fault: Conflict, injectedFault"},
+			},
+			wantInjected: 1,
+		},
+		"NoFaults": {
+			o:     Options{Repeat: true},
+			calls: 1,
+			want:  []outcome{{Code: http.StatusOK}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			i := New(tc.o)
+			c := i.WrapClient(srv.Client())
+
+			got := make([]outcome, 0, tc.calls)
+			for n := 0; n < tc.calls; n++ {
+				got = append(got, call(t, c, srv.URL))
+			}
+
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("calls: -want, +got:\n%s", diff)
+			}
+			if got := i.Injected(); got != tc.wantInjected {
+				t.Errorf("i.Injected(): want %d, got %d", tc.wantInjected, got)
+			}
+		})
+	}
+}
+
+func TestNilInjector(t *testing.T) {
+	var i *Injector
+	c := &http.Client{}
+	if got := i.WrapClient(c); got != c {
+		t.Errorf("i.WrapClient(...): want unwrapped client")
+	}
+	if got := i.Wrap(nil); got != http.DefaultTransport {
+		t.Errorf("i.Wrap(nil): want http.DefaultTransport")
+	}
+}
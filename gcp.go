@@ -17,24 +17,178 @@ limitations under the License.
 package gcp
 
 import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/apigateway"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/apikeys"
 	"github.com/crossplaneio/crossplane/pkg/controller/gcp/cache"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/certificatemanager"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/claimmetrics"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/cloudtasks"
 	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compute"
 	"github.com/crossplaneio/crossplane/pkg/controller/gcp/database"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/dataflow"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/dataplex"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/defaults"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/eventarc"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/facade"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/faultinject"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/gkebackup"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/httpreplay"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/iam"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/logging"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/monitoring"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/pubsub"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/refindex"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/resourcemanager"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/secretgc"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/servicenetworking"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/shard"
 	"github.com/crossplaneio/crossplane/pkg/controller/gcp/storage"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/topology"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/tracing"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/vertexai"
 )
 
 // Controllers passes down config and adds individual controllers to the manager.
-type Controllers struct{}
+type Controllers struct {
+	// DefaultProvider is used by managed resources that don't reference a
+	// provider. It is typically set by flag.
+	DefaultProvider types.NamespacedName
+
+	// CloudSQLReconcileTimeout and CloudSQLAPICallTimeout bound each reconcile
+	// of a CloudsqlInstance, and each Cloud SQL API call it makes. Defaults
+	// are used if they are zero.
+	CloudSQLReconcileTimeout time.Duration
+	CloudSQLAPICallTimeout   time.Duration
+
+	// CloudSQLPooler configures the connection poolers CloudsqlInstances may
+	// request.
+	CloudSQLPooler database.PoolerConfig
+
+	// Facade configures an optional REST API through which consumers that are
+	// not Kubernetes native may provision resource claims. The API is not
+	// served if its address is empty.
+	Facade facade.Options
+
+	// Topology configures an optional endpoint that serves the dependency
+	// graph of managed resources, for debugging. It is not served if its
+	// address is empty.
+	Topology topology.Options
+
+	// ConnectionSecretGC configures an optional controller that deletes the
+	// connection secrets of managed resources that no longer exist.
+	ConnectionSecretGC secretgc.Options
+
+	// ConversionWebhooks enables the webhooks that convert resources between
+	// API versions. The manager's webhook server must be configured with a
+	// serving certificate trusted by the API server.
+	ConversionWebhooks bool
+
+	// ValidatingWebhooks enables the webhooks that reject resources with
+	// invalid specs, and invalid updates such as changes to immutable fields.
+	// Like the conversion webhooks, they require a trusted serving certificate.
+	ValidatingWebhooks bool
+
+	// Defaults configures an optional mutating webhook that fills the
+	// location, tier, and network of managed resources from the default
+	// policy of their namespace when they are omitted.
+	Defaults defaults.Options
+
+	// Tracing configures the export of OpenTelemetry spans recording the
+	// phases of each reconcile. Spans are not exported if its endpoint is
+	// empty.
+	Tracing tracing.Options
+
+	// FaultInjection injects faults into Cloud SQL API calls, if not nil, so
+	// that end-to-end tests may exercise backoff and requeue behaviour. It is
+	// typically read from the environment by faultinject.FromEnvironment, and
+	// must not be set in production.
+	FaultInjection *faultinject.Injector
+
+	// HTTPReplay records Cloud SQL API calls to, or replays them from, a
+	// fixture file, if not nil, so that the controller may be run offline
+	// against real API interactions. It is typically read from the
+	// environment by httpreplay.FromEnvironment, and must not be set in
+	// production.
+	HTTPReplay *httpreplay.Recorder
+
+	// Shard splits managed resources and resource claims among several
+	// provider deployments, by label or by a hash of their namespace. Each
+	// deployment must be configured with a distinct shard. Every resource is
+	// reconciled if it is not enabled.
+	Shard shard.Options
+}
 
 // SetupWithManager adds all GCP controllers to the manager.
 func (c *Controllers) SetupWithManager(mgr ctrl.Manager) error {
+	if c.Tracing.Endpoint != "" {
+		if err := tracing.Setup(mgr, c.Tracing); err != nil {
+			return err
+		}
+	}
+
+	if c.Shard.Enabled() {
+		sharded, err := shard.NewManager(mgr, c.Shard)
+		if err != nil {
+			return err
+		}
+		mgr = sharded
+	}
+
+	if err := refindex.Setup(mgr, topology.DefaultKinds); err != nil {
+		return err
+	}
+
+	if err := (&apigateway.APIController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&apigateway.APIConfigController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&apigateway.GatewayController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&apikeys.KeyController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
 	if err := (&cache.CloudMemorystoreInstanceClaimController{}).SetupWithManager(mgr); err != nil {
 		return err
 	}
 
-	if err := (&cache.CloudMemorystoreInstanceController{}).SetupWithManager(mgr); err != nil {
+	if err := (&cache.CloudMemorystoreInstanceController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&cache.MemcachedInstanceController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&cache.RedisClusterController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&certificatemanager.DNSAuthorizationController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&certificatemanager.CertificateController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&certificatemanager.CertificateMapController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&cloudtasks.QueueController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
 		return err
 	}
 
@@ -42,7 +196,51 @@ func (c *Controllers) SetupWithManager(mgr ctrl.Manager) error {
 		return err
 	}
 
-	if err := (&compute.GKEClusterController{}).SetupWithManager(mgr); err != nil {
+	if err := (&compute.GKEClusterController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&compute.SecurityPolicyController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&compute.NetworkPeeringController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&compute.IAPTunnelController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&compute.FleetFeatureController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&compute.SharedVPCHostProjectController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&compute.SharedVPCServiceProjectController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&compute.InstanceTemplateController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&compute.InstanceGroupManagerController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&compute.VpnGatewayController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&compute.ExternalVpnGatewayController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&compute.VpnTunnelController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
 		return err
 	}
 
@@ -54,7 +252,107 @@ func (c *Controllers) SetupWithManager(mgr ctrl.Manager) error {
 		return err
 	}
 
-	if err := (&database.CloudsqlController{}).SetupWithManager(mgr); err != nil {
+	if err := (&database.SQLServerInstanceClaimController{}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&database.CloudsqlController{
+		DefaultProvider:  c.DefaultProvider,
+		ReconcileTimeout: c.CloudSQLReconcileTimeout,
+		APICallTimeout:   c.CloudSQLAPICallTimeout,
+		TraceAPICalls:    c.Tracing.Endpoint != "",
+		Faults:           c.FaultInjection,
+		Replay:           c.HTTPReplay,
+		Pooler:           c.CloudSQLPooler,
+	}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&database.TemporaryUserController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&dataflow.JobController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&dataplex.LakeController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&dataplex.ZoneController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&dataplex.AssetController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&eventarc.TriggerController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&gkebackup.BackupPlanController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&gkebackup.RestorePlanController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&iam.WorkloadIdentityBindingController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&logging.LogBucketController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&logging.SinkController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&monitoring.NotificationChannelController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&monitoring.AlertPolicyController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&monitoring.UptimeCheckController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&monitoring.BaselineController{}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&pubsub.SubscriptionController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&resourcemanager.ProjectController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&resourcemanager.ProjectBillingInfoController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&resourcemanager.ServiceEnablementController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&resourcemanager.ProjectAuditConfigController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&resourcemanager.EssentialContactController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&servicenetworking.ConnectionController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
 		return err
 	}
 
@@ -62,9 +360,57 @@ func (c *Controllers) SetupWithManager(mgr ctrl.Manager) error {
 		return err
 	}
 
-	if err := (&storage.BucketController{}).SetupWithManager(mgr); err != nil {
+	if err := (&storage.BucketController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&storage.BucketPolicyMemberController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&storage.BucketNotificationController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
 		return err
 	}
 
+	if err := (&vertexai.NotebookInstanceController{DefaultProvider: c.DefaultProvider}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&claimmetrics.Controller{}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if c.ConnectionSecretGC.Enabled {
+		if err := (&secretgc.Controller{Options: c.ConnectionSecretGC}).SetupWithManager(mgr); err != nil {
+			return err
+		}
+	}
+
+	if c.ConversionWebhooks {
+		mgr.GetWebhookServer().Register(compute.GKEClusterConversionPath, &compute.GKEClusterConversionWebhook{})
+	}
+
+	if c.ValidatingWebhooks {
+		mgr.GetWebhookServer().Register(database.CloudsqlInstanceValidationPath, &database.CloudsqlInstanceValidationWebhook{})
+		mgr.GetWebhookServer().Register(compute.GKEClusterValidationPath, &compute.GKEClusterValidationWebhook{})
+		mgr.GetWebhookServer().Register(provider.PolicyWebhookPath, provider.NewPolicyWebhook(mgr.GetClient(), provider.Resolver{Default: c.DefaultProvider}))
+	}
+
+	if c.Defaults.Enabled {
+		mgr.GetWebhookServer().Register(defaults.Path, defaults.NewWebhook(mgr.GetClient(), c.Defaults))
+	}
+
+	if c.Facade.Address != "" {
+		if err := mgr.Add(facade.NewServer(mgr.GetClient(), c.Facade)); err != nil {
+			return err
+		}
+	}
+
+	if c.Topology.Address != "" {
+		if err := mgr.Add(topology.NewServer(mgr.GetClient(), c.Topology)); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
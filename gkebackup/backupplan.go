@@ -0,0 +1,369 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gkebackup
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	gkebackupv1 "google.golang.org/api/gkebackup/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/gkebackup/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/gkebackup"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	backupPlanControllerName = "backupplans.gkebackup.gcp.crossplane.io"
+	backupPlanFinalizer      = "finalizer." + backupPlanControllerName
+)
+
+var backupPlanLog = logging.Logger.WithName("controller." + backupPlanControllerName)
+
+// A backupPlanCreateSyncDeleter can create, sync, and delete backup plans in
+// an external store - e.g. the GCP API. Each method returns true if the backup
+// plan requires further reconciliation.
+type backupPlanCreateSyncDeleter interface {
+	Create(ctx context.Context, bp *v1alpha1.BackupPlan) (requeue bool)
+	Sync(ctx context.Context, bp *v1alpha1.BackupPlan) (requeue bool)
+	Delete(ctx context.Context, bp *v1alpha1.BackupPlan) (requeue bool)
+}
+
+// backupPlans is a backupPlanCreateSyncDeleter using the GCP Backup for GKE
+// API.
+type backupPlans struct {
+	client  gkebackup.Client
+	kube    client.Client
+	project string
+}
+
+// Create creates a backup plan for the referenced GKE cluster. The cluster
+// must have been created first.
+func (c *backupPlans) Create(ctx context.Context, bp *v1alpha1.BackupPlan) bool {
+	bp.Status.SetConditions(corev1alpha1.Creating())
+
+	if err := validateBackupPlan(bp.Spec.BackupPlanParameters); err != nil {
+		// Don't requeue invalid specs; they'll be reconciled again when updated.
+		bp.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return false
+	}
+
+	cluster, err := resolveCluster(ctx, c.kube, c.project, bp.GetNamespace(), bp.Spec.ClusterRef)
+	if err != nil {
+		bp.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	parent := parentName(c.project, bp.Spec.Location)
+	id := resourceID(bp)
+	desired := newBackupPlan(bp.Spec.BackupPlanParameters)
+	desired.Cluster = cluster
+
+	// Creation is asynchronous. We may have created the backup plan but
+	// failed to record its name.
+	if err := c.client.CreateBackupPlan(ctx, parent, id, desired); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		bp.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create backup plan")))
+		return true
+	}
+
+	bp.Status.BackupPlanName = parent + "/backupPlans/" + id
+	bp.Status.Cluster = cluster
+	meta.AddFinalizer(bp, backupPlanFinalizer)
+	bp.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync updates the backup plan if its schedule, retention, or backup
+// configuration differ from its spec, and reports its state.
+func (c *backupPlans) Sync(ctx context.Context, bp *v1alpha1.BackupPlan) bool {
+	actual, err := c.client.GetBackupPlan(ctx, bp.Status.BackupPlanName)
+	if err != nil {
+		bp.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+	bp.Status.State = actual.State
+	bp.Status.StateReason = actual.StateReason
+
+	if err := validateBackupPlan(bp.Spec.BackupPlanParameters); err != nil {
+		bp.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return false
+	}
+
+	desired := newBackupPlan(bp.Spec.BackupPlanParameters)
+	if mask := backupPlanUpdateMask(desired, actual); len(mask) > 0 {
+		if err := c.client.UpdateBackupPlan(ctx, bp.Status.BackupPlanName, desired, strings.Join(mask, ",")); err != nil {
+			bp.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot update backup plan")))
+			return true
+		}
+		bp.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	conditions, requeue := stateConditions("backup plan", actual.State, actual.StateReason)
+	bp.Status.SetConditions(conditions...)
+	return requeue
+}
+
+// Delete deletes the backup plan. Backup for GKE deletes the backups it made
+// along with it, unless they are locked against deletion.
+func (c *backupPlans) Delete(ctx context.Context, bp *v1alpha1.BackupPlan) bool {
+	bp.Status.SetConditions(corev1alpha1.Deleting())
+
+	if bp.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.client.DeleteBackupPlan(ctx, bp.Status.BackupPlanName); err != nil && !googleapi.IsErrorNotFound(err) {
+			bp.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete backup plan")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(bp, backupPlanFinalizer)
+	bp.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// validateBackupPlan returns an error if the supplied parameters encrypt
+// backups with a malformed KMS key, or lock backups against deletion for
+// longer than they are retained.
+func validateBackupPlan(p v1alpha1.BackupPlanParameters) error {
+	if p.EncryptionKey != "" && !kmsKeyName.MatchString(p.EncryptionKey) {
+		return errors.Errorf("encryption key %q must be of the form projects/*/locations/*/keyRings/*/cryptoKeys/*", p.EncryptionKey)
+	}
+	if p.RetainDays != 0 && p.DeleteLockDays > p.RetainDays {
+		return errors.Errorf("deleteLockDays (%d) must not exceed retainDays (%d)", p.DeleteLockDays, p.RetainDays)
+	}
+	return nil
+}
+
+// newBackupPlan returns the backup plan described by the supplied
+// parameters. A plan that selects no namespaces backs up all of them.
+func newBackupPlan(p v1alpha1.BackupPlanParameters) *gkebackupv1.BackupPlan {
+	bp := &gkebackupv1.BackupPlan{
+		Description: p.Description,
+		Labels:      p.Labels,
+		BackupSchedule: &gkebackupv1.Schedule{
+			CronSchedule: p.Schedule,
+			Paused:       p.Paused,
+			// Send false explicitly so that the schedule may be resumed.
+			ForceSendFields: []string{"Paused"},
+		},
+		RetentionPolicy: &gkebackupv1.RetentionPolicy{
+			BackupRetainDays:     p.RetainDays,
+			BackupDeleteLockDays: p.DeleteLockDays,
+		},
+		BackupConfig: &gkebackupv1.BackupConfig{
+			IncludeVolumeData: p.IncludeVolumeData,
+			IncludeSecrets:    p.IncludeSecrets,
+			ForceSendFields:   []string{"IncludeVolumeData", "IncludeSecrets"},
+		},
+	}
+
+	if len(p.Namespaces) == 0 {
+		bp.BackupConfig.AllNamespaces = true
+	} else {
+		ns := append([]string{}, p.Namespaces...)
+		sort.Strings(ns)
+		bp.BackupConfig.SelectedNamespaces = &gkebackupv1.Namespaces{Namespaces: ns}
+	}
+
+	if p.EncryptionKey != "" {
+		bp.BackupConfig.EncryptionKey = &gkebackupv1.EncryptionKey{GcpKmsEncryptionKey: p.EncryptionKey}
+	}
+	return bp
+}
+
+// backupPlanUpdateMask returns the paths of the fields of the actual backup
+// plan that differ from the desired backup plan. The cluster a plan backs up
+// can't be changed.
+func backupPlanUpdateMask(desired, actual *gkebackupv1.BackupPlan) []string {
+	mask := []string{}
+	if desired.Description != actual.Description {
+		mask = append(mask, "description")
+	}
+	if !compare.Equal(desired.Labels, actual.Labels) {
+		mask = append(mask, "labels")
+	}
+	if !scheduleEqual(desired.BackupSchedule, actual.BackupSchedule) {
+		mask = append(mask, "backupSchedule")
+	}
+	if !retentionEqual(desired.RetentionPolicy, actual.RetentionPolicy) {
+		mask = append(mask, "retentionPolicy")
+	}
+	if !backupConfigEqual(desired.BackupConfig, actual.BackupConfig) {
+		mask = append(mask, "backupConfig")
+	}
+	return mask
+}
+
+func scheduleEqual(desired, actual *gkebackupv1.Schedule) bool {
+	if actual == nil {
+		actual = &gkebackupv1.Schedule{}
+	}
+	return desired.CronSchedule == actual.CronSchedule && desired.Paused == actual.Paused
+}
+
+func retentionEqual(desired, actual *gkebackupv1.RetentionPolicy) bool {
+	if actual == nil {
+		actual = &gkebackupv1.RetentionPolicy{}
+	}
+	return desired.BackupRetainDays == actual.BackupRetainDays && desired.BackupDeleteLockDays == actual.BackupDeleteLockDays
+}
+
+func backupConfigEqual(desired, actual *gkebackupv1.BackupConfig) bool {
+	if actual == nil {
+		actual = &gkebackupv1.BackupConfig{}
+	}
+	if desired.AllNamespaces != actual.AllNamespaces ||
+		desired.IncludeVolumeData != actual.IncludeVolumeData ||
+		desired.IncludeSecrets != actual.IncludeSecrets {
+		return false
+	}
+	if encryptionKey(desired) != encryptionKey(actual) {
+		return false
+	}
+	return compare.Equal(selectedNamespaces(desired.SelectedNamespaces), selectedNamespaces(actual.SelectedNamespaces))
+}
+
+func encryptionKey(c *gkebackupv1.BackupConfig) string {
+	if c.EncryptionKey == nil {
+		return ""
+	}
+	return c.EncryptionKey.GcpKmsEncryptionKey
+}
+
+// A backupPlanConnecter returns a backupPlanCreateSyncDeleter that can create,
+// sync, and delete backup plans with an external store - for example the GCP
+// API.
+type backupPlanConnecter interface {
+	Connect(context.Context, *v1alpha1.BackupPlan) (backupPlanCreateSyncDeleter, error)
+}
+
+// backupPlanProviderConnecter is a backupPlanConnecter that returns a
+// backupPlanCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type backupPlanProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns a backupPlanCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// BackupPlan.
+func (c *backupPlanProviderConnecter) Connect(ctx context.Context, bp *v1alpha1.BackupPlan) (backupPlanCreateSyncDeleter, error) {
+	client, p, err := c.connect(ctx, bp, bp.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &backupPlans{client: client, kube: c.kube, project: p.Spec.ProjectID}, nil
+}
+
+// BackupPlanReconciler reconciles BackupPlans read from the Kubernetes API
+// with an external store, typically the GCP API.
+type BackupPlanReconciler struct {
+	backupPlanConnecter
+	kube client.Client
+}
+
+// BackupPlanController is responsible for adding the BackupPlan controller and
+// its corresponding reconciler to the manager with any runtime configuration.
+type BackupPlanController struct {
+	// DefaultProvider is used by backup plans that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new BackupPlan Controller and adds it to the
+// Manager with default RBAC. The Manager will set fields on the Controller and
+// start it when the Manager is Started.
+func (c *BackupPlanController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &BackupPlanReconciler{
+		backupPlanConnecter: &backupPlanProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: gkebackup.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(backupPlanControllerName).
+		For(&v1alpha1.BackupPlan{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listBackupPlans)).
+		Complete(r)
+}
+
+// Reconcile Backup for GKE backup plans with the GCP API.
+func (r *BackupPlanReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	backupPlanLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.BackupPlanKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	bp := &v1alpha1.BackupPlan{}
+	if err := r.kube.Get(ctx, req.NamespacedName, bp); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get backup plan %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, bp)
+	if err != nil {
+		bp.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, bp), "cannot update backup plan %s", req.NamespacedName)
+	}
+
+	// The backup plan has been deleted from the API server. Delete it from
+	// GCP.
+	if bp.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, bp)}, errors.Wrapf(r.kube.Update(ctx, bp), "cannot update backup plan %s", req.NamespacedName)
+	}
+
+	// The backup plan is unnamed. Assume it has not been created in GCP.
+	if bp.Status.BackupPlanName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, bp)}, errors.Wrapf(r.kube.Update(ctx, bp), "cannot update backup plan %s", req.NamespacedName)
+	}
+
+	// The backup plan exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, bp)}, errors.Wrapf(r.kube.Update(ctx, bp), "cannot update backup plan %s", req.NamespacedName)
+}
+
+// listBackupPlans is a provider.Lister of backup plans.
+func listBackupPlans(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.BackupPlanList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,519 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gkebackup
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	gkebackupv1 "google.golang.org/api/gkebackup/v1"
+	"google.golang.org/api/googleapi"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	computev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/gkebackup/v1alpha1"
+	fakegkebackup "github.com/crossplaneio/crossplane/pkg/clients/gcp/gkebackup/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace    = "cool-namespace"
+	name         = "cool-plan"
+	uid          = types.UID("definitely-a-uuid")
+	project      = "cool-project"
+	providerName = "cool-gcp"
+	location     = "us-central1"
+	zone         = "us-central1-a"
+	clusterRef   = "cool-cluster"
+	gkeName      = "cool-gke-cluster"
+	kmsKey       = "projects/cool-project/locations/us-central1/keyRings/cool-ring/cryptoKeys/cool-key"
+)
+
+var (
+	ctx            = context.Background()
+	errorBoom      = errors.New("boom")
+	errorNotFound  = &googleapi.Error{Code: http.StatusNotFound}
+	parent         = parentName(project, location)
+	clusterName    = "projects/" + project + "/locations/" + zone + "/clusters/" + gkeName
+	backupPlanName = parent + "/backupPlans/" + resourceIDPrefix + string(uid)
+)
+
+// Test that our Reconciler implementations satisfy the Reconciler interface.
+var (
+	_ reconcile.Reconciler = &BackupPlanReconciler{}
+	_ reconcile.Reconciler = &RestorePlanReconciler{}
+)
+
+// getCluster returns a MockGet that gets a GKE cluster with the supplied name.
+func getCluster(n string) func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+	return func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+		c := obj.(*computev1alpha1.GKECluster)
+		c.Spec.Zone = zone
+		c.Status.ClusterName = n
+		return nil
+	}
+}
+
+type backupPlanModifier func(*v1alpha1.BackupPlan)
+
+func withBackupPlanConditions(c ...corev1alpha1.Condition) backupPlanModifier {
+	return func(bp *v1alpha1.BackupPlan) { bp.Status.SetConditions(c...) }
+}
+
+func withBackupPlanFinalizers(f ...string) backupPlanModifier {
+	return func(bp *v1alpha1.BackupPlan) { bp.ObjectMeta.Finalizers = f }
+}
+
+func withBackupPlanReclaimPolicy(r corev1alpha1.ReclaimPolicy) backupPlanModifier {
+	return func(bp *v1alpha1.BackupPlan) { bp.Spec.ReclaimPolicy = r }
+}
+
+func withBackupPlanName(n string) backupPlanModifier {
+	return func(bp *v1alpha1.BackupPlan) { bp.Status.BackupPlanName = n }
+}
+
+func withBackupPlanCluster(c string) backupPlanModifier {
+	return func(bp *v1alpha1.BackupPlan) { bp.Status.Cluster = c }
+}
+
+func withBackupPlanState(s, reason string) backupPlanModifier {
+	return func(bp *v1alpha1.BackupPlan) {
+		bp.Status.State = s
+		bp.Status.StateReason = reason
+	}
+}
+
+func withEncryptionKey(k string) backupPlanModifier {
+	return func(bp *v1alpha1.BackupPlan) { bp.Spec.EncryptionKey = k }
+}
+
+func withRetention(retain, lock int64) backupPlanModifier {
+	return func(bp *v1alpha1.BackupPlan) {
+		bp.Spec.RetainDays = retain
+		bp.Spec.DeleteLockDays = lock
+	}
+}
+
+func backupPlan(bm ...backupPlanModifier) *v1alpha1.BackupPlan {
+	bp := &v1alpha1.BackupPlan{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.BackupPlanSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			BackupPlanParameters: v1alpha1.BackupPlanParameters{
+				Location:   location,
+				ClusterRef: corev1.LocalObjectReference{Name: clusterRef},
+				Schedule:   "0 3 * * *",
+				RetainDays: 30,
+			},
+		},
+	}
+
+	for _, m := range bm {
+		m(bp)
+	}
+
+	return bp
+}
+
+func TestBackupPlanCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         backupPlanCreateSyncDeleter
+		bp          *v1alpha1.BackupPlan
+		want        *v1alpha1.BackupPlan
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &backupPlans{
+				project: project,
+				kube:    &test.MockClient{MockGet: getCluster(gkeName)},
+				client: &fakegkebackup.MockClient{
+					MockCreateBackupPlan: func(_ context.Context, p, id string, bp *gkebackupv1.BackupPlan) error {
+						if p != parent {
+							t.Errorf("CreateBackupPlan(...): want parent %s, got %s", parent, p)
+						}
+						if bp.Cluster != clusterName {
+							t.Errorf("CreateBackupPlan(...): want cluster %s, got %s", clusterName, bp.Cluster)
+						}
+						if got := bp.BackupConfig.EncryptionKey.GcpKmsEncryptionKey; got != kmsKey {
+							t.Errorf("CreateBackupPlan(...): want encryption key %s, got %s", kmsKey, got)
+						}
+						return nil
+					},
+				},
+			},
+			bp: backupPlan(withEncryptionKey(kmsKey)),
+			want: backupPlan(
+				withEncryptionKey(kmsKey),
+				withBackupPlanFinalizers(backupPlanFinalizer),
+				withBackupPlanName(backupPlanName),
+				withBackupPlanCluster(clusterName),
+				withBackupPlanConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "ClusterNotCreated",
+			csd: &backupPlans{
+				project: project,
+				kube:    &test.MockClient{MockGet: getCluster("")},
+				client:  &fakegkebackup.MockClient{},
+			},
+			bp: backupPlan(),
+			want: backupPlan(
+				withBackupPlanConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Errorf("gke cluster %s/%s is not yet created", namespace, clusterRef))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "InvalidEncryptionKey",
+			csd:  &backupPlans{project: project, client: &fakegkebackup.MockClient{}},
+			bp:   backupPlan(withEncryptionKey("cool-key")),
+			want: backupPlan(
+				withEncryptionKey("cool-key"),
+				withBackupPlanConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.New(`encryption key "cool-key" must be of the form projects/*/locations/*/keyRings/*/cryptoKeys/*`))),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedCreate",
+			csd: &backupPlans{
+				project: project,
+				kube:    &test.MockClient{MockGet: getCluster(gkeName)},
+				client: &fakegkebackup.MockClient{
+					MockCreateBackupPlan: func(_ context.Context, _, _ string, _ *gkebackupv1.BackupPlan) error { return errorBoom },
+				},
+			},
+			bp: backupPlan(),
+			want: backupPlan(
+				withBackupPlanConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create backup plan"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.bp)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.bp, test.EquateConditions()); diff != "" {
+				t.Errorf("bp: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBackupPlanSync(t *testing.T) {
+	// upToDate returns the backup plan GCP reports for backupPlan().
+	upToDate := func() *gkebackupv1.BackupPlan {
+		bp := newBackupPlan(backupPlan().Spec.BackupPlanParameters)
+		bp.Cluster = clusterName
+		bp.State = stateReady
+		return bp
+	}
+
+	cases := []struct {
+		name        string
+		csd         backupPlanCreateSyncDeleter
+		bp          *v1alpha1.BackupPlan
+		want        *v1alpha1.BackupPlan
+		wantRequeue bool
+	}{
+		{
+			name: "Ready",
+			csd: &backupPlans{client: &fakegkebackup.MockClient{
+				MockGetBackupPlan: func(_ context.Context, _ string) (*gkebackupv1.BackupPlan, error) { return upToDate(), nil },
+			}},
+			bp: backupPlan(withBackupPlanName(backupPlanName)),
+			want: backupPlan(
+				withBackupPlanName(backupPlanName),
+				withBackupPlanState(stateReady, ""),
+				withBackupPlanConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "Failed",
+			csd: &backupPlans{client: &fakegkebackup.MockClient{
+				MockGetBackupPlan: func(_ context.Context, _ string) (*gkebackupv1.BackupPlan, error) {
+					bp := upToDate()
+					bp.State = stateFailed
+					bp.StateReason = "permission denied on key"
+					return bp, nil
+				},
+			}},
+			bp: backupPlan(withBackupPlanName(backupPlanName)),
+			want: backupPlan(
+				withBackupPlanName(backupPlanName),
+				withBackupPlanState(stateFailed, "permission denied on key"),
+				withBackupPlanConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(errors.New("backup plan failed: permission denied on key"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "RetentionChanged",
+			csd: &backupPlans{client: &fakegkebackup.MockClient{
+				MockGetBackupPlan: func(_ context.Context, _ string) (*gkebackupv1.BackupPlan, error) { return upToDate(), nil },
+				MockUpdateBackupPlan: func(_ context.Context, _ string, bp *gkebackupv1.BackupPlan, mask string) error {
+					if mask != "retentionPolicy" {
+						t.Errorf("UpdateBackupPlan(...): want mask retentionPolicy, got %s", mask)
+					}
+					if bp.RetentionPolicy.BackupRetainDays != 90 {
+						t.Errorf("UpdateBackupPlan(...): want 90 retain days, got %d", bp.RetentionPolicy.BackupRetainDays)
+					}
+					return nil
+				},
+			}},
+			bp: backupPlan(withBackupPlanName(backupPlanName), withRetention(90, 7)),
+			want: backupPlan(
+				withBackupPlanName(backupPlanName),
+				withRetention(90, 7),
+				withBackupPlanState(stateReady, ""),
+				withBackupPlanConditions(corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedUpdate",
+			csd: &backupPlans{client: &fakegkebackup.MockClient{
+				MockGetBackupPlan:    func(_ context.Context, _ string) (*gkebackupv1.BackupPlan, error) { return upToDate(), nil },
+				MockUpdateBackupPlan: func(_ context.Context, _ string, _ *gkebackupv1.BackupPlan, _ string) error { return errorBoom },
+			}},
+			bp: backupPlan(withBackupPlanName(backupPlanName), withEncryptionKey(kmsKey)),
+			want: backupPlan(
+				withBackupPlanName(backupPlanName),
+				withEncryptionKey(kmsKey),
+				withBackupPlanState(stateReady, ""),
+				withBackupPlanConditions(corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot update backup plan"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGet",
+			csd: &backupPlans{client: &fakegkebackup.MockClient{
+				MockGetBackupPlan: func(_ context.Context, _ string) (*gkebackupv1.BackupPlan, error) { return nil, errorBoom },
+			}},
+			bp: backupPlan(withBackupPlanName(backupPlanName)),
+			want: backupPlan(
+				withBackupPlanName(backupPlanName),
+				withBackupPlanConditions(corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.bp)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.bp, test.EquateConditions()); diff != "" {
+				t.Errorf("bp: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBackupPlanDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         backupPlanCreateSyncDeleter
+		bp          *v1alpha1.BackupPlan
+		want        *v1alpha1.BackupPlan
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimDeleteSuccessful",
+			csd: &backupPlans{client: &fakegkebackup.MockClient{
+				MockDeleteBackupPlan: func(_ context.Context, _ string) error { return nil },
+			}},
+			bp: backupPlan(
+				withBackupPlanName(backupPlanName),
+				withBackupPlanFinalizers(backupPlanFinalizer),
+				withBackupPlanReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: backupPlan(
+				withBackupPlanName(backupPlanName),
+				withBackupPlanReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withBackupPlanConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteNotFound",
+			csd: &backupPlans{client: &fakegkebackup.MockClient{
+				MockDeleteBackupPlan: func(_ context.Context, _ string) error { return errorNotFound },
+			}},
+			bp: backupPlan(
+				withBackupPlanName(backupPlanName),
+				withBackupPlanFinalizers(backupPlanFinalizer),
+				withBackupPlanReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: backupPlan(
+				withBackupPlanName(backupPlanName),
+				withBackupPlanReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withBackupPlanConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailed",
+			csd: &backupPlans{client: &fakegkebackup.MockClient{
+				MockDeleteBackupPlan: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			bp: backupPlan(
+				withBackupPlanName(backupPlanName),
+				withBackupPlanFinalizers(backupPlanFinalizer),
+				withBackupPlanReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: backupPlan(
+				withBackupPlanName(backupPlanName),
+				withBackupPlanFinalizers(backupPlanFinalizer),
+				withBackupPlanReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withBackupPlanConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot delete backup plan"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "ReclaimRetain",
+			csd:  &backupPlans{client: &fakegkebackup.MockClient{}},
+			bp: backupPlan(
+				withBackupPlanName(backupPlanName),
+				withBackupPlanFinalizers(backupPlanFinalizer),
+				withBackupPlanReclaimPolicy(corev1alpha1.ReclaimRetain),
+			),
+			want: backupPlan(
+				withBackupPlanName(backupPlanName),
+				withBackupPlanReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withBackupPlanConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.bp)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.bp, test.EquateConditions()); diff != "" {
+				t.Errorf("bp: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateBackupPlan(t *testing.T) {
+	cases := map[string]struct {
+		p    v1alpha1.BackupPlanParameters
+		want error
+	}{
+		"Valid": {
+			p:    v1alpha1.BackupPlanParameters{EncryptionKey: kmsKey, RetainDays: 30, DeleteLockDays: 7},
+			want: nil,
+		},
+		"UnlimitedRetention": {
+			p:    v1alpha1.BackupPlanParameters{DeleteLockDays: 7},
+			want: nil,
+		},
+		"LockExceedsRetention": {
+			p:    v1alpha1.BackupPlanParameters{RetainDays: 7, DeleteLockDays: 30},
+			want: errors.New("deleteLockDays (30) must not exceed retainDays (7)"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := validateBackupPlan(tc.p)
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("validateBackupPlan(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBackupPlanUpdateMask(t *testing.T) {
+	desired := newBackupPlan(v1alpha1.BackupPlanParameters{
+		Schedule:   "0 3 * * *",
+		Namespaces: []string{"b", "a"},
+	})
+
+	cases := map[string]struct {
+		actual *gkebackupv1.BackupPlan
+		want   []string
+	}{
+		"UpToDate": {
+			actual: &gkebackupv1.BackupPlan{
+				BackupSchedule:  &gkebackupv1.Schedule{CronSchedule: "0 3 * * *"},
+				RetentionPolicy: &gkebackupv1.RetentionPolicy{},
+				BackupConfig:    &gkebackupv1.BackupConfig{SelectedNamespaces: &gkebackupv1.Namespaces{Namespaces: []string{"a", "b"}}},
+			},
+			want: []string{},
+		},
+		"Paused": {
+			actual: &gkebackupv1.BackupPlan{
+				BackupSchedule:  &gkebackupv1.Schedule{CronSchedule: "0 3 * * *", Paused: true},
+				RetentionPolicy: &gkebackupv1.RetentionPolicy{},
+				BackupConfig:    &gkebackupv1.BackupConfig{SelectedNamespaces: &gkebackupv1.Namespaces{Namespaces: []string{"a", "b"}}},
+			},
+			want: []string{"backupSchedule"},
+		},
+		"AllNamespaces": {
+			actual: &gkebackupv1.BackupPlan{
+				BackupSchedule: &gkebackupv1.Schedule{CronSchedule: "0 3 * * *"},
+				BackupConfig:   &gkebackupv1.BackupConfig{AllNamespaces: true},
+			},
+			want: []string{"backupConfig"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := backupPlanUpdateMask(desired, tc.actual)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("backupPlanUpdateMask(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
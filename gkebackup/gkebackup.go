@@ -0,0 +1,141 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gkebackup contains controllers that back up the workloads of GKE
+// clusters using Backup for GKE backup plans, and that describe how those
+// backups are restored using restore plans.
+package gkebackup
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	gkebackupv1 "google.golang.org/api/gkebackup/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	computev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/gkebackup"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+)
+
+const (
+	reconcileTimeout = 1 * time.Minute
+
+	// resourceIDPrefix is prepended to the UID of a managed resource to form
+	// the ID of its Backup for GKE resource. IDs must begin with a letter.
+	resourceIDPrefix = "crossplane-"
+)
+
+// Backup and restore plan states. See
+// https://cloud.google.com/kubernetes-engine/docs/add-on/backup-for-gke/reference/rest/v1/projects.locations.backupPlans#State
+const (
+	stateReady       = "READY"
+	stateFailed      = "FAILED"
+	stateDeactivated = "DEACTIVATED"
+)
+
+// kmsKeyName matches a fully qualified Cloud KMS crypto key name.
+var kmsKeyName = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/keyRings/[^/]+/cryptoKeys/[^/]+$`)
+
+// providerConnecter returns Backup for GKE clients authenticated using
+// credentials read from a Crossplane Provider resource.
+type providerConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (gkebackup.Client, error)
+}
+
+// connect returns a Backup for GKE client authenticated using credentials
+// read from the Provider referenced by the supplied managed resource, and
+// that Provider.
+func (c *providerConnecter) connect(ctx context.Context, mg metav1.Object, ref *corev1.ObjectReference) (gkebackup.Client, *gcpv1alpha1.Provider, error) {
+	p, err := c.providers.Get(ctx, c.kube, mg, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceGKEBackup, gkebackupv1.CloudPlatformScope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return client, p, errors.Wrap(err, "cannot create new backup for gke client")
+}
+
+// resourceID returns the ID of the Backup for GKE resource of the supplied
+// managed resource.
+func resourceID(mg metav1.Object) string {
+	return resourceIDPrefix + string(mg.GetUID())
+}
+
+// parentName returns the fully qualified name of the supplied location within
+// the supplied project, e.g. projects/p/locations/us-central1.
+func parentName(project, location string) string {
+	return fmt.Sprintf("projects/%s/locations/%s", project, location)
+}
+
+// resolveCluster returns the fully qualified name of the GKE cluster
+// referenced by the supplied reference, which must be in the supplied
+// namespace and project. It returns an error if the cluster has not yet been
+// created.
+func resolveCluster(ctx context.Context, kube client.Client, project, namespace string, ref corev1.LocalObjectReference) (string, error) {
+	c := &computev1alpha1.GKECluster{}
+	n := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+	if err := kube.Get(ctx, n, c); err != nil {
+		return "", errors.Wrapf(err, "cannot get gke cluster %s", n)
+	}
+	if c.Status.ClusterName == "" {
+		return "", errors.Errorf("gke cluster %s is not yet created", n)
+	}
+	return fmt.Sprintf("projects/%s/locations/%s/clusters/%s", project, c.Spec.Zone, c.Status.ClusterName), nil
+}
+
+// stateConditions returns the conditions of a backup or restore plan in the
+// supplied state, and whether it requires further reconciliation.
+func stateConditions(kind, state, reason string) ([]corev1alpha1.Condition, bool) {
+	switch state {
+	case stateReady:
+		return []corev1alpha1.Condition{corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()}, false
+	case stateFailed:
+		return []corev1alpha1.Condition{corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(errors.Errorf("%s failed: %s", kind, reason))}, true
+	case stateDeactivated:
+		// Plans are deactivated deliberately, and can't be reactivated.
+		return []corev1alpha1.Condition{corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess()}, false
+	default:
+		return []corev1alpha1.Condition{corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()}, true
+	}
+}
+
+// selectedNamespaces returns the supplied namespaces, sorted.
+func selectedNamespaces(n *gkebackupv1.Namespaces) []string {
+	if n == nil {
+		return nil
+	}
+	ns := append([]string{}, n.Namespaces...)
+	sort.Strings(ns)
+	return ns
+}
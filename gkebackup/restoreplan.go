@@ -0,0 +1,322 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gkebackup
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	gkebackupv1 "google.golang.org/api/gkebackup/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/gkebackup/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/gkebackup"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	restorePlanControllerName = "restoreplans.gkebackup.gcp.crossplane.io"
+	restorePlanFinalizer      = "finalizer." + restorePlanControllerName
+)
+
+var restorePlanLog = logging.Logger.WithName("controller." + restorePlanControllerName)
+
+// A restorePlanCreateSyncDeleter can create, sync, and delete restore plans
+// in an external store - e.g. the GCP API. Each method returns true if the
+// restore plan requires further reconciliation.
+type restorePlanCreateSyncDeleter interface {
+	Create(ctx context.Context, rp *v1alpha1.RestorePlan) (requeue bool)
+	Sync(ctx context.Context, rp *v1alpha1.RestorePlan) (requeue bool)
+	Delete(ctx context.Context, rp *v1alpha1.RestorePlan) (requeue bool)
+}
+
+// restorePlans is a restorePlanCreateSyncDeleter using the GCP Backup for GKE
+// API.
+type restorePlans struct {
+	client  gkebackup.Client
+	kube    client.Client
+	project string
+}
+
+// Create creates a restore plan that restores backups made by the referenced
+// backup plan to the referenced GKE cluster. Both must have been created
+// first.
+func (c *restorePlans) Create(ctx context.Context, rp *v1alpha1.RestorePlan) bool {
+	rp.Status.SetConditions(corev1alpha1.Creating())
+
+	backupPlan, err := resolveBackupPlan(ctx, c.kube, rp.GetNamespace(), rp.Spec.BackupPlanRef)
+	if err != nil {
+		rp.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	cluster, err := resolveCluster(ctx, c.kube, c.project, rp.GetNamespace(), rp.Spec.ClusterRef)
+	if err != nil {
+		rp.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	parent := parentName(c.project, rp.Spec.Location)
+	id := resourceID(rp)
+	desired := newRestorePlan(rp.Spec.RestorePlanParameters)
+	desired.BackupPlan = backupPlan
+	desired.Cluster = cluster
+
+	// Creation is asynchronous. We may have created the restore plan but
+	// failed to record its name.
+	if err := c.client.CreateRestorePlan(ctx, parent, id, desired); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		rp.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create restore plan")))
+		return true
+	}
+
+	rp.Status.RestorePlanName = parent + "/restorePlans/" + id
+	meta.AddFinalizer(rp, restorePlanFinalizer)
+	rp.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync updates the restore plan if its restore configuration differs from its
+// spec, and reports its state.
+func (c *restorePlans) Sync(ctx context.Context, rp *v1alpha1.RestorePlan) bool {
+	actual, err := c.client.GetRestorePlan(ctx, rp.Status.RestorePlanName)
+	if err != nil {
+		rp.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+	rp.Status.State = actual.State
+	rp.Status.StateReason = actual.StateReason
+
+	desired := newRestorePlan(rp.Spec.RestorePlanParameters)
+	if mask := restorePlanUpdateMask(desired, actual); len(mask) > 0 {
+		if err := c.client.UpdateRestorePlan(ctx, rp.Status.RestorePlanName, desired, strings.Join(mask, ",")); err != nil {
+			rp.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot update restore plan")))
+			return true
+		}
+		rp.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	conditions, requeue := stateConditions("restore plan", actual.State, actual.StateReason)
+	rp.Status.SetConditions(conditions...)
+	return requeue
+}
+
+// Delete deletes the restore plan.
+func (c *restorePlans) Delete(ctx context.Context, rp *v1alpha1.RestorePlan) bool {
+	rp.Status.SetConditions(corev1alpha1.Deleting())
+
+	if rp.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.client.DeleteRestorePlan(ctx, rp.Status.RestorePlanName); err != nil && !googleapi.IsErrorNotFound(err) {
+			rp.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete restore plan")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(rp, restorePlanFinalizer)
+	rp.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// resolveBackupPlan returns the fully qualified name of the backup plan
+// referenced by the supplied reference, which must be in the supplied
+// namespace. It returns an error if the backup plan has not yet been created.
+func resolveBackupPlan(ctx context.Context, kube client.Client, namespace string, ref corev1.LocalObjectReference) (string, error) {
+	bp := &v1alpha1.BackupPlan{}
+	n := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+	if err := kube.Get(ctx, n, bp); err != nil {
+		return "", errors.Wrapf(err, "cannot get backup plan %s", n)
+	}
+	if bp.Status.BackupPlanName == "" {
+		return "", errors.Errorf("backup plan %s is not yet created", n)
+	}
+	return bp.Status.BackupPlanName, nil
+}
+
+// newRestorePlan returns the restore plan described by the supplied
+// parameters. A plan that selects no namespaces restores all of them.
+func newRestorePlan(p v1alpha1.RestorePlanParameters) *gkebackupv1.RestorePlan {
+	rp := &gkebackupv1.RestorePlan{
+		Description: p.Description,
+		Labels:      p.Labels,
+		RestoreConfig: &gkebackupv1.RestoreConfig{
+			VolumeDataRestorePolicy:       p.VolumeDataRestorePolicy,
+			NamespacedResourceRestoreMode: p.NamespacedResourceRestoreMode,
+		},
+	}
+
+	if len(p.Namespaces) == 0 {
+		rp.RestoreConfig.AllNamespaces = true
+	} else {
+		ns := append([]string{}, p.Namespaces...)
+		sort.Strings(ns)
+		rp.RestoreConfig.SelectedNamespaces = &gkebackupv1.Namespaces{Namespaces: ns}
+	}
+	return rp
+}
+
+// restorePlanUpdateMask returns the paths of the fields of the actual restore
+// plan that differ from the desired restore plan. The backup plan and cluster
+// of a restore plan can't be changed.
+func restorePlanUpdateMask(desired, actual *gkebackupv1.RestorePlan) []string {
+	mask := []string{}
+	if desired.Description != actual.Description {
+		mask = append(mask, "description")
+	}
+	if !compare.Equal(desired.Labels, actual.Labels) {
+		mask = append(mask, "labels")
+	}
+	if !restoreConfigEqual(desired.RestoreConfig, actual.RestoreConfig) {
+		mask = append(mask, "restoreConfig")
+	}
+	return mask
+}
+
+func restoreConfigEqual(desired, actual *gkebackupv1.RestoreConfig) bool {
+	if actual == nil {
+		actual = &gkebackupv1.RestoreConfig{}
+	}
+	if desired.AllNamespaces != actual.AllNamespaces ||
+		desired.VolumeDataRestorePolicy != actual.VolumeDataRestorePolicy ||
+		desired.NamespacedResourceRestoreMode != actual.NamespacedResourceRestoreMode {
+		return false
+	}
+	return compare.Equal(selectedNamespaces(desired.SelectedNamespaces), selectedNamespaces(actual.SelectedNamespaces))
+}
+
+// A restorePlanConnecter returns a restorePlanCreateSyncDeleter that can
+// create, sync, and delete restore plans with an external store - for example
+// the GCP API.
+type restorePlanConnecter interface {
+	Connect(context.Context, *v1alpha1.RestorePlan) (restorePlanCreateSyncDeleter, error)
+}
+
+// restorePlanProviderConnecter is a restorePlanConnecter that returns a
+// restorePlanCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type restorePlanProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns a restorePlanCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// RestorePlan.
+func (c *restorePlanProviderConnecter) Connect(ctx context.Context, rp *v1alpha1.RestorePlan) (restorePlanCreateSyncDeleter, error) {
+	client, p, err := c.connect(ctx, rp, rp.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &restorePlans{client: client, kube: c.kube, project: p.Spec.ProjectID}, nil
+}
+
+// RestorePlanReconciler reconciles RestorePlans read from the Kubernetes API
+// with an external store, typically the GCP API.
+type RestorePlanReconciler struct {
+	restorePlanConnecter
+	kube client.Client
+}
+
+// RestorePlanController is responsible for adding the RestorePlan controller
+// and its corresponding reconciler to the manager with any runtime
+// configuration.
+type RestorePlanController struct {
+	// DefaultProvider is used by restore plans that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new RestorePlan Controller and adds it to the
+// Manager with default RBAC. The Manager will set fields on the Controller and
+// start it when the Manager is Started.
+func (c *RestorePlanController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &RestorePlanReconciler{
+		restorePlanConnecter: &restorePlanProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: gkebackup.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(restorePlanControllerName).
+		For(&v1alpha1.RestorePlan{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listRestorePlans)).
+		Complete(r)
+}
+
+// Reconcile Backup for GKE restore plans with the GCP API.
+func (r *RestorePlanReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	restorePlanLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.RestorePlanKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	rp := &v1alpha1.RestorePlan{}
+	if err := r.kube.Get(ctx, req.NamespacedName, rp); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get restore plan %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, rp)
+	if err != nil {
+		rp.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, rp), "cannot update restore plan %s", req.NamespacedName)
+	}
+
+	// The restore plan has been deleted from the API server. Delete it from
+	// GCP.
+	if rp.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, rp)}, errors.Wrapf(r.kube.Update(ctx, rp), "cannot update restore plan %s", req.NamespacedName)
+	}
+
+	// The restore plan is unnamed. Assume it has not been created in GCP.
+	if rp.Status.RestorePlanName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, rp)}, errors.Wrapf(r.kube.Update(ctx, rp), "cannot update restore plan %s", req.NamespacedName)
+	}
+
+	// The restore plan exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, rp)}, errors.Wrapf(r.kube.Update(ctx, rp), "cannot update restore plan %s", req.NamespacedName)
+}
+
+// listRestorePlans is a provider.Lister of restore plans.
+func listRestorePlans(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.RestorePlanList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
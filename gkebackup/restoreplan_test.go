@@ -0,0 +1,347 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gkebackup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	gkebackupv1 "google.golang.org/api/gkebackup/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	computev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/gkebackup/v1alpha1"
+	fakegkebackup "github.com/crossplaneio/crossplane/pkg/clients/gcp/gkebackup/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const backupPlanRef = "cool-backup-plan"
+
+var restorePlanName = parent + "/restorePlans/" + resourceIDPrefix + string(uid)
+
+// getPlanAndCluster returns a MockGet that gets a backup plan with the
+// supplied name and a created GKE cluster.
+func getPlanAndCluster(n string) func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+	return func(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+		switch o := obj.(type) {
+		case *v1alpha1.BackupPlan:
+			o.Status.BackupPlanName = n
+		case *computev1alpha1.GKECluster:
+			return getCluster(gkeName)(ctx, key, o)
+		}
+		return nil
+	}
+}
+
+type restorePlanModifier func(*v1alpha1.RestorePlan)
+
+func withRestorePlanConditions(c ...corev1alpha1.Condition) restorePlanModifier {
+	return func(rp *v1alpha1.RestorePlan) { rp.Status.SetConditions(c...) }
+}
+
+func withRestorePlanFinalizers(f ...string) restorePlanModifier {
+	return func(rp *v1alpha1.RestorePlan) { rp.ObjectMeta.Finalizers = f }
+}
+
+func withRestorePlanReclaimPolicy(r corev1alpha1.ReclaimPolicy) restorePlanModifier {
+	return func(rp *v1alpha1.RestorePlan) { rp.Spec.ReclaimPolicy = r }
+}
+
+func withRestorePlanName(n string) restorePlanModifier {
+	return func(rp *v1alpha1.RestorePlan) { rp.Status.RestorePlanName = n }
+}
+
+func withRestorePlanState(s, reason string) restorePlanModifier {
+	return func(rp *v1alpha1.RestorePlan) {
+		rp.Status.State = s
+		rp.Status.StateReason = reason
+	}
+}
+
+func withVolumeDataRestorePolicy(p string) restorePlanModifier {
+	return func(rp *v1alpha1.RestorePlan) { rp.Spec.VolumeDataRestorePolicy = p }
+}
+
+func restorePlan(rm ...restorePlanModifier) *v1alpha1.RestorePlan {
+	rp := &v1alpha1.RestorePlan{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.RestorePlanSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			RestorePlanParameters: v1alpha1.RestorePlanParameters{
+				Location:                      location,
+				BackupPlanRef:                 corev1.LocalObjectReference{Name: backupPlanRef},
+				ClusterRef:                    corev1.LocalObjectReference{Name: clusterRef},
+				VolumeDataRestorePolicy:       "RESTORE_VOLUME_DATA_FROM_BACKUP",
+				NamespacedResourceRestoreMode: "FAIL_ON_CONFLICT",
+			},
+		},
+	}
+
+	for _, m := range rm {
+		m(rp)
+	}
+
+	return rp
+}
+
+func TestRestorePlanCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         restorePlanCreateSyncDeleter
+		rp          *v1alpha1.RestorePlan
+		want        *v1alpha1.RestorePlan
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &restorePlans{
+				project: project,
+				kube:    &test.MockClient{MockGet: getPlanAndCluster(backupPlanName)},
+				client: &fakegkebackup.MockClient{
+					MockCreateRestorePlan: func(_ context.Context, p, _ string, rp *gkebackupv1.RestorePlan) error {
+						if p != parent {
+							t.Errorf("CreateRestorePlan(...): want parent %s, got %s", parent, p)
+						}
+						if rp.BackupPlan != backupPlanName {
+							t.Errorf("CreateRestorePlan(...): want backup plan %s, got %s", backupPlanName, rp.BackupPlan)
+						}
+						if rp.Cluster != clusterName {
+							t.Errorf("CreateRestorePlan(...): want cluster %s, got %s", clusterName, rp.Cluster)
+						}
+						return nil
+					},
+				},
+			},
+			rp: restorePlan(),
+			want: restorePlan(
+				withRestorePlanFinalizers(restorePlanFinalizer),
+				withRestorePlanName(restorePlanName),
+				withRestorePlanConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "BackupPlanNotCreated",
+			csd: &restorePlans{
+				project: project,
+				kube:    &test.MockClient{MockGet: getPlanAndCluster("")},
+				client:  &fakegkebackup.MockClient{},
+			},
+			rp: restorePlan(),
+			want: restorePlan(
+				withRestorePlanConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Errorf("backup plan %s/%s is not yet created", namespace, backupPlanRef))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &restorePlans{
+				project: project,
+				kube:    &test.MockClient{MockGet: getPlanAndCluster(backupPlanName)},
+				client: &fakegkebackup.MockClient{
+					MockCreateRestorePlan: func(_ context.Context, _, _ string, _ *gkebackupv1.RestorePlan) error { return errorBoom },
+				},
+			},
+			rp: restorePlan(),
+			want: restorePlan(
+				withRestorePlanConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create restore plan"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.rp)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.rp, test.EquateConditions()); diff != "" {
+				t.Errorf("rp: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRestorePlanSync(t *testing.T) {
+	// upToDate returns the restore plan GCP reports for restorePlan().
+	upToDate := func() *gkebackupv1.RestorePlan {
+		rp := newRestorePlan(restorePlan().Spec.RestorePlanParameters)
+		rp.BackupPlan = backupPlanName
+		rp.Cluster = clusterName
+		rp.State = stateReady
+		return rp
+	}
+
+	cases := []struct {
+		name        string
+		csd         restorePlanCreateSyncDeleter
+		rp          *v1alpha1.RestorePlan
+		want        *v1alpha1.RestorePlan
+		wantRequeue bool
+	}{
+		{
+			name: "Ready",
+			csd: &restorePlans{client: &fakegkebackup.MockClient{
+				MockGetRestorePlan: func(_ context.Context, _ string) (*gkebackupv1.RestorePlan, error) { return upToDate(), nil },
+			}},
+			rp: restorePlan(withRestorePlanName(restorePlanName)),
+			want: restorePlan(
+				withRestorePlanName(restorePlanName),
+				withRestorePlanState(stateReady, ""),
+				withRestorePlanConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "RestoreConfigChanged",
+			csd: &restorePlans{client: &fakegkebackup.MockClient{
+				MockGetRestorePlan: func(_ context.Context, _ string) (*gkebackupv1.RestorePlan, error) { return upToDate(), nil },
+				MockUpdateRestorePlan: func(_ context.Context, _ string, _ *gkebackupv1.RestorePlan, mask string) error {
+					if mask != "restoreConfig" {
+						t.Errorf("UpdateRestorePlan(...): want mask restoreConfig, got %s", mask)
+					}
+					return nil
+				},
+			}},
+			rp: restorePlan(withRestorePlanName(restorePlanName), withVolumeDataRestorePolicy("NO_VOLUME_DATA_RESTORATION")),
+			want: restorePlan(
+				withRestorePlanName(restorePlanName),
+				withVolumeDataRestorePolicy("NO_VOLUME_DATA_RESTORATION"),
+				withRestorePlanState(stateReady, ""),
+				withRestorePlanConditions(corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGet",
+			csd: &restorePlans{client: &fakegkebackup.MockClient{
+				MockGetRestorePlan: func(_ context.Context, _ string) (*gkebackupv1.RestorePlan, error) { return nil, errorBoom },
+			}},
+			rp: restorePlan(withRestorePlanName(restorePlanName)),
+			want: restorePlan(
+				withRestorePlanName(restorePlanName),
+				withRestorePlanConditions(corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.rp)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.rp, test.EquateConditions()); diff != "" {
+				t.Errorf("rp: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRestorePlanDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         restorePlanCreateSyncDeleter
+		rp          *v1alpha1.RestorePlan
+		want        *v1alpha1.RestorePlan
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimDeleteSuccessful",
+			csd: &restorePlans{client: &fakegkebackup.MockClient{
+				MockDeleteRestorePlan: func(_ context.Context, _ string) error { return nil },
+			}},
+			rp: restorePlan(
+				withRestorePlanName(restorePlanName),
+				withRestorePlanFinalizers(restorePlanFinalizer),
+				withRestorePlanReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: restorePlan(
+				withRestorePlanName(restorePlanName),
+				withRestorePlanReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withRestorePlanConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailed",
+			csd: &restorePlans{client: &fakegkebackup.MockClient{
+				MockDeleteRestorePlan: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			rp: restorePlan(
+				withRestorePlanName(restorePlanName),
+				withRestorePlanFinalizers(restorePlanFinalizer),
+				withRestorePlanReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: restorePlan(
+				withRestorePlanName(restorePlanName),
+				withRestorePlanFinalizers(restorePlanFinalizer),
+				withRestorePlanReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withRestorePlanConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot delete restore plan"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "ReclaimRetain",
+			csd:  &restorePlans{client: &fakegkebackup.MockClient{}},
+			rp: restorePlan(
+				withRestorePlanName(restorePlanName),
+				withRestorePlanFinalizers(restorePlanFinalizer),
+				withRestorePlanReclaimPolicy(corev1alpha1.ReclaimRetain),
+			),
+			want: restorePlan(
+				withRestorePlanName(restorePlanName),
+				withRestorePlanReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withRestorePlanConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.rp)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.rp, test.EquateConditions()); diff != "" {
+				t.Errorf("rp: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package httpreplay wraps the HTTP transport of GCP API clients such that
+// their calls are recorded to, or replayed from, a fixture file. Developers
+// may record real interactions with the GCP API once, then run controllers
+// against the recording offline and deterministically. Unlike hand-written
+// mocks, replayed calls match the bodies of the requests that were recorded.
+package httpreplay
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// EnvMode is the environment variable from which FromEnvironment reads the
+// mode of a Recorder; either record or replay.
+const EnvMode = "CROSSPLANE_GCP_HTTP_MODE"
+
+// EnvFixture is the environment variable from which FromEnvironment reads
+// the path of the fixture file to record to or replay from.
+const EnvFixture = "CROSSPLANE_GCP_HTTP_FIXTURE"
+
+// A Mode determines whether calls are recorded or replayed.
+type Mode string
+
+// Modes.
+const (
+	// ModeRecord passes calls through to the GCP API and records them.
+	ModeRecord Mode = "record"
+
+	// ModeReplay answers calls from a recording, without calling the GCP
+	// API.
+	ModeReplay Mode = "replay"
+)
+
+// redactedValue replaces the values of redactedFields in recorded bodies.
+const redactedValue = "REDACTED"
+
+// redactedFields are the JSON fields whose values are secret, such as the
+// passwords of Cloud SQL users and instances. Fixtures are meant to be
+// committed, so these values are never recorded.
+var redactedFields = map[string]bool{
+	"password":     true,
+	"rootPassword": true,
+}
+
+// An Interaction is a recorded call. Request headers, which include
+// credentials, are not recorded, and secret fields of JSON bodies are
+// redacted.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// A Request is a recorded HTTP request.
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+// A Response is a recorded HTTP response.
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Options configure a Recorder.
+type Options struct {
+	// Mode in which to run.
+	Mode Mode
+
+	// Path of the fixture file. It is rewritten after each recorded call,
+	// and must exist in order to replay calls.
+	Path string
+}
+
+// A Recorder records or replays the calls made using the HTTP transports it
+// wraps. Transports wrapped by the same Recorder share its fixture. It is
+// safe for concurrent use.
+type Recorder struct {
+	mu           sync.Mutex
+	o            Options
+	interactions []Interaction
+
+	// replayed records which interactions have been replayed. Each is
+	// replayed at most once, in the order it was recorded.
+	replayed []bool
+}
+
+// New returns a Recorder configured by the supplied options. Recorders that
+// replay calls read their fixture file immediately.
+func New(o Options) (*Recorder, error) {
+	r := &Recorder{o: o}
+	switch o.Mode {
+	case ModeRecord:
+		return r, nil
+	case ModeReplay:
+		b, err := ioutil.ReadFile(o.Path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read fixture %s", o.Path)
+		}
+		if err := json.Unmarshal(b, &r.interactions); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse fixture %s", o.Path)
+		}
+		r.replayed = make([]bool, len(r.interactions))
+		return r, nil
+	default:
+		return nil, errors.Errorf("invalid mode %q; use %s or %s", o.Mode, ModeRecord, ModeReplay)
+	}
+}
+
+// FromEnvironment returns a Recorder configured by environment variable, or
+// nil if no mode is configured.
+func FromEnvironment() (*Recorder, error) {
+	mode, ok := os.LookupEnv(EnvMode)
+	if !ok || mode == "" {
+		return nil, nil
+	}
+	path := os.Getenv(EnvFixture)
+	if path == "" {
+		return nil, errors.Errorf("%s must be set when %s is set", EnvFixture, EnvMode)
+	}
+	return New(Options{Mode: Mode(mode), Path: path})
+}
+
+// Interactions returns the interactions that have been recorded, or that are
+// available to be replayed.
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction{}, r.interactions...)
+}
+
+// Wrap the supplied transport such that its calls are recorded or replayed.
+// A nil Recorder returns the supplied transport, as does a nil transport
+// after it defaults to http.DefaultTransport.
+func (r *Recorder) Wrap(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if r == nil {
+		return base
+	}
+	return &transport{base: base, recorder: r}
+}
+
+// WrapClient returns a copy of the supplied HTTP client whose transport
+// records or replays calls. The copy is authenticated if the supplied client
+// is, though replayed calls are never authenticated.
+func (r *Recorder) WrapClient(c *http.Client) *http.Client {
+	if r == nil {
+		return c
+	}
+	wrapped := *c
+	wrapped.Transport = r.Wrap(c.Transport)
+	return &wrapped
+}
+
+// record appends the supplied interaction and rewrites the fixture file, so
+// that the recording survives the controller being stopped at any time.
+func (r *Recorder) record(i Interaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, i)
+	b, err := json.MarshalIndent(r.interactions, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "cannot encode fixture")
+	}
+	return errors.Wrapf(ioutil.WriteFile(r.o.Path, b, 0600), "cannot write fixture %s", r.o.Path)
+}
+
+// replay returns the response to the first interaction that matches the
+// supplied request and has yet to be replayed.
+func (r *Recorder) replay(req Request) (Response, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, in := range r.interactions {
+		if r.replayed[i] || !matches(in.Request, req) {
+			continue
+		}
+		r.replayed[i] = true
+		return in.Response, true
+	}
+	return Response{}, false
+}
+
+// matches returns true if the supplied requests have the same method, URL,
+// and body. JSON bodies match if they are semantically equal, regardless of
+// the order of their fields.
+func matches(recorded, req Request) bool {
+	if recorded.Method != req.Method || recorded.URL != req.URL {
+		return false
+	}
+	if recorded.Body == req.Body {
+		return true
+	}
+	var a, b interface{}
+	if json.Unmarshal([]byte(recorded.Body), &a) != nil || json.Unmarshal([]byte(req.Body), &b) != nil {
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+type transport struct {
+	base     http.RoundTripper
+	recorder *Recorder
+}
+
+// RoundTrip records the supplied request and its response, or replays the
+// response recorded for it.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	// Requests are redacted when replayed too, so that they match the
+	// redacted requests that were recorded.
+	rr := Request{Method: req.Method, URL: req.URL.String(), Body: string(redact(body))}
+
+	if t.recorder.o.Mode == ModeReplay {
+		rsp, ok := t.recorder.replay(rr)
+		if !ok {
+			return nil, errors.Errorf("no recorded interaction matches %s %s", rr.Method, rr.URL)
+		}
+		return response(req, rsp), nil
+	}
+
+	// A RoundTripper must not modify the request, so we pass a copy whose
+	// body has not been read.
+	pass := new(http.Request)
+	*pass = *req
+	if req.Body != nil {
+		pass.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	rsp, err := t.base.RoundTrip(pass)
+	if err != nil {
+		// Failed calls have no response to replay.
+		return nil, err
+	}
+
+	rb, err := ioutil.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read response body")
+	}
+	rsp.Body = ioutil.NopCloser(bytes.NewReader(rb))
+
+	in := Interaction{Request: rr, Response: Response{StatusCode: rsp.StatusCode, Header: rsp.Header, Body: string(redact(rb))}}
+	if err := t.recorder.record(in); err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
+// redact returns the supplied body with the values of its redactedFields
+// replaced, at any depth. Bodies that are not JSON, or that have no such
+// fields, are returned unchanged.
+func redact(body []byte) []byte {
+	var v interface{}
+	if len(body) == 0 || json.Unmarshal(body, &v) != nil || !redactValue(v) {
+		return body
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return b
+}
+
+// redactValue replaces the values of redactedFields within the supplied
+// decoded JSON value. It returns true if any value was replaced.
+func redactValue(v interface{}) bool {
+	redacted := false
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if _, ok := e.(string); ok && redactedFields[k] {
+				t[k] = redactedValue
+				redacted = true
+				continue
+			}
+			if redactValue(e) {
+				redacted = true
+			}
+		}
+	case []interface{}:
+		for _, e := range t {
+			if redactValue(e) {
+				redacted = true
+			}
+		}
+	}
+	return redacted
+}
+
+// readBody reads and closes the body of the supplied request, if any.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	defer req.Body.Close()
+	b, err := ioutil.ReadAll(req.Body)
+	return b, errors.Wrap(err, "cannot read request body")
+}
+
+// response returns the supplied recorded response to the supplied request.
+func response(req *http.Request, r Response) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(r.StatusCode) + " " + http.StatusText(r.StatusCode),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader([]byte(r.Body))),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
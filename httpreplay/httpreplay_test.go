@@ -0,0 +1,276 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package httpreplay
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+// call makes a POST request with the supplied body using the supplied client,
+// and returns the status code and body of its response.
+func call(t *testing.T, c *http.Client, url, body string) (int, string) {
+	t.Helper()
+	rsp, err := c.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("c.Post(...): %s", err)
+	}
+	defer rsp.Body.Close()
+	b, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		t.Fatalf("ioutil.ReadAll(...): %s", err)
+	}
+	return rsp.StatusCode, string(b)
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		b, _ := ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("created " + string(b)))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "httpreplay")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(...): %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "fixture.json")
+
+	rec, err := New(Options{Mode: ModeRecord, Path: path})
+	if err != nil {
+		t.Fatalf("New(...): %s", err)
+	}
+	c := rec.WrapClient(srv.Client())
+	if code, body := call(t, c, srv.URL+"/instances", `{"name":"a","tier":"db-f1-micro"}`); code != http.StatusCreated || body != `created {"name":"a","tier":"db-f1-micro"}` {
+		t.Errorf("record: got %d %q", code, body)
+	}
+	call(t, c, srv.URL+"/instances", `{"name":"b"}`)
+
+	want := []Interaction{
+		{
+			Request:  Request{Method: http.MethodPost, URL: srv.URL + "/instances", Body: `{"name":"a","tier":"db-f1-micro"}`},
+			Response: Response{StatusCode: http.StatusCreated, Body: `created {"name":"a","tier":"db-f1-micro"}`},
+		},
+		{
+			Request:  Request{Method: http.MethodPost, URL: srv.URL + "/instances", Body: `{"name":"b"}`},
+			Response: Response{StatusCode: http.StatusCreated, Body: `created {"name":"b"}`},
+		},
+	}
+	ignoreHeaders := cmp.FilterPath(func(p cmp.Path) bool { return p.Last().String() == ".Header" }, cmp.Ignore())
+	if diff := cmp.Diff(want, rec.Interactions(), ignoreHeaders); diff != "" {
+		t.Errorf("rec.Interactions(): -want, +got:\n%s", diff)
+	}
+
+	rep, err := New(Options{Mode: ModeReplay, Path: path})
+	if err != nil {
+		t.Fatalf("New(...): %s", err)
+	}
+	if diff := cmp.Diff(want, rep.Interactions(), ignoreHeaders); diff != "" {
+		t.Errorf("rep.Interactions(): -want, +got:\n%s", diff)
+	}
+
+	c = rep.WrapClient(srv.Client())
+
+	// JSON bodies match regardless of the order of their fields, and
+	// requests need not be replayed in the order they were recorded.
+	if code, body := call(t, c, srv.URL+"/instances", `{"name":"b"}`); code != http.StatusCreated || body != `created {"name":"b"}` {
+		t.Errorf("replay: got %d %q", code, body)
+	}
+	if code, body := call(t, c, srv.URL+"/instances", `{"tier":"db-f1-micro","name":"a"}`); code != http.StatusCreated || body != `created {"name":"a","tier":"db-f1-micro"}` {
+		t.Errorf("replay: got %d %q", code, body)
+	}
+	if calls != 2 {
+		t.Errorf("replay: want 2 calls to the server, got %d", calls)
+	}
+
+	// Each interaction is replayed only once.
+	if _, err := c.Post(srv.URL+"/instances", "application/json", strings.NewReader(`{"name":"b"}`)); err == nil {
+		t.Errorf("replay: want error for exhausted interaction")
+	}
+}
+
+func TestRecordRedactsSecrets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"name":"cool-user","password":"hunter2"}`))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "httpreplay")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(...): %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "fixture.json")
+
+	rec, err := New(Options{Mode: ModeRecord, Path: path})
+	if err != nil {
+		t.Fatalf("New(...): %s", err)
+	}
+	call(t, rec.WrapClient(srv.Client()), srv.URL+"/users", `{"name":"cool-user","password":"hunter2"}`)
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile(...): %s", err)
+	}
+	if strings.Contains(string(b), "hunter2") {
+		t.Errorf("fixture: want password redacted, got %s", b)
+	}
+
+	// Requests with any password match the redacted recording.
+	rep, err := New(Options{Mode: ModeReplay, Path: path})
+	if err != nil {
+		t.Fatalf("New(...): %s", err)
+	}
+	if code, body := call(t, rep.WrapClient(srv.Client()), srv.URL+"/users", `{"name":"cool-user","password":"correct-horse"}`); code != http.StatusOK || body != `{"name":"cool-user","password":"REDACTED"}` {
+		t.Errorf("replay: got %d %q", code, body)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	cases := map[string]struct {
+		body string
+		want string
+	}{
+		"Empty": {},
+		"NotJSON": {
+			body: "password=hunter2",
+			want: "password=hunter2",
+		},
+		"NoSecrets": {
+			body: `{"name": "cool-user"}`,
+			want: `{"name": "cool-user"}`,
+		},
+		"Password": {
+			body: `{"name":"cool-user","password":"hunter2"}`,
+			want: `{"name":"cool-user","password":"REDACTED"}`,
+		},
+		"NestedRootPassword": {
+			body: `{"items":[{"rootPassword":"hunter2"}]}`,
+			want: `{"items":[{"rootPassword":"REDACTED"}]}`,
+		},
+		"PasswordPolicy": {
+			// Only string values are secret; objects are searched instead.
+			body: `{"password":{"minLength":8}}`,
+			want: `{"password":{"minLength":8}}`,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := string(redact([]byte(tc.body))); got != tc.want {
+				t.Errorf("redact(...): want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	cases := map[string]struct {
+		recorded Request
+		req      Request
+		want     bool
+	}{
+		"Identical": {
+			recorded: Request{Method: http.MethodGet, URL: "https://example.org/a"},
+			req:      Request{Method: http.MethodGet, URL: "https://example.org/a"},
+			want:     true,
+		},
+		"DifferentMethod": {
+			recorded: Request{Method: http.MethodGet, URL: "https://example.org/a"},
+			req:      Request{Method: http.MethodDelete, URL: "https://example.org/a"},
+			want:     false,
+		},
+		"DifferentURL": {
+			recorded: Request{Method: http.MethodGet, URL: "https://example.org/a"},
+			req:      Request{Method: http.MethodGet, URL: "https://example.org/b"},
+			want:     false,
+		},
+		"EquivalentJSON": {
+			recorded: Request{Method: http.MethodPost, URL: "https://example.org/a", Body: `{"a":1,"b":[1,2]}`},
+			req:      Request{Method: http.MethodPost, URL: "https://example.org/a", Body: `{"b":[1,2], "a":1}`},
+			want:     true,
+		},
+		"DifferentJSON": {
+			recorded: Request{Method: http.MethodPost, URL: "https://example.org/a", Body: `{"a":1}`},
+			req:      Request{Method: http.MethodPost, URL: "https://example.org/a", Body: `{"a":2}`},
+			want:     false,
+		},
+		"DifferentText": {
+			recorded: Request{Method: http.MethodPost, URL: "https://example.org/a", Body: "a"},
+			req:      Request{Method: http.MethodPost, URL: "https://example.org/a", Body: "b"},
+			want:     false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := matches(tc.recorded, tc.req); got != tc.want {
+				t.Errorf("matches(...): want %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	cases := map[string]struct {
+		o    Options
+		want error
+	}{
+		"InvalidMode": {
+			o:    Options{Mode: "rewind"},
+			want: errors.New(`invalid mode "rewind"; use record or replay`),
+		},
+		"MissingFixture": {
+			o:    Options{Mode: ModeReplay, Path: "/nonexistent/fixture.json"},
+			want: errors.Wrap(errors.New("open /nonexistent/fixture.json: no such file or directory"), "cannot read fixture /nonexistent/fixture.json"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(tc.o)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("New(...): -want error, +got error:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNilRecorder(t *testing.T) {
+	var r *Recorder
+	c := &http.Client{}
+	if got := r.WrapClient(c); got != c {
+		t.Errorf("r.WrapClient(...): want unwrapped client")
+	}
+	if got := r.Wrap(nil); got != http.DefaultTransport {
+		t.Errorf("r.Wrap(nil): want http.DefaultTransport")
+	}
+}
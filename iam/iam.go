@@ -0,0 +1,108 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package iam contains controllers that manage GCP IAM policy bindings.
+package iam
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	iamv1 "google.golang.org/api/iam/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/iam"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+)
+
+const reconcileTimeout = 1 * time.Minute
+
+// providerConnecter returns IAM clients authenticated using credentials read
+// from a Crossplane Provider resource.
+type providerConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (iam.Client, error)
+}
+
+// connect returns a client authenticated using credentials read from the
+// Provider referenced by the supplied managed resource, and that Provider.
+func (c *providerConnecter) connect(ctx context.Context, mg metav1.Object, ref *corev1.ObjectReference) (iam.Client, *gcpv1alpha1.Provider, error) {
+	p, err := c.providers.Get(ctx, c.kube, mg, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceIAM)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return client, p, errors.Wrap(err, "cannot create new iam client")
+}
+
+// addMember adds the supplied member to the supplied role's binding in the
+// supplied policy. It returns false if the policy already bound the member to
+// the role.
+func addMember(p *iamv1.Policy, role, member string) bool {
+	for _, b := range p.Bindings {
+		if b.Role != role || b.Condition != nil {
+			continue
+		}
+		for _, m := range b.Members {
+			if m == member {
+				return false
+			}
+		}
+		b.Members = append(b.Members, member)
+		return true
+	}
+	p.Bindings = append(p.Bindings, &iamv1.Binding{Role: role, Members: []string{member}})
+	return true
+}
+
+// removeMember removes the supplied member from the supplied role's binding in
+// the supplied policy, removing the binding if it has no other members. It
+// returns false if the policy did not bind the member to the role.
+func removeMember(p *iamv1.Policy, role, member string) bool {
+	removed := false
+	bindings := make([]*iamv1.Binding, 0, len(p.Bindings))
+	for _, b := range p.Bindings {
+		if b.Role == role && b.Condition == nil {
+			members := make([]string, 0, len(b.Members))
+			for _, m := range b.Members {
+				if m == member {
+					removed = true
+					continue
+				}
+				members = append(members, m)
+			}
+			if len(members) == 0 {
+				continue
+			}
+			b.Members = members
+		}
+		bindings = append(bindings, b)
+	}
+	p.Bindings = bindings
+	return removed
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iam
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	iamv1 "google.golang.org/api/iam/v1"
+)
+
+func TestAddMember(t *testing.T) {
+	cases := map[string]struct {
+		p       *iamv1.Policy
+		want    *iamv1.Policy
+		wantAdd bool
+	}{
+		"NoBinding": {
+			p:       &iamv1.Policy{},
+			want:    &iamv1.Policy{Bindings: []*iamv1.Binding{{Role: "roles/cool", Members: []string{"user:a"}}}},
+			wantAdd: true,
+		},
+		"ExistingBinding": {
+			p:       &iamv1.Policy{Bindings: []*iamv1.Binding{{Role: "roles/cool", Members: []string{"user:b"}}}},
+			want:    &iamv1.Policy{Bindings: []*iamv1.Binding{{Role: "roles/cool", Members: []string{"user:b", "user:a"}}}},
+			wantAdd: true,
+		},
+		"AlreadyBound": {
+			p:    &iamv1.Policy{Bindings: []*iamv1.Binding{{Role: "roles/cool", Members: []string{"user:a"}}}},
+			want: &iamv1.Policy{Bindings: []*iamv1.Binding{{Role: "roles/cool", Members: []string{"user:a"}}}},
+		},
+		"ConditionalBindingIgnored": {
+			p: &iamv1.Policy{Bindings: []*iamv1.Binding{{Role: "roles/cool", Members: []string{"user:a"}, Condition: &iamv1.Expr{Expression: "true"}}}},
+			want: &iamv1.Policy{Bindings: []*iamv1.Binding{
+				{Role: "roles/cool", Members: []string{"user:a"}, Condition: &iamv1.Expr{Expression: "true"}},
+				{Role: "roles/cool", Members: []string{"user:a"}},
+			}},
+			wantAdd: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := addMember(tc.p, "roles/cool", "user:a")
+			if got != tc.wantAdd {
+				t.Errorf("addMember(...): want %t, got %t", tc.wantAdd, got)
+			}
+			if diff := cmp.Diff(tc.want, tc.p); diff != "" {
+				t.Errorf("addMember(...): -want policy, +got policy:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRemoveMember(t *testing.T) {
+	cases := map[string]struct {
+		p          *iamv1.Policy
+		want       *iamv1.Policy
+		wantRemove bool
+	}{
+		"NotBound": {
+			p:    &iamv1.Policy{Bindings: []*iamv1.Binding{{Role: "roles/cool", Members: []string{"user:b"}}}},
+			want: &iamv1.Policy{Bindings: []*iamv1.Binding{{Role: "roles/cool", Members: []string{"user:b"}}}},
+		},
+		"OtherMembers": {
+			p:          &iamv1.Policy{Bindings: []*iamv1.Binding{{Role: "roles/cool", Members: []string{"user:a", "user:b"}}}},
+			want:       &iamv1.Policy{Bindings: []*iamv1.Binding{{Role: "roles/cool", Members: []string{"user:b"}}}},
+			wantRemove: true,
+		},
+		"OnlyMember": {
+			p: &iamv1.Policy{Bindings: []*iamv1.Binding{
+				{Role: "roles/other", Members: []string{"user:a"}},
+				{Role: "roles/cool", Members: []string{"user:a"}},
+			}},
+			want:       &iamv1.Policy{Bindings: []*iamv1.Binding{{Role: "roles/other", Members: []string{"user:a"}}}},
+			wantRemove: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := removeMember(tc.p, "roles/cool", "user:a")
+			if got != tc.wantRemove {
+				t.Errorf("removeMember(...): want %t, got %t", tc.wantRemove, got)
+			}
+			if diff := cmp.Diff(tc.want, tc.p); diff != "" {
+				t.Errorf("removeMember(...): -want policy, +got policy:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,365 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iam
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	computev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/iam/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/iam"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compute"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	bindingControllerName = "workloadidentitybindings.iam.gcp.crossplane.io"
+	bindingFinalizer      = "finalizer." + bindingControllerName
+
+	// RoleWorkloadIdentityUser allows a Kubernetes service account to
+	// impersonate a GCP service account.
+	RoleWorkloadIdentityUser = "roles/iam.workloadIdentityUser"
+
+	// AnnotationGCPServiceAccount is the annotation that tells GKE which GCP
+	// service account a Kubernetes service account impersonates.
+	AnnotationGCPServiceAccount = "iam.gke.io/gcp-service-account"
+)
+
+var bindingLog = logging.Logger.WithName("controller." + bindingControllerName)
+
+// A bindingCreateSyncDeleter can create, sync, and delete workload identity
+// bindings in an external store - e.g. the GCP API. Each method returns true
+// if the binding requires further reconciliation.
+type bindingCreateSyncDeleter interface {
+	Create(ctx context.Context, b *v1alpha1.WorkloadIdentityBinding) (requeue bool)
+	Sync(ctx context.Context, b *v1alpha1.WorkloadIdentityBinding) (requeue bool)
+	Delete(ctx context.Context, b *v1alpha1.WorkloadIdentityBinding) (requeue bool)
+}
+
+// workloadIdentityBindings is a bindingCreateSyncDeleter using the GCP IAM
+// API, and the API server of the GKE cluster in which the Kubernetes service
+// account exists.
+type workloadIdentityBindings struct {
+	client         iam.Client
+	kube           client.Client
+	project        string
+	connectCluster func(*corev1.Secret) (client.Client, error)
+}
+
+// Create determines the IAM member of the Kubernetes service account once its
+// GKE cluster is available. The binding is made when it is synced.
+func (c *workloadIdentityBindings) Create(ctx context.Context, b *v1alpha1.WorkloadIdentityBinding) bool {
+	b.Status.SetConditions(corev1alpha1.Creating())
+
+	if _, err := c.cluster(ctx, b); err != nil {
+		b.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	b.Status.Member = member(c.workloadPool(b), b.Spec.KubernetesServiceAccount)
+	meta.AddFinalizer(b, bindingFinalizer)
+	b.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync allows the Kubernetes service account to impersonate the GCP service
+// account, and annotates the Kubernetes service account so that GKE knows
+// which GCP service account it impersonates.
+func (c *workloadIdentityBindings) Sync(ctx context.Context, b *v1alpha1.WorkloadIdentityBinding) bool {
+	if err := c.bind(ctx, b); err != nil {
+		b.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	if err := c.annotate(ctx, b); err != nil {
+		b.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	b.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete removes the binding and the Kubernetes service account's annotation.
+// The annotation is left in place if the GKE cluster is no longer available.
+func (c *workloadIdentityBindings) Delete(ctx context.Context, b *v1alpha1.WorkloadIdentityBinding) bool {
+	b.Status.SetConditions(corev1alpha1.Deleting())
+
+	if b.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.unbind(ctx, b); err != nil {
+			b.Status.SetConditions(corev1alpha1.ReconcileError(err))
+			return true
+		}
+		if err := c.unannotate(ctx, b); err != nil {
+			b.Status.SetConditions(corev1alpha1.ReconcileError(err))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(b, bindingFinalizer)
+	b.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// bind adds the Kubernetes service account's member to the GCP service
+// account's workload identity user role, if it is not already bound.
+func (c *workloadIdentityBindings) bind(ctx context.Context, b *v1alpha1.WorkloadIdentityBinding) error {
+	name := serviceAccountName(b.Spec.GCPServiceAccount)
+	p, err := c.client.GetServiceAccountIamPolicy(ctx, name)
+	if err != nil {
+		return errors.Wrapf(err, "cannot get iam policy of service account %s", b.Spec.GCPServiceAccount)
+	}
+	if !addMember(p, RoleWorkloadIdentityUser, b.Status.Member) {
+		return nil
+	}
+	return errors.Wrapf(c.client.SetServiceAccountIamPolicy(ctx, name, p), "cannot set iam policy of service account %s", b.Spec.GCPServiceAccount)
+}
+
+// unbind removes the Kubernetes service account's member from the GCP service
+// account's workload identity user role, if it is bound.
+func (c *workloadIdentityBindings) unbind(ctx context.Context, b *v1alpha1.WorkloadIdentityBinding) error {
+	name := serviceAccountName(b.Spec.GCPServiceAccount)
+	p, err := c.client.GetServiceAccountIamPolicy(ctx, name)
+	if googleapi.IsErrorNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "cannot get iam policy of service account %s", b.Spec.GCPServiceAccount)
+	}
+	if !removeMember(p, RoleWorkloadIdentityUser, b.Status.Member) {
+		return nil
+	}
+	return errors.Wrapf(c.client.SetServiceAccountIamPolicy(ctx, name, p), "cannot set iam policy of service account %s", b.Spec.GCPServiceAccount)
+}
+
+// annotate annotates the Kubernetes service account with the GCP service
+// account it impersonates.
+func (c *workloadIdentityBindings) annotate(ctx context.Context, b *v1alpha1.WorkloadIdentityBinding) error {
+	kube, err := c.cluster(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	n := types.NamespacedName{Namespace: b.Spec.KubernetesServiceAccount.Namespace, Name: b.Spec.KubernetesServiceAccount.Name}
+	sa := &corev1.ServiceAccount{}
+	if err := kube.Get(ctx, n, sa); err != nil {
+		return errors.Wrapf(err, "cannot get kubernetes service account %s", n)
+	}
+	if sa.GetAnnotations()[AnnotationGCPServiceAccount] == b.Spec.GCPServiceAccount {
+		return nil
+	}
+
+	if sa.Annotations == nil {
+		sa.Annotations = map[string]string{}
+	}
+	sa.Annotations[AnnotationGCPServiceAccount] = b.Spec.GCPServiceAccount
+	return errors.Wrapf(kube.Update(ctx, sa), "cannot annotate kubernetes service account %s", n)
+}
+
+// unannotate removes the GCP service account annotation from the Kubernetes
+// service account, if it still refers to the bound GCP service account.
+func (c *workloadIdentityBindings) unannotate(ctx context.Context, b *v1alpha1.WorkloadIdentityBinding) error {
+	kube, err := c.cluster(ctx, b)
+	if err != nil {
+		// The cluster has been deleted or is being deleted, along with its
+		// service accounts.
+		return nil
+	}
+
+	n := types.NamespacedName{Namespace: b.Spec.KubernetesServiceAccount.Namespace, Name: b.Spec.KubernetesServiceAccount.Name}
+	sa := &corev1.ServiceAccount{}
+	if err := kube.Get(ctx, n, sa); err != nil {
+		return errors.Wrapf(resource.Ignore(kerrors.IsNotFound, err), "cannot get kubernetes service account %s", n)
+	}
+	if sa.GetAnnotations()[AnnotationGCPServiceAccount] != b.Spec.GCPServiceAccount {
+		return nil
+	}
+
+	delete(sa.Annotations, AnnotationGCPServiceAccount)
+	return errors.Wrapf(kube.Update(ctx, sa), "cannot remove annotation from kubernetes service account %s", n)
+}
+
+// cluster returns a client for the GKE cluster referenced by the supplied
+// binding. It returns an error if the cluster is not yet available.
+func (c *workloadIdentityBindings) cluster(ctx context.Context, b *v1alpha1.WorkloadIdentityBinding) (client.Client, error) {
+	gke := &computev1alpha1.GKECluster{}
+	n := types.NamespacedName{Namespace: b.GetNamespace(), Name: b.Spec.ClusterRef.Name}
+	if err := c.kube.Get(ctx, n, gke); err != nil {
+		return nil, errors.Wrapf(err, "cannot get gke cluster %s", n)
+	}
+	if gke.Status.GetCondition(corev1alpha1.TypeReady).Status != corev1.ConditionTrue {
+		return nil, errors.Errorf("gke cluster %s is not yet available", n)
+	}
+
+	s := &corev1.Secret{}
+	sn := types.NamespacedName{Namespace: gke.GetNamespace(), Name: gke.Spec.WriteConnectionSecretToReference.Name}
+	if err := c.kube.Get(ctx, sn, s); err != nil {
+		return nil, errors.Wrapf(err, "cannot get connection secret of gke cluster %s", n)
+	}
+	return c.connectCluster(s)
+}
+
+// workloadPool returns the workload identity pool of the supplied binding's
+// cluster. Clusters use their project's pool unless the binding specifies
+// another.
+func (c *workloadIdentityBindings) workloadPool(b *v1alpha1.WorkloadIdentityBinding) string {
+	if b.Spec.WorkloadPool != "" {
+		return b.Spec.WorkloadPool
+	}
+	return c.project + ".svc.id.goog"
+}
+
+// member returns the IAM member of the supplied Kubernetes service account,
+// e.g. serviceAccount:p.svc.id.goog[default/app].
+func member(pool string, sa v1alpha1.KubernetesServiceAccount) string {
+	return fmt.Sprintf("serviceAccount:%s[%s/%s]", pool, sa.Namespace, sa.Name)
+}
+
+// serviceAccountName returns the resource name of the GCP service account
+// with the supplied email address.
+func serviceAccountName(email string) string {
+	return "projects/-/serviceAccounts/" + email
+}
+
+// A bindingConnecter returns a bindingCreateSyncDeleter that can create,
+// sync, and delete workload identity bindings with an external store - for
+// example the GCP API.
+type bindingConnecter interface {
+	Connect(context.Context, *v1alpha1.WorkloadIdentityBinding) (bindingCreateSyncDeleter, error)
+}
+
+// bindingProviderConnecter is a bindingConnecter that returns a
+// bindingCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type bindingProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns a bindingCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// WorkloadIdentityBinding.
+func (c *bindingProviderConnecter) Connect(ctx context.Context, b *v1alpha1.WorkloadIdentityBinding) (bindingCreateSyncDeleter, error) {
+	client, p, err := c.connect(ctx, b, b.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &workloadIdentityBindings{
+		client:         client,
+		kube:           c.kube,
+		project:        p.Spec.ProjectID,
+		connectCluster: compute.ConnectCluster,
+	}, nil
+}
+
+// WorkloadIdentityBindingReconciler reconciles WorkloadIdentityBindings read
+// from the Kubernetes API with an external store, typically the GCP API.
+type WorkloadIdentityBindingReconciler struct {
+	bindingConnecter
+	kube client.Client
+}
+
+// WorkloadIdentityBindingController is responsible for adding the
+// WorkloadIdentityBinding controller and its corresponding reconciler to the
+// manager with any runtime configuration.
+type WorkloadIdentityBindingController struct {
+	// DefaultProvider is used by bindings that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new WorkloadIdentityBinding Controller and adds
+// it to the Manager with default RBAC. The Manager will set fields on the
+// Controller and start it when the Manager is Started.
+func (c *WorkloadIdentityBindingController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &WorkloadIdentityBindingReconciler{
+		bindingConnecter: &bindingProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: iam.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(bindingControllerName).
+		For(&v1alpha1.WorkloadIdentityBinding{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listBindings)).
+		Complete(r)
+}
+
+// Reconcile workload identity bindings with the GCP API.
+func (r *WorkloadIdentityBindingReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	bindingLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.WorkloadIdentityBindingKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	b := &v1alpha1.WorkloadIdentityBinding{}
+	if err := r.kube.Get(ctx, req.NamespacedName, b); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get workload identity binding %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, b)
+	if err != nil {
+		b.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, b), "cannot update workload identity binding %s", req.NamespacedName)
+	}
+
+	// The binding has been deleted from the API server. Remove it from GCP.
+	if b.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, b)}, errors.Wrapf(r.kube.Update(ctx, b), "cannot update workload identity binding %s", req.NamespacedName)
+	}
+
+	// The binding has no member. Assume it has not been created.
+	if b.Status.Member == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, b)}, errors.Wrapf(r.kube.Update(ctx, b), "cannot update workload identity binding %s", req.NamespacedName)
+	}
+
+	// The binding exists in the API server. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, b)}, errors.Wrapf(r.kube.Update(ctx, b), "cannot update workload identity binding %s", req.NamespacedName)
+}
+
+// listBindings is a provider.Lister of workload identity bindings.
+func listBindings(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.WorkloadIdentityBindingList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,394 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iam
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+	iamv1 "google.golang.org/api/iam/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	computev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/iam/v1alpha1"
+	fakeiam "github.com/crossplaneio/crossplane/pkg/clients/gcp/iam/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace    = "cool-namespace"
+	name         = "cool-binding"
+	uid          = types.UID("definitely-a-uuid")
+	project      = "cool-project"
+	providerName = "cool-gcp"
+	clusterName  = "cool-cluster"
+	gsa          = "cool-app@cool-project.iam.gserviceaccount.com"
+)
+
+var (
+	ctx           = context.Background()
+	errorBoom     = errors.New("boom")
+	errorNotFound = &googleapi.Error{Code: http.StatusNotFound}
+	testMember    = "serviceAccount:cool-project.svc.id.goog[cool-app/cool-ksa]"
+)
+
+// Test that our Reconciler implementation satisfies the Reconciler interface.
+var _ reconcile.Reconciler = &WorkloadIdentityBindingReconciler{}
+
+type bindingModifier func(*v1alpha1.WorkloadIdentityBinding)
+
+func withConditions(c ...corev1alpha1.Condition) bindingModifier {
+	return func(b *v1alpha1.WorkloadIdentityBinding) { b.Status.SetConditions(c...) }
+}
+
+func withFinalizers(f ...string) bindingModifier {
+	return func(b *v1alpha1.WorkloadIdentityBinding) { b.ObjectMeta.Finalizers = f }
+}
+
+func withReclaimPolicy(r corev1alpha1.ReclaimPolicy) bindingModifier {
+	return func(b *v1alpha1.WorkloadIdentityBinding) { b.Spec.ReclaimPolicy = r }
+}
+
+func withMember(m string) bindingModifier {
+	return func(b *v1alpha1.WorkloadIdentityBinding) { b.Status.Member = m }
+}
+
+func binding(bm ...bindingModifier) *v1alpha1.WorkloadIdentityBinding {
+	b := &v1alpha1.WorkloadIdentityBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.WorkloadIdentityBindingSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			WorkloadIdentityBindingParameters: v1alpha1.WorkloadIdentityBindingParameters{
+				ClusterRef:               corev1.LocalObjectReference{Name: clusterName},
+				KubernetesServiceAccount: v1alpha1.KubernetesServiceAccount{Namespace: "cool-app", Name: "cool-ksa"},
+				GCPServiceAccount:        gsa,
+			},
+		},
+	}
+
+	for _, m := range bm {
+		m(b)
+	}
+
+	return b
+}
+
+// localKube returns a client for the API server in which the binding and its
+// GKE cluster exist.
+func localKube(c ...corev1alpha1.Condition) client.Client {
+	return &test.MockClient{
+		MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+			switch o := obj.(type) {
+			case *computev1alpha1.GKECluster:
+				o.SetNamespace(namespace)
+				o.SetName(clusterName)
+				o.Spec.WriteConnectionSecretToReference = corev1.LocalObjectReference{Name: clusterName}
+				o.Status.SetConditions(c...)
+			case *corev1.Secret:
+			}
+			return nil
+		},
+	}
+}
+
+// clusterKube returns a function that connects to a GKE cluster in which the
+// Kubernetes service account has the supplied annotations. Updates to the
+// service account are recorded in updated.
+func clusterKube(annotations map[string]string, updated **corev1.ServiceAccount) func(*corev1.Secret) (client.Client, error) {
+	return func(*corev1.Secret) (client.Client, error) {
+		return &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				sa := obj.(*corev1.ServiceAccount)
+				for k, v := range annotations {
+					if sa.Annotations == nil {
+						sa.Annotations = map[string]string{}
+					}
+					sa.Annotations[k] = v
+				}
+				return nil
+			},
+			MockUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+				*updated = obj.(*corev1.ServiceAccount)
+				return nil
+			},
+		}, nil
+	}
+}
+
+func TestBindingCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         bindingCreateSyncDeleter
+		b           *v1alpha1.WorkloadIdentityBinding
+		want        *v1alpha1.WorkloadIdentityBinding
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &workloadIdentityBindings{
+				project:        project,
+				kube:           localKube(corev1alpha1.Available()),
+				connectCluster: func(*corev1.Secret) (client.Client, error) { return &test.MockClient{}, nil },
+			},
+			b: binding(),
+			want: binding(
+				withMember(testMember),
+				withFinalizers(bindingFinalizer),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "ClusterNotAvailable",
+			csd: &workloadIdentityBindings{
+				project: project,
+				kube:    localKube(corev1alpha1.Creating()),
+			},
+			b: binding(),
+			want: binding(
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Errorf("gke cluster %s/%s is not yet available", namespace, clusterName))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.b)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.b, test.EquateConditions()); diff != "" {
+				t.Errorf("b: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBindingSync(t *testing.T) {
+	type want struct {
+		b          *v1alpha1.WorkloadIdentityBinding
+		requeue    bool
+		policy     *iamv1.Policy
+		annotation string
+	}
+
+	cases := []struct {
+		name        string
+		policy      *iamv1.Policy
+		setErr      error
+		annotations map[string]string
+		want        want
+	}{
+		{
+			name:   "BindAndAnnotate",
+			policy: &iamv1.Policy{Etag: "cool-etag"},
+			want: want{
+				b: binding(withMember(testMember), withConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())),
+				policy: &iamv1.Policy{Etag: "cool-etag", Bindings: []*iamv1.Binding{
+					{Role: RoleWorkloadIdentityUser, Members: []string{testMember}},
+				}},
+				annotation: gsa,
+			},
+		},
+		{
+			name:        "AlreadyBoundAndAnnotated",
+			policy:      &iamv1.Policy{Bindings: []*iamv1.Binding{{Role: RoleWorkloadIdentityUser, Members: []string{testMember}}}},
+			annotations: map[string]string{AnnotationGCPServiceAccount: gsa},
+			want: want{
+				b: binding(withMember(testMember), withConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())),
+			},
+		},
+		{
+			name:   "FailedSetPolicy",
+			policy: &iamv1.Policy{},
+			setErr: errorBoom,
+			want: want{
+				b:       binding(withMember(testMember), withConditions(corev1alpha1.ReconcileError(errors.Wrapf(errorBoom, "cannot set iam policy of service account %s", gsa)))),
+				requeue: true,
+				policy:  &iamv1.Policy{Bindings: []*iamv1.Binding{{Role: RoleWorkloadIdentityUser, Members: []string{testMember}}}},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var policy *iamv1.Policy
+			var updated *corev1.ServiceAccount
+			csd := &workloadIdentityBindings{
+				project: project,
+				kube:    localKube(corev1alpha1.Available()),
+				client: &fakeiam.MockClient{
+					MockGetServiceAccountIamPolicy: func(_ context.Context, n string) (*iamv1.Policy, error) {
+						if n != serviceAccountName(gsa) {
+							t.Errorf("GetServiceAccountIamPolicy(...): want %s, got %s", serviceAccountName(gsa), n)
+						}
+						return tc.policy, nil
+					},
+					MockSetServiceAccountIamPolicy: func(_ context.Context, _ string, p *iamv1.Policy) error {
+						policy = p
+						return tc.setErr
+					},
+				},
+				connectCluster: clusterKube(tc.annotations, &updated),
+			}
+
+			b := binding(withMember(testMember))
+			gotRequeue := csd.Sync(ctx, b)
+
+			if gotRequeue != tc.want.requeue {
+				t.Errorf("csd.Sync(...): want: %t got: %t", tc.want.requeue, gotRequeue)
+			}
+			if diff := cmp.Diff(tc.want.b, b, test.EquateConditions()); diff != "" {
+				t.Errorf("b: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.policy, policy); diff != "" {
+				t.Errorf("SetServiceAccountIamPolicy(...): -want, +got:\n%s", diff)
+			}
+			got := ""
+			if updated != nil {
+				got = updated.Annotations[AnnotationGCPServiceAccount]
+			}
+			if got != tc.want.annotation {
+				t.Errorf("kube.Update(...): want annotation %q, got %q", tc.want.annotation, got)
+			}
+		})
+	}
+}
+
+func TestBindingDelete(t *testing.T) {
+	type want struct {
+		b       *v1alpha1.WorkloadIdentityBinding
+		requeue bool
+		policy  *iamv1.Policy
+		updated bool
+	}
+
+	cases := []struct {
+		name    string
+		reclaim corev1alpha1.ReclaimPolicy
+		kube    client.Client
+		getErr  error
+		want    want
+	}{
+		{
+			name:    "ReclaimDelete",
+			reclaim: corev1alpha1.ReclaimDelete,
+			kube:    localKube(corev1alpha1.Available()),
+			want: want{
+				b:       binding(withMember(testMember), withReclaimPolicy(corev1alpha1.ReclaimDelete), withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess())),
+				policy:  &iamv1.Policy{Bindings: []*iamv1.Binding{}},
+				updated: true,
+			},
+		},
+		{
+			name:    "ClusterDeleted",
+			reclaim: corev1alpha1.ReclaimDelete,
+			kube: &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, _ runtime.Object) error {
+				return kerrors.NewNotFound(schema.GroupResource{}, clusterName)
+			}},
+			want: want{
+				b:      binding(withMember(testMember), withReclaimPolicy(corev1alpha1.ReclaimDelete), withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess())),
+				policy: &iamv1.Policy{Bindings: []*iamv1.Binding{}},
+			},
+		},
+		{
+			name:    "GCPServiceAccountDeleted",
+			reclaim: corev1alpha1.ReclaimDelete,
+			kube:    localKube(corev1alpha1.Available()),
+			getErr:  errorNotFound,
+			want: want{
+				b:       binding(withMember(testMember), withReclaimPolicy(corev1alpha1.ReclaimDelete), withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess())),
+				updated: true,
+			},
+		},
+		{
+			name:    "ReclaimRetain",
+			reclaim: corev1alpha1.ReclaimRetain,
+			kube:    localKube(corev1alpha1.Available()),
+			want: want{
+				b: binding(withMember(testMember), withReclaimPolicy(corev1alpha1.ReclaimRetain), withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess())),
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var policy *iamv1.Policy
+			var updated *corev1.ServiceAccount
+			csd := &workloadIdentityBindings{
+				project: project,
+				kube:    tc.kube,
+				client: &fakeiam.MockClient{
+					MockGetServiceAccountIamPolicy: func(_ context.Context, _ string) (*iamv1.Policy, error) {
+						if tc.getErr != nil {
+							return nil, tc.getErr
+						}
+						return &iamv1.Policy{Bindings: []*iamv1.Binding{{Role: RoleWorkloadIdentityUser, Members: []string{testMember}}}}, nil
+					},
+					MockSetServiceAccountIamPolicy: func(_ context.Context, _ string, p *iamv1.Policy) error {
+						policy = p
+						return nil
+					},
+				},
+				connectCluster: clusterKube(map[string]string{AnnotationGCPServiceAccount: gsa}, &updated),
+			}
+
+			b := binding(withMember(testMember), withFinalizers(bindingFinalizer), withReclaimPolicy(tc.reclaim))
+			gotRequeue := csd.Delete(ctx, b)
+
+			if gotRequeue != tc.want.requeue {
+				t.Errorf("csd.Delete(...): want: %t got: %t", tc.want.requeue, gotRequeue)
+			}
+			if diff := cmp.Diff(tc.want.b, b, test.EquateConditions()); diff != "" {
+				t.Errorf("b: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.policy, policy); diff != "" {
+				t.Errorf("SetServiceAccountIamPolicy(...): -want, +got:\n%s", diff)
+			}
+			if (updated != nil) != tc.want.updated {
+				t.Errorf("kube.Update(...): want called %t, got %t", tc.want.updated, updated != nil)
+			}
+			if updated != nil {
+				if _, ok := updated.Annotations[AnnotationGCPServiceAccount]; ok {
+					t.Errorf("kube.Update(...): want annotation %s removed", AnnotationGCPServiceAccount)
+				}
+			}
+		})
+	}
+}
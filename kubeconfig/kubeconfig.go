@@ -0,0 +1,140 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kubeconfig builds clients for GKE clusters from the connection
+// secrets written by the GKECluster controller.
+package kubeconfig
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+)
+
+var pemPrefix = []byte("-----BEGIN")
+
+// An Option configures the REST config built from a connection secret.
+type Option func(*rest.Config)
+
+// WithTokenSource authenticates requests to the cluster using bearer tokens
+// from the supplied source. Tokens are cached and refreshed shortly before
+// they expire, so long-lived clients keep working after the first token does.
+// Any static credentials in the connection secret are ignored.
+func WithTokenSource(ts oauth2.TokenSource) Option {
+	return func(cfg *rest.Config) {
+		cfg.Username = ""
+		cfg.Password = ""
+		cfg.CertData = nil
+		cfg.KeyData = nil
+
+		rts := oauth2.ReuseTokenSource(nil, ts)
+		cfg.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+			return &oauth2.Transport{Source: rts, Base: rt}
+		}
+	}
+}
+
+// WithGoogleCredentials authenticates requests to the cluster as the
+// supplied Google credentials, for example those of a Crossplane Provider. The
+// credentials must include the cloud-platform scope.
+func WithGoogleCredentials(creds *google.Credentials) Option {
+	return WithTokenSource(creds.TokenSource)
+}
+
+// RESTConfig returns a REST config for the GKE cluster described by the
+// supplied connection secret. Certificates may be stored PEM encoded, or
+// base64 encoded as they are returned by the GKE API.
+func RESTConfig(s *corev1.Secret, o ...Option) (*rest.Config, error) {
+	endpoint := string(s.Data[corev1alpha1.ResourceCredentialsSecretEndpointKey])
+	if endpoint == "" {
+		return nil, errors.Errorf("connection secret %s/%s has no endpoint", s.GetNamespace(), s.GetName())
+	}
+	if !strings.HasPrefix(endpoint, "https://") {
+		endpoint = "https://" + endpoint
+	}
+
+	ca, err := decodePEM(s.Data[corev1alpha1.ResourceCredentialsSecretCAKey])
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode cluster CA certificate")
+	}
+	cert, err := decodePEM(s.Data[corev1alpha1.ResourceCredentialsSecretClientCertKey])
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode client certificate")
+	}
+	key, err := decodePEM(s.Data[corev1alpha1.ResourceCredentialsSecretClientKeyKey])
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode client key")
+	}
+
+	cfg := &rest.Config{
+		Host:     endpoint,
+		Username: string(s.Data[corev1alpha1.ResourceCredentialsSecretUserKey]),
+		Password: string(s.Data[corev1alpha1.ResourceCredentialsSecretPasswordKey]),
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData:   ca,
+			CertData: cert,
+			KeyData:  key,
+		},
+	}
+
+	for _, fn := range o {
+		fn(cfg)
+	}
+
+	return cfg, nil
+}
+
+// NewClientset returns a Kubernetes clientset for the GKE cluster described
+// by the supplied connection secret.
+func NewClientset(s *corev1.Secret, o ...Option) (kubernetes.Interface, error) {
+	cfg, err := RESTConfig(s, o...)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := kubernetes.NewForConfig(cfg)
+	return cs, errors.Wrap(err, "cannot create clientset for cluster")
+}
+
+// NewClient returns a controller-runtime client for the GKE cluster described
+// by the supplied connection secret.
+func NewClient(s *corev1.Secret, o ...Option) (client.Client, error) {
+	cfg, err := RESTConfig(s, o...)
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.New(cfg, client.Options{})
+	return c, errors.Wrap(err, "cannot create client for cluster")
+}
+
+// decodePEM returns the supplied data if it is already PEM encoded, and
+// otherwise assumes it is base64 encoded PEM.
+func decodePEM(b []byte) ([]byte, error) {
+	if len(b) == 0 || bytes.HasPrefix(bytes.TrimSpace(b), pemPrefix) {
+		return b, nil
+	}
+	return base64.StdEncoding.DecodeString(string(b))
+}
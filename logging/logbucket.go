@@ -0,0 +1,257 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	loggingv2 "google.golang.org/api/logging/v2"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/logging/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	cloudlogging "github.com/crossplaneio/crossplane/pkg/clients/gcp/logging"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	logBucketControllerName = "logbuckets.logging.gcp.crossplane.io"
+	logBucketFinalizer      = "finalizer." + logBucketControllerName
+
+	// logBucketUpdateMask lists the fields of a log bucket that may be
+	// updated. Its location is immutable.
+	logBucketUpdateMask = "description,retentionDays,locked"
+
+	// lifecycleStateActive is the lifecycle state of a usable log bucket.
+	lifecycleStateActive = "ACTIVE"
+)
+
+var logBucketLog = logging.Logger.WithName("controller." + logBucketControllerName)
+
+// A logBucketCreateSyncDeleter can create, sync, and delete log buckets in an
+// external store - e.g. the GCP API. Each method returns true if the log
+// bucket requires further reconciliation.
+type logBucketCreateSyncDeleter interface {
+	Create(ctx context.Context, b *v1alpha1.LogBucket) (requeue bool)
+	Sync(ctx context.Context, b *v1alpha1.LogBucket) (requeue bool)
+	Delete(ctx context.Context, b *v1alpha1.LogBucket) (requeue bool)
+}
+
+// logBuckets is a logBucketCreateSyncDeleter using the Cloud Logging API.
+type logBuckets struct {
+	client  cloudlogging.Client
+	project string
+}
+
+// Create creates the desired log bucket.
+func (c *logBuckets) Create(ctx context.Context, b *v1alpha1.LogBucket) bool {
+	b.Status.SetConditions(corev1alpha1.Creating())
+
+	parent := bucketParent(c.project, b.Spec.Location)
+	id := resourceID(b)
+
+	// We may have created the log bucket but failed to record its name.
+	if err := c.client.CreateBucket(ctx, parent, id, desiredLogBucket(b)); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		b.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create log bucket")))
+		return true
+	}
+
+	b.Status.BucketName = parent + "/buckets/" + id
+	meta.AddFinalizer(b, logBucketFinalizer)
+	b.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync updates the log bucket if it differs from the desired bucket, and
+// records its lifecycle state. A locked bucket cannot be updated, so a locked
+// bucket that differs from its spec is reported as an error.
+func (c *logBuckets) Sync(ctx context.Context, b *v1alpha1.LogBucket) bool {
+	actual, err := c.client.GetBucket(ctx, b.Status.BucketName)
+	if err != nil {
+		b.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+	b.Status.LifecycleState = actual.LifecycleState
+
+	desired := desiredLogBucket(b)
+	if diff := compare.Diff(desired, actual, compare.IgnoreUnset()); len(diff) > 0 {
+		if actual.Locked {
+			b.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileError(errors.Errorf("log bucket is locked; cannot update fields %v", diff)))
+			return false
+		}
+		if err := c.client.PatchBucket(ctx, b.Status.BucketName, desired, logBucketUpdateMask); err != nil {
+			b.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot update log bucket")))
+			return true
+		}
+	}
+
+	if actual.LifecycleState != lifecycleStateActive {
+		b.Status.SetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	b.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete deletes the log bucket. Cloud Logging retains a deleted bucket, and
+// the logs it stores, for seven days before purging it.
+func (c *logBuckets) Delete(ctx context.Context, b *v1alpha1.LogBucket) bool {
+	b.Status.SetConditions(corev1alpha1.Deleting())
+
+	if b.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.client.DeleteBucket(ctx, b.Status.BucketName); err != nil && !googleapi.IsErrorNotFound(err) {
+			b.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete log bucket")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(b, logBucketFinalizer)
+	b.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// desiredLogBucket returns the Cloud Logging log bucket described by the
+// supplied LogBucket.
+func desiredLogBucket(b *v1alpha1.LogBucket) *loggingv2.LogBucket {
+	return &loggingv2.LogBucket{
+		Description:   b.Spec.Description,
+		RetentionDays: b.Spec.RetentionDays,
+		Locked:        b.Spec.Locked,
+	}
+}
+
+// A logBucketConnecter returns a logBucketCreateSyncDeleter that can create,
+// sync, and delete log buckets with an external store - for example the GCP
+// API.
+type logBucketConnecter interface {
+	Connect(context.Context, *v1alpha1.LogBucket) (logBucketCreateSyncDeleter, error)
+}
+
+// logBucketProviderConnecter is a logBucketConnecter that returns a
+// logBucketCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type logBucketProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns a logBucketCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the
+// supplied LogBucket.
+func (c *logBucketProviderConnecter) Connect(ctx context.Context, b *v1alpha1.LogBucket) (logBucketCreateSyncDeleter, error) {
+	client, p, err := c.connect(ctx, b, b.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &logBuckets{client: client, project: p.Spec.ProjectID}, nil
+}
+
+// LogBucketReconciler reconciles LogBuckets read from the Kubernetes API with
+// an external store, typically the GCP API.
+type LogBucketReconciler struct {
+	logBucketConnecter
+	kube client.Client
+}
+
+// LogBucketController is responsible for adding the LogBucket controller and
+// its corresponding reconciler to the manager with any runtime configuration.
+type LogBucketController struct {
+	// DefaultProvider is used by log buckets that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new LogBucket Controller and adds it to the
+// Manager with default RBAC. The Manager will set fields on the Controller
+// and start it when the Manager is Started.
+func (c *LogBucketController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &LogBucketReconciler{
+		logBucketConnecter: &logBucketProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: cloudlogging.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(logBucketControllerName).
+		For(&v1alpha1.LogBucket{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listLogBuckets)).
+		Complete(r)
+}
+
+// Reconcile Cloud Logging log buckets with the GCP API.
+func (r *LogBucketReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	logBucketLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.LogBucketKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	b := &v1alpha1.LogBucket{}
+	if err := r.kube.Get(ctx, req.NamespacedName, b); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get log bucket %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, b)
+	if err != nil {
+		b.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, b), "cannot update log bucket %s", req.NamespacedName)
+	}
+
+	// The log bucket has been deleted from the API server. Delete it from
+	// GCP.
+	if b.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, b)}, errors.Wrapf(r.kube.Update(ctx, b), "cannot update log bucket %s", req.NamespacedName)
+	}
+
+	// The log bucket is unnamed. Assume it has not been created in GCP.
+	if b.Status.BucketName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, b)}, errors.Wrapf(r.kube.Update(ctx, b), "cannot update log bucket %s", req.NamespacedName)
+	}
+
+	// The log bucket exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, b)}, errors.Wrapf(r.kube.Update(ctx, b), "cannot update log bucket %s", req.NamespacedName)
+}
+
+// listLogBuckets is a provider.Lister of log buckets.
+func listLogBuckets(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.LogBucketList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
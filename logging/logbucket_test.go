@@ -0,0 +1,327 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	loggingv2 "google.golang.org/api/logging/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/logging/v1alpha1"
+	fakelogging "github.com/crossplaneio/crossplane/pkg/clients/gcp/logging/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+type logBucketModifier func(*v1alpha1.LogBucket)
+
+func withLogBucketConditions(c ...corev1alpha1.Condition) logBucketModifier {
+	return func(b *v1alpha1.LogBucket) { b.Status.SetConditions(c...) }
+}
+
+func withLogBucketFinalizers(f ...string) logBucketModifier {
+	return func(b *v1alpha1.LogBucket) { b.ObjectMeta.Finalizers = f }
+}
+
+func withLogBucketReclaimPolicy(r corev1alpha1.ReclaimPolicy) logBucketModifier {
+	return func(b *v1alpha1.LogBucket) { b.Spec.ReclaimPolicy = r }
+}
+
+func withLogBucketName(n string) logBucketModifier {
+	return func(b *v1alpha1.LogBucket) { b.Status.BucketName = n }
+}
+
+func withLifecycleState(s string) logBucketModifier {
+	return func(b *v1alpha1.LogBucket) { b.Status.LifecycleState = s }
+}
+
+func withRetentionDays(d int64) logBucketModifier {
+	return func(b *v1alpha1.LogBucket) { b.Spec.RetentionDays = d }
+}
+
+func logBucket(bm ...logBucketModifier) *v1alpha1.LogBucket {
+	b := &v1alpha1.LogBucket{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       "cool-bucket",
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.LogBucketSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			Description:   "Audit logs",
+			RetentionDays: 400,
+		},
+	}
+
+	for _, m := range bm {
+		m(b)
+	}
+
+	return b
+}
+
+func TestLogBucketCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         logBucketCreateSyncDeleter
+		b           *v1alpha1.LogBucket
+		want        *v1alpha1.LogBucket
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &logBuckets{
+				project: project,
+				client: &fakelogging.MockClient{
+					MockCreateBucket: func(_ context.Context, parent, id string, b *loggingv2.LogBucket) error {
+						if want := bucketParent(project, ""); parent != want {
+							t.Errorf("CreateBucket(...): want parent %s, got %s", want, parent)
+						}
+						if b.RetentionDays != 400 {
+							t.Errorf("CreateBucket(...): want retention of 400 days, got %d", b.RetentionDays)
+						}
+						return nil
+					},
+				},
+			},
+			b: logBucket(),
+			want: logBucket(
+				withLogBucketFinalizers(logBucketFinalizer),
+				withLogBucketName(bucketName),
+				withLogBucketConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &logBuckets{
+				project: project,
+				client: &fakelogging.MockClient{
+					MockCreateBucket: func(_ context.Context, _, _ string, _ *loggingv2.LogBucket) error { return errorBoom },
+				},
+			},
+			b: logBucket(),
+			want: logBucket(
+				withLogBucketConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create log bucket"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.b)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.b, test.EquateConditions()); diff != "" {
+				t.Errorf("b: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLogBucketSync(t *testing.T) {
+	actual := func(state string, locked bool) func(context.Context, string) (*loggingv2.LogBucket, error) {
+		return func(_ context.Context, name string) (*loggingv2.LogBucket, error) {
+			return &loggingv2.LogBucket{
+				Name:           name,
+				Description:    "Audit logs",
+				RetentionDays:  400,
+				Locked:         locked,
+				LifecycleState: state,
+			}, nil
+		}
+	}
+
+	cases := []struct {
+		name        string
+		csd         logBucketCreateSyncDeleter
+		b           *v1alpha1.LogBucket
+		want        *v1alpha1.LogBucket
+		wantRequeue bool
+	}{
+		{
+			name: "UpToDate",
+			csd:  &logBuckets{client: &fakelogging.MockClient{MockGetBucket: actual(lifecycleStateActive, false)}},
+			b:    logBucket(withLogBucketName(bucketName)),
+			want: logBucket(
+				withLogBucketName(bucketName),
+				withLifecycleState(lifecycleStateActive),
+				withLogBucketConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "RetentionChanged",
+			csd: &logBuckets{client: &fakelogging.MockClient{
+				MockGetBucket: actual(lifecycleStateActive, false),
+				MockPatchBucket: func(_ context.Context, _ string, b *loggingv2.LogBucket, mask string) error {
+					if b.RetentionDays != 30 {
+						t.Errorf("PatchBucket(...): want retention of 30 days, got %d", b.RetentionDays)
+					}
+					if mask != logBucketUpdateMask {
+						t.Errorf("PatchBucket(...): want mask %s, got %s", logBucketUpdateMask, mask)
+					}
+					return nil
+				},
+			}},
+			b: logBucket(withLogBucketName(bucketName), withRetentionDays(30)),
+			want: logBucket(
+				withLogBucketName(bucketName),
+				withRetentionDays(30),
+				withLifecycleState(lifecycleStateActive),
+				withLogBucketConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "Locked",
+			csd:  &logBuckets{client: &fakelogging.MockClient{MockGetBucket: actual(lifecycleStateActive, true)}},
+			b:    logBucket(withLogBucketName(bucketName), withRetentionDays(30)),
+			want: logBucket(
+				withLogBucketName(bucketName),
+				withRetentionDays(30),
+				withLifecycleState(lifecycleStateActive),
+				withLogBucketConditions(corev1alpha1.Available(), corev1alpha1.ReconcileError(errors.New("log bucket is locked; cannot update fields [retentionDays]"))),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "DeleteRequested",
+			csd:  &logBuckets{client: &fakelogging.MockClient{MockGetBucket: actual("DELETE_REQUESTED", false)}},
+			b:    logBucket(withLogBucketName(bucketName)),
+			want: logBucket(
+				withLogBucketName(bucketName),
+				withLifecycleState("DELETE_REQUESTED"),
+				withLogBucketConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGet",
+			csd: &logBuckets{client: &fakelogging.MockClient{
+				MockGetBucket: func(_ context.Context, _ string) (*loggingv2.LogBucket, error) { return nil, errorBoom },
+			}},
+			b: logBucket(withLogBucketName(bucketName)),
+			want: logBucket(
+				withLogBucketName(bucketName),
+				withLogBucketConditions(corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.b)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.b, test.EquateConditions()); diff != "" {
+				t.Errorf("b: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLogBucketDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         logBucketCreateSyncDeleter
+		b           *v1alpha1.LogBucket
+		want        *v1alpha1.LogBucket
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimDeleteSuccessful",
+			csd: &logBuckets{client: &fakelogging.MockClient{
+				MockDeleteBucket: func(_ context.Context, _ string) error { return nil },
+			}},
+			b: logBucket(
+				withLogBucketName(bucketName),
+				withLogBucketFinalizers(logBucketFinalizer),
+				withLogBucketReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: logBucket(
+				withLogBucketName(bucketName),
+				withLogBucketReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withLogBucketConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailed",
+			csd: &logBuckets{client: &fakelogging.MockClient{
+				MockDeleteBucket: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			b: logBucket(
+				withLogBucketName(bucketName),
+				withLogBucketFinalizers(logBucketFinalizer),
+				withLogBucketReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: logBucket(
+				withLogBucketName(bucketName),
+				withLogBucketFinalizers(logBucketFinalizer),
+				withLogBucketReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withLogBucketConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot delete log bucket"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "ReclaimRetain",
+			csd:  &logBuckets{client: &fakelogging.MockClient{}},
+			b: logBucket(
+				withLogBucketName(bucketName),
+				withLogBucketFinalizers(logBucketFinalizer),
+				withLogBucketReclaimPolicy(corev1alpha1.ReclaimRetain),
+			),
+			want: logBucket(
+				withLogBucketName(bucketName),
+				withLogBucketReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withLogBucketConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.b)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.b, test.EquateConditions()); diff != "" {
+				t.Errorf("b: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logging contains controllers that provision Cloud Logging sinks,
+// which route log entries to a destination, and the log buckets that store
+// the entries routed to them.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	loggingv2 "google.golang.org/api/logging/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	cloudlogging "github.com/crossplaneio/crossplane/pkg/clients/gcp/logging"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+)
+
+const (
+	reconcileTimeout = 1 * time.Minute
+
+	// locationGlobal is used by log buckets that don't specify a location.
+	locationGlobal = "global"
+
+	// resourceIDPrefix is prepended to the UID of a managed resource to form
+	// the ID of its Cloud Logging resource. IDs must begin with a letter.
+	resourceIDPrefix = "crossplane-"
+)
+
+// providerConnecter returns Cloud Logging clients authenticated using
+// credentials read from a Crossplane Provider resource.
+type providerConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (cloudlogging.Client, error)
+}
+
+// connect returns a Cloud Logging client authenticated using credentials read
+// from the Provider referenced by the supplied managed resource, and that
+// Provider.
+func (c *providerConnecter) connect(ctx context.Context, mg metav1.Object, ref *corev1.ObjectReference) (cloudlogging.Client, *gcpv1alpha1.Provider, error) {
+	p, err := c.providers.Get(ctx, c.kube, mg, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceLogging, loggingv2.LoggingAdminScope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return client, p, errors.Wrap(err, "cannot create new logging client")
+}
+
+// resourceID returns the ID of the Cloud Logging resource of the supplied
+// managed resource.
+func resourceID(mg metav1.Object) string {
+	return resourceIDPrefix + string(mg.GetUID())
+}
+
+// bucketParent returns the fully qualified name of the supplied location
+// within the supplied project, e.g. projects/p/locations/global.
+func bucketParent(project, location string) string {
+	if location == "" {
+		location = locationGlobal
+	}
+	return fmt.Sprintf("projects/%s/locations/%s", project, location)
+}
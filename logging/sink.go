@@ -0,0 +1,313 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	loggingv2 "google.golang.org/api/logging/v2"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/logging/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	cloudlogging "github.com/crossplaneio/crossplane/pkg/clients/gcp/logging"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	sinkControllerName = "sinks.logging.gcp.crossplane.io"
+	sinkFinalizer      = "finalizer." + sinkControllerName
+
+	// sinkUpdateMask lists the fields of a sink that may be updated. Its
+	// parent is immutable.
+	sinkUpdateMask = "destination,filter,description,disabled,exclusions,includeChildren"
+
+	// uniqueWriterIdentity asks Cloud Logging to create a service account
+	// for each sink, to which access to its destination may be granted.
+	uniqueWriterIdentity = true
+
+	// logBucketDestinationPrefix is prepended to the name of a log bucket to
+	// form the destination of a sink that routes to it.
+	logBucketDestinationPrefix = "logging.googleapis.com/"
+)
+
+// sinkOutputFields are the output only fields of a sink.
+var sinkOutputFields = []string{
+	"name",
+	"writerIdentity",
+	"createTime",
+	"updateTime",
+	"exclusions.createTime",
+	"exclusions.updateTime",
+}
+
+var sinkLog = logging.Logger.WithName("controller." + sinkControllerName)
+
+// A sinkCreateSyncDeleter can create, sync, and delete sinks in an external
+// store - e.g. the GCP API. Each method returns true if the sink requires
+// further reconciliation.
+type sinkCreateSyncDeleter interface {
+	Create(ctx context.Context, s *v1alpha1.Sink) (requeue bool)
+	Sync(ctx context.Context, s *v1alpha1.Sink) (requeue bool)
+	Delete(ctx context.Context, s *v1alpha1.Sink) (requeue bool)
+}
+
+// sinks is a sinkCreateSyncDeleter using the Cloud Logging API.
+type sinks struct {
+	client  cloudlogging.Client
+	kube    client.Client
+	project string
+}
+
+// Create creates the desired sink in its parent project, folder, or
+// organization.
+func (c *sinks) Create(ctx context.Context, s *v1alpha1.Sink) bool {
+	s.Status.SetConditions(corev1alpha1.Creating())
+
+	desired, err := c.desiredSink(ctx, s)
+	if err != nil {
+		s.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	parent := c.parent(s)
+	desired.Name = resourceID(s)
+
+	// We may have created the sink but failed to record its name.
+	created, err := c.client.CreateSink(ctx, parent, desired, uniqueWriterIdentity)
+	if err != nil && !gcp.IsErrorAlreadyExists(err) {
+		s.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create sink")))
+		return true
+	}
+	if created != nil {
+		s.Status.WriterIdentity = created.WriterIdentity
+	}
+
+	s.Status.SinkName = parent + "/sinks/" + desired.Name
+	meta.AddFinalizer(s, sinkFinalizer)
+	s.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync updates the sink if it differs from the desired sink, and records its
+// writer identity. The writer identity must be granted access to the sink's
+// destination before log entries are routed to it.
+func (c *sinks) Sync(ctx context.Context, s *v1alpha1.Sink) bool {
+	actual, err := c.client.GetSink(ctx, s.Status.SinkName)
+	if err != nil {
+		s.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+	s.Status.WriterIdentity = actual.WriterIdentity
+
+	desired, err := c.desiredSink(ctx, s)
+	if err != nil {
+		s.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	if !compare.Equal(desired, actual, compare.IgnoreFields(sinkOutputFields...)) {
+		updated, err := c.client.UpdateSink(ctx, s.Status.SinkName, desired, sinkUpdateMask, uniqueWriterIdentity)
+		if err != nil {
+			s.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot update sink")))
+			return true
+		}
+		s.Status.WriterIdentity = updated.WriterIdentity
+	}
+
+	s.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete deletes the sink, and with it the sink's writer identity.
+func (c *sinks) Delete(ctx context.Context, s *v1alpha1.Sink) bool {
+	s.Status.SetConditions(corev1alpha1.Deleting())
+
+	if s.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.client.DeleteSink(ctx, s.Status.SinkName); err != nil && !googleapi.IsErrorNotFound(err) {
+			s.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete sink")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(s, sinkFinalizer)
+	s.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// parent returns the project, folder, or organization in which the supplied
+// sink is created. Sinks are created in the Provider's project unless their
+// spec names another parent, e.g. organizations/123.
+func (c *sinks) parent(s *v1alpha1.Sink) string {
+	if s.Spec.Parent != "" {
+		return s.Spec.Parent
+	}
+	return "projects/" + c.project
+}
+
+// desiredSink returns the Cloud Logging sink described by the supplied Sink.
+// Its destination is read from the referenced LogBucket, if any.
+func (c *sinks) desiredSink(ctx context.Context, s *v1alpha1.Sink) (*loggingv2.LogSink, error) {
+	destination := s.Spec.Destination
+	if s.Spec.LogBucketRef != nil {
+		b := &v1alpha1.LogBucket{}
+		n := types.NamespacedName{Namespace: s.GetNamespace(), Name: s.Spec.LogBucketRef.Name}
+		if err := c.kube.Get(ctx, n, b); err != nil {
+			return nil, errors.Wrapf(err, "cannot get log bucket %s", n)
+		}
+		if b.Status.BucketName == "" {
+			return nil, errors.Errorf("log bucket %s has not been created", n)
+		}
+		destination = logBucketDestinationPrefix + b.Status.BucketName
+	}
+
+	desired := &loggingv2.LogSink{
+		Destination:     destination,
+		Filter:          s.Spec.Filter,
+		Description:     s.Spec.Description,
+		Disabled:        s.Spec.Disabled,
+		IncludeChildren: s.Spec.IncludeChildren,
+	}
+	for _, e := range s.Spec.Exclusions {
+		desired.Exclusions = append(desired.Exclusions, &loggingv2.LogExclusion{
+			Name:        e.Name,
+			Description: e.Description,
+			Filter:      e.Filter,
+			Disabled:    e.Disabled,
+		})
+	}
+	return desired, nil
+}
+
+// A sinkConnecter returns a sinkCreateSyncDeleter that can create, sync, and
+// delete sinks with an external store - for example the GCP API.
+type sinkConnecter interface {
+	Connect(context.Context, *v1alpha1.Sink) (sinkCreateSyncDeleter, error)
+}
+
+// sinkProviderConnecter is a sinkConnecter that returns a
+// sinkCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type sinkProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns a sinkCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the
+// supplied Sink.
+func (c *sinkProviderConnecter) Connect(ctx context.Context, s *v1alpha1.Sink) (sinkCreateSyncDeleter, error) {
+	client, p, err := c.connect(ctx, s, s.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &sinks{client: client, kube: c.kube, project: p.Spec.ProjectID}, nil
+}
+
+// SinkReconciler reconciles Sinks read from the Kubernetes API with an
+// external store, typically the GCP API.
+type SinkReconciler struct {
+	sinkConnecter
+	kube client.Client
+}
+
+// SinkController is responsible for adding the Sink controller and its
+// corresponding reconciler to the manager with any runtime configuration.
+type SinkController struct {
+	// DefaultProvider is used by sinks that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new Sink Controller and adds it to the Manager
+// with default RBAC. The Manager will set fields on the Controller and start
+// it when the Manager is Started.
+func (c *SinkController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &SinkReconciler{
+		sinkConnecter: &sinkProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: cloudlogging.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(sinkControllerName).
+		For(&v1alpha1.Sink{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listSinks)).
+		Complete(r)
+}
+
+// Reconcile Cloud Logging sinks with the GCP API.
+func (r *SinkReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	sinkLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.SinkKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	s := &v1alpha1.Sink{}
+	if err := r.kube.Get(ctx, req.NamespacedName, s); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get sink %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, s)
+	if err != nil {
+		s.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, s), "cannot update sink %s", req.NamespacedName)
+	}
+
+	// The sink has been deleted from the API server. Delete it from GCP.
+	if s.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, s)}, errors.Wrapf(r.kube.Update(ctx, s), "cannot update sink %s", req.NamespacedName)
+	}
+
+	// The sink is unnamed. Assume it has not been created in GCP.
+	if s.Status.SinkName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, s)}, errors.Wrapf(r.kube.Update(ctx, s), "cannot update sink %s", req.NamespacedName)
+	}
+
+	// The sink exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, s)}, errors.Wrapf(r.kube.Update(ctx, s), "cannot update sink %s", req.NamespacedName)
+}
+
+// listSinks is a provider.Lister of sinks.
+func listSinks(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.SinkList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,454 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+	loggingv2 "google.golang.org/api/logging/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/logging/v1alpha1"
+	fakelogging "github.com/crossplaneio/crossplane/pkg/clients/gcp/logging/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace    = "cool-namespace"
+	uid          = types.UID("definitely-a-uuid")
+	project      = "cool-project"
+	providerName = "cool-gcp"
+
+	sinkName       = "projects/cool-project/sinks/crossplane-definitely-a-uuid"
+	bucketName     = "projects/cool-project/locations/global/buckets/crossplane-definitely-a-uuid"
+	writerIdentity = "serviceAccount:cool-writer@gcp-sa-logging.iam.gserviceaccount.com"
+	destination    = "storage.googleapis.com/cool-bucket"
+)
+
+var (
+	ctx           = context.Background()
+	errorBoom     = errors.New("boom")
+	errorNotFound = &googleapi.Error{Code: http.StatusNotFound}
+)
+
+// Test that our Reconciler implementations satisfy the Reconciler interface.
+var (
+	_ reconcile.Reconciler = &SinkReconciler{}
+	_ reconcile.Reconciler = &LogBucketReconciler{}
+)
+
+type sinkModifier func(*v1alpha1.Sink)
+
+func withSinkConditions(c ...corev1alpha1.Condition) sinkModifier {
+	return func(s *v1alpha1.Sink) { s.Status.SetConditions(c...) }
+}
+
+func withSinkFinalizers(f ...string) sinkModifier {
+	return func(s *v1alpha1.Sink) { s.ObjectMeta.Finalizers = f }
+}
+
+func withSinkReclaimPolicy(r corev1alpha1.ReclaimPolicy) sinkModifier {
+	return func(s *v1alpha1.Sink) { s.Spec.ReclaimPolicy = r }
+}
+
+func withSinkName(n string) sinkModifier {
+	return func(s *v1alpha1.Sink) { s.Status.SinkName = n }
+}
+
+func withWriterIdentity(w string) sinkModifier {
+	return func(s *v1alpha1.Sink) { s.Status.WriterIdentity = w }
+}
+
+func withSinkParent(p string) sinkModifier {
+	return func(s *v1alpha1.Sink) { s.Spec.Parent = p }
+}
+
+func withLogBucketRef(n string) sinkModifier {
+	return func(s *v1alpha1.Sink) {
+		s.Spec.Destination = ""
+		s.Spec.LogBucketRef = &corev1.LocalObjectReference{Name: n}
+	}
+}
+
+func withExclusions(e ...v1alpha1.SinkExclusion) sinkModifier {
+	return func(s *v1alpha1.Sink) { s.Spec.Exclusions = e }
+}
+
+func sink(sm ...sinkModifier) *v1alpha1.Sink {
+	s := &v1alpha1.Sink{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       "cool-sink",
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.SinkSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			Destination: destination,
+			Filter:      `logName:"cloudaudit.googleapis.com"`,
+		},
+	}
+
+	for _, m := range sm {
+		m(s)
+	}
+
+	return s
+}
+
+// logBucketGetter returns a kube client that reads a LogBucket with the
+// supplied name.
+func logBucketGetter(name string) client.Client {
+	return &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+		obj.(*v1alpha1.LogBucket).Status.BucketName = name
+		return nil
+	}}
+}
+
+func TestSinkCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         sinkCreateSyncDeleter
+		s           *v1alpha1.Sink
+		want        *v1alpha1.Sink
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &sinks{
+				project: project,
+				client: &fakelogging.MockClient{
+					MockCreateSink: func(_ context.Context, parent string, s *loggingv2.LogSink, unique bool) (*loggingv2.LogSink, error) {
+						if parent != "projects/"+project {
+							t.Errorf("CreateSink(...): want parent projects/%s, got %s", project, parent)
+						}
+						if s.Destination != destination {
+							t.Errorf("CreateSink(...): want destination %s, got %s", destination, s.Destination)
+						}
+						if !unique {
+							t.Errorf("CreateSink(...): want unique writer identity")
+						}
+						return &loggingv2.LogSink{Name: s.Name, WriterIdentity: writerIdentity}, nil
+					},
+				},
+			},
+			s: sink(),
+			want: sink(
+				withSinkFinalizers(sinkFinalizer),
+				withSinkName(sinkName),
+				withWriterIdentity(writerIdentity),
+				withSinkConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "OrganizationSink",
+			csd: &sinks{
+				project: project,
+				client: &fakelogging.MockClient{
+					MockCreateSink: func(_ context.Context, parent string, s *loggingv2.LogSink, _ bool) (*loggingv2.LogSink, error) {
+						if parent != "organizations/42" {
+							t.Errorf("CreateSink(...): want parent organizations/42, got %s", parent)
+						}
+						return &loggingv2.LogSink{Name: s.Name, WriterIdentity: writerIdentity}, nil
+					},
+				},
+			},
+			s: sink(withSinkParent("organizations/42")),
+			want: sink(
+				withSinkParent("organizations/42"),
+				withSinkFinalizers(sinkFinalizer),
+				withSinkName("organizations/42/sinks/crossplane-definitely-a-uuid"),
+				withWriterIdentity(writerIdentity),
+				withSinkConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "LogBucketDestination",
+			csd: &sinks{
+				project: project,
+				kube:    logBucketGetter(bucketName),
+				client: &fakelogging.MockClient{
+					MockCreateSink: func(_ context.Context, _ string, s *loggingv2.LogSink, _ bool) (*loggingv2.LogSink, error) {
+						if want := "logging.googleapis.com/" + bucketName; s.Destination != want {
+							t.Errorf("CreateSink(...): want destination %s, got %s", want, s.Destination)
+						}
+						return &loggingv2.LogSink{Name: s.Name, WriterIdentity: writerIdentity}, nil
+					},
+				},
+			},
+			s: sink(withLogBucketRef("cool-bucket")),
+			want: sink(
+				withLogBucketRef("cool-bucket"),
+				withSinkFinalizers(sinkFinalizer),
+				withSinkName(sinkName),
+				withWriterIdentity(writerIdentity),
+				withSinkConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "LogBucketNotCreated",
+			csd: &sinks{
+				project: project,
+				kube:    logBucketGetter(""),
+				client:  &fakelogging.MockClient{},
+			},
+			s: sink(withLogBucketRef("cool-bucket")),
+			want: sink(
+				withLogBucketRef("cool-bucket"),
+				withSinkConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.New("log bucket cool-namespace/cool-bucket has not been created"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &sinks{
+				project: project,
+				client: &fakelogging.MockClient{
+					MockCreateSink: func(_ context.Context, _ string, _ *loggingv2.LogSink, _ bool) (*loggingv2.LogSink, error) {
+						return nil, errorBoom
+					},
+				},
+			},
+			s: sink(),
+			want: sink(
+				withSinkConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create sink"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.s)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.s, test.EquateConditions()); diff != "" {
+				t.Errorf("s: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSinkSync(t *testing.T) {
+	exclusion := v1alpha1.SinkExclusion{Name: "no-debug", Filter: "severity<INFO"}
+	upToDate := func(_ context.Context, name string) (*loggingv2.LogSink, error) {
+		return &loggingv2.LogSink{
+			Name:           name,
+			Destination:    destination,
+			Filter:         `logName:"cloudaudit.googleapis.com"`,
+			WriterIdentity: writerIdentity,
+			CreateTime:     "2019-10-01T00:00:00Z",
+		}, nil
+	}
+
+	cases := []struct {
+		name        string
+		csd         sinkCreateSyncDeleter
+		s           *v1alpha1.Sink
+		want        *v1alpha1.Sink
+		wantRequeue bool
+	}{
+		{
+			name: "UpToDate",
+			csd:  &sinks{client: &fakelogging.MockClient{MockGetSink: upToDate}},
+			s:    sink(withSinkName(sinkName)),
+			want: sink(
+				withSinkName(sinkName),
+				withWriterIdentity(writerIdentity),
+				withSinkConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ExclusionAdded",
+			csd: &sinks{client: &fakelogging.MockClient{
+				MockGetSink: upToDate,
+				MockUpdateSink: func(_ context.Context, _ string, s *loggingv2.LogSink, mask string, _ bool) (*loggingv2.LogSink, error) {
+					if len(s.Exclusions) != 1 || s.Exclusions[0].Name != exclusion.Name {
+						t.Errorf("UpdateSink(...): want exclusion %s", exclusion.Name)
+					}
+					if mask != sinkUpdateMask {
+						t.Errorf("UpdateSink(...): want mask %s, got %s", sinkUpdateMask, mask)
+					}
+					return &loggingv2.LogSink{WriterIdentity: writerIdentity}, nil
+				},
+			}},
+			s: sink(withSinkName(sinkName), withExclusions(exclusion)),
+			want: sink(
+				withSinkName(sinkName),
+				withExclusions(exclusion),
+				withWriterIdentity(writerIdentity),
+				withSinkConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedUpdate",
+			csd: &sinks{client: &fakelogging.MockClient{
+				MockGetSink: upToDate,
+				MockUpdateSink: func(_ context.Context, _ string, _ *loggingv2.LogSink, _ string, _ bool) (*loggingv2.LogSink, error) {
+					return nil, errorBoom
+				},
+			}},
+			s: sink(withSinkName(sinkName), withExclusions(exclusion)),
+			want: sink(
+				withSinkName(sinkName),
+				withExclusions(exclusion),
+				withWriterIdentity(writerIdentity),
+				withSinkConditions(corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot update sink"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGet",
+			csd: &sinks{client: &fakelogging.MockClient{
+				MockGetSink: func(_ context.Context, _ string) (*loggingv2.LogSink, error) { return nil, errorBoom },
+			}},
+			s: sink(withSinkName(sinkName)),
+			want: sink(
+				withSinkName(sinkName),
+				withSinkConditions(corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.s)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.s, test.EquateConditions()); diff != "" {
+				t.Errorf("s: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSinkDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         sinkCreateSyncDeleter
+		s           *v1alpha1.Sink
+		want        *v1alpha1.Sink
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimDeleteSuccessful",
+			csd: &sinks{client: &fakelogging.MockClient{
+				MockDeleteSink: func(_ context.Context, _ string) error { return nil },
+			}},
+			s: sink(
+				withSinkName(sinkName),
+				withSinkFinalizers(sinkFinalizer),
+				withSinkReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: sink(
+				withSinkName(sinkName),
+				withSinkReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withSinkConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteNotFound",
+			csd: &sinks{client: &fakelogging.MockClient{
+				MockDeleteSink: func(_ context.Context, _ string) error { return errorNotFound },
+			}},
+			s: sink(
+				withSinkName(sinkName),
+				withSinkFinalizers(sinkFinalizer),
+				withSinkReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: sink(
+				withSinkName(sinkName),
+				withSinkReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withSinkConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailed",
+			csd: &sinks{client: &fakelogging.MockClient{
+				MockDeleteSink: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			s: sink(
+				withSinkName(sinkName),
+				withSinkFinalizers(sinkFinalizer),
+				withSinkReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: sink(
+				withSinkName(sinkName),
+				withSinkFinalizers(sinkFinalizer),
+				withSinkReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withSinkConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot delete sink"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "ReclaimRetain",
+			csd:  &sinks{client: &fakelogging.MockClient{}},
+			s: sink(
+				withSinkName(sinkName),
+				withSinkFinalizers(sinkFinalizer),
+				withSinkReclaimPolicy(corev1alpha1.ReclaimRetain),
+			),
+			want: sink(
+				withSinkName(sinkName),
+				withSinkReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withSinkConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.s)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.s, test.EquateConditions()); diff != "" {
+				t.Errorf("s: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	monitoringv3 "google.golang.org/api/monitoring/v3"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/monitoring/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/monitoring"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	alertPolicyControllerName = "alertpolicies.monitoring.gcp.crossplane.io"
+	alertPolicyFinalizer      = "finalizer." + alertPolicyControllerName
+
+	// alertPolicyUpdateMask lists the fields of an alert policy that may be
+	// updated.
+	alertPolicyUpdateMask = "displayName,combiner,conditions,notificationChannels,documentation,enabled,userLabels"
+
+	// alertPolicyDocumentationMimeType is the only format of alert policy
+	// documentation supported by Cloud Monitoring.
+	alertPolicyDocumentationMimeType = "text/markdown"
+)
+
+// alertPolicyOutputFields are the output only fields of an alert policy.
+var alertPolicyOutputFields = []string{"name", "creationRecord", "mutationRecord"}
+
+var alertPolicyLog = logging.Logger.WithName("controller." + alertPolicyControllerName)
+
+// An alertPolicyCreateSyncDeleter can create, sync, and delete alert policies
+// in an external store - e.g. the GCP API. Each method returns true if the
+// alert policy requires further reconciliation.
+type alertPolicyCreateSyncDeleter interface {
+	Create(ctx context.Context, p *v1alpha1.AlertPolicy) (requeue bool)
+	Sync(ctx context.Context, p *v1alpha1.AlertPolicy) (requeue bool)
+	Delete(ctx context.Context, p *v1alpha1.AlertPolicy) (requeue bool)
+}
+
+// alertPolicies is an alertPolicyCreateSyncDeleter using the Cloud Monitoring
+// API. Notification channels are read from the Kubernetes API.
+type alertPolicies struct {
+	client  monitoring.Client
+	kube    client.Client
+	project string
+}
+
+// Create creates the desired alert policy once the notification channels it
+// notifies exist. Cloud Monitoring names the policy when it is created.
+func (s *alertPolicies) Create(ctx context.Context, p *v1alpha1.AlertPolicy) bool {
+	p.Status.SetConditions(corev1alpha1.Creating())
+
+	channels, err := s.notificationChannels(ctx, p)
+	if err != nil {
+		p.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	created, err := s.client.CreateAlertPolicy(ctx, projectName(s.project), desiredAlertPolicy(p, channels))
+	if err != nil {
+		p.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create alert policy")))
+		return true
+	}
+
+	p.Status.AlertPolicyName = created.Name
+	meta.AddFinalizer(p, alertPolicyFinalizer)
+	p.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync updates the alert policy if it differs from the desired policy.
+func (s *alertPolicies) Sync(ctx context.Context, p *v1alpha1.AlertPolicy) bool {
+	channels, err := s.notificationChannels(ctx, p)
+	if err != nil {
+		p.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	actual, err := s.client.GetAlertPolicy(ctx, p.Status.AlertPolicyName)
+	if err != nil {
+		p.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	desired := desiredAlertPolicy(p, channels)
+	if !compare.Equal(desired, actual, compare.IgnoreFields(alertPolicyOutputFields...), compare.IgnoreUnset()) {
+		if err := s.client.PatchAlertPolicy(ctx, p.Status.AlertPolicyName, desired, alertPolicyUpdateMask); err != nil {
+			p.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot update alert policy")))
+			return true
+		}
+	}
+
+	p.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete deletes the alert policy.
+func (s *alertPolicies) Delete(ctx context.Context, p *v1alpha1.AlertPolicy) bool {
+	p.Status.SetConditions(corev1alpha1.Deleting())
+
+	if p.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := s.client.DeleteAlertPolicy(ctx, p.Status.AlertPolicyName); err != nil && !googleapi.IsErrorNotFound(err) {
+			p.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete alert policy")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(p, alertPolicyFinalizer)
+	p.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// notificationChannels returns the Cloud Monitoring names of the notification
+// channels referenced by the supplied alert policy. Each channel must have
+// been created in GCP.
+func (s *alertPolicies) notificationChannels(ctx context.Context, p *v1alpha1.AlertPolicy) ([]string, error) {
+	names := make([]string, 0, len(p.Spec.NotificationChannelRefs))
+	for _, ref := range p.Spec.NotificationChannelRefs {
+		c := &v1alpha1.NotificationChannel{}
+		if err := s.kube.Get(ctx, types.NamespacedName{Namespace: p.GetNamespace(), Name: ref.Name}, c); err != nil {
+			return nil, errors.Wrapf(err, "cannot get notification channel %s", ref.Name)
+		}
+		if c.Status.NotificationChannelName == "" {
+			return nil, errors.Errorf("notification channel %s has not been created", ref.Name)
+		}
+		names = append(names, c.Status.NotificationChannelName)
+	}
+	return names, nil
+}
+
+// desiredAlertPolicy returns the Cloud Monitoring alert policy described by
+// the supplied AlertPolicy, which notifies the supplied notification channels.
+func desiredAlertPolicy(p *v1alpha1.AlertPolicy, channels []string) *monitoringv3.AlertPolicy {
+	ap := &monitoringv3.AlertPolicy{
+		DisplayName:          p.Spec.DisplayName,
+		Combiner:             p.Spec.Combiner,
+		Conditions:           make([]*monitoringv3.Condition, 0, len(p.Spec.Conditions)),
+		NotificationChannels: channels,
+		UserLabels:           p.Spec.UserLabels,
+		Enabled:              enabled(p.Spec.Enabled),
+		// Send false explicitly so that the policy may be disabled.
+		ForceSendFields: []string{"Enabled"},
+	}
+
+	for _, c := range p.Spec.Conditions {
+		ap.Conditions = append(ap.Conditions, &monitoringv3.Condition{
+			DisplayName: c.DisplayName,
+			ConditionThreshold: &monitoringv3.MetricThreshold{
+				Filter:         c.Filter,
+				Comparison:     c.Comparison,
+				ThresholdValue: c.ThresholdValue,
+				Duration:       c.Duration,
+				// Send a zero threshold explicitly, e.g. to alert on any
+				// failed uptime check.
+				ForceSendFields: []string{"ThresholdValue"},
+			},
+		})
+	}
+
+	if p.Spec.Documentation != "" {
+		ap.Documentation = &monitoringv3.Documentation{
+			Content:  p.Spec.Documentation,
+			MimeType: alertPolicyDocumentationMimeType,
+		}
+	}
+
+	return ap
+}
+
+// An alertPolicyConnecter returns an alertPolicyCreateSyncDeleter that can
+// create, sync, and delete alert policies with an external store - for example
+// the GCP API.
+type alertPolicyConnecter interface {
+	Connect(context.Context, *v1alpha1.AlertPolicy) (alertPolicyCreateSyncDeleter, error)
+}
+
+// alertPolicyProviderConnecter is an alertPolicyConnecter that returns an
+// alertPolicyCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type alertPolicyProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns an alertPolicyCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the
+// supplied AlertPolicy.
+func (c *alertPolicyProviderConnecter) Connect(ctx context.Context, p *v1alpha1.AlertPolicy) (alertPolicyCreateSyncDeleter, error) {
+	client, pr, err := c.connect(ctx, p, p.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &alertPolicies{client: client, kube: c.kube, project: pr.Spec.ProjectID}, nil
+}
+
+// AlertPolicyReconciler reconciles AlertPolicies read from the Kubernetes API
+// with an external store, typically the GCP API.
+type AlertPolicyReconciler struct {
+	alertPolicyConnecter
+	kube client.Client
+}
+
+// AlertPolicyController is responsible for adding the AlertPolicy controller
+// and its corresponding reconciler to the manager with any runtime
+// configuration.
+type AlertPolicyController struct {
+	// DefaultProvider is used by alert policies that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new AlertPolicy Controller and adds it to the
+// Manager with default RBAC. The Manager will set fields on the Controller and
+// start it when the Manager is Started.
+func (c *AlertPolicyController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &AlertPolicyReconciler{
+		alertPolicyConnecter: &alertPolicyProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: monitoring.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(alertPolicyControllerName).
+		For(&v1alpha1.AlertPolicy{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listAlertPolicies)).
+		Complete(r)
+}
+
+// Reconcile Cloud Monitoring alert policies with the GCP API.
+func (r *AlertPolicyReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	alertPolicyLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.AlertPolicyKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	p := &v1alpha1.AlertPolicy{}
+	if err := r.kube.Get(ctx, req.NamespacedName, p); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get alert policy %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, p)
+	if err != nil {
+		p.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, p), "cannot update alert policy %s", req.NamespacedName)
+	}
+
+	// The alert policy has been deleted from the API server. Delete it from
+	// GCP.
+	if p.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, p)}, errors.Wrapf(r.kube.Update(ctx, p), "cannot update alert policy %s", req.NamespacedName)
+	}
+
+	// The alert policy is unnamed. Assume it has not been created in GCP.
+	if p.Status.AlertPolicyName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, p)}, errors.Wrapf(r.kube.Update(ctx, p), "cannot update alert policy %s", req.NamespacedName)
+	}
+
+	// The alert policy exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, p)}, errors.Wrapf(r.kube.Update(ctx, p), "cannot update alert policy %s", req.NamespacedName)
+}
+
+// listAlertPolicies is a provider.Lister of alert policies.
+func listAlertPolicies(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.AlertPolicyList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,395 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+	monitoringv3 "google.golang.org/api/monitoring/v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/monitoring/v1alpha1"
+	fakemonitoring "github.com/crossplaneio/crossplane/pkg/clients/gcp/monitoring/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace    = "cool-namespace"
+	name         = "cool-policy"
+	uid          = types.UID("definitely-a-uuid")
+	project      = "cool-project"
+	providerName = "cool-gcp"
+
+	alertPolicyName = "projects/cool-project/alertPolicies/42"
+	channelName     = "projects/cool-project/notificationChannels/7"
+)
+
+var (
+	ctx           = context.Background()
+	errorBoom     = errors.New("boom")
+	errorNotFound = &googleapi.Error{Code: http.StatusNotFound}
+)
+
+// Test that our Reconciler implementations satisfy the Reconciler interface.
+var (
+	_ reconcile.Reconciler = &AlertPolicyReconciler{}
+	_ reconcile.Reconciler = &NotificationChannelReconciler{}
+	_ reconcile.Reconciler = &UptimeCheckReconciler{}
+	_ reconcile.Reconciler = &BaselineReconciler{}
+)
+
+type alertPolicyModifier func(*v1alpha1.AlertPolicy)
+
+func withAlertPolicyConditions(c ...corev1alpha1.Condition) alertPolicyModifier {
+	return func(p *v1alpha1.AlertPolicy) { p.Status.SetConditions(c...) }
+}
+
+func withAlertPolicyFinalizers(f ...string) alertPolicyModifier {
+	return func(p *v1alpha1.AlertPolicy) { p.ObjectMeta.Finalizers = f }
+}
+
+func withAlertPolicyReclaimPolicy(r corev1alpha1.ReclaimPolicy) alertPolicyModifier {
+	return func(p *v1alpha1.AlertPolicy) { p.Spec.ReclaimPolicy = r }
+}
+
+func withAlertPolicyName(n string) alertPolicyModifier {
+	return func(p *v1alpha1.AlertPolicy) { p.Status.AlertPolicyName = n }
+}
+
+func withNotificationChannelRefs(n ...string) alertPolicyModifier {
+	return func(p *v1alpha1.AlertPolicy) {
+		for _, name := range n {
+			p.Spec.NotificationChannelRefs = append(p.Spec.NotificationChannelRefs, corev1.LocalObjectReference{Name: name})
+		}
+	}
+}
+
+func alertPolicy(pm ...alertPolicyModifier) *v1alpha1.AlertPolicy {
+	p := &v1alpha1.AlertPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.AlertPolicySpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			DisplayName: "Cool policy",
+			Combiner:    "OR",
+			Conditions: []v1alpha1.AlertPolicyCondition{{
+				DisplayName:    "CPU utilization",
+				Filter:         `metric.type="cloudsql.googleapis.com/database/cpu/utilization"`,
+				Comparison:     "COMPARISON_GT",
+				ThresholdValue: 0.9,
+				Duration:       "300s",
+			}},
+		},
+	}
+
+	for _, m := range pm {
+		m(p)
+	}
+
+	return p
+}
+
+func TestAlertPolicyCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         alertPolicyCreateSyncDeleter
+		p           *v1alpha1.AlertPolicy
+		want        *v1alpha1.AlertPolicy
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &alertPolicies{
+				project: project,
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						notificationChannel(withNotificationChannelName(channelName)).DeepCopyInto(obj.(*v1alpha1.NotificationChannel))
+						return nil
+					},
+				},
+				client: &fakemonitoring.MockClient{
+					MockCreateAlertPolicy: func(_ context.Context, parent string, p *monitoringv3.AlertPolicy) (*monitoringv3.AlertPolicy, error) {
+						if parent != projectName(project) {
+							t.Errorf("CreateAlertPolicy(...): want parent %s, got %s", projectName(project), parent)
+						}
+						if diff := cmp.Diff([]string{channelName}, p.NotificationChannels); diff != "" {
+							t.Errorf("CreateAlertPolicy(...): -want notification channels, +got:\n%s", diff)
+						}
+						return &monitoringv3.AlertPolicy{Name: alertPolicyName}, nil
+					},
+				},
+			},
+			p: alertPolicy(withNotificationChannelRefs("cool-channel")),
+			want: alertPolicy(
+				withNotificationChannelRefs("cool-channel"),
+				withAlertPolicyFinalizers(alertPolicyFinalizer),
+				withAlertPolicyName(alertPolicyName),
+				withAlertPolicyConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "NotificationChannelNotCreated",
+			csd: &alertPolicies{
+				project: project,
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						notificationChannel().DeepCopyInto(obj.(*v1alpha1.NotificationChannel))
+						return nil
+					},
+				},
+				client: &fakemonitoring.MockClient{},
+			},
+			p: alertPolicy(withNotificationChannelRefs("cool-channel")),
+			want: alertPolicy(
+				withNotificationChannelRefs("cool-channel"),
+				withAlertPolicyConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.New("notification channel cool-channel has not been created"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &alertPolicies{
+				project: project,
+				client: &fakemonitoring.MockClient{
+					MockCreateAlertPolicy: func(_ context.Context, _ string, _ *monitoringv3.AlertPolicy) (*monitoringv3.AlertPolicy, error) {
+						return nil, errorBoom
+					},
+				},
+			},
+			p: alertPolicy(),
+			want: alertPolicy(
+				withAlertPolicyConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create alert policy"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.p)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.p, test.EquateConditions()); diff != "" {
+				t.Errorf("p: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAlertPolicySync(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         alertPolicyCreateSyncDeleter
+		p           *v1alpha1.AlertPolicy
+		want        *v1alpha1.AlertPolicy
+		wantRequeue bool
+	}{
+		{
+			name: "UpToDate",
+			csd: &alertPolicies{client: &fakemonitoring.MockClient{
+				MockGetAlertPolicy: func(_ context.Context, _ string) (*monitoringv3.AlertPolicy, error) {
+					actual := desiredAlertPolicy(alertPolicy(), []string{})
+					actual.Name = alertPolicyName
+					return actual, nil
+				},
+			}},
+			p: alertPolicy(withAlertPolicyName(alertPolicyName)),
+			want: alertPolicy(
+				withAlertPolicyName(alertPolicyName),
+				withAlertPolicyConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "Updated",
+			csd: &alertPolicies{client: &fakemonitoring.MockClient{
+				MockGetAlertPolicy: func(_ context.Context, _ string) (*monitoringv3.AlertPolicy, error) {
+					return &monitoringv3.AlertPolicy{Name: alertPolicyName, DisplayName: "Old policy"}, nil
+				},
+				MockPatchAlertPolicy: func(_ context.Context, n string, _ *monitoringv3.AlertPolicy, mask string) error {
+					if n != alertPolicyName {
+						t.Errorf("PatchAlertPolicy(...): want name %s, got %s", alertPolicyName, n)
+					}
+					if mask != alertPolicyUpdateMask {
+						t.Errorf("PatchAlertPolicy(...): want mask %s, got %s", alertPolicyUpdateMask, mask)
+					}
+					return nil
+				},
+			}},
+			p: alertPolicy(withAlertPolicyName(alertPolicyName)),
+			want: alertPolicy(
+				withAlertPolicyName(alertPolicyName),
+				withAlertPolicyConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedUpdate",
+			csd: &alertPolicies{client: &fakemonitoring.MockClient{
+				MockGetAlertPolicy: func(_ context.Context, _ string) (*monitoringv3.AlertPolicy, error) {
+					return &monitoringv3.AlertPolicy{Name: alertPolicyName, DisplayName: "Old policy"}, nil
+				},
+				MockPatchAlertPolicy: func(_ context.Context, _ string, _ *monitoringv3.AlertPolicy, _ string) error { return errorBoom },
+			}},
+			p: alertPolicy(withAlertPolicyName(alertPolicyName)),
+			want: alertPolicy(
+				withAlertPolicyName(alertPolicyName),
+				withAlertPolicyConditions(corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot update alert policy"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGet",
+			csd: &alertPolicies{client: &fakemonitoring.MockClient{
+				MockGetAlertPolicy: func(_ context.Context, _ string) (*monitoringv3.AlertPolicy, error) { return nil, errorBoom },
+			}},
+			p: alertPolicy(withAlertPolicyName(alertPolicyName)),
+			want: alertPolicy(
+				withAlertPolicyName(alertPolicyName),
+				withAlertPolicyConditions(corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.p)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.p, test.EquateConditions()); diff != "" {
+				t.Errorf("p: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAlertPolicyDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         alertPolicyCreateSyncDeleter
+		p           *v1alpha1.AlertPolicy
+		want        *v1alpha1.AlertPolicy
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimDeleteSuccessful",
+			csd: &alertPolicies{client: &fakemonitoring.MockClient{
+				MockDeleteAlertPolicy: func(_ context.Context, _ string) error { return nil },
+			}},
+			p: alertPolicy(
+				withAlertPolicyName(alertPolicyName),
+				withAlertPolicyFinalizers(alertPolicyFinalizer),
+				withAlertPolicyReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: alertPolicy(
+				withAlertPolicyName(alertPolicyName),
+				withAlertPolicyReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withAlertPolicyConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteNotFound",
+			csd: &alertPolicies{client: &fakemonitoring.MockClient{
+				MockDeleteAlertPolicy: func(_ context.Context, _ string) error { return errorNotFound },
+			}},
+			p: alertPolicy(
+				withAlertPolicyName(alertPolicyName),
+				withAlertPolicyFinalizers(alertPolicyFinalizer),
+				withAlertPolicyReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: alertPolicy(
+				withAlertPolicyName(alertPolicyName),
+				withAlertPolicyReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withAlertPolicyConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailed",
+			csd: &alertPolicies{client: &fakemonitoring.MockClient{
+				MockDeleteAlertPolicy: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			p: alertPolicy(
+				withAlertPolicyName(alertPolicyName),
+				withAlertPolicyFinalizers(alertPolicyFinalizer),
+				withAlertPolicyReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: alertPolicy(
+				withAlertPolicyName(alertPolicyName),
+				withAlertPolicyFinalizers(alertPolicyFinalizer),
+				withAlertPolicyReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withAlertPolicyConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot delete alert policy"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "ReclaimRetain",
+			csd:  &alertPolicies{client: &fakemonitoring.MockClient{}},
+			p: alertPolicy(
+				withAlertPolicyName(alertPolicyName),
+				withAlertPolicyFinalizers(alertPolicyFinalizer),
+				withAlertPolicyReclaimPolicy(corev1alpha1.ReclaimRetain),
+			),
+			want: alertPolicy(
+				withAlertPolicyName(alertPolicyName),
+				withAlertPolicyReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withAlertPolicyConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.p)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.p, test.EquateConditions()); diff != "" {
+				t.Errorf("p: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	gcpdatabasev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/monitoring/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/externalname"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+	"github.com/crossplaneio/crossplane/pkg/util"
+)
+
+const (
+	baselineControllerName = "baseline.monitoring.gcp.crossplane.io"
+
+	// Baseline alert policies fire when a utilization ratio exceeds the
+	// threshold for the duration.
+	baselineThreshold  = 0.9
+	baselineDuration   = "300s"
+	baselineComparison = "COMPARISON_GT"
+	baselineCombiner   = "OR"
+)
+
+var baselineLog = logging.Logger.WithName("controller." + baselineControllerName)
+
+// A baselineMetric is a utilization metric of a managed resource that is
+// alerted on by a baseline alert policy.
+type baselineMetric struct {
+	suffix      string
+	displayName string
+	metricType  string
+}
+
+var (
+	cloudsqlBaselineMetrics = []baselineMetric{
+		{suffix: "cpu", displayName: "CPU utilization", metricType: "cloudsql.googleapis.com/database/cpu/utilization"},
+		{suffix: "disk", displayName: "Disk utilization", metricType: "cloudsql.googleapis.com/database/disk/utilization"},
+	}
+	gkeBaselineMetrics = []baselineMetric{
+		{suffix: "cpu", displayName: "Node CPU utilization", metricType: "kubernetes.io/node/cpu/allocatable_utilization"},
+		{suffix: "memory", displayName: "Node memory utilization", metricType: "kubernetes.io/node/memory/allocatable_utilization"},
+	}
+)
+
+// baselinePolicies returns the baseline alert policies asked for by the
+// supplied managed resource. It returns no policies if the resource does not
+// ask for them, or has not yet been named in GCP.
+type baselinePolicies func(mg resource.Managed) []*v1alpha1.AlertPolicy
+
+// cloudsqlBaselinePolicies returns the baseline alert policies of a
+// CloudsqlInstance.
+func cloudsqlBaselinePolicies(mg resource.Managed) []*v1alpha1.AlertPolicy {
+	i, ok := mg.(*gcpdatabasev1alpha1.CloudsqlInstance)
+	if !ok || i.Spec.BaselineAlerts == nil {
+		return nil
+	}
+
+	// Cloud SQL database IDs are of the form project:instance.
+	filter := fmt.Sprintf("resource.type=\"cloudsql_database\" AND resource.label.database_id=ends_with(\":%s\")", externalname.Resolve(i, i.GetResourceName()))
+	return baselineAlertPolicies(i, gcpdatabasev1alpha1.CloudsqlInstanceGroupVersionKind, i.Spec.ProviderReference, i.Spec.BaselineAlerts, filter, cloudsqlBaselineMetrics)
+}
+
+// gkeBaselinePolicies returns the baseline alert policies of a GKECluster.
+func gkeBaselinePolicies(mg resource.Managed) []*v1alpha1.AlertPolicy {
+	c, ok := mg.(*gcpcomputev1alpha1.GKECluster)
+	if !ok || c.Spec.BaselineAlerts == nil || c.Status.ClusterName == "" {
+		return nil
+	}
+
+	filter := fmt.Sprintf("resource.type=\"k8s_node\" AND resource.label.cluster_name=%q AND resource.label.location=%q", c.Status.ClusterName, c.Spec.Zone)
+	return baselineAlertPolicies(c, gcpcomputev1alpha1.GKEClusterGroupVersionKind, c.Spec.ProviderReference, c.Spec.BaselineAlerts, filter, gkeBaselineMetrics)
+}
+
+// baselineAlertPolicies returns an alert policy for each of the supplied
+// metrics of the managed resource selected by the supplied filter. The
+// policies are controlled by, and thus garbage collected with, the managed
+// resource.
+func baselineAlertPolicies(mg resource.Managed, gvk schema.GroupVersionKind, ref *corev1.ObjectReference, b *v1alpha1.BaselineAlerts, filter string, metrics []baselineMetric) []*v1alpha1.AlertPolicy {
+	policies := make([]*v1alpha1.AlertPolicy, 0, len(metrics))
+	for _, m := range metrics {
+		p := &v1alpha1.AlertPolicy{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       mg.GetNamespace(),
+				Name:            fmt.Sprintf("%s-%s", mg.GetName(), m.suffix),
+				OwnerReferences: []metav1.OwnerReference{meta.AsController(meta.ReferenceTo(mg, gvk))},
+			},
+		}
+		p.Spec = v1alpha1.AlertPolicySpec{
+			DisplayName: fmt.Sprintf("%s %s", mg.GetName(), m.displayName),
+			Combiner:    baselineCombiner,
+			Conditions: []v1alpha1.AlertPolicyCondition{{
+				DisplayName:    m.displayName,
+				Filter:         fmt.Sprintf("metric.type=%q AND %s", m.metricType, filter),
+				Comparison:     baselineComparison,
+				ThresholdValue: baselineThreshold,
+				Duration:       baselineDuration,
+			}},
+			NotificationChannelRefs: b.NotificationChannelRefs,
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: ref,
+				ReclaimPolicy:     corev1alpha1.ReclaimDelete,
+			},
+		}
+		policies = append(policies, p)
+	}
+	return policies
+}
+
+// A BaselineReconciler creates the baseline alert policies asked for by a
+// kind of managed resource, and deletes them when they are no longer asked
+// for.
+type BaselineReconciler struct {
+	kube       client.Client
+	newManaged func() resource.Managed
+	policies   baselinePolicies
+}
+
+// BaselineController is responsible for adding the baseline alert policy
+// controllers of CloudsqlInstances and GKEClusters to the manager.
+type BaselineController struct{}
+
+// SetupWithManager creates a baseline alert policy controller for each kind
+// of managed resource that may ask for baseline alert policies, and adds them
+// to the Manager. The Manager will set fields on the Controllers and start
+// them when the Manager is Started.
+func (c *BaselineController) SetupWithManager(mgr ctrl.Manager) error {
+	kinds := []struct {
+		name       string
+		newManaged func() resource.Managed
+		policies   baselinePolicies
+	}{
+		{
+			name:       "cloudsqlinstance." + baselineControllerName,
+			newManaged: func() resource.Managed { return &gcpdatabasev1alpha1.CloudsqlInstance{} },
+			policies:   cloudsqlBaselinePolicies,
+		},
+		{
+			name:       "gkecluster." + baselineControllerName,
+			newManaged: func() resource.Managed { return &gcpcomputev1alpha1.GKECluster{} },
+			policies:   gkeBaselinePolicies,
+		},
+	}
+
+	for _, k := range kinds {
+		r := &BaselineReconciler{kube: mgr.GetClient(), newManaged: k.newManaged, policies: k.policies}
+		if err := ctrl.NewControllerManagedBy(mgr).
+			Named(k.name).
+			For(k.newManaged()).
+			Owns(&v1alpha1.AlertPolicy{}).
+			Complete(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reconcile the baseline alert policies of a managed resource.
+func (r *BaselineReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	baselineLog.V(logging.Debug).Info("reconciling", "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	mg := r.newManaged()
+	if err := r.kube.Get(ctx, req.NamespacedName, mg); err != nil {
+		// Baseline alert policies are garbage collected with the managed
+		// resource that controls them.
+		return reconcile.Result{Requeue: false}, errors.Wrapf(resource.Ignore(kerrors.IsNotFound, err), "cannot get managed resource %s", req.NamespacedName)
+	}
+
+	want := map[string]bool{}
+	if mg.GetDeletionTimestamp() == nil {
+		for _, p := range r.policies(mg) {
+			if err := r.apply(ctx, p); err != nil {
+				return reconcile.Result{Requeue: true}, err
+			}
+			want[p.GetName()] = true
+		}
+	}
+
+	// Delete the baseline alert policies that are no longer asked for.
+	l := &v1alpha1.AlertPolicyList{}
+	if err := r.kube.List(ctx, l, client.InNamespace(mg.GetNamespace())); err != nil {
+		return reconcile.Result{Requeue: true}, errors.Wrapf(err, "cannot list alert policies of %s", req.NamespacedName)
+	}
+	for i := range l.Items {
+		p := &l.Items[i]
+		if want[p.GetName()] || !metav1.IsControlledBy(p, mg) {
+			continue
+		}
+		if err := r.kube.Delete(ctx, p); resource.Ignore(kerrors.IsNotFound, err) != nil {
+			return reconcile.Result{Requeue: true}, errors.Wrapf(err, "cannot delete alert policy %s", p.GetName())
+		}
+	}
+
+	return reconcile.Result{Requeue: false}, nil
+}
+
+// apply creates or updates the supplied baseline alert policy. An existing
+// alert policy is only updated if it is controlled by the same managed
+// resource.
+func (r *BaselineReconciler) apply(ctx context.Context, want *v1alpha1.AlertPolicy) error {
+	got := want.DeepCopy()
+	err := util.CreateOrUpdate(ctx, r.kube, got, func() error {
+		if !meta.HaveSameController(got, want) {
+			return errors.Errorf("alert policy %s/%s exists and is not controlled by %s", got.GetNamespace(), got.GetName(), want.GetOwnerReferences()[0].Name)
+		}
+		got.Spec.DisplayName = want.Spec.DisplayName
+		got.Spec.Combiner = want.Spec.Combiner
+		got.Spec.Conditions = want.Spec.Conditions
+		got.Spec.NotificationChannelRefs = want.Spec.NotificationChannelRefs
+		return nil
+	})
+	return errors.Wrapf(err, "cannot apply baseline alert policy %s", want.GetName())
+}
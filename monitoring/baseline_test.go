@@ -0,0 +1,247 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gcpcomputev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	gcpdatabasev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/monitoring/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+var baselineAlerts = &v1alpha1.BaselineAlerts{
+	NotificationChannelRefs: []corev1.LocalObjectReference{{Name: "cool-channel"}},
+}
+
+func gkeCluster(clusterName string, b *v1alpha1.BaselineAlerts) *gcpcomputev1alpha1.GKECluster {
+	c := &gcpcomputev1alpha1.GKECluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "cool-cluster", UID: uid},
+	}
+	c.Spec.Zone = "us-central1-a"
+	c.Spec.ProviderReference = &corev1.ObjectReference{Namespace: namespace, Name: providerName}
+	c.Spec.BaselineAlerts = b
+	c.Status.ClusterName = clusterName
+	return c
+}
+
+func TestBaselinePolicies(t *testing.T) {
+	i := &gcpdatabasev1alpha1.CloudsqlInstance{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "cool-instance", UID: uid},
+	}
+	i.Spec.BaselineAlerts = baselineAlerts
+
+	cases := []struct {
+		name        string
+		mg          resource.Managed
+		policies    baselinePolicies
+		wantNames   []string
+		wantFilters []string
+	}{
+		{
+			name:      "CloudsqlInstance",
+			mg:        i,
+			policies:  cloudsqlBaselinePolicies,
+			wantNames: []string{"cool-instance-cpu", "cool-instance-disk"},
+			wantFilters: []string{
+				`metric.type="cloudsql.googleapis.com/database/cpu/utilization" AND resource.type="cloudsql_database" AND resource.label.database_id=ends_with(":` + i.GetResourceName() + `")`,
+				`metric.type="cloudsql.googleapis.com/database/disk/utilization" AND resource.type="cloudsql_database" AND resource.label.database_id=ends_with(":` + i.GetResourceName() + `")`,
+			},
+		},
+		{
+			name:      "GKECluster",
+			mg:        gkeCluster("cool-gke", baselineAlerts),
+			policies:  gkeBaselinePolicies,
+			wantNames: []string{"cool-cluster-cpu", "cool-cluster-memory"},
+			wantFilters: []string{
+				`metric.type="kubernetes.io/node/cpu/allocatable_utilization" AND resource.type="k8s_node" AND resource.label.cluster_name="cool-gke" AND resource.label.location="us-central1-a"`,
+				`metric.type="kubernetes.io/node/memory/allocatable_utilization" AND resource.type="k8s_node" AND resource.label.cluster_name="cool-gke" AND resource.label.location="us-central1-a"`,
+			},
+		},
+		{
+			name:     "GKEClusterNotNamed",
+			mg:       gkeCluster("", baselineAlerts),
+			policies: gkeBaselinePolicies,
+		},
+		{
+			name:     "NotAskedFor",
+			mg:       gkeCluster("cool-gke", nil),
+			policies: gkeBaselinePolicies,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotNames, gotFilters []string
+			for _, p := range tc.policies(tc.mg) {
+				if !metav1.IsControlledBy(p, tc.mg) {
+					t.Errorf("policy %s: want controlled by %s", p.GetName(), tc.mg.GetName())
+				}
+				if diff := cmp.Diff(baselineAlerts.NotificationChannelRefs, p.Spec.NotificationChannelRefs); diff != "" {
+					t.Errorf("policy %s: -want notification channel refs, +got:\n%s", p.GetName(), diff)
+				}
+				gotNames = append(gotNames, p.GetName())
+				gotFilters = append(gotFilters, p.Spec.Conditions[0].Filter)
+			}
+
+			if diff := cmp.Diff(tc.wantNames, gotNames); diff != "" {
+				t.Errorf("tc.policies(...): -want names, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantFilters, gotFilters); diff != "" {
+				t.Errorf("tc.policies(...): -want filters, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBaselineReconcile(t *testing.T) {
+	c := gkeCluster("cool-gke", nil)
+	owned := alertPolicy()
+	owned.SetName("cool-cluster-cpu")
+	owned.SetOwnerReferences([]metav1.OwnerReference{meta.AsController(meta.ReferenceTo(c, gcpcomputev1alpha1.GKEClusterGroupVersionKind))})
+	unowned := alertPolicy()
+
+	cases := []struct {
+		name        string
+		r           *BaselineReconciler
+		wantCreated []string
+		wantDeleted []string
+		wantResult  reconcile.Result
+		wantErr     error
+	}{
+		{
+			name: "Created",
+			r: &BaselineReconciler{
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+						if c, ok := obj.(*gcpcomputev1alpha1.GKECluster); ok {
+							gkeCluster("cool-gke", baselineAlerts).DeepCopyInto(c)
+							return nil
+						}
+						return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+					},
+					MockCreate: func(_ context.Context, _ runtime.Object, _ ...client.CreateOption) error { return nil },
+					MockList:   func(_ context.Context, _ runtime.Object, _ ...client.ListOption) error { return nil },
+				},
+				newManaged: func() resource.Managed { return &gcpcomputev1alpha1.GKECluster{} },
+				policies:   gkeBaselinePolicies,
+			},
+			wantCreated: []string{"cool-cluster-cpu", "cool-cluster-memory"},
+			wantResult:  reconcile.Result{Requeue: false},
+		},
+		{
+			name: "NoLongerAskedFor",
+			r: &BaselineReconciler{
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						c.DeepCopyInto(obj.(*gcpcomputev1alpha1.GKECluster))
+						return nil
+					},
+					MockList: func(_ context.Context, obj runtime.Object, _ ...client.ListOption) error {
+						obj.(*v1alpha1.AlertPolicyList).Items = []v1alpha1.AlertPolicy{*owned, *unowned}
+						return nil
+					},
+					MockDelete: func(_ context.Context, _ runtime.Object, _ ...client.DeleteOption) error { return nil },
+				},
+				newManaged: func() resource.Managed { return &gcpcomputev1alpha1.GKECluster{} },
+				policies:   gkeBaselinePolicies,
+			},
+			wantDeleted: []string{"cool-cluster-cpu"},
+			wantResult:  reconcile.Result{Requeue: false},
+		},
+		{
+			name: "ManagedResourceNotFound",
+			r: &BaselineReconciler{
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, _ runtime.Object) error {
+						return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+					},
+				},
+				newManaged: func() resource.Managed { return &gcpcomputev1alpha1.GKECluster{} },
+				policies:   gkeBaselinePolicies,
+			},
+			wantResult: reconcile.Result{Requeue: false},
+		},
+		{
+			name: "FailedList",
+			r: &BaselineReconciler{
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						c.DeepCopyInto(obj.(*gcpcomputev1alpha1.GKECluster))
+						return nil
+					},
+					MockList: func(_ context.Context, _ runtime.Object, _ ...client.ListOption) error { return errorBoom },
+				},
+				newManaged: func() resource.Managed { return &gcpcomputev1alpha1.GKECluster{} },
+				policies:   gkeBaselinePolicies,
+			},
+			wantResult: reconcile.Result{Requeue: true},
+			wantErr:    errors.Wrapf(errorBoom, "cannot list alert policies of %s/%s", namespace, "cool-cluster"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotCreated, gotDeleted []string
+			if m, ok := tc.r.kube.(*test.MockClient); ok {
+				if create := m.MockCreate; create != nil {
+					m.MockCreate = func(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+						gotCreated = append(gotCreated, obj.(*v1alpha1.AlertPolicy).GetName())
+						return create(ctx, obj, opts...)
+					}
+				}
+				if del := m.MockDelete; del != nil {
+					m.MockDelete = func(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+						gotDeleted = append(gotDeleted, obj.(*v1alpha1.AlertPolicy).GetName())
+						return del(ctx, obj, opts...)
+					}
+				}
+			}
+
+			req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: "cool-cluster"}}
+			gotResult, gotErr := tc.r.Reconcile(req)
+
+			if diff := cmp.Diff(tc.wantErr, gotErr, test.EquateErrors()); diff != "" {
+				t.Errorf("tc.r.Reconcile(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantResult, gotResult); diff != "" {
+				t.Errorf("tc.r.Reconcile(...): -want result, +got result:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantCreated, gotCreated); diff != "" {
+				t.Errorf("tc.r.Reconcile(...): -want created, +got created:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantDeleted, gotDeleted); diff != "" {
+				t.Errorf("tc.r.Reconcile(...): -want deleted, +got deleted:\n%s", diff)
+			}
+		})
+	}
+}
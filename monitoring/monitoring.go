@@ -0,0 +1,78 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package monitoring contains controllers that provision Cloud Monitoring
+// alert policies, the notification channels they notify, and uptime checks.
+// It also provisions baseline alert policies for the CloudsqlInstances and
+// GKEClusters that ask for them.
+package monitoring
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	monitoringv3 "google.golang.org/api/monitoring/v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/monitoring"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+)
+
+const reconcileTimeout = 1 * time.Minute
+
+// providerConnecter returns Cloud Monitoring clients authenticated using
+// credentials read from a Crossplane Provider resource.
+type providerConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (monitoring.Client, error)
+}
+
+// connect returns a Cloud Monitoring client authenticated using credentials
+// read from the Provider referenced by the supplied managed resource, and that
+// Provider.
+func (c *providerConnecter) connect(ctx context.Context, mg metav1.Object, ref *corev1.ObjectReference) (monitoring.Client, *gcpv1alpha1.Provider, error) {
+	p, err := c.providers.Get(ctx, c.kube, mg, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceMonitoring, monitoringv3.MonitoringScope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return client, p, errors.Wrap(err, "cannot create new monitoring client")
+}
+
+// projectName returns the fully qualified name of the supplied project, e.g.
+// projects/p. Cloud Monitoring resources are created within a project and
+// named by the API.
+func projectName(project string) string {
+	return "projects/" + project
+}
+
+// enabled returns the value of the supplied optional enabled field. Cloud
+// Monitoring resources are enabled unless they are explicitly disabled.
+func enabled(b *bool) bool {
+	return b == nil || *b
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	monitoringv3 "google.golang.org/api/monitoring/v3"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/monitoring/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/monitoring"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	notificationChannelControllerName = "notificationchannels.monitoring.gcp.crossplane.io"
+	notificationChannelFinalizer      = "finalizer." + notificationChannelControllerName
+
+	// notificationChannelUpdateMask lists the fields of a notification
+	// channel that may be updated. Its type is immutable.
+	notificationChannelUpdateMask = "displayName,description,labels,userLabels,enabled"
+)
+
+// notificationChannelOutputFields are the output only fields of a
+// notification channel.
+var notificationChannelOutputFields = []string{"name", "verificationStatus", "creationRecord", "mutationRecords"}
+
+var notificationChannelLog = logging.Logger.WithName("controller." + notificationChannelControllerName)
+
+// A notificationChannelCreateSyncDeleter can create, sync, and delete
+// notification channels in an external store - e.g. the GCP API. Each method
+// returns true if the notification channel requires further reconciliation.
+type notificationChannelCreateSyncDeleter interface {
+	Create(ctx context.Context, c *v1alpha1.NotificationChannel) (requeue bool)
+	Sync(ctx context.Context, c *v1alpha1.NotificationChannel) (requeue bool)
+	Delete(ctx context.Context, c *v1alpha1.NotificationChannel) (requeue bool)
+}
+
+// notificationChannels is a notificationChannelCreateSyncDeleter using the
+// Cloud Monitoring API.
+type notificationChannels struct {
+	client  monitoring.Client
+	project string
+}
+
+// Create creates the desired notification channel. Cloud Monitoring names the
+// channel when it is created.
+func (s *notificationChannels) Create(ctx context.Context, c *v1alpha1.NotificationChannel) bool {
+	c.Status.SetConditions(corev1alpha1.Creating())
+
+	created, err := s.client.CreateNotificationChannel(ctx, projectName(s.project), desiredNotificationChannel(c))
+	if err != nil {
+		c.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create notification channel")))
+		return true
+	}
+
+	c.Status.NotificationChannelName = created.Name
+	meta.AddFinalizer(c, notificationChannelFinalizer)
+	c.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync updates the notification channel if it differs from the desired
+// channel, and records its verification status.
+func (s *notificationChannels) Sync(ctx context.Context, c *v1alpha1.NotificationChannel) bool {
+	actual, err := s.client.GetNotificationChannel(ctx, c.Status.NotificationChannelName)
+	if err != nil {
+		c.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+	c.Status.VerificationStatus = actual.VerificationStatus
+
+	desired := desiredNotificationChannel(c)
+	if !compare.Equal(desired, actual, compare.IgnoreFields(notificationChannelOutputFields...)) {
+		if err := s.client.PatchNotificationChannel(ctx, c.Status.NotificationChannelName, desired, notificationChannelUpdateMask); err != nil {
+			c.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot update notification channel")))
+			return true
+		}
+	}
+
+	c.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete deletes the notification channel. Alert policies that notify the
+// channel stop notifying it.
+func (s *notificationChannels) Delete(ctx context.Context, c *v1alpha1.NotificationChannel) bool {
+	c.Status.SetConditions(corev1alpha1.Deleting())
+
+	if c.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := s.client.DeleteNotificationChannel(ctx, c.Status.NotificationChannelName); err != nil && !googleapi.IsErrorNotFound(err) {
+			c.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete notification channel")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(c, notificationChannelFinalizer)
+	c.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// desiredNotificationChannel returns the Cloud Monitoring notification
+// channel described by the supplied NotificationChannel.
+func desiredNotificationChannel(c *v1alpha1.NotificationChannel) *monitoringv3.NotificationChannel {
+	return &monitoringv3.NotificationChannel{
+		Type:        c.Spec.Type,
+		DisplayName: c.Spec.DisplayName,
+		Description: c.Spec.Description,
+		Labels:      c.Spec.Labels,
+		UserLabels:  c.Spec.UserLabels,
+		Enabled:     enabled(c.Spec.Enabled),
+		// Send false explicitly so that the channel may be disabled.
+		ForceSendFields: []string{"Enabled"},
+	}
+}
+
+// A notificationChannelConnecter returns a
+// notificationChannelCreateSyncDeleter that can create, sync, and delete
+// notification channels with an external store - for example the GCP API.
+type notificationChannelConnecter interface {
+	Connect(context.Context, *v1alpha1.NotificationChannel) (notificationChannelCreateSyncDeleter, error)
+}
+
+// notificationChannelProviderConnecter is a notificationChannelConnecter that
+// returns a notificationChannelCreateSyncDeleter authenticated using
+// credentials read from a Crossplane Provider resource.
+type notificationChannelProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns a notificationChannelCreateSyncDeleter backed by the GCP
+// API. GCP credentials are read from the Crossplane Provider referenced by the
+// supplied NotificationChannel.
+func (c *notificationChannelProviderConnecter) Connect(ctx context.Context, nc *v1alpha1.NotificationChannel) (notificationChannelCreateSyncDeleter, error) {
+	client, p, err := c.connect(ctx, nc, nc.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &notificationChannels{client: client, project: p.Spec.ProjectID}, nil
+}
+
+// NotificationChannelReconciler reconciles NotificationChannels read from the
+// Kubernetes API with an external store, typically the GCP API.
+type NotificationChannelReconciler struct {
+	notificationChannelConnecter
+	kube client.Client
+}
+
+// NotificationChannelController is responsible for adding the
+// NotificationChannel controller and its corresponding reconciler to the
+// manager with any runtime configuration.
+type NotificationChannelController struct {
+	// DefaultProvider is used by notification channels that don't reference a
+	// provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new NotificationChannel Controller and adds it
+// to the Manager with default RBAC. The Manager will set fields on the
+// Controller and start it when the Manager is Started.
+func (c *NotificationChannelController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &NotificationChannelReconciler{
+		notificationChannelConnecter: &notificationChannelProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: monitoring.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(notificationChannelControllerName).
+		For(&v1alpha1.NotificationChannel{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listNotificationChannels)).
+		Complete(r)
+}
+
+// Reconcile Cloud Monitoring notification channels with the GCP API.
+func (r *NotificationChannelReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	notificationChannelLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.NotificationChannelKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	c := &v1alpha1.NotificationChannel{}
+	if err := r.kube.Get(ctx, req.NamespacedName, c); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get notification channel %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, c)
+	if err != nil {
+		c.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, c), "cannot update notification channel %s", req.NamespacedName)
+	}
+
+	// The notification channel has been deleted from the API server. Delete
+	// it from GCP.
+	if c.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, c)}, errors.Wrapf(r.kube.Update(ctx, c), "cannot update notification channel %s", req.NamespacedName)
+	}
+
+	// The notification channel is unnamed. Assume it has not been created in
+	// GCP.
+	if c.Status.NotificationChannelName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, c)}, errors.Wrapf(r.kube.Update(ctx, c), "cannot update notification channel %s", req.NamespacedName)
+	}
+
+	// The notification channel exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, c)}, errors.Wrapf(r.kube.Update(ctx, c), "cannot update notification channel %s", req.NamespacedName)
+}
+
+// listNotificationChannels is a provider.Lister of notification channels.
+func listNotificationChannels(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.NotificationChannelList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
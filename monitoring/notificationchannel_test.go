@@ -0,0 +1,302 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	monitoringv3 "google.golang.org/api/monitoring/v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/monitoring/v1alpha1"
+	fakemonitoring "github.com/crossplaneio/crossplane/pkg/clients/gcp/monitoring/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+type notificationChannelModifier func(*v1alpha1.NotificationChannel)
+
+func withNotificationChannelConditions(c ...corev1alpha1.Condition) notificationChannelModifier {
+	return func(nc *v1alpha1.NotificationChannel) { nc.Status.SetConditions(c...) }
+}
+
+func withNotificationChannelFinalizers(f ...string) notificationChannelModifier {
+	return func(nc *v1alpha1.NotificationChannel) { nc.ObjectMeta.Finalizers = f }
+}
+
+func withNotificationChannelReclaimPolicy(r corev1alpha1.ReclaimPolicy) notificationChannelModifier {
+	return func(nc *v1alpha1.NotificationChannel) { nc.Spec.ReclaimPolicy = r }
+}
+
+func withNotificationChannelName(n string) notificationChannelModifier {
+	return func(nc *v1alpha1.NotificationChannel) { nc.Status.NotificationChannelName = n }
+}
+
+func withVerificationStatus(s string) notificationChannelModifier {
+	return func(nc *v1alpha1.NotificationChannel) { nc.Status.VerificationStatus = s }
+}
+
+func withNotificationChannelEnabled(e bool) notificationChannelModifier {
+	return func(nc *v1alpha1.NotificationChannel) { nc.Spec.Enabled = &e }
+}
+
+func notificationChannel(cm ...notificationChannelModifier) *v1alpha1.NotificationChannel {
+	nc := &v1alpha1.NotificationChannel{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       "cool-channel",
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.NotificationChannelSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			Type:        "email",
+			DisplayName: "Cool channel",
+			Labels:      map[string]string{"email_address": "oncall@example.org"},
+		},
+	}
+
+	for _, m := range cm {
+		m(nc)
+	}
+
+	return nc
+}
+
+func TestNotificationChannelCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         notificationChannelCreateSyncDeleter
+		nc          *v1alpha1.NotificationChannel
+		want        *v1alpha1.NotificationChannel
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &notificationChannels{
+				project: project,
+				client: &fakemonitoring.MockClient{
+					MockCreateNotificationChannel: func(_ context.Context, parent string, c *monitoringv3.NotificationChannel) (*monitoringv3.NotificationChannel, error) {
+						if parent != projectName(project) {
+							t.Errorf("CreateNotificationChannel(...): want parent %s, got %s", projectName(project), parent)
+						}
+						if !c.Enabled {
+							t.Errorf("CreateNotificationChannel(...): want enabled channel")
+						}
+						return &monitoringv3.NotificationChannel{Name: channelName}, nil
+					},
+				},
+			},
+			nc: notificationChannel(),
+			want: notificationChannel(
+				withNotificationChannelFinalizers(notificationChannelFinalizer),
+				withNotificationChannelName(channelName),
+				withNotificationChannelConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &notificationChannels{
+				project: project,
+				client: &fakemonitoring.MockClient{
+					MockCreateNotificationChannel: func(_ context.Context, _ string, _ *monitoringv3.NotificationChannel) (*monitoringv3.NotificationChannel, error) {
+						return nil, errorBoom
+					},
+				},
+			},
+			nc: notificationChannel(),
+			want: notificationChannel(
+				withNotificationChannelConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create notification channel"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.nc)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.nc, test.EquateConditions()); diff != "" {
+				t.Errorf("nc: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNotificationChannelSync(t *testing.T) {
+	verified := func(_ context.Context, _ string) (*monitoringv3.NotificationChannel, error) {
+		actual := desiredNotificationChannel(notificationChannel())
+		actual.Name = channelName
+		actual.VerificationStatus = "VERIFIED"
+		return actual, nil
+	}
+
+	cases := []struct {
+		name        string
+		csd         notificationChannelCreateSyncDeleter
+		nc          *v1alpha1.NotificationChannel
+		want        *v1alpha1.NotificationChannel
+		wantRequeue bool
+	}{
+		{
+			name: "UpToDate",
+			csd:  &notificationChannels{client: &fakemonitoring.MockClient{MockGetNotificationChannel: verified}},
+			nc:   notificationChannel(withNotificationChannelName(channelName)),
+			want: notificationChannel(
+				withNotificationChannelName(channelName),
+				withVerificationStatus("VERIFIED"),
+				withNotificationChannelConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "Disabled",
+			csd: &notificationChannels{client: &fakemonitoring.MockClient{
+				MockGetNotificationChannel: verified,
+				MockPatchNotificationChannel: func(_ context.Context, _ string, c *monitoringv3.NotificationChannel, mask string) error {
+					if c.Enabled {
+						t.Errorf("PatchNotificationChannel(...): want disabled channel")
+					}
+					if mask != notificationChannelUpdateMask {
+						t.Errorf("PatchNotificationChannel(...): want mask %s, got %s", notificationChannelUpdateMask, mask)
+					}
+					return nil
+				},
+			}},
+			nc: notificationChannel(withNotificationChannelName(channelName), withNotificationChannelEnabled(false)),
+			want: notificationChannel(
+				withNotificationChannelName(channelName),
+				withNotificationChannelEnabled(false),
+				withVerificationStatus("VERIFIED"),
+				withNotificationChannelConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedGet",
+			csd: &notificationChannels{client: &fakemonitoring.MockClient{
+				MockGetNotificationChannel: func(_ context.Context, _ string) (*monitoringv3.NotificationChannel, error) { return nil, errorBoom },
+			}},
+			nc: notificationChannel(withNotificationChannelName(channelName)),
+			want: notificationChannel(
+				withNotificationChannelName(channelName),
+				withNotificationChannelConditions(corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.nc)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.nc, test.EquateConditions()); diff != "" {
+				t.Errorf("nc: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNotificationChannelDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         notificationChannelCreateSyncDeleter
+		nc          *v1alpha1.NotificationChannel
+		want        *v1alpha1.NotificationChannel
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimDeleteSuccessful",
+			csd: &notificationChannels{client: &fakemonitoring.MockClient{
+				MockDeleteNotificationChannel: func(_ context.Context, _ string) error { return nil },
+			}},
+			nc: notificationChannel(
+				withNotificationChannelName(channelName),
+				withNotificationChannelFinalizers(notificationChannelFinalizer),
+				withNotificationChannelReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: notificationChannel(
+				withNotificationChannelName(channelName),
+				withNotificationChannelReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withNotificationChannelConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailed",
+			csd: &notificationChannels{client: &fakemonitoring.MockClient{
+				MockDeleteNotificationChannel: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			nc: notificationChannel(
+				withNotificationChannelName(channelName),
+				withNotificationChannelFinalizers(notificationChannelFinalizer),
+				withNotificationChannelReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: notificationChannel(
+				withNotificationChannelName(channelName),
+				withNotificationChannelFinalizers(notificationChannelFinalizer),
+				withNotificationChannelReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withNotificationChannelConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot delete notification channel"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "ReclaimRetain",
+			csd:  &notificationChannels{client: &fakemonitoring.MockClient{}},
+			nc: notificationChannel(
+				withNotificationChannelName(channelName),
+				withNotificationChannelFinalizers(notificationChannelFinalizer),
+				withNotificationChannelReclaimPolicy(corev1alpha1.ReclaimRetain),
+			),
+			want: notificationChannel(
+				withNotificationChannelName(channelName),
+				withNotificationChannelReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withNotificationChannelConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.nc)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.nc, test.EquateConditions()); diff != "" {
+				t.Errorf("nc: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	monitoringv3 "google.golang.org/api/monitoring/v3"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/monitoring/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/monitoring"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	uptimeCheckControllerName = "uptimechecks.monitoring.gcp.crossplane.io"
+	uptimeCheckFinalizer      = "finalizer." + uptimeCheckControllerName
+
+	// uptimeCheckUpdateMask lists the fields of an uptime check that may be
+	// updated. Its monitored resource is immutable.
+	uptimeCheckUpdateMask = "displayName,httpCheck,period,timeout,selectedRegions,userLabels"
+
+	// uptimeCheckResourceType is the monitored resource type of uptime checks
+	// of a host.
+	uptimeCheckResourceType = "uptime_url"
+)
+
+// uptimeCheckOutputFields are the output only fields of an uptime check.
+var uptimeCheckOutputFields = []string{"name", "isInternal", "checkerType"}
+
+var uptimeCheckLog = logging.Logger.WithName("controller." + uptimeCheckControllerName)
+
+// An uptimeCheckCreateSyncDeleter can create, sync, and delete uptime checks
+// in an external store - e.g. the GCP API. Each method returns true if the
+// uptime check requires further reconciliation.
+type uptimeCheckCreateSyncDeleter interface {
+	Create(ctx context.Context, u *v1alpha1.UptimeCheck) (requeue bool)
+	Sync(ctx context.Context, u *v1alpha1.UptimeCheck) (requeue bool)
+	Delete(ctx context.Context, u *v1alpha1.UptimeCheck) (requeue bool)
+}
+
+// uptimeChecks is an uptimeCheckCreateSyncDeleter using the Cloud Monitoring
+// API.
+type uptimeChecks struct {
+	client  monitoring.Client
+	project string
+}
+
+// Create creates the desired uptime check. Cloud Monitoring names the check
+// when it is created.
+func (s *uptimeChecks) Create(ctx context.Context, u *v1alpha1.UptimeCheck) bool {
+	u.Status.SetConditions(corev1alpha1.Creating())
+
+	created, err := s.client.CreateUptimeCheckConfig(ctx, projectName(s.project), desiredUptimeCheck(s.project, u))
+	if err != nil {
+		u.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create uptime check")))
+		return true
+	}
+
+	u.Status.UptimeCheckName = created.Name
+	meta.AddFinalizer(u, uptimeCheckFinalizer)
+	u.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync updates the uptime check if it differs from the desired check.
+func (s *uptimeChecks) Sync(ctx context.Context, u *v1alpha1.UptimeCheck) bool {
+	actual, err := s.client.GetUptimeCheckConfig(ctx, u.Status.UptimeCheckName)
+	if err != nil {
+		u.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	desired := desiredUptimeCheck(s.project, u)
+	if !compare.Equal(desired, actual, compare.IgnoreFields(uptimeCheckOutputFields...), compare.IgnoreUnset()) {
+		if err := s.client.PatchUptimeCheckConfig(ctx, u.Status.UptimeCheckName, desired, uptimeCheckUpdateMask); err != nil {
+			u.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot update uptime check")))
+			return true
+		}
+	}
+
+	u.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete deletes the uptime check. Alert policies that use the check must be
+// deleted first.
+func (s *uptimeChecks) Delete(ctx context.Context, u *v1alpha1.UptimeCheck) bool {
+	u.Status.SetConditions(corev1alpha1.Deleting())
+
+	if u.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := s.client.DeleteUptimeCheckConfig(ctx, u.Status.UptimeCheckName); err != nil && !googleapi.IsErrorNotFound(err) {
+			u.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete uptime check")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(u, uptimeCheckFinalizer)
+	u.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// desiredUptimeCheck returns the Cloud Monitoring uptime check described by
+// the supplied UptimeCheck, which checks a host in the supplied project.
+// Unspecified fields are defaulted by Cloud Monitoring.
+func desiredUptimeCheck(project string, u *v1alpha1.UptimeCheck) *monitoringv3.UptimeCheckConfig {
+	return &monitoringv3.UptimeCheckConfig{
+		DisplayName: u.Spec.DisplayName,
+		MonitoredResource: &monitoringv3.MonitoredResource{
+			Type:   uptimeCheckResourceType,
+			Labels: map[string]string{"project_id": project, "host": u.Spec.Host},
+		},
+		HttpCheck: &monitoringv3.HttpCheck{
+			Path:   u.Spec.Path,
+			Port:   u.Spec.Port,
+			UseSsl: u.Spec.UseSSL,
+		},
+		Period:          u.Spec.Period,
+		Timeout:         u.Spec.Timeout,
+		SelectedRegions: u.Spec.SelectedRegions,
+		UserLabels:      u.Spec.UserLabels,
+	}
+}
+
+// An uptimeCheckConnecter returns an uptimeCheckCreateSyncDeleter that can
+// create, sync, and delete uptime checks with an external store - for example
+// the GCP API.
+type uptimeCheckConnecter interface {
+	Connect(context.Context, *v1alpha1.UptimeCheck) (uptimeCheckCreateSyncDeleter, error)
+}
+
+// uptimeCheckProviderConnecter is an uptimeCheckConnecter that returns an
+// uptimeCheckCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type uptimeCheckProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns an uptimeCheckCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the
+// supplied UptimeCheck.
+func (c *uptimeCheckProviderConnecter) Connect(ctx context.Context, u *v1alpha1.UptimeCheck) (uptimeCheckCreateSyncDeleter, error) {
+	client, p, err := c.connect(ctx, u, u.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &uptimeChecks{client: client, project: p.Spec.ProjectID}, nil
+}
+
+// UptimeCheckReconciler reconciles UptimeChecks read from the Kubernetes API
+// with an external store, typically the GCP API.
+type UptimeCheckReconciler struct {
+	uptimeCheckConnecter
+	kube client.Client
+}
+
+// UptimeCheckController is responsible for adding the UptimeCheck controller
+// and its corresponding reconciler to the manager with any runtime
+// configuration.
+type UptimeCheckController struct {
+	// DefaultProvider is used by uptime checks that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new UptimeCheck Controller and adds it to the
+// Manager with default RBAC. The Manager will set fields on the Controller and
+// start it when the Manager is Started.
+func (c *UptimeCheckController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &UptimeCheckReconciler{
+		uptimeCheckConnecter: &uptimeCheckProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: monitoring.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(uptimeCheckControllerName).
+		For(&v1alpha1.UptimeCheck{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listUptimeChecks)).
+		Complete(r)
+}
+
+// Reconcile Cloud Monitoring uptime checks with the GCP API.
+func (r *UptimeCheckReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	uptimeCheckLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.UptimeCheckKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	u := &v1alpha1.UptimeCheck{}
+	if err := r.kube.Get(ctx, req.NamespacedName, u); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get uptime check %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, u)
+	if err != nil {
+		u.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, u), "cannot update uptime check %s", req.NamespacedName)
+	}
+
+	// The uptime check has been deleted from the API server. Delete it from
+	// GCP.
+	if u.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, u)}, errors.Wrapf(r.kube.Update(ctx, u), "cannot update uptime check %s", req.NamespacedName)
+	}
+
+	// The uptime check is unnamed. Assume it has not been created in GCP.
+	if u.Status.UptimeCheckName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, u)}, errors.Wrapf(r.kube.Update(ctx, u), "cannot update uptime check %s", req.NamespacedName)
+	}
+
+	// The uptime check exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, u)}, errors.Wrapf(r.kube.Update(ctx, u), "cannot update uptime check %s", req.NamespacedName)
+}
+
+// listUptimeChecks is a provider.Lister of uptime checks.
+func listUptimeChecks(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.UptimeCheckList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,299 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	monitoringv3 "google.golang.org/api/monitoring/v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/monitoring/v1alpha1"
+	fakemonitoring "github.com/crossplaneio/crossplane/pkg/clients/gcp/monitoring/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const uptimeCheckName = "projects/cool-project/uptimeCheckConfigs/cool-check-1"
+
+type uptimeCheckModifier func(*v1alpha1.UptimeCheck)
+
+func withUptimeCheckConditions(c ...corev1alpha1.Condition) uptimeCheckModifier {
+	return func(u *v1alpha1.UptimeCheck) { u.Status.SetConditions(c...) }
+}
+
+func withUptimeCheckFinalizers(f ...string) uptimeCheckModifier {
+	return func(u *v1alpha1.UptimeCheck) { u.ObjectMeta.Finalizers = f }
+}
+
+func withUptimeCheckReclaimPolicy(r corev1alpha1.ReclaimPolicy) uptimeCheckModifier {
+	return func(u *v1alpha1.UptimeCheck) { u.Spec.ReclaimPolicy = r }
+}
+
+func withUptimeCheckName(n string) uptimeCheckModifier {
+	return func(u *v1alpha1.UptimeCheck) { u.Status.UptimeCheckName = n }
+}
+
+func uptimeCheck(um ...uptimeCheckModifier) *v1alpha1.UptimeCheck {
+	u := &v1alpha1.UptimeCheck{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       "cool-check",
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.UptimeCheckSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			DisplayName: "Cool check",
+			Host:        "example.org",
+			Path:        "/healthz",
+			Port:        443,
+			UseSSL:      true,
+			Period:      "60s",
+			Timeout:     "10s",
+		},
+	}
+
+	for _, m := range um {
+		m(u)
+	}
+
+	return u
+}
+
+func TestUptimeCheckCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         uptimeCheckCreateSyncDeleter
+		u           *v1alpha1.UptimeCheck
+		want        *v1alpha1.UptimeCheck
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &uptimeChecks{
+				project: project,
+				client: &fakemonitoring.MockClient{
+					MockCreateUptimeCheckConfig: func(_ context.Context, parent string, c *monitoringv3.UptimeCheckConfig) (*monitoringv3.UptimeCheckConfig, error) {
+						if parent != projectName(project) {
+							t.Errorf("CreateUptimeCheckConfig(...): want parent %s, got %s", projectName(project), parent)
+						}
+						want := map[string]string{"project_id": project, "host": "example.org"}
+						if diff := cmp.Diff(want, c.MonitoredResource.Labels); diff != "" {
+							t.Errorf("CreateUptimeCheckConfig(...): -want monitored resource labels, +got:\n%s", diff)
+						}
+						return &monitoringv3.UptimeCheckConfig{Name: uptimeCheckName}, nil
+					},
+				},
+			},
+			u: uptimeCheck(),
+			want: uptimeCheck(
+				withUptimeCheckFinalizers(uptimeCheckFinalizer),
+				withUptimeCheckName(uptimeCheckName),
+				withUptimeCheckConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &uptimeChecks{
+				project: project,
+				client: &fakemonitoring.MockClient{
+					MockCreateUptimeCheckConfig: func(_ context.Context, _ string, _ *monitoringv3.UptimeCheckConfig) (*monitoringv3.UptimeCheckConfig, error) {
+						return nil, errorBoom
+					},
+				},
+			},
+			u: uptimeCheck(),
+			want: uptimeCheck(
+				withUptimeCheckConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create uptime check"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.u)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.u, test.EquateConditions()); diff != "" {
+				t.Errorf("u: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUptimeCheckSync(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         uptimeCheckCreateSyncDeleter
+		u           *v1alpha1.UptimeCheck
+		want        *v1alpha1.UptimeCheck
+		wantRequeue bool
+	}{
+		{
+			name: "UpToDate",
+			csd: &uptimeChecks{project: project, client: &fakemonitoring.MockClient{
+				MockGetUptimeCheckConfig: func(_ context.Context, _ string) (*monitoringv3.UptimeCheckConfig, error) {
+					actual := desiredUptimeCheck(project, uptimeCheck())
+					actual.Name = uptimeCheckName
+					actual.SelectedRegions = []string{"USA", "EUROPE"}
+					return actual, nil
+				},
+			}},
+			u: uptimeCheck(withUptimeCheckName(uptimeCheckName)),
+			want: uptimeCheck(
+				withUptimeCheckName(uptimeCheckName),
+				withUptimeCheckConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "Updated",
+			csd: &uptimeChecks{project: project, client: &fakemonitoring.MockClient{
+				MockGetUptimeCheckConfig: func(_ context.Context, _ string) (*monitoringv3.UptimeCheckConfig, error) {
+					actual := desiredUptimeCheck(project, uptimeCheck())
+					actual.HttpCheck.Path = "/"
+					return actual, nil
+				},
+				MockPatchUptimeCheckConfig: func(_ context.Context, n string, _ *monitoringv3.UptimeCheckConfig, mask string) error {
+					if mask != uptimeCheckUpdateMask {
+						t.Errorf("PatchUptimeCheckConfig(...): want mask %s, got %s", uptimeCheckUpdateMask, mask)
+					}
+					return nil
+				},
+			}},
+			u: uptimeCheck(withUptimeCheckName(uptimeCheckName)),
+			want: uptimeCheck(
+				withUptimeCheckName(uptimeCheckName),
+				withUptimeCheckConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedGet",
+			csd: &uptimeChecks{project: project, client: &fakemonitoring.MockClient{
+				MockGetUptimeCheckConfig: func(_ context.Context, _ string) (*monitoringv3.UptimeCheckConfig, error) { return nil, errorBoom },
+			}},
+			u: uptimeCheck(withUptimeCheckName(uptimeCheckName)),
+			want: uptimeCheck(
+				withUptimeCheckName(uptimeCheckName),
+				withUptimeCheckConditions(corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.u)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.u, test.EquateConditions()); diff != "" {
+				t.Errorf("u: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUptimeCheckDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         uptimeCheckCreateSyncDeleter
+		u           *v1alpha1.UptimeCheck
+		want        *v1alpha1.UptimeCheck
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimDeleteNotFound",
+			csd: &uptimeChecks{client: &fakemonitoring.MockClient{
+				MockDeleteUptimeCheckConfig: func(_ context.Context, _ string) error { return errorNotFound },
+			}},
+			u: uptimeCheck(
+				withUptimeCheckName(uptimeCheckName),
+				withUptimeCheckFinalizers(uptimeCheckFinalizer),
+				withUptimeCheckReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: uptimeCheck(
+				withUptimeCheckName(uptimeCheckName),
+				withUptimeCheckReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withUptimeCheckConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailed",
+			csd: &uptimeChecks{client: &fakemonitoring.MockClient{
+				MockDeleteUptimeCheckConfig: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			u: uptimeCheck(
+				withUptimeCheckName(uptimeCheckName),
+				withUptimeCheckFinalizers(uptimeCheckFinalizer),
+				withUptimeCheckReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: uptimeCheck(
+				withUptimeCheckName(uptimeCheckName),
+				withUptimeCheckFinalizers(uptimeCheckFinalizer),
+				withUptimeCheckReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withUptimeCheckConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot delete uptime check"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "ReclaimRetain",
+			csd:  &uptimeChecks{client: &fakemonitoring.MockClient{}},
+			u: uptimeCheck(
+				withUptimeCheckName(uptimeCheckName),
+				withUptimeCheckFinalizers(uptimeCheckFinalizer),
+				withUptimeCheckReclaimPolicy(corev1alpha1.ReclaimRetain),
+			),
+			want: uptimeCheck(
+				withUptimeCheckName(uptimeCheckName),
+				withUptimeCheckReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withUptimeCheckConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.u)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.u, test.EquateConditions()); diff != "" {
+				t.Errorf("u: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package plan supports a dry-run management mode in which controllers record
+// the GCP API calls they would make rather than making them.
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+)
+
+const (
+	// AnnotationManagementMode selects how a controller manages the external
+	// resource corresponding to the annotated managed resource.
+	AnnotationManagementMode = "gcp.crossplane.io/management-mode"
+
+	// ModeActive controllers create, update, and delete external resources.
+	// This is the default management mode.
+	ModeActive = "Active"
+
+	// ModeDryRun controllers observe external resources, but only record the
+	// create and update calls they would make.
+	ModeDryRun = "DryRun"
+
+	// TypePlanned resources have a pending external API call that was not
+	// made because the resource is in dry-run mode.
+	TypePlanned corev1alpha1.ConditionType = "Planned"
+
+	// ReasonDryRun is the reason for a Planned condition.
+	ReasonDryRun corev1alpha1.ConditionReason = "DryRun"
+
+	// EventReasonPlanned is the reason of events recording planned calls.
+	EventReasonPlanned = "PlannedCall"
+)
+
+// IsDryRun returns true if the supplied object is in dry-run management mode.
+func IsDryRun(o metav1.Object) bool {
+	return o.GetAnnotations()[AnnotationManagementMode] == ModeDryRun
+}
+
+// Describe returns a human readable description of the supplied external API
+// call, including its JSON encoded request body.
+func Describe(call string, body interface{}) string {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Sprintf("%s (cannot encode request: %s)", call, err)
+	}
+	return fmt.Sprintf("%s %s", call, b)
+}
+
+// Planned returns a condition recording the supplied description of a call
+// that would have been made if the resource were not in dry-run mode.
+func Planned(description string) corev1alpha1.Condition {
+	return corev1alpha1.Condition{
+		Type:               TypePlanned,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonDryRun,
+		Message:            description,
+	}
+}
+
+// NothingPlanned returns a condition indicating that a resource in dry-run
+// mode is in sync with its external resource. Controllers set it whenever such
+// a resource is synced without planning a call, so that a call planned by an
+// earlier sync is not reported once the resource has converged.
+func NothingPlanned() corev1alpha1.Condition {
+	return corev1alpha1.Condition{
+		Type:               TypePlanned,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonDryRun,
+	}
+}
+
+// IsPlanned returns true if the supplied conditioned status has a true Planned
+// condition.
+func IsPlanned(s corev1alpha1.ConditionedStatus) bool {
+	return s.GetCondition(TypePlanned).Status == corev1.ConditionTrue
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plan
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+)
+
+func TestIsDryRun(t *testing.T) {
+	cases := map[string]struct {
+		o    metav1.Object
+		want bool
+	}{
+		"NoAnnotation": {
+			o:    &metav1.ObjectMeta{},
+			want: false,
+		},
+		"Active": {
+			o:    &metav1.ObjectMeta{Annotations: map[string]string{AnnotationManagementMode: ModeActive}},
+			want: false,
+		},
+		"DryRun": {
+			o:    &metav1.ObjectMeta{Annotations: map[string]string{AnnotationManagementMode: ModeDryRun}},
+			want: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := IsDryRun(tc.o); got != tc.want {
+				t.Errorf("IsDryRun(...): want %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	cases := map[string]struct {
+		call string
+		body interface{}
+		want string
+	}{
+		"Struct": {
+			call: "CreateCluster",
+			body: struct {
+				Name string `json:"name"`
+			}{Name: "cool"},
+			want: `CreateCluster {"name":"cool"}`,
+		},
+		"Unencodable": {
+			call: "CreateCluster",
+			body: make(chan int),
+			want: "CreateCluster (cannot encode request: json: unsupported type: chan int)",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := Describe(tc.call, tc.body)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Describe(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestIsPlanned(t *testing.T) {
+	s := corev1alpha1.ConditionedStatus{}
+	if IsPlanned(s) {
+		t.Errorf("IsPlanned(...): want false for empty status")
+	}
+	s.SetConditions(Planned("CreateCluster"))
+	if !IsPlanned(s) {
+		t.Errorf("IsPlanned(...): want true for planned status")
+	}
+	s.SetConditions(NothingPlanned())
+	if IsPlanned(s) {
+		t.Errorf("IsPlanned(...): want false once nothing is planned")
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+)
+
+// DefaultScope is requested by credentials for which no scopes are specified.
+const DefaultScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// The types of JSON credentials, as indicated by their type field.
+const (
+	jsonTypeServiceAccount  = "service_account"
+	jsonTypeExternalAccount = "external_account"
+)
+
+// The GCP services for which a Provider may configure separate credentials.
+// Each is named for the API it serves, e.g. sqladmin.googleapis.com.
+const (
+	ServiceCompute            = "compute"
+	ServiceContainer          = "container"
+	ServiceGKEHub             = "gkehub"
+	ServiceSQLAdmin           = "sqladmin"
+	ServiceStorage            = "storage"
+	ServiceRedis              = "redis"
+	ServiceMemcache           = "memcache"
+	ServicePubSub             = "pubsub"
+	ServiceDataflow           = "dataflow"
+	ServiceServiceNetworking  = "servicenetworking"
+	ServiceResourceManager    = "cloudresourcemanager"
+	ServiceIAM                = "iam"
+	ServiceCertificateManager = "certificatemanager"
+	ServiceAPIGateway         = "apigateway"
+	ServiceMonitoring         = "monitoring"
+	ServiceLogging            = "logging"
+	ServiceIAP                = "iap"
+	ServiceKMS                = "cloudkms"
+	ServiceEventarc           = "eventarc"
+	ServiceGKEBackup          = "gkebackup"
+	ServiceCloudTasks         = "cloudtasks"
+	ServiceNotebooks          = "notebooks"
+	ServiceAPIKeys            = "apikeys"
+	ServiceDataplex           = "dataplex"
+)
+
+// Credentials returns credentials read from the secret referenced by the
+// supplied Provider. The secret is interpreted according to the credentials
+// type of the Provider, which defaults to a JSON service account key.
+func Credentials(ctx context.Context, kube client.Client, p *gcpv1alpha1.Provider, scopes ...string) (*google.Credentials, error) {
+	return credentials(ctx, kube, p, p.Spec.Secret, scopes)
+}
+
+// ServiceCredentials returns credentials for the supplied GCP service, which
+// request the supplied scopes. Controllers should request the narrowest scopes
+// their API accepts. A Provider may configure a separate secret or narrower
+// scopes for each service, so that the credentials of one service can't be
+// used to manipulate the resources of another. Otherwise the Provider's
+// credentials are used.
+func ServiceCredentials(ctx context.Context, kube client.Client, p *gcpv1alpha1.Provider, service string, scopes ...string) (*google.Credentials, error) {
+	for _, sc := range p.Spec.ServiceCredentials {
+		if sc.Service != service {
+			continue
+		}
+		if len(sc.Scopes) > 0 {
+			scopes = sc.Scopes
+		}
+		if sc.Secret != nil {
+			return credentials(ctx, kube, p, *sc.Secret, scopes)
+		}
+		break
+	}
+	return credentials(ctx, kube, p, p.Spec.Secret, scopes)
+}
+
+func credentials(ctx context.Context, kube client.Client, p *gcpv1alpha1.Provider, sel corev1.SecretKeySelector, scopes []string) (*google.Credentials, error) {
+	s := &corev1.Secret{}
+	n := types.NamespacedName{Namespace: p.GetNamespace(), Name: sel.Name}
+	if err := kube.Get(ctx, n, s); err != nil {
+		return nil, errors.Wrapf(err, "cannot get provider secret %s", n)
+	}
+
+	if len(scopes) == 0 {
+		scopes = []string{DefaultScope}
+	}
+
+	creds, err := parseCredentials(ctx, p, s, sel.Key, scopes)
+	return creds, errors.Wrapf(err, "cannot parse credentials in provider secret %s", n)
+}
+
+func parseCredentials(ctx context.Context, p *gcpv1alpha1.Provider, s *corev1.Secret, key string, scopes []string) (*google.Credentials, error) {
+	data := s.Data[key]
+	switch p.Spec.CredentialsType {
+	case "", gcpv1alpha1.CredentialsTypeServiceAccountKey:
+		return credentialsFromJSON(ctx, data, jsonTypeServiceAccount, scopes)
+
+	case gcpv1alpha1.CredentialsTypeExternalAccount:
+		return credentialsFromJSON(ctx, data, jsonTypeExternalAccount, scopes)
+
+	case gcpv1alpha1.CredentialsTypeAccessToken:
+		// Access tokens are short-lived. Whatever rotates the secret must do so
+		// before the token expires.
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return nil, errors.New("access token is empty")
+		}
+		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+		return &google.Credentials{ProjectID: p.Spec.ProjectID, TokenSource: ts}, nil
+
+	case gcpv1alpha1.CredentialsTypeImpersonation:
+		// The secret contains the email of the service account to impersonate.
+		// The controller's own application default credentials must be allowed
+		// to create tokens for that service account. Token sources are cached
+		// so that tokens are reused until they expire.
+		target := strings.TrimSpace(string(data))
+		if target == "" {
+			return nil, errors.New("service account to impersonate is empty")
+		}
+		ts, err := impersonated.Get(p, s, target, scopes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot impersonate service account %s", target)
+		}
+		return &google.Credentials{ProjectID: p.Spec.ProjectID, TokenSource: ts}, nil
+	}
+
+	return nil, errors.Errorf("unknown credentials type %q", p.Spec.CredentialsType)
+}
+
+// credentialsFromJSON returns credentials parsed from the supplied JSON, which
+// must be of the supplied type. The Google client libraries would otherwise
+// accept any type of JSON credentials, masking a misconfigured Provider.
+func credentialsFromJSON(ctx context.Context, data []byte, jsonType string, scopes []string) (*google.Credentials, error) {
+	f := struct {
+		Type string `json:"type"`
+	}{}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	if f.Type != jsonType {
+		return nil, errors.Errorf("credentials are of type %q, not %q", f.Type, jsonType)
+	}
+	return google.CredentialsFromJSON(ctx, data, scopes...)
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	secretName = "cool-secret"
+	secretKey  = "credentials"
+	projectID  = "cool-project"
+
+	serviceAccountKey = `{"type": "service_account", "project_id": "cool-project", "client_email": "cool@cool-project.iam.gserviceaccount.com", "private_key": "definitely-a-key"}`
+	externalAccount   = `{"type": "external_account", "audience": "//iam.googleapis.com/projects/1/locations/global/workloadIdentityPools/cool/providers/cool", "subject_token_type": "urn:ietf:params:oauth:token-type:jwt", "token_url": "https://sts.googleapis.com/v1/token", "credential_source": {"file": "/var/run/token"}}`
+)
+
+func TestCredentials(t *testing.T) {
+	errBoom := errors.New("boom")
+	secret := namespace + "/" + secretName
+
+	// kube returns a Secret containing the supplied data, or the supplied error.
+	kube := func(data string, err error) client.Client {
+		return &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+			if err != nil {
+				return err
+			}
+			obj.(*corev1.Secret).Data = map[string][]byte{secretKey: []byte(data)}
+			return nil
+		}}
+	}
+
+	provider := func(t gcpv1alpha1.CredentialsType) *gcpv1alpha1.Provider {
+		p := &gcpv1alpha1.Provider{
+			Spec: gcpv1alpha1.ProviderSpec{
+				ProjectID:       projectID,
+				CredentialsType: t,
+				Secret: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+					Key:                  secretKey,
+				},
+			},
+		}
+		p.SetNamespace(namespace)
+		p.SetName(providerName)
+		return p
+	}
+
+	cases := map[string]struct {
+		kube        client.Client
+		p           *gcpv1alpha1.Provider
+		wantProject string
+		wantErr     error
+	}{
+		"DefaultIsServiceAccountKey": {
+			kube:        kube(serviceAccountKey, nil),
+			p:           provider(""),
+			wantProject: projectID,
+		},
+		"ServiceAccountKey": {
+			kube:        kube(serviceAccountKey, nil),
+			p:           provider(gcpv1alpha1.CredentialsTypeServiceAccountKey),
+			wantProject: projectID,
+		},
+		"ExternalAccount": {
+			kube: kube(externalAccount, nil),
+			p:    provider(gcpv1alpha1.CredentialsTypeExternalAccount),
+		},
+		"ExternalAccountIsNotServiceAccountKey": {
+			kube:    kube(externalAccount, nil),
+			p:       provider(gcpv1alpha1.CredentialsTypeServiceAccountKey),
+			wantErr: errors.Wrapf(errors.New(`credentials are of type "external_account", not "service_account"`), "cannot parse credentials in provider secret %s", secret),
+		},
+		"AccessToken": {
+			kube:        kube("ya29.definitely-a-token\n", nil),
+			p:           provider(gcpv1alpha1.CredentialsTypeAccessToken),
+			wantProject: projectID,
+		},
+		"EmptyAccessToken": {
+			kube:    kube("", nil),
+			p:       provider(gcpv1alpha1.CredentialsTypeAccessToken),
+			wantErr: errors.Wrapf(errors.New("access token is empty"), "cannot parse credentials in provider secret %s", secret),
+		},
+		"EmptyImpersonationTarget": {
+			kube:    kube(" ", nil),
+			p:       provider(gcpv1alpha1.CredentialsTypeImpersonation),
+			wantErr: errors.Wrapf(errors.New("service account to impersonate is empty"), "cannot parse credentials in provider secret %s", secret),
+		},
+		"UnknownType": {
+			kube:    kube(serviceAccountKey, nil),
+			p:       provider("Telepathy"),
+			wantErr: errors.Wrapf(errors.New(`unknown credentials type "Telepathy"`), "cannot parse credentials in provider secret %s", secret),
+		},
+		"SecretNotFound": {
+			kube:    kube("", kerrors.NewNotFound(schema.GroupResource{}, secretName)),
+			p:       provider(""),
+			wantErr: errors.Wrapf(kerrors.NewNotFound(schema.GroupResource{}, secretName), "cannot get provider secret %s", secret),
+		},
+		"GetSecretError": {
+			kube:    kube("", errBoom),
+			p:       provider(""),
+			wantErr: errors.Wrapf(errBoom, "cannot get provider secret %s", secret),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := Credentials(context.Background(), tc.kube, tc.p)
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("Credentials(...): -want error, +got error:\n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if got.TokenSource == nil {
+				t.Errorf("Credentials(...): want token source, got nil")
+			}
+			if got.ProjectID != tc.wantProject {
+				t.Errorf("Credentials(...): want project %q, got %q", tc.wantProject, got.ProjectID)
+			}
+		})
+	}
+}
+
+func TestServiceCredentials(t *testing.T) {
+	sqlSecretName := "cool-sql-secret"
+
+	// kube returns an access token Secret, recording the name of the Secret
+	// that was read.
+	var read string
+	kube := &test.MockClient{MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+		read = key.Name
+		obj.(*corev1.Secret).Data = map[string][]byte{secretKey: []byte("ya29.definitely-a-token")}
+		return nil
+	}}
+
+	provider := func(sc ...gcpv1alpha1.ServiceCredentials) *gcpv1alpha1.Provider {
+		p := &gcpv1alpha1.Provider{
+			Spec: gcpv1alpha1.ProviderSpec{
+				ProjectID:       projectID,
+				CredentialsType: gcpv1alpha1.CredentialsTypeAccessToken,
+				Secret: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+					Key:                  secretKey,
+				},
+				ServiceCredentials: sc,
+			},
+		}
+		p.SetNamespace(namespace)
+		p.SetName(providerName)
+		return p
+	}
+
+	cases := map[string]struct {
+		p          *gcpv1alpha1.Provider
+		service    string
+		wantSecret string
+	}{
+		"NoServiceCredentials": {
+			p:          provider(),
+			service:    ServiceSQLAdmin,
+			wantSecret: secretName,
+		},
+		"ServiceSecret": {
+			p: provider(gcpv1alpha1.ServiceCredentials{
+				Service: ServiceSQLAdmin,
+				Secret: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: sqlSecretName},
+					Key:                  secretKey,
+				},
+			}),
+			service:    ServiceSQLAdmin,
+			wantSecret: sqlSecretName,
+		},
+		"ServiceScopesOnly": {
+			p: provider(gcpv1alpha1.ServiceCredentials{
+				Service: ServiceSQLAdmin,
+				Scopes:  []string{"https://www.googleapis.com/auth/sqlservice.admin"},
+			}),
+			service:    ServiceSQLAdmin,
+			wantSecret: secretName,
+		},
+		"OtherService": {
+			p: provider(gcpv1alpha1.ServiceCredentials{
+				Service: ServiceSQLAdmin,
+				Secret: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: sqlSecretName},
+					Key:                  secretKey,
+				},
+			}),
+			service:    ServiceStorage,
+			wantSecret: secretName,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			read = ""
+			if _, err := ServiceCredentials(context.Background(), kube, tc.p, tc.service); err != nil {
+				t.Fatalf("ServiceCredentials(...): %s", err)
+			}
+			if diff := cmp.Diff(tc.wantSecret, read); diff != "" {
+				t.Errorf("ServiceCredentials(...): -want secret, +got secret:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/impersonate"
+	corev1 "k8s.io/api/core/v1"
+
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+)
+
+// impersonated caches the token sources of impersonated service accounts for
+// every controller.
+var impersonated = newTokenSourceCache(impersonatedTokenSource)
+
+// impersonatedTokenSource returns a token source for the supplied service
+// account. It is not bound to the context of the reconcile that created it,
+// which may end long before the token source is last used.
+func impersonatedTokenSource(target string, scopes []string) (oauth2.TokenSource, error) {
+	return impersonate.CredentialsTokenSource(context.Background(), impersonate.CredentialsConfig{TargetPrincipal: target, Scopes: scopes})
+}
+
+// A tokenSourceCache caches the token source of each impersonating Provider,
+// so that every Connect reuses the Provider's access token until it expires
+// rather than requesting a new one. Token sources are keyed by the Provider,
+// its credentials secret and the requested scopes, and are used only while the
+// secret's resource version is unchanged. Rotating the secret thus replaces
+// its token sources, just as it triggers the reconciles that will use them.
+type tokenSourceCache struct {
+	newTokenSource func(target string, scopes []string) (oauth2.TokenSource, error)
+
+	mu      sync.Mutex
+	sources map[string]*cachedTokenSource
+}
+
+// A cachedTokenSource is a token source for the service account read from the
+// recorded version of a credentials secret.
+type cachedTokenSource struct {
+	version string
+	target  string
+	ts      oauth2.TokenSource
+}
+
+func newTokenSourceCache(fn func(target string, scopes []string) (oauth2.TokenSource, error)) *tokenSourceCache {
+	return &tokenSourceCache{newTokenSource: fn, sources: map[string]*cachedTokenSource{}}
+}
+
+// tokenSourceKey returns the key under which the token source of the supplied
+// Provider, reading the supplied secret and requesting the supplied scopes, is
+// cached. Scopes are requested as a set, so their order is irrelevant.
+func tokenSourceKey(p *gcpv1alpha1.Provider, secret string, scopes []string) string {
+	sorted := append([]string(nil), scopes...)
+	sort.Strings(sorted)
+	return fmt.Sprintf("%s/%s/%s/%s", p.GetNamespace(), p.GetName(), secret, strings.Join(sorted, ","))
+}
+
+// Get returns a token source impersonating the supplied service account, read
+// from the supplied secret of the supplied Provider, creating one unless the
+// cached token source was created from the same version of the secret.
+func (c *tokenSourceCache) Get(p *gcpv1alpha1.Provider, s *corev1.Secret, target string, scopes []string) (oauth2.TokenSource, error) {
+	k := tokenSourceKey(p, s.GetName(), scopes)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.sources[k]; ok && cached.version == s.GetResourceVersion() && cached.target == target {
+		return cached.ts, nil
+	}
+	ts, err := c.newTokenSource(target, scopes)
+	if err != nil {
+		return nil, err
+	}
+	c.sources[k] = &cachedTokenSource{version: s.GetResourceVersion(), target: target, ts: ts}
+	return ts, nil
+}
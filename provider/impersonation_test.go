@@ -0,0 +1,130 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	corev1 "k8s.io/api/core/v1"
+
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+)
+
+func TestTokenSourceCacheGet(t *testing.T) {
+	target := "cool@cool-project.iam.gserviceaccount.com"
+	scopes := []string{DefaultScope, "https://www.googleapis.com/auth/compute"}
+
+	p := &gcpv1alpha1.Provider{}
+	p.SetNamespace(namespace)
+	p.SetName(providerName)
+
+	secret := func(version string) *corev1.Secret {
+		s := &corev1.Secret{}
+		s.SetNamespace(namespace)
+		s.SetName(secretName)
+		s.SetResourceVersion(version)
+		return s
+	}
+
+	type call struct {
+		secret  *corev1.Secret
+		target  string
+		scopes  []string
+		created bool
+	}
+
+	cases := map[string]struct {
+		calls []call
+	}{
+		"Reused": {
+			calls: []call{
+				{secret: secret("1"), target: target, scopes: scopes, created: true},
+				{secret: secret("1"), target: target, scopes: scopes},
+			},
+		},
+		"ScopesReordered": {
+			calls: []call{
+				{secret: secret("1"), target: target, scopes: scopes, created: true},
+				{secret: secret("1"), target: target, scopes: []string{scopes[1], scopes[0]}},
+			},
+		},
+		"OtherScopes": {
+			calls: []call{
+				{secret: secret("1"), target: target, scopes: scopes, created: true},
+				{secret: secret("1"), target: target, scopes: []string{DefaultScope}, created: true},
+			},
+		},
+		"SecretRotated": {
+			calls: []call{
+				{secret: secret("1"), target: target, scopes: scopes, created: true},
+				{secret: secret("2"), target: target, scopes: scopes, created: true},
+				{secret: secret("2"), target: target, scopes: scopes},
+			},
+		},
+		"TargetChanged": {
+			calls: []call{
+				{secret: secret("1"), target: target, scopes: scopes, created: true},
+				{secret: secret("1"), target: "other@cool-project.iam.gserviceaccount.com", scopes: scopes, created: true},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			created := 0
+			c := newTokenSourceCache(func(string, []string) (oauth2.TokenSource, error) {
+				created++
+				return oauth2.StaticTokenSource(&oauth2.Token{}), nil
+			})
+			for i, call := range tc.calls {
+				before := created
+				if _, err := c.Get(p, call.secret, call.target, call.scopes); err != nil {
+					t.Fatalf("call %d: c.Get(...): %s", i, err)
+				}
+				if got := created > before; got != call.created {
+					t.Errorf("call %d: c.Get(...): want token source created %t, got %t", i, call.created, got)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenSourceCacheGetError(t *testing.T) {
+	errBoom := errors.New("boom")
+	fail := true
+	c := newTokenSourceCache(func(string, []string) (oauth2.TokenSource, error) {
+		if fail {
+			return nil, errBoom
+		}
+		return oauth2.StaticTokenSource(&oauth2.Token{}), nil
+	})
+
+	p := &gcpv1alpha1.Provider{}
+	s := &corev1.Secret{}
+	if _, err := c.Get(p, s, "cool@cool-project.iam.gserviceaccount.com", nil); errors.Cause(err) != errBoom {
+		t.Fatalf("c.Get(...): want error %s, got %v", errBoom, err)
+	}
+
+	// A failure to create a token source must not be cached.
+	fail = false
+	ts, err := c.Get(p, s, "cool@cool-project.iam.gserviceaccount.com", nil)
+	if err != nil || ts == nil {
+		t.Errorf("c.Get(...): want token source, got %v, %v", ts, err)
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+)
+
+// A Provider's policy restricts the namespaces whose managed resources may use
+// it. The policy of a Provider is the ProviderPolicy of the same name in its
+// namespace; a Provider without a ProviderPolicy may be used from any
+// namespace. Policies are a kind of their own, rather than part of the
+// Provider, so that RBAC may permit editing Providers without permitting
+// changes to who may use them. Only those bound to a role that grants write
+// access to providerpolicies.gcp.crossplane.io, typically cluster
+// administrators, should be able to grant the use of a Provider.
+
+// A Policy restricts the namespaces whose managed resources may use a
+// Provider. Managed resources in the Provider's own namespace are always
+// permitted to use it.
+type Policy struct {
+	// Namespaces permitted to use the Provider.
+	Namespaces []string
+
+	// Selector matching the labels of namespaces permitted to use the
+	// Provider. Nil matches no namespaces.
+	Selector labels.Selector
+}
+
+// PolicyOf returns the Policy declared by the supplied ProviderPolicy. An empty
+// namespace selector matches every namespace.
+func PolicyOf(pp *gcpv1alpha1.ProviderPolicy) (*Policy, error) {
+	pol := &Policy{Namespaces: pp.Spec.AllowedNamespaces}
+	if pp.Spec.AllowedNamespaceSelector != nil {
+		s, err := metav1.LabelSelectorAsSelector(pp.Spec.AllowedNamespaceSelector)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot parse allowed namespace selector")
+		}
+		pol.Selector = s
+	}
+	return pol, nil
+}
+
+// getPolicy returns the Policy of the supplied Provider, or nil if its use is
+// unrestricted.
+func getPolicy(ctx context.Context, kube client.Reader, p *gcpv1alpha1.Provider) (*Policy, error) {
+	pp := &gcpv1alpha1.ProviderPolicy{}
+	n := types.NamespacedName{Namespace: p.GetNamespace(), Name: p.GetName()}
+	if err := kube.Get(ctx, n, pp); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return PolicyOf(pp)
+}
+
+// permitsName returns true if the Policy lists the supplied namespace.
+func (pol *Policy) permitsName(namespace string) bool {
+	for _, n := range pol.Namespaces {
+		if n == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+type notPermittedError struct {
+	namespace string
+	provider  types.NamespacedName
+}
+
+func (e *notPermittedError) Error() string {
+	return fmt.Sprintf("namespace %s is not permitted to use provider %s", e.namespace, e.provider)
+}
+
+// IsNotPermitted returns true if the supplied error indicates that a Provider's
+// policy does not permit its use from a namespace.
+func IsNotPermitted(err error) bool {
+	_, ok := errors.Cause(err).(*notPermittedError)
+	return ok
+}
+
+// authorize returns an error satisfying IsNotPermitted unless the policy of
+// the supplied Provider permits its use by managed resources in the supplied
+// namespace. A Provider whose policy cannot be read or parsed may only be used
+// from its own namespace.
+func authorize(ctx context.Context, kube client.Reader, namespace string, p *gcpv1alpha1.Provider) error {
+	n := types.NamespacedName{Namespace: p.GetNamespace(), Name: p.GetName()}
+	if namespace == n.Namespace {
+		return nil
+	}
+
+	pol, err := getPolicy(ctx, kube, p)
+	if err != nil {
+		return errors.Wrapf(err, "cannot get policy of provider %s", n)
+	}
+	if pol == nil || pol.permitsName(namespace) {
+		return nil
+	}
+	if pol.Selector == nil {
+		return &notPermittedError{namespace: namespace, provider: n}
+	}
+
+	ns := &corev1.Namespace{}
+	if err := kube.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		return errors.Wrapf(err, "cannot get namespace %s", namespace)
+	}
+	if !pol.Selector.Matches(labels.Set(ns.GetLabels())) {
+		return &notPermittedError{namespace: namespace, provider: n}
+	}
+	return nil
+}
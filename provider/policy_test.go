@@ -0,0 +1,254 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+// policyKube returns the supplied Provider, whose ProviderPolicy has the
+// supplied spec, and namespaces labelled with the supplied labels. The
+// Provider has no ProviderPolicy if the supplied spec is nil.
+func policyKube(spec *gcpv1alpha1.ProviderPolicySpec, nsLabels map[string]map[string]string) client.Client {
+	return &test.MockClient{MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+		switch o := obj.(type) {
+		case *gcpv1alpha1.Provider:
+			if key.Namespace != defaultNamespace {
+				return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+			}
+			o.SetNamespace(key.Namespace)
+			o.SetName(key.Name)
+		case *gcpv1alpha1.ProviderPolicy:
+			if spec == nil {
+				return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+			}
+			o.SetNamespace(key.Namespace)
+			o.SetName(key.Name)
+			o.Spec = *spec
+		case *corev1.Namespace:
+			l, ok := nsLabels[key.Name]
+			if !ok {
+				return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+			}
+			o.SetName(key.Name)
+			o.SetLabels(l)
+		}
+		return nil
+	}}
+}
+
+func TestPolicyOf(t *testing.T) {
+	cases := map[string]struct {
+		spec    gcpv1alpha1.ProviderPolicySpec
+		want    *Policy
+		wantErr bool
+	}{
+		"Empty": {
+			want: &Policy{},
+		},
+		"Namespaces": {
+			spec: gcpv1alpha1.ProviderPolicySpec{AllowedNamespaces: []string{"team-a", "team-b"}},
+			want: &Policy{Namespaces: []string{"team-a", "team-b"}},
+		},
+		"Selector": {
+			spec: gcpv1alpha1.ProviderPolicySpec{AllowedNamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "a"}}},
+			want: &Policy{Selector: labels.SelectorFromSet(labels.Set{"tenant": "a"})},
+		},
+		"InvalidSelector": {
+			spec: gcpv1alpha1.ProviderPolicySpec{AllowedNamespaceSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "tenant", Operator: "Nearly"}},
+			}},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := PolicyOf(&gcpv1alpha1.ProviderPolicy{Spec: tc.spec})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("PolicyOf(...): want error %t, got %v", tc.wantErr, err)
+			}
+			if tc.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tc.want.Namespaces, got.Namespaces); diff != "" {
+				t.Errorf("PolicyOf(...).Namespaces: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(fmt.Sprint(tc.want.Selector), fmt.Sprint(got.Selector)); diff != "" {
+				t.Errorf("PolicyOf(...).Selector: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetWithPolicy(t *testing.T) {
+	def := types.NamespacedName{Namespace: defaultNamespace, Name: defaultName}
+	labelled := map[string]map[string]string{
+		namespace:      {"tenant": "a"},
+		otherNamespace: {"tenant": "b"},
+	}
+
+	cases := map[string]struct {
+		spec      *gcpv1alpha1.ProviderPolicySpec
+		namespace string
+		wantErr   error
+	}{
+		"Unrestricted": {
+			namespace: namespace,
+		},
+		"OwnNamespace": {
+			spec:      &gcpv1alpha1.ProviderPolicySpec{AllowedNamespaces: []string{"team-a"}},
+			namespace: defaultNamespace,
+		},
+		"Listed": {
+			spec:      &gcpv1alpha1.ProviderPolicySpec{AllowedNamespaces: []string{"team-a", namespace}},
+			namespace: namespace,
+		},
+		"NotListed": {
+			spec:      &gcpv1alpha1.ProviderPolicySpec{AllowedNamespaces: []string{"team-a"}},
+			namespace: namespace,
+			wantErr:   &notPermittedError{namespace: namespace, provider: def},
+		},
+		"Selected": {
+			spec:      &gcpv1alpha1.ProviderPolicySpec{AllowedNamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "a"}}},
+			namespace: namespace,
+		},
+		"NotSelected": {
+			spec:      &gcpv1alpha1.ProviderPolicySpec{AllowedNamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "a"}}},
+			namespace: otherNamespace,
+			wantErr:   &notPermittedError{namespace: otherNamespace, provider: def},
+		},
+		"NamespaceNotFound": {
+			spec:      &gcpv1alpha1.ProviderPolicySpec{AllowedNamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "a"}}},
+			namespace: "missing",
+			wantErr:   errors.Wrap(kerrors.NewNotFound(schema.GroupResource{}, "missing"), "cannot get namespace missing"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := Resolver{Default: def}
+			mg := &metav1.ObjectMeta{Namespace: tc.namespace}
+			_, err := r.Get(context.Background(), policyKube(tc.spec, labelled), mg, nil)
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("r.Get(...): -want error, +got error:\n%s", diff)
+			}
+			if tc.wantErr == nil {
+				return
+			}
+			_, want := tc.wantErr.(*notPermittedError)
+			if got := IsNotPermitted(err); got != want {
+				t.Errorf("IsNotPermitted(...): want %t, got %t", want, got)
+			}
+		})
+	}
+}
+
+func TestPolicyWebhookAdmit(t *testing.T) {
+	def := types.NamespacedName{Namespace: defaultNamespace, Name: defaultName}
+	allowed := &gcpv1alpha1.ProviderPolicySpec{AllowedNamespaces: []string{namespace}}
+
+	policy := func(spec gcpv1alpha1.ProviderPolicySpec) runtime.RawExtension {
+		j, _ := json.Marshal(&gcpv1alpha1.ProviderPolicy{Spec: spec})
+		return runtime.RawExtension{Raw: j}
+	}
+	policyKind := metav1.GroupVersionKind{Kind: gcpv1alpha1.ProviderPolicyGroupVersionKind.Kind}
+
+	raw := func(ref *corev1.ObjectReference) runtime.RawExtension {
+		obj := &referencing{}
+		obj.Spec.ProviderReference = ref
+		j, _ := json.Marshal(obj)
+		return runtime.RawExtension{Raw: j}
+	}
+	ref := &corev1.ObjectReference{Namespace: defaultNamespace, Name: defaultName}
+
+	cases := map[string]struct {
+		req  *admissionv1beta1.AdmissionRequest
+		want bool
+	}{
+		"CreatePermitted": {
+			req:  &admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Create, Namespace: namespace, Object: raw(ref)},
+			want: true,
+		},
+		"CreateNotPermitted": {
+			req:  &admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Create, Namespace: otherNamespace, Object: raw(ref)},
+			want: false,
+		},
+		"CreateDefaultNotPermitted": {
+			req:  &admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Create, Namespace: otherNamespace, Object: raw(nil)},
+			want: false,
+		},
+		"CreateProviderNotFound": {
+			req:  &admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Create, Namespace: otherNamespace, Object: raw(&corev1.ObjectReference{Namespace: "missing", Name: providerName})},
+			want: true,
+		},
+		"UpdateUnchanged": {
+			req:  &admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Update, Namespace: otherNamespace, Object: raw(ref), OldObject: raw(ref)},
+			want: true,
+		},
+		"UpdateChanged": {
+			req:  &admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Update, Namespace: otherNamespace, Object: raw(ref), OldObject: raw(nil)},
+			want: false,
+		},
+		"Delete": {
+			req:  &admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Delete, Namespace: otherNamespace},
+			want: true,
+		},
+		"CreateValidPolicy": {
+			req:  &admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Create, Kind: policyKind, Namespace: defaultNamespace, Object: policy(*allowed)},
+			want: true,
+		},
+		"UpdateInvalidPolicy": {
+			req: &admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Update, Kind: policyKind, Namespace: defaultNamespace, Object: policy(gcpv1alpha1.ProviderPolicySpec{
+				AllowedNamespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "tenant", Operator: "Nearly"}}},
+			})},
+			want: false,
+		},
+		"Undecodable": {
+			req:  &admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Create, Namespace: namespace, Object: runtime.RawExtension{Raw: []byte("{")}},
+			want: false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := NewPolicyWebhook(policyKube(allowed, nil), Resolver{Default: def})
+			got := w.admit(context.Background(), tc.req)
+			if got.Allowed != tc.want {
+				t.Errorf("w.admit(...): want allowed %t, got %t: %v", tc.want, got.Allowed, got.Result)
+			}
+		})
+	}
+}
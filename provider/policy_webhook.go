@@ -0,0 +1,146 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+)
+
+// PolicyWebhookPath is the path at which the Provider policy webhook is
+// served.
+const PolicyWebhookPath = "/validate/providerpolicy.gcp.crossplane.io"
+
+// requestTimeout bounds the reads of Providers and namespaces made while
+// admitting a resource. The API server waits on the webhook, so it must be
+// brief.
+const requestTimeout = 5 * time.Second
+
+var log = logging.Logger.WithName("webhook.providerpolicy")
+
+// A PolicyWebhook rejects managed resources that reference a Provider whose
+// policy does not permit its use from their namespace, so that the mistake is
+// reported when the resource is applied rather than when it is reconciled. It
+// also rejects ProviderPolicies that cannot be parsed, which would otherwise
+// restrict their Provider to its own namespace.
+type PolicyWebhook struct {
+	kube      client.Client
+	providers Resolver
+}
+
+// NewPolicyWebhook returns a Provider policy webhook that resolves Providers
+// using the supplied client and Resolver.
+func NewPolicyWebhook(kube client.Client, r Resolver) *PolicyWebhook {
+	return &PolicyWebhook{kube: kube, providers: r}
+}
+
+// ServeHTTP handles an AdmissionReview sent by the API server.
+func (w *PolicyWebhook) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	review := &admissionv1beta1.AdmissionReview{}
+	if err := json.NewDecoder(req.Body).Decode(review); err != nil || review.Request == nil {
+		http.Error(rw, "cannot decode admission review", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), requestTimeout)
+	defer cancel()
+
+	review.Response = w.admit(ctx, review.Request)
+	review.Request = nil
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(review); err != nil {
+		log.Error(err, "cannot encode admission review")
+	}
+}
+
+// referencing is the subset of a managed resource read by the webhook.
+type referencing struct {
+	Spec struct {
+		ProviderReference *corev1.ObjectReference `json:"providerRef,omitempty"`
+	} `json:"spec"`
+}
+
+// admit denies the supplied AdmissionRequest if it creates a managed resource,
+// or changes the Provider reference of a managed resource, such that it
+// references a Provider it is not permitted to use. Updates that leave the
+// reference unchanged are always allowed so that resources remain deletable
+// after a policy is tightened; their controller reports the violation.
+func (w *PolicyWebhook) admit(ctx context.Context, req *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	rsp := &admissionv1beta1.AdmissionResponse{UID: req.UID, Allowed: true}
+	if req.Operation != admissionv1beta1.Create && req.Operation != admissionv1beta1.Update {
+		return rsp
+	}
+
+	if req.Kind.Kind == gcpv1alpha1.ProviderPolicyGroupVersionKind.Kind {
+		return admitPolicy(rsp, req)
+	}
+
+	obj := &referencing{}
+	if err := json.Unmarshal(req.Object.Raw, obj); err != nil {
+		return deny(rsp, metav1.StatusReasonBadRequest, "cannot decode "+req.Kind.Kind)
+	}
+
+	if req.Operation == admissionv1beta1.Update {
+		old := &referencing{}
+		if err := json.Unmarshal(req.OldObject.Raw, old); err == nil && reflect.DeepEqual(old.Spec.ProviderReference, obj.Spec.ProviderReference) {
+			return rsp
+		}
+	}
+
+	mg := &metav1.ObjectMeta{Namespace: req.Namespace}
+	_, err := w.providers.Get(ctx, w.kube, mg, obj.Spec.ProviderReference)
+	if IsNotPermitted(err) {
+		return deny(rsp, metav1.StatusReasonForbidden, err.Error())
+	}
+	if err != nil {
+		// A Provider that can't be resolved yet may be created later; the
+		// controller reports it until then.
+		log.V(logging.Debug).Info("cannot resolve provider", "namespace", req.Namespace, "error", err)
+	}
+	return rsp
+}
+
+// admitPolicy denies the supplied AdmissionRequest if it creates or updates a
+// ProviderPolicy that cannot be parsed.
+func admitPolicy(rsp *admissionv1beta1.AdmissionResponse, req *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	pp := &gcpv1alpha1.ProviderPolicy{}
+	if err := json.Unmarshal(req.Object.Raw, pp); err != nil {
+		return deny(rsp, metav1.StatusReasonBadRequest, "cannot decode "+req.Kind.Kind)
+	}
+	if _, err := PolicyOf(pp); err != nil {
+		return deny(rsp, metav1.StatusReasonInvalid, err.Error())
+	}
+	return rsp
+}
+
+func deny(rsp *admissionv1beta1.AdmissionResponse, reason metav1.StatusReason, message string) *admissionv1beta1.AdmissionResponse {
+	rsp.Allowed = false
+	rsp.Result = &metav1.Status{Status: metav1.StatusFailure, Reason: reason, Message: message}
+	return rsp
+}
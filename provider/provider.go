@@ -0,0 +1,78 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package provider resolves the GCP Provider used to authenticate calls made
+// on behalf of a managed resource.
+package provider
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+)
+
+// A Resolver resolves the Provider referenced by a managed resource.
+type Resolver struct {
+	// Default is the Provider used by managed resources that do not
+	// reference a Provider. No default is used if its name is empty.
+	Default types.NamespacedName
+}
+
+// Get returns the Provider referenced by the supplied managed resource. A
+// reference that omits a namespace refers to a Provider in the namespace of
+// the managed resource. The default Provider is returned only if no Provider
+// is referenced; a referenced Provider that does not exist is an error. An
+// error satisfying IsNotPermitted is returned if the Provider's policy does
+// not permit its use from the managed resource's namespace.
+func (r *Resolver) Get(ctx context.Context, kube client.Client, mg metav1.Object, ref *corev1.ObjectReference) (*gcpv1alpha1.Provider, error) {
+	p, err := r.resolve(ctx, kube, mg, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize(ctx, kube, mg.GetNamespace(), p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *Resolver) resolve(ctx context.Context, kube client.Client, mg metav1.Object, ref *corev1.ObjectReference) (*gcpv1alpha1.Provider, error) {
+	if ref == nil {
+		return r.getDefault(ctx, kube)
+	}
+
+	p := &gcpv1alpha1.Provider{}
+	n := types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}
+	if n.Namespace == "" {
+		n.Namespace = mg.GetNamespace()
+	}
+	// A reference that does not resolve never falls back to the default, lest
+	// a typo provision the managed resource in the wrong project.
+	return p, errors.Wrapf(kube.Get(ctx, n, p), "cannot get provider %s", n)
+}
+
+func (r *Resolver) getDefault(ctx context.Context, kube client.Client) (*gcpv1alpha1.Provider, error) {
+	if r.Default.Name == "" {
+		return nil, errors.New("no provider is referenced and no default provider is configured")
+	}
+	p := &gcpv1alpha1.Provider{}
+	return p, errors.Wrapf(kube.Get(ctx, r.Default, p), "cannot get default provider %s", r.Default)
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace        = "cool-namespace"
+	otherNamespace   = "other-namespace"
+	defaultNamespace = "crossplane-system"
+	providerName     = "cool-provider"
+	defaultName      = "default-provider"
+)
+
+func TestGet(t *testing.T) {
+	errNotFound := kerrors.NewNotFound(schema.GroupResource{}, providerName)
+	errBoom := errors.New("boom")
+
+	def := types.NamespacedName{Namespace: defaultNamespace, Name: defaultName}
+	mg := &metav1.ObjectMeta{Namespace: namespace}
+
+	// kube returns a Provider named after its key if it exists, and the
+	// supplied error otherwise.
+	kube := func(exists map[types.NamespacedName]bool, err error) client.Client {
+		return &test.MockClient{MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+			if !exists[key] {
+				return err
+			}
+			obj.(*gcpv1alpha1.Provider).SetNamespace(key.Namespace)
+			obj.(*gcpv1alpha1.Provider).SetName(key.Name)
+			return nil
+		}}
+	}
+
+	provider := func(n types.NamespacedName) *gcpv1alpha1.Provider {
+		p := &gcpv1alpha1.Provider{}
+		p.SetNamespace(n.Namespace)
+		p.SetName(n.Name)
+		return p
+	}
+
+	cases := map[string]struct {
+		r       Resolver
+		kube    client.Client
+		ref     *corev1.ObjectReference
+		want    *gcpv1alpha1.Provider
+		wantErr error
+	}{
+		"ReferencedInOwnNamespace": {
+			r:    Resolver{Default: def},
+			kube: kube(map[types.NamespacedName]bool{{Namespace: namespace, Name: providerName}: true}, errNotFound),
+			ref:  &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			want: provider(types.NamespacedName{Namespace: namespace, Name: providerName}),
+		},
+		"NamespaceOmitted": {
+			kube: kube(map[types.NamespacedName]bool{{Namespace: namespace, Name: providerName}: true}, errNotFound),
+			ref:  &corev1.ObjectReference{Name: providerName},
+			want: provider(types.NamespacedName{Namespace: namespace, Name: providerName}),
+		},
+		"NotFoundDoesNotFallBackToDefault": {
+			r:       Resolver{Default: def},
+			kube:    kube(map[types.NamespacedName]bool{def: true}, errNotFound),
+			ref:     &corev1.ObjectReference{Name: providerName},
+			wantErr: errors.Wrapf(errNotFound, "cannot get provider %s/%s", namespace, providerName),
+		},
+		"NotFoundWithoutDefault": {
+			kube:    kube(nil, errNotFound),
+			ref:     &corev1.ObjectReference{Name: providerName},
+			wantErr: errors.Wrapf(errNotFound, "cannot get provider %s/%s", namespace, providerName),
+		},
+		"NotFoundInOtherNamespace": {
+			r:       Resolver{Default: def},
+			kube:    kube(map[types.NamespacedName]bool{def: true}, errNotFound),
+			ref:     &corev1.ObjectReference{Namespace: otherNamespace, Name: providerName},
+			wantErr: errors.Wrapf(errNotFound, "cannot get provider %s/%s", otherNamespace, providerName),
+		},
+		"GetError": {
+			r:       Resolver{Default: def},
+			kube:    kube(map[types.NamespacedName]bool{def: true}, errBoom),
+			ref:     &corev1.ObjectReference{Name: providerName},
+			wantErr: errors.Wrapf(errBoom, "cannot get provider %s/%s", namespace, providerName),
+		},
+		"NotReferenced": {
+			r:    Resolver{Default: def},
+			kube: kube(map[types.NamespacedName]bool{def: true}, errNotFound),
+			want: provider(def),
+		},
+		"NotReferencedWithoutDefault": {
+			kube:    kube(nil, errNotFound),
+			wantErr: errors.New("no provider is referenced and no default provider is configured"),
+		},
+		"DefaultNotFound": {
+			r:       Resolver{Default: def},
+			kube:    kube(nil, errNotFound),
+			wantErr: errors.Wrapf(errNotFound, "cannot get default provider %s", def),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.r.Get(context.Background(), tc.kube, mg, tc.ref)
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("r.Get(...): -want error, +got error:\n%s", diff)
+			}
+			if tc.wantErr != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("r.Get(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/shard"
+)
+
+// mapTimeout bounds the time spent mapping a changed secret to the managed
+// resources that use it.
+const mapTimeout = 30 * time.Second
+
+// A Reference from a managed resource to the Provider it uses.
+type Reference struct {
+	// Resource is the managed resource.
+	Resource metav1.Object
+
+	// Provider is the managed resource's provider reference, if any.
+	Provider *corev1.ObjectReference
+}
+
+// A Lister lists managed resources of a particular kind, and the Providers
+// they reference.
+type Lister func(ctx context.Context, kube client.Client) ([]Reference, error)
+
+// EnqueueRequestsForSecret returns an event handler that, when a Secret
+// changes, enqueues a reconcile request for each managed resource listed by
+// the supplied Lister whose Provider reads its credentials from that Secret.
+// GCP clients are built from the Provider's Secret on every reconcile, so this
+// ensures rotated credentials are used promptly rather than at the next sync.
+func (r *Resolver) EnqueueRequestsForSecret(kube client.Client, list Lister) handler.EventHandler {
+	return &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+			ctx, cancel := context.WithTimeout(context.Background(), mapTimeout)
+			defer cancel()
+			return r.requestsForSecret(ctx, kube, list, types.NamespacedName{Namespace: o.Meta.GetNamespace(), Name: o.Meta.GetName()})
+		}),
+	}
+}
+
+// requestsForSecret returns a reconcile request for each listed managed
+// resource whose Provider uses the supplied Secret. Mapping is best effort;
+// resources that cannot be listed or resolved are reconciled at their next
+// sync as usual. Most Secrets, such as the connection secrets written on every
+// sync, are used by no Provider, so managed resources are listed only for
+// Secrets that are.
+func (r *Resolver) requestsForSecret(ctx context.Context, kube client.Client, list Lister, secret types.NamespacedName) []reconcile.Request {
+	if !isProviderSecret(ctx, kube, secret) {
+		return nil
+	}
+
+	refs, err := list(ctx, kube)
+	if err != nil {
+		return nil
+	}
+
+	reqs := []reconcile.Request{}
+	for _, ref := range refs {
+		p, err := r.Get(ctx, kube, ref.Resource, ref.Provider)
+		if err != nil {
+			continue
+		}
+		if p.GetNamespace() != secret.Namespace || !usesSecret(p, secret.Name) {
+			continue
+		}
+		if !shard.Owns(kube, ref.Resource) {
+			continue
+		}
+		reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{
+			Namespace: ref.Resource.GetNamespace(),
+			Name:      ref.Resource.GetName(),
+		}})
+	}
+	return reqs
+}
+
+// isProviderSecret returns true if any Provider in the supplied Secret's
+// namespace reads credentials from it. Providers are listed from the cache, so
+// this is much cheaper than listing and resolving the Providers of all managed
+// resources. It returns true if Providers cannot be listed.
+func isProviderSecret(ctx context.Context, kube client.Client, secret types.NamespacedName) bool {
+	l := &gcpv1alpha1.ProviderList{}
+	if err := kube.List(ctx, l, client.InNamespace(secret.Namespace)); err != nil {
+		return true
+	}
+	for i := range l.Items {
+		if usesSecret(&l.Items[i], secret.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// usesSecret returns true if the supplied Provider reads credentials from the
+// named Secret in its namespace, either for all services or for one.
+func usesSecret(p *gcpv1alpha1.Provider, name string) bool {
+	if p.Spec.Secret.Name == name {
+		return true
+	}
+	for _, sc := range p.Spec.ServiceCredentials {
+		if sc.Secret != nil && sc.Secret.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestRequestsForSecret(t *testing.T) {
+	secret := types.NamespacedName{Namespace: namespace, Name: "cool-secret"}
+	def := types.NamespacedName{Namespace: defaultNamespace, Name: defaultName}
+
+	// Providers in the resource namespace use cool-secret; the default
+	// provider uses a secret of the same name in another namespace.
+	provider := func(namespace, name string) gcpv1alpha1.Provider {
+		p := gcpv1alpha1.Provider{}
+		p.SetNamespace(namespace)
+		p.SetName(name)
+		p.Spec.Secret = corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "cool-secret"}}
+		p.Spec.ServiceCredentials = []gcpv1alpha1.ServiceCredentials{{
+			Service: ServiceSQLAdmin,
+			Secret:  &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "cool-sql-secret"}},
+		}}
+		return p
+	}
+	kube := &test.MockClient{
+		MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+			if key.Name == "missing" {
+				return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+			}
+			*obj.(*gcpv1alpha1.Provider) = provider(key.Namespace, key.Name)
+			return nil
+		},
+		MockList: func(_ context.Context, obj runtime.Object, _ ...client.ListOption) error {
+			obj.(*gcpv1alpha1.ProviderList).Items = []gcpv1alpha1.Provider{provider(namespace, providerName)}
+			return nil
+		},
+	}
+
+	mg := func(name string) metav1.Object { return &metav1.ObjectMeta{Namespace: namespace, Name: name} }
+	req := func(name string) reconcile.Request {
+		return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}
+	}
+
+	cases := map[string]struct {
+		r      Resolver
+		list   Lister
+		secret types.NamespacedName
+		want   []reconcile.Request
+	}{
+		"ResourcesUsingSecret": {
+			r: Resolver{Default: def},
+			list: func(_ context.Context, _ client.Client) ([]Reference, error) {
+				return []Reference{
+					{Resource: mg("referenced"), Provider: &corev1.ObjectReference{Name: providerName}},
+					{Resource: mg("other-namespace"), Provider: &corev1.ObjectReference{Namespace: otherNamespace, Name: providerName}},
+					{Resource: mg("unreferenced"), Provider: nil},
+					{Resource: mg("missing-provider"), Provider: &corev1.ObjectReference{Name: "missing"}},
+				}, nil
+			},
+			secret: secret,
+			want:   []reconcile.Request{req("referenced")},
+		},
+		"ResourcesUsingServiceSecret": {
+			r: Resolver{Default: def},
+			list: func(_ context.Context, _ client.Client) ([]Reference, error) {
+				return []Reference{
+					{Resource: mg("referenced"), Provider: &corev1.ObjectReference{Name: providerName}},
+					{Resource: mg("other-namespace"), Provider: &corev1.ObjectReference{Namespace: otherNamespace, Name: providerName}},
+				}, nil
+			},
+			secret: types.NamespacedName{Namespace: namespace, Name: "cool-sql-secret"},
+			want:   []reconcile.Request{req("referenced")},
+		},
+		"UnusedSecret": {
+			r: Resolver{Default: def},
+			list: func(_ context.Context, _ client.Client) ([]Reference, error) {
+				t.Errorf("list(...): unexpected call for a Secret no Provider uses")
+				return nil, nil
+			},
+			secret: types.NamespacedName{Namespace: namespace, Name: "connection-secret"},
+		},
+		"DefaultProviderSecret": {
+			r: Resolver{Default: def},
+			list: func(_ context.Context, _ client.Client) ([]Reference, error) {
+				return []Reference{
+					{Resource: mg("referenced"), Provider: &corev1.ObjectReference{Name: providerName}},
+					{Resource: mg("unreferenced"), Provider: nil},
+					{Resource: mg("missing-provider"), Provider: &corev1.ObjectReference{Name: "missing"}},
+				}, nil
+			},
+			secret: types.NamespacedName{Namespace: defaultNamespace, Name: "cool-secret"},
+			want:   []reconcile.Request{req("unreferenced")},
+		},
+		"ListError": {
+			list: func(_ context.Context, _ client.Client) ([]Reference, error) {
+				return nil, errors.New("boom")
+			},
+			secret: secret,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := tc.r.requestsForSecret(context.Background(), kube, tc.list, tc.secret)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("r.requestsForSecret(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
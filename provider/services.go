@@ -0,0 +1,104 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/googleapi"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/resourcemanager"
+)
+
+const (
+	// errorInfoType is the type of the google.rpc.ErrorInfo details of a GCP
+	// API error, which explain why the call failed.
+	errorInfoType = "type.googleapis.com/google.rpc.ErrorInfo"
+
+	// serviceDisabledReason is the reason GCP APIs give for failing calls
+	// to a service that is not enabled in the consumer project.
+	serviceDisabledReason = "SERVICE_DISABLED"
+)
+
+// DisabledService returns the service that the supplied error reports is not
+// enabled, e.g. container.googleapis.com, and the project that consumes it,
+// e.g. projects/123. It returns false if the error doesn't report a disabled
+// service.
+func DisabledService(err error) (service, consumer string, ok bool) {
+	gerr, isAPIError := errors.Cause(err).(*googleapi.Error)
+	if !isAPIError {
+		return "", "", false
+	}
+	for _, d := range gerr.Details {
+		info, isMap := d.(map[string]interface{})
+		if !isMap || info["@type"] != errorInfoType || info["reason"] != serviceDisabledReason {
+			continue
+		}
+		md, _ := info["metadata"].(map[string]interface{})
+		service, _ = md["service"].(string)
+		consumer, _ = md["consumer"].(string)
+		return service, consumer, service != "" && consumer != ""
+	}
+	return "", "", false
+}
+
+// A ServiceEnabler enables the GCP services that API calls report are not
+// enabled in the project they were made against, for Providers that opt in to
+// doing so. New projects have few services enabled, so the first resource
+// created in a project would otherwise fail to be created.
+type ServiceEnabler struct {
+	kube      client.Client
+	newClient func(ctx context.Context, creds *google.Credentials) (resourcemanager.Client, error)
+}
+
+// NewServiceEnabler returns a ServiceEnabler that reads Provider credentials
+// using the supplied client.
+func NewServiceEnabler(kube client.Client) *ServiceEnabler {
+	return &ServiceEnabler{kube: kube, newClient: resourcemanager.NewClient}
+}
+
+// EnableDisabledService enables the service that the supplied error reports
+// is not enabled, if the supplied Provider enables disabled services. It
+// returns true if it requested that the service be enabled, in which case the
+// failed call should be retried once GCP has enabled it - typically within a
+// few minutes.
+func (e *ServiceEnabler) EnableDisabledService(ctx context.Context, p *gcpv1alpha1.Provider, err error) (bool, error) {
+	if e == nil || !p.Spec.EnableDisabledServices {
+		return false, nil
+	}
+	service, consumer, ok := DisabledService(err)
+	if !ok {
+		return false, nil
+	}
+
+	creds, cerr := ServiceCredentials(ctx, e.kube, p, ServiceResourceManager)
+	if cerr != nil {
+		return false, cerr
+	}
+	c, cerr := e.newClient(ctx, creds)
+	if cerr != nil {
+		return false, errors.Wrap(cerr, "cannot create new resource manager client")
+	}
+	if cerr := c.EnableService(ctx, consumer+"/services/"+service); cerr != nil {
+		return false, errors.Wrapf(cerr, "cannot enable disabled service %s in %s", service, consumer)
+	}
+	return true, nil
+}
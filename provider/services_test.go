@@ -0,0 +1,182 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/googleapi"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/resourcemanager"
+	fakeresourcemanager "github.com/crossplaneio/crossplane/pkg/clients/gcp/resourcemanager/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+// serviceDisabled returns the error GCP APIs return when the supplied service
+// is not enabled in the supplied consumer project.
+func serviceDisabled(service, consumer string) error {
+	return &googleapi.Error{
+		Code:    http.StatusForbidden,
+		Message: "Kubernetes Engine API has not been used in project 123 before or it is disabled.",
+		Details: []interface{}{map[string]interface{}{
+			"@type":    errorInfoType,
+			"reason":   serviceDisabledReason,
+			"domain":   "googleapis.com",
+			"metadata": map[string]interface{}{"service": service, "consumer": consumer},
+		}},
+	}
+}
+
+func TestDisabledService(t *testing.T) {
+	type want struct {
+		service  string
+		consumer string
+		ok       bool
+	}
+
+	cases := map[string]struct {
+		err  error
+		want want
+	}{
+		"ServiceDisabled": {
+			err:  errors.Wrap(serviceDisabled("container.googleapis.com", "projects/123"), "cannot create cluster"),
+			want: want{service: "container.googleapis.com", consumer: "projects/123", ok: true},
+		},
+		"OtherReason": {
+			err: &googleapi.Error{Code: http.StatusForbidden, Details: []interface{}{map[string]interface{}{
+				"@type":  errorInfoType,
+				"reason": "IAM_PERMISSION_DENIED",
+			}}},
+		},
+		"NotAnAPIError": {
+			err: errors.New("boom"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			service, consumer, ok := DisabledService(tc.err)
+			got := want{service: service, consumer: consumer, ok: ok}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("DisabledService(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEnableDisabledService(t *testing.T) {
+	errBoom := errors.New("boom")
+	disabled := serviceDisabled("sqladmin.googleapis.com", "projects/123")
+
+	kube := &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+		obj.(*corev1.Secret).Data = map[string][]byte{secretKey: []byte("ya29.definitely-a-token")}
+		return nil
+	}}
+
+	provider := func(enable bool) *gcpv1alpha1.Provider {
+		p := &gcpv1alpha1.Provider{
+			Spec: gcpv1alpha1.ProviderSpec{
+				ProjectID:       projectID,
+				CredentialsType: gcpv1alpha1.CredentialsTypeAccessToken,
+				Secret: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+					Key:                  secretKey,
+				},
+				EnableDisabledServices: enable,
+			},
+		}
+		p.SetNamespace(namespace)
+		p.SetName(providerName)
+		return p
+	}
+
+	// enabler returns a ServiceEnabler whose client enables services by
+	// returning the supplied error, recording the service it was asked to
+	// enable.
+	var enabled string
+	enabler := func(err error) *ServiceEnabler {
+		return &ServiceEnabler{kube: kube, newClient: func(_ context.Context, _ *google.Credentials) (resourcemanager.Client, error) {
+			return &fakeresourcemanager.MockClient{MockEnableService: func(_ context.Context, name string) error {
+				enabled = name
+				return err
+			}}, nil
+		}}
+	}
+
+	cases := map[string]struct {
+		e           *ServiceEnabler
+		p           *gcpv1alpha1.Provider
+		err         error
+		want        bool
+		wantErr     error
+		wantEnabled string
+	}{
+		"Enabled": {
+			e:           enabler(nil),
+			p:           provider(true),
+			err:         disabled,
+			want:        true,
+			wantEnabled: "projects/123/services/sqladmin.googleapis.com",
+		},
+		"NotOptedIn": {
+			e:   enabler(nil),
+			p:   provider(false),
+			err: disabled,
+		},
+		"NilEnabler": {
+			p:   provider(true),
+			err: disabled,
+		},
+		"OtherError": {
+			e:   enabler(nil),
+			p:   provider(true),
+			err: errBoom,
+		},
+		"EnableFailed": {
+			e:           enabler(errBoom),
+			p:           provider(true),
+			err:         disabled,
+			wantErr:     errors.Wrapf(errBoom, "cannot enable disabled service %s in %s", "sqladmin.googleapis.com", "projects/123"),
+			wantEnabled: "projects/123/services/sqladmin.googleapis.com",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			enabled = ""
+			got, err := tc.e.EnableDisabledService(context.Background(), tc.p, tc.err)
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("EnableDisabledService(...): -want error, +got error:\n%s", diff)
+			}
+			if got != tc.want {
+				t.Errorf("EnableDisabledService(...): want %t, got %t", tc.want, got)
+			}
+			if enabled != tc.wantEnabled {
+				t.Errorf("EnableDisabledService(...): want enabled %q, got %q", tc.wantEnabled, enabled)
+			}
+		})
+	}
+}
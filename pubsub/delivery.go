@@ -0,0 +1,165 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pubsub
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	pubsubv1 "google.golang.org/api/pubsub/v1"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/pubsub/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+)
+
+const (
+	// deliveryStateActive is the state of a BigQuery or Cloud Storage
+	// subscription that is able to deliver messages.
+	deliveryStateActive = "ACTIVE"
+
+	// outputFormatAvro writes messages to Cloud Storage as Avro records,
+	// rather than as newline delimited text.
+	outputFormatAvro = "Avro"
+)
+
+// subscriptionName returns the fully qualified name of a subscription.
+func subscriptionName(project, name string) string {
+	return fmt.Sprintf("projects/%s/subscriptions/%s", project, name)
+}
+
+// topicName returns the fully qualified name of the supplied topic, which may
+// be either a name or a fully qualified name.
+func topicName(project, topic string) string {
+	if strings.HasPrefix(topic, "projects/") {
+		return topic
+	}
+	return fmt.Sprintf("projects/%s/topics/%s", project, topic)
+}
+
+// validateDelivery returns an error if the supplied subscription requests
+// more than one kind of delivery.
+func validateDelivery(p v1alpha1.SubscriptionParameters) error {
+	n := 0
+	for _, set := range []bool{p.PushEndpoint != "", p.BigQueryConfig != nil, p.CloudStorageConfig != nil} {
+		if set {
+			n++
+		}
+	}
+	if n > 1 {
+		return errors.New("only one of pushEndpoint, bigQueryConfig, or cloudStorageConfig may be set")
+	}
+	return nil
+}
+
+// newSubscription returns a Pub/Sub subscription with the supplied name,
+// described by the supplied parameters.
+func newSubscription(project, name string, p v1alpha1.SubscriptionParameters) *pubsubv1.Subscription {
+	s := &pubsubv1.Subscription{
+		Name:               subscriptionName(project, name),
+		Topic:              topicName(project, p.Topic),
+		AckDeadlineSeconds: p.AckDeadlineSeconds,
+		Labels:             p.Labels,
+		BigqueryConfig:     bigQueryConfig(p.BigQueryConfig),
+		CloudStorageConfig: cloudStorageConfig(p.CloudStorageConfig),
+	}
+	if p.PushEndpoint != "" {
+		s.PushConfig = &pubsubv1.PushConfig{PushEndpoint: p.PushEndpoint}
+	}
+	return s
+}
+
+func bigQueryConfig(c *v1alpha1.BigQueryConfig) *pubsubv1.BigQueryConfig {
+	if c == nil {
+		return nil
+	}
+	return &pubsubv1.BigQueryConfig{
+		Table:               c.Table,
+		UseTopicSchema:      c.UseTopicSchema,
+		UseTableSchema:      c.UseTableSchema,
+		WriteMetadata:       c.WriteMetadata,
+		DropUnknownFields:   c.DropUnknownFields,
+		ServiceAccountEmail: c.ServiceAccountEmail,
+	}
+}
+
+func cloudStorageConfig(c *v1alpha1.CloudStorageConfig) *pubsubv1.CloudStorageConfig {
+	if c == nil {
+		return nil
+	}
+	cfg := &pubsubv1.CloudStorageConfig{
+		Bucket:                 c.Bucket,
+		FilenamePrefix:         c.FilenamePrefix,
+		FilenameSuffix:         c.FilenameSuffix,
+		FilenameDatetimeFormat: c.FilenameDatetimeFormat,
+		MaxBytes:               c.MaxBytes,
+		MaxDuration:            c.MaxDuration,
+		ServiceAccountEmail:    c.ServiceAccountEmail,
+	}
+	if c.OutputFormat == outputFormatAvro {
+		cfg.AvroConfig = &pubsubv1.AvroConfig{WriteMetadata: c.WriteMetadata, UseTopicSchema: c.UseTopicSchema}
+	} else {
+		cfg.TextConfig = &pubsubv1.TextConfig{}
+	}
+	return cfg
+}
+
+// deliveryState returns the state of the supplied subscription's BigQuery or
+// Cloud Storage delivery, if any.
+func deliveryState(s *pubsubv1.Subscription) string {
+	switch {
+	case s.BigqueryConfig != nil:
+		return s.BigqueryConfig.State
+	case s.CloudStorageConfig != nil:
+		return s.CloudStorageConfig.State
+	}
+	return ""
+}
+
+// updateMask returns the update mask of the fields of the desired subscription
+// that differ from the actual subscription, in lexical order. Fields that are
+// output only or defaulted by Pub/Sub are ignored.
+func updateMask(desired, actual *pubsubv1.Subscription) []string {
+	mask := []string{}
+	if !compare.Equal(desired.Labels, actual.Labels) {
+		mask = append(mask, "labels")
+	}
+	if desired.AckDeadlineSeconds != 0 && desired.AckDeadlineSeconds != actual.AckDeadlineSeconds {
+		mask = append(mask, "ackDeadlineSeconds")
+	}
+
+	// Delivery configs must be compared for presence, so that switching from
+	// one kind of delivery to another is detected.
+	delivery := []struct {
+		path            string
+		desired, actual interface{}
+		wantSet, isSet  bool
+	}{
+		{"pushConfig", desired.PushConfig, actual.PushConfig, desired.PushConfig != nil, actual.PushConfig != nil && actual.PushConfig.PushEndpoint != ""},
+		{"bigqueryConfig", desired.BigqueryConfig, actual.BigqueryConfig, desired.BigqueryConfig != nil, actual.BigqueryConfig != nil},
+		{"cloudStorageConfig", desired.CloudStorageConfig, actual.CloudStorageConfig, desired.CloudStorageConfig != nil, actual.CloudStorageConfig != nil},
+	}
+	for _, d := range delivery {
+		if d.wantSet != d.isSet || !compare.Equal(d.desired, d.actual, compare.IgnoreFields("state"), compare.IgnoreUnset()) {
+			mask = append(mask, d.path)
+		}
+	}
+
+	sort.Strings(mask)
+	return mask
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	pubsubv1 "google.golang.org/api/pubsub/v1"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/pubsub/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+func TestNewSubscription(t *testing.T) {
+	cases := map[string]struct {
+		p    v1alpha1.SubscriptionParameters
+		want *pubsubv1.Subscription
+	}{
+		"Pull": {
+			p: v1alpha1.SubscriptionParameters{Topic: topic, AckDeadlineSeconds: 20},
+			want: &pubsubv1.Subscription{
+				Name:               subscriptionName(project, subName),
+				Topic:              topicName(project, topic),
+				AckDeadlineSeconds: 20,
+			},
+		},
+		"FullyQualifiedTopic": {
+			p: v1alpha1.SubscriptionParameters{Topic: "projects/other-project/topics/" + topic},
+			want: &pubsubv1.Subscription{
+				Name:  subscriptionName(project, subName),
+				Topic: "projects/other-project/topics/" + topic,
+			},
+		},
+		"BigQuery": {
+			p: v1alpha1.SubscriptionParameters{
+				Topic: topic,
+				BigQueryConfig: &v1alpha1.BigQueryConfig{
+					Table:         table,
+					WriteMetadata: true,
+				},
+			},
+			want: &pubsubv1.Subscription{
+				Name:           subscriptionName(project, subName),
+				Topic:          topicName(project, topic),
+				BigqueryConfig: &pubsubv1.BigQueryConfig{Table: table, WriteMetadata: true},
+			},
+		},
+		"CloudStorageText": {
+			p: v1alpha1.SubscriptionParameters{
+				Topic: topic,
+				CloudStorageConfig: &v1alpha1.CloudStorageConfig{
+					Bucket:                 bucket,
+					FilenamePrefix:         "events/",
+					FilenameSuffix:         ".json",
+					FilenameDatetimeFormat: "YYYY-MM-DD/hh_mm_ssZ",
+					MaxDuration:            "300s",
+				},
+			},
+			want: &pubsubv1.Subscription{
+				Name:  subscriptionName(project, subName),
+				Topic: topicName(project, topic),
+				CloudStorageConfig: &pubsubv1.CloudStorageConfig{
+					Bucket:                 bucket,
+					FilenamePrefix:         "events/",
+					FilenameSuffix:         ".json",
+					FilenameDatetimeFormat: "YYYY-MM-DD/hh_mm_ssZ",
+					MaxDuration:            "300s",
+					TextConfig:             &pubsubv1.TextConfig{},
+				},
+			},
+		},
+		"CloudStorageAvro": {
+			p: v1alpha1.SubscriptionParameters{
+				Topic: topic,
+				CloudStorageConfig: &v1alpha1.CloudStorageConfig{
+					Bucket:        bucket,
+					OutputFormat:  outputFormatAvro,
+					WriteMetadata: true,
+				},
+			},
+			want: &pubsubv1.Subscription{
+				Name:  subscriptionName(project, subName),
+				Topic: topicName(project, topic),
+				CloudStorageConfig: &pubsubv1.CloudStorageConfig{
+					Bucket:     bucket,
+					AvroConfig: &pubsubv1.AvroConfig{WriteMetadata: true},
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := newSubscription(project, subName, tc.p)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("newSubscription(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateDelivery(t *testing.T) {
+	cases := map[string]struct {
+		p    v1alpha1.SubscriptionParameters
+		want error
+	}{
+		"Pull": {
+			p: v1alpha1.SubscriptionParameters{Topic: topic},
+		},
+		"BigQuery": {
+			p: v1alpha1.SubscriptionParameters{Topic: topic, BigQueryConfig: &v1alpha1.BigQueryConfig{Table: table}},
+		},
+		"BigQueryAndCloudStorage": {
+			p: v1alpha1.SubscriptionParameters{
+				Topic:              topic,
+				BigQueryConfig:     &v1alpha1.BigQueryConfig{Table: table},
+				CloudStorageConfig: &v1alpha1.CloudStorageConfig{Bucket: bucket},
+			},
+			want: errors.New("only one of pushEndpoint, bigQueryConfig, or cloudStorageConfig may be set"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := validateDelivery(tc.p)
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("validateDelivery(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUpdateMask(t *testing.T) {
+	bq := func(table string) *pubsubv1.BigQueryConfig { return &pubsubv1.BigQueryConfig{Table: table} }
+
+	cases := map[string]struct {
+		desired *pubsubv1.Subscription
+		actual  *pubsubv1.Subscription
+		want    []string
+	}{
+		"UpToDate": {
+			desired: &pubsubv1.Subscription{BigqueryConfig: bq(table)},
+			actual: &pubsubv1.Subscription{
+				AckDeadlineSeconds: 10,
+				BigqueryConfig:     &pubsubv1.BigQueryConfig{Table: table, State: deliveryStateActive},
+				PushConfig:         &pubsubv1.PushConfig{},
+			},
+			want: []string{},
+		},
+		"TableChanged": {
+			desired: &pubsubv1.Subscription{BigqueryConfig: bq(table)},
+			actual:  &pubsubv1.Subscription{BigqueryConfig: bq("other-project.dataset.table")},
+			want:    []string{"bigqueryConfig"},
+		},
+		"BigQueryToCloudStorage": {
+			desired: &pubsubv1.Subscription{CloudStorageConfig: &pubsubv1.CloudStorageConfig{Bucket: bucket}},
+			actual:  &pubsubv1.Subscription{BigqueryConfig: bq(table)},
+			want:    []string{"bigqueryConfig", "cloudStorageConfig"},
+		},
+		"LabelsAndAckDeadline": {
+			desired: &pubsubv1.Subscription{AckDeadlineSeconds: 30, Labels: map[string]string{"cool": "true"}},
+			actual:  &pubsubv1.Subscription{AckDeadlineSeconds: 10},
+			want:    []string{"ackDeadlineSeconds", "labels"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := updateMask(tc.desired, tc.actual)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("updateMask(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pubsub
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	pubsubv1 "google.golang.org/api/pubsub/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/pubsub/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/pubsub"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	controllerName   = "subscriptions.pubsub.gcp.crossplane.io"
+	finalizerName    = "finalizer." + controllerName
+	reconcileTimeout = 1 * time.Minute
+
+	// subscriptionNamePrefix is prepended to the UID of a Subscription to
+	// name its Pub/Sub subscription.
+	subscriptionNamePrefix = "sub-"
+)
+
+var log = logging.Logger.WithName("controller." + controllerName)
+
+// A createsyncdeleter can create, sync, and delete Pub/Sub subscriptions in an
+// external store - e.g. the GCP API. Each method returns true if the
+// subscription requires further reconciliation.
+type createsyncdeleter interface {
+	Create(ctx context.Context, s *v1alpha1.Subscription) (requeue bool)
+	Sync(ctx context.Context, s *v1alpha1.Subscription) (requeue bool)
+	Delete(ctx context.Context, s *v1alpha1.Subscription) (requeue bool)
+}
+
+// subscriptions is a createsyncdeleter using the GCP Pub/Sub API.
+type subscriptions struct {
+	client  pubsub.Client
+	project string
+}
+
+// Create creates a pull, push, BigQuery, or Cloud Storage subscription.
+func (c *subscriptions) Create(ctx context.Context, s *v1alpha1.Subscription) bool {
+	s.Status.SetConditions(corev1alpha1.Creating())
+
+	if err := validateDelivery(s.Spec.SubscriptionParameters); err != nil {
+		// Don't requeue invalid specs; they'll be reconciled again when updated.
+		s.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return false
+	}
+
+	name := subscriptionNamePrefix + string(s.GetUID())
+	if _, err := c.client.CreateSubscription(ctx, newSubscription(c.project, name, s.Spec.SubscriptionParameters)); err != nil {
+		s.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create subscription")))
+		return true
+	}
+
+	s.Status.SubscriptionName = name
+	meta.AddFinalizer(s, finalizerName)
+	s.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync updates the subscription if it differs from its spec, and reports
+// whether it is able to deliver messages to BigQuery or Cloud Storage.
+func (c *subscriptions) Sync(ctx context.Context, s *v1alpha1.Subscription) bool {
+	name := subscriptionName(c.project, s.Status.SubscriptionName)
+	actual, err := c.client.GetSubscription(ctx, name)
+	if err != nil {
+		s.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+	s.Status.DeliveryState = deliveryState(actual)
+
+	if err := validateDelivery(s.Spec.SubscriptionParameters); err != nil {
+		s.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return false
+	}
+
+	desired := newSubscription(c.project, s.Status.SubscriptionName, s.Spec.SubscriptionParameters)
+	if mask := updateMask(desired, actual); len(mask) > 0 {
+		req := &pubsubv1.UpdateSubscriptionRequest{Subscription: desired, UpdateMask: strings.Join(mask, ",")}
+		if err := c.client.UpdateSubscription(ctx, name, req); err != nil {
+			s.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot update subscription")))
+			return true
+		}
+		s.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	// BigQuery and Cloud Storage subscriptions stop delivering messages if,
+	// for example, Pub/Sub loses permission to write to their destination.
+	if st := s.Status.DeliveryState; st != "" && st != deliveryStateActive {
+		s.Status.SetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(errors.Errorf("subscription cannot deliver messages: delivery state is %s", st)))
+		return true
+	}
+
+	s.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete deletes the subscription. Messages retained by the subscription are
+// lost.
+func (c *subscriptions) Delete(ctx context.Context, s *v1alpha1.Subscription) bool {
+	s.Status.SetConditions(corev1alpha1.Deleting())
+
+	if s.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		err := c.client.DeleteSubscription(ctx, subscriptionName(c.project, s.Status.SubscriptionName))
+		if err != nil && !googleapi.IsErrorNotFound(err) {
+			s.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete subscription")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(s, finalizerName)
+	s.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// A connecter returns a createsyncdeleter that can create, sync, and delete
+// Pub/Sub subscriptions with an external store - for example the GCP API.
+type connecter interface {
+	Connect(context.Context, *v1alpha1.Subscription) (createsyncdeleter, error)
+}
+
+// providerConnecter is a connecter that returns a createsyncdeleter
+// authenticated using credentials read from a Crossplane Provider resource.
+type providerConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (pubsub.Client, error)
+}
+
+// Connect returns a createsyncdeleter backed by the GCP API. GCP credentials
+// are read from the Crossplane Provider referenced by the supplied
+// Subscription.
+func (c *providerConnecter) Connect(ctx context.Context, s *v1alpha1.Subscription) (createsyncdeleter, error) {
+	p, err := c.providers.Get(ctx, c.kube, s, s.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServicePubSub, pubsubv1.PubsubScope)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return &subscriptions{client: client, project: p.Spec.ProjectID}, errors.Wrap(err, "cannot create new Pub/Sub client")
+}
+
+// Reconciler reconciles Subscriptions read from the Kubernetes API with an
+// external store, typically the GCP API.
+type Reconciler struct {
+	connecter
+	kube client.Client
+}
+
+// SubscriptionController is responsible for adding the Pub/Sub Subscription
+// controller and its corresponding reconciler to the manager with any runtime
+// configuration.
+type SubscriptionController struct {
+	// DefaultProvider is used by subscriptions that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new Subscription Controller and adds it to the
+// Manager with default RBAC. The Manager will set fields on the Controller and
+// start it when the Manager is Started.
+func (c *SubscriptionController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &Reconciler{
+		connecter: &providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: pubsub.NewClient,
+		},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerName).
+		For(&v1alpha1.Subscription{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listSubscriptions)).
+		Complete(r)
+}
+
+// Reconcile Google Pub/Sub subscriptions with the GCP API.
+func (r *Reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	log.V(logging.Debug).Info("reconciling", "kind", v1alpha1.SubscriptionKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	s := &v1alpha1.Subscription{}
+	if err := r.kube.Get(ctx, req.NamespacedName, s); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get subscription %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, s)
+	if err != nil {
+		s.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, s), "cannot update subscription %s", req.NamespacedName)
+	}
+
+	// The subscription has been deleted from the API server. Delete from GCP.
+	if s.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, s)}, errors.Wrapf(r.kube.Update(ctx, s), "cannot update subscription %s", req.NamespacedName)
+	}
+
+	// The subscription is unnamed. Assume it has not been created in GCP.
+	if s.Status.SubscriptionName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, s)}, errors.Wrapf(r.kube.Update(ctx, s), "cannot update subscription %s", req.NamespacedName)
+	}
+
+	// The subscription exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, s)}, errors.Wrapf(r.kube.Update(ctx, s), "cannot update subscription %s", req.NamespacedName)
+}
+
+// listSubscriptions is a provider.Lister of Pub/Sub subscriptions.
+func listSubscriptions(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.SubscriptionList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,347 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pubsub
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+	pubsubv1 "google.golang.org/api/pubsub/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/pubsub/v1alpha1"
+	fakepubsub "github.com/crossplaneio/crossplane/pkg/clients/gcp/pubsub/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace    = "cool-namespace"
+	name         = "cool-subscription"
+	uid          = types.UID("definitely-a-uuid")
+	subName      = subscriptionNamePrefix + string(uid)
+	project      = "coolProject"
+	providerName = "cool-gcp"
+	topic        = "cool-topic"
+	table        = "coolProject.cool_dataset.events"
+	bucket       = "cool-archive"
+)
+
+var (
+	ctx           = context.Background()
+	errorBoom     = errors.New("boom")
+	errorNotFound = &googleapi.Error{Code: http.StatusNotFound}
+)
+
+// Test that our Reconciler implementation satisfies the Reconciler interface.
+var _ reconcile.Reconciler = &Reconciler{}
+
+type subscriptionModifier func(*v1alpha1.Subscription)
+
+func withConditions(c ...corev1alpha1.Condition) subscriptionModifier {
+	return func(s *v1alpha1.Subscription) { s.Status.SetConditions(c...) }
+}
+
+func withFinalizers(f ...string) subscriptionModifier {
+	return func(s *v1alpha1.Subscription) { s.ObjectMeta.Finalizers = f }
+}
+
+func withReclaimPolicy(p corev1alpha1.ReclaimPolicy) subscriptionModifier {
+	return func(s *v1alpha1.Subscription) { s.Spec.ReclaimPolicy = p }
+}
+
+func withSubscriptionName(n string) subscriptionModifier {
+	return func(s *v1alpha1.Subscription) { s.Status.SubscriptionName = n }
+}
+
+func withDeliveryState(st string) subscriptionModifier {
+	return func(s *v1alpha1.Subscription) { s.Status.DeliveryState = st }
+}
+
+func withCloudStorageConfig(c *v1alpha1.CloudStorageConfig) subscriptionModifier {
+	return func(s *v1alpha1.Subscription) { s.Spec.CloudStorageConfig = c }
+}
+
+func subscription(sm ...subscriptionModifier) *v1alpha1.Subscription {
+	s := &v1alpha1.Subscription{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.SubscriptionSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			SubscriptionParameters: v1alpha1.SubscriptionParameters{
+				Topic:          topic,
+				BigQueryConfig: &v1alpha1.BigQueryConfig{Table: table, WriteMetadata: true},
+			},
+		},
+	}
+
+	for _, m := range sm {
+		m(s)
+	}
+
+	return s
+}
+
+func TestCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		s           *v1alpha1.Subscription
+		want        *v1alpha1.Subscription
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &subscriptions{project: project, client: &fakepubsub.MockClient{
+				MockCreateSubscription: func(_ context.Context, s *pubsubv1.Subscription) (*pubsubv1.Subscription, error) {
+					if s.Name != subscriptionName(project, subName) {
+						t.Errorf("s.Name: want %s, got %s", subscriptionName(project, subName), s.Name)
+					}
+					return s, nil
+				},
+			}},
+			s: subscription(),
+			want: subscription(
+				withFinalizers(finalizerName),
+				withSubscriptionName(subName),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "InvalidDelivery",
+			csd:  &subscriptions{project: project, client: &fakepubsub.MockClient{}},
+			s:    subscription(withCloudStorageConfig(&v1alpha1.CloudStorageConfig{Bucket: bucket})),
+			want: subscription(
+				withCloudStorageConfig(&v1alpha1.CloudStorageConfig{Bucket: bucket}),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.New("only one of pushEndpoint, bigQueryConfig, or cloudStorageConfig may be set"))),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedCreate",
+			csd: &subscriptions{project: project, client: &fakepubsub.MockClient{
+				MockCreateSubscription: func(_ context.Context, _ *pubsubv1.Subscription) (*pubsubv1.Subscription, error) {
+					return nil, errorBoom
+				},
+			}},
+			s: subscription(),
+			want: subscription(
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create subscription"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.s)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.s, test.EquateConditions()); diff != "" {
+				t.Errorf("s: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	getSubscription := func(state string, table string) func(context.Context, string) (*pubsubv1.Subscription, error) {
+		return func(_ context.Context, _ string) (*pubsubv1.Subscription, error) {
+			return &pubsubv1.Subscription{
+				Name:           subscriptionName(project, subName),
+				Topic:          topicName(project, topic),
+				BigqueryConfig: &pubsubv1.BigQueryConfig{Table: table, WriteMetadata: true, State: state},
+			}, nil
+		}
+	}
+
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		s           *v1alpha1.Subscription
+		want        *v1alpha1.Subscription
+		wantRequeue bool
+	}{
+		{
+			name: "Active",
+			csd: &subscriptions{project: project, client: &fakepubsub.MockClient{
+				MockGetSubscription: getSubscription(deliveryStateActive, table),
+			}},
+			s: subscription(withSubscriptionName(subName)),
+			want: subscription(
+				withSubscriptionName(subName),
+				withDeliveryState(deliveryStateActive),
+				withConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "PermissionDenied",
+			csd: &subscriptions{project: project, client: &fakepubsub.MockClient{
+				MockGetSubscription: getSubscription("PERMISSION_DENIED", table),
+			}},
+			s: subscription(withSubscriptionName(subName)),
+			want: subscription(
+				withSubscriptionName(subName),
+				withDeliveryState("PERMISSION_DENIED"),
+				withConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileError(errors.New("subscription cannot deliver messages: delivery state is PERMISSION_DENIED"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "NeedsUpdate",
+			csd: &subscriptions{project: project, client: &fakepubsub.MockClient{
+				MockGetSubscription: getSubscription(deliveryStateActive, "coolProject.cool_dataset.old"),
+				MockUpdateSubscription: func(_ context.Context, _ string, req *pubsubv1.UpdateSubscriptionRequest) error {
+					if req.UpdateMask != "bigqueryConfig" {
+						t.Errorf("req.UpdateMask: want bigqueryConfig, got %s", req.UpdateMask)
+					}
+					return nil
+				},
+			}},
+			s: subscription(withSubscriptionName(subName)),
+			want: subscription(
+				withSubscriptionName(subName),
+				withDeliveryState(deliveryStateActive),
+				withConditions(corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedUpdate",
+			csd: &subscriptions{project: project, client: &fakepubsub.MockClient{
+				MockGetSubscription: getSubscription(deliveryStateActive, "coolProject.cool_dataset.old"),
+				MockUpdateSubscription: func(_ context.Context, _ string, _ *pubsubv1.UpdateSubscriptionRequest) error {
+					return errorBoom
+				},
+			}},
+			s: subscription(withSubscriptionName(subName)),
+			want: subscription(
+				withSubscriptionName(subName),
+				withDeliveryState(deliveryStateActive),
+				withConditions(corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot update subscription"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGet",
+			csd: &subscriptions{project: project, client: &fakepubsub.MockClient{
+				MockGetSubscription: func(_ context.Context, _ string) (*pubsubv1.Subscription, error) { return nil, errorBoom },
+			}},
+			s: subscription(withSubscriptionName(subName)),
+			want: subscription(
+				withSubscriptionName(subName),
+				withConditions(corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.s)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.s, test.EquateConditions()); diff != "" {
+				t.Errorf("s: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		s           *v1alpha1.Subscription
+		want        *v1alpha1.Subscription
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimRetain",
+			csd:  &subscriptions{project: project, client: &fakepubsub.MockClient{}},
+			s:    subscription(withSubscriptionName(subName), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimRetain)),
+			want: subscription(
+				withSubscriptionName(subName),
+				withReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteAlreadyGone",
+			csd: &subscriptions{project: project, client: &fakepubsub.MockClient{
+				MockDeleteSubscription: func(_ context.Context, _ string) error { return errorNotFound },
+			}},
+			s: subscription(withSubscriptionName(subName), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: subscription(
+				withSubscriptionName(subName),
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailed",
+			csd: &subscriptions{project: project, client: &fakepubsub.MockClient{
+				MockDeleteSubscription: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			s: subscription(withSubscriptionName(subName), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: subscription(
+				withSubscriptionName(subName),
+				withFinalizers(finalizerName),
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot delete subscription"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.s)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.s, test.EquateConditions()); diff != "" {
+				t.Errorf("s: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package refindex indexes managed resources by the resources they reference,
+// so that a managed resource waiting on a dependency is reconciled as soon as
+// that dependency becomes ready, rather than at its next periodic sync.
+package refindex
+
+import (
+	"context"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/shard"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/topology"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+)
+
+// The fields by which managed resources are indexed.
+const (
+	// FieldReferences indexes managed resources by the Kubernetes and GCP
+	// resources they reference, for example a servicenetworking Connection,
+	// a Bucket, a VPC network, or a KMS key.
+	FieldReferences = "refindex.gcp.crossplane.io/references"
+
+	// FieldProvider indexes managed resources by the Provider they reference.
+	FieldProvider = "refindex.gcp.crossplane.io/provider"
+)
+
+// defaultProvider is the FieldProvider value of managed resources that do not
+// reference a Provider, and therefore use the default Provider.
+const defaultProvider = "<default>"
+
+// mapTimeout bounds the time spent finding the dependents of a resource.
+const mapTimeout = 30 * time.Second
+
+// A providerReferencer references a Provider. Each GCP managed resource does.
+type providerReferencer interface {
+	GetProviderReference() *corev1.ObjectReference
+}
+
+// Setup indexes managed resources of the supplied kinds by the Provider and
+// other resources they reference. It must be called before the manager is
+// started, and at most once per manager. Only the controllers of indexed
+// kinds may watch Providers and their dependencies using this package; other
+// kinds notice a ready dependency at their next sync.
+func Setup(mgr ctrl.Manager, kinds []topology.Kind) error {
+	for _, k := range kinds {
+		obj, err := mgr.GetScheme().New(k.GroupVersionKind)
+		if err != nil {
+			return errors.Wrapf(err, "cannot index %s", k.GroupVersionKind.Kind)
+		}
+		if err := mgr.GetFieldIndexer().IndexField(obj, FieldProvider, providerKeys); err != nil {
+			return errors.Wrapf(err, "cannot index %s by provider", k.GroupVersionKind.Kind)
+		}
+		if k.References == nil {
+			continue
+		}
+		if err := mgr.GetFieldIndexer().IndexField(obj, FieldReferences, referenceKeys(k.References)); err != nil {
+			return errors.Wrapf(err, "cannot index %s by reference", k.GroupVersionKind.Kind)
+		}
+	}
+	return nil
+}
+
+// Key returns the key under which references to the supplied node are
+// indexed. VPC networks may be referenced by name or by URL, so they are
+// keyed by name.
+func Key(n topology.Node) string {
+	kind := n.Kind
+	if n.Group != "" {
+		kind = n.Kind + "." + n.Group
+	}
+	name := n.Name
+	if n.External {
+		name = path.Base(n.Name)
+	}
+	return strings.Join([]string{kind, n.Namespace, name}, "/")
+}
+
+// ProviderKey returns the key under which references to the supplied Provider
+// are indexed.
+func ProviderKey(p types.NamespacedName) string {
+	return p.String()
+}
+
+func providerKeys(obj runtime.Object) []string {
+	pr, ok := obj.(providerReferencer)
+	if !ok {
+		return nil
+	}
+	ref := pr.GetProviderReference()
+	if ref == nil {
+		return []string{defaultProvider}
+	}
+	n := types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}
+	if n.Namespace == "" {
+		if m, err := meta.Accessor(obj); err == nil {
+			n.Namespace = m.GetNamespace()
+		}
+	}
+	return []string{ProviderKey(n)}
+}
+
+func referenceKeys(references func(mg resource.Managed) []topology.Node) client.IndexerFunc {
+	return func(obj runtime.Object) []string {
+		mg, ok := obj.(resource.Managed)
+		if !ok {
+			return nil
+		}
+		nodes := references(mg)
+		keys := make([]string, 0, len(nodes))
+		for _, n := range nodes {
+			keys = append(keys, Key(n))
+		}
+		return keys
+	}
+}
+
+// A Lister returns an empty list of the kind of managed resource that depends
+// on a particular kind of resource.
+type Lister func() runtime.Object
+
+// EnqueueRequestsForDependents returns an event handler that, when a managed
+// resource of the supplied kind becomes ready, enqueues a reconcile request for
+// each managed resource listed by the supplied Lister that references it.
+// Managed resources that reference a VPC network, such as a servicenetworking
+// Connection, make that network usable by others, so the dependents of the
+// network are enqueued too. KMS keys and networks are not Kubernetes resources
+// and cannot be watched; their dependents are found only via the resources that
+// reference them.
+func EnqueueRequestsForDependents(kube client.Reader, gvk schema.GroupVersionKind, list Lister) handler.EventHandler {
+	return &enqueueRequestsForDependents{kube: kube, kind: kindOf(gvk), list: list}
+}
+
+// kindOf returns the default topology kind with the supplied GroupVersionKind,
+// which determines the resources a managed resource of that kind references.
+func kindOf(gvk schema.GroupVersionKind) topology.Kind {
+	for _, k := range topology.DefaultKinds {
+		if k.GroupVersionKind == gvk {
+			return k
+		}
+	}
+	return topology.Kind{GroupVersionKind: gvk}
+}
+
+type enqueueRequestsForDependents struct {
+	kube client.Reader
+	kind topology.Kind
+	list Lister
+}
+
+// Create enqueues the dependents of a resource that is ready when created,
+// typically because the manager's caches were just started.
+func (e *enqueueRequestsForDependents) Create(ev event.CreateEvent, q workqueue.RateLimitingInterface) {
+	if isReady(ev.Object) {
+		e.enqueue(ev.Object, q)
+	}
+}
+
+// Update enqueues the dependents of a resource that has just become ready.
+func (e *enqueueRequestsForDependents) Update(ev event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	if !isReady(ev.ObjectOld) && isReady(ev.ObjectNew) {
+		e.enqueue(ev.ObjectNew, q)
+	}
+}
+
+// Delete does nothing. Dependents notice a deleted dependency at their next
+// reconcile.
+func (e *enqueueRequestsForDependents) Delete(_ event.DeleteEvent, _ workqueue.RateLimitingInterface) {
+}
+
+// Generic does nothing.
+func (e *enqueueRequestsForDependents) Generic(_ event.GenericEvent, _ workqueue.RateLimitingInterface) {
+}
+
+func (e *enqueueRequestsForDependents) enqueue(obj runtime.Object, q workqueue.RateLimitingInterface) {
+	ctx, cancel := context.WithTimeout(context.Background(), mapTimeout)
+	defer cancel()
+	for _, r := range requestsFor(ctx, e.kube, e.list, FieldReferences, dependencyKeys(e.kind, obj)) {
+		q.Add(r)
+	}
+}
+
+// dependencyKeys returns the keys under which the dependents of the supplied
+// managed resource are indexed.
+func dependencyKeys(k topology.Kind, obj runtime.Object) []string {
+	mg, ok := obj.(resource.Managed)
+	if !ok {
+		return nil
+	}
+	keys := []string{Key(topology.Node{
+		Group:     k.GroupVersionKind.Group,
+		Kind:      k.GroupVersionKind.Kind,
+		Namespace: mg.GetNamespace(),
+		Name:      mg.GetName(),
+	})}
+	if k.References == nil {
+		return keys
+	}
+	for _, n := range k.References(mg) {
+		if n.External {
+			keys = append(keys, Key(n))
+		}
+	}
+	return keys
+}
+
+func isReady(obj runtime.Object) bool {
+	mg, ok := obj.(resource.Managed)
+	return ok && mg.GetCondition(corev1alpha1.TypeReady).Status == corev1.ConditionTrue
+}
+
+// EnqueueRequestsForProvider returns an event handler that, when a Provider is
+// created or updated, enqueues a reconcile request for each managed resource
+// listed by the supplied Lister that uses it. Managed resources that do not
+// reference a Provider are enqueued when the supplied default Provider changes.
+func EnqueueRequestsForProvider(kube client.Reader, defaultProvider types.NamespacedName, list Lister) handler.EventHandler {
+	return &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+			if _, ok := o.Object.(*gcpv1alpha1.Provider); !ok {
+				return nil
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), mapTimeout)
+			defer cancel()
+			n := types.NamespacedName{Namespace: o.Meta.GetNamespace(), Name: o.Meta.GetName()}
+			return requestsFor(ctx, kube, list, FieldProvider, providerDependencyKeys(n, defaultProvider))
+		}),
+	}
+}
+
+func providerDependencyKeys(p, def types.NamespacedName) []string {
+	keys := []string{ProviderKey(p)}
+	if p == def {
+		keys = append(keys, defaultProvider)
+	}
+	return keys
+}
+
+// requestsFor returns a reconcile request for each listed managed resource
+// indexed under any of the supplied keys. Mapping is best effort; resources
+// that cannot be listed are reconciled at their next sync as usual.
+func requestsFor(ctx context.Context, kube client.Reader, list Lister, field string, keys []string) []reconcile.Request {
+	seen := map[types.NamespacedName]bool{}
+	reqs := []reconcile.Request{}
+	for _, key := range keys {
+		l := list()
+		if err := kube.List(ctx, l, client.MatchingField(field, key)); err != nil {
+			continue
+		}
+		items, err := meta.ExtractList(l)
+		if err != nil {
+			continue
+		}
+		for _, item := range items {
+			m, err := meta.Accessor(item)
+			if err != nil {
+				continue
+			}
+			n := types.NamespacedName{Namespace: m.GetNamespace(), Name: m.GetName()}
+			if seen[n] || !shard.Owns(kube, m) {
+				continue
+			}
+			seen[n] = true
+			reqs = append(reqs, reconcile.Request{NamespacedName: n})
+		}
+	}
+	return reqs
+}
@@ -0,0 +1,341 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package refindex
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	cachev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/cache/v1alpha1"
+	databasev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	servicenetworkingv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/servicenetworking/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/topology"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace = "cool-namespace"
+	network   = "cool-network"
+)
+
+var (
+	connectionGVK = servicenetworkingv1alpha1.ConnectionGroupVersionKind
+	connectionKey = "Connection." + connectionGVK.Group + "/" + namespace + "/cool-connection"
+	networkKey    = "Network//" + network
+)
+
+type instanceModifier func(*databasev1alpha1.CloudsqlInstance)
+
+func withProviderReference(ref *corev1.ObjectReference) instanceModifier {
+	return func(i *databasev1alpha1.CloudsqlInstance) { i.Spec.ProviderReference = ref }
+}
+
+func withConnectionReference(ref *corev1.ObjectReference) instanceModifier {
+	return func(i *databasev1alpha1.CloudsqlInstance) { i.Spec.ConnectionReference = ref }
+}
+
+func withPrivateNetwork(n string) instanceModifier {
+	return func(i *databasev1alpha1.CloudsqlInstance) { i.Spec.PrivateNetwork = n }
+}
+
+func instance(name string, im ...instanceModifier) *databasev1alpha1.CloudsqlInstance {
+	i := &databasev1alpha1.CloudsqlInstance{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	for _, m := range im {
+		m(i)
+	}
+	return i
+}
+
+func connection(ready bool) *servicenetworkingv1alpha1.Connection {
+	c := &servicenetworkingv1alpha1.Connection{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "cool-connection"}}
+	c.Spec.Network = "projects/cool-project/global/networks/" + network
+	if ready {
+		c.Status.SetConditions(corev1alpha1.Available())
+	}
+	return c
+}
+
+func TestKey(t *testing.T) {
+	cases := map[string]struct {
+		n    topology.Node
+		want string
+	}{
+		"Managed": {
+			n:    topology.Node{Group: connectionGVK.Group, Kind: connectionGVK.Kind, Namespace: namespace, Name: "cool-connection", Managed: true},
+			want: connectionKey,
+		},
+		"ExternalByName": {
+			n:    topology.Node{Kind: "Network", Name: network, External: true},
+			want: networkKey,
+		},
+		"ExternalByURL": {
+			n:    topology.Node{Kind: "Network", Name: "https://www.googleapis.com/compute/v1/projects/cool-project/global/networks/" + network, External: true},
+			want: networkKey,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, Key(tc.n)); diff != "" {
+				t.Errorf("Key(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestProviderKeys(t *testing.T) {
+	cases := map[string]struct {
+		obj  runtime.Object
+		want []string
+	}{
+		"NotAProviderReferencer": {
+			obj:  &corev1.Secret{},
+			want: nil,
+		},
+		"DefaultProvider": {
+			obj:  instance("cool-instance"),
+			want: []string{defaultProvider},
+		},
+		"SameNamespace": {
+			obj:  instance("cool-instance", withProviderReference(&corev1.ObjectReference{Name: "cool-provider"})),
+			want: []string{namespace + "/cool-provider"},
+		},
+		"OtherNamespace": {
+			obj:  instance("cool-instance", withProviderReference(&corev1.ObjectReference{Namespace: "other", Name: "cool-provider"})),
+			want: []string{"other/cool-provider"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, providerKeys(tc.obj)); diff != "" {
+				t.Errorf("providerKeys(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReferenceKeys(t *testing.T) {
+	kind := kindOf(databasev1alpha1.CloudsqlInstanceGroupVersionKind)
+
+	cases := map[string]struct {
+		obj  runtime.Object
+		want []string
+	}{
+		"NotManaged": {
+			obj:  &corev1.Secret{},
+			want: nil,
+		},
+		"NoReferences": {
+			obj:  instance("cool-instance"),
+			want: []string{},
+		},
+		"References": {
+			obj: instance("cool-instance",
+				withConnectionReference(&corev1.ObjectReference{Namespace: namespace, Name: "cool-connection"}),
+				withPrivateNetwork(network),
+			),
+			want: []string{connectionKey, networkKey},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, referenceKeys(kind.References)(tc.obj)); diff != "" {
+				t.Errorf("referenceKeys(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestWiredKindReferences ensures each kind whose controller watches
+// Connections via EnqueueRequestsForDependents is indexed by the Connection
+// it references.
+func TestWiredKindReferences(t *testing.T) {
+	ref := &corev1.ObjectReference{Namespace: namespace, Name: "cool-connection"}
+	om := metav1.ObjectMeta{Namespace: namespace, Name: "cool-instance"}
+
+	cases := map[string]struct {
+		gvk  schema.GroupVersionKind
+		obj  runtime.Object
+		want []string
+	}{
+		"CloudsqlInstance": {
+			gvk:  databasev1alpha1.CloudsqlInstanceGroupVersionKind,
+			obj:  instance("cool-instance", withConnectionReference(ref)),
+			want: []string{connectionKey},
+		},
+		"CloudMemorystoreInstance": {
+			gvk: cachev1alpha1.CloudMemorystoreInstanceGroupVersionKind,
+			obj: &cachev1alpha1.CloudMemorystoreInstance{
+				ObjectMeta: om,
+				Spec:       cachev1alpha1.CloudMemorystoreInstanceSpec{ConnectionReference: ref},
+			},
+			want: []string{connectionKey},
+		},
+		"MemcachedInstance": {
+			gvk: cachev1alpha1.MemcachedInstanceGroupVersionKind,
+			obj: &cachev1alpha1.MemcachedInstance{
+				ObjectMeta: om,
+				Spec:       cachev1alpha1.MemcachedInstanceSpec{ConnectionReference: ref},
+			},
+			want: []string{connectionKey},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			kind := kindOf(tc.gvk)
+			if kind.References == nil {
+				t.Fatalf("kindOf(%s): want a default kind with references", tc.gvk.Kind)
+			}
+			if diff := cmp.Diff(tc.want, referenceKeys(kind.References)(tc.obj)); diff != "" {
+				t.Errorf("referenceKeys(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDependencyKeys(t *testing.T) {
+	cases := map[string]struct {
+		kind topology.Kind
+		obj  runtime.Object
+		want []string
+	}{
+		"NotManaged": {
+			kind: kindOf(connectionGVK),
+			obj:  &corev1.Secret{},
+			want: nil,
+		},
+		"ConnectionAndNetwork": {
+			kind: kindOf(connectionGVK),
+			obj:  connection(true),
+			want: []string{connectionKey, networkKey},
+		},
+		"UnknownKind": {
+			kind: kindOf(connectionGVK.GroupVersion().WithKind("Unknown")),
+			obj:  connection(true),
+			want: []string{"Unknown." + connectionGVK.Group + "/" + namespace + "/cool-connection"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, dependencyKeys(tc.kind, tc.obj)); diff != "" {
+				t.Errorf("dependencyKeys(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestProviderDependencyKeys(t *testing.T) {
+	p := types.NamespacedName{Namespace: namespace, Name: "cool-provider"}
+
+	cases := map[string]struct {
+		def  types.NamespacedName
+		want []string
+	}{
+		"NotDefault": {
+			def:  types.NamespacedName{Namespace: "crossplane-system", Name: "default"},
+			want: []string{namespace + "/cool-provider"},
+		},
+		"Default": {
+			def:  p,
+			want: []string{namespace + "/cool-provider", defaultProvider},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, providerDependencyKeys(p, tc.def)); diff != "" {
+				t.Errorf("providerDependencyKeys(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestIsReady(t *testing.T) {
+	cases := map[string]struct {
+		obj  runtime.Object
+		want bool
+	}{
+		"NotManaged": {obj: &corev1.Secret{}, want: false},
+		"NotReady":   {obj: connection(false), want: false},
+		"Ready":      {obj: connection(true), want: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := isReady(tc.obj); got != tc.want {
+				t.Errorf("isReady(...): want %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRequestsFor(t *testing.T) {
+	errBoom := errors.New("boom")
+	list := func() runtime.Object { return &databasev1alpha1.CloudsqlInstanceList{} }
+
+	cases := map[string]struct {
+		kube client.Reader
+		keys []string
+		want []reconcile.Request
+	}{
+		"ListError": {
+			kube: &test.MockClient{
+				MockList: func(_ context.Context, _ runtime.Object, _ ...client.ListOption) error { return errBoom },
+			},
+			keys: []string{connectionKey},
+			want: []reconcile.Request{},
+		},
+		"DeduplicatesAcrossKeys": {
+			kube: &test.MockClient{
+				MockList: func(_ context.Context, obj runtime.Object, _ ...client.ListOption) error {
+					l := obj.(*databasev1alpha1.CloudsqlInstanceList)
+					l.Items = []databasev1alpha1.CloudsqlInstance{*instance("a"), *instance("b")}
+					return nil
+				},
+			},
+			keys: []string{connectionKey, networkKey},
+			want: []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Namespace: namespace, Name: "a"}},
+				{NamespacedName: types.NamespacedName{Namespace: namespace, Name: "b"}},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := requestsFor(context.Background(), tc.kube, list, FieldReferences, tc.keys)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("requestsFor(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resourcemanager
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+	crm "google.golang.org/api/cloudresourcemanager/v3"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/resourcemanager/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/resourcemanager"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	auditConfigControllerName = "projectauditconfigs.resourcemanager.gcp.crossplane.io"
+	auditConfigFinalizer      = "finalizer." + auditConfigControllerName
+
+	// auditConfigsMask limits IAM policy updates to audit configs, so that
+	// they can't clobber role bindings.
+	auditConfigsMask = "auditConfigs"
+)
+
+var auditConfigLog = logging.Logger.WithName("controller." + auditConfigControllerName)
+
+// An auditConfigCreateSyncDeleter can create, sync, and delete project audit
+// configs in an external store - e.g. the GCP API. Each method returns true
+// if the audit config requires further reconciliation.
+type auditConfigCreateSyncDeleter interface {
+	Create(ctx context.Context, a *v1alpha1.ProjectAuditConfig) (requeue bool)
+	Sync(ctx context.Context, a *v1alpha1.ProjectAuditConfig) (requeue bool)
+	Delete(ctx context.Context, a *v1alpha1.ProjectAuditConfig) (requeue bool)
+}
+
+// auditConfigs is an auditConfigCreateSyncDeleter using the GCP Resource
+// Manager API. A project's audit configs are part of its IAM policy.
+type auditConfigs struct {
+	client resourcemanager.Client
+	kube   client.Client
+}
+
+// Create resolves the target project, then syncs the audit config.
+func (c *auditConfigs) Create(ctx context.Context, a *v1alpha1.ProjectAuditConfig) bool {
+	a.Status.SetConditions(corev1alpha1.Creating())
+
+	id, err := resolveProject(ctx, c.kube, a, a.Spec.ProjectTarget)
+	if err != nil {
+		a.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	a.Status.ProjectID = id
+	meta.AddFinalizer(a, auditConfigFinalizer)
+	return c.Sync(ctx, a)
+}
+
+// Sync sets the audit config of the target project's IAM policy for the
+// desired service if it differs from the desired audit config. The policy's
+// etag is sent with the update, so concurrent policy changes cause the update
+// to fail rather than be lost.
+func (c *auditConfigs) Sync(ctx context.Context, a *v1alpha1.ProjectAuditConfig) bool {
+	resource := projectName(a.Status.ProjectID)
+	p, err := c.client.GetIamPolicy(ctx, resource)
+	if err != nil {
+		a.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot get IAM policy of %s", resource)))
+		return true
+	}
+
+	if setAuditConfig(p, newAuditConfig(a.Spec.Service, a.Spec.AuditLogConfigs)) {
+		if _, err := c.client.SetIamPolicy(ctx, resource, p, auditConfigsMask); err != nil {
+			a.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot set IAM policy of %s", resource)))
+			return true
+		}
+	}
+
+	a.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete removes the audit config for the desired service from the target
+// project's IAM policy.
+func (c *auditConfigs) Delete(ctx context.Context, a *v1alpha1.ProjectAuditConfig) bool {
+	a.Status.SetConditions(corev1alpha1.Deleting())
+
+	if a.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete && a.Status.ProjectID != "" {
+		resource := projectName(a.Status.ProjectID)
+		p, err := c.client.GetIamPolicy(ctx, resource)
+		if err != nil && !googleapi.IsErrorNotFound(err) {
+			a.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot get IAM policy of %s", resource)))
+			return true
+		}
+		if err == nil && removeAuditConfig(p, a.Spec.Service) {
+			if _, err := c.client.SetIamPolicy(ctx, resource, p, auditConfigsMask); err != nil && !googleapi.IsErrorNotFound(err) {
+				a.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot set IAM policy of %s", resource)))
+				return true
+			}
+		}
+	}
+
+	meta.RemoveFinalizer(a, auditConfigFinalizer)
+	a.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// newAuditConfig returns an audit config for the supplied service. Log types
+// and exempted members are sorted so that audit configs may be compared.
+func newAuditConfig(service string, lc []v1alpha1.AuditLogConfig) *crm.AuditConfig {
+	ac := &crm.AuditConfig{Service: service, AuditLogConfigs: make([]*crm.AuditLogConfig, 0, len(lc))}
+	for _, c := range lc {
+		members := append([]string{}, c.ExemptedMembers...)
+		sort.Strings(members)
+		ac.AuditLogConfigs = append(ac.AuditLogConfigs, &crm.AuditLogConfig{LogType: c.LogType, ExemptedMembers: members})
+	}
+	sort.Slice(ac.AuditLogConfigs, func(i, j int) bool { return ac.AuditLogConfigs[i].LogType < ac.AuditLogConfigs[j].LogType })
+	return ac
+}
+
+// setAuditConfig sets the supplied audit config in the supplied policy,
+// replacing any existing audit config for the same service. It returns false
+// if the policy already contained an equivalent audit config.
+func setAuditConfig(p *crm.Policy, desired *crm.AuditConfig) bool {
+	for i, ac := range p.AuditConfigs {
+		if ac.Service != desired.Service {
+			continue
+		}
+		existing := make([]v1alpha1.AuditLogConfig, 0, len(ac.AuditLogConfigs))
+		for _, c := range ac.AuditLogConfigs {
+			existing = append(existing, v1alpha1.AuditLogConfig{LogType: c.LogType, ExemptedMembers: c.ExemptedMembers})
+		}
+		if compare.Equal(desired, newAuditConfig(ac.Service, existing)) {
+			return false
+		}
+		p.AuditConfigs[i] = desired
+		return true
+	}
+	p.AuditConfigs = append(p.AuditConfigs, desired)
+	return true
+}
+
+// removeAuditConfig removes the audit config for the supplied service from the
+// supplied policy. It returns false if the policy had no such audit config.
+func removeAuditConfig(p *crm.Policy, service string) bool {
+	removed := false
+	configs := make([]*crm.AuditConfig, 0, len(p.AuditConfigs))
+	for _, ac := range p.AuditConfigs {
+		if ac.Service == service {
+			removed = true
+			continue
+		}
+		configs = append(configs, ac)
+	}
+	p.AuditConfigs = configs
+	return removed
+}
+
+// An auditConfigConnecter returns an auditConfigCreateSyncDeleter that can
+// create, sync, and delete project audit configs with an external store - for
+// example the GCP API.
+type auditConfigConnecter interface {
+	Connect(context.Context, *v1alpha1.ProjectAuditConfig) (auditConfigCreateSyncDeleter, error)
+}
+
+// auditConfigProviderConnecter is an auditConfigConnecter that returns an
+// auditConfigCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type auditConfigProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns an auditConfigCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// ProjectAuditConfig.
+func (c *auditConfigProviderConnecter) Connect(ctx context.Context, a *v1alpha1.ProjectAuditConfig) (auditConfigCreateSyncDeleter, error) {
+	client, _, err := c.connect(ctx, a, a.Spec.ProviderReference)
+	return &auditConfigs{client: client, kube: c.kube}, err
+}
+
+// AuditConfigReconciler reconciles ProjectAuditConfigs read from the
+// Kubernetes API with an external store, typically the GCP API.
+type AuditConfigReconciler struct {
+	auditConfigConnecter
+	kube client.Client
+}
+
+// ProjectAuditConfigController is responsible for adding the
+// ProjectAuditConfig controller and its corresponding reconciler to the
+// manager with any runtime configuration.
+type ProjectAuditConfigController struct {
+	// DefaultProvider is used by audit configs that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new ProjectAuditConfig Controller and adds it to
+// the Manager with default RBAC. The Manager will set fields on the Controller
+// and start it when the Manager is Started.
+func (c *ProjectAuditConfigController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &AuditConfigReconciler{
+		auditConfigConnecter: &auditConfigProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: resourcemanager.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(auditConfigControllerName).
+		For(&v1alpha1.ProjectAuditConfig{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listAuditConfigs)).
+		Complete(r)
+}
+
+// Reconcile GCP project audit configs with the GCP API.
+func (r *AuditConfigReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	auditConfigLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.ProjectAuditConfigKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	a := &v1alpha1.ProjectAuditConfig{}
+	if err := r.kube.Get(ctx, req.NamespacedName, a); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get project audit config %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, a)
+	if err != nil {
+		a.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, a), "cannot update project audit config %s", req.NamespacedName)
+	}
+
+	// The audit config has been deleted from the API server. Remove it from
+	// the project's IAM policy in GCP.
+	if a.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, a)}, errors.Wrapf(r.kube.Update(ctx, a), "cannot update project audit config %s", req.NamespacedName)
+	}
+
+	// The target project has not been resolved. Assume the audit config has
+	// not been set.
+	if a.Status.ProjectID == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, a)}, errors.Wrapf(r.kube.Update(ctx, a), "cannot update project audit config %s", req.NamespacedName)
+	}
+
+	// The audit config exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, a)}, errors.Wrapf(r.kube.Update(ctx, a), "cannot update project audit config %s", req.NamespacedName)
+}
+
+// listAuditConfigs is a provider.Lister of project audit configs.
+func listAuditConfigs(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.ProjectAuditConfigList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,372 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resourcemanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	crm "google.golang.org/api/cloudresourcemanager/v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/resourcemanager/v1alpha1"
+	fakeresourcemanager "github.com/crossplaneio/crossplane/pkg/clients/gcp/resourcemanager/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	allServices   = "allServices"
+	logDataRead   = "DATA_READ"
+	logDataWrite  = "DATA_WRITE"
+	exemptedUser  = "user:alice@example.org"
+	policyEtag    = "BwWWja0YfJA="
+	storageAudits = "storage.googleapis.com"
+)
+
+type auditConfigModifier func(*v1alpha1.ProjectAuditConfig)
+
+func withAuditConfigConditions(c ...corev1alpha1.Condition) auditConfigModifier {
+	return func(a *v1alpha1.ProjectAuditConfig) { a.Status.SetConditions(c...) }
+}
+
+func withAuditConfigFinalizers(f ...string) auditConfigModifier {
+	return func(a *v1alpha1.ProjectAuditConfig) { a.ObjectMeta.Finalizers = f }
+}
+
+func withAuditConfigReclaimPolicy(r corev1alpha1.ReclaimPolicy) auditConfigModifier {
+	return func(a *v1alpha1.ProjectAuditConfig) { a.Spec.ReclaimPolicy = r }
+}
+
+func withAuditConfigProjectID(id string) auditConfigModifier {
+	return func(a *v1alpha1.ProjectAuditConfig) { a.Status.ProjectID = id }
+}
+
+func auditConfig(am ...auditConfigModifier) *v1alpha1.ProjectAuditConfig {
+	a := &v1alpha1.ProjectAuditConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.ProjectAuditConfigSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			ProjectAuditConfigParameters: v1alpha1.ProjectAuditConfigParameters{
+				ProjectTarget: v1alpha1.ProjectTarget{ProjectID: generatedID},
+				Service:       allServices,
+				AuditLogConfigs: []v1alpha1.AuditLogConfig{
+					{LogType: logDataWrite},
+					{LogType: logDataRead, ExemptedMembers: []string{exemptedUser}},
+				},
+			},
+		},
+	}
+
+	for _, m := range am {
+		m(a)
+	}
+
+	return a
+}
+
+// desiredAuditConfig is the GCP representation of the audit config produced
+// by auditConfig().
+func desiredAuditConfig() *crm.AuditConfig {
+	return &crm.AuditConfig{
+		Service: allServices,
+		AuditLogConfigs: []*crm.AuditLogConfig{
+			{LogType: logDataRead, ExemptedMembers: []string{exemptedUser}},
+			{LogType: logDataWrite, ExemptedMembers: []string{}},
+		},
+	}
+}
+
+func TestAuditConfigCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         auditConfigCreateSyncDeleter
+		a           *v1alpha1.ProjectAuditConfig
+		want        *v1alpha1.ProjectAuditConfig
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &auditConfigs{client: &fakeresourcemanager.MockClient{
+				MockGetIamPolicy: func(_ context.Context, _ string) (*crm.Policy, error) {
+					return &crm.Policy{AuditConfigs: []*crm.AuditConfig{desiredAuditConfig()}}, nil
+				},
+			}},
+			a: auditConfig(),
+			want: auditConfig(
+				withAuditConfigFinalizers(auditConfigFinalizer),
+				withAuditConfigProjectID(generatedID),
+				withAuditConfigConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "NoProject",
+			csd:  &auditConfigs{client: &fakeresourcemanager.MockClient{}},
+			a: auditConfig(func(a *v1alpha1.ProjectAuditConfig) {
+				a.Spec.ProjectTarget = v1alpha1.ProjectTarget{}
+			}),
+			want: auditConfig(
+				func(a *v1alpha1.ProjectAuditConfig) { a.Spec.ProjectTarget = v1alpha1.ProjectTarget{} },
+				withAuditConfigConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.New("one of projectId or projectRef must be set"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.a)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.a, test.EquateConditions()); diff != "" {
+				t.Errorf("a: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAuditConfigSync(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         auditConfigCreateSyncDeleter
+		a           *v1alpha1.ProjectAuditConfig
+		want        *v1alpha1.ProjectAuditConfig
+		wantRequeue bool
+	}{
+		{
+			name: "UpToDate",
+			csd: &auditConfigs{client: &fakeresourcemanager.MockClient{
+				MockGetIamPolicy: func(_ context.Context, _ string) (*crm.Policy, error) {
+					// Equivalent, but differently ordered, audit log configs.
+					return &crm.Policy{AuditConfigs: []*crm.AuditConfig{{
+						Service: allServices,
+						AuditLogConfigs: []*crm.AuditLogConfig{
+							{LogType: logDataWrite},
+							{LogType: logDataRead, ExemptedMembers: []string{exemptedUser}},
+						},
+					}}}, nil
+				},
+			}},
+			a: auditConfig(withAuditConfigProjectID(generatedID)),
+			want: auditConfig(
+				withAuditConfigProjectID(generatedID),
+				withAuditConfigConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "AddsAuditConfig",
+			csd: &auditConfigs{client: &fakeresourcemanager.MockClient{
+				MockGetIamPolicy: func(_ context.Context, _ string) (*crm.Policy, error) {
+					return &crm.Policy{
+						Etag:         policyEtag,
+						Bindings:     []*crm.Binding{{Role: "roles/owner", Members: []string{exemptedUser}}},
+						AuditConfigs: []*crm.AuditConfig{{Service: storageAudits}},
+					}, nil
+				},
+				MockSetIamPolicy: func(_ context.Context, resource string, p *crm.Policy, mask string) (*crm.Policy, error) {
+					want := &crm.Policy{
+						Etag:         policyEtag,
+						Bindings:     []*crm.Binding{{Role: "roles/owner", Members: []string{exemptedUser}}},
+						AuditConfigs: []*crm.AuditConfig{{Service: storageAudits}, desiredAuditConfig()},
+					}
+					if diff := cmp.Diff(want, p); diff != "" {
+						t.Errorf("SetIamPolicy(...): -want, +got:\n%s", diff)
+					}
+					if resource != projectName(generatedID) || mask != auditConfigsMask {
+						t.Errorf("SetIamPolicy(...): want %s %s, got %s %s", projectName(generatedID), auditConfigsMask, resource, mask)
+					}
+					return p, nil
+				},
+			}},
+			a: auditConfig(withAuditConfigProjectID(generatedID)),
+			want: auditConfig(
+				withAuditConfigProjectID(generatedID),
+				withAuditConfigConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReplacesAuditConfig",
+			csd: &auditConfigs{client: &fakeresourcemanager.MockClient{
+				MockGetIamPolicy: func(_ context.Context, _ string) (*crm.Policy, error) {
+					return &crm.Policy{AuditConfigs: []*crm.AuditConfig{{
+						Service:         allServices,
+						AuditLogConfigs: []*crm.AuditLogConfig{{LogType: logDataWrite}},
+					}}}, nil
+				},
+				MockSetIamPolicy: func(_ context.Context, _ string, p *crm.Policy, _ string) (*crm.Policy, error) {
+					want := &crm.Policy{AuditConfigs: []*crm.AuditConfig{desiredAuditConfig()}}
+					if diff := cmp.Diff(want, p); diff != "" {
+						t.Errorf("SetIamPolicy(...): -want, +got:\n%s", diff)
+					}
+					return p, nil
+				},
+			}},
+			a: auditConfig(withAuditConfigProjectID(generatedID)),
+			want: auditConfig(
+				withAuditConfigProjectID(generatedID),
+				withAuditConfigConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedSetIamPolicy",
+			csd: &auditConfigs{client: &fakeresourcemanager.MockClient{
+				MockGetIamPolicy: func(_ context.Context, _ string) (*crm.Policy, error) { return &crm.Policy{}, nil },
+				MockSetIamPolicy: func(_ context.Context, _ string, _ *crm.Policy, _ string) (*crm.Policy, error) {
+					return nil, errorBoom
+				},
+			}},
+			a: auditConfig(withAuditConfigProjectID(generatedID)),
+			want: auditConfig(
+				withAuditConfigProjectID(generatedID),
+				withAuditConfigConditions(corev1alpha1.ReconcileError(errors.Wrapf(errorBoom, "cannot set IAM policy of %s", projectName(generatedID)))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.a)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.a, test.EquateConditions()); diff != "" {
+				t.Errorf("a: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAuditConfigDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         auditConfigCreateSyncDeleter
+		a           *v1alpha1.ProjectAuditConfig
+		want        *v1alpha1.ProjectAuditConfig
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimDelete",
+			csd: &auditConfigs{client: &fakeresourcemanager.MockClient{
+				MockGetIamPolicy: func(_ context.Context, _ string) (*crm.Policy, error) {
+					return &crm.Policy{AuditConfigs: []*crm.AuditConfig{{Service: storageAudits}, desiredAuditConfig()}}, nil
+				},
+				MockSetIamPolicy: func(_ context.Context, _ string, p *crm.Policy, _ string) (*crm.Policy, error) {
+					want := &crm.Policy{AuditConfigs: []*crm.AuditConfig{{Service: storageAudits}}}
+					if diff := cmp.Diff(want, p); diff != "" {
+						t.Errorf("SetIamPolicy(...): -want, +got:\n%s", diff)
+					}
+					return p, nil
+				},
+			}},
+			a: auditConfig(
+				withAuditConfigProjectID(generatedID),
+				withAuditConfigFinalizers(auditConfigFinalizer),
+				withAuditConfigReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: auditConfig(
+				withAuditConfigProjectID(generatedID),
+				withAuditConfigReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withAuditConfigConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimRetain",
+			csd:  &auditConfigs{client: &fakeresourcemanager.MockClient{}},
+			a: auditConfig(
+				withAuditConfigProjectID(generatedID),
+				withAuditConfigFinalizers(auditConfigFinalizer),
+				withAuditConfigReclaimPolicy(corev1alpha1.ReclaimRetain),
+			),
+			want: auditConfig(
+				withAuditConfigProjectID(generatedID),
+				withAuditConfigReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withAuditConfigConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ProjectGone",
+			csd: &auditConfigs{client: &fakeresourcemanager.MockClient{
+				MockGetIamPolicy: func(_ context.Context, _ string) (*crm.Policy, error) { return nil, errorNotFound },
+			}},
+			a: auditConfig(
+				withAuditConfigProjectID(generatedID),
+				withAuditConfigFinalizers(auditConfigFinalizer),
+				withAuditConfigReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: auditConfig(
+				withAuditConfigProjectID(generatedID),
+				withAuditConfigReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withAuditConfigConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedGetIamPolicy",
+			csd: &auditConfigs{client: &fakeresourcemanager.MockClient{
+				MockGetIamPolicy: func(_ context.Context, _ string) (*crm.Policy, error) { return nil, errorBoom },
+			}},
+			a: auditConfig(
+				withAuditConfigProjectID(generatedID),
+				withAuditConfigFinalizers(auditConfigFinalizer),
+				withAuditConfigReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: auditConfig(
+				withAuditConfigProjectID(generatedID),
+				withAuditConfigFinalizers(auditConfigFinalizer),
+				withAuditConfigReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withAuditConfigConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrapf(errorBoom, "cannot get IAM policy of %s", projectName(generatedID)))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.a)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.a, test.EquateConditions()); diff != "" {
+				t.Errorf("a: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
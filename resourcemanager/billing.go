@@ -0,0 +1,238 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resourcemanager
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	cloudbilling "google.golang.org/api/cloudbilling/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/resourcemanager/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/resourcemanager"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	billingControllerName = "projectbillinginfos.resourcemanager.gcp.crossplane.io"
+	billingFinalizer      = "finalizer." + billingControllerName
+
+	billingAccountPrefix = "billingAccounts/"
+)
+
+var billingLog = logging.Logger.WithName("controller." + billingControllerName)
+
+// A billingCreateSyncDeleter can create, sync, and delete project billing
+// info in an external store - e.g. the GCP API. Each method returns true if
+// the billing info requires further reconciliation.
+type billingCreateSyncDeleter interface {
+	Create(ctx context.Context, b *v1alpha1.ProjectBillingInfo) (requeue bool)
+	Sync(ctx context.Context, b *v1alpha1.ProjectBillingInfo) (requeue bool)
+	Delete(ctx context.Context, b *v1alpha1.ProjectBillingInfo) (requeue bool)
+}
+
+// billingInfos is a billingCreateSyncDeleter using the GCP Cloud Billing API.
+type billingInfos struct {
+	client resourcemanager.Client
+	kube   client.Client
+}
+
+// Create links the target project to the billing account. Linking is
+// synchronous, so the billing info is synced immediately after.
+func (c *billingInfos) Create(ctx context.Context, b *v1alpha1.ProjectBillingInfo) bool {
+	b.Status.SetConditions(corev1alpha1.Creating())
+
+	id, err := resolveProject(ctx, c.kube, b, b.Spec.ProjectTarget)
+	if err != nil {
+		b.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	b.Status.ProjectID = id
+	meta.AddFinalizer(b, billingFinalizer)
+	return c.Sync(ctx, b)
+}
+
+// Sync links the target project to the billing account if it is not already
+// linked to it.
+func (c *billingInfos) Sync(ctx context.Context, b *v1alpha1.ProjectBillingInfo) bool {
+	actual, err := c.client.GetBillingInfo(ctx, b.Status.ProjectID)
+	if err != nil {
+		b.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	desired := billingAccountName(b.Spec.BillingAccount)
+	if actual.BillingAccountName != desired {
+		actual, err = c.client.UpdateBillingInfo(ctx, b.Status.ProjectID, &cloudbilling.ProjectBillingInfo{BillingAccountName: desired})
+		if err != nil {
+			b.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot link project %s to billing account %s", b.Status.ProjectID, desired)))
+			return true
+		}
+	}
+
+	b.Status.BillingEnabled = actual.BillingEnabled
+	b.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete unlinks the target project from its billing account. Doing so stops
+// all billable services in the project.
+func (c *billingInfos) Delete(ctx context.Context, b *v1alpha1.ProjectBillingInfo) bool {
+	b.Status.SetConditions(corev1alpha1.Deleting())
+
+	if b.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete && b.Status.ProjectID != "" {
+		// An empty billing account name disables billing for the project.
+		_, err := c.client.UpdateBillingInfo(ctx, b.Status.ProjectID, &cloudbilling.ProjectBillingInfo{ForceSendFields: []string{"BillingAccountName"}})
+		if err != nil && !googleapi.IsErrorNotFound(err) {
+			b.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot unlink project %s from its billing account", b.Status.ProjectID)))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(b, billingFinalizer)
+	b.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// billingAccountName returns the resource name of the supplied billing
+// account, which may be specified either by ID or by resource name.
+func billingAccountName(account string) string {
+	if account == "" || strings.HasPrefix(account, billingAccountPrefix) {
+		return account
+	}
+	return billingAccountPrefix + account
+}
+
+// A billingConnecter returns a billingCreateSyncDeleter that can create, sync,
+// and delete project billing info with an external store - for example the
+// GCP API.
+type billingConnecter interface {
+	Connect(context.Context, *v1alpha1.ProjectBillingInfo) (billingCreateSyncDeleter, error)
+}
+
+// billingProviderConnecter is a billingConnecter that returns a
+// billingCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type billingProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns a billingCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// ProjectBillingInfo.
+func (c *billingProviderConnecter) Connect(ctx context.Context, b *v1alpha1.ProjectBillingInfo) (billingCreateSyncDeleter, error) {
+	client, _, err := c.connect(ctx, b, b.Spec.ProviderReference)
+	return &billingInfos{client: client, kube: c.kube}, err
+}
+
+// BillingReconciler reconciles ProjectBillingInfos read from the Kubernetes
+// API with an external store, typically the GCP API.
+type BillingReconciler struct {
+	billingConnecter
+	kube client.Client
+}
+
+// ProjectBillingInfoController is responsible for adding the
+// ProjectBillingInfo controller and its corresponding reconciler to the
+// manager with any runtime configuration.
+type ProjectBillingInfoController struct {
+	// DefaultProvider is used by billing infos that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new ProjectBillingInfo Controller and adds it to
+// the Manager with default RBAC. The Manager will set fields on the Controller
+// and start it when the Manager is Started.
+func (c *ProjectBillingInfoController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &BillingReconciler{
+		billingConnecter: &billingProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: resourcemanager.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(billingControllerName).
+		For(&v1alpha1.ProjectBillingInfo{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listBillingInfos)).
+		Complete(r)
+}
+
+// Reconcile GCP project billing info with the GCP API.
+func (r *BillingReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	billingLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.ProjectBillingInfoKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	b := &v1alpha1.ProjectBillingInfo{}
+	if err := r.kube.Get(ctx, req.NamespacedName, b); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get project billing info %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, b)
+	if err != nil {
+		b.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, b), "cannot update project billing info %s", req.NamespacedName)
+	}
+
+	// The billing info has been deleted from the API server. Unlink the
+	// project in GCP.
+	if b.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, b)}, errors.Wrapf(r.kube.Update(ctx, b), "cannot update project billing info %s", req.NamespacedName)
+	}
+
+	// The target project has not been resolved. Assume it has not been linked.
+	if b.Status.ProjectID == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, b)}, errors.Wrapf(r.kube.Update(ctx, b), "cannot update project billing info %s", req.NamespacedName)
+	}
+
+	// The billing info exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, b)}, errors.Wrapf(r.kube.Update(ctx, b), "cannot update project billing info %s", req.NamespacedName)
+}
+
+// listBillingInfos is a provider.Lister of project billing infos.
+func listBillingInfos(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.ProjectBillingInfoList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
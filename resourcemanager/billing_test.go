@@ -0,0 +1,310 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resourcemanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	cloudbilling "google.golang.org/api/cloudbilling/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/resourcemanager/v1alpha1"
+	fakeresourcemanager "github.com/crossplaneio/crossplane/pkg/clients/gcp/resourcemanager/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const billingAccount = "012345-6789AB-CDEF01"
+
+type billingModifier func(*v1alpha1.ProjectBillingInfo)
+
+func withBillingConditions(c ...corev1alpha1.Condition) billingModifier {
+	return func(b *v1alpha1.ProjectBillingInfo) { b.Status.SetConditions(c...) }
+}
+
+func withBillingFinalizers(f ...string) billingModifier {
+	return func(b *v1alpha1.ProjectBillingInfo) { b.ObjectMeta.Finalizers = f }
+}
+
+func withBillingReclaimPolicy(r corev1alpha1.ReclaimPolicy) billingModifier {
+	return func(b *v1alpha1.ProjectBillingInfo) { b.Spec.ReclaimPolicy = r }
+}
+
+func withBillingProjectID(id string) billingModifier {
+	return func(b *v1alpha1.ProjectBillingInfo) { b.Status.ProjectID = id }
+}
+
+func withBillingEnabled(e bool) billingModifier {
+	return func(b *v1alpha1.ProjectBillingInfo) { b.Status.BillingEnabled = e }
+}
+
+func billingInfo(bm ...billingModifier) *v1alpha1.ProjectBillingInfo {
+	b := &v1alpha1.ProjectBillingInfo{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.ProjectBillingInfoSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			ProjectBillingInfoParameters: v1alpha1.ProjectBillingInfoParameters{
+				ProjectTarget:  v1alpha1.ProjectTarget{ProjectRef: &corev1.LocalObjectReference{Name: name}},
+				BillingAccount: billingAccount,
+			},
+		},
+	}
+
+	for _, m := range bm {
+		m(b)
+	}
+
+	return b
+}
+
+func TestBillingCreate(t *testing.T) {
+	linked := func(_ context.Context, _ string) (*cloudbilling.ProjectBillingInfo, error) {
+		return &cloudbilling.ProjectBillingInfo{BillingAccountName: billingAccountPrefix + billingAccount, BillingEnabled: true}, nil
+	}
+
+	cases := []struct {
+		name        string
+		csd         billingCreateSyncDeleter
+		b           *v1alpha1.ProjectBillingInfo
+		want        *v1alpha1.ProjectBillingInfo
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &billingInfos{
+				client: &fakeresourcemanager.MockClient{MockGetBillingInfo: linked},
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						p := project(withProjectID(generatedID), withProjectConditions(corev1alpha1.Available()))
+						p.DeepCopyInto(obj.(*v1alpha1.Project))
+						return nil
+					},
+				},
+			},
+			b: billingInfo(),
+			want: billingInfo(
+				withBillingFinalizers(billingFinalizer),
+				withBillingProjectID(generatedID),
+				withBillingEnabled(true),
+				withBillingConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ProjectNotAvailable",
+			csd: &billingInfos{
+				client: &fakeresourcemanager.MockClient{},
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						p := project(withProjectID(generatedID), withProjectConditions(corev1alpha1.Creating()))
+						p.DeepCopyInto(obj.(*v1alpha1.Project))
+						return nil
+					},
+				},
+			},
+			b: billingInfo(),
+			want: billingInfo(
+				withBillingConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Errorf("project %s/%s is not yet available", namespace, name))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.b)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.b, test.EquateConditions()); diff != "" {
+				t.Errorf("b: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBillingSync(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         billingCreateSyncDeleter
+		b           *v1alpha1.ProjectBillingInfo
+		want        *v1alpha1.ProjectBillingInfo
+		wantRequeue bool
+	}{
+		{
+			name: "AlreadyLinked",
+			csd: &billingInfos{client: &fakeresourcemanager.MockClient{
+				MockGetBillingInfo: func(_ context.Context, _ string) (*cloudbilling.ProjectBillingInfo, error) {
+					return &cloudbilling.ProjectBillingInfo{BillingAccountName: billingAccountPrefix + billingAccount, BillingEnabled: true}, nil
+				},
+				MockUpdateBillingInfo: func(_ context.Context, _ string, _ *cloudbilling.ProjectBillingInfo) (*cloudbilling.ProjectBillingInfo, error) {
+					t.Errorf("UpdateBillingInfo(...): unexpected call")
+					return nil, nil
+				},
+			}},
+			b: billingInfo(withBillingProjectID(generatedID)),
+			want: billingInfo(
+				withBillingProjectID(generatedID),
+				withBillingEnabled(true),
+				withBillingConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "Linked",
+			csd: &billingInfos{client: &fakeresourcemanager.MockClient{
+				MockGetBillingInfo: func(_ context.Context, _ string) (*cloudbilling.ProjectBillingInfo, error) {
+					return &cloudbilling.ProjectBillingInfo{}, nil
+				},
+				MockUpdateBillingInfo: func(_ context.Context, _ string, b *cloudbilling.ProjectBillingInfo) (*cloudbilling.ProjectBillingInfo, error) {
+					return &cloudbilling.ProjectBillingInfo{BillingAccountName: b.BillingAccountName, BillingEnabled: true}, nil
+				},
+			}},
+			b: billingInfo(withBillingProjectID(generatedID)),
+			want: billingInfo(
+				withBillingProjectID(generatedID),
+				withBillingEnabled(true),
+				withBillingConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedUpdate",
+			csd: &billingInfos{client: &fakeresourcemanager.MockClient{
+				MockGetBillingInfo: func(_ context.Context, _ string) (*cloudbilling.ProjectBillingInfo, error) {
+					return &cloudbilling.ProjectBillingInfo{}, nil
+				},
+				MockUpdateBillingInfo: func(_ context.Context, _ string, _ *cloudbilling.ProjectBillingInfo) (*cloudbilling.ProjectBillingInfo, error) {
+					return nil, errorBoom
+				},
+			}},
+			b: billingInfo(withBillingProjectID(generatedID)),
+			want: billingInfo(
+				withBillingProjectID(generatedID),
+				withBillingConditions(corev1alpha1.ReconcileError(errors.Wrapf(errorBoom, "cannot link project %s to billing account %s", generatedID, billingAccountPrefix+billingAccount))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.b)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.b, test.EquateConditions()); diff != "" {
+				t.Errorf("b: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBillingDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         billingCreateSyncDeleter
+		b           *v1alpha1.ProjectBillingInfo
+		want        *v1alpha1.ProjectBillingInfo
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimDelete",
+			csd: &billingInfos{client: &fakeresourcemanager.MockClient{
+				MockUpdateBillingInfo: func(_ context.Context, _ string, b *cloudbilling.ProjectBillingInfo) (*cloudbilling.ProjectBillingInfo, error) {
+					if b.BillingAccountName != "" {
+						t.Errorf("UpdateBillingInfo(...): want empty billing account, got %s", b.BillingAccountName)
+					}
+					return b, nil
+				},
+			}},
+			b: billingInfo(withBillingProjectID(generatedID), withBillingFinalizers(billingFinalizer), withBillingReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: billingInfo(
+				withBillingProjectID(generatedID),
+				withBillingReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withBillingConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedDelete",
+			csd: &billingInfos{client: &fakeresourcemanager.MockClient{
+				MockUpdateBillingInfo: func(_ context.Context, _ string, _ *cloudbilling.ProjectBillingInfo) (*cloudbilling.ProjectBillingInfo, error) {
+					return nil, errorBoom
+				},
+			}},
+			b: billingInfo(withBillingProjectID(generatedID), withBillingFinalizers(billingFinalizer), withBillingReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: billingInfo(
+				withBillingProjectID(generatedID),
+				withBillingFinalizers(billingFinalizer),
+				withBillingReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withBillingConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrapf(errorBoom, "cannot unlink project %s from its billing account", generatedID))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.b)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.b, test.EquateConditions()); diff != "" {
+				t.Errorf("b: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBillingAccountName(t *testing.T) {
+	cases := map[string]struct {
+		account string
+		want    string
+	}{
+		"ID":           {account: billingAccount, want: billingAccountPrefix + billingAccount},
+		"ResourceName": {account: billingAccountPrefix + billingAccount, want: billingAccountPrefix + billingAccount},
+		"Empty":        {account: "", want: ""},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := billingAccountName(tc.account); got != tc.want {
+				t.Errorf("billingAccountName(%q): want %q, got %q", tc.account, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resourcemanager
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	essentialcontacts "google.golang.org/api/essentialcontacts/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/resourcemanager/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/resourcemanager"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	contactControllerName = "essentialcontacts.resourcemanager.gcp.crossplane.io"
+	contactFinalizer      = "finalizer." + contactControllerName
+
+	// contactMask contains the fields of a contact that may be updated. A
+	// contact's email can't be changed once it has been created.
+	contactMask = "notificationCategorySubscriptions,languageTag"
+)
+
+var contactLog = logging.Logger.WithName("controller." + contactControllerName)
+
+// A contactCreateSyncDeleter can create, sync, and delete essential contacts
+// in an external store - e.g. the GCP API. Each method returns true if the
+// contact requires further reconciliation.
+type contactCreateSyncDeleter interface {
+	Create(ctx context.Context, c *v1alpha1.EssentialContact) (requeue bool)
+	Sync(ctx context.Context, c *v1alpha1.EssentialContact) (requeue bool)
+	Delete(ctx context.Context, c *v1alpha1.EssentialContact) (requeue bool)
+}
+
+// contacts is a contactCreateSyncDeleter using the GCP Essential Contacts
+// API.
+type contacts struct {
+	client resourcemanager.Client
+	kube   client.Client
+}
+
+// Create resolves the target project and creates the contact within it.
+// Contact names are assigned by GCP, and recorded in the contact's status.
+func (c *contacts) Create(ctx context.Context, ec *v1alpha1.EssentialContact) bool {
+	ec.Status.SetConditions(corev1alpha1.Creating())
+
+	id, err := resolveProject(ctx, c.kube, ec, ec.Spec.ProjectTarget)
+	if err != nil {
+		ec.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	actual, err := c.client.CreateContact(ctx, projectName(id), newContact(ec.Spec.EssentialContactParameters))
+	if err != nil {
+		ec.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot create essential contact %s in project %s", ec.Spec.Email, id)))
+		return true
+	}
+
+	ec.Status.ProjectID = id
+	ec.Status.ContactName = actual.Name
+	ec.Status.ValidationState = actual.ValidationState
+	meta.AddFinalizer(ec, contactFinalizer)
+	ec.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync updates the contact's notification categories and language if they
+// differ from those desired. A contact that no longer exists in GCP is
+// recreated.
+func (c *contacts) Sync(ctx context.Context, ec *v1alpha1.EssentialContact) bool {
+	actual, err := c.client.GetContact(ctx, ec.Status.ContactName)
+	if googleapi.IsErrorNotFound(err) {
+		ec.Status.ContactName = ""
+		ec.Status.SetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess())
+		return true
+	}
+	if err != nil {
+		ec.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	if !strings.EqualFold(actual.Email, ec.Spec.Email) {
+		ec.Status.SetConditions(corev1alpha1.ReconcileError(errors.Errorf("cannot change email of essential contact %s from %s to %s", actual.Name, actual.Email, ec.Spec.Email)))
+		return true
+	}
+
+	if desired := newContact(ec.Spec.EssentialContactParameters); !contactUpToDate(desired, actual) {
+		actual, err = c.client.PatchContact(ctx, actual.Name, desired, contactMask)
+		if err != nil {
+			ec.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot update essential contact %s", ec.Status.ContactName)))
+			return true
+		}
+	}
+
+	ec.Status.ValidationState = actual.ValidationState
+	ec.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete deletes the contact.
+func (c *contacts) Delete(ctx context.Context, ec *v1alpha1.EssentialContact) bool {
+	ec.Status.SetConditions(corev1alpha1.Deleting())
+
+	if ec.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete && ec.Status.ContactName != "" {
+		if err := c.client.DeleteContact(ctx, ec.Status.ContactName); err != nil && !googleapi.IsErrorNotFound(err) {
+			ec.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot delete essential contact %s", ec.Status.ContactName)))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(ec, contactFinalizer)
+	ec.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// newContact returns the GCP representation of the supplied parameters.
+func newContact(p v1alpha1.EssentialContactParameters) *essentialcontacts.GoogleCloudEssentialcontactsV1Contact {
+	categories := append([]string{}, p.NotificationCategorySubscriptions...)
+	sort.Strings(categories)
+	return &essentialcontacts.GoogleCloudEssentialcontactsV1Contact{
+		Email:                             p.Email,
+		LanguageTag:                       p.LanguageTag,
+		NotificationCategorySubscriptions: categories,
+	}
+}
+
+// contactUpToDate returns true if the supplied actual contact's mutable fields
+// match those of the supplied desired contact.
+func contactUpToDate(desired, actual *essentialcontacts.GoogleCloudEssentialcontactsV1Contact) bool {
+	if desired.LanguageTag != actual.LanguageTag {
+		return false
+	}
+	categories := append([]string{}, actual.NotificationCategorySubscriptions...)
+	sort.Strings(categories)
+	if len(categories) != len(desired.NotificationCategorySubscriptions) {
+		return false
+	}
+	for i := range categories {
+		if categories[i] != desired.NotificationCategorySubscriptions[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// A contactConnecter returns a contactCreateSyncDeleter that can create,
+// sync, and delete essential contacts with an external store - for example
+// the GCP API.
+type contactConnecter interface {
+	Connect(context.Context, *v1alpha1.EssentialContact) (contactCreateSyncDeleter, error)
+}
+
+// contactProviderConnecter is a contactConnecter that returns a
+// contactCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type contactProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns a contactCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// EssentialContact.
+func (c *contactProviderConnecter) Connect(ctx context.Context, ec *v1alpha1.EssentialContact) (contactCreateSyncDeleter, error) {
+	client, _, err := c.connect(ctx, ec, ec.Spec.ProviderReference)
+	return &contacts{client: client, kube: c.kube}, err
+}
+
+// ContactReconciler reconciles EssentialContacts read from the Kubernetes
+// API with an external store, typically the GCP API.
+type ContactReconciler struct {
+	contactConnecter
+	kube client.Client
+}
+
+// EssentialContactController is responsible for adding the EssentialContact
+// controller and its corresponding reconciler to the manager with any runtime
+// configuration.
+type EssentialContactController struct {
+	// DefaultProvider is used by essential contacts that don't reference a
+	// provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new EssentialContact Controller and adds it to
+// the Manager with default RBAC. The Manager will set fields on the Controller
+// and start it when the Manager is Started.
+func (c *EssentialContactController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &ContactReconciler{
+		contactConnecter: &contactProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: resourcemanager.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(contactControllerName).
+		For(&v1alpha1.EssentialContact{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listContacts)).
+		Complete(r)
+}
+
+// Reconcile GCP essential contacts with the GCP API.
+func (r *ContactReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	contactLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.EssentialContactKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	ec := &v1alpha1.EssentialContact{}
+	if err := r.kube.Get(ctx, req.NamespacedName, ec); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get essential contact %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, ec)
+	if err != nil {
+		ec.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, ec), "cannot update essential contact %s", req.NamespacedName)
+	}
+
+	// The contact has been deleted from the API server. Delete it from GCP.
+	if ec.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, ec)}, errors.Wrapf(r.kube.Update(ctx, ec), "cannot update essential contact %s", req.NamespacedName)
+	}
+
+	// The contact has not been created in GCP. Create it.
+	if ec.Status.ContactName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, ec)}, errors.Wrapf(r.kube.Update(ctx, ec), "cannot update essential contact %s", req.NamespacedName)
+	}
+
+	// The contact exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, ec)}, errors.Wrapf(r.kube.Update(ctx, ec), "cannot update essential contact %s", req.NamespacedName)
+}
+
+// listContacts is a provider.Lister of essential contacts.
+func listContacts(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.EssentialContactList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,325 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resourcemanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	essentialcontacts "google.golang.org/api/essentialcontacts/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/resourcemanager/v1alpha1"
+	fakeresourcemanager "github.com/crossplaneio/crossplane/pkg/clients/gcp/resourcemanager/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	contactEmail           = "security@example.org"
+	contactLanguage        = "en-GB"
+	categorySecurity       = "SECURITY"
+	categoryTechnical      = "TECHNICAL"
+	contactValid           = "VALID"
+	contactName            = "projects/" + projectNumber + "/contacts/42"
+	contactValidationUnset = "VALIDATION_STATE_UNSPECIFIED"
+)
+
+type contactModifier func(*v1alpha1.EssentialContact)
+
+func withContactConditions(c ...corev1alpha1.Condition) contactModifier {
+	return func(ec *v1alpha1.EssentialContact) { ec.Status.SetConditions(c...) }
+}
+
+func withContactFinalizers(f ...string) contactModifier {
+	return func(ec *v1alpha1.EssentialContact) { ec.ObjectMeta.Finalizers = f }
+}
+
+func withContactReclaimPolicy(r corev1alpha1.ReclaimPolicy) contactModifier {
+	return func(ec *v1alpha1.EssentialContact) { ec.Spec.ReclaimPolicy = r }
+}
+
+func withContactProjectID(id string) contactModifier {
+	return func(ec *v1alpha1.EssentialContact) { ec.Status.ProjectID = id }
+}
+
+func withContactName(n string) contactModifier {
+	return func(ec *v1alpha1.EssentialContact) { ec.Status.ContactName = n }
+}
+
+func withContactValidationState(s string) contactModifier {
+	return func(ec *v1alpha1.EssentialContact) { ec.Status.ValidationState = s }
+}
+
+func essentialContact(cm ...contactModifier) *v1alpha1.EssentialContact {
+	ec := &v1alpha1.EssentialContact{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.EssentialContactSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			EssentialContactParameters: v1alpha1.EssentialContactParameters{
+				ProjectTarget:                     v1alpha1.ProjectTarget{ProjectID: generatedID},
+				Email:                             contactEmail,
+				LanguageTag:                       contactLanguage,
+				NotificationCategorySubscriptions: []string{categoryTechnical, categorySecurity},
+			},
+		},
+	}
+
+	for _, m := range cm {
+		m(ec)
+	}
+
+	return ec
+}
+
+func TestContactCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         contactCreateSyncDeleter
+		ec          *v1alpha1.EssentialContact
+		want        *v1alpha1.EssentialContact
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &contacts{client: &fakeresourcemanager.MockClient{
+				MockCreateContact: func(_ context.Context, parent string, c *essentialcontacts.GoogleCloudEssentialcontactsV1Contact) (*essentialcontacts.GoogleCloudEssentialcontactsV1Contact, error) {
+					if parent != projectName(generatedID) {
+						t.Errorf("CreateContact(...): want parent %s, got %s", projectName(generatedID), parent)
+					}
+					want := &essentialcontacts.GoogleCloudEssentialcontactsV1Contact{
+						Email:                             contactEmail,
+						LanguageTag:                       contactLanguage,
+						NotificationCategorySubscriptions: []string{categorySecurity, categoryTechnical},
+					}
+					if diff := cmp.Diff(want, c); diff != "" {
+						t.Errorf("CreateContact(...): -want, +got:\n%s", diff)
+					}
+					return &essentialcontacts.GoogleCloudEssentialcontactsV1Contact{Name: contactName, ValidationState: contactValidationUnset}, nil
+				},
+			}},
+			ec: essentialContact(),
+			want: essentialContact(
+				withContactFinalizers(contactFinalizer),
+				withContactProjectID(generatedID),
+				withContactName(contactName),
+				withContactValidationState(contactValidationUnset),
+				withContactConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &contacts{client: &fakeresourcemanager.MockClient{
+				MockCreateContact: func(_ context.Context, _ string, _ *essentialcontacts.GoogleCloudEssentialcontactsV1Contact) (*essentialcontacts.GoogleCloudEssentialcontactsV1Contact, error) {
+					return nil, errorBoom
+				},
+			}},
+			ec: essentialContact(),
+			want: essentialContact(
+				withContactConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrapf(errorBoom, "cannot create essential contact %s in project %s", contactEmail, generatedID))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.ec)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.ec, test.EquateConditions()); diff != "" {
+				t.Errorf("ec: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestContactSync(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         contactCreateSyncDeleter
+		ec          *v1alpha1.EssentialContact
+		want        *v1alpha1.EssentialContact
+		wantRequeue bool
+	}{
+		{
+			name: "UpToDate",
+			csd: &contacts{client: &fakeresourcemanager.MockClient{
+				MockGetContact: func(_ context.Context, _ string) (*essentialcontacts.GoogleCloudEssentialcontactsV1Contact, error) {
+					return &essentialcontacts.GoogleCloudEssentialcontactsV1Contact{
+						Name:                              contactName,
+						Email:                             "Security@example.org",
+						LanguageTag:                       contactLanguage,
+						NotificationCategorySubscriptions: []string{categoryTechnical, categorySecurity},
+						ValidationState:                   contactValid,
+					}, nil
+				},
+			}},
+			ec: essentialContact(withContactProjectID(generatedID), withContactName(contactName)),
+			want: essentialContact(
+				withContactProjectID(generatedID),
+				withContactName(contactName),
+				withContactValidationState(contactValid),
+				withContactConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "NeedsUpdate",
+			csd: &contacts{client: &fakeresourcemanager.MockClient{
+				MockGetContact: func(_ context.Context, _ string) (*essentialcontacts.GoogleCloudEssentialcontactsV1Contact, error) {
+					return &essentialcontacts.GoogleCloudEssentialcontactsV1Contact{
+						Name:                              contactName,
+						Email:                             contactEmail,
+						NotificationCategorySubscriptions: []string{categorySecurity},
+					}, nil
+				},
+				MockPatchContact: func(_ context.Context, n string, _ *essentialcontacts.GoogleCloudEssentialcontactsV1Contact, mask string) (*essentialcontacts.GoogleCloudEssentialcontactsV1Contact, error) {
+					if n != contactName || mask != contactMask {
+						t.Errorf("PatchContact(...): want %s %s, got %s %s", contactName, contactMask, n, mask)
+					}
+					return &essentialcontacts.GoogleCloudEssentialcontactsV1Contact{Name: contactName, ValidationState: contactValid}, nil
+				},
+			}},
+			ec: essentialContact(withContactProjectID(generatedID), withContactName(contactName)),
+			want: essentialContact(
+				withContactProjectID(generatedID),
+				withContactName(contactName),
+				withContactValidationState(contactValid),
+				withContactConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "EmailChanged",
+			csd: &contacts{client: &fakeresourcemanager.MockClient{
+				MockGetContact: func(_ context.Context, _ string) (*essentialcontacts.GoogleCloudEssentialcontactsV1Contact, error) {
+					return &essentialcontacts.GoogleCloudEssentialcontactsV1Contact{Name: contactName, Email: "old@example.org"}, nil
+				},
+			}},
+			ec: essentialContact(withContactProjectID(generatedID), withContactName(contactName)),
+			want: essentialContact(
+				withContactProjectID(generatedID),
+				withContactName(contactName),
+				withContactConditions(corev1alpha1.ReconcileError(errors.Errorf("cannot change email of essential contact %s from %s to %s", contactName, "old@example.org", contactEmail))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "NotFound",
+			csd: &contacts{client: &fakeresourcemanager.MockClient{
+				MockGetContact: func(_ context.Context, _ string) (*essentialcontacts.GoogleCloudEssentialcontactsV1Contact, error) {
+					return nil, errorNotFound
+				},
+			}},
+			ec: essentialContact(withContactProjectID(generatedID), withContactName(contactName)),
+			want: essentialContact(
+				withContactProjectID(generatedID),
+				withContactConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.ec)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.ec, test.EquateConditions()); diff != "" {
+				t.Errorf("ec: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestContactDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         contactCreateSyncDeleter
+		ec          *v1alpha1.EssentialContact
+		want        *v1alpha1.EssentialContact
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimDelete",
+			csd: &contacts{client: &fakeresourcemanager.MockClient{
+				MockDeleteContact: func(_ context.Context, _ string) error { return errorNotFound },
+			}},
+			ec: essentialContact(
+				withContactName(contactName),
+				withContactFinalizers(contactFinalizer),
+				withContactReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: essentialContact(
+				withContactName(contactName),
+				withContactReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withContactConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedDelete",
+			csd: &contacts{client: &fakeresourcemanager.MockClient{
+				MockDeleteContact: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			ec: essentialContact(
+				withContactName(contactName),
+				withContactFinalizers(contactFinalizer),
+				withContactReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: essentialContact(
+				withContactName(contactName),
+				withContactFinalizers(contactFinalizer),
+				withContactReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withContactConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrapf(errorBoom, "cannot delete essential contact %s", contactName))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.ec)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.ec, test.EquateConditions()); diff != "" {
+				t.Errorf("ec: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resourcemanager
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	crm "google.golang.org/api/cloudresourcemanager/v3"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/resourcemanager/v1alpha1"
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/resourcemanager"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	projectControllerName = "projects.resourcemanager.gcp.crossplane.io"
+	projectFinalizer      = "finalizer." + projectControllerName
+
+	// projectIDPrefix is prepended to the UID of a Project that does not
+	// specify a project ID. Project IDs must start with a letter and be at
+	// most 30 characters long.
+	projectIDPrefix    = "cp-"
+	projectIDMaxLength = 30
+
+	projectStateActive = "ACTIVE"
+
+	// projectOwnerLabel is set on each project to the UID of the Project that
+	// created it, in order to prove ownership of a project that already
+	// exists. Project IDs are globally unique, so a project with the ID we
+	// would have created may belong to someone else.
+	projectOwnerLabel = "crossplane-owner-uid"
+)
+
+var projectLog = logging.Logger.WithName("controller." + projectControllerName)
+
+// A projectCreateSyncDeleter can create, sync, and delete projects in an
+// external store - e.g. the GCP API. Each method returns true if the project
+// requires further reconciliation.
+type projectCreateSyncDeleter interface {
+	Create(ctx context.Context, p *v1alpha1.Project) (requeue bool)
+	Sync(ctx context.Context, p *v1alpha1.Project) (requeue bool)
+	Delete(ctx context.Context, p *v1alpha1.Project) (requeue bool)
+}
+
+// projects is a projectCreateSyncDeleter using the GCP Resource Manager API.
+type projects struct {
+	client   resourcemanager.Client
+	kube     client.Client
+	provider *gcpv1alpha1.Provider
+}
+
+// Create requests the creation of the project. Project creation is
+// asynchronous. A project that already exists is adopted only if it was
+// created by this Project, i.e. if we created it but failed to record its ID.
+func (c *projects) Create(ctx context.Context, p *v1alpha1.Project) bool {
+	p.Status.SetConditions(corev1alpha1.Creating())
+
+	id := projectID(p)
+	err := c.client.CreateProject(ctx, newProject(id, p.GetUID(), p.Spec.ProjectParameters))
+	if gcp.IsErrorAlreadyExists(err) {
+		err = c.verifyOwner(ctx, p, id)
+	}
+	if err != nil {
+		p.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot create project %s", id)))
+		return true
+	}
+
+	p.Status.ProjectID = id
+	meta.AddFinalizer(p, projectFinalizer)
+	p.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync observes the project, updates its display name and labels if they
+// have changed, and publishes a Provider targeting it if requested.
+func (c *projects) Sync(ctx context.Context, p *v1alpha1.Project) bool {
+	actual, err := c.client.GetProject(ctx, p.Status.ProjectID)
+	if err != nil {
+		p.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	p.Status.ProjectNumber = strings.TrimPrefix(actual.Name, "projects/")
+	p.Status.State = actual.State
+
+	if actual.State != projectStateActive {
+		p.Status.SetConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	desired := newProject(p.Status.ProjectID, p.GetUID(), p.Spec.ProjectParameters)
+	if mask := projectUpdateMask(desired, actual); len(mask) > 0 {
+		if err := c.client.UpdateProject(ctx, actual.Name, desired, strings.Join(mask, ",")); err != nil {
+			p.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot update project")))
+			return true
+		}
+	}
+
+	if err := c.publishProvider(ctx, p); err != nil {
+		p.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	p.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete requests the deletion of the project. GCP retains deleted projects
+// for 30 days, during which they may be restored.
+func (c *projects) Delete(ctx context.Context, p *v1alpha1.Project) bool {
+	p.Status.SetConditions(corev1alpha1.Deleting())
+
+	if p.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.client.DeleteProject(ctx, p.Status.ProjectID); err != nil && !googleapi.IsErrorNotFound(err) {
+			p.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete project")))
+			return true
+		}
+	}
+
+	if err := c.unpublishProvider(ctx, p); err != nil {
+		p.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	meta.RemoveFinalizer(p, projectFinalizer)
+	p.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// verifyOwner returns an error unless the existing project with the supplied
+// ID was created by the supplied Project.
+func (c *projects) verifyOwner(ctx context.Context, p *v1alpha1.Project, id string) error {
+	actual, err := c.client.GetProject(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "cannot get existing project")
+	}
+	if actual.Labels[projectOwnerLabel] != string(p.GetUID()) {
+		return errors.New("project already exists and was not created by this resource")
+	}
+	return nil
+}
+
+// publishProvider creates or updates a Provider that targets the project,
+// using the same credentials as the Provider that created it. This allows
+// other managed resources to be created in the project. The published Provider
+// is created in the namespace of the Provider that created the project, where
+// its credential secrets live. Owner references may not cross namespaces, so
+// the published Provider is instead labelled with the UID of the Project, and
+// deleted with it.
+func (c *projects) publishProvider(ctx context.Context, p *v1alpha1.Project) error {
+	if p.Spec.ProviderName == "" {
+		return nil
+	}
+
+	got := &gcpv1alpha1.Provider{ObjectMeta: metav1.ObjectMeta{Namespace: c.provider.GetNamespace(), Name: p.Spec.ProviderName}}
+	err := util.CreateOrUpdate(ctx, c.kube, got, func() error {
+		if got.GetResourceVersion() != "" && got.GetLabels()[projectOwnerLabel] != string(p.GetUID()) {
+			return errors.Errorf("provider %s/%s exists and was not published by project %s", got.GetNamespace(), got.GetName(), p.GetName())
+		}
+		if got.Labels == nil {
+			got.Labels = map[string]string{}
+		}
+		got.Labels[projectOwnerLabel] = string(p.GetUID())
+		creds := c.provider.Spec.DeepCopy()
+		got.Spec.ProjectID = p.Status.ProjectID
+		got.Spec.CredentialsType = creds.CredentialsType
+		got.Spec.Secret = creds.Secret
+		got.Spec.ServiceCredentials = creds.ServiceCredentials
+		return nil
+	})
+	return errors.Wrapf(err, "cannot publish provider %s", p.Spec.ProviderName)
+}
+
+// unpublishProvider deletes the Provider published by publishProvider, if any.
+func (c *projects) unpublishProvider(ctx context.Context, p *v1alpha1.Project) error {
+	if p.Spec.ProviderName == "" {
+		return nil
+	}
+
+	pr := &gcpv1alpha1.Provider{}
+	err := c.kube.Get(ctx, types.NamespacedName{Namespace: c.provider.GetNamespace(), Name: p.Spec.ProviderName}, pr)
+	if kerrors.IsNotFound(err) || (err == nil && pr.GetLabels()[projectOwnerLabel] != string(p.GetUID())) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "cannot get published provider %s", p.Spec.ProviderName)
+	}
+	if err := c.kube.Delete(ctx, pr); err != nil && !kerrors.IsNotFound(err) {
+		return errors.Wrapf(err, "cannot delete published provider %s", p.Spec.ProviderName)
+	}
+	return nil
+}
+
+// projectID returns the ID of the supplied project, which is generated from
+// its UID unless specified.
+func projectID(p *v1alpha1.Project) string {
+	if p.Spec.ProjectID != "" {
+		return p.Spec.ProjectID
+	}
+	id := projectIDPrefix + strings.Replace(string(p.GetUID()), "-", "", -1)
+	if len(id) > projectIDMaxLength {
+		id = id[:projectIDMaxLength]
+	}
+	return id
+}
+
+// newProject returns a project with the supplied ID, owned by the Project
+// with the supplied UID and described by the supplied parameters.
+func newProject(id string, owner types.UID, params v1alpha1.ProjectParameters) *crm.Project {
+	labels := make(map[string]string, len(params.Labels)+1)
+	for k, v := range params.Labels {
+		labels[k] = v
+	}
+	labels[projectOwnerLabel] = string(owner)
+
+	return &crm.Project{
+		ProjectId:   id,
+		DisplayName: params.DisplayName,
+		Parent:      params.Parent,
+		Labels:      labels,
+	}
+}
+
+// projectUpdateMask returns the update mask of the mutable fields of the
+// desired project that differ from the actual project. Projects are moved
+// between folders using a separate API, so their parent is never updated.
+func projectUpdateMask(desired, actual *crm.Project) []string {
+	mask := []string{}
+	if desired.DisplayName != "" && desired.DisplayName != actual.DisplayName {
+		mask = append(mask, "displayName")
+	}
+	if !compare.Equal(desired.Labels, actual.Labels) {
+		mask = append(mask, "labels")
+	}
+	sort.Strings(mask)
+	return mask
+}
+
+// A projectConnecter returns a projectCreateSyncDeleter that can create, sync,
+// and delete projects with an external store - for example the GCP API.
+type projectConnecter interface {
+	Connect(context.Context, *v1alpha1.Project) (projectCreateSyncDeleter, error)
+}
+
+// projectProviderConnecter is a projectConnecter that returns a
+// projectCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type projectProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns a projectCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// Project.
+func (c *projectProviderConnecter) Connect(ctx context.Context, p *v1alpha1.Project) (projectCreateSyncDeleter, error) {
+	client, pr, err := c.connect(ctx, p, p.Spec.ProviderReference)
+	return &projects{client: client, kube: c.kube, provider: pr}, err
+}
+
+// ProjectReconciler reconciles Projects read from the Kubernetes API with an
+// external store, typically the GCP API.
+type ProjectReconciler struct {
+	projectConnecter
+	kube client.Client
+}
+
+// ProjectController is responsible for adding the Project controller and its
+// corresponding reconciler to the manager with any runtime configuration.
+type ProjectController struct {
+	// DefaultProvider is used by projects that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new Project Controller and adds it to the Manager
+// with default RBAC. The Manager will set fields on the Controller and start
+// it when the Manager is Started.
+func (c *ProjectController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &ProjectReconciler{
+		projectConnecter: &projectProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: resourcemanager.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(projectControllerName).
+		For(&v1alpha1.Project{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listProjects)).
+		Complete(r)
+}
+
+// Reconcile GCP projects with the GCP API.
+func (r *ProjectReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	projectLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.ProjectKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	p := &v1alpha1.Project{}
+	if err := r.kube.Get(ctx, req.NamespacedName, p); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get project %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, p)
+	if err != nil {
+		p.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, p), "cannot update project %s", req.NamespacedName)
+	}
+
+	// The project has been deleted from the API server. Delete from GCP.
+	if p.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, p)}, errors.Wrapf(r.kube.Update(ctx, p), "cannot update project %s", req.NamespacedName)
+	}
+
+	// The project has no ID. Assume it has not been created in GCP.
+	if p.Status.ProjectID == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, p)}, errors.Wrapf(r.kube.Update(ctx, p), "cannot update project %s", req.NamespacedName)
+	}
+
+	// The project exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, p)}, errors.Wrapf(r.kube.Update(ctx, p), "cannot update project %s", req.NamespacedName)
+}
+
+// listProjects is a provider.Lister of projects.
+func listProjects(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.ProjectList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
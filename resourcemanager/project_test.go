@@ -0,0 +1,512 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resourcemanager
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	crm "google.golang.org/api/cloudresourcemanager/v3"
+	"google.golang.org/api/googleapi"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/resourcemanager/v1alpha1"
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	fakeresourcemanager "github.com/crossplaneio/crossplane/pkg/clients/gcp/resourcemanager/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace     = "cool-namespace"
+	name          = "cool-project"
+	uid           = types.UID("definitely-a-uuid")
+	projectNumber = "1234567890"
+	providerName  = "cool-gcp"
+	parent        = "folders/42"
+)
+
+var (
+	ctx           = context.Background()
+	errorBoom     = errors.New("boom")
+	errorNotFound = &googleapi.Error{Code: http.StatusNotFound}
+	generatedID   = projectIDPrefix + "definitelyauuid"
+)
+
+// Test that our Reconciler implementations satisfy the Reconciler interface.
+var (
+	_ reconcile.Reconciler = &ProjectReconciler{}
+	_ reconcile.Reconciler = &BillingReconciler{}
+	_ reconcile.Reconciler = &ServicesReconciler{}
+	_ reconcile.Reconciler = &AuditConfigReconciler{}
+	_ reconcile.Reconciler = &ContactReconciler{}
+)
+
+type projectModifier func(*v1alpha1.Project)
+
+func withProjectConditions(c ...corev1alpha1.Condition) projectModifier {
+	return func(p *v1alpha1.Project) { p.Status.SetConditions(c...) }
+}
+
+func withProjectFinalizers(f ...string) projectModifier {
+	return func(p *v1alpha1.Project) { p.ObjectMeta.Finalizers = f }
+}
+
+func withProjectReclaimPolicy(r corev1alpha1.ReclaimPolicy) projectModifier {
+	return func(p *v1alpha1.Project) { p.Spec.ReclaimPolicy = r }
+}
+
+func withProjectID(id string) projectModifier {
+	return func(p *v1alpha1.Project) { p.Status.ProjectID = id }
+}
+
+func withProjectState(number, state string) projectModifier {
+	return func(p *v1alpha1.Project) {
+		p.Status.ProjectNumber = number
+		p.Status.State = state
+	}
+}
+
+func withProviderName(n string) projectModifier {
+	return func(p *v1alpha1.Project) { p.Spec.ProviderName = n }
+}
+
+func project(pm ...projectModifier) *v1alpha1.Project {
+	p := &v1alpha1.Project{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.ProjectSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			ProjectParameters: v1alpha1.ProjectParameters{
+				DisplayName: "Cool Project",
+				Parent:      parent,
+				Labels:      map[string]string{"team": "cool"},
+			},
+		},
+	}
+
+	for _, m := range pm {
+		m(p)
+	}
+
+	return p
+}
+
+func TestProjectCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         projectCreateSyncDeleter
+		p           *v1alpha1.Project
+		want        *v1alpha1.Project
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &projects{client: &fakeresourcemanager.MockClient{
+				MockCreateProject: func(_ context.Context, p *crm.Project) error {
+					if p.ProjectId != generatedID {
+						t.Errorf("p.ProjectId: want %s, got %s", generatedID, p.ProjectId)
+					}
+					if p.Parent != parent {
+						t.Errorf("p.Parent: want %s, got %s", parent, p.Parent)
+					}
+					if p.Labels[projectOwnerLabel] != string(uid) {
+						t.Errorf("p.Labels[%s]: want %s, got %s", projectOwnerLabel, uid, p.Labels[projectOwnerLabel])
+					}
+					return nil
+				},
+			}},
+			p: project(),
+			want: project(
+				withProjectFinalizers(projectFinalizer),
+				withProjectID(generatedID),
+				withProjectConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "AlreadyExists",
+			csd: &projects{client: &fakeresourcemanager.MockClient{
+				MockCreateProject: func(_ context.Context, _ *crm.Project) error { return &googleapi.Error{Code: http.StatusConflict} },
+				MockGetProject: func(_ context.Context, _ string) (*crm.Project, error) {
+					return &crm.Project{ProjectId: generatedID, Labels: map[string]string{projectOwnerLabel: string(uid)}}, nil
+				},
+			}},
+			p: project(),
+			want: project(
+				withProjectFinalizers(projectFinalizer),
+				withProjectID(generatedID),
+				withProjectConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "AlreadyExistsNotOwned",
+			csd: &projects{client: &fakeresourcemanager.MockClient{
+				MockCreateProject: func(_ context.Context, _ *crm.Project) error { return &googleapi.Error{Code: http.StatusConflict} },
+				MockGetProject: func(_ context.Context, _ string) (*crm.Project, error) {
+					return &crm.Project{ProjectId: generatedID, Labels: map[string]string{"team": "other"}}, nil
+				},
+			}},
+			p: project(),
+			want: project(
+				withProjectConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrapf(
+					errors.New("project already exists and was not created by this resource"), "cannot create project %s", generatedID))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			csd: &projects{client: &fakeresourcemanager.MockClient{
+				MockCreateProject: func(_ context.Context, _ *crm.Project) error { return errorBoom },
+			}},
+			p: project(),
+			want: project(
+				withProjectConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrapf(errorBoom, "cannot create project %s", generatedID))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.p)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.p, test.EquateConditions()); diff != "" {
+				t.Errorf("p: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestProjectSync(t *testing.T) {
+	active := func(_ context.Context, _ string) (*crm.Project, error) {
+		return &crm.Project{
+			Name:        "projects/" + projectNumber,
+			ProjectId:   generatedID,
+			DisplayName: "Cool Project",
+			Labels:      map[string]string{"team": "cool", projectOwnerLabel: string(uid)},
+			State:       projectStateActive,
+		}, nil
+	}
+	publisher := &gcpv1alpha1.Provider{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "crossplane-system", Name: providerName},
+		Spec: gcpv1alpha1.ProviderSpec{
+			CredentialsType: gcpv1alpha1.CredentialsTypeImpersonation,
+			Secret:          corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "creds"}, Key: "target"},
+			ServiceCredentials: []gcpv1alpha1.ServiceCredentials{{
+				Service: "sqladmin",
+				Secret:  &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "sql-creds"}, Key: "target"},
+			}},
+		},
+	}
+
+	cases := []struct {
+		name        string
+		csd         projectCreateSyncDeleter
+		p           *v1alpha1.Project
+		want        *v1alpha1.Project
+		wantRequeue bool
+	}{
+		{
+			name: "ProjectActive",
+			csd: &projects{client: &fakeresourcemanager.MockClient{
+				MockGetProject: active,
+				MockUpdateProject: func(_ context.Context, _ string, _ *crm.Project, _ string) error {
+					t.Errorf("UpdateProject(...): unexpected call")
+					return nil
+				},
+			}},
+			p: project(withProjectID(generatedID)),
+			want: project(
+				withProjectID(generatedID),
+				withProjectState(projectNumber, projectStateActive),
+				withProjectConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ProjectPending",
+			csd: &projects{client: &fakeresourcemanager.MockClient{
+				MockGetProject: func(_ context.Context, _ string) (*crm.Project, error) {
+					return &crm.Project{Name: "projects/" + projectNumber, State: "STATE_UNSPECIFIED"}, nil
+				},
+			}},
+			p: project(withProjectID(generatedID)),
+			want: project(
+				withProjectID(generatedID),
+				withProjectState(projectNumber, "STATE_UNSPECIFIED"),
+				withProjectConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "ProjectUpdated",
+			csd: &projects{client: &fakeresourcemanager.MockClient{
+				MockGetProject: func(_ context.Context, _ string) (*crm.Project, error) {
+					return &crm.Project{Name: "projects/" + projectNumber, DisplayName: "Old Project", State: projectStateActive}, nil
+				},
+				MockUpdateProject: func(_ context.Context, _ string, _ *crm.Project, mask string) error {
+					if mask != "displayName,labels" {
+						t.Errorf("UpdateProject(...): want mask displayName,labels, got %s", mask)
+					}
+					return nil
+				},
+			}},
+			p: project(withProjectID(generatedID)),
+			want: project(
+				withProjectID(generatedID),
+				withProjectState(projectNumber, projectStateActive),
+				withProjectConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ProviderPublished",
+			csd: &projects{
+				client:   &fakeresourcemanager.MockClient{MockGetProject: active},
+				provider: publisher,
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, _ runtime.Object) error {
+						return kerrors.NewNotFound(schema.GroupResource{}, "")
+					},
+					MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+						want := &gcpv1alpha1.Provider{
+							ObjectMeta: metav1.ObjectMeta{
+								Namespace: publisher.GetNamespace(),
+								Name:      "cool-project-gcp",
+								Labels:    map[string]string{projectOwnerLabel: string(uid)},
+							},
+							Spec: publisher.Spec,
+						}
+						want.Spec.ProjectID = generatedID
+						if diff := cmp.Diff(want, obj); diff != "" {
+							t.Errorf("Create(...): -want, +got:\n%s", diff)
+						}
+						return nil
+					},
+				},
+			},
+			p: project(withProjectID(generatedID), withProviderName("cool-project-gcp")),
+			want: project(
+				withProjectID(generatedID),
+				withProviderName("cool-project-gcp"),
+				withProjectState(projectNumber, projectStateActive),
+				withProjectConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ProviderNotPublishedByProject",
+			csd: &projects{
+				client:   &fakeresourcemanager.MockClient{MockGetProject: active},
+				provider: publisher,
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						obj.(*gcpv1alpha1.Provider).SetResourceVersion("1")
+						return nil
+					},
+				},
+			},
+			p: project(withProjectID(generatedID), withProviderName("cool-project-gcp")),
+			want: project(
+				withProjectID(generatedID),
+				withProviderName("cool-project-gcp"),
+				withProjectState(projectNumber, projectStateActive),
+				withProjectConditions(corev1alpha1.ReconcileError(errors.Wrap(
+					errors.Errorf("provider %s/%s exists and was not published by project %s", publisher.GetNamespace(), "cool-project-gcp", name),
+					"cannot publish provider cool-project-gcp"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGet",
+			csd: &projects{client: &fakeresourcemanager.MockClient{
+				MockGetProject: func(_ context.Context, _ string) (*crm.Project, error) { return nil, errorBoom },
+			}},
+			p: project(withProjectID(generatedID)),
+			want: project(
+				withProjectID(generatedID),
+				withProjectConditions(corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.p)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.p, test.EquateConditions()); diff != "" {
+				t.Errorf("p: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestProjectDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         projectCreateSyncDeleter
+		p           *v1alpha1.Project
+		want        *v1alpha1.Project
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimDelete",
+			csd: &projects{client: &fakeresourcemanager.MockClient{
+				MockDeleteProject: func(_ context.Context, _ string) error { return nil },
+			}},
+			p: project(withProjectID(generatedID), withProjectFinalizers(projectFinalizer), withProjectReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: project(
+				withProjectID(generatedID),
+				withProjectReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withProjectConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "UnpublishProvider",
+			csd: &projects{
+				client:   &fakeresourcemanager.MockClient{},
+				provider: &gcpv1alpha1.Provider{ObjectMeta: metav1.ObjectMeta{Namespace: "crossplane-system", Name: providerName}},
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+						if key.Namespace != "crossplane-system" {
+							t.Errorf("Get(...): want namespace crossplane-system, got %s", key.Namespace)
+						}
+						obj.(*gcpv1alpha1.Provider).SetLabels(map[string]string{projectOwnerLabel: string(uid)})
+						return nil
+					},
+					MockDelete: func(_ context.Context, _ runtime.Object, _ ...client.DeleteOption) error { return nil },
+				},
+			},
+			p: project(withProjectID(generatedID), withProviderName("cool-project-gcp"), withProjectFinalizers(projectFinalizer), withProjectReclaimPolicy(corev1alpha1.ReclaimRetain)),
+			want: project(
+				withProjectID(generatedID),
+				withProviderName("cool-project-gcp"),
+				withProjectReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withProjectConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimRetain",
+			csd:  &projects{client: &fakeresourcemanager.MockClient{}},
+			p:    project(withProjectID(generatedID), withProjectFinalizers(projectFinalizer), withProjectReclaimPolicy(corev1alpha1.ReclaimRetain)),
+			want: project(
+				withProjectID(generatedID),
+				withProjectReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withProjectConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "NotFound",
+			csd: &projects{client: &fakeresourcemanager.MockClient{
+				MockDeleteProject: func(_ context.Context, _ string) error { return errorNotFound },
+			}},
+			p: project(withProjectID(generatedID), withProjectFinalizers(projectFinalizer), withProjectReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: project(
+				withProjectID(generatedID),
+				withProjectReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withProjectConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedDelete",
+			csd: &projects{client: &fakeresourcemanager.MockClient{
+				MockDeleteProject: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			p: project(withProjectID(generatedID), withProjectFinalizers(projectFinalizer), withProjectReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: project(
+				withProjectID(generatedID),
+				withProjectFinalizers(projectFinalizer),
+				withProjectReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withProjectConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot delete project"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.p)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.p, test.EquateConditions()); diff != "" {
+				t.Errorf("p: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestProjectID(t *testing.T) {
+	cases := map[string]struct {
+		p    *v1alpha1.Project
+		want string
+	}{
+		"Specified": {
+			p:    &v1alpha1.Project{Spec: v1alpha1.ProjectSpec{ProjectParameters: v1alpha1.ProjectParameters{ProjectID: "my-project"}}},
+			want: "my-project",
+		},
+		"Generated": {
+			p:    &v1alpha1.Project{ObjectMeta: metav1.ObjectMeta{UID: uid}},
+			want: generatedID,
+		},
+		"Truncated": {
+			p:    &v1alpha1.Project{ObjectMeta: metav1.ObjectMeta{UID: types.UID("0b1a6b8e-3d3c-4a35-9e0f-0c5a2b5f1b7e")}},
+			want: "cp-0b1a6b8e3d3c4a359e0f0c5a2b5",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := projectID(tc.p); got != tc.want {
+				t.Errorf("projectID(...): want %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package resourcemanager contains controllers that create GCP projects, link
+// them to billing accounts, enable services within them, and configure their
+// audit logging and essential contacts.
+package resourcemanager
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/resourcemanager/v1alpha1"
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/resourcemanager"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+)
+
+const reconcileTimeout = 1 * time.Minute
+
+// providerConnecter returns resource manager clients authenticated using
+// credentials read from a Crossplane Provider resource.
+type providerConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (resourcemanager.Client, error)
+}
+
+// connect returns a client authenticated using credentials read from the
+// Provider referenced by the supplied managed resource, and that Provider.
+func (c *providerConnecter) connect(ctx context.Context, mg metav1.Object, ref *corev1.ObjectReference) (resourcemanager.Client, *gcpv1alpha1.Provider, error) {
+	p, err := c.providers.Get(ctx, c.kube, mg, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceResourceManager)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return client, p, errors.Wrap(err, "cannot create new resource manager client")
+}
+
+// resolveProject returns the ID of the project targeted by a
+// ProjectBillingInfo or ServiceEnablement, which may either be specified
+// directly or by reference to a Project in the same namespace. It returns an
+// error if a referenced Project is not yet available.
+func resolveProject(ctx context.Context, kube client.Client, mg metav1.Object, t v1alpha1.ProjectTarget) (string, error) {
+	if t.ProjectRef == nil {
+		if t.ProjectID == "" {
+			return "", errors.New("one of projectId or projectRef must be set")
+		}
+		return t.ProjectID, nil
+	}
+
+	p := &v1alpha1.Project{}
+	n := types.NamespacedName{Namespace: mg.GetNamespace(), Name: t.ProjectRef.Name}
+	if err := kube.Get(ctx, n, p); err != nil {
+		return "", errors.Wrapf(err, "cannot get project %s", n)
+	}
+	if p.Status.ProjectID == "" || p.Status.GetCondition(corev1alpha1.TypeReady).Status != corev1.ConditionTrue {
+		return "", errors.Errorf("project %s is not yet available", n)
+	}
+	return p.Status.ProjectID, nil
+}
+
+// projectName returns the resource name of the supplied project ID.
+func projectName(id string) string {
+	return "projects/" + id
+}
@@ -0,0 +1,289 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resourcemanager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/resourcemanager/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/resourcemanager"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	servicesControllerName = "serviceenablements.resourcemanager.gcp.crossplane.io"
+	servicesFinalizer      = "finalizer." + servicesControllerName
+
+	serviceStateEnabled = "ENABLED"
+
+	// annotationServicesEnabled records the services that a service
+	// enablement enabled, as opposed to those that were already enabled, as
+	// a comma separated list. Only these services are disabled when the
+	// service enablement is deleted.
+	annotationServicesEnabled = "resourcemanager.gcp.crossplane.io/enabled-services"
+)
+
+var servicesLog = logging.Logger.WithName("controller." + servicesControllerName)
+
+// A servicesCreateSyncDeleter can create, sync, and delete service
+// enablements in an external store - e.g. the GCP API. Each method returns
+// true if the service enablement requires further reconciliation.
+type servicesCreateSyncDeleter interface {
+	Create(ctx context.Context, s *v1alpha1.ServiceEnablement) (requeue bool)
+	Sync(ctx context.Context, s *v1alpha1.ServiceEnablement) (requeue bool)
+	Delete(ctx context.Context, s *v1alpha1.ServiceEnablement) (requeue bool)
+}
+
+// serviceEnablements is a servicesCreateSyncDeleter using the GCP Service
+// Usage API.
+type serviceEnablements struct {
+	client resourcemanager.Client
+	kube   client.Client
+}
+
+// Create resolves the target project. Services are enabled when the service
+// enablement is synced.
+func (c *serviceEnablements) Create(ctx context.Context, s *v1alpha1.ServiceEnablement) bool {
+	s.Status.SetConditions(corev1alpha1.Creating())
+
+	id, err := resolveProject(ctx, c.kube, s, s.Spec.ProjectTarget)
+	if err != nil {
+		s.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	s.Status.ProjectID = id
+	meta.AddFinalizer(s, servicesFinalizer)
+	s.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync enables each of the desired services that is not already enabled.
+// Enabling a service is asynchronous, so the service enablement is requeued
+// until all services report that they are enabled.
+func (c *serviceEnablements) Sync(ctx context.Context, s *v1alpha1.ServiceEnablement) bool {
+	enabled := []string{}
+	for _, svc := range s.Spec.Services {
+		actual, err := c.client.GetService(ctx, serviceName(s.Status.ProjectID, svc))
+		if err != nil {
+			s.Status.SetConditions(corev1alpha1.ReconcileError(err))
+			return true
+		}
+		if actual.State == serviceStateEnabled {
+			enabled = append(enabled, svc)
+			continue
+		}
+		if err := c.client.EnableService(ctx, serviceName(s.Status.ProjectID, svc)); err != nil {
+			s.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot enable service %s", svc)))
+			return true
+		}
+		setEnabledByUs(s, append(enabledByUs(s), svc))
+	}
+
+	sort.Strings(enabled)
+	s.Status.EnabledServices = enabled
+
+	if len(enabled) < len(s.Spec.Services) {
+		s.Status.SetConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	s.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete disables the services that the service enablement enabled. Services
+// that were already enabled may be used by other resources in the project, so
+// they are left enabled.
+func (c *serviceEnablements) Delete(ctx context.Context, s *v1alpha1.ServiceEnablement) bool {
+	s.Status.SetConditions(corev1alpha1.Deleting())
+
+	if s.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		for _, svc := range enabledByUs(s) {
+			if err := c.client.DisableService(ctx, serviceName(s.Status.ProjectID, svc)); err != nil && !googleapi.IsErrorNotFound(err) {
+				s.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot disable service %s", svc)))
+				return true
+			}
+		}
+	}
+
+	meta.RemoveFinalizer(s, servicesFinalizer)
+	s.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// enabledByUs returns the services that the supplied service enablement
+// enabled.
+func enabledByUs(s *v1alpha1.ServiceEnablement) []string {
+	v := s.GetAnnotations()[annotationServicesEnabled]
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
+
+// setEnabledByUs records the services that the supplied service enablement
+// enabled.
+func setEnabledByUs(s *v1alpha1.ServiceEnablement, svcs []string) {
+	seen := map[string]bool{}
+	unique := make([]string, 0, len(svcs))
+	for _, svc := range svcs {
+		if !seen[svc] {
+			seen[svc] = true
+			unique = append(unique, svc)
+		}
+	}
+	sort.Strings(unique)
+
+	a := s.GetAnnotations()
+	if a == nil {
+		a = map[string]string{}
+	}
+	a[annotationServicesEnabled] = strings.Join(unique, ",")
+	s.SetAnnotations(a)
+}
+
+// serviceName returns the resource name of the supplied service within the
+// supplied project, e.g. projects/p/services/compute.googleapis.com.
+func serviceName(project, service string) string {
+	return fmt.Sprintf("projects/%s/services/%s", project, service)
+}
+
+// A servicesConnecter returns a servicesCreateSyncDeleter that can create,
+// sync, and delete service enablements with an external store - for example
+// the GCP API.
+type servicesConnecter interface {
+	Connect(context.Context, *v1alpha1.ServiceEnablement) (servicesCreateSyncDeleter, error)
+}
+
+// servicesProviderConnecter is a servicesConnecter that returns a
+// servicesCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type servicesProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns a servicesCreateSyncDeleter backed by the GCP API. GCP
+// credentials are read from the Crossplane Provider referenced by the supplied
+// ServiceEnablement.
+func (c *servicesProviderConnecter) Connect(ctx context.Context, s *v1alpha1.ServiceEnablement) (servicesCreateSyncDeleter, error) {
+	client, _, err := c.connect(ctx, s, s.Spec.ProviderReference)
+	return &serviceEnablements{client: client, kube: c.kube}, err
+}
+
+// ServicesReconciler reconciles ServiceEnablements read from the Kubernetes
+// API with an external store, typically the GCP API.
+type ServicesReconciler struct {
+	servicesConnecter
+	kube client.Client
+}
+
+// ServiceEnablementController is responsible for adding the
+// ServiceEnablement controller and its corresponding reconciler to the
+// manager with any runtime configuration.
+type ServiceEnablementController struct {
+	// DefaultProvider is used by service enablements that don't reference a
+	// provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new ServiceEnablement Controller and adds it to
+// the Manager with default RBAC. The Manager will set fields on the Controller
+// and start it when the Manager is Started.
+func (c *ServiceEnablementController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &ServicesReconciler{
+		servicesConnecter: &servicesProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: resourcemanager.NewClient,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(servicesControllerName).
+		For(&v1alpha1.ServiceEnablement{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listServiceEnablements)).
+		Complete(r)
+}
+
+// Reconcile GCP service enablements with the GCP API.
+func (r *ServicesReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	servicesLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.ServiceEnablementKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	s := &v1alpha1.ServiceEnablement{}
+	if err := r.kube.Get(ctx, req.NamespacedName, s); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get service enablement %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, s)
+	if err != nil {
+		s.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, s), "cannot update service enablement %s", req.NamespacedName)
+	}
+
+	// The service enablement has been deleted from the API server. Disable
+	// its services in GCP.
+	if s.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, s)}, errors.Wrapf(r.kube.Update(ctx, s), "cannot update service enablement %s", req.NamespacedName)
+	}
+
+	// The target project has not been resolved. Assume no services have been
+	// enabled.
+	if s.Status.ProjectID == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, s)}, errors.Wrapf(r.kube.Update(ctx, s), "cannot update service enablement %s", req.NamespacedName)
+	}
+
+	// The service enablement exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, s)}, errors.Wrapf(r.kube.Update(ctx, s), "cannot update service enablement %s", req.NamespacedName)
+}
+
+// listServiceEnablements is a provider.Lister of service enablements.
+func listServiceEnablements(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.ServiceEnablementList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
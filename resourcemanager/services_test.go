@@ -0,0 +1,313 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resourcemanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	serviceusage "google.golang.org/api/serviceusage/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/resourcemanager/v1alpha1"
+	fakeresourcemanager "github.com/crossplaneio/crossplane/pkg/clients/gcp/resourcemanager/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	computeService   = "compute.googleapis.com"
+	containerService = "container.googleapis.com"
+)
+
+type servicesModifier func(*v1alpha1.ServiceEnablement)
+
+func withServicesConditions(c ...corev1alpha1.Condition) servicesModifier {
+	return func(s *v1alpha1.ServiceEnablement) { s.Status.SetConditions(c...) }
+}
+
+func withServicesFinalizers(f ...string) servicesModifier {
+	return func(s *v1alpha1.ServiceEnablement) { s.ObjectMeta.Finalizers = f }
+}
+
+func withServicesReclaimPolicy(r corev1alpha1.ReclaimPolicy) servicesModifier {
+	return func(s *v1alpha1.ServiceEnablement) { s.Spec.ReclaimPolicy = r }
+}
+
+func withServicesProjectID(id string) servicesModifier {
+	return func(s *v1alpha1.ServiceEnablement) { s.Status.ProjectID = id }
+}
+
+func withEnabledServices(svc ...string) servicesModifier {
+	return func(s *v1alpha1.ServiceEnablement) { s.Status.EnabledServices = svc }
+}
+
+func withEnabledByUs(svc ...string) servicesModifier {
+	return func(s *v1alpha1.ServiceEnablement) { setEnabledByUs(s, svc) }
+}
+
+func serviceEnablement(sm ...servicesModifier) *v1alpha1.ServiceEnablement {
+	s := &v1alpha1.ServiceEnablement{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.ServiceEnablementSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			ServiceEnablementParameters: v1alpha1.ServiceEnablementParameters{
+				ProjectTarget: v1alpha1.ProjectTarget{ProjectID: generatedID},
+				Services:      []string{containerService, computeService},
+			},
+		},
+	}
+
+	for _, m := range sm {
+		m(s)
+	}
+
+	return s
+}
+
+func TestServicesCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         servicesCreateSyncDeleter
+		s           *v1alpha1.ServiceEnablement
+		want        *v1alpha1.ServiceEnablement
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd:  &serviceEnablements{client: &fakeresourcemanager.MockClient{}},
+			s:    serviceEnablement(),
+			want: serviceEnablement(
+				withServicesFinalizers(servicesFinalizer),
+				withServicesProjectID(generatedID),
+				withServicesConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "NoProject",
+			csd:  &serviceEnablements{client: &fakeresourcemanager.MockClient{}},
+			s: serviceEnablement(func(s *v1alpha1.ServiceEnablement) {
+				s.Spec.ProjectTarget = v1alpha1.ProjectTarget{}
+			}),
+			want: serviceEnablement(
+				func(s *v1alpha1.ServiceEnablement) { s.Spec.ProjectTarget = v1alpha1.ProjectTarget{} },
+				withServicesConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.New("one of projectId or projectRef must be set"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.s)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.s, test.EquateConditions()); diff != "" {
+				t.Errorf("s: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServicesSync(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         servicesCreateSyncDeleter
+		s           *v1alpha1.ServiceEnablement
+		want        *v1alpha1.ServiceEnablement
+		wantRequeue bool
+	}{
+		{
+			name: "AllEnabled",
+			csd: &serviceEnablements{client: &fakeresourcemanager.MockClient{
+				MockGetService: func(_ context.Context, _ string) (*serviceusage.GoogleApiServiceusageV1Service, error) {
+					return &serviceusage.GoogleApiServiceusageV1Service{State: serviceStateEnabled}, nil
+				},
+			}},
+			s: serviceEnablement(withServicesProjectID(generatedID)),
+			want: serviceEnablement(
+				withServicesProjectID(generatedID),
+				withEnabledServices(computeService, containerService),
+				withServicesConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "Enabling",
+			csd: &serviceEnablements{client: &fakeresourcemanager.MockClient{
+				MockGetService: func(_ context.Context, n string) (*serviceusage.GoogleApiServiceusageV1Service, error) {
+					if n == serviceName(generatedID, computeService) {
+						return &serviceusage.GoogleApiServiceusageV1Service{State: "DISABLED"}, nil
+					}
+					return &serviceusage.GoogleApiServiceusageV1Service{State: serviceStateEnabled}, nil
+				},
+				MockEnableService: func(_ context.Context, n string) error {
+					if n != serviceName(generatedID, computeService) {
+						t.Errorf("EnableService(...): want %s, got %s", serviceName(generatedID, computeService), n)
+					}
+					return nil
+				},
+			}},
+			s: serviceEnablement(withServicesProjectID(generatedID)),
+			want: serviceEnablement(
+				withServicesProjectID(generatedID),
+				withEnabledServices(containerService),
+				withEnabledByUs(computeService),
+				withServicesConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedEnable",
+			csd: &serviceEnablements{client: &fakeresourcemanager.MockClient{
+				MockGetService: func(_ context.Context, _ string) (*serviceusage.GoogleApiServiceusageV1Service, error) {
+					return &serviceusage.GoogleApiServiceusageV1Service{State: "DISABLED"}, nil
+				},
+				MockEnableService: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			s: serviceEnablement(withServicesProjectID(generatedID)),
+			want: serviceEnablement(
+				withServicesProjectID(generatedID),
+				withServicesConditions(corev1alpha1.ReconcileError(errors.Wrapf(errorBoom, "cannot enable service %s", containerService))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.s)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.s, test.EquateConditions()); diff != "" {
+				t.Errorf("s: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServicesDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         servicesCreateSyncDeleter
+		s           *v1alpha1.ServiceEnablement
+		want        *v1alpha1.ServiceEnablement
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimDelete",
+			csd: &serviceEnablements{client: &fakeresourcemanager.MockClient{
+				MockDisableService: func(_ context.Context, n string) error {
+					if n != serviceName(generatedID, computeService) {
+						t.Errorf("DisableService(...): want %s, got %s", serviceName(generatedID, computeService), n)
+					}
+					return nil
+				},
+			}},
+			s: serviceEnablement(
+				withServicesProjectID(generatedID),
+				withEnabledServices(computeService, containerService),
+				withEnabledByUs(computeService),
+				withServicesFinalizers(servicesFinalizer),
+				withServicesReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: serviceEnablement(
+				withServicesProjectID(generatedID),
+				withEnabledServices(computeService, containerService),
+				withEnabledByUs(computeService),
+				withServicesReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withServicesConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "AlreadyEnabled",
+			csd: &serviceEnablements{client: &fakeresourcemanager.MockClient{
+				MockDisableService: func(_ context.Context, n string) error {
+					t.Errorf("DisableService(...): want no services disabled, got %s", n)
+					return nil
+				},
+			}},
+			s: serviceEnablement(
+				withServicesProjectID(generatedID),
+				withEnabledServices(computeService),
+				withServicesFinalizers(servicesFinalizer),
+				withServicesReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: serviceEnablement(
+				withServicesProjectID(generatedID),
+				withEnabledServices(computeService),
+				withServicesReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withServicesConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedDisable",
+			csd: &serviceEnablements{client: &fakeresourcemanager.MockClient{
+				MockDisableService: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			s: serviceEnablement(
+				withServicesProjectID(generatedID),
+				withEnabledServices(computeService),
+				withEnabledByUs(computeService),
+				withServicesFinalizers(servicesFinalizer),
+				withServicesReclaimPolicy(corev1alpha1.ReclaimDelete),
+			),
+			want: serviceEnablement(
+				withServicesProjectID(generatedID),
+				withEnabledServices(computeService),
+				withEnabledByUs(computeService),
+				withServicesFinalizers(servicesFinalizer),
+				withServicesReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withServicesConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrapf(errorBoom, "cannot disable service %s", computeService))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.s)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.s, test.EquateConditions()); diff != "" {
+				t.Errorf("s: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package secretgc contains a controller that deletes the connection secrets
+// of managed resources that no longer exist. Kubernetes garbage collects a
+// connection secret whose controller reference names a deleted owner in the
+// same namespace, but not one whose owner reference is missing, or that was
+// written to a namespace other than that of its owner.
+package secretgc
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+)
+
+const (
+	controllerName   = "connectionsecrets.gcp.crossplane.io"
+	reconcileTimeout = 1 * time.Minute
+
+	// DefaultInterval is how often each connection secret is checked for an
+	// owner if the Options don't specify an interval. The deletion of an
+	// owner does not trigger a reconcile of its secrets, so they are checked
+	// periodically.
+	DefaultInterval = 30 * time.Minute
+
+	// managedGroupSuffix is the suffix of the API groups of the managed
+	// resources whose connection secrets are garbage collected. Secrets
+	// owned by anything else are never deleted.
+	managedGroupSuffix = ".gcp.crossplane.io"
+)
+
+// AnnotationOwner records the managed resource that wrote a connection secret,
+// as a JSON object reference. It identifies the owner of secrets that have no
+// owner reference, or that live in a different namespace than their owner.
+const AnnotationOwner = "gcp.crossplane.io/connection-secret-owner"
+
+var log = logging.Logger.WithName("controller." + controllerName)
+
+// Mark annotates the supplied connection secret with a reference to the
+// supplied managed resource of the supplied kind, so that it may be garbage
+// collected once the managed resource is deleted.
+func Mark(s *corev1.Secret, mg metav1.Object, gvk schema.GroupVersionKind) {
+	ref := corev1.ObjectReference{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Namespace:  mg.GetNamespace(),
+		Name:       mg.GetName(),
+		UID:        mg.GetUID(),
+	}
+	// An ObjectReference can always be marshalled.
+	b, _ := json.Marshal(ref)
+
+	a := s.GetAnnotations()
+	if a == nil {
+		a = map[string]string{}
+	}
+	a[AnnotationOwner] = string(b)
+	s.SetAnnotations(a)
+}
+
+// owner returns a reference to the managed resource that wrote the supplied
+// connection secret, or nil if it was not written by a managed resource. The
+// owner is read from the secret's owner annotation or, failing that, its
+// controller reference.
+func owner(s *corev1.Secret) (*corev1.ObjectReference, error) {
+	if v, ok := s.GetAnnotations()[AnnotationOwner]; ok {
+		ref := &corev1.ObjectReference{}
+		if err := json.Unmarshal([]byte(v), ref); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse annotation %s", AnnotationOwner)
+		}
+		return managed(ref), nil
+	}
+
+	c := metav1.GetControllerOf(s)
+	if c == nil {
+		return nil, nil
+	}
+	// Owner references are always to objects in the same namespace.
+	return managed(&corev1.ObjectReference{
+		APIVersion: c.APIVersion,
+		Kind:       c.Kind,
+		Namespace:  s.GetNamespace(),
+		Name:       c.Name,
+		UID:        c.UID,
+	}), nil
+}
+
+// managed returns the supplied reference if it refers to a managed resource,
+// or nil if it does not.
+func managed(ref *corev1.ObjectReference) *corev1.ObjectReference {
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil || !strings.HasSuffix(gv.Group, managedGroupSuffix) || ref.Kind == "" || ref.Name == "" {
+		return nil
+	}
+	return ref
+}
+
+// Options configure the connection secret garbage collector.
+type Options struct {
+	// Enabled enables the garbage collector. Deleted secrets can't be
+	// recovered, so it is disabled by default.
+	Enabled bool
+
+	// Interval at which each connection secret is checked for an owner.
+	// DefaultInterval is used if it is zero.
+	Interval time.Duration
+}
+
+// Reconciler deletes connection secrets whose owners no longer exist.
+type Reconciler struct {
+	kube client.Client
+
+	// owners reads managed resources directly from the API server, so that
+	// an owner missing from a stale cache is not mistaken for a deleted one.
+	owners   client.Reader
+	interval time.Duration
+}
+
+// Controller is responsible for adding the connection secret garbage
+// collector and its corresponding reconciler to the manager with any runtime
+// configuration.
+type Controller struct {
+	Options Options
+}
+
+// SetupWithManager creates a new connection secret garbage collector and adds
+// it to the Manager. The Manager will set fields on the Controller and start
+// it when the Manager is Started.
+func (c *Controller) SetupWithManager(mgr ctrl.Manager) error {
+	interval := c.Options.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+	r := &Reconciler{kube: mgr.GetClient(), owners: mgr.GetAPIReader(), interval: interval}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerName).
+		For(&corev1.Secret{}).
+		Complete(r)
+}
+
+// Reconcile deletes the requested connection secret if its owner has been
+// deleted.
+func (r *Reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	log.V(logging.Debug).Info("reconciling", "kind", "Secret", "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	s := &corev1.Secret{}
+	if err := r.kube.Get(ctx, req.NamespacedName, s); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get secret %s", req.NamespacedName)
+	}
+	if s.GetDeletionTimestamp() != nil {
+		return reconcile.Result{Requeue: false}, nil
+	}
+
+	ref, err := owner(s)
+	if err != nil {
+		// Don't requeue secrets with unparseable annotations; they'll be
+		// reconciled again when updated.
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot determine owner of secret %s", req.NamespacedName)
+	}
+	if ref == nil {
+		// This is not a connection secret.
+		return reconcile.Result{Requeue: false}, nil
+	}
+
+	exists, err := r.ownerExists(ctx, ref)
+	if err != nil {
+		return reconcile.Result{Requeue: true}, err
+	}
+	if exists {
+		return reconcile.Result{RequeueAfter: r.interval}, nil
+	}
+
+	log.Info("deleting orphaned connection secret", "secret", req.NamespacedName, "owner", ref.Kind+" "+ref.Namespace+"/"+ref.Name)
+	err = resource.Ignore(kerrors.IsNotFound, r.kube.Delete(ctx, s))
+	return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot delete orphaned connection secret %s", req.NamespacedName)
+}
+
+// ownerExists returns true if the managed resource the supplied reference
+// refers to exists. A managed resource that exists with a different UID is a
+// new resource of the same name that will overwrite the secret, and is
+// treated as its owner.
+func (r *Reconciler) ownerExists(ctx context.Context, ref *corev1.ObjectReference) (bool, error) {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind))
+	err := r.owners.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, u)
+	if kerrors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, errors.Wrapf(err, "cannot get owner %s %s/%s", ref.Kind, ref.Namespace, ref.Name)
+}
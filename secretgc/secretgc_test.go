@@ -0,0 +1,209 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secretgc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace  = "cool-namespace"
+	secretName = "cool-secret"
+	ownerName  = "cool-db"
+	ownerUID   = types.UID("definitely-a-uuid")
+)
+
+var (
+	errBoom  = errors.New("boom")
+	ownerGVK = schema.GroupVersionKind{Group: "database.gcp.crossplane.io", Version: "v1alpha1", Kind: "CloudsqlInstance"}
+)
+
+// Test that our Reconciler implementation satisfies the Reconciler interface.
+var _ reconcile.Reconciler = &Reconciler{}
+
+type secretModifier func(*corev1.Secret)
+
+func withController(apiVersion, kind string) secretModifier {
+	return func(s *corev1.Secret) {
+		c := true
+		s.OwnerReferences = []metav1.OwnerReference{{APIVersion: apiVersion, Kind: kind, Name: ownerName, UID: ownerUID, Controller: &c}}
+	}
+}
+
+func withOwnerIn(ns string) secretModifier {
+	return func(s *corev1.Secret) {
+		Mark(s, &metav1.ObjectMeta{Namespace: ns, Name: ownerName, UID: ownerUID}, ownerGVK)
+	}
+}
+
+func withAnnotation(v string) secretModifier {
+	return func(s *corev1.Secret) { s.Annotations = map[string]string{AnnotationOwner: v} }
+}
+
+func secret(sm ...secretModifier) *corev1.Secret {
+	s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: secretName}}
+	for _, m := range sm {
+		m(s)
+	}
+	return s
+}
+
+func TestMark(t *testing.T) {
+	s := secret(withOwnerIn("other-namespace"))
+
+	got, err := owner(s)
+	if err != nil {
+		t.Fatalf("owner(...): %s", err)
+	}
+	want := &corev1.ObjectReference{
+		APIVersion: "database.gcp.crossplane.io/v1alpha1",
+		Kind:       "CloudsqlInstance",
+		Namespace:  "other-namespace",
+		Name:       ownerName,
+		UID:        ownerUID,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("owner(Mark(...)): -want, +got:\n%s", diff)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	interval := 5 * time.Minute
+
+	type want struct {
+		result  reconcile.Result
+		err     error
+		owner   types.NamespacedName
+		deleted bool
+	}
+
+	cases := map[string]struct {
+		secret   *corev1.Secret
+		getOwner error
+		want     want
+	}{
+		"NotAConnectionSecret": {
+			secret: secret(),
+			want:   want{result: reconcile.Result{Requeue: false}},
+		},
+		"OwnedByOtherGroup": {
+			secret: secret(withController("apps/v1", "Deployment")),
+			want:   want{result: reconcile.Result{Requeue: false}},
+		},
+		"ControllerExists": {
+			secret: secret(withController("database.gcp.crossplane.io/v1alpha1", "CloudsqlInstance")),
+			want: want{
+				result: reconcile.Result{RequeueAfter: interval},
+				owner:  types.NamespacedName{Namespace: namespace, Name: ownerName},
+			},
+		},
+		"ControllerDeleted": {
+			secret:   secret(withController("database.gcp.crossplane.io/v1alpha1", "CloudsqlInstance")),
+			getOwner: kerrors.NewNotFound(schema.GroupResource{}, ownerName),
+			want: want{
+				result:  reconcile.Result{Requeue: false},
+				owner:   types.NamespacedName{Namespace: namespace, Name: ownerName},
+				deleted: true,
+			},
+		},
+		"OwnerInOtherNamespaceDeleted": {
+			secret:   secret(withOwnerIn("other-namespace")),
+			getOwner: kerrors.NewNotFound(schema.GroupResource{}, ownerName),
+			want: want{
+				result:  reconcile.Result{Requeue: false},
+				owner:   types.NamespacedName{Namespace: "other-namespace", Name: ownerName},
+				deleted: true,
+			},
+		},
+		"GetOwnerFailed": {
+			secret:   secret(withOwnerIn(namespace)),
+			getOwner: errBoom,
+			want: want{
+				result: reconcile.Result{Requeue: true},
+				err:    errors.Wrapf(errBoom, "cannot get owner CloudsqlInstance %s/%s", namespace, ownerName),
+				owner:  types.NamespacedName{Namespace: namespace, Name: ownerName},
+			},
+		},
+		"InvalidAnnotation": {
+			secret: secret(withAnnotation("{")),
+			want: want{
+				result: reconcile.Result{Requeue: false},
+				err: errors.Wrapf(errors.Wrapf(errors.New("unexpected end of JSON input"), "cannot parse annotation %s", AnnotationOwner),
+					"cannot determine owner of secret %s/%s", namespace, secretName),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			deleted := false
+			kube := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+					tc.secret.DeepCopyInto(obj.(*corev1.Secret))
+					return nil
+				},
+				MockDelete: func(_ context.Context, obj runtime.Object, _ ...client.DeleteOption) error {
+					deleted = true
+					return nil
+				},
+			}
+
+			var gotOwner types.NamespacedName
+			owners := &test.MockClient{
+				MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+					if gvk := obj.(*unstructured.Unstructured).GroupVersionKind(); gvk != ownerGVK {
+						t.Errorf("owners.Get(...): want GVK %s, got %s", ownerGVK, gvk)
+					}
+					gotOwner = key
+					return tc.getOwner
+				},
+			}
+
+			r := &Reconciler{kube: kube, owners: owners, interval: interval}
+			got, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: secretName}})
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("r.Reconcile(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("r.Reconcile(...): -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.owner, gotOwner); diff != "" {
+				t.Errorf("r.Reconcile(...): -want owner, +got owner:\n%s", diff)
+			}
+			if deleted != tc.want.deleted {
+				t.Errorf("r.Reconcile(...): want deleted %t, got %t", tc.want.deleted, deleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package servicenetworking
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	compute "google.golang.org/api/compute/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/servicenetworking/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/servicenetworking"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	controllerName   = "connections.servicenetworking.gcp.crossplane.io"
+	finalizerName    = "finalizer." + controllerName
+	reconcileTimeout = 1 * time.Minute
+
+	// addressPurposePeering is the purpose of a global address reserved for
+	// private services access.
+	addressPurposePeering = "VPC_PEERING"
+	addressTypeInternal   = "INTERNAL"
+)
+
+var log = logging.Logger.WithName("controller." + controllerName)
+
+// A createsyncdeleter can create, sync, and delete service networking
+// connections in an external store - e.g. the GCP API. Each method returns
+// true if the connection requires further reconciliation.
+type createsyncdeleter interface {
+	Create(ctx context.Context, c *v1alpha1.Connection) (requeue bool)
+	Sync(ctx context.Context, c *v1alpha1.Connection) (requeue bool)
+	Delete(ctx context.Context, c *v1alpha1.Connection) (requeue bool)
+}
+
+// serviceNetworking is a createsyncdeleter using the GCP Compute and Service
+// Networking APIs.
+type serviceNetworking struct {
+	client  servicenetworking.Client
+	project string
+}
+
+// Create allocates the connection's IP range, if necessary, then peers the
+// consumer network with the service producer network using that range. A
+// range that already exists is used as is, and is not recorded as allocated
+// for this connection.
+func (s *serviceNetworking) Create(ctx context.Context, c *v1alpha1.Connection) bool {
+	c.Status.SetConditions(corev1alpha1.Creating())
+	meta.AddFinalizer(c, finalizerName)
+
+	r := c.Spec.AllocatedRange
+	if _, err := s.client.GetAddress(ctx, s.project, r.Name); err != nil {
+		if !googleapi.IsErrorNotFound(err) {
+			c.Status.SetConditions(corev1alpha1.ReconcileError(err))
+			return true
+		}
+		a := &compute.Address{
+			Name:         r.Name,
+			Address:      r.Address,
+			PrefixLength: int64(r.PrefixLength),
+			Network:      servicenetworking.NetworkURL(s.project, c.Spec.Network),
+			Purpose:      addressPurposePeering,
+			AddressType:  addressTypeInternal,
+		}
+		if err := s.client.InsertAddress(ctx, s.project, a); err != nil {
+			c.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot allocate range %s", r.Name)))
+			return true
+		}
+		c.Status.AllocatedRangeCreated = true
+	}
+
+	conn := servicenetworking.NewConnection(s.project, c)
+	if err := s.client.CreateConnection(ctx, conn); err != nil {
+		c.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create connection")))
+		return true
+	}
+
+	c.Status.Network = conn.Network
+	c.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync observes the connection between the consumer and producer networks.
+func (s *serviceNetworking) Sync(ctx context.Context, c *v1alpha1.Connection) bool {
+	conns, err := s.client.ListConnections(ctx, servicenetworking.NetworkName(s.project, c.Spec.Network))
+	if err != nil {
+		c.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	for _, conn := range conns {
+		if conn.Service != servicenetworking.Service(c) {
+			continue
+		}
+		c.Status.Peering = conn.Peering
+		c.Status.ReservedPeeringRanges = conn.ReservedPeeringRanges
+		c.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+		return false
+	}
+
+	// The connection is still being established.
+	c.Status.SetConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Delete removes the peering and, if it was allocated for this connection,
+// releases the reserved IP range.
+func (s *serviceNetworking) Delete(ctx context.Context, c *v1alpha1.Connection) bool {
+	c.Status.SetConditions(corev1alpha1.Deleting())
+
+	if c.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		err := s.client.DeleteConnection(ctx, servicenetworking.NetworkName(s.project, c.Spec.Network), servicenetworking.Service(c))
+		if err != nil && !googleapi.IsErrorNotFound(err) {
+			c.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete connection")))
+			return true
+		}
+		// A range that existed before this connection may be used by other
+		// peerings, so only a range this connection allocated is released.
+		if c.Status.AllocatedRangeCreated {
+			if err := s.client.DeleteAddress(ctx, s.project, c.Spec.AllocatedRange.Name); err != nil && !googleapi.IsErrorNotFound(err) {
+				c.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot release range %s", c.Spec.AllocatedRange.Name)))
+				return true
+			}
+			c.Status.AllocatedRangeCreated = false
+		}
+	}
+
+	meta.RemoveFinalizer(c, finalizerName)
+	c.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// A connecter returns a createsyncdeleter that can create, sync, and delete
+// service networking connections with an external store - for example the GCP
+// API.
+type connecter interface {
+	Connect(context.Context, *v1alpha1.Connection) (createsyncdeleter, error)
+}
+
+// providerConnecter is a connecter that returns a createsyncdeleter
+// authenticated using credentials read from a Crossplane Provider resource.
+type providerConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (servicenetworking.Client, error)
+}
+
+// Connect returns a createsyncdeleter backed by the GCP API. GCP credentials
+// are read from the Crossplane Provider referenced by the supplied Connection.
+func (c *providerConnecter) Connect(ctx context.Context, i *v1alpha1.Connection) (createsyncdeleter, error) {
+	p, err := c.providers.Get(ctx, c.kube, i, i.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceServiceNetworking)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return &serviceNetworking{client: client, project: p.Spec.ProjectID}, errors.Wrap(err, "cannot create new ServiceNetworking client")
+}
+
+// Reconciler reconciles Connections read from the Kubernetes API with an
+// external store, typically the GCP API.
+type Reconciler struct {
+	connecter
+	kube client.Client
+}
+
+// ConnectionController is responsible for adding the service networking
+// Connection controller and its corresponding reconciler to the manager with
+// any runtime configuration.
+type ConnectionController struct {
+	// DefaultProvider is used by connections that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new Connection Controller and adds it to the
+// Manager with default RBAC. The Manager will set fields on the Controller and
+// start it when the Manager is Started.
+func (c *ConnectionController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &Reconciler{
+		connecter: &providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: servicenetworking.NewClient,
+		},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerName).
+		For(&v1alpha1.Connection{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listConnections)).
+		Complete(r)
+}
+
+// Reconcile Google service networking connections with the GCP API.
+func (r *Reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	log.V(logging.Debug).Info("reconciling", "kind", v1alpha1.ConnectionKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	c := &v1alpha1.Connection{}
+	if err := r.kube.Get(ctx, req.NamespacedName, c); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get connection %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, c)
+	if err != nil {
+		c.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, c), "cannot update connection %s", req.NamespacedName)
+	}
+
+	// The connection has been deleted from the API server. Delete from GCP.
+	if c.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, c)}, errors.Wrapf(r.kube.Update(ctx, c), "cannot update connection %s", req.NamespacedName)
+	}
+
+	// The connection has no network. Assume it has not been created in GCP.
+	if c.Status.Network == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, c)}, errors.Wrapf(r.kube.Update(ctx, c), "cannot update connection %s", req.NamespacedName)
+	}
+
+	// The connection exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, c)}, errors.Wrapf(r.kube.Update(ctx, c), "cannot update connection %s", req.NamespacedName)
+}
+
+// listConnections is a provider.Lister of service networking connections.
+func listConnections(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.ConnectionList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,407 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package servicenetworking
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	servicenetworkingv1 "google.golang.org/api/servicenetworking/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/servicenetworking/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/servicenetworking"
+	fakeservicenetworking "github.com/crossplaneio/crossplane/pkg/clients/gcp/servicenetworking/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace      = "cool-namespace"
+	connectionName = "cool-connection"
+	project        = "coolProject"
+	network        = "cool-network"
+	rangeName      = "cool-range"
+	providerName   = "cool-gcp"
+	peering        = "servicenetworking-googleapis-com"
+)
+
+var (
+	ctx           = context.Background()
+	errorBoom     = errors.New("boom")
+	errorNotFound = &googleapi.Error{Code: http.StatusNotFound}
+	networkName   = servicenetworking.NetworkName(project, network)
+)
+
+// Test that our Reconciler implementation satisfies the Reconciler interface.
+var _ reconcile.Reconciler = &Reconciler{}
+
+type connectionModifier func(*v1alpha1.Connection)
+
+func withConditions(c ...corev1alpha1.Condition) connectionModifier {
+	return func(i *v1alpha1.Connection) { i.Status.SetConditions(c...) }
+}
+
+func withFinalizers(f ...string) connectionModifier {
+	return func(i *v1alpha1.Connection) { i.ObjectMeta.Finalizers = f }
+}
+
+func withReclaimPolicy(p corev1alpha1.ReclaimPolicy) connectionModifier {
+	return func(i *v1alpha1.Connection) { i.Spec.ReclaimPolicy = p }
+}
+
+func withStatusNetwork(n string) connectionModifier {
+	return func(i *v1alpha1.Connection) { i.Status.Network = n }
+}
+
+func withAllocatedRangeCreated() connectionModifier {
+	return func(i *v1alpha1.Connection) { i.Status.AllocatedRangeCreated = true }
+}
+
+func withPeering(p string, ranges ...string) connectionModifier {
+	return func(i *v1alpha1.Connection) {
+		i.Status.Peering = p
+		i.Status.ReservedPeeringRanges = ranges
+	}
+}
+
+func connection(cm ...connectionModifier) *v1alpha1.Connection {
+	c := &v1alpha1.Connection{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       connectionName,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.ConnectionSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			ConnectionParameters: v1alpha1.ConnectionParameters{
+				Network:        network,
+				AllocatedRange: v1alpha1.AllocatedRange{Name: rangeName, PrefixLength: 16},
+			},
+		},
+	}
+
+	for _, m := range cm {
+		m(c)
+	}
+
+	return c
+}
+
+func TestCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		c           *v1alpha1.Connection
+		want        *v1alpha1.Connection
+		wantRequeue bool
+	}{
+		{
+			name: "SuccessfulCreateAllocatingRange",
+			csd: &serviceNetworking{project: project, client: &fakeservicenetworking.MockClient{
+				MockGetAddress: func(_ context.Context, _, _ string) (*compute.Address, error) { return nil, errorNotFound },
+				MockInsertAddress: func(_ context.Context, _ string, a *compute.Address) error {
+					if a.Purpose != addressPurposePeering {
+						t.Errorf("a.Purpose: want %s, got %s", addressPurposePeering, a.Purpose)
+					}
+					return nil
+				},
+				MockCreateConnection: func(_ context.Context, _ *servicenetworkingv1.Connection) error { return nil },
+			}},
+			c: connection(),
+			want: connection(
+				withFinalizers(finalizerName),
+				withStatusNetwork(networkName),
+				withAllocatedRangeCreated(),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "SuccessfulCreateReusingRange",
+			csd: &serviceNetworking{project: project, client: &fakeservicenetworking.MockClient{
+				MockGetAddress:       func(_ context.Context, _, _ string) (*compute.Address, error) { return &compute.Address{}, nil },
+				MockCreateConnection: func(_ context.Context, _ *servicenetworkingv1.Connection) error { return nil },
+			}},
+			c: connection(),
+			want: connection(
+				withFinalizers(finalizerName),
+				withStatusNetwork(networkName),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedToAllocateRange",
+			csd: &serviceNetworking{project: project, client: &fakeservicenetworking.MockClient{
+				MockGetAddress:    func(_ context.Context, _, _ string) (*compute.Address, error) { return nil, errorNotFound },
+				MockInsertAddress: func(_ context.Context, _ string, _ *compute.Address) error { return errorBoom },
+			}},
+			c: connection(),
+			want: connection(
+				withFinalizers(finalizerName),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrapf(errorBoom, "cannot allocate range %s", rangeName))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedToCreateConnection",
+			csd: &serviceNetworking{project: project, client: &fakeservicenetworking.MockClient{
+				MockGetAddress:       func(_ context.Context, _, _ string) (*compute.Address, error) { return &compute.Address{}, nil },
+				MockCreateConnection: func(_ context.Context, _ *servicenetworkingv1.Connection) error { return errorBoom },
+			}},
+			c: connection(),
+			want: connection(
+				withFinalizers(finalizerName),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create connection"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.c)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.c, test.EquateConditions()); diff != "" {
+				t.Errorf("c: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		c           *v1alpha1.Connection
+		want        *v1alpha1.Connection
+		wantRequeue bool
+	}{
+		{
+			name: "ConnectionEstablished",
+			csd: &serviceNetworking{project: project, client: &fakeservicenetworking.MockClient{
+				MockListConnections: func(_ context.Context, _ string) ([]*servicenetworkingv1.Connection, error) {
+					return []*servicenetworkingv1.Connection{{
+						Service:               servicenetworking.Service(connection()),
+						Peering:               peering,
+						ReservedPeeringRanges: []string{rangeName},
+					}}, nil
+				},
+			}},
+			c: connection(withStatusNetwork(networkName)),
+			want: connection(
+				withStatusNetwork(networkName),
+				withPeering(peering, rangeName),
+				withConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ConnectionPending",
+			csd: &serviceNetworking{project: project, client: &fakeservicenetworking.MockClient{
+				MockListConnections: func(_ context.Context, _ string) ([]*servicenetworkingv1.Connection, error) {
+					return nil, nil
+				},
+			}},
+			c: connection(withStatusNetwork(networkName)),
+			want: connection(
+				withStatusNetwork(networkName),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedList",
+			csd: &serviceNetworking{project: project, client: &fakeservicenetworking.MockClient{
+				MockListConnections: func(_ context.Context, _ string) ([]*servicenetworkingv1.Connection, error) {
+					return nil, errorBoom
+				},
+			}},
+			c: connection(withStatusNetwork(networkName)),
+			want: connection(
+				withStatusNetwork(networkName),
+				withConditions(corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.c)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.c, test.EquateConditions()); diff != "" {
+				t.Errorf("c: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		c           *v1alpha1.Connection
+		want        *v1alpha1.Connection
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimRetain",
+			csd:  &serviceNetworking{project: project, client: &fakeservicenetworking.MockClient{}},
+			c:    connection(withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimRetain)),
+			want: connection(
+				withReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteAlreadyGone",
+			csd: &serviceNetworking{project: project, client: &fakeservicenetworking.MockClient{
+				MockDeleteConnection: func(_ context.Context, _, _ string) error { return errorNotFound },
+				MockDeleteAddress:    func(_ context.Context, _, _ string) error { return errorNotFound },
+			}},
+			c: connection(withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete), withAllocatedRangeCreated()),
+			want: connection(
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteKeepsExistingRange",
+			csd: &serviceNetworking{project: project, client: &fakeservicenetworking.MockClient{
+				MockDeleteConnection: func(_ context.Context, _, _ string) error { return nil },
+				MockDeleteAddress: func(_ context.Context, _, _ string) error {
+					t.Errorf("DeleteAddress(...): range not allocated for this connection was released")
+					return nil
+				},
+			}},
+			c: connection(withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: connection(
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimReleaseRangeFailed",
+			csd: &serviceNetworking{project: project, client: &fakeservicenetworking.MockClient{
+				MockDeleteConnection: func(_ context.Context, _, _ string) error { return nil },
+				MockDeleteAddress:    func(_ context.Context, _, _ string) error { return errorBoom },
+			}},
+			c: connection(withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete), withAllocatedRangeCreated()),
+			want: connection(
+				withFinalizers(finalizerName),
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withAllocatedRangeCreated(),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrapf(errorBoom, "cannot release range %s", rangeName))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "ReclaimDeleteFailed",
+			csd: &serviceNetworking{project: project, client: &fakeservicenetworking.MockClient{
+				MockDeleteConnection: func(_ context.Context, _, _ string) error { return errorBoom },
+			}},
+			c: connection(withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: connection(
+				withFinalizers(finalizerName),
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot delete connection"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.c)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.c, test.EquateConditions()); diff != "" {
+				t.Errorf("c: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestResolveConnection(t *testing.T) {
+	ref := &corev1.ObjectReference{Namespace: namespace, Name: connectionName}
+
+	cases := map[string]struct {
+		kube    client.Client
+		want    *v1alpha1.Connection
+		wantErr error
+	}{
+		"Available": {
+			kube: &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				*obj.(*v1alpha1.Connection) = *connection(withConditions(corev1alpha1.Available()))
+				return nil
+			}},
+			want: connection(withConditions(corev1alpha1.Available())),
+		},
+		"NotAvailable": {
+			kube: &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				*obj.(*v1alpha1.Connection) = *connection(withConditions(corev1alpha1.Creating()))
+				return nil
+			}},
+			wantErr: errors.Errorf("service networking connection %s/%s is not yet available", namespace, connectionName),
+		},
+		"GetFailed": {
+			kube: &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, _ runtime.Object) error {
+				return errorBoom
+			}},
+			wantErr: errors.Wrapf(errorBoom, "cannot get service networking connection %s/%s", namespace, connectionName),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ResolveConnection(ctx, tc.kube, ref)
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("ResolveConnection(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want, got, test.EquateConditions()); diff != "" {
+				t.Errorf("ResolveConnection(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package servicenetworking
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/servicenetworking/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+)
+
+// ResolveConnection returns the referenced Connection, or an error if it does
+// not exist or is not yet available. Managed resources that use private
+// services access (e.g. CloudSQL private IP and Memorystore) call this before
+// they are created, so that they wait for the peering they depend on.
+func ResolveConnection(ctx context.Context, kube client.Client, ref *corev1.ObjectReference) (*v1alpha1.Connection, error) {
+	c := &v1alpha1.Connection{}
+	n := meta.NamespacedNameOf(ref)
+	if err := kube.Get(ctx, n, c); err != nil {
+		return nil, errors.Wrapf(err, "cannot get service networking connection %s", n)
+	}
+
+	if c.Status.GetCondition(corev1alpha1.TypeReady).Status != corev1.ConditionTrue {
+		return nil, errors.Errorf("service networking connection %s is not yet available", n)
+	}
+
+	return c, nil
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shard
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+)
+
+// mapTimeout bounds the time spent reading the object of a request in order to
+// determine whether it is of the shard.
+const mapTimeout = 30 * time.Second
+
+// A shardClient is the client of a sharded manager. It reads every object,
+// but tells the event handlers that use it which objects are of its shard.
+type shardClient struct {
+	client.Client
+	filter *Filter
+}
+
+// NewClient returns a client that reads every object, and whose shard is
+// configured by the supplied options. It is the client of the managers
+// returned by NewManager, and may be used to test event handlers that call
+// Owns.
+func NewClient(kube client.Client, o Options) (client.Client, error) {
+	f, err := NewFilter(o)
+	if err != nil {
+		return nil, err
+	}
+	return &shardClient{Client: kube, filter: f}, nil
+}
+
+// Owns returns true if the supplied object belongs to the shard of the
+// supplied client, which is typically the client of the manager. Every object
+// belongs to the shard of a client that is not the client of a sharded
+// manager.
+//
+// Event handlers that map an event to requests for other objects, for example
+// for each managed resource that uses a Provider, must check that each object
+// is of their shard. Only the events of sharded objects are filtered before
+// they reach an event handler.
+func Owns(kube client.Reader, obj metav1.Object) bool {
+	c, ok := kube.(*shardClient)
+	if !ok {
+		return true
+	}
+	return c.filter.Owns(obj)
+}
+
+// FilterRequests returns an event handler that enqueues only the requests of
+// the supplied event handler that are for objects of the shard of the supplied
+// client. Each request is for an object of the kind returned by the supplied
+// function. Requests for objects that cannot be read are enqueued, such that
+// their reconciler handles them as usual.
+func FilterRequests(kube client.Reader, of func() runtime.Object, h handler.EventHandler) handler.EventHandler {
+	if _, ok := kube.(*shardClient); !ok {
+		return h
+	}
+	return &filteredHandler{kube: kube, of: of, handler: h}
+}
+
+type filteredHandler struct {
+	kube    client.Reader
+	of      func() runtime.Object
+	handler handler.EventHandler
+}
+
+func (h *filteredHandler) Create(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+	h.handler.Create(e, h.queue(q))
+}
+
+func (h *filteredHandler) Update(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	h.handler.Update(e, h.queue(q))
+}
+
+func (h *filteredHandler) Delete(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	h.handler.Delete(e, h.queue(q))
+}
+
+func (h *filteredHandler) Generic(e event.GenericEvent, q workqueue.RateLimitingInterface) {
+	h.handler.Generic(e, h.queue(q))
+}
+
+// InjectFunc injects dependencies into the wrapped event handler.
+func (h *filteredHandler) InjectFunc(f inject.Func) error {
+	return f(h.handler)
+}
+
+func (h *filteredHandler) queue(q workqueue.RateLimitingInterface) workqueue.RateLimitingInterface {
+	return &filteredQueue{RateLimitingInterface: q, owns: h.owns}
+}
+
+// owns returns true unless the object of the supplied request can be read and
+// is not of the shard.
+func (h *filteredHandler) owns(item interface{}) bool {
+	req, ok := item.(reconcile.Request)
+	if !ok {
+		return true
+	}
+	obj := h.of()
+	ctx, cancel := context.WithTimeout(context.Background(), mapTimeout)
+	defer cancel()
+	if err := h.kube.Get(ctx, req.NamespacedName, obj); err != nil {
+		return true
+	}
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return true
+	}
+	return Owns(h.kube, m)
+}
+
+// A filteredQueue drops the items that its owns function rejects.
+type filteredQueue struct {
+	workqueue.RateLimitingInterface
+	owns func(item interface{}) bool
+}
+
+func (q *filteredQueue) Add(item interface{}) {
+	if q.owns(item) {
+		q.RateLimitingInterface.Add(item)
+	}
+}
+
+func (q *filteredQueue) AddAfter(item interface{}, d time.Duration) {
+	if q.owns(item) {
+		q.RateLimitingInterface.AddAfter(item, d)
+	}
+}
+
+func (q *filteredQueue) AddRateLimited(item interface{}) {
+	if q.owns(item) {
+		q.RateLimitingInterface.AddRateLimited(item)
+	}
+}
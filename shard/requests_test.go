@@ -0,0 +1,94 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shard
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestFilterRequests(t *testing.T) {
+	f, err := NewFilter(Options{Selector: "team=cool"})
+	if err != nil {
+		t.Fatalf("NewFilter(...): %s", err)
+	}
+
+	cool := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "cool", Labels: map[string]string{"team": "cool"}}}
+	uncool := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "uncool"}}
+	kube := fake.NewFakeClient(cool, uncool)
+
+	// Request both objects, and one that does not exist.
+	all := &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(_ handler.MapObject) []reconcile.Request {
+			return []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "cool"}},
+				{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "uncool"}},
+				{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "missing"}},
+			}
+		}),
+	}
+	of := func() runtime.Object { return &corev1.ConfigMap{} }
+
+	cases := map[string]struct {
+		kube    *shardClient
+		wantLen int
+	}{
+		"Sharded":   {kube: &shardClient{Client: kube, filter: f}, wantLen: 2},
+		"Unsharded": {wantLen: 3},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := FilterRequests(kube, of, all)
+			if tc.kube != nil {
+				h = FilterRequests(tc.kube, of, all)
+			}
+
+			q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+			defer q.ShutDown()
+			h.Generic(event.GenericEvent{Meta: cool, Object: cool}, q)
+
+			if q.Len() != tc.wantLen {
+				t.Errorf("h.Generic(...): want %d requests, got %d", tc.wantLen, q.Len())
+			}
+		})
+	}
+}
+
+func TestOwnsClient(t *testing.T) {
+	f, err := NewFilter(Options{Selector: "team=cool"})
+	if err != nil {
+		t.Fatalf("NewFilter(...): %s", err)
+	}
+	uncool := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "uncool"}}
+
+	if Owns(&shardClient{Client: fake.NewFakeClient(), filter: f}, uncool) {
+		t.Errorf("Owns(...): want object not owned by a sharded client")
+	}
+	if !Owns(fake.NewFakeClient(), uncool) {
+		t.Errorf("Owns(...): want object owned by an unsharded client")
+	}
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package shard splits the managed resources and resource claims of an
+// installation among several provider deployments, so that installations
+// managing thousands of GCP resources may scale out horizontally. Each
+// deployment reconciles only the resources of its shard, which is selected by
+// label, by a hash of their namespace, or both.
+package shard
+
+import (
+	"hash/fnv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	toolscache "k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+)
+
+// shardedGroupSuffix is the suffix of the API groups of the managed resources
+// and resource claims that are split among shards. Anything else, such as the
+// connection secrets and Providers that resources reference, is watched by
+// every shard.
+const shardedGroupSuffix = ".crossplane.io"
+
+// kindProvider is the kind of the Providers that managed resources reference.
+// Every shard must see every Provider.
+const kindProvider = "Provider"
+
+// Options configure which managed resources and resource claims a provider
+// deployment reconciles.
+type Options struct {
+	// Selector is a label selector, e.g. team=payments. Only resources whose
+	// labels match it are reconciled, if it is not empty.
+	Selector string
+
+	// Shards is the number of shards among which resources are split by a
+	// hash of their namespace. Resources are not split by namespace if it
+	// is less than two. Cluster scoped resources belong to a single shard.
+	Shards int
+
+	// Index is the shard, from zero to Shards - 1, that is reconciled.
+	Index int
+}
+
+// Enabled returns true if the options split resources among shards.
+func (o Options) Enabled() bool {
+	return o.Selector != "" || o.Shards > 1
+}
+
+// A Filter determines which objects belong to a shard.
+type Filter struct {
+	selector labels.Selector
+	shards   uint32
+	index    uint32
+}
+
+// NewFilter returns a Filter that selects the objects of the shard configured
+// by the supplied options.
+func NewFilter(o Options) (*Filter, error) {
+	f := &Filter{selector: labels.Everything(), shards: 1}
+
+	if o.Selector != "" {
+		s, err := labels.Parse(o.Selector)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse shard selector %q", o.Selector)
+		}
+		f.selector = s
+	}
+
+	if o.Shards > 1 {
+		if o.Index < 0 || o.Index >= o.Shards {
+			return nil, errors.Errorf("shard index %d must be between 0 and %d", o.Index, o.Shards-1)
+		}
+		f.shards, f.index = uint32(o.Shards), uint32(o.Index)
+	}
+
+	return f, nil
+}
+
+// Owns returns true if the supplied object belongs to the filter's shard.
+func (f *Filter) Owns(obj metav1.Object) bool {
+	if !f.selector.Matches(labels.Set(obj.GetLabels())) {
+		return false
+	}
+	return namespaceShard(obj.GetNamespace(), f.shards) == f.index
+}
+
+// owns adapts Owns to the objects delivered to informer event handlers,
+// which may be tombstones of deleted objects.
+func (f *Filter) owns(obj interface{}) bool {
+	if d, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = d.Obj
+	}
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+	return f.Owns(m)
+}
+
+func namespaceShard(namespace string, shards uint32) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(namespace))
+	return h.Sum32() % shards
+}
+
+// sharded returns true if objects of the supplied kind are split among
+// shards.
+func sharded(gvk schema.GroupVersionKind) bool {
+	return strings.HasSuffix(gvk.Group, shardedGroupSuffix) && gvk.Kind != kindProvider
+}
+
+// NewManager returns a manager that wraps the supplied manager such that the
+// controllers added to it are notified only of events concerning the
+// resources of the shard configured by the supplied options. Resources that
+// are not of their shard are still read from the manager's cache. Event
+// handlers that map events to requests for other resources must filter them
+// using Owns or FilterRequests and the manager's client.
+func NewManager(mgr ctrl.Manager, o Options) (ctrl.Manager, error) {
+	f, err := NewFilter(o)
+	if err != nil {
+		return nil, err
+	}
+	c := &shardCache{Cache: mgr.GetCache(), scheme: mgr.GetScheme(), filter: f}
+	return &manager{Manager: mgr, cache: c, client: &shardClient{Client: mgr.GetClient(), filter: f}}, nil
+}
+
+type manager struct {
+	ctrl.Manager
+	cache  *shardCache
+	client *shardClient
+}
+
+func (m *manager) GetCache() cache.Cache {
+	return m.cache
+}
+
+func (m *manager) GetClient() client.Client {
+	return m.client
+}
+
+// SetFields injects the sharded cache into the sources that controllers
+// watch, in place of the wrapped manager's cache.
+func (m *manager) SetFields(i interface{}) error {
+	if err := m.Manager.SetFields(i); err != nil {
+		return err
+	}
+	_, err := inject.CacheInto(m.cache, i)
+	return err
+}
+
+type shardCache struct {
+	cache.Cache
+	scheme *runtime.Scheme
+	filter *Filter
+}
+
+func (c *shardCache) GetInformer(obj runtime.Object) (toolscache.SharedIndexInformer, error) {
+	gvk, err := apiutil.GVKForObject(obj, c.scheme)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetInformerForKind(gvk)
+}
+
+func (c *shardCache) GetInformerForKind(gvk schema.GroupVersionKind) (toolscache.SharedIndexInformer, error) {
+	i, err := c.Cache.GetInformerForKind(gvk)
+	if err != nil || !sharded(gvk) {
+		return i, err
+	}
+	return &informer{SharedIndexInformer: i, filter: c.filter}, nil
+}
+
+// An informer delivers only the events of objects in its filter's shard. An
+// object whose labels change such that it joins or leaves the shard is
+// delivered as added or deleted.
+type informer struct {
+	toolscache.SharedIndexInformer
+	filter *Filter
+}
+
+func (i *informer) AddEventHandler(h toolscache.ResourceEventHandler) {
+	i.SharedIndexInformer.AddEventHandler(toolscache.FilteringResourceEventHandler{FilterFunc: i.filter.owns, Handler: h})
+}
+
+func (i *informer) AddEventHandlerWithResyncPeriod(h toolscache.ResourceEventHandler, period time.Duration) {
+	i.SharedIndexInformer.AddEventHandlerWithResyncPeriod(toolscache.FilteringResourceEventHandler{FilterFunc: i.filter.owns, Handler: h}, period)
+}
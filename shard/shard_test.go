@@ -0,0 +1,168 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shard
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+func TestNewFilter(t *testing.T) {
+	cases := map[string]struct {
+		o       Options
+		wantErr bool
+	}{
+		"Disabled":         {o: Options{}},
+		"Selector":         {o: Options{Selector: "team=cool,env in (prod, staging)"}},
+		"InvalidSelector":  {o: Options{Selector: "team=="}, wantErr: true},
+		"Namespace":        {o: Options{Shards: 3, Index: 2}},
+		"IndexOutOfRange":  {o: Options{Shards: 3, Index: 3}, wantErr: true},
+		"NegativeIndex":    {o: Options{Shards: 3, Index: -1}, wantErr: true},
+		"SingleShardIndex": {o: Options{Shards: 1, Index: 5}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewFilter(tc.o)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("NewFilter(...): want error %t, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestOwns(t *testing.T) {
+	obj := func(namespace string, labels map[string]string) metav1.Object {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Labels: labels}}
+	}
+
+	cases := map[string]struct {
+		o    Options
+		obj  metav1.Object
+		want bool
+	}{
+		"Disabled": {
+			o:    Options{},
+			obj:  obj("cool", nil),
+			want: true,
+		},
+		"SelectorMatches": {
+			o:    Options{Selector: "team=cool"},
+			obj:  obj("cool", map[string]string{"team": "cool"}),
+			want: true,
+		},
+		"SelectorDoesNotMatch": {
+			o:    Options{Selector: "team=cool"},
+			obj:  obj("cool", map[string]string{"team": "uncool"}),
+			want: false,
+		},
+		"NamespaceInShard": {
+			o:    Options{Shards: 4, Index: int(namespaceShard("cool", 4))},
+			obj:  obj("cool", nil),
+			want: true,
+		},
+		"NamespaceNotInShard": {
+			o:    Options{Shards: 4, Index: int((namespaceShard("cool", 4) + 1) % 4)},
+			obj:  obj("cool", nil),
+			want: false,
+		},
+		"SelectorMatchesNamespaceNotInShard": {
+			o:    Options{Selector: "team=cool", Shards: 4, Index: int((namespaceShard("cool", 4) + 1) % 4)},
+			obj:  obj("cool", map[string]string{"team": "cool"}),
+			want: false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			f, err := NewFilter(tc.o)
+			if err != nil {
+				t.Fatalf("NewFilter(...): %s", err)
+			}
+			if got := f.Owns(tc.obj); got != tc.want {
+				t.Errorf("f.Owns(...): want %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSharded(t *testing.T) {
+	cases := map[string]struct {
+		gvk  schema.GroupVersionKind
+		want bool
+	}{
+		"ManagedResource": {gvk: schema.GroupVersionKind{Group: "database.gcp.crossplane.io", Kind: "CloudsqlInstance"}, want: true},
+		"Claim":           {gvk: schema.GroupVersionKind{Group: "database.crossplane.io", Kind: "MySQLInstance"}, want: true},
+		"Provider":        {gvk: schema.GroupVersionKind{Group: "gcp.crossplane.io", Kind: "Provider"}, want: false},
+		"Secret":          {gvk: schema.GroupVersionKind{Kind: "Secret"}, want: false},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := sharded(tc.gvk); got != tc.want {
+				t.Errorf("sharded(%s): want %t, got %t", tc.gvk, tc.want, got)
+			}
+		})
+	}
+}
+
+type handlerInformer struct {
+	toolscache.SharedIndexInformer
+	handler toolscache.ResourceEventHandler
+}
+
+func (i *handlerInformer) AddEventHandler(h toolscache.ResourceEventHandler) {
+	i.handler = h
+}
+
+func TestInformer(t *testing.T) {
+	f, err := NewFilter(Options{Selector: "team=cool"})
+	if err != nil {
+		t.Fatalf("NewFilter(...): %s", err)
+	}
+
+	inner := &handlerInformer{}
+	i := &informer{SharedIndexInformer: inner, filter: f}
+
+	var added, deleted []string
+	i.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { added = append(added, obj.(*corev1.ConfigMap).GetName()) },
+		DeleteFunc: func(obj interface{}) { deleted = append(deleted, obj.(*corev1.ConfigMap).GetName()) },
+	})
+
+	cool := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cool", Labels: map[string]string{"team": "cool"}}}
+	uncool := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "uncool"}}
+
+	inner.handler.OnAdd(cool)
+	inner.handler.OnAdd(uncool)
+
+	// An object whose labels no longer match leaves the shard.
+	left := cool.DeepCopy()
+	left.Labels = nil
+	inner.handler.OnUpdate(cool, left)
+
+	if len(added) != 1 || added[0] != "cool" {
+		t.Errorf("added: want [cool], got %v", added)
+	}
+	if len(deleted) != 1 || deleted[0] != "cool" {
+		t.Errorf("deleted: want [cool], got %v", deleted)
+	}
+}
@@ -18,12 +18,10 @@ package storage
 
 import (
 	"context"
-	"reflect"
 	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/pkg/errors"
-	"golang.org/x/oauth2/google"
 	"google.golang.org/api/option"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
@@ -31,13 +29,14 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
 	"github.com/crossplaneio/crossplane/gcp/apis/storage/v1alpha1"
-	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
 	gcpstorage "github.com/crossplaneio/crossplane/pkg/clients/gcp/storage"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
 	"github.com/crossplaneio/crossplane/pkg/logging"
-	"github.com/crossplaneio/crossplane/pkg/meta"
 )
 
 const (
@@ -63,19 +62,24 @@ type Reconciler struct {
 
 // BucketController is responsible for adding the Bucket controller and its
 // corresponding reconciler to the manager with any runtime configuration.
-type BucketController struct{}
+type BucketController struct {
+	// DefaultProvider is used by buckets that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
 
 // SetupWithManager creates a newSyncDeleter Controller and adds it to the Manager with default RBAC.
 // The Manager will set fields on the Controller and Start it when the Manager is Started.
 func (c *BucketController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
 	r := &Reconciler{
 		Client:  mgr.GetClient(),
-		factory: &bucketFactory{mgr.GetClient()},
+		factory: &bucketFactory{Client: mgr.GetClient(), providers: providers},
 	}
 
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(controllerName).
 		For(&v1alpha1.Bucket{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listBuckets)).
 		Owns(&corev1.Secret{}).
 		Complete(r)
 }
@@ -116,23 +120,18 @@ type factory interface {
 
 type bucketFactory struct {
 	client.Client
+	providers provider.Resolver
 }
 
 func (m *bucketFactory) newSyncDeleter(ctx context.Context, b *v1alpha1.Bucket) (syncdeleter, error) {
-	p := &gcpv1alpha1.Provider{}
-	if err := m.Get(ctx, meta.NamespacedNameOf(b.Spec.ProviderReference), p); err != nil {
+	p, err := m.providers.Get(ctx, m, b, b.Spec.ProviderReference)
+	if err != nil {
 		return nil, err
 	}
 
-	s := &corev1.Secret{}
-	n := types.NamespacedName{Namespace: p.GetNamespace(), Name: p.Spec.Secret.Name}
-	if err := m.Get(ctx, n, s); err != nil {
-		return nil, errors.Wrapf(err, "cannot get provider's secret %s", n)
-	}
-
-	creds, err := google.CredentialsFromJSON(context.Background(), s.Data[p.Spec.Secret.Key], storage.ScopeFullControl)
+	creds, err := provider.ServiceCredentials(ctx, m, p, provider.ServiceStorage, storage.ScopeFullControl)
 	if err != nil {
-		return nil, errors.Wrapf(err, "cannot retrieve creds from json")
+		return nil, err
 	}
 
 	sc, err := storage.NewClient(ctx, option.WithCredentials(creds))
@@ -258,7 +257,7 @@ func (bh *bucketCreateUpdater) create(ctx context.Context) (reconcile.Result, er
 // update bucket resource if needed
 func (bh *bucketCreateUpdater) update(ctx context.Context, attrs *storage.BucketAttrs) (reconcile.Result, error) {
 	current := v1alpha1.NewBucketUpdatableAttrs(attrs)
-	if reflect.DeepEqual(*current, bh.getSpecAttrs()) {
+	if compare.Equal(bh.getSpecAttrs(), *current) {
 		return requeueOnSuccess, nil
 	}
 
@@ -277,3 +276,16 @@ func (bh *bucketCreateUpdater) update(ctx context.Context, attrs *storage.Bucket
 	bh.setStatusConditions(corev1alpha1.ReconcileSuccess())
 	return requeueOnSuccess, bh.updateStatus(ctx)
 }
+
+// listBuckets is a provider.Lister of buckets.
+func listBuckets(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.BucketList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,305 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/storage/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	notificationControllerName = "bucketnotifications.storage.gcp.crossplane.io"
+	notificationFinalizer      = "finalizer." + notificationControllerName
+
+	notificationReconcileTimeout = 1 * time.Minute
+)
+
+var notificationLog = logging.Logger.WithName("controller." + notificationControllerName)
+
+// A bucketNotifications can add, list, and delete the Pub/Sub notification
+// configurations of a GCS bucket. It is satisfied by *storage.BucketHandle.
+type bucketNotifications interface {
+	AddNotification(ctx context.Context, n *storage.Notification) (*storage.Notification, error)
+	Notifications(ctx context.Context) (map[string]*storage.Notification, error)
+	DeleteNotification(ctx context.Context, id string) error
+}
+
+// A notificationCreateSyncDeleter can create, sync, and delete bucket
+// notifications in an external store - e.g. the GCP API. Each method returns
+// true if the notification requires further reconciliation.
+type notificationCreateSyncDeleter interface {
+	Create(ctx context.Context, n *v1alpha1.BucketNotification) (requeue bool)
+	Sync(ctx context.Context, n *v1alpha1.BucketNotification) (requeue bool)
+	Delete(ctx context.Context, n *v1alpha1.BucketNotification) (requeue bool)
+}
+
+// notifications is a notificationCreateSyncDeleter using the GCS JSON API.
+type notifications struct {
+	kube          client.Client
+	project       string
+	notifications func(bucket string) bucketNotifications
+}
+
+// Create adds a notification configuration to the bucket. Note that the
+// bucket's GCS service agent must be allowed to publish to the topic.
+func (c *notifications) Create(ctx context.Context, n *v1alpha1.BucketNotification) bool {
+	n.Status.SetConditions(corev1alpha1.Creating())
+	meta.AddFinalizer(n, notificationFinalizer)
+
+	name, err := bucketName(ctx, c.kube, n.GetNamespace(), n.Spec.BucketName, n.Spec.BucketRef)
+	if err != nil {
+		n.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	created, err := c.notifications(name).AddNotification(ctx, newNotification(c.project, n.Spec))
+	if err != nil {
+		n.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot add notification to bucket %s", name)))
+		return true
+	}
+
+	n.Status.BucketName = name
+	n.Status.NotificationID = created.ID
+	n.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync reports whether the notification configuration exists. Notification
+// configurations cannot be updated, so a configuration that differs from its
+// spec is deleted in order that it be added again.
+func (c *notifications) Sync(ctx context.Context, n *v1alpha1.BucketNotification) bool {
+	h := c.notifications(n.Status.BucketName)
+	all, err := h.Notifications(ctx)
+	if err != nil {
+		n.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot list notifications of bucket %s", n.Status.BucketName)))
+		return true
+	}
+
+	actual, ok := all[n.Status.NotificationID]
+	if !ok {
+		// The notification was removed outside of Crossplane. Add it again.
+		n.Status.SetConditions(corev1alpha1.ReconcileError(errors.Errorf("notification %s of bucket %s not found", n.Status.NotificationID, n.Status.BucketName)))
+		n.Status.NotificationID = ""
+		return true
+	}
+
+	if !notificationUpToDate(newNotification(c.project, n.Spec), actual) {
+		if err := h.DeleteNotification(ctx, n.Status.NotificationID); err != nil && !googleapi.IsErrorNotFound(err) {
+			n.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot delete notification %s of bucket %s", n.Status.NotificationID, n.Status.BucketName)))
+			return true
+		}
+		n.Status.NotificationID = ""
+		n.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	n.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete removes the notification configuration from the bucket.
+func (c *notifications) Delete(ctx context.Context, n *v1alpha1.BucketNotification) bool {
+	n.Status.SetConditions(corev1alpha1.Deleting())
+
+	if n.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete && n.Status.NotificationID != "" {
+		err := c.notifications(n.Status.BucketName).DeleteNotification(ctx, n.Status.NotificationID)
+		if err != nil && !googleapi.IsErrorNotFound(err) && err != storage.ErrBucketNotExist {
+			n.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot delete notification %s of bucket %s", n.Status.NotificationID, n.Status.BucketName)))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(n, notificationFinalizer)
+	n.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// newNotification returns a notification configuration described by the
+// supplied spec. The topic may be a name within the supplied project or a
+// relative path such as projects/p/topics/t.
+func newNotification(project string, spec v1alpha1.BucketNotificationSpec) *storage.Notification {
+	topicProject, topic := project, spec.Topic
+	if parts := strings.Split(spec.Topic, "/"); len(parts) == 4 && parts[0] == "projects" && parts[2] == "topics" {
+		topicProject, topic = parts[1], parts[3]
+	}
+
+	payload := spec.PayloadFormat
+	if payload == "" {
+		payload = storage.JSONPayload
+	}
+
+	return &storage.Notification{
+		TopicProjectID:   topicProject,
+		TopicID:          topic,
+		EventTypes:       spec.EventTypes,
+		ObjectNamePrefix: spec.ObjectNamePrefix,
+		CustomAttributes: spec.CustomAttributes,
+		PayloadFormat:    payload,
+	}
+}
+
+// notificationUpToDate returns true if the actual notification configuration
+// matches the desired one. An empty set of event types or custom attributes
+// matches a nil one.
+func notificationUpToDate(desired, actual *storage.Notification) bool {
+	switch {
+	case desired.TopicProjectID != actual.TopicProjectID,
+		desired.TopicID != actual.TopicID,
+		desired.ObjectNamePrefix != actual.ObjectNamePrefix,
+		desired.PayloadFormat != actual.PayloadFormat:
+		return false
+	case len(desired.EventTypes) != len(actual.EventTypes),
+		len(desired.EventTypes) > 0 && !reflect.DeepEqual(desired.EventTypes, actual.EventTypes):
+		return false
+	case len(desired.CustomAttributes) != len(actual.CustomAttributes),
+		len(desired.CustomAttributes) > 0 && !reflect.DeepEqual(desired.CustomAttributes, actual.CustomAttributes):
+		return false
+	}
+	return true
+}
+
+// A notificationConnecter returns a notificationCreateSyncDeleter that can
+// create, sync, and delete bucket notifications with an external store - for
+// example the GCP API.
+type notificationConnecter interface {
+	Connect(context.Context, *v1alpha1.BucketNotification) (notificationCreateSyncDeleter, error)
+}
+
+// notificationProviderConnecter is a notificationConnecter that returns a
+// notificationCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type notificationProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns a notificationCreateSyncDeleter backed by the GCS JSON API.
+// GCP credentials are read from the Crossplane Provider referenced by the
+// supplied BucketNotification.
+func (c *notificationProviderConnecter) Connect(ctx context.Context, n *v1alpha1.BucketNotification) (notificationCreateSyncDeleter, error) {
+	sc, p, err := c.connect(ctx, n, n.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &notifications{
+		kube:          c.kube,
+		project:       p.Spec.ProjectID,
+		notifications: func(bucket string) bucketNotifications { return sc.Bucket(bucket) },
+	}, nil
+}
+
+// BucketNotificationReconciler reconciles BucketNotifications read from the
+// Kubernetes API with an external store, typically the GCP API.
+type BucketNotificationReconciler struct {
+	notificationConnecter
+	kube client.Client
+}
+
+// BucketNotificationController is responsible for adding the
+// BucketNotification controller and its corresponding reconciler to the
+// manager with any runtime configuration.
+type BucketNotificationController struct {
+	// DefaultProvider is used by notifications that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new BucketNotification Controller and adds it to
+// the Manager with default RBAC. The Manager will set fields on the Controller
+// and start it when the Manager is Started.
+func (c *BucketNotificationController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &BucketNotificationReconciler{
+		notificationConnecter: &notificationProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(notificationControllerName).
+		For(&v1alpha1.BucketNotification{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listNotifications)).
+		Complete(r)
+}
+
+// Reconcile bucket notifications with the GCP API.
+func (r *BucketNotificationReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	notificationLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.BucketNotificationKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), notificationReconcileTimeout)
+	defer cancel()
+
+	n := &v1alpha1.BucketNotification{}
+	if err := r.kube.Get(ctx, req.NamespacedName, n); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get bucket notification %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, n)
+	if err != nil {
+		n.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, n), "cannot update bucket notification %s", req.NamespacedName)
+	}
+
+	// The notification has been deleted from the API server. Remove it from
+	// GCP.
+	if n.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, n)}, errors.Wrapf(r.kube.Update(ctx, n), "cannot update bucket notification %s", req.NamespacedName)
+	}
+
+	// The notification has no ID. Assume it has not been created in GCP.
+	if n.Status.NotificationID == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, n)}, errors.Wrapf(r.kube.Update(ctx, n), "cannot update bucket notification %s", req.NamespacedName)
+	}
+
+	// The notification exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, n)}, errors.Wrapf(r.kube.Update(ctx, n), "cannot update bucket notification %s", req.NamespacedName)
+}
+
+// listNotifications is a provider.Lister of bucket notifications.
+func listNotifications(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.BucketNotificationList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,386 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/storage/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	notificationProject = "cool-project"
+	notificationBucket  = "cool-bucket"
+	notificationTopic   = "cool-topic"
+	notificationID      = "7"
+)
+
+var errNotificationBoom = errors.New("boom")
+
+// Test that our Reconciler implementation satisfies the Reconciler interface.
+var _ reconcile.Reconciler = &BucketNotificationReconciler{}
+
+type mockBucketNotifications struct {
+	MockAddNotification    func(ctx context.Context, n *storage.Notification) (*storage.Notification, error)
+	MockNotifications      func(ctx context.Context) (map[string]*storage.Notification, error)
+	MockDeleteNotification func(ctx context.Context, id string) error
+}
+
+func (m *mockBucketNotifications) AddNotification(ctx context.Context, n *storage.Notification) (*storage.Notification, error) {
+	return m.MockAddNotification(ctx, n)
+}
+
+func (m *mockBucketNotifications) Notifications(ctx context.Context) (map[string]*storage.Notification, error) {
+	return m.MockNotifications(ctx)
+}
+
+func (m *mockBucketNotifications) DeleteNotification(ctx context.Context, id string) error {
+	return m.MockDeleteNotification(ctx, id)
+}
+
+type notificationModifier func(*v1alpha1.BucketNotification)
+
+func withNotificationConditions(c ...corev1alpha1.Condition) notificationModifier {
+	return func(n *v1alpha1.BucketNotification) { n.Status.SetConditions(c...) }
+}
+
+func withNotificationFinalizers(f ...string) notificationModifier {
+	return func(n *v1alpha1.BucketNotification) { n.ObjectMeta.Finalizers = f }
+}
+
+func withNotificationReclaimPolicy(r corev1alpha1.ReclaimPolicy) notificationModifier {
+	return func(n *v1alpha1.BucketNotification) { n.Spec.ReclaimPolicy = r }
+}
+
+func withNotificationStatus(bucket, id string) notificationModifier {
+	return func(n *v1alpha1.BucketNotification) {
+		n.Status.BucketName = bucket
+		n.Status.NotificationID = id
+	}
+}
+
+func bucketNotification(nm ...notificationModifier) *v1alpha1.BucketNotification {
+	n := &v1alpha1.BucketNotification{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  testNamespace,
+			Name:       "cool-notification",
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.BucketNotificationSpec{
+			BucketName: notificationBucket,
+			Topic:      notificationTopic,
+			EventTypes: []string{storage.ObjectFinalizeEvent},
+		},
+	}
+
+	for _, m := range nm {
+		m(n)
+	}
+
+	return n
+}
+
+func notification(id string) *storage.Notification {
+	return &storage.Notification{
+		ID:             id,
+		TopicProjectID: notificationProject,
+		TopicID:        notificationTopic,
+		EventTypes:     []string{storage.ObjectFinalizeEvent},
+		PayloadFormat:  storage.JSONPayload,
+	}
+}
+
+func TestBucketNotificationCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		client      *mockBucketNotifications
+		n           *v1alpha1.BucketNotification
+		want        *v1alpha1.BucketNotification
+		wantRequeue bool
+	}{
+		{
+			name: "SuccessfulCreate",
+			client: &mockBucketNotifications{
+				MockAddNotification: func(_ context.Context, n *storage.Notification) (*storage.Notification, error) {
+					if diff := cmp.Diff(notification(""), n); diff != "" {
+						t.Errorf("AddNotification(...): -want, +got:\n%s", diff)
+					}
+					return notification(notificationID), nil
+				},
+			},
+			n: bucketNotification(),
+			want: bucketNotification(
+				withNotificationFinalizers(notificationFinalizer),
+				withNotificationStatus(notificationBucket, notificationID),
+				withNotificationConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedCreate",
+			client: &mockBucketNotifications{
+				MockAddNotification: func(_ context.Context, _ *storage.Notification) (*storage.Notification, error) {
+					return nil, errNotificationBoom
+				},
+			},
+			n: bucketNotification(),
+			want: bucketNotification(
+				withNotificationFinalizers(notificationFinalizer),
+				withNotificationConditions(
+					corev1alpha1.Creating(),
+					corev1alpha1.ReconcileError(errors.Wrapf(errNotificationBoom, "cannot add notification to bucket %s", notificationBucket)),
+				),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			csd := &notifications{project: notificationProject, notifications: func(_ string) bucketNotifications { return tc.client }}
+			gotRequeue := csd.Create(context.Background(), tc.n)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.n, test.EquateConditions()); diff != "" {
+				t.Errorf("csd.Create(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBucketNotificationSync(t *testing.T) {
+	cases := []struct {
+		name        string
+		client      *mockBucketNotifications
+		n           *v1alpha1.BucketNotification
+		want        *v1alpha1.BucketNotification
+		wantRequeue bool
+	}{
+		{
+			name: "NotificationUpToDate",
+			client: &mockBucketNotifications{
+				MockNotifications: func(_ context.Context) (map[string]*storage.Notification, error) {
+					return map[string]*storage.Notification{notificationID: notification(notificationID)}, nil
+				},
+			},
+			n: bucketNotification(withNotificationStatus(notificationBucket, notificationID)),
+			want: bucketNotification(
+				withNotificationStatus(notificationBucket, notificationID),
+				withNotificationConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "NotificationNotFound",
+			client: &mockBucketNotifications{
+				MockNotifications: func(_ context.Context) (map[string]*storage.Notification, error) {
+					return map[string]*storage.Notification{}, nil
+				},
+			},
+			n: bucketNotification(withNotificationStatus(notificationBucket, notificationID)),
+			want: bucketNotification(
+				withNotificationStatus(notificationBucket, ""),
+				withNotificationConditions(corev1alpha1.ReconcileError(errors.Errorf("notification %s of bucket %s not found", notificationID, notificationBucket))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "NotificationOutdated",
+			client: &mockBucketNotifications{
+				MockNotifications: func(_ context.Context) (map[string]*storage.Notification, error) {
+					n := notification(notificationID)
+					n.EventTypes = []string{storage.ObjectDeleteEvent}
+					return map[string]*storage.Notification{notificationID: n}, nil
+				},
+				MockDeleteNotification: func(_ context.Context, id string) error {
+					if id != notificationID {
+						t.Errorf("DeleteNotification(...): want %s, got %s", notificationID, id)
+					}
+					return nil
+				},
+			},
+			n: bucketNotification(withNotificationStatus(notificationBucket, notificationID)),
+			want: bucketNotification(
+				withNotificationStatus(notificationBucket, ""),
+				withNotificationConditions(corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedListNotifications",
+			client: &mockBucketNotifications{
+				MockNotifications: func(_ context.Context) (map[string]*storage.Notification, error) {
+					return nil, errNotificationBoom
+				},
+			},
+			n: bucketNotification(withNotificationStatus(notificationBucket, notificationID)),
+			want: bucketNotification(
+				withNotificationStatus(notificationBucket, notificationID),
+				withNotificationConditions(corev1alpha1.ReconcileError(errors.Wrapf(errNotificationBoom, "cannot list notifications of bucket %s", notificationBucket))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			csd := &notifications{project: notificationProject, notifications: func(_ string) bucketNotifications { return tc.client }}
+			gotRequeue := csd.Sync(context.Background(), tc.n)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.n, test.EquateConditions()); diff != "" {
+				t.Errorf("csd.Sync(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBucketNotificationDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		client      *mockBucketNotifications
+		n           *v1alpha1.BucketNotification
+		want        *v1alpha1.BucketNotification
+		wantRequeue bool
+	}{
+		{
+			name:   "ReclaimRetain",
+			client: &mockBucketNotifications{},
+			n: bucketNotification(
+				withNotificationReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withNotificationFinalizers(notificationFinalizer),
+				withNotificationStatus(notificationBucket, notificationID),
+			),
+			want: bucketNotification(
+				withNotificationReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withNotificationStatus(notificationBucket, notificationID),
+				withNotificationConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteSuccessful",
+			client: &mockBucketNotifications{
+				MockDeleteNotification: func(_ context.Context, _ string) error { return nil },
+			},
+			n: bucketNotification(
+				withNotificationReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withNotificationFinalizers(notificationFinalizer),
+				withNotificationStatus(notificationBucket, notificationID),
+			),
+			want: bucketNotification(
+				withNotificationReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withNotificationStatus(notificationBucket, notificationID),
+				withNotificationConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteBucketNotFound",
+			client: &mockBucketNotifications{
+				MockDeleteNotification: func(_ context.Context, _ string) error { return storage.ErrBucketNotExist },
+			},
+			n: bucketNotification(
+				withNotificationReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withNotificationFinalizers(notificationFinalizer),
+				withNotificationStatus(notificationBucket, notificationID),
+			),
+			want: bucketNotification(
+				withNotificationReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withNotificationStatus(notificationBucket, notificationID),
+				withNotificationConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailed",
+			client: &mockBucketNotifications{
+				MockDeleteNotification: func(_ context.Context, _ string) error { return errNotificationBoom },
+			},
+			n: bucketNotification(
+				withNotificationReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withNotificationFinalizers(notificationFinalizer),
+				withNotificationStatus(notificationBucket, notificationID),
+			),
+			want: bucketNotification(
+				withNotificationReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withNotificationFinalizers(notificationFinalizer),
+				withNotificationStatus(notificationBucket, notificationID),
+				withNotificationConditions(
+					corev1alpha1.Deleting(),
+					corev1alpha1.ReconcileError(errors.Wrapf(errNotificationBoom, "cannot delete notification %s of bucket %s", notificationID, notificationBucket)),
+				),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			csd := &notifications{project: notificationProject, notifications: func(_ string) bucketNotifications { return tc.client }}
+			gotRequeue := csd.Delete(context.Background(), tc.n)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.n, test.EquateConditions()); diff != "" {
+				t.Errorf("csd.Delete(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewNotification(t *testing.T) {
+	cases := map[string]struct {
+		spec v1alpha1.BucketNotificationSpec
+		want *storage.Notification
+	}{
+		"TopicName": {
+			spec: v1alpha1.BucketNotificationSpec{Topic: notificationTopic},
+			want: &storage.Notification{TopicProjectID: notificationProject, TopicID: notificationTopic, PayloadFormat: storage.JSONPayload},
+		},
+		"FullyQualifiedTopic": {
+			spec: v1alpha1.BucketNotificationSpec{Topic: "projects/other-project/topics/" + notificationTopic, PayloadFormat: storage.NoPayload},
+			want: &storage.Notification{TopicProjectID: "other-project", TopicID: notificationTopic, PayloadFormat: storage.NoPayload},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := newNotification(notificationProject, tc.spec)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("newNotification(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
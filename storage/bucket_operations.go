@@ -28,6 +28,7 @@ import (
 	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
 	"github.com/crossplaneio/crossplane/gcp/apis/storage/v1alpha1"
 	gcpstorage "github.com/crossplaneio/crossplane/pkg/clients/gcp/storage"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/secretgc"
 	"github.com/crossplaneio/crossplane/pkg/meta"
 	"github.com/crossplaneio/crossplane/pkg/resource"
 	"github.com/crossplaneio/crossplane/pkg/util"
@@ -130,6 +131,7 @@ const (
 
 func (bh *bucketHandler) updateSecret(ctx context.Context) error {
 	s := resource.ConnectionSecretFor(bh.Bucket, v1alpha1.BucketGroupVersionKind)
+	secretgc.Mark(s, bh.Bucket, v1alpha1.BucketGroupVersionKind)
 	if ref := bh.Spec.ServiceAccountSecretRef; ref != nil {
 		ss := &corev1.Secret{}
 		nn := types.NamespacedName{Namespace: bh.GetNamespace(), Name: ref.Name}
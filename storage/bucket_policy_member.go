@@ -0,0 +1,268 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/iam"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/storage/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	policyMemberControllerName = "bucketpolicymembers.storage.gcp.crossplane.io"
+	policyMemberFinalizer      = "finalizer." + policyMemberControllerName
+
+	policyMemberReconcileTimeout = 1 * time.Minute
+)
+
+var policyMemberLog = logging.Logger.WithName("controller." + policyMemberControllerName)
+
+// A bucketIAM can read and write the IAM policy of a GCS bucket. It is
+// satisfied by the *iam.Handle of a *storage.BucketHandle.
+type bucketIAM interface {
+	Policy(ctx context.Context) (*iam.Policy, error)
+	SetPolicy(ctx context.Context, p *iam.Policy) error
+}
+
+// A policyMemberCreateSyncDeleter can create, sync, and delete bucket policy
+// members in an external store - e.g. the GCP API. Each method returns true
+// if the member requires further reconciliation.
+type policyMemberCreateSyncDeleter interface {
+	Create(ctx context.Context, m *v1alpha1.BucketPolicyMember) (requeue bool)
+	Sync(ctx context.Context, m *v1alpha1.BucketPolicyMember) (requeue bool)
+	Delete(ctx context.Context, m *v1alpha1.BucketPolicyMember) (requeue bool)
+}
+
+// policyMembers is a policyMemberCreateSyncDeleter using the GCS JSON API.
+type policyMembers struct {
+	kube client.Client
+	iam  func(bucket string) bucketIAM
+}
+
+// Create determines the name of the member's bucket. The member is bound to
+// its role when it is synced.
+func (c *policyMembers) Create(ctx context.Context, m *v1alpha1.BucketPolicyMember) bool {
+	m.Status.SetConditions(corev1alpha1.Creating())
+
+	name, err := bucketName(ctx, c.kube, m.GetNamespace(), m.Spec.BucketName, m.Spec.BucketRef)
+	if err != nil {
+		m.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+
+	m.Status.BucketName = name
+	meta.AddFinalizer(m, policyMemberFinalizer)
+	m.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync binds the member to its role in the bucket's IAM policy, if it is not
+// already bound. A member that was bound to a role that is no longer its spec
+// is unbound from that role.
+func (c *policyMembers) Sync(ctx context.Context, m *v1alpha1.BucketPolicyMember) bool {
+	h := c.iam(m.Status.BucketName)
+	p, err := h.Policy(ctx)
+	if err != nil {
+		m.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot get iam policy of bucket %s", m.Status.BucketName)))
+		return true
+	}
+
+	changed := false
+	if m.Status.Role != "" && (m.Status.Role != m.Spec.Role || m.Status.Member != m.Spec.Member) && p.HasRole(m.Status.Member, iam.RoleName(m.Status.Role)) {
+		p.Remove(m.Status.Member, iam.RoleName(m.Status.Role))
+		changed = true
+	}
+	if !p.HasRole(m.Spec.Member, iam.RoleName(m.Spec.Role)) {
+		p.Add(m.Spec.Member, iam.RoleName(m.Spec.Role))
+		changed = true
+	}
+
+	if changed {
+		// The policy's etag ensures we don't overwrite concurrent changes.
+		// If the policy has changed since we read it we'll try again.
+		if err := h.SetPolicy(ctx, p); err != nil {
+			m.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrapf(err, "cannot set iam policy of bucket %s", m.Status.BucketName)))
+			return true
+		}
+	}
+
+	m.Status.Role = m.Spec.Role
+	m.Status.Member = m.Spec.Member
+	m.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// Delete unbinds the member from its role in the bucket's IAM policy.
+func (c *policyMembers) Delete(ctx context.Context, m *v1alpha1.BucketPolicyMember) bool {
+	m.Status.SetConditions(corev1alpha1.Deleting())
+
+	if m.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete && m.Status.Role != "" {
+		if err := c.unbind(ctx, m); err != nil {
+			m.Status.SetConditions(corev1alpha1.ReconcileError(err))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(m, policyMemberFinalizer)
+	m.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// unbind removes the member from the role to which it was bound, if the
+// bucket still exists and the member is still bound.
+func (c *policyMembers) unbind(ctx context.Context, m *v1alpha1.BucketPolicyMember) error {
+	h := c.iam(m.Status.BucketName)
+	p, err := h.Policy(ctx)
+	if googleapi.IsErrorNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "cannot get iam policy of bucket %s", m.Status.BucketName)
+	}
+	if !p.HasRole(m.Status.Member, iam.RoleName(m.Status.Role)) {
+		return nil
+	}
+	p.Remove(m.Status.Member, iam.RoleName(m.Status.Role))
+	return errors.Wrapf(h.SetPolicy(ctx, p), "cannot set iam policy of bucket %s", m.Status.BucketName)
+}
+
+// A policyMemberConnecter returns a policyMemberCreateSyncDeleter that can
+// create, sync, and delete bucket policy members with an external store - for
+// example the GCP API.
+type policyMemberConnecter interface {
+	Connect(context.Context, *v1alpha1.BucketPolicyMember) (policyMemberCreateSyncDeleter, error)
+}
+
+// policyMemberProviderConnecter is a policyMemberConnecter that returns a
+// policyMemberCreateSyncDeleter authenticated using credentials read from a
+// Crossplane Provider resource.
+type policyMemberProviderConnecter struct {
+	*providerConnecter
+}
+
+// Connect returns a policyMemberCreateSyncDeleter backed by the GCS JSON API.
+// GCP credentials are read from the Crossplane Provider referenced by the
+// supplied BucketPolicyMember.
+func (c *policyMemberProviderConnecter) Connect(ctx context.Context, m *v1alpha1.BucketPolicyMember) (policyMemberCreateSyncDeleter, error) {
+	sc, _, err := c.connect(ctx, m, m.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	return &policyMembers{
+		kube: c.kube,
+		iam:  func(bucket string) bucketIAM { return sc.Bucket(bucket).IAM() },
+	}, nil
+}
+
+// BucketPolicyMemberReconciler reconciles BucketPolicyMembers read from the
+// Kubernetes API with an external store, typically the GCP API.
+type BucketPolicyMemberReconciler struct {
+	policyMemberConnecter
+	kube client.Client
+}
+
+// BucketPolicyMemberController is responsible for adding the
+// BucketPolicyMember controller and its corresponding reconciler to the
+// manager with any runtime configuration.
+type BucketPolicyMemberController struct {
+	// DefaultProvider is used by members that don't reference a provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new BucketPolicyMember Controller and adds it to
+// the Manager with default RBAC. The Manager will set fields on the Controller
+// and start it when the Manager is Started.
+func (c *BucketPolicyMemberController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &BucketPolicyMemberReconciler{
+		policyMemberConnecter: &policyMemberProviderConnecter{&providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+		}},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(policyMemberControllerName).
+		For(&v1alpha1.BucketPolicyMember{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listPolicyMembers)).
+		Complete(r)
+}
+
+// Reconcile bucket policy members with the GCP API.
+func (r *BucketPolicyMemberReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	policyMemberLog.V(logging.Debug).Info("reconciling", "kind", v1alpha1.BucketPolicyMemberKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), policyMemberReconcileTimeout)
+	defer cancel()
+
+	m := &v1alpha1.BucketPolicyMember{}
+	if err := r.kube.Get(ctx, req.NamespacedName, m); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get bucket policy member %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, m)
+	if err != nil {
+		m.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, m), "cannot update bucket policy member %s", req.NamespacedName)
+	}
+
+	// The member has been deleted from the API server. Remove it from GCP.
+	if m.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, m)}, errors.Wrapf(r.kube.Update(ctx, m), "cannot update bucket policy member %s", req.NamespacedName)
+	}
+
+	// The member has no bucket. Assume it has not been created.
+	if m.Status.BucketName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, m)}, errors.Wrapf(r.kube.Update(ctx, m), "cannot update bucket policy member %s", req.NamespacedName)
+	}
+
+	// The member exists in the API server. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, m)}, errors.Wrapf(r.kube.Update(ctx, m), "cannot update bucket policy member %s", req.NamespacedName)
+}
+
+// listPolicyMembers is a provider.Lister of bucket policy members.
+func listPolicyMembers(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.BucketPolicyMemberList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
@@ -0,0 +1,403 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"cloud.google.com/go/iam"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/storage/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	memberBucket = "cool-bucket"
+	memberRole   = "roles/storage.objectViewer"
+	memberMember = "serviceAccount:cool@cool-project.iam.gserviceaccount.com"
+)
+
+var (
+	errMemberBoom     = errors.New("boom")
+	errMemberNotFound = &googleapi.Error{Code: http.StatusNotFound}
+)
+
+// Test that our Reconciler implementation satisfies the Reconciler interface.
+var _ reconcile.Reconciler = &BucketPolicyMemberReconciler{}
+
+type mockBucketIAM struct {
+	MockPolicy    func(ctx context.Context) (*iam.Policy, error)
+	MockSetPolicy func(ctx context.Context, p *iam.Policy) error
+}
+
+func (m *mockBucketIAM) Policy(ctx context.Context) (*iam.Policy, error) { return m.MockPolicy(ctx) }
+
+func (m *mockBucketIAM) SetPolicy(ctx context.Context, p *iam.Policy) error {
+	return m.MockSetPolicy(ctx, p)
+}
+
+func policy(role, member string) *iam.Policy {
+	p := &iam.Policy{}
+	if role != "" {
+		p.Add(member, iam.RoleName(role))
+	}
+	return p
+}
+
+type policyMemberModifier func(*v1alpha1.BucketPolicyMember)
+
+func withMemberConditions(c ...corev1alpha1.Condition) policyMemberModifier {
+	return func(m *v1alpha1.BucketPolicyMember) { m.Status.SetConditions(c...) }
+}
+
+func withMemberFinalizers(f ...string) policyMemberModifier {
+	return func(m *v1alpha1.BucketPolicyMember) { m.ObjectMeta.Finalizers = f }
+}
+
+func withMemberReclaimPolicy(r corev1alpha1.ReclaimPolicy) policyMemberModifier {
+	return func(m *v1alpha1.BucketPolicyMember) { m.Spec.ReclaimPolicy = r }
+}
+
+func withMemberBucketRef(name string) policyMemberModifier {
+	return func(m *v1alpha1.BucketPolicyMember) {
+		m.Spec.BucketName = ""
+		m.Spec.BucketRef = &corev1.LocalObjectReference{Name: name}
+	}
+}
+
+func withMemberStatus(bucket, role, member string) policyMemberModifier {
+	return func(m *v1alpha1.BucketPolicyMember) {
+		m.Status.BucketName = bucket
+		m.Status.Role = role
+		m.Status.Member = member
+	}
+}
+
+func policyMember(pm ...policyMemberModifier) *v1alpha1.BucketPolicyMember {
+	m := &v1alpha1.BucketPolicyMember{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  testNamespace,
+			Name:       "cool-member",
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.BucketPolicyMemberSpec{
+			BucketName: memberBucket,
+			Role:       memberRole,
+			Member:     memberMember,
+		},
+	}
+
+	for _, mod := range pm {
+		mod(m)
+	}
+
+	return m
+}
+
+func TestBucketPolicyMemberCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         policyMemberCreateSyncDeleter
+		m           *v1alpha1.BucketPolicyMember
+		want        *v1alpha1.BucketPolicyMember
+		wantRequeue bool
+	}{
+		{
+			name: "SuccessfulCreate",
+			csd:  &policyMembers{},
+			m:    policyMember(),
+			want: policyMember(
+				withMemberFinalizers(policyMemberFinalizer),
+				withMemberStatus(memberBucket, "", ""),
+				withMemberConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "SuccessfulCreateFromBucketRef",
+			csd: &policyMembers{kube: &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				b := obj.(*v1alpha1.Bucket)
+				b.Spec.NameFormat = memberBucket
+				return nil
+			}}},
+			m: policyMember(withMemberBucketRef("cool-bucket-resource")),
+			want: policyMember(
+				withMemberBucketRef("cool-bucket-resource"),
+				withMemberFinalizers(policyMemberFinalizer),
+				withMemberStatus(memberBucket, "", ""),
+				withMemberConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGetBucketRef",
+			csd: &policyMembers{kube: &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, _ runtime.Object) error {
+				return errMemberBoom
+			}}},
+			m: policyMember(withMemberBucketRef("cool-bucket-resource")),
+			want: policyMember(
+				withMemberBucketRef("cool-bucket-resource"),
+				withMemberConditions(
+					corev1alpha1.Creating(),
+					corev1alpha1.ReconcileError(errors.Wrapf(errMemberBoom, "cannot get bucket %s/%s", testNamespace, "cool-bucket-resource")),
+				),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(context.Background(), tc.m)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.m, test.EquateConditions()); diff != "" {
+				t.Errorf("tc.csd.Create(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBucketPolicyMemberSync(t *testing.T) {
+	cases := []struct {
+		name        string
+		iam         *mockBucketIAM
+		m           *v1alpha1.BucketPolicyMember
+		want        *v1alpha1.BucketPolicyMember
+		wantPolicy  *iam.Policy
+		wantRequeue bool
+	}{
+		{
+			name: "MemberBound",
+			iam: &mockBucketIAM{
+				MockPolicy: func(_ context.Context) (*iam.Policy, error) { return policy("", ""), nil },
+			},
+			m: policyMember(withMemberStatus(memberBucket, "", "")),
+			want: policyMember(
+				withMemberStatus(memberBucket, memberRole, memberMember),
+				withMemberConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantPolicy:  policy(memberRole, memberMember),
+			wantRequeue: false,
+		},
+		{
+			name: "MemberAlreadyBound",
+			iam: &mockBucketIAM{
+				MockPolicy: func(_ context.Context) (*iam.Policy, error) { return policy(memberRole, memberMember), nil },
+			},
+			m: policyMember(withMemberStatus(memberBucket, memberRole, memberMember)),
+			want: policyMember(
+				withMemberStatus(memberBucket, memberRole, memberMember),
+				withMemberConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "RoleChanged",
+			iam: &mockBucketIAM{
+				MockPolicy: func(_ context.Context) (*iam.Policy, error) {
+					return policy("roles/storage.objectAdmin", memberMember), nil
+				},
+			},
+			m: policyMember(withMemberStatus(memberBucket, "roles/storage.objectAdmin", memberMember)),
+			want: policyMember(
+				withMemberStatus(memberBucket, memberRole, memberMember),
+				withMemberConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantPolicy:  policy(memberRole, memberMember),
+			wantRequeue: false,
+		},
+		{
+			name: "FailedGetPolicy",
+			iam: &mockBucketIAM{
+				MockPolicy: func(_ context.Context) (*iam.Policy, error) { return nil, errMemberBoom },
+			},
+			m: policyMember(withMemberStatus(memberBucket, "", "")),
+			want: policyMember(
+				withMemberStatus(memberBucket, "", ""),
+				withMemberConditions(corev1alpha1.ReconcileError(errors.Wrapf(errMemberBoom, "cannot get iam policy of bucket %s", memberBucket))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedSetPolicy",
+			iam: &mockBucketIAM{
+				MockPolicy:    func(_ context.Context) (*iam.Policy, error) { return policy("", ""), nil },
+				MockSetPolicy: func(_ context.Context, _ *iam.Policy) error { return errMemberBoom },
+			},
+			m: policyMember(withMemberStatus(memberBucket, "", "")),
+			want: policyMember(
+				withMemberStatus(memberBucket, "", ""),
+				withMemberConditions(corev1alpha1.ReconcileError(errors.Wrapf(errMemberBoom, "cannot set iam policy of bucket %s", memberBucket))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotPolicy *iam.Policy
+			if tc.iam.MockSetPolicy == nil {
+				tc.iam.MockSetPolicy = func(_ context.Context, p *iam.Policy) error {
+					gotPolicy = p
+					return nil
+				}
+			}
+			csd := &policyMembers{iam: func(bucket string) bucketIAM {
+				if bucket != memberBucket {
+					t.Errorf("bucket: want %s, got %s", memberBucket, bucket)
+				}
+				return tc.iam
+			}}
+
+			gotRequeue := csd.Sync(context.Background(), tc.m)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.m, test.EquateConditions()); diff != "" {
+				t.Errorf("csd.Sync(...): -want, +got:\n%s", diff)
+			}
+
+			if tc.wantPolicy == nil {
+				return
+			}
+			if gotPolicy == nil {
+				t.Fatalf("csd.Sync(...): want policy to be set")
+			}
+			if diff := cmp.Diff(tc.wantPolicy.Members(iam.RoleName(memberRole)), gotPolicy.Members(iam.RoleName(memberRole))); diff != "" {
+				t.Errorf("csd.Sync(...): -want members, +got members:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantPolicy.Roles(), gotPolicy.Roles()); diff != "" {
+				t.Errorf("csd.Sync(...): -want roles, +got roles:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBucketPolicyMemberDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		iam         *mockBucketIAM
+		m           *v1alpha1.BucketPolicyMember
+		want        *v1alpha1.BucketPolicyMember
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimRetain",
+			iam:  &mockBucketIAM{},
+			m: policyMember(
+				withMemberReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withMemberFinalizers(policyMemberFinalizer),
+				withMemberStatus(memberBucket, memberRole, memberMember),
+			),
+			want: policyMember(
+				withMemberReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withMemberStatus(memberBucket, memberRole, memberMember),
+				withMemberConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteSuccessful",
+			iam: &mockBucketIAM{
+				MockPolicy:    func(_ context.Context) (*iam.Policy, error) { return policy(memberRole, memberMember), nil },
+				MockSetPolicy: func(_ context.Context, _ *iam.Policy) error { return nil },
+			},
+			m: policyMember(
+				withMemberReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withMemberFinalizers(policyMemberFinalizer),
+				withMemberStatus(memberBucket, memberRole, memberMember),
+			),
+			want: policyMember(
+				withMemberReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withMemberStatus(memberBucket, memberRole, memberMember),
+				withMemberConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteBucketNotFound",
+			iam: &mockBucketIAM{
+				MockPolicy: func(_ context.Context) (*iam.Policy, error) { return nil, errMemberNotFound },
+			},
+			m: policyMember(
+				withMemberReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withMemberFinalizers(policyMemberFinalizer),
+				withMemberStatus(memberBucket, memberRole, memberMember),
+			),
+			want: policyMember(
+				withMemberReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withMemberStatus(memberBucket, memberRole, memberMember),
+				withMemberConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailed",
+			iam: &mockBucketIAM{
+				MockPolicy:    func(_ context.Context) (*iam.Policy, error) { return policy(memberRole, memberMember), nil },
+				MockSetPolicy: func(_ context.Context, _ *iam.Policy) error { return errMemberBoom },
+			},
+			m: policyMember(
+				withMemberReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withMemberFinalizers(policyMemberFinalizer),
+				withMemberStatus(memberBucket, memberRole, memberMember),
+			),
+			want: policyMember(
+				withMemberReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withMemberFinalizers(policyMemberFinalizer),
+				withMemberStatus(memberBucket, memberRole, memberMember),
+				withMemberConditions(
+					corev1alpha1.Deleting(),
+					corev1alpha1.ReconcileError(errors.Wrapf(errMemberBoom, "cannot set iam policy of bucket %s", memberBucket)),
+				),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			csd := &policyMembers{iam: func(_ string) bucketIAM { return tc.iam }}
+			gotRequeue := csd.Delete(context.Background(), tc.m)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.m, test.EquateConditions()); diff != "" {
+				t.Errorf("csd.Delete(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -334,9 +334,9 @@ func Test_bucketFactory_newHandler(t *testing.T) {
 			Client: fake.NewFakeClient(),
 			bucket: newBucket(ns, bucketName).withProvider(ns, providerName).Bucket,
 			want: want{
-				err: kerrors.NewNotFound(schema.GroupResource{
+				err: errors.Wrapf(kerrors.NewNotFound(schema.GroupResource{
 					Group:    gcpv1alpha1.Group,
-					Resource: "providers"}, "test-provider"),
+					Resource: "providers"}, "test-provider"), "cannot get provider %s/%s", ns, providerName),
 			},
 		},
 		{
@@ -345,7 +345,7 @@ func Test_bucketFactory_newHandler(t *testing.T) {
 			bucket: newBucket(ns, bucketName).withProvider(ns, providerName).Bucket,
 			want: want{
 				err: errors.WithStack(
-					errors.Errorf("cannot get provider's secret %s/%s: secrets \"%s\" not found", ns, secretName, secretName)),
+					errors.Errorf("cannot get provider secret %s/%s: secrets \"%s\" not found", ns, secretName, secretName)),
 			},
 		},
 		{
@@ -356,7 +356,7 @@ func Test_bucketFactory_newHandler(t *testing.T) {
 			bucket: newBucket(ns, bucketName).withProvider(ns, providerName).Bucket,
 			want: want{
 				err: errors.WithStack(
-					errors.Errorf("cannot retrieve creds from json: unexpected end of JSON input")),
+					errors.Errorf("cannot parse credentials in provider secret %s/%s: unexpected end of JSON input", ns, secretName)),
 			},
 		},
 		{
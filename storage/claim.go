@@ -20,18 +20,37 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
 	storagev1alpha1 "github.com/crossplaneio/crossplane/apis/storage/v1alpha1"
 	"github.com/crossplaneio/crossplane/gcp/apis/storage/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/shard"
 	"github.com/crossplaneio/crossplane/pkg/resource"
 )
 
+const (
+	publicAccessPreventionEnforced = "enforced"
+
+	// maxRetentionPeriod is the longest retention period GCS supports; about
+	// 100 years.
+	maxRetentionPeriod = 36500 * 24 * time.Hour
+)
+
+// publicACLs are the predefined ACLs that grant access to all users or all
+// authenticated users.
+var publicACLs = map[string]bool{
+	"publicRead":        true,
+	"publicReadWrite":   true,
+	"authenticatedRead": true,
+}
+
 // BucketClaimController is responsible for adding the Bucket claim controller and its
 // corresponding reconciler to the manager with any runtime configuration.
 type BucketClaimController struct{}
@@ -55,7 +74,7 @@ func (c *BucketClaimController) SetupWithManager(mgr ctrl.Manager) error {
 
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(name).
-		Watches(&source.Kind{Type: &v1alpha1.Bucket{}}, &resource.EnqueueRequestForClaim{}).
+		Watches(&source.Kind{Type: &v1alpha1.Bucket{}}, shard.FilterRequests(mgr.GetClient(), func() runtime.Object { return &storagev1alpha1.Bucket{} }, &resource.EnqueueRequestForClaim{})).
 		For(&storagev1alpha1.Bucket{}).
 		WithEventFilter(resource.NewPredicates(resource.ObjectHasProvisioner(mgr.GetClient(), p))).
 		Complete(r)
@@ -93,6 +112,25 @@ func ConfigureBucket(_ context.Context, cm resource.Claim, cs resource.Class, mg
 		spec.PredefinedACL = string(*bcm.Spec.PredefinedACL)
 	}
 
+	// Set the retention period from the claim only iff: the claim has this
+	// value and it is not defined in the resource class.
+	if bcm.Spec.RetentionDays != nil && spec.RetentionPolicy == nil {
+		spec.RetentionPolicy = &v1alpha1.RetentionPolicy{RetentionPeriod: time.Duration(*bcm.Spec.RetentionDays) * 24 * time.Hour}
+	}
+
+	// A claim may enable, but not disable, public access prevention and
+	// uniform bucket level access required by the resource class.
+	if bcm.Spec.PublicAccessPrevention != nil && *bcm.Spec.PublicAccessPrevention {
+		spec.PublicAccessPrevention = publicAccessPreventionEnforced
+	}
+	if bcm.Spec.UniformAccess != nil && *bcm.Spec.UniformAccess {
+		spec.BucketPolicyOnly.Enabled = true
+	}
+
+	if err := validateBucketPolicy(spec.BucketUpdatableAttrs); err != nil {
+		return errors.Wrapf(err, "invalid bucket claim %s", cm.GetName())
+	}
+
 	spec.WriteConnectionSecretToReference = corev1.LocalObjectReference{Name: string(cm.GetUID())}
 	spec.ProviderReference = rs.ProviderReference
 	spec.ReclaimPolicy = rs.ReclaimPolicy
@@ -101,3 +139,23 @@ func ConfigureBucket(_ context.Context, cm resource.Claim, cs resource.Class, mg
 
 	return nil
 }
+
+// validateBucketPolicy returns an error if the supplied bucket attributes
+// combine an access policy with ACLs that it forbids, or specify a retention
+// period GCS does not support.
+func validateBucketPolicy(a v1alpha1.BucketUpdatableAttrs) error {
+	if a.RetentionPolicy != nil {
+		if p := a.RetentionPolicy.RetentionPeriod; p < 24*time.Hour || p > maxRetentionPeriod {
+			return errors.Errorf("retention period %s must be between 1 and %d days", p, maxRetentionPeriod/(24*time.Hour))
+		}
+	}
+	if a.PublicAccessPrevention == publicAccessPreventionEnforced {
+		if publicACLs[a.PredefinedACL] || publicACLs[a.PredefinedDefaultObjectACL] {
+			return errors.Errorf("public access prevention forbids predefined ACLs %q and %q", a.PredefinedACL, a.PredefinedDefaultObjectACL)
+		}
+	}
+	if a.BucketPolicyOnly.Enabled && (a.PredefinedACL != "" || a.PredefinedDefaultObjectACL != "") {
+		return errors.New("uniform bucket level access forbids predefined ACLs")
+	}
+	return nil
+}
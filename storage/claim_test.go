@@ -19,8 +19,10 @@ package storage
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -51,6 +53,8 @@ func TestConfigureBucket(t *testing.T) {
 	providerName := "coolprovider"
 	bucketName := "coolbucket"
 	bucketPrivate := storagev1alpha1.ACLPrivate
+	retentionDays := 30
+	enabled := true
 
 	cases := map[string]struct {
 		args args
@@ -90,6 +94,59 @@ func TestConfigureBucket(t *testing.T) {
 				err: nil,
 			},
 		},
+		"CompliancePolicy": {
+			args: args{
+				cm: &storagev1alpha1.Bucket{
+					ObjectMeta: metav1.ObjectMeta{UID: claimUID},
+					Spec: storagev1alpha1.BucketSpec{
+						RetentionDays:          &retentionDays,
+						PublicAccessPrevention: &enabled,
+						UniformAccess:          &enabled,
+					},
+				},
+				cs: &corev1alpha1.ResourceClass{
+					ProviderReference: &corev1.ObjectReference{Name: providerName},
+					ReclaimPolicy:     corev1alpha1.ReclaimDelete,
+				},
+				mg: &v1alpha1.Bucket{},
+			},
+			want: want{
+				mg: &v1alpha1.Bucket{
+					Spec: v1alpha1.BucketSpec{
+						ResourceSpec: corev1alpha1.ResourceSpec{
+							ReclaimPolicy:                    corev1alpha1.ReclaimDelete,
+							WriteConnectionSecretToReference: corev1.LocalObjectReference{Name: string(claimUID)},
+							ProviderReference:                &corev1.ObjectReference{Name: providerName},
+						},
+						BucketSpecAttrs: v1alpha1.BucketSpecAttrs{
+							BucketUpdatableAttrs: v1alpha1.BucketUpdatableAttrs{
+								RetentionPolicy:        &v1alpha1.RetentionPolicy{RetentionPeriod: 30 * 24 * time.Hour},
+								PublicAccessPrevention: publicAccessPreventionEnforced,
+								BucketPolicyOnly:       v1alpha1.BucketPolicyOnly{Enabled: true},
+							},
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"UniformAccessWithPredefinedACL": {
+			args: args{
+				cm: &storagev1alpha1.Bucket{
+					ObjectMeta: metav1.ObjectMeta{Name: bucketName, UID: claimUID},
+					Spec: storagev1alpha1.BucketSpec{
+						PredefinedACL: &bucketPrivate,
+						UniformAccess: &enabled,
+					},
+				},
+				cs: &corev1alpha1.ResourceClass{},
+				mg: &v1alpha1.Bucket{},
+			},
+			want: want{
+				mg:  &v1alpha1.Bucket{},
+				err: errors.Wrapf(errors.New("uniform bucket level access forbids predefined ACLs"), "invalid bucket claim %s", bucketName),
+			},
+		},
 	}
 
 	for name, tc := range cases {
@@ -104,3 +161,34 @@ func TestConfigureBucket(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateBucketPolicy(t *testing.T) {
+	cases := map[string]struct {
+		attrs v1alpha1.BucketUpdatableAttrs
+		want  error
+	}{
+		"Unrestricted": {
+			attrs: v1alpha1.BucketUpdatableAttrs{PredefinedACL: "publicRead"},
+		},
+		"RetentionTooShort": {
+			attrs: v1alpha1.BucketUpdatableAttrs{RetentionPolicy: &v1alpha1.RetentionPolicy{RetentionPeriod: time.Hour}},
+			want:  errors.Errorf("retention period %s must be between 1 and %d days", time.Hour, 36500),
+		},
+		"PublicACLWithPublicAccessPrevention": {
+			attrs: v1alpha1.BucketUpdatableAttrs{PublicAccessPrevention: publicAccessPreventionEnforced, PredefinedDefaultObjectACL: "publicRead"},
+			want:  errors.Errorf("public access prevention forbids predefined ACLs %q and %q", "", "publicRead"),
+		},
+		"PrivateACLWithPublicAccessPrevention": {
+			attrs: v1alpha1.BucketUpdatableAttrs{PublicAccessPrevention: publicAccessPreventionEnforced, PredefinedACL: "private"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateBucketPolicy(tc.attrs)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("validateBucketPolicy(...): -want error, +got error:\n%s", diff)
+			}
+		})
+	}
+}
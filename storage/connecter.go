@@ -0,0 +1,72 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+
+	"cloud.google.com/go/storage"
+	"github.com/pkg/errors"
+	"google.golang.org/api/option"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplaneio/crossplane/gcp/apis/storage/v1alpha1"
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+)
+
+// providerConnecter returns storage clients authenticated using credentials
+// read from a Crossplane Provider resource.
+type providerConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+}
+
+// connect returns a client authenticated using credentials read from the
+// Provider referenced by the supplied managed resource, and that Provider.
+func (c *providerConnecter) connect(ctx context.Context, mg metav1.Object, ref *corev1.ObjectReference) (*storage.Client, *gcpv1alpha1.Provider, error) {
+	p, err := c.providers.Get(ctx, c.kube, mg, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceStorage, storage.ScopeFullControl)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sc, err := storage.NewClient(ctx, option.WithCredentials(creds))
+	return sc, p, errors.Wrap(err, "cannot create new storage client")
+}
+
+// bucketName returns the name of the GCS bucket with the supplied name, or of
+// the Bucket managed resource referenced by the supplied reference in the
+// supplied namespace.
+func bucketName(ctx context.Context, kube client.Client, namespace, name string, ref *corev1.LocalObjectReference) (string, error) {
+	if ref == nil {
+		return name, nil
+	}
+	b := &v1alpha1.Bucket{}
+	nn := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+	if err := kube.Get(ctx, nn, b); err != nil {
+		return "", errors.Wrapf(err, "cannot get bucket %s", nn)
+	}
+	return b.GetBucketName(), nil
+}
@@ -0,0 +1,249 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package topology
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cachev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/cache/v1alpha1"
+	computev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/compute/v1alpha1"
+	databasev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	servicenetworkingv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/servicenetworking/v1alpha1"
+	storagev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/storage/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+)
+
+// The kinds of GCP resource that managed resources reference by name.
+const (
+	externalKindNetwork   = "Network"
+	externalKindCryptoKey = "CryptoKey"
+)
+
+// DefaultKinds are the kinds of managed resource included in a graph unless
+// others are specified. They include every kind that references another
+// managed resource, such as a servicenetworking Connection, because they are
+// also the kinds the refindex package indexes.
+var DefaultKinds = []Kind{
+	{
+		GroupVersionKind: databasev1alpha1.CloudsqlInstanceGroupVersionKind,
+		List:             listCloudsqlInstances,
+		References:       cloudsqlInstanceReferences,
+	},
+	{
+		GroupVersionKind: computev1alpha1.GKEClusterGroupVersionKind,
+		List:             listGKEClusters,
+	},
+	{
+		GroupVersionKind: computev1alpha1.NetworkPeeringGroupVersionKind,
+		List:             listNetworkPeerings,
+		References:       networkPeeringReferences,
+	},
+	{
+		GroupVersionKind: cachev1alpha1.CloudMemorystoreInstanceGroupVersionKind,
+		List:             listCloudMemorystoreInstances,
+		References:       cloudMemorystoreInstanceReferences,
+	},
+	{
+		GroupVersionKind: cachev1alpha1.MemcachedInstanceGroupVersionKind,
+		List:             listMemcachedInstances,
+		References:       memcachedInstanceReferences,
+	},
+	{
+		GroupVersionKind: servicenetworkingv1alpha1.ConnectionGroupVersionKind,
+		List:             listConnections,
+		References:       connectionReferences,
+	},
+	{
+		GroupVersionKind: storagev1alpha1.BucketGroupVersionKind,
+		List:             listBuckets,
+	},
+}
+
+func listCloudsqlInstances(ctx context.Context, kube client.Client, namespace string) ([]resource.Managed, error) {
+	l := &databasev1alpha1.CloudsqlInstanceList{}
+	if err := kube.List(ctx, l, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	mgs := make([]resource.Managed, 0, len(l.Items))
+	for i := range l.Items {
+		mgs = append(mgs, &l.Items[i])
+	}
+	return mgs, nil
+}
+
+func cloudsqlInstanceReferences(mg resource.Managed) []Node {
+	i, ok := mg.(*databasev1alpha1.CloudsqlInstance)
+	if !ok {
+		return nil
+	}
+	var refs []Node
+	if i.Spec.ConnectionReference != nil {
+		refs = append(refs, connectionNode(i.Spec.ConnectionReference))
+	}
+	if i.Spec.PrivateNetwork != "" {
+		refs = append(refs, networkNode(i.Spec.PrivateNetwork))
+	}
+	if i.Spec.FinalBackupBucketRef != nil {
+		refs = append(refs, bucketNode(i.GetNamespace(), *i.Spec.FinalBackupBucketRef))
+	}
+	if a := i.Spec.ServiceAccountAccess; a != nil {
+		for _, b := range a.BucketRefs {
+			refs = append(refs, bucketNode(i.GetNamespace(), b))
+		}
+		for _, k := range a.KMSKeys {
+			refs = append(refs, Node{Kind: externalKindCryptoKey, Name: k, External: true})
+		}
+	}
+	return refs
+}
+
+func listGKEClusters(ctx context.Context, kube client.Client, namespace string) ([]resource.Managed, error) {
+	l := &computev1alpha1.GKEClusterList{}
+	if err := kube.List(ctx, l, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	mgs := make([]resource.Managed, 0, len(l.Items))
+	for i := range l.Items {
+		mgs = append(mgs, &l.Items[i])
+	}
+	return mgs, nil
+}
+
+func listNetworkPeerings(ctx context.Context, kube client.Client, namespace string) ([]resource.Managed, error) {
+	l := &computev1alpha1.NetworkPeeringList{}
+	if err := kube.List(ctx, l, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	mgs := make([]resource.Managed, 0, len(l.Items))
+	for i := range l.Items {
+		mgs = append(mgs, &l.Items[i])
+	}
+	return mgs, nil
+}
+
+func networkPeeringReferences(mg resource.Managed) []Node {
+	p, ok := mg.(*computev1alpha1.NetworkPeering)
+	if !ok || p.Spec.Network == "" {
+		return nil
+	}
+	return []Node{networkNode(p.Spec.Network)}
+}
+
+func listCloudMemorystoreInstances(ctx context.Context, kube client.Client, namespace string) ([]resource.Managed, error) {
+	l := &cachev1alpha1.CloudMemorystoreInstanceList{}
+	if err := kube.List(ctx, l, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	mgs := make([]resource.Managed, 0, len(l.Items))
+	for i := range l.Items {
+		mgs = append(mgs, &l.Items[i])
+	}
+	return mgs, nil
+}
+
+func cloudMemorystoreInstanceReferences(mg resource.Managed) []Node {
+	i, ok := mg.(*cachev1alpha1.CloudMemorystoreInstance)
+	if !ok {
+		return nil
+	}
+	var refs []Node
+	if i.Spec.ConnectionReference != nil {
+		refs = append(refs, connectionNode(i.Spec.ConnectionReference))
+	}
+	if i.Spec.AuthorizedNetwork != "" {
+		refs = append(refs, networkNode(i.Spec.AuthorizedNetwork))
+	}
+	return refs
+}
+
+func listMemcachedInstances(ctx context.Context, kube client.Client, namespace string) ([]resource.Managed, error) {
+	l := &cachev1alpha1.MemcachedInstanceList{}
+	if err := kube.List(ctx, l, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	mgs := make([]resource.Managed, 0, len(l.Items))
+	for i := range l.Items {
+		mgs = append(mgs, &l.Items[i])
+	}
+	return mgs, nil
+}
+
+func memcachedInstanceReferences(mg resource.Managed) []Node {
+	i, ok := mg.(*cachev1alpha1.MemcachedInstance)
+	if !ok {
+		return nil
+	}
+	var refs []Node
+	if i.Spec.ConnectionReference != nil {
+		refs = append(refs, connectionNode(i.Spec.ConnectionReference))
+	}
+	if i.Spec.AuthorizedNetwork != "" {
+		refs = append(refs, networkNode(i.Spec.AuthorizedNetwork))
+	}
+	return refs
+}
+
+func listConnections(ctx context.Context, kube client.Client, namespace string) ([]resource.Managed, error) {
+	l := &servicenetworkingv1alpha1.ConnectionList{}
+	if err := kube.List(ctx, l, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	mgs := make([]resource.Managed, 0, len(l.Items))
+	for i := range l.Items {
+		mgs = append(mgs, &l.Items[i])
+	}
+	return mgs, nil
+}
+
+func connectionReferences(mg resource.Managed) []Node {
+	c, ok := mg.(*servicenetworkingv1alpha1.Connection)
+	if !ok || c.Spec.Network == "" {
+		return nil
+	}
+	return []Node{networkNode(c.Spec.Network)}
+}
+
+func listBuckets(ctx context.Context, kube client.Client, namespace string) ([]resource.Managed, error) {
+	l := &storagev1alpha1.BucketList{}
+	if err := kube.List(ctx, l, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	mgs := make([]resource.Managed, 0, len(l.Items))
+	for i := range l.Items {
+		mgs = append(mgs, &l.Items[i])
+	}
+	return mgs, nil
+}
+
+func connectionNode(ref *corev1.ObjectReference) Node {
+	gvk := servicenetworkingv1alpha1.ConnectionGroupVersionKind
+	return Node{Group: gvk.Group, Kind: gvk.Kind, Namespace: ref.Namespace, Name: ref.Name, Managed: true}
+}
+
+func bucketNode(namespace string, ref corev1.LocalObjectReference) Node {
+	gvk := storagev1alpha1.BucketGroupVersionKind
+	return Node{Group: gvk.Group, Kind: gvk.Kind, Namespace: namespace, Name: ref.Name, Managed: true}
+}
+
+// networkNode returns a node for the supplied VPC network, which managed
+// resources reference by name or by URL.
+func networkNode(network string) Node {
+	return Node{Kind: externalKindNetwork, Name: network, External: true}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package topology
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplaneio/crossplane/pkg/logging"
+)
+
+const (
+	requestTimeout  = 30 * time.Second
+	shutdownTimeout = 10 * time.Second
+
+	// Path at which the graph is served.
+	Path = "/topology"
+)
+
+// The formats in which the graph may be served.
+const (
+	FormatJSON = "json"
+	FormatDOT  = "dot"
+)
+
+var log = logging.Logger.WithName("topology")
+
+// Options configure the topology server.
+type Options struct {
+	// Address at which the graph is served, e.g. :8082. The graph reveals the
+	// names of resources and Secrets, so it should not be exposed outside
+	// the cluster.
+	Address string
+}
+
+// A Server serves the graph of managed resources. It is a manager.Runnable.
+type Server struct {
+	kube  client.Client
+	o     Options
+	kinds []Kind
+}
+
+// NewServer returns a Server that builds graphs of the DefaultKinds of managed
+// resource using the supplied client.
+func NewServer(kube client.Client, o Options) *Server {
+	return &Server{kube: kube, o: o, kinds: DefaultKinds}
+}
+
+// Start serves the graph until the supplied channel is closed.
+func (s *Server) Start(stop <-chan struct{}) error {
+	srv := &http.Server{Addr: s.o.Address, Handler: s}
+
+	go func() {
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Info("cannot shut down topology server", "error", err.Error())
+		}
+	}()
+
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return errors.Wrapf(err, "cannot serve topology at %s", s.o.Address)
+	}
+	return nil
+}
+
+// ServeHTTP serves the graph at:
+//
+//	GET /topology?format={json,dot}&namespace={namespace}
+//
+// The graph is served as JSON unless the DOT format is requested. It includes
+// managed resources in all namespaces unless a namespace is specified.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != Path {
+		http.Error(w, "unknown path "+r.URL.Path, http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method "+r.Method+" is not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = FormatJSON
+	}
+	if format != FormatJSON && format != FormatDOT {
+		http.Error(w, "unknown format "+format, http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	g, err := Build(ctx, s.kube, r.URL.Query().Get("namespace"), s.kinds)
+	if err != nil {
+		http.Error(w, errors.Wrap(err, "cannot build graph").Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if format == FormatDOT {
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		err = g.WriteDOT(w)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(g)
+	}
+	if err != nil {
+		log.Info("cannot write response", "error", err.Error())
+	}
+}
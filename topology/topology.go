@@ -0,0 +1,235 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package topology builds the dependency graph of managed resources; the
+// claims that bind them, the Providers and Secrets they use, and the GCP
+// resources they reference. It can serve the graph as JSON or in the Graphviz
+// DOT language, which helps debug environments with many resources.
+package topology
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/resource"
+)
+
+// The relations represented by the edges of a graph.
+const (
+	RelationBinds      = "binds"
+	RelationUses       = "uses"
+	RelationWrites     = "writes"
+	RelationReferences = "references"
+)
+
+var secretGroupVersionKind = corev1.SchemeGroupVersion.WithKind("Secret")
+
+// A Node of a graph; a Kubernetes or GCP resource.
+type Node struct {
+	ID        string `json:"id"`
+	Group     string `json:"group,omitempty"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+
+	// Managed is true if the resource is a managed resource.
+	Managed bool `json:"managed,omitempty"`
+
+	// Ready is true if the resource is a managed resource with a Ready
+	// condition of True.
+	Ready bool `json:"ready,omitempty"`
+
+	// External is true if the resource is a GCP resource that is referenced
+	// by name, rather than a Kubernetes resource.
+	External bool `json:"external,omitempty"`
+}
+
+// An Edge of a graph, from the node that depends on another to the node it
+// depends on.
+type Edge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Relation string `json:"relation"`
+}
+
+// A Graph of resources and their dependencies.
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+
+	index map[string]int
+}
+
+// A Kind of managed resource that is included in a graph.
+type Kind struct {
+	GroupVersionKind schema.GroupVersionKind
+
+	// List the managed resources of this kind in the supplied namespace, or
+	// in all namespaces if it is empty.
+	List func(ctx context.Context, kube client.Client, namespace string) ([]resource.Managed, error)
+
+	// References returns the nodes the supplied managed resource references,
+	// other than its claim, Provider, and connection Secret. It may be nil.
+	References func(mg resource.Managed) []Node
+}
+
+// A providerReferencer references a Provider. Each GCP managed resource does.
+type providerReferencer interface {
+	GetProviderReference() *corev1.ObjectReference
+}
+
+// Build returns the graph of the managed resources of the supplied kinds in
+// the supplied namespace, or in all namespaces if it is empty. Resources are
+// read using the supplied client, which is typically backed by the manager's
+// informer caches so that building a graph does not load the API server.
+func Build(ctx context.Context, kube client.Client, namespace string, kinds []Kind) (*Graph, error) {
+	g := &Graph{Nodes: []Node{}, Edges: []Edge{}}
+	for _, k := range kinds {
+		mgs, err := k.List(ctx, kube, namespace)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot list %s", k.GroupVersionKind.Kind)
+		}
+		for _, mg := range mgs {
+			g.addManaged(k, mg)
+		}
+	}
+	g.sort()
+	return g, nil
+}
+
+func (g *Graph) addManaged(k Kind, mg resource.Managed) {
+	n := g.add(Node{
+		Group:     k.GroupVersionKind.Group,
+		Kind:      k.GroupVersionKind.Kind,
+		Namespace: mg.GetNamespace(),
+		Name:      mg.GetName(),
+		Managed:   true,
+		Ready:     mg.GetCondition(corev1alpha1.TypeReady).Status == corev1.ConditionTrue,
+	})
+
+	if ref := mg.GetClaimReference(); ref != nil {
+		c := g.add(Node{Group: ref.GroupVersionKind().Group, Kind: ref.Kind, Namespace: ref.Namespace, Name: ref.Name})
+		g.link(c, n, RelationBinds)
+	}
+	if pr, ok := mg.(providerReferencer); ok && pr.GetProviderReference() != nil {
+		ref := pr.GetProviderReference()
+		p := g.add(Node{Group: gcpv1alpha1.ProviderGroupVersionKind.Group, Kind: gcpv1alpha1.ProviderGroupVersionKind.Kind, Namespace: ref.Namespace, Name: ref.Name})
+		g.link(n, p, RelationUses)
+	}
+	if s := mg.GetWriteConnectionSecretToReference(); s.Name != "" {
+		sn := g.add(Node{Kind: secretGroupVersionKind.Kind, Namespace: mg.GetNamespace(), Name: s.Name})
+		g.link(n, sn, RelationWrites)
+	}
+	if k.References == nil {
+		return
+	}
+	for _, r := range k.References(mg) {
+		g.link(n, g.add(r), RelationReferences)
+	}
+}
+
+// add adds the supplied node to the graph, unless it already contains it, and
+// returns its ID. A managed resource's readiness is recorded even if it was
+// first added as the target of another resource's reference.
+func (g *Graph) add(n Node) string {
+	n.ID = idOf(n)
+	if g.index == nil {
+		g.index = map[string]int{}
+	}
+	if i, ok := g.index[n.ID]; ok {
+		g.Nodes[i].Managed = g.Nodes[i].Managed || n.Managed
+		g.Nodes[i].Ready = g.Nodes[i].Ready || n.Ready
+		return n.ID
+	}
+	g.index[n.ID] = len(g.Nodes)
+	g.Nodes = append(g.Nodes, n)
+	return n.ID
+}
+
+func (g *Graph) link(from, to, relation string) {
+	g.Edges = append(g.Edges, Edge{From: from, To: to, Relation: relation})
+}
+
+// sort sorts the graph's nodes and edges so that it renders deterministically.
+func (g *Graph) sort() {
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Relation < b.Relation
+	})
+	g.index = nil
+}
+
+func idOf(n Node) string {
+	kind := n.Kind
+	if n.Group != "" {
+		kind = n.Kind + "." + n.Group
+	}
+	if n.Namespace == "" {
+		return kind + "/" + n.Name
+	}
+	return kind + "/" + n.Namespace + "/" + n.Name
+}
+
+// WriteDOT writes the graph to the supplied writer in the Graphviz DOT
+// language. External GCP resources are drawn dashed, and managed resources
+// that are not ready are drawn red.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	b := &strings.Builder{}
+	b.WriteString("digraph topology {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	for _, n := range g.Nodes {
+		label := n.Kind + "\\n" + n.Name
+		if n.Namespace != "" {
+			label = n.Kind + "\\n" + n.Namespace + "/" + n.Name
+		}
+		attrs := []string{"label=" + quote(label)}
+		if n.External {
+			attrs = append(attrs, "style=dashed")
+		}
+		if n.Managed && !n.Ready {
+			attrs = append(attrs, "color=red")
+		}
+		fmt.Fprintf(b, "\t%s [%s];\n", quote(n.ID), strings.Join(attrs, ", "))
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(b, "\t%s -> %s [label=%s];\n", quote(e.From), quote(e.To), quote(e.Relation))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// quote returns the supplied string as a DOT quoted string. Backslash
+// sequences such as \n are preserved, since DOT uses them in labels.
+func quote(s string) string {
+	return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package topology
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	gcpapis "github.com/crossplaneio/crossplane/gcp/apis"
+	databasev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/database/v1alpha1"
+	storagev1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/storage/v1alpha1"
+	gcpv1alpha1 "github.com/crossplaneio/crossplane/gcp/apis/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace = "cool-namespace"
+	network   = "cool-network"
+)
+
+var testKinds = []Kind{
+	{
+		GroupVersionKind: databasev1alpha1.CloudsqlInstanceGroupVersionKind,
+		List:             listCloudsqlInstances,
+		References:       cloudsqlInstanceReferences,
+	},
+	{
+		GroupVersionKind: storagev1alpha1.BucketGroupVersionKind,
+		List:             listBuckets,
+	},
+}
+
+func init() {
+	_ = gcpapis.AddToScheme(scheme.Scheme)
+}
+
+func cloudsqlInstance() *databasev1alpha1.CloudsqlInstance {
+	return &databasev1alpha1.CloudsqlInstance{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "cool-instance"},
+		Spec: databasev1alpha1.CloudsqlInstanceSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ClaimReference:                   &corev1.ObjectReference{APIVersion: "database.crossplane.io/v1alpha1", Kind: "PostgreSQLInstance", Namespace: namespace, Name: "cool-claim"},
+				ProviderReference:                &corev1.ObjectReference{Namespace: namespace, Name: "cool-provider"},
+				WriteConnectionSecretToReference: corev1.LocalObjectReference{Name: "cool-secret"},
+			},
+			PrivateNetwork:       network,
+			FinalBackupBucketRef: &corev1.LocalObjectReference{Name: "cool-bucket"},
+		},
+	}
+}
+
+func bucket() *storagev1alpha1.Bucket {
+	b := &storagev1alpha1.Bucket{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "cool-bucket"}}
+	b.Status.SetConditions(corev1alpha1.Available())
+	return b
+}
+
+func node(group, kind, ns, name string, managed, ready, external bool) Node {
+	n := Node{Group: group, Kind: kind, Namespace: ns, Name: name, Managed: managed, Ready: ready, External: external}
+	n.ID = idOf(n)
+	return n
+}
+
+func TestBuild(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	sql := databasev1alpha1.CloudsqlInstanceGroupVersionKind
+	bkt := storagev1alpha1.BucketGroupVersionKind
+	prv := gcpv1alpha1.ProviderGroupVersionKind
+
+	instanceNode := node(sql.Group, sql.Kind, namespace, "cool-instance", true, false, false)
+	bucketNode := node(bkt.Group, bkt.Kind, namespace, "cool-bucket", true, true, false)
+	claimNode := node("database.crossplane.io", "PostgreSQLInstance", namespace, "cool-claim", false, false, false)
+	providerNode := node(prv.Group, prv.Kind, namespace, "cool-provider", false, false, false)
+	secretNode := node("", "Secret", namespace, "cool-secret", false, false, false)
+	networkNode := node("", externalKindNetwork, "", network, false, false, true)
+
+	type want struct {
+		g   *Graph
+		err error
+	}
+
+	cases := map[string]struct {
+		kube client.Client
+		want want
+	}{
+		"Graph": {
+			kube: fakeclient.NewFakeClient(cloudsqlInstance(), bucket()),
+			want: want{g: &Graph{
+				Nodes: []Node{bucketNode, instanceNode, networkNode, claimNode, providerNode, secretNode},
+				Edges: []Edge{
+					{From: instanceNode.ID, To: bucketNode.ID, Relation: RelationReferences},
+					{From: instanceNode.ID, To: networkNode.ID, Relation: RelationReferences},
+					{From: instanceNode.ID, To: providerNode.ID, Relation: RelationUses},
+					{From: instanceNode.ID, To: secretNode.ID, Relation: RelationWrites},
+					{From: claimNode.ID, To: instanceNode.ID, Relation: RelationBinds},
+				},
+			}},
+		},
+		"ListError": {
+			kube: &test.MockClient{
+				MockList: func(_ context.Context, _ runtime.Object, _ ...client.ListOption) error { return errBoom },
+			},
+			want: want{err: errors.Wrapf(errBoom, "cannot list %s", sql.Kind)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			g, err := Build(context.Background(), tc.kube, "", testKinds)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("Build(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.g, g, cmp.AllowUnexported(Graph{})); diff != "" {
+				t.Errorf("Build(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	g := &Graph{Nodes: []Node{}, Edges: []Edge{}}
+	g.addManaged(testKinds[0], cloudsqlInstance())
+	g.sort()
+
+	b := &bytes.Buffer{}
+	if err := g.WriteDOT(b); err != nil {
+		t.Fatalf("WriteDOT(...): %s", err)
+	}
+	for _, want := range []string{
+		"digraph topology {",
+		`label="CloudsqlInstance\n` + namespace + `/cool-instance", color=red`,
+		`label="Network\n` + network + `", style=dashed`,
+		`[label="binds"]`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("WriteDOT(...): want output to contain %q, got:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	cases := map[string]struct {
+		path        string
+		method      string
+		code        int
+		contentType string
+	}{
+		"JSON": {
+			path:        Path,
+			method:      http.MethodGet,
+			code:        http.StatusOK,
+			contentType: "application/json",
+		},
+		"DOT": {
+			path:        Path + "?format=dot&namespace=" + namespace,
+			method:      http.MethodGet,
+			code:        http.StatusOK,
+			contentType: "text/vnd.graphviz",
+		},
+		"UnknownFormat": {
+			path:   Path + "?format=svg",
+			method: http.MethodGet,
+			code:   http.StatusBadRequest,
+		},
+		"UnknownPath": {
+			path:   "/graph",
+			method: http.MethodGet,
+			code:   http.StatusNotFound,
+		},
+		"MethodNotAllowed": {
+			path:   Path,
+			method: http.MethodPost,
+			code:   http.StatusMethodNotAllowed,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := &Server{kube: fakeclient.NewFakeClient(cloudsqlInstance(), bucket()), kinds: testKinds}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+			if w.Code != tc.code {
+				t.Errorf("ServeHTTP(...): want code %d, got %d: %s", tc.code, w.Code, w.Body.String())
+			}
+			if tc.contentType != "" && w.Header().Get("Content-Type") != tc.contentType {
+				t.Errorf("ServeHTTP(...): want content type %q, got %q", tc.contentType, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tracing records OpenTelemetry spans for the reconciles of managed
+// resources, and the GCP API calls they make, so that slow reconciles can be
+// explained. Spans are exported via OTLP if tracing is set up, and discarded
+// otherwise.
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// ServiceName identifies the spans exported by this controller.
+const ServiceName = "crossplane-gcp"
+
+// The phases of a reconcile that are recorded as spans.
+const (
+	PhaseConnect       = "connect"
+	PhaseObserve       = "observe"
+	PhaseCreate        = "create"
+	PhaseUpdate        = "update"
+	PhaseDelete        = "delete"
+	PhasePublishSecret = "publish-secret"
+)
+
+// The attributes of reconcile spans.
+const (
+	AttributeKind      = "crossplane.resource.kind"
+	AttributeNamespace = "crossplane.resource.namespace"
+	AttributeName      = "crossplane.resource.name"
+)
+
+const tracerName = "github.com/crossplaneio/crossplane/pkg/controller/gcp/tracing"
+
+// Options configure the export of spans.
+type Options struct {
+	// Endpoint of the OTLP gRPC collector to which spans are exported, e.g.
+	// otel-collector:4317. Spans are not exported if it is empty.
+	Endpoint string
+
+	// Insecure exports spans without TLS.
+	Insecure bool
+}
+
+// Setup exports spans to the OTLP collector configured by the supplied
+// options. Spans that have not yet been exported are flushed when the manager
+// stops.
+func Setup(mgr ctrl.Manager, o Options) error {
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(o.Endpoint)}
+	if o.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	exp, err := otlptracegrpc.New(context.Background(), opts...)
+	if err != nil {
+		return errors.Wrapf(err, "cannot create OTLP exporter for %s", o.Endpoint)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(sdkresource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(ServiceName))),
+	)
+
+	if err := mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		<-stop
+		return errors.Wrap(tp.Shutdown(context.Background()), "cannot flush spans")
+	})); err != nil {
+		return err
+	}
+
+	otel.SetTracerProvider(tp)
+	return nil
+}
+
+// StartReconcile starts a span for the reconcile of the supplied kind of
+// managed resource. The span is the parent of any phase span started using
+// the returned context.
+func StartReconcile(ctx context.Context, kind string, nn types.NamespacedName) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, kind+" reconcile", trace.WithAttributes(
+		attribute.String(AttributeKind, kind),
+		attribute.String(AttributeNamespace, nn.Namespace),
+		attribute.String(AttributeName, nn.Name),
+	))
+}
+
+// StartPhase starts a span for the supplied phase of a reconcile.
+func StartPhase(ctx context.Context, phase string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, phase)
+}
+
+// End ends the supplied span, recording the supplied error if it is not nil.
+// The error is returned so that a call may be wrapped, e.g.
+// return tracing.End(span, err).
+func End(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	return err
+}
+
+// HTTPClient returns an HTTP client authenticated using the supplied
+// credentials, which records a span for each request it makes. The spans are
+// children of any span in the context with which the request is made.
+func HTTPClient(ctx context.Context, creds *google.Credentials) *http.Client {
+	base := &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
+	return oauth2.NewClient(context.WithValue(ctx, oauth2.HTTPClient, base), creds.TokenSource)
+}
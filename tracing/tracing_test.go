@@ -0,0 +1,139 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+// record installs a tracer provider that records ended spans. The returned
+// function restores the previous tracer provider.
+func record() (*tracetest.SpanRecorder, func()) {
+	sr := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	return sr, func() { otel.SetTracerProvider(prev) }
+}
+
+func TestPhases(t *testing.T) {
+	errBoom := errors.New("boom")
+	sr, restore := record()
+	defer restore()
+
+	ctx, span := StartReconcile(context.Background(), "GKECluster", types.NamespacedName{Namespace: "cool", Name: "cluster"})
+	_, connect := StartPhase(ctx, PhaseConnect)
+	End(connect, nil)
+	_, observe := StartPhase(ctx, PhaseObserve)
+	if err := End(observe, errBoom); err != errBoom {
+		t.Errorf("End(...): want %s, got %s", errBoom, err)
+	}
+	End(span, nil)
+
+	ended := sr.Ended()
+	if len(ended) != 3 {
+		t.Fatalf("sr.Ended(): want 3 spans, got %d", len(ended))
+	}
+
+	root := ended[2]
+	if diff := cmp.Diff("GKECluster reconcile", root.Name()); diff != "" {
+		t.Errorf("reconcile span name: -want, +got:\n%s", diff)
+	}
+	for _, s := range ended[:2] {
+		if s.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("span %s: want parent %s, got %s", s.Name(), root.SpanContext().SpanID(), s.Parent().SpanID())
+		}
+	}
+	if ended[0].Status().Code != codes.Unset {
+		t.Errorf("span %s: want unset status, got %s", ended[0].Name(), ended[0].Status().Code)
+	}
+	if diff := cmp.Diff(errBoom.Error(), ended[1].Status().Description); diff != "" {
+		t.Errorf("span %s status: -want, +got:\n%s", ended[1].Name(), diff)
+	}
+}
+
+func TestEnd(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	cases := map[string]struct {
+		err  error
+		want error
+	}{
+		"Success": {},
+		"Error": {
+			err:  errBoom,
+			want: errBoom,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, span := StartPhase(context.Background(), PhaseUpdate)
+			if diff := cmp.Diff(tc.want, End(span, tc.err), test.EquateErrors()); diff != "" {
+				t.Errorf("End(...): -want error, +got error:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	sr, restore := record()
+	defer restore()
+
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	creds := &google.Credentials{TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "cool-token"})}
+	ctx, span := StartPhase(context.Background(), PhaseObserve)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	rsp, err := HTTPClient(context.Background(), creds).Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatalf("Do(...): %s", err)
+	}
+	rsp.Body.Close()
+	End(span, nil)
+
+	if diff := cmp.Diff("Bearer cool-token", auth); diff != "" {
+		t.Errorf("Authorization: -want, +got:\n%s", diff)
+	}
+
+	ended := sr.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("sr.Ended(): want 2 spans, got %d", len(ended))
+	}
+	if ended[0].Parent().SpanID() != ended[1].SpanContext().SpanID() {
+		t.Errorf("HTTP span: want parent %s, got %s", ended[1].SpanContext().SpanID(), ended[0].Parent().SpanID())
+	}
+}
@@ -0,0 +1,455 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package vertexai contains controllers that manage Vertex AI resources, such
+// as the Workbench notebook instances in which data scientists develop models.
+package vertexai
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	notebooks "google.golang.org/api/notebooks/v2"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/vertexai/v1alpha1"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp"
+	"github.com/crossplaneio/crossplane/pkg/clients/gcp/vertexai"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/compare"
+	"github.com/crossplaneio/crossplane/pkg/controller/gcp/provider"
+	"github.com/crossplaneio/crossplane/pkg/logging"
+	"github.com/crossplaneio/crossplane/pkg/meta"
+	"github.com/crossplaneio/crossplane/pkg/util/googleapi"
+)
+
+const (
+	controllerName   = "notebookinstances.vertexai.gcp.crossplane.io"
+	finalizerName    = "finalizer." + controllerName
+	reconcileTimeout = 1 * time.Minute
+
+	// instanceIDPrefix is prepended to the UID of a NotebookInstance to form
+	// the ID of its Workbench instance. IDs must begin with a letter.
+	instanceIDPrefix = "notebook-"
+)
+
+// States of a Workbench instance.
+const (
+	stateActive       = "ACTIVE"
+	stateProvisioning = "PROVISIONING"
+	stateStarting     = "STARTING"
+	stateInitializing = "INITIALIZING"
+	stateStopped      = "STOPPED"
+)
+
+// diskTypes are the persistent disk types a Workbench instance may use.
+var diskTypes = []string{"PD_STANDARD", "PD_SSD", "PD_BALANCED", "PD_EXTREME"}
+
+// zone matches a Compute Engine zone, e.g. us-central1-a. Workbench instances
+// are zonal.
+var zone = regexp.MustCompile(`^[a-z]+-[a-z]+[0-9]+-[a-z]$`)
+
+var log = logging.Logger.WithName("controller." + controllerName)
+
+// A createsyncdeleter can create, sync, and delete Workbench instances in an
+// external store - e.g. the GCP API. Each method returns true if the instance
+// requires further reconciliation.
+type createsyncdeleter interface {
+	Create(ctx context.Context, i *v1alpha1.NotebookInstance) (requeue bool)
+	Sync(ctx context.Context, i *v1alpha1.NotebookInstance) (requeue bool)
+	Delete(ctx context.Context, i *v1alpha1.NotebookInstance) (requeue bool)
+}
+
+// instances is a createsyncdeleter using the GCP Notebooks API.
+type instances struct {
+	client  vertexai.Client
+	project string
+}
+
+// Create creates a Workbench instance with the desired machine, image, disks,
+// and network.
+func (c *instances) Create(ctx context.Context, i *v1alpha1.NotebookInstance) bool {
+	i.Status.SetConditions(corev1alpha1.Creating())
+
+	if err := validateNotebookInstance(i.Spec.NotebookInstanceParameters); err != nil {
+		// Don't requeue invalid specs; they'll be reconciled again when updated.
+		i.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return false
+	}
+
+	parent := parentName(c.project, i.Spec.Location)
+	id := instanceIDPrefix + string(i.GetUID())
+
+	// Creation is asynchronous. We may have created the instance but failed
+	// to record its name.
+	if err := c.client.CreateInstance(ctx, parent, id, newInstance(i.Spec.NotebookInstanceParameters)); err != nil && !gcp.IsErrorAlreadyExists(err) {
+		i.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot create notebook instance")))
+		return true
+	}
+
+	i.Status.InstanceName = parent + "/instances/" + id
+	meta.AddFinalizer(i, finalizerName)
+	i.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return true
+}
+
+// Sync updates the instance if it differs from its spec. The instance is
+// available while it is active; users may stop their instances from the
+// Workbench UI when they're not in use.
+func (c *instances) Sync(ctx context.Context, i *v1alpha1.NotebookInstance) bool {
+	actual, err := c.client.GetInstance(ctx, i.Status.InstanceName)
+	if err != nil {
+		i.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return true
+	}
+	i.Status.State = actual.State
+	i.Status.ProxyURI = actual.ProxyUri
+
+	if err := validateNotebookInstance(i.Spec.NotebookInstanceParameters); err != nil {
+		i.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return false
+	}
+
+	desired := newInstance(i.Spec.NotebookInstanceParameters)
+	if mask := updateMask(desired, actual); len(mask) > 0 {
+		if err := c.client.UpdateInstance(ctx, i.Status.InstanceName, desired, strings.Join(mask, ",")); err != nil {
+			i.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot update notebook instance")))
+			return true
+		}
+		i.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+		return true
+	}
+
+	switch actual.State {
+	case stateActive:
+		i.Status.SetConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess())
+		return false
+	case stateProvisioning, stateStarting, stateInitializing:
+		i.Status.SetConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess())
+		return true
+	default:
+		i.Status.SetConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess())
+		return false
+	}
+}
+
+// Delete deletes the instance, along with its boot and data disks.
+func (c *instances) Delete(ctx context.Context, i *v1alpha1.NotebookInstance) bool {
+	i.Status.SetConditions(corev1alpha1.Deleting())
+
+	if i.Spec.ReclaimPolicy == corev1alpha1.ReclaimDelete {
+		if err := c.client.DeleteInstance(ctx, i.Status.InstanceName); err != nil && !googleapi.IsErrorNotFound(err) {
+			i.Status.SetConditions(corev1alpha1.ReconcileError(errors.Wrap(err, "cannot delete notebook instance")))
+			return true
+		}
+	}
+
+	meta.RemoveFinalizer(i, finalizerName)
+	i.Status.SetConditions(corev1alpha1.ReconcileSuccess())
+	return false
+}
+
+// validateNotebookInstance returns an error if the supplied parameters don't
+// specify a zone and machine type, specify more than one image, or specify
+// disks, accelerators, or a subnetwork that Workbench would reject.
+func validateNotebookInstance(p v1alpha1.NotebookInstanceParameters) error {
+	if !zone.MatchString(p.Location) {
+		return errors.Errorf("location %q must be a zone, e.g. us-central1-a", p.Location)
+	}
+	if p.MachineType == "" {
+		return errors.New("machineType must be specified")
+	}
+
+	if p.VMImage != nil && p.ContainerImage != nil {
+		return errors.New("at most one of vmImage or containerImage may be specified")
+	}
+	if img := p.VMImage; img != nil {
+		if img.Project == "" {
+			return errors.New("vmImage must specify a project")
+		}
+		if (img.Family == "") == (img.Name == "") {
+			return errors.New("vmImage must specify exactly one of family or name")
+		}
+	}
+
+	for _, a := range p.AcceleratorConfigs {
+		if a.CoreCount < 1 {
+			return errors.Errorf("accelerator %s must have at least one core", a.Type)
+		}
+	}
+
+	for _, d := range []*v1alpha1.Disk{p.BootDisk, p.DataDisk} {
+		if d != nil && d.Type != "" && !containsString(diskTypes, d.Type) {
+			return errors.Errorf("disk type %q must be one of %s", d.Type, strings.Join(diskTypes, ", "))
+		}
+	}
+
+	if p.Subnet != "" && p.Network == "" {
+		return errors.New("a subnet may only be specified with a network")
+	}
+	return nil
+}
+
+// newInstance returns the Workbench instance described by the supplied
+// parameters.
+func newInstance(p v1alpha1.NotebookInstanceParameters) *notebooks.Instance {
+	s := &notebooks.GceSetup{
+		MachineType:     p.MachineType,
+		DisablePublicIp: p.DisablePublicIP,
+		Metadata:        p.Metadata,
+	}
+
+	for _, a := range p.AcceleratorConfigs {
+		s.AcceleratorConfigs = append(s.AcceleratorConfigs, &notebooks.AcceleratorConfig{Type: a.Type, CoreCount: a.CoreCount})
+	}
+	if p.EnableGPUDriver {
+		s.GpuDriverConfig = &notebooks.GpuDriverConfig{EnableGpuDriver: true}
+	}
+
+	if p.ServiceAccount != "" {
+		s.ServiceAccounts = []*notebooks.ServiceAccount{{Email: p.ServiceAccount}}
+	}
+
+	if img := p.VMImage; img != nil {
+		s.VmImage = &notebooks.VmImage{Project: img.Project, Family: img.Family, Name: img.Name}
+	}
+	if img := p.ContainerImage; img != nil {
+		s.ContainerImage = &notebooks.ContainerImage{Repository: img.Repository, Tag: img.Tag}
+	}
+
+	if d := p.BootDisk; d != nil {
+		s.BootDisk = &notebooks.BootDisk{DiskSizeGb: d.SizeGB, DiskType: d.Type}
+	}
+	if d := p.DataDisk; d != nil {
+		s.DataDisks = []*notebooks.DataDisk{{DiskSizeGb: d.SizeGB, DiskType: d.Type}}
+	}
+
+	if p.Network != "" {
+		s.NetworkInterfaces = []*notebooks.NetworkInterface{{Network: p.Network, Subnet: p.Subnet}}
+	}
+
+	return &notebooks.Instance{
+		GceSetup:       s,
+		Labels:         p.Labels,
+		InstanceOwners: p.InstanceOwners,
+	}
+}
+
+// updateMask returns the paths of the fields of the actual instance that
+// differ from the desired instance and that Workbench can update. Metadata
+// that Workbench sets is preserved, so the desired instance's metadata is
+// updated to be merged into that of the actual instance. The machine type, accelerators, and GPU driver can
+// only be changed while the instance is stopped, so changes to them are
+// deferred until it is.
+func updateMask(desired, actual *notebooks.Instance) []string {
+	mask := []string{}
+	if !compare.Equal(desired.Labels, actual.Labels) {
+		mask = append(mask, "labels")
+	}
+
+	as := actual.GceSetup
+	if as == nil {
+		as = &notebooks.GceSetup{}
+	}
+	ds := desired.GceSetup
+
+	if !containsMetadata(as.Metadata, ds.Metadata) {
+		ds.Metadata = mergeMetadata(as.Metadata, ds.Metadata)
+		mask = append(mask, "gce_setup.metadata")
+	}
+
+	if actual.State != stateStopped {
+		return mask
+	}
+	// The API may return the machine type as a URL.
+	if ds.MachineType != "" && as.MachineType != ds.MachineType && !strings.HasSuffix(as.MachineType, "/"+ds.MachineType) {
+		mask = append(mask, "gce_setup.machine_type")
+	}
+	if !compare.Equal(ds.AcceleratorConfigs, as.AcceleratorConfigs) {
+		mask = append(mask, "gce_setup.accelerator_configs")
+	}
+	if (ds.GpuDriverConfig != nil && ds.GpuDriverConfig.EnableGpuDriver) != (as.GpuDriverConfig != nil && as.GpuDriverConfig.EnableGpuDriver) {
+		mask = append(mask, "gce_setup.gpu_driver_config.enable_gpu_driver")
+	}
+	return mask
+}
+
+// containsMetadata returns true if the supplied actual metadata contains all
+// of the supplied desired metadata.
+func containsMetadata(actual, desired map[string]string) bool {
+	for k, v := range desired {
+		if av, ok := actual[k]; !ok || av != v {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeMetadata returns the supplied actual metadata, overridden by the
+// supplied desired metadata.
+func mergeMetadata(actual, desired map[string]string) map[string]string {
+	m := make(map[string]string, len(actual)+len(desired))
+	for k, v := range actual {
+		m[k] = v
+	}
+	for k, v := range desired {
+		m[k] = v
+	}
+	return m
+}
+
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// parentName returns the fully qualified name of the supplied zone within the
+// supplied project, e.g. projects/p/locations/us-central1-a.
+func parentName(project, location string) string {
+	return fmt.Sprintf("projects/%s/locations/%s", project, location)
+}
+
+// A connecter returns a createsyncdeleter that can create, sync, and delete
+// Workbench instances with an external store - for example the GCP API.
+type connecter interface {
+	Connect(context.Context, *v1alpha1.NotebookInstance) (createsyncdeleter, error)
+}
+
+// providerConnecter is a connecter that returns a createsyncdeleter
+// authenticated using credentials read from a Crossplane Provider resource.
+type providerConnecter struct {
+	kube      client.Client
+	providers provider.Resolver
+	newClient func(ctx context.Context, creds *google.Credentials) (vertexai.Client, error)
+}
+
+// Connect returns a createsyncdeleter backed by the GCP API. GCP credentials
+// are read from the Crossplane Provider referenced by the supplied
+// NotebookInstance.
+func (c *providerConnecter) Connect(ctx context.Context, i *v1alpha1.NotebookInstance) (createsyncdeleter, error) {
+	p, err := c.providers.Get(ctx, c.kube, i, i.Spec.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := provider.ServiceCredentials(ctx, c.kube, p, provider.ServiceNotebooks, notebooks.CloudPlatformScope)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := c.newClient(ctx, creds)
+	return &instances{client: client, project: p.Spec.ProjectID}, errors.Wrap(err, "cannot create new Notebooks client")
+}
+
+// Reconciler reconciles NotebookInstances read from the Kubernetes API with
+// an external store, typically the GCP API.
+type Reconciler struct {
+	connecter
+	kube client.Client
+}
+
+// NotebookInstanceController is responsible for adding the Vertex AI
+// NotebookInstance controller and its corresponding reconciler to the manager
+// with any runtime configuration.
+type NotebookInstanceController struct {
+	// DefaultProvider is used by notebook instances that don't reference a
+	// provider.
+	DefaultProvider types.NamespacedName
+}
+
+// SetupWithManager creates a new NotebookInstance Controller and adds it to
+// the Manager with default RBAC. The Manager will set fields on the
+// Controller and start it when the Manager is Started.
+func (c *NotebookInstanceController) SetupWithManager(mgr ctrl.Manager) error {
+	providers := provider.Resolver{Default: c.DefaultProvider}
+	r := &Reconciler{
+		connecter: &providerConnecter{
+			kube:      mgr.GetClient(),
+			providers: providers,
+			newClient: vertexai.NewClient,
+		},
+		kube: mgr.GetClient(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerName).
+		For(&v1alpha1.NotebookInstance{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, providers.EnqueueRequestsForSecret(mgr.GetClient(), listNotebookInstances)).
+		Complete(r)
+}
+
+// Reconcile Vertex AI Workbench instances with the GCP API.
+func (r *Reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	log.V(logging.Debug).Info("reconciling", "kind", v1alpha1.NotebookInstanceKindAPIVersion, "request", req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	i := &v1alpha1.NotebookInstance{}
+	if err := r.kube.Get(ctx, req.NamespacedName, i); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{Requeue: false}, nil
+		}
+		return reconcile.Result{Requeue: false}, errors.Wrapf(err, "cannot get notebook instance %s", req.NamespacedName)
+	}
+
+	client, err := r.Connect(ctx, i)
+	if err != nil {
+		i.Status.SetConditions(corev1alpha1.ReconcileError(err))
+		return reconcile.Result{Requeue: true}, errors.Wrapf(r.kube.Update(ctx, i), "cannot update notebook instance %s", req.NamespacedName)
+	}
+
+	// The instance has been deleted from the API server. Delete from GCP.
+	if i.DeletionTimestamp != nil {
+		return reconcile.Result{Requeue: client.Delete(ctx, i)}, errors.Wrapf(r.kube.Update(ctx, i), "cannot update notebook instance %s", req.NamespacedName)
+	}
+
+	// The instance is unnamed. Assume it has not been created in GCP.
+	if i.Status.InstanceName == "" {
+		return reconcile.Result{Requeue: client.Create(ctx, i)}, errors.Wrapf(r.kube.Update(ctx, i), "cannot update notebook instance %s", req.NamespacedName)
+	}
+
+	// The instance exists in the API server and GCP. Sync it.
+	return reconcile.Result{Requeue: client.Sync(ctx, i)}, errors.Wrapf(r.kube.Update(ctx, i), "cannot update notebook instance %s", req.NamespacedName)
+}
+
+// listNotebookInstances is a provider.Lister of Workbench instances.
+func listNotebookInstances(ctx context.Context, kube client.Client) ([]provider.Reference, error) {
+	l := &v1alpha1.NotebookInstanceList{}
+	if err := kube.List(ctx, l); err != nil {
+		return nil, err
+	}
+	refs := make([]provider.Reference, 0, len(l.Items))
+	for i := range l.Items {
+		refs = append(refs, provider.Reference{Resource: &l.Items[i], Provider: l.Items[i].Spec.ProviderReference})
+	}
+	return refs, nil
+}
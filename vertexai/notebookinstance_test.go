@@ -0,0 +1,532 @@
+/*
+Copyright 2019 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vertexai
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+	notebooks "google.golang.org/api/notebooks/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1alpha1 "github.com/crossplaneio/crossplane/apis/core/v1alpha1"
+	"github.com/crossplaneio/crossplane/gcp/apis/vertexai/v1alpha1"
+	fakevertexai "github.com/crossplaneio/crossplane/pkg/clients/gcp/vertexai/fake"
+	"github.com/crossplaneio/crossplane/pkg/test"
+)
+
+const (
+	namespace    = "cool-namespace"
+	name         = "cool-notebook"
+	uid          = types.UID("definitely-a-uuid")
+	project      = "coolProject"
+	location     = "us-central1-a"
+	providerName = "cool-gcp"
+	machineType  = "e2-standard-4"
+	proxyURI     = "abc123-dot-us-central1.notebooks.googleusercontent.com"
+)
+
+var (
+	ctx           = context.Background()
+	errorBoom     = errors.New("boom")
+	errorNotFound = &googleapi.Error{Code: http.StatusNotFound}
+	instanceName  = parentName(project, location) + "/instances/" + instanceIDPrefix + string(uid)
+)
+
+// Test that our Reconciler implementation satisfies the Reconciler interface.
+var _ reconcile.Reconciler = &Reconciler{}
+
+type instanceModifier func(*v1alpha1.NotebookInstance)
+
+func withConditions(c ...corev1alpha1.Condition) instanceModifier {
+	return func(i *v1alpha1.NotebookInstance) { i.Status.SetConditions(c...) }
+}
+
+func withFinalizers(f ...string) instanceModifier {
+	return func(i *v1alpha1.NotebookInstance) { i.ObjectMeta.Finalizers = f }
+}
+
+func withReclaimPolicy(p corev1alpha1.ReclaimPolicy) instanceModifier {
+	return func(i *v1alpha1.NotebookInstance) { i.Spec.ReclaimPolicy = p }
+}
+
+func withInstanceName(n string) instanceModifier {
+	return func(i *v1alpha1.NotebookInstance) { i.Status.InstanceName = n }
+}
+
+func withState(s string) instanceModifier {
+	return func(i *v1alpha1.NotebookInstance) {
+		i.Status.State = s
+		i.Status.ProxyURI = proxyURI
+	}
+}
+
+func withMachineType(t string) instanceModifier {
+	return func(i *v1alpha1.NotebookInstance) { i.Spec.MachineType = t }
+}
+
+func withContainerImage(img *v1alpha1.ContainerImage) instanceModifier {
+	return func(i *v1alpha1.NotebookInstance) { i.Spec.ContainerImage = img }
+}
+
+func notebookInstance(im ...instanceModifier) *v1alpha1.NotebookInstance {
+	i := &v1alpha1.NotebookInstance{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+			Finalizers: []string{},
+		},
+		Spec: v1alpha1.NotebookInstanceSpec{
+			ResourceSpec: corev1alpha1.ResourceSpec{
+				ProviderReference: &corev1.ObjectReference{Namespace: namespace, Name: providerName},
+			},
+			NotebookInstanceParameters: v1alpha1.NotebookInstanceParameters{
+				Location:    location,
+				MachineType: machineType,
+				VMImage:     &v1alpha1.VMImage{Project: "cloud-notebooks-managed", Family: "workbench-instances"},
+				BootDisk:    &v1alpha1.Disk{SizeGB: 150, Type: "PD_BALANCED"},
+				Labels:      map[string]string{"team": "cool"},
+				Metadata:    map[string]string{"idle-timeout-seconds": "3600"},
+			},
+		},
+	}
+
+	for _, m := range im {
+		m(i)
+	}
+
+	return i
+}
+
+// actualInstance returns the instance GCP would return for the default spec,
+// including the metadata Workbench sets.
+func actualInstance(state string) *notebooks.Instance {
+	return &notebooks.Instance{
+		Name:     instanceName,
+		State:    state,
+		ProxyUri: proxyURI,
+		Labels:   map[string]string{"team": "cool"},
+		GceSetup: &notebooks.GceSetup{
+			MachineType: "https://www.googleapis.com/compute/v1/projects/" + project + "/zones/" + location + "/machineTypes/" + machineType,
+			Metadata: map[string]string{
+				"idle-timeout-seconds": "3600",
+				"proxy-mode":           "service_account",
+			},
+		},
+	}
+}
+
+func TestCreate(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		i           *v1alpha1.NotebookInstance
+		want        *v1alpha1.NotebookInstance
+		wantRequeue bool
+	}{
+		{
+			name: "Successful",
+			csd: &instances{project: project, client: &fakevertexai.MockClient{
+				MockCreateInstance: func(_ context.Context, parent, id string, i *notebooks.Instance) error {
+					if want := instanceIDPrefix + string(uid); id != want {
+						t.Errorf("CreateInstance(...): want id %s, got %s", want, id)
+					}
+					if i.GceSetup.MachineType != machineType {
+						t.Errorf("CreateInstance(...): want machine type %s, got %s", machineType, i.GceSetup.MachineType)
+					}
+					return nil
+				},
+			}},
+			i: notebookInstance(),
+			want: notebookInstance(
+				withFinalizers(finalizerName),
+				withInstanceName(instanceName),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "AlreadyExists",
+			csd: &instances{project: project, client: &fakevertexai.MockClient{
+				MockCreateInstance: func(_ context.Context, _, _ string, _ *notebooks.Instance) error {
+					return &googleapi.Error{Code: http.StatusConflict}
+				},
+			}},
+			i: notebookInstance(),
+			want: notebookInstance(
+				withFinalizers(finalizerName),
+				withInstanceName(instanceName),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "TwoImages",
+			csd:  &instances{project: project, client: &fakevertexai.MockClient{}},
+			i:    notebookInstance(withContainerImage(&v1alpha1.ContainerImage{Repository: "gcr.io/cool/notebook"})),
+			want: notebookInstance(
+				withContainerImage(&v1alpha1.ContainerImage{Repository: "gcr.io/cool/notebook"}),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.New("at most one of vmImage or containerImage may be specified"))),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "Failed",
+			csd: &instances{project: project, client: &fakevertexai.MockClient{
+				MockCreateInstance: func(_ context.Context, _, _ string, _ *notebooks.Instance) error { return errorBoom },
+			}},
+			i: notebookInstance(),
+			want: notebookInstance(
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot create notebook instance"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Create(ctx, tc.i)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Create(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.i, test.EquateConditions()); diff != "" {
+				t.Errorf("i: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		i           *v1alpha1.NotebookInstance
+		want        *v1alpha1.NotebookInstance
+		wantRequeue bool
+	}{
+		{
+			name: "Available",
+			csd: &instances{project: project, client: &fakevertexai.MockClient{
+				MockGetInstance: func(_ context.Context, _ string) (*notebooks.Instance, error) {
+					return actualInstance(stateActive), nil
+				},
+			}},
+			i: notebookInstance(withInstanceName(instanceName)),
+			want: notebookInstance(
+				withInstanceName(instanceName),
+				withState(stateActive),
+				withConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "Provisioning",
+			csd: &instances{project: project, client: &fakevertexai.MockClient{
+				MockGetInstance: func(_ context.Context, _ string) (*notebooks.Instance, error) {
+					return actualInstance(stateProvisioning), nil
+				},
+			}},
+			i: notebookInstance(withInstanceName(instanceName)),
+			want: notebookInstance(
+				withInstanceName(instanceName),
+				withState(stateProvisioning),
+				withConditions(corev1alpha1.Creating(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "Stopped",
+			csd: &instances{project: project, client: &fakevertexai.MockClient{
+				MockGetInstance: func(_ context.Context, _ string) (*notebooks.Instance, error) {
+					return actualInstance(stateStopped), nil
+				},
+			}},
+			i: notebookInstance(withInstanceName(instanceName)),
+			want: notebookInstance(
+				withInstanceName(instanceName),
+				withState(stateStopped),
+				withConditions(corev1alpha1.Unavailable(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "MachineTypeChangeDeferred",
+			csd: &instances{project: project, client: &fakevertexai.MockClient{
+				MockGetInstance: func(_ context.Context, _ string) (*notebooks.Instance, error) {
+					return actualInstance(stateActive), nil
+				},
+			}},
+			i: notebookInstance(withInstanceName(instanceName), withMachineType("n2-highmem-8")),
+			want: notebookInstance(
+				withInstanceName(instanceName),
+				withMachineType("n2-highmem-8"),
+				withState(stateActive),
+				withConditions(corev1alpha1.Available(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "MachineTypeChangedWhileStopped",
+			csd: &instances{project: project, client: &fakevertexai.MockClient{
+				MockGetInstance: func(_ context.Context, _ string) (*notebooks.Instance, error) {
+					return actualInstance(stateStopped), nil
+				},
+				MockUpdateInstance: func(_ context.Context, _ string, i *notebooks.Instance, mask string) error {
+					if mask != "gce_setup.machine_type" {
+						t.Errorf("UpdateInstance(...): want mask gce_setup.machine_type, got %s", mask)
+					}
+					if i.GceSetup.MachineType != "n2-highmem-8" {
+						t.Errorf("UpdateInstance(...): want machine type n2-highmem-8, got %s", i.GceSetup.MachineType)
+					}
+					return nil
+				},
+			}},
+			i: notebookInstance(withInstanceName(instanceName), withMachineType("n2-highmem-8")),
+			want: notebookInstance(
+				withInstanceName(instanceName),
+				withMachineType("n2-highmem-8"),
+				withState(stateStopped),
+				withConditions(corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedUpdate",
+			csd: &instances{project: project, client: &fakevertexai.MockClient{
+				MockGetInstance: func(_ context.Context, _ string) (*notebooks.Instance, error) {
+					return actualInstance(stateStopped), nil
+				},
+				MockUpdateInstance: func(_ context.Context, _ string, _ *notebooks.Instance, _ string) error { return errorBoom },
+			}},
+			i: notebookInstance(withInstanceName(instanceName), withMachineType("n2-highmem-8")),
+			want: notebookInstance(
+				withInstanceName(instanceName),
+				withMachineType("n2-highmem-8"),
+				withState(stateStopped),
+				withConditions(corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot update notebook instance"))),
+			),
+			wantRequeue: true,
+		},
+		{
+			name: "FailedGet",
+			csd: &instances{project: project, client: &fakevertexai.MockClient{
+				MockGetInstance: func(_ context.Context, _ string) (*notebooks.Instance, error) { return nil, errorBoom },
+			}},
+			i: notebookInstance(withInstanceName(instanceName)),
+			want: notebookInstance(
+				withInstanceName(instanceName),
+				withConditions(corev1alpha1.ReconcileError(errorBoom)),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Sync(ctx, tc.i)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Sync(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.i, test.EquateConditions()); diff != "" {
+				t.Errorf("i: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		csd         createsyncdeleter
+		i           *v1alpha1.NotebookInstance
+		want        *v1alpha1.NotebookInstance
+		wantRequeue bool
+	}{
+		{
+			name: "ReclaimRetain",
+			csd:  &instances{project: project, client: &fakevertexai.MockClient{}},
+			i:    notebookInstance(withInstanceName(instanceName), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimRetain)),
+			want: notebookInstance(
+				withInstanceName(instanceName),
+				withReclaimPolicy(corev1alpha1.ReclaimRetain),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteAlreadyGone",
+			csd: &instances{project: project, client: &fakevertexai.MockClient{
+				MockDeleteInstance: func(_ context.Context, _ string) error { return errorNotFound },
+			}},
+			i: notebookInstance(withInstanceName(instanceName), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: notebookInstance(
+				withInstanceName(instanceName),
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileSuccess()),
+			),
+			wantRequeue: false,
+		},
+		{
+			name: "ReclaimDeleteFailed",
+			csd: &instances{project: project, client: &fakevertexai.MockClient{
+				MockDeleteInstance: func(_ context.Context, _ string) error { return errorBoom },
+			}},
+			i: notebookInstance(withInstanceName(instanceName), withFinalizers(finalizerName), withReclaimPolicy(corev1alpha1.ReclaimDelete)),
+			want: notebookInstance(
+				withInstanceName(instanceName),
+				withFinalizers(finalizerName),
+				withReclaimPolicy(corev1alpha1.ReclaimDelete),
+				withConditions(corev1alpha1.Deleting(), corev1alpha1.ReconcileError(errors.Wrap(errorBoom, "cannot delete notebook instance"))),
+			),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRequeue := tc.csd.Delete(ctx, tc.i)
+
+			if gotRequeue != tc.wantRequeue {
+				t.Errorf("tc.csd.Delete(...): want: %t got: %t", tc.wantRequeue, gotRequeue)
+			}
+
+			if diff := cmp.Diff(tc.want, tc.i, test.EquateConditions()); diff != "" {
+				t.Errorf("i: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateNotebookInstance(t *testing.T) {
+	valid := func(m func(*v1alpha1.NotebookInstanceParameters)) v1alpha1.NotebookInstanceParameters {
+		p := notebookInstance().Spec.NotebookInstanceParameters
+		m(&p)
+		return p
+	}
+
+	cases := map[string]struct {
+		p       v1alpha1.NotebookInstanceParameters
+		wantErr bool
+	}{
+		"Valid": {
+			p: notebookInstance().Spec.NotebookInstanceParameters,
+		},
+		"RegionalLocation": {
+			p:       valid(func(p *v1alpha1.NotebookInstanceParameters) { p.Location = "us-central1" }),
+			wantErr: true,
+		},
+		"NoMachineType": {
+			p:       valid(func(p *v1alpha1.NotebookInstanceParameters) { p.MachineType = "" }),
+			wantErr: true,
+		},
+		"ImageFamilyAndName": {
+			p: valid(func(p *v1alpha1.NotebookInstanceParameters) {
+				p.VMImage = &v1alpha1.VMImage{Project: "cloud-notebooks-managed", Family: "workbench-instances", Name: "workbench-instances-v20240101"}
+			}),
+			wantErr: true,
+		},
+		"NoAcceleratorCores": {
+			p: valid(func(p *v1alpha1.NotebookInstanceParameters) {
+				p.AcceleratorConfigs = []v1alpha1.AcceleratorConfig{{Type: "NVIDIA_TESLA_T4"}}
+			}),
+			wantErr: true,
+		},
+		"UnknownDiskType": {
+			p: valid(func(p *v1alpha1.NotebookInstanceParameters) {
+				p.DataDisk = &v1alpha1.Disk{SizeGB: 100, Type: "LOCAL_SSD"}
+			}),
+			wantErr: true,
+		},
+		"SubnetWithoutNetwork": {
+			p: valid(func(p *v1alpha1.NotebookInstanceParameters) {
+				p.Subnet = "projects/coolProject/regions/us-central1/subnetworks/cool"
+			}),
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateNotebookInstance(tc.p)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("validateNotebookInstance(...): want error %t, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestUpdateMask(t *testing.T) {
+	cases := map[string]struct {
+		desired      *notebooks.Instance
+		actual       *notebooks.Instance
+		want         []string
+		wantMetadata map[string]string
+	}{
+		"UpToDate": {
+			desired:      newInstance(notebookInstance().Spec.NotebookInstanceParameters),
+			actual:       actualInstance(stateActive),
+			want:         []string{},
+			wantMetadata: map[string]string{"idle-timeout-seconds": "3600"},
+		},
+		"LabelsAndMetadataChanged": {
+			desired: newInstance(notebookInstance().Spec.NotebookInstanceParameters),
+			actual: func() *notebooks.Instance {
+				i := actualInstance(stateActive)
+				i.Labels = map[string]string{"team": "uncool"}
+				i.GceSetup.Metadata["idle-timeout-seconds"] = "600"
+				return i
+			}(),
+			want:         []string{"labels", "gce_setup.metadata"},
+			wantMetadata: map[string]string{"idle-timeout-seconds": "3600", "proxy-mode": "service_account"},
+		},
+		"AcceleratorsChangedWhileStopped": {
+			desired: func() *notebooks.Instance {
+				p := notebookInstance().Spec.NotebookInstanceParameters
+				p.AcceleratorConfigs = []v1alpha1.AcceleratorConfig{{Type: "NVIDIA_TESLA_T4", CoreCount: 1}}
+				p.EnableGPUDriver = true
+				return newInstance(p)
+			}(),
+			actual:       actualInstance(stateStopped),
+			want:         []string{"gce_setup.accelerator_configs", "gce_setup.gpu_driver_config.enable_gpu_driver"},
+			wantMetadata: map[string]string{"idle-timeout-seconds": "3600"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := updateMask(tc.desired, tc.actual)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("updateMask(...): -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantMetadata, tc.desired.GceSetup.Metadata); diff != "" {
+				t.Errorf("updateMask(...): desired metadata -want, +got:\n%s", diff)
+			}
+		})
+	}
+}